@@ -7,7 +7,7 @@ import (
 	"github.com/adewale/rogue_planet/pkg/config"
 )
 
-func cmdAddAll(opts AddAllOptions) error {
+func cmdAddAll(ctx context.Context, opts AddAllOptions) error {
 	if opts.FeedsFile == "" {
 		return fmt.Errorf("feeds file is required")
 	}
@@ -29,8 +29,6 @@ func cmdAddAll(opts AddAllOptions) error {
 		return nil
 	}
 
-	ctx := context.Background()
-
 	fmt.Fprintf(opts.Output, "Adding %d feeds from %s...\n", len(feedURLs), opts.FeedsFile)
 
 	// Add each feed to database
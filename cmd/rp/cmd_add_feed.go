@@ -3,27 +3,54 @@ package main
 import (
 	"context"
 	"fmt"
+	"io"
+
+	"github.com/adewale/rogue_planet/pkg/repository"
 )
 
-func cmdAddFeed(opts AddFeedOptions) error {
+func cmdAddFeed(ctx context.Context, opts AddFeedOptions) error {
 	if opts.URL == "" {
 		return fmt.Errorf("URL is required")
 	}
 
-	_, repo, cleanup, err := openConfigAndRepo(opts.ConfigPath)
+	p, err := openPlanet(opts.ConfigPath, opts.Logger, opts.Output)
 	if err != nil {
 		return err
 	}
-	defer cleanup()
-
-	ctx := context.Background()
+	defer p.Close()
 
-	// Add feed
-	id, err := repo.AddFeed(ctx, opts.URL, "")
+	if opts.Fetch {
+		fmt.Fprintf(opts.Output, "Fetching %s...\n", opts.URL)
+	}
+	added, err := p.AddFeed(ctx, opts.URL, opts.Fetch)
 	if err != nil {
-		return fmt.Errorf("failed to add feed: %w", err)
+		return fmt.Errorf("feed not added: %w", err)
 	}
 
-	fmt.Fprintf(opts.Output, "✓ Added feed: %s (ID: %d)\n", opts.URL, id)
+	if !opts.Fetch {
+		fmt.Fprintf(opts.Output, "✓ Added feed: %s (ID: %d)\n", added.Feed.URL, added.Feed.ID)
+	} else {
+		title := added.Feed.Title
+		if title == "" {
+			title = "(untitled)"
+		}
+		fmt.Fprintf(opts.Output, "✓ Added feed: %s - %s (ID: %d)\n", title, added.Feed.URL, added.Feed.ID)
+		fmt.Fprintf(opts.Output, "  Stored %d entries\n", added.Entries)
+	}
+	warnDuplicates(opts.Output, "  ", added.Duplicates)
 	return nil
 }
+
+// warnDuplicates tells the user about feeds already in the planet that look
+// like the one just added, and how to drop one, indenting the warning to
+// line up with the output before it
+func warnDuplicates(w io.Writer, indent string, duplicates []repository.Feed) {
+	if len(duplicates) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "%s⚠ The planet already has what looks like the same feed:\n", indent)
+	for _, d := range duplicates {
+		fmt.Fprintf(w, "%s    %s\n", indent, describeFeed(d))
+	}
+	fmt.Fprintf(w, "%s  Keep one and remove the others with 'rp remove-feed URL'\n", indent)
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/adewale/rogue_planet/pkg/repository"
+)
+
+// bulkFeedVerbs gives the past tense of each bulk action, for its summary
+var bulkFeedVerbs = map[string]string{
+	"remove":     "Removed",
+	"deactivate": "Deactivated",
+	"reactivate": "Reactivated",
+}
+
+// cmdBulkFeeds removes, deactivates, or reactivates every feed the
+// selection matches, after listing them. Removal asks for confirmation
+// unless forced.
+func cmdBulkFeeds(ctx context.Context, opts BulkFeedsOptions) error {
+	verb, ok := bulkFeedVerbs[opts.Action]
+	if !ok {
+		return fmt.Errorf("unknown action %q", opts.Action)
+	}
+
+	_, repo, cleanup, err := openConfigAndRepo(opts.ConfigPath)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	// Deactivating only applies to the feeds still being fetched
+	feeds, err := repo.GetFeeds(ctx, opts.Action == "deactivate")
+	if err != nil {
+		return fmt.Errorf("failed to get feeds: %w", err)
+	}
+	selected, err := opts.Selection.Filter(ctx, repo, feeds)
+	if err != nil {
+		return err
+	}
+	if len(selected) == 0 {
+		fmt.Fprintln(opts.Output, "No feeds match")
+		return nil
+	}
+
+	entryCounts := make(map[int64]int64, len(selected))
+	var totalEntries int64
+	fmt.Fprintf(opts.Output, "%d feeds match:\n", len(selected))
+	for _, feed := range selected {
+		count, err := repo.GetEntryCountForFeed(ctx, feed.ID)
+		if err != nil {
+			return fmt.Errorf("failed to count entries: %w", err)
+		}
+		entryCounts[feed.ID] = count
+		totalEntries += count
+		fmt.Fprintf(opts.Output, "  %s%s\n", feed.URL, describeBulkFeed(feed, count))
+	}
+	fmt.Fprintln(opts.Output)
+
+	if opts.DryRun {
+		fmt.Fprintf(opts.Output, "DRY RUN: Would %s %d feeds\n", opts.Action, len(selected))
+		return nil
+	}
+
+	if opts.Action == "remove" && !opts.Force {
+		if err := checkCanPrompt(opts.Input); err != nil {
+			return err
+		}
+		question := fmt.Sprintf("Remove these %d feeds and all %d entries? (y/N): ", len(selected), totalEntries)
+		confirmed, err := confirmPrompt(ctx, opts.Input, opts.Output, question)
+		if err != nil {
+			return err
+		}
+		if !confirmed {
+			fmt.Fprintln(opts.Output, "Cancelled.")
+			return &ErrUserCancelled{"operation cancelled by user"}
+		}
+	}
+
+	done := 0
+	var deleted int64
+	var failed []string
+	for _, feed := range selected {
+		var err error
+		switch opts.Action {
+		case "remove":
+			err = repo.RemoveFeed(ctx, feed.ID)
+		case "deactivate":
+			err = repo.DeactivateFeed(ctx, feed.ID)
+		case "reactivate":
+			err = repo.ReactivateFeed(ctx, feed.ID)
+		}
+		if err != nil {
+			fmt.Fprintf(opts.Output, "  ✗ %s: %v\n", feed.URL, err)
+			failed = append(failed, feed.URL)
+			continue
+		}
+		done++
+		deleted += entryCounts[feed.ID]
+	}
+
+	switch opts.Action {
+	case "remove":
+		fmt.Fprintf(opts.Output, "✓ %s %d feeds (%d entries deleted)\n", verb, done, deleted)
+	case "reactivate":
+		fmt.Fprintf(opts.Output, "✓ %s %d feeds; they will be fetched on the next update\n", verb, done)
+	default:
+		fmt.Fprintf(opts.Output, "✓ %s %d feeds\n", verb, done)
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to %s %d feeds: %s", opts.Action, len(failed), strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// describeBulkFeed summarises a feed for the list of feeds a bulk command
+// will change: its title, entries, errors, and state
+func describeBulkFeed(feed repository.Feed, entries int64) string {
+	var parts []string
+	if feed.Title != "" {
+		parts = append(parts, feed.Title)
+	}
+	parts = append(parts, fmt.Sprintf("%d entries", entries))
+	if feed.FetchErrorCount > 0 {
+		parts = append(parts, fmt.Sprintf("%d errors", feed.FetchErrorCount))
+	}
+	if !feed.Active {
+		parts = append(parts, "inactive")
+	}
+	return " (" + strings.Join(parts, ", ") + ")"
+}
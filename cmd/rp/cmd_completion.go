@@ -0,0 +1,293 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// completionShells are the shells rp completion writes scripts for
+var completionShells = []string{"bash", "zsh", "fish"}
+
+// completionSetup explains how to load the completion scripts
+const completionSetup = `Load the completions into the current shell with
+  source <(rp completion bash)
+  source <(rp completion zsh)
+  rp completion fish | source
+or install them for new shells with
+  rp completion bash > ~/.local/share/bash-completion/completions/rp
+  rp completion zsh > "${fpath[1]}/_rp"
+  rp completion fish > ~/.config/fish/completions/rp.fish`
+
+// completionCommand is a command as the completion scripts offer it
+type completionCommand struct {
+	Name    string
+	Summary string
+	Flags   []completionFlag
+	Words   []string // Completions of the command's first argument
+}
+
+// completionFlag is a flag as the completion scripts offer it
+type completionFlag struct {
+	Name   string // Without dashes
+	Usage  string
+	Valued bool // Takes a value, rather than being a boolean switch
+}
+
+// dashed returns the flag as it is typed: -f, or --name for longer names
+func (f completionFlag) dashed() string {
+	if len(f.Name) == 1 {
+		return "-" + f.Name
+	}
+	return "--" + f.Name
+}
+
+// completionGlobalFlags are offered before the command
+var completionGlobalFlags = []completionFlag{
+	{Name: "planet", Usage: "Run the command for a registered planet", Valued: true},
+	{Name: "config", Usage: "Path to config file", Valued: true},
+	{Name: "verbose", Usage: "Enable verbose logging"},
+	{Name: "quiet", Usage: "Only show errors"},
+}
+
+func cmdCompletion(opts CompletionOptions) error {
+	var script string
+	switch opts.Shell {
+	case "bash":
+		script = bashCompletion(opts.Commands)
+	case "zsh":
+		script = zshCompletion(opts.Commands)
+	case "fish":
+		script = fishCompletion(opts.Commands)
+	default:
+		return fmt.Errorf("unknown shell %q (want %s)", opts.Shell, strings.Join(completionShells, ", "))
+	}
+	_, err := fmt.Fprint(opts.Output, script)
+	return err
+}
+
+// flagWords returns the flags as they are typed, separated by spaces
+func flagWords(flags []completionFlag) string {
+	words := make([]string, len(flags))
+	for i, f := range flags {
+		words[i] = f.dashed()
+	}
+	return strings.Join(words, " ")
+}
+
+// valuedFlagPattern is a shell case pattern matching the flags, global or
+// any command's, that take a value
+func valuedFlagPattern(commands []completionCommand) string {
+	seen := make(map[string]bool)
+	var patterns []string
+	add := func(flags []completionFlag) {
+		for _, f := range flags {
+			if f.Valued && !seen[f.Name] {
+				seen[f.Name] = true
+				patterns = append(patterns, "-"+f.Name)
+				if len(f.Name) > 1 {
+					patterns = append(patterns, "--"+f.Name)
+				}
+			}
+		}
+	}
+	add(completionGlobalFlags)
+	for _, c := range commands {
+		add(c.Flags)
+	}
+	return strings.Join(patterns, "|")
+}
+
+func bashCompletion(commands []completionCommand) string {
+	var b strings.Builder
+	names := make([]string, len(commands))
+	for i, c := range commands {
+		names[i] = c.Name
+	}
+
+	b.WriteString(`# bash completion for rp, written by 'rp completion bash'
+_rp() {
+    local cur=${COMP_WORDS[COMP_CWORD]} prev=${COMP_WORDS[COMP_CWORD-1]} cmd="" i
+    for ((i = 1; i < COMP_CWORD; i++)); do
+        case ${COMP_WORDS[i]} in
+            --planet|-planet|--config|-config) ((i++)) ;;
+            -*) ;;
+            *) cmd=${COMP_WORDS[i]}; break ;;
+        esac
+    done
+
+    # A flag's value: leave it to the default completion of file names
+    case $prev in
+        ` + valuedFlagPattern(commands) + `) return ;;
+    esac
+
+    local flags words
+    case $cmd in
+`)
+	fmt.Fprintf(&b, "        \"\") flags=%q; words=%q ;;\n", flagWords(completionGlobalFlags), strings.Join(names, " "))
+	for _, c := range commands {
+		fmt.Fprintf(&b, "        %s) flags=%q; words=%q ;;\n", c.Name, flagWords(c.Flags), strings.Join(c.Words, " "))
+	}
+	b.WriteString(`    esac
+
+    if [[ $cur == -* ]]; then
+        COMPREPLY=($(compgen -W "$flags" -- "$cur"))
+    elif [[ -n $words && ( -z $cmd || $((i + 1)) -eq $COMP_CWORD ) ]]; then
+        COMPREPLY=($(compgen -W "$words" -- "$cur"))
+    fi
+}
+complete -o default -F _rp rp
+`)
+	return b.String()
+}
+
+// zshQuote quotes s for zsh as a single-quoted word
+func zshQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// zshDescribed returns the items, each with its description, as _describe
+// takes them
+func zshDescribed(items, descriptions []string) string {
+	quoted := make([]string, len(items))
+	for i, item := range items {
+		entry := strings.ReplaceAll(item, ":", `\:`)
+		if descriptions != nil {
+			entry += ":" + descriptions[i]
+		}
+		quoted[i] = zshQuote(entry)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// zshFlags returns the flags, described, as _describe takes them
+func zshFlags(flags []completionFlag) string {
+	names := make([]string, len(flags))
+	usages := make([]string, len(flags))
+	for i, f := range flags {
+		names[i], usages[i] = f.dashed(), f.Usage
+	}
+	return zshDescribed(names, usages)
+}
+
+func zshCompletion(commands []completionCommand) string {
+	var b strings.Builder
+	names := make([]string, len(commands))
+	summaries := make([]string, len(commands))
+	for i, c := range commands {
+		names[i], summaries[i] = c.Name, c.Summary
+	}
+
+	b.WriteString(`#compdef rp
+# zsh completion for rp, written by 'rp completion zsh'
+_rp() {
+    local cmd i
+    local -a flags values
+    for ((i = 2; i < CURRENT; i++)); do
+        case $words[i] in
+            --planet|-planet|--config|-config) ((i++)) ;;
+            -*) ;;
+            *) cmd=$words[i]; break ;;
+        esac
+    done
+
+    # A flag's value: complete file names
+    case $words[CURRENT-1] in
+        ` + valuedFlagPattern(commands) + `) _files; return ;;
+    esac
+
+    case $cmd in
+`)
+	fmt.Fprintf(&b, "        '') flags=(%s)\n            values=(%s) ;;\n", zshFlags(completionGlobalFlags), zshDescribed(names, summaries))
+	for _, c := range commands {
+		fmt.Fprintf(&b, "        %s) flags=(%s)\n            values=(%s) ;;\n", c.Name, zshFlags(c.Flags), zshDescribed(c.Words, nil))
+	}
+	b.WriteString(`    esac
+
+    if [[ $PREFIX == -* ]]; then
+        _describe -t flags flag flags
+    elif (( $#values )) && { [[ -z $cmd ]] || (( i + 1 == CURRENT )); }; then
+        _describe -t values value values
+    else
+        _files
+    fi
+}
+
+if [[ $funcstack[1] == _rp ]]; then
+    _rp "$@"
+else
+    compdef _rp rp
+fi
+`)
+	return b.String()
+}
+
+// fishQuote quotes s for fish as a single-quoted word
+func fishQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return "'" + strings.ReplaceAll(s, "'", `\'`) + "'"
+}
+
+// fishFlag returns the complete command offering flag f when cond holds
+func fishFlag(cond string, f completionFlag) string {
+	option := "-l " + f.Name
+	if len(f.Name) == 1 {
+		option = "-s " + f.Name
+	}
+	if f.Valued {
+		option += " -r"
+	}
+	return fmt.Sprintf("complete -c rp -n %s %s -d %s\n", fishQuote(cond), option, fishQuote(f.Usage))
+}
+
+func fishCompletion(commands []completionCommand) string {
+	var b strings.Builder
+	b.WriteString(`# fish completion for rp, written by 'rp completion fish'
+
+# The command given so far, skipping global flags and their values
+function __rp_command
+    set -l tokens (commandline -opc)
+    set -e tokens[1]
+    while set -q tokens[1]
+        switch $tokens[1]
+            case --planet -planet --config -config
+                set -e tokens[1]
+            case '-*'
+            case '*'
+                echo $tokens[1]
+                return
+        end
+        set -e tokens[1]
+    end
+end
+
+# Whether the command given is $argv[1] ("" for none yet)
+function __rp_using
+    set -l cmd (__rp_command)
+    test "$cmd" = "$argv[1]"
+end
+
+# Whether the command's first argument is next
+function __rp_first_argument
+    set -l tokens (commandline -opc)
+    set -l cmd (__rp_command)
+    test "$tokens[-1]" = "$cmd"
+end
+
+`)
+	for _, f := range completionGlobalFlags {
+		b.WriteString(fishFlag(`__rp_using ""`, f))
+	}
+	for _, c := range commands {
+		fmt.Fprintf(&b, "complete -c rp -n %s -f -a %s -d %s\n", fishQuote(`__rp_using ""`), c.Name, fishQuote(c.Summary))
+	}
+	for _, c := range commands {
+		cond := "__rp_using " + c.Name
+		for _, f := range c.Flags {
+			b.WriteString(fishFlag(cond, f))
+		}
+		if len(c.Words) > 0 {
+			fmt.Fprintf(&b, "complete -c rp -n %s -f -a %s\n", fishQuote(cond+"; and __rp_first_argument"), fishQuote(strings.Join(c.Words, " ")))
+		}
+	}
+	return b.String()
+}
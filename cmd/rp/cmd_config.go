@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/adewale/rogue_planet/pkg/config"
+)
+
+func cmdConfig(opts ConfigOptions) error {
+	path := config.Locate(opts.ConfigPath)
+
+	switch opts.Action {
+	case "get":
+		values, err := config.Get(path, opts.Key)
+		if err != nil {
+			return err
+		}
+		if len(values) == 0 {
+			return fmt.Errorf("%s is not set in %s", opts.Key, path)
+		}
+		for _, v := range values {
+			fmt.Fprintln(opts.Output, v)
+		}
+		return nil
+	default:
+		if err := config.Set(path, opts.Key, opts.Value); err != nil {
+			return err
+		}
+		fmt.Fprintf(opts.Output, "✓ Set %s = %s in %s\n", opts.Key, opts.Value, path)
+		return nil
+	}
+}
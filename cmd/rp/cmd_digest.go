@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/adewale/rogue_planet/pkg/digest"
+	"github.com/adewale/rogue_planet/pkg/planet"
+)
+
+func cmdDigest(ctx context.Context, opts DigestOptions) error {
+	cfg, err := planet.LoadConfig(opts.ConfigPath)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	dc := cfg.Digest
+	if opts.Send && dc.SMTPAddr == "" {
+		return fmt.Errorf("--send needs smtp_addr in the [digest] section")
+	}
+
+	// Parse the templates before doing anything else, so a broken one is
+	// reported whether or not there are entries
+	tmpl, err := digest.NewTemplates(dc.Subject, dc.TextTemplate, dc.HTMLTemplate)
+	if err != nil {
+		return err
+	}
+
+	p, err := planet.Open(cfg, planet.Options{Output: opts.Output})
+	if err != nil {
+		return fmt.Errorf("failed to open planet: %w", err)
+	}
+	defer p.Close()
+
+	since := time.Now().Add(-opts.Since)
+	d, err := p.Digest(ctx, since)
+	if err != nil {
+		return fmt.Errorf("failed to build digest: %w", err)
+	}
+	msg, err := tmpl.Render(d)
+	if err != nil {
+		return err
+	}
+
+	if !opts.Send {
+		out := "Subject: " + msg.Subject + "\n\n" + msg.Text
+		if opts.HTML {
+			out = msg.HTML
+		}
+		if opts.OutputFile == "" {
+			fmt.Fprint(opts.Output, out)
+			return nil
+		}
+		if err := os.WriteFile(opts.OutputFile, []byte(out), 0644); err != nil {
+			return fmt.Errorf("failed to write digest: %w", err)
+		}
+		fmt.Fprintf(opts.Output, "✓ Wrote digest of %d entries to %s\n", len(d.Entries), opts.OutputFile)
+		return nil
+	}
+
+	if d.Empty() {
+		fmt.Fprintf(opts.Output, "No new entries since %s; no digest sent\n", since.Format(time.RFC3339))
+		return nil
+	}
+	subscribers, err := digest.Subscribers(dc.Subscribers, dc.SubscribersFile)
+	if err != nil {
+		return err
+	}
+	if len(subscribers) == 0 {
+		return fmt.Errorf("no digest subscribers: set subscribers or subscribers_file in [digest]")
+	}
+
+	mailer := digest.Mailer{
+		Addr:     dc.SMTPAddr,
+		From:     dc.EmailFrom,
+		Username: dc.SMTPUsername,
+		Password: dc.SMTPPassword,
+	}
+	sent, err := mailer.Send(ctx, subscribers, msg)
+	if sent > 0 {
+		fmt.Fprintf(opts.Output, "✓ Sent digest of %d entries to %d of %d subscribers\n", len(d.Entries), sent, len(subscribers))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to send digest: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,432 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/adewale/rogue_planet/pkg/config"
+	"github.com/adewale/rogue_planet/pkg/crawler"
+	"github.com/adewale/rogue_planet/pkg/feedurl"
+	"github.com/adewale/rogue_planet/pkg/generator"
+	"github.com/adewale/rogue_planet/pkg/planet"
+	"github.com/adewale/rogue_planet/pkg/repository"
+)
+
+// doctorTimeout limits each DNS lookup and connection attempt
+const doctorTimeout = 5 * time.Second
+
+// doctorReport prints the outcome of each check and counts the problems
+type doctorReport struct {
+	out      io.Writer
+	problems int
+}
+
+func (r *doctorReport) section(name string) {
+	fmt.Fprintf(r.out, "%s\n", name)
+}
+
+func (r *doctorReport) ok(format string, args ...any) {
+	fmt.Fprintf(r.out, "  ✓ "+format+"\n", args...)
+}
+
+func (r *doctorReport) skip(format string, args ...any) {
+	fmt.Fprintf(r.out, "  - "+format+"\n", args...)
+}
+
+// problem reports a failed check and, if there is one, how to fix it
+func (r *doctorReport) problem(fix, format string, args ...any) {
+	r.problems++
+	fmt.Fprintf(r.out, "  ✗ "+format+"\n", args...)
+	if fix != "" {
+		fmt.Fprintf(r.out, "    → %s\n", fix)
+	}
+}
+
+// warning reports something that works but is unsafe, without counting it
+// as a problem
+func (r *doctorReport) warning(fix, format string, args ...any) {
+	fmt.Fprintf(r.out, "  ⚠ "+format+"\n", args...)
+	if fix != "" {
+		fmt.Fprintf(r.out, "    → %s\n", fix)
+	}
+}
+
+// summary prints the number of problems found
+func (r *doctorReport) summary() {
+	fmt.Fprintln(r.out)
+	if r.problems == 0 {
+		fmt.Fprintln(r.out, "No problems found.")
+		return
+	}
+	fmt.Fprintf(r.out, "Found %s.\n", plural(r.problems, "problem", "problems"))
+}
+
+func (r *doctorReport) finish() error {
+	r.summary()
+	if r.problems == 0 {
+		return nil
+	}
+	return fmt.Errorf("doctor found %s", plural(r.problems, "problem", "problems"))
+}
+
+func cmdDoctor(ctx context.Context, opts DoctorOptions) error {
+	report := &doctorReport{out: opts.Output}
+
+	report.section("Configuration")
+	opts.ConfigPath = config.Locate(opts.ConfigPath)
+	cfg, err := config.LoadFromFile(opts.ConfigPath)
+	if err != nil {
+		report.problem("fix the file, or run 'rp init' to create one", "cannot load %s: %v", opts.ConfigPath, err)
+		return report.finish()
+	}
+	if err := cfg.Validate(); err != nil {
+		report.problem("edit "+opts.ConfigPath+" (rp verify checks it in more detail)", "invalid configuration: %v", err)
+	} else {
+		report.ok("%s is valid", opts.ConfigPath)
+	}
+	doctorInsecureFeeds(cfg, report)
+
+	report.section("Database")
+	feeds := doctorDatabase(ctx, cfg, opts.Fix, report)
+
+	report.section("Feeds")
+	addrs := doctorFeedURLs(feeds, planet.SSRFPolicy(cfg), report)
+	doctorFeedSections(cfg, feeds, report)
+
+	report.section("Network")
+	if opts.Offline {
+		report.skip("skipped (--offline)")
+	} else {
+		var dialer net.Dialer
+		results := checkEndpoints(ctx, addrs, cfg.Planet.ConcurrentFetch, net.DefaultResolver.LookupHost, dialer.DialContext)
+		doctorNetwork(results, report)
+	}
+
+	report.section("Template")
+	doctorTemplate(ctx, cfg, report)
+
+	return report.finish()
+}
+
+// doctorInsecureFeeds warns of the feeds fetched without verifying their
+// TLS certificates
+func doctorInsecureFeeds(cfg *config.Config, report *doctorReport) {
+	var feedURLs []string
+	for feedURL := range planet.InsecureFeeds(cfg) {
+		feedURLs = append(feedURLs, feedURL)
+	}
+	sort.Strings(feedURLs)
+	for _, feedURL := range feedURLs {
+		report.warning("trust the site's certificate authority with tls_ca_file instead, and remove insecure_skip_verify",
+			"TLS certificates of %s are not verified (insecure_skip_verify); the feed could be forged", feedURL)
+	}
+}
+
+// doctorDatabase checks the database's integrity and looks for orphaned
+// rows, deleting them if fix is set. It returns the feeds in the database.
+func doctorDatabase(ctx context.Context, cfg *config.Config, fix bool, report *doctorReport) []repository.Feed {
+	sqlite := cfg.Database.Driver != repository.DriverPostgres
+	if _, err := os.Stat(cfg.Database.Path); sqlite && os.IsNotExist(err) {
+		report.problem("run 'rp init' to create it", "database %s does not exist", cfg.Database.Path)
+		return nil
+	}
+
+	repo, err := planet.OpenDatabase(cfg)
+	if err != nil {
+		report.problem("check the [database] section of the config", "cannot open database: %v", err)
+		return nil
+	}
+	defer repo.Close()
+
+	problems, err := repo.CheckIntegrity(ctx)
+	switch {
+	case err != nil:
+		report.problem("restore the database from a backup", "integrity check failed: %v", err)
+	case len(problems) > 0:
+		fix := fmt.Sprintf("restore the database from a backup, or salvage it with: sqlite3 %s .recover | sqlite3 recovered.db", cfg.Database.Path)
+		report.problem(fix, "integrity check found %s, starting with: %s", plural(len(problems), "problem", "problems"), problems[0])
+	case sqlite:
+		report.ok("integrity check passed")
+	}
+
+	orphans, err := repo.CountOrphans(ctx)
+	switch {
+	case err != nil:
+		report.problem("", "cannot look for orphaned rows: %v", err)
+	case orphans.Total() == 0:
+		report.ok("no orphaned rows")
+	case fix:
+		deleted, err := repo.DeleteOrphans(ctx)
+		if err != nil {
+			report.problem("", "cannot delete orphaned rows: %v", err)
+		} else {
+			report.ok("deleted %s", describeOrphans(deleted))
+		}
+	default:
+		report.problem("run 'rp doctor --fix' to delete them", "%s left behind by removed feeds", describeOrphans(orphans))
+	}
+
+	feeds, err := repo.GetFeeds(ctx, false)
+	if err != nil {
+		report.problem("", "cannot read feeds: %v", err)
+		return nil
+	}
+	return feeds
+}
+
+// describeOrphans lists the non-zero counts of orphaned rows
+func describeOrphans(o repository.Orphans) string {
+	var parts []string
+	for _, c := range []struct {
+		n            int64
+		one, several string
+	}{
+		{o.Entries, "entry", "entries"},
+		{o.EntryCategories, "entry category", "entry categories"},
+		{o.FeedCategories, "feed category", "feed categories"},
+		{o.FetchLog, "fetch log record", "fetch log records"},
+	} {
+		switch {
+		case c.n == 1:
+			parts = append(parts, "1 "+c.one)
+		case c.n > 1:
+			parts = append(parts, fmt.Sprintf("%d %s", c.n, c.several))
+		}
+	}
+	if len(parts) == 0 {
+		return "no orphaned rows"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// doctorFeedURLs reports feeds whose URLs cannot be fetched, and returns the
+// host:port addresses of the active feeds that can
+func doctorFeedURLs(feeds []repository.Feed, policy crawler.Policy, report *doctorReport) []string {
+	seen := make(map[string]bool)
+	var addrs []string
+	bad := 0
+	for _, feed := range feeds {
+		u, err := url.Parse(feed.URL)
+		if err == nil && u.Hostname() == "" {
+			err = crawler.ErrInvalidURL
+		}
+		if err == nil {
+			err = policy.ValidateURL(feed.URL)
+		}
+		if err != nil {
+			bad++
+			report.problem(fmt.Sprintf("rp remove-feed %s, then add the correct URL", feed.URL), "feed URL %q cannot be fetched: %v", feed.URL, err)
+			continue
+		}
+		if !feed.Active {
+			continue
+		}
+
+		port := u.Port()
+		if port == "" {
+			port = "443"
+			if strings.EqualFold(u.Scheme, "http") {
+				port = "80"
+			}
+		}
+		addr := net.JoinHostPort(u.Hostname(), port)
+		if !seen[addr] {
+			seen[addr] = true
+			addrs = append(addrs, addr)
+		}
+	}
+
+	switch {
+	case len(feeds) == 0:
+		report.skip("no feeds in the database")
+	case bad == 0:
+		report.ok("all %d feed URLs are well formed", len(feeds))
+	}
+	sort.Strings(addrs)
+	return addrs
+}
+
+// doctorFeedSections warns of [feed], [filters] and [sanitize] sections
+// naming a URL no feed in the database has, whose settings apply to nothing
+func doctorFeedSections(cfg *config.Config, feeds []repository.Feed, report *doctorReport) {
+	if len(feeds) == 0 {
+		return
+	}
+	stored := make(map[string]bool, len(feeds))
+	byKey := make(map[string]string, len(feeds))
+	for _, feed := range feeds {
+		stored[feed.URL] = true
+		byKey[feedurl.Key(feed.URL)] = feed.URL
+	}
+
+	var sections []string
+	for feedURL := range cfg.FeedSettings {
+		sections = append(sections, "feed "+feedURL)
+	}
+	for feedURL := range cfg.FeedFilters {
+		sections = append(sections, "filters "+feedURL)
+	}
+	for feedURL := range cfg.FeedSanitize {
+		sections = append(sections, "sanitize "+feedURL)
+	}
+	sort.Strings(sections)
+	for _, section := range sections {
+		kind, feedURL, _ := strings.Cut(section, " ")
+		if stored[feedURL] {
+			continue
+		}
+		fix := "remove the section, or add the feed with 'rp add-feed " + feedURL + "'"
+		if other, ok := byKey[feedurl.Key(feedURL)]; ok {
+			fix = fmt.Sprintf("rename it to [%s %s]", kind, other)
+		}
+		report.warning(fix, "[%s] matches no feed, so its settings are not used", section)
+	}
+}
+
+// endpointResult is the outcome of checking one feed host
+type endpointResult struct {
+	addr     string // host:port
+	resolved bool   // DNS lookup succeeded
+	err      error
+}
+
+// checkEndpoints resolves each address's host and opens a TCP connection to
+// it, checking up to workers addresses at a time. Results are in the order
+// of addrs.
+func checkEndpoints(ctx context.Context, addrs []string, workers int,
+	lookup func(ctx context.Context, host string) ([]string, error),
+	dial func(ctx context.Context, network, addr string) (net.Conn, error)) []endpointResult {
+	results := make([]endpointResult, len(addrs))
+	sem := make(chan struct{}, max(workers, 1))
+	var wg sync.WaitGroup
+	for i, addr := range addrs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			results[i] = endpointResult{addr: addr}
+			host, _, _ := net.SplitHostPort(addr)
+			if net.ParseIP(host) == nil {
+				lookupCtx, cancel := context.WithTimeout(ctx, doctorTimeout)
+				_, err := lookup(lookupCtx, host)
+				cancel()
+				if err != nil {
+					results[i].err = err
+					return
+				}
+			}
+			results[i].resolved = true
+
+			dialCtx, cancel := context.WithTimeout(ctx, doctorTimeout)
+			defer cancel()
+			conn, err := dial(dialCtx, "tcp", addr)
+			if err != nil {
+				results[i].err = err
+				return
+			}
+			conn.Close()
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// doctorNetwork reports the hosts that could not be resolved or reached. When
+// none can, the problem is this machine's network rather than the feeds.
+func doctorNetwork(results []endpointResult, report *doctorReport) {
+	var failed []endpointResult
+	for _, r := range results {
+		if r.err != nil {
+			failed = append(failed, r)
+		}
+	}
+
+	switch {
+	case len(results) == 0:
+		report.skip("no active feeds to check")
+	case len(failed) == 0:
+		report.ok("all %d feed hosts resolve and accept connections", len(results))
+	case len(failed) == len(results) && len(results) > 1:
+		report.problem("check this machine's network connection, DNS servers (/etc/resolv.conf), and firewall", "none of the %d feed hosts could be reached, e.g. %s: %v", len(results), failed[0].addr, failed[0].err)
+	default:
+		for _, r := range failed {
+			if r.resolved {
+				report.problem("the site may be down, or a firewall may block it; rp list-feeds --errors shows the feed's recent errors", "cannot connect to %s: %v", r.addr, r.err)
+			} else {
+				report.problem("check the feed URL's host name; if it is right, check this machine's DNS servers", "cannot resolve %s: %v", r.addr, r.err)
+			}
+		}
+		report.ok("%d of %d feed hosts resolve and accept connections", len(results)-len(failed), len(results))
+	}
+}
+
+// doctorTemplate renders the configured template with sample entries, with
+// and without their optional fields, so a template that would fail during
+// a run fails here instead
+func doctorTemplate(ctx context.Context, cfg *config.Config, report *doctorReport) {
+	gen, err := planet.NewGenerator(cfg)
+	if err != nil {
+		report.problem("fix the template's syntax, or remove 'template' from [planet] to use the built-in one", "cannot load template: %v", err)
+		return
+	}
+
+	if err := gen.Generate(ctx, io.Discard, doctorSampleData(cfg)); err != nil {
+		report.problem("check the fields the template uses against the template reference in the README", "template fails to render sample entries: %v", err)
+		return
+	}
+	if cfg.Planet.Template != "" {
+		report.ok("%s renders sample entries", cfg.Planet.Template)
+	} else {
+		report.ok("built-in template renders sample entries")
+	}
+}
+
+// doctorSampleData is the site doctorTemplate renders
+func doctorSampleData(cfg *config.Config) generator.TemplateData {
+	now := time.Now()
+	feeds := []generator.FeedData{
+		{Title: "Sample Blog", Link: "https://blog.example.com/", URL: "https://blog.example.com/feed.xml", LastUpdated: now},
+		{URL: "https://untitled.example.com/feed.xml", ErrorCount: 3},
+	}
+	entries := []generator.EntryData{
+		{
+			ID:         "https://blog.example.com/posts/1",
+			Title:      "A <em>sample</em> entry",
+			Link:       "https://blog.example.com/posts/1",
+			Author:     "Sample Author",
+			FeedTitle:  "Sample Blog",
+			FeedLink:   "https://blog.example.com/",
+			Published:  now.Add(-time.Hour),
+			Updated:    now.Add(-time.Hour),
+			Content:    template.HTML(`<p>Sample content with <a href="https://example.com/">a link</a>.</p>`),
+			Summary:    "Sample summary",
+			Categories: []string{"sample", "go"},
+		},
+		// An entry with only the fields every feed provides
+		{
+			ID:        "urn:sample:2",
+			Published: now.AddDate(0, 0, -2),
+		},
+	}
+	return generator.TemplateData{
+		Title:       cfg.Planet.Name,
+		Link:        cfg.Planet.Link,
+		OwnerName:   cfg.Planet.OwnerName,
+		OwnerEmail:  cfg.Planet.OwnerEmail,
+		Entries:     entries,
+		GroupByDate: cfg.Planet.GroupByDate,
+		Feeds:       feeds,
+		AtomURL:     generator.AtomFileName,
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/adewale/rogue_planet/pkg/repository"
+)
+
+func cmdEditFeed(ctx context.Context, opts EditFeedOptions) error {
+	if opts.URL == "" {
+		return fmt.Errorf("URL is required")
+	}
+
+	_, repo, cleanup, err := openConfigAndRepo(opts.ConfigPath)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	feed, err := repo.GetFeedByURL(ctx, opts.URL)
+	if err != nil {
+		return fmt.Errorf("feed not found: %w", err)
+	}
+
+	edit := repository.FeedEdit{Title: opts.Title, Active: opts.Active, Categories: opts.Tags}
+	if err := repo.EditFeed(ctx, feed.ID, edit); err != nil {
+		return fmt.Errorf("failed to edit feed: %w", err)
+	}
+
+	fmt.Fprintf(opts.Output, "✓ Updated feed: %s\n", opts.URL)
+	if opts.Title != nil {
+		if *opts.Title == "" {
+			fmt.Fprintln(opts.Output, "  Title: the feed's own, from the next update")
+		} else {
+			fmt.Fprintf(opts.Output, "  Title: %s\n", *opts.Title)
+		}
+	}
+	if opts.Tags != nil {
+		if tags, err := repo.GetFeedCategories(ctx, feed.ID); err == nil && len(tags) > 0 {
+			fmt.Fprintf(opts.Output, "  Tags: %s\n", strings.Join(tags, ", "))
+		} else if err == nil {
+			fmt.Fprintln(opts.Output, "  Tags: none")
+		}
+	}
+	if opts.Active != nil {
+		if *opts.Active {
+			fmt.Fprintln(opts.Output, "  Active: fetched on the next update")
+		} else {
+			fmt.Fprintln(opts.Output, "  Active: no; not fetched until reactivated")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/adewale/rogue_planet/pkg/repository"
+)
+
+// exportedEntry is an entry as rp export writes it in JSON Lines
+type exportedEntry struct {
+	FeedURL     string                  `json:"feed_url"`
+	FeedTitle   string                  `json:"feed_title"`
+	ID          string                  `json:"id"`
+	Title       string                  `json:"title"`
+	Link        string                  `json:"link"`
+	Author      string                  `json:"author,omitempty"`
+	Authors     []string                `json:"authors,omitempty"`
+	Published   time.Time               `json:"published"`
+	Updated     time.Time               `json:"updated"`
+	FirstSeen   time.Time               `json:"first_seen"`
+	Categories  []string                `json:"categories,omitempty"`
+	Language    string                  `json:"language,omitempty"`
+	ExternalURL string                  `json:"external_url,omitempty"`
+	Image       string                  `json:"image,omitempty"`
+	WordCount   int                     `json:"word_count"`
+	Summary     string                  `json:"summary,omitempty"`
+	Content     string                  `json:"content"`
+	ContentType string                  `json:"content_type,omitempty"`
+	Attachments []repository.Attachment `json:"attachments,omitempty"`
+}
+
+// exportCSVHeader names the columns of a CSV export. Lists are joined with
+// "; " and attachments are given by their URLs, separated by spaces.
+var exportCSVHeader = []string{
+	"feed_url", "feed_title", "id", "title", "link", "author", "authors",
+	"published", "updated", "first_seen", "categories", "language",
+	"external_url", "image", "word_count", "summary", "content", "attachments",
+}
+
+func cmdExport(ctx context.Context, opts ExportOptions) error {
+	_, repo, cleanup, err := openConfigAndRepo(opts.ConfigPath)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	feeds, err := repo.GetFeeds(ctx, false)
+	if err != nil {
+		return fmt.Errorf("failed to get feeds: %w", err)
+	}
+	filter := repository.EntryFilter{Since: opts.Since, Until: opts.Until}
+	if !opts.Selection.Empty() {
+		if feeds, err = opts.Selection.Filter(ctx, repo, feeds); err != nil {
+			return err
+		}
+		filter.FeedIDs = make([]int64, 0, len(feeds))
+		for _, feed := range feeds {
+			filter.FeedIDs = append(filter.FeedIDs, feed.ID)
+		}
+	}
+
+	var count int
+	if opts.Format == "sqlite" {
+		count, err = exportSQLite(ctx, repo, feeds, filter, opts.OutputFile)
+	} else {
+		count, err = exportEntries(ctx, repo, feeds, filter, opts)
+	}
+	if err != nil {
+		return err
+	}
+
+	if opts.OutputFile != "" {
+		fmt.Fprintf(opts.Output, "✓ Exported %d entries to %s\n", count, opts.OutputFile)
+	}
+	return nil
+}
+
+// exportEntries writes the entries filter selects as JSON Lines or CSV to
+// the output file, or to opts.Output, returning how many it wrote
+func exportEntries(ctx context.Context, repo *repository.Repository, feeds []repository.Feed, filter repository.EntryFilter, opts ExportOptions) (int, error) {
+	byID := make(map[int64]repository.Feed, len(feeds))
+	for _, feed := range feeds {
+		byID[feed.ID] = feed
+	}
+
+	out := opts.Output
+	var file *os.File
+	if opts.OutputFile != "" {
+		var err error
+		if file, err = os.Create(opts.OutputFile); err != nil {
+			return 0, fmt.Errorf("failed to create file: %w", err)
+		}
+		out = file
+	}
+	w := bufio.NewWriter(out)
+
+	var err error
+	write := exportJSONLine(w)
+	var csvWriter *csv.Writer
+	if opts.Format == "csv" {
+		csvWriter = csv.NewWriter(w)
+		err = csvWriter.Write(exportCSVHeader)
+		write = exportCSVRecord(csvWriter)
+	}
+
+	count := 0
+	if err == nil {
+		err = repo.ExportEntries(ctx, filter, func(entry repository.Entry) error {
+			count++
+			return write(byID[entry.FeedID], entry)
+		})
+	}
+	if err == nil && csvWriter != nil {
+		csvWriter.Flush()
+		err = csvWriter.Error()
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if file != nil {
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			os.Remove(opts.OutputFile)
+		}
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to export entries: %w", err)
+	}
+	return count, nil
+}
+
+// exportJSONLine returns a function writing an entry to w as a line of JSON
+func exportJSONLine(w io.Writer) func(repository.Feed, repository.Entry) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return func(feed repository.Feed, e repository.Entry) error {
+		return enc.Encode(exportedEntry{
+			FeedURL:     feed.URL,
+			FeedTitle:   feed.Title,
+			ID:          e.EntryID,
+			Title:       e.Title,
+			Link:        e.Link,
+			Author:      e.Author,
+			Authors:     e.Authors,
+			Published:   e.Published,
+			Updated:     e.Updated,
+			FirstSeen:   e.FirstSeen,
+			Categories:  e.Categories,
+			Language:    e.Language,
+			ExternalURL: e.ExternalURL,
+			Image:       e.Image,
+			WordCount:   e.WordCount,
+			Summary:     e.Summary,
+			Content:     e.Content,
+			ContentType: e.ContentType,
+			Attachments: e.Attachments,
+		})
+	}
+}
+
+// exportCSVRecord returns a function writing an entry to w as a CSV record
+// with the columns of exportCSVHeader
+func exportCSVRecord(w *csv.Writer) func(repository.Feed, repository.Entry) error {
+	return func(feed repository.Feed, e repository.Entry) error {
+		attachments := make([]string, 0, len(e.Attachments))
+		for _, a := range e.Attachments {
+			attachments = append(attachments, a.URL)
+		}
+		return w.Write([]string{
+			feed.URL, feed.Title, e.EntryID, e.Title, e.Link, e.Author,
+			strings.Join(e.Authors, "; "),
+			e.Published.Format(time.RFC3339), e.Updated.Format(time.RFC3339), e.FirstSeen.Format(time.RFC3339),
+			strings.Join(e.Categories, "; "), e.Language, e.ExternalURL, e.Image,
+			strconv.Itoa(e.WordCount), e.Summary, e.Content,
+			strings.Join(attachments, " "),
+		})
+	}
+}
+
+// exportSQLite copies feeds and the entries filter selects into a new rp
+// database at path, leaving out fetch history, caches, and other state
+// only needed to run the planet. It returns how many entries it copied.
+func exportSQLite(ctx context.Context, repo *repository.Repository, feeds []repository.Feed, filter repository.EntryFilter, path string) (int, error) {
+	// Never add to, or replace, a database that is already there
+	if _, err := os.Stat(path); err == nil {
+		return 0, fmt.Errorf("%s already exists", path)
+	}
+	dst, err := repository.New(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create database: %w", err)
+	}
+
+	count, err := copyEntries(ctx, repo, dst, feeds, filter)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		for _, suffix := range []string{"", "-wal", "-shm"} {
+			os.Remove(path + suffix)
+		}
+		return 0, fmt.Errorf("failed to export database: %w", err)
+	}
+	return count, nil
+}
+
+// copyEntries copies feeds, with their categories, and the entries filter
+// selects from repo to dst
+func copyEntries(ctx context.Context, repo, dst *repository.Repository, feeds []repository.Feed, filter repository.EntryFilter) (int, error) {
+	categories, err := repo.GetAllFeedCategories(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("get feed categories: %w", err)
+	}
+	ids := make(map[int64]int64, len(feeds))
+	for _, feed := range feeds {
+		id, err := dst.AddFeed(ctx, feed.URL, feed.Title)
+		if err != nil {
+			return 0, err
+		}
+		if err := dst.UpdateFeed(ctx, id, feed.Title, feed.Link, feed.Updated); err != nil {
+			return 0, err
+		}
+		if err := dst.SetFeedCategories(ctx, id, categories[feed.ID]); err != nil {
+			return 0, err
+		}
+		if !feed.Active {
+			if err := dst.DeactivateFeed(ctx, id); err != nil {
+				return 0, err
+			}
+		}
+		ids[feed.ID] = id
+	}
+
+	count := 0
+	err = dst.Batch(ctx, func(tx repository.FeedRepository) error {
+		return repo.ExportEntries(ctx, filter, func(entry repository.Entry) error {
+			entry.FeedID = ids[entry.FeedID]
+			count++
+			return tx.UpsertEntry(ctx, &entry)
+		})
+	})
+	return count, err
+}
@@ -6,9 +6,10 @@ import (
 	"os"
 
 	"github.com/adewale/rogue_planet/pkg/opml"
+	"github.com/adewale/rogue_planet/pkg/timeprovider"
 )
 
-func cmdExportOPML(opts ExportOPMLOptions) error {
+func cmdExportOPML(ctx context.Context, opts ExportOPMLOptions) error {
 	// Load config
 	cfg, repo, cleanup, err := openConfigAndRepo(opts.ConfigPath)
 	if err != nil {
@@ -16,8 +17,6 @@ func cmdExportOPML(opts ExportOPMLOptions) error {
 	}
 	defer cleanup()
 
-	ctx := context.Background()
-
 	// Get all feeds
 	repoFeeds, err := repo.GetFeeds(ctx, false)
 	if err != nil {
@@ -29,6 +28,11 @@ func cmdExportOPML(opts ExportOPMLOptions) error {
 		return nil
 	}
 
+	categories, err := repo.GetAllFeedCategories(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get feed categories: %w", err)
+	}
+
 	// Convert to OPML feeds
 	opmlFeeds := make([]opml.Feed, 0, len(repoFeeds))
 	for _, feed := range repoFeeds {
@@ -38,9 +42,13 @@ func cmdExportOPML(opts ExportOPMLOptions) error {
 		}
 
 		opmlFeeds = append(opmlFeeds, opml.Feed{
-			Title:   title,
-			FeedURL: feed.URL,
-			WebURL:  feed.Link,
+			Title:       title,
+			FeedURL:     feed.URL,
+			WebURL:      feed.Link,
+			Categories:  categories[feed.ID],
+			LastFetched: feed.LastFetched,
+			ErrorCount:  feed.FetchErrorCount,
+			LastError:   feed.FetchError,
 		})
 	}
 
@@ -51,7 +59,7 @@ func cmdExportOPML(opts ExportOPMLOptions) error {
 		OwnerEmail: cfg.Planet.OwnerEmail,
 	}
 
-	opmlDoc, err := opml.Generate(opmlFeeds, metadata)
+	opmlDoc, err := opml.GenerateWithOptions(opmlFeeds, metadata, opml.Options{Health: opts.Health}, timeprovider.WallClock{})
 	if err != nil {
 		return fmt.Errorf("failed to generate OPML: %w", err)
 	}
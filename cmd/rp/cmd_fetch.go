@@ -2,22 +2,121 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/adewale/rogue_planet/pkg/config"
+	"github.com/adewale/rogue_planet/pkg/fetcher"
+	"github.com/adewale/rogue_planet/pkg/logging"
+	"github.com/adewale/rogue_planet/pkg/planet"
+	"github.com/adewale/rogue_planet/pkg/repository"
 )
 
 func cmdFetch(ctx context.Context, opts FetchOptions) error {
-	setVerboseLogging(opts.Verbose)
-
-	cfg, err := loadConfig(opts.ConfigPath)
+	cfg, err := planet.LoadConfig(opts.ConfigPath)
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
 
-	fmt.Fprintln(opts.Output, "Fetching feeds...")
-	if err := fetchFeeds(ctx, cfg, opts.Logger); err != nil {
+	if opts.TraceFeed != "" {
+		return traceFeed(ctx, cfg, opts)
+	}
+	if opts.Offline && cfg.Planet.ResponseCacheDir == "" {
+		return fmt.Errorf("--offline replays saved responses: set response_cache_dir in [planet] and fetch once online first")
+	}
+
+	p, err := planet.Open(cfg, planet.Options{Logger: opts.Logger, Output: opts.Output})
+	if err != nil {
+		return fmt.Errorf("failed to open planet: %w", err)
+	}
+	defer p.Close()
+
+	abort, stopListening := abortOnSignal(opts.Logger)
+	defer stopListening()
+
+	err = p.Fetch(ctx, planet.FetchOptions{Force: opts.Force, Offline: opts.Offline, Fixtures: opts.Fixtures, Selection: opts.Selection, Wait: opts.Wait, Abort: abort})
+	if errors.Is(err, planet.ErrRunInProgress) && opts.Wait == 0 {
+		fmt.Fprintf(opts.Output, "Skipping fetch: %v. Use --wait to wait for it.\n", err)
+		return nil
+	}
+	if err != nil {
 		return fmt.Errorf("failed to fetch feeds: %w", err)
 	}
 
 	fmt.Fprintln(opts.Output, "✓ Fetch complete")
 	return nil
 }
+
+// traceFeed fetches a single feed and prints the response diagnostics
+// recorded in its fetch log
+func traceFeed(ctx context.Context, cfg *config.Config, opts FetchOptions) error {
+	repo, err := planet.OpenRepository(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer repo.Close()
+
+	feed, err := repo.GetFeedByURL(ctx, opts.TraceFeed)
+	if errors.Is(err, repository.ErrFeedNotFound) {
+		return fmt.Errorf("feed not found: %s", opts.TraceFeed)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get feed: %w", err)
+	}
+
+	fmt.Fprintf(opts.Output, "Tracing %s\n", feed.URL)
+
+	n, err := planet.NewNormalizer(cfg)
+	if err != nil {
+		return err
+	}
+
+	var mu sync.Mutex
+	logger := logging.Configure(opts.Logger, "", cfg.Planet.LogFormat)
+	feedFetcher := fetcher.New(planet.NewCrawler(cfg), n, repo, &mu, logger, cfg.Planet.MaxRetries)
+	feedFetcher.SetForce(true) // Tracing is an explicit request to contact the server
+
+	fetchCtx, cancel := context.WithTimeout(ctx, planet.FeedTimeout(cfg))
+	defer cancel()
+	result := feedFetcher.FetchFeed(fetchCtx, *feed)
+
+	recent, err := repo.GetFetchLog(ctx, feed.ID, 1)
+	if err != nil {
+		return fmt.Errorf("failed to read fetch log: %w", err)
+	}
+	if len(recent) > 0 {
+		latest := recent[0]
+		status := "no response"
+		if latest.StatusCode != 0 {
+			status = fmt.Sprintf("%d", latest.StatusCode)
+		}
+		fmt.Fprintf(opts.Output, "  Status:  %s\n", status)
+		fmt.Fprintf(opts.Output, "  Fetched: %s\n", latest.FetchedAt.Format(time.RFC3339))
+
+		if len(latest.Headers) > 0 {
+			fmt.Fprintln(opts.Output, "  Headers:")
+			names := make([]string, 0, len(latest.Headers))
+			for name := range latest.Headers {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				fmt.Fprintf(opts.Output, "    %s: %s\n", name, latest.Headers[name])
+			}
+		}
+	}
+
+	switch {
+	case result.Error != nil:
+		fmt.Fprintf(opts.Output, "  Error:   %v\n", result.Error)
+	case result.NotModified:
+		fmt.Fprintln(opts.Output, "  Result:  not modified (cached)")
+	default:
+		fmt.Fprintf(opts.Output, "  Result:  stored %d entries\n", result.StoredEntries)
+	}
+
+	return nil
+}
@@ -3,10 +3,12 @@ package main
 import (
 	"context"
 	"fmt"
+
+	"github.com/adewale/rogue_planet/pkg/planet"
 )
 
 func cmdGenerate(ctx context.Context, opts GenerateOptions) error {
-	cfg, err := loadConfig(opts.ConfigPath)
+	cfg, err := planet.LoadConfig(opts.ConfigPath)
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
@@ -15,8 +17,13 @@ func cmdGenerate(ctx context.Context, opts GenerateOptions) error {
 		cfg.Planet.Days = opts.Days
 	}
 
-	fmt.Fprintln(opts.Output, "Generating site...")
-	if err := generateSite(ctx, cfg); err != nil {
+	p, err := planet.Open(cfg, planet.Options{Output: opts.Output})
+	if err != nil {
+		return fmt.Errorf("failed to open planet: %w", err)
+	}
+	defer p.Close()
+
+	if err := p.Generate(ctx, planet.GenerateOptions{Tags: opts.Tags, NoPublish: opts.NoPublish}); err != nil {
 		return fmt.Errorf("failed to generate site: %w", err)
 	}
 
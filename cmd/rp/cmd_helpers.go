@@ -1,52 +1,40 @@
 package main
 
 import (
+	"bufio"
 	"context"
 	"fmt"
-	"html/template"
 	"io"
 	"log"
+	"log/slog"
 	"os"
 	"os/signal"
-	"path/filepath"
-	"sync"
+	"strings"
 	"syscall"
-	"time"
 
 	"github.com/adewale/rogue_planet/pkg/config"
-	"github.com/adewale/rogue_planet/pkg/crawler"
-	"github.com/adewale/rogue_planet/pkg/fetcher"
-	"github.com/adewale/rogue_planet/pkg/generator"
+	"github.com/adewale/rogue_planet/pkg/feedurl"
 	"github.com/adewale/rogue_planet/pkg/logging"
-	"github.com/adewale/rogue_planet/pkg/normalizer"
-	"github.com/adewale/rogue_planet/pkg/ratelimit"
+	"github.com/adewale/rogue_planet/pkg/planet"
 	"github.com/adewale/rogue_planet/pkg/repository"
 )
 
-// loadConfig loads configuration from file, falling back to defaults if file doesn't exist
-func loadConfig(path string) (*config.Config, error) {
-	if _, err := os.Stat(path); os.IsNotExist(err) {
-		return config.Default(), nil
-	}
-	return config.LoadFromFile(path)
-}
-
-// setVerboseLogging configures log output to include file and line numbers
-func setVerboseLogging(verbose bool) {
-	if verbose {
-		log.SetFlags(log.LstdFlags | log.Lshortfile)
-	}
+// newLogger creates the logger for a command that fetches feeds. Verbose
+// logging adds the file and line of each log call. The config's log_level
+// and log_format are applied once it is loaded.
+func newLogger(verbose bool) *slog.Logger {
+	return logging.NewWithOptions(logging.Options{Level: "info", AddSource: verbose})
 }
 
 // openConfigAndRepo loads config and opens database, returning both along with a cleanup function
 // The cleanup function should be called with defer to ensure the repository is closed
 func openConfigAndRepo(configPath string) (*config.Config, *repository.Repository, func(), error) {
-	cfg, err := loadConfig(configPath)
+	cfg, err := planet.LoadConfig(configPath)
 	if err != nil {
 		return nil, nil, nil, fmt.Errorf("failed to load config: %w", err)
 	}
 
-	repo, err := repository.New(cfg.Database.Path)
+	repo, err := planet.OpenRepository(cfg)
 	if err != nil {
 		return nil, nil, nil, fmt.Errorf("failed to open database: %w", err)
 	}
@@ -55,11 +43,89 @@ func openConfigAndRepo(configPath string) (*config.Config, *repository.Repositor
 	return cfg, repo, cleanup, nil
 }
 
-// importFeedsFromURLs adds a list of feed URLs to the repository with progress reporting
+// openPlanet loads config and opens the planet, printing its progress to
+// output. The planet should be closed with defer.
+func openPlanet(configPath string, logger *slog.Logger, output io.Writer) (*planet.Planet, error) {
+	cfg, err := planet.LoadConfig(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	p, err := planet.Open(cfg, planet.Options{Logger: logger, Output: output})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open planet: %w", err)
+	}
+	return p, nil
+}
+
+// checkCanPrompt fails if input is a file that is not a terminal, so a
+// script that did not pass --force gets an error rather than a hang.
+// Other readers, such as a strings.Reader in tests, can always be prompted.
+func checkCanPrompt(input io.Reader) error {
+	inputFile, isFile := input.(*os.File)
+	if !isFile {
+		return nil
+	}
+	stat, err := inputFile.Stat()
+	if err != nil {
+		return fmt.Errorf("cannot determine terminal status: %w", err)
+	}
+	if stat.Mode()&os.ModeCharDevice == 0 {
+		return fmt.Errorf("cannot prompt for confirmation in non-interactive mode. Use --force to skip confirmation")
+	}
+	return nil
+}
+
+// confirmPrompt asks a yes/no question on output and reports whether the
+// answer read from input was yes. Check the input with checkCanPrompt first.
+func confirmPrompt(ctx context.Context, input io.Reader, output io.Writer, question string) (bool, error) {
+	fmt.Fprint(output, question)
+
+	response, err := readLine(ctx, bufio.NewReader(input))
+	if err != nil {
+		return false, fmt.Errorf("failed to read input: %w", err)
+	}
+	response = strings.TrimSpace(strings.ToLower(response))
+	return response == "y" || response == "yes", nil
+}
+
+// readLine reads a line from r, giving up with ctx's error if ctx is done
+// first. Ctrl+C cancels ctx rather than killing rp, so without this a
+// prompt would ignore it.
+func readLine(ctx context.Context, r *bufio.Reader) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	type result struct {
+		line string
+		err  error
+	}
+	// Buffered, so the read can finish after ctx is done and rp stops
+	read := make(chan result, 1)
+	go func() {
+		line, err := r.ReadString('\n')
+		read <- result{line, err}
+	}()
+	select {
+	case res := <-read:
+		return res.line, res.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// importFeedsFromURLs adds a list of feed URLs to the repository with progress reporting,
+// normalizing each URL and warning about feeds that look like ones already added
 // Returns the number of successfully added feeds
 func importFeedsFromURLs(ctx context.Context, repo *repository.Repository, feedURLs []string, output io.Writer) int {
+	existing, err := repo.GetFeeds(ctx, false)
+	if err != nil {
+		log.Printf("Warning: Cannot check for duplicate feeds: %v", err)
+	}
 	addedCount := 0
 	for i, url := range feedURLs {
+		if normalized, err := feedurl.Normalize(url); err == nil {
+			url = normalized
+		}
 		fmt.Fprintf(output, "  [%d/%d] Adding %s\n", i+1, len(feedURLs), url)
 		id, err := repo.AddFeed(ctx, url, "")
 		if err != nil {
@@ -67,256 +133,54 @@ func importFeedsFromURLs(ctx context.Context, repo *repository.Repository, feedU
 			continue
 		}
 		fmt.Fprintf(output, "         ✓ Added (ID: %d)\n", id)
+		feed := repository.Feed{ID: id, URL: url}
+		warnDuplicates(output, "         ", planet.Duplicates(existing, feed))
+		existing = append(existing, feed)
 		addedCount++
 	}
 	return addedCount
 }
 
-func fetchFeeds(ctx context.Context, cfg *config.Config, logger logging.Logger) error {
-	// Set log level from config if logger supports it
-	if stdLogger, ok := logger.(*logging.StandardLogger); ok {
-		stdLogger.SetLevel(cfg.Planet.LogLevel)
-	}
-
-	repo, err := repository.New(cfg.Database.Path)
-	if err != nil {
-		return fmt.Errorf("open database: %w", err)
-	}
-	defer repo.Close()
-
-	// Get feeds from database
-	feeds, err := repo.GetFeeds(ctx, true)
-	if err != nil {
-		return fmt.Errorf("get feeds: %w", err)
-	}
-
-	if len(feeds) == 0 {
-		fmt.Println("No feeds to fetch. Add feeds with 'rp add-feed <url>'")
-		return nil
-	}
-
-	logger.Info("Fetching %d feeds with concurrency=%d", len(feeds), cfg.Planet.ConcurrentFetch)
-
-	// Create crawler with custom configuration
-	c := crawler.NewWithConfig(crawler.CrawlerConfig{
-		UserAgent:                    cfg.Planet.UserAgent,
-		MaxIdleConns:                 cfg.Planet.MaxIdleConns,
-		MaxIdleConnsPerHost:          cfg.Planet.MaxIdleConnsPerHost,
-		MaxConnsPerHost:              cfg.Planet.MaxConnsPerHost,
-		IdleConnTimeoutSeconds:       cfg.Planet.IdleConnTimeoutSeconds,
-		HTTPTimeoutSeconds:           cfg.Planet.HTTPTimeoutSeconds,
-		DialTimeoutSeconds:           cfg.Planet.DialTimeoutSeconds,
-		TLSHandshakeTimeoutSeconds:   cfg.Planet.TLSHandshakeTimeoutSeconds,
-		ResponseHeaderTimeoutSeconds: cfg.Planet.ResponseHeaderTimeoutSeconds,
-	})
-	n := normalizer.New()
-
-	// Create rate limiter for per-domain rate limiting
-	rateLimiter := ratelimit.New(cfg.Planet.RequestsPerMinute, cfg.Planet.RateLimitBurst)
-	logger.Debug("Rate limiter configured: %d requests/min, burst=%d", cfg.Planet.RequestsPerMinute, cfg.Planet.RateLimitBurst)
-
-	// Set up signal handling for graceful shutdown
-	ctx, cancel := context.WithCancel(ctx)
-	defer cancel()
-
+// abortOnSignal returns a channel closed when the process gets a second
+// interrupt or terminate signal, for planet.FetchOptions.Abort. The first
+// cancels the command's context, which lets the fetches in flight finish.
+// The returned function stops listening.
+func abortOnSignal(logger *slog.Logger) (<-chan struct{}, func()) {
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	abort := make(chan struct{})
+	finished := make(chan struct{})
 
 	// Handle signals in background
 	go func() {
-		sig, ok := <-sigChan
-		if !ok {
-			// Channel closed, normal shutdown
-			return
-		}
-		logger.Info("Received signal %v, cancelling fetches...", sig)
-		cancel()
-	}()
-
-	// Use semaphore pattern for concurrency control
-	concurrency := cfg.Planet.ConcurrentFetch
-	if concurrency < 1 {
-		concurrency = 1
-	}
-	if concurrency > len(feeds) {
-		concurrency = len(feeds)
-	}
-
-	sem := make(chan struct{}, concurrency)
-	var wg sync.WaitGroup
-	var mu sync.Mutex // Protects repo writes
-
-	// Create fetcher with dependencies (passes mutex for database protection)
-	feedFetcher := fetcher.New(c, n, repo, &mu, logger, cfg.Planet.MaxRetries)
-
-	// Fetch feeds concurrently
-	for i, feed := range feeds {
-		wg.Add(1)
-		go func(index int, f repository.Feed) {
-			defer wg.Done()
-
-			// Acquire semaphore
+		signals := 0
+		for {
 			select {
-			case sem <- struct{}{}:
-				defer func() { <-sem }()
-			case <-ctx.Done():
-				logger.Debug("Skipping %s (cancelled)", f.URL)
-				return
-			}
-
-			// Check if already cancelled before starting
-			select {
-			case <-ctx.Done():
-				logger.Debug("Skipping %s (cancelled)", f.URL)
-				return
-			default:
-			}
-
-			fmt.Printf("  [%d/%d] Fetching %s\n", index+1, len(feeds), f.URL)
-
-			// Apply rate limiting before fetching (use parent context)
-			fetchCtx, fetchCancel := context.WithTimeout(ctx, 30*time.Second)
-			defer fetchCancel()
-
-			if err := rateLimiter.Wait(fetchCtx, f.URL); err != nil {
-				if err == context.Canceled {
-					logger.Debug("Fetch cancelled for %s", f.URL)
+			case sig := <-sigChan:
+				if signals++; signals == 1 {
+					logger.Info("Received signal, finishing fetches in progress (send it again to abort them)", "signal", sig)
 				} else {
-					logger.Error("Rate limiter error for %s: %v", f.URL, err)
+					logger.Info("Received signal again, aborting fetches", "signal", sig)
+					close(abort)
+					return
 				}
+			case <-finished:
 				return
 			}
-
-			// Fetch and process feed (fetcher handles mutex internally for database writes)
-			result := feedFetcher.FetchFeed(fetchCtx, f)
-
-			// Report results
-			if result.Error != nil {
-				// Error already logged by fetcher
-				return
-			}
-
-			if result.NotModified {
-				fmt.Printf("    Not modified (cached)\n")
-				return
-			}
-
-			fmt.Printf("    Stored %d entries\n", result.StoredEntries)
-		}(i, feed)
-	}
-
-	// Wait for all fetches to complete
-	wg.Wait()
-
-	// Stop listening for signals
-	signal.Stop(sigChan)
-	close(sigChan)
-
-	// Check if we were cancelled
-	select {
-	case <-ctx.Done():
-		logger.Info("Fetch operation cancelled")
-		return fmt.Errorf("operation cancelled by user")
-	default:
-		logger.Info("Completed fetching all feeds")
-	}
-
-	return nil
-}
-
-func generateSite(ctx context.Context, cfg *config.Config) error {
-	repo, err := repository.New(cfg.Database.Path)
-	if err != nil {
-		return fmt.Errorf("open database: %w", err)
-	}
-	defer repo.Close()
-
-	// Get recent entries
-	entries, err := repo.GetRecentEntriesWithOptions(ctx, cfg.Planet.Days, cfg.Planet.FilterByFirstSeen, cfg.Planet.SortBy)
-	if err != nil {
-		return fmt.Errorf("get entries: %w", err)
-	}
-
-	// Get feeds for metadata
-	feeds, err := repo.GetFeeds(ctx, true)
-	if err != nil {
-		return fmt.Errorf("get feeds: %w", err)
-	}
-
-	feedMap := make(map[int64]*repository.Feed)
-	for i := range feeds {
-		feedMap[feeds[i].ID] = &feeds[i]
-	}
-
-	// Convert to generator format
-	genEntries := make([]generator.EntryData, 0, len(entries))
-	for _, entry := range entries {
-		feed := feedMap[entry.FeedID]
-		if feed == nil {
-			continue
 		}
+	}()
 
-		// SAFETY: Content was sanitized by normalizer.Parse() before storage.
-		// See pkg/normalizer/normalizer.go:56-69 for HTML sanitization using bluemonday.
-		// Title, Content, and Summary are safe for template.HTML after sanitization:
-		// - XSS vectors removed (script tags, event handlers, javascript: URLs)
-		// - Only http/https schemes allowed in links
-		// - Dangerous tags stripped (object, embed, iframe, base)
-		genEntries = append(genEntries, generator.EntryData{
-			Title:     template.HTML(entry.Title),
-			Link:      entry.Link,
-			Author:    entry.Author,
-			FeedTitle: feed.Title,
-			FeedLink:  feed.Link,
-			Published: entry.Published,
-			Updated:   entry.Updated,
-			Content:   template.HTML(entry.Content),
-			Summary:   template.HTML(entry.Summary),
-		})
-	}
-
-	// Create generator
-	var gen *generator.Generator
-	if cfg.Planet.Template != "" {
-		gen, err = generator.NewWithTemplate(cfg.Planet.Template)
-		if err != nil {
-			return fmt.Errorf("create generator with template: %w", err)
-		}
-	} else {
-		gen, err = generator.New()
-		if err != nil {
-			return fmt.Errorf("create generator: %w", err)
-		}
-	}
-
-	// Convert feeds for sidebar
-	genFeeds := make([]generator.FeedData, 0, len(feeds))
-	for _, feed := range feeds {
-		genFeeds = append(genFeeds, generator.FeedData{
-			Title:       feed.Title,
-			Link:        feed.Link,
-			URL:         feed.URL,
-			LastUpdated: feed.LastFetched,
-			ErrorCount:  feed.FetchErrorCount,
-		})
-	}
-
-	// Generate HTML
-	data := generator.TemplateData{
-		Title:       cfg.Planet.Name,
-		Link:        cfg.Planet.Link,
-		OwnerName:   cfg.Planet.OwnerName,
-		OwnerEmail:  cfg.Planet.OwnerEmail,
-		Entries:     genEntries,
-		GroupByDate: cfg.Planet.GroupByDate,
-		Feeds:       genFeeds,
+	return abort, func() {
+		signal.Stop(sigChan)
+		close(finished)
 	}
+}
 
-	outputPath := filepath.Join(cfg.Planet.OutputDir, "index.html")
-	if err := gen.GenerateToFile(ctx, outputPath, data); err != nil {
-		return fmt.Errorf("generate file: %w", err)
+// plural returns n followed by one if n is 1 and by many otherwise, as in
+// plural(n, "problem", "problems")
+func plural(n int, one, many string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, one)
 	}
-
-	fmt.Printf("  Generated %s with %d entries\n", outputPath, len(entries))
-	return nil
+	return fmt.Sprintf("%d %s", n, many)
 }
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"time"
+
+	"github.com/adewale/rogue_planet/pkg/repository"
+)
+
+func cmdHideEntry(ctx context.Context, opts EntryOptions) error {
+	if opts.Ref == "" {
+		return fmt.Errorf("entry link or ID is required")
+	}
+
+	_, repo, cleanup, err := openConfigAndRepo(opts.ConfigPath)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	entries, err := repo.HideEntries(ctx, opts.Ref, time.Now())
+	if errors.Is(err, repository.ErrEntryNotFound) {
+		return fmt.Errorf("no stored entry has the link or ID %s", opts.Ref)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to hide entry: %w", err)
+	}
+
+	for _, e := range entries {
+		fmt.Fprintf(opts.Output, "✓ Hidden: %s (%s)\n", entryLabel(e.Title, e.EntryID), e.Link)
+	}
+	fmt.Fprintln(opts.Output, "  Run 'rp generate' to take it off the site")
+	return nil
+}
+
+func cmdUnhideEntry(ctx context.Context, opts EntryOptions) error {
+	if opts.Ref == "" {
+		return fmt.Errorf("entry link or ID is required")
+	}
+
+	_, repo, cleanup, err := openConfigAndRepo(opts.ConfigPath)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	n, err := repo.UnhideEntries(ctx, opts.Ref)
+	if err != nil {
+		return fmt.Errorf("failed to unhide entry: %w", err)
+	}
+	if n == 0 {
+		return fmt.Errorf("no hidden entry has the link or ID %s (see 'rp list-hidden')", opts.Ref)
+	}
+
+	if n == 1 {
+		fmt.Fprintf(opts.Output, "✓ Unhid %s\n", opts.Ref)
+	} else {
+		fmt.Fprintf(opts.Output, "✓ Unhid %d entries with the link or ID %s\n", n, opts.Ref)
+	}
+	fmt.Fprintln(opts.Output, "  Run 'rp generate' to put it back on the site")
+	return nil
+}
+
+func cmdListHidden(ctx context.Context, opts ListHiddenOptions) error {
+	_, repo, cleanup, err := openConfigAndRepo(opts.ConfigPath)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	hidden, err := repo.GetHiddenEntries(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get hidden entries: %w", err)
+	}
+
+	if len(hidden) == 0 {
+		fmt.Fprintln(opts.Output, "No hidden entries.")
+		return nil
+	}
+
+	fmt.Fprintf(opts.Output, "Hidden entries (%d):\n\n", len(hidden))
+	printMarkedEntries(opts.Output, hidden, "Hidden")
+	return nil
+}
+
+// printMarkedEntries lists hidden, pinned, or starred entries, with when
+// they were marked under label
+func printMarkedEntries(w io.Writer, entries []repository.MarkedEntry, label string) {
+	for _, m := range entries {
+		fmt.Fprintf(w, "  %s\n", entryLabel(m.Title, m.EntryID))
+		if m.Link != "" {
+			fmt.Fprintf(w, "      Link: %s\n", m.Link)
+		}
+		fmt.Fprintf(w, "      ID: %s\n", m.EntryID)
+		fmt.Fprintf(w, "      Feed: %s\n", m.FeedURL)
+		fmt.Fprintf(w, "      %s: %s\n", label, m.At.Format(time.RFC3339))
+		fmt.Fprintln(w)
+	}
+}
+
+// entryLabel names an entry by its title, or its ID if it has none
+func entryLabel(title, id string) string {
+	if title == "" {
+		return id
+	}
+	return html.UnescapeString(title)
+}
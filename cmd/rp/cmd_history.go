@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/adewale/rogue_planet/pkg/repository"
+)
+
+func cmdHistory(ctx context.Context, opts HistoryOptions) error {
+	cfg, repo, cleanup, err := openConfigAndRepo(opts.ConfigPath)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	feed, err := repo.GetFeedByURL(ctx, opts.Feed)
+	if errors.Is(err, repository.ErrFeedNotFound) {
+		return fmt.Errorf("feed not found: %s", opts.Feed)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get feed: %w", err)
+	}
+
+	history, err := repo.GetFetchLog(ctx, feed.ID, opts.Limit)
+	if err != nil {
+		return fmt.Errorf("failed to read fetch log: %w", err)
+	}
+
+	w := opts.Output
+	if len(history) == 0 {
+		fmt.Fprintf(w, "No fetches recorded for %s.\n", feed.URL)
+		return nil
+	}
+
+	fmt.Fprintf(w, "Fetch history for %s (newest first, %d of at most %d kept):\n\n", feed.URL, len(history), cfg.Database.FetchHistory)
+	fmt.Fprintf(w, "%-16s  %-11s  %9s  %8s  %5s  %s\n", "TIME", "STATUS", "BYTES", "DURATION", "ADDED", "ERROR")
+	for _, h := range history {
+		fmt.Fprintf(w, "%-16s  %-11s  %9s  %8s  %5d  %s\n",
+			h.FetchedAt.Local().Format("2006-01-02 15:04"),
+			formatStatusCode(h.StatusCode),
+			formatBytes(h.WireBytes),
+			formatFetchDuration(h.Duration),
+			h.EntriesAdded,
+			h.Error)
+	}
+
+	return nil
+}
+
+// formatFetchDuration renders a fetch duration to the nearest millisecond,
+// or "-" for records written before durations were kept
+func formatFetchDuration(d time.Duration) string {
+	if d <= 0 {
+		return "-"
+	}
+	if d < time.Second {
+		return d.Round(time.Millisecond).String()
+	}
+	return d.Round(10 * time.Millisecond).String()
+}
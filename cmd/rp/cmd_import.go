@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/adewale/rogue_planet/pkg/importer"
+	"github.com/adewale/rogue_planet/pkg/planet"
+	"github.com/adewale/rogue_planet/pkg/repository"
+)
+
+func cmdImport(ctx context.Context, opts ImportOptions) error {
+	info, err := os.Stat(opts.Source)
+	if err != nil {
+		return fmt.Errorf("failed to read import source: %w", err)
+	}
+
+	cfg, repo, cleanup, err := openConfigAndRepo(opts.ConfigPath)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	im := importer.New(repo, opts.DryRun)
+	var result importer.Result
+	if info.IsDir() {
+		n, err := planet.NewNormalizer(cfg)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(opts.Output, "Importing Planet Venus cache %s\n", opts.Source)
+		result, err = im.FromVenusCache(ctx, opts.Source, n)
+		if err != nil {
+			return fmt.Errorf("failed to import: %w", err)
+		}
+	} else {
+		if cfg.Database.Driver != repository.DriverPostgres && sameFile(opts.Source, cfg.Database.Path) {
+			return fmt.Errorf("%s is this planet's own database", opts.Source)
+		}
+		fmt.Fprintf(opts.Output, "Importing database %s\n", opts.Source)
+		result, err = im.FromDatabase(ctx, opts.Source)
+		if err != nil {
+			return fmt.Errorf("failed to import: %w", err)
+		}
+	}
+
+	verb := "Imported"
+	if opts.DryRun {
+		verb = "Would import"
+	}
+	fmt.Fprintf(opts.Output, "✓ %s %d entries from %d feeds (%d feeds new to this planet)\n", verb, result.Entries, result.Feeds, result.FeedsAdded)
+	if result.Duplicates > 0 {
+		fmt.Fprintf(opts.Output, "  %d entries already here were left alone\n", result.Duplicates)
+	}
+	if result.Unreadable > 0 {
+		fmt.Fprintf(opts.Output, "  %d files were not entries rp could read\n", result.Unreadable)
+	}
+	if result.Entries > 0 && !opts.DryRun {
+		fmt.Fprintln(opts.Output, "\nRun 'rp generate' to add them to the site.")
+	}
+	return nil
+}
+
+// sameFile reports whether paths a and b name the same file
+func sameFile(a, b string) bool {
+	infoA, errA := os.Stat(a)
+	infoB, errB := os.Stat(b)
+	if errA == nil && errB == nil {
+		return os.SameFile(infoA, infoB)
+	}
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && absA == absB
+}
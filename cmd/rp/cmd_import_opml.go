@@ -3,32 +3,38 @@ package main
 import (
 	"context"
 	"fmt"
+	"strings"
 
-	"github.com/adewale/rogue_planet/pkg/crawler"
+	"github.com/adewale/rogue_planet/pkg/feedurl"
 	"github.com/adewale/rogue_planet/pkg/opml"
+	"github.com/adewale/rogue_planet/pkg/planet"
+	"github.com/adewale/rogue_planet/pkg/repository"
 )
 
-func cmdImportOPML(opts ImportOPMLOptions) error {
+func cmdImportOPML(ctx context.Context, opts ImportOPMLOptions) error {
 	if opts.OPMLFile == "" {
 		return fmt.Errorf("OPML file is required")
 	}
 
 	// Parse OPML file
-	opmlDoc, err := opml.ParseFile(context.Background(), opts.OPMLFile)
+	opmlDoc, err := opml.ParseFile(ctx, opts.OPMLFile)
 	if err != nil {
 		return fmt.Errorf("failed to parse OPML file: %w", err)
 	}
 
-	// Extract feeds
+	// Extract feeds, in the spelling AddFeed would store
 	feeds := opmlDoc.ExtractFeeds()
+	for i, feed := range feeds {
+		if normalized, err := feedurl.Normalize(feed.FeedURL); err == nil {
+			feeds[i].FeedURL = normalized
+		}
+	}
 
 	if len(feeds) == 0 {
 		fmt.Fprintln(opts.Output, "No feeds found in OPML file")
 		return nil
 	}
 
-	ctx := context.Background()
-
 	if opts.DryRun {
 		fmt.Fprintf(opts.Output, "DRY RUN: Importing feeds from %s...\n\n", opts.OPMLFile)
 		fmt.Fprintf(opts.Output, "Found %d feeds in OPML file\n\n", len(feeds))
@@ -38,7 +44,7 @@ func cmdImportOPML(opts ImportOPMLOptions) error {
 		if err != nil {
 			// Database might not exist yet, just show what would be imported
 			for i, feed := range feeds {
-				fmt.Fprintf(opts.Output, "  [%d/%d] Would add: %s (%s)\n", i+1, len(feeds), feed.FeedURL, feed.Title)
+				fmt.Fprintf(opts.Output, "  [%d/%d] Would add: %s (%s)%s\n", i+1, len(feeds), feed.FeedURL, feed.Title, formatCategories(feed.Categories))
 			}
 			fmt.Fprintf(opts.Output, "\nDRY RUN: Would import %d feeds\n", len(feeds))
 			return nil
@@ -53,7 +59,7 @@ func cmdImportOPML(opts ImportOPMLOptions) error {
 				fmt.Fprintf(opts.Output, "  [%d/%d] Would skip: %s (already exists)\n", i+1, len(feeds), feed.FeedURL)
 				skipCount++
 			} else {
-				fmt.Fprintf(opts.Output, "  [%d/%d] Would add: %s (%s)\n", i+1, len(feeds), feed.FeedURL, feed.Title)
+				fmt.Fprintf(opts.Output, "  [%d/%d] Would add: %s (%s)%s\n", i+1, len(feeds), feed.FeedURL, feed.Title, formatCategories(feed.Categories))
 			}
 		}
 
@@ -65,12 +71,18 @@ func cmdImportOPML(opts ImportOPMLOptions) error {
 	fmt.Fprintf(opts.Output, "Importing feeds from %s...\n\n", opts.OPMLFile)
 	fmt.Fprintf(opts.Output, "Found %d feeds in OPML file\n\n", len(feeds))
 
-	_, repo, cleanup, err := openConfigAndRepo(opts.ConfigPath)
+	cfg, repo, cleanup, err := openConfigAndRepo(opts.ConfigPath)
 	if err != nil {
 		return err
 	}
 	defer cleanup()
 
+	existing, err := repo.GetFeeds(ctx, false)
+	if err != nil {
+		return fmt.Errorf("failed to get feeds: %w", err)
+	}
+	policy := planet.SSRFPolicy(cfg)
+
 	// Import each feed
 	addedCount := 0
 	skippedCount := 0
@@ -86,7 +98,7 @@ func cmdImportOPML(opts ImportOPMLOptions) error {
 		}
 
 		// Validate URL
-		if err := crawler.ValidateURL(feed.FeedURL); err != nil {
+		if err := policy.ValidateURL(feed.FeedURL); err != nil {
 			fmt.Fprintf(opts.Output, "  [%d/%d] %s\n", i+1, len(feeds), feed.FeedURL)
 			fmt.Fprintf(opts.Output, "         ✗ Skipped (invalid URL: %v)\n", err)
 			skippedCount++
@@ -107,8 +119,20 @@ func cmdImportOPML(opts ImportOPMLOptions) error {
 			continue
 		}
 
-		fmt.Fprintf(opts.Output, "  [%d/%d] Adding %s (%s)\n", i+1, len(feeds), feed.FeedURL, title)
+		fmt.Fprintf(opts.Output, "  [%d/%d] Adding %s (%s)%s\n", i+1, len(feeds), feed.FeedURL, title, formatCategories(feed.Categories))
+
+		if len(feed.Categories) > 0 {
+			if err := repo.SetFeedCategories(ctx, id, feed.Categories); err != nil {
+				fmt.Fprintf(opts.Output, "         ⚠ Added (ID: %d) but failed to save categories: %v\n", id, err)
+				addedCount++
+				continue
+			}
+		}
+
 		fmt.Fprintf(opts.Output, "         ✓ Added (ID: %d)\n", id)
+		added := repository.Feed{ID: id, URL: feed.FeedURL}
+		warnDuplicates(opts.Output, "         ", planet.Duplicates(existing, added))
+		existing = append(existing, added)
 		addedCount++
 	}
 
@@ -118,3 +142,11 @@ func cmdImportOPML(opts ImportOPMLOptions) error {
 
 	return nil
 }
+
+// formatCategories renders categories as a suffix for import progress lines
+func formatCategories(categories []string) string {
+	if len(categories) == 0 {
+		return ""
+	}
+	return " [" + strings.Join(categories, ", ") + "]"
+}
@@ -9,10 +9,51 @@ import (
 	"github.com/adewale/rogue_planet/pkg/config"
 )
 
-func cmdInit(opts InitOptions) error {
-	fmt.Fprintln(opts.Output, "Initializing Rogue Planet...")
+// initSettings holds the planet details written to a new config.ini
+type initSettings struct {
+	Name       string
+	Link       string
+	OwnerName  string
+	OwnerEmail string
+	Template   string // Optional theme template path
+}
 
-	// Create directories
+// defaultInitSettings returns the placeholder values used by non-interactive init
+func defaultInitSettings() initSettings {
+	return initSettings{
+		Name:       "My Planet",
+		Link:       "https://example.com",
+		OwnerName:  "Your Name",
+		OwnerEmail: "you@example.com",
+	}
+}
+
+// renderInitConfig builds the contents of a starter config.ini
+func renderInitConfig(s initSettings) string {
+	var b strings.Builder
+	b.WriteString("[planet]\n")
+	fmt.Fprintf(&b, "name = %s\n", s.Name)
+	fmt.Fprintf(&b, "link = %s\n", s.Link)
+	fmt.Fprintf(&b, "owner_name = %s\n", s.OwnerName)
+	fmt.Fprintf(&b, "owner_email = %s\n", s.OwnerEmail)
+	b.WriteString(`output_dir = ./public
+days = 7
+log_level = info
+concurrent_fetches = 5
+group_by_date = true
+`)
+	if s.Template != "" {
+		fmt.Fprintf(&b, "template = %s\n", s.Template)
+	}
+	b.WriteString(`
+[database]
+path = ./data/planet.db
+`)
+	return b.String()
+}
+
+// createPlanetDirs creates the data/ and public/ directories
+func createPlanetDirs() error {
 	dirs := []string{"data", "public"}
 	for _, dir := range dirs {
 		// Safety check: reject parent directory references to prevent path traversal
@@ -23,22 +64,23 @@ func cmdInit(opts InitOptions) error {
 			return fmt.Errorf("failed to create directory %s: %w", dir, err)
 		}
 	}
+	return nil
+}
+
+func cmdInit(ctx context.Context, opts InitOptions) error {
+	if opts.Interactive {
+		return cmdInitInteractive(ctx, opts)
+	}
 
-	// Create example config file
-	configContent := `[planet]
-name = My Planet
-link = https://example.com
-owner_name = Your Name
-owner_email = you@example.com
-output_dir = ./public
-days = 7
-log_level = info
-concurrent_fetches = 5
-group_by_date = true
+	fmt.Fprintln(opts.Output, "Initializing Rogue Planet...")
 
-[database]
-path = ./data/planet.db
-`
+	// Create directories
+	if err := createPlanetDirs(); err != nil {
+		return err
+	}
+
+	// Create example config file
+	configContent := renderInitConfig(defaultInitSettings())
 
 	if err := os.WriteFile(opts.ConfigPath, []byte(configContent), 0644); err != nil {
 		return fmt.Errorf("failed to create config.ini: %w", err)
@@ -64,8 +106,6 @@ path = ./data/planet.db
 			return fmt.Errorf("failed to load feeds file: %w", err)
 		}
 
-		ctx := context.Background()
-
 		// Add each feed to database
 		addedCount := importFeedsFromURLs(ctx, repo, feedURLs, opts.Output)
 
@@ -0,0 +1,409 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/adewale/rogue_planet/pkg/crawler"
+	"github.com/adewale/rogue_planet/pkg/discover"
+	"github.com/adewale/rogue_planet/pkg/logging"
+	"github.com/adewale/rogue_planet/pkg/opml"
+)
+
+// initThemes lists the themes offered by the interactive wizard.
+// "default" uses the built-in template; the others live in examples/themes/.
+var initThemes = []string{"default", "classic", "dark", "elegant", "flexoki"}
+
+// prompter reads answers to interactive questions, one per line
+type prompter struct {
+	in  *bufio.Reader
+	out io.Writer
+	eof bool // Input is exhausted; remaining questions take their defaults
+}
+
+func newPrompter(in io.Reader, out io.Writer) *prompter {
+	return &prompter{in: bufio.NewReader(in), out: out}
+}
+
+// ask prints a question and returns the trimmed answer, or def if the answer is empty
+func (p *prompter) ask(ctx context.Context, question, def string) (string, error) {
+	if def != "" {
+		fmt.Fprintf(p.out, "%s [%s]: ", question, def)
+	} else {
+		fmt.Fprintf(p.out, "%s: ", question)
+	}
+
+	if p.eof {
+		fmt.Fprintln(p.out)
+		return def, nil
+	}
+
+	line, err := readLine(ctx, p.in)
+	if err == io.EOF {
+		p.eof = true
+		fmt.Fprintln(p.out)
+	} else if err != nil {
+		return "", fmt.Errorf("failed to read input: %w", err)
+	}
+
+	answer := strings.TrimSpace(line)
+	if answer == "" {
+		return def, nil
+	}
+	return answer, nil
+}
+
+// confirm asks a yes/no question
+func (p *prompter) confirm(ctx context.Context, question string, def bool) (bool, error) {
+	hint := "y/N"
+	if def {
+		hint = "Y/n"
+	}
+
+	answer, err := p.ask(ctx, fmt.Sprintf("%s (%s)", question, hint), "")
+	if err != nil {
+		return false, err
+	}
+
+	switch strings.ToLower(answer) {
+	case "":
+		return def, nil
+	case "y", "yes":
+		return true, nil
+	default:
+		return false, nil
+	}
+}
+
+// askValid repeats a question until validate accepts the answer
+func (p *prompter) askValid(ctx context.Context, question, def string, validate func(string) error) (string, error) {
+	for {
+		answer, err := p.ask(ctx, question, def)
+		if err != nil {
+			return "", err
+		}
+		verr := validate(answer)
+		if verr == nil {
+			return answer, nil
+		}
+		if p.eof {
+			return "", fmt.Errorf("invalid answer for %q: %w", question, verr)
+		}
+		fmt.Fprintf(p.out, "  ✗ %v\n", verr)
+	}
+}
+
+// cmdInitInteractive walks the user through creating a planet: planet
+// details, update schedule, theme, and an initial feed list, then optionally
+// runs the first update
+func cmdInitInteractive(ctx context.Context, opts InitOptions) error {
+	input := opts.Input
+	if input == nil {
+		input = os.Stdin
+	}
+	p := newPrompter(input, opts.Output)
+
+	find := opts.Discover
+	if find == nil {
+		c := crawler.New()
+		find = func(ctx context.Context, pageURL string) ([]discover.Feed, error) {
+			return discover.Feeds(ctx, c, pageURL)
+		}
+	}
+
+	fmt.Fprintln(opts.Output, "Initializing Rogue Planet (interactive)...")
+	fmt.Fprintln(opts.Output, "Press Enter to accept the default shown in brackets.")
+	fmt.Fprintln(opts.Output)
+
+	if _, err := os.Stat(opts.ConfigPath); err == nil {
+		overwrite, err := p.confirm(ctx, fmt.Sprintf("%s already exists. Overwrite?", opts.ConfigPath), false)
+		if err != nil {
+			return err
+		}
+		if !overwrite {
+			fmt.Fprintln(opts.Output, "Cancelled.")
+			return &ErrUserCancelled{"operation cancelled by user"}
+		}
+	}
+
+	settings := defaultInitSettings()
+	var err error
+
+	if settings.Name, err = p.askValid(ctx, "Planet name", settings.Name, validateNotEmpty); err != nil {
+		return err
+	}
+	if settings.Link, err = p.askValid(ctx, "Planet URL", settings.Link, validatePlanetLink); err != nil {
+		return err
+	}
+	if settings.OwnerName, err = p.ask(ctx, "Owner name", settings.OwnerName); err != nil {
+		return err
+	}
+	if settings.OwnerEmail, err = p.askValid(ctx, "Owner email", settings.OwnerEmail, validateEmail); err != nil {
+		return err
+	}
+
+	schedule, err := p.askValid(ctx, "Update every (e.g. 30m or 1h; blank to schedule updates yourself)", "", validateSchedule)
+	if err != nil {
+		return err
+	}
+	var interval time.Duration
+	if schedule != "" {
+		interval, _ = time.ParseDuration(schedule)
+	}
+
+	fmt.Fprintf(opts.Output, "Available themes: %s\n", strings.Join(initThemes, ", "))
+	theme, err := p.askValid(ctx, "Theme", "default", validateTheme)
+	if err != nil {
+		return err
+	}
+	if theme != "default" {
+		settings.Template = "./" + filepath.ToSlash(filepath.Join("themes", theme, "template.html"))
+	}
+
+	fmt.Fprintln(opts.Output)
+	fmt.Fprintln(opts.Output, "Enter feed or site URLs, one per line; sites are searched for their feeds. Leave blank to finish.")
+	feedURLs, err := promptFeeds(ctx, p, find)
+	if err != nil {
+		return err
+	}
+	opmlFile, err := p.askValid(ctx, "OPML file to import (blank to skip)", "", func(s string) error { return validateOPMLFile(ctx, s) })
+	if err != nil {
+		return err
+	}
+
+	// Write planet layout
+	fmt.Fprintln(opts.Output)
+	if err := createPlanetDirs(); err != nil {
+		return err
+	}
+	if err := os.WriteFile(opts.ConfigPath, []byte(renderInitConfig(settings)), 0644); err != nil {
+		return fmt.Errorf("failed to create config.ini: %w", err)
+	}
+
+	fmt.Fprintln(opts.Output, "✓ Created config.ini")
+	fmt.Fprintln(opts.Output, "✓ Created data/ directory")
+	fmt.Fprintln(opts.Output, "✓ Created public/ directory")
+
+	if settings.Template != "" {
+		if _, err := os.Stat(settings.Template); os.IsNotExist(err) {
+			fmt.Fprintf(opts.Output, "⚠ Theme not found at %s; copy examples/themes/%s into ./themes/ before generating\n", settings.Template, theme)
+		}
+	}
+
+	if len(feedURLs) > 0 {
+		_, repo, cleanup, err := openConfigAndRepo(opts.ConfigPath)
+		if err != nil {
+			return err
+		}
+		addedCount := importFeedsFromURLs(ctx, repo, feedURLs, opts.Output)
+		cleanup()
+		fmt.Fprintf(opts.Output, "✓ Added %d/%d feeds\n", addedCount, len(feedURLs))
+	}
+
+	if opmlFile != "" {
+		fmt.Fprintln(opts.Output)
+		if err := cmdImportOPML(ctx, ImportOPMLOptions{OPMLFile: opmlFile, ConfigPath: opts.ConfigPath, Output: opts.Output}); err != nil {
+			return err
+		}
+	}
+
+	if interval > 0 {
+		fmt.Fprintln(opts.Output)
+		installSchedule(ctx, opts, interval)
+	}
+
+	if len(feedURLs) == 0 && opmlFile == "" {
+		fmt.Fprintln(opts.Output, "\nNext steps:")
+		fmt.Fprintln(opts.Output, "  1. Add feeds with 'rp add-feed <url>'")
+		fmt.Fprintln(opts.Output, "  2. Run 'rp update' to fetch feeds and generate your planet")
+		return nil
+	}
+
+	fmt.Fprintln(opts.Output)
+	runUpdate, err := p.confirm(ctx, "Run the first update now?", false)
+	if err != nil {
+		return err
+	}
+	if !runUpdate {
+		fmt.Fprintln(opts.Output, "\nNext steps:")
+		fmt.Fprintln(opts.Output, "  1. Run 'rp update' to fetch feeds and generate your planet")
+		return nil
+	}
+
+	return cmdUpdate(ctx, UpdateOptions{
+		ConfigPath: opts.ConfigPath,
+		Output:     opts.Output,
+		Logger:     logging.New("info"),
+	})
+}
+
+// installSchedule installs a service running 'rp update' every interval. A
+// failure is only a warning: the planet works without it, and the user can
+// run install-service once the problem is fixed.
+func installSchedule(ctx context.Context, opts InitOptions, interval time.Duration) {
+	svc := opts.Service
+	svc.ConfigPath = opts.ConfigPath
+	svc.Interval = interval
+	svc.Output = opts.Output
+	if svc.Kind == "" {
+		svc.Kind = defaultServiceKind()
+	}
+	if svc.Name == "" {
+		svc.Name = "rogue-planet"
+	}
+	if err := cmdInstallService(ctx, svc); err != nil {
+		fmt.Fprintf(opts.Output, "⚠ Could not schedule updates: %v\n", err)
+		if runtime.GOOS != "windows" {
+			fmt.Fprintf(opts.Output, "  Run 'rp install-service --interval %s' to try again\n", interval)
+		}
+	}
+}
+
+// promptFeeds collects feed URLs until a blank line, rejecting invalid or
+// duplicate URLs. Each URL is looked up with find, so a site's address can
+// be given in place of its feed's.
+func promptFeeds(ctx context.Context, p *prompter, find func(context.Context, string) ([]discover.Feed, error)) ([]string, error) {
+	var feeds []string
+	seen := make(map[string]bool)
+
+	for {
+		answer, err := p.ask(ctx, fmt.Sprintf("Feed %d", len(feeds)+1), "")
+		if err != nil {
+			return nil, err
+		}
+		if answer == "" {
+			return feeds, nil
+		}
+
+		if err := crawler.ValidateURL(answer); err != nil {
+			fmt.Fprintf(p.out, "  ✗ Invalid feed URL: %v\n", err)
+		} else {
+			chosen, err := chooseFeeds(ctx, p, find, answer)
+			if err != nil {
+				return nil, err
+			}
+			for _, feedURL := range chosen {
+				if seen[feedURL] {
+					fmt.Fprintf(p.out, "  ⚠ Already added: %s\n", feedURL)
+					continue
+				}
+				seen[feedURL] = true
+				feeds = append(feeds, feedURL)
+			}
+		}
+
+		if p.eof {
+			return feeds, nil
+		}
+	}
+}
+
+// chooseFeeds returns the feeds to add for rawURL: the URL itself if it is a
+// feed, the feed a site links to, or the user's pick of a site's several
+// feeds. A URL that cannot be fetched is kept as given, since the site may
+// only be down for now.
+func chooseFeeds(ctx context.Context, p *prompter, find func(context.Context, string) ([]discover.Feed, error), rawURL string) ([]string, error) {
+	feeds, err := find(ctx, rawURL)
+	switch {
+	case errors.Is(err, discover.ErrNoFeeds):
+		fmt.Fprintln(p.out, "  ✗ Not a feed, and the page links to no feeds")
+		return nil, nil
+	case err != nil:
+		fmt.Fprintf(p.out, "  ⚠ Could not check the URL, adding it as given: %v\n", err)
+		return []string{rawURL}, nil
+	case len(feeds) == 1:
+		if feeds[0].URL != rawURL {
+			fmt.Fprintf(p.out, "  ✓ Found %s feed %s\n", feeds[0].Type, feeds[0].URL)
+		}
+		return []string{feeds[0].URL}, nil
+	}
+
+	fmt.Fprintf(p.out, "  Found %d feeds:\n", len(feeds))
+	for i, f := range feeds {
+		label := f.Type
+		if f.Title != "" {
+			label = f.Title + ", " + f.Type
+		}
+		fmt.Fprintf(p.out, "    %d. %s (%s)\n", i+1, f.URL, label)
+	}
+	answer, err := p.askValid(ctx, `  Which feed? (number, or "all")`, "1", func(s string) error {
+		if n, err := strconv.Atoi(s); s != "all" && (err != nil || n < 1 || n > len(feeds)) {
+			return fmt.Errorf("choose a number from 1 to %d, or all", len(feeds))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if answer == "all" {
+		urls := make([]string, len(feeds))
+		for i, f := range feeds {
+			urls[i] = f.URL
+		}
+		return urls, nil
+	}
+	n, _ := strconv.Atoi(answer)
+	return []string{feeds[n-1].URL}, nil
+}
+
+func validateNotEmpty(s string) error {
+	if strings.TrimSpace(s) == "" {
+		return fmt.Errorf("value is required")
+	}
+	return nil
+}
+
+func validatePlanetLink(s string) error {
+	u, err := url.Parse(s)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("planet URL must be an absolute http or https URL")
+	}
+	return nil
+}
+
+func validateEmail(s string) error {
+	if s != "" && !strings.Contains(s, "@") {
+		return fmt.Errorf("email address must contain @")
+	}
+	return nil
+}
+
+func validateSchedule(s string) error {
+	if s == "" {
+		return nil
+	}
+	interval, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("not a duration such as 30m or 2h")
+	}
+	return validateServiceInterval(interval)
+}
+
+func validateOPMLFile(ctx context.Context, s string) error {
+	if s == "" {
+		return nil
+	}
+	if _, err := opml.ParseFile(ctx, s); err != nil {
+		return fmt.Errorf("cannot read OPML file: %w", err)
+	}
+	return nil
+}
+
+func validateTheme(s string) error {
+	for _, theme := range initThemes {
+		if s == theme {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown theme %q (choose from %s)", s, strings.Join(initThemes, ", "))
+}
@@ -6,30 +6,44 @@ import (
 	"time"
 )
 
-func cmdListFeeds(opts ListFeedsOptions) error {
+func cmdListFeeds(ctx context.Context, opts ListFeedsOptions) error {
 	_, repo, cleanup, err := openConfigAndRepo(opts.ConfigPath)
 	if err != nil {
 		return err
 	}
 	defer cleanup()
 
-	ctx := context.Background()
-
 	// Get feeds
 	feeds, err := repo.GetFeeds(ctx, false)
 	if err != nil {
 		return fmt.Errorf("failed to get feeds: %w", err)
 	}
 
-	if len(feeds) == 0 {
+	if opts.Errors {
+		failing := feeds[:0]
+		for _, feed := range feeds {
+			if feed.FetchErrorCount > 0 {
+				failing = append(failing, feed)
+			}
+		}
+		if len(failing) == 0 {
+			fmt.Fprintln(opts.Output, "No feeds with fetch errors.")
+			return nil
+		}
+		feeds = failing
+		fmt.Fprintf(opts.Output, "Feeds with fetch errors (%d):\n\n", len(feeds))
+	} else if len(feeds) == 0 {
 		fmt.Fprintln(opts.Output, "No feeds configured.")
 		return nil
+	} else {
+		fmt.Fprintf(opts.Output, "Configured feeds (%d):\n\n", len(feeds))
 	}
-
-	fmt.Fprintf(opts.Output, "Configured feeds (%d):\n\n", len(feeds))
 	for _, feed := range feeds {
 		status := "active"
-		if !feed.Active {
+		switch {
+		case !feed.GoneAt.IsZero():
+			status = "gone since " + feed.GoneAt.Format(time.RFC3339) + " (no longer fetched)"
+		case !feed.Active:
 			status = "inactive"
 		}
 
@@ -44,6 +58,12 @@ func cmdListFeeds(opts ListFeedsOptions) error {
 		if feed.FetchError != "" {
 			fmt.Fprintf(opts.Output, "      Error: %s\n", feed.FetchError)
 		}
+		if feed.FetchErrorCount > 0 {
+			fmt.Fprintf(opts.Output, "      Consecutive errors: %d\n", feed.FetchErrorCount)
+			if feed.Active && feed.NextFetch.After(time.Now()) {
+				fmt.Fprintf(opts.Output, "      Next attempt: %s\n", feed.NextFetch.Format(time.RFC3339))
+			}
+		}
 		fmt.Fprintln(opts.Output)
 	}
 
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/adewale/rogue_planet/pkg/repository"
+)
+
+func cmdMarkRead(ctx context.Context, opts MarkReadOptions) error {
+	if opts.Ref == "" && !opts.All {
+		return fmt.Errorf("entry link or ID, or --all, is required")
+	}
+
+	_, repo, cleanup, err := openConfigAndRepo(opts.ConfigPath)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	if opts.All {
+		var feedID int64
+		if opts.Feed != "" {
+			feed, err := repo.GetFeedByURL(ctx, opts.Feed)
+			if err != nil {
+				return fmt.Errorf("feed not found: %s", opts.Feed)
+			}
+			feedID = feed.ID
+		}
+		n, err := repo.MarkAllRead(ctx, feedID, time.Now())
+		if err != nil {
+			return fmt.Errorf("failed to mark entries read: %w", err)
+		}
+		fmt.Fprintf(opts.Output, "✓ Marked %d entries read\n", n)
+		return nil
+	}
+
+	entries, err := repo.MarkRead(ctx, opts.Ref, time.Now())
+	if errors.Is(err, repository.ErrEntryNotFound) {
+		return fmt.Errorf("no stored entry has the link or ID %s", opts.Ref)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to mark entry read: %w", err)
+	}
+
+	for _, e := range entries {
+		fmt.Fprintf(opts.Output, "✓ Read: %s (%s)\n", entryLabel(e.Title, e.EntryID), e.Link)
+	}
+	return nil
+}
+
+func cmdMarkUnread(ctx context.Context, opts EntryOptions) error {
+	if opts.Ref == "" {
+		return fmt.Errorf("entry link or ID is required")
+	}
+
+	_, repo, cleanup, err := openConfigAndRepo(opts.ConfigPath)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	n, err := repo.MarkUnread(ctx, opts.Ref)
+	if err != nil {
+		return fmt.Errorf("failed to mark entry unread: %w", err)
+	}
+	if n == 0 {
+		return fmt.Errorf("no read entry has the link or ID %s", opts.Ref)
+	}
+
+	fmt.Fprintf(opts.Output, "✓ Marked %s unread\n", opts.Ref)
+	return nil
+}
+
+func cmdListUnread(ctx context.Context, opts ListUnreadOptions) error {
+	_, repo, cleanup, err := openConfigAndRepo(opts.ConfigPath)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	total, err := repo.CountUnreadEntries(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to count unread entries: %w", err)
+	}
+	if total == 0 {
+		fmt.Fprintln(opts.Output, "No unread entries.")
+		return nil
+	}
+	entries, err := repo.GetUnreadEntries(ctx, opts.Limit)
+	if err != nil {
+		return fmt.Errorf("failed to get unread entries: %w", err)
+	}
+	feeds, err := repo.GetFeeds(ctx, false)
+	if err != nil {
+		return fmt.Errorf("failed to get feeds: %w", err)
+	}
+	feedTitles := make(map[int64]string, len(feeds))
+	for _, f := range feeds {
+		feedTitles[f.ID] = entryLabel(f.Title, f.URL)
+	}
+
+	if int64(len(entries)) < total {
+		fmt.Fprintf(opts.Output, "Unread entries (newest %d of %d):\n\n", len(entries), total)
+	} else {
+		fmt.Fprintf(opts.Output, "Unread entries (%d):\n\n", total)
+	}
+	for _, e := range entries {
+		fmt.Fprintf(opts.Output, "  %s\n", entryLabel(e.Title, e.EntryID))
+		if e.Link != "" {
+			fmt.Fprintf(opts.Output, "      Link: %s\n", e.Link)
+		}
+		fmt.Fprintf(opts.Output, "      Feed: %s\n", feedTitles[e.FeedID])
+		fmt.Fprintf(opts.Output, "      Published: %s\n", e.Published.Format(time.RFC3339))
+		fmt.Fprintln(opts.Output)
+	}
+	fmt.Fprintln(opts.Output, "Mark them read with 'rp mark-read <link>' or 'rp mark-read --all'")
+	return nil
+}
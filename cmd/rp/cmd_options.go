@@ -1,9 +1,13 @@
 package main
 
 import (
+	"context"
 	"io"
+	"log/slog"
+	"time"
 
-	"github.com/adewale/rogue_planet/pkg/logging"
+	"github.com/adewale/rogue_planet/pkg/discover"
+	"github.com/adewale/rogue_planet/pkg/planet"
 )
 
 // ErrUserCancelled indicates the user cancelled an operation
@@ -18,15 +22,22 @@ func (e *ErrUserCancelled) Error() string {
 // Command options structures for testability
 
 type InitOptions struct {
-	FeedsFile  string
-	ConfigPath string
-	Output     io.Writer
+	FeedsFile   string
+	ConfigPath  string
+	Interactive bool
+	Output      io.Writer
+	Input       io.Reader // For interactive prompts (defaults to os.Stdin)
+
+	Discover func(ctx context.Context, url string) ([]discover.Feed, error) // Finds the feeds at a URL (interactive only); defaults to discover.Feeds
+	Service  ServiceOptions                                                 // How the update schedule is installed (interactive only); Kind and Name default as for install-service
 }
 
 type AddFeedOptions struct {
 	URL        string
 	ConfigPath string
+	Fetch      bool // Fetch and parse the feed now; the add is undone if that fails
 	Output     io.Writer
+	Logger     *slog.Logger
 }
 
 type AddAllOptions struct {
@@ -43,33 +54,176 @@ type RemoveFeedOptions struct {
 	Force      bool      // Skip confirmation prompt
 }
 
+// BulkFeedsOptions are the options of remove-feeds, deactivate-feeds, and
+// reactivate-feeds
+type BulkFeedsOptions struct {
+	Action     string // remove, deactivate, or reactivate
+	ConfigPath string
+	Selection  planet.Selection
+	DryRun     bool // List the selected feeds without changing them
+	Force      bool // Skip the confirmation prompt before removing feeds
+	Input      io.Reader
+	Output     io.Writer
+}
+
 type ListFeedsOptions struct {
+	ConfigPath string
+	Errors     bool // Only list feeds with consecutive fetch errors, including deactivated ones
+	Output     io.Writer
+}
+
+type ReactivateFeedOptions struct {
+	URL        string
+	ConfigPath string
+	Output     io.Writer
+}
+
+type RenameFeedOptions struct {
+	OldURL     string
+	NewURL     string
+	ConfigPath string
+	Output     io.Writer
+}
+
+type EditFeedOptions struct {
+	URL        string
+	ConfigPath string
+	Title      *string  // Display title; "" goes back to the feed's own (nil leaves it)
+	Tags       []string // Replace the feed's categories (nil leaves them, empty removes them)
+	Active     *bool    // Whether the feed is fetched (nil leaves it)
+	Output     io.Writer
+}
+
+// EntryOptions names an entry for hide-entry, pin-entry, star-entry,
+// mark-unread, and their undoing
+type EntryOptions struct {
+	Ref        string // Entry link or ID
+	ConfigPath string
+	Output     io.Writer
+}
+
+// ShowEntryOptions names the entry show-entry prints
+type ShowEntryOptions struct {
+	Ref        string // Entry link or ID
+	Revisions  bool   // Also print its earlier revisions and what changed in each
+	ConfigPath string
+	Output     io.Writer
+}
+
+type ListHiddenOptions struct {
+	ConfigPath string
+	Output     io.Writer
+}
+
+type ListPinnedOptions struct {
+	ConfigPath string
+	Output     io.Writer
+}
+
+// MarkReadOptions names the entries mark-read marks: one entry, or with All
+// every entry, or every entry of Feed
+type MarkReadOptions struct {
+	Ref        string // Entry link or ID
+	All        bool
+	Feed       string // With All, only this feed's entries
+	ConfigPath string
+	Output     io.Writer
+}
+
+type ListUnreadOptions struct {
+	ConfigPath string
+	Limit      int // Most entries listed
+	Output     io.Writer
+}
+
+type ListStarredOptions struct {
 	ConfigPath string
 	Output     io.Writer
 }
 
 type StatusOptions struct {
 	ConfigPath string
+	Feed       string // Show detailed diagnostics for this feed URL
+	Output     io.Writer
+}
+
+type HistoryOptions struct {
+	ConfigPath string
+	Feed       string // Feed URL whose fetch attempts are shown
+	Limit      int    // Most recent attempts shown
 	Output     io.Writer
 }
 
+type ReportOptions struct {
+	ConfigPath  string
+	StaleMonths int // Feeds with nothing published for this many months are stale
+	Failures    int // Feeds whose last this many fetches all failed with client errors are reported
+	Output      io.Writer
+}
+
 type UpdateOptions struct {
 	ConfigPath string
 	Verbose    bool
+	Force      bool             // Fetch feeds even if their HTTP cache is still fresh
+	Selection  planet.Selection // Fetch only these feeds (--feed, --tag, --only-errors, --resume)
+	Wait       time.Duration    // How long to wait for another run to finish; 0 skips this run
+	NoPublish  bool             // Skip the [publish] hooks
 	Output     io.Writer
-	Logger     logging.Logger
+	Logger     *slog.Logger
 }
 
 type FetchOptions struct {
 	ConfigPath string
 	Verbose    bool
+	TraceFeed  string           // Fetch only this feed URL and print diagnostics
+	Force      bool             // Fetch feeds even if their HTTP cache is still fresh
+	Offline    bool             // Replay the responses saved in the response cache instead of fetching
+	Fixtures   string           // Replay the responses record-fixtures saved in this directory instead of fetching
+	Selection  planet.Selection // Fetch only these feeds (--feed, --tag, --only-errors, --resume)
+	Wait       time.Duration    // How long to wait for another run to finish; 0 skips this run
+	Output     io.Writer
+	Logger     *slog.Logger
+}
+
+// RecordFixturesOptions names the feeds record-fixtures saves, and where
+type RecordFixturesOptions struct {
+	ConfigPath string
+	Feeds      []string // Feed URLs to record; none records every active feed
+	Dir        string   // Directory the fixtures are written to
+	Output     io.Writer
+}
+
+type ServeOptions struct {
+	ConfigPath string
+	Addr       string        // Listen address, e.g. ":8080"
+	Interval   time.Duration // Time between fetch+generate runs; 0 disables refresh
+	Verbose    bool
 	Output     io.Writer
-	Logger     logging.Logger
+	Logger     *slog.Logger
 }
 
 type GenerateOptions struct {
 	ConfigPath string
 	Days       int
+	Tags       []string // Only include entries with one of these categories
+	NoPublish  bool     // Skip the [publish] hooks
+	Output     io.Writer
+}
+
+// DigestOptions selects the entries rp digest includes and where it goes:
+// written to Output or OutputFile, or with Send emailed to subscribers
+type DigestOptions struct {
+	ConfigPath string
+	Since      time.Duration // Include entries first seen this long ago or since
+	HTML       bool          // Write the HTML part instead of the text
+	OutputFile string        // Write the digest here instead of Output
+	Send       bool          // Email the digest to the [digest] subscribers
+	Output     io.Writer
+}
+
+// RelateOptions configures rp relate, which works out related entries again
+type RelateOptions struct {
+	ConfigPath string
 	Output     io.Writer
 }
 
@@ -80,11 +234,64 @@ type PruneOptions struct {
 	Output     io.Writer
 }
 
+type RollbackOptions struct {
+	ConfigPath string
+	Output     io.Writer
+}
+
+type ConfigOptions struct {
+	ConfigPath string
+	Action     string // "get" or "set"
+	Key        string // section.key, e.g. planet.days
+	Value      string // New value (set only)
+	Output     io.Writer
+}
+
+type PlanetsOptions struct {
+	Action     string   // list, add, remove, or update
+	Name       string   // Planet to add or remove
+	ConfigPath string   // Config of the planet to add
+	UpdateArgs []string // Flags passed to each planet's update
+	Registry   string   // Registry file
+	Output     io.Writer
+
+	// Runs rp with args from dir; defaults to runPlanetCommand
+	Runner func(ctx context.Context, dir string, out io.Writer, args ...string) error
+}
+
+type ServiceOptions struct {
+	ConfigPath string
+	Interval   time.Duration // Time between updates (install-service only)
+	Kind       string        // systemd, launchd, or cron
+	Name       string        // Name of the units, launch agent, or crontab entry
+	Dir        string        // Directory for unit files; defaults to the service manager's per-user one
+	DryRun     bool          // Show what would be written and run without doing it
+	Output     io.Writer
+
+	Executable string                                                                        // rp's path; defaults to the running executable
+	Runner     func(ctx context.Context, input, name string, args ...string) (string, error) // Runs systemctl, launchctl, and crontab; defaults to runServiceCommand
+}
+
 type VerifyOptions struct {
 	ConfigPath string
 	Output     io.Writer
 }
 
+// ValidateFeedOptions names the feed validate-feed checks
+type ValidateFeedOptions struct {
+	Source     string // Feed URL or path to a feed file
+	BaseURL    string // URL a feed file will be served from, for resolving its relative links
+	ConfigPath string
+	Output     io.Writer
+}
+
+type DoctorOptions struct {
+	ConfigPath string
+	Fix        bool // Delete orphaned database rows
+	Offline    bool // Skip the DNS and connectivity checks
+	Output     io.Writer
+}
+
 type ImportOPMLOptions struct {
 	OPMLFile   string
 	ConfigPath string
@@ -92,8 +299,37 @@ type ImportOPMLOptions struct {
 	Output     io.Writer
 }
 
+type ImportOptions struct {
+	Source     string // Rogue Planet database file or Planet Venus cache directory
+	ConfigPath string
+	DryRun     bool // Report what would be imported without importing it
+	Output     io.Writer
+}
+
 type ExportOPMLOptions struct {
 	OutputFile string
 	ConfigPath string
+	Health     bool // Include lastFetched/errorCount/lastError attributes
 	Output     io.Writer
 }
+
+type ExportOptions struct {
+	ConfigPath string
+	Format     string           // "jsonl", "csv", or "sqlite"
+	OutputFile string           // File written; "" for stdout, which sqlite can't use
+	Selection  planet.Selection // Only entries of these feeds (--feed, --tag)
+	Since      time.Time        // Only entries published at or after Since; zero for no limit
+	Until      time.Time        // Only entries published before Until; zero for no limit
+	Output     io.Writer
+}
+
+type VersionOptions struct {
+	Verbose bool
+	Output  io.Writer
+}
+
+type CompletionOptions struct {
+	Shell    string              // "bash", "zsh", or "fish"
+	Commands []completionCommand // Commands and flags the script completes
+	Output   io.Writer
+}
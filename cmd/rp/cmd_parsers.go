@@ -3,32 +3,97 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
 
+	"github.com/adewale/rogue_planet/pkg/config"
 	"github.com/adewale/rogue_planet/pkg/logging"
+	"github.com/adewale/rogue_planet/pkg/planet"
 )
 
 // Flag parsing functions - extracted for testability
 // Each function takes args []string and returns (Options, error)
 
+// helpError is returned by parseFlags when a command's help is asked for
+// with -h or --help; main prints the help from the command's flags
+type helpError struct {
+	fs *flag.FlagSet // nil for commands without flags
+}
+
+func (e *helpError) Error() string { return "help requested" }
+func (e *helpError) Unwrap() error { return flag.ErrHelp }
+
+// helpRequested reports whether args ask for help before any "--"
+func helpRequested(args []string) bool {
+	for _, arg := range args {
+		switch arg {
+		case "--":
+			return false
+		case "-h", "-help", "--help":
+			return true
+		}
+	}
+	return false
+}
+
+// parseFlags parses args like fs.Parse, but allows flags anywhere among the
+// arguments (rp remove-feed <url> --force) rather than only before them.
+// Everything after "--" is an argument. fs.Args() returns the arguments.
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	if helpRequested(args) {
+		return &helpError{fs: fs}
+	}
+	// Errors are returned, and main shows the command's usage with them
+	fs.SetOutput(io.Discard)
+
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		rest := fs.Args()
+		if len(rest) == 0 {
+			break
+		}
+		if n := len(args) - len(rest); n > 0 && args[n-1] == "--" {
+			positional = append(positional, rest...)
+			break
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+	// Parsing "--" alone leaves the flags set and the arguments in fs.Args()
+	return fs.Parse(append([]string{"--"}, positional...))
+}
+
 func parseInitFlags(args []string) (InitOptions, error) {
 	fs := flag.NewFlagSet("init", flag.ContinueOnError)
 	feedsFile := fs.String("f", "", "Import feeds from file")
+	interactive := fs.Bool("interactive", false, "Prompt for planet details, update schedule, theme, feeds, and an OPML file")
 
-	if err := fs.Parse(args); err != nil {
+	if err := parseFlags(fs, args); err != nil {
 		return InitOptions{}, fmt.Errorf("parsing flags: %w", err)
 	}
 
+	if *interactive && *feedsFile != "" {
+		return InitOptions{}, fmt.Errorf("-f cannot be combined with --interactive")
+	}
+
 	return InitOptions{
-		FeedsFile:  *feedsFile,
-		ConfigPath: "config.ini",
+		FeedsFile:   *feedsFile,
+		ConfigPath:  "config.ini",
+		Interactive: *interactive,
 	}, nil
 }
 
 func parseAddFeedFlags(args []string) (AddFeedOptions, error) {
 	fs := flag.NewFlagSet("add-feed", flag.ContinueOnError)
 	configPath := fs.String("config", "./config.ini", "Path to config file")
+	fetch := fs.Bool("fetch", false, "Fetch and validate the feed immediately")
 
-	if err := fs.Parse(args); err != nil {
+	if err := parseFlags(fs, args); err != nil {
 		return AddFeedOptions{}, fmt.Errorf("parsing flags: %w", err)
 	}
 
@@ -39,6 +104,8 @@ func parseAddFeedFlags(args []string) (AddFeedOptions, error) {
 	return AddFeedOptions{
 		URL:        fs.Arg(0),
 		ConfigPath: *configPath,
+		Fetch:      *fetch,
+		Logger:     logging.New("warn"),
 	}, nil
 }
 
@@ -47,7 +114,7 @@ func parseAddAllFlags(args []string) (AddAllOptions, error) {
 	configPath := fs.String("config", "./config.ini", "Path to config file")
 	feedsFile := fs.String("f", "", "Path to feeds file")
 
-	if err := fs.Parse(args); err != nil {
+	if err := parseFlags(fs, args); err != nil {
 		return AddAllOptions{}, fmt.Errorf("parsing flags: %w", err)
 	}
 
@@ -66,7 +133,7 @@ func parseRemoveFeedFlags(args []string) (RemoveFeedOptions, error) {
 	configPath := fs.String("config", "./config.ini", "Path to config file")
 	force := fs.Bool("force", false, "Skip confirmation prompt")
 
-	if err := fs.Parse(args); err != nil {
+	if err := parseFlags(fs, args); err != nil {
 		return RemoveFeedOptions{}, fmt.Errorf("parsing flags: %w", err)
 	}
 
@@ -81,29 +148,336 @@ func parseRemoveFeedFlags(args []string) (RemoveFeedOptions, error) {
 	}, nil
 }
 
+// bulkFeedActions maps the bulk feed commands to their actions
+var bulkFeedActions = map[string]string{
+	"remove-feeds":     "remove",
+	"deactivate-feeds": "deactivate",
+	"reactivate-feeds": "reactivate",
+}
+
+// parseBulkFeedsFlags parses the flags of a command that acts on every feed
+// matching its selection flags
+func parseBulkFeedsFlags(command string, args []string) (BulkFeedsOptions, error) {
+	fs := flag.NewFlagSet(command, flag.ContinueOnError)
+	configPath := fs.String("config", "./config.ini", "Path to config file")
+	var match, patterns stringList
+	fs.Var(&match, "match", "Select feeds whose URL or title contains this text, ignoring case (repeatable)")
+	fs.Var(&patterns, "feed", "Select this feed URL, or feeds matching a glob such as 'https://*.example.com/*' (repeatable)")
+	tag := fs.String("tag", "", "Select feeds in this category (comma-separated for several)")
+	errorsGT := fs.Int("errors-gt", 0, "Select feeds with more than N consecutive fetch errors")
+	inactive := fs.Bool("inactive", false, "Select deactivated feeds")
+	dryRun := fs.Bool("dry-run", false, "List the selected feeds without changing them")
+	var force *bool
+	if command == "remove-feeds" {
+		force = fs.Bool("force", false, "Skip confirmation prompt")
+	}
+
+	if err := parseFlags(fs, args); err != nil {
+		return BulkFeedsOptions{}, fmt.Errorf("parsing flags: %w", err)
+	}
+	if fs.NArg() > 0 {
+		return BulkFeedsOptions{}, fmt.Errorf("unexpected argument %q; select feeds with flags such as --match", fs.Arg(0))
+	}
+
+	opts := BulkFeedsOptions{
+		Action:     bulkFeedActions[command],
+		ConfigPath: *configPath,
+		Selection: planet.Selection{
+			Patterns: patterns,
+			Tags:     splitTags(*tag),
+			Match:    match,
+			Inactive: *inactive,
+		},
+		DryRun: *dryRun,
+		Force:  force != nil && *force,
+	}
+	if *errorsGT < 0 {
+		return BulkFeedsOptions{}, fmt.Errorf("--errors-gt must not be negative")
+	}
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "errors-gt" {
+			opts.Selection.MinErrors = *errorsGT + 1
+		}
+	})
+	// An empty selection would act on every feed, which is never what a typo meant
+	if opts.Selection.Empty() {
+		return BulkFeedsOptions{}, fmt.Errorf("select feeds with --match, --feed, --tag, --errors-gt, or --inactive")
+	}
+	return opts, nil
+}
+
 func parseListFeedsFlags(args []string) (ListFeedsOptions, error) {
 	fs := flag.NewFlagSet("list-feeds", flag.ContinueOnError)
 	configPath := fs.String("config", "./config.ini", "Path to config file")
+	errorsOnly := fs.Bool("errors", false, "Only list feeds with fetch errors")
 
-	if err := fs.Parse(args); err != nil {
+	if err := parseFlags(fs, args); err != nil {
 		return ListFeedsOptions{}, fmt.Errorf("parsing flags: %w", err)
 	}
 
 	return ListFeedsOptions{
 		ConfigPath: *configPath,
+		Errors:     *errorsOnly,
+	}, nil
+}
+
+func parseReactivateFeedFlags(args []string) (ReactivateFeedOptions, error) {
+	fs := flag.NewFlagSet("reactivate-feed", flag.ContinueOnError)
+	configPath := fs.String("config", "./config.ini", "Path to config file")
+
+	if err := parseFlags(fs, args); err != nil {
+		return ReactivateFeedOptions{}, fmt.Errorf("parsing flags: %w", err)
+	}
+
+	if fs.NArg() < 1 {
+		return ReactivateFeedOptions{}, fmt.Errorf("missing feed URL argument")
+	}
+
+	return ReactivateFeedOptions{
+		URL:        fs.Arg(0),
+		ConfigPath: *configPath,
+	}, nil
+}
+
+func parseRenameFeedFlags(args []string) (RenameFeedOptions, error) {
+	fs := flag.NewFlagSet("rename-feed", flag.ContinueOnError)
+	configPath := fs.String("config", "./config.ini", "Path to config file")
+
+	if err := parseFlags(fs, args); err != nil {
+		return RenameFeedOptions{}, fmt.Errorf("parsing flags: %w", err)
+	}
+
+	if fs.NArg() != 2 {
+		return RenameFeedOptions{}, fmt.Errorf("want the feed's current URL and its new URL")
+	}
+
+	return RenameFeedOptions{
+		OldURL:     fs.Arg(0),
+		NewURL:     fs.Arg(1),
+		ConfigPath: *configPath,
+	}, nil
+}
+
+func parseEditFeedFlags(args []string) (EditFeedOptions, error) {
+	fs := flag.NewFlagSet("edit-feed", flag.ContinueOnError)
+	configPath := fs.String("config", "./config.ini", "Path to config file")
+	title := fs.String("title", "", "Title shown for the feed instead of its own (\"\" to use its own again)")
+	tags := fs.String("tags", "", "Categories of the feed, comma-separated, replacing its current ones (\"\" to remove them)")
+	active := fs.Bool("active", false, "Whether the feed is fetched (--active=false pauses it)")
+
+	if err := parseFlags(fs, args); err != nil {
+		return EditFeedOptions{}, fmt.Errorf("parsing flags: %w", err)
+	}
+
+	if fs.NArg() < 1 {
+		return EditFeedOptions{}, fmt.Errorf("missing feed URL argument")
+	}
+
+	opts := EditFeedOptions{
+		URL:        fs.Arg(0),
+		ConfigPath: *configPath,
+	}
+	// Only the flags given change the feed
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "title":
+			opts.Title = title
+		case "tags":
+			// Not nil even when empty, so --tags "" removes the feed's tags
+			opts.Tags = append([]string{}, splitTags(*tags)...)
+		case "active":
+			opts.Active = active
+		}
+	})
+	if opts.Title == nil && opts.Tags == nil && opts.Active == nil {
+		return EditFeedOptions{}, fmt.Errorf("nothing to change; give --title, --tags, or --active")
+	}
+	return opts, nil
+}
+
+// parseEntryFlags parses the flags of a command that takes an entry's link
+// or ID
+func parseEntryFlags(command string, args []string) (EntryOptions, error) {
+	fs := flag.NewFlagSet(command, flag.ContinueOnError)
+	configPath := fs.String("config", "./config.ini", "Path to config file")
+
+	if err := parseFlags(fs, args); err != nil {
+		return EntryOptions{}, fmt.Errorf("parsing flags: %w", err)
+	}
+
+	if fs.NArg() < 1 {
+		return EntryOptions{}, fmt.Errorf("missing entry link or ID argument")
+	}
+
+	return EntryOptions{
+		Ref:        fs.Arg(0),
+		ConfigPath: *configPath,
+	}, nil
+}
+
+func parseShowEntryFlags(args []string) (ShowEntryOptions, error) {
+	fs := flag.NewFlagSet("show-entry", flag.ContinueOnError)
+	configPath := fs.String("config", "./config.ini", "Path to config file")
+	revisions := fs.Bool("revisions", false, "Also show earlier revisions of the entry and what changed")
+
+	if err := parseFlags(fs, args); err != nil {
+		return ShowEntryOptions{}, fmt.Errorf("parsing flags: %w", err)
+	}
+
+	if fs.NArg() < 1 {
+		return ShowEntryOptions{}, fmt.Errorf("missing entry link or ID argument")
+	}
+
+	return ShowEntryOptions{
+		Ref:        fs.Arg(0),
+		Revisions:  *revisions,
+		ConfigPath: *configPath,
+	}, nil
+}
+
+func parseListHiddenFlags(args []string) (ListHiddenOptions, error) {
+	fs := flag.NewFlagSet("list-hidden", flag.ContinueOnError)
+	configPath := fs.String("config", "./config.ini", "Path to config file")
+
+	if err := parseFlags(fs, args); err != nil {
+		return ListHiddenOptions{}, fmt.Errorf("parsing flags: %w", err)
+	}
+
+	return ListHiddenOptions{
+		ConfigPath: *configPath,
+	}, nil
+}
+
+func parseListPinnedFlags(args []string) (ListPinnedOptions, error) {
+	fs := flag.NewFlagSet("list-pinned", flag.ContinueOnError)
+	configPath := fs.String("config", "./config.ini", "Path to config file")
+
+	if err := parseFlags(fs, args); err != nil {
+		return ListPinnedOptions{}, fmt.Errorf("parsing flags: %w", err)
+	}
+
+	return ListPinnedOptions{
+		ConfigPath: *configPath,
+	}, nil
+}
+
+func parseMarkReadFlags(args []string) (MarkReadOptions, error) {
+	fs := flag.NewFlagSet("mark-read", flag.ContinueOnError)
+	configPath := fs.String("config", "./config.ini", "Path to config file")
+	all := fs.Bool("all", false, "Mark every stored entry read")
+	feed := fs.String("feed", "", "With --all, only mark this feed's entries read")
+
+	if err := parseFlags(fs, args); err != nil {
+		return MarkReadOptions{}, fmt.Errorf("parsing flags: %w", err)
+	}
+
+	if *feed != "" && !*all {
+		return MarkReadOptions{}, fmt.Errorf("--feed needs --all")
+	}
+	if *all == (fs.NArg() > 0) {
+		return MarkReadOptions{}, fmt.Errorf("give an entry link or ID, or --all")
+	}
+
+	return MarkReadOptions{
+		Ref:        fs.Arg(0),
+		All:        *all,
+		Feed:       *feed,
+		ConfigPath: *configPath,
+	}, nil
+}
+
+func parseListUnreadFlags(args []string) (ListUnreadOptions, error) {
+	fs := flag.NewFlagSet("list-unread", flag.ContinueOnError)
+	configPath := fs.String("config", "./config.ini", "Path to config file")
+	limit := fs.Int("limit", 50, "Number of newest unread entries listed")
+
+	if err := parseFlags(fs, args); err != nil {
+		return ListUnreadOptions{}, fmt.Errorf("parsing flags: %w", err)
+	}
+
+	if *limit < 1 {
+		return ListUnreadOptions{}, fmt.Errorf("--limit must be at least 1, got %d", *limit)
+	}
+
+	return ListUnreadOptions{
+		ConfigPath: *configPath,
+		Limit:      *limit,
+	}, nil
+}
+
+func parseListStarredFlags(args []string) (ListStarredOptions, error) {
+	fs := flag.NewFlagSet("list-starred", flag.ContinueOnError)
+	configPath := fs.String("config", "./config.ini", "Path to config file")
+
+	if err := parseFlags(fs, args); err != nil {
+		return ListStarredOptions{}, fmt.Errorf("parsing flags: %w", err)
+	}
+
+	return ListStarredOptions{
+		ConfigPath: *configPath,
 	}, nil
 }
 
 func parseStatusFlags(args []string) (StatusOptions, error) {
 	fs := flag.NewFlagSet("status", flag.ContinueOnError)
 	configPath := fs.String("config", "./config.ini", "Path to config file")
+	feed := fs.String("feed", "", "Show detailed diagnostics for one feed URL")
 
-	if err := fs.Parse(args); err != nil {
+	if err := parseFlags(fs, args); err != nil {
 		return StatusOptions{}, fmt.Errorf("parsing flags: %w", err)
 	}
 
 	return StatusOptions{
 		ConfigPath: *configPath,
+		Feed:       *feed,
+	}, nil
+}
+
+func parseHistoryFlags(args []string) (HistoryOptions, error) {
+	fs := flag.NewFlagSet("history", flag.ContinueOnError)
+	configPath := fs.String("config", "./config.ini", "Path to config file")
+	feed := fs.String("feed", "", "Feed URL whose fetch attempts are shown")
+	limit := fs.Int("limit", 50, "Number of most recent fetch attempts shown")
+
+	if err := parseFlags(fs, args); err != nil {
+		return HistoryOptions{}, fmt.Errorf("parsing flags: %w", err)
+	}
+
+	if *feed == "" {
+		return HistoryOptions{}, fmt.Errorf("missing --feed URL")
+	}
+	if *limit < 1 {
+		return HistoryOptions{}, fmt.Errorf("--limit must be at least 1, got %d", *limit)
+	}
+
+	return HistoryOptions{
+		ConfigPath: *configPath,
+		Feed:       *feed,
+		Limit:      *limit,
+	}, nil
+}
+
+func parseReportFlags(args []string) (ReportOptions, error) {
+	fs := flag.NewFlagSet("report", flag.ContinueOnError)
+	configPath := fs.String("config", "./config.ini", "Path to config file")
+	staleMonths := fs.Int("stale-months", 6, "Report feeds that have published nothing for this many months")
+	failures := fs.Int("failures", 3, "Report feeds whose last this many fetches all failed with client errors (4xx)")
+
+	if err := parseFlags(fs, args); err != nil {
+		return ReportOptions{}, fmt.Errorf("parsing flags: %w", err)
+	}
+
+	if *staleMonths < 1 {
+		return ReportOptions{}, fmt.Errorf("--stale-months must be at least 1, got %d", *staleMonths)
+	}
+	if *failures < 1 {
+		return ReportOptions{}, fmt.Errorf("--failures must be at least 1, got %d", *failures)
+	}
+
+	return ReportOptions{
+		ConfigPath:  *configPath,
+		StaleMonths: *staleMonths,
+		Failures:    *failures,
 	}, nil
 }
 
@@ -111,46 +485,182 @@ func parseUpdateFlags(args []string) (UpdateOptions, error) {
 	fs := flag.NewFlagSet("update", flag.ContinueOnError)
 	configPath := fs.String("config", "./config.ini", "Path to config file")
 	verbose := fs.Bool("verbose", false, "Enable verbose logging")
+	force := fs.Bool("force", false, "Fetch feeds even if their HTTP cache has not expired")
+	wait := fs.Duration("wait", 0, "Wait this long for another run to finish instead of skipping this one (e.g. 10m)")
+	noPublish := fs.Bool("no-publish", false, "Do not run the [publish] hooks after generating")
+	selection := selectionFlags(fs)
 
-	if err := fs.Parse(args); err != nil {
+	if err := parseFlags(fs, args); err != nil {
 		return UpdateOptions{}, fmt.Errorf("parsing flags: %w", err)
 	}
+	if *wait < 0 {
+		return UpdateOptions{}, fmt.Errorf("--wait must not be negative, got %s", *wait)
+	}
 
 	return UpdateOptions{
 		ConfigPath: *configPath,
 		Verbose:    *verbose,
-		Logger:     logging.New("info"),
+		Force:      *force,
+		Wait:       *wait,
+		NoPublish:  *noPublish,
+		Selection:  selection(),
+		Logger:     newLogger(*verbose),
 	}, nil
 }
 
+// stringList is a flag that may be given several times
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ", ") }
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// selectionFlags defines the --feed, --tag, --only-errors, and --resume
+// flags of update and fetch, returning a function that builds the selection once
+// the flags are parsed
+func selectionFlags(fs *flag.FlagSet) func() planet.Selection {
+	var patterns stringList
+	fs.Var(&patterns, "feed", "Only fetch this feed URL, or feeds matching a glob such as 'https://*.example.com/*' (repeatable)")
+	tag := fs.String("tag", "", "Only fetch feeds in this category (comma-separated for several)")
+	onlyErrors := fs.Bool("only-errors", false, "Only fetch feeds whose last fetch failed")
+	resume := fs.Bool("resume", false, "Only fetch the feeds an interrupted run did not reach")
+
+	return func() planet.Selection {
+		return planet.Selection{
+			Patterns:   patterns,
+			Tags:       splitTags(*tag),
+			OnlyErrors: *onlyErrors,
+			Resume:     *resume,
+		}
+	}
+}
+
+// splitTags splits a comma-separated --tag value, dropping empty items
+func splitTags(value string) []string {
+	var tags []string
+	for _, t := range strings.Split(value, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			tags = append(tags, t)
+		}
+	}
+	return tags
+}
+
 func parseFetchFlags(args []string) (FetchOptions, error) {
 	fs := flag.NewFlagSet("fetch", flag.ContinueOnError)
 	configPath := fs.String("config", "./config.ini", "Path to config file")
 	verbose := fs.Bool("verbose", false, "Enable verbose logging")
+	traceFeed := fs.String("trace-feed", "", "Fetch a single feed and show response diagnostics")
+	force := fs.Bool("force", false, "Fetch feeds even if their HTTP cache has not expired")
+	offline := fs.Bool("offline", false, "Replay the last saved responses instead of fetching (needs response_cache_dir)")
+	fixtures := fs.String("fixtures", "", "Replay the responses rp record-fixtures saved in this directory instead of fetching")
+	wait := fs.Duration("wait", 0, "Wait this long for another run to finish instead of skipping this one (e.g. 10m)")
+	selection := selectionFlags(fs)
 
-	if err := fs.Parse(args); err != nil {
+	if err := parseFlags(fs, args); err != nil {
 		return FetchOptions{}, fmt.Errorf("parsing flags: %w", err)
 	}
+	if *wait < 0 {
+		return FetchOptions{}, fmt.Errorf("--wait must not be negative, got %s", *wait)
+	}
 
-	return FetchOptions{
+	opts := FetchOptions{
 		ConfigPath: *configPath,
 		Verbose:    *verbose,
-		Logger:     logging.New("info"),
-	}, nil
+		TraceFeed:  *traceFeed,
+		Force:      *force,
+		Offline:    *offline,
+		Fixtures:   *fixtures,
+		Wait:       *wait,
+		Selection:  selection(),
+		Logger:     newLogger(*verbose),
+	}
+	if opts.Offline && opts.Fixtures != "" {
+		return FetchOptions{}, fmt.Errorf("--offline cannot be combined with --fixtures")
+	}
+	if opts.TraceFeed != "" && !opts.Selection.Empty() {
+		return FetchOptions{}, fmt.Errorf("--trace-feed cannot be combined with --feed, --tag, --only-errors, or --resume")
+	}
+	if opts.TraceFeed != "" && (opts.Offline || opts.Fixtures != "") {
+		return FetchOptions{}, fmt.Errorf("--trace-feed cannot be combined with --offline or --fixtures")
+	}
+	return opts, nil
 }
 
 func parseGenerateFlags(args []string) (GenerateOptions, error) {
 	fs := flag.NewFlagSet("generate", flag.ContinueOnError)
 	configPath := fs.String("config", "./config.ini", "Path to config file")
 	days := fs.Int("days", 0, "Number of days to include (overrides config)")
+	tag := fs.String("tag", "", "Only include entries with this category (comma-separated for several)")
+	noPublish := fs.Bool("no-publish", false, "Do not run the [publish] hooks after generating")
 
-	if err := fs.Parse(args); err != nil {
+	if err := parseFlags(fs, args); err != nil {
 		return GenerateOptions{}, fmt.Errorf("parsing flags: %w", err)
 	}
 
 	return GenerateOptions{
 		ConfigPath: *configPath,
 		Days:       *days,
+		Tags:       splitTags(*tag),
+		NoPublish:  *noPublish,
+	}, nil
+}
+
+func parseDigestFlags(args []string) (DigestOptions, error) {
+	fs := flag.NewFlagSet("digest", flag.ContinueOnError)
+	configPath := fs.String("config", "./config.ini", "Path to config file")
+	since := fs.Duration("since", 24*time.Hour, "Include entries first seen this long ago or since (e.g. 24h, 168h)")
+	html := fs.Bool("html", false, "Write the HTML part instead of the plain text")
+	output := fs.String("output", "", "Write the digest to this file instead of stdout")
+	send := fs.Bool("send", false, "Email the digest to the [digest] subscribers")
+
+	if err := parseFlags(fs, args); err != nil {
+		return DigestOptions{}, fmt.Errorf("parsing flags: %w", err)
+	}
+
+	if *since <= 0 {
+		return DigestOptions{}, fmt.Errorf("--since must be positive, got %s", *since)
+	}
+	if *send && (*html || *output != "") {
+		return DigestOptions{}, fmt.Errorf("--send cannot be combined with --html or --output")
+	}
+
+	return DigestOptions{
+		ConfigPath: *configPath,
+		Since:      *since,
+		HTML:       *html,
+		OutputFile: *output,
+		Send:       *send,
+	}, nil
+}
+
+func parseServeFlags(args []string) (ServeOptions, error) {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	configPath := fs.String("config", "./config.ini", "Path to config file")
+	addr := fs.String("addr", ":8080", "Address to listen on")
+	interval := fs.Duration("interval", 30*time.Minute, "Time between refreshes (0 to disable)")
+	verbose := fs.Bool("verbose", false, "Enable verbose logging")
+
+	if err := parseFlags(fs, args); err != nil {
+		return ServeOptions{}, fmt.Errorf("parsing flags: %w", err)
+	}
+
+	if *interval < 0 {
+		return ServeOptions{}, fmt.Errorf("interval must not be negative")
+	}
+	if *interval > 0 && *interval < time.Minute {
+		return ServeOptions{}, fmt.Errorf("interval must be at least 1m to avoid hammering feeds")
+	}
+
+	return ServeOptions{
+		ConfigPath: *configPath,
+		Addr:       *addr,
+		Interval:   *interval,
+		Verbose:    *verbose,
+		Logger:     newLogger(*verbose),
 	}, nil
 }
 
@@ -160,7 +670,7 @@ func parsePruneFlags(args []string) (PruneOptions, error) {
 	days := fs.Int("days", 90, "Remove entries older than N days")
 	dryRun := fs.Bool("dry-run", false, "Show what would be deleted without deleting")
 
-	if err := fs.Parse(args); err != nil {
+	if err := parseFlags(fs, args); err != nil {
 		return PruneOptions{}, fmt.Errorf("parsing flags: %w", err)
 	}
 
@@ -171,11 +681,180 @@ func parsePruneFlags(args []string) (PruneOptions, error) {
 	}, nil
 }
 
+func parseRelateFlags(args []string) (RelateOptions, error) {
+	fs := flag.NewFlagSet("relate", flag.ContinueOnError)
+	configPath := fs.String("config", "./config.ini", "Path to config file")
+
+	if err := parseFlags(fs, args); err != nil {
+		return RelateOptions{}, fmt.Errorf("parsing flags: %w", err)
+	}
+
+	return RelateOptions{ConfigPath: *configPath}, nil
+}
+
+func parseRollbackFlags(args []string) (RollbackOptions, error) {
+	fs := flag.NewFlagSet("rollback", flag.ContinueOnError)
+	configPath := fs.String("config", "./config.ini", "Path to config file")
+
+	if err := parseFlags(fs, args); err != nil {
+		return RollbackOptions{}, fmt.Errorf("parsing flags: %w", err)
+	}
+
+	return RollbackOptions{
+		ConfigPath: *configPath,
+	}, nil
+}
+
+func parseConfigFlags(args []string) (ConfigOptions, error) {
+	fs := flag.NewFlagSet("config", flag.ContinueOnError)
+	configPath := fs.String("config", "./config.ini", "Path to config file")
+
+	if err := parseFlags(fs, args); err != nil {
+		return ConfigOptions{}, fmt.Errorf("parsing flags: %w", err)
+	}
+	positional := fs.Args()
+
+	if len(positional) == 0 {
+		return ConfigOptions{}, fmt.Errorf("missing action (get or set)")
+	}
+	opts := ConfigOptions{ConfigPath: *configPath, Action: positional[0]}
+	switch {
+	case opts.Action == "get" && len(positional) == 2:
+		opts.Key = positional[1]
+	case opts.Action == "set" && len(positional) == 3:
+		opts.Key, opts.Value = positional[1], positional[2]
+	case opts.Action == "get" || opts.Action == "set":
+		return ConfigOptions{}, fmt.Errorf("wrong number of arguments for config %s", opts.Action)
+	default:
+		return ConfigOptions{}, fmt.Errorf("unknown config action %q (want get or set)", opts.Action)
+	}
+	return opts, nil
+}
+
+func parsePlanetsFlags(args []string) (PlanetsOptions, error) {
+	if helpRequested(args) {
+		return PlanetsOptions{}, &helpError{}
+	}
+	if len(args) == 0 {
+		return PlanetsOptions{}, fmt.Errorf("missing action (list, add, remove, or update)")
+	}
+	opts := PlanetsOptions{Action: args[0]}
+	args = args[1:]
+
+	switch opts.Action {
+	case "list":
+		if len(args) != 0 {
+			return PlanetsOptions{}, fmt.Errorf("planets list takes no arguments")
+		}
+	case "add":
+		if len(args) < 1 || len(args) > 2 {
+			return PlanetsOptions{}, fmt.Errorf("usage: rp planets add <name> [config]")
+		}
+		opts.Name, opts.ConfigPath = args[0], "./config.ini"
+		if len(args) == 2 {
+			opts.ConfigPath = args[1]
+		}
+		if err := config.ValidatePlanetName(opts.Name); err != nil {
+			return PlanetsOptions{}, err
+		}
+	case "remove":
+		if len(args) != 1 {
+			return PlanetsOptions{}, fmt.Errorf("usage: rp planets remove <name>")
+		}
+		opts.Name = args[0]
+	case "update":
+		// Each planet's update gets these flags; check them now rather than once per planet
+		for _, arg := range args {
+			if arg == "--config" || arg == "-config" || strings.HasPrefix(arg, "--config=") || strings.HasPrefix(arg, "-config=") {
+				return PlanetsOptions{}, fmt.Errorf("planets update uses each planet's own config; --config cannot be given")
+			}
+		}
+		if _, err := parseUpdateFlags(args); err != nil {
+			return PlanetsOptions{}, err
+		}
+		opts.UpdateArgs = args
+	default:
+		return PlanetsOptions{}, fmt.Errorf("unknown planets action %q (want list, add, remove, or update)", opts.Action)
+	}
+	return opts, nil
+}
+
+func parseInstallServiceFlags(args []string) (ServiceOptions, error) {
+	fs := flag.NewFlagSet("install-service", flag.ContinueOnError)
+	configPath := fs.String("config", "./config.ini", "Path to config file")
+	interval := fs.Duration("interval", 30*time.Minute, "Time between updates")
+	opts := serviceFlags(fs)
+
+	if err := parseFlags(fs, args); err != nil {
+		return ServiceOptions{}, fmt.Errorf("parsing flags: %w", err)
+	}
+	if err := validateServiceInterval(*interval); err != nil {
+		return ServiceOptions{}, err
+	}
+	if err := opts.validate(); err != nil {
+		return ServiceOptions{}, err
+	}
+
+	opts.ConfigPath = *configPath
+	opts.Interval = *interval
+	return *opts, nil
+}
+
+func parseUninstallServiceFlags(args []string) (ServiceOptions, error) {
+	fs := flag.NewFlagSet("uninstall-service", flag.ContinueOnError)
+	configPath := fs.String("config", "./config.ini", "Path to config file")
+	opts := serviceFlags(fs)
+
+	if err := parseFlags(fs, args); err != nil {
+		return ServiceOptions{}, fmt.Errorf("parsing flags: %w", err)
+	}
+	if err := opts.validate(); err != nil {
+		return ServiceOptions{}, err
+	}
+
+	opts.ConfigPath = *configPath
+	return *opts, nil
+}
+
+// serviceFlags defines the flags install-service and uninstall-service share
+func serviceFlags(fs *flag.FlagSet) *ServiceOptions {
+	opts := &ServiceOptions{}
+	fs.StringVar(&opts.Kind, "kind", defaultServiceKind(), "Service manager: systemd, launchd, or cron")
+	fs.StringVar(&opts.Name, "name", "rogue-planet", "Name of the service")
+	fs.StringVar(&opts.Dir, "dir", "", "Directory for unit files (default: the service manager's per-user directory)")
+	fs.BoolVar(&opts.DryRun, "dry-run", false, "Show what would be done without doing it")
+	return opts
+}
+
+// validateServiceInterval checks the time between a service's updates
+func validateServiceInterval(interval time.Duration) error {
+	if interval < time.Minute {
+		return fmt.Errorf("interval must be at least 1m to avoid hammering feeds")
+	}
+	if interval%time.Second != 0 {
+		return fmt.Errorf("interval must be whole seconds")
+	}
+	return nil
+}
+
+// validate checks the flags serviceFlags defines
+func (opts ServiceOptions) validate() error {
+	switch opts.Kind {
+	case serviceSystemd, serviceLaunchd, serviceCron:
+	default:
+		return fmt.Errorf("kind must be systemd, launchd, or cron, not %q", opts.Kind)
+	}
+	if opts.Dir != "" && opts.Kind == serviceCron {
+		return fmt.Errorf("--dir does not apply to cron, which keeps its entries in the crontab")
+	}
+	return validServiceName(opts.Name)
+}
+
 func parseVerifyFlags(args []string) (VerifyOptions, error) {
 	fs := flag.NewFlagSet("verify", flag.ContinueOnError)
 	configPath := fs.String("config", "./config.ini", "Path to config file")
 
-	if err := fs.Parse(args); err != nil {
+	if err := parseFlags(fs, args); err != nil {
 		return VerifyOptions{}, fmt.Errorf("parsing flags: %w", err)
 	}
 
@@ -184,12 +863,70 @@ func parseVerifyFlags(args []string) (VerifyOptions, error) {
 	}, nil
 }
 
+func parseRecordFixturesFlags(args []string) (RecordFixturesOptions, error) {
+	fs := flag.NewFlagSet("record-fixtures", flag.ContinueOnError)
+	configPath := fs.String("config", "./config.ini", "Path to config file")
+	var feeds stringList
+	fs.Var(&feeds, "feed", "Record this feed URL (repeatable; default: every active feed)")
+	dir := fs.String("output", "fixtures", "Directory to write the fixtures to")
+
+	if err := parseFlags(fs, args); err != nil {
+		return RecordFixturesOptions{}, fmt.Errorf("parsing flags: %w", err)
+	}
+	if *dir == "" {
+		return RecordFixturesOptions{}, fmt.Errorf("--output must name a directory")
+	}
+
+	return RecordFixturesOptions{
+		ConfigPath: *configPath,
+		Feeds:      feeds,
+		Dir:        *dir,
+	}, nil
+}
+
+func parseValidateFeedFlags(args []string) (ValidateFeedOptions, error) {
+	fs := flag.NewFlagSet("validate-feed", flag.ContinueOnError)
+	configPath := fs.String("config", "./config.ini", "Path to config file (optional; supplies crawler and sanitizer settings)")
+	baseURL := fs.String("url", "", "URL a feed file will be served from, for resolving its relative links")
+
+	if err := parseFlags(fs, args); err != nil {
+		return ValidateFeedOptions{}, fmt.Errorf("parsing flags: %w", err)
+	}
+
+	if fs.NArg() < 1 {
+		return ValidateFeedOptions{}, fmt.Errorf("missing feed URL or file argument")
+	}
+
+	return ValidateFeedOptions{
+		Source:     fs.Arg(0),
+		BaseURL:    *baseURL,
+		ConfigPath: *configPath,
+	}, nil
+}
+
+func parseDoctorFlags(args []string) (DoctorOptions, error) {
+	fs := flag.NewFlagSet("doctor", flag.ContinueOnError)
+	configPath := fs.String("config", "./config.ini", "Path to config file")
+	fix := fs.Bool("fix", false, "Delete orphaned database rows")
+	offline := fs.Bool("offline", false, "Skip DNS and connectivity checks")
+
+	if err := parseFlags(fs, args); err != nil {
+		return DoctorOptions{}, fmt.Errorf("parsing flags: %w", err)
+	}
+
+	return DoctorOptions{
+		ConfigPath: *configPath,
+		Fix:        *fix,
+		Offline:    *offline,
+	}, nil
+}
+
 func parseImportOPMLFlags(args []string) (ImportOPMLOptions, error) {
 	fs := flag.NewFlagSet("import-opml", flag.ContinueOnError)
 	configPath := fs.String("config", "./config.ini", "Path to config file")
 	dryRun := fs.Bool("dry-run", false, "Preview feeds without importing")
 
-	if err := fs.Parse(args); err != nil {
+	if err := parseFlags(fs, args); err != nil {
 		return ImportOPMLOptions{}, fmt.Errorf("parsing flags: %w", err)
 	}
 
@@ -204,17 +941,142 @@ func parseImportOPMLFlags(args []string) (ImportOPMLOptions, error) {
 	}, nil
 }
 
+func parseImportFlags(args []string) (ImportOptions, error) {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	configPath := fs.String("config", "./config.ini", "Path to config file")
+	dryRun := fs.Bool("dry-run", false, "Report what would be imported without importing it")
+
+	if err := parseFlags(fs, args); err != nil {
+		return ImportOptions{}, fmt.Errorf("parsing flags: %w", err)
+	}
+
+	if fs.NArg() < 1 {
+		return ImportOptions{}, fmt.Errorf("missing database file or Venus cache directory argument")
+	}
+
+	return ImportOptions{
+		Source:     fs.Arg(0),
+		ConfigPath: *configPath,
+		DryRun:     *dryRun,
+	}, nil
+}
+
 func parseExportOPMLFlags(args []string) (ExportOPMLOptions, error) {
 	fs := flag.NewFlagSet("export-opml", flag.ContinueOnError)
 	configPath := fs.String("config", "./config.ini", "Path to config file")
 	output := fs.String("output", "", "Output file (default: stdout)")
+	health := fs.Bool("health", false, "Include fetch health attributes (last fetched, error count, last error)")
 
-	if err := fs.Parse(args); err != nil {
+	if err := parseFlags(fs, args); err != nil {
 		return ExportOPMLOptions{}, fmt.Errorf("parsing flags: %w", err)
 	}
 
 	return ExportOPMLOptions{
 		ConfigPath: *configPath,
 		OutputFile: *output,
+		Health:     *health,
+	}, nil
+}
+
+// exportFormats are the formats rp export writes
+var exportFormats = []string{"jsonl", "csv", "sqlite"}
+
+func parseExportFlags(args []string) (ExportOptions, error) {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	configPath := fs.String("config", "./config.ini", "Path to config file")
+	format := fs.String("format", "jsonl", "Output format: jsonl, csv, or sqlite")
+	output := fs.String("output", "", "Output file (default: stdout; required for sqlite)")
+	var patterns stringList
+	fs.Var(&patterns, "feed", "Only export entries of this feed URL, or feeds matching a glob (repeatable)")
+	tag := fs.String("tag", "", "Only export entries of feeds in this category (comma-separated for several)")
+	since := fs.String("since", "", "Only export entries published on or after this date (YYYY-MM-DD or RFC 3339)")
+	until := fs.String("until", "", "Only export entries published on or before this date (YYYY-MM-DD or RFC 3339)")
+
+	if err := parseFlags(fs, args); err != nil {
+		return ExportOptions{}, fmt.Errorf("parsing flags: %w", err)
+	}
+
+	if !slices.Contains(exportFormats, *format) {
+		return ExportOptions{}, fmt.Errorf("--format must be one of %s, got %q", strings.Join(exportFormats, ", "), *format)
+	}
+	if *format == "sqlite" && *output == "" {
+		return ExportOptions{}, fmt.Errorf("--format sqlite needs --output FILE")
+	}
+	sinceTime, err := parseDateFlag("since", *since, false)
+	if err != nil {
+		return ExportOptions{}, err
+	}
+	untilTime, err := parseDateFlag("until", *until, true)
+	if err != nil {
+		return ExportOptions{}, err
+	}
+	if !sinceTime.IsZero() && !untilTime.IsZero() && !sinceTime.Before(untilTime) {
+		return ExportOptions{}, fmt.Errorf("--since must be before --until")
+	}
+
+	return ExportOptions{
+		ConfigPath: *configPath,
+		Format:     *format,
+		OutputFile: *output,
+		Selection:  planet.Selection{Patterns: patterns, Tags: splitTags(*tag)},
+		Since:      sinceTime,
+		Until:      untilTime,
+	}, nil
+}
+
+// parseDateFlag parses the value of a date flag, a local date (YYYY-MM-DD)
+// or an RFC 3339 time, as the start of a range. If inclusiveEnd is true it
+// is parsed as the exclusive end of a range that includes the date or time:
+// the next day, or the next second. An empty value is the zero time.
+func parseDateFlag(name, value string, inclusiveEnd bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		if inclusiveEnd {
+			// Stored times have whole seconds
+			return t.Truncate(time.Second).Add(time.Second), nil
+		}
+		return t, nil
+	}
+	day, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("--%s must be a date (YYYY-MM-DD) or RFC 3339 time, got %q", name, value)
+	}
+	if inclusiveEnd {
+		return day.AddDate(0, 0, 1), nil
+	}
+	return day, nil
+}
+
+func parseVersionFlags(args []string) (VersionOptions, error) {
+	fs := flag.NewFlagSet("version", flag.ContinueOnError)
+	verbose := fs.Bool("verbose", false, "Show detailed build information")
+
+	if err := parseFlags(fs, args); err != nil {
+		return VersionOptions{}, fmt.Errorf("parsing flags: %w", err)
+	}
+
+	return VersionOptions{
+		Verbose: *verbose,
+	}, nil
+}
+
+func parseCompletionFlags(args []string) (CompletionOptions, error) {
+	fs := flag.NewFlagSet("completion", flag.ContinueOnError)
+
+	if err := parseFlags(fs, args); err != nil {
+		return CompletionOptions{}, fmt.Errorf("parsing flags: %w", err)
+	}
+
+	if fs.NArg() != 1 {
+		return CompletionOptions{}, fmt.Errorf("missing shell argument (%s)", strings.Join(completionShells, ", "))
+	}
+	if !slices.Contains(completionShells, fs.Arg(0)) {
+		return CompletionOptions{}, fmt.Errorf("unknown shell %q (want %s)", fs.Arg(0), strings.Join(completionShells, ", "))
+	}
+
+	return CompletionOptions{
+		Shell: fs.Arg(0),
 	}, nil
 }
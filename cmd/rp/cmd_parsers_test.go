@@ -1,18 +1,25 @@
 package main
 
 import (
+	"errors"
+	"flag"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/adewale/rogue_planet/pkg/planet"
 )
 
 func TestParseInitFlags(t *testing.T) {
 	t.Parallel()
 
 	tests := []struct {
-		name      string
-		args      []string
-		wantFile  string
-		wantError bool
+		name            string
+		args            []string
+		wantFile        string
+		wantInteractive bool
+		wantError       bool
 	}{
 		{
 			name:      "no flags",
@@ -26,6 +33,16 @@ func TestParseInitFlags(t *testing.T) {
 			wantFile:  "feeds.txt",
 			wantError: false,
 		},
+		{
+			name:            "interactive",
+			args:            []string{"--interactive"},
+			wantInteractive: true,
+		},
+		{
+			name:      "interactive with feeds file",
+			args:      []string{"--interactive", "-f", "feeds.txt"},
+			wantError: true,
+		},
 		{
 			name:      "invalid flag",
 			args:      []string{"-invalid"},
@@ -48,6 +65,9 @@ func TestParseInitFlags(t *testing.T) {
 			if opts.FeedsFile != tt.wantFile {
 				t.Errorf("FeedsFile = %q, want %q", opts.FeedsFile, tt.wantFile)
 			}
+			if opts.Interactive != tt.wantInteractive {
+				t.Errorf("Interactive = %v, want %v", opts.Interactive, tt.wantInteractive)
+			}
 			if opts.ConfigPath != "config.ini" {
 				t.Errorf("ConfigPath = %q, want %q", opts.ConfigPath, "config.ini")
 			}
@@ -63,6 +83,7 @@ func TestParseAddFeedFlags(t *testing.T) {
 		args       []string
 		wantURL    string
 		wantConfig string
+		wantFetch  bool
 		wantError  bool
 	}{
 		{
@@ -72,6 +93,20 @@ func TestParseAddFeedFlags(t *testing.T) {
 			wantConfig: "./config.ini",
 			wantError:  false,
 		},
+		{
+			name:       "fetch before url",
+			args:       []string{"-fetch", "https://example.com/feed.xml"},
+			wantURL:    "https://example.com/feed.xml",
+			wantConfig: "./config.ini",
+			wantFetch:  true,
+		},
+		{
+			name:       "fetch after url",
+			args:       []string{"https://example.com/feed.xml", "--fetch"},
+			wantURL:    "https://example.com/feed.xml",
+			wantConfig: "./config.ini",
+			wantFetch:  true,
+		},
 		{
 			name:       "url with custom config",
 			args:       []string{"-config", "/tmp/config.ini", "https://example.com/feed.xml"},
@@ -109,6 +144,9 @@ func TestParseAddFeedFlags(t *testing.T) {
 			if opts.ConfigPath != tt.wantConfig {
 				t.Errorf("ConfigPath = %q, want %q", opts.ConfigPath, tt.wantConfig)
 			}
+			if opts.Fetch != tt.wantFetch {
+				t.Errorf("Fetch = %v, want %v", opts.Fetch, tt.wantFetch)
+			}
 		})
 	}
 }
@@ -249,6 +287,8 @@ func TestParseGenerateFlags(t *testing.T) {
 		args       []string
 		wantDays   int
 		wantConfig string
+		wantTags   string
+		wantNoPub  bool
 		wantError  bool
 	}{
 		{
@@ -258,6 +298,18 @@ func TestParseGenerateFlags(t *testing.T) {
 			wantConfig: "./config.ini",
 			wantError:  false,
 		},
+		{
+			name:       "without publishing",
+			args:       []string{"-no-publish"},
+			wantConfig: "./config.ini",
+			wantNoPub:  true,
+		},
+		{
+			name:       "with tags",
+			args:       []string{"-tag", "go, rust,,"},
+			wantConfig: "./config.ini",
+			wantTags:   "go|rust",
+		},
 		{
 			name:       "with days",
 			args:       []string{"-days", "14"},
@@ -297,6 +349,12 @@ func TestParseGenerateFlags(t *testing.T) {
 			if opts.ConfigPath != tt.wantConfig {
 				t.Errorf("ConfigPath = %q, want %q", opts.ConfigPath, tt.wantConfig)
 			}
+			if got := strings.Join(opts.Tags, "|"); got != tt.wantTags {
+				t.Errorf("Tags = %q, want %q", got, tt.wantTags)
+			}
+			if opts.NoPublish != tt.wantNoPub {
+				t.Errorf("NoPublish = %v, want %v", opts.NoPublish, tt.wantNoPub)
+			}
 		})
 	}
 }
@@ -379,6 +437,7 @@ func TestParseUpdateFlags(t *testing.T) {
 		args        []string
 		wantVerbose bool
 		wantConfig  string
+		wantForce   bool
 		wantError   bool
 	}{
 		{
@@ -402,6 +461,14 @@ func TestParseUpdateFlags(t *testing.T) {
 			wantConfig:  "/tmp/config.ini",
 			wantError:   false,
 		},
+		{
+			name:        "with force",
+			args:        []string{"--force"},
+			wantVerbose: false,
+			wantConfig:  "./config.ini",
+			wantForce:   true,
+			wantError:   false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -422,6 +489,9 @@ func TestParseUpdateFlags(t *testing.T) {
 			if opts.ConfigPath != tt.wantConfig {
 				t.Errorf("ConfigPath = %q, want %q", opts.ConfigPath, tt.wantConfig)
 			}
+			if opts.Force != tt.wantForce {
+				t.Errorf("Force = %v, want %v", opts.Force, tt.wantForce)
+			}
 			if opts.Logger == nil {
 				t.Error("Logger should not be nil")
 			}
@@ -504,6 +574,7 @@ func TestParseExportOPMLFlags(t *testing.T) {
 		args       []string
 		wantOutput string
 		wantConfig string
+		wantHealth bool
 		wantError  bool
 	}{
 		{
@@ -527,6 +598,14 @@ func TestParseExportOPMLFlags(t *testing.T) {
 			wantConfig: "/tmp/config.ini",
 			wantError:  false,
 		},
+		{
+			name:       "with health",
+			args:       []string{"--health"},
+			wantOutput: "",
+			wantConfig: "./config.ini",
+			wantHealth: true,
+			wantError:  false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -547,6 +626,112 @@ func TestParseExportOPMLFlags(t *testing.T) {
 			if opts.ConfigPath != tt.wantConfig {
 				t.Errorf("ConfigPath = %q, want %q", opts.ConfigPath, tt.wantConfig)
 			}
+			if opts.Health != tt.wantHealth {
+				t.Errorf("Health = %v, want %v", opts.Health, tt.wantHealth)
+			}
+		})
+	}
+}
+
+func TestParseImportFlags(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		args      []string
+		want      ImportOptions
+		wantError bool
+	}{
+		{name: "database", args: []string{"old.db"}, want: ImportOptions{Source: "old.db", ConfigPath: "./config.ini"}},
+		{name: "dry run before", args: []string{"--dry-run", "cache"}, want: ImportOptions{Source: "cache", ConfigPath: "./config.ini", DryRun: true}},
+		{name: "dry run after", args: []string{"cache", "--dry-run"}, want: ImportOptions{Source: "cache", ConfigPath: "./config.ini", DryRun: true}},
+		{name: "missing source", args: []string{}, wantError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, err := parseImportFlags(tt.args)
+			if tt.wantError {
+				if err == nil {
+					t.Error("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if opts != tt.want {
+				t.Errorf("parseImportFlags() = %+v, want %+v", opts, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseExportFlags(t *testing.T) {
+	t.Parallel()
+
+	day := func(s string) time.Time {
+		d, err := time.ParseInLocation("2006-01-02", s, time.Local)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	tests := []struct {
+		name      string
+		args      []string
+		want      ExportOptions
+		wantError bool
+	}{
+		{
+			name: "defaults",
+			args: []string{},
+			want: ExportOptions{ConfigPath: "./config.ini", Format: "jsonl"},
+		},
+		{
+			name: "csv for a date range",
+			args: []string{"--format", "csv", "--output", "out.csv", "--since", "2024-01-01", "--until", "2024-01-31"},
+			want: ExportOptions{ConfigPath: "./config.ini", Format: "csv", OutputFile: "out.csv", Since: day("2024-01-01"), Until: day("2024-02-01")},
+		},
+		{
+			name: "until an RFC 3339 time includes it",
+			args: []string{"--until", "2024-01-31T12:00:00Z"},
+			want: ExportOptions{ConfigPath: "./config.ini", Format: "jsonl", Until: time.Date(2024, 1, 31, 12, 0, 1, 0, time.UTC)},
+		},
+		{
+			name: "feeds and tags",
+			args: []string{"--feed", "https://a.example.com/*", "--feed", "https://b.example.com/feed", "--tag", "go, rust"},
+			want: ExportOptions{ConfigPath: "./config.ini", Format: "jsonl", Selection: planet.Selection{
+				Patterns: []string{"https://a.example.com/*", "https://b.example.com/feed"},
+				Tags:     []string{"go", "rust"},
+			}},
+		},
+		{
+			name: "sqlite",
+			args: []string{"--format", "sqlite", "--output", "corpus.db"},
+			want: ExportOptions{ConfigPath: "./config.ini", Format: "sqlite", OutputFile: "corpus.db"},
+		},
+		{name: "sqlite to stdout", args: []string{"--format", "sqlite"}, wantError: true},
+		{name: "unknown format", args: []string{"--format", "xml"}, wantError: true},
+		{name: "bad date", args: []string{"--since", "last week"}, wantError: true},
+		{name: "since after until", args: []string{"--since", "2024-02-01", "--until", "2024-01-01"}, wantError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, err := parseExportFlags(tt.args)
+			if tt.wantError {
+				if err == nil {
+					t.Error("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(opts, tt.want) {
+				t.Errorf("parseExportFlags() = %+v, want %+v", opts, tt.want)
+			}
 		})
 	}
 }
@@ -570,6 +755,150 @@ func TestParseListFeedsFlags(t *testing.T) {
 	if opts.ConfigPath != "/tmp/config.ini" {
 		t.Errorf("ConfigPath = %q, want %q", opts.ConfigPath, "/tmp/config.ini")
 	}
+	if opts.Errors {
+		t.Error("Errors = true, want false by default")
+	}
+
+	opts, err = parseListFeedsFlags([]string{"--errors"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !opts.Errors {
+		t.Error("Errors = false, want true")
+	}
+}
+
+func TestParseReactivateFeedFlags(t *testing.T) {
+	t.Parallel()
+
+	opts, err := parseReactivateFeedFlags([]string{"--config", "/tmp/config.ini", "https://example.com/feed.xml"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if opts.URL != "https://example.com/feed.xml" {
+		t.Errorf("URL = %q, want %q", opts.URL, "https://example.com/feed.xml")
+	}
+	if opts.ConfigPath != "/tmp/config.ini" {
+		t.Errorf("ConfigPath = %q, want %q", opts.ConfigPath, "/tmp/config.ini")
+	}
+
+	if _, err := parseReactivateFeedFlags([]string{}); err == nil {
+		t.Error("expected error for missing URL, got nil")
+	}
+}
+
+func TestParseBulkFeedsFlags(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		command string
+		args    []string
+		want    BulkFeedsOptions
+		wantErr bool
+	}{
+		{
+			name:    "match and dry run",
+			command: "remove-feeds",
+			args:    []string{"--match", "blogspot.com", "--match", "wordpress", "--dry-run"},
+			want: BulkFeedsOptions{Action: "remove", ConfigPath: "./config.ini", DryRun: true,
+				Selection: planet.Selection{Match: []string{"blogspot.com", "wordpress"}}},
+		},
+		{
+			name:    "errors-gt 0 selects any errors",
+			command: "deactivate-feeds",
+			args:    []string{"--errors-gt", "0", "--tag", "go,web"},
+			want: BulkFeedsOptions{Action: "deactivate", ConfigPath: "./config.ini",
+				Selection: planet.Selection{Tags: []string{"go", "web"}, MinErrors: 1}},
+		},
+		{
+			name:    "inactive forced",
+			command: "remove-feeds",
+			args:    []string{"--inactive", "--force", "--feed", "https://*.example.com/*"},
+			want: BulkFeedsOptions{Action: "remove", ConfigPath: "./config.ini", Force: true,
+				Selection: planet.Selection{Patterns: []string{"https://*.example.com/*"}, Inactive: true}},
+		},
+		{name: "no selection", command: "remove-feeds", args: []string{"--dry-run"}, wantErr: true},
+		{name: "negative errors", command: "deactivate-feeds", args: []string{"--errors-gt", "-1"}, wantErr: true},
+		{name: "argument", command: "remove-feeds", args: []string{"https://example.com/feed"}, wantErr: true},
+		{name: "force only removes", command: "reactivate-feeds", args: []string{"--inactive", "--force"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := parseBulkFeedsFlags(tt.command, tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseBulkFeedsFlags() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseBulkFeedsFlags() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRenameFeedFlags(t *testing.T) {
+	t.Parallel()
+
+	opts, err := parseRenameFeedFlags([]string{"http://example.com/rss", "https://example.com/feed.xml", "--config", "/tmp/config.ini"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if opts.OldURL != "http://example.com/rss" || opts.NewURL != "https://example.com/feed.xml" {
+		t.Errorf("OldURL, NewURL = %q, %q", opts.OldURL, opts.NewURL)
+	}
+	if opts.ConfigPath != "/tmp/config.ini" {
+		t.Errorf("ConfigPath = %q, want %q", opts.ConfigPath, "/tmp/config.ini")
+	}
+
+	for _, args := range [][]string{{}, {"https://example.com/feed.xml"}, {"a", "b", "c"}} {
+		if _, err := parseRenameFeedFlags(args); err == nil {
+			t.Errorf("parseRenameFeedFlags(%q): expected error, got nil", args)
+		}
+	}
+}
+
+func TestParseEditFeedFlags(t *testing.T) {
+	t.Parallel()
+
+	opts, err := parseEditFeedFlags([]string{"https://example.com/feed.xml", "--title", "Blog", "--tags", "go, web,"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if opts.URL != "https://example.com/feed.xml" {
+		t.Errorf("URL = %q", opts.URL)
+	}
+	if opts.Title == nil || *opts.Title != "Blog" {
+		t.Errorf("Title = %v, want Blog", opts.Title)
+	}
+	if !reflect.DeepEqual(opts.Tags, []string{"go", "web"}) {
+		t.Errorf("Tags = %q, want [go web]", opts.Tags)
+	}
+	if opts.Active != nil {
+		t.Errorf("Active = %v, want nil when --active is not given", *opts.Active)
+	}
+
+	// Empty values clear the title and tags rather than leaving them
+	opts, err = parseEditFeedFlags([]string{"--title", "", "--tags", "", "--active=false", "https://example.com/feed.xml"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if opts.Title == nil || *opts.Title != "" {
+		t.Errorf("Title = %v, want empty", opts.Title)
+	}
+	if opts.Tags == nil || len(opts.Tags) != 0 {
+		t.Errorf("Tags = %#v, want an empty slice", opts.Tags)
+	}
+	if opts.Active == nil || *opts.Active {
+		t.Errorf("Active = %v, want false", opts.Active)
+	}
+
+	if _, err := parseEditFeedFlags([]string{"--title", "Blog"}); err == nil {
+		t.Error("expected error for missing URL, got nil")
+	}
+	if _, err := parseEditFeedFlags([]string{"https://example.com/feed.xml"}); err == nil || !strings.Contains(err.Error(), "nothing to change") {
+		t.Errorf("expected error for no changes, got %v", err)
+	}
 }
 
 func TestParseStatusFlags(t *testing.T) {
@@ -582,31 +911,694 @@ func TestParseStatusFlags(t *testing.T) {
 	if opts.ConfigPath != "./config.ini" {
 		t.Errorf("ConfigPath = %q, want %q", opts.ConfigPath, "./config.ini")
 	}
+	if opts.Feed != "" {
+		t.Errorf("Feed = %q, want empty", opts.Feed)
+	}
+
+	opts, err = parseStatusFlags([]string{"--feed", "https://example.com/feed.xml"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if opts.Feed != "https://example.com/feed.xml" {
+		t.Errorf("Feed = %q, want %q", opts.Feed, "https://example.com/feed.xml")
+	}
 }
 
-func TestParseVerifyFlags(t *testing.T) {
+func TestParseHistoryFlags(t *testing.T) {
 	t.Parallel()
 
-	opts, err := parseVerifyFlags([]string{})
+	opts, err := parseHistoryFlags([]string{"--feed", "https://example.com/feed.xml"})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
-	if opts.ConfigPath != "./config.ini" {
-		t.Errorf("ConfigPath = %q, want %q", opts.ConfigPath, "./config.ini")
+	if opts.Feed != "https://example.com/feed.xml" || opts.Limit != 50 || opts.ConfigPath != "./config.ini" {
+		t.Errorf("opts = %+v, want feed URL, limit 50, ./config.ini", opts)
+	}
+
+	opts, err = parseHistoryFlags([]string{"--feed", "https://example.com/feed.xml", "--limit", "5"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if opts.Limit != 5 {
+		t.Errorf("Limit = %d, want 5", opts.Limit)
+	}
+
+	for _, args := range [][]string{
+		{},
+		{"--feed", "https://example.com/feed.xml", "--limit", "0"},
+	} {
+		if _, err := parseHistoryFlags(args); err == nil {
+			t.Errorf("parseHistoryFlags(%q) succeeded, want error", args)
+		}
 	}
 }
 
-func TestParseFetchFlags(t *testing.T) {
+func TestParseReportFlags(t *testing.T) {
 	t.Parallel()
 
-	opts, err := parseFetchFlags([]string{"-verbose"})
+	opts, err := parseReportFlags(nil)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
-	if !opts.Verbose {
-		t.Error("Verbose should be true")
+	if opts.StaleMonths != 6 || opts.Failures != 3 || opts.ConfigPath != "./config.ini" {
+		t.Errorf("opts = %+v, want 6 months, 3 failures, ./config.ini", opts)
 	}
-	if opts.Logger == nil {
-		t.Error("Logger should not be nil")
+
+	opts, err = parseReportFlags([]string{"--stale-months", "12", "--failures", "5"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if opts.StaleMonths != 12 || opts.Failures != 5 {
+		t.Errorf("opts = %+v, want 12 months, 5 failures", opts)
+	}
+
+	for _, args := range [][]string{
+		{"--stale-months", "0"},
+		{"--failures", "0"},
+	} {
+		if _, err := parseReportFlags(args); err == nil {
+			t.Errorf("parseReportFlags(%q) succeeded, want error", args)
+		}
+	}
+}
+
+func TestParseMarkReadFlags(t *testing.T) {
+	t.Parallel()
+
+	opts, err := parseMarkReadFlags([]string{"https://example.com/post"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if opts.Ref != "https://example.com/post" || opts.All {
+		t.Errorf("opts = %+v, want one entry", opts)
+	}
+
+	opts, err = parseMarkReadFlags([]string{"--all", "--feed", "https://example.com/feed.xml"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !opts.All || opts.Feed != "https://example.com/feed.xml" || opts.Ref != "" {
+		t.Errorf("opts = %+v, want all of one feed", opts)
+	}
+
+	for _, args := range [][]string{
+		{},
+		{"--all", "https://example.com/post"},
+		{"--feed", "https://example.com/feed.xml", "https://example.com/post"},
+	} {
+		if _, err := parseMarkReadFlags(args); err == nil {
+			t.Errorf("parseMarkReadFlags(%q) succeeded, want error", args)
+		}
+	}
+}
+
+func TestParseDigestFlags(t *testing.T) {
+	t.Parallel()
+
+	opts, err := parseDigestFlags(nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if opts.Since != 24*time.Hour || opts.Send || opts.HTML {
+		t.Errorf("opts = %+v, want the last day as text", opts)
+	}
+
+	opts, err = parseDigestFlags([]string{"--since", "168h", "--html", "--output", "digest.html"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if opts.Since != 168*time.Hour || !opts.HTML || opts.OutputFile != "digest.html" {
+		t.Errorf("opts = %+v", opts)
+	}
+
+	for _, args := range [][]string{
+		{"--since", "0s"},
+		{"--since", "1d"},
+		{"--send", "--html"},
+		{"--send", "--output", "digest.txt"},
+	} {
+		if _, err := parseDigestFlags(args); err == nil {
+			t.Errorf("parseDigestFlags(%q) succeeded, want error", args)
+		}
+	}
+}
+
+func TestParseRollbackFlags(t *testing.T) {
+	t.Parallel()
+
+	opts, err := parseRollbackFlags([]string{"--config", "/tmp/config.ini"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if opts.ConfigPath != "/tmp/config.ini" {
+		t.Errorf("ConfigPath = %q, want %q", opts.ConfigPath, "/tmp/config.ini")
+	}
+}
+
+func TestParseRelateFlags(t *testing.T) {
+	t.Parallel()
+
+	opts, err := parseRelateFlags([]string{"--config", "/tmp/config.ini"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if opts.ConfigPath != "/tmp/config.ini" {
+		t.Errorf("ConfigPath = %q, want %q", opts.ConfigPath, "/tmp/config.ini")
+	}
+}
+
+func TestParseShowEntryFlags(t *testing.T) {
+	t.Parallel()
+
+	opts, err := parseShowEntryFlags([]string{"--config", "/tmp/config.ini", "--revisions", "https://example.com/post"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if opts.Ref != "https://example.com/post" || !opts.Revisions || opts.ConfigPath != "/tmp/config.ini" {
+		t.Errorf("parseShowEntryFlags() = %+v", opts)
+	}
+	if _, err := parseShowEntryFlags([]string{"--revisions"}); err == nil {
+		t.Error("parseShowEntryFlags() without an entry succeeded, want error")
+	}
+}
+
+func TestParseVerifyFlags(t *testing.T) {
+	t.Parallel()
+
+	opts, err := parseVerifyFlags([]string{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if opts.ConfigPath != "./config.ini" {
+		t.Errorf("ConfigPath = %q, want %q", opts.ConfigPath, "./config.ini")
+	}
+}
+
+func TestParseValidateFeedFlags(t *testing.T) {
+	t.Parallel()
+
+	opts, err := parseValidateFeedFlags([]string{"--url", "https://example.com/feed.xml", "feed.xml"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if opts.Source != "feed.xml" || opts.BaseURL != "https://example.com/feed.xml" {
+		t.Errorf("Source, BaseURL = %q, %q, want feed.xml, https://example.com/feed.xml", opts.Source, opts.BaseURL)
+	}
+
+	if _, err := parseValidateFeedFlags([]string{}); err == nil {
+		t.Error("expected error for missing feed, got nil")
+	}
+}
+
+func TestParseFetchFlags(t *testing.T) {
+	t.Parallel()
+
+	opts, err := parseFetchFlags([]string{"-verbose"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !opts.Verbose {
+		t.Error("Verbose should be true")
+	}
+	if opts.Logger == nil {
+		t.Error("Logger should not be nil")
+	}
+	if opts.TraceFeed != "" {
+		t.Errorf("TraceFeed = %q, want empty", opts.TraceFeed)
+	}
+
+	opts, err = parseFetchFlags([]string{"--trace-feed", "https://example.com/feed.xml"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if opts.TraceFeed != "https://example.com/feed.xml" {
+		t.Errorf("TraceFeed = %q, want https://example.com/feed.xml", opts.TraceFeed)
+	}
+	if opts.Force {
+		t.Error("Force should default to false")
+	}
+
+	opts, err = parseFetchFlags([]string{"--force"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !opts.Force {
+		t.Error("Force should be true")
+	}
+
+	opts, err = parseFetchFlags([]string{"--offline"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !opts.Offline {
+		t.Error("Offline should be true")
+	}
+	if _, err := parseFetchFlags([]string{"--offline", "--trace-feed", "https://example.com/feed.xml"}); err == nil {
+		t.Error("--offline with --trace-feed should be rejected")
+	}
+
+	opts, err = parseFetchFlags([]string{"--fixtures", "testdata/fixtures"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if opts.Fixtures != "testdata/fixtures" {
+		t.Errorf("Fixtures = %q, want testdata/fixtures", opts.Fixtures)
+	}
+	if _, err := parseFetchFlags([]string{"--fixtures", "fixtures", "--offline"}); err == nil {
+		t.Error("--fixtures with --offline should be rejected")
+	}
+}
+
+func TestParseRecordFixturesFlags(t *testing.T) {
+	t.Parallel()
+
+	opts, err := parseRecordFixturesFlags([]string{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if opts.Dir != "fixtures" || len(opts.Feeds) != 0 {
+		t.Errorf("Dir, Feeds = %q, %v, want fixtures and every feed", opts.Dir, opts.Feeds)
+	}
+
+	opts, err = parseRecordFixturesFlags([]string{"--feed", "https://a.example.com/feed", "--feed", "https://b.example.com/feed", "--output", "testdata"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if opts.Dir != "testdata" || len(opts.Feeds) != 2 || opts.Feeds[1] != "https://b.example.com/feed" {
+		t.Errorf("Dir, Feeds = %q, %v", opts.Dir, opts.Feeds)
+	}
+
+	if _, err := parseRecordFixturesFlags([]string{"--output", ""}); err == nil {
+		t.Error("expected error for an empty --output, got nil")
+	}
+}
+
+func TestParseSelectionFlags(t *testing.T) {
+	t.Parallel()
+
+	args := []string{"--feed", "https://a.example.com/feed", "--feed", "https://*.example.org/*", "--tag", "go, rust", "--only-errors"}
+	update, err := parseUpdateFlags(args)
+	if err != nil {
+		t.Fatalf("parseUpdateFlags() error = %v", err)
+	}
+	fetch, err := parseFetchFlags(args)
+	if err != nil {
+		t.Fatalf("parseFetchFlags() error = %v", err)
+	}
+	for _, sel := range []planet.Selection{update.Selection, fetch.Selection} {
+		if len(sel.Patterns) != 2 || sel.Patterns[1] != "https://*.example.org/*" ||
+			len(sel.Tags) != 2 || sel.Tags[1] != "rust" || !sel.OnlyErrors {
+			t.Errorf("Selection = %+v", sel)
+		}
+	}
+
+	if opts, _ := parseUpdateFlags(nil); !opts.Selection.Empty() {
+		t.Errorf("default Selection = %+v, want empty", opts.Selection)
+	}
+	if opts, _ := parseUpdateFlags([]string{"--resume"}); !opts.Selection.Resume || opts.Selection.Empty() {
+		t.Errorf("--resume Selection = %+v, want resume", opts.Selection)
+	}
+	if _, err := parseFetchFlags([]string{"--trace-feed", "https://a.example.com/feed", "--only-errors"}); err == nil {
+		t.Error("parseFetchFlags() accepted --trace-feed with --only-errors")
+	}
+}
+
+func TestParseWaitFlag(t *testing.T) {
+	t.Parallel()
+	if opts, _ := parseUpdateFlags([]string{"--wait", "5m"}); opts.Wait != 5*time.Minute {
+		t.Errorf("--wait 5m parsed as %v", opts.Wait)
+	}
+	if _, err := parseFetchFlags([]string{"--wait", "-1s"}); err == nil {
+		t.Error("parseFetchFlags() accepted a negative --wait")
+	}
+	if opts, _ := parseFetchFlags(nil); opts.Wait != 0 {
+		t.Errorf("default Wait = %v, want 0", opts.Wait)
+	}
+}
+
+func TestParseVersionFlags(t *testing.T) {
+	t.Parallel()
+
+	opts, err := parseVersionFlags([]string{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if opts.Verbose {
+		t.Error("Verbose should default to false")
+	}
+
+	opts, err = parseVersionFlags([]string{"--verbose"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !opts.Verbose {
+		t.Error("Verbose should be true")
+	}
+}
+
+func TestParseServeFlags(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name         string
+		args         []string
+		wantAddr     string
+		wantInterval time.Duration
+		wantError    bool
+	}{
+		{"defaults", []string{}, ":8080", 30 * time.Minute, false},
+		{"custom", []string{"--addr", "127.0.0.1:9000", "--interval", "2h"}, "127.0.0.1:9000", 2 * time.Hour, false},
+		{"refresh disabled", []string{"--interval", "0"}, ":8080", 0, false},
+		{"too frequent", []string{"--interval", "10s"}, "", 0, true},
+		{"negative", []string{"--interval", "-1h"}, "", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, err := parseServeFlags(tt.args)
+			if tt.wantError {
+				if err == nil {
+					t.Error("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if opts.Addr != tt.wantAddr {
+				t.Errorf("Addr = %q, want %q", opts.Addr, tt.wantAddr)
+			}
+			if opts.Interval != tt.wantInterval {
+				t.Errorf("Interval = %v, want %v", opts.Interval, tt.wantInterval)
+			}
+			if opts.Logger == nil {
+				t.Error("Logger should not be nil")
+			}
+		})
+	}
+}
+
+func TestParseInstallServiceFlags(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name         string
+		args         []string
+		wantKind     string
+		wantName     string
+		wantInterval time.Duration
+		wantError    bool
+	}{
+		{"defaults", []string{}, defaultServiceKind(), "rogue-planet", 30 * time.Minute, false},
+		{"custom", []string{"--kind", "cron", "--name", "planet.go", "--interval", "2h", "--dry-run"}, "cron", "planet.go", 2 * time.Hour, false},
+		{"too frequent", []string{"--interval", "30s"}, "", "", 0, true},
+		{"fractional seconds", []string{"--interval", "90.5s"}, "", "", 0, true},
+		{"unknown kind", []string{"--kind", "upstart"}, "", "", 0, true},
+		{"unsafe name", []string{"--name", "../evil"}, "", "", 0, true},
+		{"dir with cron", []string{"--kind", "cron", "--dir", "/tmp"}, "", "", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, err := parseInstallServiceFlags(tt.args)
+			if tt.wantError {
+				if err == nil {
+					t.Error("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if opts.Kind != tt.wantKind || opts.Name != tt.wantName || opts.Interval != tt.wantInterval {
+				t.Errorf("got kind %q, name %q, interval %v; want %q, %q, %v", opts.Kind, opts.Name, opts.Interval, tt.wantKind, tt.wantName, tt.wantInterval)
+			}
+		})
+	}
+
+	if _, err := parseUninstallServiceFlags([]string{"--interval", "1h"}); err == nil {
+		t.Error("uninstall-service accepted --interval")
+	}
+}
+
+func TestParseConfigFlags(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		args      []string
+		want      ConfigOptions
+		wantError bool
+	}{
+		{"get", []string{"get", "planet.days"}, ConfigOptions{ConfigPath: "./config.ini", Action: "get", Key: "planet.days"}, false},
+		{"set with flag after", []string{"set", "planet.days", "14", "--config", "x.ini"}, ConfigOptions{ConfigPath: "x.ini", Action: "set", Key: "planet.days", Value: "14"}, false},
+		{"set dash value", []string{"--config", "x.ini", "set", "planet.name", "--", "-x-"}, ConfigOptions{ConfigPath: "x.ini", Action: "set", Key: "planet.name", Value: "-x-"}, false},
+		{"no action", []string{}, ConfigOptions{}, true},
+		{"unknown action", []string{"delete", "planet.days"}, ConfigOptions{}, true},
+		{"set without value", []string{"set", "planet.days"}, ConfigOptions{}, true},
+		{"get extra", []string{"get", "planet.days", "14"}, ConfigOptions{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, err := parseConfigFlags(tt.args)
+			if tt.wantError {
+				if err == nil {
+					t.Error("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if opts != tt.want {
+				t.Errorf("got %+v, want %+v", opts, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePlanetsFlags(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		args      []string
+		want      PlanetsOptions
+		wantError bool
+	}{
+		{"list", []string{"list"}, PlanetsOptions{Action: "list"}, false},
+		{"add default config", []string{"add", "work"}, PlanetsOptions{Action: "add", Name: "work", ConfigPath: "./config.ini"}, false},
+		{"add", []string{"add", "work", "w/config.ini"}, PlanetsOptions{Action: "add", Name: "work", ConfigPath: "w/config.ini"}, false},
+		{"remove", []string{"remove", "work"}, PlanetsOptions{Action: "remove", Name: "work"}, false},
+		{"no action", []string{}, PlanetsOptions{}, true},
+		{"bad name", []string{"add", "my planet"}, PlanetsOptions{}, true},
+		{"update with config", []string{"update", "--config", "x.ini"}, PlanetsOptions{}, true},
+		{"update bad flag", []string{"update", "--nope"}, PlanetsOptions{}, true},
+		{"unknown", []string{"rename", "a"}, PlanetsOptions{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, err := parsePlanetsFlags(tt.args)
+			if tt.wantError {
+				if err == nil {
+					t.Error("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if opts.Action != tt.want.Action || opts.Name != tt.want.Name || opts.ConfigPath != tt.want.ConfigPath {
+				t.Errorf("got %+v, want %+v", opts, tt.want)
+			}
+		})
+	}
+
+	opts, err := parsePlanetsFlags([]string{"update", "--force", "--tag", "go"})
+	if err != nil || strings.Join(opts.UpdateArgs, " ") != "--force --tag go" {
+		t.Errorf("planets update flags = %q, %v", opts.UpdateArgs, err)
+	}
+}
+
+func TestParseEntryFlags(t *testing.T) {
+	t.Parallel()
+
+	opts, err := parseEntryFlags("hide-entry", []string{"--config", "p.ini", "https://example.com/post"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if opts.Ref != "https://example.com/post" || opts.ConfigPath != "p.ini" {
+		t.Errorf("got %+v", opts)
+	}
+	if _, err := parseEntryFlags("unhide-entry", []string{}); err == nil {
+		t.Error("expected error for a missing entry")
+	}
+}
+
+func TestParseFlagsAnywhere(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		parse   func([]string) (any, error)
+		args    []string
+		want    any
+		wantErr bool
+	}{
+		{"flag after argument", func(a []string) (any, error) { return parseRemoveFeedFlags(a) },
+			[]string{"https://example.com/feed.xml", "--force", "--config", "p.ini"},
+			RemoveFeedOptions{URL: "https://example.com/feed.xml", ConfigPath: "p.ini", Force: true}, false},
+		{"flag between arguments", func(a []string) (any, error) { return parseImportOPMLFlags(a) },
+			[]string{"--config", "p.ini", "feeds.opml", "--dry-run"},
+			ImportOPMLOptions{OPMLFile: "feeds.opml", ConfigPath: "p.ini", DryRun: true}, false},
+		{"argument after --", func(a []string) (any, error) { return parseEntryFlags("hide-entry", a) },
+			[]string{"--config", "p.ini", "--", "--odd-id"},
+			EntryOptions{Ref: "--odd-id", ConfigPath: "p.ini"}, false},
+		{"unknown flag after argument", func(a []string) (any, error) { return parseRemoveFeedFlags(a) },
+			[]string{"https://example.com/feed.xml", "--nope"}, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := tt.parse(tt.args)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFlagsHelp(t *testing.T) {
+	t.Parallel()
+
+	for _, args := range [][]string{{"--help"}, {"-h"}, {"https://example.com/feed.xml", "-help"}} {
+		_, err := parseAddFeedFlags(args)
+		var help *helpError
+		if !errors.As(err, &help) || help.fs == nil || help.fs.Lookup("fetch") == nil {
+			t.Errorf("parseAddFeedFlags(%q) error = %v, want help with the command's flags", args, err)
+		}
+		if !errors.Is(err, flag.ErrHelp) {
+			t.Errorf("parseAddFeedFlags(%q) error should be flag.ErrHelp", args)
+		}
+	}
+	if _, err := parseEntryFlags("hide-entry", []string{"--", "--help"}); err != nil {
+		t.Errorf("--help after -- is an argument, got error %v", err)
+	}
+	if _, err := parsePlanetsFlags([]string{"update", "--help"}); !errors.Is(err, flag.ErrHelp) {
+		t.Errorf("parsePlanetsFlags(update --help) error = %v, want help", err)
+	}
+}
+
+func TestParseCompletionFlags(t *testing.T) {
+	t.Parallel()
+
+	for _, shell := range []string{"bash", "zsh", "fish"} {
+		opts, err := parseCompletionFlags([]string{shell})
+		if err != nil || opts.Shell != shell {
+			t.Errorf("parseCompletionFlags(%s) = %+v, %v", shell, opts, err)
+		}
+	}
+	for _, args := range [][]string{{}, {"powershell"}, {"bash", "zsh"}} {
+		if _, err := parseCompletionFlags(args); err == nil {
+			t.Errorf("parseCompletionFlags(%q) expected error", args)
+		}
+	}
+}
+
+func TestSplitArgs(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		args       []string
+		wantName   string
+		wantPlanet string
+		wantRest   []string
+		wantErr    bool
+	}{
+		{"command only", []string{"status"}, "status", "", []string{}, false},
+		{"global flags before", []string{"--config", "p.ini", "--verbose", "update", "--force"}, "update", "", []string{"--config", "p.ini", "--verbose", "--force"}, false},
+		{"config with equals", []string{"-config=p.ini", "status"}, "status", "", []string{"--config", "p.ini"}, false},
+		{"planet before", []string{"--planet", "work", "status"}, "status", "work", []string{}, false},
+		{"planet after", []string{"status", "--feed", "x", "--planet=work"}, "status", "work", []string{"--feed", "x"}, false},
+		{"planet after --", []string{"hide-entry", "--", "--planet"}, "hide-entry", "", []string{"--", "--planet"}, false},
+		{"version flag", []string{"--version"}, "--version", "", []string{}, false},
+		{"no command", []string{"--verbose"}, "", "", []string{"--verbose"}, false},
+		{"missing value", []string{"--config"}, "", "", nil, true},
+		{"missing planet", []string{"status", "--planet"}, "", "", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			name, planetName, rest, err := splitArgs(tt.args)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if name != tt.wantName || planetName != tt.wantPlanet || strings.Join(rest, " ") != strings.Join(tt.wantRest, " ") {
+				t.Errorf("splitArgs() = %q, %q, %q; want %q, %q, %q", name, planetName, rest, tt.wantName, tt.wantPlanet, tt.wantRest)
+			}
+		})
+	}
+}
+
+func TestPrintCommandHelp(t *testing.T) {
+	t.Parallel()
+
+	var buf strings.Builder
+	for _, name := range []string{"fetch", "planets"} {
+		cmd, ok := findCommand(name)
+		if !ok {
+			t.Fatalf("findCommand(%s) not found", name)
+		}
+		var help *helpError
+		if err := cmd.run(t.Context(), []string{"--help"}); !errors.As(err, &help) {
+			t.Fatalf("rp %s --help: error = %v, want help", name, err)
+		}
+		printCommandHelp(&buf, cmd, help.fs)
+	}
+	for _, want := range []string{
+		"Usage: rp fetch [flags]\n\nFetch all feeds without generating\n",
+		"  --feed value",
+		"  --config string ",
+		"Path to config file (default: ./config.ini)\n",
+		"  rp fetch --offline\n",
+		"Usage: rp planets <action> [flags]",
+		"  remove NAME       Unregister a planet",
+		"  rp planets update\n",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("help missing %q:\n%s", want, buf.String())
+		}
+	}
+	if strings.Contains(buf.String(), "rp planets update\n  rp fetch") {
+		t.Error("help should only show the command's own examples")
+	}
+
+	for _, alias := range []string{"--version", "-h", "--help"} {
+		if _, ok := findCommand(alias); !ok {
+			t.Errorf("findCommand(%s) not found", alias)
+		}
+	}
+	if _, ok := findCommand("fetch-all"); ok {
+		t.Error("findCommand(fetch-all) should not be found")
 	}
 }
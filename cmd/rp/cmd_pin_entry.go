@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/adewale/rogue_planet/pkg/repository"
+)
+
+func cmdPinEntry(ctx context.Context, opts EntryOptions) error {
+	if opts.Ref == "" {
+		return fmt.Errorf("entry link or ID is required")
+	}
+
+	_, repo, cleanup, err := openConfigAndRepo(opts.ConfigPath)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	entry, err := repo.PinEntry(ctx, opts.Ref, time.Now())
+	if errors.Is(err, repository.ErrEntryNotFound) {
+		return fmt.Errorf("no stored entry has the link or ID %s", opts.Ref)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to pin entry: %w", err)
+	}
+
+	fmt.Fprintf(opts.Output, "✓ Pinned: %s (%s)\n", entryLabel(entry.Title, entry.EntryID), entry.Link)
+	fmt.Fprintln(opts.Output, "  Run 'rp generate' to feature it at the top of the site")
+	return nil
+}
+
+func cmdUnpinEntry(ctx context.Context, opts EntryOptions) error {
+	if opts.Ref == "" {
+		return fmt.Errorf("entry link or ID is required")
+	}
+
+	_, repo, cleanup, err := openConfigAndRepo(opts.ConfigPath)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	n, err := repo.UnpinEntries(ctx, opts.Ref)
+	if err != nil {
+		return fmt.Errorf("failed to unpin entry: %w", err)
+	}
+	if n == 0 {
+		return fmt.Errorf("no pinned entry has the link or ID %s (see 'rp list-pinned')", opts.Ref)
+	}
+
+	fmt.Fprintf(opts.Output, "✓ Unpinned %s\n", opts.Ref)
+	fmt.Fprintln(opts.Output, "  Run 'rp generate' to take it out of the Featured section")
+	return nil
+}
+
+func cmdListPinned(ctx context.Context, opts ListPinnedOptions) error {
+	_, repo, cleanup, err := openConfigAndRepo(opts.ConfigPath)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	pinned, err := repo.GetPinnedEntries(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get pinned entries: %w", err)
+	}
+
+	if len(pinned) == 0 {
+		fmt.Fprintln(opts.Output, "No pinned entries.")
+		return nil
+	}
+
+	fmt.Fprintf(opts.Output, "Pinned entries (%d), in the order they are featured:\n\n", len(pinned))
+	printMarkedEntries(opts.Output, pinned, "Pinned")
+	return nil
+}
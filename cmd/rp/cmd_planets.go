@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"github.com/adewale/rogue_planet/pkg/config"
+)
+
+// selectPlanet handles the global flag in rp --planet work update, given
+// the planet's name and the command with its arguments. It returns the
+// command to run instead, with the planet's config, and the planet's
+// directory, which the command must run from so relative paths in the
+// config resolve as they do for 'cd dir && rp update'.
+func selectPlanet(name string, args []string, registry string) ([]string, string, error) {
+	if name == "" {
+		return nil, "", fmt.Errorf("--planet needs a planet name")
+	}
+	if len(args) == 0 {
+		return nil, "", fmt.Errorf("no command specified for planet %s", name)
+	}
+	switch args[0] {
+	case "init", "planets", "completion", "version", "--version", "help", "--help", "-h":
+		return nil, "", fmt.Errorf("--planet cannot be used with %s", args[0])
+	}
+
+	planets, err := config.LoadRegistry(registry)
+	if err != nil {
+		return nil, "", err
+	}
+	p, err := config.FindPlanet(planets, name)
+	if err != nil {
+		return nil, "", err
+	}
+	return append([]string{args[0], "--config", p.Config}, args[1:]...), p.Dir(), nil
+}
+
+// runPlanetCommand runs rp with args from dir, as if started there
+func runPlanetCommand(ctx context.Context, dir string, out io.Writer, args ...string) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("find rp executable: %w", err)
+	}
+	cmd := exec.CommandContext(ctx, exe, args...)
+	cmd.Dir = dir
+	cmd.Stdout = out
+	cmd.Stderr = os.Stderr
+	// Let an interrupted update finish its fetches in progress, as Ctrl+C would
+	if runtime.GOOS != "windows" {
+		cmd.Cancel = func() error { return cmd.Process.Signal(os.Interrupt) }
+	}
+	cmd.WaitDelay = time.Minute
+	return cmd.Run()
+}
+
+func cmdPlanets(ctx context.Context, opts PlanetsOptions) error {
+	planets, err := config.LoadRegistry(opts.Registry)
+	if err != nil {
+		return err
+	}
+
+	switch opts.Action {
+	case "list":
+		return listPlanets(opts, planets)
+	case "add":
+		return addPlanet(opts, planets)
+	case "remove":
+		return removePlanet(opts, planets)
+	default:
+		return updatePlanets(ctx, opts, planets)
+	}
+}
+
+func listPlanets(opts PlanetsOptions, planets []config.RegisteredPlanet) error {
+	if len(planets) == 0 {
+		fmt.Fprintln(opts.Output, "No planets registered. Add one with: rp planets add <name> <config>")
+		return nil
+	}
+
+	fmt.Fprintf(opts.Output, "Registered planets (%d):\n\n", len(planets))
+	for _, p := range planets {
+		fmt.Fprintf(opts.Output, "  %s\n", p.Name)
+		fmt.Fprintf(opts.Output, "      Config: %s\n", p.Config)
+		cfg, err := config.LoadFromFile(p.Config)
+		if err != nil {
+			fmt.Fprintf(opts.Output, "      Error: %v\n", err)
+		} else {
+			fmt.Fprintf(opts.Output, "      Name: %s\n", cfg.Planet.Name)
+			fmt.Fprintf(opts.Output, "      Output: %s\n", planetPath(p, cfg.Planet.OutputDir))
+			if cfg.Database.Driver == "postgres" {
+				fmt.Fprintf(opts.Output, "      Database: postgres\n")
+			} else {
+				fmt.Fprintf(opts.Output, "      Database: %s\n", planetPath(p, cfg.Database.Path))
+			}
+		}
+		fmt.Fprintln(opts.Output)
+	}
+	return nil
+}
+
+// planetPath resolves a path from p's config against p's directory
+func planetPath(p config.RegisteredPlanet, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(p.Dir(), path)
+}
+
+func addPlanet(opts PlanetsOptions, planets []config.RegisteredPlanet) error {
+	if _, err := config.FindPlanet(planets, opts.Name); err == nil {
+		return fmt.Errorf("a planet named %s is already registered (rp planets remove %s first)", opts.Name, opts.Name)
+	}
+	path, err := filepath.Abs(config.Locate(opts.ConfigPath))
+	if err != nil {
+		return fmt.Errorf("resolve config path: %w", err)
+	}
+	if _, err := config.LoadFromFile(path); err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	planets = append(planets, config.RegisteredPlanet{Name: opts.Name, Config: path})
+	if err := config.SaveRegistry(opts.Registry, planets); err != nil {
+		return err
+	}
+	fmt.Fprintf(opts.Output, "✓ Registered planet %s (%s)\n", opts.Name, path)
+	fmt.Fprintf(opts.Output, "  Run its commands with: rp --planet %s <command>\n", opts.Name)
+	return nil
+}
+
+func removePlanet(opts PlanetsOptions, planets []config.RegisteredPlanet) error {
+	p, err := config.FindPlanet(planets, opts.Name)
+	if err != nil {
+		return err
+	}
+	kept := planets[:0]
+	for _, q := range planets {
+		if q.Name != p.Name {
+			kept = append(kept, q)
+		}
+	}
+	if err := config.SaveRegistry(opts.Registry, kept); err != nil {
+		return err
+	}
+	fmt.Fprintf(opts.Output, "✓ Removed planet %s from the registry; %s is untouched\n", p.Name, p.Dir())
+	return nil
+}
+
+// updatePlanets runs rp update for each planet in turn, each in its own
+// process so one planet's failure or lock never holds up the others
+func updatePlanets(ctx context.Context, opts PlanetsOptions, planets []config.RegisteredPlanet) error {
+	if len(planets) == 0 {
+		return fmt.Errorf("no planets registered; add one with 'rp planets add'")
+	}
+	run := opts.Runner
+	if run == nil {
+		run = runPlanetCommand
+	}
+
+	var errs []error
+	updated := 0
+	for _, p := range planets {
+		if ctx.Err() != nil {
+			break
+		}
+		fmt.Fprintf(opts.Output, "==> %s\n", p.Name)
+		args := append([]string{"update", "--config", p.Config}, opts.UpdateArgs...)
+		if err := run(ctx, p.Dir(), opts.Output, args...); err != nil {
+			fmt.Fprintf(opts.Output, "✗ %s: %v\n", p.Name, err)
+			errs = append(errs, fmt.Errorf("planet %s: %w", p.Name, err))
+			continue
+		}
+		updated++
+	}
+
+	fmt.Fprintf(opts.Output, "\nUpdated %d of %d planets\n", updated, len(planets))
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return errors.Join(errs...)
+}
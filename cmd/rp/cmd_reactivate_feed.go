@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+func cmdReactivateFeed(ctx context.Context, opts ReactivateFeedOptions) error {
+	if opts.URL == "" {
+		return fmt.Errorf("URL is required")
+	}
+
+	_, repo, cleanup, err := openConfigAndRepo(opts.ConfigPath)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	feed, err := repo.GetFeedByURL(ctx, opts.URL)
+	if err != nil {
+		return fmt.Errorf("feed not found: %w", err)
+	}
+
+	if err := repo.ReactivateFeed(ctx, feed.ID); err != nil {
+		return fmt.Errorf("failed to reactivate feed: %w", err)
+	}
+
+	if feed.Active {
+		fmt.Fprintf(opts.Output, "✓ Cleared %d errors for %s; it will be fetched on the next update\n", feed.FetchErrorCount, opts.URL)
+	} else {
+		fmt.Fprintf(opts.Output, "✓ Reactivated feed: %s\n", opts.URL)
+	}
+	return nil
+}
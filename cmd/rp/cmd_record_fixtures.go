@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/adewale/rogue_planet/pkg/crawler"
+	"github.com/adewale/rogue_planet/pkg/planet"
+)
+
+func cmdRecordFixtures(ctx context.Context, opts RecordFixturesOptions) error {
+	cfg, err := planet.LoadConfig(opts.ConfigPath)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	feedURLs := opts.Feeds
+	if len(feedURLs) == 0 {
+		_, repo, cleanup, err := openConfigAndRepo(opts.ConfigPath)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+		feeds, err := repo.GetFeeds(ctx, true)
+		if err != nil {
+			return fmt.Errorf("failed to get feeds: %w", err)
+		}
+		for _, feed := range feeds {
+			feedURLs = append(feedURLs, feed.URL)
+		}
+		if len(feedURLs) == 0 {
+			return fmt.Errorf("no active feeds to record; name one with --feed")
+		}
+	}
+
+	// Conditional requests would record a 304 instead of the feed
+	c := planet.NewCrawler(cfg).WithResponseCache(crawler.NewFixtures(opts.Dir))
+	failed := 0
+	for _, feedURL := range feedURLs {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		fetchCtx, cancel := context.WithTimeout(ctx, planet.FeedTimeout(cfg))
+		_, err := c.Fetch(fetchCtx, feedURL, crawler.FeedCache{})
+		cancel()
+		if err != nil {
+			fmt.Fprintf(opts.Output, "✗ %s: %v\n", feedURL, err)
+			failed++
+			continue
+		}
+		fmt.Fprintf(opts.Output, "✓ %s → %s\n", feedURL, filepath.Join(opts.Dir, crawler.FixtureName(feedURL)+".meta.json"))
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d feeds could not be recorded", failed, len(feedURLs))
+	}
+	fmt.Fprintf(opts.Output, "\nReplay them with: rp fetch --fixtures %s\n", opts.Dir)
+	return nil
+}
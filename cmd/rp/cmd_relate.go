@@ -0,0 +1,27 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/adewale/rogue_planet/pkg/planet"
+)
+
+func cmdRelate(ctx context.Context, opts RelateOptions) error {
+	cfg, err := planet.LoadConfig(opts.ConfigPath)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	p, err := planet.Open(cfg, planet.Options{Output: opts.Output})
+	if err != nil {
+		return fmt.Errorf("failed to open planet: %w", err)
+	}
+	defer p.Close()
+
+	n, err := p.Relate(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to relate entries: %w", err)
+	}
+	fmt.Fprintf(opts.Output, "✓ Stored %d related entry links\n", n)
+	return nil
+}
@@ -1,14 +1,11 @@
 package main
 
 import (
-	"bufio"
 	"context"
 	"fmt"
-	"os"
-	"strings"
 )
 
-func cmdRemoveFeed(opts RemoveFeedOptions) error {
+func cmdRemoveFeed(ctx context.Context, opts RemoveFeedOptions) error {
 	if opts.URL == "" {
 		return fmt.Errorf("URL is required")
 	}
@@ -19,8 +16,6 @@ func cmdRemoveFeed(opts RemoveFeedOptions) error {
 	}
 	defer cleanup()
 
-	ctx := context.Background()
-
 	// Find feed
 	feed, err := repo.GetFeedByURL(ctx, opts.URL)
 	if err != nil {
@@ -35,20 +30,9 @@ func cmdRemoveFeed(opts RemoveFeedOptions) error {
 
 	// Interactive confirmation unless --force is specified
 	if !opts.Force {
-		// Check if input is a terminal (for production use with os.Stdin)
-		// For testing, non-os.File inputs (like strings.Reader) are allowed for mocking
-		if inputFile, isFile := opts.Input.(*os.File); isFile {
-			// Only check terminal status for actual files (production)
-			stat, err := inputFile.Stat()
-			if err != nil {
-				return fmt.Errorf("cannot determine terminal status: %w", err)
-			}
-			isTerminal := (stat.Mode() & os.ModeCharDevice) != 0
-			if !isTerminal {
-				return fmt.Errorf("cannot prompt for confirmation in non-interactive mode. Use --force to skip confirmation")
-			}
+		if err := checkCanPrompt(opts.Input); err != nil {
+			return err
 		}
-		// Non-file inputs (e.g., strings.Reader in tests) are allowed to proceed
 
 		// Display feed information
 		feedTitle := feed.Title
@@ -61,18 +45,12 @@ func cmdRemoveFeed(opts RemoveFeedOptions) error {
 		fmt.Fprintf(opts.Output, "Entries: %d\n\n", entryCount)
 
 		// Prompt for confirmation (matches spec exactly)
-		fmt.Fprintf(opts.Output, "Remove this feed and all %d entries? (y/N): ", entryCount)
-
-		// Read user input
-		reader := bufio.NewReader(opts.Input)
-		response, err := reader.ReadString('\n')
+		question := fmt.Sprintf("Remove this feed and all %d entries? (y/N): ", entryCount)
+		confirmed, err := confirmPrompt(ctx, opts.Input, opts.Output, question)
 		if err != nil {
-			return fmt.Errorf("failed to read input: %w", err)
+			return err
 		}
-
-		// Parse response
-		response = strings.TrimSpace(strings.ToLower(response))
-		if response != "y" && response != "yes" {
+		if !confirmed {
 			fmt.Fprintln(opts.Output, "Cancelled.")
 			return &ErrUserCancelled{"operation cancelled by user"}
 		}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/adewale/rogue_planet/pkg/planet"
+	"github.com/adewale/rogue_planet/pkg/repository"
+)
+
+func cmdRenameFeed(ctx context.Context, opts RenameFeedOptions) error {
+	if opts.OldURL == "" || opts.NewURL == "" {
+		return fmt.Errorf("current and new URLs are required")
+	}
+	if opts.NewURL == opts.OldURL {
+		return fmt.Errorf("the new URL is the feed's current URL")
+	}
+
+	cfg, repo, cleanup, err := openConfigAndRepo(opts.ConfigPath)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	if err := planet.SSRFPolicy(cfg).ValidateURL(opts.NewURL); err != nil {
+		return fmt.Errorf("invalid new URL: %w", err)
+	}
+
+	feed, err := repo.GetFeedByURL(ctx, opts.OldURL)
+	if err != nil {
+		return fmt.Errorf("feed not found: %w", err)
+	}
+	if other, err := repo.GetFeedByURL(ctx, opts.NewURL); err == nil {
+		return fmt.Errorf("another feed (ID: %d) already has URL %s", other.ID, opts.NewURL)
+	} else if !errors.Is(err, repository.ErrFeedNotFound) {
+		return fmt.Errorf("failed to check new URL: %w", err)
+	}
+
+	if err := repo.EditFeed(ctx, feed.ID, repository.FeedEdit{URL: &opts.NewURL}); err != nil {
+		return fmt.Errorf("failed to rename feed: %w", err)
+	}
+
+	entries, _ := repo.GetEntryCountForFeed(ctx, feed.ID)
+	fmt.Fprintf(opts.Output, "✓ Renamed feed (ID: %d): %s → %s\n", feed.ID, opts.OldURL, opts.NewURL)
+	fmt.Fprintf(opts.Output, "  Kept its %d entries; it will be fetched from the new URL on the next update\n", entries)
+	if _, ok := cfg.FeedSettings[feed.URL]; ok {
+		fmt.Fprintf(opts.Output, "⚠ %s has a [feed %s] section; rename it to [feed %s] to keep its settings\n", opts.ConfigPath, opts.OldURL, opts.NewURL)
+	}
+	return nil
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/adewale/rogue_planet/pkg/feedurl"
+	"github.com/adewale/rogue_planet/pkg/planet"
+	"github.com/adewale/rogue_planet/pkg/repository"
+)
+
+// parkingDomains are domain parking and for-sale services. A feed that ends
+// up on one has lost its domain and will not come back.
+var parkingDomains = []string{
+	"above.com",
+	"afternic.com",
+	"bodis.com",
+	"buydomains.com",
+	"dan.com",
+	"domainmarket.com",
+	"hugedomains.com",
+	"parkingcrew.net",
+	"parklogic.com",
+	"parkingpage.namecheap.com",
+	"sedo.com",
+	"sedoparking.com",
+	"undeveloped.com",
+}
+
+// parkingDomain returns the parking service rawURL is on, or ""
+func parkingDomain(rawURL string) string {
+	host := feedurl.Host(rawURL)
+	for _, domain := range parkingDomains {
+		if feedurl.InDomain(host, domain) {
+			return domain
+		}
+	}
+	return ""
+}
+
+// plainArg matches the arguments a shell takes as they are
+var plainArg = regexp.MustCompile(`^[A-Za-z0-9:/._~%=+,@-]+$`)
+
+// commandArg quotes s for the shell if it needs quoting, so that suggested
+// commands can be pasted
+func commandArg(s string) string {
+	if plainArg.MatchString(s) {
+		return s
+	}
+	return shellQuote(s)
+}
+
+// clientError reports whether an HTTP status means the request itself is
+// wrong, which retrying will not fix. Rate limiting is left out: it passes.
+func clientError(code int) bool {
+	return code >= 400 && code < 500 && code != 429
+}
+
+// cmdReport prints a health report of the planet's feeds: feeds that have
+// stopped posting, lost their domain, keep failing with client errors, or
+// redirect elsewhere, and feeds subscribed to twice under different URLs,
+// each with the command that fixes it
+func cmdReport(ctx context.Context, opts ReportOptions) error {
+	_, repo, cleanup, err := openConfigAndRepo(opts.ConfigPath)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	feeds, err := repo.GetFeeds(ctx, false)
+	if err != nil {
+		return fmt.Errorf("failed to get feeds: %w", err)
+	}
+	activity, err := repo.GetFeedActivity(ctx, opts.Failures)
+	if err != nil {
+		return fmt.Errorf("failed to read feed activity: %w", err)
+	}
+	activityOf := func(id int64) *repository.FeedActivity {
+		if a := activity[id]; a != nil {
+			return a
+		}
+		return &repository.FeedActivity{}
+	}
+	var active []repository.Feed
+	for _, feed := range feeds {
+		if feed.Active {
+			active = append(active, feed)
+		}
+	}
+
+	report := &doctorReport{out: opts.Output}
+	fmt.Fprintf(opts.Output, "Feed health report: %d feeds, %d active\n\n", len(feeds), len(active))
+
+	report.section(fmt.Sprintf("Stale feeds (nothing new in %d months)", opts.StaleMonths))
+	reportStale(report, active, activityOf, time.Now().AddDate(0, -opts.StaleMonths, 0))
+
+	report.section("Parked domains")
+	reportParked(report, active, activityOf)
+
+	report.section(fmt.Sprintf("Client errors (on the last %d fetches)", opts.Failures))
+	reportClientErrors(report, active, activityOf, opts.Failures)
+
+	report.section("Redirects")
+	reportRedirects(report, active, activityOf)
+
+	report.section("Duplicate feeds")
+	reportDuplicates(report, feeds, activityOf)
+
+	report.summary()
+	return nil
+}
+
+// reportStale reports the feeds whose newest entry was published before cutoff
+func reportStale(report *doctorReport, feeds []repository.Feed, activityOf func(int64) *repository.FeedActivity, cutoff time.Time) {
+	found := 0
+	for _, feed := range feeds {
+		last := activityOf(feed.ID).LastPublished
+		if last.IsZero() || !last.Before(cutoff) {
+			continue
+		}
+		found++
+		url := commandArg(feed.URL)
+		report.problem(fmt.Sprintf("stop fetching it with 'rp edit-feed %s --active=false', or remove it with 'rp remove-feed %s'", url, url),
+			"%s: last post %s (%s)", describeFeed(feed), last.Local().Format("2006-01-02"), formatAge(time.Since(last)))
+	}
+	if found == 0 {
+		report.ok("every feed has posted since %s", cutoff.Local().Format("2006-01-02"))
+	}
+}
+
+// reportParked reports the feeds whose URL, latest redirect, or site link is
+// on a domain parking service
+func reportParked(report *doctorReport, feeds []repository.Feed, activityOf func(int64) *repository.FeedActivity) {
+	found := 0
+	for _, feed := range feeds {
+		var where, domain string
+		fetches := activityOf(feed.ID).Fetches
+		switch {
+		case parkingDomain(feed.URL) != "":
+			where, domain = "is on", parkingDomain(feed.URL)
+		case len(fetches) > 0 && parkingDomain(fetches[0].FinalURL) != "":
+			where, domain = "redirects to", parkingDomain(fetches[0].FinalURL)
+		case parkingDomain(feed.Link) != "":
+			where, domain = "links to", parkingDomain(feed.Link)
+		default:
+			continue
+		}
+		found++
+		report.problem(fmt.Sprintf("the domain has lapsed; remove the feed with 'rp remove-feed %s'", commandArg(feed.URL)),
+			"%s %s %s, a domain parking service", describeFeed(feed), where, domain)
+	}
+	if found == 0 {
+		report.ok("no feed has ended up on a parked domain")
+	}
+}
+
+// reportClientErrors reports the feeds whose last n fetches all failed with
+// a client error
+func reportClientErrors(report *doctorReport, feeds []repository.Feed, activityOf func(int64) *repository.FeedActivity, n int) {
+	found := 0
+	for _, feed := range feeds {
+		fetches := activityOf(feed.ID).Fetches
+		if len(fetches) < n {
+			continue
+		}
+		var codes []string
+		failing := true
+		for _, f := range fetches[:n] {
+			if !clientError(f.StatusCode) {
+				failing = false
+				break
+			}
+			if code := fmt.Sprint(f.StatusCode); !slices.Contains(codes, code) {
+				codes = append(codes, code)
+			}
+		}
+		if !failing {
+			continue
+		}
+		found++
+		url := commandArg(feed.URL)
+		report.problem(fmt.Sprintf("find the feed's new address on its site and run 'rp rename-feed %s NEW-URL', or remove it with 'rp remove-feed %s'", url, url),
+			"%s: HTTP %s since %s", describeFeed(feed), strings.Join(codes, ", "), fetches[n-1].FetchedAt.Local().Format("2006-01-02"))
+	}
+	if found == 0 {
+		report.ok("no feed keeps failing with client errors")
+	}
+}
+
+// reportRedirects reports the feeds whose latest successful fetch was
+// temporarily redirected; permanent redirects move feeds by themselves
+func reportRedirects(report *doctorReport, feeds []repository.Feed, activityOf func(int64) *repository.FeedActivity) {
+	found := 0
+	for _, feed := range feeds {
+		fetches := activityOf(feed.ID).Fetches
+		if len(fetches) == 0 {
+			continue
+		}
+		latest := fetches[0]
+		if latest.FinalURL == "" || latest.FinalURL == feed.URL || latest.Error != "" || parkingDomain(latest.FinalURL) != "" {
+			continue
+		}
+		found++
+		report.problem(fmt.Sprintf("fetch it from there directly with 'rp rename-feed %s %s'", commandArg(feed.URL), commandArg(latest.FinalURL)),
+			"%s redirects to %s", describeFeed(feed), latest.FinalURL)
+	}
+	if found == 0 {
+		report.ok("no feed is redirected")
+	}
+}
+
+// reportDuplicates reports the feeds that look like the same feed, as
+// planet.Duplicates judges, suggesting which to keep: active over inactive,
+// direct over proxied, https over http, then the one with the most entries,
+// then the oldest
+func reportDuplicates(report *doctorReport, feeds []repository.Feed, activityOf func(int64) *repository.FeedActivity) {
+	var groups [][]repository.Feed
+	grouped := make(map[int64]bool)
+	for i, feed := range feeds {
+		if grouped[feed.ID] {
+			continue
+		}
+		group := []repository.Feed{feed}
+		for _, other := range planet.Duplicates(feeds[i+1:], feed) {
+			if !grouped[other.ID] {
+				grouped[other.ID] = true
+				group = append(group, other)
+			}
+		}
+		groups = append(groups, group)
+	}
+
+	found := 0
+	for _, group := range groups {
+		if len(group) < 2 {
+			continue
+		}
+		sort.SliceStable(group, func(i, j int) bool {
+			a, b := group[i], group[j]
+			if a.Active != b.Active {
+				return a.Active
+			}
+			if pa, pb := feedurl.IsProxy(a.URL), feedurl.IsProxy(b.URL); pa != pb {
+				return pb
+			}
+			if ha, hb := strings.HasPrefix(a.URL, "https:"), strings.HasPrefix(b.URL, "https:"); ha != hb {
+				return ha
+			}
+			if ea, eb := activityOf(a.ID).Entries, activityOf(b.ID).Entries; ea != eb {
+				return ea > eb
+			}
+			return a.ID < b.ID
+		})
+
+		urls := make([]string, len(group))
+		var removals []string
+		for i, feed := range group {
+			urls[i] = feed.URL
+			if i > 0 {
+				removals = append(removals, "'rp remove-feed "+commandArg(feed.URL)+"'")
+			}
+		}
+		found++
+		report.problem(fmt.Sprintf("keep %s and run %s", group[0].URL, strings.Join(removals, " and ")),
+			"%s are the same feed", strings.Join(urls, " and "))
+	}
+	if found == 0 {
+		report.ok("no feed is subscribed to twice")
+	}
+}
+
+// describeFeed names a feed by its title, if it has one, and URL
+func describeFeed(feed repository.Feed) string {
+	if feed.Title == "" {
+		return feed.URL
+	}
+	return fmt.Sprintf("%s (%s)", feed.Title, feed.URL)
+}
+
+// formatAge renders a long duration in whole months or, past two years, years
+func formatAge(d time.Duration) string {
+	months := int(d.Hours() / 24 / 30)
+	if months >= 24 {
+		return fmt.Sprintf("%d years ago", months/12)
+	}
+	return fmt.Sprintf("%d months ago", months)
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/adewale/rogue_planet/pkg/generator"
+	"github.com/adewale/rogue_planet/pkg/planet"
+)
+
+func cmdRollback(opts RollbackOptions) error {
+	cfg, err := planet.LoadConfig(opts.ConfigPath)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	if err := generator.Rollback(cfg.Planet.OutputDir); err != nil {
+		if errors.Is(err, generator.ErrNoPreviousGeneration) {
+			return fmt.Errorf("%s has %w", cfg.Planet.OutputDir, err)
+		}
+		return fmt.Errorf("failed to roll back: %w", err)
+	}
+
+	fmt.Fprintf(opts.Output, "✓ Restored the previous generation of %s\n", cfg.Planet.OutputDir)
+	fmt.Fprintf(opts.Output, "  Run 'rp rollback' again to undo\n")
+	return nil
+}
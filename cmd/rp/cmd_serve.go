@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/adewale/rogue_planet/pkg/admin"
+	"github.com/adewale/rogue_planet/pkg/fever"
+	"github.com/adewale/rogue_planet/pkg/generator"
+	"github.com/adewale/rogue_planet/pkg/logging"
+	"github.com/adewale/rogue_planet/pkg/metrics"
+	"github.com/adewale/rogue_planet/pkg/planet"
+)
+
+// serveState tracks refresh results for the health endpoint
+type serveState struct {
+	mu          sync.Mutex
+	started     time.Time
+	lastRefresh time.Time
+	lastError   string
+	nextRefresh time.Time
+	refreshes   int
+	metrics     *metrics.Registry // Fetch metrics across refreshes, served on /metrics
+}
+
+// healthResponse is the JSON body returned by /healthz
+type healthResponse struct {
+	Status      string `json:"status"` // "ok", "starting", or "error"
+	LastRefresh string `json:"last_refresh,omitempty"`
+	NextRefresh string `json:"next_refresh,omitempty"`
+	LastError   string `json:"last_error,omitempty"`
+	Refreshes   int    `json:"refreshes"`
+	Uptime      string `json:"uptime"`
+}
+
+func (s *serveState) record(at time.Time, err error, next time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastRefresh = at
+	s.nextRefresh = next
+	s.refreshes++
+	s.lastError = ""
+	if err != nil {
+		s.lastError = err.Error()
+	}
+}
+
+func (s *serveState) health(now time.Time) (int, healthResponse) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	resp := healthResponse{
+		Status:    "ok",
+		LastError: s.lastError,
+		Refreshes: s.refreshes,
+		Uptime:    now.Sub(s.started).Truncate(time.Second).String(),
+	}
+	if !s.lastRefresh.IsZero() {
+		resp.LastRefresh = s.lastRefresh.Format(time.RFC3339)
+	}
+	if !s.nextRefresh.IsZero() {
+		resp.NextRefresh = s.nextRefresh.Format(time.RFC3339)
+	}
+
+	switch {
+	case s.lastError != "":
+		resp.Status = "error"
+		return http.StatusServiceUnavailable, resp
+	case s.refreshes == 0:
+		resp.Status = "starting"
+	}
+	return http.StatusOK, resp
+}
+
+// newServeHandler serves the generated site and the /healthz and /metrics
+// endpoints, and each of apis, such as the admin API, under its path prefix
+func newServeHandler(outputDir string, state *serveState, apis map[string]http.Handler) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		code, body := state.health(time.Now())
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(body)
+	})
+	mux.Handle("/metrics", state.metrics.Handler())
+	for prefix, h := range apis {
+		mux.Handle(prefix, h)
+	}
+	mux.Handle("/", http.FileServer(generator.SiteFS(outputDir)))
+	return mux
+}
+
+// refresh runs the fetch+generate pipeline once, adding the fetch's metrics
+// to reg. A refresh that finds another run in progress is skipped.
+func refresh(ctx context.Context, p *planet.Planet, logger *slog.Logger, reg *metrics.Registry) error {
+	err := p.Update(ctx, planet.UpdateOptions{Fetch: planet.FetchOptions{Metrics: reg}})
+	if errors.Is(err, planet.ErrRunInProgress) {
+		logger.Info("Skipping refresh", "reason", err)
+		return nil
+	}
+	return err
+}
+
+func cmdServe(ctx context.Context, opts ServeOptions) error {
+	cfg, err := planet.LoadConfig(opts.ConfigPath)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	opts.Logger = logging.Configure(opts.Logger, cfg.Planet.LogLevel, cfg.Planet.LogFormat)
+
+	p, err := planet.Open(cfg, planet.Options{Logger: opts.Logger, Output: opts.Output})
+	if err != nil {
+		return fmt.Errorf("failed to open planet: %w", err)
+	}
+	defer p.Close()
+
+	listener, err := net.Listen("tcp", opts.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", opts.Addr, err)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	apis := make(map[string]http.Handler)
+	var api *admin.API
+	if cfg.Admin.Enabled() {
+		api = admin.New(ctx, p, cfg.Admin.Token)
+		apis[admin.Prefix] = api
+		if cfg.Admin.UI {
+			apis[admin.UIPrefix] = admin.UI()
+		}
+	} else if cfg.Admin.UI {
+		opts.Logger.Warn("Not serving the admin UI", "reason", "[admin] ui needs a token")
+	}
+	if cfg.Fever.Enabled() {
+		apis[fever.Prefix] = fever.New(p.Repository(), cfg.Fever.Email, cfg.Fever.Password)
+	}
+
+	state := &serveState{started: time.Now(), metrics: metrics.NewRegistry()}
+	server := &http.Server{
+		Handler:           newServeHandler(cfg.Planet.OutputDir, state, apis),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	serverErr := make(chan error, 1)
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
+		}
+		close(serverErr)
+	}()
+
+	fmt.Fprintf(opts.Output, "Serving %s on http://%s\n", cfg.Planet.OutputDir, listener.Addr())
+	if opts.Interval > 0 {
+		fmt.Fprintf(opts.Output, "Refreshing every %s (health: /healthz, metrics: /metrics)\n", opts.Interval)
+	} else {
+		fmt.Fprintln(opts.Output, "Automatic refresh disabled (health: /healthz, metrics: /metrics)")
+	}
+	if api != nil {
+		fmt.Fprintf(opts.Output, "Admin API enabled at http://%s%s\n", listener.Addr(), admin.Prefix)
+	}
+	if apis[admin.UIPrefix] != nil {
+		fmt.Fprintf(opts.Output, "Admin UI at http://%s%s\n", listener.Addr(), admin.UIPrefix)
+	}
+	if apis[fever.Prefix] != nil {
+		fmt.Fprintf(opts.Output, "Fever API at http://%s%s\n", listener.Addr(), fever.Prefix)
+	}
+
+	// Refresh loop: run immediately, then on every tick
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		runRefreshLoop(ctx, p, opts, state)
+	}()
+
+	select {
+	case <-ctx.Done():
+	case err = <-serverErr:
+		cancel()
+	}
+
+	fmt.Fprintln(opts.Output, "Shutting down...")
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer shutdownCancel()
+	if shutdownErr := server.Shutdown(shutdownCtx); shutdownErr != nil && err == nil {
+		err = fmt.Errorf("shutdown server: %w", shutdownErr)
+	}
+	wg.Wait()
+	if api != nil {
+		api.Wait()
+	}
+
+	if err != nil {
+		return fmt.Errorf("server error: %w", err)
+	}
+	return nil
+}
+
+// runRefreshLoop refreshes the site until ctx is cancelled
+func runRefreshLoop(ctx context.Context, p *planet.Planet, opts ServeOptions, state *serveState) {
+	if opts.Interval == 0 {
+		err := refresh(ctx, p, opts.Logger, state.metrics)
+		state.record(time.Now(), err, time.Time{})
+		if err != nil && ctx.Err() == nil {
+			opts.Logger.Error("Refresh failed", "error", err)
+		}
+		return
+	}
+
+	ticker := time.NewTicker(opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		err := refresh(ctx, p, opts.Logger, state.metrics)
+		if ctx.Err() != nil {
+			return
+		}
+		now := time.Now()
+		state.record(now, err, now.Add(opts.Interval))
+		if err != nil {
+			opts.Logger.Error("Refresh failed", "error", err)
+		} else {
+			opts.Logger.Info("Refresh complete", "next", now.Add(opts.Interval).Format(time.Kitchen))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
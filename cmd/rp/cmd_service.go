@@ -0,0 +1,535 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/adewale/rogue_planet/pkg/planet"
+)
+
+// Service managers install-service can write for
+const (
+	serviceSystemd = "systemd"
+	serviceLaunchd = "launchd"
+	serviceCron    = "cron"
+)
+
+// defaultServiceKind is the service manager of the platform rp runs on
+func defaultServiceKind() string {
+	switch runtime.GOOS {
+	case "linux":
+		return serviceSystemd
+	case "darwin":
+		return serviceLaunchd
+	default:
+		return serviceCron
+	}
+}
+
+// serviceSpec describes the scheduled `rp update` a service runs
+type serviceSpec struct {
+	Name       string        // Unit, agent, or cron entry name
+	Executable string        // Absolute path to rp
+	ConfigPath string        // Absolute path to the planet's config
+	Interval   time.Duration // Time between updates
+}
+
+// WorkDir is the planet's directory, where relative paths in its config resolve
+func (s serviceSpec) WorkDir() string {
+	return filepath.Dir(s.ConfigPath)
+}
+
+// LogPath is where launchd and cron append the output of each run
+func (s serviceSpec) LogPath() string {
+	return filepath.Join(s.WorkDir(), s.Name+".log")
+}
+
+// serviceFile is a file install-service writes
+type serviceFile struct {
+	Path    string
+	Content string
+}
+
+// runServiceCommand runs a service manager's command with input on stdin,
+// returning its standard output
+func runServiceCommand(ctx context.Context, input, name string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdin = strings.NewReader(input)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return stdout.String(), fmt.Errorf("%s: %w: %s", name, err, msg)
+		}
+		return stdout.String(), fmt.Errorf("%s: %w", name, err)
+	}
+	return stdout.String(), nil
+}
+
+// run runs a service manager's command with opts.Runner
+func (opts ServiceOptions) run(ctx context.Context, input, name string, args ...string) (string, error) {
+	if opts.Runner != nil {
+		return opts.Runner(ctx, input, name, args...)
+	}
+	return runServiceCommand(ctx, input, name, args...)
+}
+
+func cmdInstallService(ctx context.Context, opts ServiceOptions) error {
+	if runtime.GOOS == "windows" {
+		return fmt.Errorf("install-service does not support Windows; schedule 'rp update' with Task Scheduler (schtasks) instead")
+	}
+	spec, err := newServiceSpec(opts)
+	if err != nil {
+		return err
+	}
+	if strings.HasPrefix(spec.Executable, filepath.Clean(os.TempDir())+string(filepath.Separator)) {
+		fmt.Fprintf(opts.Output, "Warning: %s is in a temporary directory (go run?); the service will break when it is removed\n", spec.Executable)
+	}
+
+	switch opts.Kind {
+	case serviceSystemd:
+		return installSystemd(ctx, opts, spec)
+	case serviceLaunchd:
+		return installLaunchd(ctx, opts, spec)
+	default:
+		return installCron(ctx, opts, spec)
+	}
+}
+
+func cmdUninstallService(ctx context.Context, opts ServiceOptions) error {
+	if runtime.GOOS == "windows" {
+		return fmt.Errorf("uninstall-service does not support Windows")
+	}
+	switch opts.Kind {
+	case serviceSystemd:
+		return uninstallSystemd(ctx, opts)
+	case serviceLaunchd:
+		return uninstallLaunchd(ctx, opts)
+	default:
+		return uninstallCron(ctx, opts)
+	}
+}
+
+// newServiceSpec resolves the paths a service needs, which must be absolute
+// because service managers do not start rp from the planet's directory
+func newServiceSpec(opts ServiceOptions) (serviceSpec, error) {
+	configPath, err := filepath.Abs(opts.ConfigPath)
+	if err != nil {
+		return serviceSpec{}, fmt.Errorf("resolve config path: %w", err)
+	}
+	if _, err := os.Stat(configPath); err != nil {
+		return serviceSpec{}, fmt.Errorf("config file: %w", err)
+	}
+	if _, err := planet.LoadConfig(configPath); err != nil {
+		return serviceSpec{}, fmt.Errorf("failed to load config: %w", err)
+	}
+
+	exe := opts.Executable
+	if exe == "" {
+		if exe, err = os.Executable(); err != nil {
+			return serviceSpec{}, fmt.Errorf("find rp executable: %w", err)
+		}
+		if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+			exe = resolved
+		}
+	}
+	return serviceSpec{Name: opts.Name, Executable: exe, ConfigPath: configPath, Interval: opts.Interval}, nil
+}
+
+// serviceDir returns the directory unit files go in: opts.Dir, or the
+// service manager's per-user directory
+func serviceDir(opts ServiceOptions) (string, error) {
+	if opts.Dir != "" {
+		return opts.Dir, nil
+	}
+	switch opts.Kind {
+	case serviceSystemd:
+		dir, err := os.UserConfigDir()
+		if err != nil {
+			return "", fmt.Errorf("find systemd user directory: %w", err)
+		}
+		return filepath.Join(dir, "systemd", "user"), nil
+	default:
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("find LaunchAgents directory: %w", err)
+		}
+		return filepath.Join(home, "Library", "LaunchAgents"), nil
+	}
+}
+
+// writeServiceFiles writes files, or lists them for a dry run
+func writeServiceFiles(opts ServiceOptions, files []serviceFile) error {
+	for _, f := range files {
+		if opts.DryRun {
+			fmt.Fprintf(opts.Output, "Would write %s:\n%s\n", f.Path, f.Content)
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(f.Path), 0755); err != nil {
+			return fmt.Errorf("create service directory: %w", err)
+		}
+		if err := os.WriteFile(f.Path, []byte(f.Content), 0644); err != nil {
+			return fmt.Errorf("write %s: %w", f.Path, err)
+		}
+	}
+	return nil
+}
+
+// serviceCommand runs a service manager's command, or shows it for a dry run
+func serviceCommand(ctx context.Context, opts ServiceOptions, input, name string, args ...string) (string, error) {
+	if opts.DryRun {
+		fmt.Fprintf(opts.Output, "Would run: %s %s\n", name, strings.Join(args, " "))
+		return "", nil
+	}
+	return opts.run(ctx, input, name, args...)
+}
+
+// removeServiceFiles removes paths, reporting whether any of them existed
+func removeServiceFiles(opts ServiceOptions, paths ...string) (bool, error) {
+	found := false
+	for _, path := range paths {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			continue
+		}
+		found = true
+		if opts.DryRun {
+			fmt.Fprintf(opts.Output, "Would remove %s\n", path)
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			return found, fmt.Errorf("remove %s: %w", path, err)
+		}
+	}
+	return found, nil
+}
+
+// systemdUnits returns the service and timer units for spec. The service is
+// a oneshot, so the timer never starts a run while the last one is going;
+// the next run is scheduled an interval after the last one started. The
+// units are user units, and the user manager cannot see system targets such
+// as network-online.target, so the service does not wait on one; a run
+// that starts before the network is up reports the failed fetches and the
+// next run retries them.
+func systemdUnits(spec serviceSpec) (service, timer string) {
+	service = fmt.Sprintf(`[Unit]
+Description=Rogue Planet update (%[1]s)
+
+[Service]
+Type=oneshot
+WorkingDirectory=%[2]s
+ExecStart=%[3]s update --config %[4]s
+`, systemdEscape(spec.ConfigPath), systemdEscape(spec.WorkDir()), systemdQuote(spec.Executable), systemdQuote(spec.ConfigPath))
+
+	timer = fmt.Sprintf(`[Unit]
+Description=Run Rogue Planet update every %[1]s
+
+[Timer]
+OnBootSec=2min
+OnUnitActiveSec=%[2]ds
+AccuracySec=1min
+Unit=%[3]s.service
+
+[Install]
+WantedBy=timers.target
+`, spec.Interval, int64(spec.Interval/time.Second), spec.Name)
+	return service, timer
+}
+
+// systemdEscape escapes the specifiers (%) systemd expands in unit settings
+func systemdEscape(s string) string {
+	return strings.ReplaceAll(s, "%", "%%")
+}
+
+// systemdQuote quotes s as one word of a unit file's command line, escaping
+// the specifiers (%) and variables ($) systemd would otherwise expand
+func systemdQuote(s string) string {
+	s = strings.ReplaceAll(systemdEscape(s), "$", "$$")
+	if !strings.ContainsAny(s, " \t\"'\\;") {
+		return s
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+func installSystemd(ctx context.Context, opts ServiceOptions, spec serviceSpec) error {
+	dir, err := serviceDir(opts)
+	if err != nil {
+		return err
+	}
+	service, timer := systemdUnits(spec)
+	timerName := spec.Name + ".timer"
+	if err := writeServiceFiles(opts, []serviceFile{
+		{Path: filepath.Join(dir, spec.Name+".service"), Content: service},
+		{Path: filepath.Join(dir, timerName), Content: timer},
+	}); err != nil {
+		return err
+	}
+
+	if _, err := serviceCommand(ctx, opts, "", "systemctl", "--user", "daemon-reload"); err != nil {
+		return fmt.Errorf("wrote the units to %s but could not reload systemd (run 'systemctl --user enable --now %s'): %w", dir, timerName, err)
+	}
+	if _, err := serviceCommand(ctx, opts, "", "systemctl", "--user", "enable", "--now", timerName); err != nil {
+		return fmt.Errorf("wrote the units to %s but could not enable them (run 'systemctl --user enable --now %s'): %w", dir, timerName, err)
+	}
+	if opts.DryRun {
+		return nil
+	}
+
+	fmt.Fprintf(opts.Output, "✓ Installed %s in %s, running 'rp update' every %s\n", timerName, dir, spec.Interval)
+	fmt.Fprintf(opts.Output, "  Logs: journalctl --user -u %s.service\n", spec.Name)
+	fmt.Fprintf(opts.Output, "  To keep it running while you are logged out: loginctl enable-linger\n")
+	return nil
+}
+
+func uninstallSystemd(ctx context.Context, opts ServiceOptions) error {
+	dir, err := serviceDir(opts)
+	if err != nil {
+		return err
+	}
+	timerName := opts.Name + ".timer"
+	servicePath := filepath.Join(dir, opts.Name+".service")
+	timerPath := filepath.Join(dir, timerName)
+	if _, err := os.Stat(timerPath); os.IsNotExist(err) {
+		if _, err := os.Stat(servicePath); os.IsNotExist(err) {
+			return fmt.Errorf("no systemd units named %s in %s", opts.Name, dir)
+		}
+	}
+
+	// The timer may already be stopped or unknown to systemd; the files go either way
+	if _, err := serviceCommand(ctx, opts, "", "systemctl", "--user", "disable", "--now", timerName); err != nil {
+		fmt.Fprintf(opts.Output, "Warning: could not disable %s: %v\n", timerName, err)
+	}
+	if _, err := removeServiceFiles(opts, timerPath, servicePath); err != nil {
+		return err
+	}
+	if _, err := serviceCommand(ctx, opts, "", "systemctl", "--user", "daemon-reload"); err != nil {
+		fmt.Fprintf(opts.Output, "Warning: could not reload systemd: %v\n", err)
+	}
+	if !opts.DryRun {
+		fmt.Fprintf(opts.Output, "✓ Removed %s and %s.service from %s\n", timerName, opts.Name, dir)
+	}
+	return nil
+}
+
+// launchdLabel is the launchd job label for a service name
+func launchdLabel(name string) string {
+	return "com.github.adewale." + name
+}
+
+// launchdPlist returns a launch agent running spec every interval. launchd
+// does not start a job again while it is still running.
+func launchdPlist(spec serviceSpec) string {
+	var b strings.Builder
+	str := func(s string) string {
+		var esc bytes.Buffer
+		xml.EscapeText(&esc, []byte(s))
+		return "<string>" + esc.String() + "</string>"
+	}
+	b.WriteString(xml.Header)
+	b.WriteString(`<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">` + "\n")
+	b.WriteString("<plist version=\"1.0\">\n<dict>\n")
+	fmt.Fprintf(&b, "    <key>Label</key>\n    %s\n", str(launchdLabel(spec.Name)))
+	b.WriteString("    <key>ProgramArguments</key>\n    <array>\n")
+	for _, arg := range []string{spec.Executable, "update", "--config", spec.ConfigPath} {
+		fmt.Fprintf(&b, "        %s\n", str(arg))
+	}
+	b.WriteString("    </array>\n")
+	fmt.Fprintf(&b, "    <key>WorkingDirectory</key>\n    %s\n", str(spec.WorkDir()))
+	fmt.Fprintf(&b, "    <key>StartInterval</key>\n    <integer>%d</integer>\n", int64(spec.Interval/time.Second))
+	b.WriteString("    <key>RunAtLoad</key>\n    <true/>\n")
+	fmt.Fprintf(&b, "    <key>StandardOutPath</key>\n    %s\n", str(spec.LogPath()))
+	fmt.Fprintf(&b, "    <key>StandardErrorPath</key>\n    %s\n", str(spec.LogPath()))
+	b.WriteString("</dict>\n</plist>\n")
+	return b.String()
+}
+
+func installLaunchd(ctx context.Context, opts ServiceOptions, spec serviceSpec) error {
+	dir, err := serviceDir(opts)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(dir, launchdLabel(spec.Name)+".plist")
+
+	// Unload an earlier install, so launchd picks up the new interval
+	if _, err := os.Stat(path); err == nil {
+		serviceCommand(ctx, opts, "", "launchctl", "unload", path)
+	}
+	if err := writeServiceFiles(opts, []serviceFile{{Path: path, Content: launchdPlist(spec)}}); err != nil {
+		return err
+	}
+	if _, err := serviceCommand(ctx, opts, "", "launchctl", "load", "-w", path); err != nil {
+		return fmt.Errorf("wrote %s but could not load it (run 'launchctl load -w %s'): %w", path, path, err)
+	}
+	if opts.DryRun {
+		return nil
+	}
+
+	fmt.Fprintf(opts.Output, "✓ Installed %s, running 'rp update' every %s\n", path, spec.Interval)
+	fmt.Fprintf(opts.Output, "  Logs: %s\n", spec.LogPath())
+	return nil
+}
+
+func uninstallLaunchd(ctx context.Context, opts ServiceOptions) error {
+	dir, err := serviceDir(opts)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(dir, launchdLabel(opts.Name)+".plist")
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return fmt.Errorf("no launch agent at %s", path)
+	}
+	if _, err := serviceCommand(ctx, opts, "", "launchctl", "unload", "-w", path); err != nil {
+		fmt.Fprintf(opts.Output, "Warning: could not unload %s: %v\n", path, err)
+	}
+	if _, err := removeServiceFiles(opts, path); err != nil {
+		return err
+	}
+	if !opts.DryRun {
+		fmt.Fprintf(opts.Output, "✓ Removed %s\n", path)
+	}
+	return nil
+}
+
+// cronMarker ends the crontab line of the service named name, so it can be
+// found again to replace or remove
+func cronMarker(name string) string {
+	return "# rp:" + name
+}
+
+// cronSchedule returns the schedule fields running every interval. cron can
+// only express intervals that divide an hour or a day evenly.
+func cronSchedule(interval time.Duration) (string, error) {
+	switch {
+	case interval%time.Minute != 0:
+		return "", fmt.Errorf("cron intervals must be whole minutes, not %s", interval)
+	case interval < time.Hour && time.Hour%interval == 0:
+		if interval == time.Minute {
+			return "* * * * *", nil
+		}
+		return fmt.Sprintf("*/%d * * * *", int(interval/time.Minute)), nil
+	case interval%time.Hour == 0 && (24*time.Hour)%interval == 0:
+		if interval == time.Hour {
+			return "0 * * * *", nil
+		}
+		if interval == 24*time.Hour {
+			return "0 0 * * *", nil
+		}
+		return fmt.Sprintf("0 */%d * * *", int(interval/time.Hour)), nil
+	}
+	return "", fmt.Errorf("cron cannot run every %s; use an interval that divides an hour or a day evenly (e.g. 15m, 30m, 2h)", interval)
+}
+
+// cronLine returns the crontab line running spec
+func cronLine(spec serviceSpec) (string, error) {
+	schedule, err := cronSchedule(spec.Interval)
+	if err != nil {
+		return "", err
+	}
+	command := fmt.Sprintf("cd %s && %s update --config %s >> %s 2>&1",
+		shellQuote(spec.WorkDir()), shellQuote(spec.Executable), shellQuote(spec.ConfigPath), shellQuote(spec.LogPath()))
+	// cron turns an unescaped % into a newline
+	command = strings.ReplaceAll(command, "%", `\%`)
+	return schedule + " " + command + " " + cronMarker(spec.Name), nil
+}
+
+// shellQuote quotes s as one word for sh
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// withoutCronLine returns crontab without the lines ending in marker, and
+// whether there were any
+func withoutCronLine(crontab, marker string) (string, bool) {
+	var kept []string
+	found := false
+	for _, line := range strings.Split(strings.TrimRight(crontab, "\n"), "\n") {
+		if strings.HasSuffix(strings.TrimSpace(line), marker) {
+			found = true
+			continue
+		}
+		kept = append(kept, line)
+	}
+	result := strings.Join(kept, "\n")
+	if strings.TrimSpace(result) == "" {
+		return "", found
+	}
+	return result + "\n", found
+}
+
+// readCrontab returns the user's crontab; a user without one has an empty one
+func readCrontab(ctx context.Context, opts ServiceOptions) string {
+	crontab, err := opts.run(ctx, "", "crontab", "-l")
+	if err != nil {
+		return ""
+	}
+	return crontab
+}
+
+func installCron(ctx context.Context, opts ServiceOptions, spec serviceSpec) error {
+	line, err := cronLine(spec)
+	if err != nil {
+		return err
+	}
+	crontab, _ := withoutCronLine(readCrontab(ctx, opts), cronMarker(spec.Name))
+	crontab += line + "\n"
+
+	if opts.DryRun {
+		fmt.Fprintf(opts.Output, "Would add to crontab:\n%s\n", line)
+		return nil
+	}
+	if _, err := opts.run(ctx, crontab, "crontab", "-"); err != nil {
+		return fmt.Errorf("install crontab: %w", err)
+	}
+	fmt.Fprintf(opts.Output, "✓ Added a crontab entry running 'rp update' every %s\n", spec.Interval)
+	fmt.Fprintf(opts.Output, "  Logs: %s\n", spec.LogPath())
+	return nil
+}
+
+func uninstallCron(ctx context.Context, opts ServiceOptions) error {
+	crontab, found := withoutCronLine(readCrontab(ctx, opts), cronMarker(opts.Name))
+	if !found {
+		return fmt.Errorf("no crontab entry marked %q", cronMarker(opts.Name))
+	}
+	if opts.DryRun {
+		fmt.Fprintf(opts.Output, "Would remove the crontab entry marked %q\n", cronMarker(opts.Name))
+		return nil
+	}
+
+	var err error
+	if crontab == "" {
+		_, err = opts.run(ctx, "", "crontab", "-r")
+	} else {
+		_, err = opts.run(ctx, crontab, "crontab", "-")
+	}
+	if err != nil {
+		return fmt.Errorf("update crontab: %w", err)
+	}
+	fmt.Fprintf(opts.Output, "✓ Removed the crontab entry marked %q\n", cronMarker(opts.Name))
+	return nil
+}
+
+// errServiceName is returned for names that are not safe as file names
+var errServiceName = errors.New("service name may only contain letters, digits, '.', '_', and '-', and must not start with '-' or '.'")
+
+// validServiceName reports whether name is safe as a unit file name and
+// cron marker
+func validServiceName(name string) error {
+	if name == "" || name[0] == '-' || name[0] == '.' {
+		return errServiceName
+	}
+	for _, c := range name {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '.' || c == '_' || c == '-') {
+			return errServiceName
+		}
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+
+	"github.com/adewale/rogue_planet/pkg/repository"
+)
+
+// cmdShowEntry prints the stored entries whose link or ID is opts.Ref and,
+// with opts.Revisions, the earlier revisions kept of each with what every
+// edit changed
+func cmdShowEntry(ctx context.Context, opts ShowEntryOptions) error {
+	if opts.Ref == "" {
+		return fmt.Errorf("entry link or ID is required")
+	}
+
+	cfg, repo, cleanup, err := openConfigAndRepo(opts.ConfigPath)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	entries, err := repo.FindEntries(ctx, opts.Ref)
+	if err != nil {
+		return fmt.Errorf("failed to find entry: %w", err)
+	}
+	if len(entries) == 0 {
+		return fmt.Errorf("no stored entry has the link or ID %s", opts.Ref)
+	}
+
+	for i, e := range entries {
+		if i > 0 {
+			fmt.Fprintln(opts.Output)
+		}
+		feedURL := fmt.Sprint(e.FeedID)
+		if feed, err := repo.GetFeedByID(ctx, e.FeedID); err == nil {
+			feedURL = feed.URL
+		}
+		fmt.Fprintf(opts.Output, "%s\n", entryLabel(e.Title, e.EntryID))
+		if e.Link != "" {
+			fmt.Fprintf(opts.Output, "  Link: %s\n", e.Link)
+		}
+		fmt.Fprintf(opts.Output, "  ID: %s\n", e.EntryID)
+		fmt.Fprintf(opts.Output, "  Feed: %s\n", feedURL)
+		fmt.Fprintf(opts.Output, "  Published: %s\n", e.Published.Format(time.RFC3339))
+		fmt.Fprintf(opts.Output, "  First seen: %s\n", e.FirstSeen.Format(time.RFC3339))
+		if e.UpdatedCount > 0 {
+			fmt.Fprintf(opts.Output, "  Edited: %d times, last seen %s\n", e.UpdatedCount, e.LastSignificantUpdate.Format(time.RFC3339))
+		}
+
+		if !opts.Revisions {
+			continue
+		}
+		revisions, err := repo.GetRevisions(ctx, e.ID)
+		if err != nil {
+			return fmt.Errorf("failed to get revisions: %w", err)
+		}
+		if len(revisions) == 0 {
+			if cfg.Database.EntryRevisions == 0 {
+				fmt.Fprintln(opts.Output, "\n  No revisions kept; set entry_revisions in [database] to keep them")
+			} else {
+				fmt.Fprintln(opts.Output, "\n  No revisions kept; its content has not changed since it was first kept")
+			}
+			continue
+		}
+		fmt.Fprintf(opts.Output, "\n  Revisions (%d, oldest first):\n", len(revisions))
+		printRevisions(opts.Output, revisions, e)
+	}
+	return nil
+}
+
+// printRevisions prints each revision of e with the lines the edit that
+// replaced it removed and added
+func printRevisions(w io.Writer, revisions []repository.Revision, e repository.Entry) {
+	for i, rev := range revisions {
+		nextTitle, nextContent := e.Title, e.Content
+		if i+1 < len(revisions) {
+			nextTitle, nextContent = revisions[i+1].Title, revisions[i+1].Content
+		}
+		fmt.Fprintf(w, "\n  %d. Replaced %s\n", i+1, rev.ReplacedAt.Local().Format(time.RFC3339))
+		if rev.Title != nextTitle {
+			fmt.Fprintf(w, "      Title: %s → %s\n", entryLabel(rev.Title, ""), entryLabel(nextTitle, ""))
+		}
+		changes := diffLines(contentLines(rev.Content), contentLines(nextContent))
+		if len(changes) == 0 {
+			fmt.Fprintln(w, "      (only markup changed)")
+		}
+		for _, line := range changes {
+			fmt.Fprintf(w, "      %s\n", line)
+		}
+	}
+}
+
+// blockElements start a new line of an entry's text
+var blockElements = map[string]bool{
+	"p": true, "div": true, "br": true, "li": true, "tr": true, "blockquote": true, "pre": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"figcaption": true, "dt": true, "dd": true, "hr": true, "section": true, "article": true,
+}
+
+// contentLines returns the text of an entry's HTML content a paragraph to a
+// line, with entities decoded and whitespace collapsed
+func contentLines(content string) []string {
+	z := html.NewTokenizer(strings.NewReader(content))
+	var lines []string
+	var line strings.Builder
+	flush := func() {
+		if text := strings.Join(strings.Fields(line.String()), " "); text != "" {
+			lines = append(lines, text)
+		}
+		line.Reset()
+	}
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			flush()
+			return lines
+		case html.TextToken:
+			line.Write(z.Text())
+		case html.StartTagToken, html.EndTagToken, html.SelfClosingTagToken:
+			name, _ := z.TagName()
+			if blockElements[string(name)] {
+				flush()
+			} else {
+				// Tags separate words
+				line.WriteByte(' ')
+			}
+		}
+	}
+}
+
+// maxDiffLines bounds the lines diffLines compares line by line; longer
+// texts are shown as wholly replaced
+const maxDiffLines = 2000
+
+// diffLines returns the lines of before that after removes, prefixed "- ",
+// and those it adds, prefixed "+ ", in order, leaving out the lines they
+// share
+func diffLines(before, after []string) []string {
+	if len(before) > maxDiffLines || len(after) > maxDiffLines {
+		return append(prefixLines("- ", before), prefixLines("+ ", after)...)
+	}
+
+	// common[i][j] is the length of the longest common subsequence of
+	// before[i:] and after[j:]
+	common := make([][]int, len(before)+1)
+	for i := range common {
+		common[i] = make([]int, len(after)+1)
+	}
+	for i := len(before) - 1; i >= 0; i-- {
+		for j := len(after) - 1; j >= 0; j-- {
+			if before[i] == after[j] {
+				common[i][j] = common[i+1][j+1] + 1
+			} else {
+				common[i][j] = max(common[i+1][j], common[i][j+1])
+			}
+		}
+	}
+
+	var changes []string
+	i, j := 0, 0
+	for i < len(before) && j < len(after) {
+		switch {
+		case before[i] == after[j]:
+			i++
+			j++
+		case common[i+1][j] >= common[i][j+1]:
+			changes = append(changes, "- "+before[i])
+			i++
+		default:
+			changes = append(changes, "+ "+after[j])
+			j++
+		}
+	}
+	changes = append(changes, prefixLines("- ", before[i:])...)
+	return append(changes, prefixLines("+ ", after[j:])...)
+}
+
+// prefixLines returns lines each with prefix in front
+func prefixLines(prefix string, lines []string) []string {
+	prefixed := make([]string, len(lines))
+	for i, line := range lines {
+		prefixed[i] = prefix + line
+	}
+	return prefixed
+}
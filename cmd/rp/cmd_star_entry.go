@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/adewale/rogue_planet/pkg/repository"
+)
+
+func cmdStarEntry(ctx context.Context, opts EntryOptions) error {
+	if opts.Ref == "" {
+		return fmt.Errorf("entry link or ID is required")
+	}
+
+	_, repo, cleanup, err := openConfigAndRepo(opts.ConfigPath)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	entries, err := repo.StarEntries(ctx, opts.Ref, time.Now())
+	if errors.Is(err, repository.ErrEntryNotFound) {
+		return fmt.Errorf("no stored entry has the link or ID %s", opts.Ref)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to star entry: %w", err)
+	}
+
+	for _, e := range entries {
+		fmt.Fprintf(opts.Output, "✓ Starred: %s (%s)\n", entryLabel(e.Title, e.EntryID), e.Link)
+	}
+	return nil
+}
+
+func cmdUnstarEntry(ctx context.Context, opts EntryOptions) error {
+	if opts.Ref == "" {
+		return fmt.Errorf("entry link or ID is required")
+	}
+
+	_, repo, cleanup, err := openConfigAndRepo(opts.ConfigPath)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	n, err := repo.UnstarEntries(ctx, opts.Ref)
+	if err != nil {
+		return fmt.Errorf("failed to unstar entry: %w", err)
+	}
+	if n == 0 {
+		return fmt.Errorf("no starred entry has the link or ID %s (see 'rp list-starred')", opts.Ref)
+	}
+
+	fmt.Fprintf(opts.Output, "✓ Unstarred %s\n", opts.Ref)
+	return nil
+}
+
+func cmdListStarred(ctx context.Context, opts ListStarredOptions) error {
+	_, repo, cleanup, err := openConfigAndRepo(opts.ConfigPath)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	starred, err := repo.GetStarredEntries(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get starred entries: %w", err)
+	}
+
+	if len(starred) == 0 {
+		fmt.Fprintln(opts.Output, "No starred entries.")
+		return nil
+	}
+
+	fmt.Fprintf(opts.Output, "Starred entries (%d):\n\n", len(starred))
+	printMarkedEntries(opts.Output, starred, "Starred")
+	return nil
+}
@@ -2,17 +2,33 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
+
+	"github.com/adewale/rogue_planet/pkg/config"
+	"github.com/adewale/rogue_planet/pkg/repository"
+)
+
+// Limits for the per-feed status report
+const (
+	statusFetchHistory = 10   // Fetch attempts shown
+	statusWeeks        = 8    // Weeks of entry counts shown
+	statusEntryTimes   = 1000 // Entry timestamps read for cadence statistics
+	statusTransferDays = 1    // Days of fetches totalled in the transfer summary
 )
 
-func cmdStatus(opts StatusOptions) error {
+func cmdStatus(ctx context.Context, opts StatusOptions) error {
 	cfg, repo, cleanup, err := openConfigAndRepo(opts.ConfigPath)
 	if err != nil {
 		return err
 	}
 	defer cleanup()
 
-	ctx := context.Background()
+	if opts.Feed != "" {
+		return feedStatus(ctx, cfg, repo, opts)
+	}
 
 	// Get feed counts
 	feeds, err := repo.GetFeeds(ctx, false)
@@ -20,11 +36,14 @@ func cmdStatus(opts StatusOptions) error {
 		return fmt.Errorf("failed to get feeds: %w", err)
 	}
 
-	activeFeeds := 0
+	activeFeeds, goneFeeds := 0, 0
 	for _, feed := range feeds {
 		if feed.Active {
 			activeFeeds++
 		}
+		if !feed.GoneAt.IsZero() {
+			goneFeeds++
+		}
 	}
 
 	// Get entry count
@@ -39,16 +58,278 @@ func cmdStatus(opts StatusOptions) error {
 		return fmt.Errorf("failed to count recent entries: %w", err)
 	}
 
+	transfer, err := repo.GetTransferStats(ctx, time.Now().AddDate(0, 0, -statusTransferDays))
+	if err != nil {
+		return fmt.Errorf("failed to read transfer stats: %w", err)
+	}
+
 	// Display status
 	fmt.Fprintln(opts.Output, "Rogue Planet Status")
 	fmt.Fprintln(opts.Output, "===================")
 	fmt.Fprintln(opts.Output)
-	fmt.Fprintf(opts.Output, "Feeds:           %d total (%d active, %d inactive)\n", len(feeds), activeFeeds, len(feeds)-activeFeeds)
+	fmt.Fprintf(opts.Output, "Feeds:           %d total (%d active, %d inactive, %d gone)\n", len(feeds), activeFeeds, len(feeds)-activeFeeds-goneFeeds, goneFeeds)
 	fmt.Fprintf(opts.Output, "Entries:         %d total\n", totalEntries)
 	fmt.Fprintf(opts.Output, "Recent entries:  %d (last %d days)\n", recentEntries, cfg.Planet.Days)
+	if transfer.Fetches > 0 {
+		fmt.Fprintf(opts.Output, "Downloaded:      %s\n", formatTransfer(transfer))
+	}
 	fmt.Fprintln(opts.Output)
 	fmt.Fprintf(opts.Output, "Output:          %s/index.html\n", cfg.Planet.OutputDir)
-	fmt.Fprintf(opts.Output, "Database:        %s\n", cfg.Database.Path)
+	if cfg.Database.Driver == repository.DriverPostgres {
+		// The connection string may hold a password, so it is not shown
+		fmt.Fprintln(opts.Output, "Database:        PostgreSQL")
+	} else {
+		fmt.Fprintf(opts.Output, "Database:        %s\n", cfg.Database.Path)
+	}
+
+	return nil
+}
+
+// feedStatus prints detailed diagnostics for a single feed: HTTP cache state,
+// recent fetch attempts, posting cadence, and when it will next be fetched
+func feedStatus(ctx context.Context, cfg *config.Config, repo *repository.Repository, opts StatusOptions) error {
+	feed, err := repo.GetFeedByURL(ctx, opts.Feed)
+	if errors.Is(err, repository.ErrFeedNotFound) {
+		return fmt.Errorf("feed not found: %s", opts.Feed)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get feed: %w", err)
+	}
+
+	history, err := repo.GetFetchLog(ctx, feed.ID, statusFetchHistory)
+	if err != nil {
+		return fmt.Errorf("failed to read fetch log: %w", err)
+	}
+	entryCount, err := repo.GetEntryCountForFeed(ctx, feed.ID)
+	if err != nil {
+		return fmt.Errorf("failed to count entries: %w", err)
+	}
+	times, err := repo.GetEntryTimes(ctx, feed.ID, statusEntryTimes)
+	if err != nil {
+		return fmt.Errorf("failed to read entry times: %w", err)
+	}
+	categories, err := repo.GetFeedCategories(ctx, feed.ID)
+	if err != nil {
+		return fmt.Errorf("failed to read categories: %w", err)
+	}
+
+	w := opts.Output
+	now := time.Now()
+
+	title := feed.Title
+	if title == "" {
+		title = "(untitled)"
+	}
+	fmt.Fprintln(w, title)
+	fmt.Fprintln(w, strings.Repeat("=", len([]rune(title))))
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "URL:             %s\n", feed.URL)
+	if feed.Link != "" {
+		fmt.Fprintf(w, "Site:            %s\n", feed.Link)
+	}
+	fmt.Fprintf(w, "ID:              %d\n", feed.ID)
+	fmt.Fprintf(w, "Active:          %s\n", yesNo(feed.Active))
+	if !feed.GoneAt.IsZero() {
+		fmt.Fprintf(w, "Gone since:      %s\n", feed.GoneAt.Local().Format("2006-01-02 15:04"))
+	}
+	if len(categories) > 0 {
+		fmt.Fprintf(w, "Categories:      %s\n", strings.Join(categories, ", "))
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "HTTP")
+	fmt.Fprintf(w, "  Last fetched:  %s\n", formatStatusTime(feed.LastFetched, now))
+	if len(history) > 0 {
+		fmt.Fprintf(w, "  Last status:   %s\n", formatStatusCode(history[0].StatusCode))
+	}
+	fmt.Fprintf(w, "  ETag:          %s\n", orNone(feed.ETag))
+	fmt.Fprintf(w, "  Last-Modified: %s\n", orNone(feed.LastModified))
+	fmt.Fprintf(w, "  Cache expires: %s\n", formatStatusTime(feed.CacheExpires, now))
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Errors")
+	fmt.Fprintf(w, "  Consecutive:   %d\n", feed.FetchErrorCount)
+	if feed.FetchError != "" {
+		fmt.Fprintf(w, "  Last error:    %s\n", feed.FetchError)
+	}
+	if len(history) > 0 {
+		fmt.Fprintf(w, "  Recent fetches (newest first):\n")
+		for _, h := range history {
+			line := fmt.Sprintf("    %s  %s", h.FetchedAt.Local().Format("2006-01-02 15:04"), formatStatusCode(h.StatusCode))
+			if h.DecodedBytes > 0 {
+				line += fmt.Sprintf("  %s %s, %s", h.Proto, orNone(h.ContentEncoding), formatBytes(h.WireBytes))
+				if h.WireBytes != h.DecodedBytes {
+					line += " → " + formatBytes(h.DecodedBytes)
+				}
+			}
+			if h.Error != "" {
+				line += "  " + h.Error
+			}
+			fmt.Fprintln(w, line)
+		}
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Entries")
+	fmt.Fprintf(w, "  Total:         %d\n", entryCount)
+	if avg, ok := averageInterval(times); ok {
+		fmt.Fprintf(w, "  Avg interval:  %s (over %d entries)\n", formatInterval(avg), len(times))
+	} else {
+		fmt.Fprintf(w, "  Avg interval:  unknown (fewer than 2 dated entries)\n")
+	}
+	fmt.Fprintf(w, "  By week:\n")
+	for _, wk := range weeklyCounts(times, now, statusWeeks) {
+		fmt.Fprintf(w, "    %s  %3d %s\n", wk.start.Format("2006-01-02"), wk.count, strings.Repeat("#", min(wk.count, 40)))
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Schedule")
+	switch {
+	case !feed.Active:
+		fmt.Fprintln(w, "  Next fetch:    never (feed is inactive)")
+	case feed.CacheExpires.After(now):
+		fmt.Fprintf(w, "  Next fetch:    %s (HTTP cache lifetime)\n", formatStatusTime(feed.CacheExpires, now))
+	case cfg.Planet.AdaptiveScheduling:
+		fmt.Fprintf(w, "  Next fetch:    %s\n", formatStatusTime(feed.NextFetch, now))
+		if feed.FetchInterval > 0 {
+			fmt.Fprintf(w, "  Interval:      %s (adaptive)\n", formatInterval(time.Duration(feed.FetchInterval)*time.Second))
+		}
+	default:
+		fmt.Fprintln(w, "  Next fetch:    next update (adaptive_scheduling is off)")
+	}
 
 	return nil
 }
+
+// weekCount is the number of entries published in the week starting at start
+type weekCount struct {
+	start time.Time
+	count int
+}
+
+// weeklyCounts buckets entry times into the last n weeks (Monday to Sunday,
+// local time), oldest first. Times outside that range are ignored.
+func weeklyCounts(times []time.Time, now time.Time, n int) []weekCount {
+	now = now.Local()
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	offset := (int(day.Weekday()) + 6) % 7 // Days since Monday
+	current := day.AddDate(0, 0, -offset)
+
+	weeks := make([]weekCount, n)
+	for i := range weeks {
+		weeks[i].start = current.AddDate(0, 0, -7*(n-1-i))
+	}
+	for _, t := range times {
+		t = t.Local()
+		for i := n - 1; i >= 0; i-- {
+			if !t.Before(weeks[i].start) {
+				if i < n-1 || t.Before(current.AddDate(0, 0, 7)) {
+					weeks[i].count++
+				}
+				break
+			}
+		}
+	}
+	return weeks
+}
+
+// averageInterval returns the mean gap between entry times, or false when
+// there are fewer than two
+func averageInterval(times []time.Time) (time.Duration, bool) {
+	var newest, oldest time.Time
+	n := 0
+	for _, t := range times {
+		if t.IsZero() {
+			continue
+		}
+		if n == 0 || t.After(newest) {
+			newest = t
+		}
+		if n == 0 || t.Before(oldest) {
+			oldest = t
+		}
+		n++
+	}
+	if n < 2 {
+		return 0, false
+	}
+	return newest.Sub(oldest) / time.Duration(n-1), true
+}
+
+// formatInterval renders a duration in days and hours, or hours and minutes
+func formatInterval(d time.Duration) string {
+	if d >= 24*time.Hour {
+		days := d / (24 * time.Hour)
+		hours := (d % (24 * time.Hour)) / time.Hour
+		return fmt.Sprintf("%dd %dh", days, hours)
+	}
+	return d.Round(time.Minute).String()
+}
+
+// formatStatusTime renders a time with a relative hint, or "never"
+func formatStatusTime(t, now time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+	when := t.Local().Format("2006-01-02 15:04")
+	if t.After(now) {
+		return fmt.Sprintf("%s (in %s)", when, formatInterval(t.Sub(now)))
+	}
+	return fmt.Sprintf("%s (%s ago)", when, formatInterval(now.Sub(t)))
+}
+
+// formatTransfer summarises downloads: bytes on the wire and decoded, and
+// how many fetches used compression and HTTP/2
+func formatTransfer(s repository.TransferStats) string {
+	summary := fmt.Sprintf("%s in %d fetches (last %dh)", formatBytes(s.WireBytes), s.Fetches, statusTransferDays*24)
+	if s.DecodedBytes > s.WireBytes {
+		saved := 100 - s.WireBytes*100/s.DecodedBytes
+		summary += fmt.Sprintf(", %s decoded (%d%% saved by compression)", formatBytes(s.DecodedBytes), saved)
+	}
+	var parts []string
+	for _, enc := range []string{"br", "gzip", "deflate"} {
+		if n := s.ByEncoding[enc]; n > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", n, enc))
+		}
+	}
+	if n := s.ByProto["HTTP/2.0"]; n > 0 {
+		parts = append(parts, fmt.Sprintf("%d over HTTP/2", n))
+	}
+	if len(parts) > 0 {
+		summary += "; " + strings.Join(parts, ", ")
+	}
+	return summary
+}
+
+// formatBytes renders a byte count in B, KB, or MB
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1024*1024:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1024*1024))
+	case n >= 1024:
+		return fmt.Sprintf("%.1f KB", float64(n)/1024)
+	default:
+		return fmt.Sprintf("%d B", n)
+	}
+}
+
+func formatStatusCode(code int) string {
+	if code == 0 {
+		return "no response"
+	}
+	return fmt.Sprintf("%d", code)
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "none"
+	}
+	return s
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
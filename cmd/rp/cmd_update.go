@@ -2,28 +2,32 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+
+	"github.com/adewale/rogue_planet/pkg/planet"
 )
 
 func cmdUpdate(ctx context.Context, opts UpdateOptions) error {
-	setVerboseLogging(opts.Verbose)
-
-	// Load config
-	cfg, err := loadConfig(opts.ConfigPath)
+	p, err := openPlanet(opts.ConfigPath, opts.Logger, opts.Output)
 	if err != nil {
-		return fmt.Errorf("failed to load config: %w", err)
+		return err
 	}
+	defer p.Close()
 
-	// Fetch feeds
-	fmt.Fprintln(opts.Output, "Fetching feeds...")
-	if err := fetchFeeds(ctx, cfg, opts.Logger); err != nil {
-		return fmt.Errorf("failed to fetch feeds: %w", err)
-	}
+	abort, stopListening := abortOnSignal(opts.Logger)
+	defer stopListening()
 
-	// Generate site
-	fmt.Fprintln(opts.Output, "Generating site...")
-	if err := generateSite(ctx, cfg); err != nil {
-		return fmt.Errorf("failed to generate site: %w", err)
+	err = p.Update(ctx, planet.UpdateOptions{
+		Fetch:    planet.FetchOptions{Force: opts.Force, Selection: opts.Selection, Wait: opts.Wait, Abort: abort},
+		Generate: planet.GenerateOptions{NoPublish: opts.NoPublish},
+	})
+	if errors.Is(err, planet.ErrRunInProgress) && opts.Wait == 0 {
+		fmt.Fprintf(opts.Output, "Skipping update: %v. Use --wait to wait for it.\n", err)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update: %w", err)
 	}
 
 	fmt.Fprintln(opts.Output, "✓ Update complete")
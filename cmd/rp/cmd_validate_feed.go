@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/adewale/rogue_planet/pkg/crawler"
+	"github.com/adewale/rogue_planet/pkg/normalizer"
+	"github.com/adewale/rogue_planet/pkg/planet"
+)
+
+func cmdValidateFeed(ctx context.Context, opts ValidateFeedOptions) error {
+	if opts.Source == "" {
+		return fmt.Errorf("feed URL or file is required")
+	}
+
+	// The config only supplies crawler and sanitizer settings, so it may be absent
+	cfg, err := planet.LoadConfig(opts.ConfigPath)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	n, err := planet.NewNormalizer(cfg)
+	if err != nil {
+		return err
+	}
+
+	var data []byte
+	feedURL, contentType := opts.Source, ""
+	if isFeedURL(opts.Source) {
+		fetchCtx, cancel := context.WithTimeout(ctx, planet.FeedTimeout(cfg))
+		defer cancel()
+		resp, err := planet.NewCrawler(cfg).Fetch(fetchCtx, opts.Source, crawler.FeedCache{})
+		if err != nil {
+			return fmt.Errorf("failed to fetch feed: %w", err)
+		}
+		data, feedURL, contentType = resp.Body, resp.FinalURL, resp.Headers["Content-Type"]
+	} else {
+		data, err = os.ReadFile(opts.Source)
+		if err != nil {
+			return fmt.Errorf("failed to read feed: %w", err)
+		}
+		// Relative links resolve against the file unless told where it will live
+		if opts.BaseURL != "" {
+			feedURL = opts.BaseURL
+		} else if abs, err := filepath.Abs(opts.Source); err == nil {
+			feedURL = "file://" + filepath.ToSlash(abs)
+		}
+	}
+
+	report, err := n.Validate(ctx, data, feedURL, contentType, time.Now())
+	if err != nil {
+		return err
+	}
+	printValidation(opts, feedURL, report)
+
+	if errs := report.Errors(); errs > 0 {
+		return fmt.Errorf("feed has %d errors", errs)
+	}
+	return nil
+}
+
+// isFeedURL reports whether source names a feed on the web rather than a file
+func isFeedURL(source string) bool {
+	lower := strings.ToLower(source)
+	return strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://")
+}
+
+// printValidation writes the report of validate-feed
+func printValidation(opts ValidateFeedOptions, feedURL string, report *normalizer.Report) {
+	out := opts.Output
+	fmt.Fprintf(out, "Validating %s\n", feedURL)
+	format := report.Format
+	if format == "" {
+		format = "unknown"
+	}
+	fmt.Fprintf(out, "  Format:   %s\n", format)
+	if report.Encoding != "" {
+		fmt.Fprintf(out, "  Encoding: %s\n", report.Encoding)
+	}
+	if report.Feed != nil {
+		fmt.Fprintf(out, "  Title:    %s\n", report.Feed.Title)
+		fmt.Fprintf(out, "  Link:     %s\n", report.Feed.Link)
+		fmt.Fprintf(out, "  Entries:  %d\n", len(report.Entries))
+	}
+
+	if len(report.Problems) > 0 {
+		fmt.Fprintln(out)
+		fmt.Fprintln(out, "Problems:")
+		for _, p := range report.Problems {
+			mark := "⚠"
+			if p.Severity == normalizer.SeverityError {
+				mark = "✗"
+			}
+			where := "feed"
+			if p.Entry > 0 {
+				where = fmt.Sprintf("entry %d", p.Entry)
+			}
+			fmt.Fprintf(out, "  %s %s: %s\n", mark, where, p.Message)
+		}
+	}
+
+	if len(report.Entries) > 0 {
+		fmt.Fprintln(out)
+		fmt.Fprintln(out, "Entries as rp would store them:")
+		for i, e := range report.Entries {
+			fmt.Fprintf(out, "  %d. %s\n", i+1, entryLabel(e.Title, e.ID))
+			fmt.Fprintf(out, "     ID:        %s\n", e.ID)
+			if e.Link != "" {
+				fmt.Fprintf(out, "     Link:      %s\n", e.Link)
+			}
+			if e.Author != "" {
+				fmt.Fprintf(out, "     Author:    %s\n", e.Author)
+			}
+			fmt.Fprintf(out, "     Published: %s\n", e.Published.Format(time.RFC3339))
+			fmt.Fprintf(out, "     Content:   %d words\n", e.WordCount)
+		}
+	}
+
+	fmt.Fprintln(out)
+	errs := report.Errors()
+	warnings := len(report.Problems) - errs
+	if len(report.Problems) == 0 {
+		fmt.Fprintln(out, "✓ No problems found")
+	} else {
+		fmt.Fprintf(out, "Found %s and %s.\n", plural(errs, "error", "errors"), plural(warnings, "warning", "warnings"))
+	}
+}
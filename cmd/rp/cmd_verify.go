@@ -5,15 +5,18 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/adewale/rogue_planet/pkg/config"
+	"github.com/adewale/rogue_planet/pkg/planet"
 	"github.com/adewale/rogue_planet/pkg/repository"
 )
 
-func cmdVerify(opts VerifyOptions) error {
+func cmdVerify(ctx context.Context, opts VerifyOptions) error {
 	errors := []string{}
 
 	// 1. Load and validate config file
+	opts.ConfigPath = config.Locate(opts.ConfigPath)
 	cfg, err := config.LoadFromFile(opts.ConfigPath)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -26,15 +29,17 @@ func cmdVerify(opts VerifyOptions) error {
 	if err := cfg.Validate(); err != nil {
 		errors = append(errors, fmt.Sprintf("Invalid config value: %v", err))
 	}
-
-	ctx := context.Background()
+	if _, err := planet.NewNormalizer(cfg); err != nil {
+		errors = append(errors, fmt.Sprintf("Invalid [sanitize] section: %v", err))
+	}
 
 	// 2. Check database accessibility and schema
-	if _, err := os.Stat(cfg.Database.Path); os.IsNotExist(err) {
+	postgres := cfg.Database.Driver == repository.DriverPostgres
+	if _, err := os.Stat(cfg.Database.Path); !postgres && os.IsNotExist(err) {
 		errors = append(errors, "Database does not exist → run 'rp init' to create")
 	} else {
 		// Try to open database
-		repo, err := repository.New(cfg.Database.Path)
+		repo, err := planet.OpenDatabase(cfg)
 		if err != nil {
 			errors = append(errors, fmt.Sprintf("Database error: %v", err))
 		} else {
@@ -43,6 +48,12 @@ func cmdVerify(opts VerifyOptions) error {
 			if err != nil {
 				errors = append(errors, fmt.Sprintf("Database schema error: %v", err))
 			}
+			// Without WAL, generating the site fails while a fetch is writing
+			if settings, err := repo.Settings(ctx); err != nil {
+				errors = append(errors, fmt.Sprintf("Database settings error: %v", err))
+			} else if !postgres && settings.JournalMode != "wal" {
+				errors = append(errors, fmt.Sprintf("Database uses %s journal mode, not WAL → keep it on a local file system that supports shared memory", settings.JournalMode))
+			}
 			repo.Close()
 		}
 	}
@@ -67,7 +78,14 @@ func cmdVerify(opts VerifyOptions) error {
 		}
 	}
 
-	// 5. Report results
+	// 5. Credentials should not be readable by other users
+	if cfg.Planet.SecretsFile != "" {
+		if info, err := os.Stat(cfg.Planet.SecretsFile); err == nil && info.Mode().Perm()&0077 != 0 {
+			errors = append(errors, fmt.Sprintf("Secrets file is readable by other users → chmod 600 %s", cfg.Planet.SecretsFile))
+		}
+	}
+
+	// 6. Report results
 	if len(errors) > 0 {
 		fmt.Fprintln(opts.Output, "✗ Configuration validation failed")
 		fmt.Fprintln(opts.Output)
@@ -75,17 +93,22 @@ func cmdVerify(opts VerifyOptions) error {
 			fmt.Fprintf(opts.Output, "- %s\n", e)
 		}
 		fmt.Fprintln(opts.Output)
-		fmt.Fprintf(opts.Output, "Found %d errors.\n", len(errors))
+		fmt.Fprintf(opts.Output, "Found %s.\n", plural(len(errors), "error", "errors"))
 		return fmt.Errorf("validation failed")
 	}
 
 	// Success - get feed/entry counts if database exists
-	repo, err := repository.New(cfg.Database.Path)
+	repo, err := planet.OpenDatabase(cfg)
 	if err == nil {
 		defer repo.Close()
 		feeds, _ := repo.GetFeeds(ctx, false)
 		entries, _ := repo.CountEntries(ctx)
 		fmt.Fprintf(opts.Output, "✓ Configuration valid (%d feeds, %d entries)\n", len(feeds), entries)
+		if settings, err := repo.Settings(ctx); err == nil && settings.Driver == repository.DriverPostgres {
+			fmt.Fprintln(opts.Output, "  Database: PostgreSQL")
+		} else if err == nil {
+			fmt.Fprintf(opts.Output, "  Database: %s journal mode, synchronous=%s, busy timeout %s\n", strings.ToUpper(settings.JournalMode), settings.Synchronous, settings.BusyTimeout)
+		}
 	} else {
 		fmt.Fprintln(opts.Output, "✓ Configuration valid")
 	}
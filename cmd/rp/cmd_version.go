@@ -0,0 +1,19 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/adewale/rogue_planet/pkg/buildinfo"
+)
+
+func cmdVersion(opts VersionOptions) error {
+	info := buildinfo.Get()
+
+	if opts.Verbose {
+		fmt.Fprint(opts.Output, info.Verbose())
+		return nil
+	}
+
+	fmt.Fprintf(opts.Output, "rp version %s\n", info.Version)
+	return nil
+}
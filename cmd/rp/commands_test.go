@@ -3,16 +3,31 @@ package main
 import (
 	"bytes"
 	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"reflect"
+	"runtime"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/adewale/rogue_planet/pkg/config"
+	"github.com/adewale/rogue_planet/pkg/discover"
 	"github.com/adewale/rogue_planet/pkg/logging"
+	"github.com/adewale/rogue_planet/pkg/metrics"
+	"github.com/adewale/rogue_planet/pkg/planet"
 	"github.com/adewale/rogue_planet/pkg/repository"
+	"github.com/adewale/rogue_planet/pkg/translate"
 )
 
 func TestCmdAddFeed(t *testing.T) {
@@ -37,7 +52,7 @@ func TestCmdAddFeed(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := cmdAddFeed(tt.opts)
+			err := cmdAddFeed(context.Background(), tt.opts)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("cmdAddFeed() error = %v, wantErr %v", err, tt.wantErr)
 			}
@@ -45,6 +60,61 @@ func TestCmdAddFeed(t *testing.T) {
 	}
 }
 
+func TestCmdAddFeedFetchFailureUndoesAdd(t *testing.T) {
+	t.Parallel()
+	configPath, _ := writeServeConfig(t)
+
+	// Loopback URLs are rejected by the crawler's SSRF protection, so the
+	// fetch fails without touching the network
+	var out bytes.Buffer
+	err := cmdAddFeed(context.Background(), AddFeedOptions{
+		URL:        "http://127.0.0.1:1/feed.xml",
+		ConfigPath: configPath,
+		Fetch:      true,
+		Output:     &out,
+		Logger:     logging.New("error"),
+	})
+	if err == nil || !strings.Contains(err.Error(), "feed not added") {
+		t.Fatalf("cmdAddFeed() error = %v, want feed not added", err)
+	}
+
+	_, repo, cleanup, err := openConfigAndRepo(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+	feeds, err := repo.GetFeeds(context.Background(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(feeds) != 0 {
+		t.Errorf("feed should have been removed after failed fetch, got %d feeds", len(feeds))
+	}
+}
+
+func TestCmdAddFeedWarnsOfDuplicates(t *testing.T) {
+	t.Parallel()
+	configPath, _ := writeServeConfig(t)
+
+	add := func(url string) string {
+		var out bytes.Buffer
+		err := cmdAddFeed(context.Background(), AddFeedOptions{URL: url, ConfigPath: configPath, Output: &out, Logger: logging.New("error")})
+		if err != nil {
+			t.Fatalf("cmdAddFeed(%q) error = %v", url, err)
+		}
+		return out.String()
+	}
+	if out := add("HTTPS://Example.com/feed?utm_source=newsletter"); !strings.Contains(out, "✓ Added feed: https://example.com/feed (ID:") || strings.Contains(out, "⚠") {
+		t.Errorf("first add output = %q, want the normalized URL and no warning", out)
+	}
+	out := add("http://www.example.com/feed/")
+	for _, want := range []string{"⚠ The planet already has what looks like the same feed:", "https://example.com/feed", "rp remove-feed URL"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("second add output = %q, want %q", out, want)
+		}
+	}
+}
+
 func TestCmdAddAll(t *testing.T) {
 	t.Parallel()
 	tests := []struct {
@@ -67,7 +137,7 @@ func TestCmdAddAll(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := cmdAddAll(tt.opts)
+			err := cmdAddAll(context.Background(), tt.opts)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("cmdAddAll() error = %v, wantErr %v", err, tt.wantErr)
 			}
@@ -75,6 +145,22 @@ func TestCmdAddAll(t *testing.T) {
 	}
 }
 
+// Ctrl+C cancels the command's context rather than killing rp, so a prompt
+// waiting for an answer must give up when it is cancelled
+func TestConfirmPromptCancelled(t *testing.T) {
+	t.Parallel()
+	input, w := io.Pipe()
+	defer w.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	var buf bytes.Buffer
+	confirmed, err := confirmPrompt(ctx, input, &buf, "Remove? (y/N): ")
+	if !errors.Is(err, context.Canceled) || confirmed {
+		t.Errorf("confirmPrompt() = %v, %v; want context.Canceled", confirmed, err)
+	}
+}
+
 func TestCmdRemoveFeed(t *testing.T) {
 	t.Parallel()
 	t.Run("missing URL", func(t *testing.T) {
@@ -85,7 +171,7 @@ func TestCmdRemoveFeed(t *testing.T) {
 			Output:     &buf,
 			Force:      true,
 		}
-		err := cmdRemoveFeed(opts)
+		err := cmdRemoveFeed(context.Background(), opts)
 		if err == nil {
 			t.Error("cmdRemoveFeed() expected error for missing URL, got nil")
 		}
@@ -157,7 +243,7 @@ path = %s
 			Force:      true,
 		}
 
-		if err := cmdRemoveFeed(opts); err != nil {
+		if err := cmdRemoveFeed(context.Background(), opts); err != nil {
 			t.Fatalf("cmdRemoveFeed() error = %v", err)
 		}
 
@@ -226,7 +312,7 @@ path = %s
 			Force:      true,
 		}
 
-		err = cmdRemoveFeed(opts)
+		err = cmdRemoveFeed(context.Background(), opts)
 		if err == nil {
 			t.Error("cmdRemoveFeed() expected error for non-existent feed, got nil")
 		}
@@ -298,7 +384,7 @@ path = %s
 			Force:      false,
 		}
 
-		if err := cmdRemoveFeed(opts); err != nil {
+		if err := cmdRemoveFeed(context.Background(), opts); err != nil {
 			t.Fatalf("cmdRemoveFeed() should succeed with 'y' input, got error: %v", err)
 		}
 
@@ -371,7 +457,7 @@ path = %s
 			Force:      false,
 		}
 
-		err = cmdRemoveFeed(opts)
+		err = cmdRemoveFeed(context.Background(), opts)
 		if err == nil {
 			t.Error("cmdRemoveFeed() should return error when user cancels")
 		}
@@ -447,7 +533,7 @@ path = %s
 			Force:      false,
 		}
 
-		if err := cmdRemoveFeed(opts); err != nil {
+		if err := cmdRemoveFeed(context.Background(), opts); err != nil {
 			t.Fatalf("cmdRemoveFeed() should succeed with 'yes' input, got error: %v", err)
 		}
 	})
@@ -513,7 +599,7 @@ path = %s
 			Force:      false,
 		}
 
-		err = cmdRemoveFeed(opts)
+		err = cmdRemoveFeed(context.Background(), opts)
 		if err == nil {
 			t.Error("cmdRemoveFeed() should return error in non-interactive mode without --force")
 		}
@@ -550,7 +636,7 @@ func TestCmdInit(t *testing.T) {
 		Output:     &buf,
 	}
 
-	if err := cmdInit(opts); err != nil {
+	if err := cmdInit(context.Background(), opts); err != nil {
 		t.Fatalf("cmdInit() error = %v", err)
 	}
 
@@ -605,7 +691,7 @@ func TestCmdInitWithFeedsFile(t *testing.T) {
 		Output:     &buf,
 	}
 
-	if err := cmdInit(opts); err != nil {
+	if err := cmdInit(context.Background(), opts); err != nil {
 		t.Fatalf("cmdInit() error = %v", err)
 	}
 
@@ -616,6 +702,203 @@ func TestCmdInitWithFeedsFile(t *testing.T) {
 	}
 }
 
+func TestCmdInitInteractive(t *testing.T) {
+	_, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	input := strings.Join([]string{
+		"Go Planet",                    // name
+		"not a url",                    // link (rejected)
+		"https://planet.example.com",   // link
+		"Gopher",                       // owner name
+		"",                             // owner email (default)
+		"5s",                           // schedule (rejected)
+		"",                             // no schedule
+		"neon",                         // theme (rejected)
+		"dark",                         // theme
+		"ftp://example.com/feed",       // feed (rejected)
+		"https://example.com/feed.xml", // feed
+		"https://example.com/feed.xml", // duplicate
+		"https://example.org/atom.xml", // feed
+		"https://example.net/",         // site with two feeds
+		"3",                            // feed choice (rejected)
+		"2",                            // feed choice
+		"https://example.net/empty",    // site with no feeds
+		"",                             // finish feeds
+		"",                             // no OPML file
+		"n",                            // skip first update
+	}, "\n") + "\n"
+
+	var buf bytes.Buffer
+	opts := InitOptions{
+		ConfigPath:  "config.ini",
+		Interactive: true,
+		Output:      &buf,
+		Input:       strings.NewReader(input),
+		Discover:    fakeDiscover,
+	}
+
+	if err := cmdInit(context.Background(), opts); err != nil {
+		t.Fatalf("cmdInit() error = %v\n%s", err, buf.String())
+	}
+
+	cfg, err := config.LoadFromFile("config.ini")
+	if err != nil {
+		t.Fatalf("LoadFromFile() error = %v", err)
+	}
+	if cfg.Planet.Name != "Go Planet" {
+		t.Errorf("Name = %q, want Go Planet", cfg.Planet.Name)
+	}
+	if cfg.Planet.Link != "https://planet.example.com" {
+		t.Errorf("Link = %q, want https://planet.example.com", cfg.Planet.Link)
+	}
+	if cfg.Planet.OwnerName != "Gopher" {
+		t.Errorf("OwnerName = %q, want Gopher", cfg.Planet.OwnerName)
+	}
+	if cfg.Planet.Template != "./themes/dark/template.html" {
+		t.Errorf("Template = %q, want ./themes/dark/template.html", cfg.Planet.Template)
+	}
+
+	repo, err := repository.New(cfg.Database.Path)
+	if err != nil {
+		t.Fatalf("repository.New() error = %v", err)
+	}
+	defer repo.Close()
+
+	feeds, err := repo.GetFeeds(context.Background(), false)
+	if err != nil {
+		t.Fatalf("GetFeeds() error = %v", err)
+	}
+	if len(feeds) != 3 {
+		t.Errorf("got %d feeds, want 3", len(feeds))
+	}
+	if _, err := repo.GetFeedByURL(context.Background(), "https://example.net/atom.xml"); err != nil {
+		t.Errorf("chosen feed of example.net was not added: %v", err)
+	}
+
+	output := buf.String()
+	for _, want := range []string{"planet URL must be", "at least 1m", "unknown theme", "Invalid feed URL", "Already added", "choose a number", "links to no feeds"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("output missing %q", want)
+		}
+	}
+}
+
+// fakeDiscover stands in for feed discovery: example.net/ is a site with two
+// feeds, example.net/empty a page with none, and any other URL is a feed
+func fakeDiscover(_ context.Context, url string) ([]discover.Feed, error) {
+	switch url {
+	case "https://example.net/":
+		return []discover.Feed{
+			{URL: "https://example.net/rss.xml", Type: "RSS"},
+			{URL: "https://example.net/atom.xml", Title: "Posts", Type: "Atom"},
+		}, nil
+	case "https://example.net/empty":
+		return nil, discover.ErrNoFeeds
+	}
+	return []discover.Feed{{URL: url, Type: "RSS"}}, nil
+}
+
+func TestCmdInitInteractiveScheduleAndOPML(t *testing.T) {
+	dir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	opmlDoc := `<?xml version="1.0"?>
+<opml version="2.0"><head><title>Feeds</title></head><body>
+<outline type="rss" text="Ada" xmlUrl="https://ada.example.com/feed.xml"/>
+<outline type="rss" text="Duplicate" xmlUrl="https://example.com/feed.xml"/>
+</body></opml>`
+	if err := os.WriteFile("feeds.opml", []byte(opmlDoc), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	input := strings.Join([]string{
+		"Go Planet",                    // name
+		"https://planet.example.com",   // link
+		"",                             // owner name
+		"",                             // owner email
+		"often",                        // schedule (rejected)
+		"1h",                           // schedule
+		"",                             // theme
+		"https://example.com/feed.xml", // feed
+		"",                             // finish feeds
+		"missing.opml",                 // OPML file (rejected)
+		"feeds.opml",                   // OPML file
+		"n",                            // skip first update
+	}, "\n") + "\n"
+
+	var buf bytes.Buffer
+	err := cmdInit(context.Background(), InitOptions{
+		ConfigPath:  "config.ini",
+		Interactive: true,
+		Output:      &buf,
+		Input:       strings.NewReader(input),
+		Discover:    fakeDiscover,
+		Service: ServiceOptions{
+			Kind:       serviceSystemd,
+			Dir:        filepath.Join(dir, "units"),
+			DryRun:     true,
+			Executable: "/usr/local/bin/rp",
+		},
+	})
+	if err != nil {
+		t.Fatalf("cmdInit() error = %v\n%s", err, buf.String())
+	}
+
+	cfg, err := config.LoadFromFile("config.ini")
+	if err != nil {
+		t.Fatalf("LoadFromFile() error = %v", err)
+	}
+	repo, err := repository.New(cfg.Database.Path)
+	if err != nil {
+		t.Fatalf("repository.New() error = %v", err)
+	}
+	defer repo.Close()
+	feeds, err := repo.GetFeeds(context.Background(), false)
+	if err != nil {
+		t.Fatalf("GetFeeds() error = %v", err)
+	}
+	if len(feeds) != 2 {
+		t.Errorf("got %d feeds, want the entered feed and one imported from OPML", len(feeds))
+	}
+
+	output := buf.String()
+	for _, want := range []string{"not a duration", "cannot read OPML file", "already exists"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("output missing %q", want)
+		}
+	}
+	// install-service does not support Windows, where the wizard only warns
+	if runtime.GOOS != "windows" && !strings.Contains(output, "OnUnitActiveSec=3600s") {
+		t.Errorf("output does not show the 1h systemd timer:\n%s", output)
+	}
+}
+
+func TestCmdInitInteractiveKeepsExistingConfig(t *testing.T) {
+	_, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	if err := os.WriteFile("config.ini", []byte("[planet]\nname = Existing\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	err := cmdInit(context.Background(), InitOptions{
+		ConfigPath:  "config.ini",
+		Interactive: true,
+		Output:      &buf,
+		Input:       strings.NewReader("n\n"),
+	})
+	if _, ok := err.(*ErrUserCancelled); !ok {
+		t.Fatalf("cmdInit() error = %v, want ErrUserCancelled", err)
+	}
+
+	data, _ := os.ReadFile("config.ini")
+	if !strings.Contains(string(data), "Existing") {
+		t.Error("existing config.ini was overwritten")
+	}
+}
+
 func TestCmdPrune(t *testing.T) {
 	t.Parallel()
 	var buf bytes.Buffer
@@ -762,6 +1045,42 @@ path = ` + dbPath + `
 			wantErr:    true,
 			wantOutput: "Template file not found",
 		},
+		{
+			name: "forbidden sanitize tag",
+			setup: func(t *testing.T) (string, func()) {
+				tmpDir := t.TempDir()
+				configPath := filepath.Join(tmpDir, "config.ini")
+				dbPath := filepath.Join(tmpDir, "planet.db")
+				outputDir := filepath.Join(tmpDir, "public")
+				if err := os.MkdirAll(outputDir, 0755); err != nil {
+					t.Fatal(err)
+				}
+
+				configContent := `[planet]
+name = Test Planet
+output_dir = ` + outputDir + `
+
+[database]
+path = ` + dbPath + `
+
+[sanitize]
+allow_tags = kbd, script
+`
+				if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+					t.Fatal(err)
+				}
+
+				repo, err := repository.New(dbPath)
+				if err != nil {
+					t.Fatal(err)
+				}
+				repo.Close()
+
+				return configPath, func() {}
+			},
+			wantErr:    true,
+			wantOutput: `tag "script" cannot be allowed`,
+		},
 		{
 			name: "valid configuration",
 			setup: func(t *testing.T) (string, func()) {
@@ -813,7 +1132,7 @@ path = ` + dbPath + `
 				Output:     &buf,
 			}
 
-			err := cmdVerify(opts)
+			err := cmdVerify(context.Background(), opts)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("cmdVerify() error = %v, wantErr %v", err, tt.wantErr)
 			}
@@ -892,6 +1211,55 @@ path = ` + dbPath + `
 	}
 }
 
+func TestCmdUpdateRunLock(t *testing.T) {
+	t.Parallel()
+	configPath, _ := writeServeConfig(t)
+	cfg, err := planet.LoadConfig(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := planet.OpenRepository(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+
+	ctx := context.Background()
+	if err := repo.AcquireLock(ctx, planet.RunLockName, "cron pid 42", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	// Another run in progress: skip successfully, or fail after waiting
+	var buf bytes.Buffer
+	opts := UpdateOptions{ConfigPath: configPath, Output: &buf, Logger: logging.New("error")}
+	if err := cmdUpdate(ctx, opts); err != nil {
+		t.Fatalf("cmdUpdate() while locked error = %v", err)
+	}
+	if out := buf.String(); !strings.Contains(out, "Skipping update: another rp run is in progress (cron pid 42") || strings.Contains(out, "Fetching feeds") {
+		t.Errorf("cmdUpdate() while locked output = %q", out)
+	}
+
+	opts.Wait = 50 * time.Millisecond
+	if err := cmdUpdate(ctx, opts); !errors.Is(err, planet.ErrRunInProgress) {
+		t.Errorf("cmdUpdate() with --wait while locked error = %v, want planet.ErrRunInProgress", err)
+	}
+
+	// Once released, the run goes ahead and gives the lock back afterwards
+	if err := repo.ReleaseLock(ctx, planet.RunLockName, "cron pid 42"); err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	if err := cmdUpdate(ctx, opts); err != nil {
+		t.Fatalf("cmdUpdate() after release error = %v", err)
+	}
+	if !strings.Contains(buf.String(), "Update complete") {
+		t.Errorf("cmdUpdate() after release output = %q", buf.String())
+	}
+	if lock, err := repo.GetLock(ctx, planet.RunLockName); err != nil || lock != nil {
+		t.Errorf("lock after the run = %+v, %v; want released", lock, err)
+	}
+}
+
 func TestCmdFetch(t *testing.T) {
 	t.Parallel()
 	tests := []struct {
@@ -1116,22 +1484,14 @@ path = ` + dbPath + `
 
 	// Capture log output
 	var logBuf bytes.Buffer
-	originalLogOutput := log.Writer()
-	originalLogFlags := log.Flags()
-	log.SetOutput(&logBuf)
-	log.SetFlags(0) // Remove timestamp for easier testing
-	defer func() {
-		log.SetOutput(originalLogOutput)
-		log.SetFlags(originalLogFlags)
-	}()
 
-	// Run cmdFetch (which calls fetchFeeds with signal handling)
+	// Run cmdFetch (which listens for signals while it fetches)
 	var outputBuf bytes.Buffer
 	opts := FetchOptions{
 		ConfigPath: configPath,
 		Verbose:    false,
 		Output:     &outputBuf,
-		Logger:     logging.New("info"),
+		Logger:     logging.NewWithOptions(logging.Options{Level: "info", Output: &logBuf}),
 	}
 
 	err = cmdFetch(context.Background(), opts)
@@ -1141,15 +1501,2039 @@ path = ` + dbPath + `
 
 	// Check that log output does NOT contain the spurious signal message
 	logOutput := logBuf.String()
-	if strings.Contains(logOutput, "Received signal <nil>") {
-		t.Errorf("Log output contains spurious 'Received signal <nil>' message during normal shutdown.\nLog output:\n%s", logOutput)
+	if strings.Contains(logOutput, "Received signal") {
+		t.Errorf("Log output contains spurious 'Received signal' message during normal shutdown.\nLog output:\n%s", logOutput)
 	}
 
 	// Verify expected log messages are present
-	if !strings.Contains(logOutput, "Fetching 1 feeds") {
-		t.Errorf("Log output should contain 'Fetching 1 feeds', got:\n%s", logOutput)
+	if !strings.Contains(logOutput, `msg="Fetching feeds" feeds=1`) {
+		t.Errorf("Log output should contain 'Fetching feeds' with feeds=1, got:\n%s", logOutput)
 	}
 	if !strings.Contains(logOutput, "Completed fetching all feeds") {
 		t.Errorf("Log output should contain 'Completed fetching all feeds', got:\n%s", logOutput)
 	}
 }
+
+// writeServeConfig writes a minimal config with an empty database for serve tests
+func writeServeConfig(t *testing.T) (configPath, outputDir string) {
+	t.Helper()
+	tmpDir := t.TempDir()
+	configPath = filepath.Join(tmpDir, "config.ini")
+	outputDir = filepath.Join(tmpDir, "public")
+
+	configContent := `[planet]
+name = Serve Planet
+link = https://example.com
+output_dir = ` + outputDir + `
+
+[database]
+path = ` + filepath.Join(tmpDir, "planet.db") + `
+`
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return configPath, outputDir
+}
+
+func TestServeHandler(t *testing.T) {
+	t.Parallel()
+	outputDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(outputDir, "index.html"), []byte("<h1>planet</h1>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	state := &serveState{started: time.Now(), metrics: metrics.NewRegistry()}
+	handler := newServeHandler(outputDir, state, nil)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	if rec := get("/"); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "planet") {
+		t.Errorf("GET / = %d %q, want index.html", rec.Code, rec.Body.String())
+	}
+
+	rec := get("/healthz")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"status":"starting"`) {
+		t.Errorf("GET /healthz before refresh = %d %s", rec.Code, rec.Body.String())
+	}
+
+	state.record(time.Now(), nil, time.Now().Add(time.Hour))
+	rec = get("/healthz")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"status":"ok"`) {
+		t.Errorf("GET /healthz after refresh = %d %s", rec.Code, rec.Body.String())
+	}
+
+	state.record(time.Now(), fmt.Errorf("database locked"), time.Now().Add(time.Hour))
+	rec = get("/healthz")
+	if rec.Code != http.StatusServiceUnavailable || !strings.Contains(rec.Body.String(), "database locked") {
+		t.Errorf("GET /healthz after failure = %d %s", rec.Code, rec.Body.String())
+	}
+
+	rec = get("/metrics")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "rogue_planet_runs_total 0\n") {
+		t.Errorf("GET /metrics = %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestRunRefreshLoopOnce(t *testing.T) {
+	t.Parallel()
+	configPath, outputDir := writeServeConfig(t)
+
+	p, err := openPlanet(configPath, nil, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	state := &serveState{started: time.Now()}
+	opts := ServeOptions{Interval: 0, Logger: logging.New("error")}
+	runRefreshLoop(context.Background(), p, opts, state)
+
+	if _, err := os.Stat(filepath.Join(outputDir, "index.html")); err != nil {
+		t.Errorf("refresh should generate index.html: %v", err)
+	}
+
+	code, health := state.health(time.Now())
+	if code != http.StatusOK || health.Refreshes != 1 || health.LastRefresh == "" {
+		t.Errorf("health = %d %+v, want one successful refresh", code, health)
+	}
+}
+
+func TestCmdServeShutsDownOnCancel(t *testing.T) {
+	t.Parallel()
+	configPath, _ := writeServeConfig(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var buf bytes.Buffer
+	done := make(chan error, 1)
+	go func() {
+		done <- cmdServe(ctx, ServeOptions{
+			ConfigPath: configPath,
+			Addr:       "127.0.0.1:0",
+			Interval:   time.Hour,
+			Output:     &buf,
+			Logger:     logging.New("error"),
+		})
+	}()
+
+	time.Sleep(200 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("cmdServe() error = %v", err)
+		}
+	case <-time.After(15 * time.Second):
+		t.Fatal("cmdServe() did not shut down after cancel")
+	}
+}
+
+func TestCmdServeInvalidAddr(t *testing.T) {
+	t.Parallel()
+	configPath, _ := writeServeConfig(t)
+
+	err := cmdServe(context.Background(), ServeOptions{
+		ConfigPath: configPath,
+		Addr:       "not-an-address",
+		Output:     &bytes.Buffer{},
+		Logger:     logging.New("error"),
+	})
+	if err == nil || !strings.Contains(err.Error(), "failed to listen") {
+		t.Errorf("cmdServe() error = %v, want listen error", err)
+	}
+}
+
+func TestCmdGenerateTag(t *testing.T) {
+	t.Parallel()
+	configPath, outputDir := writeServeConfig(t)
+
+	cfg, err := planet.LoadConfig(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := planet.OpenRepository(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	id, err := repo.AddFeed(ctx, "https://feed.invalid/atom.xml", "Blog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	entries := []struct {
+		title      string
+		categories []string
+	}{
+		{"Go generics deep dive", []string{"Go", "Programming"}},
+		{"Holiday photos", []string{"Travel"}},
+	}
+	for i, e := range entries {
+		if err := repo.UpsertEntry(ctx, &repository.Entry{
+			FeedID: id, EntryID: e.title, Title: e.title, Link: fmt.Sprintf("https://feed.invalid/%d", i),
+			Published: now, Updated: now, FirstSeen: now, Categories: e.categories,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	repo.Close()
+
+	var out bytes.Buffer
+	if err := cmdGenerate(ctx, GenerateOptions{ConfigPath: configPath, Tags: []string{"go"}, Output: &out}); err != nil {
+		t.Fatalf("cmdGenerate() error = %v", err)
+	}
+	if !strings.Contains(out.String(), "tags: go") {
+		t.Errorf("output should mention the tag filter, got %q", out.String())
+	}
+
+	index, err := os.ReadFile(filepath.Join(outputDir, "index.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(index), "Go generics deep dive") || !strings.Contains(string(index), "<li>Programming</li>") {
+		t.Error("index.html should include the tagged entry and its categories")
+	}
+	if strings.Contains(string(index), "Holiday photos") {
+		t.Error("index.html should not include entries without the tag")
+	}
+}
+
+func TestCmdGenerateMaxEntriesPerFeed(t *testing.T) {
+	t.Parallel()
+	configPath, outputDir := writeServeConfig(t)
+	f, err := os.OpenFile(configPath, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Fprint(f, "\n[planet]\nmax_entries_per_feed = 2\n\n[feed https://quiet.invalid/atom.xml]\nmax_entries = 3\n")
+	f.Close()
+
+	cfg, err := planet.LoadConfig(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := planet.OpenRepository(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	now := time.Now()
+	for _, feed := range []string{"flood", "quiet"} {
+		id, err := repo.AddFeed(ctx, "https://"+feed+".invalid/atom.xml", feed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := range 5 {
+			published := now.Add(-time.Duration(i) * time.Hour)
+			if err := repo.UpsertEntry(ctx, &repository.Entry{
+				FeedID: id, EntryID: fmt.Sprintf("%s-%d", feed, i), Title: fmt.Sprintf("%s post %d", feed, i),
+				Link: fmt.Sprintf("https://%s.invalid/%d", feed, i), Published: published, Updated: published, FirstSeen: now,
+			}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	repo.Close()
+
+	if err := cmdGenerate(ctx, GenerateOptions{ConfigPath: configPath, Output: io.Discard}); err != nil {
+		t.Fatalf("cmdGenerate() error = %v", err)
+	}
+	index, err := os.ReadFile(filepath.Join(outputDir, "index.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		feed  string
+		shown int
+	}{
+		{"flood", 2}, // planet-wide limit
+		{"quiet", 3}, // the feed's own limit
+	} {
+		for i := range 5 {
+			title := fmt.Sprintf("%s post %d", tt.feed, i)
+			if got, want := strings.Contains(string(index), title), i < tt.shown; got != want {
+				t.Errorf("index.html has %q = %v, want %v", title, got, want)
+			}
+		}
+	}
+}
+
+func TestCmdGenerateAuthorMapping(t *testing.T) {
+	t.Parallel()
+	configPath, outputDir := writeServeConfig(t)
+	f, err := os.OpenFile(configPath, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Fprint(f, "\n[author John Smith]\nalias = jsmith\nalias = john@example.com\n")
+	f.Close()
+
+	cfg, err := planet.LoadConfig(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := planet.OpenRepository(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	now := time.Now()
+	for i, author := range []string{"jsmith", "john@example.com"} {
+		id, err := repo.AddFeed(ctx, fmt.Sprintf("https://feed%d.invalid/atom.xml", i), "Blog")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := repo.UpsertEntry(ctx, &repository.Entry{
+			FeedID: id, EntryID: "post", Title: "Post", Link: fmt.Sprintf("https://feed%d.invalid/post", i),
+			Author: author, Published: now, Updated: now, FirstSeen: now,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	repo.Close()
+
+	if err := cmdGenerate(ctx, GenerateOptions{ConfigPath: configPath, Output: io.Discard}); err != nil {
+		t.Fatalf("cmdGenerate() error = %v", err)
+	}
+	index, err := os.ReadFile(filepath.Join(outputDir, "index.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(index), "By John Smith"); n != 2 {
+		t.Errorf("index.html credits John Smith %d times, want 2", n)
+	}
+	if strings.Contains(string(index), "jsmith") || strings.Contains(string(index), "john@example.com") {
+		t.Error("index.html should show the canonical name, not aliases")
+	}
+}
+
+func TestCmdGenerateTranslate(t *testing.T) {
+	t.Parallel()
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		var req translate.Request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		resp := translate.Response{}
+		for _, text := range req.Texts {
+			resp.Texts = append(resp.Texts, strings.ReplaceAll(text, "Hola", "Hello"))
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	configPath, outputDir := writeServeConfig(t)
+	f, err := os.OpenFile(configPath, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Fprintf(f, "\n[translate]\ntarget_language = en\nurl = %s\n\n[feed https://feed1.invalid/atom.xml]\ntranslate = false\n", server.URL)
+	f.Close()
+
+	cfg, err := planet.LoadConfig(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := planet.OpenRepository(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	now := time.Now()
+	for i, title := range []string{"Hola &amp; mundo", "Hola otra vez"} {
+		id, err := repo.AddFeed(ctx, fmt.Sprintf("https://feed%d.invalid/atom.xml", i), "Blog")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := repo.UpsertEntry(ctx, &repository.Entry{
+			FeedID: id, EntryID: "post", Title: title, Link: fmt.Sprintf("https://feed%d.invalid/post", i),
+			Published: now, Updated: now, FirstSeen: now,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	repo.Close()
+
+	for range 2 {
+		if err := cmdGenerate(ctx, GenerateOptions{ConfigPath: configPath, Output: io.Discard}); err != nil {
+			t.Fatalf("cmdGenerate() error = %v", err)
+		}
+	}
+	index, err := os.ReadFile(filepath.Join(outputDir, "index.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	page := string(index)
+	if !strings.Contains(page, "Hello &amp; mundo") || !strings.Contains(page, `translate="no">Hola &amp; mundo</p>`) {
+		t.Error("index.html should show the translated title with the original below it")
+	}
+	if !strings.Contains(page, "Hola otra vez") || strings.Contains(page, "Hello otra vez") {
+		t.Error("index.html should leave entries of a feed with translate = false alone")
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("translator was asked %d times, want once with later runs served from the cache", n)
+	}
+}
+
+func TestCmdStatusFeed(t *testing.T) {
+	t.Parallel()
+	configPath, _ := writeServeConfig(t)
+
+	cfg, err := planet.LoadConfig(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := planet.OpenRepository(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	feedURL := "https://feed.invalid/atom.xml"
+	id, err := repo.AddFeed(ctx, feedURL, "Diagnostics Blog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	if err := repo.UpdateFeedCache(ctx, id, `"abc123"`, "Mon, 03 Mar 2025 10:00:00 GMT", now); err != nil {
+		t.Fatal(err)
+	}
+	for _, rec := range []repository.FetchLogEntry{
+		{FeedID: id, FetchedAt: now.Add(-2 * time.Hour), StatusCode: 500, Error: "server error"},
+		{FeedID: id, FetchedAt: now.Add(-time.Hour), StatusCode: 304},
+	} {
+		if err := repo.RecordFetch(ctx, rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		published := now.Add(-time.Duration(i) * 48 * time.Hour)
+		if err := repo.UpsertEntry(ctx, &repository.Entry{
+			FeedID: id, EntryID: fmt.Sprintf("e%d", i), Title: fmt.Sprintf("Entry %d", i), Link: fmt.Sprintf("https://feed.invalid/%d", i),
+			Published: published, Updated: published, FirstSeen: now,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	repo.Close()
+
+	var out bytes.Buffer
+	if err := cmdStatus(context.Background(), StatusOptions{ConfigPath: configPath, Feed: feedURL, Output: &out}); err != nil {
+		t.Fatalf("cmdStatus() error = %v", err)
+	}
+	for _, want := range []string{
+		"Diagnostics Blog",
+		"Last status:   304",
+		`ETag:          "abc123"`,
+		"Last-Modified: Mon, 03 Mar 2025 10:00:00 GMT",
+		"500  server error",
+		"Total:         3",
+		"Avg interval:  2d 0h (over 3 entries)",
+		"adaptive_scheduling is off",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, out.String())
+		}
+	}
+
+	err = cmdStatus(context.Background(), StatusOptions{ConfigPath: configPath, Feed: "https://missing.invalid/feed", Output: &out})
+	if err == nil || !strings.Contains(err.Error(), "feed not found") {
+		t.Errorf("cmdStatus() for unknown feed error = %v, want feed not found", err)
+	}
+}
+
+func TestCmdHistory(t *testing.T) {
+	t.Parallel()
+	configPath, _ := writeServeConfig(t)
+
+	cfg, err := planet.LoadConfig(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := planet.OpenRepository(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	feedURL := "https://feed.invalid/atom.xml"
+	id, err := repo.AddFeed(ctx, feedURL, "History Blog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	for _, rec := range []repository.FetchLogEntry{
+		{FeedID: id, FetchedAt: now.Add(-3 * time.Hour), Error: "dial tcp: connection refused", Duration: 2 * time.Second},
+		{FeedID: id, FetchedAt: now.Add(-2 * time.Hour), StatusCode: 200, WireBytes: 2048, Duration: 350 * time.Millisecond, EntriesAdded: 4},
+		{FeedID: id, FetchedAt: now.Add(-time.Hour), StatusCode: 304, Duration: 80 * time.Millisecond},
+	} {
+		if err := repo.RecordFetch(ctx, rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := repo.AddFeed(ctx, "https://quiet.invalid/feed", "Quiet"); err != nil {
+		t.Fatal(err)
+	}
+	repo.Close()
+
+	var out bytes.Buffer
+	if err := cmdHistory(context.Background(), HistoryOptions{ConfigPath: configPath, Feed: feedURL, Limit: 2, Output: &out}); err != nil {
+		t.Fatalf("cmdHistory() error = %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 5 {
+		t.Fatalf("output has %d lines, want a title, a blank line, a header, and 2 rows:\n%s", len(lines), out.String())
+	}
+	for i, want := range []string{"304", "200"} {
+		if fields := strings.Fields(lines[3+i]); fields[2] != want {
+			t.Errorf("row %d status = %q, want %q (newest first):\n%s", i, fields[2], want, out.String())
+		}
+	}
+	if !strings.Contains(lines[4], "2.0 KB") || !strings.Contains(lines[4], "350ms") || !strings.HasSuffix(strings.TrimSpace(lines[4]), " 4") {
+		t.Errorf("row for the 200 fetch = %q, want bytes, duration, and 4 entries added", lines[4])
+	}
+	if strings.Contains(out.String(), "connection refused") {
+		t.Errorf("--limit 2 showed the oldest fetch:\n%s", out.String())
+	}
+
+	out.Reset()
+	if err := cmdHistory(context.Background(), HistoryOptions{ConfigPath: configPath, Feed: "https://quiet.invalid/feed", Limit: 50, Output: &out}); err != nil {
+		t.Fatalf("cmdHistory() error = %v", err)
+	}
+	if !strings.Contains(out.String(), "No fetches recorded") {
+		t.Errorf("output for a feed never fetched = %q", out.String())
+	}
+
+	err = cmdHistory(context.Background(), HistoryOptions{ConfigPath: configPath, Feed: "https://missing.invalid/feed", Limit: 50, Output: &out})
+	if err == nil || !strings.Contains(err.Error(), "feed not found") {
+		t.Errorf("cmdHistory() for unknown feed error = %v, want feed not found", err)
+	}
+}
+
+func TestCmdRollback(t *testing.T) {
+	t.Parallel()
+	configPath, outputDir := writeServeConfig(t)
+	ctx := context.Background()
+
+	var out bytes.Buffer
+	if err := cmdRollback(RollbackOptions{ConfigPath: configPath, Output: &out}); err == nil {
+		t.Error("cmdRollback() before any generation should fail")
+	}
+
+	// Two generations, then a file added to the live site by hand
+	for range 2 {
+		if err := cmdGenerate(ctx, GenerateOptions{ConfigPath: configPath, Output: io.Discard}); err != nil {
+			t.Fatalf("cmdGenerate() error = %v", err)
+		}
+	}
+	marker := filepath.Join(outputDir, "marker.txt")
+	if err := os.WriteFile(marker, []byte("new"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cmdRollback(RollbackOptions{ConfigPath: configPath, Output: &out}); err != nil {
+		t.Fatalf("cmdRollback() error = %v", err)
+	}
+	if !strings.Contains(out.String(), "Restored the previous generation") {
+		t.Errorf("output = %q", out.String())
+	}
+	if _, err := os.Stat(marker); !os.IsNotExist(err) {
+		t.Errorf("marker.txt should be gone after rollback, stat error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "index.html")); err != nil {
+		t.Errorf("index.html missing after rollback: %v", err)
+	}
+}
+
+func TestWeeklyCounts(t *testing.T) {
+	t.Parallel()
+	// Wednesday; the current week starts Monday 2025-03-03
+	now := time.Date(2025, 3, 5, 12, 0, 0, 0, time.Local)
+	times := []time.Time{
+		time.Date(2025, 3, 5, 9, 0, 0, 0, time.Local),  // This week
+		time.Date(2025, 3, 3, 0, 0, 0, 0, time.Local),  // Monday, this week
+		time.Date(2025, 3, 2, 23, 0, 0, 0, time.Local), // Sunday, last week
+		time.Date(2025, 2, 24, 8, 0, 0, 0, time.Local), // Last week
+		time.Date(2025, 2, 1, 8, 0, 0, 0, time.Local),  // Before the window
+		time.Date(2025, 3, 12, 8, 0, 0, 0, time.Local), // Future
+	}
+
+	weeks := weeklyCounts(times, now, 3)
+	if len(weeks) != 3 {
+		t.Fatalf("got %d weeks, want 3", len(weeks))
+	}
+	want := []struct {
+		start string
+		count int
+	}{
+		{"2025-02-17", 0},
+		{"2025-02-24", 2},
+		{"2025-03-03", 2},
+	}
+	for i, w := range want {
+		if got := weeks[i].start.Format("2006-01-02"); got != w.start || weeks[i].count != w.count {
+			t.Errorf("week %d = %s/%d, want %s/%d", i, got, weeks[i].count, w.start, w.count)
+		}
+	}
+}
+
+func TestFormatTransfer(t *testing.T) {
+	t.Parallel()
+	got := formatTransfer(repository.TransferStats{
+		Fetches:      4,
+		WireBytes:    256 * 1024,
+		DecodedBytes: 1024 * 1024,
+		ByEncoding:   map[string]int{"br": 2, "gzip": 1, "": 1},
+		ByProto:      map[string]int{"HTTP/2.0": 3, "HTTP/1.1": 1},
+	})
+	want := "256.0 KB in 4 fetches (last 24h), 1.0 MB decoded (75% saved by compression); 2 br, 1 gzip, 3 over HTTP/2"
+	if got != want {
+		t.Errorf("formatTransfer() = %q, want %q", got, want)
+	}
+}
+
+func TestCmdBulkFeeds(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "planet.db")
+	configPath := filepath.Join(tmpDir, "config.ini")
+	configContent := fmt.Sprintf("[planet]\nname = Test Planet\n\n[database]\npath = %s\n", dbPath)
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to create config: %v", err)
+	}
+
+	repo, err := repository.New(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create repository: %v", err)
+	}
+	ctx := context.Background()
+	add := func(url, title string, errors int) int64 {
+		id, err := repo.AddFeed(ctx, url, title)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for range errors {
+			_ = repo.UpdateFeedError(ctx, id, "HTTP 500")
+		}
+		return id
+	}
+	spamID := add("https://spam.blogspot.com/feeds/posts/default", "Spam", 0)
+	_ = repo.UpsertEntry(ctx, &repository.Entry{FeedID: spamID, EntryID: "1", Title: "Buy", Published: time.Now()})
+	add("https://other.example.com/feed", "A BlogSpot mirror", 0)
+	add("https://flaky.example.com/feed", "Flaky", 3)
+	add("https://broken.example.com/feed", "Broken", 12)
+	add("https://keep.example.com/feed", "Keep", 0)
+	repo.Close()
+
+	count := func() (active, total int) {
+		repo, err := repository.New(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer repo.Close()
+		feeds, _ := repo.GetFeeds(ctx, false)
+		for _, f := range feeds {
+			if f.Active {
+				active++
+			}
+		}
+		return active, len(feeds)
+	}
+	run := func(action, input string, sel planet.Selection, dryRun bool) (string, error) {
+		var buf bytes.Buffer
+		err := cmdBulkFeeds(context.Background(), BulkFeedsOptions{Action: action, ConfigPath: configPath, Selection: sel, DryRun: dryRun,
+			Input: strings.NewReader(input), Output: &buf})
+		return buf.String(), err
+	}
+
+	blogspot := planet.Selection{Match: []string{"blogspot"}}
+	out, err := run("remove", "", blogspot, true)
+	if err != nil || !strings.Contains(out, "2 feeds match") || !strings.Contains(out, "Spam, 1 entries") || !strings.Contains(out, "Would remove 2 feeds") {
+		t.Errorf("dry run: error = %v, output:\n%s", err, out)
+	}
+	if _, total := count(); total != 5 {
+		t.Errorf("dry run removed feeds: %d left", total)
+	}
+
+	if _, err := run("remove", "n\n", blogspot, false); err == nil {
+		t.Error("declined removal: want ErrUserCancelled")
+	}
+	out, err = run("remove", "y\n", blogspot, false)
+	if err != nil || !strings.Contains(out, "Removed 2 feeds (1 entries deleted)") {
+		t.Errorf("remove: error = %v, output:\n%s", err, out)
+	}
+	if _, total := count(); total != 3 {
+		t.Errorf("after removal %d feeds, want 3", total)
+	}
+
+	out, err = run("deactivate", "", planet.Selection{MinErrors: 11}, false)
+	if err != nil || !strings.Contains(out, "broken.example.com") || strings.Contains(out, "flaky") || !strings.Contains(out, "Deactivated 1 feeds") {
+		t.Errorf("deactivate: error = %v, output:\n%s", err, out)
+	}
+	if active, _ := count(); active != 2 {
+		t.Errorf("after deactivation %d active feeds, want 2", active)
+	}
+
+	out, err = run("reactivate", "", planet.Selection{Inactive: true}, false)
+	if err != nil || !strings.Contains(out, "Reactivated 1 feeds") {
+		t.Errorf("reactivate: error = %v, output:\n%s", err, out)
+	}
+	if active, _ := count(); active != 3 {
+		t.Errorf("after reactivation %d active feeds, want 3", active)
+	}
+
+	out, err = run("remove", "", planet.Selection{Match: []string{"nothing"}}, false)
+	if err != nil || !strings.Contains(out, "No feeds match") {
+		t.Errorf("empty selection: error = %v, output:\n%s", err, out)
+	}
+}
+
+func TestCmdRenameAndEditFeed(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "planet.db")
+	configPath := filepath.Join(tmpDir, "config.ini")
+	oldURL, newURL := "http://example.com/rss", "https://example.com/feed.xml"
+	configContent := fmt.Sprintf("[planet]\nname = Test Planet\n\n[database]\npath = %s\n\n[feed %s]\ngroup = Friends\n", dbPath, oldURL)
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to create config: %v", err)
+	}
+
+	repo, err := repository.New(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create repository: %v", err)
+	}
+	ctx := context.Background()
+	id, _ := repo.AddFeed(ctx, oldURL, "Example")
+	_, _ = repo.AddFeed(ctx, "https://other.example.com/feed", "Other")
+	if err := repo.UpsertEntry(ctx, &repository.Entry{FeedID: id, EntryID: "1", Title: "Post", Published: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	repo.Close()
+
+	var buf bytes.Buffer
+	if err := cmdRenameFeed(context.Background(), RenameFeedOptions{OldURL: oldURL, NewURL: newURL, ConfigPath: configPath, Output: &buf}); err != nil {
+		t.Fatalf("cmdRenameFeed() error = %v", err)
+	}
+	for _, want := range []string{"Renamed feed", "Kept its 1 entries", "[feed " + newURL + "]"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("rename-feed output missing %q:\n%s", want, buf.String())
+		}
+	}
+
+	for _, opts := range []RenameFeedOptions{
+		{OldURL: oldURL, NewURL: "https://example.org/feed"},       // Old URL is gone
+		{OldURL: newURL, NewURL: "https://other.example.com/feed"}, // Taken by another feed
+		{OldURL: newURL, NewURL: "file:///etc/passwd"},             // Invalid
+	} {
+		opts.ConfigPath, opts.Output = configPath, io.Discard
+		if err := cmdRenameFeed(context.Background(), opts); err == nil {
+			t.Errorf("cmdRenameFeed(%s → %s) succeeded, want an error", opts.OldURL, opts.NewURL)
+		}
+	}
+
+	title, inactive := "Example Blog", false
+	buf.Reset()
+	err = cmdEditFeed(context.Background(), EditFeedOptions{URL: newURL, ConfigPath: configPath, Title: &title, Tags: []string{"go", "web"}, Active: &inactive, Output: &buf})
+	if err != nil {
+		t.Fatalf("cmdEditFeed() error = %v", err)
+	}
+	for _, want := range []string{"Title: Example Blog", "Tags: go, web", "not fetched"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("edit-feed output missing %q:\n%s", want, buf.String())
+		}
+	}
+
+	repo, err = repository.New(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+	feed, err := repo.GetFeedByURL(ctx, newURL)
+	if err != nil {
+		t.Fatalf("renamed feed not found: %v", err)
+	}
+	if feed.ID != id || feed.Title != title || !feed.CustomTitle || feed.Active {
+		t.Errorf("feed = %+v, want ID %d titled %q, inactive", feed, id, title)
+	}
+	if tags, _ := repo.GetFeedCategories(ctx, id); !reflect.DeepEqual(tags, []string{"go", "web"}) {
+		t.Errorf("tags = %v, want [go web]", tags)
+	}
+
+	if err := cmdEditFeed(context.Background(), EditFeedOptions{URL: oldURL, ConfigPath: configPath, Title: &title, Output: io.Discard}); err == nil {
+		t.Error("cmdEditFeed() of a missing feed succeeded")
+	}
+}
+
+func TestCmdReactivateFeed(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "planet.db")
+	configPath := filepath.Join(tmpDir, "config.ini")
+	configContent := fmt.Sprintf("[planet]\nname = Test Planet\n\n[database]\npath = %s\n", dbPath)
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to create config: %v", err)
+	}
+
+	repo, err := repository.New(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create repository: %v", err)
+	}
+	ctx := context.Background()
+	deadURL := "https://dead.example.com/feed"
+	deadID, _ := repo.AddFeed(ctx, deadURL, "Dead Feed")
+	_, _ = repo.AddFeed(ctx, "https://ok.example.com/feed", "Working Feed")
+	for i := 0; i < 3; i++ {
+		if err := repo.UpdateFeedError(ctx, deadID, "connection refused"); err != nil {
+			t.Fatalf("UpdateFeedError() error = %v", err)
+		}
+	}
+	if err := repo.DeactivateFeed(ctx, deadID); err != nil {
+		t.Fatalf("DeactivateFeed() error = %v", err)
+	}
+	goneID, _ := repo.AddFeed(ctx, "https://gone.example.com/feed", "Gone Feed")
+	_ = repo.UpdateFeedError(ctx, goneID, "gone (HTTP 410)")
+	if err := repo.MarkFeedGone(ctx, goneID, time.Now()); err != nil {
+		t.Fatalf("MarkFeedGone() error = %v", err)
+	}
+	repo.Close()
+
+	// list-feeds --errors shows only the failing feed
+	var buf bytes.Buffer
+	if err := cmdListFeeds(context.Background(), ListFeedsOptions{ConfigPath: configPath, Errors: true, Output: &buf}); err != nil {
+		t.Fatalf("cmdListFeeds() error = %v", err)
+	}
+	output := buf.String()
+	for _, want := range []string{"Feeds with fetch errors (2)", deadURL, "Status: inactive", "Consecutive errors: 3", "Status: gone since"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("list-feeds --errors output missing %q:\n%s", want, output)
+		}
+	}
+	if strings.Contains(output, "ok.example.com") {
+		t.Errorf("list-feeds --errors listed a working feed:\n%s", output)
+	}
+
+	buf.Reset()
+	if err := cmdReactivateFeed(context.Background(), ReactivateFeedOptions{URL: deadURL, ConfigPath: configPath, Output: &buf}); err != nil {
+		t.Fatalf("cmdReactivateFeed() error = %v", err)
+	}
+	if !strings.Contains(buf.String(), "Reactivated feed") {
+		t.Errorf("Unexpected output: %s", buf.String())
+	}
+
+	buf.Reset()
+	if err := cmdListFeeds(context.Background(), ListFeedsOptions{ConfigPath: configPath, Errors: true, Output: &buf}); err != nil {
+		t.Fatalf("cmdListFeeds() error = %v", err)
+	}
+	if strings.Contains(buf.String(), deadURL) || !strings.Contains(buf.String(), "Feeds with fetch errors (1)") {
+		t.Errorf("Feed still listed after reactivation:\n%s", buf.String())
+	}
+
+	if err := cmdReactivateFeed(context.Background(), ReactivateFeedOptions{URL: "https://missing.example.com/feed", ConfigPath: configPath, Output: &buf}); err == nil {
+		t.Error("cmdReactivateFeed() expected error for unknown feed")
+	}
+}
+
+func TestCmdDoctor(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.ini")
+	dbPath := filepath.Join(tmpDir, "planet.db")
+	templatePath := filepath.Join(tmpDir, "template.html")
+
+	writeConfig := func(template string) {
+		t.Helper()
+		content := `[planet]
+name = Test Planet
+link = https://example.com
+output_dir = ` + filepath.Join(tmpDir, "public") + `
+template = ` + template + `
+
+[database]
+path = ` + dbPath + `
+
+[feed https://self-signed.example.com/feed.xml]
+insecure_skip_verify = true
+`
+		if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeConfig("")
+
+	ctx := context.Background()
+	repo, err := repository.New(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.AddFeed(ctx, "https://blog.example.com/feed.xml", "Blog"); err != nil {
+		t.Fatal(err)
+	}
+	badID, err := repo.AddFeed(ctx, "ftp://files.example.com/feed.xml", "FTP")
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo.Close()
+
+	var buf bytes.Buffer
+	err = cmdDoctor(ctx, DoctorOptions{ConfigPath: configPath, Offline: true, Output: &buf})
+	if err == nil {
+		t.Fatal("cmdDoctor() succeeded with an ftp feed URL")
+	}
+	output := buf.String()
+	for _, want := range []string{
+		"⚠ TLS certificates of https://self-signed.example.com/feed.xml are not verified",
+		"✓ integrity check passed",
+		"✓ no orphaned rows",
+		`✗ feed URL "ftp://files.example.com/feed.xml" cannot be fetched`,
+		"→ rp remove-feed ftp://files.example.com/feed.xml",
+		"- skipped (--offline)",
+		"✓ built-in template renders sample entries",
+		"Found 1 problem.",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("output missing %q:\n%s", want, output)
+		}
+	}
+
+	// Remove the bad feed without cascading, leaving orphans to --fix
+	repo, err = repository.New(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.UpsertEntry(ctx, &repository.Entry{FeedID: badID, EntryID: "e"}); err != nil {
+		t.Fatal(err)
+	}
+	repo.Close()
+	db, err := sql.Open("sqlite3", dbPath+"?_foreign_keys=off")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("DELETE FROM feeds WHERE id = ?", badID); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	buf.Reset()
+	if err := cmdDoctor(ctx, DoctorOptions{ConfigPath: configPath, Offline: true, Output: &buf}); err == nil {
+		t.Error("cmdDoctor() succeeded with orphaned entries")
+	}
+	if !strings.Contains(buf.String(), "✗ 1 entry left behind by removed feeds") {
+		t.Errorf("output does not report the orphan:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	if err := cmdDoctor(ctx, DoctorOptions{ConfigPath: configPath, Fix: true, Offline: true, Output: &buf}); err != nil {
+		t.Errorf("cmdDoctor(--fix) error = %v\n%s", err, buf.String())
+	}
+	if !strings.Contains(buf.String(), "✓ deleted 1 entry") || !strings.Contains(buf.String(), "No problems found.") {
+		t.Errorf("output after --fix:\n%s", buf.String())
+	}
+
+	// A template that only fails when rendered
+	if err := os.WriteFile(templatePath, []byte(`{{range .Entries}}{{.NoSuchField}}{{end}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	writeConfig(templatePath)
+	buf.Reset()
+	if err := cmdDoctor(ctx, DoctorOptions{ConfigPath: configPath, Offline: true, Output: &buf}); err == nil {
+		t.Error("cmdDoctor() succeeded with a broken template")
+	}
+	if !strings.Contains(buf.String(), "✗ template fails to render sample entries") {
+		t.Errorf("output does not report the template:\n%s", buf.String())
+	}
+}
+
+func TestDoctorFeedSections(t *testing.T) {
+	t.Parallel()
+	cfg := config.Default()
+	cfg.FeedSettings = map[string]config.FeedConfig{
+		"https://blog.example.com/feed.xml": {},
+		"https://gone.example.com/feed.xml": {},
+	}
+	cfg.FeedFilters = map[string]config.FilterConfig{"http://www.blog.example.com/feed.xml": {}}
+	feeds := []repository.Feed{{URL: "https://blog.example.com/feed.xml"}}
+
+	var buf bytes.Buffer
+	report := &doctorReport{out: &buf}
+	doctorFeedSections(cfg, feeds, report)
+	output := buf.String()
+	for _, want := range []string{
+		"⚠ [feed https://gone.example.com/feed.xml] matches no feed",
+		"→ remove the section, or add the feed with 'rp add-feed https://gone.example.com/feed.xml'",
+		"⚠ [filters http://www.blog.example.com/feed.xml] matches no feed",
+		"→ rename it to [filters https://blog.example.com/feed.xml]",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("output missing %q:\n%s", want, output)
+		}
+	}
+	if strings.Contains(output, "[feed https://blog.example.com/feed.xml]") {
+		t.Errorf("warned of a section that matches a feed:\n%s", output)
+	}
+	if report.problems != 0 {
+		t.Errorf("doctorFeedSections() counted %d problems, want warnings only", report.problems)
+	}
+}
+
+func TestCheckEndpoints(t *testing.T) {
+	t.Parallel()
+	lookup := func(ctx context.Context, host string) ([]string, error) {
+		if host == "nxdomain.example" {
+			return nil, errors.New("no such host")
+		}
+		return []string{"192.0.2.1"}, nil
+	}
+	dial := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if addr == "down.example:443" {
+			return nil, errors.New("connection refused")
+		}
+		client, server := net.Pipe()
+		server.Close()
+		return client, nil
+	}
+
+	addrs := []string{"up.example:443", "nxdomain.example:443", "down.example:443", "192.0.2.7:80"}
+	results := checkEndpoints(context.Background(), addrs, 2, lookup, dial)
+	want := []struct {
+		resolved bool
+		failed   bool
+	}{{true, false}, {false, true}, {true, true}, {true, false}}
+	for i, r := range results {
+		if r.addr != addrs[i] || r.resolved != want[i].resolved || (r.err != nil) != want[i].failed {
+			t.Errorf("result %d = %+v, want resolved=%v failed=%v", i, r, want[i].resolved, want[i].failed)
+		}
+	}
+
+	var buf bytes.Buffer
+	report := &doctorReport{out: &buf}
+	doctorNetwork(results, report)
+	if report.problems != 2 || !strings.Contains(buf.String(), "cannot resolve nxdomain.example:443") ||
+		!strings.Contains(buf.String(), "cannot connect to down.example:443") {
+		t.Errorf("doctorNetwork() reported %d problems:\n%s", report.problems, buf.String())
+	}
+
+	// When nothing can be reached, the machine's network is the problem
+	buf.Reset()
+	report = &doctorReport{out: &buf}
+	doctorNetwork(results[1:3], report)
+	if report.problems != 1 || !strings.Contains(buf.String(), "none of the 2 feed hosts could be reached") {
+		t.Errorf("doctorNetwork() with every host failing:\n%s", buf.String())
+	}
+}
+
+func TestInstallSystemdService(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	var ran []string
+	var out bytes.Buffer
+	opts := ServiceOptions{
+		Kind:   serviceSystemd,
+		Name:   "planet",
+		Dir:    dir,
+		Output: &out,
+		Runner: func(ctx context.Context, input, name string, args ...string) (string, error) {
+			ran = append(ran, name+" "+strings.Join(args, " "))
+			return "", nil
+		},
+	}
+	spec := serviceSpec{Name: "planet", Executable: "/usr/local/bin/rp", ConfigPath: "/srv/my planet/config.ini", Interval: time.Hour}
+
+	if err := installSystemd(context.Background(), opts, spec); err != nil {
+		t.Fatalf("installSystemd() error = %v", err)
+	}
+	service, err := os.ReadFile(filepath.Join(dir, "planet.service"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	timer, err := os.ReadFile(filepath.Join(dir, "planet.timer"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"Type=oneshot", "WorkingDirectory=/srv/my planet\n", `ExecStart=/usr/local/bin/rp update --config "/srv/my planet/config.ini"`} {
+		if !strings.Contains(string(service), want) {
+			t.Errorf("service unit missing %q:\n%s", want, service)
+		}
+	}
+	// The user manager has no network-online.target to wait for
+	if strings.Contains(string(service), "network-online.target") {
+		t.Errorf("user service unit refers to network-online.target:\n%s", service)
+	}
+	for _, want := range []string{"OnUnitActiveSec=3600s", "Unit=planet.service", "WantedBy=timers.target"} {
+		if !strings.Contains(string(timer), want) {
+			t.Errorf("timer unit missing %q:\n%s", want, timer)
+		}
+	}
+	if got := strings.Join(ran, "; "); got != "systemctl --user daemon-reload; systemctl --user enable --now planet.timer" {
+		t.Errorf("ran %q", got)
+	}
+
+	ran = nil
+	if err := uninstallSystemd(context.Background(), opts); err != nil {
+		t.Fatalf("uninstallSystemd() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "planet.timer")); !os.IsNotExist(err) {
+		t.Error("uninstallSystemd() kept the timer")
+	}
+	if !strings.Contains(strings.Join(ran, "; "), "disable --now planet.timer") {
+		t.Errorf("uninstallSystemd() did not disable the timer: %q", ran)
+	}
+	if err := uninstallSystemd(context.Background(), opts); err == nil {
+		t.Error("uninstallSystemd() with nothing installed should fail")
+	}
+}
+
+func TestInstallCronService(t *testing.T) {
+	t.Parallel()
+	crontab := "MAILTO=me@example.com\n0 0 * * 0 backup\n"
+	var out bytes.Buffer
+	opts := ServiceOptions{
+		Kind:   serviceCron,
+		Name:   "rogue-planet",
+		Output: &out,
+		Runner: func(ctx context.Context, input, name string, args ...string) (string, error) {
+			switch strings.Join(args, " ") {
+			case "-l":
+				return crontab, nil
+			case "-":
+				crontab = input
+			case "-r":
+				crontab = ""
+			}
+			return "", nil
+		},
+	}
+	spec := serviceSpec{Name: "rogue-planet", Executable: "/opt/rp", ConfigPath: "/home/o'neil/100%/config.ini", Interval: 15 * time.Minute}
+
+	// Installing twice replaces the first entry
+	for i := 0; i < 2; i++ {
+		if err := installCron(context.Background(), opts, spec); err != nil {
+			t.Fatalf("installCron() error = %v", err)
+		}
+	}
+	want := `MAILTO=me@example.com
+0 0 * * 0 backup
+*/15 * * * * cd '/home/o'\''neil/100\%' && '/opt/rp' update --config '/home/o'\''neil/100\%/config.ini' >> '/home/o'\''neil/100\%/rogue-planet.log' 2>&1 # rp:rogue-planet
+`
+	if crontab != want {
+		t.Errorf("crontab =\n%s\nwant\n%s", crontab, want)
+	}
+
+	spec.Interval = 45 * time.Minute
+	if err := installCron(context.Background(), opts, spec); err == nil {
+		t.Error("installCron() accepted an interval cron cannot express")
+	}
+
+	if err := uninstallCron(context.Background(), opts); err != nil {
+		t.Fatalf("uninstallCron() error = %v", err)
+	}
+	if crontab != "MAILTO=me@example.com\n0 0 * * 0 backup\n" {
+		t.Errorf("crontab after uninstall = %q", crontab)
+	}
+	if err := uninstallCron(context.Background(), opts); err == nil {
+		t.Error("uninstallCron() with no entry should fail")
+	}
+}
+
+func TestCronSchedule(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		interval time.Duration
+		want     string
+	}{
+		{time.Minute, "* * * * *"},
+		{20 * time.Minute, "*/20 * * * *"},
+		{time.Hour, "0 * * * *"},
+		{6 * time.Hour, "0 */6 * * *"},
+		{24 * time.Hour, "0 0 * * *"},
+		{7 * time.Minute, ""},
+		{90 * time.Minute, ""},
+		{48 * time.Hour, ""},
+	}
+	for _, tt := range tests {
+		got, err := cronSchedule(tt.interval)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("cronSchedule(%v) = %q, want an error", tt.interval, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("cronSchedule(%v) = %q, %v; want %q", tt.interval, got, err, tt.want)
+		}
+	}
+}
+
+func TestLaunchdPlist(t *testing.T) {
+	t.Parallel()
+	plist := launchdPlist(serviceSpec{Name: "rogue-planet", Executable: "/usr/local/bin/rp", ConfigPath: "/Users/a&b/planet/config.ini", Interval: 30 * time.Minute})
+	for _, want := range []string{
+		"<string>com.github.adewale.rogue-planet</string>",
+		"<string>/Users/a&amp;b/planet/config.ini</string>",
+		"<key>StartInterval</key>\n    <integer>1800</integer>",
+		"<string>/Users/a&amp;b/planet/rogue-planet.log</string>",
+	} {
+		if !strings.Contains(plist, want) {
+			t.Errorf("plist missing %q:\n%s", want, plist)
+		}
+	}
+}
+
+func TestCmdConfig(t *testing.T) {
+	t.Parallel()
+	configPath, _ := writeServeConfig(t)
+
+	var out bytes.Buffer
+	if err := cmdConfig(ConfigOptions{ConfigPath: configPath, Action: "set", Key: "planet.days", Value: "21", Output: &out}); err != nil {
+		t.Fatalf("cmdConfig(set) error = %v", err)
+	}
+	cfg, err := planet.LoadConfig(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Planet.Days != 21 {
+		t.Errorf("days = %d after config set, want 21", cfg.Planet.Days)
+	}
+
+	out.Reset()
+	if err := cmdConfig(ConfigOptions{ConfigPath: configPath, Action: "get", Key: "planet.days", Output: &out}); err != nil {
+		t.Fatalf("cmdConfig(get) error = %v", err)
+	}
+	if out.String() != "21\n" {
+		t.Errorf("config get printed %q, want %q", out.String(), "21\n")
+	}
+
+	if err := cmdConfig(ConfigOptions{ConfigPath: configPath, Action: "get", Key: "notify.webhook_url", Output: &out}); err == nil {
+		t.Error("config get of an unset key should fail")
+	}
+	if err := cmdConfig(ConfigOptions{ConfigPath: configPath, Action: "set", Key: "planet.concurrent_fetches", Value: "500", Output: &out}); err == nil {
+		t.Error("config set accepted an out-of-range value")
+	}
+}
+
+func TestCmdPlanets(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	registry := filepath.Join(t.TempDir(), "planets.ini")
+	work, _ := writeServeConfig(t)
+	home, _ := writeServeConfig(t)
+
+	var out bytes.Buffer
+	opts := PlanetsOptions{Registry: registry, Output: &out}
+	for _, add := range []struct{ name, config string }{{"work", work}, {"home", home}} {
+		opts.Action, opts.Name, opts.ConfigPath = "add", add.name, add.config
+		if err := cmdPlanets(ctx, opts); err != nil {
+			t.Fatalf("planets add %s error = %v", add.name, err)
+		}
+	}
+	opts.Name, opts.ConfigPath = "work", home
+	if err := cmdPlanets(ctx, opts); err == nil {
+		t.Error("planets add accepted a name already registered")
+	}
+	opts.Name, opts.ConfigPath = "nope", filepath.Join(t.TempDir(), "missing.ini")
+	if err := cmdPlanets(ctx, opts); err == nil {
+		t.Error("planets add accepted a config that does not exist")
+	}
+
+	out.Reset()
+	opts.Action = "list"
+	if err := cmdPlanets(ctx, opts); err != nil {
+		t.Fatalf("planets list error = %v", err)
+	}
+	for _, want := range []string{"Registered planets (2)", "  home\n", "Config: " + work, "Output: " + filepath.Dir(work)} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("planets list missing %q:\n%s", want, out.String())
+		}
+	}
+
+	// One planet failing does not stop the others
+	var ran []string
+	opts.Action = "update"
+	opts.UpdateArgs = []string{"--force"}
+	opts.Runner = func(ctx context.Context, dir string, out io.Writer, args ...string) error {
+		ran = append(ran, dir+": "+strings.Join(args, " "))
+		if dir == filepath.Dir(home) {
+			return errors.New("exit status 1")
+		}
+		return nil
+	}
+	out.Reset()
+	err := cmdPlanets(ctx, opts)
+	if err == nil || !strings.Contains(err.Error(), "planet home") {
+		t.Errorf("planets update error = %v, want the failing planet", err)
+	}
+	want := []string{
+		filepath.Dir(home) + ": update --config " + home + " --force",
+		filepath.Dir(work) + ": update --config " + work + " --force",
+	}
+	if strings.Join(ran, "\n") != strings.Join(want, "\n") {
+		t.Errorf("ran\n%s\nwant\n%s", strings.Join(ran, "\n"), strings.Join(want, "\n"))
+	}
+	if !strings.Contains(out.String(), "Updated 1 of 2 planets") {
+		t.Errorf("planets update output:\n%s", out.String())
+	}
+
+	args, dir, err := selectPlanet("work", []string{"status", "--feed", "x"}, registry)
+	if err != nil {
+		t.Fatalf("selectPlanet() error = %v", err)
+	}
+	if strings.Join(args, " ") != "status --config "+work+" --feed x" || dir != filepath.Dir(work) {
+		t.Errorf("selectPlanet() = %q, %q", args, dir)
+	}
+	for _, bad := range [][]string{{"play", "status"}, {"work"}, {"work", "init"}, {"", "status"}} {
+		if _, _, err := selectPlanet(bad[0], bad[1:], registry); err == nil {
+			t.Errorf("selectPlanet(%q) should fail", bad)
+		}
+	}
+
+	opts.Action, opts.Name = "remove", "home"
+	if err := cmdPlanets(ctx, opts); err != nil {
+		t.Fatalf("planets remove error = %v", err)
+	}
+	if _, err := os.Stat(home); err != nil {
+		t.Error("planets remove deleted the planet's files")
+	}
+	planets, _ := config.LoadRegistry(registry)
+	if len(planets) != 1 || planets[0].Name != "work" {
+		t.Errorf("registry after remove = %+v", planets)
+	}
+}
+
+func TestCmdHideEntry(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "planet.db")
+	configPath := filepath.Join(tmpDir, "config.ini")
+	configContent := fmt.Sprintf("[planet]\nname = Test Planet\n\n[database]\npath = %s\n", dbPath)
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to create config: %v", err)
+	}
+
+	repo, err := repository.New(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create repository: %v", err)
+	}
+	ctx := context.Background()
+	feedID, _ := repo.AddFeed(ctx, "https://example.com/feed", "Example")
+	now := time.Now()
+	for _, id := range []string{"private", "public"} {
+		entry := &repository.Entry{FeedID: feedID, EntryID: "urn:" + id, Title: "Tom &amp; Jerry " + id, Link: "https://example.com/" + id, Published: now, Updated: now, FirstSeen: now}
+		if err := repo.UpsertEntry(ctx, entry); err != nil {
+			t.Fatalf("UpsertEntry() error = %v", err)
+		}
+	}
+	repo.Close()
+
+	var buf bytes.Buffer
+	if err := cmdHideEntry(context.Background(), EntryOptions{Ref: "https://example.com/private", ConfigPath: configPath, Output: &buf}); err != nil {
+		t.Fatalf("cmdHideEntry() error = %v", err)
+	}
+	if !strings.Contains(buf.String(), "✓ Hidden: Tom & Jerry private") {
+		t.Errorf("hide-entry output:\n%s", buf.String())
+	}
+	if err := cmdHideEntry(context.Background(), EntryOptions{Ref: "https://example.com/missing", ConfigPath: configPath, Output: &buf}); err == nil {
+		t.Error("cmdHideEntry() of an unknown entry should fail")
+	}
+
+	buf.Reset()
+	if err := cmdListHidden(context.Background(), ListHiddenOptions{ConfigPath: configPath, Output: &buf}); err != nil {
+		t.Fatalf("cmdListHidden() error = %v", err)
+	}
+	for _, want := range []string{"Hidden entries (1)", "ID: urn:private", "Feed: https://example.com/feed"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("list-hidden output missing %q:\n%s", want, buf.String())
+		}
+	}
+
+	buf.Reset()
+	if err := cmdUnhideEntry(context.Background(), EntryOptions{Ref: "urn:private", ConfigPath: configPath, Output: &buf}); err != nil {
+		t.Fatalf("cmdUnhideEntry() error = %v", err)
+	}
+	if err := cmdUnhideEntry(context.Background(), EntryOptions{Ref: "urn:private", ConfigPath: configPath, Output: &buf}); err == nil {
+		t.Error("cmdUnhideEntry() of an entry that is not hidden should fail")
+	}
+	buf.Reset()
+	if err := cmdListHidden(context.Background(), ListHiddenOptions{ConfigPath: configPath, Output: &buf}); err != nil || !strings.Contains(buf.String(), "No hidden entries") {
+		t.Errorf("list-hidden after unhiding = %q, %v", buf.String(), err)
+	}
+}
+
+func TestCmdShowEntry(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "planet.db")
+	configPath := filepath.Join(tmpDir, "config.ini")
+	configContent := fmt.Sprintf("[planet]\nname = Test Planet\n\n[database]\npath = %s\n", dbPath)
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to create config: %v", err)
+	}
+
+	repo, err := repository.New(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create repository: %v", err)
+	}
+	repo.SetRevisionRetention(repository.RevisionRetention{MaxPerEntry: 5})
+	ctx := context.Background()
+	feedID, _ := repo.AddFeed(ctx, "https://example.com/feed", "Example")
+	now := time.Now()
+	entry := &repository.Entry{FeedID: feedID, EntryID: "urn:policy", Title: "Policy", Link: "https://example.com/policy", Published: now, Updated: now, FirstSeen: now}
+	for i, content := range []string{
+		"<p>We never share your data.</p><p>Contact us.</p>",
+		"<p>We rarely share your data.</p><p>Contact us.</p>",
+	} {
+		entry.Content, entry.ContentHash = content, fmt.Sprint(i+1)
+		if err := repo.UpsertEntry(ctx, entry); err != nil {
+			t.Fatalf("UpsertEntry() error = %v", err)
+		}
+	}
+	repo.Close()
+
+	var buf bytes.Buffer
+	if err := cmdShowEntry(ctx, ShowEntryOptions{Ref: "urn:policy", ConfigPath: configPath, Output: &buf}); err != nil {
+		t.Fatalf("cmdShowEntry() error = %v", err)
+	}
+	if !strings.Contains(buf.String(), "Feed: https://example.com/feed") || strings.Contains(buf.String(), "Revisions") {
+		t.Errorf("show-entry output:\n%s", buf.String())
+	}
+
+	// Revisions were kept although the config doesn't ask for them, so
+	// they are shown
+	buf.Reset()
+	if err := cmdShowEntry(ctx, ShowEntryOptions{Ref: "https://example.com/policy", Revisions: true, ConfigPath: configPath, Output: &buf}); err != nil {
+		t.Fatalf("cmdShowEntry() error = %v", err)
+	}
+	for _, want := range []string{"Revisions (1, oldest first)", "- We never share your data.", "+ We rarely share your data."} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("show-entry --revisions output missing %q:\n%s", want, buf.String())
+		}
+	}
+	if strings.Contains(buf.String(), "Contact us.") {
+		t.Errorf("show-entry --revisions shows unchanged lines:\n%s", buf.String())
+	}
+
+	if err := cmdShowEntry(ctx, ShowEntryOptions{Ref: "urn:missing", ConfigPath: configPath, Output: &buf}); err == nil {
+		t.Error("cmdShowEntry() of an unknown entry should fail")
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		before, after string
+		want          []string
+	}{
+		{"<p>a</p><p>b</p>", "<p>a</p><p>b</p>", nil},
+		{"<p>a</p><p>b</p><p>c</p>", "<p>a</p><p>c</p>", []string{"- b"}},
+		{"<p>a</p>", "<p>a</p><ul><li>b &amp; c</li></ul>", []string{"+ b & c"}},
+		{"<p>a <em>b</em></p>", "<p>a <strong>b</strong>\n</p>", nil},
+		{"<p>a</p><p>b</p>", "<p>b</p><p>c</p>", []string{"- a", "+ c"}},
+	}
+	for _, tt := range tests {
+		got := diffLines(contentLines(tt.before), contentLines(tt.after))
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("diffLines(%q, %q) = %q, want %q", tt.before, tt.after, got, tt.want)
+		}
+	}
+}
+
+func TestCmdReadState(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "planet.db")
+	configPath := filepath.Join(tmpDir, "config.ini")
+	configContent := fmt.Sprintf("[planet]\nname = Test Planet\n\n[database]\npath = %s\n", dbPath)
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to create config: %v", err)
+	}
+
+	repo, err := repository.New(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create repository: %v", err)
+	}
+	ctx := context.Background()
+	feedID, _ := repo.AddFeed(ctx, "https://example.com/feed", "Example")
+	otherID, _ := repo.AddFeed(ctx, "https://other.example.com/feed", "Other")
+	now := time.Now()
+	for feed, id := range map[int64]string{feedID: "first", otherID: "second"} {
+		entry := &repository.Entry{FeedID: feed, EntryID: "urn:" + id, Title: "Post " + id, Link: "https://example.com/" + id, Published: now, Updated: now, FirstSeen: now}
+		if err := repo.UpsertEntry(ctx, entry); err != nil {
+			t.Fatalf("UpsertEntry() error = %v", err)
+		}
+	}
+	repo.Close()
+
+	listUnread := func() string {
+		t.Helper()
+		var buf bytes.Buffer
+		if err := cmdListUnread(context.Background(), ListUnreadOptions{ConfigPath: configPath, Limit: 1, Output: &buf}); err != nil {
+			t.Fatalf("cmdListUnread() error = %v", err)
+		}
+		return buf.String()
+	}
+
+	if out := listUnread(); !strings.Contains(out, "newest 1 of 2") {
+		t.Errorf("list-unread output:\n%s", out)
+	}
+
+	var buf bytes.Buffer
+	if err := cmdMarkRead(context.Background(), MarkReadOptions{Ref: "urn:first", ConfigPath: configPath, Output: &buf}); err != nil {
+		t.Fatalf("cmdMarkRead() error = %v", err)
+	}
+	if !strings.Contains(buf.String(), "✓ Read: Post first") {
+		t.Errorf("mark-read output:\n%s", buf.String())
+	}
+	if err := cmdMarkRead(context.Background(), MarkReadOptions{Ref: "urn:missing", ConfigPath: configPath, Output: &buf}); err == nil {
+		t.Error("cmdMarkRead() of an unknown entry should fail")
+	}
+	if out := listUnread(); !strings.Contains(out, "Unread entries (1)") || !strings.Contains(out, "Post second") || !strings.Contains(out, "Feed: Other") {
+		t.Errorf("list-unread output after mark-read:\n%s", out)
+	}
+
+	if err := cmdMarkUnread(context.Background(), EntryOptions{Ref: "urn:first", ConfigPath: configPath, Output: &buf}); err != nil {
+		t.Fatalf("cmdMarkUnread() error = %v", err)
+	}
+	if err := cmdMarkUnread(context.Background(), EntryOptions{Ref: "urn:first", ConfigPath: configPath, Output: &buf}); err == nil {
+		t.Error("cmdMarkUnread() of an unread entry should fail")
+	}
+
+	buf.Reset()
+	if err := cmdMarkRead(context.Background(), MarkReadOptions{All: true, Feed: "https://other.example.com/feed", ConfigPath: configPath, Output: &buf}); err != nil || !strings.Contains(buf.String(), "Marked 1 entries read") {
+		t.Errorf("mark-read --all --feed = %q, %v", buf.String(), err)
+	}
+	buf.Reset()
+	if err := cmdMarkRead(context.Background(), MarkReadOptions{All: true, ConfigPath: configPath, Output: &buf}); err != nil || !strings.Contains(buf.String(), "Marked 1 entries read") {
+		t.Errorf("mark-read --all = %q, %v", buf.String(), err)
+	}
+	if out := listUnread(); !strings.Contains(out, "No unread entries") {
+		t.Errorf("list-unread output after mark-read --all:\n%s", out)
+	}
+
+	// Stars
+	buf.Reset()
+	if err := cmdStarEntry(context.Background(), EntryOptions{Ref: "https://example.com/second", ConfigPath: configPath, Output: &buf}); err != nil || !strings.Contains(buf.String(), "✓ Starred: Post second") {
+		t.Errorf("star-entry = %q, %v", buf.String(), err)
+	}
+	buf.Reset()
+	if err := cmdListStarred(context.Background(), ListStarredOptions{ConfigPath: configPath, Output: &buf}); err != nil || !strings.Contains(buf.String(), "Starred entries (1)") {
+		t.Errorf("list-starred = %q, %v", buf.String(), err)
+	}
+	if err := cmdUnstarEntry(context.Background(), EntryOptions{Ref: "urn:second", ConfigPath: configPath, Output: &buf}); err != nil {
+		t.Fatalf("cmdUnstarEntry() error = %v", err)
+	}
+	if err := cmdUnstarEntry(context.Background(), EntryOptions{Ref: "urn:second", ConfigPath: configPath, Output: &buf}); err == nil {
+		t.Error("cmdUnstarEntry() of an entry that is not starred should fail")
+	}
+}
+
+func TestCmdDigest(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "planet.db")
+	configPath := filepath.Join(tmpDir, "config.ini")
+	configContent := fmt.Sprintf("[planet]\nname = Test Planet\n\n[database]\npath = %s\n", dbPath)
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to create config: %v", err)
+	}
+
+	repo, err := repository.New(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create repository: %v", err)
+	}
+	ctx := context.Background()
+	feedID, _ := repo.AddFeed(ctx, "https://example.com/feed", "Example")
+	now := time.Now()
+	entry := &repository.Entry{FeedID: feedID, EntryID: "urn:new", Title: "New post", Link: "https://example.com/new", Published: now, Updated: now, FirstSeen: now}
+	if err := repo.UpsertEntry(ctx, entry); err != nil {
+		t.Fatalf("UpsertEntry() error = %v", err)
+	}
+	repo.Close()
+
+	var buf bytes.Buffer
+	if err := cmdDigest(ctx, DigestOptions{ConfigPath: configPath, Since: time.Hour, Output: &buf}); err != nil {
+		t.Fatalf("cmdDigest() error = %v", err)
+	}
+	if out := buf.String(); !strings.HasPrefix(out, "Subject: Test Planet: 1 new post\n") || !strings.Contains(out, "New post\nExample, ") {
+		t.Errorf("digest output:\n%s", out)
+	}
+
+	htmlPath := filepath.Join(tmpDir, "digest.html")
+	buf.Reset()
+	if err := cmdDigest(ctx, DigestOptions{ConfigPath: configPath, Since: time.Hour, HTML: true, OutputFile: htmlPath, Output: &buf}); err != nil {
+		t.Fatalf("cmdDigest(--html) error = %v", err)
+	}
+	if html, _ := os.ReadFile(htmlPath); !strings.Contains(string(html), `<a href="https://example.com/new"`) {
+		t.Errorf("digest.html:\n%s", html)
+	}
+
+	if err := cmdDigest(ctx, DigestOptions{ConfigPath: configPath, Since: time.Hour, Send: true, Output: &buf}); err == nil || !strings.Contains(err.Error(), "smtp_addr") {
+		t.Errorf("cmdDigest(--send) without SMTP error = %v", err)
+	}
+}
+
+func TestCmdPinEntry(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "planet.db")
+	configPath := filepath.Join(tmpDir, "config.ini")
+	configContent := fmt.Sprintf("[planet]\nname = Test Planet\n\n[database]\npath = %s\n", dbPath)
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to create config: %v", err)
+	}
+
+	repo, err := repository.New(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create repository: %v", err)
+	}
+	ctx := context.Background()
+	feedID, _ := repo.AddFeed(ctx, "https://example.com/feed", "Example")
+	now := time.Now()
+	entry := &repository.Entry{FeedID: feedID, EntryID: "urn:classic", Title: "Classic", Link: "https://example.com/classic", Published: now, Updated: now, FirstSeen: now}
+	if err := repo.UpsertEntry(ctx, entry); err != nil {
+		t.Fatalf("UpsertEntry() error = %v", err)
+	}
+	repo.Close()
+
+	var buf bytes.Buffer
+	if err := cmdPinEntry(context.Background(), EntryOptions{Ref: "https://example.com/classic", ConfigPath: configPath, Output: &buf}); err != nil {
+		t.Fatalf("cmdPinEntry() error = %v", err)
+	}
+	if !strings.Contains(buf.String(), "✓ Pinned: Classic") {
+		t.Errorf("pin-entry output:\n%s", buf.String())
+	}
+	if err := cmdPinEntry(context.Background(), EntryOptions{Ref: "https://example.com/missing", ConfigPath: configPath, Output: &buf}); err == nil {
+		t.Error("cmdPinEntry() of an unknown entry should fail")
+	}
+
+	buf.Reset()
+	if err := cmdListPinned(context.Background(), ListPinnedOptions{ConfigPath: configPath, Output: &buf}); err != nil {
+		t.Fatalf("cmdListPinned() error = %v", err)
+	}
+	for _, want := range []string{"Pinned entries (1)", "ID: urn:classic", "Pinned: "} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("list-pinned output missing %q:\n%s", want, buf.String())
+		}
+	}
+
+	buf.Reset()
+	if err := cmdUnpinEntry(context.Background(), EntryOptions{Ref: "urn:classic", ConfigPath: configPath, Output: &buf}); err != nil {
+		t.Fatalf("cmdUnpinEntry() error = %v", err)
+	}
+	if err := cmdUnpinEntry(context.Background(), EntryOptions{Ref: "urn:classic", ConfigPath: configPath, Output: &buf}); err == nil {
+		t.Error("cmdUnpinEntry() of an entry that is not pinned should fail")
+	}
+	buf.Reset()
+	if err := cmdListPinned(context.Background(), ListPinnedOptions{ConfigPath: configPath, Output: &buf}); err != nil || !strings.Contains(buf.String(), "No pinned entries") {
+		t.Errorf("list-pinned after unpinning = %q, %v", buf.String(), err)
+	}
+}
+
+func TestCmdValidateFeed(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	good := filepath.Join(dir, "good.xml")
+	bad := filepath.Join(dir, "bad.xml")
+	files := map[string]string{
+		good: `<?xml version="1.0" encoding="UTF-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom"><title>Blog</title><id>urn:blog</id><updated>2026-01-02T00:00:00Z</updated>
+<link href="https://example.com/"/>
+<entry><title>Post</title><id>urn:post</id><updated>2026-01-02T00:00:00Z</updated><link href="https://example.com/posts/1"/><content type="html">&lt;p&gt;Hi there&lt;/p&gt;</content></entry>
+</feed>`,
+		bad: `<rss version="2.0"><channel><title>Blog</title><item><title>Post</title><pubDate>soon</pubDate></item></channel></rss>`,
+	}
+	for path, content := range files {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	noConfig := filepath.Join(dir, "config.ini")
+
+	var buf bytes.Buffer
+	err := cmdValidateFeed(context.Background(), ValidateFeedOptions{Source: good, BaseURL: "https://example.com/feed.atom", ConfigPath: noConfig, Output: &buf})
+	if err != nil {
+		t.Fatalf("cmdValidateFeed() error = %v\n%s", err, buf.String())
+	}
+	for _, want := range []string{"Format:   Atom 1.0", "Link:      https://example.com/posts/1", "Content:   2 words", "✓ No problems found"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, buf.String())
+		}
+	}
+
+	buf.Reset()
+	err = cmdValidateFeed(context.Background(), ValidateFeedOptions{Source: bad, ConfigPath: noConfig, Output: &buf})
+	if err == nil {
+		t.Fatal("cmdValidateFeed() of an invalid feed should fail")
+	}
+	for _, want := range []string{"✗ feed: RSS channel has no description", `✗ entry 1: published date "soon" doesn't parse`, "Found 2 errors"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, buf.String())
+		}
+	}
+
+	if err := cmdValidateFeed(context.Background(), ValidateFeedOptions{Source: filepath.Join(dir, "missing.xml"), ConfigPath: noConfig, Output: &buf}); err == nil {
+		t.Error("cmdValidateFeed() of a missing file should fail")
+	}
+}
+
+func TestCmdRecordFixtures(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/atom+xml")
+		fmt.Fprintf(w, `<feed xmlns="http://www.w3.org/2005/Atom"><title>Blog</title><id>urn:blog</id>
+<entry><title>Post</title><id>urn:post</id><updated>%s</updated><link href="https://example.com/posts/1"/></entry>
+</feed>`, time.Now().UTC().Format(time.RFC3339))
+	}))
+	defer server.Close()
+	feedURL := server.URL + "/feed.atom"
+
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.ini")
+	fixtures := filepath.Join(tmpDir, "fixtures")
+	configContent := `[planet]
+name = Fixture Planet
+allow_hosts = 127.0.0.1
+
+[database]
+path = ` + filepath.Join(tmpDir, "planet.db") + `
+`
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := planet.LoadConfig(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := planet.OpenRepository(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.AddFeed(context.Background(), feedURL, "Blog"); err != nil {
+		t.Fatal(err)
+	}
+	repo.Close()
+
+	var buf bytes.Buffer
+	if err := cmdRecordFixtures(context.Background(), RecordFixturesOptions{ConfigPath: configPath, Dir: fixtures, Output: &buf}); err != nil {
+		t.Fatalf("cmdRecordFixtures() error = %v\n%s", err, buf.String())
+	}
+	if !strings.Contains(buf.String(), "✓ "+feedURL) {
+		t.Errorf("output missing the recorded feed:\n%s", buf.String())
+	}
+	if _, err := os.Stat(filepath.Join(fixtures, "127.0.0.1-"+strings.TrimPrefix(server.URL, "http://127.0.0.1:")+"-feed.atom.xml")); err != nil {
+		t.Errorf("fixture body: %v", err)
+	}
+
+	// The fixtures replace the network
+	server.Close()
+	buf.Reset()
+	err = cmdFetch(context.Background(), FetchOptions{ConfigPath: configPath, Fixtures: fixtures, Output: &buf, Logger: logging.New("error")})
+	if err != nil {
+		t.Fatalf("cmdFetch() with fixtures error = %v", err)
+	}
+	repo, err = planet.OpenRepository(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+	if n, err := repo.CountEntries(context.Background()); err != nil || n != 1 {
+		t.Errorf("CountEntries() after replaying fixtures = %d, %v, want 1", n, err)
+	}
+
+	buf.Reset()
+	err = cmdRecordFixtures(context.Background(), RecordFixturesOptions{ConfigPath: configPath, Feeds: []string{feedURL}, Dir: fixtures, Output: &buf})
+	if err == nil || !strings.Contains(buf.String(), "✗ "+feedURL) {
+		t.Errorf("cmdRecordFixtures() of an unreachable feed = %v\n%s", err, buf.String())
+	}
+}
+
+func TestCmdExport(t *testing.T) {
+	t.Parallel()
+	configPath, _ := writeServeConfig(t)
+	cfg, err := planet.LoadConfig(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := planet.OpenRepository(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	goFeed, _ := repo.AddFeed(ctx, "https://go.invalid/feed", "Go Blog")
+	rustFeed, _ := repo.AddFeed(ctx, "https://rust.invalid/feed", "Rust Blog")
+	if err := repo.SetFeedCategories(ctx, goFeed, []string{"go"}); err != nil {
+		t.Fatal(err)
+	}
+	day := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+	for _, e := range []repository.Entry{
+		{FeedID: goFeed, EntryID: "go-1", Title: "Generics, \"finally\"", Link: "https://go.invalid/1", Published: day, Content: "<p>Hello, world</p>",
+			Authors: []string{"Ann", "Bob"}, Attachments: []repository.Attachment{{URL: "https://go.invalid/talk.mp3", MimeType: "audio/mpeg"}}},
+		{FeedID: rustFeed, EntryID: "rust-1", Title: "Borrowing", Link: "https://rust.invalid/1", Published: day.AddDate(0, 0, 1)},
+		{FeedID: goFeed, EntryID: "go-2", Title: "Modules", Link: "https://go.invalid/2", Published: day.AddDate(0, 0, 2)},
+	} {
+		e.Updated, e.FirstSeen = e.Published, e.Published
+		if err := repo.UpsertEntry(ctx, &e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	repo.Close()
+
+	t.Run("jsonl", func(t *testing.T) {
+		var out bytes.Buffer
+		if err := cmdExport(ctx, ExportOptions{ConfigPath: configPath, Format: "jsonl", Output: &out}); err != nil {
+			t.Fatalf("cmdExport() error = %v", err)
+		}
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		if len(lines) != 3 {
+			t.Fatalf("got %d lines, want 3:\n%s", len(lines), out.String())
+		}
+		var first exportedEntry
+		if err := json.Unmarshal([]byte(lines[0]), &first); err != nil {
+			t.Fatalf("line 1 is not JSON: %v", err)
+		}
+		if first.ID != "go-1" || first.FeedURL != "https://go.invalid/feed" || first.FeedTitle != "Go Blog" ||
+			first.Content != "<p>Hello, world</p>" || len(first.Authors) != 2 || len(first.Attachments) != 1 || !first.Published.Equal(day) {
+			t.Errorf("first entry = %+v", first)
+		}
+	})
+
+	t.Run("csv of one tag and date range", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "go.csv")
+		var out bytes.Buffer
+		opts := ExportOptions{ConfigPath: configPath, Format: "csv", OutputFile: path, Output: &out,
+			Selection: planet.Selection{Tags: []string{"go"}}, Since: day.AddDate(0, 0, 1)}
+		if err := cmdExport(ctx, opts); err != nil {
+			t.Fatalf("cmdExport() error = %v", err)
+		}
+		if !strings.Contains(out.String(), "Exported 1 entries") {
+			t.Errorf("output = %q, want a count of 1", out.String())
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		records, err := csv.NewReader(f).ReadAll()
+		if err != nil {
+			t.Fatalf("export is not CSV: %v", err)
+		}
+		if len(records) != 2 || !reflect.DeepEqual(records[0], exportCSVHeader) || records[1][2] != "go-2" {
+			t.Errorf("records = %q, want the header and go-2", records)
+		}
+	})
+
+	t.Run("sqlite", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "corpus.db")
+		opts := ExportOptions{ConfigPath: configPath, Format: "sqlite", OutputFile: path, Output: io.Discard,
+			Selection: planet.Selection{Tags: []string{"go"}}}
+		if err := cmdExport(ctx, opts); err != nil {
+			t.Fatalf("cmdExport() error = %v", err)
+		}
+		dump, err := repository.New(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer dump.Close()
+		feeds, _ := dump.GetFeeds(ctx, false)
+		if len(feeds) != 1 || feeds[0].URL != "https://go.invalid/feed" {
+			t.Errorf("dumped feeds = %+v, want the Go feed", feeds)
+		}
+		var ids []string
+		err = dump.ExportEntries(ctx, repository.EntryFilter{}, func(e repository.Entry) error {
+			ids = append(ids, e.EntryID)
+			return nil
+		})
+		if err != nil || !reflect.DeepEqual(ids, []string{"go-1", "go-2"}) {
+			t.Errorf("dumped entries = %q, %v; want go-1 and go-2", ids, err)
+		}
+
+		// An existing file is left alone
+		if err := cmdExport(ctx, opts); err == nil || !strings.Contains(err.Error(), "already exists") {
+			t.Errorf("second export error = %v, want already exists", err)
+		}
+	})
+}
+
+func TestCmdImport(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	configPath, _ := writeServeConfig(t)
+	cfg, err := planet.LoadConfig(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	oldPath := filepath.Join(t.TempDir(), "old.db")
+	old, err := repository.New(oldPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	feedID, _ := old.AddFeed(ctx, "https://old.invalid/feed", "Old Blog")
+	published := time.Date(2010, 1, 2, 3, 4, 5, 0, time.UTC)
+	entry := repository.Entry{FeedID: feedID, EntryID: "old-1", Title: "From 2010", Link: "https://old.invalid/1",
+		Published: published, Updated: published, FirstSeen: published}
+	if err := old.UpsertEntry(ctx, &entry); err != nil {
+		t.Fatal(err)
+	}
+	old.Close()
+
+	var out bytes.Buffer
+	if err := cmdImport(ctx, ImportOptions{Source: oldPath, ConfigPath: configPath, DryRun: true, Output: &out}); err != nil {
+		t.Fatalf("dry run error = %v", err)
+	}
+	if !strings.Contains(out.String(), "Would import 1 entries from 1 feeds (1 feeds new to this planet)") {
+		t.Errorf("dry run output = %q", out.String())
+	}
+
+	out.Reset()
+	if err := cmdImport(ctx, ImportOptions{Source: oldPath, ConfigPath: configPath, Output: &out}); err != nil {
+		t.Fatalf("cmdImport() error = %v", err)
+	}
+	if !strings.Contains(out.String(), "Imported 1 entries") {
+		t.Errorf("output = %q", out.String())
+	}
+	repo, err := planet.OpenRepository(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, _ := repo.FindEntries(ctx, "https://old.invalid/1")
+	repo.Close()
+	if len(entries) != 1 || entries[0].Title != "From 2010" {
+		t.Errorf("imported entries = %+v", entries)
+	}
+
+	if err := cmdImport(ctx, ImportOptions{Source: cfg.Database.Path, ConfigPath: configPath, Output: io.Discard}); err == nil {
+		t.Error("importing the planet's own database succeeded, want an error")
+	}
+}
+
+func TestCmdCompletion(t *testing.T) {
+	t.Parallel()
+
+	addFeed, _ := findCommand("add-feed")
+	flags := commandFlags(addFeed)
+	want := []completionFlag{
+		{Name: "config", Usage: "Path to config file", Valued: true},
+		{Name: "fetch", Usage: "Fetch and validate the feed immediately"},
+	}
+	if !reflect.DeepEqual(flags, want) {
+		t.Errorf("commandFlags(add-feed) = %+v, want %+v", flags, want)
+	}
+
+	commands := []completionCommand{
+		{Name: "add-feed", Summary: "Add a feed to the planet", Flags: flags},
+		{Name: "planets", Summary: "List the planet's planets", Words: []string{"list", "add"}},
+	}
+	for shell, wants := range map[string][]string{
+		"bash": {`complete -o default -F _rp rp`, `add-feed) flags="--config --fetch"; words="" ;;`, `planets) flags=""; words="list add" ;;`, `--config) return ;;`},
+		"zsh":  {`#compdef rp`, `'add-feed:Add a feed to the planet'`, `'--fetch:Fetch and validate the feed immediately'`, `'planets:List the planet'\''s planets'`},
+		"fish": {`complete -c rp -n '__rp_using ""' -f -a add-feed -d 'Add a feed to the planet'`, `complete -c rp -n '__rp_using add-feed' -l config -r -d 'Path to config file'`, `complete -c rp -n '__rp_using planets; and __rp_first_argument' -f -a 'list add'`, `planet\'s`},
+	} {
+		var buf bytes.Buffer
+		if err := cmdCompletion(CompletionOptions{Shell: shell, Commands: commands, Output: &buf}); err != nil {
+			t.Fatalf("cmdCompletion(%s) error = %v", shell, err)
+		}
+		for _, w := range wants {
+			if !strings.Contains(buf.String(), w) {
+				t.Errorf("%s completion missing %q:\n%s", shell, w, buf.String())
+			}
+		}
+	}
+
+	if err := cmdCompletion(CompletionOptions{Shell: "tcsh", Output: io.Discard}); err == nil {
+		t.Error("cmdCompletion(tcsh) expected error")
+	}
+}
+
+func TestCmdReport(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "planet.db")
+	configPath := filepath.Join(tmpDir, "config.ini")
+	configContent := fmt.Sprintf("[planet]\nname = Test Planet\n\n[database]\npath = %s\n", dbPath)
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to create config: %v", err)
+	}
+
+	repo, err := repository.New(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create repository: %v", err)
+	}
+	ctx := context.Background()
+	add := func(url, title string, published time.Time, fetches ...repository.FetchLogEntry) int64 {
+		id, err := repo.AddFeed(ctx, url, title)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !published.IsZero() {
+			_ = repo.UpsertEntry(ctx, &repository.Entry{FeedID: id, EntryID: url, Title: "Post", Published: published, FirstSeen: published})
+		}
+		for i, f := range fetches {
+			f.FeedID = id
+			f.FetchedAt = time.Now().Add(time.Duration(i-len(fetches)) * time.Hour)
+			if err := repo.RecordFetch(ctx, f); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return id
+	}
+	now := time.Now()
+	add("https://fresh.example.com/feed", "Fresh", now.AddDate(0, 0, -3))
+	add("https://quiet.example.com/feed", "Quiet", now.AddDate(-2, 0, 0))
+	add("https://lapsed.example.com/feed", "Lapsed", now, repository.FetchLogEntry{StatusCode: 200, FinalURL: "https://ww1.sedoparking.com/lapsed.example.com"})
+	notFound := repository.FetchLogEntry{StatusCode: 404, Error: "unexpected status code: 404"}
+	add("https://moved.example.com/rss", "Moved", now, notFound, repository.FetchLogEntry{StatusCode: 403}, notFound)
+	add("https://flaky.example.com/rss", "Flaky", now, notFound, repository.FetchLogEntry{StatusCode: 200}, notFound)
+	add("https://detour.example.com/feed", "Detour", now, repository.FetchLogEntry{StatusCode: 200, FinalURL: "https://cdn.example.net/detour.xml"})
+	add("http://twice.example.com/feed", "Twice over http", now)
+	add("https://www.twice.example.com/feed/", "Twice over https", now)
+	repo.Close()
+
+	var buf bytes.Buffer
+	if err := cmdReport(context.Background(), ReportOptions{ConfigPath: configPath, StaleMonths: 6, Failures: 3, Output: &buf}); err != nil {
+		t.Fatalf("cmdReport() error = %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"Feed health report: 8 feeds, 8 active",
+		"Quiet (https://quiet.example.com/feed): last post",
+		"(2 years ago)",
+		"'rp edit-feed https://quiet.example.com/feed --active=false'",
+		"Lapsed (https://lapsed.example.com/feed) redirects to sedoparking.com, a domain parking service",
+		"'rp remove-feed https://lapsed.example.com/feed'",
+		"Moved (https://moved.example.com/rss): HTTP 404, 403 since",
+		"'rp rename-feed https://moved.example.com/rss NEW-URL'",
+		"Detour (https://detour.example.com/feed) redirects to https://cdn.example.net/detour.xml",
+		"'rp rename-feed https://detour.example.com/feed https://cdn.example.net/detour.xml'",
+		"https://www.twice.example.com/feed/ and http://twice.example.com/feed are the same feed",
+		"keep https://www.twice.example.com/feed/ and run 'rp remove-feed http://twice.example.com/feed'",
+		"Found 5 problems.",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("report missing %q:\n%s", want, out)
+		}
+	}
+	for _, unwanted := range []string{"Fresh (", "Flaky ("} {
+		if strings.Contains(out, unwanted) {
+			t.Errorf("report mentions %q:\n%s", unwanted, out)
+		}
+	}
+
+	// A healthy planet
+	healthy := filepath.Join(t.TempDir(), "config.ini")
+	content := fmt.Sprintf("[planet]\nname = Test Planet\n\n[database]\npath = %s\n", filepath.Join(filepath.Dir(healthy), "planet.db"))
+	if err := os.WriteFile(healthy, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	if err := cmdReport(context.Background(), ReportOptions{ConfigPath: healthy, StaleMonths: 6, Failures: 3, Output: &buf}); err != nil {
+		t.Fatalf("cmdReport() error = %v", err)
+	}
+	if !strings.Contains(buf.String(), "No problems found.") {
+		t.Errorf("healthy report:\n%s", buf.String())
+	}
+}
@@ -22,7 +22,7 @@ func TestEntrySpamPrevention(t *testing.T) {
 		FeedsFile:  "",
 		Output:     io.Discard,
 	}
-	if err := cmdInit(initOpts); err != nil {
+	if err := cmdInit(context.Background(), initOpts); err != nil {
 		t.Fatalf("cmdInit() error = %v", err)
 	}
 
@@ -134,7 +134,7 @@ func TestBackwardsCompatibility(t *testing.T) {
 		ConfigPath: "./config.ini",
 		Output:     io.Discard,
 	}
-	if err := cmdInit(initOpts); err != nil {
+	if err := cmdInit(context.Background(), initOpts); err != nil {
 		t.Fatalf("cmdInit() error = %v", err)
 	}
 
@@ -2,13 +2,20 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"os"
 	"os/signal"
+	"slices"
+	"strings"
 	"syscall"
-)
+	"text/tabwriter"
+	_ "time/tzdata" // Feed time zones work on systems without a zone database
 
-const version = "0.4.0"
+	"github.com/adewale/rogue_planet/pkg/config"
+)
 
 func main() {
 	if err := run(); err != nil {
@@ -18,59 +25,254 @@ func main() {
 }
 
 func run() error {
-	if len(os.Args) < 2 {
+	name, planetName, args, err := splitArgs(os.Args[1:])
+	if err != nil {
+		return err
+	}
+	if name == "" {
 		printUsage()
 		return fmt.Errorf("no command specified")
 	}
 
-	command := os.Args[1]
+	// rp --planet work <command> runs command for a registered planet
+	if planetName != "" {
+		registry, err := config.RegistryPath()
+		if err != nil {
+			return err
+		}
+		planetArgs, dir, err := selectPlanet(planetName, append([]string{name}, args...), registry)
+		if err != nil {
+			return err
+		}
+		if err := os.Chdir(dir); err != nil {
+			return fmt.Errorf("enter planet directory: %w", err)
+		}
+		name, args = planetArgs[0], planetArgs[1:]
+	}
+
+	// rp help <command> is rp <command> --help
+	if name == "help" && len(args) > 0 {
+		name, args = args[0], []string{"--help"}
+	}
 
-	// Create context with signal handling for long-running commands
-	// This enables graceful cancellation with Ctrl+C (SIGINT) or kill (SIGTERM)
+	cmd, ok := findCommand(name)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown command: %s\n", name)
+		printUsage()
+		return fmt.Errorf("unknown command: %s", name)
+	}
+
+	// Every command is given a context that Ctrl+C (SIGINT) or kill
+	// (SIGTERM) cancels, so it can stop cleanly partway through
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
-	switch command {
-	case "init":
-		return runInit()
-	case "add-feed":
-		return runAddFeed()
-	case "add-all":
-		return runAddAll()
-	case "remove-feed":
-		return runRemoveFeed()
-	case "list-feeds":
-		return runListFeeds()
-	case "status":
-		return runStatus()
-	case "update":
-		// Long-running command - pass context for cancellation support
-		return runUpdateWithContext(ctx)
-	case "fetch":
-		// Long-running command - pass context for cancellation support
-		return runFetchWithContext(ctx)
-	case "generate":
-		// Long-running command - pass context for cancellation support
-		return runGenerateWithContext(ctx)
-	case "prune":
-		// Long-running command - pass context for cancellation support
-		return runPruneWithContext(ctx)
-	case "verify":
-		return runVerify()
-	case "import-opml":
-		return runImportOPML()
-	case "export-opml":
-		return runExportOPML()
-	case "version":
-		fmt.Printf("rp version %s\n", version)
-		return nil
-	case "help", "--help", "-h":
-		printUsage()
+	err = cmd.run(ctx, args)
+	var help *helpError
+	if errors.As(err, &help) {
+		printCommandHelp(os.Stdout, cmd, help.fs)
 		return nil
-	default:
-		fmt.Fprintf(os.Stderr, "Unknown command: %s\n", command)
-		printUsage()
-		return fmt.Errorf("unknown command: %s", command)
+	}
+	var usage *usageError
+	if errors.As(err, &usage) {
+		fmt.Fprintf(os.Stderr, "Usage: %s\nRun 'rp help %s' for its flags and examples\n", cmd.usage(), cmd.name)
+	}
+	return err
+}
+
+// command is one of rp's commands
+type command struct {
+	name    string
+	args    string   // Arguments shown after the name in usage lines, e.g. "<url>"
+	summary string   // One line description for the command list
+	details string   // More help, shown by rp help <command>
+	words   []string // Completions of the command's first argument
+	run     func(ctx context.Context, args []string) error
+}
+
+// usage returns the command's usage line
+func (c command) usage() string {
+	return strings.Join(strings.Fields("rp "+c.name+" "+c.args+" [flags]"), " ")
+}
+
+// usageError is a command's error parsing its arguments, after which main
+// shows the command's usage line
+type usageError struct {
+	err error
+}
+
+func (e *usageError) Error() string { return e.err.Error() }
+func (e *usageError) Unwrap() error { return e.err }
+
+// commands lists rp's commands in the order rp help shows them
+func commands() []command {
+	return []command{
+		{name: "init", args: "[-f FILE]", summary: "Initialize a new planet in the current directory", run: runInit},
+		{name: "add-feed", args: "<url>", summary: "Add a feed to the planet", run: runAddFeed},
+		{name: "add-all", args: "-f FILE", summary: "Add multiple feeds from a file", run: runAddAll},
+		{name: "remove-feed", args: "<url>", summary: "Remove a feed from the planet (interactive confirmation)", run: runRemoveFeed},
+		{name: "remove-feeds", summary: "Remove every feed matching the selection flags (interactive confirmation)", run: bulkFeedsCommand("remove-feeds")},
+		{name: "list-feeds", summary: "List all configured feeds", run: runListFeeds},
+		{name: "reactivate-feed", args: "<url>", summary: "Resume fetching a deactivated or failing feed", run: runReactivateFeed},
+		{name: "deactivate-feeds", summary: "Stop fetching every feed matching the selection flags", run: bulkFeedsCommand("deactivate-feeds")},
+		{name: "reactivate-feeds", summary: "Resume fetching every feed matching the selection flags", run: bulkFeedsCommand("reactivate-feeds")},
+		{name: "rename-feed", args: "<url> <new-url>", summary: "Move a feed to a new URL, keeping its entries and history", run: runRenameFeed},
+		{name: "edit-feed", args: "<url>", summary: "Change a feed's title, tags, or whether it is fetched", run: runEditFeed},
+		{name: "show-entry", args: "<link>", summary: "Show a stored entry, and with --revisions how its content was edited", run: runShowEntry},
+		{name: "hide-entry", args: "<link>", summary: "Keep an entry off the site (by link or entry ID)", run: runHideEntry},
+		{name: "unhide-entry", args: "<link>", summary: "Put a hidden entry back on the site", run: runUnhideEntry},
+		{name: "list-hidden", summary: "List hidden entries", run: runListHidden},
+		{name: "pin-entry", args: "<link>", summary: "Feature an entry at the top of the site (by link or entry ID)", run: runPinEntry},
+		{name: "unpin-entry", args: "<link>", summary: "Stop featuring an entry", run: runUnpinEntry},
+		{name: "list-pinned", summary: "List pinned entries", run: runListPinned},
+		{name: "mark-read", args: "<link> | --all", summary: "Mark an entry read (by link or entry ID), or all entries with --all", run: runMarkRead},
+		{name: "mark-unread", args: "<link>", summary: "Mark an entry unread again", run: runMarkUnread},
+		{name: "list-unread", summary: "List unread entries, newest first", run: runListUnread},
+		{name: "star-entry", args: "<link>", summary: "Star an entry to keep for later (by link or entry ID)", run: runStarEntry},
+		{name: "unstar-entry", args: "<link>", summary: "Unstar an entry", run: runUnstarEntry},
+		{name: "list-starred", summary: "List starred entries", run: runListStarred},
+		{name: "status", summary: "Show planet status (feed and entry counts)", run: runStatus},
+		{name: "history", summary: "Show a feed's recent fetch attempts", run: runHistory},
+		{name: "report", summary: "Find stale, parked, failing, redirected, and duplicate feeds, and say how to fix them", run: runReport},
+		{name: "update", summary: "Fetch all feeds and regenerate site", run: runUpdate},
+		{name: "fetch", summary: "Fetch all feeds without generating", run: runFetch},
+		{name: "generate", summary: "Generate site without fetching", run: runGenerate},
+		{name: "digest", summary: "Write or email a digest of the entries first seen recently", run: runDigest},
+		{name: "prune", summary: "Remove old entries from database", run: runPrune},
+		{name: "relate", summary: "Work out the related posts of every recent entry again", run: runRelate},
+		{name: "serve", summary: "Serve the site and refresh it periodically", run: runServe},
+		{name: "rollback", summary: "Restore the previously generated site", run: runRollback},
+		{name: "verify", summary: "Validate configuration and environment", run: runVerify},
+		{name: "validate-feed", args: "<url-or-file>", summary: "Check a feed against its spec and show how rp would read it", run: runValidateFeed},
+		{name: "record-fixtures", summary: "Save feeds' raw responses as fixtures that fetch --fixtures replays", run: runRecordFixtures},
+		{name: "config", args: "get KEY | set KEY VALUE", summary: "Print or change a config setting such as planet.days, keeping the file's comments",
+			words: []string{"get", "set"}, run: runConfig},
+		{name: "planets", args: "<action>", summary: "List, add, remove, or update the planets this installation runs",
+			details: "Actions:\n" + planetsActions, words: []string{"list", "add", "remove", "update"}, run: runPlanets},
+		{name: "install-service", summary: "Run 'rp update' on a schedule with systemd, launchd, or cron", run: runInstallService},
+		{name: "uninstall-service", summary: "Remove the schedule install-service set up", run: runUninstallService},
+		{name: "doctor", summary: "Check database integrity, feed URLs, network, and templates", run: runDoctor},
+		{name: "import-opml", args: "FILE", summary: "Import feeds from OPML file", run: runImportOPML},
+		{name: "import", args: "<source>", summary: "Import entries from another planet.db or a Planet Venus cache directory", run: runImport},
+		{name: "export-opml", summary: "Export feeds to OPML format", run: runExportOPML},
+		{name: "export", summary: "Export stored entries as JSON Lines, CSV, or an SQLite database", run: runExport},
+		{name: "completion", args: "<shell>", summary: "Print a bash, zsh, or fish completion script for rp",
+			details: completionSetup, words: completionShells, run: runCompletion},
+		{name: "version", summary: "Show version information", run: runVersion},
+		{name: "help", args: "[command]", summary: "Show this help message, or a command's", run: runHelp},
+	}
+}
+
+// findCommand returns the command called name
+func findCommand(name string) (command, bool) {
+	switch name {
+	case "--version":
+		name = "version"
+	case "--help", "-h":
+		name = "help"
+	}
+	for _, c := range commands() {
+		if c.name == name {
+			return c, true
+		}
+	}
+	return command{}, false
+}
+
+// globalFlags are the flags that may be given before the command as well
+// as after it, and whether each takes a value
+var globalFlags = map[string]bool{
+	"planet":  true,
+	"config":  true,
+	"verbose": false,
+	"quiet":   false,
+}
+
+// splitArgs finds the command in rp's arguments and the planet --planet
+// names, wherever they are. Other global flags given before the command are
+// moved after it, among the arguments returned, for the command to parse.
+func splitArgs(args []string) (name, planetName string, rest []string, err error) {
+	var moved []string
+	for len(args) > 0 && name == "" {
+		arg := args[0]
+		args = args[1:]
+		flagName, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		takesValue, global := globalFlags[flagName]
+		if !strings.HasPrefix(arg, "-") || !global {
+			name = arg
+			break
+		}
+		if takesValue && !hasValue {
+			if len(args) == 0 {
+				return "", "", nil, fmt.Errorf("--%s needs a value", flagName)
+			}
+			value, args = args[0], args[1:]
+		}
+		if flagName == "planet" {
+			planetName = value
+		} else if takesValue {
+			moved = append(moved, "--"+flagName, value)
+		} else {
+			moved = append(moved, arg)
+		}
+	}
+
+	// --planet may follow the command too
+	for i := 0; i < len(args); i++ {
+		if args[i] == "--" {
+			break
+		}
+		if args[i] == "--planet" || args[i] == "-planet" {
+			if i+1 == len(args) {
+				return "", "", nil, fmt.Errorf("--planet needs a planet name")
+			}
+			planetName = args[i+1]
+			args = slices.Delete(slices.Clone(args), i, i+2)
+			i--
+		} else if value, ok := strings.CutPrefix(args[i], "--planet="); ok {
+			planetName = value
+			args = slices.Delete(slices.Clone(args), i, i+1)
+			i--
+		}
+	}
+	return name, planetName, append(moved, args...), nil
+}
+
+// printCommandHelp writes the help for cmd: its usage line, description,
+// flags from fs (nil if it has none), and examples
+func printCommandHelp(w io.Writer, cmd command, fs *flag.FlagSet) {
+	fmt.Fprintf(w, "Usage: %s\n\n%s\n", cmd.usage(), cmd.summary)
+	if cmd.details != "" {
+		fmt.Fprintf(w, "\n%s\n", cmd.details)
+	}
+
+	if fs != nil {
+		fmt.Fprintln(w, "\nFlags:")
+		tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+		fs.VisitAll(func(f *flag.Flag) {
+			name := "--" + f.Name
+			if len(f.Name) == 1 {
+				name = "-" + f.Name
+			}
+			typ, desc := flag.UnquoteUsage(f)
+			switch f.DefValue {
+			case "", "0", "0s", "false":
+			default:
+				desc += fmt.Sprintf(" (default: %s)", f.DefValue)
+			}
+			fmt.Fprintf(tw, "  %s\t%s\n", strings.TrimSpace(name+" "+typ), desc)
+		})
+		tw.Flush()
+	}
+
+	var examples []string
+	for _, line := range strings.Split(usageExamples, "\n") {
+		if rest, ok := strings.CutPrefix(line, "  rp "+cmd.name); ok && (rest == "" || rest[0] == ' ') {
+			examples = append(examples, line)
+		}
+	}
+	if len(examples) > 0 {
+		fmt.Fprintf(w, "\nExamples:\n%s\n", strings.Join(examples, "\n"))
 	}
 }
 
@@ -81,25 +283,29 @@ Supports RSS, Atom, and JSON Feed formats
 Usage:
   rp <command> [flags]
 
+Run 'rp help <command>' or 'rp <command> --help' for a command's flags and examples.
+
 Commands:
-  init [-f FILE]    Initialize a new planet in the current directory
-  add-feed <url>    Add a feed to the planet
-  add-all -f FILE   Add multiple feeds from a file
-  remove-feed <url> Remove a feed from the planet (interactive confirmation)
-  list-feeds        List all configured feeds
-  status            Show planet status (feed and entry counts)
-  update            Fetch all feeds and regenerate site
-  fetch             Fetch all feeds without generating
-  generate          Generate site without fetching
-  prune             Remove old entries from database
-  verify            Validate configuration and environment
-  import-opml FILE  Import feeds from OPML file
-  export-opml       Export feeds to OPML format
-  version           Show version information
-  help              Show this help message
-
-Init Flags:
+`)
+	for _, c := range commands() {
+		fmt.Printf("  %-17s %s\n", strings.TrimSpace(c.name+" "+c.args), c.summary)
+	}
+	fmt.Printf("\n%s\n\nExamples:\n%s\n\n", usageFlags, usageExamples)
+}
+
+// planetsActions describes the actions of rp planets
+const planetsActions = `  list              List registered planets with their config, output, and database
+  add NAME [CONFIG] Register a planet (default config: ./config.ini)
+  remove NAME       Unregister a planet; its files are left alone
+  update [FLAGS]    Run 'rp update' for every planet, taking update's flags`
+
+// usageFlags describes the flags of each command in rp help
+const usageFlags = `Init Flags:
   -f FILE           Import feeds from file (one URL per line)
+  --interactive     Prompt for planet details, update schedule, theme, feeds, and an OPML file
+
+Add-Feed Flags:
+  --fetch           Fetch and parse the feed now; nothing is added if that fails
 
 Add-All Flags:
   -f FILE           Path to feeds file (one URL per line)
@@ -107,76 +313,246 @@ Add-All Flags:
 Remove-Feed Flags:
   --force           Skip confirmation prompt (for scripting)
 
+Remove-Feeds/Deactivate-Feeds/Reactivate-Feeds Flags:
+  --match TEXT      Select feeds whose URL or title contains TEXT, ignoring case; repeatable
+  --feed URL        Select this feed, or feeds matching a glob (* matches anything); repeatable
+  --tag TAG         Select feeds in this category (comma-separated for several)
+  --errors-gt N     Select feeds with more than N consecutive fetch errors
+  --inactive        Select deactivated feeds
+  --dry-run         List the selected feeds without changing them
+  --force           Remove without a confirmation prompt (remove-feeds only)
+  A feed must match every flag given.
+
+List-Feeds Flags:
+  --errors          Only list feeds with consecutive fetch errors, including deactivated ones
+
+Edit-Feed Flags:
+  --title TITLE     Title shown for the feed instead of its own ("" to use its own again)
+  --tags TAGS       Categories of the feed, comma-separated, replacing its current ones ("" removes them)
+  --active=BOOL     Whether the feed is fetched (--active=false pauses it)
+
+Show-Entry Flags:
+  --revisions       Also show the entry's kept earlier revisions, and the lines each edit removed (-) and added (+)
+
+Update/Fetch Flags:
+  --force           Fetch feeds even if their Cache-Control/Expires lifetime has not expired
+  --feed URL        Only fetch this feed, or feeds matching a glob (* matches anything); repeatable
+  --tag TAG         Only fetch feeds in this category (comma-separated for several)
+  --only-errors     Only fetch feeds whose last fetch failed
+  --resume          Only fetch the feeds an interrupted run (Ctrl+C, SIGTERM) did not reach
+  --wait DUR        Wait up to DUR for another run to finish instead of skipping this one
+
+Fetch Flags:
+  --trace-feed URL  Fetch one feed and show status and response headers
+  --offline         Replay the responses saved in response_cache_dir instead of fetching
+  --fixtures DIR    Replay the responses record-fixtures saved in DIR instead of fetching
+
+Generate Flags:
+  --days N          Number of days to include (overrides config)
+  --tag TAG         Only include entries with this category (comma-separated for several)
+  --no-publish      Do not run the [publish] hooks (update accepts it too)
+
+Digest Flags:
+  --since DUR       Include entries first seen this long ago or since (default: 24h)
+  --html            Write the HTML part instead of the plain text
+  --output FILE     Output file (default: stdout)
+  --send            Email the digest to the [digest] subscribers instead
+
+Status Flags:
+  --feed URL        Show HTTP cache state, fetch history, posting cadence, and schedule for one feed
+
+Mark-Read Flags:
+  --all             Mark every stored entry read
+  --feed URL        With --all, only mark this feed's entries read
+
+List-Unread Flags:
+  --limit N         Number of newest unread entries listed (default: 50)
+
+History Flags:
+  --feed URL        Feed whose fetch attempts are shown (required)
+  --limit N         Number of most recent attempts shown (default: 50)
+
+Report Flags:
+  --stale-months N  Report feeds that have published nothing for N months (default: 6)
+  --failures N      Report feeds whose last N fetches all failed with 4xx errors (default: 3)
+
+Serve Flags:
+  --addr ADDR       Address to listen on (default: :8080)
+  --interval DUR    Time between fetch+generate runs (default: 30m, 0 disables)
+
+Planets Actions:
+` + planetsActions + `
+
+Install-Service/Uninstall-Service Flags:
+  --interval DUR    Time between updates (default: 30m; install-service only)
+  --kind KIND       systemd, launchd, or cron (default: systemd on Linux, launchd on macOS, cron elsewhere)
+  --name NAME       Name of the units, launch agent, or crontab entry (default: rogue-planet)
+  --dir DIR         Directory for the unit files (default: the per-user one)
+  --dry-run         Show the files and commands without installing anything
+
+Doctor Flags:
+  --fix             Delete database rows left behind by removed feeds and entries
+  --offline         Skip the DNS and connectivity checks
+
 Import-OPML Flags:
   --dry-run         Preview feeds without importing
 
+Import Flags:
+  --dry-run         Count the feeds and entries that would be imported without importing them
+
 Export-OPML Flags:
   --output FILE     Output file (default: stdout)
+  --health          Include last fetched, error count, and last error per feed
+
+Export Flags:
+  --format FORMAT   jsonl (default), csv, or sqlite (a new rp database with only feeds and entries)
+  --output FILE     Output file (default: stdout; required for sqlite)
+  --feed URL        Only export entries of this feed, or feeds matching a glob; repeatable
+  --tag TAG         Only export entries of feeds in this category (comma-separated for several)
+  --since DATE      Only export entries published on or after DATE (YYYY-MM-DD or RFC 3339)
+  --until DATE      Only export entries published on or before DATE
+
+Validate-Feed Flags:
+  --url URL         URL a feed file will be served from, for resolving its relative links
+
+Record-Fixtures Flags:
+  --feed URL        Record this feed; repeatable (default: every active feed)
+  --output DIR      Directory to write the fixtures to (default: fixtures)
 
-Global Flags:
+Version Flags:
+  --verbose         Show commit, build date, Go version, and build tags
+
+Global Flags (before or after the command):
+  --planet NAME     Run the command for a registered planet, from its directory
   --config <path>   Path to config file (default: ./config.ini)
   --verbose         Enable verbose logging
-  --quiet           Only show errors
+  --quiet           Only show errors`
 
-Examples:
-  rp init
+// usageExamples lists examples of each command; rp help <command> shows
+// those of the command
+const usageExamples = `  rp init
   rp init -f feeds.txt
+  rp init --interactive
   rp add-feed https://blog.golang.org/feed.atom
   rp add-feed https://username.micro.blog/feed.json
+  rp add-feed https://example.com/feed.xml --fetch
   rp add-all -f feeds.txt
   rp remove-feed https://example.com/feed.xml
   rp remove-feed https://example.com/feed.xml --force
+  rp remove-feeds --match blogspot.com --dry-run
+  rp remove-feeds --inactive --force
+  rp deactivate-feeds --errors-gt 10
+  rp reactivate-feeds --tag go
   rp list-feeds
+  rp list-feeds --errors
+  rp reactivate-feed https://example.com/feed.xml
+  rp rename-feed http://example.com/rss https://example.com/feed.xml
+  rp edit-feed https://example.com/feed.xml --title "Example Blog" --tags go,web
+  rp edit-feed https://example.com/feed.xml --active=false
+  rp show-entry https://example.com/2024/05/policy-update --revisions
+  rp hide-entry https://example.com/2024/05/private-post
+  rp list-hidden
+  rp pin-entry https://example.com/2019/01/classic-post
+  rp list-unread --limit 20
+  rp mark-read --all --feed https://example.com/feed.xml
   rp status
+  rp status --feed https://example.com/feed.xml
+  rp history --feed https://example.com/feed.xml --limit 20
+  rp report --stale-months 12
   rp update
+  rp update --force
+  rp update --feed https://example.com/feed.xml
+  rp fetch --only-errors --tag go
+  rp update --resume
+  rp update --wait 10m
+  rp fetch --trace-feed https://example.com/feed.xml
+  rp fetch --offline
+  rp record-fixtures --feed https://example.com/feed.xml
+  rp fetch --fixtures fixtures
+  rp validate-feed https://example.com/feed.xml
+  rp validate-feed ./feed.xml
   rp generate --days 14
+  rp generate --tag go,rust
+  rp digest --since 168h --html --output digest.html
+  rp digest --since 24h --send
   rp prune --days 90
+  rp relate
+  rp serve --addr :8080 --interval 1h
+  rp rollback
+  rp config get planet.days
+  rp config set planet.days 14
+  rp config set feed.https://example.com/feed.xml.extract_content true
+  rp planets add work ~/planets/work/config.ini
+  rp planets update
+  rp --planet work status
+  rp install-service --interval 1h
+  rp install-service --kind cron --dry-run
+  rp uninstall-service
+  rp doctor
+  rp doctor --fix --offline
   rp import-opml feeds.opml
   rp import-opml feeds.opml --dry-run
+  rp import ../old-planet/data/planet.db
+  rp import ~/venus/cache --dry-run
   rp export-opml --output feeds.opml
-
-`)
+  rp export-opml --health
+  rp export --output entries.jsonl
+  rp export --format csv --since 2024-01-01 --until 2024-12-31 --output 2024.csv
+  rp export --format sqlite --tag go --output go-corpus.db
+  rp version --verbose
+  rp help fetch
+  rp completion bash > ~/.local/share/bash-completion/completions/rp`
+
+func runHelp(_ context.Context, args []string) error {
+	if len(args) > 0 {
+		return &helpError{}
+	}
+	printUsage()
+	return nil
 }
 
-func runInit() error {
-	opts, err := parseInitFlags(os.Args[2:])
+func runInit(ctx context.Context, args []string) error {
+	opts, err := parseInitFlags(args)
 	if err != nil {
-		return err
+		return &usageError{err}
 	}
 	opts.Output = os.Stdout
-	return cmdInit(opts)
+	opts.Input = os.Stdin
+
+	err = cmdInit(ctx, opts)
+	if _, ok := err.(*ErrUserCancelled); ok {
+		os.Exit(1)
+	}
+	return err
 }
 
-func runAddFeed() error {
-	opts, err := parseAddFeedFlags(os.Args[2:])
+func runAddFeed(ctx context.Context, args []string) error {
+	opts, err := parseAddFeedFlags(args)
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "Usage: rp add-feed <url>")
-		return err
+		return &usageError{err}
 	}
 	opts.Output = os.Stdout
-	return cmdAddFeed(opts)
+	return cmdAddFeed(ctx, opts)
 }
 
-func runAddAll() error {
-	opts, err := parseAddAllFlags(os.Args[2:])
+func runAddAll(ctx context.Context, args []string) error {
+	opts, err := parseAddAllFlags(args)
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "Usage: rp add-all -f <feeds-file>")
-		return err
+		return &usageError{err}
 	}
 	opts.Output = os.Stdout
-	return cmdAddAll(opts)
+	return cmdAddAll(ctx, opts)
 }
 
-func runRemoveFeed() error {
-	opts, err := parseRemoveFeedFlags(os.Args[2:])
+func runRemoveFeed(ctx context.Context, args []string) error {
+	opts, err := parseRemoveFeedFlags(args)
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "Usage: rp remove-feed <url> [--force]")
-		return err
+		return &usageError{err}
 	}
 	opts.Output = os.Stdout
 	opts.Input = os.Stdin
 
-	err = cmdRemoveFeed(opts)
+	err = cmdRemoveFeed(ctx, opts)
 	// Check if this is a user cancellation
 	if _, ok := err.(*ErrUserCancelled); ok {
 		// "Cancelled." already printed by cmdRemoveFeed
@@ -186,85 +562,426 @@ func runRemoveFeed() error {
 	return err
 }
 
-func runListFeeds() error {
-	opts, err := parseListFeedsFlags(os.Args[2:])
-	if err != nil {
+// bulkFeedsCommand returns the run function of a bulk feed command
+func bulkFeedsCommand(command string) func(ctx context.Context, args []string) error {
+	return func(ctx context.Context, args []string) error {
+		opts, err := parseBulkFeedsFlags(command, args)
+		if err != nil {
+			return &usageError{err}
+		}
+		opts.Output = os.Stdout
+		opts.Input = os.Stdin
+
+		err = cmdBulkFeeds(ctx, opts)
+		if _, ok := err.(*ErrUserCancelled); ok {
+			// "Cancelled." already printed; exit with code 1 without an error message
+			os.Exit(1)
+		}
 		return err
 	}
+}
+
+func runListFeeds(ctx context.Context, args []string) error {
+	opts, err := parseListFeedsFlags(args)
+	if err != nil {
+		return &usageError{err}
+	}
 	opts.Output = os.Stdout
-	return cmdListFeeds(opts)
+	return cmdListFeeds(ctx, opts)
 }
 
-func runStatus() error {
-	opts, err := parseStatusFlags(os.Args[2:])
+func runReactivateFeed(ctx context.Context, args []string) error {
+	opts, err := parseReactivateFeedFlags(args)
 	if err != nil {
-		return err
+		return &usageError{err}
 	}
 	opts.Output = os.Stdout
-	return cmdStatus(opts)
+	return cmdReactivateFeed(ctx, opts)
 }
 
-// WithContext versions of long-running commands for cancellation support
-func runUpdateWithContext(ctx context.Context) error {
-	opts, err := parseUpdateFlags(os.Args[2:])
+func runRenameFeed(ctx context.Context, args []string) error {
+	opts, err := parseRenameFeedFlags(args)
 	if err != nil {
-		return err
+		return &usageError{err}
+	}
+	opts.Output = os.Stdout
+	return cmdRenameFeed(ctx, opts)
+}
+
+func runEditFeed(ctx context.Context, args []string) error {
+	opts, err := parseEditFeedFlags(args)
+	if err != nil {
+		return &usageError{err}
+	}
+	opts.Output = os.Stdout
+	return cmdEditFeed(ctx, opts)
+}
+
+func runShowEntry(ctx context.Context, args []string) error {
+	opts, err := parseShowEntryFlags(args)
+	if err != nil {
+		return &usageError{err}
+	}
+	opts.Output = os.Stdout
+	return cmdShowEntry(ctx, opts)
+}
+
+func runHideEntry(ctx context.Context, args []string) error {
+	opts, err := parseEntryFlags("hide-entry", args)
+	if err != nil {
+		return &usageError{err}
+	}
+	opts.Output = os.Stdout
+	return cmdHideEntry(ctx, opts)
+}
+
+func runUnhideEntry(ctx context.Context, args []string) error {
+	opts, err := parseEntryFlags("unhide-entry", args)
+	if err != nil {
+		return &usageError{err}
+	}
+	opts.Output = os.Stdout
+	return cmdUnhideEntry(ctx, opts)
+}
+
+func runListHidden(ctx context.Context, args []string) error {
+	opts, err := parseListHiddenFlags(args)
+	if err != nil {
+		return &usageError{err}
+	}
+	opts.Output = os.Stdout
+	return cmdListHidden(ctx, opts)
+}
+
+func runPinEntry(ctx context.Context, args []string) error {
+	opts, err := parseEntryFlags("pin-entry", args)
+	if err != nil {
+		return &usageError{err}
+	}
+	opts.Output = os.Stdout
+	return cmdPinEntry(ctx, opts)
+}
+
+func runUnpinEntry(ctx context.Context, args []string) error {
+	opts, err := parseEntryFlags("unpin-entry", args)
+	if err != nil {
+		return &usageError{err}
+	}
+	opts.Output = os.Stdout
+	return cmdUnpinEntry(ctx, opts)
+}
+
+func runListPinned(ctx context.Context, args []string) error {
+	opts, err := parseListPinnedFlags(args)
+	if err != nil {
+		return &usageError{err}
+	}
+	opts.Output = os.Stdout
+	return cmdListPinned(ctx, opts)
+}
+
+func runMarkRead(ctx context.Context, args []string) error {
+	opts, err := parseMarkReadFlags(args)
+	if err != nil {
+		return &usageError{err}
+	}
+	opts.Output = os.Stdout
+	return cmdMarkRead(ctx, opts)
+}
+
+func runMarkUnread(ctx context.Context, args []string) error {
+	opts, err := parseEntryFlags("mark-unread", args)
+	if err != nil {
+		return &usageError{err}
+	}
+	opts.Output = os.Stdout
+	return cmdMarkUnread(ctx, opts)
+}
+
+func runListUnread(ctx context.Context, args []string) error {
+	opts, err := parseListUnreadFlags(args)
+	if err != nil {
+		return &usageError{err}
+	}
+	opts.Output = os.Stdout
+	return cmdListUnread(ctx, opts)
+}
+
+func runStarEntry(ctx context.Context, args []string) error {
+	opts, err := parseEntryFlags("star-entry", args)
+	if err != nil {
+		return &usageError{err}
+	}
+	opts.Output = os.Stdout
+	return cmdStarEntry(ctx, opts)
+}
+
+func runUnstarEntry(ctx context.Context, args []string) error {
+	opts, err := parseEntryFlags("unstar-entry", args)
+	if err != nil {
+		return &usageError{err}
+	}
+	opts.Output = os.Stdout
+	return cmdUnstarEntry(ctx, opts)
+}
+
+func runListStarred(ctx context.Context, args []string) error {
+	opts, err := parseListStarredFlags(args)
+	if err != nil {
+		return &usageError{err}
+	}
+	opts.Output = os.Stdout
+	return cmdListStarred(ctx, opts)
+}
+
+func runStatus(ctx context.Context, args []string) error {
+	opts, err := parseStatusFlags(args)
+	if err != nil {
+		return &usageError{err}
+	}
+	opts.Output = os.Stdout
+	return cmdStatus(ctx, opts)
+}
+
+func runHistory(ctx context.Context, args []string) error {
+	opts, err := parseHistoryFlags(args)
+	if err != nil {
+		return &usageError{err}
+	}
+	opts.Output = os.Stdout
+	return cmdHistory(ctx, opts)
+}
+
+func runReport(ctx context.Context, args []string) error {
+	opts, err := parseReportFlags(args)
+	if err != nil {
+		return &usageError{err}
+	}
+	opts.Output = os.Stdout
+	return cmdReport(ctx, opts)
+}
+
+func runUpdate(ctx context.Context, args []string) error {
+	opts, err := parseUpdateFlags(args)
+	if err != nil {
+		return &usageError{err}
 	}
 	opts.Output = os.Stdout
 	return cmdUpdate(ctx, opts)
 }
 
-func runFetchWithContext(ctx context.Context) error {
-	opts, err := parseFetchFlags(os.Args[2:])
+func runFetch(ctx context.Context, args []string) error {
+	opts, err := parseFetchFlags(args)
 	if err != nil {
-		return err
+		return &usageError{err}
 	}
 	opts.Output = os.Stdout
 	return cmdFetch(ctx, opts)
 }
 
-func runGenerateWithContext(ctx context.Context) error {
-	opts, err := parseGenerateFlags(os.Args[2:])
+func runGenerate(ctx context.Context, args []string) error {
+	opts, err := parseGenerateFlags(args)
 	if err != nil {
-		return err
+		return &usageError{err}
 	}
 	opts.Output = os.Stdout
 	return cmdGenerate(ctx, opts)
 }
 
-func runPruneWithContext(ctx context.Context) error {
-	opts, err := parsePruneFlags(os.Args[2:])
+func runDigest(ctx context.Context, args []string) error {
+	opts, err := parseDigestFlags(args)
 	if err != nil {
-		return err
+		return &usageError{err}
+	}
+	opts.Output = os.Stdout
+	return cmdDigest(ctx, opts)
+}
+
+func runPrune(ctx context.Context, args []string) error {
+	opts, err := parsePruneFlags(args)
+	if err != nil {
+		return &usageError{err}
 	}
 	opts.Output = os.Stdout
 	return cmdPrune(ctx, opts)
 }
 
-func runVerify() error {
-	opts, err := parseVerifyFlags(os.Args[2:])
+func runRelate(ctx context.Context, args []string) error {
+	opts, err := parseRelateFlags(args)
 	if err != nil {
-		return err
+		return &usageError{err}
 	}
 	opts.Output = os.Stdout
-	return cmdVerify(opts)
+	return cmdRelate(ctx, opts)
 }
 
-func runImportOPML() error {
-	opts, err := parseImportOPMLFlags(os.Args[2:])
+func runServe(ctx context.Context, args []string) error {
+	opts, err := parseServeFlags(args)
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "Usage: rp import-opml <opml-file> [--dry-run]")
-		return err
+		return &usageError{err}
+	}
+	opts.Output = os.Stdout
+	return cmdServe(ctx, opts)
+}
+
+func runRollback(_ context.Context, args []string) error {
+	opts, err := parseRollbackFlags(args)
+	if err != nil {
+		return &usageError{err}
+	}
+	opts.Output = os.Stdout
+	return cmdRollback(opts)
+}
+
+func runVerify(ctx context.Context, args []string) error {
+	opts, err := parseVerifyFlags(args)
+	if err != nil {
+		return &usageError{err}
 	}
 	opts.Output = os.Stdout
-	return cmdImportOPML(opts)
+	return cmdVerify(ctx, opts)
 }
 
-func runExportOPML() error {
-	opts, err := parseExportOPMLFlags(os.Args[2:])
+func runValidateFeed(ctx context.Context, args []string) error {
+	opts, err := parseValidateFeedFlags(args)
 	if err != nil {
+		return &usageError{err}
+	}
+	opts.Output = os.Stdout
+	return cmdValidateFeed(ctx, opts)
+}
+
+func runRecordFixtures(ctx context.Context, args []string) error {
+	opts, err := parseRecordFixturesFlags(args)
+	if err != nil {
+		return &usageError{err}
+	}
+	opts.Output = os.Stdout
+	return cmdRecordFixtures(ctx, opts)
+}
+
+func runConfig(_ context.Context, args []string) error {
+	opts, err := parseConfigFlags(args)
+	if err != nil {
+		return &usageError{err}
+	}
+	opts.Output = os.Stdout
+	return cmdConfig(opts)
+}
+
+func runPlanets(ctx context.Context, args []string) error {
+	opts, err := parsePlanetsFlags(args)
+	if err != nil {
+		return &usageError{err}
+	}
+	if opts.Registry, err = config.RegistryPath(); err != nil {
 		return err
 	}
 	opts.Output = os.Stdout
-	return cmdExportOPML(opts)
+	return cmdPlanets(ctx, opts)
+}
+
+func runInstallService(ctx context.Context, args []string) error {
+	opts, err := parseInstallServiceFlags(args)
+	if err != nil {
+		return &usageError{err}
+	}
+	opts.Output = os.Stdout
+	return cmdInstallService(ctx, opts)
+}
+
+func runUninstallService(ctx context.Context, args []string) error {
+	opts, err := parseUninstallServiceFlags(args)
+	if err != nil {
+		return &usageError{err}
+	}
+	opts.Output = os.Stdout
+	return cmdUninstallService(ctx, opts)
+}
+
+func runDoctor(ctx context.Context, args []string) error {
+	opts, err := parseDoctorFlags(args)
+	if err != nil {
+		return &usageError{err}
+	}
+	opts.Output = os.Stdout
+	return cmdDoctor(ctx, opts)
+}
+
+func runImportOPML(ctx context.Context, args []string) error {
+	opts, err := parseImportOPMLFlags(args)
+	if err != nil {
+		return &usageError{err}
+	}
+	opts.Output = os.Stdout
+	return cmdImportOPML(ctx, opts)
+}
+
+func runImport(ctx context.Context, args []string) error {
+	opts, err := parseImportFlags(args)
+	if err != nil {
+		return &usageError{err}
+	}
+	opts.Output = os.Stdout
+	return cmdImport(ctx, opts)
+}
+
+func runExportOPML(ctx context.Context, args []string) error {
+	opts, err := parseExportOPMLFlags(args)
+	if err != nil {
+		return &usageError{err}
+	}
+	opts.Output = os.Stdout
+	return cmdExportOPML(ctx, opts)
+}
+
+func runExport(ctx context.Context, args []string) error {
+	opts, err := parseExportFlags(args)
+	if err != nil {
+		return &usageError{err}
+	}
+	opts.Output = os.Stdout
+	return cmdExport(ctx, opts)
+}
+
+func runVersion(_ context.Context, args []string) error {
+	opts, err := parseVersionFlags(args)
+	if err != nil {
+		return &usageError{err}
+	}
+	opts.Output = os.Stdout
+	return cmdVersion(opts)
+}
+
+func runCompletion(_ context.Context, args []string) error {
+	opts, err := parseCompletionFlags(args)
+	if err != nil {
+		return &usageError{err}
+	}
+	for _, c := range commands() {
+		cc := completionCommand{Name: c.name, Summary: c.summary, Flags: commandFlags(c), Words: c.words}
+		if c.name == "help" {
+			for _, other := range commands() {
+				cc.Words = append(cc.Words, other.name)
+			}
+		}
+		opts.Commands = append(opts.Commands, cc)
+	}
+	opts.Output = os.Stdout
+	return cmdCompletion(opts)
+}
+
+// commandFlags returns the flags of cmd, found by asking it for its help
+func commandFlags(cmd command) []completionFlag {
+	var help *helpError
+	if !errors.As(cmd.run(context.Background(), []string{"--help"}), &help) || help.fs == nil {
+		return nil
+	}
+	var flags []completionFlag
+	help.fs.VisitAll(func(f *flag.Flag) {
+		_, usage := flag.UnquoteUsage(f)
+		b, ok := f.Value.(interface{ IsBoolFlag() bool })
+		flags = append(flags, completionFlag{Name: f.Name, Usage: usage, Valued: !ok || !b.IsBoolFlag()})
+	})
+	return flags
 }
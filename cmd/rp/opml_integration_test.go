@@ -23,7 +23,7 @@ func TestOPMLRoundTrip(t *testing.T) {
 		ConfigPath: configPath,
 		Output:     &bytes.Buffer{},
 	}
-	if err := cmdInit(initOpts); err != nil {
+	if err := cmdInit(context.Background(), initOpts); err != nil {
 		t.Fatalf("cmdInit failed: %v", err)
 	}
 
@@ -43,7 +43,7 @@ func TestOPMLRoundTrip(t *testing.T) {
 			ConfigPath: configPath,
 			Output:     &bytes.Buffer{},
 		}
-		if err := cmdAddFeed(addOpts); err != nil {
+		if err := cmdAddFeed(context.Background(), addOpts); err != nil {
 			t.Fatalf("cmdAddFeed failed for %s: %v", feed.url, err)
 		}
 	}
@@ -55,7 +55,7 @@ func TestOPMLRoundTrip(t *testing.T) {
 		OutputFile: exportPath,
 		Output:     &bytes.Buffer{},
 	}
-	if err := cmdExportOPML(exportOpts); err != nil {
+	if err := cmdExportOPML(context.Background(), exportOpts); err != nil {
 		t.Fatalf("cmdExportOPML failed: %v", err)
 	}
 
@@ -84,7 +84,7 @@ func TestOPMLRoundTrip(t *testing.T) {
 		ConfigPath: configPath2,
 		Output:     &bytes.Buffer{},
 	}
-	if err := cmdInit(initOpts2); err != nil {
+	if err := cmdInit(context.Background(), initOpts2); err != nil {
 		t.Fatalf("cmdInit failed for import test: %v", err)
 	}
 
@@ -95,7 +95,7 @@ func TestOPMLRoundTrip(t *testing.T) {
 		DryRun:     false,
 		Output:     &bytes.Buffer{},
 	}
-	if err := cmdImportOPML(importOpts); err != nil {
+	if err := cmdImportOPML(context.Background(), importOpts); err != nil {
 		t.Fatalf("cmdImportOPML failed: %v", err)
 	}
 
@@ -105,7 +105,7 @@ func TestOPMLRoundTrip(t *testing.T) {
 		ConfigPath: configPath2,
 		Output:     &listBuf,
 	}
-	if err := cmdListFeeds(listOpts); err != nil {
+	if err := cmdListFeeds(context.Background(), listOpts); err != nil {
 		t.Fatalf("cmdListFeeds failed: %v", err)
 	}
 
@@ -131,7 +131,7 @@ func TestOPMLImportRealWorldFile(t *testing.T) {
 		ConfigPath: configPath,
 		Output:     &bytes.Buffer{},
 	}
-	if err := cmdInit(initOpts); err != nil {
+	if err := cmdInit(context.Background(), initOpts); err != nil {
 		t.Fatalf("cmdInit failed: %v", err)
 	}
 
@@ -172,7 +172,7 @@ func TestOPMLImportRealWorldFile(t *testing.T) {
 		DryRun:     false,
 		Output:     &importBuf,
 	}
-	if err := cmdImportOPML(importOpts); err != nil {
+	if err := cmdImportOPML(context.Background(), importOpts); err != nil {
 		t.Fatalf("cmdImportOPML failed: %v", err)
 	}
 
@@ -189,7 +189,7 @@ func TestOPMLImportRealWorldFile(t *testing.T) {
 		ConfigPath: configPath,
 		Output:     &listBuf,
 	}
-	if err := cmdListFeeds(listOpts); err != nil {
+	if err := cmdListFeeds(context.Background(), listOpts); err != nil {
 		t.Fatalf("cmdListFeeds failed: %v", err)
 	}
 
@@ -220,7 +220,7 @@ func TestOPMLImportDryRun(t *testing.T) {
 		ConfigPath: configPath,
 		Output:     &bytes.Buffer{},
 	}
-	if err := cmdInit(initOpts); err != nil {
+	if err := cmdInit(context.Background(), initOpts); err != nil {
 		t.Fatalf("cmdInit failed: %v", err)
 	}
 
@@ -249,7 +249,7 @@ func TestOPMLImportDryRun(t *testing.T) {
 		DryRun:     true,
 		Output:     &dryRunBuf,
 	}
-	if err := cmdImportOPML(dryRunOpts); err != nil {
+	if err := cmdImportOPML(context.Background(), dryRunOpts); err != nil {
 		t.Fatalf("cmdImportOPML dry run failed: %v", err)
 	}
 
@@ -270,7 +270,7 @@ func TestOPMLImportDryRun(t *testing.T) {
 		ConfigPath: configPath,
 		Output:     &listBuf,
 	}
-	if err := cmdListFeeds(listOpts); err != nil {
+	if err := cmdListFeeds(context.Background(), listOpts); err != nil {
 		t.Fatalf("cmdListFeeds failed: %v", err)
 	}
 
@@ -293,7 +293,7 @@ func TestOPMLImportDuplicateDetection(t *testing.T) {
 		ConfigPath: configPath,
 		Output:     &bytes.Buffer{},
 	}
-	if err := cmdInit(initOpts); err != nil {
+	if err := cmdInit(context.Background(), initOpts); err != nil {
 		t.Fatalf("cmdInit failed: %v", err)
 	}
 
@@ -303,7 +303,7 @@ func TestOPMLImportDuplicateDetection(t *testing.T) {
 		ConfigPath: configPath,
 		Output:     &bytes.Buffer{},
 	}
-	if err := cmdAddFeed(addOpts); err != nil {
+	if err := cmdAddFeed(context.Background(), addOpts); err != nil {
 		t.Fatalf("cmdAddFeed failed: %v", err)
 	}
 
@@ -332,7 +332,7 @@ func TestOPMLImportDuplicateDetection(t *testing.T) {
 		DryRun:     false,
 		Output:     &importBuf,
 	}
-	if err := cmdImportOPML(importOpts); err != nil {
+	if err := cmdImportOPML(context.Background(), importOpts); err != nil {
 		t.Fatalf("cmdImportOPML failed: %v", err)
 	}
 
@@ -404,7 +404,7 @@ func TestOPMLExportToStdout(t *testing.T) {
 		ConfigPath: configPath,
 		Output:     &bytes.Buffer{},
 	}
-	if err := cmdInit(initOpts); err != nil {
+	if err := cmdInit(context.Background(), initOpts); err != nil {
 		t.Fatalf("cmdInit failed: %v", err)
 	}
 
@@ -413,7 +413,7 @@ func TestOPMLExportToStdout(t *testing.T) {
 		ConfigPath: configPath,
 		Output:     &bytes.Buffer{},
 	}
-	if err := cmdAddFeed(addOpts); err != nil {
+	if err := cmdAddFeed(context.Background(), addOpts); err != nil {
 		t.Fatalf("cmdAddFeed failed: %v", err)
 	}
 
@@ -424,7 +424,7 @@ func TestOPMLExportToStdout(t *testing.T) {
 		OutputFile: "", // stdout
 		Output:     &exportBuf,
 	}
-	if err := cmdExportOPML(exportOpts); err != nil {
+	if err := cmdExportOPML(context.Background(), exportOpts); err != nil {
 		t.Fatalf("cmdExportOPML failed: %v", err)
 	}
 
@@ -443,3 +443,99 @@ func TestOPMLExportToStdout(t *testing.T) {
 		t.Error("Export to stdout should contain the feed URL")
 	}
 }
+
+func TestOPMLCategoriesAndHealthRoundTrip(t *testing.T) {
+	dir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	configPath := filepath.Join(dir, "config.ini")
+	if err := cmdInit(context.Background(), InitOptions{ConfigPath: configPath, Output: &bytes.Buffer{}}); err != nil {
+		t.Fatalf("cmdInit failed: %v", err)
+	}
+
+	opmlPath := filepath.Join(dir, "categories.opml")
+	opmlContent := `<?xml version="1.0" encoding="UTF-8"?>
+<opml version="2.0">
+  <head><title>Categorized</title></head>
+  <body>
+    <outline text="Tech">
+      <outline text="Go">
+        <outline text="Go Blog" type="rss" xmlUrl="https://go.dev/blog/feed.atom"/>
+      </outline>
+      <outline text="Rust Blog" type="rss" xmlUrl="https://blog.rust-lang.org/feed.xml" category="/Community"/>
+    </outline>
+    <outline text="Loose Feed" type="rss" xmlUrl="https://example.com/feed.xml"/>
+  </body>
+</opml>`
+	if err := os.WriteFile(opmlPath, []byte(opmlContent), 0644); err != nil {
+		t.Fatalf("Failed to write OPML: %v", err)
+	}
+
+	var importBuf bytes.Buffer
+	if err := cmdImportOPML(context.Background(), ImportOPMLOptions{OPMLFile: opmlPath, ConfigPath: configPath, Output: &importBuf}); err != nil {
+		t.Fatalf("cmdImportOPML failed: %v", err)
+	}
+	if !strings.Contains(importBuf.String(), "[Tech, Community]") {
+		t.Errorf("Import output should list categories:\n%s", importBuf.String())
+	}
+
+	// Categories are stored in the repository
+	_, repo, repoCleanup, err := openConfigAndRepo(configPath)
+	if err != nil {
+		t.Fatalf("openConfigAndRepo failed: %v", err)
+	}
+	ctx := context.Background()
+	rust, err := repo.GetFeedByURL(ctx, "https://blog.rust-lang.org/feed.xml")
+	if err != nil {
+		t.Fatalf("GetFeedByURL failed: %v", err)
+	}
+	categories, err := repo.GetFeedCategories(ctx, rust.ID)
+	if err != nil {
+		t.Fatalf("GetFeedCategories failed: %v", err)
+	}
+	if strings.Join(categories, ",") != "Community,Tech" {
+		t.Errorf("Stored categories = %v, want [Community Tech]", categories)
+	}
+	if err := repo.UpdateFeedError(ctx, rust.ID, "HTTP 500"); err != nil {
+		t.Fatalf("UpdateFeedError failed: %v", err)
+	}
+	repoCleanup()
+
+	// Export nests feeds by category and includes health when requested
+	var exportBuf bytes.Buffer
+	if err := cmdExportOPML(context.Background(), ExportOPMLOptions{ConfigPath: configPath, Health: true, Output: &exportBuf}); err != nil {
+		t.Fatalf("cmdExportOPML failed: %v", err)
+	}
+
+	doc, err := opml.Parse(exportBuf.Bytes())
+	if err != nil {
+		t.Fatalf("Failed to parse exported OPML: %v", err)
+	}
+	exported := doc.ExtractFeeds()
+	if len(exported) != 3 {
+		t.Fatalf("Expected 3 exported feeds, got %d", len(exported))
+	}
+
+	byURL := make(map[string]opml.Feed)
+	for _, f := range exported {
+		byURL[f.FeedURL] = f
+	}
+	if got := byURL["https://go.dev/blog/feed.atom"].Categories; strings.Join(got, ",") != "Tech/Go" {
+		t.Errorf("Go Blog categories = %v, want [Tech/Go]", got)
+	}
+	if got := byURL["https://blog.rust-lang.org/feed.xml"]; got.ErrorCount != 1 || got.LastError != "HTTP 500" {
+		t.Errorf("Rust Blog health = %d/%q, want 1/HTTP 500", got.ErrorCount, got.LastError)
+	}
+	if got := byURL["https://example.com/feed.xml"].Categories; len(got) != 0 {
+		t.Errorf("Loose feed categories = %v, want none", got)
+	}
+
+	// Health attributes are opt-in
+	exportBuf.Reset()
+	if err := cmdExportOPML(context.Background(), ExportOPMLOptions{ConfigPath: configPath, Output: &exportBuf}); err != nil {
+		t.Fatalf("cmdExportOPML failed: %v", err)
+	}
+	if strings.Contains(exportBuf.String(), "errorCount=") {
+		t.Error("Export without --health should not include health attributes")
+	}
+}
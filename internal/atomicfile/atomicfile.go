@@ -0,0 +1,37 @@
+// Package atomicfile replaces files so that a reader, or a crash partway
+// through, sees the old contents or the new, never part of either.
+package atomicfile
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// Write replaces path with data by writing a temporary file next to it and
+// renaming that into place. An existing file keeps its permissions; a new
+// one is created with perm.
+func Write(path string, data []byte, perm os.FileMode) error {
+	if info, err := os.Stat(path); err == nil {
+		perm = info.Mode().Perm()
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, writeErr := tmp.Write(data)
+	closeErr := tmp.Close()
+	if writeErr == nil {
+		writeErr = closeErr
+	}
+	if writeErr == nil {
+		writeErr = os.Chmod(tmp.Name(), perm)
+	}
+	if writeErr == nil {
+		writeErr = os.Rename(tmp.Name(), path)
+	}
+	if writeErr != nil {
+		os.Remove(tmp.Name())
+		return writeErr
+	}
+	return nil
+}
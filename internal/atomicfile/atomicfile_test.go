@@ -0,0 +1,44 @@
+package atomicfile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.ini")
+
+	if err := Write(path, []byte("v1"), 0600); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("new file mode = %v, %v; want 0600", info.Mode().Perm(), err)
+	}
+
+	// Replacing a file keeps the permissions it was given
+	if err := os.Chmod(path, 0640); err != nil {
+		t.Fatal(err)
+	}
+	if err := Write(path, []byte("v2"), 0600); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || string(data) != "v2" {
+		t.Errorf("contents = %q, %v; want v2", data, err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0640 {
+		t.Errorf("replaced file mode = %v, %v; want 0640", info.Mode().Perm(), err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) != 1 {
+		t.Errorf("directory holds %v, %v; want only config.ini", entries, err)
+	}
+
+	if err := Write(filepath.Join(dir, "missing", "x"), nil, 0644); err == nil {
+		t.Error("Write() into a missing directory succeeded")
+	}
+}
@@ -0,0 +1,444 @@
+// Package admin is the HTTP API rp serve offers for managing a planet while
+// it runs: listing, adding, and removing feeds, starting fetches, and
+// reading the planet's status, as the rp commands of the same names do.
+//
+// Every request must carry the configured token as a bearer token:
+//
+//	Authorization: Bearer <token>
+//
+// The API also serves a personal reader's state: unread entries, starred
+// entries, and marking them.
+//
+// Requests and responses are JSON. Errors are reported with an HTTP status
+// and a body of the form {"error": "..."}.
+//
+// UI is a web page built on the API, for managing the planet from a browser.
+package admin
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/adewale/rogue_planet/pkg/feedurl"
+	"github.com/adewale/rogue_planet/pkg/planet"
+	"github.com/adewale/rogue_planet/pkg/repository"
+)
+
+// Prefix is the path the API is served under
+const Prefix = "/api/"
+
+// maxRequestBody limits the size of request bodies
+const maxRequestBody = 64 << 10
+
+// Feed is a feed as the API describes it
+type Feed struct {
+	ID          int64     `json:"id"`
+	URL         string    `json:"url"`
+	Title       string    `json:"title"`
+	Link        string    `json:"link,omitempty"`
+	Active      bool      `json:"active"`
+	Categories  []string  `json:"categories,omitempty"`
+	LastFetched time.Time `json:"last_fetched,omitzero"`
+	Errors      int       `json:"errors"` // Consecutive failed fetches
+	LastError   string    `json:"last_error,omitempty"`
+	Gone        bool      `json:"gone"`
+	Health      string    `json:"health"` // "ok", "new", "failing", "inactive", or "gone"
+}
+
+// Fetch is a fetch of a feed, from its fetch history
+type Fetch struct {
+	FetchedAt    time.Time `json:"fetched_at"`
+	Status       int       `json:"status,omitempty"` // HTTP status; absent if there was no response
+	Error        string    `json:"error,omitempty"`
+	DurationMS   int64     `json:"duration_ms"`
+	EntriesAdded int       `json:"entries_added"`
+}
+
+// Fetch history sizes for GET /api/feeds/{id}/fetches
+const (
+	defaultFetches = 20
+	maxFetches     = 100
+)
+
+// Run is a fetch or update started through the API
+type Run struct {
+	Command  string    `json:"command"` // "fetch" or "update"
+	Running  bool      `json:"running"`
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished,omitzero"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// Status describes the planet
+type Status struct {
+	Name         string `json:"name"`
+	Feeds        int    `json:"feeds"`
+	ActiveFeeds  int    `json:"active_feeds"`
+	FailingFeeds int    `json:"failing_feeds"` // Active feeds whose last fetch failed
+	GoneFeeds    int    `json:"gone_feeds"`
+	Entries      int64  `json:"entries"`
+	LastRun      *Run   `json:"last_run,omitempty"` // The last run started through the API
+}
+
+// addFeedRequest is the body of POST /api/feeds
+type addFeedRequest struct {
+	URL   string `json:"url"`
+	Fetch bool   `json:"fetch"` // Fetch the feed now, and only add it if that succeeds
+}
+
+// runRequest is the optional body of POST /api/fetch and /api/update. Its
+// fields are the flags of rp fetch and rp update.
+type runRequest struct {
+	Feeds      []string `json:"feeds"` // Feed URLs or globs
+	Tags       []string `json:"tags"`
+	OnlyErrors bool     `json:"only_errors"`
+	Force      bool     `json:"force"`
+}
+
+// API serves the admin API of a planet
+type API struct {
+	ctx    context.Context
+	planet *planet.Planet
+	token  []byte
+	mux    *http.ServeMux
+
+	mu      sync.Mutex
+	lastRun *Run
+	runs    sync.WaitGroup
+}
+
+// New returns the admin API of p, accepting requests bearing token. Fetches
+// it starts run in the background until done or ctx is cancelled.
+func New(ctx context.Context, p *planet.Planet, token string) *API {
+	a := &API{ctx: ctx, planet: p, token: []byte(token), mux: http.NewServeMux()}
+	a.mux.HandleFunc("GET /api/status", a.status)
+	a.mux.HandleFunc("GET /api/feeds", a.listFeeds)
+	a.mux.HandleFunc("POST /api/feeds", a.addFeed)
+	a.mux.HandleFunc("GET /api/feeds/{id}", a.getFeed)
+	a.mux.HandleFunc("DELETE /api/feeds/{id}", a.removeFeed)
+	a.mux.HandleFunc("GET /api/feeds/{id}/fetches", a.feedFetches)
+	a.mux.HandleFunc("POST /api/feeds/{id}/reactivate", a.reactivateFeed)
+	a.mux.HandleFunc("POST /api/fetch", a.startRun)
+	a.mux.HandleFunc("POST /api/update", a.startRun)
+	a.mux.HandleFunc("GET /api/unread", a.listUnread)
+	a.mux.HandleFunc("GET /api/starred", a.listStarred)
+	a.mux.HandleFunc("POST /api/read", a.markEntries)
+	a.mux.HandleFunc("POST /api/unread", a.markEntries)
+	a.mux.HandleFunc("POST /api/star", a.markEntries)
+	a.mux.HandleFunc("POST /api/unstar", a.markEntries)
+	a.mux.HandleFunc(Prefix, func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusNotFound, "no such endpoint")
+	})
+	return a
+}
+
+// ServeHTTP serves a request to the API, once its token is checked
+func (a *API) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), a.token) != 1 {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="rp admin"`)
+		writeError(w, http.StatusUnauthorized, "missing or wrong token")
+		return
+	}
+	a.mux.ServeHTTP(w, r)
+}
+
+// Wait waits for the fetches started through the API to end
+func (a *API) Wait() {
+	a.runs.Wait()
+}
+
+func (a *API) status(w http.ResponseWriter, r *http.Request) {
+	repo := a.planet.Repository()
+	feeds, err := repo.GetFeeds(r.Context(), false)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	entries, err := repo.CountEntries(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	status := Status{Name: a.planet.Config().Planet.Name, Feeds: len(feeds), Entries: entries}
+	for _, f := range feeds {
+		switch {
+		case !f.GoneAt.IsZero():
+			status.GoneFeeds++
+		case f.Active:
+			status.ActiveFeeds++
+			if f.FetchErrorCount > 0 {
+				status.FailingFeeds++
+			}
+		}
+	}
+	a.mu.Lock()
+	if a.lastRun != nil {
+		run := *a.lastRun
+		status.LastRun = &run
+	}
+	a.mu.Unlock()
+	writeJSON(w, http.StatusOK, status)
+}
+
+func (a *API) listFeeds(w http.ResponseWriter, r *http.Request) {
+	repo := a.planet.Repository()
+	feeds, err := repo.GetFeeds(r.Context(), false)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	categories, err := repo.GetAllFeedCategories(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	list := make([]Feed, 0, len(feeds))
+	for _, f := range feeds {
+		list = append(list, apiFeed(f, categories[f.ID]))
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+func (a *API) getFeed(w http.ResponseWriter, r *http.Request) {
+	feed, ok := a.feed(w, r)
+	if !ok {
+		return
+	}
+	categories, err := a.planet.Repository().GetFeedCategories(r.Context(), feed.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, apiFeed(*feed, categories))
+}
+
+func (a *API) addFeed(w http.ResponseWriter, r *http.Request) {
+	var req addFeedRequest
+	if err := readJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !strings.HasPrefix(req.URL, "http://") && !strings.HasPrefix(req.URL, "https://") {
+		writeError(w, http.StatusBadRequest, "url must be an http:// or https:// URL")
+		return
+	}
+	// AddFeed stores the URL in this spelling
+	url, err := feedurl.Normalize(req.URL)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if _, err := a.planet.Repository().GetFeedByURL(r.Context(), url); err == nil {
+		writeError(w, http.StatusConflict, "the planet already has this feed")
+		return
+	}
+
+	added, err := a.planet.AddFeed(r.Context(), url, req.Fetch)
+	if err != nil {
+		// With fetch set, the feed itself is at fault
+		code := http.StatusInternalServerError
+		if req.Fetch {
+			code = http.StatusUnprocessableEntity
+		}
+		writeError(w, code, fmt.Sprintf("feed not added: %v", err))
+		return
+	}
+	writeJSON(w, http.StatusCreated, apiFeed(added.Feed, nil))
+}
+
+func (a *API) removeFeed(w http.ResponseWriter, r *http.Request) {
+	feed, ok := a.feed(w, r)
+	if !ok {
+		return
+	}
+	if err := a.planet.Repository().RemoveFeed(r.Context(), feed.ID); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *API) reactivateFeed(w http.ResponseWriter, r *http.Request) {
+	feed, ok := a.feed(w, r)
+	if !ok {
+		return
+	}
+	repo := a.planet.Repository()
+	if err := repo.ReactivateFeed(r.Context(), feed.ID); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if feed, err := repo.GetFeedByID(r.Context(), feed.ID); err == nil {
+		writeJSON(w, http.StatusOK, apiFeed(*feed, nil))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// feedFetches lists a feed's latest fetches, newest first, up to the limit
+// query parameter
+func (a *API) feedFetches(w http.ResponseWriter, r *http.Request) {
+	limit := defaultFetches
+	if s := r.URL.Query().Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > maxFetches {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxFetches))
+			return
+		}
+		limit = n
+	}
+	feed, ok := a.feed(w, r)
+	if !ok {
+		return
+	}
+	log, err := a.planet.Repository().GetFetchLog(r.Context(), feed.ID, limit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	fetches := make([]Fetch, 0, len(log))
+	for _, l := range log {
+		fetches = append(fetches, Fetch{
+			FetchedAt:    l.FetchedAt,
+			Status:       l.StatusCode,
+			Error:        l.Error,
+			DurationMS:   l.Duration.Milliseconds(),
+			EntriesAdded: l.EntriesAdded,
+		})
+	}
+	writeJSON(w, http.StatusOK, fetches)
+}
+
+// feed returns the feed the request's path names, or writes the error
+func (a *API) feed(w http.ResponseWriter, r *http.Request) (*repository.Feed, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "feed ID must be a number")
+		return nil, false
+	}
+	feed, err := a.planet.Repository().GetFeedByID(r.Context(), id)
+	if errors.Is(err, repository.ErrFeedNotFound) {
+		writeError(w, http.StatusNotFound, "feed not found")
+		return nil, false
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return nil, false
+	}
+	return feed, true
+}
+
+// startRun starts a fetch, for POST /api/fetch, or a fetch and generation,
+// for POST /api/update, in the background. It refuses while the last one
+// started through the API is still running.
+func (a *API) startRun(w http.ResponseWriter, r *http.Request) {
+	var req runRequest
+	if err := readJSON(r, &req); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	command := strings.TrimPrefix(r.URL.Path, Prefix)
+	opts := planet.FetchOptions{
+		Force:     req.Force,
+		Selection: planet.Selection{Patterns: req.Feeds, Tags: req.Tags, OnlyErrors: req.OnlyErrors},
+	}
+
+	a.mu.Lock()
+	if a.lastRun != nil && a.lastRun.Running {
+		a.mu.Unlock()
+		writeError(w, http.StatusConflict, "a "+a.lastRun.Command+" started through the API is still running")
+		return
+	}
+	run := &Run{Command: command, Running: true, Started: time.Now()}
+	a.lastRun = run
+	accepted := *run
+	a.runs.Add(1)
+	a.mu.Unlock()
+
+	go func() {
+		defer a.runs.Done()
+		var err error
+		if command == "update" {
+			err = a.planet.Update(a.ctx, planet.UpdateOptions{Fetch: opts})
+		} else {
+			err = a.planet.Fetch(a.ctx, opts)
+		}
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		run.Running, run.Finished = false, time.Now()
+		if err != nil {
+			run.Error = err.Error()
+		}
+	}()
+	writeJSON(w, http.StatusAccepted, accepted)
+}
+
+// apiFeed describes a stored feed for the API
+func apiFeed(f repository.Feed, categories []string) Feed {
+	return Feed{
+		ID:          f.ID,
+		URL:         f.URL,
+		Title:       f.Title,
+		Link:        f.Link,
+		Active:      f.Active,
+		Categories:  categories,
+		LastFetched: f.LastFetched,
+		Errors:      f.FetchErrorCount,
+		LastError:   f.FetchError,
+		Gone:        !f.GoneAt.IsZero(),
+		Health:      health(f),
+	}
+}
+
+// health sums up a feed's state
+func health(f repository.Feed) string {
+	switch {
+	case !f.GoneAt.IsZero():
+		return "gone"
+	case !f.Active:
+		return "inactive"
+	case f.FetchErrorCount > 0:
+		return "failing"
+	case f.LastFetched.IsZero():
+		return "new"
+	}
+	return "ok"
+}
+
+// readJSON decodes the request's JSON body into v. An empty body is
+// reported as io.EOF.
+func readJSON(r *http.Request, v any) error {
+	dec := json.NewDecoder(io.LimitReader(r.Body, maxRequestBody))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		if errors.Is(err, io.EOF) {
+			return err
+		}
+		return fmt.Errorf("invalid request body: %w", err)
+	}
+	return nil
+}
+
+// writeJSON writes v as the JSON response with status code
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes an error response
+func writeError(w http.ResponseWriter, code int, message string) {
+	writeJSON(w, code, map[string]string{"error": message})
+}
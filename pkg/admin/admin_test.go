@@ -0,0 +1,220 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/adewale/rogue_planet/pkg/config"
+	"github.com/adewale/rogue_planet/pkg/planet"
+)
+
+const testToken = "0123456789abcdef"
+
+// newAPI returns the API of a planet in a temporary directory, waiting for
+// the runs it starts when the test ends
+func newAPI(t *testing.T) (*API, *planet.Planet) {
+	t.Helper()
+	dir := t.TempDir()
+	cfg := config.Default()
+	cfg.Planet.Name = "Test Planet"
+	cfg.Planet.OutputDir = filepath.Join(dir, "public")
+	cfg.Database.Path = filepath.Join(dir, "planet.db")
+	p, err := planet.Open(cfg, planet.Options{})
+	if err != nil {
+		t.Fatalf("planet.Open() error = %v", err)
+	}
+	api := New(context.Background(), p, testToken)
+	t.Cleanup(func() {
+		api.Wait()
+		p.Close()
+	})
+	return api, p
+}
+
+// do sends a request with the test token to api
+func do(api *API, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	rec := httptest.NewRecorder()
+	api.ServeHTTP(rec, req)
+	return rec
+}
+
+func decode[T any](t *testing.T, rec *httptest.ResponseRecorder) T {
+	t.Helper()
+	var v T
+	if err := json.Unmarshal(rec.Body.Bytes(), &v); err != nil {
+		t.Fatalf("response %q: %v", rec.Body.String(), err)
+	}
+	return v
+}
+
+func TestAuthorization(t *testing.T) {
+	t.Parallel()
+	api, _ := newAPI(t)
+
+	tests := []struct {
+		name   string
+		header string
+	}{
+		{"no header", ""},
+		{"wrong token", "Bearer wrong"},
+		{"not bearer", "Basic " + testToken},
+		{"token prefix", "Bearer " + testToken[:8]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/status", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			api.ServeHTTP(rec, req)
+			if rec.Code != http.StatusUnauthorized || rec.Header().Get("WWW-Authenticate") == "" {
+				t.Errorf("status = %d, WWW-Authenticate %q", rec.Code, rec.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+
+	if rec := do(api, http.MethodGet, "/api/status", ""); rec.Code != http.StatusOK {
+		t.Errorf("GET /api/status with the token = %d %s", rec.Code, rec.Body.String())
+	}
+	if rec := do(api, http.MethodGet, "/api/nothing", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("GET /api/nothing = %d", rec.Code)
+	}
+}
+
+func TestFeeds(t *testing.T) {
+	t.Parallel()
+	api, p := newAPI(t)
+
+	rec := do(api, http.MethodPost, "/api/feeds", `{"url": "https://blog.example.com/feed"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("POST /api/feeds = %d %s", rec.Code, rec.Body.String())
+	}
+	added := decode[Feed](t, rec)
+	if added.ID == 0 || added.URL != "https://blog.example.com/feed" || !added.Active || added.Health != "new" {
+		t.Errorf("added feed = %+v", added)
+	}
+
+	for _, body := range []string{
+		`{"url": "https://blog.example.com/feed"}`,
+		`{"url": "ftp://example.com/feed"}`,
+		`{"url": "https://example.com/", "extra": 1}`,
+		`not json`,
+	} {
+		if rec := do(api, http.MethodPost, "/api/feeds", body); rec.Code < 400 || rec.Code >= 500 {
+			t.Errorf("POST /api/feeds %s = %d, want a client error", body, rec.Code)
+		}
+	}
+
+	if err := p.Repository().SetFeedCategories(context.Background(), added.ID, []string{"go"}); err != nil {
+		t.Fatal(err)
+	}
+	rec = do(api, http.MethodGet, "/api/feeds", "")
+	feeds := decode[[]Feed](t, rec)
+	if len(feeds) != 1 || feeds[0].ID != added.ID || len(feeds[0].Categories) != 1 {
+		t.Errorf("GET /api/feeds = %+v", feeds)
+	}
+
+	path := "/api/feeds/" + strconv.FormatInt(added.ID, 10)
+	if rec := do(api, http.MethodGet, path, ""); rec.Code != http.StatusOK || decode[Feed](t, rec).URL != added.URL {
+		t.Errorf("GET %s = %d %s", path, rec.Code, rec.Body.String())
+	}
+	if rec := do(api, http.MethodGet, path+"/fetches", ""); rec.Code != http.StatusOK || len(decode[[]Fetch](t, rec)) != 0 {
+		t.Errorf("GET %s/fetches = %d %s, want no fetches", path, rec.Code, rec.Body.String())
+	}
+	if rec := do(api, http.MethodGet, path+"/fetches?limit=0", ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("GET %s/fetches?limit=0 = %d", path, rec.Code)
+	}
+	if rec := do(api, http.MethodPost, path+"/reactivate", ""); rec.Code != http.StatusOK {
+		t.Errorf("POST %s/reactivate = %d %s", path, rec.Code, rec.Body.String())
+	}
+	if rec := do(api, http.MethodDelete, path, ""); rec.Code != http.StatusNoContent {
+		t.Errorf("DELETE %s = %d %s", path, rec.Code, rec.Body.String())
+	}
+	if rec := do(api, http.MethodGet, path, ""); rec.Code != http.StatusNotFound {
+		t.Errorf("GET %s after removal = %d", path, rec.Code)
+	}
+	if rec := do(api, http.MethodGet, "/api/feeds/abc", ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("GET /api/feeds/abc = %d", rec.Code)
+	}
+}
+
+func TestStatusAndRuns(t *testing.T) {
+	t.Parallel()
+	api, _ := newAPI(t)
+
+	if rec := do(api, http.MethodPost, "/api/feeds", `{"url": "https://blog.example.com/feed"}`); rec.Code != http.StatusCreated {
+		t.Fatalf("POST /api/feeds = %d %s", rec.Code, rec.Body.String())
+	}
+	status := decode[Status](t, do(api, http.MethodGet, "/api/status", ""))
+	if status.Name != "Test Planet" || status.Feeds != 1 || status.ActiveFeeds != 1 || status.LastRun != nil {
+		t.Errorf("status = %+v", status)
+	}
+
+	// A selection matching no feed fails without touching the network
+	rec := do(api, http.MethodPost, "/api/fetch", `{"feeds": ["https://nowhere.example.com/*"]}`)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("POST /api/fetch = %d %s", rec.Code, rec.Body.String())
+	}
+	if run := decode[Run](t, rec); run.Command != "fetch" || !run.Running {
+		t.Errorf("accepted run = %+v", run)
+	}
+	api.Wait()
+
+	status = decode[Status](t, do(api, http.MethodGet, "/api/status", ""))
+	if run := status.LastRun; run == nil || run.Running || run.Finished.IsZero() || run.Error == "" {
+		t.Errorf("last run = %+v, want a finished run with an error", run)
+	}
+
+	if rec := do(api, http.MethodPost, "/api/update", `{"force": "yes"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("POST /api/update with a bad body = %d", rec.Code)
+	}
+}
+
+func TestUI(t *testing.T) {
+	t.Parallel()
+	ui := UI()
+
+	tests := []struct {
+		method      string
+		path        string
+		wantCode    int
+		contentType string
+		contains    string
+	}{
+		{http.MethodGet, "/admin/", http.StatusOK, "text/html", `<script src="admin.js"`},
+		{http.MethodGet, "/admin/admin.js", http.StatusOK, "text/javascript", `"/api/"`},
+		{http.MethodGet, "/admin/admin.css", http.StatusOK, "text/css", ".health.failing"},
+		{http.MethodGet, "/admin/missing", http.StatusNotFound, "", ""},
+		{http.MethodPost, "/admin/", http.StatusMethodNotAllowed, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			ui.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantCode)
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+			if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, tt.contentType) {
+				t.Errorf("Content-Type = %q, want %s", ct, tt.contentType)
+			}
+			if csp := rec.Header().Get("Content-Security-Policy"); !strings.Contains(csp, "script-src 'self'") {
+				t.Errorf("Content-Security-Policy = %q", csp)
+			}
+			if !strings.Contains(rec.Body.String(), tt.contains) {
+				t.Errorf("body does not contain %q", tt.contains)
+			}
+		})
+	}
+}
@@ -0,0 +1,192 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/adewale/rogue_planet/pkg/repository"
+)
+
+// Unread entry list sizes for GET /api/unread
+const (
+	defaultUnread = 50
+	maxUnread     = 500
+)
+
+// Entry is a stored entry as the API describes it
+type Entry struct {
+	FeedID    int64     `json:"feed_id"`
+	ID        string    `json:"id"` // The entry's ID in its feed
+	Title     string    `json:"title"`
+	Link      string    `json:"link,omitempty"`
+	Author    string    `json:"author,omitempty"`
+	Published time.Time `json:"published"`
+	Content   string    `json:"content,omitempty"` // Sanitized HTML
+}
+
+// Unread is the response to GET /api/unread
+type Unread struct {
+	Total   int64   `json:"total"` // All unread entries, however many are listed
+	Entries []Entry `json:"entries"`
+}
+
+// Starred is a starred entry. Its title and link are from when it was
+// starred, and it is listed even if the entry has since been pruned.
+type Starred struct {
+	FeedID  int64     `json:"feed_id"`
+	FeedURL string    `json:"feed_url"`
+	ID      string    `json:"id"`
+	Title   string    `json:"title"`
+	Link    string    `json:"link,omitempty"`
+	Starred time.Time `json:"starred"`
+}
+
+// Marked is the response to marking entries
+type Marked struct {
+	Marked   int64    `json:"marked"`              // Entries the request applied to
+	NotFound []string `json:"not_found,omitempty"` // Refs matching no entry
+}
+
+// markRequest is the body of POST /api/read, /api/unread, /api/star, and
+// /api/unstar
+type markRequest struct {
+	Refs   []string `json:"refs"`    // Entry links or IDs
+	All    bool     `json:"all"`     // Mark every entry read (POST /api/read only)
+	FeedID int64    `json:"feed_id"` // With all, only this feed's entries
+}
+
+// listUnread lists the newest unread entries, up to the limit query
+// parameter
+func (a *API) listUnread(w http.ResponseWriter, r *http.Request) {
+	limit := defaultUnread
+	if s := r.URL.Query().Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > maxUnread {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxUnread))
+			return
+		}
+		limit = n
+	}
+
+	repo := a.planet.Repository()
+	total, err := repo.CountUnreadEntries(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	entries, err := repo.GetUnreadEntries(r.Context(), limit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	unread := Unread{Total: total, Entries: make([]Entry, 0, len(entries))}
+	for _, e := range entries {
+		unread.Entries = append(unread.Entries, Entry{
+			FeedID:    e.FeedID,
+			ID:        e.EntryID,
+			Title:     e.Title,
+			Link:      e.Link,
+			Author:    e.Author,
+			Published: e.Published,
+			Content:   e.Content,
+		})
+	}
+	writeJSON(w, http.StatusOK, unread)
+}
+
+func (a *API) listStarred(w http.ResponseWriter, r *http.Request) {
+	marked, err := a.planet.Repository().GetStarredEntries(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	starred := make([]Starred, 0, len(marked))
+	for _, m := range marked {
+		starred = append(starred, Starred{
+			FeedID:  m.FeedID,
+			FeedURL: m.FeedURL,
+			ID:      m.EntryID,
+			Title:   m.Title,
+			Link:    m.Link,
+			Starred: m.At,
+		})
+	}
+	writeJSON(w, http.StatusOK, starred)
+}
+
+// markEntries marks the requested entries read, unread, starred, or
+// unstarred, as the path says
+func (a *API) markEntries(w http.ResponseWriter, r *http.Request) {
+	var req markRequest
+	if err := readJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	action := strings.TrimPrefix(r.URL.Path, Prefix)
+	if req.All != (len(req.Refs) == 0) {
+		writeError(w, http.StatusBadRequest, "give refs, or all")
+		return
+	}
+	if (req.All || req.FeedID != 0) && action != "read" {
+		writeError(w, http.StatusBadRequest, "only read takes all and feed_id")
+		return
+	}
+	if req.FeedID != 0 && !req.All {
+		writeError(w, http.StatusBadRequest, "feed_id needs all")
+		return
+	}
+
+	ctx := r.Context()
+	repo := a.planet.Repository()
+	now := time.Now()
+	var marked Marked
+
+	if req.All {
+		if req.FeedID != 0 {
+			if _, err := repo.GetFeedByID(ctx, req.FeedID); errors.Is(err, repository.ErrFeedNotFound) {
+				writeError(w, http.StatusNotFound, "feed not found")
+				return
+			}
+		}
+		n, err := repo.MarkAllRead(ctx, req.FeedID, now)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, Marked{Marked: n})
+		return
+	}
+
+	for _, ref := range req.Refs {
+		var n int64
+		var err error
+		switch action {
+		case "read", "star":
+			mark := repo.MarkRead
+			if action == "star" {
+				mark = repo.StarEntries
+			}
+			var entries []repository.Entry
+			entries, err = mark(ctx, ref, now)
+			n = int64(len(entries))
+		case "unread":
+			n, err = repo.MarkUnread(ctx, ref)
+		case "unstar":
+			n, err = repo.UnstarEntries(ctx, ref)
+		}
+		if errors.Is(err, repository.ErrEntryNotFound) {
+			marked.NotFound = append(marked.NotFound, ref)
+			continue
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		marked.Marked += n
+	}
+	writeJSON(w, http.StatusOK, marked)
+}
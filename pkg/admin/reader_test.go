@@ -0,0 +1,80 @@
+package admin
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/adewale/rogue_planet/pkg/repository"
+)
+
+func TestReaderState(t *testing.T) {
+	t.Parallel()
+	api, p := newAPI(t)
+
+	ctx := context.Background()
+	repo := p.Repository()
+	feedID, err := repo.AddFeed(ctx, "https://blog.example.com/feed", "Blog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	for i, id := range []string{"old", "new"} {
+		published := now.Add(time.Duration(i) * time.Hour)
+		entry := &repository.Entry{FeedID: feedID, EntryID: id, Title: id, Link: "https://blog.example.com/" + id, Content: "<p>" + id + "</p>", Published: published, Updated: published, FirstSeen: now}
+		if err := repo.UpsertEntry(ctx, entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	unread := decode[Unread](t, do(api, http.MethodGet, "/api/unread?limit=1", ""))
+	if unread.Total != 2 || len(unread.Entries) != 1 || unread.Entries[0].ID != "new" || unread.Entries[0].Content != "<p>new</p>" {
+		t.Errorf("GET /api/unread = %+v", unread)
+	}
+	if rec := do(api, http.MethodGet, "/api/unread?limit=0", ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("GET /api/unread?limit=0 = %d", rec.Code)
+	}
+
+	marked := decode[Marked](t, do(api, http.MethodPost, "/api/read", `{"refs": ["https://blog.example.com/new", "nope"]}`))
+	if marked.Marked != 1 || len(marked.NotFound) != 1 || marked.NotFound[0] != "nope" {
+		t.Errorf("POST /api/read = %+v", marked)
+	}
+	if unread := decode[Unread](t, do(api, http.MethodGet, "/api/unread", "")); unread.Total != 1 || unread.Entries[0].ID != "old" {
+		t.Errorf("GET /api/unread after marking one read = %+v", unread)
+	}
+
+	if marked := decode[Marked](t, do(api, http.MethodPost, "/api/unread", `{"refs": ["new"]}`)); marked.Marked != 1 {
+		t.Errorf("POST /api/unread = %+v", marked)
+	}
+	if marked := decode[Marked](t, do(api, http.MethodPost, "/api/read", `{"all": true}`)); marked.Marked != 2 {
+		t.Errorf("POST /api/read all = %+v", marked)
+	}
+	if unread := decode[Unread](t, do(api, http.MethodGet, "/api/unread", "")); unread.Total != 0 || unread.Entries == nil {
+		t.Errorf("GET /api/unread after marking all read = %+v, want an empty list", unread)
+	}
+
+	if marked := decode[Marked](t, do(api, http.MethodPost, "/api/star", `{"refs": ["old"]}`)); marked.Marked != 1 {
+		t.Errorf("POST /api/star = %+v", marked)
+	}
+	starred := decode[[]Starred](t, do(api, http.MethodGet, "/api/starred", ""))
+	if len(starred) != 1 || starred[0].ID != "old" || starred[0].FeedURL != "https://blog.example.com/feed" {
+		t.Errorf("GET /api/starred = %+v", starred)
+	}
+	if marked := decode[Marked](t, do(api, http.MethodPost, "/api/unstar", `{"refs": ["old"]}`)); marked.Marked != 1 {
+		t.Errorf("POST /api/unstar = %+v", marked)
+	}
+
+	for path, body := range map[string]string{
+		"/api/read":   `{}`,
+		"/api/star":   `{"all": true}`,
+		"/api/unread": `{"refs": ["old"], "feed_id": 1}`,
+	} {
+		if rec := do(api, http.MethodPost, path, body); rec.Code != http.StatusBadRequest {
+			t.Errorf("POST %s %s = %d, want 400", path, body, rec.Code)
+		}
+	}
+	if rec := do(api, http.MethodPost, "/api/read", `{"all": true, "feed_id": 999}`); rec.Code != http.StatusNotFound {
+		t.Errorf("POST /api/read for an unknown feed = %d, want 404", rec.Code)
+	}
+}
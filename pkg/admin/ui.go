@@ -0,0 +1,434 @@
+package admin
+
+import (
+	"net/http"
+	"strings"
+)
+
+// UIPrefix is the path the admin UI is served under
+const UIPrefix = "/admin/"
+
+// uiCSP only lets the UI's page load its own script and style and call the
+// API on the same origin
+const uiCSP = "default-src 'none'; script-src 'self'; style-src 'self'; connect-src 'self'; " +
+	"img-src 'self'; form-action 'none'; frame-ancestors 'none'; base-uri 'none'"
+
+// UI returns the admin web UI: a page for people who don't use the CLI to
+// see the health of the feeds, add and remove them, read their recent
+// errors, and refresh the planet. The page itself holds no data; it asks for
+// the admin token and calls the API with it, so it must be served with the
+// API under Prefix on the same origin.
+func UI() http.Handler {
+	files := map[string]struct{ contentType, body string }{
+		"":          {"text/html; charset=utf-8", uiPage},
+		"admin.js":  {"text/javascript; charset=utf-8", uiScript},
+		"admin.css": {"text/css; charset=utf-8", uiStyle},
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		file, ok := files[strings.TrimPrefix(r.URL.Path, UIPrefix)]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		h := w.Header()
+		h.Set("Content-Type", file.contentType)
+		h.Set("Content-Security-Policy", uiCSP)
+		h.Set("X-Content-Type-Options", "nosniff")
+		h.Set("Referrer-Policy", "no-referrer")
+		h.Set("Cache-Control", "no-cache")
+		w.Write([]byte(file.body))
+	})
+}
+
+const uiPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>Planet admin</title>
+<link rel="stylesheet" href="admin.css">
+<script src="admin.js" defer></script>
+</head>
+<body>
+<header>
+  <h1 id="name">Planet admin</h1>
+  <nav id="session" hidden>
+    <button type="button" id="refresh">Refresh now</button>
+    <button type="button" id="signout" class="quiet">Sign out</button>
+  </nav>
+</header>
+
+<main>
+  <form id="signin" hidden>
+    <p>Enter the admin token from the planet's configuration.</p>
+    <label for="token">Admin token</label>
+    <input type="password" id="token" autocomplete="current-password" required>
+    <button type="submit">Sign in</button>
+  </form>
+
+  <div id="app" hidden>
+    <section id="status" aria-live="polite"></section>
+
+    <form id="add">
+      <label for="url">Add a feed</label>
+      <input type="url" id="url" placeholder="https://blog.example.com/feed.xml" required>
+      <label class="check"><input type="checkbox" id="check" checked> Check the feed works first</label>
+      <button type="submit">Add feed</button>
+    </form>
+
+    <p id="message" role="status"></p>
+
+    <label class="check"><input type="checkbox" id="problems"> Only show feeds with problems</label>
+    <table id="feeds">
+      <thead>
+        <tr><th>Health</th><th>Feed</th><th>Last fetched</th><th>Problem</th><th></th></tr>
+      </thead>
+      <tbody></tbody>
+    </table>
+  </div>
+</main>
+</body>
+</html>
+`
+
+const uiScript = `"use strict";
+
+(function () {
+  var tokenKey = "rp-admin-token";
+  var healthLabels = {
+    ok: "OK",
+    "new": "Not fetched yet",
+    failing: "Failing",
+    inactive: "Inactive",
+    gone: "Gone"
+  };
+  var feeds = [];
+  var polling = null;
+
+  function $(id) { return document.getElementById(id); }
+
+  function el(tag, text, className) {
+    var e = document.createElement(tag);
+    if (text) { e.textContent = text; }
+    if (className) { e.className = className; }
+    return e;
+  }
+
+  function when(iso) {
+    return iso ? new Date(iso).toLocaleString() : "Never";
+  }
+
+  function say(text, isError) {
+    var m = $("message");
+    m.textContent = text || "";
+    m.className = isError ? "error" : "";
+  }
+
+  // api calls the admin API, signing out if the token is refused
+  function api(method, path, body) {
+    var opts = {
+      method: method,
+      headers: { "Authorization": "Bearer " + sessionStorage.getItem(tokenKey) }
+    };
+    if (body !== undefined) {
+      opts.headers["Content-Type"] = "application/json";
+      opts.body = JSON.stringify(body);
+    }
+    return fetch("/api/" + path, opts).then(function (resp) {
+      if (resp.status === 401) {
+        signOut("That token was not accepted.");
+        throw new Error("not signed in");
+      }
+      if (resp.status === 204) { return null; }
+      return resp.json().then(function (data) {
+        if (!resp.ok) { throw new Error(data.error || resp.statusText); }
+        return data;
+      });
+    });
+  }
+
+  function showStatus(s) {
+    $("name").textContent = s.name + " admin";
+    document.title = s.name + " admin";
+    var box = $("status");
+    box.textContent = "";
+    var counts = el("p");
+    counts.textContent = s.feeds + " feeds: " + s.active_feeds + " active, " +
+      s.failing_feeds + " failing, " + s.gone_feeds + " gone. " + s.entries + " entries.";
+    box.appendChild(counts);
+
+    var run = s.last_run;
+    var refreshing = run && run.running;
+    $("refresh").disabled = refreshing;
+    if (run) {
+      var text;
+      if (run.running) {
+        text = "Refreshing since " + when(run.started) + "...";
+      } else if (run.error) {
+        text = "The refresh started " + when(run.started) + " failed: " + run.error;
+      } else {
+        text = "Last refreshed " + when(run.finished) + ".";
+      }
+      box.appendChild(el("p", text, run.error ? "error" : ""));
+    }
+
+    if (refreshing && !polling) {
+      polling = setInterval(load, 5000);
+    } else if (!refreshing && polling) {
+      clearInterval(polling);
+      polling = null;
+    }
+  }
+
+  function showHistory(feed, row, button) {
+    var next = row.nextSibling;
+    if (next && next.classList.contains("history")) {
+      next.remove();
+      button.textContent = "History";
+      return;
+    }
+    api("GET", "feeds/" + feed.id + "/fetches").then(function (fetches) {
+      var tr = el("tr", "", "history");
+      var td = el("td");
+      td.colSpan = 5;
+      if (fetches.length === 0) {
+        td.textContent = "No fetches recorded yet.";
+      } else {
+        var list = el("ol");
+        fetches.forEach(function (f) {
+          var text = when(f.fetched_at) + ": ";
+          if (f.error) {
+            text += f.error;
+          } else {
+            text += "HTTP " + f.status + ", " + f.entries_added + " new entries";
+          }
+          list.appendChild(el("li", text, f.error ? "error" : ""));
+        });
+        td.appendChild(list);
+      }
+      tr.appendChild(td);
+      row.after(tr);
+      button.textContent = "Hide history";
+    }).catch(function (err) { say(err.message, true); });
+  }
+
+  function feedRow(feed) {
+    var tr = el("tr");
+
+    var health = el("td");
+    health.appendChild(el("span", healthLabels[feed.health] || feed.health, "health " + feed.health));
+    tr.appendChild(health);
+
+    var name = el("td");
+    name.appendChild(el("strong", feed.title || "(untitled)"));
+    name.appendChild(el("br"));
+    name.appendChild(el("small", feed.url));
+    tr.appendChild(name);
+
+    tr.appendChild(el("td", when(feed.last_fetched)));
+
+    var problem = "";
+    if (feed.last_error) {
+      problem = feed.last_error + " (" + feed.errors + " in a row)";
+    }
+    tr.appendChild(el("td", problem, "error"));
+
+    var actions = el("td", "", "actions");
+    var history = el("button", "History", "quiet");
+    history.type = "button";
+    history.addEventListener("click", function () { showHistory(feed, tr, history); });
+    actions.appendChild(history);
+
+    if (!feed.active) {
+      var reactivate = el("button", "Reactivate");
+      reactivate.type = "button";
+      reactivate.addEventListener("click", function () {
+        api("POST", "feeds/" + feed.id + "/reactivate").then(function () {
+          say("Reactivated " + feed.url + ". It will be fetched on the next refresh.");
+          load();
+        }).catch(function (err) { say(err.message, true); });
+      });
+      actions.appendChild(reactivate);
+    }
+
+    var remove = el("button", "Remove", "danger");
+    remove.type = "button";
+    remove.addEventListener("click", function () {
+      if (!confirm("Remove " + (feed.title || feed.url) + " and all its entries?")) { return; }
+      api("DELETE", "feeds/" + feed.id).then(function () {
+        say("Removed " + feed.url + ".");
+        load();
+      }).catch(function (err) { say(err.message, true); });
+    });
+    actions.appendChild(remove);
+    tr.appendChild(actions);
+    return tr;
+  }
+
+  function showFeeds() {
+    var body = $("feeds").tBodies[0];
+    body.textContent = "";
+    var problemsOnly = $("problems").checked;
+    feeds.forEach(function (feed) {
+      if (problemsOnly && (feed.health === "ok" || feed.health === "new")) { return; }
+      body.appendChild(feedRow(feed));
+    });
+    if (!body.firstChild) {
+      var tr = el("tr");
+      var td = el("td", problemsOnly ? "No feeds have problems." : "No feeds yet.");
+      td.colSpan = 5;
+      tr.appendChild(td);
+      body.appendChild(tr);
+    }
+  }
+
+  function load() {
+    return Promise.all([api("GET", "status"), api("GET", "feeds")]).then(function (results) {
+      showStatus(results[0]);
+      feeds = results[1];
+      feeds.sort(function (a, b) {
+        return (a.title || a.url).localeCompare(b.title || b.url);
+      });
+      showFeeds();
+    });
+  }
+
+  function signIn() {
+    $("signin").hidden = true;
+    $("app").hidden = false;
+    $("session").hidden = false;
+    load().catch(function (err) { say(err.message, true); });
+  }
+
+  function signOut(reason) {
+    sessionStorage.removeItem(tokenKey);
+    if (polling) {
+      clearInterval(polling);
+      polling = null;
+    }
+    $("app").hidden = true;
+    $("session").hidden = true;
+    $("signin").hidden = false;
+    var form = $("signin");
+    var old = form.querySelector(".error");
+    if (old) { old.remove(); }
+    if (reason) { form.appendChild(el("p", reason, "error")); }
+    $("token").focus();
+  }
+
+  document.addEventListener("DOMContentLoaded", function () {
+    $("signin").addEventListener("submit", function (e) {
+      e.preventDefault();
+      sessionStorage.setItem(tokenKey, $("token").value);
+      $("token").value = "";
+      signIn();
+    });
+
+    $("signout").addEventListener("click", function () { signOut(""); });
+
+    $("refresh").addEventListener("click", function () {
+      api("POST", "update").then(function () {
+        say("Refreshing the planet. This page updates when it is done.");
+        return load();
+      }).catch(function (err) { say(err.message, true); });
+    });
+
+    $("add").addEventListener("submit", function (e) {
+      e.preventDefault();
+      var url = $("url").value.trim();
+      var button = e.target.querySelector("button");
+      button.disabled = true;
+      say($("check").checked ? "Checking " + url + "..." : "");
+      api("POST", "feeds", { url: url, fetch: $("check").checked }).then(function (feed) {
+        $("url").value = "";
+        say("Added " + (feed.title || feed.url) + ".");
+        return load();
+      }).catch(function (err) {
+        say(err.message, true);
+      }).finally(function () {
+        button.disabled = false;
+      });
+    });
+
+    $("problems").addEventListener("change", showFeeds);
+
+    if (sessionStorage.getItem(tokenKey)) {
+      signIn();
+    } else {
+      signOut("");
+    }
+  });
+})();
+`
+
+const uiStyle = `body {
+  font-family: system-ui, sans-serif;
+  margin: 0 auto;
+  max-width: 72rem;
+  padding: 1rem;
+  color: #222;
+}
+
+header {
+  display: flex;
+  align-items: center;
+  justify-content: space-between;
+  border-bottom: 1px solid #ddd;
+}
+
+h1 { font-size: 1.5rem; }
+
+label { display: block; font-weight: bold; margin: 0.5rem 0 0.25rem; }
+label.check { display: inline-block; font-weight: normal; }
+
+input[type=url], input[type=password] {
+  width: 100%;
+  max-width: 32rem;
+  padding: 0.4rem;
+  font: inherit;
+}
+
+button {
+  font: inherit;
+  padding: 0.3rem 0.8rem;
+  margin: 0.25rem 0.25rem 0.25rem 0;
+  border: 1px solid #2a5db0;
+  border-radius: 4px;
+  background: #2a5db0;
+  color: #fff;
+  cursor: pointer;
+}
+button:disabled { opacity: 0.5; cursor: default; }
+button.quiet { background: #fff; color: #2a5db0; }
+button.danger { background: #fff; border-color: #b03a2a; color: #b03a2a; }
+
+#add { margin: 1rem 0; }
+
+.error { color: #b03a2a; }
+
+table { width: 100%; border-collapse: collapse; margin-top: 0.5rem; }
+th, td { text-align: left; vertical-align: top; padding: 0.5rem; border-bottom: 1px solid #eee; }
+td small { color: #666; word-break: break-all; }
+td.actions { white-space: nowrap; }
+tr.history td { background: #f7f7f7; }
+tr.history ol { margin: 0; padding-left: 1.5rem; }
+
+.health {
+  display: inline-block;
+  padding: 0.1rem 0.5rem;
+  border-radius: 1rem;
+  font-size: 0.85rem;
+  white-space: nowrap;
+}
+.health.ok { background: #dff3e0; color: #1d6b25; }
+.health.new { background: #e6eefb; color: #2a5db0; }
+.health.failing { background: #fdf0d5; color: #8a5a00; }
+.health.inactive { background: #eee; color: #555; }
+.health.gone { background: #f8dedb; color: #b03a2a; }
+`
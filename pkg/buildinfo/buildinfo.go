@@ -0,0 +1,122 @@
+// Package buildinfo describes the running rp binary for bug reports.
+//
+// Version, Commit, and BuildDate are set at link time via ldflags:
+//
+//	go build -ldflags "-X github.com/adewale/rogue_planet/pkg/buildinfo.Commit=abc123" ./cmd/rp
+//
+// The Go version and build tags are read from the binary's embedded build
+// information, and the VCS revision is used when Commit was not set.
+package buildinfo
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"strings"
+)
+
+// Values injected via -ldflags "-X ...". Defaults apply to plain `go build`.
+var (
+	Version   = "0.4.0"
+	Commit    = ""
+	BuildDate = ""
+)
+
+// Info contains build metadata for the running binary
+type Info struct {
+	Version   string   `json:"version"`
+	Commit    string   `json:"commit,omitempty"`
+	BuildDate string   `json:"build_date,omitempty"`
+	GoVersion string   `json:"go_version"`
+	BuildTags []string `json:"build_tags,omitempty"`
+}
+
+// Get returns build metadata, combining ldflags values with runtime build info
+func Get() Info {
+	info := Info{
+		Version:   Version,
+		Commit:    Commit,
+		BuildDate: BuildDate,
+		GoVersion: runtime.Version(),
+	}
+
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+
+	for _, setting := range bi.Settings {
+		switch setting.Key {
+		case "-tags":
+			info.BuildTags = parseTags(setting.Value)
+		case "vcs.revision":
+			if info.Commit == "" {
+				info.Commit = setting.Value
+			}
+		case "vcs.time":
+			if info.BuildDate == "" {
+				info.BuildDate = setting.Value
+			}
+		}
+	}
+
+	return info
+}
+
+// String returns a one-line summary suitable for logs and HTML comments
+func (i Info) String() string {
+	parts := []string{"rp " + i.Version}
+	if i.Commit != "" {
+		parts = append(parts, "commit "+shortCommit(i.Commit))
+	}
+	if i.BuildDate != "" {
+		parts = append(parts, "built "+i.BuildDate)
+	}
+	parts = append(parts, i.GoVersion)
+	if len(i.BuildTags) > 0 {
+		parts = append(parts, "tags "+strings.Join(i.BuildTags, ","))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Verbose returns a multi-line description for `rp version --verbose`
+func (i Info) Verbose() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "rp version %s\n", i.Version)
+	fmt.Fprintf(&b, "  Commit:     %s\n", orUnknown(i.Commit))
+	fmt.Fprintf(&b, "  Build date: %s\n", orUnknown(i.BuildDate))
+	fmt.Fprintf(&b, "  Go version: %s\n", i.GoVersion)
+	fmt.Fprintf(&b, "  Platform:   %s/%s\n", runtime.GOOS, runtime.GOARCH)
+	tags := "none"
+	if len(i.BuildTags) > 0 {
+		tags = strings.Join(i.BuildTags, ",")
+	}
+	fmt.Fprintf(&b, "  Build tags: %s\n", tags)
+	return b.String()
+}
+
+// parseTags splits the -tags build setting (comma or space separated)
+func parseTags(value string) []string {
+	fields := strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || r == ' '
+	})
+	if len(fields) == 0 {
+		return nil
+	}
+	return fields
+}
+
+// shortCommit abbreviates a full SHA to 12 characters
+func shortCommit(commit string) string {
+	if len(commit) > 12 {
+		return commit[:12]
+	}
+	return commit
+}
+
+func orUnknown(s string) string {
+	if s == "" {
+		return "unknown"
+	}
+	return s
+}
@@ -0,0 +1,74 @@
+package buildinfo
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestGet(t *testing.T) {
+	t.Parallel()
+	info := Get()
+
+	if info.Version != Version {
+		t.Errorf("Version = %q, want %q", info.Version, Version)
+	}
+	if info.GoVersion != runtime.Version() {
+		t.Errorf("GoVersion = %q, want %q", info.GoVersion, runtime.Version())
+	}
+}
+
+func TestInfoString(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		info Info
+		want string
+	}{
+		{
+			name: "minimal",
+			info: Info{Version: "1.0.0", GoVersion: "go1.24.0"},
+			want: "rp 1.0.0, go1.24.0",
+		},
+		{
+			name: "full",
+			info: Info{
+				Version:   "1.0.0",
+				Commit:    "abcdef0123456789",
+				BuildDate: "2025-01-02T03:04:05Z",
+				GoVersion: "go1.24.0",
+				BuildTags: []string{"network", "integration"},
+			},
+			want: "rp 1.0.0, commit abcdef012345, built 2025-01-02T03:04:05Z, go1.24.0, tags network,integration",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.info.String(); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInfoVerbose(t *testing.T) {
+	t.Parallel()
+	out := Info{Version: "1.0.0", GoVersion: "go1.24.0"}.Verbose()
+
+	for _, want := range []string{"rp version 1.0.0", "Commit:     unknown", "Go version: go1.24.0", "Build tags: none"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Verbose() missing %q in:\n%s", want, out)
+		}
+	}
+}
+
+func TestParseTags(t *testing.T) {
+	t.Parallel()
+	if got := parseTags("network,integration"); len(got) != 2 || got[1] != "integration" {
+		t.Errorf("parseTags() = %v", got)
+	}
+	if got := parseTags(""); got != nil {
+		t.Errorf("parseTags(\"\") = %v, want nil", got)
+	}
+}
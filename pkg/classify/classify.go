@@ -0,0 +1,345 @@
+// Package classify tags entries with topics at generation time.
+//
+// A topic matches an entry whose text has one of its keywords, compared
+// case-insensitively as whole words. Topics may also be decided by an HTTP
+// service, which is POSTed a Request as JSON and answers with a Response;
+// it can wrap whichever classification model the operator prefers. Its
+// answers are cached by a hash of the topics and the text, so each text is
+// sent once.
+package classify
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+)
+
+// maxBatch is the most texts sent in one Request
+const maxBatch = 100
+
+// maxResponse limits the size of a Response
+const maxResponse = 16 * 1024 * 1024
+
+// Topic is a topic entries can be tagged with
+type Topic struct {
+	Name     string
+	Keywords []string // Words or phrases that tag an entry with the topic
+}
+
+// Request asks for the topics of Texts. Topics lists the names the answer
+// should choose from; if it is empty, any topic names will do.
+type Request struct {
+	Topics []string `json:"topics"`
+	Texts  []string `json:"texts"`
+}
+
+// Response holds the topics of each of a Request's texts, in the same order
+type Response struct {
+	Topics [][]string `json:"topics"`
+}
+
+// Model decides the topics of plain texts
+type Model interface {
+	Classify(ctx context.Context, req Request) ([][]string, error)
+}
+
+// HTTP POSTs each Request as JSON to a URL
+type HTTP struct {
+	URL    string
+	Client *http.Client // Defaults to a client with a 60 second timeout
+}
+
+// Classify posts the request and reads the topics from the reply
+func (h HTTP) Classify(ctx context.Context, req Request) ([][]string, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("encode classification request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("create classification request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	client := h.Client
+	if client == nil {
+		client = &http.Client{Timeout: 60 * time.Second}
+	}
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("post classification request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+		return nil, fmt.Errorf("post classification request: server returned %s", resp.Status)
+	}
+	var r Response
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponse)).Decode(&r); err != nil {
+		return nil, fmt.Errorf("decode classification response: %w", err)
+	}
+	if len(r.Topics) != len(req.Texts) {
+		return nil, fmt.Errorf("classification response has %d texts, want %d", len(r.Topics), len(req.Texts))
+	}
+	return r.Topics, nil
+}
+
+// Store caches the topics a Model gave texts, keyed by Key
+type Store interface {
+	GetClassifications(ctx context.Context, keys []string) (map[string][]string, error)
+	SaveClassifications(ctx context.Context, classifications map[string][]string) error
+}
+
+// Key identifies a text classified into topics in a Store
+func Key(topics []string, text string) string {
+	h := sha256.New()
+	for _, t := range topics {
+		h.Write([]byte(t))
+		h.Write([]byte{0})
+	}
+	h.Write([]byte{0})
+	h.Write([]byte(text))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Classifier tags texts with topics
+type Classifier struct {
+	topics   []Topic
+	keywords []*regexp.Regexp // Matches the keywords of each topic, nil for none
+	all      *regexp.Regexp   // Matches any keyword, nil for none
+
+	// Model and Store, if set, also tag texts with the topics the model
+	// gives them, cached in the store
+	Model   Model
+	Store   Store
+	Timeout time.Duration // Time limit for each request to the Model (0 = none)
+}
+
+// New returns a Classifier for topics, matching their keywords
+func New(topics []Topic) (*Classifier, error) {
+	c := &Classifier{topics: topics, keywords: make([]*regexp.Regexp, len(topics))}
+	var all []string
+	for i, t := range topics {
+		var quoted []string
+		for _, kw := range t.Keywords {
+			if kw = strings.TrimSpace(kw); kw != "" {
+				quoted = append(quoted, regexp.QuoteMeta(kw))
+			}
+		}
+		if len(quoted) == 0 {
+			continue
+		}
+		re, err := compile(quoted)
+		if err != nil {
+			return nil, fmt.Errorf("topic %s: %w", t.Name, err)
+		}
+		c.keywords[i] = re
+		all = append(all, quoted...)
+	}
+	if len(all) > 0 {
+		re, err := compile(all)
+		if err != nil {
+			return nil, err
+		}
+		c.all = re
+	}
+	return c, nil
+}
+
+// compile returns a case-insensitive regexp matching any of the quoted
+// keywords, preferring the longest
+func compile(quoted []string) (*regexp.Regexp, error) {
+	re, err := regexp.Compile(`(?i)(?:` + strings.Join(quoted, "|") + `)`)
+	if err != nil {
+		return nil, err
+	}
+	re.Longest()
+	return re, nil
+}
+
+// matches returns the indices of re's matches in text that are whole
+// words: not preceded or followed by a letter or number
+func matches(re *regexp.Regexp, text string) [][]int {
+	var words [][]int
+	for _, m := range re.FindAllStringIndex(text, -1) {
+		before, _ := utf8.DecodeLastRuneInString(text[:m[0]])
+		after, _ := utf8.DecodeRuneInString(text[m[1]:])
+		if m[0] > 0 && isWord(before) || m[1] < len(text) && isWord(after) {
+			continue
+		}
+		words = append(words, m)
+	}
+	return words
+}
+
+func isWord(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsNumber(r)
+}
+
+// Classify returns the topics of each plain text: those whose keywords it
+// has, in the order the topics were given, followed by those the Model
+// gives it. Model answers are kept in the Store as they arrive, so a later
+// run resumes where a failed one stopped.
+func (c *Classifier) Classify(ctx context.Context, texts []string) ([][]string, error) {
+	out := make([][]string, len(texts))
+	for i, text := range texts {
+		for j, re := range c.keywords {
+			if re != nil && len(matches(re, text)) > 0 {
+				out[i] = append(out[i], c.topics[j].Name)
+			}
+		}
+	}
+	if c.Model == nil || c.Store == nil {
+		return out, nil
+	}
+
+	names := make([]string, len(c.topics))
+	for i, t := range c.topics {
+		names[i] = t.Name
+	}
+	keys := make([]string, len(texts))
+	for i, text := range texts {
+		keys[i] = Key(names, text)
+	}
+	cached, err := c.Store.GetClassifications(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	// Ask for each missing text once
+	var missing []string
+	seen := make(map[string]bool)
+	for i, text := range texts {
+		if text == "" || seen[keys[i]] {
+			continue
+		}
+		seen[keys[i]] = true
+		if _, ok := cached[keys[i]]; !ok {
+			missing = append(missing, text)
+		}
+	}
+	for start := 0; start < len(missing); start += maxBatch {
+		batch := missing[start:min(start+maxBatch, len(missing))]
+		topics, err := c.classify(ctx, Request{Topics: names, Texts: batch})
+		if err != nil {
+			return nil, err
+		}
+		fresh := make(map[string][]string, len(batch))
+		for i, text := range batch {
+			fresh[Key(names, text)] = topics[i]
+			cached[Key(names, text)] = topics[i]
+		}
+		if err := c.Store.SaveClassifications(ctx, fresh); err != nil {
+			return nil, err
+		}
+	}
+
+	for i := range texts {
+		for _, topic := range cached[keys[i]] {
+			if topic = c.name(topic); topic != "" && !containsFold(out[i], topic) {
+				out[i] = append(out[i], topic)
+			}
+		}
+	}
+	return out, nil
+}
+
+// name returns the configured name of a topic the Model gave, which must
+// be one of them if any were configured, or "" if it is not
+func (c *Classifier) name(topic string) string {
+	topic = strings.TrimSpace(topic)
+	if len(c.topics) == 0 {
+		return topic
+	}
+	for _, t := range c.topics {
+		if strings.EqualFold(t.Name, topic) {
+			return t.Name
+		}
+	}
+	return ""
+}
+
+// classify makes one request to the Model within the time limit
+func (c *Classifier) classify(ctx context.Context, req Request) ([][]string, error) {
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+	topics, err := c.Model.Classify(ctx, req)
+	if c.Timeout > 0 && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return nil, fmt.Errorf("classification timed out after %s", c.Timeout)
+	}
+	return topics, err
+}
+
+func containsFold(names []string, name string) bool {
+	return slices.ContainsFunc(names, func(n string) bool { return strings.EqualFold(n, name) })
+}
+
+// skipHighlight are elements whose text is not highlighted
+var skipHighlight = map[string]bool{"a": true, "code": true, "pre": true, "mark": true, "script": true, "style": true}
+
+// Highlight returns an HTML fragment with the topic keywords in its text
+// wrapped in <mark> elements. Text inside links and code is left alone.
+func (c *Classifier) Highlight(fragment string) string {
+	if c == nil || c.all == nil {
+		return fragment
+	}
+	z := html.NewTokenizer(strings.NewReader(fragment))
+	var b strings.Builder
+	skip := 0
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			return b.String()
+		case html.StartTagToken, html.EndTagToken:
+			name, _ := z.TagName()
+			if skipHighlight[string(name)] {
+				if tt == html.StartTagToken {
+					skip++
+				} else if skip > 0 {
+					skip--
+				}
+			}
+		case html.TextToken:
+			if skip == 0 {
+				b.WriteString(c.mark(string(z.Text())))
+				continue
+			}
+		}
+		b.Write(z.Raw())
+	}
+}
+
+// mark escapes text, wrapping keywords in <mark> elements
+func (c *Classifier) mark(text string) string {
+	var b strings.Builder
+	last := 0
+	for _, m := range matches(c.all, text) {
+		b.WriteString(html.EscapeString(text[last:m[0]]))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(text[m[0]:m[1]]))
+		b.WriteString("</mark>")
+		last = m[1]
+	}
+	b.WriteString(html.EscapeString(text[last:]))
+	return b.String()
+}
@@ -0,0 +1,208 @@
+package classify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// memoryStore is a Store backed by a map
+type memoryStore map[string][]string
+
+func (m memoryStore) GetClassifications(_ context.Context, keys []string) (map[string][]string, error) {
+	out := make(map[string][]string)
+	for _, k := range keys {
+		if t, ok := m[k]; ok {
+			out[k] = t
+		}
+	}
+	return out, nil
+}
+
+func (m memoryStore) SaveClassifications(_ context.Context, classifications map[string][]string) error {
+	for k, t := range classifications {
+		m[k] = t
+	}
+	return nil
+}
+
+// modelFunc is a Model that calls a function
+type modelFunc func(context.Context, Request) ([][]string, error)
+
+func (f modelFunc) Classify(ctx context.Context, req Request) ([][]string, error) {
+	return f(ctx, req)
+}
+
+var topics = []Topic{
+	{Name: "Go", Keywords: []string{"golang", "go"}},
+	{Name: "Databases", Keywords: []string{"SQLite", "Postgres", "query planner"}},
+	{Name: "Security"},
+}
+
+func TestClassifyKeywords(t *testing.T) {
+	t.Parallel()
+	c, err := New(topics)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"Writing Go with SQLite", []string{"Go", "Databases"}},
+		{"The Postgres QUERY PLANNER explained", []string{"Databases"}},
+		{"Going, gone, ago", nil},
+		{"Café golang-nuts", []string{"Go"}},
+		{"Nothing here", nil},
+		{"", nil},
+	}
+	texts := make([]string, len(tests))
+	for i, tt := range tests {
+		texts[i] = tt.text
+	}
+	got, err := c.Classify(context.Background(), texts)
+	if err != nil {
+		t.Fatalf("Classify() error = %v", err)
+	}
+	for i, tt := range tests {
+		if !reflect.DeepEqual(got[i], tt.want) {
+			t.Errorf("Classify(%q) = %q, want %q", tt.text, got[i], tt.want)
+		}
+	}
+}
+
+func TestClassifyModel(t *testing.T) {
+	t.Parallel()
+	var requests []Request
+	model := modelFunc(func(_ context.Context, req Request) ([][]string, error) {
+		requests = append(requests, req)
+		out := make([][]string, len(req.Texts))
+		for i, text := range req.Texts {
+			if strings.Contains(text, "CVE") {
+				// Topics are matched to the configured names, and unknown ones dropped
+				out[i] = []string{"security", "Other", "go"}
+			}
+		}
+		return out, nil
+	})
+	store := memoryStore{}
+	c, err := New(topics)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	c.Model, c.Store = model, store
+
+	got, err := c.Classify(context.Background(), []string{"Go fixes a CVE", "Nothing", "Go fixes a CVE"})
+	if err != nil {
+		t.Fatalf("Classify() error = %v", err)
+	}
+	if want := [][]string{{"Go", "Security"}, nil, {"Go", "Security"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Classify() = %q, want %q", got, want)
+	}
+	if len(requests) != 1 || !reflect.DeepEqual(requests[0].Texts, []string{"Go fixes a CVE", "Nothing"}) {
+		t.Fatalf("requests = %+v, want one request for each text once", requests)
+	}
+	if want := []string{"Go", "Databases", "Security"}; !reflect.DeepEqual(requests[0].Topics, want) {
+		t.Errorf("request topics = %q, want %q", requests[0].Topics, want)
+	}
+
+	// Cached answers are not requested again
+	if _, err := c.Classify(context.Background(), []string{"Nothing"}); err != nil {
+		t.Fatalf("Classify() error = %v", err)
+	}
+	if len(requests) != 1 {
+		t.Errorf("requests = %d, want the cached answer used", len(requests))
+	}
+}
+
+func TestClassifyModelErrors(t *testing.T) {
+	t.Parallel()
+	failing := modelFunc(func(context.Context, Request) ([][]string, error) {
+		return nil, errors.New("model unavailable")
+	})
+	c := &Classifier{Model: failing, Store: memoryStore{}}
+	if _, err := c.Classify(context.Background(), []string{"text"}); err == nil || !strings.Contains(err.Error(), "model unavailable") {
+		t.Errorf("Classify() error = %v, want the model's error", err)
+	}
+
+	slow := modelFunc(func(ctx context.Context, _ Request) ([][]string, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	c = &Classifier{Model: slow, Store: memoryStore{}, Timeout: 10 * time.Millisecond}
+	if _, err := c.Classify(context.Background(), []string{"text"}); err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("Classify() error = %v, want a timeout", err)
+	}
+}
+
+func TestHTTPClassify(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req Request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		resp := Response{}
+		for range req.Texts {
+			resp.Topics = append(resp.Topics, req.Topics)
+		}
+		if len(req.Texts) > 1 {
+			// Answer too few texts to a batch
+			resp.Topics = resp.Topics[:1]
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	h := HTTP{URL: server.URL}
+	got, err := h.Classify(context.Background(), Request{Topics: []string{"Go"}, Texts: []string{"a"}})
+	if err != nil {
+		t.Fatalf("Classify() error = %v", err)
+	}
+	if want := [][]string{{"Go"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Classify() = %q, want %q", got, want)
+	}
+	if _, err := h.Classify(context.Background(), Request{Texts: []string{"a", "b"}}); err == nil {
+		t.Error("Classify() accepted a response with too few texts")
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	t.Parallel()
+	c, err := New(topics)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"keywords", "<p>Go and <em>sqlite</em> &amp; more</p>", "<p><mark>Go</mark> and <em><mark>sqlite</mark></em> &amp; more</p>"},
+		{"phrases", "<p>The query planner</p>", "<p>The <mark>query planner</mark></p>"},
+		{"whole words", "<p>Going to the category</p>", "<p>Going to the category</p>"},
+		{"links and code", `<a href="/go">Go</a> <code>go build</code>`, `<a href="/go">Go</a> <code>go build</code>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := c.Highlight(tt.in); got != tt.want {
+				t.Errorf("Highlight() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	var none *Classifier
+	if got := none.Highlight("<p>Go</p>"); got != "<p>Go</p>" {
+		t.Errorf("nil Classifier Highlight() = %q", got)
+	}
+}
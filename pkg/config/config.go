@@ -3,14 +3,29 @@
 // The config package reads INI-format configuration files and provides
 // validated configuration values with sensible defaults. It supports
 // forward compatibility by ignoring unknown sections and keys.
+//
+// Configs may also be written in TOML or YAML. Their tables map onto the
+// INI sections, so all three formats share one set of keys and validation.
 package config
 
 import (
 	"bufio"
+	"crypto/x509"
 	"fmt"
+	"io"
+	"net/http"
+	"net/netip"
+	"net/url"
 	"os"
+	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
+	"time"
+
+	"golang.org/x/net/http/httpguts"
+
+	"github.com/adewale/rogue_planet/pkg/feedurl"
 )
 
 // Configuration validation constants define acceptable ranges for config values.
@@ -44,38 +59,243 @@ const (
 	MinResponseHeaderTimeout = 1   // 1 second
 	MaxResponseHeaderTimeout = 60  // 1 minute
 
+	// Stage timeouts (minutes; 0 is no limit)
+	MinStageTimeout = 0
+	MaxStageTimeout = 1440 // 1 day
+
+	// Limit on fetching one feed with its retries (seconds; 0 fits every retry)
+	MinFeedTimeout = 0
+	MaxFeedTimeout = 3600 // 1 hour
+
 	// Rate limiting
 	MinRequestsPerMinute = 1
 	MaxRequestsPerMinute = 600 // 10 requests/second max
 	MinRateLimitBurst    = 1
 	MaxRateLimitBurst    = 50
+	MinRateLimitWait     = 1    // 1 second
+	MaxRateLimitWait     = 3600 // 1 hour
+
+	// Download bandwidth cap, in kilobits per second (0 is unlimited)
+	MinBandwidthKbps = 0
+	MaxBandwidthKbps = 10000000 // 10 Gbit/s
 
 	// Content limits
 	MinDays = 1 // At least 1 day of content
+
+	// Output feed size
+	MinFeedEntries = 1
+	MaxFeedEntries = 500
+
+	// HTML pagination (0 puts every entry on index.html)
+	MinEntriesPerPage = 0
+	MaxEntriesPerPage = 1000
+
+	// Author face image size, in pixels
+	MinFaceSize = 16
+	MaxFaceSize = 512
+
+	// Cached entry image size limit, in KB
+	MinImageSizeKB = 1
+	MaxImageSizeKB = 51200 // 50MB
+
+	// Adaptive fetch scheduling bounds, in minutes
+	MinFetchInterval = 5
+	MaxFetchInterval = 10080 // 1 week
+
+	// Consecutive fetch errors before a feed is deactivated (0 never deactivates)
+	MinDeactivateAfterErrors = 0
+	MaxDeactivateAfterErrors = 1000
+
+	// Entry quotas (0 disables the quota)
+	MinEntryQuota = 0
+	MaxEntryQuota = 10000000
+
+	// Fetch history records kept per feed
+	MinFetchHistory = 1
+	MaxFetchHistory = 10000
+
+	// Earlier revisions kept per changed entry (0 keeps none), and for how
+	// many days (0 keeps them however old)
+	MinEntryRevisions    = 0
+	MaxEntryRevisions    = 1000
+	MinEntryRevisionDays = 0
+	MaxEntryRevisionDays = 36500
+
+	// Consecutive fetch errors before a failing feed is notified (0 disables)
+	MinNotifyThreshold = 0
+	MaxNotifyThreshold = 1000
+
+	// Time limit for each publish hook, in seconds
+	MinPublishTimeout = 1
+	MaxPublishTimeout = 86400 // 1 day
+
+	// Entries shown in each feed group (0 shows them all)
+	MinGroupEntries = 0
+	MaxGroupEntries = 1000
+
+	// Entries shown from each feed (0 shows them all)
+	MinEntriesPerFeed = 0
+	MaxEntriesPerFeed = 1000
+
+	// Entries read from one fetch of a feed (0 reads them all)
+	MinParsedEntries = 0
+	MaxParsedEntries = 100000
+
+	// Words in summaries made from entry content (0 makes none)
+	MinSummaryWords = 0
+	MaxSummaryWords = 1000
+
+	// Time limit for each translation request, in seconds
+	MinTranslateTimeout = 1
+	MaxTranslateTimeout = 3600
+
+	// Time limit for each topic classification request, in seconds
+	MinClassifyTimeout = 1
+	MaxClassifyTimeout = 3600
+
+	// Related entries shown under each entry
+	MinRelatedEntries = 1
+	MaxRelatedEntries = 20
+
+	// Similarity an entry needs to be related, in percent
+	MinRelatedScore = 1
+	MaxRelatedScore = 100
+
+	// Age of the entries an entry may be related to, in days
+	MinRelatedWindow = 1
+	MaxRelatedWindow = 3650 // 10 years
+
+	// Time limit for each embeddings request, in seconds
+	MinRelatedTimeout = 1
+	MaxRelatedTimeout = 3600
+
+	// Age below which a saved feed response is reused, in minutes (0 always fetches)
+	MinResponseCacheMaxAge = 0
+	MaxResponseCacheMaxAge = 10080 // 1 week
+
+	// New-entry webhook delivery
+	MinWebhookBatch   = 1
+	MaxWebhookBatch   = 100
+	MinWebhookRetries = 0
+	MaxWebhookRetries = 10
+	MinWebhookTimeout = 1
+	MaxWebhookTimeout = 300
+
+	// Entries in an email digest (0 includes them all)
+	MinDigestEntries = 0
+	MaxDigestEntries = 1000
+
+	// Time limit for each command hook, in seconds
+	MinHookTimeout = 1
+	MaxHookTimeout = 3600
+
+	// Limits of each run of a WebAssembly plugin
+	MinPluginMemory  = 1 // MiB
+	MaxPluginMemory  = 4096
+	MinPluginTimeout = 1 // Seconds
+	MaxPluginTimeout = 300
 )
 
 // Config represents the application configuration
 type Config struct {
-	Planet   PlanetConfig
-	Database DatabaseConfig
-	Feeds    []string
+	Planet       PlanetConfig
+	Database     DatabaseConfig
+	Metrics      MetricsConfig
+	Admin        AdminConfig
+	Fever        FeverConfig
+	Notify       NotifyConfig
+	Digest       DigestConfig
+	Webhook      WebhookConfig
+	Hooks        HooksConfig
+	Plugins      PluginsConfig
+	Publish      PublishConfig
+	Translate    TranslateConfig
+	Classify     ClassifyConfig
+	Related      RelatedConfig
+	Filters      FilterConfig              // [filters] section, applied to every feed
+	FeedFilters  map[string]FilterConfig   // [filters <feed URL>] sections, keyed by feed URL
+	Sanitize     SanitizeConfig            // [sanitize] section, applied to every feed
+	FeedSanitize map[string]SanitizeConfig // [sanitize] merged with each [sanitize <feed URL>] section
+	FeedSettings map[string]FeedConfig     // [feed <feed URL>] sections, keyed by feed URL
+	Groups       []GroupConfig             // Feed groups, in the order the config first names them
+	Authors      []AuthorConfig            // [author <name>] sections, in the order the config names them
+	Topics       []TopicConfig             // [topic <name>] sections, in the order the config names them
+	Feeds        []string
+
+	// Settings from [sanitize <feed URL>] sections, applied over [sanitize]
+	// once the whole file has been read
+	feedSanitizeSettings []feedSetting
+}
+
+// feedSetting is one key from a per-feed section
+type feedSetting struct {
+	url, key, value string
 }
 
 // PlanetConfig contains planet-level settings
 type PlanetConfig struct {
 	Name              string
 	Link              string
+	Image             string // Image for previews of the planet when shared; absolute, or relative to Link (default: the newest entry's image)
+	AccentColor       string // Link and focus colour of the built-in template, as #rgb or #rrggbb (default: the template's)
+	AccentColorDark   string // AccentColor in dark mode (default: AccentColor if set, else the template's)
 	OwnerName         string
 	OwnerEmail        string
 	OutputDir         string
 	Days              int
 	LogLevel          string
+	LogFormat         string // "text" or "json" (default: text)
 	ConcurrentFetch   int
+	LowMemory         bool // Fetch, parse, and store one feed at a time and download one image at a time, for small machines (default: false)
 	UserAgent         string
 	GroupByDate       bool
+	GroupLayout       string // How feed groups are shown: "sections" or "tabs" (default: sections)
+	EntriesPerPage    int    // Entries per HTML page; 0 keeps a single index.html (default: 0)
+	Archives          bool   // Write monthly and yearly archive pages of every stored entry (default: false)
 	Template          string
 	FilterByFirstSeen bool
 	SortBy            string
+	ResurfaceUpdated  bool   // Move entries whose content changed materially within "days" to the top of the river (default: false)
+	FilterStage       string // When entry filters apply: "fetch" (before storage) or "generate" (before rendering)
+	FeedEntries       int    // Entries in the generated atom.xml/rss.xml (default: 20)
+	MaxEntriesPerFeed int    // Most entries any one feed contributes to the pages and feeds; 0 is unlimited (default: 0)
+	SummaryWords      int    // Length of the plain-text summary made for entries whose feed gives none; 0 makes none (default: 0)
+	MaxParsedEntries  int    // Most entries read from one fetch of a feed, the most recently published; 0 is unlimited (default: 1000)
+	UndatedEntries    string // Date of entries without one: "first_seen", "feed_updated", or "skip" (default: first_seen)
+	FutureDates       string // Entries dated after they are fetched: "clamp" to the fetch time, "keep", or "skip" (default: clamp)
+	GenerateRSS       bool   // Also write rss.xml (default: false)
+	GenerateJSONFeed  bool   // Also write feed.json, paginated by FeedEntries (default: false)
+	GenerateSitemap   bool   // Write sitemap.xml and robots.txt; needs Link (default: false)
+	GenerateLLMsTxt   bool   // Write llms.txt, a Markdown overview for language models; needs Link (default: false)
+	Minify            bool   // Minify generated pages and fingerprint theme style sheets and scripts (default: false)
+	Favicons          bool   // Download and cache each feed site's favicon when generating (default: false)
+	CacheImages       bool   // Serve entry images from locally cached copies in output_dir/media (default: false)
+	MaxImageSizeKB    int    // Largest image cached by CacheImages, in KB (default: 2048)
+	RobotsTxt         string // "obey", "warn", or "ignore" robots.txt when fetching (default: obey)
+	SecretsFile       string // File of [feed <URL>] sections holding credentials, kept out of the main config
+
+	// Internal addresses and host names fetches may reach despite the
+	// crawler's SSRF protection, for planets of intranet blogs (default: none)
+	AllowNetworks []netip.Prefix
+	AllowHosts    []string
+
+	// TLS for fetches: certificates may also be signed by the authorities in
+	// TLSCAFile, a PEM bundle for self-hosted feeds with a private CA, read
+	// into TLSRootCAs when the config is loaded; TLSMinVersion is "1.0",
+	// "1.1", "1.2", or "1.3" (default: 1.2)
+	TLSCAFile     string
+	TLSRootCAs    *x509.CertPool
+	TLSMinVersion string
+
+	// Author face images named by [author] face lines are read from
+	// FacesDir and scaled to FaceSize pixels square in output_dir/static/faces
+	FacesDir string
+	FaceSize int
+
+	// Each feed's last response, saved for rp fetch --offline and reused
+	// while younger than ResponseCacheMaxAgeMinutes (0 = always fetch)
+	ResponseCacheDir           string
+	ResponseCacheMaxAgeMinutes int
 
 	// HTTP connection pooling and retry settings
 	MaxRetries             int // Number of retry attempts for failed requests (default: 3)
@@ -90,14 +310,338 @@ type PlanetConfig struct {
 	TLSHandshakeTimeoutSeconds   int // TLS handshake timeout (default: 10)
 	ResponseHeaderTimeoutSeconds int // Response header timeout (default: 10)
 
+	// Stage timeouts: limits on a whole run's fetching and generating
+	FetchStageTimeoutMinutes    int // Limit on fetching every feed; 0 is none (default: 0)
+	GenerateStageTimeoutMinutes int // Limit on generating and publishing the site; 0 is none (default: 0)
+
+	// Limit on fetching, with every retry and backoff, and parsing one feed;
+	// 0 allows each attempt max_retries permits its full HTTP timeout (default: 0)
+	FeedTimeoutSeconds int
+
 	// Rate limiting settings (per domain)
-	RequestsPerMinute int // Maximum requests per domain per minute (default: 60)
-	RateLimitBurst    int // Burst size for rate limiter (default: 10)
+	RequestsPerMinute    int // Maximum requests per domain per minute (default: 60)
+	RateLimitBurst       int // Burst size for rate limiter (default: 10)
+	RateLimitWaitSeconds int // Longest a feed waits for its host's rate limit before being left for the next run (default: 30)
+
+	// Download rate cap shared by all fetches, in kilobits per second (0 = unlimited)
+	MaxBandwidthKbps int
+
+	// Adaptive scheduling: fetch each feed only when due based on its posting cadence
+	AdaptiveScheduling      bool // Enable per-feed scheduling (default: false)
+	MinFetchIntervalMinutes int  // Shortest interval between fetches of one feed (default: 30)
+	MaxFetchIntervalMinutes int  // Longest interval between fetches of one feed (default: 1440)
+
+	// Failing feeds are retried less often, and deactivated after this many
+	// consecutive errors (0 = never; default: 10)
+	DeactivateAfterErrors int
 }
 
 // DatabaseConfig contains database settings
 type DatabaseConfig struct {
-	Path string
+	Driver string // "sqlite" (default) or "postgres"
+	Path   string // SQLite database file
+	DSN    string // PostgreSQL connection string
+
+	// Entry quotas enforced at upsert time (0 = unlimited)
+	QuotaEntriesPerFeed int    // Maximum entries kept per feed
+	QuotaTotalEntries   int    // Maximum entries kept across all feeds
+	EvictionPolicy      string // "oldest_first" or "lowest_score_first" (default: oldest_first)
+
+	FetchHistory int // Fetch attempts kept per feed for 'rp history' (default: 100)
+
+	// Earlier content kept of entries whose content changes, for
+	// 'rp show-entry --revisions'
+	EntryRevisions    int // Revisions kept per entry; 0 keeps none (default: 0)
+	EntryRevisionDays int // Days revisions are kept; 0 keeps them however old (default: 0)
+}
+
+// MetricsConfig contains settings for exporting fetch metrics
+type MetricsConfig struct {
+	Textfile string // Prometheus textfile written after each fetch (e.g. for node_exporter); empty disables it
+}
+
+// AdminConfig contains settings for the admin API and admin UI of rp serve
+type AdminConfig struct {
+	Token string // Bearer token clients must send; empty disables the API. May be set in the secrets file
+	UI    bool   // Serve the admin web UI at /admin/, which signs in with the token
+}
+
+// Enabled reports whether rp serve serves the admin API
+func (a AdminConfig) Enabled() bool {
+	return a.Token != ""
+}
+
+// FeverConfig contains the account feed reader apps sign in to when rp serve
+// offers the Fever API
+type FeverConfig struct {
+	Email    string // Account name apps sign in with; need not be an email address
+	Password string // May be set in the secrets file
+}
+
+// Enabled reports whether rp serve serves the Fever API
+func (f FeverConfig) Enabled() bool {
+	return f.Email != "" && f.Password != ""
+}
+
+// NotifyConfig contains settings for notifying operators about failing and
+// gone feeds after each fetch run
+type NotifyConfig struct {
+	ErrorThreshold int    // Consecutive errors at which a feed is reported (0 = only gone feeds; default: 5)
+	WebhookURL     string // Slack/Discord-compatible incoming webhook; empty disables it
+
+	// Email by SMTP; disabled unless SMTPAddr is set
+	SMTPAddr     string   // Server host:port
+	SMTPUsername string   // Optional
+	SMTPPassword string   // Optional; may be set in the secrets file
+	EmailFrom    string   // Sender address
+	EmailTo      []string // Recipient addresses
+}
+
+// Enabled reports whether any notification channel is configured
+func (n NotifyConfig) Enabled() bool {
+	return n.WebhookURL != "" || n.SMTPAddr != ""
+}
+
+// DigestConfig contains settings for rp digest, which emails subscribers
+// the entries first seen in a period
+type DigestConfig struct {
+	Subject      string // Subject line template (default: planet name and entry count)
+	TextTemplate string // Path to a text/template for the plain text part (default: built in)
+	HTMLTemplate string // Path to an html/template for the HTML part (default: built in)
+	MaxEntries   int    // Most entries in one digest (0 = all; default: 100)
+
+	// Email by SMTP; rp digest --send needs SMTPAddr
+	SMTPAddr        string   // Server host:port
+	SMTPUsername    string   // Optional
+	SMTPPassword    string   // Optional; may be set in the secrets file
+	EmailFrom       string   // Sender address
+	Subscribers     []string // Recipient addresses, each sent their own copy
+	SubscribersFile string   // File of further recipient addresses, one per line, read when sending
+}
+
+// WebhookConfig contains the webhooks posted the entries each fetch run
+// stores for the first time
+type WebhookConfig struct {
+	URLs           []string // Each is posted every new entry; may be set in the secrets file
+	BatchSize      int      // Most entries in one request (default: 20)
+	MaxRetries     int      // Retries of a request that failed with a network error, 429, or 5xx (default: 3)
+	TimeoutSeconds int      // Time limit for each request (default: 30)
+}
+
+// Enabled reports whether new entries are posted anywhere
+func (w WebhookConfig) Enabled() bool {
+	return len(w.URLs) > 0
+}
+
+// HooksConfig contains shell commands run at points of the fetch and
+// generate lifecycle. Each is given a JSON event on stdin; see package hooks.
+type HooksConfig struct {
+	PreFetch       []string // Run before feeds are fetched; a failure stops the fetch
+	PostEntry      []string // Run on each parsed entry, which they may change or drop
+	PreGenerate    []string // Run before the site is generated; a failure stops the generation
+	PostGenerate   []string // Run once the site is generated, before [publish] hooks
+	TimeoutSeconds int      // Time limit for each command (default: 30)
+}
+
+// PluginsConfig contains WebAssembly plugins run on each parsed entry; see
+// package plugin
+type PluginsConfig struct {
+	PostEntry      []string // Path of a .wasm file, optionally followed by arguments
+	MemoryLimitMB  int      // Most memory each run may use (default: 64)
+	TimeoutSeconds int      // Time limit for each run (default: 5)
+	CacheDir       string   // Directory keeping compiled plugins between runs (default: none)
+}
+
+// PublishConfig contains hooks that deploy the site after a generation
+// that succeeded and changed it
+type PublishConfig struct {
+	Command         string // Shell command, run with RP_OUTPUT_DIR set to the output directory
+	Rsync           string // rsync destination, e.g. user@host:/var/www/planet/
+	S3              string // s3://bucket/prefix, synced with the aws CLI
+	CloudflarePages string // Cloudflare Pages project, deployed with wrangler
+	Git             string // Repository URL the site is committed and pushed to; may be set in the secrets file
+	GitBranch       string // Branch holding the site (default: gh-pages)
+	TimeoutSeconds  int    // Time limit for each hook (default: 600)
+	Always          bool   // Publish after every generation, even if the site did not change (default: false)
+}
+
+// Enabled reports whether any publish hook is configured
+func (p PublishConfig) Enabled() bool {
+	return p.Command != "" || p.Rsync != "" || p.S3 != "" || p.CloudflarePages != "" || p.Git != ""
+}
+
+// TranslateConfig sets up machine translation of entry titles and summaries
+// from a [translate] section
+type TranslateConfig struct {
+	TargetLanguage string   // Language tag to translate into, e.g. "en"
+	Command        string   // Shell command reading a JSON request on stdin and writing the reply to stdout
+	URL            string   // HTTP endpoint POSTed the JSON request, used instead of Command
+	Fields         []string // Entry fields to translate: "title", "summary" (default: title)
+	TimeoutSeconds int      // Time limit for each request (default: 60)
+}
+
+// Enabled reports whether entries are translated
+func (t TranslateConfig) Enabled() bool {
+	return t.TargetLanguage != "" && (t.Command != "" || t.URL != "")
+}
+
+// ClassifyConfig contains settings for tagging entries with topics, from
+// the keywords of [topic <name>] sections and optionally a classification
+// service
+type ClassifyConfig struct {
+	URL            string // HTTP endpoint POSTed entry texts, answering with their topics
+	Highlight      bool   // Mark topic keywords in entry content
+	TimeoutSeconds int    // Time limit for each request (default: 60)
+}
+
+// RelatedConfig contains settings for linking entries to related entries
+type RelatedConfig struct {
+	Enabled        bool   // Work out related entries after each fetch and show them under entries
+	MaxEntries     int    // Related entries kept for each entry (default: 3)
+	MinScore       int    // Similarity, in percent, below which entries are not related (default: 20)
+	WindowDays     int    // Entries published this many days back may be related (default: 90)
+	EmbeddingsURL  string // HTTP endpoint POSTed entry texts, answering with their embeddings; empty compares tags and titles
+	TimeoutSeconds int    // Time limit for each embeddings request (default: 60)
+}
+
+// TopicConfig is a topic entries are tagged with when their text has one
+// of its keywords. A [topic <name>] section lists them in keywords lines.
+type TopicConfig struct {
+	Name     string
+	Keywords []string
+}
+
+// Classifies reports whether entries are tagged with topics
+func (c *Config) Classifies() bool {
+	return len(c.Topics) > 0 || c.Classify.URL != ""
+}
+
+// FilterConfig lists entry filter rules from a [filters] section. Keywords,
+// authors, and categories are comma-separated and may be repeated; each
+// regex key holds a single regular expression.
+type FilterConfig struct {
+	IncludeKeywords   []string
+	ExcludeKeywords   []string
+	IncludeRegex      []string
+	ExcludeRegex      []string
+	IncludeAuthors    []string
+	ExcludeAuthors    []string
+	IncludeCategories []string
+	ExcludeCategories []string
+}
+
+// SanitizeConfig controls which HTML survives sanitization of entry content.
+// Tags and iframe hosts are comma-separated and may be repeated.
+type SanitizeConfig struct {
+	Trust       string   // "strict", "normal", or "trusted" (default: normal)
+	AllowTags   []string // Extra elements to keep
+	AllowMathML bool     // Keep MathML markup
+	AllowSVG    bool     // Keep inline SVG drawings
+	IframeHosts []string // Hosts whose HTTPS iframes are kept, e.g. www.youtube.com
+	StripImages bool     // Remove all images
+}
+
+// GroupConfig is a named group of feeds, shown as its own section of the
+// page. Feeds join a group with the group key of their [feed <URL>] section;
+// a [group <name>] section holds the group's settings.
+type GroupConfig struct {
+	Name       string
+	MaxEntries int // Entries shown in the group's section of each page (0 = all)
+}
+
+// AuthorConfig is one person, who may be credited differently by different
+// feeds. An [author <name>] section lists the other names and email
+// addresses they are credited with in repeated alias lines, and may name
+// an image of them in the faces directory.
+type AuthorConfig struct {
+	Name    string
+	Aliases []string
+	Face    string // File name in the planet's faces_dir
+}
+
+// AuthorMap maps the names and emails authors are credited with to the
+// names they are shown with, ignoring case and spacing
+type AuthorMap map[string]string
+
+// authorKey is how credited names are compared: trimmed, lower case, with
+// runs of whitespace collapsed
+func authorKey(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+// AuthorMap returns the mapping given by the [author <name>] sections, or
+// nil if there are none
+func (c *Config) AuthorMap() AuthorMap {
+	if len(c.Authors) == 0 {
+		return nil
+	}
+	m := make(AuthorMap)
+	for _, a := range c.Authors {
+		m[authorKey(a.Name)] = a.Name
+		for _, alias := range a.Aliases {
+			m[authorKey(alias)] = a.Name
+		}
+	}
+	return m
+}
+
+// AuthorFaces maps the names authors are shown with to the face image files
+// their [author] sections name, or returns nil if none name one
+func (c *Config) AuthorFaces() map[string]string {
+	var faces map[string]string
+	for _, a := range c.Authors {
+		if a.Face == "" {
+			continue
+		}
+		if faces == nil {
+			faces = make(map[string]string)
+		}
+		faces[a.Name] = a.Face
+	}
+	return faces
+}
+
+// Canonical returns the name an author credited as name is shown with:
+// their [author] section's name if one lists it, otherwise name itself
+func (m AuthorMap) Canonical(name string) string {
+	if canonical, ok := m[authorKey(name)]; ok {
+		return canonical
+	}
+	return name
+}
+
+// FeedConfig holds settings for one feed from a [feed <feed URL>] section
+type FeedConfig struct {
+	ExtractContent bool   // Replace summaries of new entries with the article text from their pages
+	Group          string // Name of the group the feed's entries are shown in
+	MaxEntries     int    // Overrides the planet's max_entries_per_feed for this feed (0 keeps it)
+	NoTranslate    bool   // Leave the feed's entries untranslated ("translate = false")
+	OGImage        bool   // Give new entries without an image the og:image of their pages
+
+	// Fetch the feed without verifying its TLS certificate, a last resort for
+	// self-signed hosts that leaves the feed open to tampering
+	InsecureSkipVerify bool
+
+	// Time zone the feed's timestamps are really in, whatever offset they
+	// give; nil leaves them as they are
+	Timezone *time.Location
+
+	// Presentation of the feed's entries and sidebar entry, so themes can
+	// tell sources apart
+	CSSClass    string // Extra CSS classes, separated by spaces
+	AccentColor string // Colour as #rgb or #rrggbb
+	Avatar      string // Image of the feed's author: an http(s) URL, or a path relative to the site root
+
+	// Credentials sent when fetching the feed itself (not its pages or images)
+	Username string            // HTTP Basic auth user name, sent with Password
+	Password string            // HTTP Basic auth password
+	Token    string            // Sent as "Authorization: Bearer <token>"
+	Headers  map[string]string // Extra request headers, from repeated "header = Name: value" lines
+}
+
+// HasCredentials reports whether the feed has authentication or extra headers
+func (fc FeedConfig) HasCredentials() bool {
+	return fc.Username != "" || fc.Password != "" || fc.Token != "" || len(fc.Headers) > 0
 }
 
 // Default returns a configuration with default values
@@ -111,11 +655,25 @@ func Default() *Config {
 			OutputDir:         "./public",
 			Days:              7,
 			LogLevel:          "info",
+			LogFormat:         "text",
 			ConcurrentFetch:   5,
 			UserAgent:         "RoguePlanet/0.4",
 			GroupByDate:       true,
+			GroupLayout:       "sections",
 			FilterByFirstSeen: false,
 			SortBy:            "published",
+			FilterStage:       "fetch",
+			FeedEntries:       20,
+			UndatedEntries:    "first_seen",
+			FutureDates:       "clamp",
+			MaxImageSizeKB:    2048,
+			MaxParsedEntries:  1000,
+			RobotsTxt:         "obey",
+			TLSMinVersion:     "1.2",
+
+			// Author face defaults
+			FacesDir: "./faces",
+			FaceSize: 128,
 
 			// HTTP connection pooling and retry defaults
 			MaxRetries:             3,
@@ -131,17 +689,41 @@ func Default() *Config {
 			ResponseHeaderTimeoutSeconds: 10,
 
 			// Rate limiting defaults
-			RequestsPerMinute: 60,
-			RateLimitBurst:    10,
+			RequestsPerMinute:    60,
+			RateLimitBurst:       10,
+			RateLimitWaitSeconds: 30,
+
+			// Adaptive scheduling defaults
+			MinFetchIntervalMinutes: 30,
+			MaxFetchIntervalMinutes: 1440,
+
+			DeactivateAfterErrors: 10,
 		},
 		Database: DatabaseConfig{
-			Path: "./data/planet.db",
+			Driver:         "sqlite",
+			Path:           "./data/planet.db",
+			EvictionPolicy: "oldest_first",
+			FetchHistory:   100,
+		},
+		Notify:  NotifyConfig{ErrorThreshold: 5},
+		Digest:  DigestConfig{MaxEntries: 100},
+		Webhook: WebhookConfig{BatchSize: 20, MaxRetries: 3, TimeoutSeconds: 30},
+		Hooks:   HooksConfig{TimeoutSeconds: 30},
+		Plugins: PluginsConfig{MemoryLimitMB: 64, TimeoutSeconds: 5},
+		Publish: PublishConfig{GitBranch: "gh-pages", TimeoutSeconds: 600},
+		Translate: TranslateConfig{
+			Fields:         []string{"title"},
+			TimeoutSeconds: 60,
 		},
-		Feeds: []string{},
+		Classify: ClassifyConfig{TimeoutSeconds: 60},
+		Related:  RelatedConfig{MaxEntries: 3, MinScore: 20, WindowDays: 90, TimeoutSeconds: 60},
+		Sanitize: SanitizeConfig{Trust: "normal"},
+		Feeds:    []string{},
 	}
 }
 
-// LoadFromFile loads configuration from an INI file
+// LoadFromFile loads configuration from an INI, TOML, or YAML file, told
+// apart by its extension
 func LoadFromFile(path string) (config *Config, err error) {
 	file, openErr := os.Open(path)
 	if openErr != nil {
@@ -149,9 +731,44 @@ func LoadFromFile(path string) (config *Config, err error) {
 	}
 	// Close file when done. Close errors during read are rarely actionable.
 	defer file.Close()
+	return load(path, file)
+}
+
+// load reads the config at path from r
+func load(path string, r io.Reader) (*Config, error) {
+	config := Default()
+	if err := parseFile(path, r, config.set); err != nil {
+		return nil, err
+	}
+
+	if config.Planet.SecretsFile != "" {
+		if err := config.loadSecrets(config.Planet.SecretsFile); err != nil {
+			return nil, err
+		}
+	}
+	if config.Planet.TLSCAFile != "" {
+		if err := config.loadCAFile(config.Planet.TLSCAFile); err != nil {
+			return nil, err
+		}
+	}
+	if err := config.validateCredentials(); err != nil {
+		return nil, err
+	}
+	if err := config.validateAuthors(); err != nil {
+		return nil, err
+	}
+	if err := config.validateTopics(); err != nil {
+		return nil, err
+	}
+
+	config.applyFeedSanitize()
 
-	config = Default()
-	scanner := bufio.NewScanner(file)
+	return config, nil
+}
+
+// parseINI reads an INI file, calling set for each key in each section
+func parseINI(r io.Reader, set func(section, key, value string) error) error {
+	scanner := bufio.NewScanner(r)
 	currentSection := ""
 
 	lineNum := 0
@@ -173,7 +790,7 @@ func LoadFromFile(path string) (config *Config, err error) {
 		// Parse key-value pairs
 		parts := strings.SplitN(line, "=", 2)
 		if len(parts) != 2 {
-			return nil, fmt.Errorf("invalid line %d: %s", lineNum, line)
+			return fmt.Errorf("invalid line %d: %s", lineNum, line)
 		}
 
 		key := strings.TrimSpace(parts[0])
@@ -186,16 +803,16 @@ func LoadFromFile(path string) (config *Config, err error) {
 		}
 
 		// Apply configuration based on section
-		if err := config.set(currentSection, key, value); err != nil {
-			return nil, fmt.Errorf("line %d: %w", lineNum, err)
+		if err := set(currentSection, key, value); err != nil {
+			return fmt.Errorf("line %d: %w", lineNum, err)
 		}
 	}
 
 	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("read config file: %w", err)
+		return fmt.Errorf("read config file: %w", err)
 	}
 
-	return config, nil
+	return nil
 }
 
 // set applies a configuration value
@@ -205,12 +822,114 @@ func (c *Config) set(section, key, value string) error {
 		return c.setPlanet(key, value)
 	case "database":
 		return c.setDatabase(key, value)
+	case "metrics":
+		return c.setMetrics(key, value)
+	case "admin":
+		return c.setAdmin(key, value)
+	case "fever":
+		return c.setFever(key, value)
+	case "notify":
+		return c.setNotify(key, value)
+	case "digest":
+		return c.setDigest(key, value)
+	case "webhook":
+		return c.setWebhook(key, value)
+	case "hooks":
+		return c.setHooks(key, value)
+	case "plugins":
+		return c.setPlugins(key, value)
+	case "publish":
+		return c.setPublish(key, value)
+	case "translate":
+		return c.setTranslate(key, value)
+	case "classify":
+		return c.setClassify(key, value)
+	case "related":
+		return c.setRelated(key, value)
+	case "filters":
+		return setFilter(&c.Filters, key, value)
+	case "sanitize":
+		return setSanitize(&c.Sanitize, key, value)
 	default:
+		// [feed https://example.com/feed.xml] holds settings for one feed
+		if url, ok := strings.CutPrefix(section, "feed "); ok {
+			url = sectionURL(url)
+			if c.FeedSettings == nil {
+				c.FeedSettings = make(map[string]FeedConfig)
+			}
+			fc := c.FeedSettings[url]
+			if err := setFeed(&fc, key, value); err != nil {
+				return err
+			}
+			c.FeedSettings[url] = fc
+			if key == "group" && fc.Group != "" {
+				c.group(fc.Group)
+			}
+			return nil
+		}
+		// [group Core team] holds settings for one feed group
+		if name, ok := strings.CutPrefix(section, "group "); ok {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				return fmt.Errorf("group section needs a name, e.g. [group Core team]")
+			}
+			return c.setGroup(c.group(name), key, value)
+		}
+		// [topic Networking] lists the keywords of the Networking topic
+		if name, ok := strings.CutPrefix(section, "topic "); ok {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				return fmt.Errorf("topic section needs a name, e.g. [topic Networking]")
+			}
+			return c.setTopic(c.topic(name), key, value)
+		}
+		// [author John Smith] lists the names John Smith is credited with
+		if name, ok := strings.CutPrefix(section, "author "); ok {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				return fmt.Errorf("author section needs a name, e.g. [author John Smith]")
+			}
+			return c.setAuthor(c.author(name), key, value)
+		}
+		// [sanitize https://example.com/feed.xml] adjusts [sanitize] for one
+		// feed. Values are checked now, so errors carry a line number, and
+		// applied once [sanitize] is complete.
+		if url, ok := strings.CutPrefix(section, "sanitize "); ok {
+			var scratch SanitizeConfig
+			if err := setSanitize(&scratch, key, value); err != nil {
+				return err
+			}
+			c.feedSanitizeSettings = append(c.feedSanitizeSettings, feedSetting{sectionURL(url), key, value})
+			return nil
+		}
+		// [filters https://example.com/feed.xml] applies to one feed
+		if url, ok := strings.CutPrefix(section, "filters "); ok {
+			url = sectionURL(url)
+			if c.FeedFilters == nil {
+				c.FeedFilters = make(map[string]FilterConfig)
+			}
+			fc := c.FeedFilters[url]
+			if err := setFilter(&fc, key, value); err != nil {
+				return err
+			}
+			c.FeedFilters[url] = fc
+			return nil
+		}
 		// Unknown sections are ignored for forward compatibility
 		return nil
 	}
 }
 
+// sectionURL returns the feed URL a [feed], [filters] or [sanitize] section
+// names, spelled as feedurl.Normalize spells stored feed URLs so that the
+// section matches its feed however it was written
+func sectionURL(raw string) string {
+	if url, err := feedurl.Normalize(raw); err == nil {
+		return url
+	}
+	return strings.TrimSpace(raw)
+}
+
 // setPlanet sets planet configuration values
 // setIntWithRange validates and sets an integer config value within a specified range
 func (c *Config) setIntWithRange(target *int, key, value string, min, max int) error {
@@ -225,12 +944,31 @@ func (c *Config) setIntWithRange(target *int, key, value string, min, max int) e
 	return nil
 }
 
+// hexColor matches a CSS colour in #rgb or #rrggbb form
+var hexColor = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// setHexColor validates and sets a colour config value
+func setHexColor(target *string, key, value string) error {
+	value = strings.TrimSpace(value)
+	if value != "" && !hexColor.MatchString(value) {
+		return fmt.Errorf("%s must be a colour such as #0066cc, got: %s", key, value)
+	}
+	*target = strings.ToLower(value)
+	return nil
+}
+
 func (c *Config) setPlanet(key, value string) error {
 	switch key {
 	case "name":
 		c.Planet.Name = value
 	case "link":
 		c.Planet.Link = value
+	case "image":
+		c.Planet.Image = strings.TrimSpace(value)
+	case "accent_color":
+		return setHexColor(&c.Planet.AccentColor, "accent_color", value)
+	case "accent_color_dark":
+		return setHexColor(&c.Planet.AccentColorDark, "accent_color_dark", value)
 	case "owner_name":
 		c.Planet.OwnerName = value
 	case "owner_email":
@@ -248,8 +986,20 @@ func (c *Config) setPlanet(key, value string) error {
 		c.Planet.Days = days
 	case "log_level":
 		c.Planet.LogLevel = strings.ToLower(value)
+	case "log_format":
+		value = strings.ToLower(value)
+		if value != "text" && value != "json" {
+			return fmt.Errorf("log_format must be 'text' or 'json', got: %s", value)
+		}
+		c.Planet.LogFormat = value
 	case "concurrent_fetches":
 		return c.setIntWithRange(&c.Planet.ConcurrentFetch, "concurrent_fetches", value, MinConcurrentFetches, MaxConcurrentFetches)
+	case "low_memory":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid low_memory value: %s", value)
+		}
+		c.Planet.LowMemory = b
 	case "user_agent":
 		c.Planet.UserAgent = value
 	case "group_by_date":
@@ -258,6 +1008,14 @@ func (c *Config) setPlanet(key, value string) error {
 			return fmt.Errorf("invalid group_by_date value: %s", value)
 		}
 		c.Planet.GroupByDate = b
+	case "group_layout":
+		value = strings.ToLower(value)
+		if value != "sections" && value != "tabs" {
+			return fmt.Errorf("group_layout must be 'sections' or 'tabs', got: %s", value)
+		}
+		c.Planet.GroupLayout = value
+	case "entries_per_page":
+		return c.setIntWithRange(&c.Planet.EntriesPerPage, "entries_per_page", value, MinEntriesPerPage, MaxEntriesPerPage)
 	case "template":
 		c.Planet.Template = value
 	case "filter_by_first_seen":
@@ -271,6 +1029,132 @@ func (c *Config) setPlanet(key, value string) error {
 			return fmt.Errorf("sort_by must be 'published' or 'first_seen', got: %s", value)
 		}
 		c.Planet.SortBy = value
+	case "filter_stage":
+		if value != "fetch" && value != "generate" {
+			return fmt.Errorf("filter_stage must be 'fetch' or 'generate', got: %s", value)
+		}
+		c.Planet.FilterStage = value
+	case "feed_entries":
+		return c.setIntWithRange(&c.Planet.FeedEntries, "feed_entries", value, MinFeedEntries, MaxFeedEntries)
+	case "max_entries_per_feed":
+		return c.setIntWithRange(&c.Planet.MaxEntriesPerFeed, "max_entries_per_feed", value, MinEntriesPerFeed, MaxEntriesPerFeed)
+	case "summary_words":
+		return c.setIntWithRange(&c.Planet.SummaryWords, "summary_words", value, MinSummaryWords, MaxSummaryWords)
+	case "max_parsed_entries":
+		return c.setIntWithRange(&c.Planet.MaxParsedEntries, "max_parsed_entries", value, MinParsedEntries, MaxParsedEntries)
+	case "undated_entries":
+		value = strings.ToLower(value)
+		if value != "first_seen" && value != "feed_updated" && value != "skip" {
+			return fmt.Errorf("undated_entries must be 'first_seen', 'feed_updated', or 'skip', got: %s", value)
+		}
+		c.Planet.UndatedEntries = value
+	case "future_dates":
+		value = strings.ToLower(value)
+		if value != "clamp" && value != "keep" && value != "skip" {
+			return fmt.Errorf("future_dates must be 'clamp', 'keep', or 'skip', got: %s", value)
+		}
+		c.Planet.FutureDates = value
+	case "generate_rss":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid generate_rss value: %s", value)
+		}
+		c.Planet.GenerateRSS = b
+	case "generate_json_feed":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid generate_json_feed value: %s", value)
+		}
+		c.Planet.GenerateJSONFeed = b
+	case "resurface_updated":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid resurface_updated value: %s", value)
+		}
+		c.Planet.ResurfaceUpdated = b
+	case "archives":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid archives value: %s", value)
+		}
+		c.Planet.Archives = b
+	case "generate_sitemap":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid generate_sitemap value: %s", value)
+		}
+		c.Planet.GenerateSitemap = b
+	case "generate_llms_txt":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid generate_llms_txt value: %s", value)
+		}
+		c.Planet.GenerateLLMsTxt = b
+	case "minify":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid minify value: %s", value)
+		}
+		c.Planet.Minify = b
+	case "favicons":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid favicons value: %s", value)
+		}
+		c.Planet.Favicons = b
+	case "cache_images":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid cache_images value: %s", value)
+		}
+		c.Planet.CacheImages = b
+	case "max_image_size_kb":
+		return c.setIntWithRange(&c.Planet.MaxImageSizeKB, "max_image_size_kb", value, MinImageSizeKB, MaxImageSizeKB)
+	case "secrets_file":
+		c.Planet.SecretsFile = value
+	case "faces_dir":
+		c.Planet.FacesDir = value
+	case "face_size":
+		return c.setIntWithRange(&c.Planet.FaceSize, "face_size", value, MinFaceSize, MaxFaceSize)
+	case "response_cache_dir":
+		c.Planet.ResponseCacheDir = value
+	case "response_cache_max_age_minutes":
+		return c.setIntWithRange(&c.Planet.ResponseCacheMaxAgeMinutes, "response_cache_max_age_minutes", value, MinResponseCacheMaxAge, MaxResponseCacheMaxAge)
+	case "robots_txt":
+		value = strings.ToLower(value)
+		if value != "obey" && value != "warn" && value != "ignore" {
+			return fmt.Errorf("robots_txt must be 'obey', 'warn', or 'ignore', got: %s", value)
+		}
+		c.Planet.RobotsTxt = value
+	case "allow_networks":
+		for _, item := range splitList(value) {
+			network, err := parseNetwork(item)
+			if err != nil {
+				return fmt.Errorf("invalid allow_networks entry %q: want an address or a range such as 10.0.0.0/8", item)
+			}
+			c.Planet.AllowNetworks = append(c.Planet.AllowNetworks, network)
+		}
+	case "tls_ca_file":
+		c.Planet.TLSCAFile = value
+	case "tls_min_version":
+		if value != "1.0" && value != "1.1" && value != "1.2" && value != "1.3" {
+			return fmt.Errorf("tls_min_version must be '1.0', '1.1', '1.2', or '1.3', got: %s", value)
+		}
+		c.Planet.TLSMinVersion = value
+	case "allow_hosts":
+		c.Planet.AllowHosts = append(c.Planet.AllowHosts, splitList(strings.ToLower(value))...)
+	case "adaptive_scheduling":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid adaptive_scheduling value: %s", value)
+		}
+		c.Planet.AdaptiveScheduling = b
+	case "min_fetch_interval_minutes":
+		return c.setIntWithRange(&c.Planet.MinFetchIntervalMinutes, "min_fetch_interval_minutes", value, MinFetchInterval, MaxFetchInterval)
+	case "max_fetch_interval_minutes":
+		return c.setIntWithRange(&c.Planet.MaxFetchIntervalMinutes, "max_fetch_interval_minutes", value, MinFetchInterval, MaxFetchInterval)
+	case "deactivate_after_errors":
+		return c.setIntWithRange(&c.Planet.DeactivateAfterErrors, "deactivate_after_errors", value, MinDeactivateAfterErrors, MaxDeactivateAfterErrors)
 	case "max_retries":
 		return c.setIntWithRange(&c.Planet.MaxRetries, "max_retries", value, MinMaxRetries, MaxMaxRetries)
 	case "max_idle_conns":
@@ -289,10 +1173,20 @@ func (c *Config) setPlanet(key, value string) error {
 		return c.setIntWithRange(&c.Planet.TLSHandshakeTimeoutSeconds, "tls_handshake_timeout_seconds", value, MinTLSHandshakeTimeout, MaxTLSHandshakeTimeout)
 	case "response_header_timeout_seconds":
 		return c.setIntWithRange(&c.Planet.ResponseHeaderTimeoutSeconds, "response_header_timeout_seconds", value, MinResponseHeaderTimeout, MaxResponseHeaderTimeout)
+	case "fetch_stage_timeout_minutes":
+		return c.setIntWithRange(&c.Planet.FetchStageTimeoutMinutes, "fetch_stage_timeout_minutes", value, MinStageTimeout, MaxStageTimeout)
+	case "generate_stage_timeout_minutes":
+		return c.setIntWithRange(&c.Planet.GenerateStageTimeoutMinutes, "generate_stage_timeout_minutes", value, MinStageTimeout, MaxStageTimeout)
 	case "requests_per_minute":
 		return c.setIntWithRange(&c.Planet.RequestsPerMinute, "requests_per_minute", value, MinRequestsPerMinute, MaxRequestsPerMinute)
 	case "rate_limit_burst":
 		return c.setIntWithRange(&c.Planet.RateLimitBurst, "rate_limit_burst", value, MinRateLimitBurst, MaxRateLimitBurst)
+	case "rate_limit_wait_seconds":
+		return c.setIntWithRange(&c.Planet.RateLimitWaitSeconds, "rate_limit_wait_seconds", value, MinRateLimitWait, MaxRateLimitWait)
+	case "feed_timeout_seconds":
+		return c.setIntWithRange(&c.Planet.FeedTimeoutSeconds, "feed_timeout_seconds", value, MinFeedTimeout, MaxFeedTimeout)
+	case "max_bandwidth_kbps":
+		return c.setIntWithRange(&c.Planet.MaxBandwidthKbps, "max_bandwidth_kbps", value, MinBandwidthKbps, MaxBandwidthKbps)
 	default:
 		// Unknown keys are ignored for forward compatibility
 		return nil
@@ -303,15 +1197,755 @@ func (c *Config) setPlanet(key, value string) error {
 // setDatabase sets database configuration values
 func (c *Config) setDatabase(key, value string) error {
 	switch key {
+	case "driver":
+		if value != "sqlite" && value != "postgres" {
+			return fmt.Errorf("driver must be 'sqlite' or 'postgres', got: %s", value)
+		}
+		c.Database.Driver = value
 	case "path":
 		c.Database.Path = value
+	case "dsn":
+		c.Database.DSN = value
+	case "quota_entries_per_feed":
+		return c.setIntWithRange(&c.Database.QuotaEntriesPerFeed, "quota_entries_per_feed", value, MinEntryQuota, MaxEntryQuota)
+	case "quota_total_entries":
+		return c.setIntWithRange(&c.Database.QuotaTotalEntries, "quota_total_entries", value, MinEntryQuota, MaxEntryQuota)
+	case "eviction_policy":
+		if value != "oldest_first" && value != "lowest_score_first" {
+			return fmt.Errorf("eviction_policy must be 'oldest_first' or 'lowest_score_first', got: %s", value)
+		}
+		c.Database.EvictionPolicy = value
+	case "fetch_history":
+		return c.setIntWithRange(&c.Database.FetchHistory, "fetch_history", value, MinFetchHistory, MaxFetchHistory)
+	case "entry_revisions":
+		return c.setIntWithRange(&c.Database.EntryRevisions, "entry_revisions", value, MinEntryRevisions, MaxEntryRevisions)
+	case "entry_revision_days":
+		return c.setIntWithRange(&c.Database.EntryRevisionDays, "entry_revision_days", value, MinEntryRevisionDays, MaxEntryRevisionDays)
+	default:
+		// Unknown keys are ignored
+		return nil
+	}
+	return nil
+}
+
+// setMetrics sets metrics configuration values
+func (c *Config) setMetrics(key, value string) error {
+	switch key {
+	case "textfile":
+		c.Metrics.Textfile = value
+	default:
+		// Unknown keys are ignored
+		return nil
+	}
+	return nil
+}
+
+// minAdminToken is the shortest admin token accepted, so it can't be guessed
+const minAdminToken = 16
+
+// setAdmin sets admin API configuration values
+func (c *Config) setAdmin(key, value string) error {
+	switch key {
+	case "token":
+		if value != "" && len(value) < minAdminToken {
+			return fmt.Errorf("token must be at least %d characters", minAdminToken)
+		}
+		c.Admin.Token = value
+	case "ui":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid ui value: %s", value)
+		}
+		c.Admin.UI = b
+	default:
+		// Unknown keys are ignored
+		return nil
+	}
+	return nil
+}
+
+// setFever sets Fever API configuration values
+func (c *Config) setFever(key, value string) error {
+	switch key {
+	case "email":
+		c.Fever.Email = value
+	case "password":
+		c.Fever.Password = value
+	default:
+		// Unknown keys are ignored
+		return nil
+	}
+	return nil
+}
+
+// setNotify sets notification configuration values
+func (c *Config) setNotify(key, value string) error {
+	switch key {
+	case "error_threshold":
+		return c.setIntWithRange(&c.Notify.ErrorThreshold, "error_threshold", value, MinNotifyThreshold, MaxNotifyThreshold)
+	case "webhook_url":
+		if value != "" && !strings.HasPrefix(value, "https://") && !strings.HasPrefix(value, "http://") {
+			return fmt.Errorf("webhook_url must be an http:// or https:// URL, got: %s", value)
+		}
+		c.Notify.WebhookURL = value
+	case "smtp_addr":
+		c.Notify.SMTPAddr = value
+	case "smtp_username":
+		c.Notify.SMTPUsername = value
+	case "smtp_password":
+		c.Notify.SMTPPassword = value
+	case "email_from":
+		c.Notify.EmailFrom = value
+	case "email_to":
+		c.Notify.EmailTo = append(c.Notify.EmailTo, splitList(value)...)
+	default:
+		// Unknown keys are ignored
+		return nil
+	}
+	return nil
+}
+
+// setDigest sets email digest configuration values
+func (c *Config) setDigest(key, value string) error {
+	switch key {
+	case "subject":
+		c.Digest.Subject = value
+	case "text_template":
+		c.Digest.TextTemplate = value
+	case "html_template":
+		c.Digest.HTMLTemplate = value
+	case "max_entries":
+		return c.setIntWithRange(&c.Digest.MaxEntries, "max_entries", value, MinDigestEntries, MaxDigestEntries)
+	case "smtp_addr":
+		c.Digest.SMTPAddr = value
+	case "smtp_username":
+		c.Digest.SMTPUsername = value
+	case "smtp_password":
+		c.Digest.SMTPPassword = value
+	case "email_from":
+		c.Digest.EmailFrom = value
+	case "subscribers":
+		c.Digest.Subscribers = append(c.Digest.Subscribers, splitList(value)...)
+	case "subscribers_file":
+		c.Digest.SubscribersFile = value
+	default:
+		// Unknown keys are ignored
+		return nil
+	}
+	return nil
+}
+
+// setWebhook sets new-entry webhook configuration values
+func (c *Config) setWebhook(key, value string) error {
+	switch key {
+	case "url":
+		if !strings.HasPrefix(value, "https://") && !strings.HasPrefix(value, "http://") {
+			return fmt.Errorf("webhook url must be an http:// or https:// URL, got: %s", value)
+		}
+		c.Webhook.URLs = append(c.Webhook.URLs, value)
+	case "batch_size":
+		return c.setIntWithRange(&c.Webhook.BatchSize, "batch_size", value, MinWebhookBatch, MaxWebhookBatch)
+	case "max_retries":
+		return c.setIntWithRange(&c.Webhook.MaxRetries, "max_retries", value, MinWebhookRetries, MaxWebhookRetries)
+	case "timeout_seconds":
+		return c.setIntWithRange(&c.Webhook.TimeoutSeconds, "timeout_seconds", value, MinWebhookTimeout, MaxWebhookTimeout)
+	default:
+		// Unknown keys are ignored
+		return nil
+	}
+	return nil
+}
+
+// setHooks sets command hook configuration values. Each hook key may be
+// repeated to run several commands in turn.
+func (c *Config) setHooks(key, value string) error {
+	switch key {
+	case "pre_fetch":
+		c.Hooks.PreFetch = append(c.Hooks.PreFetch, value)
+	case "post_entry":
+		c.Hooks.PostEntry = append(c.Hooks.PostEntry, value)
+	case "pre_generate":
+		c.Hooks.PreGenerate = append(c.Hooks.PreGenerate, value)
+	case "post_generate":
+		c.Hooks.PostGenerate = append(c.Hooks.PostGenerate, value)
+	case "timeout_seconds":
+		return c.setIntWithRange(&c.Hooks.TimeoutSeconds, "timeout_seconds", value, MinHookTimeout, MaxHookTimeout)
+	default:
+		// Unknown keys are ignored
+		return nil
+	}
+	return nil
+}
+
+// setPlugins sets WebAssembly plugin configuration values. post_entry may
+// be repeated to run several plugins in turn.
+func (c *Config) setPlugins(key, value string) error {
+	switch key {
+	case "post_entry":
+		c.Plugins.PostEntry = append(c.Plugins.PostEntry, value)
+	case "memory_limit_mb":
+		return c.setIntWithRange(&c.Plugins.MemoryLimitMB, "memory_limit_mb", value, MinPluginMemory, MaxPluginMemory)
+	case "timeout_seconds":
+		return c.setIntWithRange(&c.Plugins.TimeoutSeconds, "timeout_seconds", value, MinPluginTimeout, MaxPluginTimeout)
+	case "cache_dir":
+		c.Plugins.CacheDir = value
+	default:
+		// Unknown keys are ignored
+		return nil
+	}
+	return nil
+}
+
+// setPublish sets publish hook configuration values
+func (c *Config) setPublish(key, value string) error {
+	switch key {
+	case "command":
+		c.Publish.Command = value
+	case "rsync":
+		c.Publish.Rsync = value
+	case "s3":
+		if value != "" && !strings.HasPrefix(value, "s3://") {
+			return fmt.Errorf("s3 must be an s3://bucket/prefix URL, got: %s", value)
+		}
+		c.Publish.S3 = value
+	case "cloudflare_pages":
+		c.Publish.CloudflarePages = value
+	case "git":
+		c.Publish.Git = value
+	case "git_branch":
+		if value == "" || strings.ContainsAny(value, " :~^?*[\\") || strings.HasPrefix(value, "-") {
+			return fmt.Errorf("invalid git_branch value: %s", value)
+		}
+		c.Publish.GitBranch = value
+	case "timeout_seconds":
+		return c.setIntWithRange(&c.Publish.TimeoutSeconds, "timeout_seconds", value, MinPublishTimeout, MaxPublishTimeout)
+	case "always":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid always value: %s", value)
+		}
+		c.Publish.Always = b
+	default:
+		// Unknown keys are ignored
+		return nil
+	}
+	return nil
+}
+
+func (c *Config) setTranslate(key, value string) error {
+	switch key {
+	case "target_language":
+		c.Translate.TargetLanguage = strings.TrimSpace(value)
+	case "command":
+		c.Translate.Command = value
+	case "url":
+		if value != "" && !strings.HasPrefix(value, "http://") && !strings.HasPrefix(value, "https://") {
+			return fmt.Errorf("translate url must be an http:// or https:// URL, got: %s", value)
+		}
+		c.Translate.URL = value
+	case "fields":
+		fields := splitList(strings.ToLower(value))
+		for _, f := range fields {
+			if f != "title" && f != "summary" {
+				return fmt.Errorf("invalid translate field %q (must be title or summary)", f)
+			}
+		}
+		c.Translate.Fields = fields
+	case "timeout_seconds":
+		return c.setIntWithRange(&c.Translate.TimeoutSeconds, "timeout_seconds", value, MinTranslateTimeout, MaxTranslateTimeout)
+	default:
+		// Unknown keys are ignored
+		return nil
+	}
+	return nil
+}
+
+// setClassify sets topic classification configuration values
+func (c *Config) setClassify(key, value string) error {
+	switch key {
+	case "url":
+		if value != "" && !strings.HasPrefix(value, "http://") && !strings.HasPrefix(value, "https://") {
+			return fmt.Errorf("classify url must be an http:// or https:// URL, got: %s", value)
+		}
+		c.Classify.URL = value
+	case "highlight":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid highlight value: %s", value)
+		}
+		c.Classify.Highlight = b
+	case "timeout_seconds":
+		return c.setIntWithRange(&c.Classify.TimeoutSeconds, "timeout_seconds", value, MinClassifyTimeout, MaxClassifyTimeout)
+	default:
+		// Unknown keys are ignored
+		return nil
+	}
+	return nil
+}
+
+// setRelated sets related entry configuration values
+func (c *Config) setRelated(key, value string) error {
+	switch key {
+	case "enabled":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid enabled value: %s", value)
+		}
+		c.Related.Enabled = b
+	case "max_entries":
+		return c.setIntWithRange(&c.Related.MaxEntries, "max_entries", value, MinRelatedEntries, MaxRelatedEntries)
+	case "min_score":
+		return c.setIntWithRange(&c.Related.MinScore, "min_score", value, MinRelatedScore, MaxRelatedScore)
+	case "window_days":
+		return c.setIntWithRange(&c.Related.WindowDays, "window_days", value, MinRelatedWindow, MaxRelatedWindow)
+	case "embeddings_url":
+		if value != "" && !strings.HasPrefix(value, "http://") && !strings.HasPrefix(value, "https://") {
+			return fmt.Errorf("related embeddings_url must be an http:// or https:// URL, got: %s", value)
+		}
+		c.Related.EmbeddingsURL = value
+	case "timeout_seconds":
+		return c.setIntWithRange(&c.Related.TimeoutSeconds, "timeout_seconds", value, MinRelatedTimeout, MaxRelatedTimeout)
+	default:
+		// Unknown keys are ignored
+		return nil
+	}
+	return nil
+}
+
+// setFilter adds a rule to a filter section. List values accumulate across
+// repeated keys so long lists can be split over several lines.
+func setFilter(fc *FilterConfig, key, value string) error {
+	switch key {
+	case "include_keywords":
+		fc.IncludeKeywords = append(fc.IncludeKeywords, splitList(value)...)
+	case "exclude_keywords":
+		fc.ExcludeKeywords = append(fc.ExcludeKeywords, splitList(value)...)
+	case "include_authors":
+		fc.IncludeAuthors = append(fc.IncludeAuthors, splitList(value)...)
+	case "exclude_authors":
+		fc.ExcludeAuthors = append(fc.ExcludeAuthors, splitList(value)...)
+	case "include_categories":
+		fc.IncludeCategories = append(fc.IncludeCategories, splitList(value)...)
+	case "exclude_categories":
+		fc.ExcludeCategories = append(fc.ExcludeCategories, splitList(value)...)
+	case "include_regex", "exclude_regex":
+		if _, err := regexp.Compile(value); err != nil {
+			return fmt.Errorf("invalid %s: %w", key, err)
+		}
+		if key == "include_regex" {
+			fc.IncludeRegex = append(fc.IncludeRegex, value)
+		} else {
+			fc.ExcludeRegex = append(fc.ExcludeRegex, value)
+		}
+	default:
+		// Unknown keys are ignored
+	}
+	return nil
+}
+
+// setSanitize sets a sanitization option. List values accumulate across
+// repeated keys.
+func setSanitize(sc *SanitizeConfig, key, value string) error {
+	switch key {
+	case "trust":
+		value = strings.ToLower(value)
+		if value != "strict" && value != "normal" && value != "trusted" {
+			return fmt.Errorf("trust must be 'strict', 'normal', or 'trusted', got: %s", value)
+		}
+		sc.Trust = value
+	case "allow_tags":
+		sc.AllowTags = append(sc.AllowTags, splitList(strings.ToLower(value))...)
+	case "iframe_hosts":
+		sc.IframeHosts = append(sc.IframeHosts, splitList(strings.ToLower(value))...)
+	case "allow_mathml", "allow_svg", "strip_images":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid %s value: %s", key, value)
+		}
+		switch key {
+		case "allow_mathml":
+			sc.AllowMathML = b
+		case "allow_svg":
+			sc.AllowSVG = b
+		default:
+			sc.StripImages = b
+		}
+	default:
+		// Unknown keys are ignored
+	}
+	return nil
+}
+
+// group returns the group called name, adding it if the config has not
+// named it before
+func (c *Config) group(name string) *GroupConfig {
+	for i := range c.Groups {
+		if c.Groups[i].Name == name {
+			return &c.Groups[i]
+		}
+	}
+	c.Groups = append(c.Groups, GroupConfig{Name: name})
+	return &c.Groups[len(c.Groups)-1]
+}
+
+// setGroup sets a feed group option
+func (c *Config) setGroup(g *GroupConfig, key, value string) error {
+	switch key {
+	case "max_entries":
+		return c.setIntWithRange(&g.MaxEntries, "max_entries", value, MinGroupEntries, MaxGroupEntries)
+	default:
+		// Unknown keys are ignored
+	}
+	return nil
+}
+
+// author returns the author called name, adding them if the config has
+// not named them before
+func (c *Config) author(name string) *AuthorConfig {
+	for i := range c.Authors {
+		if c.Authors[i].Name == name {
+			return &c.Authors[i]
+		}
+	}
+	c.Authors = append(c.Authors, AuthorConfig{Name: name})
+	return &c.Authors[len(c.Authors)-1]
+}
+
+// topic returns the topic called name, adding it if the config has not
+// named it before
+func (c *Config) topic(name string) *TopicConfig {
+	for i := range c.Topics {
+		if c.Topics[i].Name == name {
+			return &c.Topics[i]
+		}
+	}
+	c.Topics = append(c.Topics, TopicConfig{Name: name})
+	return &c.Topics[len(c.Topics)-1]
+}
+
+// setTopic sets a topic option. Keywords are comma-separated and may be
+// repeated.
+func (c *Config) setTopic(t *TopicConfig, key, value string) error {
+	switch key {
+	case "keywords":
+		t.Keywords = append(t.Keywords, splitList(value)...)
+	default:
+		// Unknown keys are ignored
+	}
+	return nil
+}
+
+// setAuthor sets an author option
+func (c *Config) setAuthor(a *AuthorConfig, key, value string) error {
+	switch key {
+	case "alias":
+		if alias := strings.TrimSpace(value); alias != "" {
+			a.Aliases = append(a.Aliases, alias)
+		}
+	case "face":
+		face := strings.TrimSpace(value)
+		if face == "" || face == "." || face == ".." || filepath.Base(face) != face {
+			return fmt.Errorf("invalid face for author %s: %q is not a file name in faces_dir", a.Name, value)
+		}
+		a.Face = face
+	default:
+		// Unknown keys are ignored
+	}
+	return nil
+}
+
+// setFeed sets a per-feed option
+func setFeed(fc *FeedConfig, key, value string) error {
+	switch key {
+	case "extract_content":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid extract_content value: %s", value)
+		}
+		fc.ExtractContent = b
+	case "og_image":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid og_image value: %s", value)
+		}
+		fc.OGImage = b
+	case "insecure_skip_verify":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid insecure_skip_verify value: %s", value)
+		}
+		fc.InsecureSkipVerify = b
+	case "group":
+		fc.Group = strings.TrimSpace(value)
+	case "translate":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid translate value: %s", value)
+		}
+		fc.NoTranslate = !b
+	case "timezone":
+		loc, err := parseTimezone(value)
+		if err != nil {
+			return err
+		}
+		fc.Timezone = loc
+	case "max_entries":
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid max_entries value: %s", value)
+		}
+		if n < MinEntriesPerFeed || n > MaxEntriesPerFeed {
+			return fmt.Errorf("max_entries must be between %d and %d", MinEntriesPerFeed, MaxEntriesPerFeed)
+		}
+		fc.MaxEntries = n
+	case "css_class":
+		classes := strings.Fields(value)
+		for _, class := range classes {
+			if !cssClass.MatchString(class) {
+				return fmt.Errorf("invalid css_class %q (want names such as team-go)", class)
+			}
+		}
+		fc.CSSClass = strings.Join(classes, " ")
+	case "accent_color":
+		return setHexColor(&fc.AccentColor, "accent_color", value)
+	case "avatar":
+		value = strings.TrimSpace(value)
+		if u, err := url.Parse(value); err != nil || u.Scheme != "" && u.Scheme != "http" && u.Scheme != "https" {
+			return fmt.Errorf("avatar must be an http:// or https:// URL or a path, got: %s", value)
+		}
+		fc.Avatar = value
+	default:
+		return setFeedCredential(fc, key, value)
+	}
+	return nil
+}
+
+// cssClass matches a CSS class name
+var cssClass = regexp.MustCompile(`^-?[_a-zA-Z][_a-zA-Z0-9-]*$`)
+
+// parseTimezone parses a time zone given as an IANA name such as
+// "America/Los_Angeles", or as a UTC offset such as "+08:00" or "-0530"
+func parseTimezone(value string) (*time.Location, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+	if value[0] == '+' || value[0] == '-' {
+		for _, layout := range []string{"-07:00", "-0700", "-07"} {
+			if t, err := time.Parse(layout, value); err == nil {
+				_, offset := t.Zone()
+				return time.FixedZone("UTC"+value, offset), nil
+			}
+		}
+		return nil, fmt.Errorf("invalid timezone offset %q (want e.g. +08:00)", value)
+	}
+	loc, err := time.LoadLocation(value)
+	if err != nil {
+		return nil, fmt.Errorf("unknown timezone %q (want a name like America/Los_Angeles or an offset like +08:00)", value)
+	}
+	return loc, nil
+}
+
+// credentialKeys are the [feed <URL>] keys allowed in a secrets file
+var credentialKeys = map[string]bool{"username": true, "password": true, "token": true, "header": true}
+
+// setFeedCredential sets a per-feed authentication option
+func setFeedCredential(fc *FeedConfig, key, value string) error {
+	switch key {
+	case "username":
+		fc.Username = value
+	case "password":
+		fc.Password = value
+	case "token":
+		fc.Token = value
+	case "header":
+		name, val, ok := strings.Cut(value, ":")
+		name, val = strings.TrimSpace(name), strings.TrimSpace(val)
+		if !ok || !httpguts.ValidHeaderFieldName(name) || !httpguts.ValidHeaderFieldValue(val) {
+			return fmt.Errorf("invalid header %q (want \"Name: value\")", value)
+		}
+		if fc.Headers == nil {
+			fc.Headers = make(map[string]string)
+		}
+		fc.Headers[http.CanonicalHeaderKey(name)] = val
 	default:
 		// Unknown keys are ignored
+	}
+	return nil
+}
+
+// loadCAFile reads the certificate authorities in a PEM file into
+// TLSRootCAs, alongside the system's own
+func (c *Config) loadCAFile(path string) error {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read tls_ca_file: %w", err)
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return fmt.Errorf("tls_ca_file %s holds no PEM certificates", path)
+	}
+	c.Planet.TLSRootCAs = pool
+	return nil
+}
+
+// loadSecrets reads [feed <URL>] credential sections from a secrets file
+// into FeedSettings, the database connection string from a [database]
+// section, the webhook URL and SMTP password from a [notify] section, the
+// SMTP password from a [digest] section, webhook URLs from a [webhook]
+// section, the admin API token from an [admin] section, and the Fever API password from
+// a [fever] section.
+// Only those keys are accepted, so the file cannot change anything else.
+func (c *Config) loadSecrets(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open secrets file: %w", err)
+	}
+	defer file.Close()
+
+	err = parseFile(path, file, func(section, key, value string) error {
+		if section == "database" {
+			if key != "dsn" {
+				return fmt.Errorf("secrets file may only set dsn in [database], found %s", key)
+			}
+			c.Database.DSN = value
+			return nil
+		}
+		if section == "notify" {
+			if key != "webhook_url" && key != "smtp_password" {
+				return fmt.Errorf("secrets file may only set webhook_url and smtp_password in [notify], found %s", key)
+			}
+			return c.setNotify(key, value)
+		}
+		if section == "digest" {
+			if key != "smtp_password" {
+				return fmt.Errorf("secrets file may only set smtp_password in [digest], found %s", key)
+			}
+			return c.setDigest(key, value)
+		}
+		if section == "webhook" {
+			if key != "url" {
+				return fmt.Errorf("secrets file may only set url in [webhook], found %s", key)
+			}
+			return c.setWebhook(key, value)
+		}
+		if section == "admin" {
+			if key != "token" {
+				return fmt.Errorf("secrets file may only set token in [admin], found %s", key)
+			}
+			return c.setAdmin(key, value)
+		}
+		if section == "fever" {
+			if key != "password" {
+				return fmt.Errorf("secrets file may only set password in [fever], found %s", key)
+			}
+			return c.setFever(key, value)
+		}
+		if section == "publish" {
+			if key != "git" {
+				return fmt.Errorf("secrets file may only set git in [publish], found %s", key)
+			}
+			return c.setPublish(key, value)
+		}
+		url, ok := strings.CutPrefix(section, "feed ")
+		if !ok {
+			return fmt.Errorf("secrets file may only contain [database], [notify], [digest], [webhook], [publish], [admin], [fever], and [feed <URL>] sections, found [%s]", section)
+		}
+		if !credentialKeys[key] {
+			return fmt.Errorf("secrets file may only set username, password, token, and header, found %s", key)
+		}
+		return c.set("feed "+strings.TrimSpace(url), key, value)
+	})
+	if err != nil {
+		return fmt.Errorf("secrets file %s: %w", path, err)
+	}
+	return nil
+}
+
+// validateCredentials rejects feeds that mix Basic auth and a bearer token
+func (c *Config) validateCredentials() error {
+	for url, fc := range c.FeedSettings {
+		if fc.Token != "" && (fc.Username != "" || fc.Password != "") {
+			return fmt.Errorf("[feed %s]: use either username and password or token, not both", url)
+		}
+	}
+	return nil
+}
+
+// validateAuthors checks that each name or alias belongs to one author
+func (c *Config) validateAuthors() error {
+	owner := make(map[string]string)
+	for _, a := range c.Authors {
+		for _, name := range append([]string{a.Name}, a.Aliases...) {
+			key := authorKey(name)
+			if other, ok := owner[key]; ok && other != a.Name {
+				return fmt.Errorf("[author %s]: %q is already an alias of %s", a.Name, name, other)
+			}
+			owner[key] = a.Name
+		}
+	}
+	return nil
+}
+
+// validateTopics checks that topics have keywords, unless a classification
+// service decides which entries they fit
+func (c *Config) validateTopics() error {
+	if c.Classify.URL != "" {
 		return nil
 	}
+	for _, t := range c.Topics {
+		if len(t.Keywords) == 0 {
+			return fmt.Errorf("[topic %s] needs keywords, or a url in [classify]", t.Name)
+		}
+	}
 	return nil
 }
 
+// applyFeedSanitize builds FeedSanitize: each feed starts from [sanitize],
+// its own section adds tags and hosts and overrides the other options
+func (c *Config) applyFeedSanitize() {
+	for _, s := range c.feedSanitizeSettings {
+		if c.FeedSanitize == nil {
+			c.FeedSanitize = make(map[string]SanitizeConfig)
+		}
+		sc, ok := c.FeedSanitize[s.url]
+		if !ok {
+			sc = c.Sanitize
+			sc.AllowTags = append([]string(nil), c.Sanitize.AllowTags...)
+			sc.IframeHosts = append([]string(nil), c.Sanitize.IframeHosts...)
+		}
+		_ = setSanitize(&sc, s.key, s.value) // Checked when the line was read
+		c.FeedSanitize[s.url] = sc
+	}
+	c.feedSanitizeSettings = nil
+}
+
+// parseNetwork parses an address range in CIDR notation, or a single address
+func parseNetwork(s string) (netip.Prefix, error) {
+	if addr, err := netip.ParseAddr(s); err == nil {
+		return netip.PrefixFrom(addr, addr.BitLen()), nil
+	}
+	network, err := netip.ParsePrefix(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return network.Masked(), nil
+}
+
+// splitList splits a comma-separated value, dropping empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 // Validate checks if the configuration is valid
 func (c *Config) Validate() error {
 	if c.Planet.Name == "" {
@@ -326,7 +1960,11 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("concurrent_fetches must be between 1 and 50")
 	}
 
-	if c.Database.Path == "" {
+	if c.Database.Driver == "postgres" {
+		if c.Database.DSN == "" {
+			return fmt.Errorf("database dsn is required for the postgres driver")
+		}
+	} else if c.Database.Path == "" {
 		return fmt.Errorf("database path is required")
 	}
 
@@ -343,6 +1981,38 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("template path must not contain parent directory references (..): %s", c.Planet.Template)
 	}
 
+	if (c.Planet.GenerateSitemap || c.Planet.GenerateLLMsTxt) && c.Planet.Link == "" {
+		return fmt.Errorf("generate_sitemap and generate_llms_txt need the planet link, to make absolute URLs")
+	}
+
+	if c.Planet.MinFetchIntervalMinutes > c.Planet.MaxFetchIntervalMinutes {
+		return fmt.Errorf("min_fetch_interval_minutes (%d) must not exceed max_fetch_interval_minutes (%d)",
+			c.Planet.MinFetchIntervalMinutes, c.Planet.MaxFetchIntervalMinutes)
+	}
+
+	if n := c.Notify; n.SMTPAddr != "" && (n.EmailFrom == "" || len(n.EmailTo) == 0) {
+		return fmt.Errorf("[notify] smtp_addr needs email_from and email_to")
+	}
+
+	if d := c.Digest; d.SMTPAddr != "" && (d.EmailFrom == "" || (len(d.Subscribers) == 0 && d.SubscribersFile == "")) {
+		return fmt.Errorf("[digest] smtp_addr needs email_from and subscribers or subscribers_file")
+	}
+
+	if c.Admin.UI && !c.Admin.Enabled() {
+		return fmt.Errorf("[admin] ui needs a token")
+	}
+
+	if f := c.Fever; (f.Email == "") != (f.Password == "") {
+		return fmt.Errorf("[fever] needs both email and password")
+	}
+
+	if t := c.Translate; t.Command != "" && t.URL != "" {
+		return fmt.Errorf("[translate] needs either command or url, not both")
+	}
+	if t := c.Translate; (t.Command != "" || t.URL != "") && t.TargetLanguage == "" {
+		return fmt.Errorf("[translate] needs target_language")
+	}
+
 	// Set default and validate sort_by
 	if c.Planet.SortBy == "" {
 		c.Planet.SortBy = "published"
@@ -0,0 +1,223 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/adewale/rogue_planet/internal/atomicfile"
+)
+
+// Get returns the values of a key in the config file at path, in file
+// order; keys that take lists may be given more than once. name is
+// section.key, e.g. planet.days or feed.https://example.com/feed.xml.token.
+// Only the config file is read, not its secrets file. A key that is not set
+// has no values.
+func Get(path, name string) ([]string, error) {
+	section, key, err := splitKey(name)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open config file: %w", err)
+	}
+	defer file.Close()
+
+	var values []string
+	err = parseFile(path, file, func(s, k, v string) error {
+		if canonicalSection(s) == section && k == key {
+			values = append(values, v)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// Set sets a key in the INI config file at path, replacing every value it
+// had, and leaves the rest of the file, comments included, as it was. The
+// value is checked as loading the file would check it, and the edited file
+// must load; if the config was valid before, it must still be valid.
+func Set(path, name, value string) error {
+	section, key, err := splitKey(name)
+	if err != nil {
+		return err
+	}
+	if ext := strings.ToLower(filepath.Ext(path)); ext == ".toml" || ext == ".yaml" || ext == ".yml" {
+		return fmt.Errorf("only INI configs can be edited; edit %s by hand", path)
+	}
+	if strings.ContainsAny(value, "\r\n") {
+		return fmt.Errorf("%s: values must be on one line", name)
+	}
+	if err := Default().set(section, key, value); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+
+	old, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read config file: %w", err)
+	}
+	updated := setINIValue(string(old), section, key, value)
+
+	cfg, err := load(path, strings.NewReader(updated))
+	if err != nil {
+		return fmt.Errorf("config would not load: %w", err)
+	}
+	if oldCfg, err := LoadFromFile(path); err == nil && oldCfg.Validate() == nil {
+		if err := cfg.Validate(); err != nil {
+			return fmt.Errorf("invalid configuration: %w", err)
+		}
+	}
+	return writeConfigFile(path, []byte(updated))
+}
+
+// splitKey splits section.key into its INI section and key. Keys never
+// contain dots, so the key follows the last one; a section naming a feed,
+// group, or author may separate the URL or name with a dot or a space.
+func splitKey(name string) (section, key string, err error) {
+	i := strings.LastIndex(name, ".")
+	if i <= 0 || i == len(name)-1 {
+		return "", "", fmt.Errorf("config key must be section.key (e.g. planet.days), got %q", name)
+	}
+	section, key = name[:i], name[i+1:]
+	for _, prefix := range []string{"feed.", "filters.", "sanitize.", "group.", "author."} {
+		if url, ok := strings.CutPrefix(section, prefix); ok {
+			section = strings.TrimSuffix(prefix, ".") + " " + url
+		}
+	}
+	section = canonicalSection(section)
+	if !knownKey(section, key) {
+		return "", "", fmt.Errorf("unknown config key %s", name)
+	}
+	return section, key, nil
+}
+
+// canonicalSection returns the section a header names, treating keys
+// before the first header as [planet]
+func canonicalSection(s string) string {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return "planet"
+	}
+	if word, rest, ok := strings.Cut(s, " "); ok {
+		return word + " " + strings.TrimSpace(rest)
+	}
+	return s
+}
+
+// knownKey reports whether loading a config uses key in section. Loading
+// ignores unknown keys, so instead of keeping a second list of keys, this
+// sets the key to two values no setting shares and checks whether either
+// changed the config or was rejected.
+func knownKey(section, key string) bool {
+	// Per-feed sections take the keys of their global sections
+	for _, prefix := range []string{"filters ", "sanitize "} {
+		if strings.HasPrefix(section, prefix) {
+			section = strings.TrimSuffix(prefix, " ")
+		}
+	}
+	// Setting any key in a section may create the section's entry, so
+	// compare with a config where a key no section knows was set
+	baseline := Default()
+	if err := baseline.set(section, "\x00", ""); err != nil {
+		return false
+	}
+	for _, probe := range []string{"", "\x00probe"} {
+		cfg := Default()
+		if err := cfg.set(section, key, probe); err != nil || !reflect.DeepEqual(cfg, baseline) {
+			return true
+		}
+	}
+	return false
+}
+
+// setINIValue returns content with key in section set to value: the first
+// line setting it is rewritten and any others removed. A key that is not set
+// is added after the last setting in its section, and a missing section is
+// added at the end.
+func setINIValue(content, section, key, value string) string {
+	newline := "\n"
+	if strings.Contains(content, "\r\n") {
+		newline = "\r\n"
+	}
+	// parseINI strips one pair of surrounding quotes, so quote values that
+	// would otherwise lose their own quotes or spaces
+	if value != strings.TrimSpace(value) || (len(value) >= 2 && strings.ContainsAny(value[:1], `"'`) && value[len(value)-1] == value[0]) {
+		value = `"` + value + `"`
+	}
+
+	lines := strings.SplitAfter(content, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	var out []string
+	current := "planet"
+	set := false
+	insertAt := -1 // After the last setting in the section's first block
+	passed := false
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "[") && strings.HasSuffix(trimmed, "]") {
+			if current == section && insertAt >= 0 {
+				passed = true
+			}
+			current = canonicalSection(trimmed[1 : len(trimmed)-1])
+			out = append(out, line)
+			if current == section && insertAt < 0 {
+				insertAt = len(out)
+			}
+			continue
+		}
+		if current != section || trimmed == "" || strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, ";") {
+			out = append(out, line)
+			continue
+		}
+
+		k, _, ok := strings.Cut(trimmed, "=")
+		if ok && strings.TrimSpace(k) == key {
+			if set {
+				continue
+			}
+			set = true
+			eq := strings.Index(line, "=")
+			ending := line[len(strings.TrimRight(line, "\r\n")):]
+			line = line[:eq+1] + " " + value + ending
+		}
+		out = append(out, line)
+		if !passed {
+			insertAt = len(out)
+		}
+	}
+	if set {
+		return strings.Join(out, "")
+	}
+
+	if len(out) > 0 && !strings.HasSuffix(out[len(out)-1], "\n") {
+		out[len(out)-1] += newline
+	}
+	entry := key + " = " + value + newline
+	if insertAt < 0 {
+		if len(out) > 0 {
+			out = append(out, newline)
+		}
+		out = append(out, "["+section+"]"+newline, entry)
+		return strings.Join(out, "")
+	}
+	out = append(out[:insertAt], append([]string{entry}, out[insertAt:]...)...)
+	return strings.Join(out, "")
+}
+
+// writeConfigFile replaces path with data, keeping its permissions, so a
+// crash never leaves a half-written config
+func writeConfigFile(path string, data []byte) error {
+	if err := atomicfile.Write(path, data, 0644); err != nil {
+		return fmt.Errorf("write config file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,203 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+const editConfig = `# My planet
+[planet]
+name = Test Planet
+days=7
+# Keep an eye on this one
+
+[filters]
+exclude_keywords = ads
+exclude_keywords = sponsored
+
+[feed https://example.com/feed.xml]
+extract_content = true
+`
+
+func TestSet(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		key   string
+		value string
+		want  string // Replaces the first occurrence of the line before it
+		from  string
+	}{
+		{"replace", "planet.days", "14", "days= 14\n", "days=7\n"},
+		{"add to section", "planet.link", "https://planet.example.com", "days=7\nlink = https://planet.example.com\n", "days=7\n"},
+		{"list collapsed", "filters.exclude_keywords", "ads, sponsored", "exclude_keywords = ads, sponsored\n\n[feed", "exclude_keywords = ads\nexclude_keywords = sponsored\n\n[feed"},
+		{"feed section", "feed.https://example.com/feed.xml.extract_content", "false", "extract_content = false\n", "extract_content = true\n"},
+		{"feed section with space", "feed https://example.com/feed.xml.token", "abc", "extract_content = true\ntoken = abc\n", "extract_content = true\n"},
+		{"new section", "publish.rsync", "www@example.com:/srv/", "extract_content = true\n\n[publish]\nrsync = www@example.com:/srv/\n", "extract_content = true\n"},
+		{"quoted", "planet.owner_name", " padded ", "days=7\nowner_name = \" padded \"\n", "days=7\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			path := filepath.Join(t.TempDir(), "config.ini")
+			if err := os.WriteFile(path, []byte(editConfig), 0600); err != nil {
+				t.Fatal(err)
+			}
+			if err := Set(path, tt.key, tt.value); err != nil {
+				t.Fatalf("Set() error = %v", err)
+			}
+			got, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := strings.Replace(editConfig, tt.from, tt.want, 1)
+			if string(got) != want {
+				t.Errorf("config =\n%s\nwant\n%s", got, want)
+			}
+			if info, err := os.Stat(path); err == nil && info.Mode().Perm() != 0600 && runtime.GOOS != "windows" {
+				t.Errorf("mode = %v, want 0600 kept", info.Mode().Perm())
+			}
+
+			values, err := Get(path, tt.key)
+			if err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			if len(values) != 1 || values[0] != tt.value {
+				t.Errorf("Get() = %q, want [%q]", values, tt.value)
+			}
+		})
+	}
+}
+
+func TestSetRejects(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.ini")
+	if err := os.WriteFile(path, []byte(editConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		key     string
+		value   string
+		wantErr string
+	}{
+		{"planet.days", "0", "days must be >= 1"},
+		{"planet.dayz", "14", "unknown config key planet.dayz"},
+		{"days", "14", "must be section.key"},
+		{"planet.name", "a\nb", "one line"},
+		{"planet.generate_sitemap", "true", "invalid configuration"},
+		{"feed.https://example.com/feed.xml.header", "nope", "invalid header"},
+	}
+	for _, tt := range tests {
+		err := Set(path, tt.key, tt.value)
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("Set(%s, %q) error = %v, want one containing %q", tt.key, tt.value, err, tt.wantErr)
+		}
+	}
+	if got, _ := os.ReadFile(path); string(got) != editConfig {
+		t.Errorf("rejected edits changed the file:\n%s", got)
+	}
+
+	toml := filepath.Join(dir, "config.toml")
+	if err := os.WriteFile(toml, []byte("[planet]\nname = \"X\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Set(toml, "planet.days", "14"); err == nil {
+		t.Error("Set() edited a TOML config")
+	}
+	if values, err := Get(toml, "planet.name"); err != nil || len(values) != 1 || values[0] != "X" {
+		t.Errorf("Get() from TOML = %q, %v", values, err)
+	}
+}
+
+func TestGet(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "config.ini")
+	if err := os.WriteFile(path, []byte("days = 3\n"+editConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	values, err := Get(path, "planet.days")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if strings.Join(values, ",") != "3,7" {
+		t.Errorf("Get(planet.days) = %q, want keys before any section to count as [planet]", values)
+	}
+	if values, _ := Get(path, "filters.exclude_keywords"); len(values) != 2 {
+		t.Errorf("Get(filters.exclude_keywords) = %q, want both lines", values)
+	}
+	if values, err := Get(path, "planet.link"); err != nil || len(values) != 0 {
+		t.Errorf("Get() of an unset key = %q, %v; want nothing", values, err)
+	}
+	if _, err := Get(path, "planet.nonsense"); err == nil {
+		t.Error("Get() of an unknown key should fail")
+	}
+}
+
+func TestKnownKey(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		section, key string
+		want         bool
+	}{
+		{"planet", "name", true},
+		{"planet", "template", true},
+		{"planet", "sort_by", true},
+		{"planet", "days", true},
+		{"planet", "bogus", false},
+		{"database", "dsn", true},
+		{"database", "entry_revisions", true},
+		{"database", "entry_revision_days", true},
+		{"notify", "email_to", true},
+		{"publish", "always", true},
+		{"sanitize", "trust", true},
+		{"sanitize https://example.com/", "allow_tags", true},
+		{"sanitize https://example.com/", "bogus", false},
+		{"filters https://example.com/", "include_regex", true},
+		{"feed https://example.com/", "username", true},
+		{"feed https://example.com/", "bogus", false},
+		{"group Core team", "max_entries", true},
+		{"group Core team", "bogus", false},
+		{"author John Smith", "alias", true},
+		{"author John Smith", "bogus", false},
+		{"translate", "target_language", true},
+		{"translate", "fields", true},
+		{"translate", "bogus", false},
+		{"feed https://example.com/", "translate", true},
+		{"feed https://example.com/", "og_image", true},
+		{"feed https://example.com/", "timezone", true},
+		{"planet", "image", true},
+		{"planet", "accent_color", true},
+		{"planet", "accent_color_dark", true},
+		{"planet", "minify", true},
+		{"planet", "max_bandwidth_kbps", true},
+		{"planet", "allow_networks", true},
+		{"planet", "allow_hosts", true},
+		{"planet", "tls_ca_file", true},
+		{"planet", "tls_min_version", true},
+		{"feed https://example.com/", "insecure_skip_verify", true},
+		{"planet", "response_cache_dir", true},
+		{"planet", "response_cache_max_age_minutes", true},
+		{"planet", "undated_entries", true},
+		{"planet", "max_parsed_entries", true},
+		{"planet", "low_memory", true},
+		{"planet", "fetch_stage_timeout_minutes", true},
+		{"planet", "generate_stage_timeout_minutes", true},
+		{"planet", "feed_timeout_seconds", true},
+		{"planet", "rate_limit_wait_seconds", true},
+		{"planet", "future_dates", true},
+		{"nosuchsection", "name", false},
+	}
+	for _, tt := range tests {
+		if got := knownKey(tt.section, tt.key); got != tt.want {
+			t.Errorf("knownKey(%q, %q) = %v, want %v", tt.section, tt.key, got, tt.want)
+		}
+	}
+}
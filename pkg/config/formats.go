@@ -0,0 +1,202 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Locate returns path, or when path is a config.ini that does not exist, a
+// config.toml, config.yaml, or config.yml next to it. Commands default to
+// ./config.ini, so this lets a planet switch formats without passing --config.
+func Locate(path string) string {
+	if filepath.Base(path) != "config.ini" {
+		return path
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		return path
+	}
+	dir := filepath.Dir(path)
+	for _, name := range []string{"config.toml", "config.yaml", "config.yml"} {
+		candidate := filepath.Join(dir, name)
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate
+		}
+	}
+	return path
+}
+
+// parseFile reads a config or secrets file in the format its extension
+// names: TOML (.toml), YAML (.yaml, .yml), or INI (anything else). Every
+// format is read into the same sections and keys, so a value is validated
+// the same way whichever format it is written in.
+func parseFile(path string, r io.Reader, set func(section, key, value string) error) error {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".toml":
+		return parseTOML(r, set)
+	case ".yaml", ".yml":
+		return parseYAML(r, set)
+	default:
+		return parseINI(r, set)
+	}
+}
+
+// setNested sets a key nested in tables, as TOML and YAML write them, as the
+// INI key it stands for: top-level keys belong to [planet], and a table
+// nested in another, like [feed."https://example.com/feed.xml"], is the
+// section [feed https://example.com/feed.xml].
+func setNested(set func(section, key, value string) error, path []string, value string) error {
+	switch len(path) {
+	case 1:
+		return set("", path[0], value)
+	case 2:
+		return set(path[0], path[1], value)
+	case 3:
+		return set(path[0]+" "+path[1], path[2], value)
+	default:
+		return fmt.Errorf("%s is nested too deeply", strings.Join(path, "."))
+	}
+}
+
+// parseYAML reads a YAML config. Lists set their key once per item, like a
+// key repeated in an INI file.
+func parseYAML(r io.Reader, set func(section, key, value string) error) error {
+	var doc yaml.Node
+	if err := yaml.NewDecoder(r).Decode(&doc); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		return fmt.Errorf("parse YAML: %w", err)
+	}
+	if len(doc.Content) == 0 {
+		return nil
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: config must be a mapping of sections", root.Line)
+	}
+	return walkYAML(root, nil, set)
+}
+
+// walkYAML sets the values under n, found at path
+func walkYAML(n *yaml.Node, path []string, set func(section, key, value string) error) error {
+	switch n.Kind {
+	case yaml.AliasNode:
+		return walkYAML(n.Alias, path, set)
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key := n.Content[i]
+			if key.Kind != yaml.ScalarNode {
+				return fmt.Errorf("line %d: keys must be plain values", key.Line)
+			}
+			if err := walkYAML(n.Content[i+1], append(path[:len(path):len(path)], key.Value), set); err != nil {
+				return err
+			}
+		}
+		return nil
+	case yaml.SequenceNode:
+		for _, item := range n.Content {
+			if item.Kind == yaml.AliasNode {
+				item = item.Alias
+			}
+			if item.Kind != yaml.ScalarNode {
+				return fmt.Errorf("line %d: %s may only list plain values", item.Line, strings.Join(path, "."))
+			}
+			if err := walkYAML(item, path, set); err != nil {
+				return err
+			}
+		}
+		return nil
+	case yaml.ScalarNode:
+		value := n.Value
+		if n.Tag == "!!null" {
+			value = ""
+		}
+		if err := setNested(set, path, value); err != nil {
+			return fmt.Errorf("line %d: %w", n.Line, err)
+		}
+		return nil
+	default:
+		return fmt.Errorf("line %d: unexpected YAML node", n.Line)
+	}
+}
+
+// parseTOML reads a TOML config. Arrays set their key once per item, like a
+// key repeated in an INI file, and keys are set in the order the file gives
+// them. Arrays of tables have no INI equivalent and are rejected.
+func parseTOML(r io.Reader, set func(section, key, value string) error) error {
+	var doc map[string]any
+	md, err := toml.NewDecoder(r).Decode(&doc)
+	if err != nil {
+		return fmt.Errorf("parse TOML: %w", err)
+	}
+	for _, key := range md.Keys() {
+		if err := setTOML(doc, key, set); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setTOML sets the value of key in doc, unless it is a table, whose keys
+// are set in their own turn. Errors setting it name the key, as TOML keys
+// have no line numbers once decoded.
+func setTOML(doc map[string]any, key toml.Key, set func(section, key, value string) error) error {
+	var value any = doc
+	for _, part := range key {
+		value = value.(map[string]any)[part]
+	}
+	setKey := func(section, name, value string) error {
+		if err := set(section, name, value); err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		return nil
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		return nil
+	case []map[string]any:
+		return fmt.Errorf("%s: arrays of tables are not supported", key)
+	case []any:
+		for _, item := range v {
+			switch item.(type) {
+			case map[string]any, []any, []map[string]any:
+				return fmt.Errorf("%s may only list plain values", key)
+			}
+			if err := setNested(setKey, key, tomlString(item)); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return setNested(setKey, key, tomlString(v))
+	}
+}
+
+// tomlString returns a decoded TOML value as the text an INI file would
+// give it
+func tomlString(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	default:
+		return fmt.Sprint(v)
+	}
+}
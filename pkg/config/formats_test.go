@@ -0,0 +1,208 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// The same planet, written in each format
+var formatConfigs = map[string]string{
+	"config.ini": `[planet]
+name = Go Planet
+days = 14
+archives = true
+
+[filters]
+exclude_keywords = sponsored, advert
+exclude_regex = (?i)^ad,vert
+
+[filters https://example.com/feed.xml]
+include_categories = go
+
+[sanitize]
+allow_tags = video, audio
+
+[feed https://example.com/feed.xml]
+extract_content = true
+
+[publish]
+rsync = www@example.com:/var/www/
+timeout_seconds = 60
+`,
+	"config.toml": `# A TOML planet
+name = "Go Planet"
+
+[planet]
+days = 14
+archives = true
+
+[filters]
+exclude_keywords = ["sponsored", "advert"]
+exclude_regex = '(?i)^ad,vert'
+"https://example.com/feed.xml" = { include_categories = "go" }
+
+[sanitize]
+allow_tags = [
+  "video", # moving pictures
+  "audio",
+]
+
+[feed."https://example.com/feed.xml"]
+extract_content = true
+
+[publish]
+rsync = """
+www@example.com:/var/www/"""
+timeout_seconds = 6_0
+`,
+	"config.yaml": `planet:
+  name: Go Planet
+  days: 14
+  archives: true
+filters:
+  exclude_keywords: [sponsored, advert]
+  exclude_regex: (?i)^ad,vert
+  https://example.com/feed.xml:
+    include_categories: go
+sanitize:
+  allow_tags:
+    - video
+    - audio
+feed:
+  https://example.com/feed.xml:
+    extract_content: true
+publish:
+  rsync: www@example.com:/var/www/
+  timeout_seconds: 60
+`,
+}
+
+func TestLoadFromFile_Formats(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+
+	configs := map[string]*Config{}
+	for name, content := range formatConfigs {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		cfg, err := LoadFromFile(path)
+		if err != nil {
+			t.Fatalf("LoadFromFile(%s) error = %v", name, err)
+		}
+		configs[name] = cfg
+	}
+
+	want := configs["config.ini"]
+	if want.Planet.Name != "Go Planet" || want.Publish.TimeoutSeconds != 60 || len(want.Filters.ExcludeRegex) != 1 {
+		t.Fatalf("config.ini loaded as %+v", want)
+	}
+	for _, name := range []string{"config.toml", "config.yaml"} {
+		if !reflect.DeepEqual(configs[name], want) {
+			t.Errorf("%s loaded as\n%+v\nwant\n%+v", name, configs[name], want)
+		}
+	}
+}
+
+func TestLoadFromFile_FormatErrors(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		wantErr string
+	}{
+		{"TOML validation", "a.toml", "[planet]\nname = \"X\"\n\ndays = 0\n", "planet.days: days must be >= 1"},
+		{"YAML validation", "b.yaml", "planet:\n  name: X\n  days: 0\n", "line 3: days must be >= 1"},
+		{"unquoted TOML string", "c.toml", "[planet]\nname = Go Planet\n", "line 2 (last key \"planet.name\"): expected value"},
+		{"TOML array of tables", "d.toml", "[[feed]]\n", "arrays of tables are not supported"},
+		{"TOML array of inline tables", "k.toml", "[filters]\nexclude_keywords = [{ a = 1 }]\n", "filters.exclude_keywords may only list plain values"},
+		{"TOML nested too deeply", "e.toml", "[a.b]\nc.d = 1\n", "a.b.c.d is nested too deeply"},
+		{"TOML unterminated string", "f.toml", "name = \"Go\n", "line 1 (last key \"name\"): strings cannot contain newlines"},
+		{"TOML trailing text", "g.toml", "name = \"Go\" planet\n", "line 1: expected a top-level item to end with a newline"},
+		{"YAML list of maps", "h.yml", "filters:\n  exclude_keywords:\n    - a: b\n", "line 3: filters.exclude_keywords may only list plain values"},
+		{"YAML not a mapping", "i.yaml", "- planet\n", "config must be a mapping"},
+		{"YAML syntax", "j.yaml", "planet: [\n", "parse YAML"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.file)
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			_, err := LoadFromFile(path)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("LoadFromFile() error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadFromFile_TOMLSecrets(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	secrets := filepath.Join(dir, "secrets.toml")
+	config := filepath.Join(dir, "config.yaml")
+
+	if err := os.WriteFile(config, []byte("planet:\n  secrets_file: "+secrets+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(secrets, []byte("[feed.'https://example.com/feed.xml']\ntoken = \"s3cr\\u00e9t\"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadFromFile(config)
+	if err != nil {
+		t.Fatalf("LoadFromFile() error = %v", err)
+	}
+	if got := cfg.FeedSettings["https://example.com/feed.xml"].Token; got != "s3crét" {
+		t.Errorf("token = %q, want %q", got, "s3crét")
+	}
+
+	if err := os.WriteFile(secrets, []byte("[planet]\nname = \"X\"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadFromFile(config); err == nil {
+		t.Error("a TOML secrets file may set only what an INI one may")
+	}
+}
+
+func TestLocate(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	ini := filepath.Join(dir, "config.ini")
+
+	if got := Locate(ini); got != ini {
+		t.Errorf("Locate() with no config = %q, want %q", got, ini)
+	}
+	yml := filepath.Join(dir, "config.yml")
+	if err := os.WriteFile(yml, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := Locate(ini); got != yml {
+		t.Errorf("Locate() = %q, want %q", got, yml)
+	}
+	toml := filepath.Join(dir, "config.toml")
+	if err := os.WriteFile(toml, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := Locate(ini); got != toml {
+		t.Errorf("Locate() = %q, want TOML ahead of YAML", got)
+	}
+	if err := os.WriteFile(ini, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := Locate(ini); got != ini {
+		t.Errorf("Locate() = %q, want the INI file that exists", got)
+	}
+	other := filepath.Join(dir, "planet.ini")
+	if got := Locate(other); got != other {
+		t.Errorf("Locate(%q) = %q, only config.ini falls back", other, got)
+	}
+}
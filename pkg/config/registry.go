@@ -0,0 +1,121 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// RegistryEnv overrides where the planet registry is kept
+const RegistryEnv = "RP_PLANETS"
+
+// RegisteredPlanet is a planet in the registry, which lets one installation
+// run several planets by name
+type RegisteredPlanet struct {
+	Name   string
+	Config string // Absolute path to the planet's config file
+}
+
+// Dir is the planet's directory, where relative paths in its config resolve
+func (p RegisteredPlanet) Dir() string {
+	return filepath.Dir(p.Config)
+}
+
+// RegistryPath returns the registry file: $RP_PLANETS, or planets.ini in
+// the user's config directory (e.g. ~/.config/rogue-planet/planets.ini)
+func RegistryPath() (string, error) {
+	if path := os.Getenv(RegistryEnv); path != "" {
+		return path, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("find planet registry: %w", err)
+	}
+	return filepath.Join(dir, "rogue-planet", "planets.ini"), nil
+}
+
+// LoadRegistry reads the registry at path, sorted by name. A registry that
+// does not exist has no planets.
+//
+//	[planet work]
+//	config = /home/me/planets/work/config.ini
+func LoadRegistry(path string) ([]RegisteredPlanet, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("open planet registry: %w", err)
+	}
+	defer file.Close()
+
+	byName := map[string]string{}
+	err = parseINI(file, func(section, key, value string) error {
+		name, ok := strings.CutPrefix(section, "planet ")
+		if !ok {
+			return fmt.Errorf("planet registry may only contain [planet <name>] sections, found [%s]", section)
+		}
+		name = strings.TrimSpace(name)
+		if err := ValidatePlanetName(name); err != nil {
+			return err
+		}
+		if key == "config" {
+			byName[name] = value
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("planet registry %s: %w", path, err)
+	}
+
+	planets := make([]RegisteredPlanet, 0, len(byName))
+	for name, config := range byName {
+		planets = append(planets, RegisteredPlanet{Name: name, Config: config})
+	}
+	sort.Slice(planets, func(i, j int) bool { return planets[i].Name < planets[j].Name })
+	return planets, nil
+}
+
+// SaveRegistry replaces the registry at path with planets
+func SaveRegistry(path string, planets []RegisteredPlanet) error {
+	var b strings.Builder
+	b.WriteString("# Planets run by this installation; edit with rp planets add and rp planets remove\n")
+	for _, p := range planets {
+		fmt.Fprintf(&b, "\n[planet %s]\nconfig = %s\n", p.Name, p.Config)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("create planet registry directory: %w", err)
+	}
+	return writeConfigFile(path, []byte(b.String()))
+}
+
+// FindPlanet returns the planet called name
+func FindPlanet(planets []RegisteredPlanet, name string) (RegisteredPlanet, error) {
+	names := make([]string, 0, len(planets))
+	for _, p := range planets {
+		if p.Name == name {
+			return p, nil
+		}
+		names = append(names, p.Name)
+	}
+	if len(names) == 0 {
+		return RegisteredPlanet{}, fmt.Errorf("no planet named %s (no planets are registered; add one with 'rp planets add')", name)
+	}
+	return RegisteredPlanet{}, fmt.Errorf("no planet named %s (registered: %s)", name, strings.Join(names, ", "))
+}
+
+// ValidatePlanetName checks that name can be used on the command line and
+// in the registry
+func ValidatePlanetName(name string) error {
+	if name == "" || name[0] == '-' {
+		return fmt.Errorf("invalid planet name %q", name)
+	}
+	for _, c := range name {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '.' || c == '_' || c == '-') {
+			return fmt.Errorf("invalid planet name %q: use letters, digits, '.', '_', and '-'", name)
+		}
+	}
+	return nil
+}
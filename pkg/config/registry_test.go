@@ -0,0 +1,64 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "rogue-planet", "planets.ini")
+
+	planets, err := LoadRegistry(path)
+	if err != nil || len(planets) != 0 {
+		t.Fatalf("LoadRegistry() of a missing file = %v, %v; want no planets", planets, err)
+	}
+
+	want := []RegisteredPlanet{
+		{Name: "home", Config: filepath.Join("/planets", "home", "config.ini")},
+		{Name: "work", Config: filepath.Join("/planets", "work", "config.toml")},
+	}
+	if err := SaveRegistry(path, []RegisteredPlanet{want[1], want[0]}); err != nil {
+		t.Fatalf("SaveRegistry() error = %v", err)
+	}
+	planets, err = LoadRegistry(path)
+	if err != nil {
+		t.Fatalf("LoadRegistry() error = %v", err)
+	}
+	if len(planets) != 2 || planets[0] != want[0] || planets[1] != want[1] {
+		t.Errorf("LoadRegistry() = %+v, want %+v sorted by name", planets, want)
+	}
+	if planets[1].Dir() != filepath.Join("/planets", "work") {
+		t.Errorf("Dir() = %q", planets[1].Dir())
+	}
+
+	if p, err := FindPlanet(planets, "work"); err != nil || p != want[1] {
+		t.Errorf("FindPlanet(work) = %+v, %v", p, err)
+	}
+	if _, err := FindPlanet(planets, "play"); err == nil || !strings.Contains(err.Error(), "home, work") {
+		t.Errorf("FindPlanet(play) error = %v, want one listing the planets", err)
+	}
+
+	if err := os.WriteFile(path, []byte("[planet]\nname = oops\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadRegistry(path); err == nil {
+		t.Error("LoadRegistry() accepted a config file")
+	}
+}
+
+func TestValidatePlanetName(t *testing.T) {
+	t.Parallel()
+	for _, name := range []string{"work", "planet-2", "go.dev_mirror"} {
+		if err := ValidatePlanetName(name); err != nil {
+			t.Errorf("ValidatePlanetName(%q) error = %v", name, err)
+		}
+	}
+	for _, name := range []string{"", "-work", "my planet", "a/b", "wörk]"} {
+		if err := ValidatePlanetName(name); err == nil {
+			t.Errorf("ValidatePlanetName(%q) accepted it", name)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"golang.org/x/time/rate"
+)
+
+// minBandwidthBurst is the smallest burst of a bandwidth limit, in bytes,
+// so each read can fetch a useful amount at low rates
+const minBandwidthBurst = 4096
+
+// WithBandwidthLimit returns a copy of the crawler whose response bodies,
+// across all of its requests and those of copies made from it, are
+// downloaded at no more than kbps kilobits per second. Zero removes the
+// limit. The copy shares the original's connection pool.
+//
+// Bytes are counted as received, before decompression. Reading slowly
+// leaves the rest in the connection's buffers, so TCP flow control slows
+// the sender rather than the data being discarded.
+func (c *Crawler) WithBandwidthLimit(kbps int) *Crawler {
+	limited := *c
+	limited.bandwidth = nil
+	if kbps > 0 {
+		bytesPerSecond := kbps * 1000 / 8
+		limited.bandwidth = rate.NewLimiter(rate.Limit(bytesPerSecond), max(bytesPerSecond/4, minBandwidthBurst))
+	}
+	return &limited
+}
+
+// throttledReader reads no faster than its limiter allows
+type throttledReader struct {
+	ctx     context.Context
+	r       io.Reader
+	limiter *rate.Limiter
+}
+
+func (t *throttledReader) Read(p []byte) (int, error) {
+	if burst := t.limiter.Burst(); len(p) > burst {
+		p = p[:burst]
+	}
+	n, err := t.r.Read(p)
+	if n > 0 {
+		if waitErr := t.limiter.WaitN(t.ctx, n); waitErr != nil {
+			if ctxErr := t.ctx.Err(); ctxErr != nil {
+				return n, ctxErr
+			}
+			// The wait would outlast the context's deadline
+			return n, fmt.Errorf("bandwidth limit: %w", context.DeadlineExceeded)
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,75 @@
+package crawler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFetch_BandwidthLimit(t *testing.T) {
+	t.Parallel()
+	body := bytes.Repeat([]byte("x"), 40000)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(body)
+	}))
+	defer server.Close()
+
+	// 800 kbit/s is 100,000 bytes a second, with a burst of 25,000 bytes,
+	// shared by copies of the crawler
+	c := NewForTesting().WithBandwidthLimit(800)
+	copies := []*Crawler{c, c.WithMaxSize(MaxFeedSize)}
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for _, cc := range copies {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := cc.Fetch(context.Background(), server.URL, FeedCache{})
+			if err != nil {
+				t.Errorf("Fetch() error = %v", err)
+				return
+			}
+			if !bytes.Equal(resp.Body, body) {
+				t.Errorf("Fetch() body has %d bytes, want %d", len(resp.Body), len(body))
+			}
+		}()
+	}
+	wg.Wait()
+
+	// 80,000 bytes less the burst take at least 0.55s at the limit
+	if elapsed := time.Since(start); elapsed < 500*time.Millisecond {
+		t.Errorf("downloads took %s, want them held to the shared limit", elapsed)
+	}
+}
+
+func TestFetch_BandwidthLimitCancelled(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(bytes.Repeat([]byte("x"), 100000))
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	// 8 kbit/s would take over ten seconds
+	_, err := NewForTesting().WithBandwidthLimit(8).Fetch(ctx, server.URL, FeedCache{})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Fetch() error = %v, want the deadline to stop a throttled download", err)
+	}
+}
+
+func TestWithBandwidthLimit_Zero(t *testing.T) {
+	t.Parallel()
+	if c := NewForTesting().WithBandwidthLimit(100).WithBandwidthLimit(0); c.bandwidth != nil {
+		t.Error("WithBandwidthLimit(0) should remove the limit")
+	}
+	if c := NewWithConfig(CrawlerConfig{}); c.bandwidth != nil {
+		t.Error("crawler should be unlimited by default")
+	}
+}
@@ -6,17 +6,20 @@
 package crawler
 
 import (
-	"compress/gzip"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
 	"io"
 	"math/rand"
 	"net"
 	"net/http"
-	"net/url"
+	"strconv"
 	"strings"
 	"time"
+
+	"golang.org/x/time/rate"
 )
 
 const (
@@ -26,6 +29,8 @@ const (
 	DefaultTimeout = 30 * time.Second
 	// MaxRedirects prevents redirect loops
 	MaxRedirects = 5
+	// MaxCacheLifetime caps how long a feed is skipped on the strength of its cache headers
+	MaxCacheLifetime = 24 * time.Hour
 	// UserAgent identifies the bot
 	UserAgent = "RoguePlanet/0.4 (+https://github.com/adewale/rogue_planet)"
 )
@@ -35,14 +40,40 @@ var (
 	ErrPrivateIP       = errors.New("private or internal IP not allowed")
 	ErrInvalidScheme   = errors.New("only http and https schemes allowed")
 	ErrMaxSizeExceeded = errors.New("response body exceeds maximum size")
+	ErrGone            = errors.New("gone (HTTP 410)")
+	ErrHostNotFound    = errors.New("host not found")
 )
 
+// Credentials authenticate requests for one feed. They are sent only with
+// requests for that feed's URL, and are dropped if it redirects to another
+// host or port.
+type Credentials struct {
+	Username string            // HTTP Basic auth user name, sent with Password
+	Password string            // HTTP Basic auth password
+	Token    string            // Bearer token, used when Username and Password are empty
+	Headers  map[string]string // Extra request headers
+}
+
+// apply adds the credentials to a request
+func (cr Credentials) apply(req *http.Request) {
+	for name, value := range cr.Headers {
+		req.Header.Set(name, value)
+	}
+	switch {
+	case cr.Username != "" || cr.Password != "":
+		req.SetBasicAuth(cr.Username, cr.Password)
+	case cr.Token != "":
+		req.Header.Set("Authorization", "Bearer "+cr.Token)
+	}
+}
+
 // FeedCache stores HTTP caching headers for conditional requests
 type FeedCache struct {
 	URL          string
 	ETag         string // Stored exactly as received, including quotes
 	LastModified string // Stored exactly as received
 	LastFetched  time.Time
+	Expires      time.Time // End of the freshness lifetime from Cache-Control/Expires (zero if none)
 }
 
 // FeedResponse contains the fetched feed data and metadata
@@ -54,7 +85,56 @@ type FeedResponse struct {
 	FinalURL          string    // URL after redirects (for 301 permanent redirects)
 	PermanentRedirect bool      // True if a 301 redirect was encountered
 	FetchTime         time.Time
-	RetryAfter        time.Duration // Parsed Retry-After header for rate limiting (0 if not present)
+	RetryAfter        time.Duration     // Parsed Retry-After header for rate limiting (0 if not present)
+	Headers           map[string]string // Diagnostic response headers (see CaptureHeaders)
+	RobotsDisallowed  bool              // robots.txt disallows the URL; fetched anyway under RobotsWarn
+	Proto             string            // Protocol of the final response, e.g. "HTTP/2.0"
+	ContentEncoding   string            // Content-Encoding of the body as sent ("" if uncompressed)
+	WireBytes         int64             // Body bytes received, before decoding
+	DecodedBytes      int64             // Body bytes after decoding
+	Replayed          bool              // Served from the response cache rather than the network
+	Insecure          bool              // Fetched without verifying the TLS certificate (insecure_skip_verify)
+}
+
+// capturedHeaders lists response headers kept for debugging fetch problems.
+// Headers with a prefix in capturedHeaderPrefixes are also kept.
+var capturedHeaders = []string{
+	"Server",
+	"Cache-Control",
+	"Expires",
+	"Age",
+	"Retry-After",
+	"Content-Type",
+	"CF-Ray",
+	"CF-Cache-Status",
+	"Via",
+}
+
+var capturedHeaderPrefixes = []string{
+	"X-Ratelimit-",
+}
+
+// CaptureHeaders extracts the diagnostic subset of response headers.
+// Header names are returned in canonical form; multiple values are joined with ", ".
+func CaptureHeaders(h http.Header) map[string]string {
+	captured := make(map[string]string)
+
+	for _, name := range capturedHeaders {
+		if values := h.Values(name); len(values) > 0 {
+			captured[http.CanonicalHeaderKey(name)] = strings.Join(values, ", ")
+		}
+	}
+
+	for name, values := range h {
+		canonical := http.CanonicalHeaderKey(name)
+		for _, prefix := range capturedHeaderPrefixes {
+			if strings.HasPrefix(canonical, prefix) {
+				captured[canonical] = strings.Join(values, ", ")
+			}
+		}
+	}
+
+	return captured
 }
 
 // Crawler handles HTTP fetching with proper conditional request support
@@ -62,11 +142,26 @@ type Crawler struct {
 	client        *http.Client
 	userAgent     string
 	maxSize       int64
-	skipSSRFCheck bool // For testing only - allows local URLs
+	skipSSRFCheck bool           // For testing only - allows local URLs
+	policy        Policy         // Internal addresses that may be fetched anyway
+	dialer        *guardedDialer // Connects to the addresses policy allows
+	robots        *robotsCache
+	robotsMode    RobotsMode
+	credentials   map[string]Credentials // Keyed by feed URL
+	insecureFeeds map[string]bool        // Feed URLs fetched without verifying TLS certificates
+	insecure      *http.Transport        // Skips certificate verification, for insecureFeeds (nil = none)
+	bandwidth     *rate.Limiter          // Shared download rate limit, in bytes per second (nil = none)
+	responses     *ResponseCache         // Saved responses, replayed offline or while fresh (nil = none)
+	offline       bool                   // Answer only from responses, never the network
 }
 
 // New creates a new Crawler with default settings
 func New() *Crawler {
+	dialer := &guardedDialer{dialer: &net.Dialer{
+		Timeout:   10 * time.Second, // TCP connection timeout
+		KeepAlive: 30 * time.Second, // TCP keep-alive
+	}}
+
 	// Configure HTTP transport with connection pooling
 	transport := &http.Transport{
 		// Connection pooling settings
@@ -76,10 +171,7 @@ func New() *Crawler {
 		IdleConnTimeout:     90 * time.Second, // Keep idle connections for reuse
 
 		// Timeouts for connection establishment
-		DialContext: (&net.Dialer{
-			Timeout:   10 * time.Second, // TCP connection timeout
-			KeepAlive: 30 * time.Second, // TCP keep-alive
-		}).DialContext,
+		DialContext: dialer.DialContext,
 
 		// TLS handshake timeout
 		TLSHandshakeTimeout: 10 * time.Second,
@@ -89,6 +181,12 @@ func New() *Crawler {
 
 		// Expect Continue timeout
 		ExpectContinueTimeout: 1 * time.Second,
+
+		// A custom DialContext turns off HTTP/2 unless it is asked for
+		ForceAttemptHTTP2: true,
+
+		// Compression is negotiated in Fetch, which also decodes brotli
+		DisableCompression: true,
 	}
 
 	return &Crawler{
@@ -105,6 +203,8 @@ func New() *Crawler {
 		userAgent:     UserAgent,
 		maxSize:       MaxFeedSize,
 		skipSSRFCheck: false,
+		dialer:        dialer,
+		robots:        newRobotsCache(),
 	}
 }
 
@@ -120,10 +220,33 @@ func NewWithUserAgent(userAgent string) *Crawler {
 // NewForTesting creates a Crawler that allows local URLs (for testing only)
 func NewForTesting() *Crawler {
 	c := New()
-	c.skipSSRFCheck = true
+	c.allowLocal()
 	return c
 }
 
+// allowLocal turns off SSRF protection, both the check of each URL and of
+// each address connected to (for testing only)
+func (c *Crawler) allowLocal() {
+	c.skipSSRFCheck = true
+	c.dialer.disabled = true
+}
+
+// WithMaxSize returns a copy of the crawler that rejects response bodies
+// larger than n bytes. The copy shares the original's connection pool.
+func (c *Crawler) WithMaxSize(n int64) *Crawler {
+	limited := *c
+	limited.maxSize = n
+	return &limited
+}
+
+// WithCredentials returns a copy of the crawler that authenticates requests
+// for the feed URLs in creds. The copy shares the original's connection pool.
+func (c *Crawler) WithCredentials(creds map[string]Credentials) *Crawler {
+	copied := *c
+	copied.credentials = creds
+	return &copied
+}
+
 // CrawlerConfig contains configuration options for HTTP connection pooling and timeouts
 type CrawlerConfig struct {
 	UserAgent                    string
@@ -131,10 +254,17 @@ type CrawlerConfig struct {
 	MaxIdleConnsPerHost          int
 	MaxConnsPerHost              int
 	IdleConnTimeoutSeconds       int
-	HTTPTimeoutSeconds           int // Overall HTTP request timeout (default: 30)
-	DialTimeoutSeconds           int // TCP connection timeout (default: 10)
-	TLSHandshakeTimeoutSeconds   int // TLS handshake timeout (default: 10)
-	ResponseHeaderTimeoutSeconds int // Response header timeout (default: 10)
+	HTTPTimeoutSeconds           int                    // Overall HTTP request timeout (default: 30)
+	DialTimeoutSeconds           int                    // TCP connection timeout (default: 10)
+	TLSHandshakeTimeoutSeconds   int                    // TLS handshake timeout (default: 10)
+	ResponseHeaderTimeoutSeconds int                    // Response header timeout (default: 10)
+	RobotsMode                   RobotsMode             // What to do about robots.txt (default: RobotsIgnore)
+	Credentials                  map[string]Credentials // Per-feed authentication, keyed by feed URL
+	MaxBandwidthKbps             int                    // Download rate cap across all requests, in kilobits per second (0 = unlimited)
+	SSRF                         Policy                 // Internal addresses that may be fetched anyway (default: none)
+	RootCAs                      *x509.CertPool         // Certificate authorities trusted for TLS (default: the system's)
+	MinTLSVersion                uint16                 // Oldest TLS version accepted, a crypto/tls constant (default: TLS 1.2)
+	InsecureSkipVerify           map[string]bool        // Feed URLs whose TLS certificates are not verified
 }
 
 // NewWithConfig creates a Crawler with custom configuration
@@ -160,6 +290,19 @@ func NewWithConfig(cfg CrawlerConfig) *Crawler {
 		responseHeaderTimeout = 10 // Default: 10 seconds
 	}
 
+	minTLSVersion := cfg.MinTLSVersion
+	if minTLSVersion == 0 {
+		minTLSVersion = tls.VersionTLS12
+	}
+
+	dialer := &guardedDialer{
+		policy: cfg.SSRF,
+		dialer: &net.Dialer{
+			Timeout:   time.Duration(dialTimeout) * time.Second,
+			KeepAlive: 30 * time.Second, // TCP keep-alive (not configurable)
+		},
+	}
+
 	// Configure HTTP transport with custom connection pooling
 	transport := &http.Transport{
 		// Connection pooling settings
@@ -169,10 +312,7 @@ func NewWithConfig(cfg CrawlerConfig) *Crawler {
 		IdleConnTimeout:     time.Duration(cfg.IdleConnTimeoutSeconds) * time.Second,
 
 		// Timeouts for connection establishment
-		DialContext: (&net.Dialer{
-			Timeout:   time.Duration(dialTimeout) * time.Second,
-			KeepAlive: 30 * time.Second, // TCP keep-alive (not configurable)
-		}).DialContext,
+		DialContext: dialer.DialContext,
 
 		// TLS handshake timeout
 		TLSHandshakeTimeout: time.Duration(tlsHandshakeTimeout) * time.Second,
@@ -182,6 +322,25 @@ func NewWithConfig(cfg CrawlerConfig) *Crawler {
 
 		// Expect Continue timeout
 		ExpectContinueTimeout: 1 * time.Second,
+
+		// A custom DialContext turns off HTTP/2 unless it is asked for
+		ForceAttemptHTTP2: true,
+
+		// Compression is negotiated in Fetch, which also decodes brotli
+		DisableCompression: true,
+
+		TLSClientConfig: &tls.Config{
+			RootCAs:    cfg.RootCAs,
+			MinVersion: minTLSVersion,
+		},
+	}
+
+	// Feeds with insecure_skip_verify get their own connections, so that no
+	// unverified connection is reused for another feed
+	var insecure *http.Transport
+	if len(cfg.InsecureSkipVerify) > 0 {
+		insecure = transport.Clone()
+		insecure.TLSClientConfig.InsecureSkipVerify = true
 	}
 
 	userAgent := cfg.UserAgent
@@ -189,7 +348,7 @@ func NewWithConfig(cfg CrawlerConfig) *Crawler {
 		userAgent = UserAgent
 	}
 
-	return &Crawler{
+	c := &Crawler{
 		client: &http.Client{
 			Transport: transport,
 			Timeout:   time.Duration(httpTimeout) * time.Second,
@@ -203,73 +362,44 @@ func NewWithConfig(cfg CrawlerConfig) *Crawler {
 		userAgent:     userAgent,
 		maxSize:       MaxFeedSize,
 		skipSSRFCheck: false,
-	}
+		policy:        cfg.SSRF,
+		dialer:        dialer,
+		robots:        newRobotsCache(),
+		robotsMode:    cfg.RobotsMode,
+		credentials:   cfg.Credentials,
+		insecureFeeds: cfg.InsecureSkipVerify,
+		insecure:      insecure,
+	}
+	return c.WithBandwidthLimit(cfg.MaxBandwidthKbps)
 }
 
-// ValidateURL checks if a URL is safe to fetch (SSRF prevention)
+// ValidateURL checks if a URL is safe to fetch (SSRF prevention) under the
+// default Policy, which allows no internal addresses
 func ValidateURL(rawURL string) error {
-	// Handle empty string explicitly
-	if rawURL == "" {
-		return ErrInvalidURL
-	}
-
-	parsed, err := url.Parse(rawURL)
-	if err != nil {
-		return fmt.Errorf("%w: %v", ErrInvalidURL, err)
-	}
-
-	// url.Parse can succeed but return invalid URLs (e.g., "not a url" parses but has no scheme)
-	// Explicitly check for presence of scheme
-	if parsed.Scheme == "" {
-		return ErrInvalidURL
-	}
-
-	// Only allow http and https
-	scheme := strings.ToLower(parsed.Scheme)
-	if scheme != "http" && scheme != "https" {
-		return ErrInvalidScheme
-	}
-
-	// Check hostname
-	host := parsed.Hostname()
-
-	// Block known internal hostnames
-	internalHosts := []string{"localhost", "127.0.0.1", "::1", "0.0.0.0"}
-	for _, blocked := range internalHosts {
-		if strings.EqualFold(host, blocked) {
-			return ErrPrivateIP
-		}
-	}
-
-	// Try to parse as IP address
-	ip := net.ParseIP(host)
-	if ip != nil {
-		// Block loopback
-		if ip.IsLoopback() {
-			return ErrPrivateIP
-		}
-		// Block private networks (RFC 1918)
-		if ip.IsPrivate() {
-			return ErrPrivateIP
-		}
-		// Block link-local
-		if ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() {
-			return ErrPrivateIP
-		}
-	}
-
-	return nil
+	return Policy{}.ValidateURL(rawURL)
 }
 
 // Fetch fetches a feed with conditional request support
 func (c *Crawler) Fetch(ctx context.Context, feedURL string, cache FeedCache) (*FeedResponse, error) {
 	// Validate URL for SSRF prevention (unless testing mode)
 	if !c.skipSSRFCheck {
-		if err := ValidateURL(feedURL); err != nil {
+		if err := c.policy.ValidateURL(feedURL); err != nil {
 			return nil, err
 		}
 	}
 
+	if c.offline {
+		return c.replay(feedURL)
+	}
+	if resp := c.responses.fresh(feedURL, time.Now()); resp != nil {
+		return resp, nil
+	}
+
+	robotsDisallowed, err := c.checkRobots(ctx, feedURL)
+	if err != nil {
+		return nil, err
+	}
+
 	req, err := http.NewRequestWithContext(ctx, "GET", feedURL, nil)
 	if err != nil {
 		return nil, fmt.Errorf("create request: %w", err)
@@ -278,6 +408,11 @@ func (c *Crawler) Fetch(ctx context.Context, feedURL string, cache FeedCache) (*
 	// Set User-Agent
 	req.Header.Set("User-Agent", c.userAgent)
 
+	creds, hasCreds := c.credentials[feedURL]
+	if hasCreds {
+		creds.apply(req)
+	}
+
 	// Set conditional request headers if we have cached values
 	if cache.LastModified != "" {
 		req.Header.Set("If-Modified-Since", cache.LastModified)
@@ -287,19 +422,34 @@ func (c *Crawler) Fetch(ctx context.Context, feedURL string, cache FeedCache) (*
 	}
 
 	// Request compression
-	req.Header.Set("Accept-Encoding", "gzip, deflate")
+	req.Header.Set("Accept-Encoding", AcceptEncoding)
 
 	// Track if we encountered a 301 permanent redirect
 	var sawPermanentRedirect bool
 
+	transport, insecure := c.transportFor(feedURL)
+
 	// Create a custom client for this request that tracks 301 redirects
 	customClient := &http.Client{
-		Transport: c.client.Transport,
+		Transport: transport,
 		Timeout:   c.client.Timeout,
 		CheckRedirect: func(req *http.Request, via []*http.Request) error {
 			if len(via) >= MaxRedirects {
 				return fmt.Errorf("stopped after %d redirects", MaxRedirects)
 			}
+			// Redirects are held to the same policy as the feed's URL
+			if !c.skipSSRFCheck {
+				if err := c.policy.ValidateURL(req.URL.String()); err != nil {
+					return err
+				}
+			}
+			// Keep credentials on the feed's own host and port
+			if hasCreds && !strings.EqualFold(req.URL.Host, via[0].URL.Host) {
+				req.Header.Del("Authorization")
+				for name := range creds.Headers {
+					req.Header.Del(name)
+				}
+			}
 			// Check if this redirect is a 301 Moved Permanently or 308 Permanent Redirect
 			// req.Response contains the response that triggered this redirect
 			if req.Response != nil && (req.Response.StatusCode == http.StatusMovedPermanently ||
@@ -313,6 +463,10 @@ func (c *Crawler) Fetch(ctx context.Context, feedURL string, cache FeedCache) (*
 	// Execute request
 	resp, err := customClient.Do(req)
 	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return nil, fmt.Errorf("fetch failed: %w: %w", ErrHostNotFound, err)
+		}
 		return nil, fmt.Errorf("fetch failed: %w", err)
 	}
 	defer resp.Body.Close()
@@ -320,46 +474,66 @@ func (c *Crawler) Fetch(ctx context.Context, feedURL string, cache FeedCache) (*
 	// Prepare response
 	fetchTime := time.Now()
 	finalURL := resp.Request.URL.String()
+	headers := CaptureHeaders(resp.Header)
 
 	// Handle 304 Not Modified
 	if resp.StatusCode == http.StatusNotModified {
+		if c.responses != nil {
+			// Failing to update the cache only means fetching sooner
+			_ = c.responses.revalidated(feedURL, fetchTime)
+		}
 		return &FeedResponse{
 			StatusCode:  resp.StatusCode,
 			NotModified: true,
+			Proto:       resp.Proto,
 			NewCache: FeedCache{
 				URL:          finalURL,
 				ETag:         cache.ETag,
 				LastModified: cache.LastModified,
 				LastFetched:  fetchTime,
+				Expires:      parseCacheExpiry(resp.Header, fetchTime),
 			},
 			FinalURL:          finalURL,
 			PermanentRedirect: sawPermanentRedirect,
 			FetchTime:         fetchTime,
+			Headers:           headers,
+			RobotsDisallowed:  robotsDisallowed,
+			Insecure:          insecure,
 		}, nil
 	}
 
 	// Handle non-200 responses
 	if resp.StatusCode != http.StatusOK {
 		retryAfter := parseRetryAfter(resp.Header.Get("Retry-After"))
+		statusErr := fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		if resp.StatusCode == http.StatusGone {
+			statusErr = ErrGone
+		}
 		return &FeedResponse{
 			StatusCode:        resp.StatusCode,
 			FinalURL:          finalURL,
 			PermanentRedirect: sawPermanentRedirect,
 			FetchTime:         fetchTime,
 			RetryAfter:        retryAfter,
-		}, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+			Proto:             resp.Proto,
+			Headers:           headers,
+			RobotsDisallowed:  robotsDisallowed,
+			Insecure:          insecure,
+		}, statusErr
 	}
 
-	// Handle gzip decompression if needed
-	var reader io.Reader = resp.Body
-	if resp.Header.Get("Content-Encoding") == "gzip" {
-		gzReader, err := gzip.NewReader(resp.Body)
-		if err != nil {
-			return nil, fmt.Errorf("create gzip reader: %w", err)
-		}
-		defer gzReader.Close()
-		reader = gzReader
+	// Decompress the body, counting the bytes that crossed the wire
+	var received io.Reader = resp.Body
+	if c.bandwidth != nil {
+		received = &throttledReader{ctx: ctx, r: received, limiter: c.bandwidth}
 	}
+	wire := &countingReader{r: received}
+	encoding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+	reader, err := decodeBody(wire, encoding)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
 
 	// Limit response body size - add 1 to detect when limit is exceeded
 	limitedReader := io.LimitedReader{
@@ -386,9 +560,10 @@ func (c *Crawler) Fetch(ctx context.Context, feedURL string, cache FeedCache) (*
 		ETag:         resp.Header.Get("ETag"),
 		LastModified: resp.Header.Get("Last-Modified"),
 		LastFetched:  fetchTime,
+		Expires:      parseCacheExpiry(resp.Header, fetchTime),
 	}
 
-	return &FeedResponse{
+	feedResp := &FeedResponse{
 		Body:              body,
 		StatusCode:        resp.StatusCode,
 		NotModified:       false,
@@ -396,7 +571,20 @@ func (c *Crawler) Fetch(ctx context.Context, feedURL string, cache FeedCache) (*
 		FinalURL:          finalURL,
 		PermanentRedirect: sawPermanentRedirect,
 		FetchTime:         fetchTime,
-	}, nil
+		Headers:           headers,
+		RobotsDisallowed:  robotsDisallowed,
+		Insecure:          insecure,
+		Proto:             resp.Proto,
+		ContentEncoding:   encoding,
+		WireBytes:         wire.n,
+		DecodedBytes:      int64(len(body)),
+	}
+	if c.responses != nil {
+		// The cache is a development aid; a response it can't save is
+		// fetched again next time
+		_ = c.responses.Save(feedURL, feedResp)
+	}
+	return feedResp, nil
 }
 
 // parseRetryAfter parses the Retry-After header value.
@@ -434,8 +622,65 @@ func parseRetryAfterSeconds(value string) time.Duration {
 	return time.Duration(seconds) * time.Second
 }
 
+// parseCacheExpiry returns when a response stops being fresh, per RFC 9111.
+// Cache-Control max-age (less Age) takes precedence over Expires; Expires is
+// measured against the Date header to tolerate clock skew. no-cache and
+// no-store mean the feed must always be fetched. The lifetime is capped at
+// MaxCacheLifetime; zero is returned if the response carries no lifetime.
+func parseCacheExpiry(h http.Header, fetchTime time.Time) time.Time {
+	var lifetime time.Duration
+	hasMaxAge := false
+
+	for _, directive := range strings.Split(h.Get("Cache-Control"), ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		switch strings.ToLower(name) {
+		case "no-cache", "no-store":
+			return time.Time{}
+		case "max-age":
+			seconds, err := strconv.ParseInt(strings.Trim(value, `"`), 10, 64)
+			if err != nil || seconds < 0 {
+				return time.Time{}
+			}
+			lifetime = time.Duration(seconds) * time.Second
+			hasMaxAge = true
+		}
+	}
+
+	if hasMaxAge {
+		if age, err := strconv.ParseInt(strings.TrimSpace(h.Get("Age")), 10, 64); err == nil && age > 0 {
+			lifetime -= time.Duration(age) * time.Second
+		}
+	} else if expiresHeader := h.Get("Expires"); expiresHeader != "" {
+		expires, err := http.ParseTime(expiresHeader)
+		if err != nil {
+			return time.Time{} // Invalid values such as "0" mean already expired
+		}
+		date, err := http.ParseTime(h.Get("Date"))
+		if err != nil {
+			date = fetchTime
+		}
+		lifetime = expires.Sub(date)
+	}
+
+	if lifetime <= 0 {
+		return time.Time{}
+	}
+	if lifetime > MaxCacheLifetime {
+		lifetime = MaxCacheLifetime
+	}
+	return fetchTime.Add(lifetime)
+}
+
+// MaxRetryAfterWait is the longest Retry-After FetchWithRetry waits out
+// before retrying. Servers asking for longer get no more attempts this run.
+const MaxRetryAfterWait = 5 * time.Minute
+
 // FetchWithRetry attempts to fetch with exponential backoff.
 // Respects Retry-After header on 429 (Too Many Requests) and 503 (Service Unavailable) responses.
+// When every attempt fails, the last response received (if any) is returned
+// with the error so callers can inspect its status and Retry-After. A retry
+// whose wait would pass ctx's deadline is not made, so that the caller gets
+// that response rather than the fetch being cut off mid-wait.
 func (c *Crawler) FetchWithRetry(ctx context.Context, feedURL string, cache FeedCache, maxRetries int) (*FeedResponse, error) {
 	var lastErr error
 	var lastResp *FeedResponse
@@ -448,10 +693,6 @@ func (c *Crawler) FetchWithRetry(ctx context.Context, feedURL string, cache Feed
 			// Prefer Retry-After header if present (for 429/503 responses)
 			if lastResp != nil && lastResp.RetryAfter > 0 {
 				backoff = lastResp.RetryAfter
-				// Cap at 5 minutes to prevent indefinite waiting
-				if backoff > 5*time.Minute {
-					backoff = 5 * time.Minute
-				}
 			} else {
 				// Exponential backoff: 1s, 2s, 4s, 8s...
 				backoff = time.Duration(1<<uint(attempt-1)) * time.Second
@@ -463,6 +704,10 @@ func (c *Crawler) FetchWithRetry(ctx context.Context, feedURL string, cache Feed
 				backoff += jitter
 			}
 
+			if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < backoff {
+				return lastResp, fmt.Errorf("no time left to retry after %s: %w", backoff.Round(time.Millisecond), lastErr)
+			}
+
 			select {
 			case <-time.After(backoff):
 			case <-ctx.Done():
@@ -482,7 +727,10 @@ func (c *Crawler) FetchWithRetry(ctx context.Context, feedURL string, cache Feed
 		if errors.Is(err, ErrInvalidURL) ||
 			errors.Is(err, ErrPrivateIP) ||
 			errors.Is(err, ErrInvalidScheme) ||
-			errors.Is(err, ErrMaxSizeExceeded) {
+			errors.Is(err, ErrMaxSizeExceeded) ||
+			errors.Is(err, ErrDisallowedByRobots) ||
+			errors.Is(err, ErrHostNotFound) ||
+			errors.Is(err, ErrOffline) {
 			return nil, err
 		}
 
@@ -490,7 +738,12 @@ func (c *Crawler) FetchWithRetry(ctx context.Context, feedURL string, cache Feed
 		if resp != nil && resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != 429 {
 			return resp, err
 		}
+
+		// Don't wait longer than MaxRetryAfterWait for a server that asked us to go away
+		if resp != nil && resp.RetryAfter > MaxRetryAfterWait {
+			return resp, err
+		}
 	}
 
-	return nil, fmt.Errorf("max retries exceeded: %w", lastErr)
+	return lastResp, fmt.Errorf("max retries exceeded: %w", lastErr)
 }
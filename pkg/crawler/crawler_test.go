@@ -3,6 +3,7 @@ package crawler
 import (
 	"context"
 	"errors"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -574,6 +575,58 @@ func TestFetchWithRetry_RespectsRetryAfter(t *testing.T) {
 	}
 }
 
+func TestFetchWithRetry_LongRetryAfterStopsRetrying(t *testing.T) {
+	t.Parallel()
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.Header().Set("Retry-After", "3600")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	resp, err := NewForTesting().FetchWithRetry(context.Background(), server.URL, FeedCache{}, 3)
+	if err == nil {
+		t.Fatal("Expected error for 429")
+	}
+	if attempts != 1 {
+		t.Errorf("attempts = %d, want 1 (Retry-After beyond MaxRetryAfterWait)", attempts)
+	}
+	if resp == nil || resp.StatusCode != http.StatusTooManyRequests || resp.RetryAfter != time.Hour {
+		t.Errorf("resp = %+v, want the 429 response with its Retry-After", resp)
+	}
+}
+
+func TestFetchWithRetry_RetryAfterPastDeadline(t *testing.T) {
+	t.Parallel()
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.Header().Set("Retry-After", "2")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	// The retry would land after the deadline, so the 503 is returned at
+	// once rather than the wait being cut off
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	start := time.Now()
+	resp, err := NewForTesting().FetchWithRetry(ctx, server.URL, FeedCache{}, 3)
+	if err == nil || errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("FetchWithRetry() error = %v, want the 503's", err)
+	}
+	if attempts != 1 {
+		t.Errorf("attempts = %d, want 1", attempts)
+	}
+	if resp == nil || resp.StatusCode != http.StatusServiceUnavailable || resp.RetryAfter != 2*time.Second {
+		t.Errorf("resp = %+v, want the 503 response with its Retry-After", resp)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("FetchWithRetry() took %v, want it to give up without waiting", elapsed)
+	}
+}
+
 func TestFetch_CapturesRetryAfter(t *testing.T) {
 	t.Parallel()
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -603,6 +656,43 @@ func TestFetch_CapturesRetryAfter(t *testing.T) {
 	}
 }
 
+func TestFetch_CapturesDiagnosticHeaders(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		w.Header().Set("Server", "nginx")
+		w.Header().Set("Cache-Control", "max-age=300")
+		w.Header().Set("CF-Ray", "8a1b2c3d4e5f-LHR")
+		w.Header().Set("X-RateLimit-Remaining", "42")
+		w.Header().Set("Set-Cookie", "session=secret")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("<rss></rss>"))
+	}))
+	defer server.Close()
+
+	crawler := NewForTesting()
+	resp, err := crawler.Fetch(context.Background(), server.URL, FeedCache{})
+	if err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+
+	want := map[string]string{
+		"Server":                "nginx",
+		"Cache-Control":         "max-age=300",
+		"Cf-Ray":                "8a1b2c3d4e5f-LHR",
+		"X-Ratelimit-Remaining": "42",
+	}
+	for name, value := range want {
+		if got := resp.Headers[name]; got != value {
+			t.Errorf("Headers[%q] = %q, want %q", name, got, value)
+		}
+	}
+
+	if _, ok := resp.Headers["Set-Cookie"]; ok {
+		t.Error("Set-Cookie should not be captured")
+	}
+}
+
 func TestFetch_Tracks301PermanentRedirect(t *testing.T) {
 	t.Parallel()
 	// Create a server that redirects with 301
@@ -1248,3 +1338,185 @@ func TestFetchWithRetry_JitterVariability(t *testing.T) {
 		}
 	}
 }
+
+func TestParseCacheExpiry(t *testing.T) {
+	t.Parallel()
+	fetchTime := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	httpDate := func(d time.Duration) string {
+		return fetchTime.Add(d).Format(http.TimeFormat)
+	}
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    time.Duration // Offset from fetchTime; 0 means no expiry
+	}{
+		{"no headers", nil, 0},
+		{"max-age", map[string]string{"Cache-Control": "public, max-age=3600"}, time.Hour},
+		{"max-age minus age", map[string]string{"Cache-Control": "max-age=3600", "Age": "600"}, 50 * time.Minute},
+		{"max-age beats expires", map[string]string{"Cache-Control": "max-age=60", "Expires": httpDate(time.Hour)}, time.Minute},
+		{"no-cache", map[string]string{"Cache-Control": "no-cache, max-age=3600"}, 0},
+		{"no-store", map[string]string{"Cache-Control": "no-store"}, 0},
+		{"max-age zero", map[string]string{"Cache-Control": "max-age=0"}, 0},
+		{"invalid max-age", map[string]string{"Cache-Control": "max-age=soon"}, 0},
+		{"expires", map[string]string{"Expires": httpDate(2 * time.Hour)}, 2 * time.Hour},
+		{"expires relative to date", map[string]string{"Date": httpDate(-time.Hour), "Expires": httpDate(0)}, time.Hour},
+		{"expires in past", map[string]string{"Expires": httpDate(-time.Hour)}, 0},
+		{"invalid expires", map[string]string{"Expires": "0"}, 0},
+		{"capped", map[string]string{"Cache-Control": "max-age=31536000"}, MaxCacheLifetime},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			h := http.Header{}
+			for k, v := range tt.headers {
+				h.Set(k, v)
+			}
+
+			got := parseCacheExpiry(h, fetchTime)
+			if tt.want == 0 {
+				if !got.IsZero() {
+					t.Errorf("parseCacheExpiry() = %v, want zero", got)
+				}
+				return
+			}
+			if want := fetchTime.Add(tt.want); !got.Equal(want) {
+				t.Errorf("parseCacheExpiry() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestFetch_CacheExpiry(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=1800")
+		if r.Header.Get("If-None-Match") != "" {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte("<rss></rss>"))
+	}))
+	defer server.Close()
+
+	crawler := NewForTesting()
+	for _, cache := range []FeedCache{{}, {ETag: `"v1"`}} {
+		resp, err := crawler.Fetch(context.Background(), server.URL, cache)
+		if err != nil {
+			t.Fatalf("Fetch() error = %v", err)
+		}
+		want := resp.FetchTime.Add(30 * time.Minute)
+		if !resp.NewCache.Expires.Equal(want) {
+			t.Errorf("NewCache.Expires = %v, want %v (not modified: %v)", resp.NewCache.Expires, want, resp.NotModified)
+		}
+	}
+}
+
+// roundTripFunc adapts a function to http.RoundTripper
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+func TestFetchWithRetry_PermanentFailures(t *testing.T) {
+	t.Parallel()
+
+	t.Run("410 Gone", func(t *testing.T) {
+		t.Parallel()
+		attempts := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			attempts++
+			w.WriteHeader(http.StatusGone)
+		}))
+		defer server.Close()
+
+		resp, err := NewForTesting().FetchWithRetry(context.Background(), server.URL, FeedCache{}, 3)
+		if !errors.Is(err, ErrGone) {
+			t.Errorf("error = %v, want ErrGone", err)
+		}
+		if resp == nil || resp.StatusCode != http.StatusGone {
+			t.Errorf("resp = %+v, want the 410 response", resp)
+		}
+		if attempts != 1 {
+			t.Errorf("attempts = %d, want 1", attempts)
+		}
+	})
+
+	t.Run("NXDOMAIN", func(t *testing.T) {
+		t.Parallel()
+		attempts := 0
+		c := NewForTesting()
+		c.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			attempts++
+			return nil, &net.DNSError{Err: "no such host", Name: r.URL.Hostname(), IsNotFound: true}
+		})}
+
+		_, err := c.FetchWithRetry(context.Background(), "https://vanished.example/feed", FeedCache{}, 3)
+		if !errors.Is(err, ErrHostNotFound) {
+			t.Errorf("error = %v, want ErrHostNotFound", err)
+		}
+		if attempts != 1 {
+			t.Errorf("attempts = %d, want 1", attempts)
+		}
+	})
+}
+
+func TestFetch_Credentials(t *testing.T) {
+	t.Parallel()
+	type seen struct{ auth, key string }
+	requests := make(chan seen, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests <- seen{r.Header.Get("Authorization"), r.Header.Get("X-Api-Key")}
+		_, _ = w.Write([]byte("<rss/>"))
+	}))
+	defer server.Close()
+
+	c := NewForTesting().WithCredentials(map[string]Credentials{
+		server.URL + "/basic":  {Username: "reader", Password: "s3cret", Headers: map[string]string{"X-Api-Key": "abc"}},
+		server.URL + "/bearer": {Token: "tok-456"},
+	})
+
+	tests := []struct {
+		path     string
+		wantAuth string
+		wantKey  string
+	}{
+		{"/basic", "Basic cmVhZGVyOnMzY3JldA==", "abc"},
+		{"/bearer", "Bearer tok-456", ""},
+		{"/other", "", ""},
+	}
+	for _, tt := range tests {
+		if _, err := c.Fetch(context.Background(), server.URL+tt.path, FeedCache{}); err != nil {
+			t.Fatalf("Fetch(%s) error: %v", tt.path, err)
+		}
+		got := <-requests
+		if got.auth != tt.wantAuth || got.key != tt.wantKey {
+			t.Errorf("%s: Authorization = %q, X-Api-Key = %q; want %q, %q", tt.path, got.auth, got.key, tt.wantAuth, tt.wantKey)
+		}
+	}
+}
+
+func TestFetch_CredentialsDroppedOnCrossHostRedirect(t *testing.T) {
+	t.Parallel()
+	var gotAuth, gotKey string
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth, gotKey = r.Header.Get("Authorization"), r.Header.Get("X-Api-Key")
+		_, _ = w.Write([]byte("<rss/>"))
+	}))
+	defer other.Close()
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, other.URL+"/feed", http.StatusFound)
+	}))
+	defer origin.Close()
+
+	c := NewForTesting().WithCredentials(map[string]Credentials{
+		origin.URL + "/feed": {Token: "tok", Headers: map[string]string{"X-Api-Key": "abc"}},
+	})
+	if _, err := c.Fetch(context.Background(), origin.URL+"/feed", FeedCache{}); err != nil {
+		t.Fatalf("Fetch error: %v", err)
+	}
+	if gotAuth != "" || gotKey != "" {
+		t.Errorf("credentials leaked to another host: Authorization = %q, X-Api-Key = %q", gotAuth, gotKey)
+	}
+}
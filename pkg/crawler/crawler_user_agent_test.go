@@ -47,7 +47,7 @@ func TestNewWithUserAgent(t *testing.T) {
 
 			// Create crawler with custom user agent
 			c := NewWithUserAgent(tt.userAgent)
-			c.skipSSRFCheck = true // Allow localhost for testing
+			c.allowLocal() // Allow localhost for testing
 
 			// Make request
 			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
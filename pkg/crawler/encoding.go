@@ -0,0 +1,63 @@
+package crawler
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/andybalholm/brotli"
+)
+
+// AcceptEncoding lists the content codings Fetch can decode, best first
+const AcceptEncoding = "br, gzip, deflate"
+
+// ErrUnsupportedEncoding is returned for a body in a content coding Fetch
+// did not ask for and cannot decode
+var ErrUnsupportedEncoding = errors.New("unsupported content encoding")
+
+// decodeBody wraps a response body in a decoder for its Content-Encoding,
+// given in lower case
+func decodeBody(body io.Reader, encoding string) (io.ReadCloser, error) {
+	switch encoding {
+	case "", "identity":
+		return io.NopCloser(body), nil
+	case "gzip", "x-gzip":
+		r, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, fmt.Errorf("create gzip reader: %w", err)
+		}
+		return r, nil
+	case "br":
+		return io.NopCloser(brotli.NewReader(body)), nil
+	case "deflate":
+		// RFC 9110 deflate is zlib-wrapped, but some servers send a raw
+		// deflate stream; a zlib header always has a checksum divisible by 31
+		buf := bufio.NewReader(body)
+		if header, err := buf.Peek(2); err == nil && header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+			r, err := zlib.NewReader(buf)
+			if err != nil {
+				return nil, fmt.Errorf("create deflate reader: %w", err)
+			}
+			return r, nil
+		}
+		return flate.NewReader(buf), nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedEncoding, encoding)
+	}
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
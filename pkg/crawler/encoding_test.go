@@ -0,0 +1,132 @@
+package crawler
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+)
+
+func compress(t *testing.T, encoding string, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch encoding {
+	case "br":
+		w = brotli.NewWriter(&buf)
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "deflate":
+		w = zlib.NewWriter(&buf)
+	case "raw-deflate":
+		w, _ = flate.NewWriter(&buf, flate.DefaultCompression)
+	default:
+		return data
+	}
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestFetch_ContentEncodings(t *testing.T) {
+	t.Parallel()
+	feed := []byte("<rss><channel><title>" + strings.Repeat("Compressible feed text. ", 200) + "</title></channel></rss>")
+
+	tests := []struct {
+		name   string
+		header string // Content-Encoding sent
+		format string // How the body is compressed
+	}{
+		{"brotli", "br", "br"},
+		{"gzip", "gzip", "gzip"},
+		{"deflate", "deflate", "deflate"},
+		{"raw deflate", "deflate", "raw-deflate"},
+		{"uppercase gzip", "GZIP", "gzip"},
+		{"identity", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			body := compress(t, tt.format, feed)
+			var acceptEncoding string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				acceptEncoding = r.Header.Get("Accept-Encoding")
+				if tt.header != "" {
+					w.Header().Set("Content-Encoding", tt.header)
+				}
+				_, _ = w.Write(body)
+			}))
+			defer server.Close()
+
+			resp, err := NewForTesting().Fetch(context.Background(), server.URL, FeedCache{})
+			if err != nil {
+				t.Fatalf("Fetch error: %v", err)
+			}
+			if acceptEncoding != AcceptEncoding {
+				t.Errorf("Accept-Encoding = %q, want %q", acceptEncoding, AcceptEncoding)
+			}
+			if !bytes.Equal(resp.Body, feed) {
+				t.Errorf("Body was not decoded (got %d bytes, want %d)", len(resp.Body), len(feed))
+			}
+			if resp.ContentEncoding != strings.ToLower(tt.header) {
+				t.Errorf("ContentEncoding = %q, want %q", resp.ContentEncoding, strings.ToLower(tt.header))
+			}
+			if resp.WireBytes != int64(len(body)) || resp.DecodedBytes != int64(len(feed)) {
+				t.Errorf("WireBytes/DecodedBytes = %d/%d, want %d/%d", resp.WireBytes, resp.DecodedBytes, len(body), len(feed))
+			}
+			if resp.Proto != "HTTP/1.1" {
+				t.Errorf("Proto = %q, want HTTP/1.1", resp.Proto)
+			}
+		})
+	}
+}
+
+func TestFetch_UnsupportedEncoding(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "zstd")
+		_, _ = w.Write([]byte("not really zstd"))
+	}))
+	defer server.Close()
+
+	_, err := NewForTesting().Fetch(context.Background(), server.URL, FeedCache{})
+	if !errors.Is(err, ErrUnsupportedEncoding) {
+		t.Errorf("Fetch error = %v, want ErrUnsupportedEncoding", err)
+	}
+}
+
+func TestFetch_HTTP2(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("<rss/>"))
+	}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+
+	c := NewForTesting()
+	// Trust the test server's certificate, keeping the crawler's own transport
+	transport := c.client.Transport.(*http.Transport)
+	transport.TLSClientConfig = server.Client().Transport.(*http.Transport).TLSClientConfig
+
+	resp, err := c.Fetch(context.Background(), server.URL, FeedCache{})
+	if err != nil {
+		t.Fatalf("Fetch error: %v", err)
+	}
+	if resp.Proto != "HTTP/2.0" {
+		t.Errorf("Proto = %q, want HTTP/2.0", resp.Proto)
+	}
+}
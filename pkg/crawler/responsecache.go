@@ -0,0 +1,275 @@
+package crawler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/adewale/rogue_planet/internal/atomicfile"
+)
+
+// ErrOffline is returned by an offline crawler for a URL with no saved response
+var ErrOffline = errors.New("offline and no saved response")
+
+// ResponseCache keeps the last successful response for each URL on disk,
+// so it can be replayed without the network. Each response is two files
+// named by a hash of the URL: the body as received (after decompression)
+// in .body, and its metadata in .json.
+type ResponseCache struct {
+	dir      string
+	maxAge   time.Duration
+	fixtures bool // Files named for people, as by NewFixtures
+}
+
+// NewResponseCache returns a cache in dir. A saved response younger than
+// maxAge is used instead of fetching the URL again; with a maxAge of zero
+// responses are only replayed by an offline crawler.
+func NewResponseCache(dir string, maxAge time.Duration) *ResponseCache {
+	return &ResponseCache{dir: dir, maxAge: maxAge}
+}
+
+// NewFixtures returns a response cache in dir laid out as test fixtures,
+// with each response's files named after its URL (see FixtureName) so they
+// can be read, edited, and attached to bug reports: the body gets the
+// extension of its content type if the name lacks it, as in
+// example.com-feed.xml, and the metadata is in example.com-feed.xml.meta.json.
+// Saved responses never expire; an offline crawler replays them.
+func NewFixtures(dir string) *ResponseCache {
+	return &ResponseCache{dir: dir, fixtures: true}
+}
+
+// unsafeNameChars are the characters fixture names leave out
+var unsafeNameChars = regexp.MustCompile(`[^A-Za-z0-9._]+`)
+
+// FixtureName returns the base name of a URL's fixture files: its host and
+// path with other characters turned into hyphens, e.g. "example.com-feed.xml"
+// for https://example.com/feed.xml. A URL with a query, or a name that would
+// be too long, ends in a hash of the URL so that names stay distinct.
+func FixtureName(rawURL string) string {
+	name, hashed := rawURL, true
+	if u, err := url.Parse(rawURL); err == nil && u.Host != "" {
+		name, hashed = u.Host+u.Path, u.RawQuery != ""
+	}
+	name = strings.Trim(unsafeNameChars.ReplaceAllString(name, "-"), "-.")
+	if len(name) > 80 {
+		name, hashed = name[:80], true
+	}
+	if hashed {
+		sum := sha256.Sum256([]byte(rawURL))
+		name += "-" + hex.EncodeToString(sum[:4])
+	}
+	return name
+}
+
+// fixtureExtension returns the file extension for a body of contentType
+func fixtureExtension(contentType string) string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case strings.HasSuffix(mediaType, "json"):
+		return ".json"
+	case mediaType == "text/html":
+		return ".html"
+	case mediaType == "text/plain":
+		return ".txt"
+	default:
+		return ".xml"
+	}
+}
+
+// savedResponse is the metadata of a saved response
+type savedResponse struct {
+	URL               string            `json:"url"`
+	Body              string            `json:"body,omitempty"` // Name of the body's file in a fixtures directory
+	FinalURL          string            `json:"final_url"`
+	PermanentRedirect bool              `json:"permanent_redirect,omitempty"`
+	FetchTime         time.Time         `json:"fetch_time"`
+	ETag              string            `json:"etag,omitempty"`
+	LastModified      string            `json:"last_modified,omitempty"`
+	Expires           time.Time         `json:"expires,omitzero"`
+	Proto             string            `json:"proto,omitempty"`
+	ContentEncoding   string            `json:"content_encoding,omitempty"`
+	Headers           map[string]string `json:"headers,omitempty"`
+}
+
+// paths returns the metadata and body files for url. A fixture's body file
+// is named in its metadata instead.
+func (rc *ResponseCache) paths(url string) (meta, body string) {
+	if rc.fixtures {
+		return filepath.Join(rc.dir, FixtureName(url)+".meta.json"), ""
+	}
+	sum := sha256.Sum256([]byte(url))
+	base := filepath.Join(rc.dir, hex.EncodeToString(sum[:16]))
+	return base + ".json", base + ".body"
+}
+
+// Load returns the saved response for url, or nil if there is none
+func (rc *ResponseCache) Load(url string) (*FeedResponse, error) {
+	metaPath, bodyPath := rc.paths(url)
+	saved, err := rc.loadMeta(metaPath)
+	if saved == nil || err != nil {
+		return nil, err
+	}
+	if saved.URL != url {
+		return nil, nil // Another URL with the same name
+	}
+	if rc.fixtures {
+		bodyPath = filepath.Join(rc.dir, filepath.Base(saved.Body))
+	}
+	body, err := os.ReadFile(bodyPath)
+	if err != nil {
+		return nil, fmt.Errorf("read saved response: %w", err)
+	}
+
+	return &FeedResponse{
+		Body:       body,
+		StatusCode: 200,
+		NewCache: FeedCache{
+			URL:          saved.FinalURL,
+			ETag:         saved.ETag,
+			LastModified: saved.LastModified,
+			LastFetched:  saved.FetchTime,
+			Expires:      saved.Expires,
+		},
+		FinalURL:          saved.FinalURL,
+		PermanentRedirect: saved.PermanentRedirect,
+		FetchTime:         saved.FetchTime,
+		Headers:           saved.Headers,
+		Proto:             saved.Proto,
+		ContentEncoding:   saved.ContentEncoding,
+		DecodedBytes:      int64(len(body)),
+		Replayed:          true,
+	}, nil
+}
+
+// loadMeta reads a response's metadata, returning nil if it is not saved
+func (rc *ResponseCache) loadMeta(metaPath string) (*savedResponse, error) {
+	data, err := os.ReadFile(metaPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read saved response: %w", err)
+	}
+	var saved savedResponse
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, fmt.Errorf("decode saved response: %w", err)
+	}
+	return &saved, nil
+}
+
+// fresh returns the saved response for url if it is younger than the
+// cache's max age, or nil
+func (rc *ResponseCache) fresh(url string, now time.Time) *FeedResponse {
+	if rc == nil || rc.maxAge <= 0 {
+		return nil
+	}
+	// A response that can't be read is fetched again
+	resp, err := rc.Load(url)
+	if err != nil || resp == nil || now.Sub(resp.FetchTime) >= rc.maxAge {
+		return nil
+	}
+	return resp
+}
+
+// Save stores a successful response for url, replacing any saved before
+func (rc *ResponseCache) Save(url string, resp *FeedResponse) error {
+	if err := os.MkdirAll(rc.dir, 0755); err != nil {
+		return fmt.Errorf("create response cache: %w", err)
+	}
+	metaPath, bodyPath := rc.paths(url)
+	var bodyName string
+	if rc.fixtures {
+		bodyName = FixtureName(url)
+		if ext := fixtureExtension(resp.Headers["Content-Type"]); !strings.HasSuffix(bodyName, ext) {
+			bodyName += ext
+		}
+		bodyPath = filepath.Join(rc.dir, bodyName)
+	}
+	if err := saveFile(bodyPath, resp.Body); err != nil {
+		return err
+	}
+	return rc.saveMeta(metaPath, savedResponse{
+		URL:               url,
+		Body:              bodyName,
+		FinalURL:          resp.FinalURL,
+		PermanentRedirect: resp.PermanentRedirect,
+		FetchTime:         resp.FetchTime,
+		ETag:              resp.NewCache.ETag,
+		LastModified:      resp.NewCache.LastModified,
+		Expires:           resp.NewCache.Expires,
+		Proto:             resp.Proto,
+		ContentEncoding:   resp.ContentEncoding,
+		Headers:           resp.Headers,
+	})
+}
+
+// revalidated records that the saved response for url was confirmed
+// unchanged (HTTP 304) at fetchTime, restarting its max age
+func (rc *ResponseCache) revalidated(url string, fetchTime time.Time) error {
+	metaPath, _ := rc.paths(url)
+	saved, err := rc.loadMeta(metaPath)
+	if saved == nil || err != nil {
+		return err
+	}
+	saved.FetchTime = fetchTime
+	return rc.saveMeta(metaPath, *saved)
+}
+
+func (rc *ResponseCache) saveMeta(metaPath string, saved savedResponse) error {
+	data, err := json.MarshalIndent(saved, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode saved response: %w", err)
+	}
+	return saveFile(metaPath, append(data, '\n'))
+}
+
+// saveFile replaces path with data, so a concurrent reader sees the old or
+// the new file, never part of one
+func saveFile(path string, data []byte) error {
+	if err := atomicfile.Write(path, data, 0600); err != nil {
+		return fmt.Errorf("save response: %w", err)
+	}
+	return nil
+}
+
+// WithResponseCache returns a copy of the crawler that saves each
+// successful response in rc, and answers from it while a saved response is
+// fresh. The copy shares the original's connection pool.
+func (c *Crawler) WithResponseCache(rc *ResponseCache) *Crawler {
+	copied := *c
+	copied.responses = rc
+	return &copied
+}
+
+// Offline returns a copy of the crawler that never uses the network. It
+// replays the responses saved in its response cache, ignoring their age,
+// and fails with ErrOffline for any other URL.
+func (c *Crawler) Offline() *Crawler {
+	copied := *c
+	copied.offline = true
+	return &copied
+}
+
+// replay answers a request from the response cache for an offline crawler
+func (c *Crawler) replay(feedURL string) (*FeedResponse, error) {
+	if c.responses == nil {
+		return nil, fmt.Errorf("%w: %s", ErrOffline, feedURL)
+	}
+	resp, err := c.responses.Load(feedURL)
+	if err != nil {
+		return nil, err
+	}
+	if resp == nil {
+		return nil, fmt.Errorf("%w: %s", ErrOffline, feedURL)
+	}
+	return resp, nil
+}
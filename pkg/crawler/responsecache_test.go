@@ -0,0 +1,225 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestResponseCache_SaveLoad(t *testing.T) {
+	t.Parallel()
+	rc := NewResponseCache(t.TempDir(), 0)
+
+	if resp, err := rc.Load("https://example.com/feed"); resp != nil || err != nil {
+		t.Fatalf("Load() of unsaved URL = %v, %v, want nil, nil", resp, err)
+	}
+
+	fetched := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	saved := &FeedResponse{
+		Body:      []byte("<rss/>"),
+		FinalURL:  "https://example.com/feed.xml",
+		NewCache:  FeedCache{ETag: `"abc"`, LastModified: "Fri, 02 Jan 2026 03:04:05 GMT"},
+		FetchTime: fetched,
+		Headers:   map[string]string{"Content-Type": "application/rss+xml"},
+	}
+	if err := rc.Save("https://example.com/feed", saved); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	resp, err := rc.Load("https://example.com/feed")
+	if err != nil || resp == nil {
+		t.Fatalf("Load() = %v, %v", resp, err)
+	}
+	if string(resp.Body) != "<rss/>" || resp.FinalURL != saved.FinalURL || !resp.FetchTime.Equal(fetched) {
+		t.Errorf("Load() = %+v, want the saved response", resp)
+	}
+	if resp.NewCache.ETag != `"abc"` || resp.Headers["Content-Type"] != "application/rss+xml" {
+		t.Errorf("Load() lost the response's headers: %+v", resp)
+	}
+	if !resp.Replayed || resp.StatusCode != 200 {
+		t.Errorf("Load() = status %d, replayed %v, want a replayed 200", resp.StatusCode, resp.Replayed)
+	}
+}
+
+func TestFetch_ResponseCacheMaxAge(t *testing.T) {
+	t.Parallel()
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Write([]byte("<rss/>"))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	c := NewForTesting().WithResponseCache(NewResponseCache(dir, time.Hour))
+	for i := 0; i < 2; i++ {
+		resp, err := c.Fetch(context.Background(), server.URL, FeedCache{})
+		if err != nil {
+			t.Fatalf("Fetch() error = %v", err)
+		}
+		if string(resp.Body) != "<rss/>" {
+			t.Errorf("Fetch() body = %q", resp.Body)
+		}
+		if resp.Replayed != (i == 1) {
+			t.Errorf("fetch %d: Replayed = %v", i, resp.Replayed)
+		}
+	}
+	if got := requests.Load(); got != 1 {
+		t.Errorf("server saw %d requests, want 1 while the saved response is fresh", got)
+	}
+
+	// Without a max age every fetch goes to the network
+	c = NewForTesting().WithResponseCache(NewResponseCache(dir, 0))
+	if _, err := c.Fetch(context.Background(), server.URL, FeedCache{}); err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	if got := requests.Load(); got != 2 {
+		t.Errorf("server saw %d requests, want 2", got)
+	}
+}
+
+func TestFetch_Offline(t *testing.T) {
+	t.Parallel()
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte("<feed/>"))
+	}))
+	defer server.Close()
+
+	rc := NewResponseCache(t.TempDir(), 0)
+	online := NewForTesting().WithResponseCache(rc)
+	if _, err := online.Fetch(context.Background(), server.URL, FeedCache{}); err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+
+	// An offline crawler replays the response, however old, even when the
+	// caller's cache would make it conditional
+	offline := online.Offline()
+	resp, err := offline.Fetch(context.Background(), server.URL, FeedCache{ETag: `"v1"`})
+	if err != nil {
+		t.Fatalf("offline Fetch() error = %v", err)
+	}
+	if string(resp.Body) != "<feed/>" || !resp.Replayed {
+		t.Errorf("offline Fetch() = %q, replayed %v, want the saved body", resp.Body, resp.Replayed)
+	}
+	if got := requests.Load(); got != 1 {
+		t.Errorf("server saw %d requests, want none offline", got)
+	}
+
+	if _, err := offline.Fetch(context.Background(), server.URL+"/other", FeedCache{}); !errors.Is(err, ErrOffline) {
+		t.Errorf("offline Fetch() of an unsaved URL error = %v, want ErrOffline", err)
+	}
+	if _, err := NewForTesting().Offline().Fetch(context.Background(), server.URL, FeedCache{}); !errors.Is(err, ErrOffline) {
+		t.Errorf("offline Fetch() without a cache error = %v, want ErrOffline", err)
+	}
+}
+
+func TestFetch_ResponseCacheRevalidated(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte("<feed/>"))
+	}))
+	defer server.Close()
+
+	rc := NewResponseCache(t.TempDir(), 0)
+	c := NewForTesting().WithResponseCache(rc)
+	first, err := c.Fetch(context.Background(), server.URL, FeedCache{})
+	if err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	resp, err := c.Fetch(context.Background(), server.URL, first.NewCache)
+	if err != nil {
+		t.Fatalf("conditional Fetch() error = %v", err)
+	}
+	if resp.StatusCode != http.StatusNotModified {
+		t.Fatalf("conditional Fetch() status = %d, want 304", resp.StatusCode)
+	}
+
+	// The saved body is kept, and its age restarts
+	saved, err := rc.Load(server.URL)
+	if err != nil || saved == nil {
+		t.Fatalf("Load() = %v, %v", saved, err)
+	}
+	if string(saved.Body) != "<feed/>" {
+		t.Errorf("saved body = %q, want it kept after a 304", saved.Body)
+	}
+	if !saved.FetchTime.After(first.FetchTime) {
+		t.Errorf("saved fetch time = %v, want it after %v", saved.FetchTime, first.FetchTime)
+	}
+}
+
+func TestFixtureName(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"https://example.com/feed.xml", "example.com-feed.xml"},
+		{"https://example.com/blog/feed/", "example.com-blog-feed"},
+		{"http://localhost:8080/atom", "localhost-8080-atom"},
+		{"https://example.com/feed?format=rss", "example.com-feed-1c02ac2f"},
+	}
+	for _, tt := range tests {
+		if got := FixtureName(tt.url); got != tt.want {
+			t.Errorf("FixtureName(%q) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+	long := FixtureName("https://example.com/" + strings.Repeat("a", 200))
+	if len(long) != 89 {
+		t.Errorf("FixtureName() of a long URL = %q, want 80 characters and a hash", long)
+	}
+}
+
+func TestFixtures(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/atom+xml")
+		w.Write([]byte("<feed/>"))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	recorder := NewForTesting().WithResponseCache(NewFixtures(dir))
+	if _, err := recorder.Fetch(context.Background(), server.URL+"/atom", FeedCache{}); err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+
+	name := FixtureName(server.URL + "/atom")
+	body, err := os.ReadFile(filepath.Join(dir, name+".xml"))
+	if err != nil || string(body) != "<feed/>" {
+		t.Fatalf("fixture body = %q, %v, want the response", body, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, name+".meta.json")); err != nil {
+		t.Fatalf("fixture metadata: %v", err)
+	}
+
+	// An edited fixture is replayed as edited, however old
+	if err := os.WriteFile(filepath.Join(dir, name+".xml"), []byte("<feed>edited</feed>"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	server.Close()
+	resp, err := NewForTesting().WithResponseCache(NewFixtures(dir)).Offline().Fetch(context.Background(), server.URL+"/atom", FeedCache{})
+	if err != nil {
+		t.Fatalf("replayed Fetch() error = %v", err)
+	}
+	if string(resp.Body) != "<feed>edited</feed>" || !resp.Replayed {
+		t.Errorf("replayed Fetch() = %q, replayed %v, want the fixture", resp.Body, resp.Replayed)
+	}
+}
@@ -0,0 +1,301 @@
+package crawler
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// MaxRobotsSize limits robots.txt files to 500KB, as RFC 9309 allows
+	MaxRobotsSize = 500 * 1024
+	// RobotsCacheLifetime is how long a host's robots.txt rules are reused
+	RobotsCacheLifetime = 24 * time.Hour
+	// robotsErrorLifetime is how long an unreachable robots.txt disallows a host
+	// before it is tried again
+	robotsErrorLifetime = time.Hour
+)
+
+// ErrDisallowedByRobots is returned for URLs a site's robots.txt asks us not to fetch
+var ErrDisallowedByRobots = errors.New("disallowed by robots.txt")
+
+// RobotsMode says what Fetch does about robots.txt
+type RobotsMode int
+
+const (
+	// RobotsIgnore never consults robots.txt
+	RobotsIgnore RobotsMode = iota
+	// RobotsWarn fetches disallowed URLs anyway and sets
+	// FeedResponse.RobotsDisallowed so the caller can warn about them
+	RobotsWarn
+	// RobotsObey refuses disallowed URLs with ErrDisallowedByRobots
+	RobotsObey
+)
+
+// Names for RobotsMode in configuration files
+const (
+	RobotsIgnoreName = "ignore"
+	RobotsWarnName   = "warn"
+	RobotsObeyName   = "obey"
+)
+
+// RobotsModeByName returns the mode for a config name. An empty name
+// selects RobotsObey.
+func RobotsModeByName(name string) (RobotsMode, error) {
+	switch name {
+	case "", RobotsObeyName:
+		return RobotsObey, nil
+	case RobotsWarnName:
+		return RobotsWarn, nil
+	case RobotsIgnoreName:
+		return RobotsIgnore, nil
+	default:
+		return RobotsIgnore, fmt.Errorf("unknown robots.txt mode: %s (must be '%s', '%s', or '%s')", name, RobotsObeyName, RobotsWarnName, RobotsIgnoreName)
+	}
+}
+
+// WithRobots returns a copy of the crawler that treats robots.txt according
+// to mode. The copy shares the original's connection pool and robots.txt cache.
+func (c *Crawler) WithRobots(mode RobotsMode) *Crawler {
+	copied := *c
+	copied.robotsMode = mode
+	return &copied
+}
+
+// checkRobots applies the crawler's RobotsMode to a URL about to be fetched.
+// It reports whether the URL is disallowed but being fetched anyway.
+func (c *Crawler) checkRobots(ctx context.Context, rawURL string) (disallowed bool, err error) {
+	if c.robotsMode == RobotsIgnore {
+		return false, nil
+	}
+	allowed, err := c.Allowed(ctx, rawURL)
+	if err != nil {
+		return false, err
+	}
+	if allowed {
+		return false, nil
+	}
+	if c.robotsMode == RobotsObey {
+		return false, fmt.Errorf("%w: %s", ErrDisallowedByRobots, rawURL)
+	}
+	return true, nil
+}
+
+// robotsCache holds parsed robots.txt rules per scheme and host
+type robotsCache struct {
+	mu    sync.Mutex
+	hosts map[string]*robotsEntry
+}
+
+type robotsEntry struct {
+	rules   robotsRules
+	expires time.Time
+	ready   chan struct{} // Closed once rules are loaded
+}
+
+func newRobotsCache() *robotsCache {
+	return &robotsCache{hosts: make(map[string]*robotsEntry)}
+}
+
+// Allowed reports whether the site's robots.txt lets this crawler's user
+// agent fetch rawURL. robots.txt is fetched once per host and cached for
+// RobotsCacheLifetime. Following RFC 9309, a missing robots.txt (any 4xx)
+// allows everything and one that cannot be fetched (5xx or network error)
+// disallows everything until it is retried.
+func (c *Crawler) Allowed(ctx context.Context, rawURL string) (bool, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return false, ErrInvalidURL
+	}
+
+	rules, err := c.robotsRules(ctx, u.Scheme+"://"+u.Host)
+	if err != nil {
+		return false, err
+	}
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+	return rules.allowed(path), nil
+}
+
+// robotsRules returns the cached rules for a site, fetching them if needed.
+// Concurrent callers for the same site share one fetch.
+func (c *Crawler) robotsRules(ctx context.Context, site string) (robotsRules, error) {
+	c.robots.mu.Lock()
+	e, ok := c.robots.hosts[site]
+	if ok && !e.expires.IsZero() && time.Now().After(e.expires) {
+		ok = false
+	}
+	if !ok {
+		e = &robotsEntry{ready: make(chan struct{})}
+		c.robots.hosts[site] = e
+		c.robots.mu.Unlock()
+
+		rules, lifetime := c.fetchRobots(ctx, site)
+		if ctx.Err() != nil {
+			// Cancelled: forget the entry so a later call tries again
+			c.robots.mu.Lock()
+			delete(c.robots.hosts, site)
+			c.robots.mu.Unlock()
+			close(e.ready)
+			return robotsRules{}, ctx.Err()
+		}
+		e.rules = rules
+		e.expires = time.Now().Add(lifetime)
+		close(e.ready)
+		return rules, nil
+	}
+	c.robots.mu.Unlock()
+
+	select {
+	case <-e.ready:
+		return e.rules, nil
+	case <-ctx.Done():
+		return robotsRules{}, ctx.Err()
+	}
+}
+
+// fetchRobots downloads and parses a site's robots.txt, returning the rules
+// and how long to keep them
+func (c *Crawler) fetchRobots(ctx context.Context, site string) (robotsRules, time.Duration) {
+	resp, err := c.WithMaxSize(MaxRobotsSize).WithRobots(RobotsIgnore).Fetch(ctx, site+"/robots.txt", FeedCache{})
+	switch {
+	case err == nil:
+		return parseRobots(resp.Body, c.userAgent), RobotsCacheLifetime
+	case errors.Is(err, ErrMaxSizeExceeded):
+		// Too large to parse reliably; RFC 9309 allows ignoring the excess,
+		// but without the body we cannot, so treat it as unrestricted
+		return robotsRules{}, RobotsCacheLifetime
+	case resp != nil && resp.StatusCode >= 400 && resp.StatusCode < 500:
+		return robotsRules{}, RobotsCacheLifetime
+	default:
+		return robotsRules{disallowAll: true}, robotsErrorLifetime
+	}
+}
+
+// robotsRules are the Allow and Disallow lines that apply to our user agent
+type robotsRules struct {
+	rules       []robotsRule
+	disallowAll bool // robots.txt could not be fetched
+}
+
+type robotsRule struct {
+	pattern string
+	allow   bool
+}
+
+// allowed applies the most specific (longest) matching rule; on a tie Allow
+// wins, and a path no rule matches is allowed
+func (r robotsRules) allowed(path string) bool {
+	if r.disallowAll {
+		return false
+	}
+	best, allow := -1, true
+	for _, rule := range r.rules {
+		if !robotsMatch(rule.pattern, path) {
+			continue
+		}
+		if n := len(rule.pattern); n > best || (n == best && rule.allow) {
+			best, allow = n, rule.allow
+		}
+	}
+	return allow
+}
+
+// robotsMatch matches a path against a robots.txt pattern, where * matches
+// any sequence of characters and a trailing $ anchors the end of the path
+func robotsMatch(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	pattern = strings.TrimSuffix(pattern, "$")
+
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	rest := path[len(parts[0]):]
+	for i, part := range parts[1:] {
+		last := i == len(parts)-2
+		if last && anchored {
+			return strings.HasSuffix(rest, part)
+		}
+		idx := strings.Index(rest, part)
+		if idx < 0 {
+			return false
+		}
+		rest = rest[idx+len(part):]
+	}
+	return !anchored || rest == ""
+}
+
+// parseRobots extracts the rules for userAgent from a robots.txt file. The
+// group naming our product token (the part of the User-Agent before "/") is
+// used if there is one, otherwise the "*" group.
+func parseRobots(data []byte, userAgent string) robotsRules {
+	token := strings.ToLower(userAgent)
+	if i := strings.IndexAny(token, "/ "); i >= 0 {
+		token = token[:i]
+	}
+
+	var (
+		specific, wildcard []robotsRule
+		namedUs            bool // Some group names our agent, even if it has no rules
+		groupAgents        []string
+		inRules            bool // The current group's user-agent lines have ended
+	)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			if inRules {
+				groupAgents = nil
+				inRules = false
+			}
+			agent := strings.ToLower(value)
+			groupAgents = append(groupAgents, agent)
+			if token != "" && agent == token {
+				namedUs = true
+			}
+		case "allow", "disallow":
+			inRules = true
+			if value == "" {
+				// "Disallow:" with no path allows everything
+				continue
+			}
+			rule := robotsRule{pattern: value, allow: key == "allow"}
+			for _, agent := range groupAgents {
+				switch {
+				case agent == "*":
+					wildcard = append(wildcard, rule)
+				case token != "" && agent == token:
+					specific = append(specific, rule)
+				}
+			}
+		}
+	}
+
+	if namedUs {
+		return robotsRules{rules: specific}
+	}
+	return robotsRules{rules: wildcard}
+}
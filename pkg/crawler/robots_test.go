@@ -0,0 +1,173 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestParseRobots(t *testing.T) {
+	t.Parallel()
+
+	const robots = `# Example
+User-agent: *
+Disallow: /private/
+Allow: /private/public-page
+Disallow: /*.pdf$
+
+User-agent: BadBot
+User-agent: OtherBot
+Disallow: /
+
+User-agent: RoguePlanet
+Disallow: /no-planets/
+`
+	tests := []struct {
+		name      string
+		userAgent string
+		path      string
+		want      bool
+	}{
+		{"wildcard group allows", "SomeBot/1.0", "/blog/post", true},
+		{"wildcard group disallows", "SomeBot/1.0", "/private/notes", false},
+		{"longer allow wins", "SomeBot/1.0", "/private/public-page", true},
+		{"wildcard with anchor", "SomeBot/1.0", "/files/report.pdf", false},
+		{"anchor requires end of path", "SomeBot/1.0", "/files/report.pdf.html", true},
+		{"named group in a multi-agent block", "OtherBot/2", "/anything", false},
+		{"our group replaces wildcard", "RoguePlanet/0.4 (+https://example.com)", "/private/notes", true},
+		{"our group applies", "RoguePlanet/0.4 (+https://example.com)", "/no-planets/x", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			rules := parseRobots([]byte(robots), tt.userAgent)
+			if got := rules.allowed(tt.path); got != tt.want {
+				t.Errorf("allowed(%q) for %q = %v, want %v", tt.path, tt.userAgent, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRobots_EmptyDisallow(t *testing.T) {
+	t.Parallel()
+	rules := parseRobots([]byte("User-agent: *\nDisallow: /\n\nUser-agent: RoguePlanet\nDisallow:\n"), UserAgent)
+	if !rules.allowed("/feed.xml") {
+		t.Error("an empty Disallow in our group should allow everything")
+	}
+}
+
+func TestAllowed(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		path    string
+		want    bool
+		wantErr bool
+	}{
+		{"disallowed path", http.StatusOK, "User-agent: *\nDisallow: /private\n", "/private/page", false, false},
+		{"allowed path", http.StatusOK, "User-agent: *\nDisallow: /private\n", "/public/page", true, false},
+		{"missing robots.txt allows all", http.StatusNotFound, "", "/private/page", true, false},
+		{"server error disallows all", http.StatusInternalServerError, "", "/public/page", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var robotsFetches atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/robots.txt" {
+					t.Errorf("unexpected request for %s", r.URL.Path)
+				}
+				robotsFetches.Add(1)
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			c := NewForTesting()
+			for i := 0; i < 3; i++ {
+				got, err := c.Allowed(context.Background(), server.URL+tt.path)
+				if (err != nil) != tt.wantErr {
+					t.Fatalf("Allowed() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				if got != tt.want {
+					t.Errorf("Allowed() = %v, want %v", got, tt.want)
+				}
+			}
+			if n := robotsFetches.Load(); n != 1 {
+				t.Errorf("robots.txt fetched %d times, want 1 (cached)", n)
+			}
+		})
+	}
+}
+
+func TestFetch_RobotsModes(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name           string
+		mode           RobotsMode
+		wantErr        error
+		wantDisallowed bool
+		wantRobots     int32
+	}{
+		{"obey refuses", RobotsObey, ErrDisallowedByRobots, false, 1},
+		{"warn fetches and flags", RobotsWarn, nil, true, 1},
+		{"ignore never asks", RobotsIgnore, nil, false, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var robotsFetches, feedFetches atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/robots.txt" {
+					robotsFetches.Add(1)
+					_, _ = w.Write([]byte("User-agent: *\nDisallow: /feeds/\n"))
+					return
+				}
+				feedFetches.Add(1)
+				_, _ = w.Write([]byte("<rss/>"))
+			}))
+			defer server.Close()
+
+			c := NewForTesting().WithRobots(tt.mode)
+			resp, err := c.FetchWithRetry(context.Background(), server.URL+"/feeds/all.xml", FeedCache{}, 3)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Fatalf("FetchWithRetry() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && resp.RobotsDisallowed != tt.wantDisallowed {
+				t.Errorf("RobotsDisallowed = %v, want %v", resp.RobotsDisallowed, tt.wantDisallowed)
+			}
+			if n := robotsFetches.Load(); n != tt.wantRobots {
+				t.Errorf("robots.txt fetched %d times, want %d", n, tt.wantRobots)
+			}
+			wantFeed := int32(1)
+			if tt.wantErr != nil {
+				wantFeed = 0 // Refused without retries
+			}
+			if n := feedFetches.Load(); n != wantFeed {
+				t.Errorf("feed fetched %d times, want %d", n, wantFeed)
+			}
+		})
+	}
+}
+
+func TestRobotsModeByName(t *testing.T) {
+	t.Parallel()
+	for name, want := range map[string]RobotsMode{"": RobotsObey, "obey": RobotsObey, "warn": RobotsWarn, "ignore": RobotsIgnore} {
+		if got, err := RobotsModeByName(name); err != nil || got != want {
+			t.Errorf("RobotsModeByName(%q) = %v, %v; want %v", name, got, err, want)
+		}
+	}
+	if _, err := RobotsModeByName("sometimes"); err == nil {
+		t.Error("RobotsModeByName(sometimes) succeeded, want error")
+	}
+}
@@ -0,0 +1,183 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"strings"
+)
+
+// Policy decides which addresses the crawler may connect to, its protection
+// against server-side request forgery. The zero Policy blocks every
+// loopback, private, link-local, and otherwise internal address.
+//
+// Every connection is checked, including those redirects lead to. A host
+// name is resolved once, each address it resolves to must be allowed, and the
+// connection is made to the address that was checked, so a name cannot pass
+// with a public address and then be reached at an internal one (DNS
+// rebinding).
+type Policy struct {
+	// AllowNetworks are internal address ranges that may be fetched anyway,
+	// for planets that aggregate an intranet
+	AllowNetworks []netip.Prefix
+
+	// AllowHosts are host names that may resolve to internal addresses. A
+	// name starting with "." allows its subdomains, and the name itself.
+	AllowHosts []string
+}
+
+// reservedPrefixes are internal ranges the netip.Addr predicates miss
+var reservedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),     // "This network"
+	netip.MustParsePrefix("100.64.0.0/10"), // Carrier-grade NAT, also used by VPNs such as Tailscale
+	netip.MustParsePrefix("192.0.0.0/24"),  // IETF protocol assignments
+	netip.MustParsePrefix("198.18.0.0/15"), // Benchmarking
+	netip.MustParsePrefix("240.0.0.0/4"),   // Reserved, including broadcast
+}
+
+// internal reports whether addr is not a public internet address
+func internal(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() || addr.IsMulticast() {
+		return true
+	}
+	for _, p := range reservedPrefixes {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowsHost reports whether host is one of AllowHosts
+func (p Policy) allowsHost(host string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	for _, allowed := range p.AllowHosts {
+		allowed = strings.ToLower(allowed)
+		if host == strings.TrimPrefix(allowed, ".") ||
+			(strings.HasPrefix(allowed, ".") && strings.HasSuffix(host, allowed)) {
+			return true
+		}
+	}
+	return false
+}
+
+// allows reports whether the crawler may connect to addr for host
+func (p Policy) allows(host string, addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !internal(addr) || p.allowsHost(host) {
+		return true
+	}
+	for _, network := range p.AllowNetworks {
+		if network.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateURL checks that rawURL is an http or https URL the policy allows,
+// as far as can be told without resolving its host: a host that is an
+// internal address or a name for the local machine is rejected unless
+// allowed. Names that resolve to internal addresses are caught when the
+// crawler connects.
+func (p Policy) ValidateURL(rawURL string) error {
+	// Handle empty string explicitly
+	if rawURL == "" {
+		return ErrInvalidURL
+	}
+
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidURL, err)
+	}
+
+	// url.Parse can succeed but return invalid URLs (e.g., "not a url" parses but has no scheme)
+	// Explicitly check for presence of scheme
+	if parsed.Scheme == "" {
+		return ErrInvalidURL
+	}
+
+	// Only allow http and https
+	scheme := strings.ToLower(parsed.Scheme)
+	if scheme != "http" && scheme != "https" {
+		return ErrInvalidScheme
+	}
+
+	host := parsed.Hostname()
+	if addr, err := netip.ParseAddr(host); err == nil {
+		if !p.allows(host, addr) {
+			return ErrPrivateIP
+		}
+		return nil
+	}
+
+	// Names for the local machine, which resolve to loopback addresses
+	name := strings.TrimSuffix(strings.ToLower(host), ".")
+	if (name == "localhost" || strings.HasSuffix(name, ".localhost")) && !p.allowsHost(name) {
+		return ErrPrivateIP
+	}
+
+	return nil
+}
+
+// guardedDialer makes the crawler's connections, to addresses its policy
+// allows only
+type guardedDialer struct {
+	policy   Policy
+	dialer   *net.Dialer
+	resolver *net.Resolver // nil uses net.DefaultResolver
+	disabled bool          // For testing only - connects anywhere
+}
+
+func (d *guardedDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	if d.disabled {
+		return d.dialer.DialContext(ctx, network, address)
+	}
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+
+	var addrs []netip.Addr
+	if addr, err := netip.ParseAddr(host); err == nil {
+		addrs = []netip.Addr{addr}
+	} else {
+		resolver := d.resolver
+		if resolver == nil {
+			resolver = net.DefaultResolver
+		}
+		if addrs, err = resolver.LookupNetIP(ctx, "ip", host); err != nil {
+			return nil, err
+		}
+	}
+
+	// All or nothing: a name with one internal address among public ones is
+	// as suspect as one with only internal addresses
+	for _, addr := range addrs {
+		if !d.policy.allows(host, addr) {
+			return nil, fmt.Errorf("%w: %s resolves to %s", ErrPrivateIP, host, addr.Unmap())
+		}
+	}
+
+	// Connect to the addresses checked, not to the name, which could
+	// resolve differently a second time
+	var firstErr error
+	for _, addr := range addrs {
+		conn, err := d.dialer.DialContext(ctx, network, net.JoinHostPort(addr.Unmap().String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	if firstErr == nil {
+		firstErr = fmt.Errorf("%s has no addresses", host)
+	}
+	return nil, firstErr
+}
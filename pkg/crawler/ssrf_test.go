@@ -0,0 +1,138 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+func TestPolicyValidateURL(t *testing.T) {
+	t.Parallel()
+
+	policy := Policy{
+		AllowNetworks: []netip.Prefix{netip.MustParsePrefix("10.1.0.0/16")},
+		AllowHosts:    []string{"dev.localhost", ".intranet.example"},
+	}
+	tests := []struct {
+		url     string
+		wantErr bool
+	}{
+		{"https://example.com/feed", false},
+		{"http://10.1.2.3/feed", false},
+		{"http://10.2.0.1/feed", true},
+		{"http://[::ffff:10.1.2.3]/feed", false},
+		{"http://192.168.1.1/feed", true},
+		{"http://100.100.1.1/feed", true},
+		{"http://localhost/feed", true},
+		{"http://dev.localhost/feed", false},
+		{"http://other.localhost/feed", true},
+		{"ftp://10.1.2.3/feed", true},
+	}
+	for _, tt := range tests {
+		if err := policy.ValidateURL(tt.url); (err != nil) != tt.wantErr {
+			t.Errorf("ValidateURL(%q) error = %v, wantErr %v", tt.url, err, tt.wantErr)
+		}
+	}
+}
+
+func TestPolicyAllows(t *testing.T) {
+	t.Parallel()
+
+	policy := Policy{AllowHosts: []string{".intranet.example", "wiki.corp"}}
+	tests := []struct {
+		host string
+		addr string
+		want bool
+	}{
+		{"example.com", "93.184.216.34", true},
+		{"example.com", "2606:2800:220:1::1", true},
+		{"example.com", "10.0.0.1", false},
+		{"example.com", "100.64.0.1", false},
+		{"example.com", "198.18.0.1", false},
+		{"example.com", "255.255.255.255", false},
+		{"example.com", "fd00::1", false},
+		{"example.com", "::ffff:127.0.0.1", false},
+		{"blog.intranet.example", "10.0.0.1", true},
+		{"intranet.example", "10.0.0.1", true},
+		{"notintranet.example", "10.0.0.1", false},
+		{"WIKI.corp.", "192.168.0.1", true},
+		{"sub.wiki.corp", "192.168.0.1", false},
+	}
+	for _, tt := range tests {
+		if got := policy.allows(tt.host, netip.MustParseAddr(tt.addr)); got != tt.want {
+			t.Errorf("allows(%q, %s) = %v, want %v", tt.host, tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestGuardedDialer(t *testing.T) {
+	t.Parallel()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = ln.Close() }()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			_ = conn.Close()
+		}
+	}()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+
+	// localhost comes from the hosts file, so no DNS server is needed
+	blocked := &guardedDialer{dialer: &net.Dialer{}}
+	_, err = blocked.DialContext(context.Background(), "tcp", net.JoinHostPort("localhost", port))
+	if !errors.Is(err, ErrPrivateIP) {
+		t.Errorf("DialContext() to a name for a loopback address error = %v, want ErrPrivateIP", err)
+	}
+
+	allowed := &guardedDialer{
+		policy: Policy{AllowNetworks: []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8"), netip.MustParsePrefix("::1/128")}},
+		dialer: &net.Dialer{},
+	}
+	conn, err := allowed.DialContext(context.Background(), "tcp", net.JoinHostPort("localhost", port))
+	if err != nil {
+		t.Fatalf("DialContext() with loopback allowed error = %v", err)
+	}
+	_ = conn.Close()
+}
+
+func TestFetchWithSSRFPolicy(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/moved" {
+			http.Redirect(w, r, strings.Replace("http://"+r.Host+"/feed", "127.0.0.1", "localhost", 1), http.StatusFound)
+			return
+		}
+		_, _ = w.Write([]byte("<rss></rss>"))
+	}))
+	defer server.Close()
+
+	if _, err := NewWithConfig(CrawlerConfig{}).Fetch(context.Background(), server.URL+"/feed", FeedCache{}); !errors.Is(err, ErrPrivateIP) {
+		t.Errorf("Fetch() of a loopback feed by default error = %v, want ErrPrivateIP", err)
+	}
+
+	c := NewWithConfig(CrawlerConfig{SSRF: Policy{AllowHosts: []string{"127.0.0.1"}}})
+	resp, err := c.Fetch(context.Background(), server.URL+"/feed", FeedCache{})
+	if err != nil {
+		t.Fatalf("Fetch() of an allowed host error = %v", err)
+	}
+	if string(resp.Body) != "<rss></rss>" {
+		t.Errorf("Fetch() body = %q", resp.Body)
+	}
+
+	if _, err := c.Fetch(context.Background(), server.URL+"/moved", FeedCache{}); !errors.Is(err, ErrPrivateIP) {
+		t.Errorf("Fetch() redirected to a host not allowed error = %v, want ErrPrivateIP", err)
+	}
+}
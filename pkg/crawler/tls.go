@@ -0,0 +1,57 @@
+package crawler
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// TLSVersionByName returns the crypto/tls version for a config name such
+// as "1.2". An empty name selects TLS 1.2.
+func TLSVersionByName(name string) (uint16, error) {
+	switch name {
+	case "1.0":
+		return tls.VersionTLS10, nil
+	case "1.1":
+		return tls.VersionTLS11, nil
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return tls.VersionTLS12, fmt.Errorf("unknown TLS version: %s (must be '1.0', '1.1', '1.2', or '1.3')", name)
+	}
+}
+
+// insecureTransport sends requests to one host without verifying its TLS
+// certificate, and all others through the verifying transport, so that a
+// feed fetched insecurely cannot redirect the crawler to skip verification
+// elsewhere
+type insecureTransport struct {
+	host     string
+	insecure http.RoundTripper
+	secure   http.RoundTripper
+}
+
+func (t *insecureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if strings.EqualFold(req.URL.Host, t.host) {
+		return t.insecure.RoundTrip(req)
+	}
+	return t.secure.RoundTrip(req)
+}
+
+// transportFor returns the transport for fetching feedURL: the crawler's
+// own, or for a feed with insecure_skip_verify, one that does not verify the
+// feed host's certificate. insecure reports which.
+func (c *Crawler) transportFor(feedURL string) (transport http.RoundTripper, insecure bool) {
+	if !c.insecureFeeds[feedURL] || c.insecure == nil {
+		return c.client.Transport, false
+	}
+	u, err := url.Parse(feedURL)
+	if err != nil || !strings.EqualFold(u.Scheme, "https") {
+		return c.client.Transport, false
+	}
+	return &insecureTransport{host: u.Host, insecure: c.insecure, secure: c.client.Transport}, true
+}
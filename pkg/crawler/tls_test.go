@@ -0,0 +1,93 @@
+package crawler
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTLSVersionByName(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		want uint16
+	}{
+		{"", tls.VersionTLS12},
+		{"1.0", tls.VersionTLS10},
+		{"1.1", tls.VersionTLS11},
+		{"1.2", tls.VersionTLS12},
+		{"1.3", tls.VersionTLS13},
+	}
+	for _, tt := range tests {
+		if got, err := TLSVersionByName(tt.name); err != nil || got != tt.want {
+			t.Errorf("TLSVersionByName(%q) = %x, %v; want %x", tt.name, got, err, tt.want)
+		}
+	}
+	if _, err := TLSVersionByName("1.4"); err == nil {
+		t.Error("TLSVersionByName(\"1.4\") succeeded")
+	}
+}
+
+func TestFetchTLSConfig(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("<rss></rss>"))
+	}))
+	server.TLS = &tls.Config{MaxVersion: tls.VersionTLS12}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	local := Policy{AllowHosts: []string{"127.0.0.1"}}
+	ctx := context.Background()
+
+	t.Run("untrusted certificate", func(t *testing.T) {
+		t.Parallel()
+		resp, err := NewWithConfig(CrawlerConfig{SSRF: local}).Fetch(ctx, server.URL, FeedCache{})
+		if err == nil {
+			t.Fatalf("Fetch() of a self-signed server succeeded: %+v", resp)
+		}
+	})
+
+	t.Run("custom CA", func(t *testing.T) {
+		t.Parallel()
+		pool := x509.NewCertPool()
+		pool.AddCert(server.Certificate())
+		resp, err := NewWithConfig(CrawlerConfig{SSRF: local, RootCAs: pool}).Fetch(ctx, server.URL, FeedCache{})
+		if err != nil {
+			t.Fatalf("Fetch() with the server's CA trusted error = %v", err)
+		}
+		if resp.Insecure {
+			t.Error("Insecure = true for a verified fetch")
+		}
+	})
+
+	t.Run("minimum version", func(t *testing.T) {
+		t.Parallel()
+		pool := x509.NewCertPool()
+		pool.AddCert(server.Certificate())
+		c := NewWithConfig(CrawlerConfig{SSRF: local, RootCAs: pool, MinTLSVersion: tls.VersionTLS13})
+		if _, err := c.Fetch(ctx, server.URL, FeedCache{}); err == nil {
+			t.Error("Fetch() from a TLS 1.2 server with TLS 1.3 required succeeded")
+		}
+	})
+
+	t.Run("insecure_skip_verify", func(t *testing.T) {
+		t.Parallel()
+		c := NewWithConfig(CrawlerConfig{SSRF: local, InsecureSkipVerify: map[string]bool{server.URL + "/feed": true}})
+		resp, err := c.Fetch(ctx, server.URL+"/feed", FeedCache{})
+		if err != nil {
+			t.Fatalf("Fetch() of an insecure feed error = %v", err)
+		}
+		if !resp.Insecure {
+			t.Error("Insecure = false for a fetch without verification")
+		}
+		if _, err := c.Fetch(ctx, server.URL+"/other", FeedCache{}); err == nil {
+			t.Error("Fetch() of another feed on the host skipped verification")
+		}
+	})
+}
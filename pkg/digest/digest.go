@@ -0,0 +1,326 @@
+// Package digest renders the entries a planet first saw in a period as an
+// email digest, with plain text and HTML parts, and sends it to subscribers
+// by SMTP.
+//
+// The subject and both parts are Go templates executed on a Digest. The
+// built-in HTML keeps to inline styles and a single column, which mail
+// clients render reliably; operators may replace any of the three.
+package digest
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	htmltemplate "html/template"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/adewale/rogue_planet/pkg/translate"
+)
+
+// Entry is an entry in a digest. Its title and summary are plain text.
+type Entry struct {
+	Title     string
+	Link      string
+	Author    string
+	FeedTitle string
+	FeedLink  string
+	Published time.Time
+	Summary   string // At most summaryLength characters of the entry's text
+}
+
+// Digest is the entries of a planet first seen in a period, newest first
+type Digest struct {
+	Planet  string // Planet name
+	Link    string // Planet URL, if set
+	Since   time.Time
+	Until   time.Time
+	Entries []Entry
+	Total   int // Entries first seen in the period, which may exceed len(Entries)
+}
+
+// Empty reports whether there is nothing to send
+func (d Digest) Empty() bool {
+	return len(d.Entries) == 0
+}
+
+// More is the number of entries left out of the digest
+func (d Digest) More() int {
+	return d.Total - len(d.Entries)
+}
+
+// summaryLength is the most characters of entry text in a digest
+const summaryLength = 300
+
+// Excerpt returns the start of the text of an HTML fragment, for an entry
+// summary
+func Excerpt(fragment string) string {
+	return truncate(translate.PlainText(fragment), summaryLength)
+}
+
+// Message is a rendered digest
+type Message struct {
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Templates render digests
+type Templates struct {
+	subject *texttemplate.Template
+	text    *texttemplate.Template
+	html    *htmltemplate.Template
+}
+
+// Default templates
+const (
+	DefaultSubject = `{{.Planet}}: {{.Total}} new {{if eq .Total 1}}post{{else}}posts{{end}}`
+
+	defaultText = `{{.Planet}}: new posts from {{date .Since}} to {{date .Until}}
+{{range .Entries}}
+{{.Title}}
+{{if .Author}}{{.Author}}, {{end}}{{.FeedTitle}}, {{date .Published}}
+{{.Link}}
+{{if .Summary}}
+{{.Summary}}
+{{end}}{{end}}{{if gt .More 0}}
+...and {{.More}} more{{if .Link}} at {{.Link}}{{end}}
+{{end}}`
+
+	defaultHTML = `<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><meta name="viewport" content="width=device-width"><title>{{.Planet}}</title></head>
+<body style="margin:0;padding:0;background:#f6f6f6">
+<div style="max-width:600px;margin:0 auto;padding:16px;background:#ffffff;font-family:Helvetica,Arial,sans-serif;font-size:16px;line-height:1.5;color:#222222">
+<h1 style="font-size:22px;margin:0 0 4px">{{if .Link}}<a href="{{.Link}}" style="color:#222222;text-decoration:none">{{.Planet}}</a>{{else}}{{.Planet}}{{end}}</h1>
+<p style="margin:0 0 16px;color:#666666;font-size:14px">New posts from {{date .Since}} to {{date .Until}}</p>
+{{range .Entries}}<div style="padding:12px 0;border-top:1px solid #eeeeee">
+<h2 style="font-size:18px;margin:0"><a href="{{.Link}}" style="color:#1a5fb4">{{.Title}}</a></h2>
+<p style="margin:2px 0 0;color:#666666;font-size:14px">{{if .Author}}{{.Author}}, {{end}}{{if .FeedLink}}<a href="{{.FeedLink}}" style="color:#666666">{{.FeedTitle}}</a>{{else}}{{.FeedTitle}}{{end}}, {{date .Published}}</p>
+{{if .Summary}}<p style="margin:6px 0 0">{{.Summary}}</p>{{end}}
+</div>
+{{end}}{{if gt .More 0}}<p style="padding-top:12px;border-top:1px solid #eeeeee">...and {{.More}} more{{if .Link}} at <a href="{{.Link}}" style="color:#1a5fb4">{{.Planet}}</a>{{end}}</p>
+{{end}}</div>
+</body>
+</html>
+`
+)
+
+// funcs are the functions digest templates may call
+var funcs = map[string]any{
+	"date": func(t time.Time) string { return t.Format("2 January 2006") },
+}
+
+// NewTemplates parses the subject template, or DefaultSubject if it is
+// empty, and the text and HTML templates in files, or the built-in ones for
+// empty paths
+func NewTemplates(subject, textPath, htmlPath string) (*Templates, error) {
+	if subject == "" {
+		subject = DefaultSubject
+	}
+	textSrc, err := readTemplate(textPath, defaultText)
+	if err != nil {
+		return nil, err
+	}
+	htmlSrc, err := readTemplate(htmlPath, defaultHTML)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &Templates{}
+	if t.subject, err = texttemplate.New("subject").Funcs(funcs).Parse(subject); err != nil {
+		return nil, fmt.Errorf("parse digest subject: %w", err)
+	}
+	if t.text, err = texttemplate.New("text").Funcs(funcs).Parse(textSrc); err != nil {
+		return nil, fmt.Errorf("parse digest text template: %w", err)
+	}
+	if t.html, err = htmltemplate.New("html").Funcs(funcs).Parse(htmlSrc); err != nil {
+		return nil, fmt.Errorf("parse digest HTML template: %w", err)
+	}
+	return t, nil
+}
+
+func readTemplate(path, fallback string) (string, error) {
+	if path == "" {
+		return fallback, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("read digest template: %w", err)
+	}
+	return string(data), nil
+}
+
+// Render renders a digest
+func (t *Templates) Render(d Digest) (Message, error) {
+	var subject, text, html bytes.Buffer
+	if err := t.subject.Execute(&subject, d); err != nil {
+		return Message{}, fmt.Errorf("render digest subject: %w", err)
+	}
+	if err := t.text.Execute(&text, d); err != nil {
+		return Message{}, fmt.Errorf("render digest text: %w", err)
+	}
+	if err := t.html.Execute(&html, d); err != nil {
+		return Message{}, fmt.Errorf("render digest HTML: %w", err)
+	}
+	return Message{
+		Subject: strings.Join(strings.Fields(subject.String()), " "), // One line
+		Text:    text.String(),
+		HTML:    html.String(),
+	}, nil
+}
+
+// Mailer sends digests by SMTP. The connection is upgraded with STARTTLS
+// when the server offers it; credentials are only sent over TLS or to
+// localhost.
+type Mailer struct {
+	Addr     string // SMTP server host:port
+	From     string
+	Username string // Optional; enables PLAIN authentication
+	Password string
+
+	send func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+}
+
+// Send emails msg to each subscriber separately, so none sees the others'
+// addresses, and returns how many it was sent to. It tries every
+// subscriber, returning the first error.
+func (m Mailer) Send(ctx context.Context, subscribers []string, msg Message) (int, error) {
+	var auth smtp.Auth
+	if m.Username != "" {
+		host, _, err := net.SplitHostPort(m.Addr)
+		if err != nil {
+			return 0, fmt.Errorf("invalid SMTP address %q: %w", m.Addr, err)
+		}
+		auth = smtp.PlainAuth("", m.Username, m.Password, host)
+	}
+	send := m.send
+	if send == nil {
+		send = smtp.SendMail
+	}
+
+	sent := 0
+	var firstErr error
+	for _, to := range subscribers {
+		// net/smtp has no context support, so each send runs until the
+		// server answers; ctx is checked between them
+		if err := ctx.Err(); err != nil {
+			return sent, err
+		}
+		body, err := m.message(to, msg)
+		if err == nil {
+			err = send(m.Addr, auth, m.From, []string{to}, body)
+		}
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("send digest to %s: %w", to, err)
+			}
+			continue
+		}
+		sent++
+	}
+	return sent, firstErr
+}
+
+// message builds a multipart/alternative email of msg to one subscriber
+func (m Mailer) message(to string, msg Message) ([]byte, error) {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	for _, part := range []struct{ contentType, content string }{
+		{"text/plain; charset=utf-8", msg.Text},
+		{"text/html; charset=utf-8", msg.HTML},
+	} {
+		pw, err := w.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(pw)
+		if _, err := qp.Write([]byte(strings.ReplaceAll(part.content, "\n", "\r\n"))); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "From: %s\r\n", m.From)
+	fmt.Fprintf(&out, "To: %s\r\n", to)
+	fmt.Fprintf(&out, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&out, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	out.WriteString("Auto-Submitted: auto-generated\r\n")
+	out.WriteString("Precedence: bulk\r\n")
+	out.WriteString("MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&out, "Content-Type: multipart/alternative; boundary=%q\r\n", w.Boundary())
+	out.WriteString("\r\n")
+	out.Write(body.Bytes())
+	return out.Bytes(), nil
+}
+
+// Subscribers returns the addresses in list and in the file at path, if
+// not empty, without duplicates. The file has one address per line; blank
+// lines and lines starting with # are skipped.
+func Subscribers(list []string, path string) ([]string, error) {
+	all := append([]string(nil), list...)
+	if path != "" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("open subscribers file: %w", err)
+		}
+		defer file.Close()
+
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			if line := strings.TrimSpace(scanner.Text()); line != "" && !strings.HasPrefix(line, "#") {
+				all = append(all, line)
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("read subscribers file: %w", err)
+		}
+	}
+
+	seen := make(map[string]bool, len(all))
+	var subscribers []string
+	for _, s := range all {
+		addr, err := mail.ParseAddress(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid subscriber address %q: %w", s, err)
+		}
+		if key := strings.ToLower(addr.Address); !seen[key] {
+			seen[key] = true
+			subscribers = append(subscribers, addr.Address)
+		}
+	}
+	return subscribers, nil
+}
+
+// truncate shortens s to at most n characters, at a word boundary, adding
+// an ellipsis if anything was cut
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	cut := string(r[:n-1])
+	if i := strings.LastIndexByte(cut, ' '); i > 0 {
+		cut = cut[:i]
+	}
+	return strings.TrimRight(cut, " ,.;:") + "…"
+}
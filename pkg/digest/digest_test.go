@@ -0,0 +1,162 @@
+package digest
+
+import (
+	"context"
+	"errors"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testDigest() Digest {
+	since := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	return Digest{
+		Planet: "Planète",
+		Link:   "https://planet.example.com/",
+		Since:  since,
+		Until:  since.Add(24 * time.Hour),
+		Entries: []Entry{
+			{Title: "Fish & chips", Link: "https://a.example.com/1", Author: "Ann", FeedTitle: "Ann's blog", FeedLink: "https://a.example.com/", Published: since, Summary: "A <b> is not a tag here"},
+			{Title: "Second", Link: "javascript:alert(1)", FeedTitle: "Other", Published: since},
+		},
+		Total: 5,
+	}
+}
+
+func TestRender(t *testing.T) {
+	t.Parallel()
+	tmpl, err := NewTemplates("", "", "")
+	if err != nil {
+		t.Fatalf("NewTemplates() error = %v", err)
+	}
+	msg, err := tmpl.Render(testDigest())
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	if msg.Subject != "Planète: 5 new posts" {
+		t.Errorf("Subject = %q", msg.Subject)
+	}
+	for _, want := range []string{"Fish & chips\nAnn, Ann's blog, 1 March 2025\nhttps://a.example.com/1\n", "A <b> is not a tag here", "...and 3 more at https://planet.example.com/"} {
+		if !strings.Contains(msg.Text, want) {
+			t.Errorf("Text missing %q:\n%s", want, msg.Text)
+		}
+	}
+	for _, want := range []string{"Fish &amp; chips", "A &lt;b&gt; is not", `href="#ZgotmplZ"`, "...and 3 more"} {
+		if !strings.Contains(msg.HTML, want) {
+			t.Errorf("HTML missing %q:\n%s", want, msg.HTML)
+		}
+	}
+}
+
+func TestCustomTemplates(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	textPath := filepath.Join(dir, "digest.txt")
+	if err := os.WriteFile(textPath, []byte("{{range .Entries}}* {{.Title}}\n{{end}}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tmpl, err := NewTemplates("{{.Planet}}\ndaily ({{date .Until}})", textPath, "")
+	if err != nil {
+		t.Fatalf("NewTemplates() error = %v", err)
+	}
+	msg, err := tmpl.Render(testDigest())
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	if msg.Subject != "Planète daily (2 March 2025)" || msg.Text != "* Fish & chips\n* Second\n" {
+		t.Errorf("Render() = %q, %q", msg.Subject, msg.Text)
+	}
+
+	if _, err := NewTemplates("{{.Planet", "", ""); err == nil {
+		t.Error("NewTemplates() accepted an invalid subject")
+	}
+	if _, err := NewTemplates("", "", filepath.Join(dir, "missing.html")); err == nil {
+		t.Error("NewTemplates() accepted a missing HTML template")
+	}
+}
+
+func TestMailerSend(t *testing.T) {
+	t.Parallel()
+	var sent []string
+	var msgs []string
+	m := Mailer{
+		Addr:     "smtp.example.com:587",
+		From:     "planet@example.com",
+		Username: "planet",
+		Password: "secret",
+		send: func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+			if a == nil || from != "planet@example.com" || len(to) != 1 {
+				t.Errorf("send(%q, %v, %q, %v)", addr, a, from, to)
+			}
+			if to[0] == "bounce@example.com" {
+				return errors.New("550 no such user")
+			}
+			sent = append(sent, to[0])
+			msgs = append(msgs, string(msg))
+			return nil
+		},
+	}
+
+	msg := Message{Subject: "Planète: 2 new posts", Text: "Hello\n", HTML: "<p>Hello</p>\n"}
+	n, err := m.Send(context.Background(), []string{"a@example.com", "bounce@example.com", "b@example.com"}, msg)
+	if n != 2 || err == nil || !strings.Contains(err.Error(), "bounce@example.com") {
+		t.Errorf("Send() = %d, %v; want 2 sent and the bounce's error", n, err)
+	}
+	if len(sent) != 2 || sent[1] != "b@example.com" {
+		t.Fatalf("sent to %v", sent)
+	}
+	for _, want := range []string{
+		"To: a@example.com\r\n",
+		"Subject: =?utf-8?q?Plan=C3=A8te:_2_new_posts?=\r\n",
+		"Content-Type: multipart/alternative;",
+		"Content-Type: text/plain; charset=utf-8",
+		"Content-Type: text/html; charset=utf-8",
+		"Hello\r\n",
+	} {
+		if !strings.Contains(msgs[0], want) {
+			t.Errorf("message missing %q:\n%s", want, msgs[0])
+		}
+	}
+	if strings.Contains(msgs[0], "b@example.com") {
+		t.Error("message to one subscriber mentions another")
+	}
+}
+
+func TestSubscribers(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "subscribers.txt")
+	content := "# Readers\nb@example.com\n\n  Carol <c@example.com>  \nA@example.com\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := Subscribers([]string{"a@example.com"}, path)
+	if err != nil {
+		t.Fatalf("Subscribers() error = %v", err)
+	}
+	if strings.Join(got, " ") != "a@example.com b@example.com c@example.com" {
+		t.Errorf("Subscribers() = %v", got)
+	}
+
+	if _, err := Subscribers([]string{"not an address"}, ""); err == nil {
+		t.Error("Subscribers() accepted an invalid address")
+	}
+	if _, err := Subscribers(nil, filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("Subscribers() accepted a missing file")
+	}
+}
+
+func TestExcerpt(t *testing.T) {
+	t.Parallel()
+	if got := Excerpt("<p>Short &amp; <em>sweet</em></p>"); got != "Short & sweet" {
+		t.Errorf("Excerpt() = %q", got)
+	}
+	long := strings.Repeat("word ", 100)
+	got := Excerpt(long)
+	if r := []rune(got); len(r) > summaryLength || !strings.HasSuffix(got, "d…") {
+		t.Errorf("Excerpt() of long text = %q (%d characters)", got, len(r))
+	}
+}
@@ -0,0 +1,170 @@
+// Package discover finds the feeds a web page offers.
+//
+// People usually know a blog's address rather than its feed's. Feeds returns
+// the URL itself when it is already a feed, and otherwise the feeds the page
+// advertises in <link rel="alternate"> tags, which is how browsers and feed
+// readers find them. Pages are fetched through the crawler so the usual SSRF
+// protections apply.
+package discover
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+
+	"github.com/adewale/rogue_planet/pkg/crawler"
+)
+
+// ErrNoFeeds is returned for a page that is not a feed and links to none
+var ErrNoFeeds = errors.New("no feeds found")
+
+// feedTypes maps the MIME types of advertised feeds to their formats
+var feedTypes = map[string]string{
+	"application/rss+xml":   "RSS",
+	"application/atom+xml":  "Atom",
+	"application/rdf+xml":   "RDF",
+	"application/feed+json": "JSON Feed",
+	"application/json":      "JSON Feed",
+}
+
+// Feed is a feed found for a page
+type Feed struct {
+	URL   string
+	Title string // From the link's title attribute; may be empty
+	Type  string // RSS, Atom, RDF, or JSON Feed
+}
+
+// Feeds fetches pageURL with c and returns the feeds it offers: pageURL itself
+// if it is a feed, or the feeds linked from its head, in page order.
+func Feeds(ctx context.Context, c *crawler.Crawler, pageURL string) ([]Feed, error) {
+	resp, err := c.Fetch(ctx, pageURL, crawler.FeedCache{})
+	if err != nil {
+		return nil, fmt.Errorf("fetch %s: %w", pageURL, err)
+	}
+	if format := sniffFeed(resp.Body); format != "" {
+		return []Feed{{URL: pageURL, Type: format}}, nil
+	}
+
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q", crawler.ErrInvalidURL, pageURL)
+	}
+	if resp.FinalURL != "" {
+		if final, err := url.Parse(resp.FinalURL); err == nil {
+			base = final
+		}
+	}
+	feeds := FeedLinks(resp.Body, base)
+	if len(feeds) == 0 {
+		return nil, fmt.Errorf("%w at %s", ErrNoFeeds, pageURL)
+	}
+	return feeds, nil
+}
+
+// FeedLinks returns the feeds advertised by the <link rel="alternate"> tags
+// in an HTML page's head, resolved against base. Duplicates are dropped.
+func FeedLinks(page []byte, base *url.URL) []Feed {
+	var feeds []Feed
+	seen := make(map[string]bool)
+	z := html.NewTokenizer(bytes.NewReader(page))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return feeds
+		case html.StartTagToken, html.SelfClosingTagToken:
+			tok := z.Token()
+			if tok.Data == "body" {
+				return feeds
+			}
+			if tok.Data != "link" {
+				continue
+			}
+			var rel, typ, href, title string
+			for _, a := range tok.Attr {
+				switch a.Key {
+				case "rel":
+					rel = strings.ToLower(a.Val)
+				case "type":
+					typ = strings.ToLower(strings.TrimSpace(a.Val))
+				case "href":
+					href = strings.TrimSpace(a.Val)
+				case "title":
+					title = strings.TrimSpace(a.Val)
+				}
+			}
+			format, ok := feedTypes[typ]
+			if !ok || href == "" || !hasToken(rel, "alternate") {
+				continue
+			}
+			ref, err := url.Parse(href)
+			if err != nil {
+				continue
+			}
+			resolved := base.ResolveReference(ref)
+			feedURL := resolved.String()
+			if (resolved.Scheme != "http" && resolved.Scheme != "https") || seen[feedURL] {
+				continue
+			}
+			seen[feedURL] = true
+			feeds = append(feeds, Feed{URL: feedURL, Title: title, Type: format})
+		case html.EndTagToken:
+			if name, _ := z.TagName(); string(name) == "head" {
+				return feeds
+			}
+		}
+	}
+}
+
+// hasToken reports whether the space-separated list contains token
+func hasToken(list, token string) bool {
+	for _, t := range strings.Fields(list) {
+		if t == token {
+			return true
+		}
+	}
+	return false
+}
+
+// sniffFeed returns the format of body if it is a feed, or "" if it is not
+func sniffFeed(body []byte) string {
+	trimmed := bytes.TrimSpace(bytes.TrimPrefix(body, []byte("\xef\xbb\xbf")))
+	if bytes.HasPrefix(trimmed, []byte("{")) {
+		// JSON Feeds declare their version as a jsonfeed.org URL
+		head := trimmed[:min(len(trimmed), 1024)]
+		if bytes.Contains(head, []byte("jsonfeed.org/version")) {
+			return "JSON Feed"
+		}
+		return ""
+	}
+
+	// The first element of an XML feed names its format; an HTML page's is
+	// <html>, if it decodes as XML at all
+	d := xml.NewDecoder(bytes.NewReader(trimmed))
+	d.Strict = false
+	// Element names are ASCII in any charset a feed declares
+	d.CharsetReader = func(_ string, r io.Reader) (io.Reader, error) { return r, nil }
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return ""
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			switch strings.ToLower(start.Name.Local) {
+			case "rss":
+				return "RSS"
+			case "feed":
+				return "Atom"
+			case "rdf":
+				return "RDF"
+			}
+			return ""
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package discover
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/adewale/rogue_planet/pkg/crawler"
+)
+
+func TestFeedLinks(t *testing.T) {
+	t.Parallel()
+	page := []byte(`<!DOCTYPE html><html><head>
+<link rel="stylesheet" href="/style.css">
+<link rel="alternate" type="application/rss+xml" title="Posts" href="/feed.xml">
+<link rel="Alternate" type="Application/Atom+XML" href="../atom.xml">
+<link rel="alternate" type="application/feed+json" href="https://cdn.example.com/feed.json">
+<link rel="alternate" type="text/html" hreflang="fr" href="/fr/">
+<link rel="alternate" type="application/rss+xml" href="/feed.xml">
+<link rel="alternate" type="application/rss+xml" href="">
+<link rel="alternate" type="application/rss+xml" href="javascript:alert(1)">
+</head><body>
+<link rel="alternate" type="application/rss+xml" href="/ignored.xml">
+</body></html>`)
+	base, _ := url.Parse("https://example.com/blog/")
+
+	got := FeedLinks(page, base)
+	want := []Feed{
+		{URL: "https://example.com/feed.xml", Title: "Posts", Type: "RSS"},
+		{URL: "https://example.com/atom.xml", Type: "Atom"},
+		{URL: "https://cdn.example.com/feed.json", Type: "JSON Feed"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("FeedLinks() = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("FeedLinks()[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestSniffFeed(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"rss", `<?xml version="1.0"?><rss version="2.0"><channel/></rss>`, "RSS"},
+		{"atom", "\xef\xbb\xbf\n<feed xmlns=\"http://www.w3.org/2005/Atom\"/>", "Atom"},
+		{"rdf", `<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#"/>`, "RDF"},
+		{"latin1 rss", `<?xml version="1.0" encoding="ISO-8859-1"?><rss/>`, "RSS"},
+		{"stylesheet first", `<?xml version="1.0"?><?xml-stylesheet href="/feed.xsl"?><!-- c --><rss/>`, "RSS"},
+		{"json feed", `{"version": "https://jsonfeed.org/version/1.1", "items": []}`, "JSON Feed"},
+		{"other json", `{"name": "not a feed"}`, ""},
+		{"html", `<!DOCTYPE html><html><head><title>Blog</title></head></html>`, ""},
+		{"empty", ``, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := sniffFeed([]byte(tt.body)); got != tt.want {
+				t.Errorf("sniffFeed() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFeeds(t *testing.T) {
+	t.Parallel()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<html><head><link rel="alternate" type="application/atom+xml" href="atom.xml"></head></html>`))
+	})
+	mux.HandleFunc("/old", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/blog/", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/blog/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<html><head><link rel="alternate" type="application/rss+xml" href="rss.xml"></head></html>`))
+	})
+	mux.HandleFunc("/feed.xml", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<rss version="2.0"><channel/></rss>`))
+	})
+	mux.HandleFunc("/plain", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<html><head><title>No feeds</title></head></html>`))
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	c := crawler.NewForTesting()
+	ctx := context.Background()
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/", server.URL + "/atom.xml"},
+		{"/old", server.URL + "/blog/rss.xml"}, // Resolved against the redirected page
+		{"/feed.xml", server.URL + "/feed.xml"},
+	}
+	for _, tt := range tests {
+		feeds, err := Feeds(ctx, c, server.URL+tt.path)
+		if err != nil {
+			t.Errorf("Feeds(%s) error = %v", tt.path, err)
+			continue
+		}
+		if len(feeds) != 1 || feeds[0].URL != tt.want {
+			t.Errorf("Feeds(%s) = %+v, want %s", tt.path, feeds, tt.want)
+		}
+	}
+
+	if _, err := Feeds(ctx, c, server.URL+"/plain"); !errors.Is(err, ErrNoFeeds) {
+		t.Errorf("Feeds(/plain) error = %v, want ErrNoFeeds", err)
+	}
+	if _, err := Feeds(ctx, crawler.New(), "http://127.0.0.1:1/"); err == nil {
+		t.Error("Feeds() of a private address succeeded, want the crawler's SSRF check to refuse it")
+	}
+}
@@ -0,0 +1,438 @@
+// Package extract recovers the full text of articles from feeds that only
+// publish summaries.
+//
+// The entry's page is fetched through the crawler (with its SSRF checks and,
+// unless the crawler ignores it, the site's robots.txt), and the element most
+// likely to hold the article body is picked with a readability-style
+// heuristic: paragraphs of prose score their containers, containers whose
+// class or id looks like navigation, comments, or sharing widgets are
+// penalised, and link-heavy blocks count for less. The chosen element's HTML, with URLs made absolute,
+// is sanitized with the feed's policy before it replaces the summary.
+package extract
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+
+	"github.com/adewale/rogue_planet/pkg/crawler"
+)
+
+const (
+	// MaxPageSize limits article pages to 5MB
+	MaxPageSize = 5 * 1024 * 1024
+	// MinWords is the length below which an entry's content is treated as a
+	// summary worth replacing
+	MinWords = 150
+	// minArticleChars is the least text an extracted article may have
+	minArticleChars = 250
+)
+
+// ErrNoArticle is returned when a page has no recognisable article body
+var ErrNoArticle = errors.New("no article found on page")
+
+// Extractor fetches entry pages and extracts their article bodies or
+// preview images for the feeds it is enabled for
+type Extractor struct {
+	crawler  *crawler.Crawler
+	sanitize func(feedURL, content string) string
+	feeds    map[string]bool // Feeds whose articles are extracted
+	images   map[string]bool // Feeds whose entries take their page's preview image
+}
+
+// New creates an Extractor that extracts articles for the feeds in feeds
+// and preview images for the feeds in imageFeeds. sanitize cleans extracted
+// HTML with the feed's sanitization policy.
+func New(c *crawler.Crawler, sanitize func(feedURL, content string) string, feeds, imageFeeds []string) *Extractor {
+	set := func(urls []string) map[string]bool {
+		m := make(map[string]bool, len(urls))
+		for _, u := range urls {
+			m[u] = true
+		}
+		return m
+	}
+	return &Extractor{
+		crawler:  c.WithMaxSize(MaxPageSize),
+		sanitize: sanitize,
+		feeds:    set(feeds),
+		images:   set(imageFeeds),
+	}
+}
+
+// Enabled reports whether full content is extracted for a feed
+func (e *Extractor) Enabled(feedURL string) bool {
+	return e != nil && e.feeds[feedURL]
+}
+
+// ImagesEnabled reports whether entries of a feed without an image of
+// their own take the preview image of their page
+func (e *Extractor) ImagesEnabled(feedURL string) bool {
+	return e != nil && e.images[feedURL]
+}
+
+// Page is what an Extractor found on an entry's page
+type Page struct {
+	Article string // Sanitized article body, or "" if none was found
+	Image   string // Absolute URL of the page's og:image or twitter:image, or ""
+}
+
+// NeedsExtraction reports whether entry content looks like a summary rather
+// than the full article
+func NeedsExtraction(content string) bool {
+	return wordCount(content) < MinWords
+}
+
+// Article fetches link and returns its sanitized article body. Whether the
+// site's robots.txt is obeyed depends on the crawler's RobotsMode.
+func (e *Extractor) Article(ctx context.Context, feedURL, link string) (string, error) {
+	page, err := e.Page(ctx, feedURL, link)
+	if err != nil {
+		return "", err
+	}
+	if page.Article == "" {
+		return "", ErrNoArticle
+	}
+	return page.Article, nil
+}
+
+// Page fetches link and returns its sanitized article body and preview
+// image, either of which may be missing. Only failure to fetch the page is
+// an error.
+func (e *Extractor) Page(ctx context.Context, feedURL, link string) (Page, error) {
+	resp, err := e.crawler.Fetch(ctx, link, crawler.FeedCache{})
+	if err != nil {
+		return Page{}, fmt.Errorf("fetch page: %w", err)
+	}
+
+	pageURL := link
+	if resp.FinalURL != "" {
+		pageURL = resp.FinalURL
+	}
+	var page Page
+	page.Image = PreviewImage(resp.Body, pageURL)
+	if article, err := Extract(resp.Body, pageURL); err == nil {
+		article = strings.TrimSpace(e.sanitize(feedURL, article))
+		if len(textContent(article)) >= minArticleChars {
+			page.Article = article
+		}
+	}
+	return page, nil
+}
+
+// PreviewImage returns the image a page offers for link previews, from its
+// og:image (or og:image:url, og:image:secure_url) or twitter:image meta
+// tag, resolved against pageURL. Only http and https URLs are returned.
+func PreviewImage(page []byte, pageURL string) string {
+	doc, err := html.Parse(bytes.NewReader(page))
+	if err != nil {
+		return ""
+	}
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return ""
+	}
+	if href := baseHref(doc); href != "" {
+		if b, err := base.Parse(href); err == nil {
+			base = b
+		}
+	}
+
+	found := make(map[string]string)
+	walk(doc, func(n *html.Node) {
+		if n.DataAtom != atom.Meta {
+			return
+		}
+		key := attr(n, "property")
+		if key == "" {
+			key = attr(n, "name")
+		}
+		key = strings.ToLower(key)
+		if _, seen := found[key]; !seen {
+			found[key] = strings.TrimSpace(attr(n, "content"))
+		}
+	})
+	for _, key := range []string{"og:image:secure_url", "og:image", "og:image:url", "twitter:image", "twitter:image:src"} {
+		if found[key] == "" {
+			continue
+		}
+		u, err := base.Parse(found[key])
+		if err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+			return u.String()
+		}
+	}
+	return ""
+}
+
+var (
+	// unlikelyPattern matches class names and ids of page furniture
+	unlikelyPattern = regexp.MustCompile(`(?i)comment|sidebar|footer|header|masthead|nav|menu|share|social|related|promo|advert|sponsor|cookie|banner|subscribe|newsletter|popup|modal|breadcrumb|pagination|widget`)
+	// likelyPattern matches class names and ids of article containers
+	likelyPattern = regexp.MustCompile(`(?i)article|content|entry|post|body|main|story|text|prose`)
+)
+
+// removedElements never contain article text
+var removedElements = map[atom.Atom]bool{
+	atom.Script: true, atom.Style: true, atom.Noscript: true, atom.Nav: true,
+	atom.Header: true, atom.Footer: true, atom.Aside: true, atom.Form: true,
+	atom.Button: true, atom.Template: true, atom.Dialog: true,
+}
+
+// Extract returns the HTML of the article body in page, with relative URLs
+// resolved against pageURL (or the page's <base href>). The result is not
+// sanitized.
+func Extract(page []byte, pageURL string) (string, error) {
+	doc, err := html.Parse(bytes.NewReader(page))
+	if err != nil {
+		return "", fmt.Errorf("parse page: %w", err)
+	}
+
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return "", fmt.Errorf("parse page URL: %w", err)
+	}
+	if href := baseHref(doc); href != "" {
+		if b, err := base.Parse(href); err == nil {
+			base = b
+		}
+	}
+
+	body := findElement(doc, atom.Body)
+	if body == nil {
+		return "", ErrNoArticle
+	}
+	clean(body)
+
+	best := bestCandidate(body)
+	if best == nil || len(textContent(renderChildren(best))) < minArticleChars {
+		return "", ErrNoArticle
+	}
+
+	resolveURLs(best, base)
+	return strings.TrimSpace(renderChildren(best)), nil
+}
+
+// bestCandidate picks the element holding the article. A page with a single
+// <article> element uses it; otherwise containers are scored by the prose in
+// their paragraphs.
+func bestCandidate(body *html.Node) *html.Node {
+	var articles []*html.Node
+	walk(body, func(n *html.Node) {
+		if n.DataAtom == atom.Article {
+			articles = append(articles, n)
+		}
+	})
+	if len(articles) == 1 && len(textContent(renderChildren(articles[0]))) >= minArticleChars {
+		return articles[0]
+	}
+
+	scores := make(map[*html.Node]float64)
+	walk(body, func(n *html.Node) {
+		if n.DataAtom != atom.P && n.DataAtom != atom.Pre && n.DataAtom != atom.Blockquote {
+			return
+		}
+		text := strings.TrimSpace(nodeText(n))
+		if len(text) < 25 {
+			return
+		}
+		score := 1 + float64(strings.Count(text, ",")) + min(float64(len(text))/100, 3)
+
+		parent := n.Parent
+		if parent == nil {
+			return
+		}
+		addScore(scores, parent, score)
+		if grand := parent.Parent; grand != nil && grand.Type == html.ElementNode {
+			addScore(scores, grand, score/2)
+		}
+	})
+
+	var best *html.Node
+	bestScore := 0.0
+	for n, score := range scores {
+		score *= 1 - linkDensity(n)
+		if score > bestScore {
+			best, bestScore = n, score
+		}
+	}
+	return best
+}
+
+// addScore adds to a candidate's score, seeding new candidates with a bonus
+// or penalty from their class and id
+func addScore(scores map[*html.Node]float64, n *html.Node, score float64) {
+	if _, ok := scores[n]; !ok {
+		scores[n] = classWeight(n)
+		switch n.DataAtom {
+		case atom.Article, atom.Main:
+			scores[n] += 10
+		case atom.Div, atom.Section:
+			scores[n] += 5
+		}
+	}
+	scores[n] += score
+}
+
+// classWeight scores an element's class and id
+func classWeight(n *html.Node) float64 {
+	weight := 0.0
+	for _, name := range []string{attr(n, "class"), attr(n, "id")} {
+		if name == "" {
+			continue
+		}
+		if unlikelyPattern.MatchString(name) && !likelyPattern.MatchString(name) {
+			weight -= 25
+		}
+		if likelyPattern.MatchString(name) {
+			weight += 25
+		}
+	}
+	return weight
+}
+
+// linkDensity is the fraction of an element's text inside links
+func linkDensity(n *html.Node) float64 {
+	total := len(nodeText(n))
+	if total == 0 {
+		return 0
+	}
+	linked := 0
+	walk(n, func(c *html.Node) {
+		if c.DataAtom == atom.A {
+			linked += len(nodeText(c))
+		}
+	})
+	return float64(linked) / float64(total)
+}
+
+// clean removes elements that never hold article text, and page furniture
+// recognisable by class or id
+func clean(n *html.Node) {
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+		switch {
+		case c.Type == html.CommentNode:
+			n.RemoveChild(c)
+		case c.Type == html.ElementNode && (removedElements[c.DataAtom] || isFurniture(c)):
+			n.RemoveChild(c)
+		default:
+			clean(c)
+		}
+		c = next
+	}
+}
+
+// isFurniture reports whether an element's class or id marks it as page
+// furniture rather than content. Structural elements are never removed this
+// way, since sites put "content" and "comments" classes on the same wrapper.
+func isFurniture(n *html.Node) bool {
+	switch n.DataAtom {
+	case atom.Body, atom.Article, atom.Main, atom.A:
+		return false
+	}
+	name := attr(n, "class") + " " + attr(n, "id")
+	return unlikelyPattern.MatchString(name) && !likelyPattern.MatchString(name)
+}
+
+// resolveURLs makes href and src attributes absolute
+func resolveURLs(n *html.Node, base *url.URL) {
+	walk(n, func(c *html.Node) {
+		for i, a := range c.Attr {
+			if a.Key != "href" && a.Key != "src" {
+				continue
+			}
+			if ref, err := url.Parse(strings.TrimSpace(a.Val)); err == nil {
+				c.Attr[i].Val = base.ResolveReference(ref).String()
+			}
+		}
+	})
+}
+
+// baseHref returns the href of the document's <base> element, if any
+func baseHref(doc *html.Node) string {
+	if b := findElement(doc, atom.Base); b != nil {
+		return attr(b, "href")
+	}
+	return ""
+}
+
+// findElement returns the first element of the given type
+func findElement(n *html.Node, a atom.Atom) *html.Node {
+	var found *html.Node
+	walk(n, func(c *html.Node) {
+		if found == nil && c.DataAtom == a {
+			found = c
+		}
+	})
+	return found
+}
+
+// walk calls fn for n and every element below it
+func walk(n *html.Node, fn func(*html.Node)) {
+	if n.Type == html.ElementNode {
+		fn(n)
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		walk(c, fn)
+	}
+}
+
+// attr returns an attribute's value, or ""
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// nodeText returns the text below a node
+func nodeText(n *html.Node) string {
+	var b strings.Builder
+	var collect func(*html.Node)
+	collect = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			collect(c)
+		}
+	}
+	collect(n)
+	return b.String()
+}
+
+// renderChildren renders the contents of an element
+func renderChildren(n *html.Node) string {
+	var b strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		_ = html.Render(&b, c)
+	}
+	return b.String()
+}
+
+// textContent returns the whitespace-collapsed text of an HTML fragment
+func textContent(fragment string) string {
+	nodes, err := html.ParseFragment(strings.NewReader(fragment), &html.Node{Type: html.ElementNode, Data: "div", DataAtom: atom.Div})
+	if err != nil {
+		return ""
+	}
+	var b strings.Builder
+	for _, n := range nodes {
+		b.WriteString(nodeText(n))
+		b.WriteByte(' ')
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// wordCount counts the words in an HTML fragment
+func wordCount(fragment string) int {
+	return len(strings.Fields(textContent(fragment)))
+}
@@ -0,0 +1,175 @@
+package extract
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/adewale/rogue_planet/pkg/crawler"
+)
+
+// prose returns n sentences of article text
+func prose(n int) string {
+	return strings.Repeat("The quick brown fox, having jumped over the lazy dog, rested in the shade. ", n)
+}
+
+var articlePage = `<!DOCTYPE html>
+<html><head><title>Post</title></head>
+<body>
+<header class="site-header"><nav><a href="/">Home</a> <a href="/about">About</a></nav></header>
+<div class="layout">
+  <div id="main-content" class="post-body">
+    <h1>A post</h1>
+    <p>` + prose(3) + `</p>
+    <p>See <a href="/related">this</a> and <img src="images/fig1.png" alt="Figure 1">.</p>
+    <p>` + prose(4) + `</p>
+    <script>track()</script>
+  </div>
+  <div class="sidebar">
+    <p>` + strings.Repeat("<a href=\"/x\">Link text that is long enough to count, really</a> ", 10) + `</p>
+  </div>
+  <div class="comments">
+    <p>First! ` + prose(1) + `</p>
+  </div>
+</div>
+<footer><p>Copyright, all rights reserved, and so on and so forth.</p></footer>
+</body></html>`
+
+func TestExtract(t *testing.T) {
+	t.Parallel()
+
+	got, err := Extract([]byte(articlePage), "https://blog.example.com/2024/post.html")
+	if err != nil {
+		t.Fatalf("Extract() error = %v", err)
+	}
+
+	for _, want := range []string{
+		"<h1>A post</h1>",
+		"The quick brown fox",
+		`href="https://blog.example.com/related"`,
+		`src="https://blog.example.com/2024/images/fig1.png"`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Extract() missing %q in:\n%s", want, got)
+		}
+	}
+	for _, notWant := range []string{"track()", "Home", "First!", "Copyright", "Link text"} {
+		if strings.Contains(got, notWant) {
+			t.Errorf("Extract() kept %q:\n%s", notWant, got)
+		}
+	}
+}
+
+func TestExtract_SingleArticleElement(t *testing.T) {
+	t.Parallel()
+	page := `<html><head><base href="https://cdn.example.com/"></head><body>
+<div class="wrapper"><article><p>` + prose(4) + `</p><p><img src="a.png"></p></article></div>
+<div><p>` + prose(6) + `</p></div>
+</body></html>`
+
+	got, err := Extract([]byte(page), "https://example.com/post")
+	if err != nil {
+		t.Fatalf("Extract() error = %v", err)
+	}
+	if !strings.Contains(got, `src="https://cdn.example.com/a.png"`) {
+		t.Errorf("Extract() did not use the <article> element or <base href>:\n%s", got)
+	}
+	if strings.Count(got, "quick brown fox") != 4 {
+		t.Errorf("Extract() = %s, want only the article's four sentences", got)
+	}
+}
+
+func TestExtract_NoArticle(t *testing.T) {
+	t.Parallel()
+	page := `<html><body><nav><a href="/">Home</a></nav><p>Short.</p></body></html>`
+	if _, err := Extract([]byte(page), "https://example.com/"); !errors.Is(err, ErrNoArticle) {
+		t.Errorf("Extract() error = %v, want ErrNoArticle", err)
+	}
+}
+
+func TestNeedsExtraction(t *testing.T) {
+	t.Parallel()
+	if !NeedsExtraction("<p>Just a teaser. Read more…</p>") {
+		t.Error("NeedsExtraction(teaser) = false")
+	}
+	if NeedsExtraction("<p>" + prose(20) + "</p>") {
+		t.Error("NeedsExtraction(full article) = true")
+	}
+}
+
+func TestArticle(t *testing.T) {
+	t.Parallel()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/robots.txt", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("User-agent: *\nDisallow: /members/\n"))
+	})
+	mux.HandleFunc("/post", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(articlePage))
+	})
+	mux.HandleFunc("/members/post", func(w http.ResponseWriter, r *http.Request) {
+		t.Error("fetched a page disallowed by robots.txt")
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	const feedURL = "https://blog.example.com/feed.xml"
+	var sanitizedFor string
+	e := New(crawler.NewForTesting().WithRobots(crawler.RobotsObey), func(f, content string) string {
+		sanitizedFor = f
+		return strings.ReplaceAll(content, "<h1>A post</h1>", "")
+	}, []string{feedURL}, nil)
+
+	if !e.Enabled(feedURL) || e.Enabled("https://other.example.com/feed") {
+		t.Error("Enabled() does not match the configured feeds")
+	}
+
+	got, err := e.Article(context.Background(), feedURL, server.URL+"/post")
+	if err != nil {
+		t.Fatalf("Article() error = %v", err)
+	}
+	if sanitizedFor != feedURL {
+		t.Errorf("sanitized with policy for %q, want %q", sanitizedFor, feedURL)
+	}
+	if strings.Contains(got, "<h1>") || !strings.Contains(got, "quick brown fox") {
+		t.Errorf("Article() = %s, want sanitized article text", got)
+	}
+
+	if _, err := e.Article(context.Background(), feedURL, server.URL+"/members/post"); !errors.Is(err, crawler.ErrDisallowedByRobots) {
+		t.Errorf("Article() error = %v, want ErrDisallowedByRobots", err)
+	}
+}
+
+func TestEnabled_NilExtractor(t *testing.T) {
+	t.Parallel()
+	var e *Extractor
+	if e.Enabled("https://example.com/feed") || e.ImagesEnabled("https://example.com/feed") {
+		t.Error("nil Extractor reports enabled")
+	}
+}
+
+func TestPreviewImage(t *testing.T) {
+	t.Parallel()
+	const pageURL = "https://blog.example.com/posts/1"
+	tests := []struct {
+		name, head, want string
+	}{
+		{"og:image", `<meta property="og:image" content="https://cdn.example.com/a.png">`, "https://cdn.example.com/a.png"},
+		{"relative", `<meta property="og:image" content="/img/a.png">`, "https://blog.example.com/img/a.png"},
+		{"base href", `<base href="https://static.example.com/"><meta property="og:image" content="a.png">`, "https://static.example.com/a.png"},
+		{"secure url preferred", `<meta property="og:image" content="http://a/1.png"><meta property="og:image:secure_url" content="https://a/1.png">`, "https://a/1.png"},
+		{"twitter fallback", `<meta name="twitter:image" content="https://cdn.example.com/t.png">`, "https://cdn.example.com/t.png"},
+		{"first og:image wins", `<meta property="og:image" content="https://a/1.png"><meta property="og:image" content="https://a/2.png">`, "https://a/1.png"},
+		{"unsafe scheme", `<meta property="og:image" content="javascript:alert(1)">`, ""},
+		{"none", `<title>No image</title>`, ""},
+	}
+	for _, tt := range tests {
+		page := []byte("<html><head>" + tt.head + "</head><body></body></html>")
+		if got := PreviewImage(page, pageURL); got != tt.want {
+			t.Errorf("%s: PreviewImage() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
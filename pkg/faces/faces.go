@@ -0,0 +1,170 @@
+// Package faces publishes the planet's author face images ("hackergotchis").
+//
+// Faces are image files an administrator keeps in a local directory and
+// names in [author] sections. Publishing copies each one into the generated
+// site scaled to one square size, so a page of faces is uniform however big
+// or oddly shaped the originals were. Raster images are cropped to their
+// centre square and written as PNG; SVG images are copied as they are, since
+// they scale themselves.
+package faces
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"  // Register GIF for Decode
+	_ "image/jpeg" // Register JPEG for Decode
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxPixels limits the size of source images, so a stray photograph or a
+// decompression bomb in the faces directory cannot exhaust memory
+const maxPixels = 50_000_000
+
+// Publish writes the face images called names in srcDir to dstDir, scaled to
+// size pixels square, and removes any other files from dstDir. It returns the
+// file name in dstDir of each face published. Faces that cannot be read or
+// decoded are left out and reported together in the error.
+func Publish(srcDir, dstDir string, names []string, size int) (map[string]string, error) {
+	if err := os.MkdirAll(dstDir, 0755); err != nil {
+		return nil, fmt.Errorf("create faces directory: %w", err)
+	}
+
+	published := make(map[string]string)
+	sources := make(map[string]string) // Published file name -> source name
+	var errs []error
+	for _, name := range names {
+		if _, done := published[name]; done {
+			continue
+		}
+		out := outputName(name)
+		if other, ok := sources[out]; ok {
+			errs = append(errs, fmt.Errorf("face %s: same published name as %s", name, other))
+			continue
+		}
+		if err := publish(filepath.Join(srcDir, name), filepath.Join(dstDir, out), size); err != nil {
+			errs = append(errs, fmt.Errorf("face %s: %w", name, err))
+			continue
+		}
+		published[name] = out
+		sources[out] = name
+	}
+
+	existing, err := os.ReadDir(dstDir)
+	if err != nil {
+		return published, fmt.Errorf("read faces directory: %w", err)
+	}
+	for _, entry := range existing {
+		if _, ok := sources[entry.Name()]; ok {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(dstDir, entry.Name())); err != nil {
+			errs = append(errs, fmt.Errorf("remove old face: %w", err))
+		}
+	}
+
+	return published, errors.Join(errs...)
+}
+
+// outputName is the name a face is published under: SVG keeps its name,
+// other images are re-encoded as PNG
+func outputName(name string) string {
+	ext := filepath.Ext(name)
+	if strings.EqualFold(ext, ".svg") {
+		return name
+	}
+	return strings.TrimSuffix(name, ext) + ".png"
+}
+
+// publish writes the scaled copy of the face at src to dst
+func publish(src, dst string, size int) error {
+	if strings.EqualFold(filepath.Ext(src), ".svg") {
+		data, err := os.ReadFile(src)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(dst, data, 0644)
+	}
+
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	cfg, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return fmt.Errorf("not a PNG, JPEG, GIF, or SVG image: %w", err)
+	}
+	if cfg.Width*cfg.Height > maxPixels {
+		return fmt.Errorf("image is too large (%dx%d)", cfg.Width, cfg.Height)
+	}
+	if _, err := f.Seek(0, 0); err != nil {
+		return err
+	}
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return fmt.Errorf("decode image: %w", err)
+	}
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(out, Scale(img, size)); err != nil {
+		out.Close()
+		return fmt.Errorf("encode image: %w", err)
+	}
+	return out.Close()
+}
+
+// Scale crops img to its centre square and resizes it to size pixels square.
+// Each pixel of a smaller image averages the source pixels it covers; a
+// larger one repeats the nearest source pixel.
+func Scale(img image.Image, size int) *image.RGBA {
+	b := img.Bounds()
+	side := min(b.Dx(), b.Dy())
+	x0 := b.Min.X + (b.Dx()-side)/2
+	y0 := b.Min.Y + (b.Dy()-side)/2
+
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	if side == 0 {
+		return dst
+	}
+	for y := range size {
+		sy0, sy1 := span(y, side, size)
+		for x := range size {
+			sx0, sx1 := span(x, side, size)
+			var r, g, bl, a, n uint64
+			for sy := sy0; sy < sy1; sy++ {
+				for sx := sx0; sx < sx1; sx++ {
+					cr, cg, cb, ca := img.At(x0+sx, y0+sy).RGBA()
+					r, g, bl, a = r+uint64(cr), g+uint64(cg), bl+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+			dst.SetRGBA(x, y, color.RGBA{
+				R: uint8(r / n >> 8),
+				G: uint8(g / n >> 8),
+				B: uint8(bl / n >> 8),
+				A: uint8(a / n >> 8),
+			})
+		}
+	}
+	return dst
+}
+
+// span returns the source pixels [start, end) covered by pixel i of an
+// image of size pixels scaled from side pixels, always at least one
+func span(i, side, size int) (int, int) {
+	start := i * side / size
+	end := (i + 1) * side / size
+	if end <= start {
+		end = start + 1
+	}
+	return start, end
+}
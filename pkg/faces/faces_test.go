@@ -0,0 +1,153 @@
+package faces
+
+import (
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeImage encodes img into dir/name as PNG or, for .jpg names, JPEG
+func writeImage(t *testing.T, dir, name string, img image.Image) {
+	t.Helper()
+	f, err := os.Create(filepath.Join(dir, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if strings.HasSuffix(name, ".jpg") {
+		err = jpeg.Encode(f, img, nil)
+	} else {
+		err = png.Encode(f, img)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// filled returns a w x h image of one colour
+func filled(w, h int, c color.RGBA) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			img.SetRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestScale(t *testing.T) {
+	t.Parallel()
+
+	red := color.RGBA{255, 0, 0, 255}
+	blue := color.RGBA{0, 0, 255, 255}
+
+	// A wide image with blue edges: cropping to the centre square drops them
+	wide := filled(30, 10, red)
+	for y := range 10 {
+		for x := range 10 {
+			wide.SetRGBA(x, y, blue)
+			wide.SetRGBA(29-x, y, blue)
+		}
+	}
+	tests := []struct {
+		name string
+		img  image.Image
+		size int
+		want color.RGBA
+	}{
+		{"downscale", filled(100, 100, red), 8, red},
+		{"upscale", filled(2, 2, blue), 16, blue},
+		{"centre crop", wide, 5, red},
+		{"offset bounds", filled(40, 40, blue).SubImage(image.Rect(10, 10, 30, 20)), 4, blue},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got := Scale(tt.img, tt.size)
+			if b := got.Bounds(); b.Dx() != tt.size || b.Dy() != tt.size {
+				t.Fatalf("Scale() bounds = %v, want %dx%d", b, tt.size, tt.size)
+			}
+			for _, p := range []image.Point{{0, 0}, {tt.size - 1, tt.size - 1}, {tt.size / 2, 0}} {
+				if c := got.RGBAAt(p.X, p.Y); c != tt.want {
+					t.Errorf("pixel %v = %v, want %v", p, c, tt.want)
+				}
+			}
+		})
+	}
+
+	// Averaging: a 2x2 checkerboard of black and white scales to grey
+	checks := filled(2, 2, color.RGBA{0, 0, 0, 255})
+	checks.SetRGBA(0, 0, color.RGBA{255, 255, 255, 255})
+	checks.SetRGBA(1, 1, color.RGBA{255, 255, 255, 255})
+	if c := Scale(checks, 1).RGBAAt(0, 0); c.R < 126 || c.R > 128 || c.A != 255 {
+		t.Errorf("Scale() of a checkerboard = %v, want mid grey", c)
+	}
+}
+
+func TestPublish(t *testing.T) {
+	t.Parallel()
+	src := t.TempDir()
+	dst := filepath.Join(t.TempDir(), "faces")
+
+	writeImage(t, src, "ada.png", filled(300, 200, color.RGBA{0, 128, 0, 255}))
+	writeImage(t, src, "john.jpg", filled(50, 80, color.RGBA{200, 200, 200, 255}))
+	svg := `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 1 1"/>`
+	if err := os.WriteFile(filepath.Join(src, "grace.svg"), []byte(svg), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "notes.png"), []byte("not an image"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dst, "old.png"), []byte("stale"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	published, err := Publish(src, dst, []string{"ada.png", "john.jpg", "grace.svg", "notes.png", "missing.gif", "ada.png"}, 48)
+	if err == nil || !strings.Contains(err.Error(), "face notes.png") || !strings.Contains(err.Error(), "face missing.gif") {
+		t.Errorf("Publish() error = %v, want errors for notes.png and missing.gif", err)
+	}
+	want := map[string]string{"ada.png": "ada.png", "john.jpg": "john.png", "grace.svg": "grace.svg"}
+	if len(published) != len(want) {
+		t.Errorf("Publish() = %v, want %v", published, want)
+	}
+	for name, out := range want {
+		if published[name] != out {
+			t.Errorf("Publish()[%q] = %q, want %q", name, published[name], out)
+		}
+	}
+
+	for _, name := range []string{"ada.png", "john.png"} {
+		f, err := os.Open(filepath.Join(dst, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg, err := png.DecodeConfig(f)
+		f.Close()
+		if err != nil {
+			t.Fatalf("%s is not a PNG: %v", name, err)
+		}
+		if cfg.Width != 48 || cfg.Height != 48 {
+			t.Errorf("%s is %dx%d, want 48x48", name, cfg.Width, cfg.Height)
+		}
+	}
+	if data, err := os.ReadFile(filepath.Join(dst, "grace.svg")); err != nil || string(data) != svg {
+		t.Errorf("grace.svg = %q, %v; want it copied unchanged", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "old.png")); !os.IsNotExist(err) {
+		t.Errorf("stale face was not removed: %v", err)
+	}
+
+	// Two faces that would publish under one name
+	writeImage(t, src, "ada.jpg", filled(10, 10, color.RGBA{0, 0, 0, 255}))
+	if _, err := Publish(src, dst, []string{"ada.png", "ada.jpg"}, 48); err == nil || !strings.Contains(err.Error(), "same published name") {
+		t.Errorf("Publish() with clashing names: error = %v", err)
+	}
+}
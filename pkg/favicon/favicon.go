@@ -0,0 +1,328 @@
+// Package favicon discovers, downloads, and caches the favicons of feed sites.
+//
+// Icons are found through the site's <link rel="icon"> tags, falling back to
+// /favicon.ico, and fetched through the crawler so the usual SSRF protections
+// apply. Only small raster images are kept (SVG can carry scripts), one file
+// per host, named after the host. Cached icons are refreshed weekly; sites
+// without a usable icon are retried daily.
+package favicon
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/html"
+
+	"github.com/adewale/rogue_planet/pkg/crawler"
+)
+
+const (
+	// MaxIconSize limits a downloaded icon to 100KB
+	MaxIconSize = 100 * 1024
+	// MaxPageSize limits the home page read to discover icon links to 1MB
+	MaxPageSize = 1024 * 1024
+	// RefreshAfter is how long a cached icon is used before it is downloaded again
+	RefreshAfter = 7 * 24 * time.Hour
+	// RetryAfter is how long to wait before trying again for a site with no usable icon
+	RetryAfter = 24 * time.Hour
+	// workers is the number of sites fetched concurrently by Icons unless
+	// SetWorkers says otherwise
+	workers = 4
+)
+
+// missingExt marks a site that had no usable icon when last checked
+const missingExt = ".missing"
+
+// ErrNoIcon is returned when none of a site's candidate icons could be used
+var ErrNoIcon = errors.New("no usable favicon")
+
+// iconTypes maps sniffed content types of accepted icons to file extensions
+var iconTypes = map[string]string{
+	"image/x-icon": ".ico",
+	"image/png":    ".png",
+	"image/gif":    ".gif",
+	"image/jpeg":   ".jpg",
+	"image/webp":   ".webp",
+	"image/bmp":    ".bmp",
+}
+
+// cacheExts lists every extension a cached file can have
+var cacheExts = []string{".ico", ".png", ".gif", ".jpg", ".webp", ".bmp", missingExt}
+
+// Cache downloads favicons into a directory and remembers them between runs
+type Cache struct {
+	dir     string
+	pages   *crawler.Crawler
+	icons   *crawler.Crawler
+	now     func() time.Time
+	workers int
+}
+
+// New creates a Cache that stores icons in dir, fetching them with c
+func New(c *crawler.Crawler, dir string) *Cache {
+	return &Cache{
+		dir:     dir,
+		pages:   c.WithMaxSize(MaxPageSize),
+		icons:   c.WithMaxSize(MaxIconSize),
+		now:     time.Now,
+		workers: workers,
+	}
+}
+
+// SetWorkers sets how many sites Icons fetches at once
+func (c *Cache) SetWorkers(n int) {
+	c.workers = max(n, 1)
+}
+
+// Icons returns the cached icon file name (relative to the cache directory)
+// for each site URL that has one. Sites on the same host share an icon.
+// Failures only mean a site has no icon, so they are not reported.
+func (c *Cache) Icons(ctx context.Context, siteURLs []string) map[string]string {
+	byHost := make(map[string][]string)
+	for _, s := range siteURLs {
+		if u, err := url.Parse(s); err == nil && u.Host != "" {
+			byHost[u.Host] = append(byHost[u.Host], s)
+		}
+	}
+
+	result := make(map[string]string)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, c.workers)
+
+	for _, sites := range byHost {
+		wg.Add(1)
+		go func(sites []string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			name, err := c.Icon(ctx, sites[0])
+			if err != nil || name == "" {
+				return
+			}
+			mu.Lock()
+			for _, s := range sites {
+				result[s] = name
+			}
+			mu.Unlock()
+		}(sites)
+	}
+	wg.Wait()
+	return result
+}
+
+// Icon returns the file name of siteURL's cached icon, downloading it if it
+// is missing or stale. It returns "" with a nil error while a site known to
+// have no icon is waiting to be retried.
+func (c *Cache) Icon(ctx context.Context, siteURL string) (string, error) {
+	site, err := url.Parse(siteURL)
+	if err != nil || (site.Scheme != "http" && site.Scheme != "https") || site.Host == "" {
+		return "", fmt.Errorf("%w: %q", crawler.ErrInvalidURL, siteURL)
+	}
+	key := cacheKey(site.Host)
+
+	cached, fresh := c.lookup(key)
+	if fresh {
+		return cached, nil
+	}
+
+	name, err := c.download(ctx, site, key)
+	if err != nil {
+		if ctx.Err() != nil {
+			return cached, ctx.Err()
+		}
+		if cached != "" {
+			// Keep serving the old icon; try again after another RefreshAfter
+			now := c.now()
+			_ = os.Chtimes(filepath.Join(c.dir, cached), now, now)
+			return cached, nil
+		}
+		c.markMissing(key)
+		return "", err
+	}
+	return name, nil
+}
+
+// lookup finds the cached file for key. fresh reports whether it (or a
+// missing-icon marker) is recent enough to skip downloading.
+func (c *Cache) lookup(key string) (name string, fresh bool) {
+	for _, m := range c.files(key) {
+		info, err := os.Stat(m)
+		if err != nil {
+			continue
+		}
+		age := c.now().Sub(info.ModTime())
+		if filepath.Ext(m) == missingExt {
+			if age < RetryAfter {
+				return "", true
+			}
+			continue
+		}
+		return filepath.Base(m), age < RefreshAfter
+	}
+	return "", false
+}
+
+// download tries each candidate icon for site and stores the first usable one
+func (c *Cache) download(ctx context.Context, site *url.URL, key string) (string, error) {
+	for _, candidate := range c.candidates(ctx, site) {
+		resp, err := c.icons.Fetch(ctx, candidate, crawler.FeedCache{})
+		if err != nil {
+			if ctx.Err() != nil {
+				return "", ctx.Err()
+			}
+			continue
+		}
+		ext, ok := iconTypes[http.DetectContentType(resp.Body)]
+		if !ok || len(resp.Body) == 0 {
+			continue
+		}
+		name := key + ext
+		if err := c.store(key, name, resp.Body); err != nil {
+			return "", err
+		}
+		return name, nil
+	}
+	return "", fmt.Errorf("%w for %s", ErrNoIcon, site.Host)
+}
+
+// candidates lists icon URLs for site: those declared in its home page's
+// <link rel="icon"> tags, then /favicon.ico
+func (c *Cache) candidates(ctx context.Context, site *url.URL) []string {
+	var urls []string
+	if resp, err := c.pages.Fetch(ctx, site.String(), crawler.FeedCache{}); err == nil {
+		base := site
+		if final, err := url.Parse(resp.FinalURL); err == nil && resp.FinalURL != "" {
+			base = final
+		}
+		for _, href := range iconLinks(resp.Body) {
+			if ref, err := url.Parse(href); err == nil {
+				urls = append(urls, base.ResolveReference(ref).String())
+			}
+		}
+	}
+	urls = append(urls, site.ResolveReference(&url.URL{Path: "/favicon.ico"}).String())
+	return urls
+}
+
+// iconLinks returns the hrefs of icon <link> elements in an HTML page's head
+func iconLinks(page []byte) []string {
+	var hrefs []string
+	z := html.NewTokenizer(bytes.NewReader(page))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return hrefs
+		case html.StartTagToken, html.SelfClosingTagToken:
+			tok := z.Token()
+			if tok.Data == "body" {
+				return hrefs
+			}
+			if tok.Data != "link" {
+				continue
+			}
+			var rel, href string
+			for _, a := range tok.Attr {
+				switch a.Key {
+				case "rel":
+					rel = strings.ToLower(a.Val)
+				case "href":
+					href = strings.TrimSpace(a.Val)
+				}
+			}
+			// Matches "icon", "shortcut icon", and "apple-touch-icon"
+			if href != "" && strings.Contains(rel, "icon") && !strings.Contains(rel, "mask-icon") {
+				hrefs = append(hrefs, href)
+			}
+		case html.EndTagToken:
+			if name, _ := z.TagName(); string(name) == "head" {
+				return hrefs
+			}
+		}
+	}
+}
+
+// store writes an icon atomically and removes other files cached for key
+func (c *Cache) store(key, name string, data []byte) error {
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return fmt.Errorf("create favicon directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(c.dir, ".icon-*")
+	if err != nil {
+		return fmt.Errorf("create favicon: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("write favicon: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("write favicon: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("write favicon: %w", err)
+	}
+
+	c.removeOthers(key, name)
+	if err := os.Rename(tmp.Name(), filepath.Join(c.dir, name)); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("store favicon: %w", err)
+	}
+	return nil
+}
+
+// markMissing records that key's site has no usable icon
+func (c *Cache) markMissing(key string) {
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return
+	}
+	c.removeOthers(key, "")
+	_ = os.WriteFile(filepath.Join(c.dir, key+missingExt), nil, 0644)
+}
+
+// removeOthers deletes files cached for key other than keep
+func (c *Cache) removeOthers(key, keep string) {
+	for _, m := range c.files(key) {
+		if filepath.Base(m) != keep {
+			os.Remove(m)
+		}
+	}
+}
+
+// files returns the paths of the files cached for key. Extensions are
+// matched exactly, so example.com never picks up example.com.au's icon.
+func (c *Cache) files(key string) []string {
+	var paths []string
+	for _, ext := range cacheExts {
+		path := filepath.Join(c.dir, key+ext)
+		if _, err := os.Stat(path); err == nil {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// cacheKey turns a host (with optional port) into a safe file name stem
+func cacheKey(host string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '.', r == '-':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + ('a' - 'A')
+		}
+		return '_'
+	}, host)
+}
@@ -0,0 +1,189 @@
+package favicon
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/adewale/rogue_planet/pkg/crawler"
+)
+
+var (
+	pngIcon = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	icoIcon = []byte("\x00\x00\x01\x00\x01\x00\x10\x10")
+)
+
+// newTestCache starts a server for handler and returns a Cache and the server URL
+func newTestCache(t *testing.T, handler http.Handler) (*Cache, string) {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return New(crawler.NewForTesting(), t.TempDir()), server.URL
+}
+
+func TestIconDiscoversLinkTag(t *testing.T) {
+	t.Parallel()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/blog/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<html><head>
+<link rel="mask-icon" href="/mask.svg">
+<link rel="shortcut icon" href="../img/icon.png">
+</head><body><link rel="icon" href="/ignored.png"></body></html>`))
+	})
+	mux.HandleFunc("/img/icon.png", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(pngIcon)
+	})
+	cache, site := newTestCache(t, mux)
+
+	name, err := cache.Icon(context.Background(), site+"/blog/")
+	if err != nil {
+		t.Fatalf("Icon() error = %v", err)
+	}
+	if filepath.Ext(name) != ".png" {
+		t.Errorf("Icon() = %q, want a .png file", name)
+	}
+	data, err := os.ReadFile(filepath.Join(cache.dir, name))
+	if err != nil || string(data) != string(pngIcon) {
+		t.Errorf("cached icon = %q, %v", data, err)
+	}
+}
+
+func TestIconFallsBackToFaviconICO(t *testing.T) {
+	t.Parallel()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<html><head><link rel="icon" href="/logo.svg"></head></html>`))
+	})
+	mux.HandleFunc("/logo.svg", func(w http.ResponseWriter, r *http.Request) {
+		// SVG can carry scripts, so it is never cached
+		w.Write([]byte(`<svg xmlns="http://www.w3.org/2000/svg"><script>alert(1)</script></svg>`))
+	})
+	mux.HandleFunc("/favicon.ico", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(icoIcon)
+	})
+	cache, site := newTestCache(t, mux)
+
+	name, err := cache.Icon(context.Background(), site)
+	if err != nil {
+		t.Fatalf("Icon() error = %v", err)
+	}
+	if filepath.Ext(name) != ".ico" {
+		t.Errorf("Icon() = %q, want the .ico fallback", name)
+	}
+}
+
+func TestIconMissingIsRetriedLater(t *testing.T) {
+	t.Parallel()
+	var requests atomic.Int32
+	cache, site := newTestCache(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		http.NotFound(w, r)
+	}))
+	ctx := context.Background()
+
+	if _, err := cache.Icon(ctx, site); !errors.Is(err, ErrNoIcon) {
+		t.Fatalf("Icon() error = %v, want ErrNoIcon", err)
+	}
+	first := requests.Load()
+
+	// Within RetryAfter the site is not contacted again
+	name, err := cache.Icon(ctx, site)
+	if err != nil || name != "" || requests.Load() != first {
+		t.Errorf("second Icon() = %q, %v after %d requests, want no new requests", name, err, requests.Load()-first)
+	}
+
+	// After RetryAfter it is
+	cache.now = func() time.Time { return time.Now().Add(RetryAfter + time.Hour) }
+	cache.Icon(ctx, site)
+	if requests.Load() == first {
+		t.Error("Icon() should retry after RetryAfter")
+	}
+}
+
+func TestIconRefresh(t *testing.T) {
+	t.Parallel()
+	var serve atomic.Value
+	serve.Store(pngIcon)
+	cache, site := newTestCache(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/favicon.ico" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(serve.Load().([]byte))
+	}))
+	ctx := context.Background()
+
+	first, err := cache.Icon(ctx, site)
+	if err != nil {
+		t.Fatalf("Icon() error = %v", err)
+	}
+
+	// A stale icon whose refresh fails is kept
+	serve.Store([]byte("not an image"))
+	cache.now = func() time.Time { return time.Now().Add(RefreshAfter + time.Hour) }
+	if name, err := cache.Icon(ctx, site); err != nil || name != first {
+		t.Errorf("Icon() with failed refresh = %q, %v, want old %q", name, err, first)
+	}
+
+	// A successful refresh replaces it, even with a different type
+	serve.Store(icoIcon)
+	cache.now = func() time.Time { return time.Now().Add(3 * RefreshAfter) }
+	name, err := cache.Icon(ctx, site)
+	if err != nil || filepath.Ext(name) != ".ico" {
+		t.Fatalf("refreshed Icon() = %q, %v, want .ico", name, err)
+	}
+	if _, err := os.Stat(filepath.Join(cache.dir, first)); !os.IsNotExist(err) {
+		t.Errorf("old icon %s should be removed, stat error = %v", first, err)
+	}
+}
+
+func TestIconsSharesHosts(t *testing.T) {
+	t.Parallel()
+	var requests atomic.Int32
+	cache, site := newTestCache(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/favicon.ico" {
+			requests.Add(1)
+			w.Write(icoIcon)
+			return
+		}
+		http.NotFound(w, r)
+	}))
+
+	icons := cache.Icons(context.Background(), []string{site + "/a/", site + "/b/", "not a url"})
+	if len(icons) != 2 || icons[site+"/a/"] == "" || icons[site+"/a/"] != icons[site+"/b/"] {
+		t.Errorf("Icons() = %v, want one shared icon for both sites", icons)
+	}
+	if requests.Load() != 1 {
+		t.Errorf("favicon requested %d times, want 1", requests.Load())
+	}
+}
+
+func TestIconRejectsUnsafeURLs(t *testing.T) {
+	t.Parallel()
+	cache := New(crawler.New(), t.TempDir())
+	for _, site := range []string{"ftp://example.com/", "", "http://127.0.0.1/"} {
+		if name, err := cache.Icon(context.Background(), site); err == nil || name != "" {
+			t.Errorf("Icon(%q) = %q, %v, want an error", site, name, err)
+		}
+	}
+}
+
+func TestCacheKey(t *testing.T) {
+	t.Parallel()
+	tests := map[string]string{
+		"Example.COM":      "example.com",
+		"example.com:8080": "example.com_8080",
+		"[::1]:80":         "___1__80",
+	}
+	for host, want := range tests {
+		if got := cacheKey(host); got != want {
+			t.Errorf("cacheKey(%q) = %q, want %q", host, got, want)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+// Package feedurl spells feed URLs consistently and recognises URLs of the
+// same feed.
+//
+// The same feed is often subscribed to more than once: over http and https,
+// with and without www., with or without a trailing slash or tracking
+// parameters, or through a proxy such as FeedBurner as well as directly.
+// Normalize gives a URL its standard spelling, Key reduces it to what
+// identifies the feed so that other spellings compare equal, and SameFeed
+// also recognises a proxied feed by the site it links to.
+package feedurl
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// trackingParams are query parameters added to links for analytics, which
+// never change what a feed serves
+var trackingParams = map[string]bool{
+	"fbclid":  true,
+	"gclid":   true,
+	"dclid":   true,
+	"msclkid": true,
+	"yclid":   true,
+	"igshid":  true,
+	"mc_cid":  true,
+	"mc_eid":  true,
+	"_hsenc":  true,
+	"_hsmi":   true,
+	"mkt_tok": true,
+}
+
+// isTrackingParam reports whether a query parameter is only for analytics
+func isTrackingParam(name string) bool {
+	name = strings.ToLower(name)
+	return strings.HasPrefix(name, "utm_") || trackingParams[name]
+}
+
+// proxyDomains are services that republish feeds from elsewhere under
+// their own URLs
+var proxyDomains = []string{
+	"feedburner.com",
+	"feedproxy.google.com",
+	"feedpress.me",
+	"feedblitz.com",
+}
+
+// defaultPorts are the ports URLs may name without changing what they refer to
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+// Normalize returns rawURL in its standard spelling: lower-case scheme and
+// host, no default port, no fragment or tracking parameters, and "/" for an
+// empty path. A trailing slash is kept, since a server may treat /feed and
+// /feed/ differently; Key ignores it instead.
+func Normalize(rawURL string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return "", fmt.Errorf("invalid URL %q: %w", rawURL, err)
+	}
+	if u.Host == "" {
+		return u.String(), nil
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	host := strings.ToLower(u.Hostname())
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]" // IPv6
+	}
+	if port := u.Port(); port != "" && port != defaultPorts[u.Scheme] {
+		host += ":" + port
+	}
+	u.Host = host
+	if u.Path == "" && u.RawPath == "" {
+		u.Path = "/"
+	}
+	u.Fragment, u.RawFragment = "", ""
+	u.RawQuery = withoutTracking(u.RawQuery)
+	u.ForceQuery = false
+	return u.String(), nil
+}
+
+// withoutTracking removes the tracking parameters from a raw query, keeping
+// the others as they were written
+func withoutTracking(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+	var kept []string
+	for _, param := range strings.Split(rawQuery, "&") {
+		name, _, _ := strings.Cut(param, "=")
+		if unescaped, err := url.QueryUnescape(name); err == nil {
+			name = unescaped
+		}
+		if param != "" && !isTrackingParam(name) {
+			kept = append(kept, param)
+		}
+	}
+	return strings.Join(kept, "&")
+}
+
+// Key returns a comparison key for a feed URL. URLs with the same key differ
+// only in scheme (http or https), host case, a www. prefix, an explicit
+// default port, a trailing slash, tracking parameters, the order of the other
+// query parameters, or a fragment, and so almost certainly serve the same
+// feed. A URL that does not parse is its own key.
+func Key(raw string) string {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.Host == "" {
+		return raw
+	}
+	scheme := strings.ToLower(u.Scheme)
+
+	host := strings.ToLower(u.Hostname())
+	host = strings.TrimSuffix(host, ".")
+	host = strings.TrimPrefix(host, "www.")
+	if port := u.Port(); port != "" && port != defaultPorts[scheme] {
+		host += ":" + port
+	}
+
+	path := strings.TrimRight(u.EscapedPath(), "/")
+	key := host + path
+	if query := withoutTracking(u.RawQuery); query != "" {
+		// Encode sorts the parameters by name
+		params, _ := url.ParseQuery(query)
+		key += "?" + params.Encode()
+	}
+	return key
+}
+
+// IsProxy reports whether rawURL is on a service, such as FeedBurner, that
+// republishes feeds from elsewhere
+func IsProxy(rawURL string) bool {
+	host := Host(rawURL)
+	for _, domain := range proxyDomains {
+		if InDomain(host, domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// SameFeed reports whether two feeds, each given by its URL and the link to
+// the site it belongs to, are probably the same feed: their URLs have the
+// same key, or one is on a feed proxy and both link to the same site. A
+// site's other feeds, such as its comments, link to it too, so links are
+// only compared for proxies.
+func SameFeed(urlA, linkA, urlB, linkB string) bool {
+	if Key(urlA) == Key(urlB) {
+		return true
+	}
+	if linkA == "" || linkB == "" || IsProxy(urlA) == IsProxy(urlB) {
+		return false
+	}
+	return Key(linkA) == Key(linkB)
+}
+
+// Host returns the URL's host name, lower case and without a www. prefix,
+// or "" if the URL does not parse
+func Host(raw string) string {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(strings.TrimSuffix(strings.ToLower(u.Hostname()), "."), "www.")
+}
+
+// InDomain reports whether host is domain or one of its subdomains
+func InDomain(host, domain string) bool {
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
@@ -0,0 +1,116 @@
+package feedurl
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		in, want string
+	}{
+		{"HTTPS://Example.COM/Feed.xml", "https://example.com/Feed.xml"},
+		{"http://example.com:80/feed", "http://example.com/feed"},
+		{"https://example.com:443/feed/", "https://example.com/feed/"},
+		{"https://example.com:8443/feed", "https://example.com:8443/feed"},
+		{"https://example.com", "https://example.com/"},
+		{"  https://example.com/feed#latest ", "https://example.com/feed"},
+		{"https://example.com/feed?utm_source=twitter&lang=en&fbclid=abc&UTM_Medium=x", "https://example.com/feed?lang=en"},
+		{"https://example.com/feed?utm_campaign=launch", "https://example.com/feed"},
+		{"https://example.com/feed?b=2&a=1", "https://example.com/feed?b=2&a=1"},
+		{"https://[2001:DB8::1]:443/feed", "https://[2001:db8::1]/feed"},
+		{"https://example.com/a%2Fb", "https://example.com/a%2Fb"},
+	}
+	for _, tt := range tests {
+		got, err := Normalize(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("Normalize(%q) = %q, %v; want %q", tt.in, got, err, tt.want)
+		}
+	}
+
+	if _, err := Normalize("https://exa mple.com/%zz"); err == nil {
+		t.Error("Normalize() of an invalid URL succeeded")
+	}
+}
+
+func TestKey(t *testing.T) {
+	t.Parallel()
+
+	same := [][]string{
+		{"http://example.com/feed", "https://example.com/feed/", "https://www.Example.com/feed", "http://example.com:80/feed#top"},
+		{"https://blog.example.org", "https://blog.example.org/", "https://blog.example.org:443"},
+		{"https://example.com/rss?b=2&a=1", "http://example.com/rss/?a=1&b=2&utm_source=feedly"},
+	}
+	for _, group := range same {
+		want := Key(group[0])
+		for _, u := range group[1:] {
+			if got := Key(u); got != want {
+				t.Errorf("Key(%q) = %q, want %q as for %q", u, got, want, group[0])
+			}
+		}
+	}
+
+	different := [][2]string{
+		{"https://example.com/feed", "https://example.com/atom"},
+		{"https://example.com/feed", "https://example.org/feed"},
+		{"https://example.com/feed", "https://blog.example.com/feed"},
+		{"https://example.com:8080/feed", "https://example.com/feed"},
+		{"https://example.com/feed?lang=en", "https://example.com/feed?lang=fr"},
+		{"https://example.com/Feed", "https://example.com/feed"},
+	}
+	for _, pair := range different {
+		if Key(pair[0]) == Key(pair[1]) {
+			t.Errorf("Key(%q) = Key(%q) = %q, want them to differ", pair[0], pair[1], Key(pair[0]))
+		}
+	}
+
+	if got := Key("not a url"); got != "not a url" {
+		t.Errorf("Key() of a non-URL = %q, want it unchanged", got)
+	}
+}
+
+func TestHostAndInDomain(t *testing.T) {
+	t.Parallel()
+
+	if got := Host("https://WWW.Example.com.:8443/feed"); got != "example.com" {
+		t.Errorf("Host() = %q, want example.com", got)
+	}
+	tests := []struct {
+		host, domain string
+		want         bool
+	}{
+		{"sedoparking.com", "sedoparking.com", true},
+		{"ww38.sedoparking.com", "sedoparking.com", true},
+		{"notsedoparking.com", "sedoparking.com", false},
+		{"sedoparking.com.example.org", "sedoparking.com", false},
+	}
+	for _, tt := range tests {
+		if got := InDomain(tt.host, tt.domain); got != tt.want {
+			t.Errorf("InDomain(%q, %q) = %v, want %v", tt.host, tt.domain, got, tt.want)
+		}
+	}
+}
+
+func TestSameFeed(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name                     string
+		urlA, linkA, urlB, linkB string
+		want                     bool
+	}{
+		{"same URL respelled", "http://example.com/feed", "", "https://www.example.com/feed/", "", true},
+		{"FeedBurner and origin", "https://feeds.feedburner.com/ExampleBlog", "https://example.com/", "https://example.com/feed.xml", "http://www.example.com", true},
+		{"FeedBurner without a link yet", "https://feeds.feedburner.com/ExampleBlog", "", "https://example.com/feed.xml", "https://example.com/", false},
+		{"posts and comments", "https://example.com/feed", "https://example.com/", "https://example.com/comments/feed", "https://example.com/", false},
+		{"two proxies of different sites", "https://feeds.feedburner.com/A", "https://a.example.com/", "https://feedpress.me/b", "https://b.example.com/", false},
+		{"different feeds", "https://example.com/feed", "", "https://example.org/feed", "", false},
+	}
+	for _, tt := range tests {
+		if got := SameFeed(tt.urlA, tt.linkA, tt.urlB, tt.linkB); got != tt.want {
+			t.Errorf("%s: SameFeed() = %v, want %v", tt.name, got, tt.want)
+		}
+		if got := SameFeed(tt.urlB, tt.linkB, tt.urlA, tt.linkA); got != tt.want {
+			t.Errorf("%s: SameFeed() reversed = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -5,28 +5,63 @@ package fetcher
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/adewale/rogue_planet/pkg/crawler"
-	"github.com/adewale/rogue_planet/pkg/logging"
+	"github.com/adewale/rogue_planet/pkg/extract"
+	"github.com/adewale/rogue_planet/pkg/filter"
+	"github.com/adewale/rogue_planet/pkg/hooks"
 	"github.com/adewale/rogue_planet/pkg/normalizer"
 	"github.com/adewale/rogue_planet/pkg/repository"
+	"github.com/adewale/rogue_planet/pkg/scheduler"
+)
+
+const (
+	// MaxHostBackoff caps how long a rate-limiting host is left alone
+	MaxHostBackoff = 24 * time.Hour
+	// hostBackoffBase is how long a host that answers 429 or 503 without a
+	// Retry-After is left alone the first time; it doubles each further time
+	hostBackoffBase = 15 * time.Minute
+	// MaxErrorBackoff caps how long a failing feed waits between attempts
+	MaxErrorBackoff = 24 * time.Hour
+	// errorBackoffBase is how long a feed waits after its first error; the
+	// wait doubles with each further consecutive error
+	errorBackoffBase = 30 * time.Minute
+	// GoneAfterHostNotFound is how many fetches in a row must find the feed's
+	// host missing from DNS (NXDOMAIN) before the feed is treated as gone
+	GoneAfterHostNotFound = 5
 )
 
 // Fetcher handles the business logic for fetching and processing a single feed.
 // It coordinates between the crawler (HTTP fetching), normalizer (parsing),
 // and repository (storage) components.
 //
-// The repoMutex protects concurrent database access. HTTP fetching and feed
-// parsing operations run concurrently without locks for maximum performance.
+// Run fetches many feeds through a worker pool with a single database
+// writer. Callers of FetchFeed from several goroutines instead share a
+// repoMutex, which protects database access; HTTP fetching and feed parsing
+// run concurrently without locks.
 type Fetcher struct {
-	crawler    crawler.FeedCrawler
-	normalizer normalizer.FeedNormalizer
-	repo       repository.FeedRepository
-	repoMutex  sync.Locker // Protects repository operations only
-	logger     logging.Logger
-	maxRetries int
+	crawler         crawler.FeedCrawler
+	normalizer      normalizer.FeedNormalizer
+	repo            repository.FeedRepository
+	repoMutex       sync.Locker // Protects repository operations only
+	logger          *slog.Logger
+	maxRetries      int
+	force           bool                 // Fetch even when the feed is fresh or not yet due
+	scheduler       *scheduler.Scheduler // Adaptive scheduling; nil fetches every feed every run
+	filters         *filter.Set          // Entry filters applied before storage; nil stores everything
+	extractor       *extract.Extractor   // Full-content extraction for summary-only feeds; nil disables it
+	entryHooks      []EntryHook          // Run in turn on parsed entries
+	deactivateAfter int                  // Consecutive errors before a feed is deactivated; 0 never deactivates
+
+	sanitize func(feedURL, html string) string // Cleans entry HTML changed by hooks
 }
 
 // New creates a new Fetcher with the provided dependencies
@@ -39,7 +74,7 @@ func New(
 	n normalizer.FeedNormalizer,
 	r repository.FeedRepository,
 	repoMutex sync.Locker,
-	logger logging.Logger,
+	logger *slog.Logger,
 	maxRetries int,
 ) *Fetcher {
 	return &Fetcher{
@@ -52,118 +87,558 @@ func New(
 	}
 }
 
+// SetForce makes FetchFeed contact every feed, ignoring cache lifetimes
+// advertised by Cache-Control max-age or Expires and adaptive schedules
+func (f *Fetcher) SetForce(force bool) {
+	f.force = force
+}
+
+// SetScheduler enables adaptive scheduling: after each successful fetch the
+// feed's next due time is computed from its posting cadence, and feeds are
+// skipped until they are due
+func (f *Fetcher) SetScheduler(s *scheduler.Scheduler) {
+	f.scheduler = s
+}
+
+// SetFilters drops entries rejected by s before they are stored
+func (f *Fetcher) SetFilters(s *filter.Set) {
+	f.filters = s
+}
+
+// SetExtractor replaces the summaries of new entries from the feeds e is
+// enabled for with the article text from their pages
+func (f *Fetcher) SetExtractor(e *extract.Extractor) {
+	f.extractor = e
+}
+
+// EntryHook changes or drops entries parsed from a feed. Both post_entry
+// command hooks and WebAssembly plugins are entry hooks.
+type EntryHook interface {
+	Entry(ctx context.Context, feed hooks.Feed, entry hooks.Entry) (_ hooks.Entry, keep bool, _ error)
+}
+
+// SetEntryHooks runs entryHooks in turn on each entry parsed from a feed,
+// before it is stored; they may change or drop it. Content and summaries
+// they change are cleaned again with sanitize.
+func (f *Fetcher) SetEntryHooks(sanitize func(feedURL, html string) string, entryHooks ...EntryHook) {
+	f.entryHooks = entryHooks
+	f.sanitize = sanitize
+}
+
+// SetDeactivateAfter deactivates feeds once they have failed n times in a
+// row. Zero (the default) keeps failing feeds active, backing off only.
+func (f *Fetcher) SetDeactivateAfter(n int) {
+	f.deactivateAfter = n
+}
+
+// SkipReason explains why a feed should not be fetched now, or returns ""
+// if it should be. Feeds are skipped while their HTTP cache lifetime has not
+// expired, while backing off after errors, or, with adaptive scheduling,
+// until they are due. Always "" when SetForce(true) is in effect.
+func (f *Fetcher) SkipReason(feed repository.Feed) string {
+	if f.force {
+		return ""
+	}
+	now := time.Now()
+	if feed.CacheExpires.After(now) {
+		return "cached until " + feed.CacheExpires.Local().Format("2006-01-02 15:04")
+	}
+	if feed.FetchErrorCount > 0 && feed.NextFetch.After(now) {
+		return fmt.Sprintf("%d consecutive errors, retrying after %s", feed.FetchErrorCount, feed.NextFetch.Local().Format("2006-01-02 15:04"))
+	}
+	if f.scheduler != nil && feed.NextFetch.After(now) {
+		return "not due until " + feed.NextFetch.Local().Format("2006-01-02 15:04")
+	}
+	return ""
+}
+
 // FetchResult contains the result of a feed fetch operation
 type FetchResult struct {
 	StoredEntries int
 	NotModified   bool
+	Skipped       bool   // Not fetched: HTTP cache still fresh, feed not yet due, or host backing off
+	SkipReason    string // Why the feed was skipped
+	Filtered      int    // Entries dropped by entry filters
 	Error         error
+
+	FetchDuration time.Duration // Time spent on the HTTP fetch, including retries
+	WireBytes     int64         // Body bytes received, before decoding
 }
 
-// FetchFeed fetches and processes a single feed.
-// This method contains all the business logic for:
-// - Fetching the feed with retries (NO LOCK - runs concurrently)
-// - Handling redirects (301) (WITH LOCK - database write)
-// - Handling cached responses (304) (WITH LOCK - database write)
-// - Parsing and normalizing feed content (NO LOCK - runs concurrently)
-// - Storing feed metadata and entries (WITH LOCK - database writes)
-//
-// HTTP fetching and feed parsing run without locks for maximum concurrency.
-// Only database operations are protected by the mutex.
+// FetchFeed fetches and processes a single feed, running the same stages as
+// Run one after another:
+//   - fetch: skip checks and the HTTP fetch with retries (NO LOCK - concurrent HTTP)
+//   - parse: parsing, filtering, and article extraction (NO LOCK - concurrent parsing)
+//   - store: redirects (301), cached responses (304), errors, feed metadata,
+//     and entries (WITH LOCK - database writes)
 //
 // The caller is responsible for:
 // - Rate limiting
 // - Spawning goroutines for concurrency
 // - Progress reporting
 func (f *Fetcher) FetchFeed(ctx context.Context, feed repository.Feed) FetchResult {
-	f.logger.Debug("Starting fetch for %s (ID: %d)", feed.URL, feed.ID)
+	j := f.newJob(0, feed)
+	if f.checkSkip(ctx, j) {
+		return j.result
+	}
+	f.fetch(ctx, j)
+	f.parse(ctx, j)
+
+	// Database writes - WITH LOCK (entire section)
+	f.lock()
+	defer f.unlock()
+	return j.measured(f.store(ctx, j))
+}
+
+// job carries one feed through the fetch, parse, and store stages
+type job struct {
+	index       int // Position in the feeds passed to Run
+	feed        repository.Feed
+	log         *slog.Logger    // The fetcher's logger with the feed's URL and ID
+	ctx         context.Context // Limits the fetch and parse stages in Run
+	cancel      context.CancelFunc
+	host        string
+	backoff     *repository.HostBackoff // Backoff recorded for the host before fetching
+	resp        *crawler.FeedResponse
+	err         error  // Fetch or parse failure
+	operation   string // Stage that failed: "fetch" or "parse"
+	interrupted bool   // The context ended before the feed was fetched and parsed
+	fetchTime   time.Duration
+	metadata    *normalizer.FeedMetadata
+	entries     []normalizer.Entry
+	hashes      map[string]string // Content fingerprints of entries, by ID, taken before article extraction
+	filtered    int
+	result      FetchResult // Outcome for skipped feeds
+}
+
+// newJob starts a feed through the stages
+func (f *Fetcher) newJob(index int, feed repository.Feed) *job {
+	return &job{index: index, feed: feed, log: f.feedLog(feed)}
+}
+
+// feedLog returns the logger with the feed's URL and ID attached, so every
+// line about a feed can be found by either
+func (f *Fetcher) feedLog(feed repository.Feed) *slog.Logger {
+	return f.logger.With("feed_url", feed.URL, "feed_id", feed.ID)
+}
+
+// measured adds the fetch's duration and size to a stored feed's result
+func (j *job) measured(result FetchResult) FetchResult {
+	result.FetchDuration = j.fetchTime
+	if j.resp != nil {
+		result.WireBytes = j.resp.WireBytes
+	}
+	return result
+}
+
+// checkSkip reports whether the feed should not be fetched now, setting the
+// job's result if so. Unlike SkipReason it also honours host backoffs, which
+// need a database read.
+func (f *Fetcher) checkSkip(ctx context.Context, j *job) bool {
+	if reason := f.SkipReason(j.feed); reason != "" {
+		j.log.Debug("Skipping feed", "reason", reason)
+		j.result = FetchResult{Skipped: true, SkipReason: reason}
+		return true
+	}
+
+	j.host = feedHost(j.feed.URL)
+	j.backoff = f.hostBackoff(ctx, j.host)
+	if j.backoff != nil && !f.force && j.backoff.Until.After(time.Now()) {
+		reason := fmt.Sprintf("%s asked us to back off until %s", j.host, j.backoff.Until.Local().Format("2006-01-02 15:04"))
+		j.log.Info("Skipping feed", "reason", reason)
+		j.result = FetchResult{Skipped: true, SkipReason: reason}
+		return true
+	}
+	return false
+}
+
+// fetch downloads the feed with retries (exponential backoff)
+func (f *Fetcher) fetch(ctx context.Context, j *job) {
+	j.log.Debug("Starting fetch")
 
-	// Prepare cache
 	cache := crawler.FeedCache{
-		URL:          feed.URL,
-		ETag:         feed.ETag,
-		LastModified: feed.LastModified,
-		LastFetched:  feed.LastFetched,
+		URL:          j.feed.URL,
+		ETag:         j.feed.ETag,
+		LastModified: j.feed.LastModified,
+		LastFetched:  j.feed.LastFetched,
+	}
+	start := time.Now()
+	j.resp, j.err = f.crawler.FetchWithRetry(ctx, j.feed.URL, cache, f.maxRetries)
+	j.fetchTime = time.Since(start)
+	if j.err != nil {
+		j.operation = "fetch"
+		j.interrupted = ctx.Err() != nil
 	}
+}
 
-	// Fetch feed with retry logic (exponential backoff) - NO LOCK (concurrent HTTP)
-	resp, err := f.crawler.FetchWithRetry(ctx, feed.URL, cache, f.maxRetries)
-	if err != nil {
-		return f.handleFetchError(ctx, feed, err, "fetch")
+// parse normalizes a fetched feed, filters its entries, and fetches full
+// articles for summary-only ones. It does nothing if the fetch failed or
+// the feed was not modified.
+func (f *Fetcher) parse(ctx context.Context, j *job) {
+	if j.err != nil || j.resp.NotModified {
+		return
+	}
+
+	// The server's charset applies to feeds that don't declare their own
+	body := normalizer.DecodeCharset(j.resp.Body, j.resp.Headers["Content-Type"])
+	j.metadata, j.entries, j.err = f.normalizer.Parse(ctx, body, j.feed.URL, j.resp.FetchTime)
+	if j.err != nil {
+		j.operation = "parse"
+		j.interrupted = ctx.Err() != nil
+		return
+	}
+	j.log.Debug("Parsed feed", "entries", len(j.entries))
+	if j.metadata.Truncated {
+		j.log.Warn("Feed has more entries than max_parsed_entries; only the newest were read", "entries", len(j.entries))
+	}
+
+	j.entries, j.filtered = f.filterEntries(j.feed, j.entries)
+	var dropped int
+	if j.entries, dropped, j.err = f.hookEntries(ctx, j.feed, j.entries); j.err != nil {
+		// Storing the entries the hooks did not get to would undo their
+		// changes, so the feed is left for the next run
+		j.operation = "parse"
+		j.interrupted = true
+		return
+	}
+	j.filtered += dropped
+	// Fingerprint the feed's own text, which stays the same when a
+	// republished entry's article is extracted again
+	j.hashes = make(map[string]string, len(j.entries))
+	for _, entry := range j.entries {
+		j.hashes[entry.ID] = contentFingerprint(entry)
+	}
+	f.extractArticles(ctx, j.feed, j.entries)
+	j.interrupted = ctx.Err() != nil
+}
+
+// store records the outcome of a fetched and parsed feed. The caller must
+// hold the repository lock.
+func (f *Fetcher) store(ctx context.Context, j *job) FetchResult {
+	feed, resp := j.feed, j.resp
+	f.updateHostBackoff(ctx, j.host, j.backoff, resp)
+	if j.operation == "fetch" {
+		f.recordFetch(ctx, j, 0)
+		return f.handleFetchError(ctx, j)
+	}
+
+	if resp.RobotsDisallowed {
+		j.log.Warn("robots.txt disallows the feed; fetched anyway (robots_txt = warn)")
+	}
+	if resp.Insecure {
+		j.log.Warn("TLS certificate NOT verified (insecure_skip_verify = true); the feed could be forged or tampered with")
 	}
 
 	// Handle 301 permanent redirect - update feed URL in database
 	if resp.PermanentRedirect && resp.FinalURL != feed.URL {
-		f.logger.Info("Feed %s permanently redirected to %s (301)", feed.URL, resp.FinalURL)
-		// Database write - WITH LOCK
-		f.lock()
+		j.log.Info("Feed permanently redirected (301)", "new_url", resp.FinalURL)
 		if updateErr := f.repo.UpdateFeedURL(ctx, feed.ID, resp.FinalURL); updateErr != nil {
-			f.logger.Error("Failed to update feed URL for %s: %v", feed.URL, updateErr)
+			j.log.Error("Failed to update feed URL", "new_url", resp.FinalURL, "error", updateErr)
 		} else {
-			f.logger.Info("Updated feed URL from %s to %s", feed.URL, resp.FinalURL)
+			j.log.Info("Updated feed URL", "new_url", resp.FinalURL)
 		}
-		f.unlock()
 	}
 
 	// Handle 304 Not Modified
 	if resp.NotModified {
-		f.logger.Debug("%s returned 304 Not Modified", feed.URL)
-		// Database write - WITH LOCK
-		f.lock()
-		if updateErr := f.repo.UpdateFeedCache(ctx, feed.ID, resp.NewCache.ETag, resp.NewCache.LastModified, resp.FetchTime); updateErr != nil {
-			f.logger.Error("Failed to update feed cache for %s: %v", feed.URL, updateErr)
-		}
-		f.unlock()
+		j.log.Debug("Feed not modified", "status", resp.StatusCode, "duration", j.fetchTime)
+		f.recordFetch(ctx, j, 0)
+		f.updateCache(ctx, feed, resp)
+		f.reschedule(ctx, feed)
 		return FetchResult{NotModified: true}
 	}
 
-	// Parse and normalize feed - NO LOCK (concurrent parsing)
-	metadata, entries, err := f.normalizer.Parse(ctx, resp.Body, feed.URL, resp.FetchTime)
+	if j.err != nil {
+		f.recordFetch(ctx, j, 0)
+		return f.handleFetchError(ctx, j)
+	}
+
+	// Update feed metadata and cache
+	if updateErr := f.repo.UpdateFeed(ctx, feed.ID, j.metadata.Title, j.metadata.Link, j.metadata.Updated); updateErr != nil {
+		j.log.Error("Failed to update feed metadata", "error", updateErr)
+	}
+	f.updateCache(ctx, feed, resp)
+
+	// Store entries, counting those not stored before
+	ids := make([]string, len(j.entries))
+	for i, entry := range j.entries {
+		ids[i] = entry.ID
+	}
+	existing, err := f.repo.GetStoredEntryIDs(ctx, feed.ID, ids)
+	if err != nil {
+		j.log.Warn("Failed to read stored entries", "error", err)
+		existing = make(map[string]bool)
+	}
+	current := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		current[id] = true
+	}
+	cutoff, err := f.repo.QuotaCutoff(ctx, feed.ID)
+	if err != nil {
+		j.log.Warn("Failed to read entry quotas", "error", err)
+	}
+	storedCount, addedCount, overQuota := 0, 0, 0
+	for _, entry := range j.entries {
+		if !existing[entry.ID] && f.adoptRepublished(ctx, j, entry, current) {
+			existing[entry.ID] = true
+		}
+		repoEntry := repositoryEntry(feed.ID, entry, j.hashes[entry.ID])
+		if !existing[entry.ID] && cutoff.Evicts(repoEntry) {
+			// Storing it would only evict it again
+			overQuota++
+			continue
+		}
+		if err := f.repo.UpsertEntry(ctx, repoEntry); err != nil {
+			j.log.Warn("Error storing entry", "entry_id", entry.ID, "error", err)
+		} else {
+			storedCount++
+			if !existing[entry.ID] {
+				existing[entry.ID] = true
+				addedCount++
+			}
+		}
+	}
+	evicted, err := f.repo.EnforceQuota(ctx, feed.ID)
 	if err != nil {
-		return f.handleFetchError(ctx, feed, err, "parse")
+		j.log.Warn("Failed to enforce entry quotas", "error", err)
 	}
+	f.recordFetch(ctx, j, addedCount)
 
-	f.logger.Debug("Parsed %d entries from %s", len(entries), feed.URL)
+	f.reschedule(ctx, feed)
 
-	// Database writes - WITH LOCK (entire section)
-	f.lock()
+	j.log.Info("Successfully processed feed",
+		"status", resp.StatusCode,
+		"duration", j.fetchTime,
+		"entries", storedCount,
+		"added", addedCount,
+		"filtered", j.filtered,
+		"over_quota", overQuota,
+		"evicted", evicted)
 
-	// Update feed metadata and cache
-	if updateErr := f.repo.UpdateFeed(ctx, feed.ID, metadata.Title, metadata.Link, metadata.Updated); updateErr != nil {
-		f.logger.Error("Failed to update feed metadata for %s: %v", feed.URL, updateErr)
+	return FetchResult{StoredEntries: storedCount, Filtered: j.filtered}
+}
+
+// StoredEntry returns entry as it is stored for the feed with ID feedID,
+// fingerprinted to recognise it if it is republished
+func StoredEntry(feedID int64, entry normalizer.Entry) *repository.Entry {
+	return repositoryEntry(feedID, entry, contentFingerprint(entry))
+}
+
+// repositoryEntry returns entry as it is stored for the feed with ID feedID,
+// with the given content fingerprint
+func repositoryEntry(feedID int64, entry normalizer.Entry, contentHash string) *repository.Entry {
+	repoEntry := &repository.Entry{
+		FeedID:      feedID,
+		EntryID:     entry.ID,
+		Title:       entry.Title,
+		Link:        entry.Link,
+		Author:      entry.Author,
+		Published:   entry.Published,
+		Updated:     entry.Updated,
+		Content:     entry.Content,
+		ContentType: entry.ContentType,
+		Summary:     entry.Summary,
+		FirstSeen:   entry.FirstSeen,
+		Categories:  entry.Categories,
+		ContentHash: contentHash,
+
+		WordCount:      entry.WordCount,
+		ReadingMinutes: entry.ReadingMinutes,
+		Image:          entry.Image,
+
+		Authors:     entry.Authors,
+		ExternalURL: entry.ExternalURL,
+		Language:    entry.Language,
 	}
-	if updateErr := f.repo.UpdateFeedCache(ctx, feed.ID, resp.NewCache.ETag, resp.NewCache.LastModified, resp.FetchTime); updateErr != nil {
-		f.logger.Error("Failed to update feed cache for %s: %v", feed.URL, updateErr)
+	for _, a := range entry.Attachments {
+		repoEntry.Attachments = append(repoEntry.Attachments, repository.Attachment(a))
 	}
+	return repoEntry
+}
+
+// adoptRepublished looks for a stored entry with the same link and content
+// as a new entry, left behind when the feed changed the entry's ID (as
+// blog migrations often do). If there is one, and the feed no longer lists
+// it under its old ID, it takes the new ID so the entry is updated rather
+// than duplicated. It reports whether an entry was adopted.
+func (f *Fetcher) adoptRepublished(ctx context.Context, j *job, entry normalizer.Entry, current map[string]bool) bool {
+	oldID, err := f.repo.FindEntryByContent(ctx, j.feed.ID, entry.Link, j.hashes[entry.ID])
+	if err != nil {
+		j.log.Warn("Failed to look up republished entry", "entry_id", entry.ID, "error", err)
+		return false
+	}
+	if oldID == "" || current[oldID] {
+		return false
+	}
+	if err := f.repo.RekeyEntry(ctx, j.feed.ID, oldID, entry.ID); err != nil {
+		j.log.Warn("Failed to update republished entry", "entry_id", entry.ID, "old_entry_id", oldID, "error", err)
+		return false
+	}
+	j.log.Info("Entry republished under a new ID", "entry_id", entry.ID, "old_entry_id", oldID)
+	return true
+}
 
-	// Store entries
-	storedCount := 0
+// filterEntries removes entries rejected by the configured filters and
+// returns the remaining entries with the number dropped
+func (f *Fetcher) filterEntries(feed repository.Feed, entries []normalizer.Entry) ([]normalizer.Entry, int) {
+	if f.filters == nil {
+		return entries, 0
+	}
+	kept := entries[:0]
 	for _, entry := range entries {
-		repoEntry := &repository.Entry{
-			FeedID:      feed.ID,
-			EntryID:     entry.ID,
-			Title:       entry.Title,
-			Link:        entry.Link,
-			Author:      entry.Author,
-			Published:   entry.Published,
-			Updated:     entry.Updated,
-			Content:     entry.Content,
-			ContentType: entry.ContentType,
-			Summary:     entry.Summary,
-			FirstSeen:   entry.FirstSeen,
+		keep, reason := f.filters.Match(feed.URL, filter.Item{
+			Title:      entry.Title,
+			Summary:    entry.Summary,
+			Content:    entry.Content,
+			Author:     entry.Author,
+			Categories: entry.Categories,
+		})
+		if !keep {
+			f.feedLog(feed).Debug("Filtered entry", "title", entry.Title, "reason", reason)
+			continue
 		}
+		kept = append(kept, entry)
+	}
+	return kept, len(entries) - len(kept)
+}
 
-		if err := f.repo.UpsertEntry(ctx, repoEntry); err != nil {
-			f.logger.Warn("Error storing entry from %s: %v", feed.URL, err)
-		} else {
-			storedCount++
+// hookEntries runs the entry hooks on entries and returns the entries they
+// kept with the number dropped. A failing hook leaves the entry as the
+// hooks before it left it. The error is only for the context ending.
+func (f *Fetcher) hookEntries(ctx context.Context, feed repository.Feed, entries []normalizer.Entry) ([]normalizer.Entry, int, error) {
+	if len(f.entryHooks) == 0 {
+		return entries, 0, nil
+	}
+	hookFeed := hooks.Feed{URL: feed.URL, Title: feed.Title, Link: feed.Link}
+	kept := entries[:0]
+	for _, entry := range entries {
+		in := hooks.Entry{
+			ID:         entry.ID,
+			Title:      entry.Title,
+			Link:       entry.Link,
+			Author:     entry.Author,
+			Published:  entry.Published,
+			Updated:    entry.Updated,
+			Content:    entry.Content,
+			Summary:    entry.Summary,
+			Categories: entry.Categories,
+			Image:      entry.Image,
+		}
+		out, keep := in, true
+		for _, h := range f.entryHooks {
+			var err error
+			if out, keep, err = h.Entry(ctx, hookFeed, out); err != nil {
+				if ctx.Err() != nil {
+					return nil, 0, ctx.Err()
+				}
+				f.feedLog(feed).Warn("Entry hook failed", "entry_id", entry.ID, "error", err)
+			}
+			if !keep {
+				break
+			}
+		}
+		if !keep {
+			f.feedLog(feed).Debug("Entry dropped by hook", "title", entry.Title)
+			continue
+		}
+
+		entry.Title, entry.Link, entry.Author = out.Title, out.Link, out.Author
+		entry.Published, entry.Updated = out.Published, out.Updated
+		entry.Categories, entry.Image = out.Categories, out.Image
+		if out.Summary != in.Summary {
+			entry.Summary = f.sanitize(feed.URL, out.Summary)
+		}
+		if out.Content != in.Content {
+			entry.Content = f.sanitize(feed.URL, out.Content)
+			entry.WordCount = normalizer.CountWords(entry.Content)
+			entry.ReadingMinutes = normalizer.ReadingMinutes(entry.WordCount)
+		}
+		kept = append(kept, entry)
+	}
+	return kept, len(entries) - len(kept), nil
+}
+
+// extractArticles replaces summary-only content with the article from the
+// entry's page, and gives entries without an image the page's preview
+// image. Only new entries are fetched; entries already stored keep the
+// content and image they were stored with, so pages are fetched once.
+func (f *Fetcher) extractArticles(ctx context.Context, feed repository.Feed, entries []normalizer.Entry) {
+	articles, images := f.extractor.Enabled(feed.URL), f.extractor.ImagesEnabled(feed.URL)
+	if !articles && !images {
+		return
+	}
+	needsArticle := func(e normalizer.Entry) bool {
+		return articles && e.Link != "" && extract.NeedsExtraction(e.Content)
+	}
+	needsImage := func(e normalizer.Entry) bool {
+		return images && e.Link != "" && e.Image == ""
+	}
+
+	var ids []string
+	for _, entry := range entries {
+		if needsArticle(entry) || needsImage(entry) {
+			ids = append(ids, entry.ID)
 		}
 	}
+	if len(ids) == 0 {
+		return
+	}
 
+	f.lock()
+	stored, err := f.repo.GetEntryContents(ctx, feed.ID, ids)
 	f.unlock()
+	if err != nil {
+		f.feedLog(feed).Warn("Failed to read stored entries", "error", err)
+		return
+	}
 
-	f.logger.Info("Successfully processed %s: %d entries", feed.URL, storedCount)
+	extracted, pictured := 0, 0
+	for i := range entries {
+		entry := &entries[i]
+		wantArticle, wantImage := needsArticle(*entry), needsImage(*entry)
+		if !wantArticle && !wantImage {
+			continue
+		}
+		if content, ok := stored[entry.ID]; ok {
+			// The stored image, if any, is kept by UpsertEntry
+			if wantArticle && !extract.NeedsExtraction(content) {
+				useArticle(entry, content)
+			}
+			continue
+		}
+		if ctx.Err() != nil {
+			return
+		}
 
-	return FetchResult{StoredEntries: storedCount}
+		page, err := f.extractor.Page(ctx, feed.URL, entry.Link)
+		if err != nil {
+			f.feedLog(feed).Debug("Page not fetched", "link", entry.Link, "error", err)
+			continue
+		}
+		if wantArticle && page.Article != "" {
+			useArticle(entry, page.Article)
+			extracted++
+		}
+		if wantImage && page.Image != "" {
+			entry.Image = page.Image
+			pictured++
+		}
+	}
+	if extracted > 0 || pictured > 0 {
+		f.feedLog(feed).Debug("Extracted from entry pages", "articles", extracted, "images", pictured)
+	}
+}
+
+// useArticle makes article the entry's content, keeping the feed's text as
+// the summary
+func useArticle(entry *normalizer.Entry, article string) {
+	if entry.Summary == "" {
+		entry.Summary = entry.Content
+	}
+	entry.Content = article
+	entry.ContentType = "html"
+	entry.WordCount = normalizer.CountWords(article)
+	entry.ReadingMinutes = normalizer.ReadingMinutes(entry.WordCount)
 }
 
 // lock acquires the repository mutex if one was provided
@@ -180,17 +655,232 @@ func (f *Fetcher) unlock() {
 	}
 }
 
-// handleFetchError logs the error, updates the database, and returns a FetchResult.
-// This method handles the common pattern of error logging + database update with locking.
-func (f *Fetcher) handleFetchError(ctx context.Context, feed repository.Feed, err error, operation string) FetchResult {
-	f.logger.Error("%s failed for %s: %v", operation, feed.URL, err)
+// updateCache stores the response's validators and cache lifetime.
+// The caller must hold the repository lock.
+func (f *Fetcher) updateCache(ctx context.Context, feed repository.Feed, resp *crawler.FeedResponse) {
+	if err := f.repo.UpdateFeedCache(ctx, feed.ID, resp.NewCache.ETag, resp.NewCache.LastModified, resp.FetchTime); err != nil {
+		f.feedLog(feed).Error("Failed to update feed cache", "error", err)
+	}
+	if err := f.repo.UpdateFeedCacheExpiry(ctx, feed.ID, resp.NewCache.Expires); err != nil {
+		f.feedLog(feed).Error("Failed to update cache expiry", "error", err)
+	}
+}
+
+// reschedule computes the feed's next due time from its posting cadence.
+// The caller must hold the repository lock.
+func (f *Fetcher) reschedule(ctx context.Context, feed repository.Feed) {
+	if f.scheduler == nil {
+		return
+	}
+
+	times, err := f.repo.GetEntryTimes(ctx, feed.ID, scheduler.HistorySize)
+	if err != nil {
+		f.feedLog(feed).Error("Failed to read entry history", "error", err)
+		return
+	}
+
+	now := time.Now()
+	interval := f.scheduler.Interval(times, now)
+	if err := f.repo.UpdateFeedSchedule(ctx, feed.ID, interval, now.Add(interval)); err != nil {
+		f.feedLog(feed).Error("Failed to update schedule", "error", err)
+		return
+	}
+	f.feedLog(feed).Debug("Scheduled next fetch", "interval", interval)
+}
+
+// feedHost returns the lowercased host (and port) of a feed URL, or ""
+func feedHost(feedURL string) string {
+	u, err := url.Parse(feedURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Host)
+}
+
+// hostBackoff returns the backoff recorded for a host, or nil
+func (f *Fetcher) hostBackoff(ctx context.Context, host string) *repository.HostBackoff {
+	if host == "" {
+		return nil
+	}
 
-	// Database write - WITH LOCK
 	f.lock()
 	defer f.unlock()
 
+	backoff, err := f.repo.GetHostBackoff(ctx, host)
+	if err != nil {
+		f.logger.Warn("Failed to read host backoff", "host", host, "error", err)
+		return nil
+	}
+	return backoff
+}
+
+// updateHostBackoff records a backoff when the host answered 429 Too Many
+// Requests or 503 Service Unavailable, so later fetches from it (in this run
+// or the next) are skipped until it has had the rest it asked for. The wait
+// is the response's Retry-After or, without one, doubles with each
+// consecutive refusal. Any other response clears a previous backoff. The
+// caller must hold the repository lock.
+func (f *Fetcher) updateHostBackoff(ctx context.Context, host string, prev *repository.HostBackoff, resp *crawler.FeedResponse) {
+	if host == "" || resp == nil || resp.StatusCode == 0 {
+		return
+	}
+	limited := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable
+	if !limited && prev == nil {
+		return
+	}
+
+	if !limited {
+		if err := f.repo.ClearHostBackoff(ctx, host); err != nil {
+			f.logger.Warn("Failed to clear host backoff", "host", host, "error", err)
+		}
+		return
+	}
+
+	failures := 1
+	if prev != nil {
+		failures = prev.Failures + 1
+	}
+	wait := resp.RetryAfter
+	if wait <= 0 {
+		wait = hostBackoffBase << min(failures-1, 10)
+	}
+	wait = min(wait, MaxHostBackoff)
+
+	backoff := repository.HostBackoff{
+		Host:       host,
+		Until:      time.Now().Add(wait),
+		StatusCode: resp.StatusCode,
+		Failures:   failures,
+	}
+	if err := f.repo.SetHostBackoff(ctx, backoff); err != nil {
+		f.logger.Warn("Failed to record host backoff", "host", host, "error", err)
+		return
+	}
+	f.logger.Warn("Host asked us to back off; not fetching from it again until then",
+		"host", host,
+		"status", resp.StatusCode,
+		"until", backoff.Until.Local().Format("2006-01-02 15:04"))
+}
+
+// recordFetch appends the outcome of a job's fetch to the feed's fetch log,
+// with the number of entries it added. j.resp may be nil when no HTTP
+// response was received. The caller must hold the repository lock.
+func (f *Fetcher) recordFetch(ctx context.Context, j *job, added int) {
+	feed, resp := j.feed, j.resp
+	entry := repository.FetchLogEntry{
+		FeedID:       feed.ID,
+		FetchedAt:    time.Now(),
+		Duration:     j.fetchTime,
+		EntriesAdded: added,
+	}
+	if resp != nil {
+		entry.StatusCode = resp.StatusCode
+		entry.Headers = resp.Headers
+		entry.Proto = resp.Proto
+		entry.ContentEncoding = resp.ContentEncoding
+		entry.WireBytes = resp.WireBytes
+		entry.DecodedBytes = resp.DecodedBytes
+		if !resp.FetchTime.IsZero() {
+			entry.FetchedAt = resp.FetchTime
+		}
+		if resp.FinalURL != feed.URL {
+			entry.FinalURL = resp.FinalURL
+		}
+	}
+	if j.err != nil {
+		entry.Error = j.err.Error()
+	}
+
+	if err := f.repo.RecordFetch(ctx, entry); err != nil {
+		j.log.Warn("Failed to record fetch log", "error", err)
+	}
+}
+
+// isGone reports whether a fetch error means the feed is permanently gone:
+// the server answered 410 Gone, or the host has been missing from DNS for
+// the last GoneAfterHostNotFound fetches. The fetch log must already include
+// this attempt. The caller must hold the repository lock.
+func (f *Fetcher) isGone(ctx context.Context, feed repository.Feed, err error) bool {
+	if errors.Is(err, crawler.ErrGone) {
+		return true
+	}
+	if !errors.Is(err, crawler.ErrHostNotFound) {
+		return false
+	}
+
+	log, logErr := f.repo.GetFetchLog(ctx, feed.ID, GoneAfterHostNotFound)
+	if logErr != nil {
+		f.feedLog(feed).Warn("Failed to read fetch log", "error", logErr)
+		return false
+	}
+	if len(log) < GoneAfterHostNotFound {
+		return false
+	}
+	for _, attempt := range log {
+		if !strings.Contains(attempt.Error, crawler.ErrHostNotFound.Error()) {
+			return false
+		}
+	}
+	return true
+}
+
+// markGone deactivates a feed that is permanently gone. The caller must hold
+// the repository lock.
+func (f *Fetcher) markGone(ctx context.Context, feed repository.Feed, err error) {
+	if markErr := f.repo.MarkFeedGone(ctx, feed.ID, time.Now()); markErr != nil {
+		f.feedLog(feed).Error("Failed to mark feed as gone", "error", markErr)
+		return
+	}
+	f.feedLog(feed).Warn("Feed is gone; it will no longer be fetched. Remove it with 'rp remove-feed <url>'", "error", err)
+}
+
+// errorBackoff is how long a feed waits after its nth consecutive error
+func errorBackoff(failures int) time.Duration {
+	return min(errorBackoffBase<<min(failures-1, 10), MaxErrorBackoff)
+}
+
+// backOff delays a failing feed's next fetch, or deactivates it once it has
+// failed deactivateAfter times in a row. The caller must hold the repository
+// lock.
+func (f *Fetcher) backOff(ctx context.Context, feed repository.Feed) {
+	failures := feed.FetchErrorCount + 1
+
+	if f.deactivateAfter > 0 && failures >= f.deactivateAfter {
+		if err := f.repo.DeactivateFeed(ctx, feed.ID); err != nil {
+			f.feedLog(feed).Error("Failed to deactivate feed", "error", err)
+			return
+		}
+		f.feedLog(feed).Warn("Deactivated feed; run 'rp reactivate-feed <url>' to fetch it again", "consecutive_errors", failures)
+		return
+	}
+
+	next := time.Now().Add(errorBackoff(failures))
+	if err := f.repo.UpdateFeedNextFetch(ctx, feed.ID, next); err != nil {
+		f.feedLog(feed).Error("Failed to update next fetch", "error", err)
+	}
+}
+
+// handleFetchError logs a failed fetch or parse, records the error and the
+// backoff or deactivation it leads to, and returns a FetchResult. The caller
+// must hold the repository lock.
+func (f *Fetcher) handleFetchError(ctx context.Context, j *job) FetchResult {
+	feed, err, operation := j.feed, j.err, j.operation
+	attrs := []any{"operation", operation, "duration", j.fetchTime, "error", err}
+	if j.resp != nil && j.resp.StatusCode != 0 {
+		attrs = append(attrs, "status", j.resp.StatusCode)
+	}
+	j.log.Error("Feed "+operation+" failed", attrs...)
+
 	if updateErr := f.repo.UpdateFeedError(ctx, feed.ID, err.Error()); updateErr != nil {
-		f.logger.Error("Failed to update feed error for %s: %v", feed.URL, updateErr)
+		j.log.Error("Failed to update feed error", "error", updateErr)
+	}
+	// An interrupted run says nothing about the feed
+	if !j.interrupted {
+		if f.isGone(ctx, feed, err) {
+			f.markGone(ctx, feed, err)
+		} else {
+			f.backOff(ctx, feed)
+		}
 	}
 
 	return FetchResult{Error: fmt.Errorf("%s: %w", operation, err)}
@@ -3,6 +3,7 @@ package fetcher
 import (
 	"context"
 	"errors"
+	"log/slog"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -95,7 +96,7 @@ func TestFetchFeed_Concurrency(t *testing.T) {
 
 	// Create mutex for shared repository access
 	var mu sync.Mutex
-	fetcher := New(mc, mn, mr, &mu, ml, 3)
+	fetcher := New(mc, mn, mr, &mu, slog.New(ml), 3)
 
 	// Create test feeds
 	feeds := make([]repository.Feed, numFeeds)
@@ -182,7 +183,7 @@ func TestFetchFeed_MutexProtectsDatabase(t *testing.T) {
 	ml := &mockLogger{}
 
 	var mu sync.Mutex
-	fetcher := New(mc, mn, mr, &mu, ml, 3)
+	fetcher := New(mc, mn, mr, &mu, slog.New(ml), 3)
 
 	// Create test feeds
 	feeds := make([]repository.Feed, numFeeds)
@@ -297,7 +298,7 @@ func BenchmarkFetchFeed_Sequential(b *testing.B) {
 	ml := &mockLogger{}
 
 	// No mutex = single-threaded
-	f := New(mc, mn, mr, nil, ml, 3)
+	f := New(mc, mn, mr, nil, slog.New(ml), 3)
 
 	feed := repository.Feed{ID: 1, URL: "http://example.com/feed"}
 
@@ -326,7 +327,7 @@ func BenchmarkFetchFeed_Concurrent(b *testing.B) {
 	ml := &mockLogger{}
 
 	var mu sync.Mutex
-	f := New(mc, mn, mr, &mu, ml, 3)
+	f := New(mc, mn, mr, &mu, slog.New(ml), 3)
 
 	feed := repository.Feed{ID: 1, URL: "http://example.com/feed"}
 
@@ -367,7 +368,7 @@ func TestFetchFeed_ConcurrentErrorHandling(t *testing.T) {
 	ml := &mockLogger{}
 
 	var mu sync.Mutex
-	fetcher := New(mc, mn, mr, &mu, ml, 3)
+	fetcher := New(mc, mn, mr, &mu, slog.New(ml), 3)
 
 	// Launch concurrent fetches that will all fail
 	var wg sync.WaitGroup
@@ -423,7 +424,7 @@ func TestFetchFeed_ContextCancellationDuringConcurrentFetches(t *testing.T) {
 	ml := &mockLogger{}
 
 	var mu sync.Mutex
-	fetcher := New(mc, mn, mr, &mu, ml, 3)
+	fetcher := New(mc, mn, mr, &mu, slog.New(ml), 3)
 
 	ctx, cancel := context.WithCancel(context.Background())
 
@@ -503,7 +504,7 @@ func TestFetchFeed_ConcurrentAccessToSameFeed(t *testing.T) {
 	ml := &mockLogger{}
 
 	var mu sync.Mutex
-	fetcher := New(mc, mn, mr, &mu, ml, 3)
+	fetcher := New(mc, mn, mr, &mu, slog.New(ml), 3)
 
 	// Same feed fetched by multiple goroutines simultaneously
 	sameFeed := repository.Feed{ID: 1, URL: "http://example.com/feed"}
@@ -1,19 +1,29 @@
 package fetcher
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"path/filepath"
+	"reflect"
+	"runtime"
 	"strings"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/adewale/rogue_planet/pkg/crawler"
+	"github.com/adewale/rogue_planet/pkg/extract"
+	"github.com/adewale/rogue_planet/pkg/filter"
+	"github.com/adewale/rogue_planet/pkg/hooks"
 	"github.com/adewale/rogue_planet/pkg/normalizer"
 	"github.com/adewale/rogue_planet/pkg/repository"
+	"github.com/adewale/rogue_planet/pkg/scheduler"
 )
 
 // Mock implementations
@@ -52,6 +62,10 @@ type mockRepository struct {
 	updateFeedErrorCalled bool
 	updateFeedURLCalled   bool
 	updateFeedCacheCalled bool
+	cacheExpires          time.Time
+	entryTimes            []time.Time
+	scheduleInterval      time.Duration
+	nextFetch             time.Time
 	updateFeedCalled      bool
 	upsertEntryCalled     bool
 	upsertEntryCount      int
@@ -62,6 +76,13 @@ type mockRepository struct {
 	updateFeedCacheError  error
 	updateFeedURLError    error
 	updateFeedErrorError  error
+	recordFetchCount      int
+	lastFetchLog          repository.FetchLogEntry
+	storedContents        map[string]string // Content of already-stored entries, by entry ID
+	hostBackoffs          map[string]repository.HostBackoff
+	deactivated           bool
+	goneAt                time.Time
+	fetchLog              []repository.FetchLogEntry // Newest first
 	// Function fields for dynamic behavior
 	upsertEntryFunc func(entry *repository.Entry) error
 }
@@ -83,6 +104,21 @@ func (m *mockRepository) UpdateFeedCache(ctx context.Context, id int64, etag, la
 	return m.updateFeedCacheError
 }
 
+func (m *mockRepository) UpdateFeedCacheExpiry(ctx context.Context, id int64, expires time.Time) error {
+	m.cacheExpires = expires
+	return nil
+}
+
+func (m *mockRepository) UpdateFeedSchedule(ctx context.Context, id int64, interval time.Duration, nextFetch time.Time) error {
+	m.scheduleInterval = interval
+	m.nextFetch = nextFetch
+	return nil
+}
+
+func (m *mockRepository) GetEntryTimes(ctx context.Context, feedID int64, limit int) ([]time.Time, error) {
+	return m.entryTimes, nil
+}
+
 func (m *mockRepository) UpdateFeed(ctx context.Context, id int64, title, link string, updated time.Time) error {
 	m.updateFeedCalled = true
 	return m.updateFeedError
@@ -97,7 +133,43 @@ func (m *mockRepository) UpsertEntry(ctx context.Context, entry *repository.Entr
 	return m.upsertEntryError
 }
 
+func (m *mockRepository) QuotaCutoff(ctx context.Context, feedID int64) (repository.QuotaCutoff, error) {
+	return repository.QuotaCutoff{}, nil
+}
+
+func (m *mockRepository) EnforceQuota(ctx context.Context, feedID int64) (int64, error) {
+	return 0, nil
+}
+
+func (m *mockRepository) GetStoredEntryIDs(ctx context.Context, feedID int64, entryIDs []string) (map[string]bool, error) {
+	stored := make(map[string]bool)
+	for _, id := range entryIDs {
+		if _, ok := m.storedContents[id]; ok {
+			stored[id] = true
+		}
+	}
+	return stored, nil
+}
+
+func (m *mockRepository) GetEntryContents(ctx context.Context, feedID int64, entryIDs []string) (map[string]string, error) {
+	contents := make(map[string]string)
+	for _, id := range entryIDs {
+		if c, ok := m.storedContents[id]; ok {
+			contents[id] = c
+		}
+	}
+	return contents, nil
+}
+
 // Implement remaining interface methods (not used in tests)
+func (m *mockRepository) FindEntryByContent(ctx context.Context, feedID int64, link, contentHash string) (string, error) {
+	return "", nil
+}
+
+func (m *mockRepository) RekeyEntry(ctx context.Context, feedID int64, oldID, newID string) error {
+	return nil
+}
+
 func (m *mockRepository) GetFeeds(ctx context.Context, activeOnly bool) ([]repository.Feed, error) {
 	return nil, nil
 }
@@ -114,6 +186,55 @@ func (m *mockRepository) RemoveFeed(ctx context.Context, id int64) error {
 	return nil
 }
 
+func (m *mockRepository) RecordFetch(ctx context.Context, entry repository.FetchLogEntry) error {
+	m.recordFetchCount++
+	m.lastFetchLog = entry
+	m.fetchLog = append([]repository.FetchLogEntry{entry}, m.fetchLog...)
+	return nil
+}
+
+func (m *mockRepository) UpdateFeedNextFetch(ctx context.Context, id int64, nextFetch time.Time) error {
+	m.nextFetch = nextFetch
+	return nil
+}
+
+func (m *mockRepository) MarkFeedGone(ctx context.Context, id int64, at time.Time) error {
+	m.goneAt = at
+	return nil
+}
+
+func (m *mockRepository) GetFetchLog(ctx context.Context, feedID int64, limit int) ([]repository.FetchLogEntry, error) {
+	if len(m.fetchLog) > limit {
+		return m.fetchLog[:limit], nil
+	}
+	return m.fetchLog, nil
+}
+
+func (m *mockRepository) DeactivateFeed(ctx context.Context, id int64) error {
+	m.deactivated = true
+	return nil
+}
+
+func (m *mockRepository) GetHostBackoff(ctx context.Context, host string) (*repository.HostBackoff, error) {
+	if b, ok := m.hostBackoffs[host]; ok {
+		return &b, nil
+	}
+	return nil, nil
+}
+
+func (m *mockRepository) SetHostBackoff(ctx context.Context, b repository.HostBackoff) error {
+	if m.hostBackoffs == nil {
+		m.hostBackoffs = make(map[string]repository.HostBackoff)
+	}
+	m.hostBackoffs[b.Host] = b
+	return nil
+}
+
+func (m *mockRepository) ClearHostBackoff(ctx context.Context, host string) error {
+	delete(m.hostBackoffs, host)
+	return nil
+}
+
 func (m *mockRepository) GetRecentEntries(ctx context.Context, days int) ([]repository.Entry, error) {
 	return nil, nil
 }
@@ -138,10 +259,15 @@ func (m *mockRepository) PruneOldEntries(ctx context.Context, days int) (int64,
 	return 0, nil
 }
 
+func (m *mockRepository) Batch(ctx context.Context, fn func(repository.FeedRepository) error) error {
+	return fn(m)
+}
+
 func (m *mockRepository) Close() error {
 	return nil
 }
 
+// mockLogger is a slog.Handler that records the messages logged at each level
 type mockLogger struct {
 	mu         sync.Mutex
 	debugCalls []string
@@ -150,28 +276,33 @@ type mockLogger struct {
 	errorCalls []string
 }
 
-func (m *mockLogger) Debug(format string, args ...interface{}) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-	m.debugCalls = append(m.debugCalls, format)
+func (m *mockLogger) Enabled(context.Context, slog.Level) bool {
+	return true
 }
 
-func (m *mockLogger) Info(format string, args ...interface{}) {
+func (m *mockLogger) Handle(_ context.Context, r slog.Record) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	m.infoCalls = append(m.infoCalls, format)
+	switch {
+	case r.Level >= slog.LevelError:
+		m.errorCalls = append(m.errorCalls, r.Message)
+	case r.Level >= slog.LevelWarn:
+		m.warnCalls = append(m.warnCalls, r.Message)
+	case r.Level >= slog.LevelInfo:
+		m.infoCalls = append(m.infoCalls, r.Message)
+	default:
+		m.debugCalls = append(m.debugCalls, r.Message)
+	}
+	return nil
 }
 
-func (m *mockLogger) Warn(format string, args ...interface{}) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-	m.warnCalls = append(m.warnCalls, format)
+// WithAttrs and WithGroup drop the attributes, keeping messages in m
+func (m *mockLogger) WithAttrs([]slog.Attr) slog.Handler {
+	return m
 }
 
-func (m *mockLogger) Error(format string, args ...interface{}) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-	m.errorCalls = append(m.errorCalls, format)
+func (m *mockLogger) WithGroup(string) slog.Handler {
+	return m
 }
 
 // Tests
@@ -213,10 +344,10 @@ func TestFetchFeed_Success(t *testing.T) {
 		},
 	}
 
-	mr := &mockRepository{}
+	mr := &mockRepository{storedContents: map[string]string{"entry2": "<p>stored</p>"}}
 	ml := &mockLogger{}
 
-	f := New(mc, mn, mr, nil, ml, 3)
+	f := New(mc, mn, mr, nil, slog.New(ml), 3)
 
 	feed := repository.Feed{
 		ID:  1,
@@ -250,6 +381,76 @@ func TestFetchFeed_Success(t *testing.T) {
 	if mr.upsertEntryCount != 2 {
 		t.Errorf("Expected UpsertEntry to be called 2 times, got %d", mr.upsertEntryCount)
 	}
+
+	if mr.recordFetchCount != 1 {
+		t.Errorf("Expected RecordFetch to be called once, got %d", mr.recordFetchCount)
+	}
+
+	if mr.lastFetchLog.StatusCode != 200 || mr.lastFetchLog.Error != "" {
+		t.Errorf("Expected successful fetch log, got %+v", mr.lastFetchLog)
+	}
+
+	// entry2 was already stored, so only entry1 is new
+	if mr.lastFetchLog.EntriesAdded != 1 {
+		t.Errorf("Expected fetch log to record 1 entry added, got %d", mr.lastFetchLog.EntriesAdded)
+	}
+}
+
+func TestFetchFeed_LogFields(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name       string
+		crawler    *mockCrawler
+		wantMsg    string
+		wantStatus bool
+	}{
+		{
+			name:       "success",
+			crawler:    &mockCrawler{resp: &crawler.FeedResponse{Body: []byte("<feed/>"), StatusCode: 200, FetchTime: time.Now()}},
+			wantMsg:    "Successfully processed feed",
+			wantStatus: true,
+		},
+		{
+			name:    "fetch error",
+			crawler: &mockCrawler{err: errors.New("connection refused")},
+			wantMsg: "Feed fetch failed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var buf bytes.Buffer
+			logger := slog.New(slog.NewJSONHandler(&buf, nil))
+			mn := &mockNormalizer{metadata: &normalizer.FeedMetadata{Title: "Test Feed"}}
+			f := New(tt.crawler, mn, &mockRepository{}, nil, logger, 0)
+			f.FetchFeed(context.Background(), repository.Feed{ID: 42, URL: "http://example.com/feed"})
+
+			var found bool
+			for _, line := range bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n")) {
+				var record map[string]any
+				if err := json.Unmarshal(line, &record); err != nil {
+					t.Fatalf("log line is not JSON: %s", line)
+				}
+				if record["msg"] != tt.wantMsg {
+					continue
+				}
+				found = true
+				if record["feed_url"] != "http://example.com/feed" || record["feed_id"] != float64(42) {
+					t.Errorf("feed_url, feed_id = %v, %v; want the feed's", record["feed_url"], record["feed_id"])
+				}
+				if _, ok := record["duration"]; !ok {
+					t.Errorf("%q has no duration: %s", tt.wantMsg, line)
+				}
+				if _, ok := record["status"]; ok != tt.wantStatus {
+					t.Errorf("%q has status = %v, want %v: %s", tt.wantMsg, ok, tt.wantStatus, line)
+				}
+			}
+			if !found {
+				t.Errorf("no %q line logged:\n%s", tt.wantMsg, buf.String())
+			}
+		})
+	}
 }
 
 func TestFetchFeed_FetchError(t *testing.T) {
@@ -263,7 +464,7 @@ func TestFetchFeed_FetchError(t *testing.T) {
 	mr := &mockRepository{}
 	ml := &mockLogger{}
 
-	f := New(mc, mn, mr, nil, ml, 3)
+	f := New(mc, mn, mr, nil, slog.New(ml), 3)
 
 	feed := repository.Feed{
 		ID:  1,
@@ -289,6 +490,10 @@ func TestFetchFeed_FetchError(t *testing.T) {
 	if len(ml.errorCalls) == 0 {
 		t.Error("Expected error to be logged")
 	}
+
+	if mr.lastFetchLog.Error != "network error" {
+		t.Errorf("Expected fetch log error 'network error', got %q", mr.lastFetchLog.Error)
+	}
 }
 
 func TestFetchFeed_301Redirect(t *testing.T) {
@@ -320,7 +525,7 @@ func TestFetchFeed_301Redirect(t *testing.T) {
 	mr := &mockRepository{}
 	ml := &mockLogger{}
 
-	f := New(mc, mn, mr, nil, ml, 3)
+	f := New(mc, mn, mr, nil, slog.New(ml), 3)
 
 	feed := repository.Feed{
 		ID:  1,
@@ -367,7 +572,7 @@ func TestFetchFeed_304NotModified(t *testing.T) {
 	mr := &mockRepository{}
 	ml := &mockLogger{}
 
-	f := New(mc, mn, mr, nil, ml, 3)
+	f := New(mc, mn, mr, nil, slog.New(ml), 3)
 
 	feed := repository.Feed{
 		ID:  1,
@@ -421,7 +626,7 @@ func TestFetchFeed_ParseError(t *testing.T) {
 	mr := &mockRepository{}
 	ml := &mockLogger{}
 
-	f := New(mc, mn, mr, nil, ml, 3)
+	f := New(mc, mn, mr, nil, slog.New(ml), 3)
 
 	feed := repository.Feed{
 		ID:  1,
@@ -487,7 +692,7 @@ func TestFetchFeed_EntryStorageError(t *testing.T) {
 
 	ml := &mockLogger{}
 
-	f := New(mc, mn, mr, nil, ml, 3)
+	f := New(mc, mn, mr, nil, slog.New(ml), 3)
 
 	feed := repository.Feed{
 		ID:  1,
@@ -538,7 +743,7 @@ func TestFetchFeed_UpdateFeedURLError(t *testing.T) {
 	}
 
 	ml := &mockLogger{}
-	f := New(mc, mn, mr, nil, ml, 3)
+	f := New(mc, mn, mr, nil, slog.New(ml), 3)
 
 	feed := repository.Feed{
 		ID:  1,
@@ -601,7 +806,7 @@ func TestFetchFeed_UpdateFeedCacheError_On304(t *testing.T) {
 	}
 
 	ml := &mockLogger{}
-	f := New(mc, mn, mr, nil, ml, 3)
+	f := New(mc, mn, mr, nil, slog.New(ml), 3)
 
 	feed := repository.Feed{ID: 1, URL: "http://example.com/feed"}
 
@@ -658,7 +863,7 @@ func TestFetchFeed_UpdateFeedMetadataError(t *testing.T) {
 	}
 
 	ml := &mockLogger{}
-	f := New(mc, mn, mr, nil, ml, 3)
+	f := New(mc, mn, mr, nil, slog.New(ml), 3)
 
 	feed := repository.Feed{ID: 1, URL: "http://example.com/feed"}
 
@@ -714,7 +919,7 @@ func TestFetchFeed_UpdateFeedCacheError_AfterParse(t *testing.T) {
 	}
 
 	ml := &mockLogger{}
-	f := New(mc, mn, mr, nil, ml, 3)
+	f := New(mc, mn, mr, nil, slog.New(ml), 3)
 
 	feed := repository.Feed{ID: 1, URL: "http://example.com/feed"}
 
@@ -757,7 +962,7 @@ func TestFetchFeed_UpdateFeedErrorFailure(t *testing.T) {
 	}
 
 	ml := &mockLogger{}
-	f := New(mc, mn, mr, nil, ml, 3)
+	f := New(mc, mn, mr, nil, slog.New(ml), 3)
 
 	feed := repository.Feed{ID: 1, URL: "http://example.com/feed"}
 
@@ -821,7 +1026,7 @@ func TestFetchFeed_MultipleSimultaneousErrors(t *testing.T) {
 	}
 
 	ml := &mockLogger{}
-	f := New(mc, mn, mr, nil, ml, 3)
+	f := New(mc, mn, mr, nil, slog.New(ml), 3)
 
 	feed := repository.Feed{ID: 1, URL: "http://example.com/feed"}
 
@@ -891,7 +1096,7 @@ func TestFetchFeed_MultipleDBErrorsDuringSuccess(t *testing.T) {
 	}
 
 	ml := &mockLogger{}
-	f := New(mc, mn, mr, nil, ml, 3)
+	f := New(mc, mn, mr, nil, slog.New(ml), 3)
 
 	feed := repository.Feed{ID: 1, URL: "http://example.com/feed"}
 
@@ -1010,7 +1215,7 @@ func TestFetchFeed_Invariants(t *testing.T) {
 		t.Run(scenario.name, func(t *testing.T) {
 			mc, mn, mr := scenario.setupMocks()
 			ml := &mockLogger{}
-			f := New(mc, mn, mr, nil, ml, 3)
+			f := New(mc, mn, mr, nil, slog.New(ml), 3)
 
 			feed := repository.Feed{ID: 1, URL: "http://example.com/feed"}
 			result := f.FetchFeed(context.Background(), feed)
@@ -1107,7 +1312,7 @@ func TestFetchFeed_Integration_RedirectThenSuccess(t *testing.T) {
 	normalizer := normalizer.New()
 	logger := &mockLogger{}
 
-	fetcher := New(crawler, normalizer, repo, nil, logger, 3)
+	fetcher := New(crawler, normalizer, repo, nil, slog.New(logger), 3)
 
 	// Execute: Fetch the feed
 	feed, err := repo.GetFeedByURL(context.Background(), server.URL)
@@ -1244,7 +1449,7 @@ func TestFetchFeed_PartialEntryStorageFailure(t *testing.T) {
 		crawler:    mc,
 		normalizer: mn,
 		repo:       mr,
-		logger:     ml,
+		logger:     slog.New(ml),
 	}
 
 	result := f.FetchFeed(context.Background(), repository.Feed{ID: 1, URL: "https://example.com/feed"})
@@ -1300,7 +1505,7 @@ func TestFetchFeed_CascadeDBFailures(t *testing.T) {
 		crawler:    mc,
 		normalizer: mn,
 		repo:       mr,
-		logger:     ml,
+		logger:     slog.New(ml),
 	}
 
 	result := f.FetchFeed(context.Background(), repository.Feed{ID: 1, URL: "https://example.com/feed"})
@@ -1339,7 +1544,7 @@ func TestFetchFeed_IntermittentNetworkError(t *testing.T) {
 		crawler:    mc,
 		normalizer: mn,
 		repo:       mr,
-		logger:     ml,
+		logger:     slog.New(ml),
 	}
 
 	result := f.FetchFeed(context.Background(), repository.Feed{ID: 1, URL: "https://example.com/feed"})
@@ -1352,7 +1557,7 @@ func TestFetchFeed_IntermittentNetworkError(t *testing.T) {
 	// Should have logged the timeout
 	foundTimeout := false
 	for _, call := range ml.errorCalls {
-		if contains(call, "failed for") { // Format: "%s failed for %s: %v"
+		if call == "Feed fetch failed" {
 			foundTimeout = true
 			break
 		}
@@ -1394,7 +1599,7 @@ func TestFetchFeed_ParseErrorWithDBRecordingFailure(t *testing.T) {
 		crawler:    mc,
 		normalizer: mn,
 		repo:       mr,
-		logger:     ml,
+		logger:     slog.New(ml),
 	}
 
 	result := f.FetchFeed(context.Background(), repository.Feed{ID: 1, URL: "https://example.com/feed"})
@@ -1408,7 +1613,7 @@ func TestFetchFeed_ParseErrorWithDBRecordingFailure(t *testing.T) {
 	foundParseError := false
 	foundRecordingError := false
 	for _, call := range ml.errorCalls {
-		if contains(call, "failed for") { // Format: "%s failed for %s: %v" (operation = "parse")
+		if call == "Feed parse failed" {
 			foundParseError = true
 		}
 		if contains(call, "Failed to update feed error") {
@@ -1427,3 +1632,734 @@ func TestFetchFeed_ParseErrorWithDBRecordingFailure(t *testing.T) {
 		t.Error("Expected error result for parse failure")
 	}
 }
+
+func TestFetchFeed_SkipsFreshCache(t *testing.T) {
+	t.Parallel()
+	expires := time.Now().Add(time.Hour).Truncate(time.Second)
+	fetched := false
+	mc := &mockCrawler{
+		responseFunc: func() (*crawler.FeedResponse, error) {
+			fetched = true
+			return &crawler.FeedResponse{
+				StatusCode:  304,
+				NotModified: true,
+				FetchTime:   time.Now(),
+				NewCache:    crawler.FeedCache{Expires: expires},
+			}, nil
+		},
+	}
+	mr := &mockRepository{}
+	f := New(mc, &mockNormalizer{}, mr, nil, slog.New(&mockLogger{}), 0)
+
+	feed := repository.Feed{ID: 1, URL: "http://example.com/feed", CacheExpires: time.Now().Add(time.Minute)}
+
+	result := f.FetchFeed(context.Background(), feed)
+	if !result.Skipped || fetched {
+		t.Fatalf("Expected fresh feed to be skipped without fetching, got %+v (fetched=%v)", result, fetched)
+	}
+	if mr.recordFetchCount != 0 {
+		t.Error("Skipped feeds should not be recorded in the fetch log")
+	}
+
+	// --force fetches anyway and stores the new expiry
+	f.SetForce(true)
+	if reason := f.SkipReason(feed); reason != "" {
+		t.Errorf("SkipReason() = %q, want empty when forced", reason)
+	}
+	result = f.FetchFeed(context.Background(), feed)
+	if result.Skipped || !fetched {
+		t.Fatalf("Expected forced fetch, got %+v (fetched=%v)", result, fetched)
+	}
+	if !mr.cacheExpires.Equal(expires) {
+		t.Errorf("Stored cache expiry = %v, want %v", mr.cacheExpires, expires)
+	}
+}
+
+func TestFetchFeed_ExpiredCacheIsFetched(t *testing.T) {
+	t.Parallel()
+	mc := &mockCrawler{
+		resp: &crawler.FeedResponse{StatusCode: 304, NotModified: true, FetchTime: time.Now()},
+	}
+	f := New(mc, &mockNormalizer{}, &mockRepository{}, nil, slog.New(&mockLogger{}), 0)
+
+	feed := repository.Feed{ID: 1, URL: "http://example.com/feed", CacheExpires: time.Now().Add(-time.Minute)}
+	if result := f.FetchFeed(context.Background(), feed); result.Skipped || !result.NotModified {
+		t.Errorf("Expected expired cache to be fetched, got %+v", result)
+	}
+}
+
+func TestFetchFeed_HostBackoff(t *testing.T) {
+	t.Parallel()
+	status, retryAfter := http.StatusTooManyRequests, time.Hour
+	fetches := 0
+	mc := &mockCrawler{
+		responseFunc: func() (*crawler.FeedResponse, error) {
+			fetches++
+			if status != http.StatusOK {
+				return &crawler.FeedResponse{StatusCode: status, RetryAfter: retryAfter}, errors.New("max retries exceeded")
+			}
+			return &crawler.FeedResponse{StatusCode: 304, NotModified: true, FetchTime: time.Now()}, nil
+		},
+	}
+	mr := &mockRepository{}
+	f := New(mc, &mockNormalizer{}, mr, nil, slog.New(&mockLogger{}), 0)
+	feed := repository.Feed{ID: 1, URL: "https://Blog.example.com/feed"}
+	other := repository.Feed{ID: 2, URL: "https://blog.example.com/comments/feed"}
+
+	// 429 with Retry-After records a backoff for the host
+	if result := f.FetchFeed(context.Background(), feed); result.Error == nil {
+		t.Fatal("Expected fetch error for 429")
+	}
+	b, ok := mr.hostBackoffs["blog.example.com"]
+	if !ok {
+		t.Fatal("No backoff recorded for blog.example.com")
+	}
+	if wait := time.Until(b.Until); wait < 59*time.Minute || wait > time.Hour {
+		t.Errorf("Backoff until %v, want about an hour from now", b.Until)
+	}
+	if b.StatusCode != http.StatusTooManyRequests || b.Failures != 1 {
+		t.Errorf("Backoff = %+v, want status 429 and 1 failure", b)
+	}
+
+	// Other feeds on the host are skipped without contacting it
+	result := f.FetchFeed(context.Background(), other)
+	if !result.Skipped || !strings.Contains(result.SkipReason, "blog.example.com") {
+		t.Errorf("Expected feed on backed-off host to be skipped, got %+v", result)
+	}
+	if fetches != 1 {
+		t.Errorf("Host contacted %d times, want 1", fetches)
+	}
+
+	// Once the backoff expires, a 503 without Retry-After doubles the wait
+	b.Until = time.Now().Add(-time.Minute)
+	mr.hostBackoffs[b.Host] = b
+	status, retryAfter = http.StatusServiceUnavailable, 0
+	f.FetchFeed(context.Background(), other)
+	b = mr.hostBackoffs["blog.example.com"]
+	if b.Failures != 2 {
+		t.Errorf("Failures = %d, want 2", b.Failures)
+	}
+	if wait := time.Until(b.Until); wait < 29*time.Minute || wait > 30*time.Minute {
+		t.Errorf("Backoff until %v, want 30 minutes from now", b.Until)
+	}
+
+	// --force fetches anyway, and a normal answer clears the backoff
+	status = http.StatusOK
+	f.SetForce(true)
+	if result := f.FetchFeed(context.Background(), feed); result.Skipped || !result.NotModified {
+		t.Fatalf("Expected forced fetch, got %+v", result)
+	}
+	if _, ok := mr.hostBackoffs["blog.example.com"]; ok {
+		t.Error("Backoff not cleared after a successful fetch")
+	}
+}
+
+func TestFetchFeed_AdaptiveScheduling(t *testing.T) {
+	t.Parallel()
+	mc := &mockCrawler{
+		resp: &crawler.FeedResponse{StatusCode: 304, NotModified: true, FetchTime: time.Now()},
+	}
+	now := time.Now()
+	mr := &mockRepository{
+		// Posts every 4 hours, most recently an hour ago
+		entryTimes: []time.Time{now.Add(-time.Hour), now.Add(-5 * time.Hour), now.Add(-9 * time.Hour)},
+	}
+	f := New(mc, &mockNormalizer{}, mr, nil, slog.New(&mockLogger{}), 0)
+	f.SetScheduler(scheduler.New(30*time.Minute, 24*time.Hour))
+
+	// Not yet due: skipped
+	notDue := repository.Feed{ID: 1, URL: "http://example.com/feed", NextFetch: now.Add(time.Hour)}
+	if result := f.FetchFeed(context.Background(), notDue); !result.Skipped {
+		t.Fatalf("Expected feed that is not due to be skipped, got %+v", result)
+	}
+	if reason := f.SkipReason(notDue); !strings.HasPrefix(reason, "not due until") {
+		t.Errorf("SkipReason() = %q, want not due", reason)
+	}
+
+	// Due: fetched and rescheduled from its cadence
+	due := repository.Feed{ID: 1, URL: "http://example.com/feed", NextFetch: now.Add(-time.Minute)}
+	if result := f.FetchFeed(context.Background(), due); result.Skipped || result.Error != nil {
+		t.Fatalf("Expected due feed to be fetched, got %+v", result)
+	}
+	if mr.scheduleInterval != 2*time.Hour {
+		t.Errorf("Scheduled interval = %v, want 2h", mr.scheduleInterval)
+	}
+	if d := mr.nextFetch.Sub(time.Now().Add(2 * time.Hour)); d > time.Minute || d < -time.Minute {
+		t.Errorf("Next fetch = %v, want about 2h from now", mr.nextFetch)
+	}
+}
+
+func TestFetchFeed_NoSchedulerIgnoresNextFetch(t *testing.T) {
+	t.Parallel()
+	mc := &mockCrawler{
+		resp: &crawler.FeedResponse{StatusCode: 304, NotModified: true, FetchTime: time.Now()},
+	}
+	mr := &mockRepository{}
+	f := New(mc, &mockNormalizer{}, mr, nil, slog.New(&mockLogger{}), 0)
+
+	feed := repository.Feed{ID: 1, URL: "http://example.com/feed", NextFetch: time.Now().Add(time.Hour)}
+	if result := f.FetchFeed(context.Background(), feed); result.Skipped {
+		t.Error("Without a scheduler, next_fetch should not cause a skip")
+	}
+	if !mr.nextFetch.IsZero() {
+		t.Error("Without a scheduler, no schedule should be written")
+	}
+}
+
+func TestFetchFeed_Filters(t *testing.T) {
+	t.Parallel()
+	mc := &mockCrawler{
+		resp: &crawler.FeedResponse{Body: []byte("<feed/>"), StatusCode: 200, FetchTime: time.Now()},
+	}
+	mn := &mockNormalizer{
+		metadata: &normalizer.FeedMetadata{Title: "Test Feed"},
+		entries: []normalizer.Entry{
+			{ID: "1", Title: "Weekly notes"},
+			{ID: "2", Title: "Sponsored: a word from our friends"},
+			{ID: "3", Title: "Release day", Categories: []string{"Ads"}},
+		},
+	}
+	var stored []string
+	mr := &mockRepository{
+		upsertEntryFunc: func(entry *repository.Entry) error {
+			stored = append(stored, entry.EntryID)
+			return nil
+		},
+	}
+
+	filters, err := filter.NewSet(
+		filter.Rules{ExcludeKeywords: []string{"sponsored"}},
+		map[string]filter.Rules{"http://example.com/feed": {ExcludeCategories: []string{"ads"}}},
+	)
+	if err != nil {
+		t.Fatalf("NewSet() error = %v", err)
+	}
+
+	f := New(mc, mn, mr, nil, slog.New(&mockLogger{}), 0)
+	f.SetFilters(filters)
+
+	result := f.FetchFeed(context.Background(), repository.Feed{ID: 1, URL: "http://example.com/feed"})
+	if result.Error != nil {
+		t.Fatalf("FetchFeed() error = %v", result.Error)
+	}
+	if result.StoredEntries != 1 || result.Filtered != 2 {
+		t.Errorf("StoredEntries = %d, Filtered = %d; want 1, 2", result.StoredEntries, result.Filtered)
+	}
+	if len(stored) != 1 || stored[0] != "1" {
+		t.Errorf("stored entries = %v, want [1]", stored)
+	}
+}
+
+func TestFetchFeed_Hooks(t *testing.T) {
+	t.Parallel()
+	if runtime.GOOS == "windows" {
+		t.Skip("hook command is a POSIX shell script")
+	}
+	mc := &mockCrawler{
+		resp: &crawler.FeedResponse{Body: []byte("<feed/>"), StatusCode: 200, FetchTime: time.Now()},
+	}
+	mn := &mockNormalizer{
+		metadata: &normalizer.FeedMetadata{Title: "Test Feed"},
+		entries: []normalizer.Entry{
+			{ID: "1", Title: "Weekly notes", Content: "<p>Notes</p>"},
+			{ID: "2", Title: "Sponsored: a word from our friends"},
+		},
+	}
+	stored := make(map[string]*repository.Entry)
+	mr := &mockRepository{
+		upsertEntryFunc: func(entry *repository.Entry) error {
+			stored[entry.EntryID] = entry
+			return nil
+		},
+	}
+
+	h := &hooks.Hooks{
+		PostEntry: []string{`grep -q Sponsored && echo '{"drop": true}' || echo '{"content": "<p>More notes</p><script>x</script>", "categories": ["notes"]}'`},
+		Timeout:   time.Minute,
+	}
+	f := New(mc, mn, mr, nil, slog.New(&mockLogger{}), 0)
+	f.SetEntryHooks(func(feedURL, html string) string {
+		return strings.ReplaceAll(html, "<script>x</script>", "")
+	}, h)
+
+	result := f.FetchFeed(context.Background(), repository.Feed{ID: 1, URL: "http://example.com/feed"})
+	if result.Error != nil {
+		t.Fatalf("FetchFeed() error = %v", result.Error)
+	}
+	if result.StoredEntries != 1 || result.Filtered != 1 {
+		t.Errorf("StoredEntries = %d, Filtered = %d; want 1, 1", result.StoredEntries, result.Filtered)
+	}
+	entry := stored["1"]
+	if entry == nil {
+		t.Fatalf("stored entries = %v, want entry 1", stored)
+	}
+	if entry.Title != "Weekly notes" || entry.Content != "<p>More notes</p>" || !reflect.DeepEqual(entry.Categories, []string{"notes"}) || entry.WordCount != 2 {
+		t.Errorf("stored entry = %+v, want the hook's content, sanitized, and categories", entry)
+	}
+}
+
+func TestFetchFeed_ExtractsFullContent(t *testing.T) {
+	t.Parallel()
+
+	article := "<p>" + strings.Repeat("A full paragraph of the article, with plenty of words in it. ", 30) + "</p>"
+	var pageFetches []string
+	var mu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		mu.Lock()
+		pageFetches = append(pageFetches, r.URL.Path)
+		mu.Unlock()
+		_, _ = w.Write([]byte("<html><body><nav>Menu</nav><article>" + article + "</article></body></html>"))
+	}))
+	defer server.Close()
+
+	const feedURL = "http://example.com/feed"
+	mc := &mockCrawler{
+		resp: &crawler.FeedResponse{Body: []byte("<feed/>"), StatusCode: 200, FetchTime: time.Now()},
+	}
+	mn := &mockNormalizer{
+		metadata: &normalizer.FeedMetadata{Title: "Test Feed"},
+		entries: []normalizer.Entry{
+			{ID: "new", Link: server.URL + "/new", Content: "<p>Teaser…</p>"},
+			{ID: "stored", Link: server.URL + "/stored", Content: "<p>Teaser…</p>"},
+			{ID: "full", Link: server.URL + "/full", Content: article},
+			{ID: "nolink", Content: "<p>Teaser…</p>"},
+		},
+	}
+	stored := make(map[string]*repository.Entry)
+	mr := &mockRepository{
+		storedContents: map[string]string{"stored": article},
+		upsertEntryFunc: func(entry *repository.Entry) error {
+			stored[entry.EntryID] = entry
+			return nil
+		},
+	}
+
+	e := extract.New(crawler.NewForTesting(), func(_, content string) string { return content }, []string{feedURL}, nil)
+	f := New(mc, mn, mr, nil, slog.New(&mockLogger{}), 0)
+	f.SetExtractor(e)
+
+	result := f.FetchFeed(context.Background(), repository.Feed{ID: 1, URL: feedURL})
+	if result.Error != nil {
+		t.Fatalf("FetchFeed() error = %v", result.Error)
+	}
+
+	if len(pageFetches) != 1 || pageFetches[0] != "/new" {
+		t.Errorf("pages fetched = %v, want only the new summary-only entry", pageFetches)
+	}
+	if got := stored["new"]; !strings.Contains(got.Content, "A full paragraph") || got.Summary != "<p>Teaser…</p>" {
+		t.Errorf("new entry content = %q, summary = %q; want article with teaser as summary", got.Content, got.Summary)
+	}
+	if got := stored["stored"]; !strings.Contains(got.Content, "A full paragraph") {
+		t.Errorf("stored entry content = %q, want previously extracted article kept", got.Content)
+	}
+	if got := stored["nolink"]; got.Content != "<p>Teaser…</p>" {
+		t.Errorf("entry without link content = %q, want unchanged", got.Content)
+	}
+}
+
+func TestFetchFeed_PreviewImages(t *testing.T) {
+	t.Parallel()
+
+	var pageFetches []string
+	var mu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		mu.Lock()
+		pageFetches = append(pageFetches, r.URL.Path)
+		mu.Unlock()
+		_, _ = w.Write([]byte(`<html><head><meta property="og:image" content="/cards` + r.URL.Path + `.png"></head><body><p>Teaser</p></body></html>`))
+	}))
+	defer server.Close()
+
+	const feedURL = "http://example.com/feed"
+	mc := &mockCrawler{
+		resp: &crawler.FeedResponse{Body: []byte("<feed/>"), StatusCode: 200, FetchTime: time.Now()},
+	}
+	mn := &mockNormalizer{
+		metadata: &normalizer.FeedMetadata{Title: "Test Feed"},
+		entries: []normalizer.Entry{
+			{ID: "new", Link: server.URL + "/new", Content: "<p>Teaser</p>"},
+			{ID: "pictured", Link: server.URL + "/pictured", Content: "<p>Teaser</p>", Image: "https://cdn.example.com/own.png"},
+			{ID: "stored", Link: server.URL + "/stored", Content: "<p>Teaser</p>"},
+		},
+	}
+	stored := make(map[string]*repository.Entry)
+	mr := &mockRepository{
+		storedContents: map[string]string{"stored": "<p>Teaser</p>"},
+		upsertEntryFunc: func(entry *repository.Entry) error {
+			stored[entry.EntryID] = entry
+			return nil
+		},
+	}
+
+	// Images only: summaries are left as they are
+	e := extract.New(crawler.NewForTesting(), func(_, content string) string { return content }, nil, []string{feedURL})
+	f := New(mc, mn, mr, nil, slog.New(&mockLogger{}), 0)
+	f.SetExtractor(e)
+
+	if result := f.FetchFeed(context.Background(), repository.Feed{ID: 1, URL: feedURL}); result.Error != nil {
+		t.Fatalf("FetchFeed() error = %v", result.Error)
+	}
+
+	if len(pageFetches) != 1 || pageFetches[0] != "/new" {
+		t.Errorf("pages fetched = %v, want only the new entry without an image", pageFetches)
+	}
+	if got := stored["new"]; got.Image != server.URL+"/cards/new.png" || got.Content != "<p>Teaser</p>" {
+		t.Errorf("new entry image = %q, content = %q; want the page's og:image and content unchanged", got.Image, got.Content)
+	}
+	if got := stored["pictured"].Image; got != "https://cdn.example.com/own.png" {
+		t.Errorf("entry with its own image has %q, want it kept", got)
+	}
+}
+
+func TestFetchFeed_ErrorBackoffAndDeactivation(t *testing.T) {
+	t.Parallel()
+	mc := &mockCrawler{err: errors.New("connection refused")}
+	mr := &mockRepository{}
+	f := New(mc, &mockNormalizer{}, mr, nil, slog.New(&mockLogger{}), 0)
+	f.SetDeactivateAfter(5)
+
+	// The wait doubles with each consecutive error
+	for failures, want := range map[int]time.Duration{0: 30 * time.Minute, 2: 2 * time.Hour} {
+		feed := repository.Feed{ID: 1, URL: "http://example.com/feed", FetchErrorCount: failures}
+		f.FetchFeed(context.Background(), feed)
+		if wait := time.Until(mr.nextFetch); wait < want-time.Minute || wait > want {
+			t.Errorf("After %d prior errors next fetch in %v, want %v", failures, wait, want)
+		}
+	}
+	if mr.deactivated {
+		t.Fatal("Feed deactivated before reaching the limit")
+	}
+
+	// A feed backing off is skipped until its next fetch
+	feed := repository.Feed{ID: 1, URL: "http://example.com/feed", FetchErrorCount: 3, NextFetch: time.Now().Add(time.Hour)}
+	if result := f.FetchFeed(context.Background(), feed); !result.Skipped || !strings.Contains(result.SkipReason, "3 consecutive errors") {
+		t.Errorf("Expected failing feed to be skipped, got %+v", result)
+	}
+
+	// The fifth consecutive error deactivates it
+	feed = repository.Feed{ID: 1, URL: "http://example.com/feed", FetchErrorCount: 4}
+	f.FetchFeed(context.Background(), feed)
+	if !mr.deactivated {
+		t.Error("Feed not deactivated after 5 consecutive errors")
+	}
+}
+
+func TestErrorBackoff(t *testing.T) {
+	t.Parallel()
+	tests := map[int]time.Duration{1: 30 * time.Minute, 2: time.Hour, 4: 4 * time.Hour, 6: 16 * time.Hour, 7: MaxErrorBackoff, 100: MaxErrorBackoff}
+	for failures, want := range tests {
+		if got := errorBackoff(failures); got != want {
+			t.Errorf("errorBackoff(%d) = %v, want %v", failures, got, want)
+		}
+	}
+}
+
+func TestFetchFeed_Gone(t *testing.T) {
+	t.Parallel()
+
+	t.Run("410 Gone", func(t *testing.T) {
+		t.Parallel()
+		mc := &mockCrawler{responseFunc: func() (*crawler.FeedResponse, error) {
+			return &crawler.FeedResponse{StatusCode: http.StatusGone}, crawler.ErrGone
+		}}
+		mr := &mockRepository{}
+		f := New(mc, &mockNormalizer{}, mr, nil, slog.New(&mockLogger{}), 0)
+
+		f.FetchFeed(context.Background(), repository.Feed{ID: 1, URL: "http://example.com/feed"})
+		if mr.goneAt.IsZero() {
+			t.Error("Feed not marked gone after 410")
+		}
+	})
+
+	t.Run("repeated NXDOMAIN", func(t *testing.T) {
+		t.Parallel()
+		mc := &mockCrawler{err: fmt.Errorf("fetch failed: %w: lookup vanished.example: no such host", crawler.ErrHostNotFound)}
+		mr := &mockRepository{}
+		f := New(mc, &mockNormalizer{}, mr, nil, slog.New(&mockLogger{}), 0)
+		feed := repository.Feed{ID: 1, URL: "http://vanished.example/feed"}
+
+		for i := 1; i < GoneAfterHostNotFound; i++ {
+			f.FetchFeed(context.Background(), feed)
+			if !mr.goneAt.IsZero() {
+				t.Fatalf("Feed marked gone after %d NXDOMAIN errors, want %d", i, GoneAfterHostNotFound)
+			}
+		}
+		f.FetchFeed(context.Background(), feed)
+		if mr.goneAt.IsZero() {
+			t.Errorf("Feed not marked gone after %d NXDOMAIN errors", GoneAfterHostNotFound)
+		}
+	})
+
+	t.Run("NXDOMAIN after other errors", func(t *testing.T) {
+		t.Parallel()
+		mr := &mockRepository{fetchLog: []repository.FetchLogEntry{{Error: "connection refused"}}}
+		for i := 0; i < GoneAfterHostNotFound-1; i++ {
+			mr.fetchLog = append([]repository.FetchLogEntry{{Error: crawler.ErrHostNotFound.Error()}}, mr.fetchLog...)
+		}
+		mc := &mockCrawler{err: crawler.ErrHostNotFound}
+		f := New(mc, &mockNormalizer{}, mr, nil, slog.New(&mockLogger{}), 0)
+
+		f.FetchFeed(context.Background(), repository.Feed{ID: 1, URL: "http://vanished.example/feed"})
+		if mr.goneAt.IsZero() {
+			t.Error("Feed not marked gone when its last fetches all found no host")
+		}
+	})
+}
+
+func TestContentFingerprint(t *testing.T) {
+	t.Parallel()
+	base := normalizer.Entry{Title: "Hello", Content: "<p>Some <b>text</b> here.</p>"}
+	same := []normalizer.Entry{
+		{Title: "Hello", Content: "<div>Some   text\nhere.</div>"},
+		{Title: "hello", Content: "<p>SOME <i>text</i> here.</p>"},
+		{Title: "Hello", Summary: "Some text here."},
+	}
+	for _, e := range same {
+		if contentFingerprint(e) != contentFingerprint(base) {
+			t.Errorf("contentFingerprint(%+v) differs from the same text with other markup", e)
+		}
+	}
+	for _, e := range []normalizer.Entry{
+		{Title: "Hello", Content: "<p>Other text here.</p>"},
+		{Title: "Goodbye", Content: "<p>Some text here.</p>"},
+	} {
+		if contentFingerprint(e) == contentFingerprint(base) {
+			t.Errorf("contentFingerprint(%+v) should differ", e)
+		}
+	}
+	if got := contentFingerprint(normalizer.Entry{Content: "<img src=x>"}); got != "" {
+		t.Errorf("contentFingerprint of an entry without text = %q, want empty", got)
+	}
+}
+
+func TestFetchFeed_RepublishedEntries(t *testing.T) {
+	t.Parallel()
+	repo, err := repository.New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+	ctx := context.Background()
+	feedID, err := repo.AddFeed(ctx, "http://example.com/feed", "Blog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	feed := repository.Feed{ID: feedID, URL: "http://example.com/feed"}
+
+	mc := &mockCrawler{resp: &crawler.FeedResponse{Body: []byte("<feed/>"), StatusCode: 200, FetchTime: time.Now()}}
+	mn := &mockNormalizer{metadata: &normalizer.FeedMetadata{Title: "Blog"}}
+	f := New(mc, mn, repo, nil, slog.New(&mockLogger{}), 3)
+	post := func(id, link, content string) normalizer.Entry {
+		return normalizer.Entry{ID: id, Title: "Post", Link: link, Content: content, Published: time.Now(), Updated: time.Now(), FirstSeen: time.Now()}
+	}
+
+	mn.entries = []normalizer.Entry{post("?p=1", "http://example.com/post", "<p>Hello</p>")}
+	f.FetchFeed(ctx, feed)
+	if _, err := repo.HideEntries(ctx, "?p=1", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	// The blog moved: same post and link, new GUID and markup
+	mn.entries = []normalizer.Entry{post("https://example.com/?p=1", "http://example.com/post", "<div>Hello</div>")}
+	f.FetchFeed(ctx, feed)
+	if n, _ := repo.CountEntries(ctx); n != 1 {
+		t.Fatalf("CountEntries() = %d after the ID changed, want 1", n)
+	}
+	hidden, err := repo.GetHiddenEntries(ctx)
+	if err != nil || len(hidden) != 1 || hidden[0].EntryID != "https://example.com/?p=1" {
+		t.Errorf("hidden entries = %+v, %v; want the entry under its new ID", hidden, err)
+	}
+	log, err := repo.GetFetchLog(ctx, feedID, 1)
+	if err != nil || len(log) != 1 || log[0].EntriesAdded != 0 {
+		t.Errorf("fetch log = %+v, %v; want no entries added", log, err)
+	}
+
+	// Same link with different text, or both IDs still in the feed, are separate entries
+	mn.entries = []normalizer.Entry{
+		post("https://example.com/?p=1", "http://example.com/post", "<div>Hello</div>"),
+		post("copy", "http://example.com/post", "<div>Hello</div>"),
+		post("edited", "http://example.com/post", "<p>Hello, world</p>"),
+	}
+	f.FetchFeed(ctx, feed)
+	if n, _ := repo.CountEntries(ctx); n != 3 {
+		t.Errorf("CountEntries() = %d, want 3", n)
+	}
+}
+
+func TestFetchFeed_ServerCharset(t *testing.T) {
+	t.Parallel()
+	// "Crème brûlée" in ISO-8859-1, in a feed with no XML declaration
+	feed := "<rss version=\"2.0\"><channel><title>Caf\xe9</title><item><title>Cr\xe8me br\xfbl\xe9e</title><guid>1</guid><pubDate>" +
+		time.Now().UTC().Format(time.RFC1123Z) + "</pubDate></item></channel></rss>"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml; charset=ISO-8859-1")
+		w.Write([]byte(feed))
+	}))
+	defer server.Close()
+
+	repo, err := repository.New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create repository: %v", err)
+	}
+	defer repo.Close()
+	if _, err := repo.AddFeed(context.Background(), server.URL, ""); err != nil {
+		t.Fatalf("Failed to add feed: %v", err)
+	}
+	f, err := repo.GetFeedByURL(context.Background(), server.URL)
+	if err != nil {
+		t.Fatalf("Failed to get feed: %v", err)
+	}
+
+	fetcher := New(crawler.NewForTesting(), normalizer.New(), repo, nil, slog.New(&mockLogger{}), 0)
+	if result := fetcher.FetchFeed(context.Background(), *f); result.Error != nil {
+		t.Fatalf("FetchFeed() error = %v", result.Error)
+	}
+
+	entries, err := repo.GetRecentEntries(context.Background(), 7)
+	if err != nil {
+		t.Fatalf("GetRecentEntries() error = %v", err)
+	}
+	if len(entries) != 1 || entries[0].Title != "Crème brûlée" {
+		t.Errorf("entries = %+v, want one titled Crème brûlée", entries)
+	}
+}
+
+func TestFetchFeed_UndatedAndFutureEntries(t *testing.T) {
+	t.Parallel()
+	repo, err := repository.New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+	ctx := context.Background()
+	feedID, err := repo.AddFeed(ctx, "http://example.com/feed", "Blog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	feed := repository.Feed{ID: feedID, URL: "http://example.com/feed"}
+
+	// As in Venus, an entry with no date is dated when first seen and keeps
+	// that date on later fetches instead of rising to the top each time, as
+	// does one dated in the future
+	body := []byte(`<rss version="2.0"><channel><title>Blog</title><link>http://example.com/</link>
+<item><title>No date</title><link>http://example.com/undated</link><guid>undated</guid></item>
+<item><title>Future</title><link>http://example.com/future</link><guid>future</guid><pubDate>Mon, 01 Jan 2035 00:00:00 GMT</pubDate></item>
+</channel></rss>`)
+	firstFetch := time.Now().Add(-2 * time.Hour).Truncate(time.Second)
+	fetchTime := firstFetch
+	mc := &mockCrawler{responseFunc: func() (*crawler.FeedResponse, error) {
+		return &crawler.FeedResponse{Body: body, StatusCode: 200, FetchTime: fetchTime}, nil
+	}}
+	f := New(mc, normalizer.New(), repo, nil, slog.New(&mockLogger{}), 0)
+
+	if result := f.FetchFeed(ctx, feed); result.Error != nil {
+		t.Fatalf("first FetchFeed() error = %v", result.Error)
+	}
+	fetchTime = time.Now().Truncate(time.Second)
+	if result := f.FetchFeed(ctx, feed); result.Error != nil {
+		t.Fatalf("second FetchFeed() error = %v", result.Error)
+	}
+
+	entries, err := repo.GetRecentEntries(ctx, 7)
+	if err != nil {
+		t.Fatalf("GetRecentEntries() error = %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(entries))
+	}
+	for _, e := range entries {
+		if !e.Published.Equal(firstFetch) {
+			t.Errorf("entry %q Published = %v, want the first fetch, %v", e.EntryID, e.Published, firstFetch)
+		}
+	}
+}
+
+func TestFetchFeed_Quota(t *testing.T) {
+	t.Parallel()
+	repo, err := repository.New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+	repo.SetQuota(repository.Quota{MaxEntriesPerFeed: 2})
+	ctx := context.Background()
+	feedID, err := repo.AddFeed(ctx, "http://example.com/feed", "Blog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	feed := repository.Feed{ID: feedID, URL: "http://example.com/feed"}
+
+	body := []byte(`<rss version="2.0"><channel><title>Blog</title><link>http://example.com/</link>
+<item><title>One</title><link>http://example.com/1</link><guid>1</guid><pubDate>Mon, 01 Jan 2024 00:00:00 GMT</pubDate></item>
+<item><title>Two</title><link>http://example.com/2</link><guid>2</guid><pubDate>Tue, 02 Jan 2024 00:00:00 GMT</pubDate></item>
+<item><title>Three</title><link>http://example.com/3</link><guid>3</guid><pubDate>Wed, 03 Jan 2024 00:00:00 GMT</pubDate></item>
+</channel></rss>`)
+	mc := &mockCrawler{responseFunc: func() (*crawler.FeedResponse, error) {
+		return &crawler.FeedResponse{Body: body, StatusCode: 200, FetchTime: time.Now()}, nil
+	}}
+	f := New(mc, normalizer.New(), repo, nil, slog.New(&mockLogger{}), 0)
+
+	if result := f.FetchFeed(ctx, feed); result.Error != nil || result.StoredEntries != 3 {
+		t.Fatalf("first FetchFeed() = %d stored, %v; want 3", result.StoredEntries, result.Error)
+	}
+	if count, _ := repo.GetEntryCountForFeed(ctx, feedID); count != 2 {
+		t.Errorf("entries after the first fetch = %d, want the quota's 2", count)
+	}
+
+	// The entry evicted last time is not stored again
+	if result := f.FetchFeed(ctx, feed); result.Error != nil || result.StoredEntries != 2 {
+		t.Fatalf("second FetchFeed() = %d stored, %v; want 2", result.StoredEntries, result.Error)
+	}
+	history, err := repo.GetFetchLog(ctx, feedID, 1)
+	if err != nil || len(history) != 1 || history[0].EntriesAdded != 0 {
+		t.Errorf("second fetch log = %+v, %v; want no entries added", history, err)
+	}
+	if got, err := repo.FindEntries(ctx, "1"); err != nil || len(got) != 0 {
+		t.Errorf("oldest entry stored again: %+v, %v", got, err)
+	}
+}
+
+func TestFetchFeed_StoresAttachments(t *testing.T) {
+	t.Parallel()
+	repo, err := repository.New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+	ctx := context.Background()
+	feedID, err := repo.AddFeed(ctx, "https://example.com/feed.json", "Podcast")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := []byte(`{"version": "https://jsonfeed.org/version/1.1", "title": "Podcast", "items": [{"id": "ep1", "url": "https://example.com/ep1",
+"content_text": "Episode one", "date_published": "` + time.Now().UTC().Format(time.RFC3339) + `", "language": "en",
+"authors": [{"name": "Ann"}, {"name": "Bob"}], "external_url": "https://elsewhere.example.org/",
+"attachments": [{"url": "https://example.com/ep1.mp3", "mime_type": "audio/mpeg", "size_in_bytes": 1000, "duration_in_seconds": 60}]}]}`)
+	mc := &mockCrawler{resp: &crawler.FeedResponse{Body: body, StatusCode: 200, FetchTime: time.Now()}}
+	f := New(mc, normalizer.New(), repo, nil, slog.New(&mockLogger{}), 0)
+	if result := f.FetchFeed(ctx, repository.Feed{ID: feedID, URL: "https://example.com/feed.json"}); result.Error != nil {
+		t.Fatalf("FetchFeed() error = %v", result.Error)
+	}
+
+	entries, err := repo.GetRecentEntries(ctx, 7)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("GetRecentEntries() = %d entries, %v", len(entries), err)
+	}
+	e := entries[0]
+	want := []repository.Attachment{{URL: "https://example.com/ep1.mp3", MimeType: "audio/mpeg", Size: 1000, Duration: 60, Medium: "audio"}}
+	if !reflect.DeepEqual(e.Attachments, want) {
+		t.Errorf("Attachments = %+v, want %+v", e.Attachments, want)
+	}
+	if !reflect.DeepEqual(e.Authors, []string{"Ann", "Bob"}) || e.ExternalURL != "https://elsewhere.example.org/" || e.Language != "en" {
+		t.Errorf("Authors, ExternalURL, Language = %q, %q, %q", e.Authors, e.ExternalURL, e.Language)
+	}
+}
@@ -0,0 +1,45 @@
+package fetcher
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"golang.org/x/net/html"
+
+	"github.com/adewale/rogue_planet/pkg/normalizer"
+)
+
+// contentFingerprint hashes an entry's text, ignoring markup, case, and
+// whitespace, so the same post republished under a new ID (as happens when
+// a blog moves between platforms) can be matched with the stored copy. It
+// returns "" for entries with no text to compare.
+func contentFingerprint(entry normalizer.Entry) string {
+	body := entry.Content
+	if body == "" {
+		body = entry.Summary
+	}
+	text := strings.ToLower(strings.Join(strings.Fields(textContent(entry.Title)+" "+textContent(body)), " "))
+	if text == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:])
+}
+
+// textContent returns the text of an HTML fragment with entities decoded
+func textContent(fragment string) string {
+	z := html.NewTokenizer(strings.NewReader(fragment))
+	var b strings.Builder
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return b.String()
+		case html.TextToken:
+			b.Write(z.Text())
+		default:
+			// Tags separate words
+			b.WriteByte(' ')
+		}
+	}
+}
@@ -0,0 +1,270 @@
+package fetcher
+
+import (
+	"context"
+	"runtime"
+	"time"
+
+	"github.com/adewale/rogue_planet/pkg/repository"
+)
+
+// DefaultBatchSize is the most feeds Run stores in one transaction
+const DefaultBatchSize = 50
+
+// RunOptions size the stages of Run and let the caller follow its progress.
+// The callbacks are called from several goroutines at once.
+type RunOptions struct {
+	FetchWorkers int           // Concurrent HTTP fetches (default: 1)
+	ParseWorkers int           // Concurrent parsers (default: GOMAXPROCS)
+	BatchSize    int           // Most feeds stored per transaction (default: DefaultBatchSize)
+	FeedTimeout  time.Duration // Limit on fetching, with its retries, and parsing one feed; 0 means none
+	WaitTimeout  time.Duration // Limit on Wait for one feed, apart from FeedTimeout; 0 means none
+
+	// Sequential takes each feed through fetching, parsing, and storing
+	// before starting the next, in place of the worker pools, so that only
+	// one feed's response and entries are in memory at a time
+	Sequential bool
+
+	// Wait is called before each fetch, e.g. for rate limiting. If it fails
+	// the feed is left alone.
+	Wait func(ctx context.Context, feedURL string) error
+
+	// Stop, when closed, stops new fetches from starting while those in
+	// flight finish and are stored. Optional.
+	Stop <-chan struct{}
+
+	OnStart func(index int, feed repository.Feed)                     // A feed's fetch is starting
+	OnDone  func(index int, feed repository.Feed, result FetchResult) // A feed was skipped or stored
+}
+
+// Run fetches feeds concurrently through three stages connected by bounded
+// queues: a pool of fetch workers doing HTTP, a pool of parse workers, and a
+// single writer that stores finished feeds in batches, one transaction per
+// batch. Only the writer writes to the repository, so no lock is needed and
+// SQLite never sees competing writers; reads (host backoffs, stored article
+// content) go straight to the database.
+//
+// Feeds whose fetch has not started when ctx is cancelled or opts.Stop is
+// closed are left alone, and get no OnDone call. Cancelling ctx also aborts
+// fetches in flight; closing Stop lets them finish. Feeds already fetched
+// are stored either way. Run returns once every feed has been dealt with.
+func (f *Fetcher) Run(ctx context.Context, feeds []repository.Feed, opts RunOptions) {
+	fetchWorkers := max(opts.FetchWorkers, 1)
+	parseWorkers := opts.ParseWorkers
+	if parseWorkers < 1 {
+		parseWorkers = runtime.GOMAXPROCS(0)
+	}
+	batchSize := opts.BatchSize
+	if batchSize < 1 {
+		batchSize = DefaultBatchSize
+	}
+
+	// accept is cancelled once no new fetch may start
+	accept, stopAccepting := context.WithCancel(ctx)
+	defer stopAccepting()
+	if opts.Stop != nil {
+		go func() {
+			select {
+			case <-opts.Stop:
+				stopAccepting()
+			case <-accept.Done():
+			}
+		}()
+	}
+
+	if opts.Sequential {
+		f.runSequential(ctx, accept, feeds, opts)
+		return
+	}
+
+	jobs := make(chan *job)
+	fetched := make(chan *job, parseWorkers)
+	parsed := make(chan *job, batchSize)
+
+	go func() {
+		defer close(jobs)
+		for i, feed := range feeds {
+			select {
+			case jobs <- f.newJob(i, feed):
+			case <-accept.Done():
+				return
+			}
+		}
+	}()
+
+	fetchersDone := make(chan struct{})
+	for range fetchWorkers {
+		go func() {
+			defer func() { fetchersDone <- struct{}{} }()
+			for j := range jobs {
+				f.runFetch(ctx, accept, j, opts, fetched, parsed)
+			}
+		}()
+	}
+
+	parsersDone := make(chan struct{})
+	for range parseWorkers {
+		go func() {
+			defer func() { parsersDone <- struct{}{} }()
+			for j := range fetched {
+				f.parse(j.ctx, j)
+				parsed <- j
+			}
+		}()
+	}
+
+	go func() {
+		for range fetchWorkers {
+			<-fetchersDone
+		}
+		close(fetched)
+		for range parseWorkers {
+			<-parsersDone
+		}
+		close(parsed)
+	}()
+
+	f.writeBatches(ctx, parsed, batchSize, opts)
+}
+
+// runFetch takes one feed through the fetch stage, passing it on to the
+// parsers, or straight to the writer if the fetch failed or was not modified.
+// The fetch does not start once accept is done.
+func (f *Fetcher) runFetch(ctx, accept context.Context, j *job, opts RunOptions, fetched, parsed chan<- *job) {
+	if accept.Err() != nil || closed(opts.Stop) {
+		j.log.Debug("Skipping feed (cancelled)")
+		return
+	}
+	if f.checkSkip(ctx, j) {
+		if opts.OnDone != nil {
+			opts.OnDone(j.index, j.feed, j.result)
+		}
+		return
+	}
+	if opts.OnStart != nil {
+		opts.OnStart(j.index, j.feed)
+	}
+
+	if opts.Wait != nil {
+		// Waiting ends when the run stops accepting feeds, so a rate-limited
+		// feed does not hold up a graceful stop
+		waitCtx, cancelWait := context.WithCancel(ctx)
+		if opts.WaitTimeout > 0 {
+			waitCtx, cancelWait = context.WithTimeout(ctx, opts.WaitTimeout)
+		}
+		stopWaiting := context.AfterFunc(accept, cancelWait)
+		err := opts.Wait(waitCtx, j.feed.URL)
+		stopWaiting()
+		cancelWait()
+		if err != nil {
+			switch {
+			case accept.Err() != nil:
+				j.log.Debug("Fetch cancelled")
+			case opts.WaitTimeout > 0 && ctx.Err() == nil:
+				// The limiter fails at once if the wait would pass the deadline
+				j.log.Warn("Rate limit wait timed out; leaving the feed for the next run", "wait", opts.WaitTimeout, "error", err)
+			default:
+				j.log.Error("Rate limiter error", "error", err)
+			}
+			return
+		}
+	}
+
+	// The feed's time limit starts once it is through the rate limiter
+	if opts.FeedTimeout > 0 {
+		j.ctx, j.cancel = context.WithTimeout(ctx, opts.FeedTimeout)
+	} else {
+		j.ctx, j.cancel = context.WithCancel(ctx)
+	}
+	f.fetch(j.ctx, j)
+	if j.err != nil || j.resp.NotModified {
+		parsed <- j
+		return
+	}
+	fetched <- j
+}
+
+// runSequential takes the feeds through the stages of Run one at a time,
+// storing each feed in a transaction of its own before fetching the next
+func (f *Fetcher) runSequential(ctx, accept context.Context, feeds []repository.Feed, opts RunOptions) {
+	// runFetch hands each feed on to exactly one of these, or drops it
+	fetched, parsed := make(chan *job, 1), make(chan *job, 1)
+	for i, feed := range feeds {
+		if accept.Err() != nil || closed(opts.Stop) {
+			return
+		}
+		f.runFetch(ctx, accept, f.newJob(i, feed), opts, fetched, parsed)
+		select {
+		case j := <-fetched:
+			f.parse(j.ctx, j)
+			f.writeBatch(context.WithoutCancel(ctx), []*job{j}, opts)
+		case j := <-parsed:
+			f.writeBatch(context.WithoutCancel(ctx), []*job{j}, opts)
+		default:
+			// Skipped, or cancelled while waiting
+		}
+	}
+}
+
+// closed reports whether ch has been closed; a nil channel never is
+func closed(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
+
+// writeBatches stores parsed feeds until the queue is closed. A batch is
+// written as soon as the writer is free, so it grows only while feeds finish
+// faster than they can be stored.
+func (f *Fetcher) writeBatches(ctx context.Context, parsed <-chan *job, batchSize int, opts RunOptions) {
+	// Feeds already fetched are stored even if the run is cancelled
+	ctx = context.WithoutCancel(ctx)
+
+	for j := range parsed {
+		batch := []*job{j}
+	collect:
+		for len(batch) < batchSize {
+			select {
+			case next, ok := <-parsed:
+				if !ok {
+					break collect
+				}
+				batch = append(batch, next)
+			default:
+				break collect
+			}
+		}
+		f.writeBatch(ctx, batch, opts)
+	}
+}
+
+// writeBatch stores a batch of feeds in one transaction and reports their
+// results once it is committed
+func (f *Fetcher) writeBatch(ctx context.Context, batch []*job, opts RunOptions) {
+	results := make([]FetchResult, len(batch))
+	err := f.repo.Batch(ctx, func(repo repository.FeedRepository) error {
+		w := *f
+		w.repo = repo
+		w.repoMutex = nil // Only the writer uses repo
+		for i, j := range batch {
+			results[i] = j.measured(w.store(ctx, j))
+		}
+		return nil
+	})
+	if err != nil {
+		f.logger.Error("Failed to store feeds", "feeds", len(batch), "error", err)
+	}
+
+	for i, j := range batch {
+		j.cancel()
+		if err != nil {
+			results[i] = FetchResult{Error: err}
+		}
+		if opts.OnDone != nil {
+			opts.OnDone(j.index, j.feed, results[i])
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package fetcher
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/adewale/rogue_planet/pkg/crawler"
+	"github.com/adewale/rogue_planet/pkg/normalizer"
+	"github.com/adewale/rogue_planet/pkg/repository"
+)
+
+// newPipelineTest serves n feeds of two entries each and adds them to a real
+// repository
+func newPipelineTest(t *testing.T, n int) (*repository.Repository, []repository.Feed) {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/broken" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprintf(w, `<rss version="2.0"><channel><title>Feed %[1]s</title><link>http://example.com%[1]s</link>
+<item><title>One</title><link>http://example.com%[1]s/1</link><guid>%[1]s/1</guid><pubDate>Mon, 03 Mar 2025 10:00:00 GMT</pubDate></item>
+<item><title>Two</title><link>http://example.com%[1]s/2</link><guid>%[1]s/2</guid><pubDate>Tue, 04 Mar 2025 10:00:00 GMT</pubDate></item>
+</channel></rss>`, r.URL.Path)
+	}))
+	t.Cleanup(server.Close)
+
+	repo, err := repository.New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create repository: %v", err)
+	}
+	t.Cleanup(func() { repo.Close() })
+
+	ctx := context.Background()
+	for i := range n {
+		if _, err := repo.AddFeed(ctx, fmt.Sprintf("%s/feed%d", server.URL, i), ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := repo.AddFeed(ctx, server.URL+"/broken", ""); err != nil {
+		t.Fatal(err)
+	}
+	feeds, err := repo.GetFeeds(ctx, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return repo, feeds
+}
+
+func TestRun(t *testing.T) {
+	t.Parallel()
+	const n = 25
+	repo, feeds := newPipelineTest(t, n)
+	f := New(crawler.NewForTesting(), normalizer.New(), repo, nil, slog.New(&mockLogger{}), 0)
+
+	var mu sync.Mutex
+	started := make(map[int]bool)
+	done := make(map[int]FetchResult)
+	f.Run(context.Background(), feeds, RunOptions{
+		FetchWorkers: 4,
+		ParseWorkers: 2,
+		BatchSize:    5,
+		FeedTimeout:  10 * time.Second,
+		OnStart: func(index int, feed repository.Feed) {
+			mu.Lock()
+			defer mu.Unlock()
+			started[index] = true
+		},
+		OnDone: func(index int, feed repository.Feed, result FetchResult) {
+			mu.Lock()
+			defer mu.Unlock()
+			if _, dup := done[index]; dup {
+				t.Errorf("OnDone called twice for %s", feed.URL)
+			}
+			done[index] = result
+		},
+	})
+
+	if len(started) != len(feeds) || len(done) != len(feeds) {
+		t.Fatalf("started %d and finished %d feeds, want %d", len(started), len(done), len(feeds))
+	}
+	failed := 0
+	for i, feed := range feeds {
+		result := done[i]
+		if result.Error != nil {
+			failed++
+			continue
+		}
+		if result.StoredEntries != 2 {
+			t.Errorf("%s: stored %d entries, want 2", feed.URL, result.StoredEntries)
+		}
+		if result.WireBytes == 0 || result.FetchDuration <= 0 {
+			t.Errorf("%s: WireBytes = %d, FetchDuration = %v; want both recorded", feed.URL, result.WireBytes, result.FetchDuration)
+		}
+	}
+	if failed != 1 {
+		t.Errorf("%d feeds failed, want 1", failed)
+	}
+
+	ctx := context.Background()
+	if count, err := repo.CountEntries(ctx); err != nil || count != 2*n {
+		t.Errorf("CountEntries() = %d, %v; want %d", count, err, 2*n)
+	}
+	broken, err := repo.GetFeedByURL(ctx, feeds[len(feeds)-1].URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if broken.FetchErrorCount != 1 {
+		t.Errorf("broken feed FetchErrorCount = %d, want 1", broken.FetchErrorCount)
+	}
+}
+
+func TestRun_Cancelled(t *testing.T) {
+	t.Parallel()
+	repo, feeds := newPipelineTest(t, 5)
+	f := New(crawler.NewForTesting(), normalizer.New(), repo, nil, slog.New(&mockLogger{}), 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	calls := 0
+	f.Run(ctx, feeds, RunOptions{
+		FetchWorkers: 2,
+		OnStart:      func(int, repository.Feed) { calls++ },
+	})
+	if calls != 0 {
+		t.Errorf("%d feeds started after cancellation, want 0", calls)
+	}
+	if count, _ := repo.CountEntries(context.Background()); count != 0 {
+		t.Errorf("CountEntries() = %d, want 0", count)
+	}
+}
+
+func TestRun_Stop(t *testing.T) {
+	t.Parallel()
+	repo, feeds := newPipelineTest(t, 5)
+	f := New(crawler.NewForTesting(), normalizer.New(), repo, nil, slog.New(&mockLogger{}), 0)
+
+	stop := make(chan struct{})
+	var mu sync.Mutex
+	var started, done []int
+	f.Run(context.Background(), feeds, RunOptions{
+		FetchWorkers: 1,
+		Stop:         stop,
+		OnStart: func(index int, feed repository.Feed) {
+			mu.Lock()
+			defer mu.Unlock()
+			if len(started) == 0 {
+				close(stop) // Stop while the first fetch is in flight
+			}
+			started = append(started, index)
+		},
+		OnDone: func(index int, feed repository.Feed, result FetchResult) {
+			mu.Lock()
+			defer mu.Unlock()
+			done = append(done, index)
+		},
+	})
+
+	if len(started) != 1 || len(done) != 1 || done[0] != started[0] {
+		t.Fatalf("started %v and finished %v, want the one feed in flight to finish", started, done)
+	}
+	if count, _ := repo.CountEntries(context.Background()); count != 2 {
+		t.Errorf("CountEntries() = %d, want the in-flight feed's 2 entries", count)
+	}
+}
+
+func TestRun_WaitTimeout(t *testing.T) {
+	t.Parallel()
+	repo, feeds := newPipelineTest(t, 2)
+	f := New(crawler.NewForTesting(), normalizer.New(), repo, nil, slog.New(&mockLogger{}), 0)
+
+	var mu sync.Mutex
+	done := make(map[int]FetchResult)
+	f.Run(context.Background(), feeds, RunOptions{
+		FetchWorkers: 3,
+		// Waiting longer than FeedTimeout doesn't use up the feed's time
+		FeedTimeout: 400 * time.Millisecond,
+		WaitTimeout: time.Second,
+		Wait: func(ctx context.Context, feedURL string) error {
+			if feedURL == feeds[1].URL {
+				<-ctx.Done()
+				return ctx.Err()
+			}
+			time.Sleep(500 * time.Millisecond)
+			return nil
+		},
+		OnDone: func(index int, feed repository.Feed, result FetchResult) {
+			mu.Lock()
+			defer mu.Unlock()
+			done[index] = result
+		},
+	})
+
+	if result, ok := done[0]; !ok || result.Error != nil || result.StoredEntries != 2 {
+		t.Errorf("feed that waited past FeedTimeout = %+v, %v; want its 2 entries stored", result, ok)
+	}
+	if result, ok := done[1]; ok {
+		t.Errorf("feed whose wait timed out = %+v, want it left alone", result)
+	}
+	if count, err := repo.CountEntries(context.Background()); err != nil || count != 2 {
+		t.Errorf("CountEntries() = %d, %v; want 2", count, err)
+	}
+}
+
+func TestRun_Sequential(t *testing.T) {
+	t.Parallel()
+	const n = 6
+	repo, feeds := newPipelineTest(t, n)
+	f := New(crawler.NewForTesting(), normalizer.New(), repo, nil, slog.New(&mockLogger{}), 0)
+
+	// Each feed is stored before the next starts, so the calls alternate
+	var calls []string
+	stop := make(chan struct{})
+	f.Run(context.Background(), feeds, RunOptions{
+		Sequential:  true,
+		FeedTimeout: 10 * time.Second,
+		Stop:        stop,
+		OnStart: func(index int, feed repository.Feed) {
+			calls = append(calls, fmt.Sprintf("start %d", index))
+		},
+		OnDone: func(index int, feed repository.Feed, result FetchResult) {
+			calls = append(calls, fmt.Sprintf("done %d", index))
+			if index == n-1 {
+				close(stop) // Leaves the broken feed alone
+			}
+		},
+	})
+
+	var want []string
+	for i := range n {
+		want = append(want, fmt.Sprintf("start %d", i), fmt.Sprintf("done %d", i))
+	}
+	if fmt.Sprint(calls) != fmt.Sprint(want) {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+	if count, err := repo.CountEntries(context.Background()); err != nil || count != 2*n {
+		t.Errorf("CountEntries() = %d, %v; want %d", count, err, 2*n)
+	}
+}
@@ -0,0 +1,400 @@
+// Package fever serves the Fever API, which feed reader apps such as Reeder,
+// ReadKit, and Unread sync with, from a planet's repository. Apps list the
+// planet's feeds, grouped by category, fetch its entries, and mark them read
+// and saved (starred), which is the read state rp mark-read and rp
+// star-entry keep.
+//
+// Apps sign in with an email and password, sending the MD5 hash of
+// "email:password" as api_key with every request. Only the JSON form of the
+// API is served; hot links and favicons are not, and are always empty.
+package fever
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"hash/crc32"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/adewale/rogue_planet/pkg/repository"
+)
+
+// Prefix is the path the API is served under; apps are given the planet's
+// URL with this path
+const Prefix = "/fever/"
+
+// apiVersion is the version of the Fever API served
+const apiVersion = 3
+
+// Limits on the items of one request, as in the Fever API
+const (
+	itemsPerRequest = 50
+	maxWithIDs      = 50
+)
+
+// maxRequestBody limits the size of request bodies
+const maxRequestBody = 64 << 10
+
+type group struct {
+	ID    int64  `json:"id"`
+	Title string `json:"title"`
+}
+
+type feedsGroup struct {
+	GroupID int64  `json:"group_id"`
+	FeedIDs string `json:"feed_ids"` // Comma-separated
+}
+
+type feed struct {
+	ID                int64  `json:"id"`
+	FaviconID         int64  `json:"favicon_id"`
+	Title             string `json:"title"`
+	URL               string `json:"url"`
+	SiteURL           string `json:"site_url"`
+	IsSpark           int    `json:"is_spark"`
+	LastUpdatedOnTime int64  `json:"last_updated_on_time"`
+}
+
+type item struct {
+	ID            int64  `json:"id"`
+	FeedID        int64  `json:"feed_id"`
+	Title         string `json:"title"`
+	Author        string `json:"author"`
+	HTML          string `json:"html"`
+	URL           string `json:"url"`
+	IsSaved       int    `json:"is_saved"`
+	IsRead        int    `json:"is_read"`
+	CreatedOnTime int64  `json:"created_on_time"`
+}
+
+// API serves the Fever API of a repository
+type API struct {
+	repo *repository.Repository
+	key  []byte
+}
+
+// New returns the Fever API of repo, for apps signing in with email and
+// password
+func New(repo *repository.Repository, email, password string) *API {
+	sum := md5.Sum([]byte(email + ":" + password))
+	return &API{repo: repo, key: []byte(hex.EncodeToString(sum[:]))}
+}
+
+// ServeHTTP answers a Fever API request. Its parameters may be in the query
+// or the form body; a request without a valid api_key is answered with auth
+// 0 and nothing else.
+func (a *API) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBody)
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form", http.StatusBadRequest)
+		return
+	}
+	if !r.Form.Has("api") {
+		http.Error(w, "not a Fever API request: ?api is missing", http.StatusBadRequest)
+		return
+	}
+
+	resp := map[string]any{"api_version": apiVersion, "auth": 0}
+	key := strings.ToLower(r.FormValue("api_key"))
+	if subtle.ConstantTimeCompare([]byte(key), a.key) != 1 {
+		writeJSON(w, resp)
+		return
+	}
+	resp["auth"] = 1
+
+	if err := a.respond(r.Context(), r, resp); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, resp)
+}
+
+// respond applies the request's mark, if any, then adds what it asks for to
+// resp
+func (a *API) respond(ctx context.Context, r *http.Request, resp map[string]any) error {
+	if r.Form.Has("mark") {
+		if err := a.mark(ctx, r); err != nil {
+			return err
+		}
+	}
+
+	feeds, err := a.repo.GetFeeds(ctx, true)
+	if err != nil {
+		return err
+	}
+	var lastRefreshed time.Time
+	for _, f := range feeds {
+		if f.LastFetched.After(lastRefreshed) {
+			lastRefreshed = f.LastFetched
+		}
+	}
+	resp["last_refreshed_on_time"] = unixTime(lastRefreshed)
+
+	if r.Form.Has("groups") || r.Form.Has("feeds") {
+		groups, feedsGroups, err := a.groups(ctx, feeds)
+		if err != nil {
+			return err
+		}
+		if r.Form.Has("groups") {
+			resp["groups"] = groups
+		}
+		resp["feeds_groups"] = feedsGroups
+	}
+	if r.Form.Has("feeds") {
+		list := make([]feed, 0, len(feeds))
+		for _, f := range feeds {
+			list = append(list, feed{
+				ID:                f.ID,
+				Title:             f.Title,
+				URL:               f.URL,
+				SiteURL:           f.Link,
+				LastUpdatedOnTime: unixTime(f.LastFetched),
+			})
+		}
+		resp["feeds"] = list
+	}
+	if r.Form.Has("favicons") {
+		resp["favicons"] = []struct{}{}
+	}
+	if r.Form.Has("links") {
+		resp["links"] = []struct{}{}
+	}
+	if r.Form.Has("items") {
+		items, total, err := a.items(ctx, r)
+		if err != nil {
+			return err
+		}
+		resp["items"] = items
+		resp["total_items"] = total
+	}
+	if r.Form.Has("unread_item_ids") {
+		ids, err := a.repo.GetUnreadEntryIDs(ctx)
+		if err != nil {
+			return err
+		}
+		resp["unread_item_ids"] = joinIDs(ids)
+	}
+	if r.Form.Has("saved_item_ids") {
+		ids, err := a.repo.GetStarredEntryIDs(ctx)
+		if err != nil {
+			return err
+		}
+		resp["saved_item_ids"] = joinIDs(ids)
+	}
+	return nil
+}
+
+// groups returns a group for each feed category, and the feeds in each
+func (a *API) groups(ctx context.Context, feeds []repository.Feed) ([]group, []feedsGroup, error) {
+	categories, err := a.repo.GetAllFeedCategories(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	members := make(map[string][]int64)
+	for _, f := range feeds {
+		for _, c := range categories[f.ID] {
+			members[c] = append(members[c], f.ID)
+		}
+	}
+	names := make([]string, 0, len(members))
+	for name := range members {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	groups := make([]group, 0, len(names))
+	feedsGroups := make([]feedsGroup, 0, len(names))
+	for _, name := range names {
+		id := groupID(name)
+		groups = append(groups, group{ID: id, Title: name})
+		feedsGroups = append(feedsGroups, feedsGroup{GroupID: id, FeedIDs: joinIDs(members[name])})
+	}
+	return groups, feedsGroups, nil
+}
+
+// items returns the page of entries the request asks for, with the number
+// of entries there are
+func (a *API) items(ctx context.Context, r *http.Request) ([]item, int64, error) {
+	var entries []repository.Entry
+	var err error
+	switch {
+	case r.Form.Has("with_ids"):
+		ids := parseIDs(r.FormValue("with_ids"))
+		entries, err = a.repo.GetEntriesByRowID(ctx, ids[:min(len(ids), maxWithIDs)])
+	case r.Form.Has("max_id"):
+		maxID, _ := strconv.ParseInt(r.FormValue("max_id"), 10, 64)
+		entries, err = a.repo.GetEntriesBeforeID(ctx, maxID, itemsPerRequest)
+	default:
+		sinceID, _ := strconv.ParseInt(r.FormValue("since_id"), 10, 64)
+		entries, err = a.repo.GetEntriesAfterID(ctx, sinceID, itemsPerRequest)
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	unread, err := a.idSet(a.repo.GetUnreadEntryIDs(ctx))
+	if err != nil {
+		return nil, 0, err
+	}
+	saved, err := a.idSet(a.repo.GetStarredEntryIDs(ctx))
+	if err != nil {
+		return nil, 0, err
+	}
+	items := make([]item, 0, len(entries))
+	for _, e := range entries {
+		created := e.Published
+		if created.IsZero() {
+			created = e.FirstSeen
+		}
+		html := e.Content
+		if html == "" {
+			html = e.Summary
+		}
+		items = append(items, item{
+			ID:            e.ID,
+			FeedID:        e.FeedID,
+			Title:         e.Title,
+			Author:        e.Author,
+			HTML:          html,
+			URL:           e.Link,
+			IsSaved:       boolInt(saved[e.ID]),
+			IsRead:        boolInt(!unread[e.ID]),
+			CreatedOnTime: unixTime(created),
+		})
+	}
+
+	total, err := a.repo.CountVisibleEntries(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	return items, total, nil
+}
+
+// mark applies a request to mark an item read, unread, saved, or unsaved,
+// or the items of a feed or group read. Marks of unknown items are ignored,
+// as apps may hold items since pruned.
+func (a *API) mark(ctx context.Context, r *http.Request) error {
+	id, _ := strconv.ParseInt(r.FormValue("id"), 10, 64)
+	as := r.FormValue("as")
+	now := time.Now()
+
+	switch r.FormValue("mark") {
+	case "item":
+		var err error
+		switch as {
+		case "read", "unread":
+			err = a.repo.SetEntryRead(ctx, id, as == "read", now)
+		case "saved", "unsaved":
+			err = a.repo.SetEntryStarred(ctx, id, as == "saved", now)
+		}
+		if err == repository.ErrEntryNotFound {
+			return nil
+		}
+		return err
+
+	case "feed", "group":
+		if as != "read" {
+			return nil
+		}
+		before := now
+		if s, err := strconv.ParseInt(r.FormValue("before"), 10, 64); err == nil && s > 0 {
+			before = time.Unix(s, 0)
+		}
+		feedIDs := []int64{id} // Group 0 is every feed; feed ID 0 is too
+		if r.FormValue("mark") == "group" && id != 0 {
+			var err error
+			if feedIDs, err = a.groupFeeds(ctx, id); err != nil {
+				return err
+			}
+		}
+		for _, feedID := range feedIDs {
+			if _, err := a.repo.MarkReadBefore(ctx, feedID, before, now); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// groupFeeds returns the IDs of the active feeds in the group with an ID
+func (a *API) groupFeeds(ctx context.Context, id int64) ([]int64, error) {
+	feeds, err := a.repo.GetFeeds(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+	_, feedsGroups, err := a.groups(ctx, feeds)
+	if err != nil {
+		return nil, err
+	}
+	for _, fg := range feedsGroups {
+		if fg.GroupID == id {
+			return parseIDs(fg.FeedIDs), nil
+		}
+	}
+	return nil, nil
+}
+
+// idSet turns a list of entry IDs into a set
+func (a *API) idSet(ids []int64, err error) (map[int64]bool, error) {
+	if err != nil {
+		return nil, err
+	}
+	set := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		set[id] = true
+	}
+	return set, nil
+}
+
+// groupID is the ID of the group of a category's feeds. It is a hash of
+// the name, so a group keeps its ID as categories come and go.
+func groupID(category string) int64 {
+	return int64(crc32.ChecksumIEEE([]byte(category))) + 1
+}
+
+// joinIDs formats IDs as the comma-separated list the Fever API uses
+func joinIDs(ids []int64) string {
+	s := make([]string, len(ids))
+	for i, id := range ids {
+		s[i] = strconv.FormatInt(id, 10)
+	}
+	return strings.Join(s, ",")
+}
+
+// parseIDs parses a comma-separated list of IDs, skipping anything else
+func parseIDs(s string) []int64 {
+	var ids []int64
+	for _, field := range strings.Split(s, ",") {
+		if id, err := strconv.ParseInt(strings.TrimSpace(field), 10, 64); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// unixTime is t in Unix seconds, or 0 if it is zero
+func unixTime(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,226 @@
+package fever
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adewale/rogue_planet/pkg/repository"
+)
+
+// apiKey is the key of the test account, md5("reader@example.com:secret")
+const apiKey = "91c2c517cae0a8e28b6fa6682e812e46"
+
+// newAPI returns the API of a repository with two feeds, the first in the
+// category "go", and the row IDs of their entries, oldest first
+func newAPI(t *testing.T) (*API, *repository.Repository, []int64) {
+	t.Helper()
+	repo, err := repository.New(filepath.Join(t.TempDir(), "planet.db"))
+	if err != nil {
+		t.Fatalf("repository.New() error = %v", err)
+	}
+	t.Cleanup(func() { repo.Close() })
+
+	ctx := context.Background()
+	goFeed, _ := repo.AddFeed(ctx, "https://go.example.com/feed", "Go Blog")
+	otherFeed, _ := repo.AddFeed(ctx, "https://other.example.com/feed", "Other Blog")
+	if err := repo.SetFeedCategories(ctx, goFeed, []string{"go"}); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now().Truncate(time.Second)
+	var ids []int64
+	for i, id := range []string{"a", "b", "c"} {
+		feed := goFeed
+		if id == "c" {
+			feed = otherFeed
+		}
+		seen := now.Add(time.Duration(i-3) * time.Hour)
+		e := &repository.Entry{FeedID: feed, EntryID: id, Title: id, Link: "https://example.com/" + id, Content: "<p>" + id + "</p>", Published: seen, Updated: seen, FirstSeen: seen}
+		if err := repo.UpsertEntry(ctx, e); err != nil {
+			t.Fatal(err)
+		}
+		entries, _ := repo.FindEntries(ctx, id)
+		ids = append(ids, entries[0].ID)
+	}
+	return New(repo, "reader@example.com", "secret"), repo, ids
+}
+
+// post sends a Fever API request with form values, signed in unless they
+// give an api_key, and decodes the response
+func post(t *testing.T, api *API, query string, form url.Values) map[string]any {
+	t.Helper()
+	if !form.Has("api_key") {
+		form.Set("api_key", apiKey)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/fever/?api&"+query, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	api.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	var resp map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("response %q: %v", rec.Body, err)
+	}
+	return resp
+}
+
+// itemIDs returns the IDs of the items of a response
+func itemIDs(resp map[string]any) []int64 {
+	var ids []int64
+	items, _ := resp["items"].([]any)
+	for _, it := range items {
+		ids = append(ids, int64(it.(map[string]any)["id"].(float64)))
+	}
+	return ids
+}
+
+func joined(ids ...int64) string {
+	return joinIDs(ids)
+}
+
+func TestAuth(t *testing.T) {
+	t.Parallel()
+	api, _, _ := newAPI(t)
+
+	for _, key := range []string{"", "wrong", apiKey[:16]} {
+		resp := post(t, api, "feeds", url.Values{"api_key": {key}})
+		if resp["auth"] != 0.0 || resp["api_version"] != 3.0 || resp["feeds"] != nil {
+			t.Errorf("api_key %q: response = %v, want auth 0 and nothing else", key, resp)
+		}
+	}
+	if resp := post(t, api, "", url.Values{"api_key": {strings.ToUpper(apiKey)}}); resp["auth"] != 1.0 {
+		t.Errorf("upper case api_key: auth = %v, want 1", resp["auth"])
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/fever/", nil)
+	rec := httptest.NewRecorder()
+	api.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("request without ?api: status = %d, want 400", rec.Code)
+	}
+}
+
+func TestGroupsAndFeeds(t *testing.T) {
+	t.Parallel()
+	api, _, _ := newAPI(t)
+
+	resp := post(t, api, "groups&feeds", url.Values{})
+	groups := resp["groups"].([]any)
+	if len(groups) != 1 || groups[0].(map[string]any)["title"] != "go" {
+		t.Fatalf("groups = %v, want go", groups)
+	}
+	groupID := groups[0].(map[string]any)["id"]
+	fg := resp["feeds_groups"].([]any)
+	if len(fg) != 1 || fg[0].(map[string]any)["group_id"] != groupID || fg[0].(map[string]any)["feed_ids"] != "1" {
+		t.Errorf("feeds_groups = %v, want feed 1 in group %v", fg, groupID)
+	}
+	feeds := resp["feeds"].([]any)
+	if len(feeds) != 2 || feeds[0].(map[string]any)["url"] != "https://go.example.com/feed" {
+		t.Errorf("feeds = %v", feeds)
+	}
+	for _, key := range []string{"favicons", "links"} {
+		if resp := post(t, api, key, url.Values{}); resp[key] == nil {
+			t.Errorf("%s = nil, want an empty list", key)
+		}
+	}
+}
+
+func TestItems(t *testing.T) {
+	t.Parallel()
+	api, repo, ids := newAPI(t)
+
+	tests := []struct {
+		query string
+		want  []int64
+	}{
+		{"items", ids},
+		{"items&since_id=" + strconv.FormatInt(ids[0], 10), ids[1:]},
+		{"items&max_id=" + strconv.FormatInt(ids[2], 10), []int64{ids[1], ids[0]}},
+		{"items&with_ids=" + joined(ids[2], ids[0], 999), []int64{ids[0], ids[2]}},
+	}
+	for _, tt := range tests {
+		resp := post(t, api, tt.query, url.Values{})
+		if got := itemIDs(resp); joined(got...) != joined(tt.want...) {
+			t.Errorf("%s: items = %v, want %v", tt.query, got, tt.want)
+		}
+		if resp["total_items"] != 3.0 {
+			t.Errorf("%s: total_items = %v, want 3", tt.query, resp["total_items"])
+		}
+	}
+
+	item := post(t, api, "items", url.Values{})["items"].([]any)[0].(map[string]any)
+	if item["html"] != "<p>a</p>" || item["url"] != "https://example.com/a" || item["is_read"] != 0.0 || item["created_on_time"] == 0.0 {
+		t.Errorf("item = %v", item)
+	}
+
+	// Hidden entries are not synced
+	if _, err := repo.HideEntries(context.Background(), "b", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if got := itemIDs(post(t, api, "items", url.Values{})); joined(got...) != joined(ids[0], ids[2]) {
+		t.Errorf("items after hiding b = %v", got)
+	}
+}
+
+func TestMark(t *testing.T) {
+	t.Parallel()
+	api, _, ids := newAPI(t)
+	id := func(i int) string { return strconv.FormatInt(ids[i], 10) }
+	mark := func(form url.Values) {
+		t.Helper()
+		post(t, api, "", form)
+	}
+	state := func() (unread, saved any) {
+		t.Helper()
+		resp := post(t, api, "unread_item_ids&saved_item_ids", url.Values{})
+		return resp["unread_item_ids"], resp["saved_item_ids"]
+	}
+
+	if unread, saved := state(); unread != joined(ids...) || saved != "" {
+		t.Fatalf("initial state = %v, %v", unread, saved)
+	}
+
+	mark(url.Values{"mark": {"item"}, "as": {"read"}, "id": {id(0)}})
+	mark(url.Values{"mark": {"item"}, "as": {"saved"}, "id": {id(1)}})
+	mark(url.Values{"mark": {"item"}, "as": {"read"}, "id": {"999"}}) // Pruned, ignored
+	if unread, saved := state(); unread != joined(ids[1], ids[2]) || saved != id(1) {
+		t.Errorf("after marking items = %v, %v", unread, saved)
+	}
+	item := post(t, api, "items&with_ids="+id(1), url.Values{})["items"].([]any)[0].(map[string]any)
+	if item["is_saved"] != 1.0 || item["is_read"] != 0.0 {
+		t.Errorf("saved item = %v", item)
+	}
+
+	mark(url.Values{"mark": {"item"}, "as": {"unread"}, "id": {id(0)}})
+	mark(url.Values{"mark": {"item"}, "as": {"unsaved"}, "id": {id(1)}})
+	if unread, saved := state(); unread != joined(ids...) || saved != "" {
+		t.Errorf("after unmarking items = %v, %v", unread, saved)
+	}
+
+	// Entries first seen after before stay unread
+	before := strconv.FormatInt(time.Now().Add(-150*time.Minute).Unix(), 10)
+	mark(url.Values{"mark": {"feed"}, "as": {"read"}, "id": {"1"}, "before": {before}})
+	if unread, _ := state(); unread != joined(ids[1], ids[2]) {
+		t.Errorf("after marking the feed read = %v", unread)
+	}
+
+	groupID := post(t, api, "groups", url.Values{})["groups"].([]any)[0].(map[string]any)["id"].(float64)
+	mark(url.Values{"mark": {"group"}, "as": {"read"}, "id": {strconv.FormatInt(int64(groupID), 10)}})
+	if unread, _ := state(); unread != id(2) {
+		t.Errorf("after marking the group read = %v, want only the other feed's entry", unread)
+	}
+	mark(url.Values{"mark": {"group"}, "as": {"read"}, "id": {"0"}})
+	if unread, _ := state(); unread != "" {
+		t.Errorf("after marking group 0 read = %v, want everything read", unread)
+	}
+}
@@ -0,0 +1,195 @@
+// Package filter includes or excludes feed entries by keyword, regular
+// expression, author, or category.
+//
+// Exclude rules win: an entry matching any exclude rule is dropped. When any
+// include rule is configured, an entry must also match at least one include
+// rule to be kept. Keyword, author, and category comparisons are
+// case-insensitive; regular expressions are used as written (prefix them with
+// (?i) for case-insensitive matching).
+package filter
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Rules lists the include and exclude rules for one filter. Keywords and
+// regular expressions are matched against an entry's title, summary, and
+// content; authors and categories must match exactly (ignoring case).
+type Rules struct {
+	IncludeKeywords   []string
+	ExcludeKeywords   []string
+	IncludeRegex      []string
+	ExcludeRegex      []string
+	IncludeAuthors    []string
+	ExcludeAuthors    []string
+	IncludeCategories []string
+	ExcludeCategories []string
+}
+
+// IsZero reports whether no rules are configured
+func (r Rules) IsZero() bool {
+	return len(r.IncludeKeywords) == 0 && len(r.ExcludeKeywords) == 0 &&
+		len(r.IncludeRegex) == 0 && len(r.ExcludeRegex) == 0 &&
+		len(r.IncludeAuthors) == 0 && len(r.ExcludeAuthors) == 0 &&
+		len(r.IncludeCategories) == 0 && len(r.ExcludeCategories) == 0
+}
+
+// Item is the part of an entry that filters look at
+type Item struct {
+	Title      string
+	Summary    string
+	Content    string
+	Author     string
+	Categories []string
+}
+
+// matcher is one compiled set of rules (either the include or exclude side)
+type matcher struct {
+	keywords   []string // Lowercased
+	regex      []*regexp.Regexp
+	authors    []string // Lowercased
+	categories []string // Lowercased
+}
+
+// Filter is a compiled set of Rules
+type Filter struct {
+	include matcher
+	exclude matcher
+}
+
+// New compiles rules into a Filter. It fails if a regular expression is invalid.
+func New(r Rules) (*Filter, error) {
+	include, err := newMatcher(r.IncludeKeywords, r.IncludeRegex, r.IncludeAuthors, r.IncludeCategories)
+	if err != nil {
+		return nil, err
+	}
+	exclude, err := newMatcher(r.ExcludeKeywords, r.ExcludeRegex, r.ExcludeAuthors, r.ExcludeCategories)
+	if err != nil {
+		return nil, err
+	}
+	return &Filter{include: include, exclude: exclude}, nil
+}
+
+func newMatcher(keywords, patterns, authors, categories []string) (matcher, error) {
+	m := matcher{
+		keywords:   lowerAll(keywords),
+		authors:    lowerAll(authors),
+		categories: lowerAll(categories),
+	}
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return matcher{}, fmt.Errorf("invalid regex %q: %w", p, err)
+		}
+		m.regex = append(m.regex, re)
+	}
+	return m, nil
+}
+
+func (m matcher) empty() bool {
+	return len(m.keywords) == 0 && len(m.regex) == 0 && len(m.authors) == 0 && len(m.categories) == 0
+}
+
+// match returns a description of the first rule the item matches, or ""
+func (m matcher) match(item Item) string {
+	text := item.Title + "\n" + item.Summary + "\n" + item.Content
+	lower := strings.ToLower(text)
+	for _, k := range m.keywords {
+		if strings.Contains(lower, k) {
+			return fmt.Sprintf("keyword %q", k)
+		}
+	}
+	for _, re := range m.regex {
+		if re.MatchString(text) {
+			return fmt.Sprintf("regex %q", re.String())
+		}
+	}
+	author := strings.ToLower(strings.TrimSpace(item.Author))
+	for _, a := range m.authors {
+		if author == a {
+			return fmt.Sprintf("author %q", a)
+		}
+	}
+	for _, c := range item.Categories {
+		c = strings.ToLower(strings.TrimSpace(c))
+		for _, want := range m.categories {
+			if c == want {
+				return fmt.Sprintf("category %q", want)
+			}
+		}
+	}
+	return ""
+}
+
+// Match reports whether item should be kept. When it should not, the reason
+// names the rule responsible. A nil Filter keeps everything.
+func (f *Filter) Match(item Item) (keep bool, reason string) {
+	if f == nil {
+		return true, ""
+	}
+	if rule := f.exclude.match(item); rule != "" {
+		return false, "excluded by " + rule
+	}
+	if !f.include.empty() && f.include.match(item) == "" {
+		return false, "matched no include rule"
+	}
+	return true, ""
+}
+
+// Set combines a global Filter with per-feed Filters keyed by feed URL.
+// An entry must pass both the global filter and its feed's filter.
+type Set struct {
+	global *Filter
+	feeds  map[string]*Filter
+}
+
+// NewSet compiles global and per-feed rules. It returns nil, nil when no
+// rules are configured at all, so callers can skip filtering cheaply.
+func NewSet(global Rules, perFeed map[string]Rules) (*Set, error) {
+	s := &Set{feeds: make(map[string]*Filter)}
+	if !global.IsZero() {
+		f, err := New(global)
+		if err != nil {
+			return nil, err
+		}
+		s.global = f
+	}
+	for url, rules := range perFeed {
+		if rules.IsZero() {
+			continue
+		}
+		f, err := New(rules)
+		if err != nil {
+			return nil, fmt.Errorf("filters for %s: %w", url, err)
+		}
+		s.feeds[url] = f
+	}
+	if s.global == nil && len(s.feeds) == 0 {
+		return nil, nil
+	}
+	return s, nil
+}
+
+// Match reports whether an entry from feedURL should be kept. A nil Set
+// keeps everything.
+func (s *Set) Match(feedURL string, item Item) (keep bool, reason string) {
+	if s == nil {
+		return true, ""
+	}
+	if keep, reason := s.global.Match(item); !keep {
+		return false, reason
+	}
+	return s.feeds[feedURL].Match(item)
+}
+
+func lowerAll(values []string) []string {
+	out := make([]string, 0, len(values))
+	for _, v := range values {
+		if v = strings.ToLower(strings.TrimSpace(v)); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
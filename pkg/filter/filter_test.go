@@ -0,0 +1,107 @@
+package filter
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFilterMatch(t *testing.T) {
+	t.Parallel()
+	post := Item{
+		Title:      "Release notes",
+		Content:    "<p>This week we shipped Go 1.24 support.</p>",
+		Author:     "Alice Example",
+		Categories: []string{"Go", "Releases"},
+	}
+	sponsored := Item{
+		Title:      "SPONSORED: Try our product",
+		Content:    "<p>Buy now</p>",
+		Author:     "Marketing",
+		Categories: []string{"ads"},
+	}
+
+	tests := []struct {
+		name       string
+		rules      Rules
+		item       Item
+		wantKeep   bool
+		wantReason string
+	}{
+		{"no rules keeps", Rules{}, sponsored, true, ""},
+		{"exclude keyword ignores case", Rules{ExcludeKeywords: []string{"Sponsored"}}, sponsored, false, `excluded by keyword "sponsored"`},
+		{"exclude keyword misses", Rules{ExcludeKeywords: []string{"sponsored"}}, post, true, ""},
+		{"exclude regex", Rules{ExcludeRegex: []string{`(?i)^sponsored:`}}, sponsored, false, "excluded by regex"},
+		{"regex is case-sensitive by default", Rules{ExcludeRegex: []string{`^sponsored:`}}, sponsored, true, ""},
+		{"exclude author", Rules{ExcludeAuthors: []string{"marketing"}}, sponsored, false, `excluded by author "marketing"`},
+		{"exclude category", Rules{ExcludeCategories: []string{"Ads"}}, sponsored, false, `excluded by category "ads"`},
+		{"include keyword matches", Rules{IncludeKeywords: []string{"go 1.24"}}, post, true, ""},
+		{"include keyword misses", Rules{IncludeKeywords: []string{"rust"}}, post, false, "matched no include rule"},
+		{"any include rule is enough", Rules{IncludeKeywords: []string{"rust"}, IncludeCategories: []string{"go"}}, post, true, ""},
+		{"exclude wins over include", Rules{IncludeCategories: []string{"go"}, ExcludeAuthors: []string{"alice example"}}, post, false, "excluded by author"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			f, err := New(tt.rules)
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			keep, reason := f.Match(tt.item)
+			if keep != tt.wantKeep {
+				t.Errorf("Match() keep = %v, want %v (reason %q)", keep, tt.wantKeep, reason)
+			}
+			if !strings.HasPrefix(reason, tt.wantReason) {
+				t.Errorf("Match() reason = %q, want prefix %q", reason, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestNewInvalidRegex(t *testing.T) {
+	t.Parallel()
+	if _, err := New(Rules{IncludeRegex: []string{"("}}); err == nil {
+		t.Error("expected error for invalid regex")
+	}
+}
+
+func TestSet(t *testing.T) {
+	t.Parallel()
+
+	s, err := NewSet(Rules{}, nil)
+	if err != nil || s != nil {
+		t.Fatalf("NewSet() with no rules = %v, %v; want nil, nil", s, err)
+	}
+	if keep, _ := s.Match("https://a.example.com/feed", Item{Title: "anything"}); !keep {
+		t.Error("nil Set should keep everything")
+	}
+
+	s, err = NewSet(
+		Rules{ExcludeKeywords: []string{"sponsored"}},
+		map[string]Rules{"https://b.example.com/feed": {IncludeCategories: []string{"go"}}},
+	)
+	if err != nil {
+		t.Fatalf("NewSet() error = %v", err)
+	}
+
+	tests := []struct {
+		feed string
+		item Item
+		want bool
+	}{
+		{"https://a.example.com/feed", Item{Title: "Hello"}, true},
+		{"https://a.example.com/feed", Item{Title: "Sponsored post"}, false},
+		{"https://b.example.com/feed", Item{Title: "Hello"}, false},
+		{"https://b.example.com/feed", Item{Title: "Hello", Categories: []string{"Go"}}, true},
+		{"https://b.example.com/feed", Item{Title: "Sponsored", Categories: []string{"Go"}}, false},
+	}
+	for _, tt := range tests {
+		if keep, reason := s.Match(tt.feed, tt.item); keep != tt.want {
+			t.Errorf("Match(%s, %q) = %v (%s), want %v", tt.feed, tt.item.Title, keep, reason, tt.want)
+		}
+	}
+
+	if _, err := NewSet(Rules{}, map[string]Rules{"https://c.example.com/": {ExcludeRegex: []string{"["}}}); err == nil {
+		t.Error("expected error for invalid per-feed regex")
+	}
+}
@@ -0,0 +1,222 @@
+package generator
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// ArchiveFileName is the archive index, listing every archived month
+const ArchiveFileName = "archive.html"
+
+// ArchiveMonth is a month with archived entries
+type ArchiveMonth struct {
+	Year    int
+	Month   time.Month
+	Entries int // Entries published that month
+}
+
+// Name returns the month's display name, e.g. "May 2024"
+func (m ArchiveMonth) Name() string {
+	return fmt.Sprintf("%s %d", m.Month, m.Year)
+}
+
+// Path returns the month's directory relative to the site root, e.g. "2024/05/"
+func (m ArchiveMonth) Path() string {
+	return fmt.Sprintf("%04d/%02d/", m.Year, int(m.Month))
+}
+
+// ArchivePermalink returns the lasting address of an entry, relative to the
+// site root: its anchor on the archive page of the month it was published,
+// e.g. "2024/05/#entry-3f9a0c1b2d4e". Entries without a publication date are
+// not archived and have none.
+func ArchivePermalink(e EntryData) string {
+	if e.Published.Year() < 1970 {
+		return ""
+	}
+	m := ArchiveMonth{Year: e.Published.Year(), Month: e.Published.Month()}
+	return m.Path() + "#" + EntryAnchor(e)
+}
+
+// ArchiveYear groups the archived months of one year, newest first
+type ArchiveYear struct {
+	Year   int
+	Months []ArchiveMonth
+}
+
+// Path returns the year's directory relative to the site root, e.g. "2024/"
+func (y ArchiveYear) Path() string {
+	return fmt.Sprintf("%04d/", y.Year)
+}
+
+// Entries returns the number of entries published that year
+func (y ArchiveYear) Entries() int {
+	n := 0
+	for _, m := range y.Months {
+		n += m.Entries
+	}
+	return n
+}
+
+// archiveTemplate lists data.Archive. It is added to templates that do not
+// define an "archive" block of their own.
+const archiveTemplate = `{{define "archive"}}
+<nav class="archive" aria-label="Archive">
+    {{range .Archive}}
+    <section class="archive-year">
+        <h2><a href="{{.Path}}">{{.Year}}</a></h2>
+        <ul>
+        {{range .Months}}
+            <li><a href="{{.Path}}">{{.Name}}</a> ({{.Entries}})</li>
+        {{end}}
+        </ul>
+    </section>
+    {{end}}
+</nav>
+{{end}}`
+
+// GenerateArchive renders the archive into outputDir: a page per month at
+// YYYY/MM/index.html, a page per year at YYYY/index.html listing its months,
+// and archive.html listing every year. months must be newest first; entries
+// loads the entries of one month, so only one month is held in memory at a
+// time. The archive of an earlier run is removed first.
+// It returns the pages written, for the sitemap.
+func (g *Generator) GenerateArchive(ctx context.Context, outputDir string, data TemplateData, months []ArchiveMonth, entries func(ArchiveMonth) ([]EntryData, error)) ([]SitePage, error) {
+	if err := RemoveArchive(outputDir); err != nil {
+		return nil, err
+	}
+
+	months = append([]ArchiveMonth(nil), months...)
+	monthPages := make([]SitePage, 0, len(months))
+	for i, m := range months {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		list, err := entries(m)
+		if err != nil {
+			return nil, fmt.Errorf("load entries for %s: %w", m.Name(), err)
+		}
+		months[i].Entries = len(list)
+
+		page := archivePage(data, "../../", m.Name())
+		page.Entries = list
+		page.Page = i + 1
+		page.TotalPages = len(months)
+		if i > 0 {
+			page.PrevURL = months[i-1].Path()
+		}
+		if i < len(months)-1 {
+			page.NextURL = months[i+1].Path()
+		}
+
+		p := SitePage{Path: m.Path() + IndexFileName}
+		for _, e := range list {
+			if t := entryUpdated(e); t.After(p.Modified) {
+				p.Modified = t
+			}
+		}
+		if err := g.writeArchivePage(ctx, outputDir, p.Path, page); err != nil {
+			return nil, err
+		}
+		monthPages = append(monthPages, p)
+	}
+
+	years := archiveYears(months)
+	pages := []SitePage{{Path: ArchiveFileName}}
+	for _, y := range years {
+		page := archivePage(data, "../", strconv.Itoa(y.Year))
+		page.Archive = []ArchiveYear{y}
+		path := y.Path() + IndexFileName
+		if err := g.writeArchivePage(ctx, outputDir, path, page); err != nil {
+			return nil, err
+		}
+		pages = append(pages, SitePage{Path: path})
+	}
+
+	index := archivePage(data, "", "Archive")
+	index.Archive = years
+	if err := g.writeArchivePage(ctx, outputDir, ArchiveFileName, index); err != nil {
+		return nil, err
+	}
+	return append(pages, monthPages...), nil
+}
+
+// archivePage returns a copy of data for an archive page titled title, with
+// no entries and relative URLs resolving against root
+func archivePage(data TemplateData, root, title string) TemplateData {
+	data.Root = root
+	data.ArchiveTitle = title
+	data.Entries = nil
+	data.Archive = nil
+	data.Page = 0
+	data.TotalPages = 0
+	data.PrevURL = ""
+	data.NextURL = ""
+	return data
+}
+
+// writeArchivePage renders data to path, relative to outputDir
+func (g *Generator) writeArchivePage(ctx context.Context, outputDir, path string, data TemplateData) error {
+	full := filepath.Join(outputDir, filepath.FromSlash(path))
+	if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+		return fmt.Errorf("create archive directory: %w", err)
+	}
+	if err := writeFeedFile(full, func(w io.Writer) error {
+		return g.Generate(ctx, w, data)
+	}); err != nil {
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+	return nil
+}
+
+// archiveYears groups months, newest first, by year
+func archiveYears(months []ArchiveMonth) []ArchiveYear {
+	var years []ArchiveYear
+	for _, m := range months {
+		if len(years) == 0 || years[len(years)-1].Year != m.Year {
+			years = append(years, ArchiveYear{Year: m.Year})
+		}
+		y := &years[len(years)-1]
+		y.Months = append(y.Months, m)
+	}
+	return years
+}
+
+// RemoveArchive removes the archive pages of an earlier run from outputDir
+func RemoveArchive(outputDir string) error {
+	dirs, err := os.ReadDir(outputDir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read output directory: %w", err)
+	}
+	for _, d := range dirs {
+		if d.IsDir() && isYear(d.Name()) {
+			if err := os.RemoveAll(filepath.Join(outputDir, d.Name())); err != nil {
+				return fmt.Errorf("remove stale archive: %w", err)
+			}
+		}
+	}
+	if err := os.Remove(filepath.Join(outputDir, ArchiveFileName)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("remove stale archive: %w", err)
+	}
+	return nil
+}
+
+// isYear reports whether name is a four-digit year
+func isYear(name string) bool {
+	if len(name) != 4 {
+		return false
+	}
+	for _, c := range name {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
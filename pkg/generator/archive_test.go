@@ -0,0 +1,143 @@
+package generator
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGenerateArchive(t *testing.T) {
+	t.Parallel()
+	gen := newFeedTestGenerator(t)
+	dir := t.TempDir()
+
+	// Left over from an earlier run
+	if err := os.MkdirAll(filepath.Join(dir, "2019", "01"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "static"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	data := feedTestData()
+	data.ArchiveURL = ArchiveFileName
+	months := []ArchiveMonth{
+		{Year: 2025, Month: time.March, Entries: 3},
+		{Year: 2025, Month: time.January, Entries: 1},
+		{Year: 2024, Month: time.December, Entries: 1},
+	}
+	var loaded []string
+	pages, err := gen.GenerateArchive(context.Background(), dir, data, months, func(m ArchiveMonth) ([]EntryData, error) {
+		loaded = append(loaded, m.Name())
+		if m.Month == time.March {
+			return data.Entries, nil
+		}
+		return data.Entries[:1], nil
+	})
+	if err != nil {
+		t.Fatalf("GenerateArchive() error = %v", err)
+	}
+	if strings.Join(loaded, ", ") != "March 2025, January 2025, December 2024" {
+		t.Errorf("loaded months %v, want each month newest first", loaded)
+	}
+
+	var paths []string
+	for _, p := range pages {
+		paths = append(paths, p.Path)
+	}
+	wantPaths := "archive.html 2025/index.html 2024/index.html 2025/03/index.html 2025/01/index.html 2024/12/index.html"
+	if strings.Join(paths, " ") != wantPaths {
+		t.Errorf("pages = %v, want %s", paths, wantPaths)
+	}
+	if !pages[3].Modified.Equal(data.Entries[0].Updated) {
+		t.Errorf("March page modified = %v, want its newest update", pages[3].Modified)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "2019")); !os.IsNotExist(err) {
+		t.Error("stale year directory was not removed")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "static")); err != nil {
+		t.Error("a directory that is not a year was removed")
+	}
+
+	read := func(path string) string {
+		t.Helper()
+		b, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(path)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+
+	index := read("archive.html")
+	for _, want := range []string{`<a href="2025/">2025</a>`, `<a href="2025/03/">March 2025</a> (3)`, `<a href="2024/12/">December 2024</a> (1)`} {
+		if !strings.Contains(index, want) {
+			t.Errorf("archive.html missing %q", want)
+		}
+	}
+	if strings.Contains(index, "<base") {
+		t.Error("archive.html has a base tag, but it is at the site root")
+	}
+
+	year := read("2025/index.html")
+	if !strings.Contains(year, `<base href="../">`) || !strings.Contains(year, "January 2025") || strings.Contains(year, "December 2024") {
+		t.Errorf("2025/index.html should list only 2025's months, resolving against the root:\n%s", year)
+	}
+
+	month := read("2025/01/index.html")
+	for _, want := range []string{
+		`<base href="../../">`,
+		`<h2 class="archive-title">January 2025</h2>`,
+		`href="2025/03/" rel="prev"`,
+		`href="2024/12/" rel="next"`,
+		`<a href="archive.html">Archive</a>`,
+		"Ben &amp; Jerry",
+	} {
+		if !strings.Contains(month, want) {
+			t.Errorf("2025/01/index.html missing %q", want)
+		}
+	}
+	if strings.Contains(month, "Second") {
+		t.Error("2025/01/index.html lists another month's entries")
+	}
+}
+
+func TestGenerateArchiveEmpty(t *testing.T) {
+	t.Parallel()
+	gen := newFeedTestGenerator(t)
+	dir := t.TempDir()
+
+	pages, err := gen.GenerateArchive(context.Background(), dir, feedTestData(), nil, nil)
+	if err != nil {
+		t.Fatalf("GenerateArchive() error = %v", err)
+	}
+	if len(pages) != 1 || pages[0].Path != ArchiveFileName {
+		t.Errorf("pages = %+v, want only the archive index", pages)
+	}
+
+	if err := RemoveArchive(dir); err != nil {
+		t.Fatalf("RemoveArchive() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, ArchiveFileName)); !os.IsNotExist(err) {
+		t.Error("RemoveArchive() kept archive.html")
+	}
+}
+
+func TestThemeArchiveBlock(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "template.html")
+	if err := os.WriteFile(path, []byte(`{{template "archive" .}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	gen, err := NewWithTemplate(path)
+	if err != nil {
+		t.Fatalf("NewWithTemplate() error = %v", err)
+	}
+	if gen.template.Lookup("archive") == nil {
+		t.Error("a template without an archive block did not get the built-in one")
+	}
+}
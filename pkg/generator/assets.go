@@ -0,0 +1,474 @@
+package generator
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html"
+	"html/template"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ThemeManifestName is the optional asset manifest next to a theme's template.html
+const ThemeManifestName = "theme.json"
+
+// ThemeTemplateName is the main template of a theme directory
+const ThemeTemplateName = "template.html"
+
+// PartialsDirName is the theme subdirectory whose templates override named blocks
+const PartialsDirName = "partials"
+
+// AssetHeadersName is the cache header guidance file written for themes with fonts or icons
+const AssetHeadersName = "asset-headers.txt"
+
+// Base Content Security Policies. Custom themes get the stricter policy
+// (no inline styles); the default template embeds its CSS inline.
+const (
+	defaultCSP = "default-src 'self'; script-src 'self'; style-src 'self' 'unsafe-inline'; img-src 'self' https:; object-src 'none'; base-uri 'self';"
+	themeCSP   = "default-src 'self'; script-src 'self'; style-src 'self'; img-src 'self' https:; object-src 'none'; base-uri 'self';"
+)
+
+// ThemeManifest declares fonts and icons used by a theme.
+//
+// Example theme.json:
+//
+//	{
+//	  "fonts": [
+//	    {"family": "Inter", "src": "static/fonts/inter-latin.woff2",
+//	     "weight": "400", "unicode_range": "U+0000-00FF", "preload": true}
+//	  ],
+//	  "icons": [
+//	    {"name": "favicon", "src": "static/icons/favicon.svg", "rel": "icon"},
+//	    {"name": "feed", "src": "static/icons/feed.svg"}
+//	  ],
+//	  "csp": {"img-src": ["data:"]}
+//	}
+type ThemeManifest struct {
+	Fonts []FontAsset         `json:"fonts"`
+	Icons []IconAsset         `json:"icons"`
+	CSP   map[string][]string `json:"csp"` // Extra sources per CSP directive
+}
+
+// FontAsset describes a web font file. Src is relative to the theme directory
+// or an absolute https URL.
+type FontAsset struct {
+	Family       string `json:"family"`
+	Src          string `json:"src"`
+	Weight       string `json:"weight,omitempty"`
+	Style        string `json:"style,omitempty"`
+	Display      string `json:"display,omitempty"`       // font-display, default "swap"
+	UnicodeRange string `json:"unicode_range,omitempty"` // For subset fonts
+	Preload      bool   `json:"preload,omitempty"`
+}
+
+// IconAsset describes an icon file. Icons with a Rel are linked from <head>;
+// all icons are available to templates as {{index .Icons "name"}}.
+type IconAsset struct {
+	Name  string `json:"name"`
+	Src   string `json:"src"`
+	Rel   string `json:"rel,omitempty"` // e.g. "icon", "apple-touch-icon"
+	Type  string `json:"type,omitempty"`
+	Sizes string `json:"sizes,omitempty"`
+}
+
+// themeAssets is the rendered form of a manifest, computed once per generator
+type themeAssets struct {
+	manifest ThemeManifest
+	themeDir string
+	csp      string
+	head     template.HTML // Everything after the CSP meta tag
+	icons    map[string]string
+}
+
+// LoadThemeManifest reads and validates a theme manifest
+func LoadThemeManifest(manifestPath string) (ThemeManifest, error) {
+	var m ThemeManifest
+
+	data, err := os.ReadFile(manifestPath)
+	if err != nil {
+		return m, fmt.Errorf("read theme manifest: %w", err)
+	}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return m, fmt.Errorf("parse theme manifest %s: %w", manifestPath, err)
+	}
+
+	themeDir := filepath.Dir(manifestPath)
+	for i, font := range m.Fonts {
+		if font.Family == "" {
+			return m, fmt.Errorf("font %d: family is required", i+1)
+		}
+		if err := validateAssetSrc(themeDir, font.Src); err != nil {
+			return m, fmt.Errorf("font %q: %w", font.Family, err)
+		}
+	}
+	for i, icon := range m.Icons {
+		if icon.Name == "" {
+			return m, fmt.Errorf("icon %d: name is required", i+1)
+		}
+		if isRemoteAsset(icon.Src) {
+			return m, fmt.Errorf("icon %q: icons must be local files", icon.Name)
+		}
+		if err := validateAssetSrc(themeDir, icon.Src); err != nil {
+			return m, fmt.Errorf("icon %q: %w", icon.Name, err)
+		}
+	}
+	for directive, sources := range m.CSP {
+		if !validCSPDirective(directive) {
+			return m, fmt.Errorf("csp: invalid directive %q", directive)
+		}
+		for _, source := range sources {
+			if strings.ContainsAny(source, ";,\"") || strings.TrimSpace(source) == "" {
+				return m, fmt.Errorf("csp %s: invalid source %q", directive, source)
+			}
+		}
+	}
+
+	return m, nil
+}
+
+// validateAssetSrc checks that src is an https URL or an existing file inside the theme
+func validateAssetSrc(themeDir, src string) error {
+	if src == "" {
+		return fmt.Errorf("src is required")
+	}
+	if isRemoteAsset(src) {
+		u, err := url.Parse(src)
+		if err != nil || u.Scheme != "https" || u.Host == "" {
+			return fmt.Errorf("remote src must be an https URL: %s", src)
+		}
+		return nil
+	}
+
+	// Safety check: assets must stay inside the theme directory
+	clean := path.Clean(src)
+	if path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") {
+		return fmt.Errorf("src must be relative to the theme directory: %s", src)
+	}
+	if _, err := os.Stat(filepath.Join(themeDir, filepath.FromSlash(clean))); err != nil {
+		return fmt.Errorf("asset not found: %s", src)
+	}
+	return nil
+}
+
+// validCSPDirective reports whether name looks like a CSP directive (e.g. "font-src")
+func validCSPDirective(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if (r < 'a' || r > 'z') && r != '-' {
+			return false
+		}
+	}
+	return true
+}
+
+func isRemoteAsset(src string) bool {
+	return strings.Contains(src, "://")
+}
+
+// loadThemeAssets loads the manifest in themeDir, if present. baseCSP is the
+// policy the theme's main template needs before the manifest extends it.
+func loadThemeAssets(themeDir, baseCSP string) (*themeAssets, error) {
+	manifestPath := filepath.Join(themeDir, ThemeManifestName)
+
+	if _, err := os.Stat(manifestPath); os.IsNotExist(err) {
+		return &themeAssets{themeDir: themeDir, csp: baseCSP}, nil
+	}
+
+	m, err := LoadThemeManifest(manifestPath)
+	if err != nil {
+		return nil, err
+	}
+	return newThemeAssets(themeDir, m, baseCSP), nil
+}
+
+// newThemeAssets renders the <head> markup and CSP for a manifest
+func newThemeAssets(themeDir string, m ThemeManifest, baseCSP string) *themeAssets {
+	a := &themeAssets{
+		manifest: m,
+		themeDir: themeDir,
+		icons:    make(map[string]string),
+	}
+
+	policy := parseCSP(baseCSP)
+	var head strings.Builder
+
+	for _, icon := range m.Icons {
+		a.icons[icon.Name] = icon.Src
+		if icon.Rel == "" {
+			continue
+		}
+		fmt.Fprintf(&head, `<link rel="%s" href="%s"`, html.EscapeString(icon.Rel), html.EscapeString(icon.Src))
+		if icon.Type != "" {
+			fmt.Fprintf(&head, ` type="%s"`, html.EscapeString(icon.Type))
+		}
+		if icon.Sizes != "" {
+			fmt.Fprintf(&head, ` sizes="%s"`, html.EscapeString(icon.Sizes))
+		}
+		head.WriteString(">\n")
+	}
+
+	if len(m.Fonts) > 0 {
+		policy.add("font-src", "'self'")
+		for _, font := range m.Fonts {
+			if isRemoteAsset(font.Src) {
+				policy.add("font-src", assetOrigin(font.Src))
+			}
+			if font.Preload {
+				fmt.Fprintf(&head, `<link rel="preload" href="%s" as="font"`, html.EscapeString(font.Src))
+				if mime := fontMIMEType(font.Src); mime != "" {
+					fmt.Fprintf(&head, ` type="%s"`, mime)
+				}
+				head.WriteString(" crossorigin>\n")
+			}
+		}
+
+		css := fontFaceCSS(m.Fonts)
+		fmt.Fprintf(&head, "<style>%s</style>\n", css)
+
+		// A hash would disable 'unsafe-inline' in CSP2+ browsers, breaking
+		// templates that rely on it, so only add one under a strict policy.
+		if !policy.has("style-src", "'unsafe-inline'") {
+			sum := sha256.Sum256([]byte(css))
+			policy.add("style-src", "'sha256-"+base64.StdEncoding.EncodeToString(sum[:])+"'")
+		}
+	}
+
+	for directive, sources := range m.CSP {
+		for _, source := range sources {
+			policy.add(directive, source)
+		}
+	}
+
+	a.csp = policy.String()
+	a.head = template.HTML(head.String())
+	return a
+}
+
+// headTags returns the CSP meta tag, a base tag on pages below the site
+// root, and the asset tags
+func (a *themeAssets) headTags(root string) template.HTML {
+	// CSP sources never contain double quotes (validated on load), and
+	// single quotes must stay literal for the policy to be readable.
+	meta := `<meta http-equiv="Content-Security-Policy" content="` + strings.ReplaceAll(a.csp, `"`, "&quot;") + `">` + "\n"
+	// Pages below the site root resolve the theme's relative URLs, and those
+	// in entry content, against it
+	if root != "" {
+		meta += `<base href="` + template.HTMLEscapeString(root) + `">` + "\n"
+	}
+	return template.HTML(meta) + a.head
+}
+
+// fontFaceCSS renders @font-face rules for the declared fonts
+func fontFaceCSS(fonts []FontAsset) string {
+	var b strings.Builder
+	for _, font := range fonts {
+		display := font.Display
+		if display == "" {
+			display = "swap"
+		}
+		fmt.Fprintf(&b, "@font-face{font-family:%s;src:url(%s)", cssString(font.Family), cssString(font.Src))
+		if format := fontFormat(font.Src); format != "" {
+			fmt.Fprintf(&b, " format(%s)", cssString(format))
+		}
+		b.WriteString(";")
+		if font.Weight != "" {
+			fmt.Fprintf(&b, "font-weight:%s;", cssValue(font.Weight))
+		}
+		if font.Style != "" {
+			fmt.Fprintf(&b, "font-style:%s;", cssValue(font.Style))
+		}
+		fmt.Fprintf(&b, "font-display:%s;", cssValue(display))
+		if font.UnicodeRange != "" {
+			fmt.Fprintf(&b, "unicode-range:%s;", cssValue(font.UnicodeRange))
+		}
+		b.WriteString("}")
+	}
+	return b.String()
+}
+
+// cssString quotes s as a CSS string that cannot close the surrounding <style>
+func cssString(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "<", `\3c `, "\n", " ", "\r", " ")
+	return `"` + r.Replace(s) + `"`
+}
+
+// cssValue strips characters that could end a declaration or the <style> element
+func cssValue(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ';', '{', '}', '<', '>', '"', '\\', '\n', '\r':
+			return -1
+		}
+		return r
+	}, s)
+}
+
+func fontFormat(src string) string {
+	switch strings.ToLower(path.Ext(src)) {
+	case ".woff2":
+		return "woff2"
+	case ".woff":
+		return "woff"
+	case ".ttf":
+		return "truetype"
+	case ".otf":
+		return "opentype"
+	}
+	return ""
+}
+
+func fontMIMEType(src string) string {
+	switch strings.ToLower(path.Ext(src)) {
+	case ".woff2":
+		return "font/woff2"
+	case ".woff":
+		return "font/woff"
+	case ".ttf":
+		return "font/ttf"
+	case ".otf":
+		return "font/otf"
+	}
+	return ""
+}
+
+// assetOrigin returns the scheme://host of a remote asset URL
+func assetOrigin(src string) string {
+	u, err := url.Parse(src)
+	if err != nil {
+		return src
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+// copyThemeAssets copies local fonts and icons to the output directory,
+// preserving their paths relative to the theme directory
+func (a *themeAssets) copyThemeAssets(ctx context.Context, outputDir string) error {
+	var srcs []string
+	for _, font := range a.manifest.Fonts {
+		if !isRemoteAsset(font.Src) {
+			srcs = append(srcs, font.Src)
+		}
+	}
+	for _, icon := range a.manifest.Icons {
+		srcs = append(srcs, icon.Src)
+	}
+
+	for _, src := range srcs {
+		rel := filepath.FromSlash(path.Clean(src))
+		dst := filepath.Join(outputDir, rel)
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return fmt.Errorf("create asset directory: %w", err)
+		}
+		if err := copyFile(ctx, filepath.Join(a.themeDir, rel), dst); err != nil {
+			return fmt.Errorf("copy %s: %w", src, err)
+		}
+	}
+	return nil
+}
+
+// writeAssetHeaders writes recommended HTTP headers for theme assets in
+// the _headers format understood by Netlify and Cloudflare Pages.
+func (a *themeAssets) writeAssetHeaders(outputDir string) error {
+	if len(a.manifest.Fonts) == 0 && len(a.manifest.Icons) == 0 {
+		return nil
+	}
+
+	var b strings.Builder
+	b.WriteString("# Recommended cache headers for theme assets, generated by Rogue Planet.\n")
+	b.WriteString("# Copy into your host's _headers file or web server configuration.\n")
+	b.WriteString("# Asset filenames should change when their contents do (e.g. inter-v4.woff2).\n\n")
+
+	for _, font := range a.manifest.Fonts {
+		if isRemoteAsset(font.Src) {
+			continue
+		}
+		fmt.Fprintf(&b, "/%s\n", path.Clean(font.Src))
+		b.WriteString("  Cache-Control: public, max-age=31536000, immutable\n")
+		b.WriteString("  Access-Control-Allow-Origin: *\n")
+		if font.UnicodeRange != "" {
+			// Subsets are fetched only when the page uses their range; each
+			// subset file caches independently.
+			fmt.Fprintf(&b, "  # Subset for %s\n", font.UnicodeRange)
+		}
+		b.WriteString("\n")
+	}
+	for _, icon := range a.manifest.Icons {
+		fmt.Fprintf(&b, "/%s\n", path.Clean(icon.Src))
+		b.WriteString("  Cache-Control: public, max-age=604800\n\n")
+	}
+
+	if err := os.WriteFile(filepath.Join(outputDir, AssetHeadersName), []byte(b.String()), 0644); err != nil {
+		return fmt.Errorf("write asset headers: %w", err)
+	}
+	return nil
+}
+
+// cspPolicy is an ordered Content Security Policy
+type cspPolicy struct {
+	directives []string
+	sources    map[string][]string
+}
+
+// parseCSP parses a policy string such as "default-src 'self'; img-src https:;"
+func parseCSP(s string) *cspPolicy {
+	p := &cspPolicy{sources: make(map[string][]string)}
+	for _, part := range strings.Split(s, ";") {
+		fields := strings.Fields(part)
+		if len(fields) == 0 {
+			continue
+		}
+		for _, source := range fields[1:] {
+			p.add(fields[0], source)
+		}
+		if len(fields) == 1 {
+			p.add(fields[0], "")
+		}
+	}
+	return p
+}
+
+// add appends source to directive, creating the directive if needed
+func (p *cspPolicy) add(directive, source string) {
+	directive = strings.ToLower(directive)
+	if _, ok := p.sources[directive]; !ok {
+		p.directives = append(p.directives, directive)
+		p.sources[directive] = nil
+	}
+	if source == "" || p.has(directive, source) {
+		return
+	}
+	p.sources[directive] = append(p.sources[directive], source)
+}
+
+func (p *cspPolicy) has(directive, source string) bool {
+	for _, s := range p.sources[directive] {
+		if s == source {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *cspPolicy) String() string {
+	var b strings.Builder
+	for i, directive := range p.directives {
+		if i > 0 {
+			b.WriteString(" ")
+		}
+		b.WriteString(directive)
+		for _, source := range p.sources[directive] {
+			b.WriteString(" ")
+			b.WriteString(source)
+		}
+		b.WriteString(";")
+	}
+	return b.String()
+}
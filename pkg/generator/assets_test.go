@@ -0,0 +1,214 @@
+package generator
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTheme creates a theme directory with template.html, the given files,
+// and an optional theme.json manifest. It returns the template path.
+func writeTheme(t *testing.T, dir, manifest string, files ...string) string {
+	t.Helper()
+
+	for _, f := range files {
+		path := filepath.Join(dir, filepath.FromSlash(f))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("asset"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if manifest != "" {
+		if err := os.WriteFile(filepath.Join(dir, ThemeManifestName), []byte(manifest), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	templatePath := filepath.Join(dir, "template.html")
+	tmpl := "<html><head>{{.HeadTags}}</head><body>{{with .Icons}}<img src=\"{{index . \"feed\"}}\">{{end}}</body></html>"
+	if err := os.WriteFile(templatePath, []byte(tmpl), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return templatePath
+}
+
+func TestThemeManifestFontsAndIcons(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	themeDir := filepath.Join(tmpDir, "theme")
+
+	manifest := `{
+		"fonts": [
+			{"family": "Inter", "src": "static/fonts/inter-latin.woff2", "weight": "400", "unicode_range": "U+0000-00FF", "preload": true},
+			{"family": "Mono", "src": "https://fonts.example.net/mono.woff2"}
+		],
+		"icons": [
+			{"name": "favicon", "src": "static/icons/favicon.svg", "rel": "icon", "type": "image/svg+xml"},
+			{"name": "feed", "src": "static/icons/feed.svg"}
+		],
+		"csp": {"img-src": ["data:"]}
+	}`
+	templatePath := writeTheme(t, themeDir, manifest,
+		"static/fonts/inter-latin.woff2", "static/icons/favicon.svg", "static/icons/feed.svg")
+
+	gen, err := NewWithTemplate(templatePath)
+	if err != nil {
+		t.Fatalf("NewWithTemplate() error = %v", err)
+	}
+
+	outputDir := filepath.Join(tmpDir, "public")
+	outputPath := filepath.Join(outputDir, "index.html")
+	if err := gen.GenerateToFile(context.Background(), outputPath, TemplateData{Title: "Test"}); err != nil {
+		t.Fatalf("GenerateToFile() error = %v", err)
+	}
+
+	data, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := string(data)
+
+	wantParts := []string{
+		`<link rel="preload" href="static/fonts/inter-latin.woff2" as="font" type="font/woff2" crossorigin>`,
+		`<link rel="icon" href="static/icons/favicon.svg" type="image/svg+xml">`,
+		`font-family:"Inter";src:url("static/fonts/inter-latin.woff2") format("woff2");font-weight:400;font-display:swap;unicode-range:U+0000-00FF;`,
+		`<img src="static/icons/feed.svg">`,
+		`font-src 'self' https://fonts.example.net;`,
+		`img-src 'self' https: data:;`,
+		`style-src 'self' 'sha256-`,
+	}
+	for _, want := range wantParts {
+		if !strings.Contains(output, want) {
+			t.Errorf("output missing %q\n%s", want, output)
+		}
+	}
+
+	// Remote fonts are not preloaded or copied
+	if strings.Contains(output, `rel="preload" href="https://fonts.example.net`) {
+		t.Error("remote font without preload flag should not be preloaded")
+	}
+
+	for _, f := range []string{"static/fonts/inter-latin.woff2", "static/icons/feed.svg", AssetHeadersName} {
+		if _, err := os.Stat(filepath.Join(outputDir, filepath.FromSlash(f))); err != nil {
+			t.Errorf("expected %s in output: %v", f, err)
+		}
+	}
+
+	headers, _ := os.ReadFile(filepath.Join(outputDir, AssetHeadersName))
+	if !strings.Contains(string(headers), "/static/fonts/inter-latin.woff2\n  Cache-Control: public, max-age=31536000, immutable") {
+		t.Errorf("asset headers missing font cache guidance:\n%s", headers)
+	}
+}
+
+func TestThemeManifestStyleHashMatchesInlineCSS(t *testing.T) {
+	t.Parallel()
+	fonts := []FontAsset{{Family: "Inter", Src: "static/inter.woff2"}}
+	a := newThemeAssets(t.TempDir(), ThemeManifest{Fonts: fonts}, themeCSP)
+
+	// The hash must cover exactly the emitted <style> body
+	head := string(a.head)
+	start := strings.Index(head, "<style>") + len("<style>")
+	end := strings.Index(head, "</style>")
+	css := head[start:end]
+
+	if css != fontFaceCSS(fonts) {
+		t.Fatalf("inline CSS = %q, want %q", css, fontFaceCSS(fonts))
+	}
+
+	other := newThemeAssets(t.TempDir(), ThemeManifest{Fonts: []FontAsset{{Family: "Other", Src: "static/other.woff2"}}}, themeCSP)
+	if a.csp == other.csp {
+		t.Error("different inline CSS should produce different CSP hashes")
+	}
+}
+
+func TestThemeManifestKeepsUnsafeInline(t *testing.T) {
+	t.Parallel()
+	fonts := []FontAsset{{Family: "Inter", Src: "static/inter.woff2"}}
+	a := newThemeAssets(t.TempDir(), ThemeManifest{Fonts: fonts}, defaultCSP)
+
+	if strings.Contains(a.csp, "sha256-") {
+		t.Errorf("CSP %q should not add a hash alongside 'unsafe-inline'", a.csp)
+	}
+}
+
+func TestThemeWithoutManifest(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	templatePath := writeTheme(t, tmpDir, "")
+
+	gen, err := NewWithTemplate(templatePath)
+	if err != nil {
+		t.Fatalf("NewWithTemplate() error = %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := gen.Generate(context.Background(), &buf, TemplateData{}); err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+
+	want := `<meta http-equiv="Content-Security-Policy" content="` + themeCSP + `">`
+	if !strings.Contains(buf.String(), want) {
+		t.Errorf("output missing base theme CSP\n%s", buf.String())
+	}
+}
+
+func TestLoadThemeManifestErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		manifest string
+		wantErr  string
+	}{
+		{"invalid json", `{`, "parse theme manifest"},
+		{"missing family", `{"fonts":[{"src":"static/a.woff2"}]}`, "family is required"},
+		{"missing file", `{"fonts":[{"family":"A","src":"static/missing.woff2"}]}`, "asset not found"},
+		{"path traversal", `{"fonts":[{"family":"A","src":"../secret.woff2"}]}`, "relative to the theme directory"},
+		{"plain http", `{"fonts":[{"family":"A","src":"http://example.com/a.woff2"}]}`, "https URL"},
+		{"remote icon", `{"icons":[{"name":"i","src":"https://example.com/i.svg"}]}`, "local files"},
+		{"bad directive", `{"csp":{"font-src;":["x"]}}`, "invalid directive"},
+		{"bad source", `{"csp":{"font-src":["a; script-src *"]}}`, "invalid source"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			dir := t.TempDir()
+			templatePath := writeTheme(t, dir, tt.manifest)
+
+			_, err := NewWithTemplate(templatePath)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("NewWithTemplate() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseCSP(t *testing.T) {
+	t.Parallel()
+	p := parseCSP(themeCSP)
+	if got := p.String(); got != themeCSP {
+		t.Errorf("round trip = %q, want %q", got, themeCSP)
+	}
+
+	p.add("img-src", "https:") // duplicate ignored
+	p.add("font-src", "'self'")
+	want := strings.TrimSuffix(themeCSP, ";") + "; font-src 'self';"
+	if got := p.String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
+
+func TestCSSStringEscapesStyleClose(t *testing.T) {
+	t.Parallel()
+	css := fontFaceCSS([]FontAsset{{Family: `</style><script>`, Src: "a.woff2"}})
+	if strings.Contains(css, "</style") || strings.Contains(css, "<script") {
+		t.Errorf("fontFaceCSS() = %q, should not contain raw markup", css)
+	}
+}
@@ -0,0 +1,357 @@
+package generator
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Output feed filenames, written alongside index.html
+const (
+	AtomFileName = "atom.xml"
+	RSSFileName  = "rss.xml"
+)
+
+// FeedOptions controls which aggregated feeds are written
+type FeedOptions struct {
+	MaxEntries int  // Entries per feed (per page for JSON Feed); 0 means all entries
+	RSS        bool // Also write rss.xml
+	JSONFeed   bool // Also write feed.json, paginated with next_url
+}
+
+type atomFeed struct {
+	XMLName   xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title     string      `xml:"title"`
+	Subtitle  string      `xml:"subtitle,omitempty"`
+	ID        string      `xml:"id"`
+	Updated   string      `xml:"updated"`
+	Links     []atomLink  `xml:"link"`
+	Author    *atomPerson `xml:"author,omitempty"`
+	Generator atomText    `xml:"generator"`
+	Entries   []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomPerson struct {
+	Name  string `xml:"name"`
+	Email string `xml:"email,omitempty"`
+}
+
+type atomText struct {
+	Type    string `xml:"type,attr,omitempty"`
+	Version string `xml:"version,attr,omitempty"`
+	Body    string `xml:",chardata"`
+}
+
+type atomEntry struct {
+	Title      atomText       `xml:"title"`
+	ID         string         `xml:"id"`
+	Links      []atomLink     `xml:"link"`
+	Published  string         `xml:"published,omitempty"`
+	Updated    string         `xml:"updated"`
+	Author     *atomPerson    `xml:"author,omitempty"`
+	Categories []atomCategory `xml:"category"`
+	Summary    *atomText      `xml:"summary,omitempty"`
+	Content    *atomText      `xml:"content,omitempty"`
+	Source     *atomSource    `xml:"source,omitempty"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomSource struct {
+	Title string     `xml:"title"`
+	Links []atomLink `xml:"link"`
+}
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	AtomNS  string     `xml:"xmlns:atom,attr"`
+	DCNS    string     `xml:"xmlns:dc,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	Generator     string    `xml:"generator"`
+	AtomLink      *atomLink `xml:"atom:link,omitempty"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string    `xml:"title"`
+	Link        string    `xml:"link,omitempty"`
+	GUID        *rssGUID  `xml:"guid,omitempty"`
+	PubDate     string    `xml:"pubDate,omitempty"`
+	Creator     string    `xml:"dc:creator,omitempty"`
+	Source      string    `xml:"source,omitempty"`
+	Categories  []string  `xml:"category"`
+	Description string    `xml:"description,omitempty"`
+	PlanetLink  *atomLink `xml:"atom:link,omitempty"` // The entry on the planet, as rel="related"
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+// GenerateAtom writes an Atom 1.0 feed of the aggregated entries
+func (g *Generator) GenerateAtom(ctx context.Context, w io.Writer, data TemplateData, maxEntries int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	entries := limitEntries(data.Entries, maxEntries)
+	feed := atomFeed{
+		Title:     data.Title,
+		Subtitle:  data.Subtitle,
+		ID:        feedID(data),
+		Updated:   g.feedUpdated(entries).Format(time.RFC3339),
+		Generator: atomText{Version: g.buildInfo.Version, Body: "Rogue Planet"},
+	}
+	if data.Link != "" {
+		feed.Links = []atomLink{
+			{Rel: "self", Type: "application/atom+xml", Href: absoluteURL(data.Link, AtomFileName)},
+			{Rel: "alternate", Type: "text/html", Href: absoluteURL(data.Link, "")},
+		}
+	}
+	if data.OwnerName != "" {
+		feed.Author = &atomPerson{Name: data.OwnerName, Email: data.OwnerEmail}
+	}
+
+	for _, e := range entries {
+		entry := atomEntry{
+			Title:   atomText{Type: "html", Body: string(e.Title)},
+			ID:      entryID(e),
+			Updated: entryUpdated(e).Format(time.RFC3339),
+		}
+		if e.Link != "" {
+			entry.Links = []atomLink{{Rel: "alternate", Type: "text/html", Href: e.Link}}
+		}
+		if href := planetURL(data, e); href != "" {
+			entry.Links = append(entry.Links, atomLink{Rel: "related", Type: "text/html", Href: href})
+		}
+		if !e.Published.IsZero() {
+			entry.Published = e.Published.Format(time.RFC3339)
+		}
+		if e.Author != "" {
+			entry.Author = &atomPerson{Name: e.Author}
+		}
+		for _, c := range e.Categories {
+			entry.Categories = append(entry.Categories, atomCategory{Term: c})
+		}
+		if e.Summary != "" {
+			entry.Summary = &atomText{Type: "html", Body: feedHTML(data.Link, e.Summary)}
+		}
+		if e.Content != "" {
+			entry.Content = &atomText{Type: "html", Body: feedHTML(data.Link, e.Content)}
+		}
+		if e.FeedTitle != "" || e.FeedLink != "" {
+			entry.Source = &atomSource{Title: e.FeedTitle}
+			if e.FeedLink != "" {
+				entry.Source.Links = []atomLink{{Rel: "alternate", Href: e.FeedLink}}
+			}
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+
+	return writeXML(w, feed)
+}
+
+// GenerateRSS writes an RSS 2.0 feed of the aggregated entries
+func (g *Generator) GenerateRSS(ctx context.Context, w io.Writer, data TemplateData, maxEntries int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	entries := limitEntries(data.Entries, maxEntries)
+	description := data.Subtitle
+	if description == "" {
+		description = data.Title
+	}
+
+	channel := rssChannel{
+		Title:         data.Title,
+		Link:          absoluteURL(data.Link, ""),
+		Description:   description,
+		LastBuildDate: g.feedUpdated(entries).Format(time.RFC1123Z),
+		Generator:     "Rogue Planet v" + g.buildInfo.Version,
+	}
+	if data.Link != "" {
+		channel.AtomLink = &atomLink{Rel: "self", Type: "application/rss+xml", Href: absoluteURL(data.Link, RSSFileName)}
+	}
+
+	for _, e := range entries {
+		item := rssItem{
+			// RSS titles are plain text; sanitized titles may contain entities
+			Title:      html.UnescapeString(string(e.Title)),
+			Link:       e.Link,
+			GUID:       &rssGUID{IsPermaLink: false, Value: entryID(e)},
+			Creator:    e.Author,
+			Source:     e.FeedTitle,
+			Categories: e.Categories,
+		}
+		if !e.Published.IsZero() {
+			item.PubDate = e.Published.Format(time.RFC1123Z)
+		}
+		if href := planetURL(data, e); href != "" {
+			item.PlanetLink = &atomLink{Rel: "related", Type: "text/html", Href: href}
+		}
+		if e.Content != "" {
+			item.Description = feedHTML(data.Link, e.Content)
+		} else {
+			item.Description = feedHTML(data.Link, e.Summary)
+		}
+		channel.Items = append(channel.Items, item)
+	}
+
+	return writeXML(w, rssFeed{
+		Version: "2.0",
+		AtomNS:  "http://www.w3.org/2005/Atom",
+		DCNS:    "http://purl.org/dc/elements/1.1/",
+		Channel: channel,
+	})
+}
+
+// WriteFeeds writes atom.xml (and rss.xml and feed.json if requested) into outputDir
+func (g *Generator) WriteFeeds(ctx context.Context, outputDir string, data TemplateData, opts FeedOptions) error {
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return fmt.Errorf("create output directory: %w", err)
+	}
+
+	if err := writeFeedFile(filepath.Join(outputDir, AtomFileName), func(w io.Writer) error {
+		return g.GenerateAtom(ctx, w, data, opts.MaxEntries)
+	}); err != nil {
+		return fmt.Errorf("write atom feed: %w", err)
+	}
+
+	if opts.RSS {
+		if err := writeFeedFile(filepath.Join(outputDir, RSSFileName), func(w io.Writer) error {
+			return g.GenerateRSS(ctx, w, data, opts.MaxEntries)
+		}); err != nil {
+			return fmt.Errorf("write rss feed: %w", err)
+		}
+	}
+
+	if opts.JSONFeed {
+		if err := g.writeJSONFeed(ctx, outputDir, data, opts.MaxEntries); err != nil {
+			return fmt.Errorf("write json feed: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func writeFeedFile(path string, write func(io.Writer) error) (err error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := f.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+	return write(f)
+}
+
+func writeXML(w io.Writer, v interface{}) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return fmt.Errorf("encode feed: %w", err)
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func limitEntries(entries []EntryData, max int) []EntryData {
+	if max > 0 && len(entries) > max {
+		return entries[:max]
+	}
+	return entries
+}
+
+// feedUpdated returns the newest entry timestamp so that regenerating an
+// unchanged planet produces an identical feed; it falls back to now.
+func (g *Generator) feedUpdated(entries []EntryData) time.Time {
+	var newest time.Time
+	for _, e := range entries {
+		if t := entryUpdated(e); t.After(newest) {
+			newest = t
+		}
+	}
+	if newest.IsZero() {
+		return g.timeProvider.Now()
+	}
+	return newest
+}
+
+func entryUpdated(e EntryData) time.Time {
+	if e.Updated.After(e.Published) {
+		return e.Updated
+	}
+	return e.Published
+}
+
+// entryID returns a stable identifier for an entry: its original ID, or its link
+func entryID(e EntryData) string {
+	if e.ID != "" {
+		return e.ID
+	}
+	return e.Link
+}
+
+// planetURL returns the absolute URL of the entry on the planet, its
+// permalink, or "" if it has none or the planet's link is not known
+func planetURL(data TemplateData, e EntryData) string {
+	if data.Link == "" || e.Permalink == "" {
+		return ""
+	}
+	return absoluteURL(data.Link, e.Permalink)
+}
+
+func feedID(data TemplateData) string {
+	if data.Link != "" {
+		return absoluteURL(data.Link, "")
+	}
+	return "urn:rogue-planet:" + strings.ToLower(strings.ReplaceAll(data.Title, " ", "-"))
+}
+
+// feedHTML prepares entry HTML for a syndication feed. Readers resolve
+// relative URLs against the feed's own location (or not at all), so links to
+// images cached in MediaDir are made absolute when the planet's link is known.
+func feedHTML(base string, h template.HTML) string {
+	if base == "" {
+		return string(h)
+	}
+	return strings.ReplaceAll(string(h), `src="`+MediaDir+`/`, `src="`+absoluteURL(base, MediaDir+"/"))
+}
+
+// absoluteURL joins a file name onto the planet's base URL
+func absoluteURL(base, name string) string {
+	if base == "" {
+		return name
+	}
+	return strings.TrimSuffix(base, "/") + "/" + name
+}
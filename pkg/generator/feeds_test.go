@@ -0,0 +1,359 @@
+package generator
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"html/template"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adewale/rogue_planet/pkg/normalizer"
+	"github.com/adewale/rogue_planet/pkg/timeprovider"
+)
+
+func feedTestData() TemplateData {
+	base := time.Date(2025, 3, 10, 9, 0, 0, 0, time.UTC)
+	return TemplateData{
+		Title:      "Test Planet",
+		Subtitle:   "Posts from friends",
+		Link:       "https://planet.example.com/",
+		OwnerName:  "Owner",
+		OwnerEmail: "owner@example.com",
+		Entries: []EntryData{
+			{
+				ID:        "tag:a.example.com,2025:1",
+				Title:     template.HTML("Ben &amp; Jerry"),
+				Link:      "https://a.example.com/1",
+				Author:    "Alice",
+				FeedTitle: "Alice's Blog",
+				FeedLink:  "https://a.example.com/",
+				Published: base.Add(2 * time.Hour),
+				Updated:   base.Add(3 * time.Hour),
+				Content:   template.HTML("<p>Hello <b>world</b></p>"),
+			},
+			{
+				Title:     template.HTML("Second"),
+				Link:      "https://b.example.com/2",
+				FeedTitle: "Bob",
+				Published: base.Add(time.Hour),
+				Summary:   template.HTML("<p>Summary</p>"),
+			},
+			{
+				Title:     template.HTML("Third"),
+				Link:      "https://b.example.com/3",
+				Published: base,
+			},
+		},
+	}
+}
+
+func newFeedTestGenerator(t *testing.T) *Generator {
+	t.Helper()
+	gen, err := NewWithTimeProvider(timeprovider.NewFakeClock(time.Date(2025, 3, 11, 0, 0, 0, 0, time.UTC)))
+	if err != nil {
+		t.Fatalf("NewWithTimeProvider() error = %v", err)
+	}
+	return gen
+}
+
+func TestGenerateAtom(t *testing.T) {
+	t.Parallel()
+	gen := newFeedTestGenerator(t)
+
+	var buf bytes.Buffer
+	if err := gen.GenerateAtom(context.Background(), &buf, feedTestData(), 2); err != nil {
+		t.Fatalf("GenerateAtom() error = %v", err)
+	}
+
+	var feed atomFeed
+	if err := xml.Unmarshal(buf.Bytes(), &feed); err != nil {
+		t.Fatalf("output is not valid XML: %v\n%s", err, buf.String())
+	}
+
+	if len(feed.Entries) != 2 {
+		t.Errorf("got %d entries, want 2 (limit)", len(feed.Entries))
+	}
+	if feed.Updated != "2025-03-10T12:00:00Z" {
+		t.Errorf("feed updated = %s, want newest entry update", feed.Updated)
+	}
+
+	links := map[string]string{}
+	for _, l := range feed.Links {
+		links[l.Rel] = l.Href
+	}
+	if links["self"] != "https://planet.example.com/atom.xml" {
+		t.Errorf("self link = %q", links["self"])
+	}
+	if links["alternate"] != "https://planet.example.com/" {
+		t.Errorf("alternate link = %q", links["alternate"])
+	}
+
+	first := feed.Entries[0]
+	if first.ID != "tag:a.example.com,2025:1" {
+		t.Errorf("entry id = %q, want original ID", first.ID)
+	}
+	if feed.Entries[1].ID != "https://b.example.com/2" {
+		t.Errorf("entry without ID should fall back to link, got %q", feed.Entries[1].ID)
+	}
+	if first.Content == nil || first.Content.Body != "<p>Hello <b>world</b></p>" {
+		t.Errorf("entry content = %+v", first.Content)
+	}
+	if first.Source == nil || first.Source.Title != "Alice's Blog" {
+		t.Errorf("entry source = %+v", first.Source)
+	}
+
+	// HTML content must be escaped, not embedded as markup
+	if strings.Contains(buf.String(), "<p>Hello") {
+		t.Error("HTML content should be escaped in Atom output")
+	}
+}
+
+func TestGenerateAtomParsesAsFeed(t *testing.T) {
+	t.Parallel()
+	gen := newFeedTestGenerator(t)
+
+	var buf bytes.Buffer
+	if err := gen.GenerateAtom(context.Background(), &buf, feedTestData(), 0); err != nil {
+		t.Fatalf("GenerateAtom() error = %v", err)
+	}
+
+	meta, entries, err := normalizer.New().Parse(context.Background(), buf.Bytes(), "https://planet.example.com/atom.xml", time.Now())
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if meta.Title != "Test Planet" {
+		t.Errorf("parsed title = %q", meta.Title)
+	}
+	if len(entries) != 3 {
+		t.Errorf("parsed %d entries, want 3", len(entries))
+	}
+}
+
+func TestGenerateRSS(t *testing.T) {
+	t.Parallel()
+	gen := newFeedTestGenerator(t)
+
+	var buf bytes.Buffer
+	if err := gen.GenerateRSS(context.Background(), &buf, feedTestData(), 0); err != nil {
+		t.Fatalf("GenerateRSS() error = %v", err)
+	}
+
+	var feed struct {
+		Channel struct {
+			Title string `xml:"title"`
+			Items []struct {
+				Title       string `xml:"title"`
+				GUID        string `xml:"guid"`
+				Description string `xml:"description"`
+			} `xml:"item"`
+		} `xml:"channel"`
+	}
+	if err := xml.Unmarshal(buf.Bytes(), &feed); err != nil {
+		t.Fatalf("output is not valid XML: %v\n%s", err, buf.String())
+	}
+
+	if !strings.Contains(buf.String(), "<link>https://planet.example.com/</link>") {
+		t.Errorf("missing channel link\n%s", buf.String())
+	}
+	if len(feed.Channel.Items) != 3 {
+		t.Fatalf("got %d items, want 3", len(feed.Channel.Items))
+	}
+	if feed.Channel.Items[0].Title != "Ben & Jerry" {
+		t.Errorf("item title = %q, want unescaped plain text", feed.Channel.Items[0].Title)
+	}
+	if feed.Channel.Items[1].Description != "<p>Summary</p>" {
+		t.Errorf("item without content should use summary, got %q", feed.Channel.Items[1].Description)
+	}
+	if !strings.Contains(buf.String(), `<atom:link rel="self" type="application/rss+xml" href="https://planet.example.com/rss.xml">`) {
+		t.Errorf("missing atom:link self reference\n%s", buf.String())
+	}
+}
+
+func TestWriteFeeds(t *testing.T) {
+	t.Parallel()
+	gen := newFeedTestGenerator(t)
+	outputDir := filepath.Join(t.TempDir(), "public")
+
+	if err := gen.WriteFeeds(context.Background(), outputDir, feedTestData(), FeedOptions{MaxEntries: 20}); err != nil {
+		t.Fatalf("WriteFeeds() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, AtomFileName)); err != nil {
+		t.Errorf("atom.xml not written: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, RSSFileName)); !os.IsNotExist(err) {
+		t.Error("rss.xml should not be written unless requested")
+	}
+
+	if err := gen.WriteFeeds(context.Background(), outputDir, feedTestData(), FeedOptions{RSS: true}); err != nil {
+		t.Fatalf("WriteFeeds() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, RSSFileName)); err != nil {
+		t.Errorf("rss.xml not written: %v", err)
+	}
+}
+
+func TestGenerateAlternateFeedLinks(t *testing.T) {
+	t.Parallel()
+	gen := newFeedTestGenerator(t)
+
+	data := feedTestData()
+	data.AtomURL = AtomFileName
+
+	var buf bytes.Buffer
+	if err := gen.Generate(context.Background(), &buf, data); err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	if !strings.Contains(buf.String(), `<link rel="alternate" type="application/atom+xml" title="Test Planet (Atom)" href="atom.xml">`) {
+		t.Error("index.html should link to atom.xml")
+	}
+	if strings.Contains(buf.String(), "application/rss+xml") {
+		t.Error("index.html should not link to rss.xml when it is not generated")
+	}
+}
+
+func TestEntryCategoriesInOutput(t *testing.T) {
+	t.Parallel()
+	gen := newFeedTestGenerator(t)
+	data := feedTestData()
+	data.Entries[0].Categories = []string{"Go", "Releases"}
+
+	outputs := []struct {
+		name  string
+		write func(*bytes.Buffer) error
+		want  []string
+	}{
+		{"atom", func(b *bytes.Buffer) error { return gen.GenerateAtom(context.Background(), b, data, 0) },
+			[]string{`<category term="Go"></category>`, `<category term="Releases"></category>`}},
+		{"rss", func(b *bytes.Buffer) error { return gen.GenerateRSS(context.Background(), b, data, 0) },
+			[]string{"<category>Go</category>", "<category>Releases</category>"}},
+		{"json", func(b *bytes.Buffer) error { return gen.GenerateJSONFeed(context.Background(), b, data, 1, 0) },
+			[]string{`"tags": [`, `"Releases"`}},
+		{"html", func(b *bytes.Buffer) error { return gen.Generate(context.Background(), b, data) },
+			[]string{`<ul class="entry-tags">`, "<li>Go</li><li>Releases</li>"}},
+	}
+
+	for _, o := range outputs {
+		var buf bytes.Buffer
+		if err := o.write(&buf); err != nil {
+			t.Fatalf("%s: error = %v", o.name, err)
+		}
+		for _, want := range o.want {
+			if !strings.Contains(buf.String(), want) {
+				t.Errorf("%s output missing %q", o.name, want)
+			}
+		}
+	}
+}
+
+func TestCachedMediaAbsoluteInFeeds(t *testing.T) {
+	t.Parallel()
+	gen := newFeedTestGenerator(t)
+	data := feedTestData()
+	data.Entries[0].Content = template.HTML(`<p><img src="media/abc.png"/></p>`)
+
+	var atom, rss, jsonFeed bytes.Buffer
+	if err := gen.GenerateAtom(context.Background(), &atom, data, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := gen.GenerateRSS(context.Background(), &rss, data, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := gen.GenerateJSONFeed(context.Background(), &jsonFeed, data, 1, 0); err != nil {
+		t.Fatal(err)
+	}
+	for name, out := range map[string]string{"atom": atom.String(), "rss": rss.String(), "json": jsonFeed.String()} {
+		if !strings.Contains(out, "https://planet.example.com/media/abc.png") {
+			t.Errorf("%s feed should use an absolute URL for cached media:\n%s", name, out)
+		}
+	}
+
+	// The HTML pages keep the relative URL
+	var page bytes.Buffer
+	if err := gen.Generate(context.Background(), &page, data); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(page.String(), `src="media/abc.png"`) {
+		t.Error("index.html should keep the relative media URL")
+	}
+}
+
+func TestEntryPermalinks(t *testing.T) {
+	t.Parallel()
+	gen := newFeedTestGenerator(t)
+	data := feedTestData()
+
+	anchor := EntryAnchor(data.Entries[0])
+	if !strings.HasPrefix(anchor, "entry-") || len(anchor) != len("entry-")+12 {
+		t.Errorf("EntryAnchor() = %q, want entry- and 12 hex digits", anchor)
+	}
+	moved := data.Entries[0]
+	moved.Title, moved.Published = "Retitled", moved.Published.AddDate(0, 1, 0)
+	if EntryAnchor(moved) != anchor {
+		t.Error("EntryAnchor() changed with the title and date, want it to depend on the ID only")
+	}
+	if EntryAnchor(data.Entries[1]) == EntryAnchor(data.Entries[2]) {
+		t.Error("entries without IDs share an anchor, want their links to tell them apart")
+	}
+
+	if got, want := ArchivePermalink(data.Entries[0]), "2025/03/#"+anchor; got != want {
+		t.Errorf("ArchivePermalink() = %q, want %q", got, want)
+	}
+	if got := ArchivePermalink(EntryData{ID: "undated"}); got != "" {
+		t.Errorf("ArchivePermalink() of an undated entry = %q, want none", got)
+	}
+
+	// Without a permalink, entries link to their anchor and feeds give no planet URL
+	var page bytes.Buffer
+	if err := gen.Generate(context.Background(), &page, data); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`<article id="` + anchor + `"`, `href="#` + anchor + `"`} {
+		if !strings.Contains(page.String(), want) {
+			t.Errorf("page missing %q", want)
+		}
+	}
+	var atom bytes.Buffer
+	if err := gen.GenerateAtom(context.Background(), &atom, data, 0); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(atom.String(), `rel="related"`) {
+		t.Error("atom feed links to the planet for entries without permalinks")
+	}
+
+	data.Entries[0].Permalink = ArchivePermalink(data.Entries[0])
+	planetURL := "https://planet.example.com/2025/03/#" + anchor
+	page.Reset()
+	if err := gen.Generate(context.Background(), &page, data); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(page.String(), `class="entry-permalink" href="2025/03/#`+anchor+`"`) {
+		t.Error("page does not link the entry to its permalink")
+	}
+
+	atom.Reset()
+	var rss, jsonFeed bytes.Buffer
+	if err := gen.GenerateAtom(context.Background(), &atom, data, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := gen.GenerateRSS(context.Background(), &rss, data, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := gen.GenerateJSONFeed(context.Background(), &jsonFeed, data, 1, 0); err != nil {
+		t.Fatal(err)
+	}
+	for _, feed := range []struct{ name, out, want string }{
+		{"atom", atom.String(), `<link rel="related" type="text/html" href="` + planetURL + `"></link>`},
+		{"rss", rss.String(), `<atom:link rel="related" type="text/html" href="` + planetURL + `"></atom:link>`},
+		{"json", jsonFeed.String(), `"_rogue_planet": {
+        "url": "` + planetURL + `"
+      }`},
+	} {
+		if !strings.Contains(feed.out, feed.want) {
+			t.Errorf("%s feed missing %s:\n%s", feed.name, feed.want, feed.out)
+		}
+	}
+}
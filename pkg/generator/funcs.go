@@ -0,0 +1,363 @@
+package generator
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"html/template"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	nethtml "golang.org/x/net/html"
+)
+
+// templateFuncs returns the functions available to every template, with
+// extra merged over them. Functions that take text accept both strings and
+// template.HTML, so they can be applied to .Content and .Summary directly.
+func (g *Generator) templateFuncs(extra template.FuncMap) template.FuncMap {
+	funcs := template.FuncMap{
+		"formatDate": func(t time.Time) string {
+			return t.Format("January 2, 2006 at 3:04 PM")
+		},
+		"formatDateShort": func(t time.Time) string {
+			return t.Format("Jan 2, 2006")
+		},
+		"formatDateISO": func(t time.Time) string {
+			return t.Format(time.RFC3339)
+		},
+		"relativeTime": func(t time.Time) string {
+			return relativeTime(t, g.timeProvider)
+		},
+		"truncate":    truncate,
+		"striptags":   stripTags,
+		"markdown":    renderMarkdown,
+		"slugify":     slugify,
+		"absoluteURL": resolveURL,
+		"wordCount":   func(v any) int { return wordCount(textOf(v)) },
+		"hostname":    hostname,
+		"formatSize":  formatSize,
+		"topicID":     topicID,
+	}
+	for name, fn := range extra {
+		funcs[name] = fn
+	}
+	return funcs
+}
+
+// textOf converts a template argument to a string
+func textOf(v any) string {
+	switch s := v.(type) {
+	case string:
+		return s
+	case template.HTML:
+		return string(s)
+	case fmt.Stringer:
+		return s.String()
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(s)
+	}
+}
+
+// truncate shortens text to at most n characters, cutting at a word boundary
+// where possible and appending an ellipsis. Use it after striptags when the
+// input is HTML: {{.Summary | striptags | truncate 200}}
+func truncate(n int, v any) string {
+	s := strings.TrimSpace(textOf(v))
+	if n <= 0 || utf8.RuneCountInString(s) <= n {
+		return s
+	}
+
+	runes := []rune(s)
+	cut := string(runes[:n])
+	if i := strings.LastIndexFunc(cut, unicode.IsSpace); i > len(cut)/2 {
+		cut = cut[:i]
+	}
+	return strings.TrimRightFunc(cut, func(r rune) bool {
+		return unicode.IsSpace(r) || unicode.IsPunct(r)
+	}) + "…"
+}
+
+// stripTags returns the text content of an HTML fragment, with entities
+// decoded and runs of whitespace collapsed to single spaces
+func stripTags(v any) string {
+	z := nethtml.NewTokenizer(strings.NewReader(textOf(v)))
+	var b strings.Builder
+	skip := 0 // Depth inside <script> or <style>
+	for {
+		switch z.Next() {
+		case nethtml.ErrorToken:
+			return strings.Join(strings.Fields(b.String()), " ")
+		case nethtml.TextToken:
+			if skip == 0 {
+				b.Write(z.Text())
+			}
+		case nethtml.StartTagToken:
+			name, _ := z.TagName()
+			if tag := string(name); tag == "script" || tag == "style" {
+				skip++
+			}
+			// Block elements separate words
+			b.WriteByte(' ')
+		case nethtml.EndTagToken:
+			name, _ := z.TagName()
+			if tag := string(name); (tag == "script" || tag == "style") && skip > 0 {
+				skip--
+			}
+			b.WriteByte(' ')
+		case nethtml.SelfClosingTagToken:
+			b.WriteByte(' ')
+		}
+	}
+}
+
+// wordCount counts the words in the text content of an HTML fragment
+func wordCount(s string) int {
+	return len(strings.Fields(stripTags(s)))
+}
+
+// wordsPerMinute is the reading speed behind readingMinutes, the same as
+// the normalizer's
+const wordsPerMinute = 200
+
+// readingMinutes estimates the whole minutes needed to read words words,
+// rounding up; 0 for no words
+func readingMinutes(words int) int {
+	if words <= 0 {
+		return 0
+	}
+	return (words + wordsPerMinute - 1) / wordsPerMinute
+}
+
+// slugify turns text into a lowercase, hyphen-separated identifier suitable
+// for anchors and file names: "Go 1.24 Release!" becomes "go-1-24-release"
+func slugify(v any) string {
+	var b strings.Builder
+	pendingHyphen := false
+	for _, r := range strings.ToLower(textOf(v)) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if pendingHyphen && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			pendingHyphen = false
+			b.WriteRune(r)
+			continue
+		}
+		pendingHyphen = true
+	}
+	return b.String()
+}
+
+// resolveURL resolves ref against base, so relative links from a feed can be
+// made absolute: {{absoluteURL .FeedLink "/about"}}. Unparseable input is
+// returned unchanged.
+func resolveURL(base, ref string) string {
+	if base == "" {
+		return ref
+	}
+	b, err := url.Parse(base)
+	if err != nil {
+		return ref
+	}
+	r, err := url.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	return b.ResolveReference(r).String()
+}
+
+// hostname returns the host of a URL without any "www." prefix, for
+// labelling links to other sites, or "" if it has none
+func hostname(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(u.Hostname(), "www.")
+}
+
+// topicID returns the anchor of a topic's filter, e.g. "topic-databases"
+func topicID(name string) string {
+	return "topic-" + slugify(name)
+}
+
+// EntryAnchor returns the id of an entry's article, e.g. "entry-3f9a0c1b2d4e":
+// a hash of the entry's ID, or of its link if it has none, so it is the same
+// on every page that shows the entry and in every run, however entries are
+// ordered
+func EntryAnchor(e EntryData) string {
+	sum := sha256.Sum256([]byte(entryID(e)))
+	return "entry-" + hex.EncodeToString(sum[:6])
+}
+
+// formatSize formats a size in bytes for people: "850 bytes", "1.5 MB"
+func formatSize(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d bytes", bytes)
+	}
+	size, prefix := float64(bytes)/unit, 0
+	for size >= unit && prefix < 3 {
+		size /= unit
+		prefix++
+	}
+	return fmt.Sprintf("%.1f %cB", size, "KMGT"[prefix])
+}
+
+var (
+	mdHeading  = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*$`)
+	mdListItem = regexp.MustCompile(`^\s*[-*+]\s+(.*)$`)
+	mdCode     = regexp.MustCompile("`([^`]+)`")
+	mdLink     = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+	mdStrong   = regexp.MustCompile(`\*\*([^*]+)\*\*|__([^_]+)__`)
+	mdEm       = regexp.MustCompile(`\*([^*]+)\*|\b_([^_]+)_\b`)
+)
+
+// renderMarkdown renders a small, safe subset of Markdown: headings,
+// paragraphs, bulleted lists, block quotes, fenced code blocks, and inline
+// code, emphasis, and links. Raw HTML in the input is escaped, and links are
+// kept only for http, https, mailto, and relative URLs.
+func renderMarkdown(v any) template.HTML {
+	lines := strings.Split(strings.ReplaceAll(textOf(v), "\r\n", "\n"), "\n")
+	var out strings.Builder
+	var para, quote []string
+	inList, inCode := false, false
+
+	flushPara := func() {
+		if len(para) > 0 {
+			out.WriteString("<p>" + mdInline(strings.Join(para, " ")) + "</p>\n")
+			para = nil
+		}
+	}
+	flushQuote := func() {
+		if len(quote) > 0 {
+			out.WriteString("<blockquote><p>" + mdInline(strings.Join(quote, " ")) + "</p></blockquote>\n")
+			quote = nil
+		}
+	}
+	closeList := func() {
+		if inList {
+			out.WriteString("</ul>\n")
+			inList = false
+		}
+	}
+	flushAll := func() {
+		flushPara()
+		flushQuote()
+		closeList()
+	}
+
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+
+		if strings.HasPrefix(trimmed, "```") {
+			if inCode {
+				out.WriteString("</code></pre>\n")
+				inCode = false
+			} else {
+				flushAll()
+				out.WriteString("<pre><code>")
+				inCode = true
+			}
+			continue
+		}
+		if inCode {
+			out.WriteString(html.EscapeString(line) + "\n")
+			continue
+		}
+
+		switch {
+		case trimmed == "":
+			flushAll()
+		case mdHeading.MatchString(trimmed):
+			flushAll()
+			m := mdHeading.FindStringSubmatch(trimmed)
+			level := len(m[1])
+			fmt.Fprintf(&out, "<h%d>%s</h%d>\n", level, mdInline(m[2]), level)
+		case mdListItem.MatchString(line):
+			flushPara()
+			flushQuote()
+			if !inList {
+				out.WriteString("<ul>\n")
+				inList = true
+			}
+			out.WriteString("<li>" + mdInline(mdListItem.FindStringSubmatch(line)[1]) + "</li>\n")
+		case strings.HasPrefix(trimmed, ">"):
+			flushPara()
+			closeList()
+			quote = append(quote, strings.TrimSpace(strings.TrimPrefix(trimmed, ">")))
+		default:
+			flushQuote()
+			closeList()
+			para = append(para, trimmed)
+		}
+	}
+	if inCode {
+		out.WriteString("</code></pre>\n")
+	}
+	flushAll()
+
+	// Every piece of input text is escaped before markup is added around it
+	return template.HTML(out.String())
+}
+
+// mdInline renders inline Markdown in a single line of text
+func mdInline(s string) string {
+	// Code spans are set aside first so their contents are not formatted.
+	// NUL marks the placeholders, so it is dropped from the input.
+	s = strings.ReplaceAll(s, "\x00", "")
+	var spans []string
+	s = mdCode.ReplaceAllStringFunc(s, func(m string) string {
+		spans = append(spans, "<code>"+html.EscapeString(m[1:len(m)-1])+"</code>")
+		return fmt.Sprintf("\x00%d\x00", len(spans)-1)
+	})
+
+	s = html.EscapeString(s)
+	s = mdLink.ReplaceAllStringFunc(s, func(m string) string {
+		parts := mdLink.FindStringSubmatch(m)
+		href := html.UnescapeString(parts[2])
+		if !safeLinkURL(href) {
+			return parts[1]
+		}
+		// Links are set aside too, so emphasis never rewrites a URL
+		spans = append(spans, `<a href="`+html.EscapeString(href)+`">`+parts[1]+"</a>")
+		return fmt.Sprintf("\x00%d\x00", len(spans)-1)
+	})
+	s = mdStrong.ReplaceAllString(s, "<strong>$1$2</strong>")
+	s = mdEm.ReplaceAllString(s, "<em>$1$2</em>")
+
+	// Later spans (links) may contain earlier ones (code), so restore in reverse
+	for i := len(spans) - 1; i >= 0; i-- {
+		s = strings.Replace(s, fmt.Sprintf("\x00%d\x00", i), spans[i], 1)
+	}
+	return s
+}
+
+// defaultFavicon guesses a site's favicon from its link: /favicon.ico on the
+// same host. Only http and https links are used.
+func defaultFavicon(link string) string {
+	u, err := url.Parse(link)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ""
+	}
+	return u.Scheme + "://" + u.Host + "/favicon.ico"
+}
+
+// safeLinkURL reports whether a Markdown link target may be rendered as a link
+func safeLinkURL(href string) bool {
+	u, err := url.Parse(href)
+	if err != nil {
+		return false
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "", "http", "https", "mailto":
+		return true
+	}
+	return false
+}
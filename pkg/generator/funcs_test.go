@@ -0,0 +1,186 @@
+package generator
+
+import (
+	"bytes"
+	"context"
+	"html/template"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTruncate(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		n    int
+		in   any
+		want string
+	}{
+		{"short text unchanged", 20, "Hello world", "Hello world"},
+		{"cuts at word boundary", 14, "The quick brown fox jumps", "The quick…"},
+		{"drops trailing punctuation", 10, "Hello, world again", "Hello…"},
+		{"counts runes not bytes", 3, "héllo", "hél…"},
+		{"accepts template.HTML", 5, template.HTML("abcdefgh"), "abcde…"},
+		{"zero disables", 0, "Hello world", "Hello world"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := truncate(tt.n, tt.in); got != tt.want {
+				t.Errorf("truncate(%d, %q) = %q, want %q", tt.n, tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStripTags(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"<p>Hello <b>world</b></p>", "Hello world"},
+		{"<p>One</p><p>Two</p>", "One Two"},
+		{"Fish &amp; chips", "Fish & chips"},
+		{"<style>p{}</style>Text<script>alert(1)</script>", "Text"},
+		{"plain", "plain"},
+	}
+	for _, tt := range tests {
+		if got := stripTags(template.HTML(tt.in)); got != tt.want {
+			t.Errorf("stripTags(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSlugify(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"Go 1.24 Release!", "go-1-24-release"},
+		{"  Leading and trailing  ", "leading-and-trailing"},
+		{"Ça marche", "ça-marche"},
+		{"---", ""},
+	}
+	for _, tt := range tests {
+		if got := slugify(tt.in); got != tt.want {
+			t.Errorf("slugify(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestResolveURL(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		base, ref, want string
+	}{
+		{"https://example.com/blog/", "post.html", "https://example.com/blog/post.html"},
+		{"https://example.com/blog/", "/about", "https://example.com/about"},
+		{"https://example.com/", "https://other.example/x", "https://other.example/x"},
+		{"", "relative", "relative"},
+	}
+	for _, tt := range tests {
+		if got := resolveURL(tt.base, tt.ref); got != tt.want {
+			t.Errorf("resolveURL(%q, %q) = %q, want %q", tt.base, tt.ref, got, tt.want)
+		}
+	}
+}
+
+func TestHostnameAndFormatSize(t *testing.T) {
+	t.Parallel()
+	for url, want := range map[string]string{
+		"https://www.example.com/a":     "example.com",
+		"http://blog.example.org:8080/": "blog.example.org",
+		"not a url\x7f":                 "",
+	} {
+		if got := hostname(url); got != want {
+			t.Errorf("hostname(%q) = %q, want %q", url, got, want)
+		}
+	}
+	for size, want := range map[int64]string{
+		850:              "850 bytes",
+		1536:             "1.5 KB",
+		25 * 1024 * 1024: "25.0 MB",
+		3 << 30:          "3.0 GB",
+	} {
+		if got := formatSize(size); got != want {
+			t.Errorf("formatSize(%d) = %q, want %q", size, got, want)
+		}
+	}
+}
+
+func TestRenderMarkdown(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"paragraphs", "One\ntwo\n\nThree", "<p>One two</p>\n<p>Three</p>\n"},
+		{"heading", "## Title ##", "<h2>Title</h2>\n"},
+		{"emphasis", "**bold** and *em* and _also_", "<p><strong>bold</strong> and <em>em</em> and <em>also</em></p>\n"},
+		{"snake_case untouched", "a snake_case_name", "<p>a snake_case_name</p>\n"},
+		{"inline code", "use `**raw**` here", "<p>use <code>**raw**</code> here</p>\n"},
+		{"link", "[Go](https://go.dev/doc_x_y)", `<p><a href="https://go.dev/doc_x_y">Go</a></p>` + "\n"},
+		{"code in link text", "[`rp`](/docs)", `<p><a href="/docs"><code>rp</code></a></p>` + "\n"},
+		{"unsafe link dropped", "[click](javascript:alert)", "<p>click</p>\n"},
+		{"html escaped", "<script>alert(1)</script>", "<p>&lt;script&gt;alert(1)&lt;/script&gt;</p>\n"},
+		{"list", "- one\n- two", "<ul>\n<li>one</li>\n<li>two</li>\n</ul>\n"},
+		{"quote", "> quoted\n> text", "<blockquote><p>quoted text</p></blockquote>\n"},
+		{"fenced code", "```\n<b>x</b>\n```", "<pre><code>&lt;b&gt;x&lt;/b&gt;\n</code></pre>\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := string(renderMarkdown(tt.in)); got != tt.want {
+				t.Errorf("renderMarkdown(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTemplateFuncsAndEntryMetadata(t *testing.T) {
+	t.Parallel()
+	themeDir := t.TempDir()
+	writeTestFile(t, filepath.Join(themeDir, "template.html"), `{{range .Entries -}}
+[{{slugify .Title}}] {{.Summary | striptags | truncate 12}} ({{.WordCount}} words) icon={{.FeedIcon}} {{shout .FeedTitle}}
+{{range .Attachments}}enclosure={{.URL}} {{end}}
+{{- end}}`)
+
+	gen, err := NewWithTemplateFuncs(filepath.Join(themeDir, "template.html"), template.FuncMap{
+		"shout": strings.ToUpper,
+	})
+	if err != nil {
+		t.Fatalf("NewWithTemplateFuncs() error = %v", err)
+	}
+
+	data := TemplateData{Entries: []EntryData{{
+		Title:       "Hello, World!",
+		FeedTitle:   "Example",
+		FeedLink:    "https://blog.example.com/posts/",
+		Summary:     "<p>A <em>short</em> summary that goes on</p>",
+		Content:     "<p>Four words of <b>content</b></p>",
+		Published:   time.Now(),
+		Attachments: []Attachment{{URL: "https://blog.example.com/ep1.mp3", MimeType: "audio/mpeg"}},
+	}}}
+
+	var buf bytes.Buffer
+	if err := gen.Generate(context.Background(), &buf, data); err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	for _, want := range []string{
+		"[hello-world]",
+		"A short…",
+		"(4 words)",
+		"icon=https://blog.example.com/favicon.ico",
+		"EXAMPLE",
+		"enclosure=https://blog.example.com/ep1.mp3",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, buf.String())
+		}
+	}
+}
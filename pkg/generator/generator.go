@@ -7,13 +7,16 @@ package generator
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"html/template"
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
+	"github.com/adewale/rogue_planet/pkg/buildinfo"
 	"github.com/adewale/rogue_planet/pkg/timeprovider"
 )
 
@@ -30,6 +33,44 @@ type TemplateData struct {
 	GroupByDate bool
 	DateGroups  []DateGroup
 	Feeds       []FeedData // For sidebar
+	BuildInfo   buildinfo.Info
+	CSP         string            // Content-Security-Policy, extended by the theme manifest
+	HeadTags    template.HTML     // CSP meta tag, preload hints, icon links, and @font-face rules
+	Icons       map[string]string // Icon name -> path, from the theme manifest
+	AtomURL     string            // Relative URL of the planet's Atom feed, if generated
+	RSSURL      string            // Relative URL of the planet's RSS feed, if generated
+	JSONFeedURL string            // Relative URL of the planet's JSON Feed, if generated
+	Image       string            // Absolute URL of the image in the planet's Open Graph tags, if any
+
+	// Accent colours overriding the built-in template's, as CSS hex colours;
+	// AccentColor applies to both colour schemes unless AccentColorDark is set
+	AccentColor     string
+	AccentColorDark string
+
+	// Pinned entries, shown in a Featured section at the top of the first
+	// page of the river and left out of the river below it
+	Featured []EntryData
+
+	// Feed groups, in display order. Generate fills in each group's entries
+	// from Entries; without groups the river is one list.
+	Groups    []EntryGroup
+	GroupTabs bool // Show the groups as tabs instead of one after another
+
+	// Topics entries are tagged with, in display order; the built-in
+	// template offers each as a filter of the river
+	Topics []string
+
+	// Pagination, set by GeneratePages
+	Page       int    // Current page, starting at 1
+	TotalPages int    // Number of pages in the river
+	PrevURL    string // Relative URL of the newer page, empty on the first page
+	NextURL    string // Relative URL of the older page, empty on the last page
+
+	// Archive, set by GenerateArchive
+	Root         string        // Relative URL of the site root, e.g. "../../"; relative URLs on the page resolve against it
+	ArchiveURL   string        // Relative URL of the archive index, if generated
+	ArchiveTitle string        // Month, year, or "Archive" on archive pages; empty elsewhere
+	Archive      []ArchiveYear // Years and months listed on the archive index and year pages
 }
 
 // FeedData represents a feed for sidebar display
@@ -40,10 +81,18 @@ type FeedData struct {
 	Subscribers int
 	LastUpdated time.Time
 	ErrorCount  int
+	Icon        string // Relative URL of the site's cached favicon, empty if none
+
+	// Presentation from the feed's [feed <URL>] section, for telling
+	// sources apart
+	CSSClass    string // Extra CSS classes, separated by spaces
+	AccentColor string // Colour as #rgb or #rrggbb
+	Avatar      string // Image of the feed's author, absolute or relative to the site root
 }
 
 // EntryData represents an entry for template rendering
 type EntryData struct {
+	ID                string // Original entry ID (GUID), used in output feeds
 	Title             template.HTML
 	Link              string
 	Author            string
@@ -54,6 +103,51 @@ type EntryData struct {
 	Content           template.HTML // Already sanitized, safe to render
 	Summary           template.HTML
 	PublishedRelative string
+	Attachments       []Attachment // Enclosures and JSON Feed attachments, included in feed.json
+	Authors           []string     // Names of all the entry's authors, when the feed names them; Author is usually the first
+	ExternalURL       string       // Page elsewhere the entry is about, for link blogs (JSON Feed external_url)
+	Language          string       // Language of the entry, or else of its feed, as the feed gives it
+	Categories        []string     // Categories/tags from the source feed
+	WordCount         int          // Words in Content, counted by Generate if not set
+	ReadingMinutes    int          // Estimated minutes to read Content, worked out by Generate if not set
+	Image             string       // Representative image URL from the feed, the content, or the entry's page
+	FeedIcon          string       // Source site's favicon URL; defaults to /favicon.ico on FeedLink's host
+	Group             string       // Name of the feed group the entry is shown in
+	Topics            []string     // Topics the entry was classified into; they are among Categories too
+	Anchor            string       // id of the entry's article, stable across runs (see EntryAnchor); set by Generate if not set
+	Permalink         string       // Lasting address of the entry on the planet, relative to the site root (see ArchivePermalink); "" without archives
+
+	// The feed's own title and summary, set when Title or Summary hold a
+	// machine translation
+	OriginalTitle   template.HTML
+	OriginalSummary template.HTML
+
+	Related []RelatedEntry // Entries related to this one, most related first
+
+	// Presentation of the entry's feed, as in FeedData
+	FeedCSSClass    string
+	FeedAccentColor string
+	FeedAvatar      string
+
+	Face string // The author's face image, from their [author] section
+
+	UpdatedCount          int       // Material changes to the content since the entry was first fetched
+	LastSignificantUpdate time.Time // When the last material change was seen
+	Resurfaced            bool      // Shown again because it changed recently; dated by LastSignificantUpdate
+}
+
+// RelatedEntry is an entry linked from another as related to it
+type RelatedEntry struct {
+	Title template.HTML
+	Link  string
+}
+
+// riverDate is the date the entry is listed under in the river
+func (e EntryData) riverDate() time.Time {
+	if e.Resurfaced {
+		return e.LastSignificantUpdate
+	}
+	return e.Published
 }
 
 // DateGroup groups entries by date
@@ -63,20 +157,35 @@ type DateGroup struct {
 	Entries []EntryData
 }
 
+// FaviconsDir is where feed favicons are cached in the output directory
+const FaviconsDir = "static/favicons"
+
+// FacesDir is where author face images are published in the output directory
+const FacesDir = "static/faces"
+
+// MediaDir is where images from entry content are cached in the output directory
+const MediaDir = "media"
+
 // Generator handles static HTML generation
 type Generator struct {
 	template     *template.Template
-	templatePath string // Path to template file (if custom template)
+	themeDir     string // Directory of the custom theme (empty for the built-in template)
+	builtinMain  bool   // The main template is the built-in one, which needs its script
 	timeProvider timeprovider.TimeProvider
+	buildInfo    buildinfo.Info
+	assets       *themeAssets
 }
 
 // New creates a new Generator with the default template and real system time
 func New() (*Generator, error) {
 	g := &Generator{
 		timeProvider: timeprovider.WallClock{},
+		buildInfo:    buildinfo.Get(),
+		assets:       &themeAssets{csp: defaultCSP},
+		builtinMain:  true,
 	}
 
-	tmpl, err := template.New("default").Funcs(g.templateFuncs()).Parse(defaultTemplate)
+	tmpl, err := template.New("default").Funcs(g.templateFuncs(nil)).Parse(defaultTemplate)
 	if err != nil {
 		return nil, fmt.Errorf("parse default template: %w", err)
 	}
@@ -90,9 +199,12 @@ func New() (*Generator, error) {
 func NewWithTimeProvider(tp timeprovider.TimeProvider) (*Generator, error) {
 	g := &Generator{
 		timeProvider: tp,
+		buildInfo:    buildinfo.Get(),
+		assets:       &themeAssets{csp: defaultCSP},
+		builtinMain:  true,
 	}
 
-	tmpl, err := template.New("default").Funcs(g.templateFuncs()).Parse(defaultTemplate)
+	tmpl, err := template.New("default").Funcs(g.templateFuncs(nil)).Parse(defaultTemplate)
 	if err != nil {
 		return nil, fmt.Errorf("parse default template: %w", err)
 	}
@@ -101,22 +213,98 @@ func NewWithTimeProvider(tp timeprovider.TimeProvider) (*Generator, error) {
 	return g, nil
 }
 
-// NewWithTemplate creates a Generator with a custom template and real system time
+// NewWithTemplate creates a Generator with a custom template and real system time.
+//
+// templatePath is either a template file or a theme directory. A theme
+// directory's main template is its template.html, or the built-in template
+// when it has none. Either way, every *.html file in the theme's partials/
+// directory is parsed after the main template, so its {{define}} blocks
+// override the blocks of the same name (see the built-in template for the
+// block names it provides).
 func NewWithTemplate(templatePath string) (*Generator, error) {
+	return NewWithTemplateFuncs(templatePath, nil)
+}
+
+// NewWithTemplateFuncs is NewWithTemplate with extra template functions,
+// which are added to (and may replace) the built-in ones
+func NewWithTemplateFuncs(templatePath string, funcs template.FuncMap) (*Generator, error) {
 	g := &Generator{
-		templatePath: templatePath,
 		timeProvider: timeprovider.WallClock{},
+		buildInfo:    buildinfo.Get(),
 	}
 
-	tmpl, err := template.New(filepath.Base(templatePath)).Funcs(g.templateFuncs()).ParseFiles(templatePath)
+	info, err := os.Stat(templatePath)
 	if err != nil {
 		return nil, fmt.Errorf("parse template: %w", err)
 	}
 
+	g.themeDir = filepath.Dir(templatePath)
+	mainPath := templatePath
+	if info.IsDir() {
+		g.themeDir = templatePath
+		mainPath = filepath.Join(templatePath, ThemeTemplateName)
+		if _, err := os.Stat(mainPath); os.IsNotExist(err) {
+			mainPath = ""
+		}
+	}
+
+	var tmpl *template.Template
+	baseCSP := themeCSP
+	if mainPath == "" {
+		// Partials override blocks of the built-in template, which keeps
+		// its inline styles and therefore its policy
+		tmpl, err = template.New("default").Funcs(g.templateFuncs(funcs)).Parse(defaultTemplate)
+		baseCSP = defaultCSP
+		g.builtinMain = true
+	} else {
+		tmpl, err = template.New(filepath.Base(mainPath)).Funcs(g.templateFuncs(funcs)).ParseFiles(mainPath)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse template: %w", err)
+	}
+
+	if tmpl, err = parsePartials(tmpl, g.themeDir); err != nil {
+		return nil, err
+	}
+	if tmpl.Lookup("archive") == nil {
+		if tmpl, err = tmpl.Parse(archiveTemplate); err != nil {
+			return nil, fmt.Errorf("parse archive template: %w", err)
+		}
+	}
+
+	assets, err := loadThemeAssets(g.themeDir, baseCSP)
+	if err != nil {
+		return nil, err
+	}
+
 	g.template = tmpl
+	g.assets = assets
 	return g, nil
 }
 
+// parsePartials adds the templates in themeDir's partials/ directory to tmpl.
+// Parsing them last lets their {{define}} blocks replace the main template's.
+func parsePartials(tmpl *template.Template, themeDir string) (*template.Template, error) {
+	partials, err := filepath.Glob(filepath.Join(themeDir, PartialsDirName, "*.html"))
+	if err != nil {
+		return nil, fmt.Errorf("find partials: %w", err)
+	}
+	if len(partials) == 0 {
+		return tmpl, nil
+	}
+	tmpl, err = tmpl.ParseFiles(partials...)
+	if err != nil {
+		return nil, fmt.Errorf("parse partials: %w", err)
+	}
+	return tmpl, nil
+}
+
+// SetBuildInfo overrides the build metadata embedded in generated output.
+// This is primarily for testing with fixed values.
+func (g *Generator) SetBuildInfo(info buildinfo.Info) {
+	g.buildInfo = info
+}
+
 // Generate generates HTML and writes it to the specified writer
 func (g *Generator) Generate(ctx context.Context, w io.Writer, data TemplateData) error {
 	if err := ctx.Err(); err != nil {
@@ -124,27 +312,86 @@ func (g *Generator) Generate(ctx context.Context, w io.Writer, data TemplateData
 	}
 
 	// Add version info
-	data.Generator = "Rogue Planet v0.1"
+	data.Generator = "Rogue Planet v" + g.buildInfo.Version
+	data.BuildInfo = g.buildInfo
 	data.Updated = g.timeProvider.Now()
-
-	// Calculate relative dates using the time provider
-	for i := range data.Entries {
-		data.Entries[i].PublishedRelative = relativeTime(data.Entries[i].Published, g.timeProvider)
+	data.CSP = g.assets.csp
+	data.HeadTags = g.assets.headTags(data.Root)
+	data.Icons = g.assets.icons
+
+	if len(data.Featured) > 0 && data.PrevURL == "" && data.ArchiveTitle == "" {
+		data.Featured = append([]EntryData(nil), data.Featured...)
+		g.prepareEntries(data.Featured)
+		data.Entries = withoutEntries(data.Entries, data.Featured)
+	} else {
+		data.Featured = nil
 	}
+	g.prepareEntries(data.Entries)
 
 	// Group by date if requested
 	if data.GroupByDate {
 		data.DateGroups = groupEntriesByDate(data.Entries, g.timeProvider)
 	}
+	// Archive pages list a month's entries in order, whatever their group
+	if len(data.Groups) > 0 && data.ArchiveTitle == "" {
+		data.Groups = groupEntries(data.Groups, data.Entries, data.GroupByDate, g.timeProvider)
+	} else {
+		data.Groups = nil
+	}
 
 	// Execute template
 	if err := g.template.Execute(w, data); err != nil {
 		return fmt.Errorf("execute template: %w", err)
 	}
 
+	// html/template strips comments from templates, so the build comment is
+	// appended after rendering. "--" cannot appear inside an HTML comment.
+	comment := strings.ReplaceAll(g.buildInfo.String(), "--", "-")
+	if _, err := fmt.Fprintf(w, "<!-- Generated by %s -->\n", comment); err != nil {
+		return fmt.Errorf("write build comment: %w", err)
+	}
+
 	return nil
 }
 
+// prepareEntries sets the fields of entries that are worked out when
+// rendering: relative dates, using the time provider, and per-entry metadata
+func (g *Generator) prepareEntries(entries []EntryData) {
+	for i := range entries {
+		e := &entries[i]
+		e.PublishedRelative = relativeTime(e.Published, g.timeProvider)
+		if e.WordCount == 0 {
+			e.WordCount = wordCount(string(e.Content))
+		}
+		if e.ReadingMinutes == 0 {
+			e.ReadingMinutes = readingMinutes(e.WordCount)
+		}
+		if e.FeedIcon == "" {
+			e.FeedIcon = defaultFavicon(e.FeedLink)
+		}
+		if e.Anchor == "" {
+			e.Anchor = EntryAnchor(*e)
+		}
+	}
+}
+
+// withoutEntries returns a copy of entries without those in remove, matched
+// by ID and link
+func withoutEntries(entries, remove []EntryData) []EntryData {
+	type key struct{ id, link string }
+	drop := make(map[key]bool, len(remove))
+	for _, e := range remove {
+		drop[key{e.ID, e.Link}] = true
+	}
+	kept := make([]EntryData, 0, len(entries))
+	for _, e := range entries {
+		if !drop[key{e.ID, e.Link}] {
+			kept = append(kept, e)
+		}
+	}
+	return kept
+}
+
 // GenerateToFile generates HTML and writes it to a file
 func (g *Generator) GenerateToFile(ctx context.Context, outputPath string, data TemplateData) (err error) {
 	if err := ctx.Err(); err != nil {
@@ -173,13 +420,44 @@ func (g *Generator) GenerateToFile(ctx context.Context, outputPath string, data
 		return err
 	}
 
+	// Record which binary produced this output (aids bug reports)
+	if err := g.writeBuildInfo(dir); err != nil {
+		return err
+	}
+
+	if g.builtinMain {
+		if err := writeScript(dir); err != nil {
+			return err
+		}
+	}
+
 	// Copy static assets if using custom template
-	if g.templatePath != "" {
+	if g.themeDir != "" {
 		if err := g.CopyStaticAssets(ctx, dir); err != nil {
 			return fmt.Errorf("copy static assets: %w", err)
 		}
+		if err := g.assets.copyThemeAssets(ctx, dir); err != nil {
+			return fmt.Errorf("copy theme assets: %w", err)
+		}
+		if err := g.assets.writeAssetHeaders(dir); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeBuildInfo writes build-info.json describing the generating binary
+func (g *Generator) writeBuildInfo(outputDir string) error {
+	data, err := json.MarshalIndent(g.buildInfo, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal build info: %w", err)
 	}
+	data = append(data, '\n')
 
+	if err := os.WriteFile(filepath.Join(outputDir, "build-info.json"), data, 0644); err != nil {
+		return fmt.Errorf("write build info: %w", err)
+	}
 	return nil
 }
 
@@ -189,13 +467,12 @@ func (g *Generator) CopyStaticAssets(ctx context.Context, outputDir string) erro
 		return err
 	}
 
-	if g.templatePath == "" {
+	if g.themeDir == "" {
 		return nil // No custom template, no static assets
 	}
 
-	// Find static directory relative to template
-	templateDir := filepath.Dir(g.templatePath)
-	staticSrc := filepath.Join(templateDir, "static")
+	// Find static directory in the theme
+	staticSrc := filepath.Join(g.themeDir, "static")
 
 	// Check if static directory exists
 	if _, err := os.Stat(staticSrc); os.IsNotExist(err) {
@@ -205,9 +482,20 @@ func (g *Generator) CopyStaticAssets(ctx context.Context, outputDir string) erro
 	// Destination static directory
 	staticDst := filepath.Join(outputDir, "static")
 
-	// Remove existing static directory
-	if err := os.RemoveAll(staticDst); err != nil {
-		return fmt.Errorf("remove existing static directory: %w", err)
+	// Remove the existing static files, except the favicon cache and the
+	// author faces which are not part of the theme
+	existing, err := os.ReadDir(staticDst)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("read existing static directory: %w", err)
+	}
+	for _, entry := range existing {
+		name := filepath.Join("static", entry.Name())
+		if name == filepath.FromSlash(FaviconsDir) || name == filepath.FromSlash(FacesDir) {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(staticDst, entry.Name())); err != nil {
+			return fmt.Errorf("remove existing static directory: %w", err)
+		}
 	}
 
 	// Copy static directory
@@ -309,24 +597,6 @@ func copyFile(ctx context.Context, src, dst string) (err error) {
 	return nil
 }
 
-// templateFuncs returns custom template functions with access to the Generator's timeProvider
-func (g *Generator) templateFuncs() template.FuncMap {
-	return template.FuncMap{
-		"formatDate": func(t time.Time) string {
-			return t.Format("January 2, 2006 at 3:04 PM")
-		},
-		"formatDateShort": func(t time.Time) string {
-			return t.Format("Jan 2, 2006")
-		},
-		"formatDateISO": func(t time.Time) string {
-			return t.Format(time.RFC3339)
-		},
-		"relativeTime": func(t time.Time) string {
-			return relativeTime(t, g.timeProvider)
-		},
-	}
-}
-
 // relativeTime returns a human-readable relative time string
 func relativeTime(t time.Time, tp timeprovider.TimeProvider) string {
 	diff := tp.Since(t)
@@ -372,13 +642,15 @@ func relativeTime(t time.Time, tp timeprovider.TimeProvider) string {
 	}
 }
 
-// groupEntriesByDate groups entries by their published date
+// groupEntriesByDate groups entries by their published date.
+// Input order is preserved within and across groups, so output is as stable
+// as the repository ordering.
 func groupEntriesByDate(entries []EntryData, tp timeprovider.TimeProvider) []DateGroup {
 	groups := make(map[string][]EntryData)
 	dateOrder := []string{}
 
 	for _, entry := range entries {
-		dateKey := entry.Published.Format("2006-01-02")
+		dateKey := entry.riverDate().Format("2006-01-02")
 		if _, exists := groups[dateKey]; !exists {
 			dateOrder = append(dateOrder, dateKey)
 		}
@@ -416,16 +688,76 @@ func formatDateGroup(t time.Time, tp timeprovider.TimeProvider) string {
 	return t.Format("Monday, January 2, 2006")
 }
 
-// defaultTemplate is the built-in HTML template
+// defaultTemplate is the built-in HTML template. Its named blocks ("head",
+// "styles", "header", "featured", "groups", "entry", "archive", "pagination", "footer", "sidebar")
+// can be overridden individually by a theme's partials.
 const defaultTemplate = `<!DOCTYPE html>
 <html lang="en">
 <head>
     <meta charset="UTF-8">
     <meta name="viewport" content="width=device-width, initial-scale=1.0">
-    <meta http-equiv="Content-Security-Policy" content="default-src 'self'; script-src 'self'; style-src 'self' 'unsafe-inline'; img-src 'self' https:; object-src 'none'; base-uri 'self';">
+    <meta name="color-scheme" content="light dark">
+    {{.HeadTags}}
     <title>{{.Title}}</title>
     <meta name="generator" content="{{.Generator}}">
+    <meta property="og:type" content="website">
+    <meta property="og:title" content="{{.Title}}">
+    {{if .Subtitle}}<meta property="og:description" content="{{.Subtitle}}">{{end}}
+    {{if .Link}}<meta property="og:url" content="{{.Link}}">{{end}}
+    {{if .Image}}<meta property="og:image" content="{{.Image}}">
+    <meta name="twitter:card" content="summary_large_image">{{else}}<meta name="twitter:card" content="summary">{{end}}
+    {{if .AtomURL}}<link rel="alternate" type="application/atom+xml" title="{{.Title}} (Atom)" href="{{.AtomURL}}">{{end}}
+    {{if .RSSURL}}<link rel="alternate" type="application/rss+xml" title="{{.Title}} (RSS)" href="{{.RSSURL}}">{{end}}
+    {{if .JSONFeedURL}}<link rel="alternate" type="application/feed+json" title="{{.Title}} (JSON Feed)" href="{{.JSONFeedURL}}">{{end}}
+    <script src="planet.js" defer></script>
+    {{block "head" .}}{{end}}
+    {{block "styles" .}}
     <style>
+        :root {
+            color-scheme: light dark;
+            --accent: #0066cc;
+            --bg: #f5f5f5;
+            --surface: #fff;
+            --surface-alt: #f9f9f9;
+            --text: #333;
+            --muted: #666;
+            --faint: #6b6b6b;
+            --border: #eee;
+            --border-strong: #ddd;
+            --rule: #333;
+            --code-bg: #f5f5f5;
+            --tag-bg: #f0f0f0;
+            --featured-bg: #fffbea;
+            --featured-border: #f0e0a0;
+            --featured-text: #8a6d00;
+            --updated-bg: #fff4d6;
+            --updated-text: #7a5a00;
+            --error: #c00;
+            --shadow: rgba(0,0,0,0.1);
+        }
+        @media (prefers-color-scheme: dark) {
+            :root {
+                --accent: #6cb4ff;
+                --bg: #121212;
+                --surface: #1e1e1e;
+                --surface-alt: #181818;
+                --text: #e4e4e4;
+                --muted: #a8a8a8;
+                --faint: #999;
+                --border: #333;
+                --border-strong: #444;
+                --rule: #e4e4e4;
+                --code-bg: #2a2a2a;
+                --tag-bg: #2c2c2c;
+                --featured-bg: #2a2614;
+                --featured-border: #5c5020;
+                --featured-text: #e0c060;
+                --updated-bg: #3d3210;
+                --updated-text: #f0d070;
+                --error: #ff6b6b;
+                --shadow: rgba(0,0,0,0.5);
+            }
+        }
         * {
             box-sizing: border-box;
             margin: 0;
@@ -434,15 +766,44 @@ const defaultTemplate = `<!DOCTYPE html>
         body {
             font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, "Helvetica Neue", Arial, sans-serif;
             line-height: 1.6;
-            color: #333;
-            background: #f5f5f5;
+            color: var(--text);
+            background: var(--bg);
             padding: 20px;
         }
+        a {
+            color: var(--accent);
+        }
+        :focus-visible {
+            outline: 3px solid var(--accent);
+            outline-offset: 2px;
+        }
+        .skip-link {
+            position: absolute;
+            left: 10px;
+            top: -100px;
+            z-index: 10;
+            background: var(--surface);
+            color: var(--accent);
+            padding: 8px 16px;
+            border-radius: 4px;
+            box-shadow: 0 2px 10px var(--shadow);
+        }
+        .skip-link:focus {
+            top: 10px;
+        }
+        .visually-hidden {
+            position: absolute;
+            width: 1px;
+            height: 1px;
+            overflow: hidden;
+            clip: rect(0 0 0 0);
+            white-space: nowrap;
+        }
         .container {
             max-width: 1200px;
             margin: 0 auto;
-            background: white;
-            box-shadow: 0 2px 10px rgba(0,0,0,0.1);
+            background: var(--surface);
+            box-shadow: 0 2px 10px var(--shadow);
         }
         .layout {
             display: flex;
@@ -455,15 +816,15 @@ const defaultTemplate = `<!DOCTYPE html>
         }
         .sidebar {
             width: 280px;
-            background: #f9f9f9;
-            border-left: 1px solid #e0e0e0;
+            background: var(--surface-alt);
+            border-left: 1px solid var(--border-strong);
             padding: 30px 20px;
         }
         .sidebar h2 {
             font-size: 1.2em;
             margin-bottom: 15px;
-            color: #333;
-            border-bottom: 2px solid #ddd;
+            color: var(--text);
+            border-bottom: 2px solid var(--border-strong);
             padding-bottom: 8px;
         }
         .sidebar ul {
@@ -474,23 +835,26 @@ const defaultTemplate = `<!DOCTYPE html>
             font-size: 0.9em;
         }
         .sidebar a {
-            color: #0066cc;
             text-decoration: none;
             display: block;
         }
         .sidebar a:hover {
             text-decoration: underline;
         }
+        .feed-icon {
+            vertical-align: -2px;
+            margin-right: 6px;
+        }
         .feed-meta {
             font-size: 0.8em;
-            color: #999;
+            color: var(--faint);
             margin-top: 3px;
         }
         .feed-error {
-            color: #cc0000;
+            color: var(--error);
         }
         header {
-            border-bottom: 3px solid #333;
+            border-bottom: 3px solid var(--rule);
             padding-bottom: 20px;
             margin-bottom: 40px;
         }
@@ -499,14 +863,14 @@ const defaultTemplate = `<!DOCTYPE html>
             margin-bottom: 10px;
         }
         h1 a {
-            color: #333;
+            color: var(--text);
             text-decoration: none;
         }
         h1 a:hover {
-            color: #666;
+            color: var(--muted);
         }
         .subtitle {
-            color: #666;
+            color: var(--muted);
             font-size: 1.1em;
         }
         .date-group {
@@ -514,43 +878,157 @@ const defaultTemplate = `<!DOCTYPE html>
         }
         .date-group h2 {
             font-size: 1.5em;
-            color: #666;
-            border-bottom: 2px solid #eee;
+            color: var(--muted);
+            border-bottom: 2px solid var(--border);
             padding-bottom: 10px;
             margin-bottom: 20px;
         }
+        .featured {
+            background: var(--featured-bg);
+            border: 1px solid var(--featured-border);
+            border-radius: 6px;
+            padding: 20px 25px 0;
+            margin-bottom: 40px;
+        }
+        .featured h2 {
+            font-size: 1.2em;
+            text-transform: uppercase;
+            letter-spacing: 0.05em;
+            color: var(--featured-text);
+            margin-bottom: 15px;
+        }
+        .group {
+            margin-bottom: 50px;
+        }
+        .group-title {
+            font-size: 1.8em;
+            border-bottom: 3px solid var(--border-strong);
+            padding-bottom: 8px;
+            margin-bottom: 25px;
+        }
+        .group-nav {
+            display: flex;
+            flex-wrap: wrap;
+            gap: 8px;
+            margin-bottom: 30px;
+        }
+        .group-nav a {
+            text-decoration: none;
+            border: 1px solid var(--border-strong);
+            border-radius: 4px;
+            padding: 4px 12px;
+        }
+        .group-nav a:hover {
+            background: var(--tag-bg);
+        }
+        /* Tabs without script: the targeted group shows, or the first one */
+        .group-tabs .group:not(:target) {
+            display: none;
+        }
+        .group-tabs:not(:has(.group:target)) .group:first-of-type {
+            display: block;
+        }
+        .topic-nav {
+            display: flex;
+            flex-wrap: wrap;
+            gap: 8px;
+            margin-bottom: 30px;
+            font-size: 0.9em;
+        }
+        .topic-nav a {
+            text-decoration: none;
+            background: var(--tag-bg);
+            border-radius: 3px;
+            padding: 2px 10px;
+        }
+        .topic-nav a:target {
+            outline: 2px solid var(--accent);
+        }
+        /* Per-feed accent colours and avatars, the classic planet faces */
+        .entry[style*="--feed-accent"] {
+            border-left: 4px solid var(--feed-accent);
+            padding-left: 16px;
+        }
+        .sidebar li[style*="--feed-accent"] {
+            border-left: 3px solid var(--feed-accent);
+            padding-left: 8px;
+        }
+        .entry:has(.entry-avatar) {
+            display: flow-root;
+        }
+        .entry-avatar {
+            float: right;
+            margin: 0 0 10px 15px;
+            border-radius: 6px;
+            object-fit: cover;
+        }
+        .entry-related {
+            margin-top: 20px;
+            font-size: 0.9em;
+        }
+        .entry-related h4 {
+            margin: 0 0 6px;
+            color: var(--muted);
+        }
+        .entry-related ul {
+            margin: 0;
+            padding-left: 20px;
+        }
+        .entry-content mark {
+            background: var(--tag-bg);
+            color: inherit;
+            border-radius: 2px;
+        }
         .entry {
             margin-bottom: 40px;
             padding-bottom: 30px;
-            border-bottom: 1px solid #eee;
+            border-bottom: 1px solid var(--border);
         }
         .entry:last-child {
             border-bottom: none;
         }
+        .entry:focus {
+            outline: none;
+        }
+        .entry:focus-visible {
+            outline: 3px solid var(--accent);
+            outline-offset: 8px;
+        }
         .entry h3 {
             font-size: 1.5em;
             margin-bottom: 10px;
         }
         .entry h3 a {
-            color: #0066cc;
             text-decoration: none;
         }
         .entry h3 a:hover {
             text-decoration: underline;
         }
         .entry-meta {
-            color: #666;
+            color: var(--muted);
             font-size: 0.9em;
             margin-bottom: 15px;
         }
         .entry-meta a {
-            color: #666;
+            color: var(--muted);
             text-decoration: none;
         }
         .entry-meta a:hover {
-            color: #333;
+            color: var(--text);
             text-decoration: underline;
         }
+        .entry-original-title {
+            color: var(--muted);
+            font-style: italic;
+            margin-top: -5px;
+        }
+        .entry-updated {
+            background: var(--updated-bg);
+            color: var(--updated-text);
+            border-radius: 3px;
+            padding: 1px 6px;
+            font-size: 0.85em;
+        }
         .entry-content {
             margin-top: 15px;
         }
@@ -559,13 +1037,13 @@ const defaultTemplate = `<!DOCTYPE html>
             height: auto;
         }
         .entry-content pre {
-            background: #f5f5f5;
+            background: var(--code-bg);
             padding: 15px;
             overflow-x: auto;
             border-radius: 5px;
         }
         .entry-content code {
-            background: #f5f5f5;
+            background: var(--code-bg);
             padding: 2px 5px;
             border-radius: 3px;
             font-family: monospace;
@@ -575,21 +1053,93 @@ const defaultTemplate = `<!DOCTYPE html>
             padding: 0;
         }
         .entry-content blockquote {
-            border-left: 4px solid #ddd;
+            border-left: 4px solid var(--border-strong);
             padding-left: 20px;
             margin: 20px 0;
-            color: #666;
+            color: var(--muted);
+        }
+        .entry-external {
+            margin: 0 0 10px;
+            color: var(--muted);
+        }
+        .entry-attachments {
+            list-style: none;
+            margin-top: 15px;
+        }
+        .entry-attachments .attachment-thumbnail {
+            display: block;
+            max-width: 320px;
+            height: auto;
+            margin-bottom: 5px;
+        }
+        .entry-attachments .attachment-size {
+            color: var(--muted);
+            font-size: 0.9em;
+        }
+        .entry-tags {
+            list-style: none;
+            display: flex;
+            flex-wrap: wrap;
+            gap: 6px;
+            margin-top: 15px;
+        }
+        .entry-tags li {
+            background: var(--tag-bg);
+            color: var(--muted);
+            border-radius: 3px;
+            padding: 2px 8px;
+            font-size: 0.8em;
+        }
+        .archive-title {
+            font-size: 1.5em;
+            color: var(--muted);
+            border-bottom: 2px solid var(--border);
+            padding-bottom: 10px;
+            margin-bottom: 20px;
+        }
+        .archive-year {
+            margin-bottom: 30px;
+        }
+        .archive-year h2 {
+            font-size: 1.3em;
+            margin-bottom: 10px;
+        }
+        .archive-year ul {
+            list-style: none;
+            columns: 3 12em;
+        }
+        .archive a {
+            text-decoration: none;
+        }
+        .archive a:hover {
+            text-decoration: underline;
+        }
+        .pagination {
+            display: flex;
+            justify-content: space-between;
+            align-items: center;
+            margin-top: 20px;
+            padding-top: 20px;
+            border-top: 1px solid var(--border);
+            color: var(--muted);
+            font-size: 0.9em;
+        }
+        .pagination a {
+            text-decoration: none;
+        }
+        .pagination a:hover {
+            text-decoration: underline;
         }
         footer {
             margin-top: 40px;
             padding-top: 20px;
-            border-top: 1px solid #eee;
+            border-top: 1px solid var(--border);
             text-align: center;
-            color: #666;
+            color: var(--muted);
             font-size: 0.9em;
         }
         footer a {
-            color: #666;
+            color: var(--muted);
         }
         @media (max-width: 968px) {
             .layout {
@@ -598,7 +1148,7 @@ const defaultTemplate = `<!DOCTYPE html>
             .sidebar {
                 width: 100%;
                 border-left: none;
-                border-top: 1px solid #e0e0e0;
+                border-top: 1px solid var(--border-strong);
             }
         }
         @media (max-width: 768px) {
@@ -612,67 +1162,137 @@ const defaultTemplate = `<!DOCTYPE html>
                 font-size: 2em;
             }
         }
+        @media (prefers-reduced-motion: no-preference) {
+            html {
+                scroll-behavior: smooth;
+            }
+        }
+        {{- if .AccentColor}}
+        :root {
+            --accent: {{.AccentColor}};
+        }
+        {{- end}}
+        {{- if .AccentColorDark}}
+        @media (prefers-color-scheme: dark) {
+            :root {
+                --accent: {{.AccentColorDark}};
+            }
+        }
+        {{- end}}
+        {{- range .Topics}}
+        /* Filters without script: a targeted topic hides the entries without it */
+        html:has(#{{topicID .}}:target) .entry:not([data-topics~="{{topicID .}}"]) {
+            display: none;
+        }
+        {{- end}}
     </style>
+    {{end}}
 </head>
 <body>
+    <a class="skip-link" href="#content">Skip to content</a>
     <div class="container">
         <div class="layout">
             <div class="main-content">
-                <header>
+                {{block "header" .}}
+                <header role="banner">
                     <h1>{{if .Link}}<a href="{{.Link}}">{{.Title}}</a>{{else}}{{.Title}}{{end}}</h1>
                     {{if .Subtitle}}<p class="subtitle">{{.Subtitle}}</p>{{end}}
                 </header>
+                {{end}}
 
-                <main>
-            {{if .GroupByDate}}
+                <main id="content" tabindex="-1">
+            {{if .ArchiveTitle}}<h2 class="archive-title">{{.ArchiveTitle}}</h2>{{end}}
+            {{if .Archive}}{{template "archive" .}}{{end}}
+            {{if .Topics}}
+                {{block "topics" .}}
+                <nav class="topic-nav" aria-label="Topics">
+                    <a href="#content">All</a>
+                    {{range .Topics}}<a id="{{topicID .}}" href="#{{topicID .}}">{{.}}</a>{{end}}
+                </nav>
+                {{end}}
+            {{end}}
+            {{if .Featured}}
+                {{block "featured" .}}
+                <section class="featured" aria-label="Featured">
+                    <h2>Featured</h2>
+                    {{range .Featured}}
+                    {{template "entry" .}}
+                    {{end}}
+                </section>
+                {{end}}
+            {{end}}
+            {{if .Groups}}
+                {{block "groups" .}}
+                <div class="groups{{if .GroupTabs}} group-tabs{{end}}">
+                    {{if .GroupTabs}}
+                    <nav class="group-nav" aria-label="Groups">
+                        {{range .Groups}}<a href="#{{.ID}}">{{.Name}}</a>{{end}}
+                    </nav>
+                    {{end}}
+                    {{range .Groups}}
+                    <section class="group" id="{{.ID}}">
+                        <h2 class="group-title">{{.Name}}</h2>
+                        {{if $.GroupByDate}}
+                        {{range .DateGroups}}
+                        <section class="date-group" aria-label="{{.DateStr}}">
+                            <h2>{{.DateStr}}</h2>
+                            {{range .Entries}}
+                            {{template "entry" .}}
+                            {{end}}
+                        </section>
+                        {{end}}
+                        {{else}}
+                        {{range .Entries}}
+                        {{template "entry" .}}
+                        {{end}}
+                        {{end}}
+                    </section>
+                    {{end}}
+                </div>
+                {{end}}
+            {{else if .GroupByDate}}
                 {{range .DateGroups}}
-                <div class="date-group">
+                <section class="date-group" aria-label="{{.DateStr}}">
                     <h2>{{.DateStr}}</h2>
                     {{range .Entries}}
-                    <article class="entry">
-                        <h3><a href="{{.Link}}">{{.Title}}</a></h3>
-                        <div class="entry-meta">
-                            {{if .Author}}By {{.Author}} &middot; {{end}}
-                            <a href="{{.FeedLink}}">{{.FeedTitle}}</a> &middot;
-                            <time datetime="{{formatDateISO .Published}}">{{.PublishedRelative}}</time>
-                        </div>
-                        <div class="entry-content">
-                            {{.Content}}
-                        </div>
-                    </article>
+                    {{template "entry" .}}
                     {{end}}
-                </div>
+                </section>
                 {{end}}
             {{else}}
                 {{range .Entries}}
-                <article class="entry">
-                    <h3><a href="{{.Link}}">{{.Title}}</a></h3>
-                    <div class="entry-meta">
-                        {{if .Author}}By {{.Author}} &middot; {{end}}
-                        <a href="{{.FeedLink}}">{{.FeedTitle}}</a> &middot;
-                        <time datetime="{{formatDateISO .Published}}">{{.PublishedRelative}}</time>
-                    </div>
-                    <div class="entry-content">
-                        {{.Content}}
-                    </div>
-                </article>
+                {{template "entry" .}}
                 {{end}}
             {{end}}
                 </main>
 
-                <footer>
+                {{block "pagination" .}}
+                {{if gt .TotalPages 1}}
+                <nav class="pagination" aria-label="Pages">
+                    <span>{{if .PrevURL}}<a href="{{.PrevURL}}" rel="prev">&larr; Newer entries</a>{{end}}</span>
+                    <span>{{if .ArchiveTitle}}{{.ArchiveTitle}}{{else}}Page {{.Page}} of {{.TotalPages}}{{end}}</span>
+                    <span>{{if .NextURL}}<a href="{{.NextURL}}" rel="next">Older entries &rarr;</a>{{end}}</span>
+                </nav>
+                {{end}}
+                {{end}}
+
+                {{block "footer" .}}
+                <footer role="contentinfo">
+                    {{if .ArchiveURL}}<p><a href="{{.ArchiveURL}}">Archive</a></p>{{end}}
                     <p>Generated by {{.Generator}} on {{formatDate .Updated}}</p>
                     {{if .OwnerName}}<p>&copy; {{.Updated.Year}} {{.OwnerName}}</p>{{end}}
                 </footer>
+                {{end}}
             </div>
 
+            {{block "sidebar" .}}
             {{if .Feeds}}
-            <aside class="sidebar">
+            <aside class="sidebar" aria-label="Subscriptions">
                 <h2>Subscriptions</h2>
                 <ul>
                 {{range .Feeds}}
-                    <li>
-                        <a href="{{.Link}}" title="{{.URL}}">{{.Title}}</a>
+                    <li{{with .CSSClass}} class="{{.}}"{{end}}{{with .AccentColor}} style="--feed-accent: {{.}}"{{end}}>
+                        <a href="{{.Link}}" title="{{.URL}}">{{if .Avatar}}<img class="feed-icon" src="{{.Avatar}}" alt="" width="16" height="16">{{else if .Icon}}<img class="feed-icon" src="{{.Icon}}" alt="" width="16" height="16">{{end}}{{.Title}}</a>
                         {{if .LastUpdated}}
                         <div class="feed-meta">
                             Updated {{relativeTime .LastUpdated}}
@@ -688,8 +1308,50 @@ const defaultTemplate = `<!DOCTYPE html>
                 </ul>
             </aside>
             {{end}}
+            {{end}}
         </div>
     </div>
 </body>
 </html>
-`
+{{define "entry"}}
+<article id="{{.Anchor}}" class="entry{{with .FeedCSSClass}} {{.}}{{end}}"{{with .FeedAccentColor}} style="--feed-accent: {{.}}"{{end}}{{if .Topics}} data-topics="{{range $i, $t := .Topics}}{{if $i}} {{end}}{{topicID $t}}{{end}}"{{end}}>
+    {{if .Face}}<img class="entry-avatar entry-face" src="{{.Face}}" alt="{{.Author}}" width="64" height="64" loading="lazy">{{else if .FeedAvatar}}<img class="entry-avatar" src="{{.FeedAvatar}}" alt="{{.FeedTitle}}" width="64" height="64" loading="lazy">{{end}}
+    <h3{{if .Language}} lang="{{.Language}}"{{end}}><a href="{{.Link}}">{{.Title}}</a></h3>
+    {{if .OriginalTitle}}<p class="entry-original-title" translate="no">{{.OriginalTitle}}</p>{{end}}
+    <div class="entry-meta">
+        {{if .Authors}}By {{range $i, $a := .Authors}}{{if $i}}, {{end}}{{$a}}{{end}} &middot; {{else if .Author}}By {{.Author}} &middot; {{end}}
+        <a href="{{.FeedLink}}">{{.FeedTitle}}</a> &middot;
+        <a class="entry-permalink" href="{{with .Permalink}}{{.}}{{else}}#{{.Anchor}}{{end}}" title="Permalink"><time datetime="{{formatDateISO .Published}}">{{.PublishedRelative}}</time></a>
+        {{- if .ReadingMinutes}} &middot;
+        <span class="entry-reading-time">{{.ReadingMinutes}} min read</span>
+        {{- end}}
+        {{- if .UpdatedCount}} &middot;
+        <time class="entry-updated" datetime="{{formatDateISO .LastSignificantUpdate}}" title="Updated {{formatDate .LastSignificantUpdate}}">Updated</time>
+        {{- end}}
+    </div>
+    {{if .ExternalURL}}<p class="entry-external">&rarr; <a href="{{.ExternalURL}}">{{hostname .ExternalURL}}</a></p>{{end}}
+    <div class="entry-content"{{if .Language}} lang="{{.Language}}"{{end}}>
+        {{.Content}}
+    </div>
+    {{if .Attachments}}
+    <ul class="entry-attachments">
+        {{range .Attachments}}<li><a href="{{.URL}}"{{if .MimeType}} type="{{.MimeType}}"{{end}}>
+        {{- if .Thumbnail}}<img class="attachment-thumbnail" src="{{.Thumbnail}}" alt="" loading="lazy">{{end}}{{or .Title (printf "Download %s" (hostname .URL))}}</a>
+        {{- if .Size}} <span class="attachment-size">({{formatSize .Size}})</span>{{end}}</li>{{end}}
+    </ul>
+    {{end}}
+    {{if .Categories}}
+    <ul class="entry-tags">
+        {{range .Categories}}<li>{{.}}</li>{{end}}
+    </ul>
+    {{end}}
+    {{if .Related}}
+    <aside class="entry-related" aria-label="Related posts">
+        <h4>Related posts</h4>
+        <ul>
+            {{range .Related}}<li><a href="{{.Link}}">{{.Title}}</a></li>{{end}}
+        </ul>
+    </aside>
+    {{end}}
+</article>
+{{end}}` + archiveTemplate
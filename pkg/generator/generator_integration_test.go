@@ -1,7 +1,9 @@
 package generator
 
 import (
+	"bytes"
 	"context"
+	"fmt"
 	"html/template"
 	"net/http"
 	"net/http/httptest"
@@ -11,12 +13,90 @@ import (
 	"testing"
 	"time"
 
+	"github.com/adewale/rogue_planet/pkg/buildinfo"
 	"github.com/adewale/rogue_planet/pkg/crawler"
 	"github.com/adewale/rogue_planet/pkg/normalizer"
 	"github.com/adewale/rogue_planet/pkg/repository"
+	"github.com/adewale/rogue_planet/pkg/timeprovider"
 	"golang.org/x/net/html"
 )
 
+// TestStableOutputForTiedTimestamps verifies that entries sharing a timestamp
+// render byte-identically regardless of the order they were stored in
+func TestStableOutputForTiedTimestamps(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	published := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	ids := []string{"post-c", "post-a", "post-b"}
+	reversed := []string{"post-b", "post-a", "post-c"}
+
+	render := func(order []string) []byte {
+		repo, err := repository.New(filepath.Join(t.TempDir(), "planet.db"))
+		if err != nil {
+			t.Fatalf("repository.New() error = %v", err)
+		}
+		defer repo.Close()
+
+		feed1, _ := repo.AddFeed(ctx, "https://one.example.com/feed", "One")
+		feed2, _ := repo.AddFeed(ctx, "https://two.example.com/feed", "Two")
+		for _, id := range order {
+			for _, feedID := range []int64{feed2, feed1} {
+				err := repo.UpsertEntry(ctx, &repository.Entry{
+					FeedID:    feedID,
+					EntryID:   id,
+					Title:     id,
+					Link:      "https://example.com/" + id,
+					Published: published,
+					FirstSeen: published,
+				})
+				if err != nil {
+					t.Fatalf("UpsertEntry() error = %v", err)
+				}
+			}
+		}
+
+		entries, err := repo.GetRecentEntriesWithOptions(ctx, 7, false, "published")
+		if err != nil {
+			t.Fatalf("GetRecentEntriesWithOptions() error = %v", err)
+		}
+
+		data := TemplateData{Title: "Stable", GroupByDate: true}
+		for _, e := range entries {
+			data.Entries = append(data.Entries, EntryData{
+				Title:     template.HTML(e.Title),
+				Link:      e.Link,
+				FeedTitle: fmt.Sprintf("Feed %d", e.FeedID),
+				Published: e.Published,
+			})
+		}
+
+		gen, err := NewWithTimeProvider(timeprovider.NewFakeClock(now))
+		if err != nil {
+			t.Fatalf("NewWithTimeProvider() error = %v", err)
+		}
+		gen.SetBuildInfo(buildinfo.Info{Version: "test", GoVersion: "go"})
+
+		var buf bytes.Buffer
+		if err := gen.Generate(ctx, &buf, data); err != nil {
+			t.Fatalf("Generate() error = %v", err)
+		}
+		return buf.Bytes()
+	}
+
+	first := render(ids)
+	second := render(ids)
+	third := render(reversed)
+
+	if !bytes.Equal(first, second) {
+		t.Error("repeated generation produced different output")
+	}
+	if !bytes.Equal(first, third) {
+		t.Error("storage order changed generated output")
+	}
+}
+
 // TestEndToEndHTMLGeneration tests the full pipeline from fetching to HTML generation
 func TestEndToEndHTMLGeneration(t *testing.T) {
 	t.Parallel()
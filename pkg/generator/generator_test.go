@@ -3,6 +3,7 @@ package generator
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"html/template"
 	"os"
 	"path/filepath"
@@ -10,6 +11,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/adewale/rogue_planet/pkg/buildinfo"
 	"github.com/adewale/rogue_planet/pkg/timeprovider"
 )
 
@@ -758,6 +760,90 @@ func TestRelativeTimeEdgeCases(t *testing.T) {
 	}
 }
 
+func TestNewWithTemplatePartials(t *testing.T) {
+	t.Parallel()
+	data := TemplateData{
+		Title:   "Partials Planet",
+		Entries: []EntryData{{Title: "First post", Link: "https://example.com/1", Published: time.Now()}},
+		Feeds:   []FeedData{{Title: "Example Feed", Link: "https://example.com/"}},
+	}
+
+	tests := []struct {
+		name     string
+		files    map[string]string
+		path     string // Relative to the theme directory; empty for the directory itself
+		want     []string
+		dontWant []string
+	}{
+		{
+			name: "directory overrides one block of the built-in template",
+			files: map[string]string{
+				"partials/entry.html": `{{define "entry"}}<p class="my-entry">{{.Title}}</p>{{end}}`,
+			},
+			want:     []string{`<p class="my-entry">First post</p>`, "Subscriptions", "'unsafe-inline'"},
+			dontWant: []string{`<article class="entry">`},
+		},
+		{
+			name: "directory with template.html uses it as the main template",
+			files: map[string]string{
+				"template.html":        `<main>{{range .Entries}}{{template "card" .}}{{end}}</main>`,
+				"partials/card.html":   `{{define "card"}}<div class="card">{{.Title}}</div>{{end}}`,
+				"partials/README.html": ``,
+			},
+			want:     []string{`<main><div class="card">First post</div></main>`},
+			dontWant: []string{"Subscriptions"},
+		},
+		{
+			name: "template file picks up partials beside it",
+			files: map[string]string{
+				"layout.html":          `{{block "header" .}}<h1>Default</h1>{{end}}`,
+				"partials/header.html": `{{define "header"}}<h1>Custom {{.Title}}</h1>{{end}}`,
+			},
+			path: "layout.html",
+			want: []string{"<h1>Custom Partials Planet</h1>"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			themeDir := t.TempDir()
+			for name, content := range tt.files {
+				writeTestFile(t, filepath.Join(themeDir, name), content)
+			}
+
+			gen, err := NewWithTemplate(filepath.Join(themeDir, tt.path))
+			if err != nil {
+				t.Fatalf("NewWithTemplate() error = %v", err)
+			}
+			var buf bytes.Buffer
+			if err := gen.Generate(context.Background(), &buf, data); err != nil {
+				t.Fatalf("Generate() error = %v", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(buf.String(), want) {
+					t.Errorf("output missing %q:\n%s", want, buf.String())
+				}
+			}
+			for _, dontWant := range tt.dontWant {
+				if strings.Contains(buf.String(), dontWant) {
+					t.Errorf("output should not contain %q", dontWant)
+				}
+			}
+		})
+	}
+}
+
+func TestNewWithTemplatePartialsBadSyntax(t *testing.T) {
+	t.Parallel()
+	themeDir := t.TempDir()
+	writeTestFile(t, filepath.Join(themeDir, "partials", "entry.html"), `{{define "entry"}}{{.Title{{end}}`)
+
+	if _, err := NewWithTemplate(themeDir); err == nil || !strings.Contains(err.Error(), "parse partials") {
+		t.Errorf("NewWithTemplate() error = %v, want parse partials error", err)
+	}
+}
+
 func TestNewWithTemplateError(t *testing.T) {
 	t.Parallel()
 	_, err := NewWithTemplate("/nonexistent/template.html")
@@ -901,6 +987,58 @@ func TestCopyStaticAssets(t *testing.T) {
 	}
 }
 
+func TestCopyStaticAssetsKeepsFaviconsAndFaces(t *testing.T) {
+	t.Parallel()
+	themeDir := t.TempDir()
+	outputDir := t.TempDir()
+	writeTestFile(t, filepath.Join(themeDir, "template.html"), "<html></html>")
+	writeTestFile(t, filepath.Join(themeDir, "static", "style.css"), "body {}")
+	writeTestFile(t, filepath.Join(outputDir, "static", "old.css"), "stale")
+	writeTestFile(t, filepath.Join(outputDir, FaviconsDir, "example.com.png"), "icon")
+	writeTestFile(t, filepath.Join(outputDir, FacesDir, "ada.png"), "face")
+
+	gen, err := NewWithTemplate(themeDir)
+	if err != nil {
+		t.Fatalf("NewWithTemplate() error = %v", err)
+	}
+	if err := gen.CopyStaticAssets(context.Background(), outputDir); err != nil {
+		t.Fatalf("CopyStaticAssets() error = %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(outputDir, "static", "old.css")); !os.IsNotExist(err) {
+		t.Errorf("stale theme file should be removed, stat error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, FaviconsDir, "example.com.png")); err != nil {
+		t.Errorf("favicon cache should be kept: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, FacesDir, "ada.png")); err != nil {
+		t.Errorf("author faces should be kept: %v", err)
+	}
+}
+
+func TestSidebarFeedIcon(t *testing.T) {
+	t.Parallel()
+	gen, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := TemplateData{Feeds: []FeedData{
+		{Title: "With Icon", Link: "https://a.example.com/", Icon: "static/favicons/a.example.com.png"},
+		{Title: "Without Icon", Link: "https://b.example.com/"},
+	}}
+
+	var buf bytes.Buffer
+	if err := gen.Generate(context.Background(), &buf, data); err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	if got := strings.Count(buf.String(), `class="feed-icon"`); got != 1 {
+		t.Errorf("rendered %d feed icons, want 1", got)
+	}
+	if !strings.Contains(buf.String(), `src="static/favicons/a.example.com.png"`) {
+		t.Error("sidebar should link the cached favicon")
+	}
+}
+
 func TestCopyStaticAssetsNoStaticDir(t *testing.T) {
 	t.Parallel()
 	tmpDir := t.TempDir()
@@ -1016,3 +1154,362 @@ func TestGenerateWithSubtitle(t *testing.T) {
 		t.Error("Title should be a link when Link is provided")
 	}
 }
+
+func TestGenerateBuildInfo(t *testing.T) {
+	t.Parallel()
+	gen, _ := New()
+	gen.SetBuildInfo(buildinfo.Info{
+		Version:   "9.9.9",
+		Commit:    "0123456789abcdef0123",
+		BuildDate: "2025-01-02T03:04:05Z",
+		GoVersion: "go1.24.0",
+		BuildTags: []string{"network"},
+	})
+
+	tmpDir := t.TempDir()
+	outputPath := filepath.Join(tmpDir, "index.html")
+
+	if err := gen.GenerateToFile(context.Background(), outputPath, TemplateData{Title: "Test Planet"}); err != nil {
+		t.Fatalf("GenerateToFile() error = %v", err)
+	}
+
+	content, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatalf("Failed to read output file: %v", err)
+	}
+	html := string(content)
+
+	if !strings.Contains(html, `content="Rogue Planet v9.9.9"`) {
+		t.Error("Generator meta tag should contain build version")
+	}
+	wantComment := "<!-- Generated by rp 9.9.9, commit 0123456789ab, built 2025-01-02T03:04:05Z, go1.24.0, tags network -->"
+	if !strings.Contains(html, wantComment) {
+		t.Errorf("Output should contain build comment %q", wantComment)
+	}
+
+	raw, err := os.ReadFile(filepath.Join(tmpDir, "build-info.json"))
+	if err != nil {
+		t.Fatalf("build-info.json should exist: %v", err)
+	}
+	var info buildinfo.Info
+	if err := json.Unmarshal(raw, &info); err != nil {
+		t.Fatalf("build-info.json is not valid JSON: %v", err)
+	}
+	if info.Version != "9.9.9" || info.Commit != "0123456789abcdef0123" || info.GoVersion != "go1.24.0" {
+		t.Errorf("build-info.json = %+v, want values from SetBuildInfo", info)
+	}
+}
+
+func TestGenerateFeatured(t *testing.T) {
+	t.Parallel()
+	gen, _ := New()
+	classic := EntryData{ID: "urn:classic", Title: "Classic Post", Link: "https://example.com/classic", Published: time.Now().AddDate(-5, 0, 0)}
+	data := TemplateData{
+		Title:    "Featured Planet",
+		Featured: []EntryData{classic},
+		Entries: []EntryData{
+			{ID: "urn:fresh", Title: "Fresh Post", Link: "https://example.com/fresh", Published: time.Now()},
+			classic,
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := gen.Generate(context.Background(), &buf, data); err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	output := buf.String()
+	if !strings.Contains(output, `<section class="featured" aria-label="Featured">`) {
+		t.Fatal("first page should have a Featured section")
+	}
+	if strings.Count(output, "Classic Post") != 1 {
+		t.Errorf("a featured entry should appear once, got %d", strings.Count(output, "Classic Post"))
+	}
+	if strings.Index(output, "Classic Post") > strings.Index(output, "Fresh Post") {
+		t.Error("featured entries should come before the river")
+	}
+
+	// Older pages and archives leave featured entries in place
+	for _, page := range []TemplateData{
+		{Title: "Page 2", PrevURL: "index.html", Featured: data.Featured, Entries: data.Entries},
+		{Title: "Archive", ArchiveTitle: "May 2024", Featured: data.Featured, Entries: data.Entries},
+	} {
+		buf.Reset()
+		if err := gen.Generate(context.Background(), &buf, page); err != nil {
+			t.Fatalf("Generate() error = %v", err)
+		}
+		if strings.Contains(buf.String(), `class="featured"`) || !strings.Contains(buf.String(), "Classic Post") {
+			t.Errorf("%s: featured section should only be on the first page", page.Title)
+		}
+	}
+}
+
+func TestGenerateReadingTime(t *testing.T) {
+	t.Parallel()
+	gen, _ := New()
+	long := "<p>" + strings.Repeat("word ", 450) + "</p>"
+	data := TemplateData{
+		Title: "Reading Planet",
+		Entries: []EntryData{
+			{Title: "Stored", Content: "<p>Short</p>", WordCount: 1000, ReadingMinutes: 5},
+			{Title: "Counted", Content: template.HTML(long)},
+			{Title: "Empty"},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := gen.Generate(context.Background(), &buf, data); err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	output := buf.String()
+	for _, want := range []string{"5 min read", "3 min read"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("output should contain %q", want)
+		}
+	}
+	if n := strings.Count(output, `class="entry-reading-time"`); n != 2 {
+		t.Errorf("output has %d reading times, want 2 (none for an empty entry)", n)
+	}
+}
+
+func TestGenerateOpenGraph(t *testing.T) {
+	t.Parallel()
+	gen, _ := New()
+	render := func(data TemplateData) string {
+		t.Helper()
+		var buf bytes.Buffer
+		if err := gen.Generate(context.Background(), &buf, data); err != nil {
+			t.Fatalf("Generate() error = %v", err)
+		}
+		return buf.String()
+	}
+
+	output := render(TemplateData{Title: "Planet Go", Subtitle: "Posts about Go", Link: "https://planet.example.com/", Image: "https://planet.example.com/card.png"})
+	for _, want := range []string{
+		`<meta property="og:title" content="Planet Go">`,
+		`<meta property="og:description" content="Posts about Go">`,
+		`<meta property="og:url" content="https://planet.example.com/">`,
+		`<meta property="og:image" content="https://planet.example.com/card.png">`,
+		`<meta name="twitter:card" content="summary_large_image">`,
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("output should contain %s", want)
+		}
+	}
+
+	output = render(TemplateData{Title: "Planet Go"})
+	if strings.Contains(output, "og:image") || strings.Contains(output, "og:url") || !strings.Contains(output, `<meta name="twitter:card" content="summary">`) {
+		t.Error("a planet without an image or link should have a summary card without og:image or og:url")
+	}
+}
+
+func TestGenerateAccessibility(t *testing.T) {
+	t.Parallel()
+	gen, _ := New()
+	render := func(data TemplateData) string {
+		t.Helper()
+		var buf bytes.Buffer
+		if err := gen.Generate(context.Background(), &buf, data); err != nil {
+			t.Fatalf("Generate() error = %v", err)
+		}
+		return buf.String()
+	}
+
+	data := TemplateData{
+		Title:   "Planet Go",
+		Entries: []EntryData{{Title: "First post", Published: time.Now()}},
+		Feeds:   []FeedData{{Title: "A feed", Link: "https://example.com/"}},
+	}
+	output := render(data)
+	for _, want := range []string{
+		`<a class="skip-link" href="#content">Skip to content</a>`,
+		`<main id="content" tabindex="-1">`,
+		`<aside class="sidebar" aria-label="Subscriptions">`,
+		`<meta name="color-scheme" content="light dark">`,
+		`@media (prefers-color-scheme: dark)`,
+		`<script src="planet.js" defer></script>`,
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("output should contain %s", want)
+		}
+	}
+	if strings.Contains(output, "--accent: #a0522d") {
+		t.Error("output should keep the built-in accent colour when none is configured")
+	}
+
+	data.AccentColor = "#a0522d"
+	data.AccentColorDark = "#f4a460"
+	output = render(data)
+	light := strings.LastIndex(output, "--accent: #a0522d;")
+	dark := strings.LastIndex(output, "--accent: #f4a460;")
+	if light < 0 || dark < light {
+		t.Error("configured accent colours should follow, and so override, the built-in ones")
+	}
+}
+
+func TestGenerateToFileScript(t *testing.T) {
+	t.Parallel()
+
+	gen, _ := New()
+	dir := t.TempDir()
+	if err := gen.GenerateToFile(context.Background(), filepath.Join(dir, "index.html"), TemplateData{Title: "Test"}); err != nil {
+		t.Fatalf("GenerateToFile() error = %v", err)
+	}
+	script, err := os.ReadFile(filepath.Join(dir, ScriptFileName))
+	if err != nil {
+		t.Fatalf("the built-in template's script should be written: %v", err)
+	}
+	if !strings.Contains(string(script), "keydown") {
+		t.Error("script should handle key presses")
+	}
+
+	// A theme with its own main template does not use the script
+	themeDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(themeDir, ThemeTemplateName), []byte(`<p>{{.Title}}</p>`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	gen, err = NewWithTemplate(themeDir)
+	if err != nil {
+		t.Fatalf("NewWithTemplate() error = %v", err)
+	}
+	dir = t.TempDir()
+	if err := gen.GenerateToFile(context.Background(), filepath.Join(dir, "index.html"), TemplateData{Title: "Test"}); err != nil {
+		t.Fatalf("GenerateToFile() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, ScriptFileName)); !os.IsNotExist(err) {
+		t.Errorf("a custom template should not get %s, stat error = %v", ScriptFileName, err)
+	}
+}
+
+func TestGenerateEntryAuthorsAndAttachments(t *testing.T) {
+	t.Parallel()
+	gen, _ := New()
+	data := TemplateData{Title: "Planet", Entries: []EntryData{{
+		Title:       "Episode 1",
+		Link:        "https://example.com/1",
+		Author:      "Ann",
+		Authors:     []string{"Ann", "Bob"},
+		ExternalURL: "https://www.elsewhere.example.org/story",
+		Language:    "fr-CA",
+		Content:     "<p>Bonjour</p>",
+		Attachments: []Attachment{
+			{URL: "https://example.com/ep1.mp3", MimeType: "audio/mpeg", Title: "Episode 1 (MP3)", Size: 1572864},
+			{URL: "https://example.com/ep1.mp4", MimeType: "video/mp4", Title: "Episode 1 (video)", Medium: "video", Thumbnail: "https://example.com/ep1.jpg"},
+		},
+		Published: time.Now(),
+	}}}
+	var buf bytes.Buffer
+	if err := gen.Generate(context.Background(), &buf, data); err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	output := buf.String()
+	for _, want := range []string{
+		"By Ann, Bob &middot;",
+		`<h3 lang="fr-CA">`,
+		`<div class="entry-content" lang="fr-CA">`,
+		`&rarr; <a href="https://www.elsewhere.example.org/story">elsewhere.example.org</a>`,
+		`<a href="https://example.com/ep1.mp3" type="audio/mpeg">Episode 1 (MP3)</a> <span class="attachment-size">(1.5 MB)</span>`,
+		`<a href="https://example.com/ep1.mp4" type="video/mp4"><img class="attachment-thumbnail" src="https://example.com/ep1.jpg" alt="" loading="lazy">Episode 1 (video)</a>`,
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("output should contain %s", want)
+		}
+	}
+}
+
+func TestGenerateUpdatedEntries(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	gen, _ := NewWithTimeProvider(timeprovider.NewFakeClock(now))
+	data := TemplateData{
+		Title:       "Updated Planet",
+		GroupByDate: true,
+		Entries: []EntryData{
+			{Title: "Corrected Post", Published: now.AddDate(0, 0, -20), UpdatedCount: 1, LastSignificantUpdate: now.Add(-time.Hour), Resurfaced: true},
+			{Title: "Fresh Post", Published: now.Add(-2 * time.Hour)},
+			{Title: "Edited Post", Published: now.AddDate(0, 0, -1), UpdatedCount: 2, LastSignificantUpdate: now.Add(-3 * time.Hour)},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := gen.Generate(context.Background(), &buf, data); err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	output := buf.String()
+	if n := strings.Count(output, `class="entry-updated"`); n != 2 {
+		t.Errorf("output has %d updated badges, want 2", n)
+	}
+	if strings.Contains(output, "April") {
+		t.Error("a resurfaced entry should be listed under the date it was updated")
+	}
+}
+
+func TestGenerateTopics(t *testing.T) {
+	t.Parallel()
+	gen, _ := New()
+	data := TemplateData{
+		Title:  "Topical Planet",
+		Topics: []string{"Go", "Query Planners"},
+		Entries: []EntryData{
+			{Title: "Go Post", Link: "https://a.example.com/1", Topics: []string{"Go", "Query Planners"}, Categories: []string{"Go", "Query Planners"}, Published: time.Now()},
+			{Title: "Other Post", Link: "https://b.example.com/1", Published: time.Now()},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := gen.Generate(context.Background(), &buf, data); err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	output := buf.String()
+	for _, want := range []string{
+		`<nav class="topic-nav" aria-label="Topics">`,
+		`<a id="topic-go" href="#topic-go">Go</a>`,
+		`<article id="` + EntryAnchor(data.Entries[0]) + `" class="entry" data-topics="topic-go topic-query-planners">`,
+		`html:has(#topic-query-planners:target) .entry:not([data-topics~="topic-query-planners"])`,
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("output missing %q", want)
+		}
+	}
+	if strings.Contains(output, `class="entry" data-topics=""`) {
+		t.Error("entries without topics should have no data-topics")
+	}
+}
+
+func TestGenerateFeedPresentation(t *testing.T) {
+	t.Parallel()
+	gen, _ := New()
+	data := TemplateData{
+		Title: "Faces Planet",
+		Entries: []EntryData{
+			{Title: "Alice's Post", Link: "https://alice.example.com/1", FeedTitle: "Alice", Published: time.Now(),
+				FeedCSSClass: "team-go core", FeedAccentColor: "#c0ffee", FeedAvatar: "faces/alice.png"},
+			{Title: "Bob's Post", Link: "https://bob.example.com/1", FeedTitle: "Bob", Published: time.Now()},
+			{Title: "Guest Post", Link: "https://alice.example.com/2", FeedTitle: "Alice", Author: "Carol", Published: time.Now(),
+				FeedAvatar: "faces/alice.png", Face: "static/faces/carol.png"},
+		},
+		Feeds: []FeedData{
+			{Title: "Alice", Link: "https://alice.example.com/", CSSClass: "team-go", AccentColor: "#c0ffee", Avatar: "faces/alice.png"},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := gen.Generate(context.Background(), &buf, data); err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	output := buf.String()
+	for _, want := range []string{
+		`<article id="` + EntryAnchor(data.Entries[0]) + `" class="entry team-go core" style="--feed-accent: #c0ffee">`,
+		`<img class="entry-avatar" src="faces/alice.png" alt="Alice"`,
+		`<article id="` + EntryAnchor(data.Entries[1]) + `" class="entry">`,
+		`<li class="team-go" style="--feed-accent: #c0ffee">`,
+		`<img class="feed-icon" src="faces/alice.png"`,
+		`<img class="entry-avatar entry-face" src="static/faces/carol.png" alt="Carol"`,
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("output missing %q", want)
+		}
+	}
+	if n := strings.Count(output, `class="entry-avatar"`); n != 1 {
+		t.Errorf("feed avatar shown %d times, want once (the author's face replaces it)", n)
+	}
+}
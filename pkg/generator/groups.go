@@ -0,0 +1,76 @@
+package generator
+
+import (
+	"strconv"
+
+	"github.com/adewale/rogue_planet/pkg/timeprovider"
+)
+
+// OtherGroup names the group holding entries from feeds in no group
+const OtherGroup = "Other"
+
+// EntryGroup is a named group of feeds, shown as its own section of the page
+type EntryGroup struct {
+	Name       string
+	ID         string // Anchor of the group's section, e.g. "group-core-team"; set by Generate
+	MaxEntries int    // Entries shown in the group's section (0 = all)
+	Entries    []EntryData
+	DateGroups []DateGroup // Entries grouped by date, when GroupByDate is set
+}
+
+// groupEntries sorts entries into groups by their Group, keeping their order
+// and at most each group's MaxEntries. Entries whose group is not listed go
+// into OtherGroup, which comes last unless listed, and groups left empty are
+// dropped.
+func groupEntries(groups []EntryGroup, entries []EntryData, byDate bool, tp timeprovider.TimeProvider) []EntryGroup {
+	all := make([]EntryGroup, 0, len(groups)+1)
+	index := make(map[string]int, len(groups))
+	for _, g := range groups {
+		if _, dup := index[g.Name]; dup {
+			continue
+		}
+		index[g.Name] = len(all)
+		g.Entries = nil
+		all = append(all, g)
+	}
+	other, ok := index[OtherGroup]
+	if !ok {
+		other = len(all)
+		all = append(all, EntryGroup{Name: OtherGroup})
+	}
+
+	for _, e := range entries {
+		i, ok := index[e.Group]
+		if !ok {
+			i = other
+		}
+		if g := &all[i]; g.MaxEntries == 0 || len(g.Entries) < g.MaxEntries {
+			g.Entries = append(g.Entries, e)
+		}
+	}
+
+	result := all[:0]
+	ids := make(map[string]bool)
+	for _, g := range all {
+		if len(g.Entries) == 0 {
+			continue
+		}
+		g.ID = uniqueID("group-"+slugify(g.Name), ids)
+		if byDate {
+			g.DateGroups = groupEntriesByDate(g.Entries, tp)
+		}
+		result = append(result, g)
+	}
+	return result
+}
+
+// uniqueID returns id, or id with a number added if used already has it,
+// and records it in used
+func uniqueID(id string, used map[string]bool) string {
+	unique := id
+	for n := 2; used[unique]; n++ {
+		unique = id + "-" + strconv.Itoa(n)
+	}
+	used[unique] = true
+	return unique
+}
@@ -0,0 +1,108 @@
+package generator
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adewale/rogue_planet/pkg/timeprovider"
+)
+
+func TestGroupEntries(t *testing.T) {
+	t.Parallel()
+	tp := timeprovider.NewFakeClock(time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC))
+	entry := func(title, group string) EntryData {
+		return EntryData{Title: "t", Link: title, Group: group, Published: tp.Now()}
+	}
+	entries := []EntryData{
+		entry("a1", "Core team"), entry("c1", ""), entry("b1", "Community"),
+		entry("a2", "Core team"), entry("b2", "Community"), entry("x1", "Unlisted"),
+		entry("a3", "Core team"),
+	}
+	groups := []EntryGroup{
+		{Name: "Core team", MaxEntries: 2},
+		{Name: "Empty"},
+		{Name: "Community"},
+		{Name: "Core-Team"},
+	}
+
+	got := groupEntries(groups, entries, true, tp)
+	want := []struct {
+		name, id, links string
+	}{
+		{"Core team", "group-core-team", "a1 a2"},
+		{"Community", "group-community", "b1 b2"},
+		{OtherGroup, "group-other", "c1 x1"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("groupEntries() = %d groups, want %d: %+v", len(got), len(want), got)
+	}
+	for i, w := range want {
+		var links []string
+		for _, e := range got[i].Entries {
+			links = append(links, e.Link)
+		}
+		if got[i].Name != w.name || got[i].ID != w.id || strings.Join(links, " ") != w.links {
+			t.Errorf("group %d = %s (%s) %v, want %s (%s) %s", i, got[i].Name, got[i].ID, links, w.name, w.id, w.links)
+		}
+		if len(got[i].DateGroups) != 1 {
+			t.Errorf("group %s has %d date groups, want 1", got[i].Name, len(got[i].DateGroups))
+		}
+	}
+
+	// A listed Other group keeps its place and limit
+	got = groupEntries([]EntryGroup{{Name: OtherGroup, MaxEntries: 1}, {Name: "Community"}}, entries, false, tp)
+	if len(got) != 2 || got[0].Name != OtherGroup || len(got[0].Entries) != 1 {
+		t.Errorf("groupEntries() with Other listed = %+v", got)
+	}
+
+	ids := map[string]bool{}
+	if a, b := uniqueID("group-go", ids), uniqueID("group-go", ids); a != "group-go" || b != "group-go-2" {
+		t.Errorf("uniqueID() = %q, %q", a, b)
+	}
+}
+
+func TestGenerateGroups(t *testing.T) {
+	t.Parallel()
+	gen, _ := New()
+	data := TemplateData{
+		Title:     "Grouped Planet",
+		GroupTabs: true,
+		Groups:    []EntryGroup{{Name: "Core team"}},
+		Entries: []EntryData{
+			{Title: "Team Post", Link: "https://a.example.com/1", Group: "Core team", Published: time.Now()},
+			{Title: "Community Post", Link: "https://b.example.com/1", Published: time.Now()},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := gen.Generate(context.Background(), &buf, data); err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	output := buf.String()
+	for _, want := range []string{
+		`class="groups group-tabs"`,
+		`<a href="#group-core-team">Core team</a>`,
+		`<section class="group" id="group-other">`,
+		`<h2 class="group-title">Core team</h2>`,
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("output missing %q", want)
+		}
+	}
+	if strings.Index(output, "Team Post") > strings.Index(output, "Community Post") {
+		t.Error("Core team entries should come before Other")
+	}
+
+	// Archive pages are not grouped
+	data.ArchiveTitle = "May 2024"
+	buf.Reset()
+	if err := gen.Generate(context.Background(), &buf, data); err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	if strings.Contains(buf.String(), `class="group"`) {
+		t.Error("archive page should not be grouped")
+	}
+}
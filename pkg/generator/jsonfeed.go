@@ -0,0 +1,208 @@
+package generator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// JSONFeedFileName is the first page of the JSON Feed; later pages are feed-2.json, feed-3.json, ...
+const JSONFeedFileName = "feed.json"
+
+const jsonFeedVersion = "https://jsonfeed.org/version/1.1"
+
+// Attachment is a resource related to an entry, such as a podcast enclosure
+// or a video
+type Attachment struct {
+	URL       string
+	MimeType  string
+	Title     string
+	Size      int64  // Bytes; 0 if unknown
+	Duration  int    // Seconds; 0 if unknown
+	Medium    string // "image", "audio", "video", ... or "" if unknown
+	Width     int    // Pixels; 0 if unknown
+	Height    int    // Pixels; 0 if unknown
+	Thumbnail string // URL of an image standing for it, if any
+}
+
+type jsonFeed struct {
+	Version     string           `json:"version"`
+	Title       string           `json:"title"`
+	HomePageURL string           `json:"home_page_url,omitempty"`
+	FeedURL     string           `json:"feed_url,omitempty"`
+	Description string           `json:"description,omitempty"`
+	NextURL     string           `json:"next_url,omitempty"`
+	Authors     []jsonFeedAuthor `json:"authors,omitempty"`
+	Items       []jsonFeedItem   `json:"items"`
+}
+
+type jsonFeedAuthor struct {
+	Name string `json:"name,omitempty"`
+	URL  string `json:"url,omitempty"`
+}
+
+type jsonFeedItem struct {
+	ID            string               `json:"id"`
+	URL           string               `json:"url,omitempty"`
+	ExternalURL   string               `json:"external_url,omitempty"`
+	Title         string               `json:"title,omitempty"`
+	ContentHTML   string               `json:"content_html,omitempty"`
+	Summary       string               `json:"summary,omitempty"`
+	Image         string               `json:"image,omitempty"`
+	DatePublished string               `json:"date_published,omitempty"`
+	DateModified  string               `json:"date_modified,omitempty"`
+	Authors       []jsonFeedAuthor     `json:"authors,omitempty"`
+	Tags          []string             `json:"tags,omitempty"`
+	Attachments   []jsonFeedAttachment `json:"attachments,omitempty"`
+	Language      string               `json:"language,omitempty"`
+	Planet        *jsonFeedPlanet      `json:"_rogue_planet,omitempty"`
+}
+
+// jsonFeedPlanet is an extension object giving where an item is on the planet
+type jsonFeedPlanet struct {
+	URL string `json:"url"`
+}
+
+type jsonFeedAttachment struct {
+	URL               string `json:"url"`
+	MimeType          string `json:"mime_type"`
+	Title             string `json:"title,omitempty"`
+	SizeInBytes       int64  `json:"size_in_bytes,omitempty"`
+	DurationInSeconds int    `json:"duration_in_seconds,omitempty"`
+}
+
+// GenerateJSONFeed writes one page of a JSON Feed 1.1 document. Pages are
+// numbered from 1 and hold perPage entries each; perPage <= 0 puts every
+// entry on a single page. Every page except the last links to the next one
+// with next_url.
+func (g *Generator) GenerateJSONFeed(ctx context.Context, w io.Writer, data TemplateData, page, perPage int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	pages := pageCount(len(data.Entries), perPage)
+	if page < 1 || page > pages {
+		return fmt.Errorf("page %d out of range (1-%d)", page, pages)
+	}
+
+	entries := data.Entries
+	if perPage > 0 {
+		start := (page - 1) * perPage
+		end := start + perPage
+		if end > len(entries) {
+			end = len(entries)
+		}
+		entries = entries[start:end]
+	}
+
+	feed := jsonFeed{
+		Version:     jsonFeedVersion,
+		Title:       data.Title,
+		Description: data.Subtitle,
+		Items:       make([]jsonFeedItem, 0, len(entries)),
+	}
+	if data.Link != "" {
+		feed.HomePageURL = absoluteURL(data.Link, "")
+		feed.FeedURL = absoluteURL(data.Link, jsonFeedPageName(page))
+	}
+	if page < pages {
+		feed.NextURL = absoluteURL(data.Link, jsonFeedPageName(page+1))
+	}
+	if data.OwnerName != "" {
+		feed.Authors = []jsonFeedAuthor{{Name: data.OwnerName}}
+	}
+
+	for _, e := range entries {
+		item := jsonFeedItem{
+			ID:          entryID(e),
+			URL:         e.Link,
+			ExternalURL: e.ExternalURL,
+			Language:    e.Language,
+			Title:       html.UnescapeString(string(e.Title)), // JSON Feed titles are plain text
+			ContentHTML: feedHTML(data.Link, e.Content),
+			Summary:     feedHTML(data.Link, e.Summary),
+			Image:       e.Image,
+			Tags:        e.Categories,
+		}
+		if item.ContentHTML == "" {
+			item.ContentHTML = item.Summary
+		}
+		if href := planetURL(data, e); href != "" {
+			item.Planet = &jsonFeedPlanet{URL: href}
+		}
+		if !e.Published.IsZero() {
+			item.DatePublished = e.Published.Format(time.RFC3339)
+		}
+		if e.Updated.After(e.Published) {
+			item.DateModified = e.Updated.Format(time.RFC3339)
+		}
+		switch {
+		case len(e.Authors) > 0:
+			for _, name := range e.Authors {
+				item.Authors = append(item.Authors, jsonFeedAuthor{Name: name})
+			}
+		case e.Author != "":
+			item.Authors = []jsonFeedAuthor{{Name: e.Author}}
+		case e.FeedTitle != "":
+			// Attribute the entry to its source feed so readers can tell entries apart
+			item.Authors = []jsonFeedAuthor{{Name: e.FeedTitle, URL: e.FeedLink}}
+		}
+		for _, a := range e.Attachments {
+			if a.URL == "" || a.MimeType == "" {
+				continue // Both are required by the spec
+			}
+			item.Attachments = append(item.Attachments, jsonFeedAttachment{
+				URL:               a.URL,
+				MimeType:          a.MimeType,
+				Title:             a.Title,
+				SizeInBytes:       a.Size,
+				DurationInSeconds: a.Duration,
+			})
+		}
+		feed.Items = append(feed.Items, item)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(feed); err != nil {
+		return fmt.Errorf("encode JSON feed: %w", err)
+	}
+	return nil
+}
+
+// writeJSONFeed writes every page of the JSON Feed into outputDir and removes
+// pages left over from a previous run that had more entries
+func (g *Generator) writeJSONFeed(ctx context.Context, outputDir string, data TemplateData, perPage int) error {
+	pages := pageCount(len(data.Entries), perPage)
+	for page := 1; page <= pages; page++ {
+		path := filepath.Join(outputDir, jsonFeedPageName(page))
+		if err := writeFeedFile(path, func(w io.Writer) error {
+			return g.GenerateJSONFeed(ctx, w, data, page, perPage)
+		}); err != nil {
+			return err
+		}
+	}
+
+	for page := pages + 1; ; page++ {
+		err := os.Remove(filepath.Join(outputDir, jsonFeedPageName(page)))
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("remove stale page: %w", err)
+		}
+	}
+}
+
+func jsonFeedPageName(page int) string {
+	if page <= 1 {
+		return JSONFeedFileName
+	}
+	return fmt.Sprintf("feed-%d.json", page)
+}
@@ -0,0 +1,177 @@
+package generator
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/adewale/rogue_planet/pkg/normalizer"
+)
+
+func TestGenerateJSONFeed(t *testing.T) {
+	t.Parallel()
+	gen := newFeedTestGenerator(t)
+
+	data := feedTestData()
+	data.Entries[0].Image = "https://a.example.com/card.png"
+	data.Entries[0].Attachments = []Attachment{
+		{URL: "https://a.example.com/1.mp3", MimeType: "audio/mpeg", Size: 1024, Duration: 60},
+		{URL: "https://a.example.com/no-type"}, // Missing mime type is dropped
+	}
+	data.Entries[0].ExternalURL = "https://elsewhere.example.org/story"
+	data.Entries[0].Language = "fr-CA"
+	data.Entries[2].Authors = []string{"Ann", "Cy"}
+
+	var buf bytes.Buffer
+	if err := gen.GenerateJSONFeed(context.Background(), &buf, data, 1, 0); err != nil {
+		t.Fatalf("GenerateJSONFeed() error = %v", err)
+	}
+
+	var feed jsonFeed
+	if err := json.Unmarshal(buf.Bytes(), &feed); err != nil {
+		t.Fatalf("output is not valid JSON: %v\n%s", err, buf.String())
+	}
+
+	if feed.Version != "https://jsonfeed.org/version/1.1" {
+		t.Errorf("version = %q", feed.Version)
+	}
+	if feed.HomePageURL != "https://planet.example.com/" || feed.FeedURL != "https://planet.example.com/feed.json" {
+		t.Errorf("home_page_url/feed_url = %q/%q", feed.HomePageURL, feed.FeedURL)
+	}
+	if feed.NextURL != "" {
+		t.Errorf("single page should not have next_url, got %q", feed.NextURL)
+	}
+	if len(feed.Authors) != 1 || feed.Authors[0].Name != "Owner" {
+		t.Errorf("feed authors = %+v", feed.Authors)
+	}
+	if len(feed.Items) != 3 {
+		t.Fatalf("got %d items, want 3", len(feed.Items))
+	}
+
+	first := feed.Items[0]
+	if first.ID != "tag:a.example.com,2025:1" || first.Title != "Ben & Jerry" {
+		t.Errorf("first item id/title = %q/%q", first.ID, first.Title)
+	}
+	if first.DatePublished != "2025-03-10T11:00:00Z" || first.DateModified != "2025-03-10T12:00:00Z" {
+		t.Errorf("first item dates = %q/%q", first.DatePublished, first.DateModified)
+	}
+	if first.Image != "https://a.example.com/card.png" {
+		t.Errorf("first item image = %q", first.Image)
+	}
+	if len(first.Authors) != 1 || first.Authors[0].Name != "Alice" {
+		t.Errorf("first item authors = %+v", first.Authors)
+	}
+	if len(first.Attachments) != 1 || first.Attachments[0].SizeInBytes != 1024 || first.Attachments[0].DurationInSeconds != 60 {
+		t.Errorf("first item attachments = %+v", first.Attachments)
+	}
+	if first.ExternalURL != "https://elsewhere.example.org/story" || first.Language != "fr-CA" {
+		t.Errorf("first item external_url/language = %q/%q", first.ExternalURL, first.Language)
+	}
+	if third := feed.Items[2]; len(third.Authors) != 2 || third.Authors[0].Name != "Ann" || third.Authors[1].Name != "Cy" {
+		t.Errorf("third item authors = %+v, want Ann and Cy", third.Authors)
+	}
+
+	// Items without an ID or author fall back to the link and source feed
+	second := feed.Items[1]
+	if second.ID != "https://b.example.com/2" {
+		t.Errorf("second item id = %q, want link", second.ID)
+	}
+	if len(second.Authors) != 1 || second.Authors[0].Name != "Bob" {
+		t.Errorf("second item authors = %+v, want source feed", second.Authors)
+	}
+	if second.ContentHTML != "<p>Summary</p>" {
+		t.Errorf("second item content_html = %q, want summary fallback", second.ContentHTML)
+	}
+}
+
+func TestGenerateJSONFeedPagination(t *testing.T) {
+	t.Parallel()
+	gen := newFeedTestGenerator(t)
+	data := feedTestData()
+
+	pages := []struct {
+		page     int
+		wantNext string
+		wantIDs  []string
+	}{
+		{1, "https://planet.example.com/feed-2.json", []string{"tag:a.example.com,2025:1", "https://b.example.com/2"}},
+		{2, "", []string{"https://b.example.com/3"}},
+	}
+
+	for _, p := range pages {
+		var buf bytes.Buffer
+		if err := gen.GenerateJSONFeed(context.Background(), &buf, data, p.page, 2); err != nil {
+			t.Fatalf("GenerateJSONFeed(page %d) error = %v", p.page, err)
+		}
+		var feed jsonFeed
+		if err := json.Unmarshal(buf.Bytes(), &feed); err != nil {
+			t.Fatal(err)
+		}
+		if feed.NextURL != p.wantNext {
+			t.Errorf("page %d next_url = %q, want %q", p.page, feed.NextURL, p.wantNext)
+		}
+		if len(feed.Items) != len(p.wantIDs) {
+			t.Fatalf("page %d has %d items, want %d", p.page, len(feed.Items), len(p.wantIDs))
+		}
+		for i, id := range p.wantIDs {
+			if feed.Items[i].ID != id {
+				t.Errorf("page %d item %d id = %q, want %q", p.page, i, feed.Items[i].ID, id)
+			}
+		}
+	}
+
+	if err := gen.GenerateJSONFeed(context.Background(), &bytes.Buffer{}, data, 3, 2); err == nil {
+		t.Error("expected error for out-of-range page")
+	}
+}
+
+func TestWriteFeedsJSONFeedPages(t *testing.T) {
+	t.Parallel()
+	gen := newFeedTestGenerator(t)
+	outputDir := t.TempDir()
+	ctx := context.Background()
+
+	opts := FeedOptions{MaxEntries: 1, JSONFeed: true}
+	if err := gen.WriteFeeds(ctx, outputDir, feedTestData(), opts); err != nil {
+		t.Fatalf("WriteFeeds() error = %v", err)
+	}
+	for _, name := range []string{"feed.json", "feed-2.json", "feed-3.json"} {
+		if _, err := os.Stat(filepath.Join(outputDir, name)); err != nil {
+			t.Errorf("%s not written: %v", name, err)
+		}
+	}
+
+	// Fewer entries on the next run removes stale pages
+	opts.MaxEntries = 2
+	if err := gen.WriteFeeds(ctx, outputDir, feedTestData(), opts); err != nil {
+		t.Fatalf("WriteFeeds() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "feed-3.json")); !os.IsNotExist(err) {
+		t.Errorf("stale feed-3.json should be removed, stat error = %v", err)
+	}
+}
+
+func TestGenerateJSONFeedParsesAsFeed(t *testing.T) {
+	t.Parallel()
+	gen := newFeedTestGenerator(t)
+
+	var buf bytes.Buffer
+	if err := gen.GenerateJSONFeed(context.Background(), &buf, feedTestData(), 1, 0); err != nil {
+		t.Fatalf("GenerateJSONFeed() error = %v", err)
+	}
+
+	meta, entries, err := normalizer.New().Parse(context.Background(), buf.Bytes(), "https://planet.example.com/feed.json", time.Now())
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if meta.Title != "Test Planet" {
+		t.Errorf("parsed title = %q", meta.Title)
+	}
+	if len(entries) != 3 {
+		t.Errorf("parsed %d entries, want 3", len(entries))
+	}
+}
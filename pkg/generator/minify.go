@@ -0,0 +1,375 @@
+package generator
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// fingerprintLen is the number of hex digits of the content hash in a
+// fingerprinted asset's name, as in style.0123abcd.css
+const fingerprintLen = 8
+
+// blockElements are the elements whitespace next to which is never rendered
+var blockElements = map[string]bool{
+	"address": true, "article": true, "aside": true, "base": true, "blockquote": true,
+	"body": true, "br": true, "caption": true, "dd": true, "details": true, "div": true,
+	"dl": true, "dt": true, "fieldset": true, "figcaption": true, "figure": true,
+	"footer": true, "form": true, "h1": true, "h2": true, "h3": true, "h4": true,
+	"h5": true, "h6": true, "head": true, "header": true, "hr": true, "html": true,
+	"legend": true, "li": true, "link": true, "main": true, "meta": true, "nav": true,
+	"ol": true, "p": true, "pre": true, "section": true, "source": true,
+	"summary": true, "table": true, "tbody": true, "td": true, "tfoot": true,
+	"th": true, "thead": true, "title": true, "tr": true, "track": true, "ul": true,
+}
+
+// Minify shrinks the generated pages in outputDir, given by their paths
+// relative to it. The theme's style sheets and scripts, and the built-in
+// template's script, are first copied to fingerprinted names (style.css to
+// style.0123abcd.css, minifying style sheets), so they can be cached
+// indefinitely, and the pages' href and src attributes are rewritten to
+// point at the copies. Whitespace between elements is removed and runs of
+// it collapsed, except in pre and textarea. Inline style elements are
+// minified unless the page's Content-Security-Policy allows them by hash.
+func (g *Generator) Minify(ctx context.Context, outputDir string, pages []SitePage) error {
+	assets, err := g.fingerprintAssets(ctx, outputDir)
+	if err != nil {
+		return err
+	}
+
+	styles := g.assets == nil || parseCSP(g.assets.csp).has("style-src", "'unsafe-inline'")
+	for _, p := range pages {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		full := filepath.Join(outputDir, filepath.FromSlash(p.Path))
+		page, err := os.ReadFile(full)
+		if err != nil {
+			return fmt.Errorf("read %s: %w", p.Path, err)
+		}
+		if err := os.WriteFile(full, minifyHTML(page, styles, assets), 0644); err != nil {
+			return fmt.Errorf("write %s: %w", p.Path, err)
+		}
+	}
+	return nil
+}
+
+// fingerprintAssets copies the style sheets and scripts used by the pages
+// to fingerprinted names, returning each one's new path keyed by its old
+// path, both relative to outputDir with forward slashes
+func (g *Generator) fingerprintAssets(ctx context.Context, outputDir string) (map[string]string, error) {
+	var srcs []string
+	if g.builtinMain {
+		srcs = append(srcs, ScriptFileName)
+	}
+	if g.themeDir != "" {
+		staticSrc := filepath.Join(g.themeDir, "static")
+		err := filepath.WalkDir(staticSrc, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) && p == staticSrc {
+					return fs.SkipDir
+				}
+				return err
+			}
+			if d.IsDir() {
+				return nil
+			}
+			if ext := strings.ToLower(filepath.Ext(p)); ext == ".css" || ext == ".js" {
+				rel, err := filepath.Rel(g.themeDir, p)
+				if err != nil {
+					return err
+				}
+				srcs = append(srcs, filepath.ToSlash(rel))
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("list theme assets: %w", err)
+		}
+	}
+
+	assets := make(map[string]string, len(srcs))
+	for _, src := range srcs {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		full := filepath.Join(outputDir, filepath.FromSlash(src))
+		data, err := os.ReadFile(full)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", src, err)
+		}
+		ext := path.Ext(src)
+		if strings.EqualFold(ext, ".css") {
+			data = []byte(MinifyCSS(string(data)))
+		}
+		sum := sha256.Sum256(data)
+		stem := strings.TrimSuffix(src, ext)
+		name := stem + "." + hex.EncodeToString(sum[:])[:fingerprintLen] + ext
+
+		if err := removeStaleFingerprints(filepath.Join(outputDir, filepath.FromSlash(stem)), ext); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(filepath.Join(outputDir, filepath.FromSlash(name)), data, 0644); err != nil {
+			return nil, fmt.Errorf("write %s: %w", name, err)
+		}
+		assets[src] = name
+	}
+	return assets, nil
+}
+
+// removeStaleFingerprints removes the fingerprinted copies of stem+ext left
+// by earlier runs
+func removeStaleFingerprints(stem, ext string) error {
+	matches, err := filepath.Glob(globEscape(stem) + ".*" + ext)
+	if err != nil {
+		return fmt.Errorf("find stale assets: %w", err)
+	}
+	for _, m := range matches {
+		hash := strings.TrimSuffix(strings.TrimPrefix(m, stem+"."), ext)
+		if !isFingerprint(hash) {
+			continue
+		}
+		if err := os.Remove(m); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("remove stale asset: %w", err)
+		}
+	}
+	return nil
+}
+
+// globEscape escapes the filepath.Match metacharacters in s
+func globEscape(s string) string {
+	if filepath.Separator == '\\' {
+		// Backslash separates paths on Windows, so can't escape there
+		return s
+	}
+	return strings.NewReplacer(`\`, `\\`, "*", `\*`, "?", `\?`, "[", `\[`).Replace(s)
+}
+
+// isFingerprint reports whether s is a content hash as used in asset names
+func isFingerprint(s string) bool {
+	if len(s) != fingerprintLen {
+		return false
+	}
+	for _, c := range s {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// minifyHTML removes whitespace that is not rendered from an HTML page,
+// minifies its style elements if styles is set, and rewrites href and src
+// attributes naming a key of assets to its value
+func minifyHTML(page []byte, styles bool, assets map[string]string) []byte {
+	z := html.NewTokenizer(bytes.NewReader(page))
+	var out bytes.Buffer
+	out.Grow(len(page))
+
+	var (
+		rawTag     string // Element whose text is copied as is: script, style, textarea, title
+		preDepth   int    // Open pre elements, inside which whitespace is kept
+		afterBlock = true // The previous token makes following whitespace invisible
+		space      bool   // Whitespace was seen and not yet written
+	)
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			if z.Err() == io.EOF {
+				return out.Bytes()
+			}
+			// The tokenizer only fails reading, which bytes.Reader doesn't
+			return page
+		}
+
+		raw := bytes.Clone(z.Raw())
+		switch tt {
+		case html.TextToken:
+			if rawTag != "" || preDepth > 0 {
+				if rawTag == "style" && styles {
+					raw = []byte(MinifyCSS(string(raw)))
+				}
+				out.Write(raw)
+				afterBlock = false
+				continue
+			}
+			// Only HTML whitespace: a no-break space is text
+			text := bytes.FieldsFunc(raw, func(r rune) bool { return r < 0x80 && isHTMLSpace(byte(r)) })
+			if len(text) == 0 {
+				space = true
+				continue
+			}
+			if (space || isHTMLSpace(raw[0])) && !afterBlock {
+				out.WriteByte(' ')
+			}
+			out.Write(bytes.Join(text, []byte(" ")))
+			space = isHTMLSpace(raw[len(raw)-1])
+			afterBlock = false
+
+		case html.StartTagToken, html.EndTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			tag := string(name)
+			block := blockElements[tag]
+			if space && !block && !afterBlock {
+				out.WriteByte(' ')
+			}
+			space = false
+			afterBlock = block
+
+			switch {
+			case tt == html.StartTagToken && (tag == "pre" || tag == "textarea"):
+				preDepth++
+			case tt == html.EndTagToken && (tag == "pre" || tag == "textarea") && preDepth > 0:
+				preDepth--
+			}
+			switch {
+			case tt == html.StartTagToken && (tag == "script" || tag == "style" || tag == "title"):
+				rawTag = tag
+			case tt == html.EndTagToken && tag == rawTag:
+				rawTag = ""
+			}
+
+			if tt != html.EndTagToken && hasAttr && len(assets) > 0 {
+				raw = rewriteAssetRefs(z, raw, tag, tt == html.SelfClosingTagToken, assets)
+			}
+			out.Write(raw)
+
+		default:
+			// Comments and the doctype
+			space = false
+			afterBlock = true
+			out.Write(raw)
+		}
+	}
+}
+
+// rewriteAssetRefs returns the current tag with its href and src attributes
+// rewritten through assets, or raw if none change
+func rewriteAssetRefs(z *html.Tokenizer, raw []byte, tag string, selfClosing bool, assets map[string]string) []byte {
+	type attr struct{ key, val string }
+	var attrs []attr
+	changed := false
+	for more := true; more; {
+		var k, v []byte
+		k, v, more = z.TagAttr()
+		a := attr{string(k), string(v)}
+		if a.key == "href" || a.key == "src" {
+			if ref, ok := assetRef(a.val, assets); ok {
+				a.val = ref
+				changed = true
+			}
+		}
+		attrs = append(attrs, a)
+	}
+	if !changed {
+		return raw
+	}
+
+	var b bytes.Buffer
+	b.WriteString("<" + tag)
+	for _, a := range attrs {
+		b.WriteString(" " + a.key)
+		if a.val != "" {
+			b.WriteString(`="` + html.EscapeString(a.val) + `"`)
+		}
+	}
+	if selfClosing {
+		b.WriteString("/")
+	}
+	b.WriteString(">")
+	return b.Bytes()
+}
+
+// assetRef returns ref with its path replaced by the fingerprinted one, if
+// it names one of assets relative to the site root
+func assetRef(ref string, assets map[string]string) (string, bool) {
+	p, suffix := ref, ""
+	if i := strings.IndexAny(ref, "?#"); i >= 0 {
+		p, suffix = ref[:i], ref[i:]
+	}
+	if p == "" || strings.Contains(p, ":") || strings.HasPrefix(p, "//") {
+		return "", false
+	}
+	prefix := ""
+	if strings.HasPrefix(p, "/") {
+		prefix = "/"
+	}
+	name, ok := assets[path.Clean(strings.TrimPrefix(p, "/"))]
+	if !ok {
+		return "", false
+	}
+	return prefix + name + suffix, true
+}
+
+// isHTMLSpace reports whether c is HTML whitespace
+func isHTMLSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\f' || c == '\r'
+}
+
+// MinifyCSS removes comments and unneeded whitespace from a style sheet.
+// Strings and escapes are kept as they are.
+func MinifyCSS(css string) string {
+	var b strings.Builder
+	b.Grow(len(css))
+	space := false
+	for i := 0; i < len(css); i++ {
+		c := css[i]
+		switch {
+		case c == '/' && i+1 < len(css) && css[i+1] == '*':
+			end := strings.Index(css[i+2:], "*/")
+			if end < 0 {
+				i = len(css)
+			} else {
+				i += end + 3
+			}
+			space = true
+			continue
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f':
+			space = true
+			continue
+		}
+
+		if space && b.Len() > 0 {
+			last := b.String()[b.Len()-1]
+			// A space before ':' is kept: ".a :hover" differs from ".a:hover"
+			if !strings.ContainsRune("{};:,>(", rune(last)) && !strings.ContainsRune("{};,>)", rune(c)) {
+				b.WriteByte(' ')
+			}
+		}
+		space = false
+
+		switch c {
+		case '"', '\'':
+			end := i + 1
+			for end < len(css) && css[end] != c && css[end] != '\n' {
+				if css[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			end = min(end+1, len(css))
+			b.WriteString(css[i:end])
+			i = end - 1
+		case '\\':
+			end := min(i+2, len(css))
+			b.WriteString(css[i:end])
+			i = end - 1
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,227 @@
+package generator
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestMinifyCSS(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name, in, want string
+	}{
+		{"whitespace", "a {\n    color: red;\n}\n", "a{color:red;}"},
+		{"comments", "/* theme */ a { color: red } /* end */", "a{color:red}"},
+		{"descendant pseudo-class keeps its space", ".a :hover { x: y }", ".a :hover{x:y}"},
+		{"selector lists and children", "h1 ,  h2 > a { x: y }", "h1,h2>a{x:y}"},
+		{"media query", "@media (max-width: 768px) and (min-width: 1px) { a { x: y } }", "@media (max-width:768px) and (min-width:1px){a{x:y}}"},
+		{"values keep needed spaces", "a { margin: 0 auto; width: calc(100% - 2px) }", "a{margin:0 auto;width:calc(100% - 2px)}"},
+		{"strings", `a::before { content: "  /* not a comment */  " }`, `a::before{content:"  /* not a comment */  "}`},
+		{"escapes", `.a\:b { x: y }`, `.a\:b{x:y}`},
+		{"already minified", `@font-face{font-family:"Inter";src:url("a.woff2") format("woff2");font-display:swap;}`, `@font-face{font-family:"Inter";src:url("a.woff2") format("woff2");font-display:swap;}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := MinifyCSS(tt.in); got != tt.want {
+				t.Errorf("MinifyCSS(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMinifyHTML(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name, in, want string
+	}{
+		{
+			name: "whitespace between blocks",
+			in:   "<!DOCTYPE html>\n<html>\n  <body>\n    <div>\n      <p>Hello</p>\n    </div>\n  </body>\n</html>\n",
+			want: "<!DOCTYPE html><html><body><div><p>Hello</p></div></body></html>",
+		},
+		{
+			name: "inline whitespace collapses to one space",
+			in:   "<p>\n  By   <a href=\"x\">Ann</a> &middot;\n  <time>today</time>\n</p>",
+			want: "<p>By <a href=\"x\">Ann</a> &middot; <time>today</time></p>",
+		},
+		{
+			name: "space between inline elements",
+			in:   "<p><em>one</em>\n<em>two</em></p>",
+			want: "<p><em>one</em> <em>two</em></p>",
+		},
+		{
+			name: "pre keeps its whitespace",
+			in:   "<div>\n<pre>  a\n    <b>b</b>  c</pre>\n</div>",
+			want: "<div><pre>  a\n    <b>b</b>  c</pre></div>",
+		},
+		{
+			name: "no-break space is text",
+			in:   "<p>a  b</p>",
+			want: "<p>a  b</p>",
+		},
+		{
+			name: "style minified",
+			in:   "<head>\n<style>\n  a {\n    color: red;\n  }\n</style>\n</head>",
+			want: "<head><style>a{color:red;}</style></head>",
+		},
+		{
+			name: "script kept",
+			in:   "<script>\n  var a  = 1;\n</script>",
+			want: "<script>\n  var a  = 1;\n</script>",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := string(minifyHTML([]byte(tt.in), true, nil)); got != tt.want {
+				t.Errorf("minifyHTML() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// Inline styles allowed by hash must not change
+	in := "<style>a { color: red }</style>"
+	if got := string(minifyHTML([]byte(in), false, nil)); got != in {
+		t.Errorf("minifyHTML() without styles = %q, want %q", got, in)
+	}
+}
+
+func TestAssetRef(t *testing.T) {
+	t.Parallel()
+	assets := map[string]string{"static/style.css": "static/style.0123abcd.css"}
+	tests := []struct {
+		ref, want string
+		ok        bool
+	}{
+		{"static/style.css", "static/style.0123abcd.css", true},
+		{"./static/style.css", "static/style.0123abcd.css", true},
+		{"/static/style.css?v=2", "/static/style.0123abcd.css?v=2", true},
+		{"static/other.css", "", false},
+		{"https://cdn.example.com/static/style.css", "", false},
+		{"//cdn.example.com/static/style.css", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := assetRef(tt.ref, assets)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("assetRef(%q) = %q, %v, want %q, %v", tt.ref, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestGeneratorMinify(t *testing.T) {
+	t.Parallel()
+
+	themeDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(themeDir, "static"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		ThemeTemplateName: `<!DOCTYPE html>
+<html>
+<head>
+    <link rel="stylesheet" href="static/style.css">
+    <script src="static/app.js" defer></script>
+</head>
+<body>
+    <h1>{{.Title}}</h1>
+</body>
+</html>
+`,
+		"static/style.css": "body {\n    color: #333;\n}\n",
+		"static/app.js":    "console.log(1);\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(themeDir, filepath.FromSlash(name)), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	gen, err := NewWithTemplate(themeDir)
+	if err != nil {
+		t.Fatalf("NewWithTemplate() error = %v", err)
+	}
+	outputDir := t.TempDir()
+	data := TemplateData{Title: "Minified Planet"}
+	for run := 0; run < 2; run++ {
+		if _, err := gen.GeneratePages(context.Background(), outputDir, data, 0); err != nil {
+			t.Fatalf("GeneratePages() error = %v", err)
+		}
+		if err := gen.Minify(context.Background(), outputDir, RiverPages(data, 0)); err != nil {
+			t.Fatalf("Minify() error = %v", err)
+		}
+	}
+
+	page, err := os.ReadFile(filepath.Join(outputDir, IndexFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	html := string(page)
+	if strings.Contains(html, "\n    ") {
+		t.Errorf("page should be minified:\n%s", html)
+	}
+	if !strings.Contains(html, "<h1>Minified Planet</h1>") {
+		t.Errorf("page lost its content:\n%s", html)
+	}
+
+	css := regexp.MustCompile(`href="(static/style\.[0-9a-f]{8}\.css)"`).FindStringSubmatch(html)
+	js := regexp.MustCompile(`src="(static/app\.[0-9a-f]{8}\.js)"`).FindStringSubmatch(html)
+	if css == nil || js == nil {
+		t.Fatalf("page should reference fingerprinted assets:\n%s", html)
+	}
+	got, err := os.ReadFile(filepath.Join(outputDir, filepath.FromSlash(css[1])))
+	if err != nil {
+		t.Fatalf("fingerprinted style sheet should exist: %v", err)
+	}
+	if string(got) != "body{color:#333;}" {
+		t.Errorf("fingerprinted style sheet = %q, want it minified", got)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, filepath.FromSlash(js[1]))); err != nil {
+		t.Errorf("fingerprinted script should exist: %v", err)
+	}
+
+	// A second run replaces, rather than adds to, the fingerprinted copies
+	matches, _ := filepath.Glob(filepath.Join(outputDir, "static", "style.*.css"))
+	if len(matches) != 1 {
+		t.Errorf("found %d fingerprinted style sheets, want 1", len(matches))
+	}
+}
+
+func TestGeneratorMinifyDefaultTemplate(t *testing.T) {
+	t.Parallel()
+
+	gen, _ := New()
+	outputDir := t.TempDir()
+	data := TemplateData{Title: "Planet", Entries: []EntryData{{Title: "Post", Content: "<p>Some   text</p>"}}}
+	if _, err := gen.GeneratePages(context.Background(), outputDir, data, 0); err != nil {
+		t.Fatalf("GeneratePages() error = %v", err)
+	}
+	before, _ := os.ReadFile(filepath.Join(outputDir, IndexFileName))
+
+	// A stale copy of the script from an earlier version is removed
+	stale := filepath.Join(outputDir, "planet.00000000.js")
+	if err := os.WriteFile(stale, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := gen.Minify(context.Background(), outputDir, RiverPages(data, 0)); err != nil {
+		t.Fatalf("Minify() error = %v", err)
+	}
+	after, _ := os.ReadFile(filepath.Join(outputDir, IndexFileName))
+
+	if len(after) >= len(before)*3/4 {
+		t.Errorf("minified page is %d bytes, want well under the original %d", len(after), len(before))
+	}
+	if !strings.Contains(string(after), ":root{color-scheme:light dark;") {
+		t.Error("inline styles of the built-in template should be minified")
+	}
+	if !regexp.MustCompile(`<script src="planet\.[0-9a-f]{8}\.js" defer>`).Match(after) {
+		t.Error("page should reference the fingerprinted script")
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Error("stale fingerprinted script should be removed")
+	}
+}
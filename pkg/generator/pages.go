@@ -0,0 +1,93 @@
+package generator
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// IndexFileName is the first page of the generated river
+const IndexFileName = "index.html"
+
+// PageFileName returns the output file name for a 1-based page number:
+// index.html for the first page, then page2.html, page3.html, ...
+func PageFileName(page int) string {
+	if page <= 1 {
+		return IndexFileName
+	}
+	return fmt.Sprintf("page%d.html", page)
+}
+
+// GeneratePages renders the river into outputDir, perPage entries per page.
+// Each page links to its neighbours through PrevURL and NextURL, and pages
+// left over from an earlier run with more entries are removed. A perPage of
+// zero writes every entry to index.html. It returns the number of pages written.
+func (g *Generator) GeneratePages(ctx context.Context, outputDir string, data TemplateData, perPage int) (int, error) {
+	pages := pageCount(len(data.Entries), perPage)
+	for page := 1; page <= pages; page++ {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+
+		pageData := paginate(data, page, pages, perPage)
+		path := filepath.Join(outputDir, PageFileName(page))
+
+		// The first page also writes build info and theme assets
+		if page == 1 {
+			if err := g.GenerateToFile(ctx, path, pageData); err != nil {
+				return 0, err
+			}
+			continue
+		}
+		if err := writeFeedFile(path, func(w io.Writer) error {
+			return g.Generate(ctx, w, pageData)
+		}); err != nil {
+			return 0, fmt.Errorf("write %s: %w", PageFileName(page), err)
+		}
+	}
+
+	for page := pages + 1; ; page++ {
+		err := os.Remove(filepath.Join(outputDir, PageFileName(page)))
+		if os.IsNotExist(err) {
+			return pages, nil
+		}
+		if err != nil {
+			return 0, fmt.Errorf("remove stale page: %w", err)
+		}
+	}
+}
+
+// paginate returns a copy of data holding only the entries for one page,
+// with the navigation fields filled in
+func paginate(data TemplateData, page, pages, perPage int) TemplateData {
+	data.Page = page
+	data.TotalPages = pages
+	data.PrevURL = ""
+	data.NextURL = ""
+	if page > 1 {
+		data.PrevURL = PageFileName(page - 1)
+	}
+	if page < pages {
+		data.NextURL = PageFileName(page + 1)
+	}
+
+	if perPage > 0 {
+		start := (page - 1) * perPage
+		end := start + perPage
+		if end > len(data.Entries) {
+			end = len(data.Entries)
+		}
+		data.Entries = data.Entries[start:end]
+	}
+	return data
+}
+
+// pageCount returns how many pages of perPage entries are needed (at least one)
+func pageCount(entries, perPage int) int {
+	if perPage <= 0 || entries <= perPage {
+		return 1
+	}
+	return (entries + perPage - 1) / perPage
+}
@@ -0,0 +1,101 @@
+package generator
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPageFileName(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		page int
+		want string
+	}{
+		{0, "index.html"},
+		{1, "index.html"},
+		{2, "page2.html"},
+		{10, "page10.html"},
+	}
+	for _, tt := range tests {
+		if got := PageFileName(tt.page); got != tt.want {
+			t.Errorf("PageFileName(%d) = %q, want %q", tt.page, got, tt.want)
+		}
+	}
+}
+
+func TestGeneratePages(t *testing.T) {
+	t.Parallel()
+	gen := newFeedTestGenerator(t)
+	outputDir := t.TempDir()
+	ctx := context.Background()
+
+	pages, err := gen.GeneratePages(ctx, outputDir, feedTestData(), 2)
+	if err != nil {
+		t.Fatalf("GeneratePages() error = %v", err)
+	}
+	if pages != 2 {
+		t.Fatalf("GeneratePages() = %d pages, want 2", pages)
+	}
+
+	index := readOutput(t, outputDir, "index.html")
+	page2 := readOutput(t, outputDir, "page2.html")
+
+	checks := []struct {
+		name    string
+		html    string
+		want    []string
+		notWant []string
+	}{
+		{
+			name:    "first page",
+			html:    index,
+			want:    []string{"https://a.example.com/1", "https://b.example.com/2", `href="page2.html" rel="next"`, "Page 1 of 2"},
+			notWant: []string{"https://b.example.com/3", `rel="prev"`},
+		},
+		{
+			name:    "last page",
+			html:    page2,
+			want:    []string{"https://b.example.com/3", `href="index.html" rel="prev"`, "Page 2 of 2"},
+			notWant: []string{"https://a.example.com/1", `rel="next"`},
+		},
+	}
+	for _, c := range checks {
+		for _, s := range c.want {
+			if !strings.Contains(c.html, s) {
+				t.Errorf("%s: missing %q", c.name, s)
+			}
+		}
+		for _, s := range c.notWant {
+			if strings.Contains(c.html, s) {
+				t.Errorf("%s: unexpected %q", c.name, s)
+			}
+		}
+	}
+
+	// A larger page size on the next run removes stale pages
+	pages, err = gen.GeneratePages(ctx, outputDir, feedTestData(), 0)
+	if err != nil {
+		t.Fatalf("GeneratePages() error = %v", err)
+	}
+	if pages != 1 {
+		t.Errorf("GeneratePages() = %d pages, want 1", pages)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "page2.html")); !os.IsNotExist(err) {
+		t.Errorf("stale page2.html should be removed, stat error = %v", err)
+	}
+	if index := readOutput(t, outputDir, "index.html"); strings.Contains(index, `class="pagination"`) {
+		t.Error("single page should not render pagination links")
+	}
+}
+
+func readOutput(t *testing.T, dir, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		t.Fatalf("read %s: %v", name, err)
+	}
+	return string(data)
+}
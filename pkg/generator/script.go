@@ -0,0 +1,84 @@
+package generator
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ScriptFileName is the built-in template's script, written to the output
+// directory alongside index.html. The default policy only allows scripts
+// from the site itself, so it cannot be inlined.
+const ScriptFileName = "planet.js"
+
+// defaultScript moves between entries with the keyboard: j for the next
+// entry, k for the previous one
+const defaultScript = `// Keyboard navigation between entries: j for the next, k for the previous
+(function () {
+    "use strict";
+
+    var reduceMotion = window.matchMedia &&
+        window.matchMedia("(prefers-reduced-motion: reduce)").matches;
+
+    function typing(target) {
+        if (!target || !target.tagName) {
+            return false;
+        }
+        var tag = target.tagName.toLowerCase();
+        return tag === "input" || tag === "textarea" || tag === "select" || target.isContentEditable;
+    }
+
+    // target returns the entry to move to, starting from the focused entry
+    // or, when none has focus, from the top of the viewport
+    function target(entries, step) {
+        var active = document.activeElement;
+        for (var i = 0; i < entries.length; i++) {
+            if (entries[i] === active || entries[i].contains(active)) {
+                return entries[i + step];
+            }
+        }
+        if (step > 0) {
+            for (var j = 0; j < entries.length; j++) {
+                if (entries[j].getBoundingClientRect().top > 1) {
+                    return entries[j];
+                }
+            }
+            return undefined;
+        }
+        for (var k = entries.length - 1; k >= 0; k--) {
+            if (entries[k].getBoundingClientRect().top < -1) {
+                return entries[k];
+            }
+        }
+        return undefined;
+    }
+
+    document.addEventListener("keydown", function (event) {
+        if (event.defaultPrevented || event.ctrlKey || event.altKey || event.metaKey || typing(event.target)) {
+            return;
+        }
+        var step = event.key === "j" ? 1 : event.key === "k" ? -1 : 0;
+        if (step === 0) {
+            return;
+        }
+        var entry = target(document.querySelectorAll("article.entry"), step);
+        if (!entry) {
+            return;
+        }
+        event.preventDefault();
+        if (!entry.hasAttribute("tabindex")) {
+            entry.setAttribute("tabindex", "-1");
+        }
+        entry.focus({preventScroll: true});
+        entry.scrollIntoView({behavior: reduceMotion ? "auto" : "smooth", block: "start"});
+    });
+})();
+`
+
+// writeScript writes the built-in template's script to outputDir
+func writeScript(outputDir string) error {
+	if err := os.WriteFile(filepath.Join(outputDir, ScriptFileName), []byte(defaultScript), 0644); err != nil {
+		return fmt.Errorf("write %s: %w", ScriptFileName, err)
+	}
+	return nil
+}
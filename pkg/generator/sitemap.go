@@ -0,0 +1,180 @@
+package generator
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Discovery files, written alongside index.html
+const (
+	SitemapFileName = "sitemap.xml"
+	RobotsFileName  = "robots.txt"
+	LLMsFileName    = "llms.txt"
+)
+
+// SitePage is an HTML page of the generated site, listed in sitemap.xml
+type SitePage struct {
+	Path     string    // Relative to the output directory, e.g. "page2.html"
+	Modified time.Time // Newest entry on the page; zero if unknown
+}
+
+// SiteFileOptions controls which discovery files WriteSiteFiles writes
+type SiteFileOptions struct {
+	Sitemap    bool       // sitemap.xml, and a robots.txt pointing to it unless the site has its own
+	LLMsTxt    bool       // llms.txt, a Markdown overview of the planet for language models
+	Pages      []SitePage // Pages listed in sitemap.xml, index first
+	MaxEntries int        // Recent entries listed in llms.txt; 0 means all entries
+}
+
+// robotsTxtPrefix starts the robots.txt WriteSiteFiles writes
+const robotsTxtPrefix = "User-agent: *\nAllow: /\n\nSitemap: "
+
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+// RiverPages lists the pages GeneratePages writes for data with perPage
+// entries per page, each dated by its newest entry
+func RiverPages(data TemplateData, perPage int) []SitePage {
+	pages := pageCount(len(data.Entries), perPage)
+	list := make([]SitePage, 0, pages)
+	for page := 1; page <= pages; page++ {
+		p := SitePage{Path: PageFileName(page)}
+		for _, e := range paginate(data, page, pages, perPage).Entries {
+			if t := entryUpdated(e); t.After(p.Modified) {
+				p.Modified = t
+			}
+		}
+		list = append(list, p)
+	}
+	return list
+}
+
+// WriteSiteFiles writes the files that help search engines and other
+// crawlers find their way around the planet. URLs in them are absolute, so
+// data.Link must be set.
+func (g *Generator) WriteSiteFiles(ctx context.Context, outputDir string, data TemplateData, opts SiteFileOptions) error {
+	if !opts.Sitemap && !opts.LLMsTxt {
+		return nil
+	}
+	if data.Link == "" {
+		return fmt.Errorf("sitemap.xml and llms.txt need the planet's link")
+	}
+
+	if opts.Sitemap {
+		if err := writeFeedFile(filepath.Join(outputDir, SitemapFileName), func(w io.Writer) error {
+			return GenerateSitemap(w, data.Link, opts.Pages)
+		}); err != nil {
+			return fmt.Errorf("write sitemap: %w", err)
+		}
+
+		// A robots.txt from the theme or put there by hand wins; one written
+		// by an earlier run is rewritten in case the link changed
+		robots := filepath.Join(outputDir, RobotsFileName)
+		existing, err := os.ReadFile(robots)
+		if os.IsNotExist(err) || strings.HasPrefix(string(existing), robotsTxtPrefix) {
+			body := robotsTxtPrefix + absoluteURL(data.Link, SitemapFileName) + "\n"
+			if err := os.WriteFile(robots, []byte(body), 0644); err != nil {
+				return fmt.Errorf("write robots.txt: %w", err)
+			}
+		}
+	}
+
+	if opts.LLMsTxt {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := writeFeedFile(filepath.Join(outputDir, LLMsFileName), func(w io.Writer) error {
+			return GenerateLLMsTxt(w, data, opts.MaxEntries)
+		}); err != nil {
+			return fmt.Errorf("write llms.txt: %w", err)
+		}
+	}
+	return nil
+}
+
+// GenerateSitemap writes a sitemap of pages, resolved against base
+func GenerateSitemap(w io.Writer, base string, pages []SitePage) error {
+	set := sitemapURLSet{URLs: make([]sitemapURL, 0, len(pages))}
+	for _, p := range pages {
+		// Index pages are listed by their directory's URL
+		u := sitemapURL{Loc: absoluteURL(base, strings.TrimSuffix(p.Path, IndexFileName))}
+		if !p.Modified.IsZero() {
+			u.LastMod = p.Modified.UTC().Format(time.RFC3339)
+		}
+		set.URLs = append(set.URLs, u)
+	}
+	return writeXML(w, set)
+}
+
+// GenerateLLMsTxt writes an llms.txt (https://llmstxt.org/) for the planet:
+// its title and owner, its feeds, and its most recent entries
+func GenerateLLMsTxt(w io.Writer, data TemplateData, maxEntries int) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", mdText(data.Title))
+	summary := "A planet aggregating the posts of the blogs listed below"
+	if data.OwnerName != "" {
+		summary += ", run by " + mdText(data.OwnerName)
+	}
+	fmt.Fprintf(&b, "> %s.\n\n", summary)
+	if data.Subtitle != "" {
+		fmt.Fprintf(&b, "%s\n\n", mdText(data.Subtitle))
+	}
+	fmt.Fprintf(&b, "The newest posts are at %s, and as Atom at %s.\n", absoluteURL(data.Link, ""), absoluteURL(data.Link, AtomFileName))
+
+	if len(data.Feeds) > 0 {
+		b.WriteString("\n## Feeds\n\n")
+		for _, f := range data.Feeds {
+			title := f.Title
+			if title == "" {
+				title = f.URL
+			}
+			link := f.Link
+			if link == "" {
+				link = f.URL
+			}
+			fmt.Fprintf(&b, "- [%s](%s): %s\n", mdText(title), link, f.URL)
+		}
+	}
+
+	if entries := limitEntries(data.Entries, maxEntries); len(entries) > 0 {
+		b.WriteString("\n## Recent posts\n\n")
+		for _, e := range entries {
+			title := stripTags(e.Title)
+			if title == "" {
+				title = e.Link
+			}
+			fmt.Fprintf(&b, "- [%s](%s)", mdText(title), e.Link)
+			if e.FeedTitle != "" {
+				fmt.Fprintf(&b, ": %s", mdText(e.FeedTitle))
+			}
+			if !e.Published.IsZero() {
+				fmt.Fprintf(&b, ", %s", e.Published.UTC().Format(time.DateOnly))
+			}
+			b.WriteString("\n")
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+var mdEscaper = strings.NewReplacer(`\`, `\\`, "[", `\[`, "]", `\]`, "\n", " ", "\r", "")
+
+// mdText escapes the characters that would end a Markdown link's text and
+// folds the text onto one line
+func mdText(s string) string {
+	return mdEscaper.Replace(strings.TrimSpace(s))
+}
@@ -0,0 +1,143 @@
+package generator
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRiverPages(t *testing.T) {
+	t.Parallel()
+	data := feedTestData()
+
+	pages := RiverPages(data, 2)
+	if len(pages) != 2 || pages[0].Path != "index.html" || pages[1].Path != "page2.html" {
+		t.Fatalf("RiverPages() = %+v, want index.html and page2.html", pages)
+	}
+	// The first page is dated by its newest update, the second by its only entry
+	if !pages[0].Modified.Equal(data.Entries[0].Updated) || !pages[1].Modified.Equal(data.Entries[2].Published) {
+		t.Errorf("RiverPages() dates = %v, %v", pages[0].Modified, pages[1].Modified)
+	}
+
+	if pages := RiverPages(TemplateData{}, 0); len(pages) != 1 || !pages[0].Modified.IsZero() {
+		t.Errorf("RiverPages() of an empty planet = %+v, want one undated index", pages)
+	}
+}
+
+func TestGenerateSitemap(t *testing.T) {
+	t.Parallel()
+	data := feedTestData()
+
+	var buf bytes.Buffer
+	if err := GenerateSitemap(&buf, data.Link, append(RiverPages(data, 2), SitePage{Path: "archive/index.html"})); err != nil {
+		t.Fatalf("GenerateSitemap() error = %v", err)
+	}
+
+	var set struct {
+		XMLName xml.Name
+		URLs    []struct {
+			Loc     string `xml:"loc"`
+			LastMod string `xml:"lastmod"`
+		} `xml:"url"`
+	}
+	if err := xml.Unmarshal(buf.Bytes(), &set); err != nil {
+		t.Fatalf("sitemap is not XML: %v\n%s", err, buf.String())
+	}
+	if set.XMLName.Space != "http://www.sitemaps.org/schemas/sitemap/0.9" || set.XMLName.Local != "urlset" {
+		t.Errorf("root element = %v", set.XMLName)
+	}
+	want := []struct{ loc, lastmod string }{
+		{"https://planet.example.com/", "2025-03-10T12:00:00Z"},
+		{"https://planet.example.com/page2.html", "2025-03-10T09:00:00Z"},
+		{"https://planet.example.com/archive/", ""},
+	}
+	if len(set.URLs) != len(want) {
+		t.Fatalf("sitemap has %d URLs, want %d:\n%s", len(set.URLs), len(want), buf.String())
+	}
+	for i, w := range want {
+		if set.URLs[i].Loc != w.loc || set.URLs[i].LastMod != w.lastmod {
+			t.Errorf("url %d = %+v, want %s (lastmod %q)", i, set.URLs[i], w.loc, w.lastmod)
+		}
+	}
+}
+
+func TestGenerateLLMsTxt(t *testing.T) {
+	t.Parallel()
+	data := feedTestData()
+	data.Feeds = []FeedData{
+		{Title: "Alice's [Blog]", Link: "https://a.example.com/", URL: "https://a.example.com/feed"},
+		{URL: "https://b.example.com/feed"},
+	}
+
+	var buf bytes.Buffer
+	if err := GenerateLLMsTxt(&buf, data, 2); err != nil {
+		t.Fatalf("GenerateLLMsTxt() error = %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"# Test Planet\n\n> A planet aggregating the posts of the blogs listed below, run by Owner.\n\nPosts from friends\n",
+		"as Atom at https://planet.example.com/atom.xml.\n",
+		"## Feeds\n\n- [Alice's \\[Blog\\]](https://a.example.com/): https://a.example.com/feed\n- [https://b.example.com/feed](https://b.example.com/feed): https://b.example.com/feed\n",
+		"## Recent posts\n\n- [Ben & Jerry](https://a.example.com/1): Alice's Blog, 2025-03-10\n- [Second](https://b.example.com/2): Bob, 2025-03-10\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("llms.txt missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "Third") {
+		t.Errorf("llms.txt lists more than 2 entries:\n%s", out)
+	}
+}
+
+func TestWriteSiteFiles(t *testing.T) {
+	t.Parallel()
+	gen := newFeedTestGenerator(t)
+	data := feedTestData()
+	ctx := context.Background()
+
+	dir := t.TempDir()
+	opts := SiteFileOptions{Sitemap: true, LLMsTxt: true, Pages: RiverPages(data, 0)}
+	if err := gen.WriteSiteFiles(ctx, dir, data, opts); err != nil {
+		t.Fatalf("WriteSiteFiles() error = %v", err)
+	}
+	for _, name := range []string{SitemapFileName, LLMsFileName} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("%s not written: %v", name, err)
+		}
+	}
+	robots, err := os.ReadFile(filepath.Join(dir, RobotsFileName))
+	if err != nil || !strings.Contains(string(robots), "Sitemap: https://planet.example.com/sitemap.xml\n") {
+		t.Errorf("robots.txt = %q, %v; want it to point to the sitemap", robots, err)
+	}
+
+	// A generated robots.txt follows the link; one from the theme is kept
+	data.Link = "https://new.example.com/"
+	if err := gen.WriteSiteFiles(ctx, dir, data, opts); err != nil {
+		t.Fatalf("WriteSiteFiles() error = %v", err)
+	}
+	if robots, _ := os.ReadFile(filepath.Join(dir, RobotsFileName)); !strings.Contains(string(robots), "https://new.example.com/sitemap.xml") {
+		t.Errorf("robots.txt after the link changed = %q", robots)
+	}
+	dir = t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, RobotsFileName), []byte("User-agent: *\nDisallow: /\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := gen.WriteSiteFiles(ctx, dir, data, opts); err != nil {
+		t.Fatalf("WriteSiteFiles() error = %v", err)
+	}
+	if robots, _ := os.ReadFile(filepath.Join(dir, RobotsFileName)); string(robots) != "User-agent: *\nDisallow: /\n" {
+		t.Errorf("theme robots.txt replaced with %q", robots)
+	}
+
+	data.Link = ""
+	if err := gen.WriteSiteFiles(ctx, t.TempDir(), data, opts); err == nil {
+		t.Error("WriteSiteFiles() without a planet link succeeded")
+	}
+	if err := gen.WriteSiteFiles(ctx, t.TempDir(), data, SiteFileOptions{}); err != nil {
+		t.Errorf("WriteSiteFiles() with nothing to write error = %v", err)
+	}
+}
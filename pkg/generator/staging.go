@@ -0,0 +1,200 @@
+package generator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ErrNoPreviousGeneration is returned by Rollback when there is nothing to roll back to
+var ErrNoPreviousGeneration = errors.New("no previous generation to roll back to")
+
+// staleStageAge is how old an abandoned staging directory (left by a crash)
+// must be before NewStage removes it
+const staleStageAge = time.Hour
+
+// Stage is a staging directory for one generation of the site.
+//
+// The site is rendered into the staging directory, a hidden sibling of the
+// output directory, and Commit swaps it into place with renames. A crash
+// mid-generate leaves the published site untouched. The generation being
+// replaced is kept as a hidden ".<name>.previous" sibling so Rollback can
+// restore it.
+//
+// The swap is two renames, so for a moment between them the output
+// directory does not exist; a directory cannot be atomically replaced by
+// another. SiteFS serves the site through that gap. A web server serving the
+// output directory itself may answer 404 for requests made during it.
+type Stage struct {
+	outputDir string
+	dir       string
+	done      bool
+}
+
+// NewStage creates a staging directory next to outputDir, seeded with a copy
+// of the current site so files not produced by the generator (a CNAME file,
+// robots.txt, ...) carry over to the new generation
+func NewStage(ctx context.Context, outputDir string) (*Stage, error) {
+	outputDir = filepath.Clean(outputDir)
+	parent, name := filepath.Split(outputDir)
+	if parent == "" {
+		parent = "."
+	}
+	if err := os.MkdirAll(parent, 0755); err != nil {
+		return nil, fmt.Errorf("create output parent directory: %w", err)
+	}
+	removeStaleStages(parent, name)
+
+	dir, err := os.MkdirTemp(parent, "."+name+".staging-")
+	if err != nil {
+		return nil, fmt.Errorf("create staging directory: %w", err)
+	}
+	s := &Stage{outputDir: outputDir, dir: dir}
+
+	mode := os.FileMode(0755)
+	if info, err := os.Stat(outputDir); err == nil {
+		mode = info.Mode().Perm()
+		if err := copyDir(ctx, outputDir, dir); err != nil {
+			s.Abort()
+			return nil, fmt.Errorf("seed staging directory: %w", err)
+		}
+	}
+	// MkdirTemp creates the directory 0700; the published site must stay readable
+	if err := os.Chmod(dir, mode); err != nil {
+		s.Abort()
+		return nil, fmt.Errorf("set staging directory permissions: %w", err)
+	}
+
+	return s, nil
+}
+
+// Dir returns the staging directory to render the site into
+func (s *Stage) Dir() string {
+	return s.dir
+}
+
+// Commit publishes the staged site. The current output directory becomes the
+// previous generation, replacing any older one. Between moving the current
+// site aside and moving the staged one into place, only the previous
+// generation exists; see SiteFS.
+func (s *Stage) Commit() error {
+	if s.done {
+		return errors.New("stage already committed or aborted")
+	}
+
+	previous := previousDir(s.outputDir)
+	hadOutput := false
+	if _, err := os.Stat(s.outputDir); err == nil {
+		hadOutput = true
+		if err := os.RemoveAll(previous); err != nil {
+			return fmt.Errorf("remove old previous generation: %w", err)
+		}
+		if err := os.Rename(s.outputDir, previous); err != nil {
+			return fmt.Errorf("move current site aside: %w", err)
+		}
+	}
+
+	if err := os.Rename(s.dir, s.outputDir); err != nil {
+		// Put the old site back so it keeps being served
+		if hadOutput {
+			_ = os.Rename(previous, s.outputDir)
+		}
+		return fmt.Errorf("publish staged site: %w", err)
+	}
+
+	s.done = true
+	return nil
+}
+
+// Abort discards the staging directory. It is safe to call after Commit, so
+// callers can defer it.
+func (s *Stage) Abort() {
+	if s.done {
+		return
+	}
+	s.done = true
+	_ = os.RemoveAll(s.dir)
+}
+
+// Rollback swaps outputDir with the previous generation kept by Commit.
+// Rolling back twice restores the newer generation.
+func Rollback(outputDir string) error {
+	outputDir = filepath.Clean(outputDir)
+	previous := previousDir(outputDir)
+	if _, err := os.Stat(previous); os.IsNotExist(err) {
+		return ErrNoPreviousGeneration
+	} else if err != nil {
+		return fmt.Errorf("check previous generation: %w", err)
+	}
+
+	if _, err := os.Stat(outputDir); os.IsNotExist(err) {
+		if err := os.Rename(previous, outputDir); err != nil {
+			return fmt.Errorf("restore previous generation: %w", err)
+		}
+		return nil
+	}
+
+	parent, name := filepath.Split(outputDir)
+	swap := filepath.Join(parent, "."+name+".rollback")
+	if err := os.RemoveAll(swap); err != nil {
+		return fmt.Errorf("remove old rollback directory: %w", err)
+	}
+	if err := os.Rename(outputDir, swap); err != nil {
+		return fmt.Errorf("move current site aside: %w", err)
+	}
+	if err := os.Rename(previous, outputDir); err != nil {
+		_ = os.Rename(swap, outputDir)
+		return fmt.Errorf("restore previous generation: %w", err)
+	}
+	if err := os.Rename(swap, previous); err != nil {
+		return fmt.Errorf("keep replaced generation: %w", err)
+	}
+	return nil
+}
+
+// SiteFS returns the site published in outputDir as an http.FileSystem that
+// keeps serving while Commit swaps generations: when outputDir is missing
+// because Commit has just moved it aside, files are served from the previous
+// generation it became.
+func SiteFS(outputDir string) http.FileSystem {
+	return siteFS(filepath.Clean(outputDir))
+}
+
+type siteFS string
+
+func (dir siteFS) Open(name string) (http.File, error) {
+	f, err := http.Dir(dir).Open(name)
+	if !errors.Is(err, fs.ErrNotExist) {
+		return f, err
+	}
+	if _, statErr := os.Stat(string(dir)); !errors.Is(statErr, fs.ErrNotExist) {
+		// The site is in place, perhaps published since the first attempt
+		return http.Dir(dir).Open(name)
+	}
+	return http.Dir(previousDir(string(dir))).Open(name)
+}
+
+// previousDir is where Commit keeps the generation it replaced
+func previousDir(outputDir string) string {
+	parent, name := filepath.Split(outputDir)
+	return filepath.Join(parent, "."+name+".previous")
+}
+
+// removeStaleStages deletes staging directories abandoned by a crashed run.
+// Recent ones are left alone in case another generate is still using them.
+func removeStaleStages(parent, name string) {
+	matches, err := filepath.Glob(filepath.Join(parent, "."+name+".staging-*"))
+	if err != nil {
+		return
+	}
+	for _, m := range matches {
+		if info, err := os.Stat(m); err == nil && time.Since(info.ModTime()) > staleStageAge {
+			_ = os.RemoveAll(m)
+		}
+	}
+}
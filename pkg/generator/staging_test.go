@@ -0,0 +1,170 @@
+package generator
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readTestFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestStageCommitAndRollback(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	outputDir := filepath.Join(t.TempDir(), "public")
+
+	// First generation into a directory that does not exist yet
+	stage, err := NewStage(ctx, outputDir)
+	if err != nil {
+		t.Fatalf("NewStage() error = %v", err)
+	}
+	writeTestFile(t, filepath.Join(stage.Dir(), "index.html"), "v1")
+	if err := stage.Commit(); err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+	if got := readTestFile(t, filepath.Join(outputDir, "index.html")); got != "v1" {
+		t.Errorf("index.html = %q, want v1", got)
+	}
+	info, err := os.Stat(outputDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0755 {
+		t.Errorf("output mode = %v, want 0755", info.Mode().Perm())
+	}
+
+	// A file placed in the site by hand survives the next generation
+	writeTestFile(t, filepath.Join(outputDir, "CNAME"), "planet.example.com")
+
+	stage, err = NewStage(ctx, outputDir)
+	if err != nil {
+		t.Fatalf("NewStage() error = %v", err)
+	}
+	writeTestFile(t, filepath.Join(stage.Dir(), "index.html"), "v2")
+	// Nothing is published until Commit
+	if got := readTestFile(t, filepath.Join(outputDir, "index.html")); got != "v1" {
+		t.Errorf("index.html before commit = %q, want v1", got)
+	}
+	if err := stage.Commit(); err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+	if got := readTestFile(t, filepath.Join(outputDir, "index.html")); got != "v2" {
+		t.Errorf("index.html = %q, want v2", got)
+	}
+	if got := readTestFile(t, filepath.Join(outputDir, "CNAME")); got != "planet.example.com" {
+		t.Errorf("CNAME = %q, want it carried over", got)
+	}
+
+	if err := Rollback(outputDir); err != nil {
+		t.Fatalf("Rollback() error = %v", err)
+	}
+	if got := readTestFile(t, filepath.Join(outputDir, "index.html")); got != "v1" {
+		t.Errorf("index.html after rollback = %q, want v1", got)
+	}
+
+	// Rolling back again restores the newer generation
+	if err := Rollback(outputDir); err != nil {
+		t.Fatalf("second Rollback() error = %v", err)
+	}
+	if got := readTestFile(t, filepath.Join(outputDir, "index.html")); got != "v2" {
+		t.Errorf("index.html after second rollback = %q, want v2", got)
+	}
+}
+
+func TestStageAbortKeepsSite(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	outputDir := filepath.Join(t.TempDir(), "public")
+	writeTestFile(t, filepath.Join(outputDir, "index.html"), "live")
+
+	stage, err := NewStage(ctx, outputDir)
+	if err != nil {
+		t.Fatalf("NewStage() error = %v", err)
+	}
+	writeTestFile(t, filepath.Join(stage.Dir(), "index.html"), "half-written")
+	stage.Abort()
+
+	if got := readTestFile(t, filepath.Join(outputDir, "index.html")); got != "live" {
+		t.Errorf("index.html = %q, want live site untouched", got)
+	}
+	if _, err := os.Stat(stage.Dir()); !os.IsNotExist(err) {
+		t.Errorf("staging directory should be removed, stat error = %v", err)
+	}
+	if err := stage.Commit(); err == nil {
+		t.Error("Commit() after Abort() should fail")
+	}
+}
+
+func TestRollbackWithoutPreviousGeneration(t *testing.T) {
+	t.Parallel()
+	outputDir := filepath.Join(t.TempDir(), "public")
+	writeTestFile(t, filepath.Join(outputDir, "index.html"), "live")
+
+	if err := Rollback(outputDir); !errors.Is(err, ErrNoPreviousGeneration) {
+		t.Errorf("Rollback() error = %v, want ErrNoPreviousGeneration", err)
+	}
+}
+
+func TestSiteFSDuringCommit(t *testing.T) {
+	t.Parallel()
+	outputDir := filepath.Join(t.TempDir(), "public")
+	writeTestFile(t, filepath.Join(outputDir, "index.html"), "v2")
+	writeTestFile(t, filepath.Join(previousDir(outputDir), "index.html"), "v1")
+	writeTestFile(t, filepath.Join(previousDir(outputDir), "gone.html"), "v1")
+	site := SiteFS(outputDir)
+
+	read := func(name string) (string, error) {
+		t.Helper()
+		f, err := site.Open(name)
+		if err != nil {
+			return "", err
+		}
+		defer f.Close()
+		data, err := io.ReadAll(f)
+		return string(data), err
+	}
+
+	if got, err := read("/index.html"); err != nil || got != "v2" {
+		t.Errorf("index.html = %q, %v; want v2", got, err)
+	}
+	// A file only the previous generation has is not served from it
+	if _, err := read("/gone.html"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("gone.html error = %v, want fs.ErrNotExist", err)
+	}
+
+	// Commit's first rename: the current site is moved aside, and the
+	// output directory is missing until the staged site replaces it
+	if err := os.RemoveAll(previousDir(outputDir)); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(outputDir, previousDir(outputDir)); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := read("/index.html"); err != nil || got != "v2" {
+		t.Errorf("index.html between renames = %q, %v; want v2", got, err)
+	}
+	if _, err := read("/missing.html"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("missing.html error = %v, want fs.ErrNotExist", err)
+	}
+}
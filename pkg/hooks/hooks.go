@@ -0,0 +1,238 @@
+// Package hooks runs the shell commands configured in [hooks] at points of
+// a planet's lifecycle: before a fetch, on each parsed entry, and before
+// and after the site is generated.
+//
+// Each command is given a JSON event on stdin, whose "event" field names
+// the hook. A pre_fetch or pre_generate command stops what would follow by
+// exiting with a non-zero status. A post_entry command may print a JSON
+// object to change the entry: the fields it has replace the entry's, and
+// "drop": true leaves the entry out. Printing nothing keeps the entry as it
+// is. Several commands for one hook run in turn, each post_entry command
+// seeing the entry as the one before left it.
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Hook names, given as the "event" of each event
+const (
+	PreFetch     = "pre_fetch"
+	PostEntry    = "post_entry"
+	PreGenerate  = "pre_generate"
+	PostGenerate = "post_generate"
+)
+
+// maxOutput is how much of a failing command's stderr is kept for its error
+const maxOutput = 2048
+
+// Hooks are the commands to run for each hook. Methods on a nil *Hooks run
+// nothing.
+type Hooks struct {
+	PreFetch     []string
+	PostEntry    []string
+	PreGenerate  []string
+	PostGenerate []string
+	Timeout      time.Duration // Time limit for each command; 0 means no limit
+}
+
+// Feed is a feed in an event
+type Feed struct {
+	URL   string `json:"url"`
+	Title string `json:"title"`
+	Link  string `json:"link,omitempty"`
+}
+
+// Entry is the entry in a post_entry event. Content and summary are HTML.
+type Entry struct {
+	ID         string    `json:"id"`
+	Title      string    `json:"title"`
+	Link       string    `json:"link"`
+	Author     string    `json:"author"`
+	Published  time.Time `json:"published"`
+	Updated    time.Time `json:"updated"`
+	Content    string    `json:"content"`
+	Summary    string    `json:"summary"`
+	Categories []string  `json:"categories"`
+	Image      string    `json:"image"`
+}
+
+type fetchEvent struct {
+	Event  string `json:"event"`
+	Planet string `json:"planet"`
+	Feeds  []Feed `json:"feeds"`
+}
+
+type entryEvent struct {
+	Event string `json:"event"`
+	Feed  Feed   `json:"feed"`
+	Entry Entry  `json:"entry"`
+}
+
+type generateEvent struct {
+	Event     string `json:"event"`
+	Planet    string `json:"planet"`
+	OutputDir string `json:"output_dir"`
+}
+
+// BeforeFetch runs the pre_fetch commands on the feeds about to be fetched,
+// returning the first failure
+func (h *Hooks) BeforeFetch(ctx context.Context, planet string, feeds []Feed) error {
+	if h == nil {
+		return nil
+	}
+	return h.runAll(ctx, PreFetch, h.PreFetch, fetchEvent{Event: PreFetch, Planet: planet, Feeds: feeds})
+}
+
+// BeforeGenerate runs the pre_generate commands, returning the first failure
+func (h *Hooks) BeforeGenerate(ctx context.Context, planet, outputDir string) error {
+	if h == nil {
+		return nil
+	}
+	return h.runAll(ctx, PreGenerate, h.PreGenerate, generateEvent{Event: PreGenerate, Planet: planet, OutputDir: outputDir})
+}
+
+// AfterGenerate runs the post_generate commands on the generated site in
+// outputDir, returning the first failure
+func (h *Hooks) AfterGenerate(ctx context.Context, planet, outputDir string) error {
+	if h == nil {
+		return nil
+	}
+	return h.runAll(ctx, PostGenerate, h.PostGenerate, generateEvent{Event: PostGenerate, Planet: planet, OutputDir: outputDir})
+}
+
+// HasEntryHooks reports whether there are post_entry commands to run
+func (h *Hooks) HasEntryHooks() bool {
+	return h != nil && len(h.PostEntry) > 0
+}
+
+// Entry runs the post_entry commands on an entry of feed. It returns the
+// entry as they left it, or keep false if one dropped it. Commands cannot
+// change the entry's ID. If a command fails, or prints something other
+// than a JSON object, Entry returns the entry as the commands before it
+// left it along with the error.
+func (h *Hooks) Entry(ctx context.Context, feed Feed, entry Entry) (_ Entry, keep bool, _ error) {
+	if !h.HasEntryHooks() {
+		return entry, true, nil
+	}
+	for _, command := range h.PostEntry {
+		input, err := EntryEvent(feed, entry)
+		if err != nil {
+			return entry, true, err
+		}
+		out, err := h.run(ctx, PostEntry, command, input)
+		if err != nil {
+			return entry, true, err
+		}
+		changed, keep, err := ApplyOutput(entry, out)
+		if err != nil {
+			return entry, true, fmt.Errorf("%s hook %q: %w", PostEntry, command, err)
+		}
+		if !keep {
+			return entry, false, nil
+		}
+		entry = changed
+	}
+	return entry, true, nil
+}
+
+// EntryEvent returns the post_entry event for an entry of feed, as JSON
+func EntryEvent(feed Feed, entry Entry) ([]byte, error) {
+	input, err := json.Marshal(entryEvent{Event: PostEntry, Feed: feed, Entry: entry})
+	if err != nil {
+		return nil, fmt.Errorf("encode %s event: %w", PostEntry, err)
+	}
+	return input, nil
+}
+
+// ApplyOutput applies what a post_entry command printed to entry: nothing
+// keeps it, a JSON object replaces the fields it has except the ID, and
+// "drop": true drops it, returning keep false
+func ApplyOutput(entry Entry, output []byte) (_ Entry, keep bool, _ error) {
+	output = bytes.TrimSpace(output)
+	if len(output) == 0 {
+		return entry, true, nil
+	}
+
+	// Decoding reuses slices, so copy the categories the caller has
+	changed := entry
+	changed.Categories = slices.Clone(entry.Categories)
+	result := struct {
+		*Entry
+		Drop bool `json:"drop"`
+	}{Entry: &changed}
+	if err := json.Unmarshal(output, &result); err != nil {
+		return entry, true, fmt.Errorf("invalid output: %w", err)
+	}
+	if result.Drop {
+		return entry, false, nil
+	}
+	changed.ID = entry.ID
+	return changed, true, nil
+}
+
+// runAll runs each command with event as its input, stopping at the first
+// failure
+func (h *Hooks) runAll(ctx context.Context, hook string, commands []string, event any) error {
+	if len(commands) == 0 {
+		return nil
+	}
+	input, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("encode %s event: %w", hook, err)
+	}
+	for _, command := range commands {
+		if _, err := h.run(ctx, hook, command, input); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// run runs command with the platform's shell, input on its stdin, and
+// returns its stdout. A failure includes the end of its stderr.
+func (h *Hooks) run(ctx context.Context, hook, command string, input []byte) ([]byte, error) {
+	if h.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.Timeout)
+		defer cancel()
+	}
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	// Don't wait forever on children of a killed command holding its output open
+	cmd.WaitDelay = 5 * time.Second
+
+	err := cmd.Run()
+	if err == nil {
+		return stdout.Bytes(), nil
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		err = fmt.Errorf("timed out after %s", h.Timeout)
+	}
+	output := strings.TrimSpace(stderr.String())
+	if len(output) > maxOutput {
+		output = "..." + output[len(output)-maxOutput:]
+	}
+	if output != "" {
+		return nil, fmt.Errorf("%s hook %q: %w\n%s", hook, command, err, output)
+	}
+	return nil, fmt.Errorf("%s hook %q: %w", hook, command, err)
+}
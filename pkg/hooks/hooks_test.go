@@ -0,0 +1,170 @@
+package hooks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestMain lets the test binary stand in for hook commands; its argument
+// picks what it does with the event on stdin
+func TestMain(m *testing.M) {
+	if len(os.Args) != 2 || strings.HasPrefix(os.Args[1], "-") {
+		os.Exit(m.Run())
+	}
+	var event struct {
+		entryEvent
+		Planet string `json:"planet"`
+		Feeds  []Feed `json:"feeds"`
+	}
+	if err := json.NewDecoder(os.Stdin).Decode(&event); err != nil {
+		fmt.Fprintln(os.Stderr, "bad event:", err)
+		os.Exit(2)
+	}
+	switch os.Args[1] {
+	case "expect-fetch":
+		if event.Event != PreFetch || event.Planet != "Planet Test" || len(event.Feeds) != 1 {
+			fmt.Fprintf(os.Stderr, "unexpected event %+v\n", event)
+			os.Exit(2)
+		}
+	case "fail":
+		fmt.Fprintln(os.Stderr, "network unreachable")
+		os.Exit(3)
+	case "hang":
+		time.Sleep(time.Minute)
+	case "shout":
+		json.NewEncoder(os.Stdout).Encode(map[string]any{
+			"id":         "changed",
+			"title":      strings.ToUpper(event.Entry.Title),
+			"categories": append(event.Entry.Categories, event.Feed.Title),
+		})
+	case "drop-sponsored":
+		if strings.Contains(event.Entry.Title, "SPONSORED") {
+			fmt.Println(`{"drop": true}`)
+		}
+	case "garbage":
+		fmt.Println("not json")
+	}
+	os.Exit(0)
+}
+
+// command returns a command running the test binary as a hook
+func command(t *testing.T, mode string) string {
+	t.Helper()
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fmt.Sprintf("%q %s", exe, mode)
+}
+
+func TestLifecycleHooks(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	feeds := []Feed{{URL: "https://example.com/feed", Title: "Example"}}
+
+	h := &Hooks{PreFetch: []string{command(t, "expect-fetch")}, Timeout: time.Minute}
+	if err := h.BeforeFetch(ctx, "Planet Test", feeds); err != nil {
+		t.Errorf("BeforeFetch() error = %v", err)
+	}
+	if err := h.BeforeGenerate(ctx, "Planet Test", t.TempDir()); err != nil {
+		t.Errorf("BeforeGenerate() with no commands error = %v", err)
+	}
+
+	h = &Hooks{PreFetch: []string{command(t, "fail"), command(t, "expect-fetch")}, Timeout: time.Minute}
+	err := h.BeforeFetch(ctx, "Planet Test", feeds)
+	if err == nil || !strings.Contains(err.Error(), "pre_fetch hook") || !strings.Contains(err.Error(), "network unreachable") {
+		t.Errorf("BeforeFetch() error = %v, want the failing command and its stderr", err)
+	}
+
+	var none *Hooks
+	if err := none.AfterGenerate(ctx, "Planet Test", t.TempDir()); err != nil {
+		t.Errorf("nil Hooks AfterGenerate() error = %v", err)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	t.Parallel()
+	h := &Hooks{PostGenerate: []string{command(t, "hang")}, Timeout: 100 * time.Millisecond}
+
+	start := time.Now()
+	err := h.AfterGenerate(context.Background(), "Planet Test", t.TempDir())
+	if err == nil || !strings.Contains(err.Error(), "timed out after 100ms") {
+		t.Errorf("AfterGenerate() error = %v, want a timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 30*time.Second {
+		t.Errorf("AfterGenerate() took %s to time out", elapsed)
+	}
+}
+
+func TestEntry(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	feed := Feed{URL: "https://example.com/feed", Title: "Example"}
+	entry := Entry{ID: "1", Title: "Hello", Link: "https://example.com/1", Content: "<p>Hi</p>", Categories: []string{"go"}}
+
+	tests := []struct {
+		name     string
+		commands []string
+		want     Entry
+		keep     bool
+		wantErr  bool
+	}{
+		{
+			name:     "no output keeps the entry",
+			commands: []string{command(t, "drop-sponsored")},
+			want:     entry,
+			keep:     true,
+		},
+		{
+			name:     "output replaces fields except the ID",
+			commands: []string{command(t, "shout")},
+			want:     Entry{ID: "1", Title: "HELLO", Link: "https://example.com/1", Content: "<p>Hi</p>", Categories: []string{"go", "Example"}},
+			keep:     true,
+		},
+		{
+			name:     "commands see earlier changes",
+			commands: []string{command(t, "shout"), command(t, "drop-sponsored"), command(t, "shout")},
+			want:     Entry{ID: "1", Title: "HELLO", Link: "https://example.com/1", Content: "<p>Hi</p>", Categories: []string{"go", "Example", "Example"}},
+			keep:     true,
+		},
+		{
+			name:     "invalid output",
+			commands: []string{command(t, "shout"), command(t, "garbage")},
+			want:     Entry{ID: "1", Title: "HELLO", Link: "https://example.com/1", Content: "<p>Hi</p>", Categories: []string{"go", "Example"}},
+			keep:     true,
+			wantErr:  true,
+		},
+		{
+			name:     "failure",
+			commands: []string{command(t, "fail")},
+			want:     entry,
+			keep:     true,
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			h := &Hooks{PostEntry: tt.commands, Timeout: time.Minute}
+			got, keep, err := h.Entry(ctx, feed, entry)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Entry() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if keep != tt.keep || fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("Entry() = %+v, %v; want %+v, %v", got, keep, tt.want, tt.keep)
+			}
+		})
+	}
+
+	sponsored := entry
+	sponsored.Title = "Hello (sponsored)"
+	h := &Hooks{PostEntry: []string{command(t, "shout"), command(t, "drop-sponsored")}}
+	if _, keep, err := h.Entry(ctx, feed, sponsored); err != nil || keep {
+		t.Errorf("Entry() = keep %v, %v; want the entry dropped", keep, err)
+	}
+}
@@ -0,0 +1,228 @@
+// Package importer brings the entries of an existing planet into a
+// planet's database, so moving to Rogue Planet keeps its history.
+//
+// Entries can come from another Rogue Planet database or from the cache
+// directory of a Planet Venus installation. Their feeds are matched with
+// the planet's by URL, and feeds it doesn't have yet are added. An entry the
+// planet already has, under the same ID or with the same link and content,
+// is left as it is.
+package importer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/adewale/rogue_planet/pkg/repository"
+)
+
+// errDryRun rolls back the transaction of a dry run
+var errDryRun = errors.New("dry run")
+
+// Result counts what an import did, or would do in a dry run
+type Result struct {
+	Feeds      int // Feeds with entries to import
+	FeedsAdded int // Of those, feeds the planet didn't have, which were added
+	Entries    int // Entries imported
+	Duplicates int // Entries the planet already had, which were left alone
+	Unreadable int // Files of a Venus cache that aren't entries rp can read
+}
+
+// Importer imports entries into a planet's database
+type Importer struct {
+	repo   *repository.Repository
+	dryRun bool
+}
+
+// New returns an Importer that imports into repo. A dry run works out what
+// an import would do without changing the database.
+func New(repo *repository.Repository, dryRun bool) *Importer {
+	return &Importer{repo: repo, dryRun: dryRun}
+}
+
+// sourceFeed is the feed imported entries belong to
+type sourceFeed struct {
+	URL        string
+	Title      string
+	Link       string
+	Updated    time.Time
+	Active     bool
+	Categories []string
+}
+
+// session is one import, whose writes all belong to one transaction
+type session struct {
+	tx     repository.FeedRepository
+	feeds  map[string]int64 // IDs of the feeds seen so far, by URL
+	added  map[int64][]string
+	result Result
+}
+
+// run calls fn with a session, committing what it stores if it returns nil
+// and this isn't a dry run. New feeds get their categories once committed.
+func (im *Importer) run(ctx context.Context, fn func(*session) error) (Result, error) {
+	s := &session{feeds: make(map[string]int64), added: make(map[int64][]string)}
+	err := im.repo.Batch(ctx, func(tx repository.FeedRepository) error {
+		s.tx = tx
+		if err := fn(s); err != nil {
+			return err
+		}
+		for _, id := range s.feeds {
+			if _, err := tx.EnforceQuota(ctx, id); err != nil {
+				return err
+			}
+		}
+		if im.dryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if errors.Is(err, errDryRun) {
+		return s.result, nil
+	}
+	if err != nil {
+		return Result{}, err
+	}
+
+	for id, categories := range s.added {
+		if err := im.repo.SetFeedCategories(ctx, id, categories); err != nil {
+			return s.result, fmt.Errorf("set feed categories: %w", err)
+		}
+	}
+	return s.result, nil
+}
+
+// feedID returns the ID of the planet's feed with feed's URL, adding the
+// feed if the planet doesn't have it
+func (s *session) feedID(ctx context.Context, feed sourceFeed) (int64, error) {
+	if id, ok := s.feeds[feed.URL]; ok {
+		return id, nil
+	}
+	s.result.Feeds++
+
+	var id int64
+	existing, err := s.tx.GetFeedByURL(ctx, feed.URL)
+	switch {
+	case err == nil:
+		id = existing.ID
+	case errors.Is(err, repository.ErrFeedNotFound):
+		if id, err = s.tx.AddFeed(ctx, feed.URL, feed.Title); err != nil {
+			return 0, err
+		}
+		if err := s.tx.UpdateFeed(ctx, id, feed.Title, feed.Link, feed.Updated); err != nil {
+			return 0, err
+		}
+		if !feed.Active {
+			if err := s.tx.DeactivateFeed(ctx, id); err != nil {
+				return 0, err
+			}
+		}
+		if len(feed.Categories) > 0 {
+			s.added[id] = feed.Categories
+		}
+		s.result.FeedsAdded++
+	default:
+		return 0, fmt.Errorf("look up feed %s: %w", feed.URL, err)
+	}
+	s.feeds[feed.URL] = id
+	return id, nil
+}
+
+// store saves entry unless the planet already has it
+func (s *session) store(ctx context.Context, entry *repository.Entry) error {
+	stored, err := s.tx.GetStoredEntryIDs(ctx, entry.FeedID, []string{entry.EntryID})
+	if err != nil {
+		return err
+	}
+	if !stored[entry.EntryID] {
+		// The same post under another ID, as a feed that moved platforms has
+		oldID, err := s.tx.FindEntryByContent(ctx, entry.FeedID, entry.Link, entry.ContentHash)
+		if err != nil {
+			return err
+		}
+		stored[entry.EntryID] = oldID != ""
+	}
+	if stored[entry.EntryID] {
+		s.result.Duplicates++
+		return nil
+	}
+
+	if err := s.tx.UpsertEntry(ctx, entry); err != nil {
+		return err
+	}
+	s.result.Entries++
+	return nil
+}
+
+// FromDatabase imports the entries of the Rogue Planet SQLite database at
+// path, leaving out its hidden entries. The database is read from a copy,
+// so it is not upgraded to the current schema.
+func (im *Importer) FromDatabase(ctx context.Context, path string) (Result, error) {
+	if _, err := os.Stat(path); err != nil {
+		return Result{}, fmt.Errorf("open database: %w", err)
+	}
+	tmpDir, err := os.MkdirTemp("", "rp-import-*")
+	if err != nil {
+		return Result{}, fmt.Errorf("copy database: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	// Entries not yet checkpointed are in the write-ahead log
+	copyPath := filepath.Join(tmpDir, "planet.db")
+	for _, suffix := range []string{"", "-wal"} {
+		if err := copyFile(path+suffix, copyPath+suffix); err != nil && !(suffix != "" && os.IsNotExist(err)) {
+			return Result{}, fmt.Errorf("copy database: %w", err)
+		}
+	}
+	src, err := repository.New(copyPath)
+	if err != nil {
+		return Result{}, fmt.Errorf("open database: %w", err)
+	}
+	defer src.Close()
+
+	feeds, err := src.GetFeeds(ctx, false)
+	if err != nil {
+		return Result{}, fmt.Errorf("get feeds: %w", err)
+	}
+	categories, err := src.GetAllFeedCategories(ctx)
+	if err != nil {
+		return Result{}, fmt.Errorf("get feed categories: %w", err)
+	}
+	byID := make(map[int64]sourceFeed, len(feeds))
+	for _, f := range feeds {
+		byID[f.ID] = sourceFeed{URL: f.URL, Title: f.Title, Link: f.Link, Updated: f.Updated, Active: f.Active, Categories: categories[f.ID]}
+	}
+
+	return im.run(ctx, func(s *session) error {
+		return src.ExportEntries(ctx, repository.EntryFilter{}, func(entry repository.Entry) error {
+			id, err := s.feedID(ctx, byID[entry.FeedID])
+			if err != nil {
+				return err
+			}
+			entry.FeedID = id
+			return s.store(ctx, &entry)
+		})
+	})
+}
+
+// copyFile copies the file at src to dst
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,215 @@
+package importer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adewale/rogue_planet/pkg/normalizer"
+	"github.com/adewale/rogue_planet/pkg/repository"
+)
+
+// newRepo returns a new database in a temporary directory, and its path
+func newRepo(t *testing.T) (*repository.Repository, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "planet.db")
+	repo, err := repository.New(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { repo.Close() })
+	return repo, path
+}
+
+// entryIDs returns the IDs of the stored entries of each feed, by feed URL
+func entryIDs(t *testing.T, repo *repository.Repository) map[string][]string {
+	t.Helper()
+	ctx := context.Background()
+	feeds, err := repo.GetFeeds(ctx, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	urls := make(map[int64]string)
+	for _, f := range feeds {
+		urls[f.ID] = f.URL
+	}
+	ids := make(map[string][]string)
+	err = repo.ExportEntries(ctx, repository.EntryFilter{}, func(e repository.Entry) error {
+		ids[urls[e.FeedID]] = append(ids[urls[e.FeedID]], e.EntryID)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, list := range ids {
+		sort.Strings(list)
+	}
+	return ids
+}
+
+func TestFromDatabase(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	day := time.Date(2012, 6, 1, 8, 0, 0, 0, time.UTC)
+
+	src, srcPath := newRepo(t)
+	oldFeed, _ := src.AddFeed(ctx, "https://old.example.com/feed", "Old Blog")
+	sharedFeed, _ := src.AddFeed(ctx, "https://shared.example.com/feed", "Shared")
+	if err := src.SetFeedCategories(ctx, oldFeed, []string{"history"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range []repository.Entry{
+		{FeedID: oldFeed, EntryID: "old-1", Link: "https://old.example.com/1", Content: "One"},
+		{FeedID: oldFeed, EntryID: "old-private", Link: "https://old.example.com/private", Content: "Private"},
+		{FeedID: sharedFeed, EntryID: "shared-1", Link: "https://shared.example.com/1", Content: "Stale copy"},
+		{FeedID: sharedFeed, EntryID: "shared-2", Link: "https://shared.example.com/2", Content: "Two"},
+		{FeedID: sharedFeed, EntryID: "shared-3-old-id", Link: "https://shared.example.com/3", Content: "Three", ContentHash: "hash-3"},
+	} {
+		e.Published, e.Updated, e.FirstSeen = day, day, day
+		if err := src.UpsertEntry(ctx, &e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := src.HideEntries(ctx, "https://old.example.com/private", day); err != nil {
+		t.Fatal(err)
+	}
+
+	dst, _ := newRepo(t)
+	dstShared, _ := dst.AddFeed(ctx, "https://shared.example.com/feed", "Shared")
+	now := time.Now().Truncate(time.Second)
+	for _, e := range []repository.Entry{
+		{FeedID: dstShared, EntryID: "shared-1", Link: "https://shared.example.com/1", Content: "Current copy"},
+		{FeedID: dstShared, EntryID: "shared-3", Link: "https://shared.example.com/3", Content: "Three", ContentHash: "hash-3"},
+	} {
+		e.Published, e.Updated, e.FirstSeen = now, now, now
+		if err := dst.UpsertEntry(ctx, &e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	before := entryIDs(t, dst)
+
+	want := Result{Feeds: 2, FeedsAdded: 1, Entries: 2, Duplicates: 2}
+	dry, err := New(dst, true).FromDatabase(ctx, srcPath)
+	if err != nil {
+		t.Fatalf("dry run error = %v", err)
+	}
+	if dry != want {
+		t.Errorf("dry run = %+v, want %+v", dry, want)
+	}
+	if got := entryIDs(t, dst); !reflect.DeepEqual(got, before) {
+		t.Errorf("dry run changed entries to %v", got)
+	}
+
+	result, err := New(dst, false).FromDatabase(ctx, srcPath)
+	if err != nil {
+		t.Fatalf("FromDatabase() error = %v", err)
+	}
+	if result != want {
+		t.Errorf("FromDatabase() = %+v, want %+v", result, want)
+	}
+	wantIDs := map[string][]string{
+		"https://old.example.com/feed":    {"old-1"},
+		"https://shared.example.com/feed": {"shared-1", "shared-2", "shared-3"},
+	}
+	if got := entryIDs(t, dst); !reflect.DeepEqual(got, wantIDs) {
+		t.Errorf("entries after import = %v, want %v", got, wantIDs)
+	}
+
+	entries, _ := dst.FindEntries(ctx, "https://shared.example.com/1")
+	if len(entries) != 1 || entries[0].Content != "Current copy" {
+		t.Errorf("an entry the planet had = %+v, want it left alone", entries)
+	}
+	entries, _ = dst.FindEntries(ctx, "https://old.example.com/1")
+	if len(entries) != 1 || !entries[0].FirstSeen.Equal(day) {
+		t.Errorf("imported entry = %+v, want it first seen %s", entries, day)
+	}
+	added, _ := dst.GetFeedByURL(ctx, "https://old.example.com/feed")
+	if categories, _ := dst.GetFeedCategories(ctx, added.ID); !reflect.DeepEqual(categories, []string{"history"}) {
+		t.Errorf("added feed categories = %q, want [history]", categories)
+	}
+
+	// Importing again finds nothing new
+	again, err := New(dst, false).FromDatabase(ctx, srcPath)
+	if err != nil || again.Entries != 0 || again.FeedsAdded != 0 {
+		t.Errorf("second import = %+v, %v; want nothing imported", again, err)
+	}
+}
+
+const venusEntryXML = `<?xml version="1.0" encoding="utf-8"?>
+<entry xmlns="http://www.w3.org/2005/Atom" xmlns:planet="http://planet.intertwingly.net/">
+  <id>tag:blog.example.com,2009:%s</id>
+  <link rel="alternate" href="http://blog.example.com/%s" type="text/html"/>
+  <title>Post %s</title>
+  <content type="xhtml"><div xmlns="http://www.w3.org/1999/xhtml"><p>Hello<script>alert(1)</script></p></div></content>
+  <updated>2009-03-0%sT10:00:00Z</updated>
+  <published>2009-03-0%sT09:00:00Z</published>
+  <author><name>Ann</name></author>
+  <source>
+    <id>tag:blog.example.com,2009:feed</id>
+    <link rel="alternate" href="http://blog.example.com/" type="text/html"/>
+    <link rel="self" href="http://blog.example.com/atom.xml" type="application/atom+xml"/>
+    <title>Example Blog</title>
+    <planet:name>Example Blog</planet:name>
+  </source>
+</entry>
+`
+
+func TestFromVenusCache(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	dir := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, n := range []string{"1", "2"} {
+		write("blog.example.com,2009:"+n, fmt.Sprintf(venusEntryXML, n, n, n, n, n))
+	}
+	write("notes.txt", "not an entry")
+	write("orphan", `<entry xmlns="http://www.w3.org/2005/Atom"><id>x</id><title>No source</title></entry>`)
+	if err := os.Mkdir(filepath.Join(dir, "sources"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	dst, _ := newRepo(t)
+	result, err := New(dst, false).FromVenusCache(ctx, dir, normalizer.New())
+	if err != nil {
+		t.Fatalf("FromVenusCache() error = %v", err)
+	}
+	if want := (Result{Feeds: 1, FeedsAdded: 1, Entries: 2, Unreadable: 2}); result != want {
+		t.Errorf("FromVenusCache() = %+v, want %+v", result, want)
+	}
+
+	feed, err := dst.GetFeedByURL(ctx, "http://blog.example.com/atom.xml")
+	if err != nil {
+		t.Fatalf("feed not added: %v", err)
+	}
+	if feed.Title != "Example Blog" || feed.Link != "http://blog.example.com/" {
+		t.Errorf("feed = %q, %q; want the source's title and link", feed.Title, feed.Link)
+	}
+	entries, _ := dst.FindEntries(ctx, "http://blog.example.com/1")
+	if len(entries) != 1 {
+		t.Fatalf("got %d entries for post 1, want 1", len(entries))
+	}
+	e := entries[0]
+	published := time.Date(2009, 3, 1, 9, 0, 0, 0, time.UTC)
+	if e.EntryID != "tag:blog.example.com,2009:1" || e.Title != "Post 1" || e.Author != "Ann" || !e.Published.Equal(published) || !e.FirstSeen.Equal(published) {
+		t.Errorf("entry = %+v", e)
+	}
+	if e.Content == "" || e.ContentHash == "" || strings.Contains(e.Content, "<script") {
+		t.Errorf("Content = %q, ContentHash = %q; want sanitized content and a fingerprint", e.Content, e.ContentHash)
+	}
+
+	again, err := New(dst, false).FromVenusCache(ctx, dir, normalizer.New())
+	if err != nil || again.Entries != 0 || again.Duplicates != 2 {
+		t.Errorf("second import = %+v, %v; want 2 duplicates", again, err)
+	}
+}
@@ -0,0 +1,125 @@
+package importer
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/adewale/rogue_planet/pkg/fetcher"
+	"github.com/adewale/rogue_planet/pkg/normalizer"
+)
+
+// venusEntry is what is read directly from an entry in a Venus cache: the
+// <source> element Venus adds to record the feed the entry came from
+type venusEntry struct {
+	XMLName xml.Name `xml:"entry"`
+	Source  struct {
+		ID    string `xml:"id"`
+		Title string `xml:"title"`
+		Links []struct {
+			Rel  string `xml:"rel,attr"`
+			Href string `xml:"href,attr"`
+		} `xml:"link"`
+	} `xml:"source"`
+}
+
+// feed returns the feed the entry came from. Its URL is the source's self
+// link, or failing that its ID if that is a URL; "" if neither is.
+func (e venusEntry) feed() sourceFeed {
+	feed := sourceFeed{Title: strings.TrimSpace(e.Source.Title), Active: true}
+	for _, link := range e.Source.Links {
+		switch link.Rel {
+		case "self":
+			feed.URL = strings.TrimSpace(link.Href)
+		case "", "alternate":
+			feed.Link = strings.TrimSpace(link.Href)
+		}
+	}
+	if id := strings.TrimSpace(e.Source.ID); feed.URL == "" && (strings.HasPrefix(id, "http://") || strings.HasPrefix(id, "https://")) {
+		feed.URL = id
+	}
+	return feed
+}
+
+// xmlDecl matches an XML declaration
+var xmlDecl = regexp.MustCompile(`^\s*<\?xml[^>]*\?>`)
+
+// FromVenusCache imports the entries in the cache directory of Planet
+// Venus at dir, one Atom entry per file, normalized by n as if fetched.
+// Entries are taken to have been first seen when they were published.
+// Files that aren't entries, such as the cache's sources and index
+// subdirectories, are skipped.
+func (im *Importer) FromVenusCache(ctx context.Context, dir string, n *normalizer.Normalizer) (Result, error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return Result{}, fmt.Errorf("read Venus cache: %w", err)
+	}
+
+	return im.run(ctx, func(s *session) error {
+		for _, file := range files {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if !file.Type().IsRegular() || strings.HasPrefix(file.Name(), ".") {
+				continue
+			}
+			feed, entry, err := readVenusEntry(ctx, filepath.Join(dir, file.Name()), n)
+			if err != nil {
+				return err
+			}
+			if entry == nil {
+				s.result.Unreadable++
+				continue
+			}
+
+			id, err := s.feedID(ctx, feed)
+			if err != nil {
+				return err
+			}
+			stored := fetcher.StoredEntry(id, *entry)
+			stored.FirstSeen = stored.Published
+			if err := s.store(ctx, stored); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// readVenusEntry reads the entry in a file of a Venus cache, and its feed.
+// The entry is nil if the file isn't an entry from a feed with a URL.
+func readVenusEntry(ctx context.Context, path string, n *normalizer.Normalizer) (sourceFeed, *normalizer.Entry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return sourceFeed{}, nil, fmt.Errorf("read Venus cache: %w", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return sourceFeed{}, nil, fmt.Errorf("read Venus cache: %w", err)
+	}
+
+	data = normalizer.DecodeCharset(data, "")
+	var raw venusEntry
+	if err := xml.Unmarshal(data, &raw); err != nil {
+		return sourceFeed{}, nil, nil
+	}
+	feed := raw.feed()
+	if feed.URL == "" {
+		return sourceFeed{}, nil, nil
+	}
+
+	// The normalizer reads feeds, so give the entry one. Venus sets the
+	// file's modification time to when the entry was last updated.
+	wrapped := append([]byte(`<feed xmlns="http://www.w3.org/2005/Atom">`), xmlDecl.ReplaceAll(data, nil)...)
+	wrapped = append(wrapped, "</feed>"...)
+	_, entries, err := n.Parse(ctx, bytes.TrimSpace(wrapped), feed.URL, info.ModTime())
+	if err != nil || len(entries) != 1 {
+		return sourceFeed{}, nil, nil
+	}
+	return feed, &entries[0], nil
+}
@@ -1,98 +1,92 @@
-// Package logging provides a simple leveled logging interface and implementation.
+// Package logging creates the log/slog loggers rp logs through.
+//
+// Log lines are text (key=value) or JSON, and carry structured fields such
+// as feed_url and feed_id, so one feed's history can be found with grep or
+// jq. A logger's level and format can be changed once the config is loaded
+// with Configure.
 package logging
 
-import "log"
-
-// Logger is the interface for structured logging with levels.
-// Implementations should format messages consistently and respect the configured level.
-type Logger interface {
-	Debug(format string, args ...interface{})
-	Info(format string, args ...interface{})
-	Warn(format string, args ...interface{})
-	Error(format string, args ...interface{})
-}
-
-// Level represents the logging level
-type Level int
+import (
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
 
+// Output formats
 const (
-	LevelError Level = 0
-	LevelWarn  Level = 1
-	LevelInfo  Level = 2
-	LevelDebug Level = 3
+	FormatText = "text"
+	FormatJSON = "json"
 )
 
-// StandardLogger wraps Go's standard logger with level support.
-// Messages are prefixed with their level (ERROR, WARN, INFO, DEBUG).
-type StandardLogger struct {
-	level Level
+// Options configure a logger
+type Options struct {
+	Level     string    // "error", "warn"/"warning", "info", or "debug" (default: info)
+	Format    string    // FormatText (default) or FormatJSON
+	Output    io.Writer // Where log lines go (default: os.Stderr)
+	AddSource bool      // Include the file and line of each log call
 }
 
-// New creates a new StandardLogger with the specified level.
-// Valid levels: "error", "warn"/"warning", "info", "debug".
-// Defaults to info if level is unrecognized.
-func New(levelStr string) *StandardLogger {
-	var level Level
-	switch levelStr {
-	case "error":
-		level = LevelError
-	case "warn", "warning":
-		level = LevelWarn
-	case "info":
-		level = LevelInfo
-	case "debug":
-		level = LevelDebug
-	default:
-		level = LevelInfo
-	}
-	return &StandardLogger{level: level}
+// handler is the slog.Handler behind loggers made by this package. It
+// remembers its options so Configure can rebuild it.
+type handler struct {
+	slog.Handler
+	opts Options
 }
 
-// NewWithLevel creates a new StandardLogger with the specified level constant.
-func NewWithLevel(level Level) *StandardLogger {
-	return &StandardLogger{level: level}
+// New creates a text logger writing to stderr at the given level.
+// Valid levels: "error", "warn"/"warning", "info", "debug".
+// Defaults to info if level is unrecognized.
+func New(level string) *slog.Logger {
+	return NewWithOptions(Options{Level: level})
 }
 
-// SetLevel changes the logger's level at runtime.
-func (l *StandardLogger) SetLevel(levelStr string) {
-	switch levelStr {
-	case "error":
-		l.level = LevelError
-	case "warn", "warning":
-		l.level = LevelWarn
-	case "info":
-		l.level = LevelInfo
-	case "debug":
-		l.level = LevelDebug
-	default:
-		l.level = LevelInfo
+// NewWithOptions creates a logger with the given options
+func NewWithOptions(opts Options) *slog.Logger {
+	if opts.Output == nil {
+		opts.Output = os.Stderr
 	}
-}
-
-// Error logs an error message.
-func (l *StandardLogger) Error(format string, args ...interface{}) {
-	if l.level >= LevelError {
-		log.Printf("ERROR: "+format, args...)
+	handlerOpts := &slog.HandlerOptions{
+		Level:     ParseLevel(opts.Level),
+		AddSource: opts.AddSource,
 	}
-}
-
-// Warn logs a warning message.
-func (l *StandardLogger) Warn(format string, args ...interface{}) {
-	if l.level >= LevelWarn {
-		log.Printf("WARN: "+format, args...)
+	var h slog.Handler
+	if opts.Format == FormatJSON {
+		h = slog.NewJSONHandler(opts.Output, handlerOpts)
+	} else {
+		h = slog.NewTextHandler(opts.Output, handlerOpts)
 	}
+	return slog.New(&handler{Handler: h, opts: opts})
 }
 
-// Info logs an info message.
-func (l *StandardLogger) Info(format string, args ...interface{}) {
-	if l.level >= LevelInfo {
-		log.Printf("INFO: "+format, args...)
+// Configure returns logger with the given level and format (both optional),
+// keeping its output. Loggers not made by this package, such as those tests
+// pass in, are returned unchanged.
+func Configure(logger *slog.Logger, level, format string) *slog.Logger {
+	h, ok := logger.Handler().(*handler)
+	if !ok {
+		return logger
 	}
+	opts := h.opts
+	if level != "" {
+		opts.Level = level
+	}
+	if format != "" {
+		opts.Format = format
+	}
+	return NewWithOptions(opts)
 }
 
-// Debug logs a debug message.
-func (l *StandardLogger) Debug(format string, args ...interface{}) {
-	if l.level >= LevelDebug {
-		log.Printf("DEBUG: "+format, args...)
+// ParseLevel converts a level name to a slog.Level, defaulting to info
+func ParseLevel(level string) slog.Level {
+	switch strings.ToLower(level) {
+	case "error":
+		return slog.LevelError
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "debug":
+		return slog.LevelDebug
+	default:
+		return slog.LevelInfo
 	}
 }
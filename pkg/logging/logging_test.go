@@ -2,187 +2,119 @@ package logging
 
 import (
 	"bytes"
-	"log"
+	"encoding/json"
+	"log/slog"
 	"strings"
 	"testing"
 )
 
-func TestStandardLoggerSetLevel(t *testing.T) {
+func TestParseLevel(t *testing.T) {
 	t.Parallel()
 	tests := []struct {
 		name  string
 		level string
-		want  Level
+		want  slog.Level
 	}{
-		{"error level", "error", LevelError},
-		{"warn level", "warn", LevelWarn},
-		{"warning level", "warning", LevelWarn},
-		{"info level", "info", LevelInfo},
-		{"debug level", "debug", LevelDebug},
-		{"unknown defaults to info", "unknown", LevelInfo},
-		{"empty defaults to info", "", LevelInfo},
+		{"error level", "error", slog.LevelError},
+		{"warn level", "warn", slog.LevelWarn},
+		{"warning level", "warning", slog.LevelWarn},
+		{"info level", "info", slog.LevelInfo},
+		{"debug level", "debug", slog.LevelDebug},
+		{"upper case", "DEBUG", slog.LevelDebug},
+		{"unknown defaults to info", "unknown", slog.LevelInfo},
+		{"empty defaults to info", "", slog.LevelInfo},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			l := NewWithLevel(LevelInfo)
-			l.SetLevel(tt.level)
-			if l.level != tt.want {
-				t.Errorf("SetLevel(%q) level = %d, want %d", tt.level, l.level, tt.want)
+			t.Parallel()
+			if got := ParseLevel(tt.level); got != tt.want {
+				t.Errorf("ParseLevel(%q) = %v, want %v", tt.level, got, tt.want)
 			}
 		})
 	}
 }
 
-func TestStandardLoggerLevelFiltering(t *testing.T) {
+func TestLevelFiltering(t *testing.T) {
+	t.Parallel()
 	tests := []struct {
-		name        string
-		logLevel    string
-		logFunc     func(*StandardLogger)
-		shouldLog   bool
-		containsMsg string
+		name      string
+		logLevel  string
+		logFunc   func(*slog.Logger)
+		shouldLog bool
+		wantMsg   string
 	}{
-		{
-			name:        "error logs at error level",
-			logLevel:    "error",
-			logFunc:     func(l *StandardLogger) { l.Error("test error") },
-			shouldLog:   true,
-			containsMsg: "ERROR: test error",
-		},
-		{
-			name:        "warn does not log at error level",
-			logLevel:    "error",
-			logFunc:     func(l *StandardLogger) { l.Warn("test warn") },
-			shouldLog:   false,
-			containsMsg: "WARN: test warn",
-		},
-		{
-			name:        "info does not log at error level",
-			logLevel:    "error",
-			logFunc:     func(l *StandardLogger) { l.Info("test info") },
-			shouldLog:   false,
-			containsMsg: "INFO: test info",
-		},
-		{
-			name:        "debug does not log at error level",
-			logLevel:    "error",
-			logFunc:     func(l *StandardLogger) { l.Debug("test debug") },
-			shouldLog:   false,
-			containsMsg: "DEBUG: test debug",
-		},
-		{
-			name:        "error logs at warn level",
-			logLevel:    "warn",
-			logFunc:     func(l *StandardLogger) { l.Error("test error") },
-			shouldLog:   true,
-			containsMsg: "ERROR: test error",
-		},
-		{
-			name:        "warn logs at warn level",
-			logLevel:    "warn",
-			logFunc:     func(l *StandardLogger) { l.Warn("test warn") },
-			shouldLog:   true,
-			containsMsg: "WARN: test warn",
-		},
-		{
-			name:        "info does not log at warn level",
-			logLevel:    "warn",
-			logFunc:     func(l *StandardLogger) { l.Info("test info") },
-			shouldLog:   false,
-			containsMsg: "INFO: test info",
-		},
-		{
-			name:        "error logs at info level",
-			logLevel:    "info",
-			logFunc:     func(l *StandardLogger) { l.Error("test error") },
-			shouldLog:   true,
-			containsMsg: "ERROR: test error",
-		},
-		{
-			name:        "warn logs at info level",
-			logLevel:    "info",
-			logFunc:     func(l *StandardLogger) { l.Warn("test warn") },
-			shouldLog:   true,
-			containsMsg: "WARN: test warn",
-		},
-		{
-			name:        "info logs at info level",
-			logLevel:    "info",
-			logFunc:     func(l *StandardLogger) { l.Info("test info") },
-			shouldLog:   true,
-			containsMsg: "INFO: test info",
-		},
-		{
-			name:        "debug does not log at info level",
-			logLevel:    "info",
-			logFunc:     func(l *StandardLogger) { l.Debug("test debug") },
-			shouldLog:   false,
-			containsMsg: "DEBUG: test debug",
-		},
-		{
-			name:        "error logs at debug level",
-			logLevel:    "debug",
-			logFunc:     func(l *StandardLogger) { l.Error("test error") },
-			shouldLog:   true,
-			containsMsg: "ERROR: test error",
-		},
-		{
-			name:        "debug logs at debug level",
-			logLevel:    "debug",
-			logFunc:     func(l *StandardLogger) { l.Debug("test debug") },
-			shouldLog:   true,
-			containsMsg: "DEBUG: test debug",
-		},
+		{"error logs at error level", "error", func(l *slog.Logger) { l.Error("test error") }, true, "level=ERROR msg=\"test error\""},
+		{"warn does not log at error level", "error", func(l *slog.Logger) { l.Warn("test warn") }, false, ""},
+		{"info does not log at error level", "error", func(l *slog.Logger) { l.Info("test info") }, false, ""},
+		{"warn logs at warn level", "warn", func(l *slog.Logger) { l.Warn("test warn") }, true, "level=WARN msg=\"test warn\""},
+		{"info does not log at warn level", "warn", func(l *slog.Logger) { l.Info("test info") }, false, ""},
+		{"info logs at info level", "info", func(l *slog.Logger) { l.Info("test info") }, true, "level=INFO msg=\"test info\""},
+		{"debug does not log at info level", "info", func(l *slog.Logger) { l.Debug("test debug") }, false, ""},
+		{"debug logs at debug level", "debug", func(l *slog.Logger) { l.Debug("test debug") }, true, "level=DEBUG msg=\"test debug\""},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			// Capture log output
+			t.Parallel()
 			var buf bytes.Buffer
-			log.SetOutput(&buf)
-			log.SetFlags(0) // Remove timestamp for easier testing
-			defer func() {
-				log.SetOutput(nil)
-				log.SetFlags(log.LstdFlags)
-			}()
-
-			// Create logger and set level
-			l := New(tt.logLevel)
+			tt.logFunc(NewWithOptions(Options{Level: tt.logLevel, Output: &buf}))
 
-			// Call the log function
-			tt.logFunc(l)
-
-			// Check output
 			output := buf.String()
-			if tt.shouldLog {
-				if !strings.Contains(output, tt.containsMsg) {
-					t.Errorf("Expected log to contain %q, got %q", tt.containsMsg, output)
-				}
-			} else {
-				if output != "" {
-					t.Errorf("Expected no log output at level %q, got %q", tt.logLevel, output)
-				}
+			if tt.shouldLog && !strings.Contains(output, tt.wantMsg) {
+				t.Errorf("Expected log to contain %q, got %q", tt.wantMsg, output)
+			}
+			if !tt.shouldLog && output != "" {
+				t.Errorf("Expected no log output at level %q, got %q", tt.logLevel, output)
 			}
 		})
 	}
 }
 
-func TestStandardLoggerFormatting(t *testing.T) {
+func TestStructuredFields(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	logger := NewWithOptions(Options{Format: FormatJSON, Output: &buf})
+
+	logger.With("feed_url", "https://example.com/feed.xml", "feed_id", 7).Info("Fetched feed", "status", 200)
+
+	var line map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("log line is not JSON: %v\n%s", err, buf.String())
+	}
+	want := map[string]any{
+		"level":    "INFO",
+		"msg":      "Fetched feed",
+		"feed_url": "https://example.com/feed.xml",
+		"feed_id":  float64(7),
+		"status":   float64(200),
+	}
+	for k, v := range want {
+		if line[k] != v {
+			t.Errorf("%s = %v, want %v", k, line[k], v)
+		}
+	}
+}
+
+func TestConfigure(t *testing.T) {
+	t.Parallel()
 	var buf bytes.Buffer
-	log.SetOutput(&buf)
-	log.SetFlags(0)
-	defer func() {
-		log.SetOutput(nil)
-		log.SetFlags(log.LstdFlags)
-	}()
+	logger := NewWithOptions(Options{Level: "info", Output: &buf})
 
-	l := NewWithLevel(LevelDebug)
+	configured := Configure(logger, "debug", FormatJSON)
+	configured.Debug("now visible")
+	if !strings.HasPrefix(buf.String(), "{") || !strings.Contains(buf.String(), `"msg":"now visible"`) {
+		t.Errorf("Configure() did not switch to JSON at debug level: %q", buf.String())
+	}
 
-	l.Info("test %s %d", "message", 42)
+	buf.Reset()
+	Configure(configured, "", "").Debug("kept")
+	if !strings.Contains(buf.String(), `"msg":"kept"`) {
+		t.Errorf("Configure() with empty settings changed the logger: %q", buf.String())
+	}
 
-	output := buf.String()
-	expected := "INFO: test message 42"
-	if !strings.Contains(output, expected) {
-		t.Errorf("Expected log to contain %q, got %q", expected, output)
+	other := slog.New(slog.DiscardHandler)
+	if Configure(other, "debug", FormatJSON) != other {
+		t.Error("Configure() replaced a logger it did not create")
 	}
 }
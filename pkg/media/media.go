@@ -0,0 +1,356 @@
+// Package media caches images from entry content so the generated site
+// serves them itself.
+//
+// Images referenced by <img src> are downloaded through the crawler (with its
+// SSRF checks), checked against a size limit and a format whitelist, and
+// stored under content-addressed names. Entry HTML is then rewritten to point
+// at the local copies. This avoids mixed-content warnings, images vanishing
+// when the source site reorganises, and tracking pixels phoning home.
+package media
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	_ "image/gif"  // Register GIF for DecodeConfig
+	_ "image/jpeg" // Register JPEG for DecodeConfig
+	_ "image/png"  // Register PNG for DecodeConfig
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/html"
+
+	"github.com/adewale/rogue_planet/pkg/crawler"
+)
+
+const (
+	// DefaultMaxSize limits each cached image to 2MB unless configured otherwise
+	DefaultMaxSize = 2 * 1024 * 1024
+	// RetryAfter is how long to wait before trying an image that failed again
+	RetryAfter = 24 * time.Hour
+	// workers is the number of images downloaded concurrently unless
+	// SetWorkers says otherwise
+	workers = 4
+)
+
+// failedExt marks an image that could not be cached when last tried
+const failedExt = ".failed"
+
+// pixelExt marks an image that turned out to be a tracking pixel
+const pixelExt = ".pixel"
+
+// imageTypes maps sniffed content types of accepted images to file extensions
+var imageTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// cacheExts lists every extension a cached file can have
+var cacheExts = []string{".jpg", ".png", ".gif", ".webp", failedExt, pixelExt}
+
+// Cache downloads images into a directory and remembers them between runs
+type Cache struct {
+	dir     string
+	crawler *crawler.Crawler
+	now     func() time.Time
+	workers int
+}
+
+// New creates a Cache that stores images of up to maxSize bytes in dir,
+// fetching them with c
+func New(c *crawler.Crawler, dir string, maxSize int64) *Cache {
+	if maxSize <= 0 {
+		maxSize = DefaultMaxSize
+	}
+	return &Cache{
+		dir:     dir,
+		crawler: c.WithMaxSize(maxSize),
+		now:     time.Now,
+		workers: workers,
+	}
+}
+
+// SetWorkers sets how many images Fetch downloads at once
+func (c *Cache) SetWorkers(n int) {
+	c.workers = max(n, 1)
+}
+
+// Result describes what became of one image URL
+type Result struct {
+	Name  string // Cached file name, relative to the cache directory; empty if not cached
+	Pixel bool   // The image is a tracking pixel and should be dropped
+}
+
+// Fetch caches every image URL not already cached, and returns the outcome
+// for each URL. Images that cannot be cached are left out of the result so
+// references to them are kept as they are.
+func (c *Cache) Fetch(ctx context.Context, urls []string) map[string]Result {
+	results := make(map[string]Result)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, c.workers)
+
+	seen := make(map[string]bool)
+	for _, u := range urls {
+		if seen[u] {
+			continue
+		}
+		seen[u] = true
+
+		wg.Add(1)
+		go func(u string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			if r, ok := c.image(ctx, u); ok {
+				mu.Lock()
+				results[u] = r
+				mu.Unlock()
+			}
+		}(u)
+	}
+	wg.Wait()
+	return results
+}
+
+// image returns the cached outcome for u, downloading it if needed
+func (c *Cache) image(ctx context.Context, u string) (Result, bool) {
+	key := cacheKey(u)
+	for _, p := range c.files(key) {
+		switch filepath.Ext(p) {
+		case pixelExt:
+			return Result{Pixel: true}, true
+		case failedExt:
+			info, err := os.Stat(p)
+			if err == nil && c.now().Sub(info.ModTime()) < RetryAfter {
+				return Result{}, false
+			}
+			os.Remove(p)
+		default:
+			return Result{Name: filepath.Base(p)}, true
+		}
+	}
+
+	resp, err := c.crawler.Fetch(ctx, u, crawler.FeedCache{})
+	if err != nil {
+		if ctx.Err() == nil {
+			c.mark(key, failedExt)
+		}
+		return Result{}, false
+	}
+
+	ext, ok := imageTypes[http.DetectContentType(resp.Body)]
+	if !ok {
+		c.mark(key, failedExt)
+		return Result{}, false
+	}
+	if isPixel(resp.Body) {
+		c.mark(key, pixelExt)
+		return Result{Pixel: true}, true
+	}
+
+	name := key + ext
+	if err := c.store(name, resp.Body); err != nil {
+		return Result{}, false
+	}
+	return Result{Name: name}, true
+}
+
+// isPixel reports whether an image is at most 1x1 pixels, the shape of a
+// tracking beacon. Formats the standard library cannot decode are kept.
+func isPixel(data []byte) bool {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	return err == nil && cfg.Width <= 1 && cfg.Height <= 1
+}
+
+// Prune removes cached images whose names are not in keep and returns how
+// many were removed. Failure and tracking-pixel markers are left in place.
+func (c *Cache) Prune(keep map[string]bool) (int, error) {
+	entries, err := os.ReadDir(c.dir)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("read media directory: %w", err)
+	}
+
+	removed := 0
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || keep[name] {
+			continue
+		}
+		if ext := filepath.Ext(name); ext == failedExt || ext == pixelExt {
+			continue
+		}
+		if err := os.Remove(filepath.Join(c.dir, name)); err != nil {
+			return removed, fmt.Errorf("remove cached image: %w", err)
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// store writes an image atomically
+func (c *Cache) store(name string, data []byte) error {
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return fmt.Errorf("create media directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(c.dir, ".image-*")
+	if err != nil {
+		return fmt.Errorf("create image: %w", err)
+	}
+	_, writeErr := tmp.Write(data)
+	closeErr := tmp.Close()
+	if writeErr == nil {
+		writeErr = closeErr
+	}
+	if writeErr == nil {
+		writeErr = os.Chmod(tmp.Name(), 0644)
+	}
+	if writeErr == nil {
+		writeErr = os.Rename(tmp.Name(), filepath.Join(c.dir, name))
+	}
+	if writeErr != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("store image: %w", writeErr)
+	}
+	return nil
+}
+
+// mark records an outcome for key with an empty marker file
+func (c *Cache) mark(key, ext string) {
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return
+	}
+	_ = os.WriteFile(filepath.Join(c.dir, key+ext), nil, 0644)
+}
+
+// files returns the paths of the files cached for key
+func (c *Cache) files(key string) []string {
+	var paths []string
+	for _, ext := range cacheExts {
+		p := filepath.Join(c.dir, key+ext)
+		if _, err := os.Stat(p); err == nil {
+			paths = append(paths, p)
+		}
+	}
+	return paths
+}
+
+// cacheKey names an image after a hash of its URL
+func cacheKey(u string) string {
+	sum := sha256.Sum256([]byte(u))
+	return hex.EncodeToString(sum[:16])
+}
+
+// ImageURLs returns the absolute http(s) URLs of the images in an HTML
+// fragment, resolving relative ones against base
+func ImageURLs(content, base string) []string {
+	var urls []string
+	forEachImage(content, func(tok html.Token) {
+		if u := imageURL(tok, base); u != "" {
+			urls = append(urls, u)
+		}
+	})
+	return urls
+}
+
+// Rewrite points the images in an HTML fragment at their cached copies,
+// prefix + name, and drops tracking pixels. Images missing from results are
+// left unchanged. srcset is removed from rewritten images, since its other
+// candidates are not cached.
+func Rewrite(content, base string, results map[string]Result, prefix string) string {
+	var out strings.Builder
+	z := html.NewTokenizer(strings.NewReader(content))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			return out.String()
+		}
+		raw := string(z.Raw())
+		if tt != html.StartTagToken && tt != html.SelfClosingTagToken {
+			out.WriteString(raw)
+			continue
+		}
+		tok := z.Token()
+		if tok.Data != "img" {
+			out.WriteString(raw)
+			continue
+		}
+		r, ok := results[imageURL(tok, base)]
+		switch {
+		case !ok:
+			out.WriteString(raw)
+		case r.Pixel:
+			// Drop it
+		default:
+			out.WriteString(imgTag(tok, path.Join(prefix, r.Name)))
+		}
+	}
+}
+
+// forEachImage calls fn for every <img> tag in an HTML fragment
+func forEachImage(content string, fn func(html.Token)) {
+	z := html.NewTokenizer(strings.NewReader(content))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return
+		case html.StartTagToken, html.SelfClosingTagToken:
+			if tok := z.Token(); tok.Data == "img" {
+				fn(tok)
+			}
+		}
+	}
+}
+
+// imageURL returns the absolute http(s) URL of an <img> tag, or ""
+func imageURL(tok html.Token, base string) string {
+	for _, a := range tok.Attr {
+		if a.Key != "src" {
+			continue
+		}
+		ref, err := url.Parse(strings.TrimSpace(a.Val))
+		if err != nil {
+			return ""
+		}
+		if b, err := url.Parse(base); err == nil {
+			ref = b.ResolveReference(ref)
+		}
+		if (ref.Scheme != "http" && ref.Scheme != "https") || ref.Host == "" {
+			return ""
+		}
+		return ref.String()
+	}
+	return ""
+}
+
+// imgTag renders an <img> tag with src replaced and srcset removed
+func imgTag(tok html.Token, src string) string {
+	var b strings.Builder
+	b.WriteString("<img")
+	for _, a := range tok.Attr {
+		switch a.Key {
+		case "srcset":
+			continue
+		case "src":
+			a.Val = src
+		}
+		fmt.Fprintf(&b, ` %s="%s"`, a.Key, html.EscapeString(a.Val))
+	}
+	b.WriteString("/>")
+	return b.String()
+}
@@ -0,0 +1,142 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/adewale/rogue_planet/pkg/crawler"
+)
+
+// pngImage encodes a blank w x h PNG
+func pngImage(t *testing.T, w, h int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, w, h))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// imageServer serves a photo, a tracking pixel, an SVG, and an oversized image
+func imageServer(t *testing.T, requests *atomic.Int32) *httptest.Server {
+	t.Helper()
+	photo := pngImage(t, 4, 3)
+	pixel := pngImage(t, 1, 1)
+	large := pngImage(t, 200, 200)
+	mux := http.NewServeMux()
+	handle := func(p string, body []byte) {
+		mux.HandleFunc(p, func(w http.ResponseWriter, r *http.Request) {
+			requests.Add(1)
+			w.Write(body)
+		})
+	}
+	handle("/photo.png", photo)
+	handle("/pixel.png", pixel)
+	handle("/large.png", append(large, make([]byte, 4096)...))
+	handle("/drawing.svg", []byte(`<svg xmlns="http://www.w3.org/2000/svg"></svg>`))
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestFetchAndRewrite(t *testing.T) {
+	t.Parallel()
+	var requests atomic.Int32
+	server := imageServer(t, &requests)
+	cache := New(crawler.NewForTesting(), t.TempDir(), 4096)
+	base := server.URL + "/posts/1"
+
+	content := `<p>Hello</p><img src="/photo.png" srcset="/photo-2x.png 2x" alt="A &amp; B">` +
+		`<img src="` + server.URL + `/pixel.png" width="1" height="1">` +
+		`<img src="/drawing.svg"><img src="/large.png"><img src="data:image/png;base64,AAAA">`
+
+	urls := ImageURLs(content, base)
+	if len(urls) != 4 {
+		t.Fatalf("ImageURLs() = %v, want 4 http URLs", urls)
+	}
+
+	results := cache.Fetch(context.Background(), urls)
+	photo, ok := results[server.URL+"/photo.png"]
+	if !ok || photo.Name == "" || filepath.Ext(photo.Name) != ".png" {
+		t.Fatalf("photo result = %+v, %v", photo, ok)
+	}
+	if !results[server.URL+"/pixel.png"].Pixel {
+		t.Error("1x1 image should be reported as a tracking pixel")
+	}
+	for _, skipped := range []string{"/drawing.svg", "/large.png"} {
+		if _, ok := results[server.URL+skipped]; ok {
+			t.Errorf("%s should not be cached", skipped)
+		}
+	}
+
+	got := Rewrite(content, base, results, "media")
+	want := `<p>Hello</p><img src="media/` + photo.Name + `" alt="A &amp; B"/>` +
+		`<img src="/drawing.svg"><img src="/large.png"><img src="data:image/png;base64,AAAA">`
+	if got != want {
+		t.Errorf("Rewrite() =\n%s\nwant\n%s", got, want)
+	}
+
+	// Cached, pixel, and failed images are not requested again
+	before := requests.Load()
+	cache.Fetch(context.Background(), urls)
+	if requests.Load() != before {
+		t.Errorf("second Fetch() made %d requests, want 0", requests.Load()-before)
+	}
+
+	// Failures are retried after RetryAfter
+	cache.now = func() time.Time { return time.Now().Add(RetryAfter + time.Hour) }
+	cache.Fetch(context.Background(), urls)
+	if got := requests.Load() - before; got != 2 {
+		t.Errorf("Fetch() after RetryAfter made %d requests, want 2 (the failed images)", got)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	for _, name := range []string{"keep.png", "old.jpg", "abc.failed", "def.pixel"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cache := New(crawler.NewForTesting(), dir, 0)
+
+	removed, err := cache.Prune(map[string]bool{"keep.png": true})
+	if err != nil {
+		t.Fatalf("Prune() error = %v", err)
+	}
+	if removed != 1 {
+		t.Errorf("Prune() removed %d, want 1", removed)
+	}
+	entries, _ := os.ReadDir(dir)
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if got := strings.Join(names, ","); got != "abc.failed,def.pixel,keep.png" {
+		t.Errorf("remaining files = %s", got)
+	}
+
+	missing := New(crawler.NewForTesting(), filepath.Join(dir, "none"), 0)
+	if n, err := missing.Prune(nil); n != 0 || err != nil {
+		t.Errorf("Prune() of missing directory = %d, %v", n, err)
+	}
+}
+
+func TestRewriteLeavesOtherMarkupAlone(t *testing.T) {
+	t.Parallel()
+	content := `<p>Text with <a href="https://example.com/">a link</a> &amp; entities</p><!-- note -->`
+	if got := Rewrite(content, "https://example.com/", nil, "media"); got != content {
+		t.Errorf("Rewrite() = %q, want input unchanged", got)
+	}
+}
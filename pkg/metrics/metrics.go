@@ -0,0 +1,283 @@
+// Package metrics records what each fetch run did and exports it in the
+// Prometheus text format.
+//
+// A Run counts the feeds of one fetch run. A Registry adds up completed
+// runs and keeps the latest one, and can serve them on an HTTP /metrics
+// endpoint or write them to a file for node_exporter's textfile collector.
+// Counters (the _total metrics) cover every run the Registry has seen; the
+// rogue_planet_last_run_* gauges cover the latest run only, so they mean the
+// same thing whether rp runs from cron or as a server.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Outcome is how fetching one feed ended
+type Outcome int
+
+const (
+	Stored      Outcome = iota // Fetched and parsed; its entries were stored
+	NotModified                // The server answered 304 Not Modified
+	Failed                     // The fetch, parse, or write failed
+	Skipped                    // Not fetched: cached, not due, or backing off
+)
+
+// Fetch describes fetching one feed
+type Fetch struct {
+	Outcome  Outcome
+	Entries  int           // Entries stored
+	Bytes    int64         // Body bytes downloaded, before decoding
+	Duration time.Duration // Time spent on the HTTP fetch
+}
+
+// DurationBuckets are the upper bounds, in seconds, of the fetch duration
+// histogram
+var DurationBuckets = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// counts are the counters kept for each run and in total
+type counts struct {
+	fetched     int64 // Feeds requested from their servers, whatever the outcome
+	notModified int64
+	errors      int64
+	skipped     int64
+	entries     int64
+	bytes       int64
+}
+
+func (c *counts) add(o counts) {
+	c.fetched += o.fetched
+	c.notModified += o.notModified
+	c.errors += o.errors
+	c.skipped += o.skipped
+	c.entries += o.entries
+	c.bytes += o.bytes
+}
+
+// counters describes the metrics exported for each count
+var counters = []struct {
+	name, help string
+	value      func(counts) int64
+}{
+	{"feeds_fetched", "Feeds requested from their servers, including those not modified and those that failed.", func(c counts) int64 { return c.fetched }},
+	{"feeds_not_modified", "Feeds whose servers answered 304 Not Modified.", func(c counts) int64 { return c.notModified }},
+	{"fetch_errors", "Feeds whose fetch, parse, or write failed.", func(c counts) int64 { return c.errors }},
+	{"feeds_skipped", "Feeds not fetched because they were cached, not yet due, or backing off.", func(c counts) int64 { return c.skipped }},
+	{"entries_stored", "Entries stored, new or updated.", func(c counts) int64 { return c.entries }},
+	{"downloaded_bytes", "Feed body bytes downloaded, before decoding.", func(c counts) int64 { return c.bytes }},
+}
+
+// histogram counts fetch durations into DurationBuckets
+type histogram struct {
+	buckets []int64 // Observations <= DurationBuckets[i], not cumulative
+	sum     float64
+	count   int64
+}
+
+func (h *histogram) observe(seconds float64) {
+	if h.buckets == nil {
+		h.buckets = make([]int64, len(DurationBuckets))
+	}
+	for i, le := range DurationBuckets {
+		if seconds <= le {
+			h.buckets[i]++
+			break
+		}
+	}
+	h.sum += seconds
+	h.count++
+}
+
+func (h *histogram) add(o histogram) {
+	if h.buckets == nil {
+		h.buckets = make([]int64, len(DurationBuckets))
+	}
+	for i, n := range o.buckets {
+		h.buckets[i] += n
+	}
+	h.sum += o.sum
+	h.count += o.count
+}
+
+// Run collects the fetches of one run. It is safe for concurrent use.
+type Run struct {
+	mu        sync.Mutex
+	start     time.Time
+	end       time.Time
+	counts    counts
+	durations histogram
+}
+
+// NewRun starts a run
+func NewRun(start time.Time) *Run {
+	return &Run{start: start}
+}
+
+// Record adds one feed's fetch to the run
+func (r *Run) Record(f Fetch) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if f.Outcome == Skipped {
+		r.counts.skipped++
+		return
+	}
+	r.counts.fetched++
+	r.counts.entries += int64(f.Entries)
+	r.counts.bytes += f.Bytes
+	switch f.Outcome {
+	case NotModified:
+		r.counts.notModified++
+	case Failed:
+		r.counts.errors++
+	}
+	if f.Duration > 0 {
+		r.durations.observe(f.Duration.Seconds())
+	}
+}
+
+// Finish marks the end of the run
+func (r *Run) Finish(end time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.end = end
+}
+
+// Registry adds up completed runs. It is safe for concurrent use.
+type Registry struct {
+	mu        sync.Mutex
+	runs      int64
+	totals    counts
+	durations histogram
+	last      *Run
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Add records a finished run
+func (reg *Registry) Add(run *Run) {
+	run.mu.Lock()
+	defer run.mu.Unlock()
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	reg.runs++
+	reg.totals.add(run.counts)
+	reg.durations.add(run.durations)
+	reg.last = &Run{start: run.start, end: run.end, counts: run.counts}
+}
+
+// WriteText writes the metrics in the Prometheus text exposition format
+func (reg *Registry) WriteText(w io.Writer) error {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	p := &printer{w: w}
+	p.metric("runs_total", "counter", "Fetch runs completed.", float64(reg.runs))
+	for _, c := range counters {
+		p.metric(c.name+"_total", "counter", c.help, float64(c.value(reg.totals)))
+	}
+
+	p.header("fetch_duration_seconds", "histogram", "Time spent fetching each feed over HTTP, including retries.")
+	var cumulative int64
+	for i, le := range DurationBuckets {
+		if reg.durations.buckets != nil {
+			cumulative += reg.durations.buckets[i]
+		}
+		p.sample("fetch_duration_seconds_bucket", `le="`+formatFloat(le)+`"`, float64(cumulative))
+	}
+	p.sample("fetch_duration_seconds_bucket", `le="+Inf"`, float64(reg.durations.count))
+	p.sample("fetch_duration_seconds_sum", "", reg.durations.sum)
+	p.sample("fetch_duration_seconds_count", "", float64(reg.durations.count))
+
+	if reg.last != nil {
+		last := reg.last
+		p.metric("last_run_timestamp_seconds", "gauge", "When the latest run finished, as a Unix time.", float64(last.end.UnixNano())/1e9)
+		p.metric("last_run_duration_seconds", "gauge", "How long the latest run took.", last.end.Sub(last.start).Seconds())
+		for _, c := range counters {
+			p.metric("last_run_"+c.name, "gauge", c.help+" Latest run only.", float64(c.value(last.counts)))
+		}
+	}
+	return p.err
+}
+
+// Handler serves the metrics, e.g. on /metrics
+func (reg *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		reg.WriteText(w)
+	})
+}
+
+// WriteTextfile writes the metrics to path for node_exporter's textfile
+// collector. The file is replaced atomically, so the collector never reads a
+// partial file; the name should end in .prom.
+func (reg *Registry) WriteTextfile(path string) error {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("create metrics file: %w", err)
+	}
+	writeErr := reg.WriteText(tmp)
+	closeErr := tmp.Close()
+	if writeErr == nil {
+		writeErr = closeErr
+	}
+	if writeErr == nil {
+		writeErr = os.Chmod(tmp.Name(), 0644)
+	}
+	if writeErr == nil {
+		writeErr = os.Rename(tmp.Name(), path)
+	}
+	if writeErr != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("write metrics file: %w", writeErr)
+	}
+	return nil
+}
+
+// printer writes metrics, keeping the first error
+type printer struct {
+	w   io.Writer
+	err error
+}
+
+// namespace prefixes every metric name
+const namespace = "rogue_planet_"
+
+func (p *printer) header(name, kind, help string) {
+	p.printf("# HELP %s%s %s\n# TYPE %s%s %s\n", namespace, name, help, namespace, name, kind)
+}
+
+func (p *printer) sample(name, labels string, value float64) {
+	if labels != "" {
+		labels = "{" + labels + "}"
+	}
+	p.printf("%s%s%s %s\n", namespace, name, labels, formatFloat(value))
+}
+
+func (p *printer) metric(name, kind, help string, value float64) {
+	p.header(name, kind, help)
+	p.sample(name, "", value)
+}
+
+func (p *printer) printf(format string, args ...any) {
+	if p.err == nil {
+		_, p.err = fmt.Fprintf(p.w, format, args...)
+	}
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,123 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRegistry(t *testing.T) {
+	t.Parallel()
+	start := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	reg := NewRegistry()
+	var buf bytes.Buffer
+	if err := reg.WriteText(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "last_run") {
+		t.Errorf("last run metrics written before any run:\n%s", buf.String())
+	}
+
+	first := NewRun(start)
+	first.Record(Fetch{Outcome: Stored, Entries: 3, Bytes: 1000, Duration: 200 * time.Millisecond})
+	first.Record(Fetch{Outcome: NotModified, Bytes: 0, Duration: 50 * time.Millisecond})
+	first.Record(Fetch{Outcome: Failed, Duration: 40 * time.Second})
+	first.Record(Fetch{Outcome: Skipped})
+	first.Finish(start.Add(45 * time.Second))
+	reg.Add(first)
+
+	second := NewRun(start.Add(time.Hour))
+	second.Record(Fetch{Outcome: Failed, Duration: 3 * time.Second})
+	second.Finish(start.Add(time.Hour + 5*time.Second))
+	reg.Add(second)
+
+	buf.Reset()
+	if err := reg.WriteText(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+
+	for _, want := range []string{
+		"# TYPE rogue_planet_runs_total counter\nrogue_planet_runs_total 2\n",
+		"rogue_planet_feeds_fetched_total 4\n",
+		"rogue_planet_feeds_not_modified_total 1\n",
+		"rogue_planet_fetch_errors_total 2\n",
+		"rogue_planet_feeds_skipped_total 1\n",
+		"rogue_planet_entries_stored_total 3\n",
+		"rogue_planet_downloaded_bytes_total 1000\n",
+		"# TYPE rogue_planet_fetch_duration_seconds histogram\n",
+		`rogue_planet_fetch_duration_seconds_bucket{le="0.1"} 1` + "\n",
+		`rogue_planet_fetch_duration_seconds_bucket{le="0.25"} 2` + "\n",
+		`rogue_planet_fetch_duration_seconds_bucket{le="5"} 3` + "\n",
+		`rogue_planet_fetch_duration_seconds_bucket{le="30"} 3` + "\n",
+		`rogue_planet_fetch_duration_seconds_bucket{le="+Inf"} 4` + "\n",
+		"rogue_planet_fetch_duration_seconds_sum 43.25\n",
+		"rogue_planet_fetch_duration_seconds_count 4\n",
+		"# TYPE rogue_planet_last_run_timestamp_seconds gauge\nrogue_planet_last_run_timestamp_seconds 1.740834005e+09\n",
+		"rogue_planet_last_run_duration_seconds 5\n",
+		"rogue_planet_last_run_feeds_fetched 1\n",
+		"rogue_planet_last_run_fetch_errors 1\n",
+		"rogue_planet_last_run_entries_stored 0\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("metrics missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	t.Parallel()
+	reg := NewRegistry()
+	run := NewRun(time.Now())
+	run.Record(Fetch{Outcome: Stored, Entries: 1})
+	run.Finish(time.Now())
+	reg.Add(run)
+
+	rec := httptest.NewRecorder()
+	reg.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	if !strings.Contains(rec.Body.String(), "rogue_planet_entries_stored_total 1\n") {
+		t.Errorf("body missing entries_stored_total:\n%s", rec.Body.String())
+	}
+}
+
+func TestWriteTextfile(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "rogue_planet.prom")
+	if err := os.WriteFile(path, []byte("stale"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	reg := NewRegistry()
+	run := NewRun(time.Now())
+	run.Record(Fetch{Outcome: Failed})
+	run.Finish(time.Now())
+	reg.Add(run)
+	if err := reg.WriteTextfile(path); err != nil {
+		t.Fatalf("WriteTextfile() error = %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "rogue_planet_last_run_fetch_errors 1\n") {
+		t.Errorf("textfile missing last run errors:\n%s", data)
+	}
+	files, _ := os.ReadDir(dir)
+	if len(files) != 1 {
+		t.Errorf("directory has %d files, want only the textfile", len(files))
+	}
+
+	if err := reg.WriteTextfile(filepath.Join(dir, "missing", "x.prom")); err == nil {
+		t.Error("WriteTextfile() into a missing directory succeeded")
+	}
+}
@@ -0,0 +1,56 @@
+package normalizer
+
+import (
+	"mime"
+	"regexp"
+	"unicode/utf8"
+
+	"golang.org/x/net/html/charset"
+	"golang.org/x/text/transform"
+)
+
+// fallbackCharset decodes feeds that are not valid UTF-8 and don't say what
+// they are. Windows-1252 is a superset of ISO-8859-1 in every printable
+// character, and what most such feeds are really in.
+const fallbackCharset = "windows-1252"
+
+// xmlDeclEncoding matches the encoding attribute of an XML declaration,
+// with the value in group 2. A byte order mark may come first.
+var xmlDeclEncoding = regexp.MustCompile(`^(\x{FEFF}?\s*<\?xml[^>]*?\bencoding\s*=\s*["'])([^"']*)(["'])`)
+
+// DecodeCharset returns feedData converted to UTF-8, with any encoding in
+// its XML declaration changed to match. The encoding is the one the XML
+// declaration gives; failing that, the charset of contentType, the feed's
+// HTTP Content-Type (which may be ""); failing that, UTF-8. A feed that
+// claims UTF-8 but isn't valid UTF-8 is decoded as Windows-1252, which is
+// what such feeds almost always are. A feed in an encoding that isn't known
+// is returned as it is.
+func DecodeCharset(feedData []byte, contentType string) []byte {
+	label := ""
+	if m := xmlDeclEncoding.FindSubmatch(feedData); m != nil {
+		label = string(m[2])
+	}
+	if label == "" && contentType != "" {
+		if _, params, err := mime.ParseMediaType(contentType); err == nil {
+			label = params["charset"]
+		}
+	}
+
+	enc, name := charset.Lookup(label)
+	switch {
+	case label == "" || name == "utf-8":
+		if utf8.Valid(feedData) {
+			return feedData
+		}
+		enc, _ = charset.Lookup(fallbackCharset)
+	case enc == nil:
+		// Leave an unknown encoding for the parser to report
+		return feedData
+	}
+
+	decoded, _, err := transform.Bytes(enc.NewDecoder(), feedData)
+	if err != nil {
+		return feedData
+	}
+	return xmlDeclEncoding.ReplaceAll(decoded, []byte("${1}UTF-8${3}"))
+}
@@ -0,0 +1,78 @@
+package normalizer
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+)
+
+func TestDecodeCharset(t *testing.T) {
+	t.Parallel()
+	latin1 := func(s string) string {
+		out, err := charmap.ISO8859_1.NewEncoder().String(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return out
+	}
+	cp1252 := func(s string) string {
+		out, err := charmap.Windows1252.NewEncoder().String(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return out
+	}
+	sjis, err := japanese.ShiftJIS.NewEncoder().String(`<?xml version="1.0" encoding="Shift_JIS"?><title>日本語</title>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name, in, contentType, want string
+	}{
+		{"UTF-8 unchanged", `<?xml version="1.0" encoding="utf-8"?><title>Café</title>`, "", `<?xml version="1.0" encoding="utf-8"?><title>Café</title>`},
+		{"declared ISO-8859-1", latin1(`<?xml version="1.0" encoding="ISO-8859-1"?><title>Café</title>`), "", `<?xml version="1.0" encoding="UTF-8"?><title>Café</title>`},
+		{"declared Shift_JIS", sjis, "", `<?xml version="1.0" encoding="UTF-8"?><title>日本語</title>`},
+		{"charset from HTTP", latin1(`<title>Crème brûlée</title>`), "text/xml; charset=iso-8859-1", `<title>Crème brûlée</title>`},
+		{"declaration beats HTTP", `<?xml version='1.0' encoding='UTF-8'?><title>Café</title>`, "text/xml; charset=iso-8859-1", `<?xml version='1.0' encoding='UTF-8'?><title>Café</title>`},
+		{"invalid UTF-8 read as Windows-1252", cp1252(`<?xml version="1.0" encoding="UTF-8"?><title>“Quoted” – €5</title>`), "", `<?xml version="1.0" encoding="UTF-8"?><title>“Quoted” – €5</title>`},
+		{"unknown encoding left alone", `<?xml version="1.0" encoding="x-klingon"?><title>a</title>`, "", `<?xml version="1.0" encoding="x-klingon"?><title>a</title>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := string(DecodeCharset([]byte(tt.in), tt.contentType)); got != tt.want {
+				t.Errorf("DecodeCharset() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParse_LegacyEncodings(t *testing.T) {
+	t.Parallel()
+	feed, err := charmap.Windows1252.NewEncoder().String(`<?xml version="1.0" encoding="windows-1252"?>
+<rss version="2.0"><channel><title>Café “Notes”</title><link>https://example.com/</link>
+<item><title>Crème – brûlée</title><guid>1</guid></item></channel></rss>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Served without a declaration, as if the server's charset were right
+	undeclared := strings.Replace(feed, ` encoding="windows-1252"`, "", 1)
+
+	for _, data := range []string{feed, undeclared} {
+		meta, entries, err := New().Parse(context.Background(), []byte(data), "https://example.com/feed", time.Now())
+		if err != nil {
+			t.Fatalf("Parse() error = %v", err)
+		}
+		if meta.Title != "Café “Notes”" {
+			t.Errorf("feed title = %q", meta.Title)
+		}
+		if len(entries) != 1 || entries[0].Title != "Crème – brûlée" {
+			t.Errorf("entries = %+v, want one titled Crème – brûlée", entries)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package normalizer
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+)
+
+// Ways of dating an entry that gives no date of its own
+const (
+	UndatedFirstSeen   = "first_seen"   // When rp first fetched it (default)
+	UndatedFeedUpdated = "feed_updated" // The feed's updated date, or when first fetched if it has none
+	UndatedSkip        = "skip"         // Not stored at all
+)
+
+// Ways of treating an entry dated after it was fetched
+const (
+	FutureClamp = "clamp" // Dated when rp first fetched it instead (default)
+	FutureKeep  = "keep"  // Dated as the feed says
+	FutureSkip  = "skip"  // Not stored until its date has passed
+)
+
+var (
+	ErrUndated    = errors.New("entry has no date")
+	ErrFutureDate = errors.New("entry is dated in the future")
+)
+
+// DatePolicy says what the normalizer does with entries that have no date,
+// or a date later than the fetch. The zero value dates both when fetched.
+type DatePolicy struct {
+	Undated string // UndatedFirstSeen, UndatedFeedUpdated, or UndatedSkip
+	Future  string // FutureClamp, FutureKeep, or FutureSkip
+}
+
+// SetDatePolicy sets how undated and future-dated entries are handled.
+// Since an entry's published date is stored only when it is first seen,
+// dating entries when fetched keeps them where they first appeared in the
+// river rather than jumping to the top on every fetch.
+func (n *Normalizer) SetDatePolicy(p DatePolicy) {
+	n.dates = p
+}
+
+// SetFeedTimezones makes the normalizer read the timestamps of the feeds in
+// zones, keyed by feed URL, as times in the feed's zone: the date and time
+// they give are kept and any offset they give is replaced. This corrects
+// feeds that give times without an offset, which are read as UTC, or with
+// the wrong one.
+func (n *Normalizer) SetFeedTimezones(zones map[string]*time.Location) {
+	n.feedZone = zones
+}
+
+// inFeedZone corrects a timestamp from the feed at feedURL for its time zone
+func (n *Normalizer) inFeedZone(feedURL string, t time.Time) time.Time {
+	loc := n.feedZone[feedURL]
+	if loc == nil {
+		return t
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), loc)
+}
+
+// entryDates returns the published and updated dates of an item under the
+// normalizer's date policy, or an error if the policy skips the item
+func (n *Normalizer) entryDates(item *gofeed.Item, feed *gofeed.Feed, feedURL string, fetchTime time.Time) (published, updated time.Time, err error) {
+	published, dated := n.extractPublished(item)
+	if dated {
+		published = n.inFeedZone(feedURL, published)
+	} else {
+		switch n.dates.Undated {
+		case UndatedSkip:
+			return time.Time{}, time.Time{}, ErrUndated
+		case UndatedFeedUpdated:
+			published = fetchTime
+			if feed.UpdatedParsed != nil && !feed.UpdatedParsed.IsZero() {
+				published = n.inFeedZone(feedURL, *feed.UpdatedParsed)
+			}
+		default:
+			published = fetchTime
+		}
+	}
+	updated, ok := n.extractUpdated(item)
+	if ok {
+		updated = n.inFeedZone(feedURL, updated)
+	} else {
+		updated = published
+	}
+
+	if n.dates.Future == FutureKeep {
+		return published, updated, nil
+	}
+	if published.After(fetchTime) {
+		if n.dates.Future == FutureSkip {
+			return time.Time{}, time.Time{}, fmt.Errorf("%w: %s", ErrFutureDate, published.Format(time.RFC3339))
+		}
+		published = fetchTime
+	}
+	if updated.After(fetchTime) {
+		updated = fetchTime
+	}
+	return published, updated, nil
+}
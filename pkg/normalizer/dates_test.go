@@ -0,0 +1,189 @@
+package normalizer
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse_DatePolicy(t *testing.T) {
+	t.Parallel()
+	fetchTime := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	feedUpdated := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+	future := time.Date(2035, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// Like Venus' tests of entries with no date, one feed with an undated,
+	// a future-dated, and a well-dated entry
+	feed := `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+  <title>Test Feed</title>
+  <id>tag:example.com,2026:feed</id>
+  <updated>2026-02-01T00:00:00Z</updated>
+  <entry><title>No date</title><id>undated</id><link href="https://example.com/undated"/></entry>
+  <entry><title>Future</title><id>future</id><link href="https://example.com/future"/>
+    <published>2035-01-01T00:00:00Z</published><updated>2035-01-01T00:00:00Z</updated></entry>
+  <entry><title>Dated</title><id>dated</id><link href="https://example.com/dated"/>
+    <published>2026-01-15T00:00:00Z</published><updated>2040-01-01T00:00:00Z</updated></entry>
+</feed>`
+	dated := time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		policy        DatePolicy
+		wantPublished map[string]time.Time // By ID; entries not listed must be skipped
+		wantUpdated   map[string]time.Time
+	}{
+		{
+			name:          "defaults date by fetch",
+			policy:        DatePolicy{},
+			wantPublished: map[string]time.Time{"undated": fetchTime, "future": fetchTime, "dated": dated},
+			wantUpdated:   map[string]time.Time{"undated": fetchTime, "future": fetchTime, "dated": fetchTime},
+		},
+		{
+			name:          "first_seen and clamp",
+			policy:        DatePolicy{Undated: UndatedFirstSeen, Future: FutureClamp},
+			wantPublished: map[string]time.Time{"undated": fetchTime, "future": fetchTime, "dated": dated},
+			wantUpdated:   map[string]time.Time{"undated": fetchTime, "future": fetchTime, "dated": fetchTime},
+		},
+		{
+			name:          "feed_updated and keep",
+			policy:        DatePolicy{Undated: UndatedFeedUpdated, Future: FutureKeep},
+			wantPublished: map[string]time.Time{"undated": feedUpdated, "future": future, "dated": dated},
+			wantUpdated:   map[string]time.Time{"undated": feedUpdated, "future": future, "dated": time.Date(2040, 1, 1, 0, 0, 0, 0, time.UTC)},
+		},
+		{
+			name:          "skip both",
+			policy:        DatePolicy{Undated: UndatedSkip, Future: FutureSkip},
+			wantPublished: map[string]time.Time{"dated": dated},
+			wantUpdated:   map[string]time.Time{"dated": fetchTime},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			n := New()
+			n.SetDatePolicy(tt.policy)
+			_, entries, err := n.Parse(context.Background(), []byte(feed), "https://example.com/feed", fetchTime)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if len(entries) != len(tt.wantPublished) {
+				t.Fatalf("got %d entries, want %d", len(entries), len(tt.wantPublished))
+			}
+			for _, e := range entries {
+				want, ok := tt.wantPublished[e.ID]
+				if !ok {
+					t.Errorf("entry %q should have been skipped", e.ID)
+					continue
+				}
+				if !e.Published.Equal(want) {
+					t.Errorf("entry %q Published = %v, want %v", e.ID, e.Published, want)
+				}
+				if !e.Updated.Equal(tt.wantUpdated[e.ID]) {
+					t.Errorf("entry %q Updated = %v, want %v", e.ID, e.Updated, tt.wantUpdated[e.ID])
+				}
+			}
+		})
+	}
+}
+
+func TestValidate_DatePolicy(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	feed := `<rss version="2.0"><channel><title>Blog</title><link>https://example.com/</link><description>A blog</description>
+<item><title>One</title><link>https://example.com/one</link><guid>one</guid></item>
+<item><title>Two</title><link>https://example.com/two</link><guid>two</guid><pubDate>Mon, 01 Jan 2035 00:00:00 GMT</pubDate></item>
+</channel></rss>`
+
+	n := New()
+	n.SetDatePolicy(DatePolicy{Undated: UndatedSkip, Future: FutureSkip})
+	report, err := n.Validate(context.Background(), []byte(feed), "https://example.com/feed", "", now)
+	if err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if len(report.Entries) != 0 {
+		t.Errorf("got %d entries, want both skipped", len(report.Entries))
+	}
+	var problems []string
+	for _, p := range report.Problems {
+		problems = append(problems, p.Message)
+	}
+	got := strings.Join(problems, "\n")
+	for _, want := range []string{"rp would skip this entry: entry has no date", "rp would skip this entry: entry is dated in the future: 2035-01-01T00:00:00Z"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("problems missing %q:\n%s", want, got)
+		}
+	}
+	if len(report.Problems) != 2 {
+		t.Errorf("got %d problems, want 2:\n%s", len(report.Problems), got)
+	}
+}
+
+func TestParse_FeedTimezone(t *testing.T) {
+	t.Parallel()
+	la, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		t.Skipf("no time zone database: %v", err)
+	}
+	fetchTime := time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)
+	// A feed that writes Pacific times as if they were UTC
+	feed := `<rss version="2.0" xmlns:dc="http://purl.org/dc/elements/1.1/"><channel><title>Blog</title>
+<lastBuildDate>Sat, 07 Mar 2026 09:00:00 +0000</lastBuildDate>
+<item><title>One</title><guid>one</guid><pubDate>Sat, 07 Mar 2026 09:00:00 +0000</pubDate></item>
+<item><title>Two</title><guid>two</guid><dc:date>2026-03-08T09:00:00</dc:date></item>
+</channel></rss>`
+
+	tests := []struct {
+		name    string
+		zones   map[string]*time.Location
+		want    map[string]time.Time
+		updated time.Time
+	}{
+		{
+			name:    "no correction",
+			want:    map[string]time.Time{"one": time.Date(2026, 3, 7, 9, 0, 0, 0, time.UTC), "two": time.Date(2026, 3, 8, 9, 0, 0, 0, time.UTC)},
+			updated: time.Date(2026, 3, 7, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			name:  "named zone, across a daylight saving change",
+			zones: map[string]*time.Location{"https://example.com/feed": la},
+			// PST is UTC-8 until 2026-03-08 02:00, then PDT is UTC-7
+			want:    map[string]time.Time{"one": time.Date(2026, 3, 7, 17, 0, 0, 0, time.UTC), "two": time.Date(2026, 3, 8, 16, 0, 0, 0, time.UTC)},
+			updated: time.Date(2026, 3, 7, 17, 0, 0, 0, time.UTC),
+		},
+		{
+			name:    "other feeds are left alone",
+			zones:   map[string]*time.Location{"https://other.example.com/feed": la},
+			want:    map[string]time.Time{"one": time.Date(2026, 3, 7, 9, 0, 0, 0, time.UTC), "two": time.Date(2026, 3, 8, 9, 0, 0, 0, time.UTC)},
+			updated: time.Date(2026, 3, 7, 9, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			n := New()
+			n.SetFeedTimezones(tt.zones)
+			metadata, entries, err := n.Parse(context.Background(), []byte(feed), "https://example.com/feed", fetchTime)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if !metadata.Updated.Equal(tt.updated) {
+				t.Errorf("feed Updated = %v, want %v", metadata.Updated, tt.updated)
+			}
+			if len(entries) != len(tt.want) {
+				t.Fatalf("got %d entries, want %d", len(entries), len(tt.want))
+			}
+			for _, e := range entries {
+				if !e.Published.Equal(tt.want[e.ID]) {
+					t.Errorf("entry %q Published = %v, want %v", e.ID, e.Published.UTC(), tt.want[e.ID])
+				}
+				if !e.Updated.Equal(tt.want[e.ID]) {
+					t.Errorf("entry %q Updated = %v, want %v", e.ID, e.Updated.UTC(), tt.want[e.ID])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package normalizer
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+)
+
+// Namespaces of RSS 1.0 and 0.90, whose items are identified by rdf:about
+const (
+	rdfNS    = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+	rss10NS  = "http://purl.org/rss/1.0/"
+	rss090NS = "http://my.netscape.com/rdf/simple/0.9/"
+)
+
+// w3cDateFormats are the W3C date and time formats Dublin Core dates use,
+// with the RSS 2.0 ones some feeds put there instead
+var w3cDateFormats = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	"2006-01",
+	"2006",
+	time.RFC1123Z,
+	time.RFC1123,
+}
+
+// parseW3CDate parses a date in one of w3cDateFormats
+func parseW3CDate(s string) (time.Time, bool) {
+	s = strings.TrimSpace(s)
+	for _, layout := range w3cDateFormats {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// dcDate returns the first of the item's Dublin Core elements (dc:date) and
+// DCMI terms (dcterms:issued and the like) named by names that holds a date
+func dcDate(item *gofeed.Item, names ...string) (time.Time, bool) {
+	for _, name := range names {
+		for _, v := range dcValues(item, name) {
+			if t, ok := parseW3CDate(v); ok {
+				return t, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// hasDCDate reports whether the item has any Dublin Core date
+func hasDCDate(item *gofeed.Item) bool {
+	_, ok := dcDate(item, "date", "issued", "created", "available", "modified")
+	return ok
+}
+
+// dcCreator returns the item's dc:creator, which the RSS translator already
+// uses as its author but the Atom one does not
+func dcCreator(item *gofeed.Item) string {
+	for _, c := range dcValues(item, "creator") {
+		if c = strings.TrimSpace(c); c != "" {
+			return c
+		}
+	}
+	return ""
+}
+
+// dcValues returns the values of the item's Dublin Core elements and DCMI
+// terms named name. The parser only fills in DublinCoreExt for RSS, so
+// Atom and JSON items have them as plain extensions.
+func dcValues(item *gofeed.Item, name string) []string {
+	var values []string
+	for _, prefix := range []string{"dc", "dcterms"} {
+		for _, e := range item.Extensions[prefix][name] {
+			values = append(values, e.Value)
+		}
+	}
+	return values
+}
+
+// fillFromRDF gives the items of an RSS 1.0 or 0.90 feed their rdf:about
+// URI as GUID, and as link when they have none. The parser drops it, so it
+// is read from feedData.
+func fillFromRDF(feed *gofeed.Feed, feedData []byte) {
+	if feed.FeedType != "rss" || (feed.FeedVersion != "1.0" && feed.FeedVersion != "0.9") {
+		return
+	}
+	abouts := rdfAbouts(feedData)
+	// Only trust a reading that found each item the parser did
+	if len(abouts) != len(feed.Items) {
+		return
+	}
+	for i, item := range feed.Items {
+		if abouts[i] == "" {
+			continue
+		}
+		if item.GUID == "" {
+			item.GUID = abouts[i]
+		}
+		if item.Link == "" {
+			item.Link = abouts[i]
+		}
+	}
+}
+
+// rdfAbouts returns the rdf:about attribute of each item in an RDF feed,
+// in order, with "" for an item without one
+func rdfAbouts(feedData []byte) []string {
+	d := xml.NewDecoder(bytes.NewReader(feedData))
+	d.Strict = false
+	d.Entity = xml.HTMLEntity
+
+	var abouts []string
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return abouts
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "item" || (start.Name.Space != rss10NS && start.Name.Space != rss090NS) {
+			continue
+		}
+		about := ""
+		for _, a := range start.Attr {
+			if a.Name.Local == "about" && a.Name.Space == rdfNS {
+				about = strings.TrimSpace(a.Value)
+			}
+		}
+		abouts = append(abouts, about)
+		if err := d.Skip(); err != nil {
+			return abouts
+		}
+	}
+}
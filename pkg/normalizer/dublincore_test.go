@@ -0,0 +1,110 @@
+package normalizer
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestParse_RDF(t *testing.T) {
+	t.Parallel()
+	feed := `<?xml version="1.0"?>
+<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#" xmlns="http://purl.org/rss/1.0/" xmlns:dublin="http://purl.org/dc/elements/1.1/">
+<channel rdf:about="https://example.edu/">
+  <title>Lab Notes</title><link>https://example.edu/</link><description>Research</description>
+  <items><rdf:Seq><rdf:li rdf:resource="https://example.edu/1"/><rdf:li rdf:resource="https://example.edu/2"/></rdf:Seq></items>
+</channel>
+<item rdf:about="https://example.edu/1">
+  <title>First</title><link>https://example.edu/1?utm=rss</link>
+  <dublin:date>2024-01-01T10:00+01:00</dublin:date><dublin:creator>Prof. Ada</dublin:creator>
+  <description>One&nbsp;result</description>
+</item>
+<item rdf:about="https://example.edu/2">
+  <title>Second</title>
+  <dublin:date>2024-02-03</dublin:date>
+</item>
+</rdf:RDF>`
+	meta, entries, err := New().Parse(context.Background(), []byte(feed), "https://example.edu/rss", time.Now())
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if meta.Title != "Lab Notes" || len(entries) != 2 {
+		t.Fatalf("Parse() = %q with %d entries, want Lab Notes with 2", meta.Title, len(entries))
+	}
+
+	first, second := entries[0], entries[1]
+	if first.ID != "https://example.edu/1" || first.Link != "https://example.edu/1?utm=rss" {
+		t.Errorf("first entry ID, Link = %q, %q, want rdf:about and its own link", first.ID, first.Link)
+	}
+	if first.Author != "Prof. Ada" {
+		t.Errorf("first entry Author = %q, want the dc:creator", first.Author)
+	}
+	if want := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC); !first.Published.Equal(want) {
+		t.Errorf("first entry Published = %v, want %v", first.Published, want)
+	}
+	// An item without a link is linked to its rdf:about
+	if second.ID != "https://example.edu/2" || second.Link != "https://example.edu/2" {
+		t.Errorf("second entry ID, Link = %q, %q, want its rdf:about", second.ID, second.Link)
+	}
+	if want := time.Date(2024, 2, 3, 0, 0, 0, 0, time.UTC); !second.Published.Equal(want) {
+		t.Errorf("second entry Published = %v, want %v", second.Published, want)
+	}
+}
+
+func TestParse_DublinCore(t *testing.T) {
+	t.Parallel()
+	fetchTime := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name          string
+		feed          string
+		wantAuthor    string
+		wantPublished time.Time
+		wantUpdated   time.Time
+	}{
+		{
+			name: "Atom with dc:date and dc:creator",
+			feed: `<feed xmlns="http://www.w3.org/2005/Atom" xmlns:dc="http://purl.org/dc/elements/1.1/"><title>A</title>
+<entry><id>urn:1</id><title>t</title><dc:date>2024-05-06T07:08:09Z</dc:date><dc:creator>Ann</dc:creator></entry></feed>`,
+			wantAuthor:    "Ann",
+			wantPublished: time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC),
+			wantUpdated:   time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC),
+		},
+		{
+			name: "RSS with DCMI terms",
+			feed: `<rss version="2.0" xmlns:dcterms="http://purl.org/dc/terms/"><channel><title>A</title>
+<item><guid>1</guid><title>t</title><dcterms:created>2024-01-01</dcterms:created><dcterms:modified>2024-03-01T12:00:00Z</dcterms:modified></item></channel></rss>`,
+			wantPublished: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			wantUpdated:   time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "item author beats dc:creator",
+			feed: `<feed xmlns="http://www.w3.org/2005/Atom" xmlns:dc="http://purl.org/dc/elements/1.1/"><title>A</title>
+<entry><id>urn:1</id><title>t</title><updated>2024-01-01T00:00:00Z</updated><author><name>Bea</name></author><dc:creator>Ann</dc:creator></entry></feed>`,
+			wantAuthor:    "Bea",
+			wantPublished: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			wantUpdated:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			_, entries, err := New().Parse(context.Background(), []byte(tt.feed), "https://example.com/feed", fetchTime)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if len(entries) != 1 {
+				t.Fatalf("Parse() returned %d entries, want 1", len(entries))
+			}
+			e := entries[0]
+			if e.Author != tt.wantAuthor {
+				t.Errorf("Author = %q, want %q", e.Author, tt.wantAuthor)
+			}
+			if !e.Published.Equal(tt.wantPublished) {
+				t.Errorf("Published = %v, want %v", e.Published, tt.wantPublished)
+			}
+			if !e.Updated.Equal(tt.wantUpdated) {
+				t.Errorf("Updated = %v, want %v", e.Updated, tt.wantUpdated)
+			}
+		})
+	}
+}
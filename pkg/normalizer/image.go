@@ -0,0 +1,87 @@
+package normalizer
+
+import (
+	"strings"
+
+	"github.com/mmcdole/gofeed"
+	ext "github.com/mmcdole/gofeed/extensions"
+	"golang.org/x/net/html"
+)
+
+// extractImage picks a representative image for an entry: its Media RSS
+// thumbnail, else an image in its media:content or the image gofeed found
+// (an iTunes image, image enclosure, or the first image of the raw item),
+// else the first image in the sanitized content, else the feed's own Media
+// RSS thumbnail, which Media RSS applies to every item. Relative URLs are
+// resolved against the entry's link. Images the feed's sanitization policy
+// would strip from content are not used.
+func (n *Normalizer) extractImage(item *gofeed.Item, feed *gofeed.Feed, entry Entry, feedURL string) string {
+	var candidates []string
+	candidates = append(candidates, mediaURLs(item.Extensions, "thumbnail")...)
+	candidates = append(candidates, mediaURLs(item.Extensions, "content")...)
+	if item.Image != nil {
+		candidates = append(candidates, item.Image.URL)
+	}
+	candidates = append(candidates, firstImage(entry.Content))
+	candidates = append(candidates, mediaURLs(feed.Extensions, "thumbnail")...)
+
+	base := entry.Link
+	if base == "" {
+		base = feedURL
+	}
+	for _, c := range candidates {
+		c = strings.TrimSpace(c)
+		if c == "" {
+			continue
+		}
+		abs, err := n.resolveURL(c, base)
+		if err != nil || !(strings.HasPrefix(abs, "http://") || strings.HasPrefix(abs, "https://")) {
+			continue
+		}
+		// The policy decides, as it would for an <img> in the content
+		if !strings.Contains(n.sanitizeHTML(`<img src="`+html.EscapeString(abs)+`">`, feedURL), "<img") {
+			return ""
+		}
+		return abs
+	}
+	return ""
+}
+
+// mediaURLs returns the urls of the item's media:<name> elements, including
+// those inside a media:group, that are images
+func mediaURLs(extensions ext.Extensions, name string) []string {
+	var urls []string
+	for _, e := range mediaElements(extensions, name) {
+		// Thumbnails are always images; media:content may be audio or video
+		if name == "content" && !strings.HasPrefix(e.Attrs["type"], "image/") && e.Attrs["medium"] != "image" {
+			continue
+		}
+		if u := e.Attrs["url"]; u != "" {
+			urls = append(urls, u)
+		}
+	}
+	return urls
+}
+
+// firstImage returns the src of the first <img> in an HTML fragment
+func firstImage(fragment string) string {
+	z := html.NewTokenizer(strings.NewReader(fragment))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return ""
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			if string(name) != "img" {
+				continue
+			}
+			for hasAttr {
+				var key, val []byte
+				key, val, hasAttr = z.TagAttr()
+				if string(key) == "src" {
+					return string(val)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package normalizer
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+
+	"github.com/mmcdole/gofeed"
+	ext "github.com/mmcdole/gofeed/extensions"
+	"github.com/mmcdole/gofeed/json"
+)
+
+// jsonFeedExt is the extension prefix under which fillFromJSONFeed keeps the
+// members of JSON Feed items the universal feed has no field for, as the
+// parser keeps XML elements it doesn't know under their namespace prefix
+const jsonFeedExt = "jsonfeed"
+
+// fillFromJSONFeed adds the external_url, language, and attachments of each
+// item of a JSON Feed to its extensions. The parser drops the first two and
+// gives attachments as enclosures with their duration for their size, so
+// they are read from feedData and the enclosures dropped.
+func fillFromJSONFeed(feed *gofeed.Feed, feedData []byte) {
+	if feed.FeedType != "json" {
+		return
+	}
+	raw, err := (&json.Parser{}).Parse(bytes.NewReader(feedData))
+	// Only trust a reading that found each item the parser did
+	if err != nil || len(raw.Items) != len(feed.Items) {
+		return
+	}
+	for i, r := range raw.Items {
+		item := feed.Items[i]
+		item.Enclosures = nil
+		if item.Extensions == nil {
+			item.Extensions = ext.Extensions{}
+		}
+		members := make(map[string][]ext.Extension)
+		if u := strings.TrimSpace(r.ExternalURL); u != "" {
+			members["external_url"] = []ext.Extension{{Name: "external_url", Value: u}}
+		}
+		if lang := strings.TrimSpace(r.Language); lang != "" {
+			members["language"] = []ext.Extension{{Name: "language", Value: lang}}
+		}
+		if r.Attachments != nil {
+			for _, a := range *r.Attachments {
+				members["attachment"] = append(members["attachment"], ext.Extension{
+					Name: "attachment",
+					Attrs: map[string]string{
+						"url":                 a.URL,
+						"mime_type":           a.MimeType,
+						"title":               a.Title,
+						"size_in_bytes":       strconv.FormatInt(a.SizeInBytes, 10),
+						"duration_in_seconds": strconv.FormatInt(a.DurationInSeconds, 10),
+					},
+				})
+			}
+		}
+		item.Extensions[jsonFeedExt] = members
+	}
+}
+
+// jsonFeedValue returns the value of a member fillFromJSONFeed kept, or ""
+func jsonFeedValue(item *gofeed.Item, name string) string {
+	for _, e := range item.Extensions[jsonFeedExt][name] {
+		return e.Value
+	}
+	return ""
+}
+
+// extractAttachments returns the item's JSON Feed attachments, Media RSS
+// content, and enclosures, with URLs made absolute and the first of any
+// with the same URL kept. Attachments that aren't on the web are left out.
+func (n *Normalizer) extractAttachments(item *gofeed.Item, feedURL string) []Attachment {
+	var attachments []Attachment
+	for _, e := range item.Extensions[jsonFeedExt]["attachment"] {
+		size, _ := strconv.ParseInt(e.Attrs["size_in_bytes"], 10, 64)
+		duration, _ := strconv.Atoi(e.Attrs["duration_in_seconds"])
+		attachments = append(attachments, Attachment{URL: e.Attrs["url"], MimeType: e.Attrs["mime_type"], Title: e.Attrs["title"], Size: size, Duration: duration})
+	}
+	attachments = append(attachments, mediaAttachments(item)...)
+	for _, e := range item.Enclosures {
+		if e == nil {
+			continue
+		}
+		size, _ := strconv.ParseInt(strings.TrimSpace(e.Length), 10, 64)
+		attachments = append(attachments, Attachment{URL: e.URL, MimeType: e.Type, Size: size})
+	}
+
+	kept := attachments[:0]
+	seen := make(map[string]int)
+	for _, a := range attachments {
+		if a.URL = n.webURL(a.URL, feedURL); a.URL == "" {
+			continue
+		}
+		a.MimeType, a.Title = strings.TrimSpace(a.MimeType), strings.TrimSpace(a.Title)
+		if a.Medium == "" {
+			a.Medium = mediaMedium(a.MimeType)
+		}
+		if a.Thumbnail != "" {
+			a.Thumbnail = n.webURL(a.Thumbnail, feedURL)
+		}
+		// An RSS item often gives its media as both media:content and an
+		// enclosure; the first has more to say
+		if i, ok := seen[a.URL]; ok {
+			if kept[i].Size == 0 {
+				kept[i].Size = a.Size
+			}
+			if kept[i].MimeType == "" {
+				kept[i].MimeType = a.MimeType
+			}
+			continue
+		}
+		seen[a.URL] = len(kept)
+		kept = append(kept, a)
+	}
+	if len(kept) == 0 {
+		return nil
+	}
+	return kept
+}
+
+// extractAuthors returns the names of all the item's own authors, in
+// order, when it names any
+func extractAuthors(item *gofeed.Item) []string {
+	people := item.Authors
+	if len(people) == 0 {
+		people = []*gofeed.Person{item.Author}
+	}
+	var names []string
+	seen := make(map[string]bool)
+	for _, p := range people {
+		if p == nil {
+			continue
+		}
+		name := strings.TrimSpace(p.Name)
+		if name == "" {
+			name = strings.TrimSpace(p.Email)
+		}
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	return names
+}
+
+// extractLanguage returns the language of the item (from JSON Feed), else
+// of its feed
+func extractLanguage(item *gofeed.Item, feed *gofeed.Feed) string {
+	if lang := jsonFeedValue(item, "language"); lang != "" {
+		return lang
+	}
+	return strings.TrimSpace(feed.Language)
+}
+
+// webURL returns href made absolute against base if it is an http or https
+// URL, or ""
+func (n *Normalizer) webURL(href, base string) string {
+	href = strings.TrimSpace(href)
+	if href == "" {
+		return ""
+	}
+	abs, err := n.resolveURL(href, base)
+	if err != nil || !(strings.HasPrefix(abs, "http://") || strings.HasPrefix(abs, "https://")) {
+		return ""
+	}
+	return abs
+}
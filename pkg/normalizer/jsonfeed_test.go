@@ -0,0 +1,93 @@
+package normalizer
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParse_JSONFeedExtensions(t *testing.T) {
+	t.Parallel()
+	feed := `{
+  "version": "https://jsonfeed.org/version/1.1",
+  "title": "Links",
+  "home_page_url": "https://example.com/",
+  "language": "en",
+  "items": [
+    {
+      "id": "1",
+      "url": "https://example.com/1",
+      "external_url": "https://elsewhere.example.org/story",
+      "content_text": "Worth reading.",
+      "language": "fr-CA",
+      "authors": [{"name": "Ann"}, {"name": "Bob"}, {"name": "Ann"}],
+      "attachments": [
+        {"url": "/ep1.mp3", "mime_type": "audio/mpeg", "title": "Episode 1", "size_in_bytes": 1048576, "duration_in_seconds": 1800},
+        {"url": "javascript:alert(1)", "mime_type": "text/html"}
+      ]
+    },
+    {
+      "id": "2",
+      "url": "https://example.com/2",
+      "content_html": "<p>Plain</p>",
+      "author": {"name": "Cy"}
+    }
+  ]
+}`
+	_, entries, err := New().Parse(context.Background(), []byte(feed), "https://example.com/feed.json", time.Now())
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(entries))
+	}
+
+	first := entries[0]
+	if first.ExternalURL != "https://elsewhere.example.org/story" {
+		t.Errorf("ExternalURL = %q", first.ExternalURL)
+	}
+	if first.Language != "fr-CA" {
+		t.Errorf("Language = %q, want fr-CA", first.Language)
+	}
+	if want := []string{"Ann", "Bob"}; !reflect.DeepEqual(first.Authors, want) || first.Author != "Ann" {
+		t.Errorf("Author = %q, Authors = %q; want Ann, %q", first.Author, first.Authors, want)
+	}
+	wantAttachments := []Attachment{{URL: "https://example.com/ep1.mp3", MimeType: "audio/mpeg", Title: "Episode 1", Size: 1048576, Duration: 1800, Medium: "audio"}}
+	if !reflect.DeepEqual(first.Attachments, wantAttachments) {
+		t.Errorf("Attachments = %+v, want %+v", first.Attachments, wantAttachments)
+	}
+
+	second := entries[1]
+	if second.ExternalURL != "" || second.Attachments != nil {
+		t.Errorf("second entry ExternalURL = %q, Attachments = %+v; want none", second.ExternalURL, second.Attachments)
+	}
+	if second.Language != "en" {
+		t.Errorf("second entry Language = %q, want the feed's, en", second.Language)
+	}
+	if !reflect.DeepEqual(second.Authors, []string{"Cy"}) {
+		t.Errorf("second entry Authors = %q, want [Cy]", second.Authors)
+	}
+}
+
+func TestParse_Enclosures(t *testing.T) {
+	t.Parallel()
+	feed := `<rss version="2.0"><channel><title>Podcast</title><language>en-gb</language>
+<item><title>Episode</title><guid>ep</guid>
+<enclosure url="https://example.com/ep.mp3" length="12345" type="audio/mpeg"/></item>
+</channel></rss>`
+	_, entries, err := New().Parse(context.Background(), []byte(feed), "https://example.com/feed", time.Now())
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("got %d entries, want 1", len(entries))
+	}
+	want := []Attachment{{URL: "https://example.com/ep.mp3", MimeType: "audio/mpeg", Size: 12345, Medium: "audio"}}
+	if !reflect.DeepEqual(entries[0].Attachments, want) {
+		t.Errorf("Attachments = %+v, want %+v", entries[0].Attachments, want)
+	}
+	if entries[0].Language != "en-gb" {
+		t.Errorf("Language = %q, want en-gb", entries[0].Language)
+	}
+}
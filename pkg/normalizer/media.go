@@ -0,0 +1,123 @@
+package normalizer
+
+import (
+	"html"
+	"strconv"
+	"strings"
+
+	"github.com/mmcdole/gofeed"
+	ext "github.com/mmcdole/gofeed/extensions"
+)
+
+// Attachment is a file that goes with an entry: a JSON Feed attachment, a
+// Media RSS media:content, or an RSS or Atom enclosure
+type Attachment struct {
+	URL       string
+	MimeType  string
+	Title     string
+	Size      int64  // Bytes; 0 if unknown
+	Duration  int    // Seconds; 0 if unknown
+	Medium    string // "image", "audio", "video", "document", "executable", or "" if unknown
+	Width     int    // Pixels, for images and video; 0 if unknown
+	Height    int    // Pixels; 0 if unknown
+	Thumbnail string // Absolute URL of an image standing for it, if any
+}
+
+// mediaElements returns the media:<name> elements (Media RSS) among
+// extensions, including those inside a media:group
+func mediaElements(extensions ext.Extensions, name string) []ext.Extension {
+	media, ok := extensions["media"]
+	if !ok {
+		return nil
+	}
+	elements := media[name]
+	for _, group := range media["group"] {
+		elements = append(elements, group.Children[name]...)
+	}
+	return elements
+}
+
+// mediaText returns the first non-empty media:<name> text among extensions,
+// and its type attribute ("plain" or "html")
+func mediaText(extensions ext.Extensions, name string) (text, kind string) {
+	for _, e := range mediaElements(extensions, name) {
+		if text := strings.TrimSpace(e.Value); text != "" {
+			return text, e.Attrs["type"]
+		}
+	}
+	return "", ""
+}
+
+// mediaAttachments returns the item's media:content elements as
+// attachments, with the item's media:title and first media:thumbnail.
+// Nested media:title and media:thumbnail elements override the item's.
+func mediaAttachments(item *gofeed.Item) []Attachment {
+	title, _ := mediaText(item.Extensions, "title")
+	thumbnail := ""
+	if thumbs := mediaElements(item.Extensions, "thumbnail"); len(thumbs) > 0 {
+		thumbnail = thumbs[0].Attrs["url"]
+	}
+
+	var attachments []Attachment
+	for _, e := range mediaElements(item.Extensions, "content") {
+		size, _ := strconv.ParseInt(e.Attrs["fileSize"], 10, 64)
+		duration, _ := strconv.ParseFloat(e.Attrs["duration"], 64)
+		width, _ := strconv.Atoi(e.Attrs["width"])
+		height, _ := strconv.Atoi(e.Attrs["height"])
+		a := Attachment{
+			URL:       e.Attrs["url"],
+			MimeType:  e.Attrs["type"],
+			Title:     title,
+			Size:      size,
+			Duration:  int(duration + 0.5),
+			Medium:    e.Attrs["medium"],
+			Width:     width,
+			Height:    height,
+			Thumbnail: thumbnail,
+		}
+		for _, t := range e.Children["title"] {
+			if v := strings.TrimSpace(t.Value); v != "" {
+				a.Title = v
+			}
+		}
+		for _, t := range e.Children["thumbnail"] {
+			if u := t.Attrs["url"]; u != "" {
+				a.Thumbnail = u
+			}
+		}
+		attachments = append(attachments, a)
+	}
+	return attachments
+}
+
+// mediaMedium returns the kind of media ("image", "audio", "video") a MIME
+// type is, or ""
+func mediaMedium(mimeType string) string {
+	kind, _, _ := strings.Cut(mimeType, "/")
+	switch kind {
+	case "image", "audio", "video":
+		return kind
+	}
+	return ""
+}
+
+// mediaDescription returns the item's media:description as sanitized HTML,
+// for items whose feed gives no other content, such as YouTube videos.
+// Plain text descriptions keep their paragraphs and line breaks.
+func (n *Normalizer) mediaDescription(item *gofeed.Item, feedURL string) string {
+	text, kind := mediaText(item.Extensions, "description")
+	if text == "" {
+		return ""
+	}
+	if kind == "html" {
+		return n.sanitizeHTML(text, feedURL)
+	}
+	var paragraphs []string
+	for _, p := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n\n") {
+		if p = strings.TrimSpace(p); p != "" {
+			lines := strings.Split(html.EscapeString(p), "\n")
+			paragraphs = append(paragraphs, "<p>"+strings.Join(lines, "<br>")+"</p>")
+		}
+	}
+	return n.sanitizeHTML(strings.Join(paragraphs, "\n"), feedURL)
+}
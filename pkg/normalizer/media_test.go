@@ -0,0 +1,103 @@
+package normalizer
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParse_YouTubeMediaGroup(t *testing.T) {
+	t.Parallel()
+	feed := `<?xml version="1.0" encoding="UTF-8"?>
+<feed xmlns:yt="http://www.youtube.com/xml/schemas/2015" xmlns:media="http://search.yahoo.com/mrss/" xmlns="http://www.w3.org/2005/Atom">
+ <id>yt:channel:UC123</id>
+ <title>A Channel</title>
+ <link rel="alternate" href="https://www.youtube.com/channel/UC123"/>
+ <entry>
+  <id>yt:video:abc</id>
+  <yt:videoId>abc</yt:videoId>
+  <title>A Video</title>
+  <link rel="alternate" href="https://www.youtube.com/watch?v=abc"/>
+  <author><name>A Channel</name></author>
+  <published>2024-01-02T10:00:00+00:00</published>
+  <updated>2024-01-02T11:00:00+00:00</updated>
+  <media:group>
+   <media:title>A Video</media:title>
+   <media:content url="https://www.youtube.com/v/abc?version=3" type="application/x-shockwave-flash" width="640" height="390"/>
+   <media:thumbnail url="https://i1.ytimg.com/vi/abc/hqdefault.jpg" width="480" height="360"/>
+   <media:description>First line
+second line &lt;b&gt;
+
+Second paragraph</media:description>
+  </media:group>
+ </entry>
+</feed>`
+	_, entries, err := New().Parse(context.Background(), []byte(feed), "https://www.youtube.com/feeds/videos.xml?channel_id=UC123", time.Now())
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("got %d entries, want 1", len(entries))
+	}
+	e := entries[0]
+
+	wantContent := "<p>First line<br>second line &lt;b&gt;</p>\n<p>Second paragraph</p>"
+	if e.Content != wantContent {
+		t.Errorf("Content = %q, want %q", e.Content, wantContent)
+	}
+	if e.Image != "https://i1.ytimg.com/vi/abc/hqdefault.jpg" {
+		t.Errorf("Image = %q, want the video's thumbnail", e.Image)
+	}
+	want := []Attachment{{
+		URL:       "https://www.youtube.com/v/abc?version=3",
+		MimeType:  "application/x-shockwave-flash",
+		Title:     "A Video",
+		Width:     640,
+		Height:    390,
+		Thumbnail: "https://i1.ytimg.com/vi/abc/hqdefault.jpg",
+	}}
+	if !reflect.DeepEqual(e.Attachments, want) {
+		t.Errorf("Attachments = %+v, want %+v", e.Attachments, want)
+	}
+}
+
+func TestParse_MediaContent(t *testing.T) {
+	t.Parallel()
+	feed := `<rss version="2.0" xmlns:media="http://search.yahoo.com/mrss/"><channel>
+<title>Videos</title><link>https://example.com/</link>
+<media:thumbnail url="https://example.com/logo.png"/>
+<item><title>Clip</title><guid>clip</guid><link>https://example.com/clip</link>
+<description>A clip.</description>
+<media:content url="/clip.mp4" type="video/mp4" fileSize="2048" duration="61.6" medium="video">
+ <media:title>Clip in HD</media:title>
+ <media:thumbnail url="/clip.jpg"/>
+</media:content>
+<enclosure url="https://example.com/clip.mp4" length="4096" type="video/mp4"/>
+<enclosure url="https://example.com/clip.mp3" length="512" type="audio/mpeg"/>
+</item>
+<item><title>Text</title><guid>text</guid><link>https://example.com/text</link>
+<description>No pictures.</description></item>
+</channel></rss>`
+	_, entries, err := New().Parse(context.Background(), []byte(feed), "https://example.com/feed", time.Now())
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(entries))
+	}
+
+	want := []Attachment{
+		{URL: "https://example.com/clip.mp4", MimeType: "video/mp4", Title: "Clip in HD", Size: 2048, Duration: 62, Medium: "video", Thumbnail: "https://example.com/clip.jpg"},
+		{URL: "https://example.com/clip.mp3", MimeType: "audio/mpeg", Size: 512, Medium: "audio"},
+	}
+	if !reflect.DeepEqual(entries[0].Attachments, want) {
+		t.Errorf("Attachments = %+v, want %+v", entries[0].Attachments, want)
+	}
+	if entries[0].Content != "A clip." {
+		t.Errorf("Content = %q, want the description", entries[0].Content)
+	}
+	if entries[1].Image != "https://example.com/logo.png" {
+		t.Errorf("Image = %q, want the feed's thumbnail", entries[1].Image)
+	}
+}
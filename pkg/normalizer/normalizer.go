@@ -3,7 +3,8 @@
 // The normalizer parses multiple feed formats (RSS 1.0, RSS 2.0, Atom, JSON Feed)
 // and converts them to a canonical internal format. It implements HTML sanitization
 // to prevent XSS attacks (CVE-2009-2937), handles missing dates and IDs gracefully,
-// and resolves relative URLs to absolute.
+// and resolves relative URLs to absolute. Feeds in legacy encodings are converted
+// to UTF-8 before parsing.
 package normalizer
 
 import (
@@ -13,10 +14,10 @@ import (
 	"errors"
 	"fmt"
 	"net/url"
+	"sort"
 	"strings"
 	"time"
 
-	"github.com/microcosm-cc/bluemonday"
 	"github.com/mmcdole/gofeed"
 )
 
@@ -37,6 +38,17 @@ type Entry struct {
 	ContentType string    // "html" or "text"
 	Summary     string    // Sanitized summary
 	FirstSeen   time.Time // When first crawled
+	Categories  []string  // Entry categories/tags, trimmed and de-duplicated
+
+	WordCount      int // Words in Content
+	ReadingMinutes int // Estimated minutes to read Content
+
+	Image string // Absolute URL of a representative image, for cards and social previews
+
+	Authors     []string     // Names of all the entry's own authors, when it names any; Author is usually the first
+	ExternalURL string       // Page elsewhere the entry is about, for link blogs (JSON Feed external_url)
+	Language    string       // Language of the entry, or else of its feed, as given (e.g. "en-GB")
+	Attachments []Attachment // Enclosures and JSON Feed attachments
 }
 
 // FeedMetadata contains feed-level information
@@ -44,30 +56,67 @@ type FeedMetadata struct {
 	Title   string
 	Link    string
 	Updated time.Time
+
+	Truncated bool // The feed had more entries than the normalizer keeps; only the newest were returned
 }
 
 // Normalizer handles feed parsing and content normalization
 type Normalizer struct {
-	parser    *gofeed.Parser
-	sanitizer *bluemonday.Policy
+	sanitizer     *sanitizer
+	feedSanitizer map[string]*sanitizer     // Per-feed policies, keyed by feed URL
+	summaryWords  int                       // Length of summaries made from content; 0 makes none
+	dates         DatePolicy                // Handling of undated and future-dated entries
+	feedZone      map[string]*time.Location // Time zones correcting feeds' timestamps, keyed by feed URL
+	maxEntries    int                       // Most entries Parse returns from one feed; 0 is unlimited
+	lowMemory     bool                      // Parse every XML feed an item at a time
 }
 
 // New creates a new Normalizer with default settings
 func New() *Normalizer {
-	// Create strict sanitization policy
-	policy := bluemonday.UGCPolicy()
+	// The default policy always compiles
+	n, _ := NewWithPolicy(Policy{}, nil)
+	return n
+}
 
-	// Only allow http and https schemes
-	policy.AllowURLSchemes("http", "https")
+// NewWithPolicy creates a Normalizer that sanitizes content with policy,
+// except for the feeds in perFeed, which use their own policies
+func NewWithPolicy(policy Policy, perFeed map[string]Policy) (*Normalizer, error) {
+	s, err := policy.compile()
+	if err != nil {
+		return nil, fmt.Errorf("sanitization policy: %w", err)
+	}
+	n := &Normalizer{
+		sanitizer:     s,
+		feedSanitizer: make(map[string]*sanitizer, len(perFeed)),
+	}
+	for url, p := range perFeed {
+		fs, err := p.compile()
+		if err != nil {
+			return nil, fmt.Errorf("sanitization policy for %s: %w", url, err)
+		}
+		n.feedSanitizer[url] = fs
+	}
+	return n, nil
+}
 
-	// Additional safe attributes
-	policy.AllowAttrs("alt", "title").OnElements("img")
-	policy.AllowAttrs("href", "title").OnElements("a")
+// SetSummaryWords makes the normalizer give entries that have content but
+// no summary a plain-text summary of at most words words, cut at a sentence
+// boundary where possible. Zero, the default, leaves their summary empty.
+func (n *Normalizer) SetSummaryWords(words int) {
+	n.summaryWords = words
+}
 
-	return &Normalizer{
-		parser:    gofeed.NewParser(),
-		sanitizer: policy,
-	}
+// SetMaxEntries limits the entries Parse returns from one feed to the max
+// most recently published, so that a feed with thousands of items costs
+// no more to store than one with max. Zero, the default, returns them all.
+func (n *Normalizer) SetMaxEntries(max int) {
+	n.maxEntries = max
+}
+
+// SetLowMemory makes Parse read every XML feed an item at a time, however
+// small, rather than only large ones, trading some speed for memory
+func (n *Normalizer) SetLowMemory(on bool) {
+	n.lowMemory = on
 }
 
 // Parse parses and normalizes a feed
@@ -77,40 +126,98 @@ func (n *Normalizer) Parse(ctx context.Context, feedData []byte, feedURL string,
 		return nil, nil, err
 	}
 
-	// Parse feed
-	feed, err := n.parser.ParseString(string(feedData))
+	// Entries are normalized as the feed is parsed, so that a large feed's
+	// parsed items needn't all be held at once
+	entries := []Entry{}
+	truncated := false
+	streamAbove := streamThreshold
+	if n.lowMemory {
+		streamAbove = 0
+	}
+	feed, err := streamFeed(feedData, "", streamAbove, func(item *gofeed.Item, feed *gofeed.Feed) bool {
+		if ctx.Err() != nil {
+			return false
+		}
+		entry, err := n.normalizeEntry(item, feed, feedURL, fetchTime)
+		if err != nil {
+			// Skip the entry but continue processing the others
+			return true
+		}
+		entries = append(entries, entry)
+		// Trim to the newest now and then rather than holding every entry
+		if n.maxEntries > 0 && len(entries) >= 2*n.maxEntries {
+			entries, truncated = newestEntries(entries, n.maxEntries), true
+		}
+		return true
+	})
 	if err != nil {
-		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidFeed, err)
+		return nil, nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	if n.maxEntries > 0 && len(entries) > n.maxEntries {
+		entries, truncated = newestEntries(entries, n.maxEntries), true
 	}
 
 	// Extract feed metadata
 	metadata := FeedMetadata{
-		Title: feed.Title,
-		Link:  feed.Link,
+		Title:     feed.Title,
+		Link:      feed.Link,
+		Truncated: truncated,
 	}
 
 	if feed.UpdatedParsed != nil {
-		metadata.Updated = *feed.UpdatedParsed
+		metadata.Updated = n.inFeedZone(feedURL, *feed.UpdatedParsed)
 	} else {
 		metadata.Updated = fetchTime
 	}
 
-	// Normalize entries
-	if len(feed.Items) == 0 {
-		return &metadata, []Entry{}, nil
+	return &metadata, entries, nil
+}
+
+// newestEntries returns the max most recently published of entries, in the
+// order they came in
+func newestEntries(entries []Entry, max int) []Entry {
+	dates := make([]time.Time, len(entries))
+	for i, e := range entries {
+		dates[i] = e.Published
+	}
+	sort.Slice(dates, func(i, j int) bool { return dates[i].After(dates[j]) })
+	cutoff := dates[max-1]
+	// Entries published at the cutoff are kept first come, first served
+	atCutoff := max
+	for _, d := range dates[:max] {
+		if d.After(cutoff) {
+			atCutoff--
+		}
 	}
 
-	entries := make([]Entry, 0, len(feed.Items))
-	for _, item := range feed.Items {
-		entry, err := n.normalizeEntry(item, feed, feedURL, fetchTime)
-		if err != nil {
-			// Log error but continue processing other entries
+	kept := entries[:0]
+	for _, e := range entries {
+		switch {
+		case e.Published.After(cutoff):
+		case e.Published.Equal(cutoff) && atCutoff > 0:
+			atCutoff--
+		default:
 			continue
 		}
-		entries = append(entries, entry)
+		kept = append(kept, e)
 	}
+	// Let the dropped entries' content go
+	clear(entries[len(kept):])
+	return kept
+}
 
-	return &metadata, entries, nil
+// parseFeed parses a feed in any supported format and encoding, given the
+// Content-Type it was served with, if known. Each call gets parsers of its
+// own, which keep state while parsing, so it is safe to call concurrently.
+func parseFeed(feedData []byte, contentType string) (*gofeed.Feed, error) {
+	feed, err := parseDocument(DecodeCharset(feedData, contentType))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidFeed, err)
+	}
+	return feed, nil
 }
 
 // normalizeEntry converts a feed item to a normalized Entry
@@ -137,10 +244,17 @@ func (n *Normalizer) normalizeEntry(item *gofeed.Item, feed *gofeed.Feed, feedUR
 
 	// Extract author
 	entry.Author = n.extractAuthor(item, feed)
+	entry.Authors = extractAuthors(item)
+	entry.ExternalURL = n.webURL(jsonFeedValue(item, "external_url"), feedURL)
+	entry.Language = extractLanguage(item, feed)
+	entry.Attachments = n.extractAttachments(item, feedURL)
 
 	// Extract dates
-	entry.Published = n.extractPublished(item, feed, fetchTime)
-	entry.Updated = n.extractUpdated(item, entry.Published)
+	var err error
+	entry.Published, entry.Updated, err = n.entryDates(item, feed, feedURL, fetchTime)
+	if err != nil {
+		return Entry{}, err
+	}
 
 	// Extract content (prefer full content over summary)
 	if item.Content != "" {
@@ -149,12 +263,23 @@ func (n *Normalizer) normalizeEntry(item *gofeed.Item, feed *gofeed.Feed, feedUR
 	} else if item.Description != "" {
 		entry.Content = n.sanitizeHTML(item.Description, feedURL)
 		entry.ContentType = "html"
+	} else if desc := n.mediaDescription(item, feedURL); desc != "" {
+		entry.Content = desc
+		entry.ContentType = "html"
 	}
 
 	// Extract summary
 	if item.Description != "" && item.Content != "" {
 		entry.Summary = n.sanitizeHTML(item.Description, feedURL)
 	}
+	if entry.Summary == "" && n.summaryWords > 0 {
+		entry.Summary = textSummary(entry.Content, n.summaryWords)
+	}
+	entry.Image = n.extractImage(item, feed, entry, feedURL)
+	entry.WordCount = CountWords(entry.Content)
+	entry.ReadingMinutes = ReadingMinutes(entry.WordCount)
+
+	entry.Categories = extractCategories(item)
 
 	return entry, nil
 }
@@ -190,53 +315,78 @@ func (n *Normalizer) extractID(item *gofeed.Item, feedURL string) string {
 	return hex.EncodeToString(hash.Sum(nil))[:16]
 }
 
-// extractAuthor gets the author name from entry or feed level
-func (n *Normalizer) extractAuthor(item *gofeed.Item, feed *gofeed.Feed) string {
-	// Try item-level author
-	if item.Author != nil && item.Author.Name != "" {
-		return item.Author.Name
+// extractCategories returns the item's categories without blanks or duplicates
+func extractCategories(item *gofeed.Item) []string {
+	var categories []string
+	seen := make(map[string]bool)
+	for _, c := range item.Categories {
+		c = strings.TrimSpace(c)
+		key := strings.ToLower(c)
+		if c == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		categories = append(categories, c)
 	}
+	return categories
+}
 
-	// Try multiple authors
-	if len(item.Authors) > 0 && item.Authors[0].Name != "" {
-		return item.Authors[0].Name
+// extractAuthor gets the author name from entry or feed level. An author
+// given only by email address is recorded by the address, so it can still
+// be mapped to a name with an [author] section.
+func (n *Normalizer) extractAuthor(item *gofeed.Item, feed *gofeed.Feed) string {
+	people := []*gofeed.Person{item.Author}
+	if len(item.Authors) > 0 {
+		people = append(people, item.Authors[0])
 	}
 
-	// Fallback to feed-level author
+	// Try item-level author, then multiple authors, then dc:creator, then
+	// the feed's author
+	for _, p := range people {
+		if p != nil && p.Name != "" {
+			return p.Name
+		}
+	}
+	if creator := dcCreator(item); creator != "" {
+		return creator
+	}
+	people = append(people, feed.Author)
 	if feed.Author != nil && feed.Author.Name != "" {
 		return feed.Author.Name
 	}
+	for _, p := range people {
+		if p != nil && p.Email != "" {
+			return p.Email
+		}
+	}
 
 	return ""
 }
 
-// extractPublished extracts the published date
-func (n *Normalizer) extractPublished(item *gofeed.Item, feed *gofeed.Feed, fetchTime time.Time) time.Time {
+// extractPublished extracts the published date, reporting whether the item
+// has one; entryDates decides what an undated item gets
+func (n *Normalizer) extractPublished(item *gofeed.Item) (time.Time, bool) {
 	// Use item published date
 	if item.PublishedParsed != nil && !item.PublishedParsed.IsZero() {
-		return *item.PublishedParsed
+		return *item.PublishedParsed, true
 	}
 
 	// Use item updated date
 	if item.UpdatedParsed != nil && !item.UpdatedParsed.IsZero() {
-		return *item.UpdatedParsed
-	}
-
-	// Use feed updated date
-	if feed.UpdatedParsed != nil && !feed.UpdatedParsed.IsZero() {
-		return *feed.UpdatedParsed
+		return *item.UpdatedParsed, true
 	}
 
-	// Use fetch time as last resort
-	return fetchTime
+	// Use Dublin Core dates, which only RSS items are given by the parser
+	return dcDate(item, "date", "issued", "created", "available", "modified")
 }
 
-// extractUpdated extracts the updated date
-func (n *Normalizer) extractUpdated(item *gofeed.Item, published time.Time) time.Time {
+// extractUpdated extracts the updated date, reporting whether the item has
+// one; without one it is the published date
+func (n *Normalizer) extractUpdated(item *gofeed.Item) (time.Time, bool) {
 	if item.UpdatedParsed != nil && !item.UpdatedParsed.IsZero() {
-		return *item.UpdatedParsed
+		return *item.UpdatedParsed, true
 	}
-	return published
+	return dcDate(item, "modified")
 }
 
 // sanitizeHTML sanitizes HTML content and resolves relative URLs
@@ -245,7 +395,11 @@ func (n *Normalizer) sanitizeHTML(html string, baseURL string) string {
 	// For now, just sanitize
 
 	// Sanitize HTML to remove dangerous content
-	sanitized := n.sanitizer.Sanitize(html)
+	s := n.sanitizer
+	if fs, ok := n.feedSanitizer[baseURL]; ok {
+		s = fs
+	}
+	sanitized := s.sanitize(html)
 
 	return strings.TrimSpace(sanitized)
 }
@@ -267,5 +421,11 @@ func (n *Normalizer) resolveURL(href string, baseURL string) (string, error) {
 
 // SanitizeHTML provides public access to HTML sanitization
 func (n *Normalizer) SanitizeHTML(html string) string {
-	return n.sanitizer.Sanitize(html)
+	return n.sanitizer.sanitize(html)
+}
+
+// SanitizeFeedHTML sanitizes HTML with the policy for feedURL, so content
+// fetched from elsewhere for a feed's entries is cleaned the same way
+func (n *Normalizer) SanitizeFeedHTML(feedURL, html string) string {
+	return strings.TrimSpace(n.sanitizeHTML(html, feedURL))
 }
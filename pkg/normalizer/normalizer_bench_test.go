@@ -2,6 +2,7 @@ package normalizer
 
 import (
 	"context"
+	"runtime"
 	"strings"
 	"testing"
 	"time"
@@ -87,3 +88,78 @@ func BenchmarkExtractID(b *testing.B) {
 		_ = n.extractID(item, "https://example.com/feed")
 	}
 }
+
+// BenchmarkParseLargeFeed benchmarks a 10MB feed of 5,000 items, parsed a
+// whole feed at a time as small feeds are and streamed an item at a time
+// as large ones are, and reports the heap each reached. A whole parse holds
+// every item's parse at once; a streamed one holds the entries it returns
+// and one item's parse, so its peak should stay near the size of the
+// entries however many items the feed has.
+func BenchmarkParseLargeFeed(b *testing.B) {
+	data := largeFeed(5000, 2000)
+	feedURL := "https://example.com/feed"
+	fetchTime := time.Now()
+	n := New()
+
+	b.Run("whole", func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetBytes(int64(len(data)))
+		peak := watchHeap(b)
+		for i := 0; i < b.N; i++ {
+			feed, err := parseFeed(data, "")
+			if err != nil {
+				b.Fatal(err)
+			}
+			entries := make([]Entry, 0, len(feed.Items))
+			for _, item := range feed.Items {
+				entry, _ := n.normalizeEntry(item, feed, feedURL, fetchTime)
+				entries = append(entries, entry)
+			}
+		}
+		peak()
+	})
+
+	b.Run("streamed", func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetBytes(int64(len(data)))
+		peak := watchHeap(b)
+		for i := 0; i < b.N; i++ {
+			if _, _, err := n.Parse(context.Background(), data, feedURL, fetchTime); err != nil {
+				b.Fatal(err)
+			}
+		}
+		peak()
+	})
+}
+
+// watchHeap samples the heap in use until the returned function is called,
+// which reports the most it saw, above where it started, as peak-heap-MB
+func watchHeap(b *testing.B) func() {
+	runtime.GC()
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	base, peak := stats.HeapAlloc, uint64(0)
+	done, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				var s runtime.MemStats
+				runtime.ReadMemStats(&s)
+				if s.HeapAlloc > base && s.HeapAlloc-base > peak {
+					peak = s.HeapAlloc - base
+				}
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+		b.ReportMetric(float64(peak)/(1<<20), "peak-heap-MB")
+	}
+}
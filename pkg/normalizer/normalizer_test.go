@@ -453,6 +453,25 @@ func TestNormalizeEntry_MissingFields(t *testing.T) {
 			wantAuthor: "",
 			wantID:     "https://example.com/post1", // Link is used as ID when no GUID
 		},
+		{
+			name: "author with only an email",
+			feedXML: `<?xml version="1.0"?>
+<rss version="2.0">
+  <channel>
+    <title>Test Feed</title>
+    <link>https://example.com</link>
+    <item>
+      <title>Entry By Email</title>
+      <link>https://example.com/post2</link>
+      <author>john@example.com</author>
+    </item>
+  </channel>
+</rss>`,
+			wantTitle:  "Entry By Email",
+			wantLink:   "https://example.com/post2",
+			wantAuthor: "john@example.com",
+			wantID:     "https://example.com/post2",
+		},
 	}
 
 	for _, tt := range tests {
@@ -547,6 +566,7 @@ func TestExtractPublished_ZeroTime(t *testing.T) {
 
 	fetchTime := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
 	n := New()
+	n.SetDatePolicy(DatePolicy{Undated: UndatedFeedUpdated})
 	_, entries, err := n.Parse(context.Background(), []byte(feedData), "https://example.com/feed", fetchTime)
 
 	if err != nil {
@@ -565,3 +585,213 @@ func TestExtractPublished_ZeroTime(t *testing.T) {
 		t.Errorf("Published = %v, want %v (feed updated)", entry.Published, feedUpdated)
 	}
 }
+
+func TestNormalizeEntry_Categories(t *testing.T) {
+	t.Parallel()
+	feedData := `<?xml version="1.0"?>
+<rss version="2.0">
+  <channel>
+    <title>Test Feed</title>
+    <link>https://example.com</link>
+    <item>
+      <title>Tagged</title>
+      <link>https://example.com/post1</link>
+      <category>Go</category>
+      <category> Releases </category>
+      <category>go</category>
+      <category></category>
+    </item>
+  </channel>
+</rss>`
+
+	_, entries, err := New().Parse(context.Background(), []byte(feedData), "https://example.com/feed", time.Now())
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("len(entries) = %d, want 1", len(entries))
+	}
+
+	got := entries[0].Categories
+	if len(got) != 2 || got[0] != "Go" || got[1] != "Releases" {
+		t.Errorf("Categories = %q, want [Go Releases]", got)
+	}
+}
+
+func TestNormalizeEntry_GeneratedSummary(t *testing.T) {
+	t.Parallel()
+	feedData := `<?xml version="1.0"?>
+<rss version="2.0" xmlns:content="http://purl.org/rss/1.0/modules/content/">
+  <channel>
+    <title>Test Feed</title>
+    <link>https://example.com</link>
+    <item>
+      <title>Content only</title>
+      <link>https://example.com/post1</link>
+      <description><![CDATA[<p>First sentence here. Second <b>bold</b> sentence &amp; more.</p><p>Third sentence is cut.</p>]]></description>
+    </item>
+    <item>
+      <title>Both</title>
+      <link>https://example.com/post2</link>
+      <description>The feed's own summary.</description>
+      <content:encoded><![CDATA[<p>Full text of the post.</p>]]></content:encoded>
+    </item>
+  </channel>
+</rss>`
+
+	n := New()
+	_, entries, err := n.Parse(context.Background(), []byte(feedData), "https://example.com/feed", time.Now())
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if entries[0].Summary != "" {
+		t.Errorf("Summary = %q, want none without SetSummaryWords", entries[0].Summary)
+	}
+
+	n.SetSummaryWords(9)
+	_, entries, err = n.Parse(context.Background(), []byte(feedData), "https://example.com/feed", time.Now())
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if want := "First sentence here. Second bold sentence &amp; more."; entries[0].Summary != want {
+		t.Errorf("generated Summary = %q, want %q", entries[0].Summary, want)
+	}
+	if !strings.Contains(entries[1].Summary, "own summary") {
+		t.Errorf("Summary = %q, want the feed's own", entries[1].Summary)
+	}
+}
+
+func TestTextSummary(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		content  string
+		maxWords int
+		want     string
+	}{
+		{"empty", "", 10, ""},
+		{"short text kept whole", "<p>Just a few words</p>", 10, "Just a few words"},
+		{"cut at sentence end", "One two three. Four five six. Seven eight nine ten.", 8, "One two three. Four five six."},
+		{"quoted sentence end", `He said "stop." Then he left the room quickly.`, 5, `He said &#34;stop.&#34;`},
+		{"no sentence end adds ellipsis", "one two three four five six", 4, "one two three four…"},
+		{"early sentence end ignored", "Hi. one two three four five six seven", 6, "Hi. one two three four five…"},
+		{"script text dropped", "<script>alert(1)</script><p>Visible</p>", 5, "Visible"},
+		{"markup escaped", "<p>a &lt;b&gt; tag</p>", 10, "a &lt;b&gt; tag"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := textSummary(tt.content, tt.maxWords); got != tt.want {
+				t.Errorf("textSummary(%q, %d) = %q, want %q", tt.content, tt.maxWords, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadingTime(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		content     string
+		wantWords   int
+		wantMinutes int
+	}{
+		{"", 0, 0},
+		{"<p>Two words</p>", 2, 1},
+		{"<p>" + strings.Repeat("word ", WordsPerMinute) + "</p>", WordsPerMinute, 1},
+		{"<p>" + strings.Repeat("word ", WordsPerMinute+1) + "</p>", WordsPerMinute + 1, 2},
+		{"<style>p { color: red }</style><p>Just&nbsp;these</p>", 2, 1},
+	}
+	for _, tt := range tests {
+		words := CountWords(tt.content)
+		if words != tt.wantWords {
+			t.Errorf("CountWords(%.40q) = %d, want %d", tt.content, words, tt.wantWords)
+		}
+		if got := ReadingMinutes(words); got != tt.wantMinutes {
+			t.Errorf("ReadingMinutes(%d) = %d, want %d", words, got, tt.wantMinutes)
+		}
+	}
+
+	feedData := `<?xml version="1.0"?>
+<rss version="2.0">
+  <channel>
+    <title>Test Feed</title>
+    <item>
+      <title>Post</title>
+      <link>https://example.com/post1</link>
+      <description>` + strings.Repeat("word ", 450) + `</description>
+    </item>
+  </channel>
+</rss>`
+	_, entries, err := New().Parse(context.Background(), []byte(feedData), "https://example.com/feed", time.Now())
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if e := entries[0]; e.WordCount != 450 || e.ReadingMinutes != 3 {
+		t.Errorf("WordCount, ReadingMinutes = %d, %d, want 450, 3", e.WordCount, e.ReadingMinutes)
+	}
+}
+
+func TestNormalizeEntry_Image(t *testing.T) {
+	t.Parallel()
+	feedData := `<?xml version="1.0"?>
+<rss version="2.0" xmlns:media="http://search.yahoo.com/mrss/">
+  <channel>
+    <title>Test Feed</title>
+    <link>https://example.com</link>
+    <item>
+      <title>Thumbnail</title>
+      <link>https://example.com/posts/1</link>
+      <media:thumbnail url="https://cdn.example.com/thumb.jpg"/>
+      <description><![CDATA[<p><img src="https://example.com/inline.jpg"></p>]]></description>
+    </item>
+    <item>
+      <title>Grouped media</title>
+      <link>https://example.com/posts/2</link>
+      <media:group>
+        <media:content url="https://cdn.example.com/video.mp4" type="video/mp4"/>
+        <media:content url="https://cdn.example.com/still.jpg" medium="image"/>
+      </media:group>
+    </item>
+    <item>
+      <title>Inline</title>
+      <link>https://example.com/posts/3</link>
+      <description><![CDATA[<p>Text <img src="/images/3.png" alt=""></p>]]></description>
+    </item>
+    <item>
+      <title>None</title>
+      <link>https://example.com/posts/4</link>
+      <description>No pictures here</description>
+    </item>
+  </channel>
+</rss>`
+
+	_, entries, err := New().Parse(context.Background(), []byte(feedData), "https://example.com/feed", time.Now())
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	want := []string{
+		"https://cdn.example.com/thumb.jpg",
+		"https://cdn.example.com/still.jpg",
+		"https://example.com/images/3.png",
+		"",
+	}
+	for i, e := range entries {
+		if e.Image != want[i] {
+			t.Errorf("%s: Image = %q, want %q", e.Title, e.Image, want[i])
+		}
+	}
+
+	// A policy that strips images from content leaves entries without one
+	n, err := NewWithPolicy(Policy{}, map[string]Policy{"https://example.com/feed": {StripImages: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, entries, err = n.Parse(context.Background(), []byte(feedData), "https://example.com/feed", time.Now())
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	for _, e := range entries {
+		if e.Image != "" {
+			t.Errorf("%s: Image = %q with strip_images, want none", e.Title, e.Image)
+		}
+	}
+}
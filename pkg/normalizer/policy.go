@@ -0,0 +1,224 @@
+package normalizer
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/microcosm-cc/bluemonday"
+	"golang.org/x/net/html"
+)
+
+// Trust levels for Policy.Trust
+const (
+	// TrustStrict keeps text formatting and links only: no images, iframes,
+	// MathML, SVG, or extra tags, whatever else the policy allows
+	TrustStrict = "strict"
+	// TrustNormal applies the policy as configured
+	TrustNormal = "normal"
+	// TrustTrusted also allows MathML, SVG, and iframes from any HTTPS host
+	TrustTrusted = "trusted"
+)
+
+// Policy relaxes or tightens HTML sanitization. The zero value is the
+// default policy: bluemonday's UGC policy restricted to http and https URLs.
+type Policy struct {
+	Trust       string   // TrustStrict, TrustNormal, or TrustTrusted; empty means TrustNormal
+	AllowTags   []string // Extra elements to keep, with the standard attributes only
+	AllowMathML bool     // Keep MathML markup
+	AllowSVG    bool     // Keep inline SVG drawings (shapes and text, no links or scripts)
+	IframeHosts []string // Hosts whose HTTPS iframes are kept, e.g. www.youtube.com
+	StripImages bool     // Remove all images
+}
+
+// forbiddenTags can never be allowed with AllowTags: they run scripts, load
+// other documents, submit data, or change how the page is parsed. Iframes,
+// MathML, and SVG have their own settings.
+var forbiddenTags = map[string]bool{
+	"script": true, "style": true, "iframe": true, "frame": true, "frameset": true,
+	"object": true, "embed": true, "applet": true, "form": true, "input": true,
+	"button": true, "select": true, "textarea": true, "option": true, "base": true,
+	"link": true, "meta": true, "noscript": true, "template": true, "html": true,
+	"head": true, "body": true, "title": true, "svg": true, "math": true,
+	"xmp": true, "plaintext": true, "noembed": true, "noframes": true,
+}
+
+var (
+	tagNamePattern  = regexp.MustCompile(`^[a-z][a-z0-9-]*$`)
+	hostNamePattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9.-]*[a-z0-9])?(:[0-9]+)?$`)
+)
+
+// mathMLElements and mathMLAttrs cover presentation MathML as produced by
+// MathJax, KaTeX, and pandoc
+var (
+	mathMLElements = []string{
+		"math", "semantics", "annotation", "mrow", "mi", "mn", "mo", "ms", "mtext",
+		"mspace", "mfrac", "msqrt", "mroot", "msub", "msup", "msubsup", "munder",
+		"mover", "munderover", "mmultiscripts", "mprescripts", "none", "mtable",
+		"mtr", "mtd", "mlabeledtr", "mstyle", "mpadded", "mphantom", "menclose",
+		"merror",
+	}
+	mathMLAttrs = []string{
+		"xmlns", "display", "mathvariant", "mathsize", "mathcolor", "stretchy",
+		"fence", "separator", "accent", "accentunder", "lspace", "rspace",
+		"largeop", "movablelimits", "symmetric", "minsize", "maxsize",
+		"columnalign", "rowalign", "columnspacing", "rowspacing", "columnlines",
+		"rowlines", "frame", "columnspan", "rowspan", "encoding", "linethickness",
+		"displaystyle", "scriptlevel", "notation", "width", "height", "depth",
+		"voffset", "bevelled", "open", "close", "separators",
+	}
+)
+
+// svgElements and svgAttrs allow static drawings. Elements that link,
+// reference other content, or animate (a, use, image, foreignobject,
+// animate, set) are left out. The HTML tokenizer lowercases names, and
+// browsers restore the camelCase SVG spellings when parsing.
+var (
+	svgElements = []string{
+		"svg", "g", "path", "circle", "ellipse", "line", "polyline", "polygon",
+		"rect", "text", "tspan", "desc", "defs", "lineargradient",
+		"radialgradient", "stop", "marker", "symbol",
+	}
+	svgAttrs = []string{
+		"xmlns", "viewbox", "preserveaspectratio", "width", "height", "x", "y",
+		"x1", "y1", "x2", "y2", "cx", "cy", "r", "rx", "ry", "d", "points",
+		"transform", "fill", "fill-opacity", "fill-rule", "stroke",
+		"stroke-width", "stroke-opacity", "stroke-linecap", "stroke-linejoin",
+		"stroke-dasharray", "opacity", "font-size", "font-family", "font-weight",
+		"text-anchor", "dominant-baseline", "dx", "dy", "offset", "stop-color",
+		"stop-opacity", "gradientunits", "gradienttransform", "markerwidth",
+		"markerheight", "refx", "refy", "orient", "vector-effect",
+	}
+)
+
+// sanitizer applies one compiled policy
+type sanitizer struct {
+	policy      *bluemonday.Policy
+	stripImages bool
+	iframes     bool // Some iframes are allowed, so rejected ones must be cleaned up
+}
+
+// compile builds the sanitizer for a policy, rejecting unknown trust levels,
+// malformed names, and tags that could run scripts
+func (p Policy) compile() (*sanitizer, error) {
+	trust := p.Trust
+	if trust == "" {
+		trust = TrustNormal
+	}
+	if trust != TrustStrict && trust != TrustNormal && trust != TrustTrusted {
+		return nil, fmt.Errorf("unknown trust level %q (want %s, %s, or %s)", p.Trust, TrustStrict, TrustNormal, TrustTrusted)
+	}
+
+	policy := bluemonday.UGCPolicy()
+
+	// Only allow http and https schemes
+	policy.AllowURLSchemes("http", "https")
+
+	// Additional safe attributes
+	policy.AllowAttrs("alt", "title").OnElements("img")
+	policy.AllowAttrs("href", "title").OnElements("a")
+
+	if trust == TrustStrict {
+		return &sanitizer{policy: policy, stripImages: true}, nil
+	}
+
+	for _, tag := range p.AllowTags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if !tagNamePattern.MatchString(tag) {
+			return nil, fmt.Errorf("invalid tag name %q", tag)
+		}
+		if forbiddenTags[tag] {
+			return nil, fmt.Errorf("tag %q cannot be allowed", tag)
+		}
+		policy.AllowElements(tag)
+		policy.AllowNoAttrs().OnElements(tag)
+	}
+
+	if p.AllowMathML || trust == TrustTrusted {
+		policy.AllowElements(mathMLElements...)
+		policy.AllowNoAttrs().OnElements(mathMLElements...)
+		policy.AllowAttrs(mathMLAttrs...).OnElements(mathMLElements...)
+	}
+	if p.AllowSVG || trust == TrustTrusted {
+		policy.AllowElements(svgElements...)
+		policy.AllowNoAttrs().OnElements(svgElements...)
+		policy.AllowAttrs(svgAttrs...).OnElements(svgElements...)
+	}
+
+	iframes := true
+	switch {
+	case trust == TrustTrusted:
+		policy.AllowAttrs("src").Matching(regexp.MustCompile(`^https://`)).OnElements("iframe")
+	case len(p.IframeHosts) > 0:
+		hosts := make([]string, 0, len(p.IframeHosts))
+		for _, h := range p.IframeHosts {
+			h = strings.ToLower(strings.TrimSpace(h))
+			if !hostNamePattern.MatchString(h) {
+				return nil, fmt.Errorf("invalid iframe host %q", h)
+			}
+			hosts = append(hosts, regexp.QuoteMeta(h))
+		}
+		src := regexp.MustCompile(`^https://(` + strings.Join(hosts, "|") + `)/`)
+		policy.AllowAttrs("src").Matching(src).OnElements("iframe")
+	default:
+		iframes = false
+	}
+	if iframes {
+		policy.AllowAttrs("width", "height", "title", "allowfullscreen", "loading").OnElements("iframe")
+	}
+
+	return &sanitizer{policy: policy, stripImages: p.StripImages, iframes: iframes}, nil
+}
+
+// sanitize cleans an HTML fragment
+func (s *sanitizer) sanitize(content string) string {
+	out := s.policy.Sanitize(content)
+	if s.stripImages || s.iframes {
+		out = s.dropEmbeds(out)
+	}
+	return out
+}
+
+// dropEmbeds removes images when they are stripped, and iframes whose src was
+// rejected by the policy, from sanitized HTML
+func (s *sanitizer) dropEmbeds(content string) string {
+	var out strings.Builder
+	z := html.NewTokenizer(strings.NewReader(content))
+	skipping := false // Inside an iframe being removed
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			return out.String()
+		}
+		raw := string(z.Raw())
+		switch tt {
+		case html.StartTagToken, html.SelfClosingTagToken:
+			tok := z.Token()
+			if tok.Data == "img" && s.stripImages {
+				continue
+			}
+			if tok.Data == "iframe" && !hasAttr(tok, "src") {
+				skipping = tt == html.StartTagToken
+				continue
+			}
+		case html.EndTagToken:
+			if name, _ := z.TagName(); string(name) == "iframe" && skipping {
+				skipping = false
+				continue
+			}
+		}
+		if !skipping {
+			out.WriteString(raw)
+		}
+	}
+}
+
+// hasAttr reports whether a tag has the named attribute
+func hasAttr(tok html.Token, key string) bool {
+	for _, a := range tok.Attr {
+		if a.Key == key {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,207 @@
+package normalizer
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPolicySanitize(t *testing.T) {
+	t.Parallel()
+
+	const (
+		mathml  = `<math display="block"><mrow><msup><mi>x</mi><mn>2</mn></msup></mrow></math>`
+		svg     = `<svg viewBox="0 0 10 10"><circle cx="5" cy="5" r="4" fill="red"></circle></svg>`
+		youtube = `<iframe src="https://www.youtube.com/embed/abc" width="560" height="315" allowfullscreen></iframe>`
+		image   = `<img src="https://example.com/a.png" alt="A">`
+	)
+
+	tests := []struct {
+		name    string
+		policy  Policy
+		input   string
+		want    []string
+		wantNot []string
+	}{
+		{
+			name:    "default drops MathML",
+			input:   mathml,
+			wantNot: []string{"<math", "<msup"},
+		},
+		{
+			name:   "MathML allowed",
+			policy: Policy{AllowMathML: true},
+			input:  mathml,
+			want:   []string{`<math display="block">`, "<msup><mi>x</mi><mn>2</mn></msup>"},
+		},
+		{
+			name:   "SVG allowed",
+			policy: Policy{AllowSVG: true},
+			input:  svg,
+			want:   []string{`<svg viewbox="0 0 10 10">`, `<circle cx="5" cy="5" r="4" fill="red">`},
+		},
+		{
+			name:    "SVG scripts and links still removed",
+			policy:  Policy{AllowSVG: true},
+			input:   `<svg onload="alert(1)"><script>alert(2)</script><a href="javascript:alert(3)"><rect width="1"></rect></a><foreignObject><p>x</p></foreignObject></svg>`,
+			want:    []string{"<svg>", `<rect width="1">`},
+			wantNot: []string{"onload", "alert", "<a", "foreignobject"},
+		},
+		{
+			name:    "default drops iframes",
+			input:   youtube,
+			wantNot: []string{"<iframe"},
+		},
+		{
+			name:   "iframe from allowed host",
+			policy: Policy{IframeHosts: []string{"www.youtube.com"}},
+			input:  youtube,
+			want:   []string{`<iframe src="https://www.youtube.com/embed/abc" width="560" height="315" allowfullscreen="">`},
+		},
+		{
+			name:    "iframe from other host removed entirely",
+			policy:  Policy{IframeHosts: []string{"www.youtube.com"}},
+			input:   `<p>a</p><iframe src="https://evil.example/embed" width="560"></iframe><p>b</p>`,
+			want:    []string{"<p>a</p><p>b</p>"},
+			wantNot: []string{"iframe", "evil"},
+		},
+		{
+			name:    "iframe over plain HTTP removed",
+			policy:  Policy{IframeHosts: []string{"www.youtube.com"}},
+			input:   `<iframe src="http://www.youtube.com/embed/abc"></iframe>`,
+			wantNot: []string{"iframe"},
+		},
+		{
+			name:    "iframe host must match exactly",
+			policy:  Policy{IframeHosts: []string{"www.youtube.com"}},
+			input:   `<iframe src="https://www.youtube.com.evil.example/embed"></iframe>`,
+			wantNot: []string{"iframe"},
+		},
+		{
+			name:    "default drops kbd",
+			input:   `<p><kbd>Ctrl</kbd></p>`,
+			wantNot: []string{"<kbd>"},
+		},
+		{
+			name:   "extra tags",
+			policy: Policy{AllowTags: []string{"Kbd"}},
+			input:  `<p><kbd>Ctrl</kbd></p>`,
+			want:   []string{"<kbd>Ctrl</kbd>"},
+		},
+		{
+			name:    "images stripped",
+			policy:  Policy{StripImages: true},
+			input:   `<p>a` + image + `b</p>`,
+			want:    []string{"<p>ab</p>"},
+			wantNot: []string{"<img"},
+		},
+		{
+			name:    "strict ignores relaxations",
+			policy:  Policy{Trust: TrustStrict, AllowMathML: true, IframeHosts: []string{"www.youtube.com"}},
+			input:   mathml + youtube + image + `<p><a href="https://example.com/">link</a></p>`,
+			want:    []string{`<a href="https://example.com/"`},
+			wantNot: []string{"<math", "<iframe", "<img"},
+		},
+		{
+			name:   "trusted allows embeds from any HTTPS host",
+			policy: Policy{Trust: TrustTrusted},
+			input:  mathml + svg + `<iframe src="https://player.vimeo.com/video/1"></iframe>`,
+			want:   []string{"<math", "<svg", `<iframe src="https://player.vimeo.com/video/1">`},
+		},
+		{
+			name:    "trusted still removes scripts",
+			policy:  Policy{Trust: TrustTrusted},
+			input:   `<iframe src="javascript:alert(1)"></iframe><script>alert(2)</script><p onclick="alert(3)">x</p>`,
+			want:    []string{"<p>x</p>"},
+			wantNot: []string{"alert", "<iframe", "onclick"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			s, err := tt.policy.compile()
+			if err != nil {
+				t.Fatalf("compile() error = %v", err)
+			}
+			got := s.sanitize(tt.input)
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("sanitize() = %q, want it to contain %q", got, want)
+				}
+			}
+			for _, notWant := range tt.wantNot {
+				if strings.Contains(strings.ToLower(got), strings.ToLower(notWant)) {
+					t.Errorf("sanitize() = %q, should not contain %q", got, notWant)
+				}
+			}
+		})
+	}
+}
+
+func TestPolicyCompileErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		policy Policy
+	}{
+		{"unknown trust level", Policy{Trust: "paranoid"}},
+		{"script tag", Policy{AllowTags: []string{"script"}}},
+		{"iframe via allow_tags", Policy{AllowTags: []string{"iframe"}}},
+		{"object tag", Policy{AllowTags: []string{"OBJECT"}}},
+		{"malformed tag", Policy{AllowTags: []string{"<b>"}}},
+		{"malformed iframe host", Policy{IframeHosts: []string{"https://www.youtube.com/"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if _, err := tt.policy.compile(); err == nil {
+				t.Error("compile() succeeded, want error")
+			}
+		})
+	}
+}
+
+func TestNewWithPolicyPerFeed(t *testing.T) {
+	t.Parallel()
+
+	const (
+		trustedURL = "https://math.example.com/feed.xml"
+		otherURL   = "https://other.example.com/feed.xml"
+	)
+	n, err := NewWithPolicy(Policy{StripImages: true}, map[string]Policy{
+		trustedURL: {AllowMathML: true},
+	})
+	if err != nil {
+		t.Fatalf("NewWithPolicy() error = %v", err)
+	}
+
+	feed := `<?xml version="1.0"?>
+<rss version="2.0"><channel><title>T</title>
+<item><title>Post</title><guid>1</guid>
+<description><![CDATA[<p>x</p><math><mi>y</mi></math><img src="https://example.com/i.png">]]></description>
+</item></channel></rss>`
+
+	_, entries, err := n.Parse(context.Background(), []byte(feed), trustedURL, time.Now())
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("Parse() = %d entries, %v", len(entries), err)
+	}
+	if c := entries[0].Content; !strings.Contains(c, "<math><mi>y</mi></math>") || !strings.Contains(c, "<img") {
+		t.Errorf("per-feed policy content = %q, want MathML and image kept", c)
+	}
+
+	_, entries, err = n.Parse(context.Background(), []byte(feed), otherURL, time.Now())
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("Parse() = %d entries, %v", len(entries), err)
+	}
+	if c := entries[0].Content; strings.Contains(c, "<math") || strings.Contains(c, "<img") {
+		t.Errorf("default policy content = %q, want MathML and image removed", c)
+	}
+
+	if _, err := NewWithPolicy(Policy{}, map[string]Policy{otherURL: {Trust: "bogus"}}); err == nil {
+		t.Error("NewWithPolicy() accepted an invalid per-feed policy")
+	}
+}
@@ -0,0 +1,22 @@
+package normalizer
+
+import "strings"
+
+// WordsPerMinute is the reading speed used to estimate reading time
+const WordsPerMinute = 200
+
+// CountWords counts the words in the text of an HTML fragment, leaving out
+// scripts and styles
+func CountWords(fragment string) int {
+	return len(strings.Fields(plainText(fragment)))
+}
+
+// ReadingMinutes estimates the whole minutes needed to read words words at
+// WordsPerMinute, rounding up, so any text takes at least a minute. It
+// returns 0 for no words.
+func ReadingMinutes(words int) int {
+	if words <= 0 {
+		return 0
+	}
+	return (words + WordsPerMinute - 1) / WordsPerMinute
+}
@@ -0,0 +1,205 @@
+package normalizer
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/mmcdole/gofeed/atom"
+	feedjson "github.com/mmcdole/gofeed/json"
+	"github.com/mmcdole/gofeed/rss"
+	"golang.org/x/net/html/charset"
+)
+
+// streamThreshold is the size of XML feed above which streamFeed parses it
+// an item at a time. Below it, parsing the whole feed at once is faster and
+// the memory it takes doesn't matter.
+var streamThreshold = 1 << 20
+
+// parseDocument parses a UTF-8 feed of any supported format. It calls the
+// format's parser directly rather than through gofeed.Parser, which copies
+// the whole feed twice to detect its format.
+func parseDocument(feedData []byte) (*gofeed.Feed, error) {
+	var (
+		feed *gofeed.Feed
+		err  error
+	)
+	switch feedType(feedData) {
+	case gofeed.FeedTypeRSS:
+		var rf *rss.Feed
+		if rf, err = (&rss.Parser{}).Parse(bytes.NewReader(feedData)); err == nil {
+			feed, err = (&gofeed.DefaultRSSTranslator{}).Translate(rf)
+		}
+	case gofeed.FeedTypeAtom:
+		var af *atom.Feed
+		if af, err = (&atom.Parser{}).Parse(bytes.NewReader(feedData)); err == nil {
+			feed, err = (&gofeed.DefaultAtomTranslator{}).Translate(af)
+		}
+	case gofeed.FeedTypeJSON:
+		var jf *feedjson.Feed
+		if jf, err = (&feedjson.Parser{}).Parse(bytes.NewReader(feedData)); err == nil {
+			feed, err = (&gofeed.DefaultJSONTranslator{}).Translate(jf)
+		}
+	default:
+		err = gofeed.ErrFeedTypeNotDetected
+	}
+	if err != nil {
+		return nil, err
+	}
+	fillFromRDF(feed, feedData)
+	fillFromJSONFeed(feed, feedData)
+	return feed, nil
+}
+
+// feedType detects the format of feedData as gofeed.DetectFeedType does,
+// without copying it
+func feedType(feedData []byte) gofeed.FeedType {
+	trimmed := bytes.TrimLeft(feedData, " \r\n\t\xfe\xff\x00\xef\xbb\xbf")
+	switch {
+	case len(trimmed) == 0:
+		return gofeed.FeedTypeUnknown
+	case trimmed[0] == '{':
+		if json.Valid(trimmed) {
+			return gofeed.FeedTypeJSON
+		}
+	case trimmed[0] == '<':
+		d := newFeedDecoder(trimmed)
+		for {
+			tok, err := d.Token()
+			if err != nil {
+				return gofeed.FeedTypeUnknown
+			}
+			if start, ok := tok.(xml.StartElement); ok {
+				switch strings.ToLower(start.Name.Local) {
+				case "rss", "rdf":
+					return gofeed.FeedTypeRSS
+				case "feed":
+					return gofeed.FeedTypeAtom
+				}
+				return gofeed.FeedTypeUnknown
+			}
+		}
+	}
+	return gofeed.FeedTypeUnknown
+}
+
+// newFeedDecoder returns an XML decoder as lenient as the feed parsers
+func newFeedDecoder(data []byte) *xml.Decoder {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	d.Strict = false
+	d.Entity = xml.HTMLEntity
+	d.CharsetReader = charset.NewReaderLabel
+	return d
+}
+
+// streamFeed parses a feed like parseFeed, but hands its items to yield in
+// turn, stopping if yield returns false, instead of returning them. The
+// feed given with each item, and returned, has no items. An XML feed larger
+// than streamAbove bytes is parsed an item at a time, so that however many
+// items it has, only one is held in memory beside the feed's bytes.
+func streamFeed(feedData []byte, contentType string, streamAbove int, yield func(item *gofeed.Item, feed *gofeed.Feed) bool) (*gofeed.Feed, error) {
+	feedData = DecodeCharset(feedData, contentType)
+	if len(feedData) > streamAbove {
+		if split, ok := splitItems(feedData); ok {
+			if feed, err := split.stream(yield); err == nil {
+				return feed, nil
+			}
+		}
+	}
+
+	feed, err := parseDocument(feedData)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidFeed, err)
+	}
+	items := feed.Items
+	feed.Items = nil
+	for i, item := range items {
+		// Let each item go once it has been handed over
+		items[i] = nil
+		if !yield(item, feed) {
+			break
+		}
+	}
+	return feed, nil
+}
+
+// splitFeed is an XML feed with the byte ranges of its items
+type splitFeed struct {
+	data  []byte
+	items [][2]int64 // Start and end offsets in data
+}
+
+// itemParents are the elements whose item and entry children are a feed's
+// items: the channel of RSS 0.9x and 2.0, the root of RSS 1.0, and the
+// root of Atom
+var itemParents = map[string]bool{"channel": true, "RDF": true, "feed": true}
+
+// splitItems finds the items of an XML feed without parsing them. ok is
+// false if it isn't XML, or has no items.
+func splitItems(data []byte) (split splitFeed, ok bool) {
+	split.data = data
+	d := newFeedDecoder(data)
+	var parents []string
+	for {
+		start := d.InputOffset()
+		tok, err := d.Token()
+		if err == io.EOF {
+			return split, len(split.items) > 0
+		}
+		if err != nil {
+			return split, false
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if (t.Name.Local == "item" || t.Name.Local == "entry") && len(parents) > 0 && len(parents) <= 2 && itemParents[parents[len(parents)-1]] {
+				if err := d.Skip(); err != nil {
+					return split, false
+				}
+				split.items = append(split.items, [2]int64{start, d.InputOffset()})
+				continue
+			}
+			parents = append(parents, t.Name.Local)
+		case xml.EndElement:
+			if len(parents) > 0 {
+				parents = parents[:len(parents)-1]
+			}
+		}
+	}
+}
+
+// stream parses the feed without its items, then each item in a document
+// of its own: the feed up to the first item, the item, and the feed after
+// the last, so that its namespaces and base URL are those of the feed. An
+// item that doesn't parse on its own is skipped.
+func (s splitFeed) stream(yield func(item *gofeed.Item, feed *gofeed.Feed) bool) (*gofeed.Feed, error) {
+	head := make([]byte, 0, len(s.data)/(len(s.items)+1))
+	var last int64
+	for _, item := range s.items {
+		head = append(head, s.data[last:item[0]]...)
+		last = item[1]
+	}
+	head = append(head, s.data[last:]...)
+	feed, err := parseDocument(head)
+	if err != nil {
+		return nil, err
+	}
+	feed.Items = nil
+
+	prefix, suffix := s.data[:s.items[0][0]], s.data[s.items[len(s.items)-1][1]:]
+	var doc []byte
+	for _, item := range s.items {
+		doc = append(append(append(doc[:0], prefix...), s.data[item[0]:item[1]]...), suffix...)
+		single, err := parseDocument(doc)
+		if err != nil || len(single.Items) != 1 {
+			continue
+		}
+		if !yield(single.Items[0], feed) {
+			break
+		}
+	}
+	return feed, nil
+}
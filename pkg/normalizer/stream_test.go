@@ -0,0 +1,205 @@
+package normalizer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+)
+
+// largeFeed returns an RSS feed of n items with content of about size bytes
+// each, newest first
+func largeFeed(n, size int) []byte {
+	var sb strings.Builder
+	sb.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:content="http://purl.org/rss/1.0/modules/content/" xmlns:dc="http://purl.org/dc/elements/1.1/">
+<channel><title>Big Feed</title><link>https://example.com/</link><description>Lots</description>
+`)
+	body := strings.Repeat("<p>Words and <a href=\"/more\">links</a>.</p>", size/44+1)
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := n; i > 0; i-- {
+		fmt.Fprintf(&sb, `<item><title>Post %d</title><link>https://example.com/%d</link><guid>urn:post:%d</guid><dc:creator>Ada</dc:creator>
+<pubDate>%s</pubDate><content:encoded><![CDATA[%s]]></content:encoded></item>
+`, i, i, i, start.Add(time.Duration(i)*time.Hour).Format(time.RFC1123Z), body)
+	}
+	sb.WriteString("</channel></rss>")
+	return []byte(sb.String())
+}
+
+func TestSplitItemsStreamsLikeWholeParse(t *testing.T) {
+	t.Parallel()
+
+	feeds := map[string][]byte{
+		"rss 2.0": largeFeed(20, 200),
+		"rss 1.0": []byte(`<?xml version="1.0"?>
+<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#" xmlns="http://purl.org/rss/1.0/" xmlns:dc="http://purl.org/dc/elements/1.1/">
+<channel rdf:about="https://example.edu/"><title>Lab</title><link>https://example.edu/</link><description>R</description></channel>
+<item rdf:about="https://example.edu/1"><title>First</title><dc:date>2024-01-01</dc:date></item>
+<image rdf:about="https://example.edu/logo.png"><url>https://example.edu/logo.png</url></image>
+<item rdf:about="https://example.edu/2"><title>Second</title><dc:date>2024-02-03</dc:date></item>
+</rdf:RDF>`),
+	}
+	paths, err := filepath.Glob("../../testdata/*.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		feeds[filepath.Base(path)] = data
+	}
+
+	n := New()
+	fetched := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	normalize := func(items []*gofeed.Item, feed *gofeed.Feed) []Entry {
+		var entries []Entry
+		for _, item := range items {
+			entry, err := n.normalizeEntry(item, feed, "https://example.com/feed", fetched)
+			if err == nil {
+				entries = append(entries, entry)
+			}
+		}
+		return entries
+	}
+
+	for name, data := range feeds {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			whole, err := parseFeed(data, "")
+			if err != nil {
+				t.Fatalf("parseFeed() error = %v", err)
+			}
+			split, ok := splitItems(DecodeCharset(data, ""))
+			if !ok {
+				t.Fatal("splitItems() found no items")
+			}
+			if len(split.items) != len(whole.Items) {
+				t.Fatalf("splitItems() found %d items, the parser %d", len(split.items), len(whole.Items))
+			}
+			var streamed []*gofeed.Item
+			feed, err := split.stream(func(item *gofeed.Item, _ *gofeed.Feed) bool {
+				streamed = append(streamed, item)
+				return true
+			})
+			if err != nil {
+				t.Fatalf("stream() error = %v", err)
+			}
+			if feed.Title != whole.Title || feed.Link != whole.Link || feed.Language != whole.Language {
+				t.Errorf("streamed feed = %q %q %q, want %q %q %q", feed.Title, feed.Link, feed.Language, whole.Title, whole.Link, whole.Language)
+			}
+			want, got := normalize(whole.Items, whole), normalize(streamed, feed)
+			if !reflect.DeepEqual(got, want) {
+				for i := range min(len(got), len(want)) {
+					if !reflect.DeepEqual(got[i], want[i]) {
+						t.Fatalf("streamed entry %d = %+v\nwant %+v", i, got[i], want[i])
+					}
+				}
+				t.Fatalf("streamed %d entries, want %d", len(got), len(want))
+			}
+		})
+	}
+}
+
+func TestSplitItemsNotAFeed(t *testing.T) {
+	t.Parallel()
+	for _, data := range []string{`{"version": "https://jsonfeed.org/version/1.1", "items": []}`, `<rss><channel><title>Empty</title></channel></rss>`, `<rss><channel><item>`} {
+		if _, ok := splitItems([]byte(data)); ok {
+			t.Errorf("splitItems(%q) succeeded", data)
+		}
+	}
+}
+
+func TestParseLargeFeed(t *testing.T) {
+	t.Parallel()
+
+	data := largeFeed(300, 4000)
+	if len(data) <= streamThreshold {
+		t.Fatalf("test feed is %d bytes, want more than the %d streamed", len(data), streamThreshold)
+	}
+	meta, entries, err := New().Parse(context.Background(), data, "https://example.com/feed", time.Now())
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if meta.Title != "Big Feed" || meta.Truncated || len(entries) != 300 {
+		t.Fatalf("Parse() = %q, truncated %v, with %d entries, want Big Feed with all 300", meta.Title, meta.Truncated, len(entries))
+	}
+	if first := entries[0]; first.ID != "urn:post:300" || first.Author != "Ada" || first.WordCount < 300 {
+		t.Errorf("first entry = %s by %q with %d words, want post 300 by Ada with its content", first.ID, first.Author, first.WordCount)
+	}
+}
+
+func TestParseMaxEntries(t *testing.T) {
+	t.Parallel()
+
+	n := New()
+	n.SetMaxEntries(5)
+	for _, size := range []int{100, 8000} {
+		// Oldest first, so the newest come last
+		data := largeFeed(150, size)
+		meta, entries, err := n.Parse(context.Background(), reverseItems(data), "https://example.com/feed", time.Now())
+		if err != nil {
+			t.Fatalf("Parse() error = %v", err)
+		}
+		if !meta.Truncated || len(entries) != 5 {
+			t.Fatalf("Parse() truncated %v with %d entries, want 5", meta.Truncated, len(entries))
+		}
+		for i, e := range entries {
+			if want := fmt.Sprintf("urn:post:%d", 146+i); e.ID != want {
+				t.Errorf("entry %d = %s, want %s: the newest, in feed order", i, e.ID, want)
+			}
+		}
+	}
+
+	_, entries, err := n.Parse(context.Background(), largeFeed(5, 100), "https://example.com/feed", time.Now())
+	if err != nil || len(entries) != 5 {
+		t.Errorf("Parse() of a feed at the limit = %d entries, %v", len(entries), err)
+	}
+}
+
+// reverseItems returns a feed from largeFeed with its items oldest first
+func reverseItems(data []byte) []byte {
+	parts := strings.SplitAfter(string(data), "</item>\n")
+	head, _, _ := strings.Cut(parts[0], "<item>")
+	items := make([]string, 0, len(parts)-1)
+	for i, part := range parts[:len(parts)-1] {
+		if i == 0 {
+			part = part[len(head):]
+		}
+		items = append(items, part)
+	}
+	var sb strings.Builder
+	sb.WriteString(head)
+	for i := len(items) - 1; i >= 0; i-- {
+		sb.WriteString(items[i])
+	}
+	sb.WriteString(parts[len(parts)-1])
+	return []byte(sb.String())
+}
+
+func TestParseLowMemory(t *testing.T) {
+	t.Parallel()
+
+	data := largeFeed(20, 100)
+	fetched := time.Now()
+	_, want, err := New().Parse(context.Background(), data, "https://example.com/feed", fetched)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := New()
+	n.SetLowMemory(true)
+	_, got, err := n.Parse(context.Background(), data, "https://example.com/feed", fetched)
+	if err != nil {
+		t.Fatalf("Parse() in low memory error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Parse() in low memory = %d entries differing from the usual %d", len(got), len(want))
+	}
+}
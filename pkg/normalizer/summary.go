@@ -0,0 +1,69 @@
+package normalizer
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// textSummary makes a plain-text summary of HTML content: its first words,
+// at most maxWords, cut at the end of the last whole sentence among them.
+// Without a sentence end in that span it cuts at maxWords and adds an
+// ellipsis. The result is HTML-escaped, since summaries are stored as HTML.
+func textSummary(content string, maxWords int) string {
+	words := strings.Fields(plainText(content))
+	if len(words) == 0 || maxWords <= 0 {
+		return ""
+	}
+	if len(words) <= maxWords {
+		return html.EscapeString(strings.Join(words, " "))
+	}
+
+	words = words[:maxWords]
+	// Keep at least half the words, so one short opening sentence does
+	// not become the whole summary
+	for i := len(words) - 1; i >= maxWords/2; i-- {
+		if endsSentence(words[i]) {
+			return html.EscapeString(strings.Join(words[:i+1], " "))
+		}
+	}
+	return html.EscapeString(strings.Join(words, " ")) + "…"
+}
+
+// endsSentence reports whether a word ends a sentence, allowing for closing
+// quotes and brackets after the punctuation
+func endsSentence(word string) bool {
+	word = strings.TrimRight(word, `"')]’”»`)
+	return strings.HasSuffix(word, ".") || strings.HasSuffix(word, "!") || strings.HasSuffix(word, "?") || strings.HasSuffix(word, "…")
+}
+
+// plainText returns the text of an HTML fragment with entities decoded,
+// leaving out scripts and styles
+func plainText(fragment string) string {
+	z := html.NewTokenizer(strings.NewReader(fragment))
+	var b strings.Builder
+	skip := 0
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return b.String()
+		case html.TextToken:
+			if skip == 0 {
+				b.Write(z.Text())
+			}
+		case html.StartTagToken:
+			if name, _ := z.TagName(); string(name) == "script" || string(name) == "style" {
+				skip++
+			}
+			b.WriteByte(' ')
+		case html.EndTagToken:
+			if name, _ := z.TagName(); (string(name) == "script" || string(name) == "style") && skip > 0 {
+				skip--
+			}
+			b.WriteByte(' ')
+		default:
+			// Other tags separate words
+			b.WriteByte(' ')
+		}
+	}
+}
@@ -0,0 +1,280 @@
+package normalizer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/mmcdole/gofeed/atom"
+	"golang.org/x/net/html/charset"
+)
+
+// futureSlack is how far ahead of the check an entry may be dated before
+// it is reported, allowing for clock skew and time zone mistakes
+const futureSlack = 24 * time.Hour
+
+// Severity says whether a Problem breaks a feed's spec or is only unwise
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// Problem is one finding of Validate
+type Problem struct {
+	Severity Severity
+	Entry    int // 1-based position of the entry in the feed, or 0 for the feed itself
+	Message  string
+}
+
+// Report is the result of validating a feed
+type Report struct {
+	Format   string        // "RSS 2.0", "Atom 1.0", "JSON Feed 1.1", or "" if unrecognised
+	Encoding string        // Character encoding the feed declares, or "" if none
+	Feed     *FeedMetadata // The feed as rp would record it; nil if it doesn't parse
+	Entries  []Entry       // The entries as rp would store them
+	Problems []Problem
+}
+
+// Errors returns the number of problems of error severity
+func (r *Report) Errors() int {
+	n := 0
+	for _, p := range r.Problems {
+		if p.Severity == SeverityError {
+			n++
+		}
+	}
+	return n
+}
+
+func (r *Report) add(severity Severity, entry int, format string, args ...any) {
+	r.Problems = append(r.Problems, Problem{Severity: severity, Entry: entry, Message: fmt.Sprintf(format, args...)})
+}
+
+// Validate checks feedData against its format's spec and reports what rp
+// would make of it: missing or duplicate IDs, undated entries, dates that
+// don't parse or lie in the future, and encoding problems. contentType is
+// the Content-Type the feed was served with, or "" for a file. A feed that
+// doesn't parse is reported as a problem; the error is only for ctx.
+func (n *Normalizer) Validate(ctx context.Context, feedData []byte, feedURL, contentType string, now time.Time) (*Report, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	report := &Report{}
+	checkEncoding(report, feedData, contentType)
+
+	feed, err := parseFeed(feedData, contentType)
+	if err != nil {
+		report.add(SeverityError, 0, "not a feed rp can read: %v", err)
+		return report, nil
+	}
+	report.Format = formatName(feed)
+	report.Feed = &FeedMetadata{Title: feed.Title, Link: feed.Link, Updated: now}
+	if feed.UpdatedParsed != nil {
+		report.Feed.Updated = n.inFeedZone(feedURL, *feed.UpdatedParsed)
+	}
+
+	checkFeed(report, feed, DecodeCharset(feedData, contentType))
+
+	ids := make(map[string]int)
+	for i, item := range feed.Items {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		pos := i + 1
+		n.checkItem(report, pos, feed, item, feedURL, now)
+
+		entry, err := n.normalizeEntry(item, feed, feedURL, now)
+		if err != nil {
+			report.add(SeverityError, pos, "rp would skip this entry: %v", err)
+			continue
+		}
+		if first, ok := ids[entry.ID]; ok {
+			report.add(SeverityError, pos, "has the same ID as entry %d (%s); rp would keep only one", first, entry.ID)
+		} else {
+			ids[entry.ID] = pos
+		}
+		if strings.ContainsRune(entry.Title, utf8.RuneError) || strings.ContainsRune(entry.Content, utf8.RuneError) {
+			report.add(SeverityWarning, pos, "contains replacement characters (�), a sign of text in the wrong encoding")
+		}
+		report.Entries = append(report.Entries, entry)
+	}
+	return report, nil
+}
+
+// formatName names the feed's format and version
+func formatName(feed *gofeed.Feed) string {
+	name := map[string]string{"rss": "RSS", "atom": "Atom", "json": "JSON Feed"}[feed.FeedType]
+	if name == "" {
+		name = feed.FeedType
+	}
+	// JSON Feed versions are URLs like https://jsonfeed.org/version/1.1
+	if version := feed.FeedVersion[strings.LastIndex(feed.FeedVersion, "/")+1:]; version != "" {
+		return name + " " + version
+	}
+	return name
+}
+
+// checkEncoding reports bytes that aren't valid in the feed's encoding and
+// a Content-Type charset that disagrees with the XML declaration
+func checkEncoding(report *Report, feedData []byte, contentType string) {
+	if m := xmlDeclEncoding.FindSubmatch(feedData); m != nil {
+		report.Encoding = string(m[2])
+	}
+	served := ""
+	if contentType != "" {
+		if _, params, err := mime.ParseMediaType(contentType); err == nil {
+			served = params["charset"]
+		}
+	}
+
+	declared := report.Encoding
+	if declared == "" {
+		declared = served
+	}
+	if served != "" && report.Encoding != "" && !sameEncoding(served, report.Encoding) {
+		report.add(SeverityWarning, 0, "served as %s but declares encoding %s; readers that trust the server will garble non-ASCII text", served, report.Encoding)
+	}
+	if enc, _ := charset.Lookup(declared); declared != "" && enc == nil {
+		report.add(SeverityError, 0, "declares encoding %s, which rp doesn't know", declared)
+	}
+	if (declared == "" || sameEncoding(declared, "utf-8")) && !utf8.Valid(feedData) {
+		report.add(SeverityError, 0, "invalid UTF-8 at byte %d; rp reads it as %s, but declare the encoding the feed is really in, or convert it to UTF-8", validUTF8Prefix(feedData), fallbackCharset)
+	}
+}
+
+// sameEncoding reports whether two encoding labels name the same encoding
+func sameEncoding(a, b string) bool {
+	if _, nameA := charset.Lookup(a); nameA != "" {
+		_, nameB := charset.Lookup(b)
+		return nameA == nameB
+	}
+	norm := func(s string) string {
+		return strings.NewReplacer("-", "", "_", "").Replace(strings.ToLower(s))
+	}
+	return norm(a) == norm(b)
+}
+
+// validUTF8Prefix returns the length of the longest valid UTF-8 prefix of data
+func validUTF8Prefix(data []byte) int {
+	for i := 0; i < len(data); {
+		r, size := utf8.DecodeRune(data[i:])
+		if r == utf8.RuneError && size <= 1 {
+			return i
+		}
+		i += size
+	}
+	return len(data)
+}
+
+// checkFeed reports problems with the feed's own metadata
+func checkFeed(report *Report, feed *gofeed.Feed, feedData []byte) {
+	if strings.TrimSpace(feed.Title) == "" {
+		report.add(SeverityError, 0, "feed has no title; rp would list it by its URL")
+	}
+	if feed.Link == "" {
+		report.add(SeverityWarning, 0, "feed has no link to its site")
+	}
+	if len(feed.Items) == 0 {
+		report.add(SeverityWarning, 0, "feed has no entries")
+	}
+
+	switch feed.FeedType {
+	case "rss":
+		if feed.FeedVersion == "2.0" && strings.TrimSpace(feed.Description) == "" {
+			report.add(SeverityError, 0, "RSS channel has no description, which RSS 2.0 requires")
+		}
+	case "atom":
+		// The universal feed drops the feed's id, so look at the Atom itself
+		if raw, err := (&atom.Parser{}).Parse(bytes.NewReader(feedData)); err == nil {
+			if raw.ID == "" {
+				report.add(SeverityError, 0, "Atom feed has no id, which Atom requires")
+			}
+			if raw.Updated == "" {
+				report.add(SeverityError, 0, "Atom feed has no updated date, which Atom requires")
+			} else if raw.UpdatedParsed == nil {
+				report.add(SeverityError, 0, "Atom feed's updated date %q doesn't parse", raw.Updated)
+			}
+		}
+	}
+}
+
+// checkItem reports problems with one of the feed's items
+func (n *Normalizer) checkItem(report *Report, pos int, feed *gofeed.Feed, item *gofeed.Item, feedURL string, now time.Time) {
+	switch feed.FeedType {
+	case "rss":
+		if item.Title == "" && item.Description == "" {
+			report.add(SeverityError, pos, "item has neither a title nor a description, one of which RSS requires")
+		}
+	case "atom":
+		if item.GUID == "" {
+			report.add(SeverityError, pos, "entry has no id, which Atom requires")
+		}
+		if item.Title == "" {
+			report.add(SeverityError, pos, "entry has no title, which Atom requires")
+		}
+		if item.Updated == "" {
+			report.add(SeverityError, pos, "entry has no updated date, which Atom requires")
+		}
+	case "json":
+		if item.GUID == "" {
+			report.add(SeverityError, pos, "item has no id, which JSON Feed requires")
+		}
+		if item.Content == "" {
+			report.add(SeverityError, pos, "item has neither content_html nor content_text, one of which JSON Feed requires")
+		}
+	}
+
+	if item.GUID == "" && feed.FeedType == "rss" {
+		switch {
+		case item.Link != "":
+			report.add(SeverityWarning, pos, "item has no guid; rp identifies it by its link, so changing the link duplicates it")
+		case item.Title != "":
+			report.add(SeverityWarning, pos, "item has no guid or link; rp identifies it by a hash of its title, so editing the title duplicates it")
+		default:
+			report.add(SeverityWarning, pos, "item has no guid, link, or title; rp identifies it by a hash of its content, so editing it duplicates it")
+		}
+	}
+	if item.Link == "" {
+		report.add(SeverityWarning, pos, "entry has no link")
+	} else if u, err := url.Parse(item.Link); err != nil {
+		report.add(SeverityError, pos, "link %q is not a URL: %v", item.Link, err)
+	} else if !u.IsAbs() {
+		report.add(SeverityWarning, pos, "link %q is relative; rp resolves it against %s", item.Link, feedURL)
+	}
+
+	if item.Published != "" && item.PublishedParsed == nil {
+		report.add(SeverityError, pos, "published date %q doesn't parse", item.Published)
+	}
+	if item.Updated != "" && item.UpdatedParsed == nil {
+		report.add(SeverityError, pos, "updated date %q doesn't parse", item.Updated)
+	}
+	switch {
+	case item.PublishedParsed == nil && item.UpdatedParsed == nil && !hasDCDate(item):
+		switch {
+		case n.dates.Undated == UndatedSkip:
+			// Reported when normalizing it fails
+		case n.dates.Undated == UndatedFeedUpdated && feed.UpdatedParsed != nil:
+			report.add(SeverityWarning, pos, "entry is undated; rp would date it by the feed's updated date")
+		default:
+			report.add(SeverityWarning, pos, "entry is undated; rp would date it when it is first fetched")
+		}
+	case item.PublishedParsed != nil && n.inFeedZone(feedURL, *item.PublishedParsed).After(now.Add(futureSlack)):
+		published := n.inFeedZone(feedURL, *item.PublishedParsed).Format(time.RFC3339)
+		switch n.dates.Future {
+		case FutureSkip:
+			// Reported when normalizing it fails
+		case FutureKeep:
+			report.add(SeverityWarning, pos, "entry is dated %s, in the future; rp would keep it at the top until then", published)
+		default:
+			report.add(SeverityWarning, pos, "entry is dated %s, in the future; rp would date it when it is first fetched", published)
+		}
+	}
+}
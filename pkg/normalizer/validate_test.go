@@ -0,0 +1,158 @@
+package normalizer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name        string
+		feed        string
+		contentType string
+		format      string
+		want        []string // Substrings of expected problems, as "severity entry: message"
+		clean       bool     // No problems at all
+	}{
+		{
+			name: "valid RSS",
+			feed: `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0"><channel><title>Blog</title><link>https://example.com/</link><description>A blog</description>
+<item><title>Post</title><link>https://example.com/post</link><guid>https://example.com/post</guid><pubDate>Sun, 01 Mar 2026 10:00:00 GMT</pubDate></item>
+</channel></rss>`,
+			format: "RSS 2.0",
+			clean:  true,
+		},
+		{
+			name: "RSS problems",
+			feed: `<rss version="2.0"><channel><title>Blog</title><link>https://example.com/</link>
+<item><title>One</title><link>/one</link><pubDate>yesterday</pubDate></item>
+<item><title>Two</title><link>https://example.com/two</link><guid>same</guid><pubDate>Mon, 01 Jan 2035 00:00:00 GMT</pubDate></item>
+<item><title>Three</title><guid>same</guid><pubDate>Sun, 01 Mar 2026 10:00:00 GMT</pubDate></item>
+<item></item>
+</channel></rss>`,
+			format: "RSS 2.0",
+			want: []string{
+				"error 0: RSS channel has no description",
+				"warning 1: item has no guid; rp identifies it by its link",
+				`warning 1: link "/one" is relative`,
+				`error 1: published date "yesterday" doesn't parse`,
+				"warning 1: entry is undated",
+				"warning 2: entry is dated 2035-01-01T00:00:00Z, in the future",
+				"warning 3: entry has no link",
+				"error 3: has the same ID as entry 2",
+				"error 4: item has neither a title nor a description",
+				"warning 4: item has no guid, link, or title",
+				"warning 4: entry has no link",
+				"warning 4: entry is undated",
+			},
+		},
+		{
+			name: "Atom missing required elements",
+			feed: `<feed xmlns="http://www.w3.org/2005/Atom"><title>Blog</title><link href="https://example.com/"/>
+<entry><link href="https://example.com/a"/></entry>
+</feed>`,
+			format: "Atom 1.0",
+			want: []string{
+				"error 0: Atom feed has no id",
+				"error 0: Atom feed has no updated date",
+				"error 1: entry has no id",
+				"error 1: entry has no title",
+				"error 1: entry has no updated date",
+				"warning 1: entry is undated; rp would date it when it is first fetched",
+			},
+		},
+		{
+			name:   "JSON Feed missing id and content",
+			feed:   `{"version": "https://jsonfeed.org/version/1.1", "title": "Blog", "home_page_url": "https://example.com/", "items": [{"url": "https://example.com/a", "title": "A", "date_published": "2026-02-01T00:00:00Z"}]}`,
+			format: "JSON Feed 1.1",
+			want: []string{
+				"error 1: item has no id",
+				"error 1: item has neither content_html nor content_text",
+			},
+		},
+		{
+			name:        "invalid UTF-8 and mismatched charset",
+			feed:        "<?xml version=\"1.0\" encoding=\"UTF-8\"?><rss version=\"2.0\"><channel><title>Caf\xe9</title><link>https://example.com/</link><description>d</description></channel></rss>",
+			contentType: "application/rss+xml; charset=ISO-8859-1",
+			want: []string{
+				"warning 0: served as ISO-8859-1 but declares encoding UTF-8",
+				"error 0: invalid UTF-8 at byte 76; rp reads it as windows-1252",
+				"warning 0: feed has no entries",
+			},
+		},
+		{
+			name: "not a feed",
+			feed: "<html><body>Hello</body></html>",
+			want: []string{"error 0: not a feed rp can read"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			report, err := New().Validate(context.Background(), []byte(tt.feed), "https://example.com/feed", tt.contentType, now)
+			if err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			if tt.format != "" && report.Format != tt.format {
+				t.Errorf("Format = %q, want %q", report.Format, tt.format)
+			}
+
+			var got []string
+			for _, p := range report.Problems {
+				got = append(got, fmt.Sprintf("%s %d: %s", p.Severity, p.Entry, p.Message))
+			}
+			if tt.clean && len(got) > 0 {
+				t.Errorf("Validate() problems = %q, want none", got)
+			}
+			if len(got) != len(tt.want) && !tt.clean {
+				t.Errorf("Validate() found %d problems, want %d:\n%s", len(got), len(tt.want), strings.Join(got, "\n"))
+			}
+			for _, want := range tt.want {
+				found := false
+				for _, g := range got {
+					if strings.HasPrefix(g, want) {
+						found = true
+						break
+					}
+				}
+				if !found {
+					t.Errorf("Validate() missed %q in:\n%s", want, strings.Join(got, "\n"))
+				}
+			}
+		})
+	}
+}
+
+func TestValidateNormalizes(t *testing.T) {
+	t.Parallel()
+	feed := `<rss version="2.0"><channel><title>Blog</title><link>https://example.com/</link><description>d</description>
+<item><title>Post</title><link>/post</link><description><![CDATA[<p>Hello <script>alert(1)</script>world</p>]]></description><pubDate>Sun, 01 Mar 2026 10:00:00 GMT</pubDate></item>
+</channel></rss>`
+	report, err := New().Validate(context.Background(), []byte(feed), "https://example.com/feed", "", time.Now())
+	if err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if len(report.Entries) != 1 {
+		t.Fatalf("Entries = %d, want 1", len(report.Entries))
+	}
+	e := report.Entries[0]
+	if e.Link != "https://example.com/post" {
+		t.Errorf("Link = %q, want it resolved against the feed", e.Link)
+	}
+	if e.ID != "/post" {
+		t.Errorf("ID = %q, want the link rp falls back to", e.ID)
+	}
+	if strings.Contains(e.Content, "script") {
+		t.Errorf("Content = %q, want it sanitized", e.Content)
+	}
+	if report.Feed == nil || report.Feed.Title != "Blog" {
+		t.Errorf("Feed = %+v, want its metadata", report.Feed)
+	}
+}
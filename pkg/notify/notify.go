@@ -0,0 +1,224 @@
+// Package notify tells operators about feeds that have started failing.
+//
+// After a fetch run, Changes compares the feeds before and after the run
+// and reports those that reached the error threshold or went permanently
+// gone during it. A Summary of them is sent to a webhook (Slack and
+// Discord incoming webhooks both accept the payload) or by email. Feeds are
+// reported once, when they change, not on every run they stay broken.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/adewale/rogue_planet/pkg/repository"
+	"github.com/adewale/rogue_planet/pkg/webhook"
+)
+
+// Feed is a feed reported in a Summary
+type Feed struct {
+	URL    string
+	Title  string
+	Errors int    // Consecutive fetch errors
+	Error  string // Most recent error
+}
+
+// name returns the feed's title and URL, or just the URL if it has no title
+func (f Feed) name() string {
+	if f.Title == "" {
+		return f.URL
+	}
+	return f.Title + " (" + f.URL + ")"
+}
+
+// Summary lists the feeds whose state changed in a fetch run
+type Summary struct {
+	Planet    string // Planet name, used in the subject and heading
+	Threshold int    // Consecutive errors at which a feed is reported
+	Failing   []Feed // Feeds that reached Threshold in this run
+	Gone      []Feed // Feeds found to be permanently gone in this run
+}
+
+// Empty reports whether there is nothing to tell anyone
+func (s Summary) Empty() bool {
+	return len(s.Failing) == 0 && len(s.Gone) == 0
+}
+
+// Subject is a one-line description of the summary
+func (s Summary) Subject() string {
+	var parts []string
+	if n := len(s.Failing); n == 1 {
+		parts = append(parts, "1 feed failing")
+	} else if n > 1 {
+		parts = append(parts, fmt.Sprintf("%d feeds failing", n))
+	}
+	if n := len(s.Gone); n == 1 {
+		parts = append(parts, "1 feed gone")
+	} else if n > 1 {
+		parts = append(parts, fmt.Sprintf("%d feeds gone", n))
+	}
+	return s.Planet + ": " + strings.Join(parts, ", ")
+}
+
+// Text renders the summary as plain text
+func (s Summary) Text() string {
+	var b strings.Builder
+	b.WriteString(s.Subject())
+	b.WriteString("\n")
+	if len(s.Failing) > 0 {
+		fmt.Fprintf(&b, "\nFailed %d or more times in a row:\n", s.Threshold)
+		for _, f := range s.Failing {
+			fmt.Fprintf(&b, "- %s: %d errors, last: %s\n", f.name(), f.Errors, f.Error)
+		}
+	}
+	if len(s.Gone) > 0 {
+		b.WriteString("\nPermanently gone (410 Gone, or the host no longer exists); no longer fetched:\n")
+		for _, f := range s.Gone {
+			fmt.Fprintf(&b, "- %s\n", f.name())
+		}
+	}
+	b.WriteString("\nrp list-feeds --errors lists failing feeds; rp history --feed URL shows a feed's recent fetches.\n")
+	return b.String()
+}
+
+// Changes compares the feeds before a fetch run with the same feeds after
+// it, and returns the ones that reached threshold consecutive errors or went
+// gone. Feeds are matched by ID; feeds missing from after are ignored.
+func Changes(before, after []repository.Feed, threshold int) Summary {
+	prev := make(map[int64]repository.Feed, len(before))
+	for _, f := range before {
+		prev[f.ID] = f
+	}
+
+	s := Summary{Threshold: threshold}
+	for _, f := range after {
+		old, ok := prev[f.ID]
+		if !ok {
+			continue
+		}
+		feed := Feed{URL: f.URL, Title: f.Title, Errors: f.FetchErrorCount, Error: f.FetchError}
+		switch {
+		case old.GoneAt.IsZero() && !f.GoneAt.IsZero():
+			s.Gone = append(s.Gone, feed)
+		case threshold > 0 && old.FetchErrorCount < threshold && f.FetchErrorCount >= threshold:
+			s.Failing = append(s.Failing, feed)
+		}
+	}
+	return s
+}
+
+// Notifier sends a summary somewhere
+type Notifier interface {
+	Notify(ctx context.Context, s Summary) error
+}
+
+// Send sends the summary with each notifier, returning the first error.
+// An empty summary is not sent.
+func Send(ctx context.Context, s Summary, notifiers ...Notifier) error {
+	if s.Empty() {
+		return nil
+	}
+	var firstErr error
+	for _, n := range notifiers {
+		if err := n.Notify(ctx, s); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Webhook posts summaries as JSON to an incoming webhook URL. The payload
+// sets both "text" (Slack, Mattermost) and "content" (Discord).
+type Webhook struct {
+	URL    string
+	Client *http.Client // Defaults to a client with a 30 second timeout
+}
+
+// Notify posts the summary to the webhook
+func (w Webhook) Notify(ctx context.Context, s Summary) error {
+	text := s.Text()
+	body, err := json.Marshal(map[string]string{"text": text, "content": webhook.DiscordContent(text)})
+	if err != nil {
+		return fmt.Errorf("encode webhook payload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := w.Client
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("post webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("post webhook: server returned %s", resp.Status)
+	}
+	return nil
+}
+
+// Email sends summaries by SMTP. The connection is upgraded with STARTTLS
+// when the server offers it; credentials are only sent over TLS or to
+// localhost.
+type Email struct {
+	Addr     string // SMTP server host:port
+	From     string
+	To       []string
+	Username string // Optional; enables PLAIN authentication
+	Password string
+
+	send func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+}
+
+// Notify emails the summary
+func (e Email) Notify(ctx context.Context, s Summary) error {
+	var auth smtp.Auth
+	if e.Username != "" {
+		host, _, err := net.SplitHostPort(e.Addr)
+		if err != nil {
+			return fmt.Errorf("invalid SMTP address %q: %w", e.Addr, err)
+		}
+		auth = smtp.PlainAuth("", e.Username, e.Password, host)
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", e.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(e.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", s.Subject()))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	msg.WriteString("\r\n")
+	msg.WriteString(strings.ReplaceAll(s.Text(), "\n", "\r\n"))
+
+	send := e.send
+	if send == nil {
+		send = smtp.SendMail
+	}
+	// net/smtp has no context support, so the send runs until the server
+	// answers; ctx is only checked before starting
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := send(e.Addr, auth, e.From, e.To, msg.Bytes()); err != nil {
+		return fmt.Errorf("send email: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adewale/rogue_planet/pkg/repository"
+)
+
+func TestChanges(t *testing.T) {
+	t.Parallel()
+	gone := time.Now()
+	before := []repository.Feed{
+		{ID: 1, URL: "https://a.example.com/feed", FetchErrorCount: 4},
+		{ID: 2, URL: "https://b.example.com/feed", FetchErrorCount: 5},
+		{ID: 3, URL: "https://c.example.com/feed", FetchErrorCount: 1},
+		{ID: 4, URL: "https://d.example.com/feed", GoneAt: gone},
+		{ID: 5, URL: "https://e.example.com/feed", FetchErrorCount: 0},
+	}
+	after := []repository.Feed{
+		{ID: 1, URL: "https://a.example.com/feed", Title: "A", FetchErrorCount: 5, FetchError: "HTTP 500"}, // Crossed the threshold
+		{ID: 2, URL: "https://b.example.com/feed", FetchErrorCount: 6},                                     // Already reported
+		{ID: 3, URL: "https://c.example.com/feed", FetchErrorCount: 2, GoneAt: gone},                       // Went gone
+		{ID: 4, URL: "https://d.example.com/feed", GoneAt: gone},                                           // Already gone
+		{ID: 5, URL: "https://e.example.com/feed", FetchErrorCount: 0},
+		{ID: 6, URL: "https://f.example.com/feed", FetchErrorCount: 9}, // Not in this run
+	}
+
+	s := Changes(before, after, 5)
+	if len(s.Failing) != 1 || s.Failing[0].URL != "https://a.example.com/feed" || s.Failing[0].Errors != 5 || s.Failing[0].Error != "HTTP 500" {
+		t.Errorf("Failing = %+v, want only feed 1", s.Failing)
+	}
+	if len(s.Gone) != 1 || s.Gone[0].URL != "https://c.example.com/feed" {
+		t.Errorf("Gone = %+v, want only feed 3", s.Gone)
+	}
+
+	if s := Changes(before, after, 0); len(s.Failing) != 0 || len(s.Gone) != 1 {
+		t.Errorf("threshold 0 reported %d failing and %d gone, want 0 and 1", len(s.Failing), len(s.Gone))
+	}
+}
+
+func TestSummaryText(t *testing.T) {
+	t.Parallel()
+	s := Summary{
+		Planet:    "My Planet",
+		Threshold: 5,
+		Failing:   []Feed{{URL: "https://a.example.com/feed", Title: "A", Errors: 5, Error: "HTTP 500"}},
+		Gone:      []Feed{{URL: "https://b.example.com/feed"}, {URL: "https://c.example.com/feed"}},
+	}
+	if got, want := s.Subject(), "My Planet: 1 feed failing, 2 feeds gone"; got != want {
+		t.Errorf("Subject() = %q, want %q", got, want)
+	}
+	text := s.Text()
+	for _, want := range []string{
+		"Failed 5 or more times in a row:\n- A (https://a.example.com/feed): 5 errors, last: HTTP 500\n",
+		"- https://b.example.com/feed\n- https://c.example.com/feed\n",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Text() missing %q:\n%s", want, text)
+		}
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	t.Parallel()
+	var payload map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Content-Type = %q", ct)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("decode payload: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	s := Summary{Planet: "P", Threshold: 3, Gone: []Feed{{URL: "https://gone.example.com/feed"}}}
+	if err := (Webhook{URL: server.URL}).Notify(context.Background(), s); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+	if payload["text"] != s.Text() || payload["content"] != s.Text() {
+		t.Errorf("payload = %v, want text and content set to the summary", payload)
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "invalid_token", http.StatusForbidden)
+	}))
+	defer failing.Close()
+	if err := (Webhook{URL: failing.URL}).Notify(context.Background(), s); err == nil || !strings.Contains(err.Error(), "403") {
+		t.Errorf("Notify() to a failing webhook error = %v, want 403", err)
+	}
+}
+
+func TestEmail(t *testing.T) {
+	t.Parallel()
+	var gotAddr, gotFrom string
+	var gotTo []string
+	var gotMsg string
+	var gotAuth smtp.Auth
+	e := Email{
+		Addr:     "smtp.example.com:587",
+		From:     "planet@example.com",
+		To:       []string{"ops@example.com", "me@example.com"},
+		Username: "planet",
+		Password: "secret",
+		send: func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+			gotAddr, gotAuth, gotFrom, gotTo, gotMsg = addr, a, from, to, string(msg)
+			return nil
+		},
+	}
+
+	s := Summary{Planet: "Planète", Threshold: 5, Failing: []Feed{{URL: "https://a.example.com/feed", Errors: 5}}}
+	if err := e.Notify(context.Background(), s); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+	if gotAddr != e.Addr || gotFrom != e.From || len(gotTo) != 2 || gotAuth == nil {
+		t.Errorf("send(%q, %v, %q, %v), want the configured server, sender, recipients, and auth", gotAddr, gotAuth, gotFrom, gotTo)
+	}
+	for _, want := range []string{
+		"To: ops@example.com, me@example.com\r\n",
+		"Subject: =?utf-8?q?Plan=C3=A8te:_1_feed_failing?=\r\n",
+		"\r\n\r\nPlanète: 1 feed failing\r\n",
+	} {
+		if !strings.Contains(gotMsg, want) {
+			t.Errorf("message missing %q:\n%s", want, gotMsg)
+		}
+	}
+}
+
+func TestSendEmptySummary(t *testing.T) {
+	t.Parallel()
+	called := false
+	e := Email{Addr: "localhost:25", send: func(string, smtp.Auth, string, []string, []byte) error {
+		called = true
+		return nil
+	}}
+	if err := Send(context.Background(), Summary{Planet: "P"}, e); err != nil || called {
+		t.Errorf("Send() of an empty summary: error = %v, sent = %v", err, called)
+	}
+}
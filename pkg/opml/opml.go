@@ -3,6 +3,12 @@
 // The package supports OPML 1.0 and 2.0 formats for importing and exporting feed lists.
 // It handles both text/title attribute variations and xmlUrl/url variations for maximum
 // compatibility with different OPML readers and writers.
+//
+// Categories are represented both as nested outlines and via the OPML 2.0
+// category attribute. Nested categories use slash-delimited paths such as
+// "Tech/Go". Feed outlines may optionally carry fetch health attributes
+// (lastFetched, errorCount, lastError), which the OPML spec permits as
+// arbitrary outline attributes.
 package opml
 
 import (
@@ -10,6 +16,7 @@ import (
 	"encoding/xml"
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/adewale/rogue_planet/pkg/timeprovider"
@@ -45,15 +52,29 @@ type Outline struct {
 	Url     string `xml:"url,attr,omitempty"`     // Feed URL (OPML 1.0 compatibility)
 	HTMLUrl string `xml:"htmlUrl,attr,omitempty"` // Website URL
 
+	// Comma-separated, slash-delimited category paths (OPML 2.0)
+	Category string `xml:"category,attr,omitempty"`
+
+	// Fetch health attributes (Rogue Planet extension)
+	LastFetched string `xml:"lastFetched,attr,omitempty"` // RFC 822
+	ErrorCount  int    `xml:"errorCount,attr,omitempty"`
+	LastError   string `xml:"lastError,attr,omitempty"`
+
 	// For nested categories
 	Outlines []Outline `xml:"outline,omitempty"`
 }
 
 // Feed represents an extracted feed
 type Feed struct {
-	Title   string
-	FeedURL string
-	WebURL  string
+	Title      string
+	FeedURL    string
+	WebURL     string
+	Categories []string // Slash-delimited paths, e.g. "Tech/Go"
+
+	// Fetch health, exported only when Options.Health is set
+	LastFetched time.Time
+	ErrorCount  int
+	LastError   string
 }
 
 // Metadata for OPML generation
@@ -63,6 +84,11 @@ type Metadata struct {
 	OwnerEmail string
 }
 
+// Options controls optional parts of generated OPML
+type Options struct {
+	Health bool // Annotate feed outlines with lastFetched, errorCount and lastError
+}
+
 // Parse parses an OPML file from bytes
 func Parse(data []byte) (*OPML, error) {
 	var opml OPML
@@ -84,15 +110,17 @@ func ParseFile(ctx context.Context, path string) (*OPML, error) {
 	return Parse(data)
 }
 
-// ExtractFeeds extracts all feed URLs from OPML (flattens nested outlines)
+// ExtractFeeds extracts all feed URLs from OPML (flattens nested outlines).
+// Each feed's categories come from its enclosing outlines and its category attribute.
 func (o *OPML) ExtractFeeds() []Feed {
 	feeds := []Feed{}
-	o.extractOutlines(o.Body.Outlines, &feeds)
+	o.extractOutlines(o.Body.Outlines, nil, &feeds)
 	return feeds
 }
 
-// extractOutlines recursively extracts feeds from outlines
-func (o *OPML) extractOutlines(outlines []Outline, feeds *[]Feed) {
+// extractOutlines recursively extracts feeds from outlines. path holds the
+// text of the enclosing category outlines.
+func (o *OPML) extractOutlines(outlines []Outline, path []string, feeds *[]Feed) {
 	for _, outline := range outlines {
 		// Get feed URL (try xmlUrl first, then url for OPML 1.0 compatibility)
 		feedURL := outline.XMLUrl
@@ -108,20 +136,73 @@ func (o *OPML) extractOutlines(outlines []Outline, feeds *[]Feed) {
 				title = outline.Text
 			}
 
-			*feeds = append(*feeds, Feed{
-				Title:   title,
-				FeedURL: feedURL,
-				WebURL:  outline.HTMLUrl,
-			})
+			var categories []string
+			if len(path) > 0 {
+				categories = append(categories, strings.Join(path, "/"))
+			}
+			categories = appendCategories(categories, outline.Category)
+
+			feed := Feed{
+				Title:      title,
+				FeedURL:    feedURL,
+				WebURL:     outline.HTMLUrl,
+				Categories: categories,
+				ErrorCount: outline.ErrorCount,
+				LastError:  outline.LastError,
+			}
+			if outline.LastFetched != "" {
+				if t, err := ParseRFC822(outline.LastFetched); err == nil {
+					feed.LastFetched = t
+				}
+			}
+
+			*feeds = append(*feeds, feed)
 		}
 
-		// Recursively process nested outlines
+		// Recursively process nested outlines; an outline without a feed URL is a category
 		if len(outline.Outlines) > 0 {
-			o.extractOutlines(outline.Outlines, feeds)
+			childPath := path
+			if feedURL == "" {
+				name := outline.Title
+				if name == "" {
+					name = outline.Text
+				}
+				if name = cleanCategory(name); name != "" {
+					childPath = append(append([]string{}, path...), name)
+				}
+			}
+			o.extractOutlines(outline.Outlines, childPath, feeds)
 		}
 	}
 }
 
+// appendCategories adds the entries of a comma-separated category attribute,
+// skipping empty and duplicate values
+func appendCategories(categories []string, attr string) []string {
+	for _, c := range strings.Split(attr, ",") {
+		c = cleanCategory(c)
+		if c == "" {
+			continue
+		}
+		duplicate := false
+		for _, existing := range categories {
+			if existing == c {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			categories = append(categories, c)
+		}
+	}
+	return categories
+}
+
+// cleanCategory trims whitespace and the leading slash OPML category paths use
+func cleanCategory(s string) string {
+	return strings.Trim(strings.TrimSpace(s), "/")
+}
+
 // Generate creates OPML from feed list using the current system time
 func Generate(feeds []Feed, metadata Metadata) (*OPML, error) {
 	return GenerateWithTimeProvider(feeds, metadata, timeprovider.WallClock{})
@@ -130,7 +211,15 @@ func Generate(feeds []Feed, metadata Metadata) (*OPML, error) {
 // GenerateWithTimeProvider creates OPML from feed list with a custom TimeProvider.
 // This is primarily for testing with FakeClock.
 func GenerateWithTimeProvider(feeds []Feed, metadata Metadata, tp timeprovider.TimeProvider) (*OPML, error) {
-	outlines := make([]Outline, 0, len(feeds))
+	return GenerateWithOptions(feeds, metadata, Options{}, tp)
+}
+
+// GenerateWithOptions creates OPML from feed list. Feeds are nested under
+// outlines for their first category (slash-delimited paths become nested
+// outlines); feeds with several categories also list them all in the category
+// attribute. Outlines appear in the order they are first encountered.
+func GenerateWithOptions(feeds []Feed, metadata Metadata, opts Options, tp timeprovider.TimeProvider) (*OPML, error) {
+	root := &outlineNode{}
 
 	for _, feed := range feeds {
 		// Set both text and title for maximum compatibility
@@ -139,13 +228,38 @@ func GenerateWithTimeProvider(feeds []Feed, metadata Metadata, tp timeprovider.T
 			title = feed.FeedURL
 		}
 
-		outlines = append(outlines, Outline{
+		outline := Outline{
 			Text:    title,
 			Title:   title,
 			Type:    "rss",
 			XMLUrl:  feed.FeedURL,
 			HTMLUrl: feed.WebURL,
-		})
+		}
+
+		categories := appendCategories(nil, strings.Join(feed.Categories, ","))
+		if len(categories) > 1 {
+			paths := make([]string, len(categories))
+			for i, c := range categories {
+				paths[i] = "/" + c
+			}
+			outline.Category = strings.Join(paths, ",")
+		}
+
+		if opts.Health {
+			if !feed.LastFetched.IsZero() {
+				outline.LastFetched = FormatRFC822(feed.LastFetched)
+			}
+			outline.ErrorCount = feed.ErrorCount
+			outline.LastError = feed.LastError
+		}
+
+		parent := root
+		if len(categories) > 0 {
+			for _, name := range strings.Split(categories[0], "/") {
+				parent = parent.category(name)
+			}
+		}
+		parent.children = append(parent.children, &outlineNode{outline: outline})
 	}
 
 	opml := &OPML{
@@ -157,13 +271,46 @@ func GenerateWithTimeProvider(feeds []Feed, metadata Metadata, tp timeprovider.T
 			OwnerEmail:  metadata.OwnerEmail,
 		},
 		Body: Body{
-			Outlines: outlines,
+			Outlines: root.outlines(),
 		},
 	}
 
 	return opml, nil
 }
 
+// outlineNode builds the outline tree during generation
+type outlineNode struct {
+	outline    Outline
+	children   []*outlineNode
+	categories map[string]*outlineNode
+}
+
+// category returns the child category outline with the given name, creating it if needed
+func (n *outlineNode) category(name string) *outlineNode {
+	if child, ok := n.categories[name]; ok {
+		return child
+	}
+	if n.categories == nil {
+		n.categories = make(map[string]*outlineNode)
+	}
+	child := &outlineNode{outline: Outline{Text: name, Title: name}}
+	n.categories[name] = child
+	n.children = append(n.children, child)
+	return child
+}
+
+func (n *outlineNode) outlines() []Outline {
+	outlines := make([]Outline, 0, len(n.children))
+	for _, child := range n.children {
+		outline := child.outline
+		if len(child.children) > 0 {
+			outline.Outlines = child.outlines()
+		}
+		outlines = append(outlines, outline)
+	}
+	return outlines
+}
+
 // Marshal serializes OPML to XML bytes
 func (o *OPML) Marshal() ([]byte, error) {
 	output, err := xml.MarshalIndent(o, "", "  ")
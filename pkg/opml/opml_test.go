@@ -4,6 +4,7 @@ import (
 	"context"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
@@ -612,3 +613,149 @@ func TestParse_CDATA(t *testing.T) {
 		t.Fatalf("Expected 1 feed, got %d", len(feeds))
 	}
 }
+
+// Test that nested outlines become category paths
+func TestExtractFeeds_Categories(t *testing.T) {
+	t.Parallel()
+	opmlData := `<?xml version="1.0" encoding="UTF-8"?>
+<opml version="2.0">
+  <body>
+    <outline text="Tech">
+      <outline text="Go">
+        <outline text="Feed 1" xmlUrl="https://example.com/1"/>
+      </outline>
+      <outline text="Feed 2" xmlUrl="https://example.com/2" category="/News,/Tech"/>
+    </outline>
+    <outline text="Feed 3" xmlUrl="https://example.com/3"/>
+  </body>
+</opml>`
+
+	opml, err := Parse([]byte(opmlData))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	feeds := opml.ExtractFeeds()
+	if len(feeds) != 3 {
+		t.Fatalf("Expected 3 feeds, got %d", len(feeds))
+	}
+
+	want := [][]string{
+		{"Tech/Go"},
+		{"Tech", "News"},
+		nil,
+	}
+	for i, feed := range feeds {
+		if !reflect.DeepEqual(feed.Categories, want[i]) {
+			t.Errorf("Feed %d categories = %v, want %v", i, feed.Categories, want[i])
+		}
+	}
+}
+
+// Test that feeds are nested under category outlines
+func TestGenerate_NestedCategories(t *testing.T) {
+	t.Parallel()
+	feeds := []Feed{
+		{Title: "Go Blog", FeedURL: "https://example.com/go", Categories: []string{"Tech/Go"}},
+		{Title: "Loose", FeedURL: "https://example.com/loose"},
+		{Title: "Rust Blog", FeedURL: "https://example.com/rust", Categories: []string{"Tech/Rust", "Community"}},
+		{Title: "Other Go", FeedURL: "https://example.com/go2", Categories: []string{"Tech/Go"}},
+	}
+
+	opml, err := Generate(feeds, Metadata{Title: "Test"})
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	outlines := opml.Body.Outlines
+	if len(outlines) != 2 {
+		t.Fatalf("Expected 2 top-level outlines, got %d", len(outlines))
+	}
+	if outlines[0].Text != "Tech" || outlines[0].XMLUrl != "" {
+		t.Errorf("First outline = %+v, want Tech category", outlines[0])
+	}
+	if outlines[1].XMLUrl != "https://example.com/loose" {
+		t.Errorf("Second outline = %+v, want uncategorized feed", outlines[1])
+	}
+
+	tech := outlines[0].Outlines
+	if len(tech) != 2 || tech[0].Text != "Go" || tech[1].Text != "Rust" {
+		t.Fatalf("Tech children = %+v, want Go and Rust", tech)
+	}
+	if len(tech[0].Outlines) != 2 {
+		t.Errorf("Go category has %d feeds, want 2", len(tech[0].Outlines))
+	}
+
+	rust := tech[1].Outlines[0]
+	if rust.Category != "/Tech/Rust,/Community" {
+		t.Errorf("Rust category attribute = %q, want all categories", rust.Category)
+	}
+	if tech[0].Outlines[0].Category != "" {
+		t.Errorf("Single-category feed should rely on nesting, got category %q", tech[0].Outlines[0].Category)
+	}
+}
+
+// Test that categories and health metadata survive a round trip
+func TestRoundTrip_CategoriesAndHealth(t *testing.T) {
+	t.Parallel()
+	lastFetched := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	feeds := []Feed{
+		{
+			Title:       "Feed 1",
+			FeedURL:     "https://example.com/1",
+			Categories:  []string{"Tech/Go", "News"},
+			LastFetched: lastFetched,
+			ErrorCount:  3,
+			LastError:   "HTTP 503",
+		},
+		{Title: "Feed 2", FeedURL: "https://example.com/2"},
+	}
+
+	fake := timeprovider.NewFakeClock(lastFetched)
+	opml, err := GenerateWithOptions(feeds, Metadata{Title: "Test"}, Options{Health: true}, fake)
+	if err != nil {
+		t.Fatalf("GenerateWithOptions failed: %v", err)
+	}
+
+	xmlData, err := opml.Marshal()
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	for _, want := range []string{`lastFetched="Sat, 01 Mar 2025 12:00:00 +0000"`, `errorCount="3"`, `lastError="HTTP 503"`} {
+		if !strings.Contains(string(xmlData), want) {
+			t.Errorf("OPML missing %s\n%s", want, xmlData)
+		}
+	}
+
+	parsed, err := Parse(xmlData)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	extracted := parsed.ExtractFeeds()
+	if len(extracted) != 2 {
+		t.Fatalf("Expected 2 feeds, got %d", len(extracted))
+	}
+
+	got := extracted[0]
+	if !reflect.DeepEqual(got.Categories, feeds[0].Categories) {
+		t.Errorf("Categories = %v, want %v", got.Categories, feeds[0].Categories)
+	}
+	if !got.LastFetched.Equal(lastFetched) || got.ErrorCount != 3 || got.LastError != "HTTP 503" {
+		t.Errorf("Health = %v/%d/%q, want %v/3/HTTP 503", got.LastFetched, got.ErrorCount, got.LastError, lastFetched)
+	}
+	if len(extracted[1].Categories) != 0 {
+		t.Errorf("Uncategorized feed got categories %v", extracted[1].Categories)
+	}
+}
+
+// Test that health attributes are omitted unless requested
+func TestGenerate_HealthOmittedByDefault(t *testing.T) {
+	t.Parallel()
+	feeds := []Feed{{Title: "Feed", FeedURL: "https://example.com/feed", ErrorCount: 5, LastError: "boom", LastFetched: time.Now()}}
+
+	opml, _ := Generate(feeds, Metadata{Title: "Test"})
+	outline := opml.Body.Outlines[0]
+	if outline.ErrorCount != 0 || outline.LastError != "" || outline.LastFetched != "" {
+		t.Errorf("Health attributes should be omitted by default, got %+v", outline)
+	}
+}
@@ -0,0 +1,197 @@
+package planet
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/adewale/rogue_planet/pkg/config"
+	"github.com/adewale/rogue_planet/pkg/crawler"
+	"github.com/adewale/rogue_planet/pkg/extract"
+	"github.com/adewale/rogue_planet/pkg/filter"
+	"github.com/adewale/rogue_planet/pkg/generator"
+	"github.com/adewale/rogue_planet/pkg/hooks"
+	"github.com/adewale/rogue_planet/pkg/normalizer"
+	"github.com/adewale/rogue_planet/pkg/plugin"
+)
+
+// newFilterSet compiles the entry filters from the [filters] sections of the config
+func newFilterSet(cfg *config.Config) (*filter.Set, error) {
+	perFeed := make(map[string]filter.Rules, len(cfg.FeedFilters))
+	for url, fc := range cfg.FeedFilters {
+		perFeed[url] = filter.Rules(fc)
+	}
+	return filter.NewSet(filter.Rules(cfg.Filters), perFeed)
+}
+
+// newHooks returns the command hooks from the [hooks] section of the
+// config, or nil if there are none
+func newHooks(cfg *config.Config) *hooks.Hooks {
+	hc := cfg.Hooks
+	if len(hc.PreFetch)+len(hc.PostEntry)+len(hc.PreGenerate)+len(hc.PostGenerate) == 0 {
+		return nil
+	}
+	return &hooks.Hooks{
+		PreFetch:     hc.PreFetch,
+		PostEntry:    hc.PostEntry,
+		PreGenerate:  hc.PreGenerate,
+		PostGenerate: hc.PostGenerate,
+		Timeout:      time.Duration(hc.TimeoutSeconds) * time.Second,
+	}
+}
+
+// loadPlugins compiles the WebAssembly plugins from the [plugins] section
+// of the config, or returns nil if there are none
+func loadPlugins(ctx context.Context, cfg *config.Config) (*plugin.Plugins, error) {
+	pc := cfg.Plugins
+	if len(pc.PostEntry) == 0 {
+		return nil, nil
+	}
+	plugins, err := plugin.Load(ctx, pc.PostEntry, plugin.Options{
+		MemoryLimitMB: pc.MemoryLimitMB,
+		Timeout:       time.Duration(pc.TimeoutSeconds) * time.Second,
+		CacheDir:      pc.CacheDir,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("load plugins: %w", err)
+	}
+	return plugins, nil
+}
+
+// NewNormalizer builds the feed normalizer with the sanitization policies from
+// the [sanitize] sections of the config
+func NewNormalizer(cfg *config.Config) (*normalizer.Normalizer, error) {
+	perFeed := make(map[string]normalizer.Policy, len(cfg.FeedSanitize))
+	for url, sc := range cfg.FeedSanitize {
+		perFeed[url] = normalizer.Policy(sc)
+	}
+	n, err := normalizer.NewWithPolicy(normalizer.Policy(cfg.Sanitize), perFeed)
+	if err != nil {
+		return nil, err
+	}
+	n.SetSummaryWords(cfg.Planet.SummaryWords)
+	n.SetMaxEntries(cfg.Planet.MaxParsedEntries)
+	n.SetLowMemory(cfg.Planet.LowMemory)
+	n.SetDatePolicy(normalizer.DatePolicy{Undated: cfg.Planet.UndatedEntries, Future: cfg.Planet.FutureDates})
+	zones := make(map[string]*time.Location)
+	for url, fc := range cfg.FeedSettings {
+		if fc.Timezone != nil {
+			zones[url] = fc.Timezone
+		}
+	}
+	n.SetFeedTimezones(zones)
+	return n, nil
+}
+
+// NewExtractor returns the extractor of full content and preview images for
+// the feeds with extract_content or og_image set, or nil if there are none
+func NewExtractor(cfg *config.Config, c *crawler.Crawler, n *normalizer.Normalizer) *extract.Extractor {
+	var feeds, imageFeeds []string
+	for url, fc := range cfg.FeedSettings {
+		if fc.ExtractContent {
+			feeds = append(feeds, url)
+		}
+		if fc.OGImage {
+			imageFeeds = append(imageFeeds, url)
+		}
+	}
+	if len(feeds) == 0 && len(imageFeeds) == 0 {
+		return nil
+	}
+	return extract.New(c, n.SanitizeFeedHTML, feeds, imageFeeds)
+}
+
+// NewCrawler creates a crawler configured from the [planet] HTTP settings
+func NewCrawler(cfg *config.Config) *crawler.Crawler {
+	// robots_txt was validated when the config was loaded
+	robotsMode, _ := crawler.RobotsModeByName(cfg.Planet.RobotsTxt)
+	// So was tls_min_version
+	minTLSVersion, _ := crawler.TLSVersionByName(cfg.Planet.TLSMinVersion)
+	return crawler.NewWithConfig(crawler.CrawlerConfig{
+		UserAgent:                    cfg.Planet.UserAgent,
+		MaxIdleConns:                 cfg.Planet.MaxIdleConns,
+		MaxIdleConnsPerHost:          cfg.Planet.MaxIdleConnsPerHost,
+		MaxConnsPerHost:              cfg.Planet.MaxConnsPerHost,
+		IdleConnTimeoutSeconds:       cfg.Planet.IdleConnTimeoutSeconds,
+		HTTPTimeoutSeconds:           cfg.Planet.HTTPTimeoutSeconds,
+		DialTimeoutSeconds:           cfg.Planet.DialTimeoutSeconds,
+		TLSHandshakeTimeoutSeconds:   cfg.Planet.TLSHandshakeTimeoutSeconds,
+		ResponseHeaderTimeoutSeconds: cfg.Planet.ResponseHeaderTimeoutSeconds,
+		RobotsMode:                   robotsMode,
+		Credentials:                  feedCredentials(cfg),
+		MaxBandwidthKbps:             cfg.Planet.MaxBandwidthKbps,
+		SSRF:                         SSRFPolicy(cfg),
+		RootCAs:                      cfg.Planet.TLSRootCAs,
+		MinTLSVersion:                minTLSVersion,
+		InsecureSkipVerify:           InsecureFeeds(cfg),
+	})
+}
+
+// InsecureFeeds returns the URLs of the feeds with insecure_skip_verify set,
+// whose TLS certificates are not verified
+func InsecureFeeds(cfg *config.Config) map[string]bool {
+	insecure := make(map[string]bool)
+	for url, fc := range cfg.FeedSettings {
+		if fc.InsecureSkipVerify {
+			insecure[url] = true
+		}
+	}
+	return insecure
+}
+
+// SSRFPolicy returns the internal addresses and hosts the [planet] section
+// allows fetches to reach
+func SSRFPolicy(cfg *config.Config) crawler.Policy {
+	return crawler.Policy{
+		AllowNetworks: cfg.Planet.AllowNetworks,
+		AllowHosts:    cfg.Planet.AllowHosts,
+	}
+}
+
+// newFeedCrawler returns the crawler for fetching feeds: c, saving each
+// response in the response cache if one is configured
+func newFeedCrawler(cfg *config.Config, c *crawler.Crawler) (*crawler.Crawler, error) {
+	if cfg.Planet.ResponseCacheDir == "" {
+		return c, nil
+	}
+	if err := os.MkdirAll(cfg.Planet.ResponseCacheDir, 0755); err != nil {
+		return nil, fmt.Errorf("create response cache: %w", err)
+	}
+	maxAge := time.Duration(cfg.Planet.ResponseCacheMaxAgeMinutes) * time.Minute
+	return c.WithResponseCache(crawler.NewResponseCache(cfg.Planet.ResponseCacheDir, maxAge)), nil
+}
+
+// feedCredentials collects the authentication settings of [feed <URL>]
+// sections for the crawler
+func feedCredentials(cfg *config.Config) map[string]crawler.Credentials {
+	creds := make(map[string]crawler.Credentials)
+	for url, fc := range cfg.FeedSettings {
+		if fc.HasCredentials() {
+			creds[url] = crawler.Credentials{
+				Username: fc.Username,
+				Password: fc.Password,
+				Token:    fc.Token,
+				Headers:  fc.Headers,
+			}
+		}
+	}
+	return creds
+}
+
+// NewGenerator creates a generator for the configured template, or the
+// built-in one
+func NewGenerator(cfg *config.Config) (*generator.Generator, error) {
+	if cfg.Planet.Template != "" {
+		gen, err := generator.NewWithTemplate(cfg.Planet.Template)
+		if err != nil {
+			return nil, fmt.Errorf("create generator with template: %w", err)
+		}
+		return gen, nil
+	}
+	gen, err := generator.New()
+	if err != nil {
+		return nil, fmt.Errorf("create generator: %w", err)
+	}
+	return gen, nil
+}
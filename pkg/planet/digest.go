@@ -0,0 +1,96 @@
+package planet
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/adewale/rogue_planet/pkg/digest"
+	"github.com/adewale/rogue_planet/pkg/filter"
+	"github.com/adewale/rogue_planet/pkg/repository"
+	"github.com/adewale/rogue_planet/pkg/translate"
+)
+
+// Digest returns the entries first seen since a time, for emailing to
+// subscribers. Entries are those the site would show: from active feeds,
+// not hidden, and passing the filters. There are at most the config's
+// [digest] max_entries, newest first; Total counts them all.
+func (p *Planet) Digest(ctx context.Context, since time.Time) (digest.Digest, error) {
+	cfg := p.cfg
+	d := digest.Digest{Planet: cfg.Planet.Name, Link: cfg.Planet.Link, Since: since, Until: time.Now()}
+
+	entries, feedMap, err := p.newEntries(ctx, since)
+	if err != nil {
+		return d, err
+	}
+
+	d.Total = len(entries)
+	if limit := cfg.Digest.MaxEntries; limit > 0 && len(entries) > limit {
+		entries = entries[:limit]
+	}
+	authors := cfg.AuthorMap()
+	for _, entry := range entries {
+		feed := feedMap[entry.FeedID]
+		summary := entry.Summary
+		if summary == "" {
+			summary = entry.Content
+		}
+		d.Entries = append(d.Entries, digest.Entry{
+			Title:     translate.PlainText(entry.Title),
+			Link:      entry.Link,
+			Author:    authors.Canonical(entry.Author),
+			FeedTitle: feed.Title,
+			FeedLink:  feed.Link,
+			Published: entry.Published,
+			Summary:   digest.Excerpt(summary),
+		})
+	}
+	return d, nil
+}
+
+// newEntries returns the entries first seen since a time that the site
+// would show: from active feeds, not hidden, and passing the filters,
+// newest first. It returns the active feeds by ID too.
+func (p *Planet) newEntries(ctx context.Context, since time.Time) ([]repository.Entry, map[int64]*repository.Feed, error) {
+	cfg, repo := p.cfg, p.repo
+	entries, err := repo.GetNewEntries(ctx, since)
+	if err != nil {
+		return nil, nil, fmt.Errorf("get entries: %w", err)
+	}
+	feeds, err := repo.GetFeeds(ctx, true)
+	if err != nil {
+		return nil, nil, fmt.Errorf("get feeds: %w", err)
+	}
+	feedMap := make(map[int64]*repository.Feed, len(feeds))
+	for i := range feeds {
+		feedMap[feeds[i].ID] = &feeds[i]
+	}
+
+	// As in Generate, filters only apply here with filter_stage = generate;
+	// otherwise they kept entries out of the database
+	var filters *filter.Set
+	if cfg.Planet.FilterStage == "generate" {
+		if filters, err = newFilterSet(cfg); err != nil {
+			return nil, nil, fmt.Errorf("compile filters: %w", err)
+		}
+	}
+
+	kept := entries[:0]
+	for _, entry := range entries {
+		feed := feedMap[entry.FeedID]
+		if feed == nil {
+			continue
+		}
+		if keep, _ := filters.Match(feed.URL, filter.Item{
+			Title:      entry.Title,
+			Summary:    entry.Summary,
+			Content:    entry.Content,
+			Author:     entry.Author,
+			Categories: entry.Categories,
+		}); !keep {
+			continue
+		}
+		kept = append(kept, entry)
+	}
+	return kept, feedMap, nil
+}
@@ -0,0 +1,59 @@
+package planet
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/adewale/rogue_planet/pkg/repository"
+)
+
+func TestDigest(t *testing.T) {
+	t.Parallel()
+	cfg := newConfig(t)
+	cfg.Planet.FilterStage = "generate"
+	cfg.Filters.ExcludeKeywords = []string{"sponsored"}
+	cfg.Digest.MaxEntries = 2
+	p := openPlanet(t, cfg, nil)
+
+	ctx := context.Background()
+	repo := p.Repository()
+	id, err := repo.AddFeed(ctx, "https://feed.invalid/atom.xml", "Blog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().Truncate(time.Second)
+	for i, title := range []string{"Old news", "Sponsored: buy this", "Two", "Three", "Four"} {
+		seen := now.Add(time.Duration(i-4) * time.Hour)
+		if i == 0 {
+			seen = now.AddDate(0, 0, -2)
+		}
+		if err := repo.UpsertEntry(ctx, &repository.Entry{
+			FeedID: id, EntryID: title, Title: title, Link: fmt.Sprintf("https://feed.invalid/%d", i),
+			Summary: "<p>About " + title + "</p>", Published: seen, Updated: seen, FirstSeen: seen,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	d, err := p.Digest(ctx, now.Add(-24*time.Hour))
+	if err != nil {
+		t.Fatalf("Digest() error = %v", err)
+	}
+	// Old news is too old and the sponsored entry is filtered; of the
+	// three left, the newest two are included
+	if d.Total != 3 || len(d.Entries) != 2 || d.More() != 1 {
+		t.Fatalf("Digest() total = %d, entries = %+v", d.Total, d.Entries)
+	}
+	if e := d.Entries[1]; e.Title != "Three" || e.FeedTitle != "Blog" || e.Summary != "About Three" {
+		t.Errorf("entry = %+v", e)
+	}
+	if d.Planet != "Test Planet" || d.Link != "https://example.com" {
+		t.Errorf("Digest() planet = %q, %q", d.Planet, d.Link)
+	}
+
+	if d, _ := p.Digest(ctx, now.Add(time.Minute)); !d.Empty() {
+		t.Errorf("Digest() of the future = %+v, want empty", d)
+	}
+}
@@ -0,0 +1,567 @@
+package planet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/adewale/rogue_planet/pkg/config"
+	"github.com/adewale/rogue_planet/pkg/crawler"
+	"github.com/adewale/rogue_planet/pkg/fetcher"
+	"github.com/adewale/rogue_planet/pkg/hooks"
+	"github.com/adewale/rogue_planet/pkg/metrics"
+	"github.com/adewale/rogue_planet/pkg/normalizer"
+	"github.com/adewale/rogue_planet/pkg/notify"
+	"github.com/adewale/rogue_planet/pkg/plugin"
+	"github.com/adewale/rogue_planet/pkg/ratelimit"
+	"github.com/adewale/rogue_planet/pkg/repository"
+	"github.com/adewale/rogue_planet/pkg/scheduler"
+)
+
+// ErrInterrupted is returned by a fetch that was stopped before it fetched
+// every feed. The feeds it did not reach can be fetched with Resume.
+var ErrInterrupted = errors.New("operation cancelled by user")
+
+// FetchOptions adjust a fetch; the zero value fetches the feeds that are due
+type FetchOptions struct {
+	Force     bool              // Fetch feeds even if their HTTP cache is still fresh
+	Offline   bool              // Replay saved responses instead of using the network
+	Fixtures  string            // Replay the responses recorded in this directory (see crawler.NewFixtures) instead; implies Offline
+	Selection Selection         // Fetch only these feeds; the zero value selects every feed
+	Metrics   *metrics.Registry // Adds up the metrics of several runs; nil records this run only
+
+	// Wait is how long to wait for another run holding the run lock before
+	// failing with ErrRunInProgress
+	Wait time.Duration
+
+	// Abort, when closed, cancels the fetches in flight too, rather than
+	// letting them finish as cancelling the context does
+	Abort <-chan struct{}
+}
+
+// Fetch fetches the planet's feeds and stores their new entries, holding
+// the run lock
+func (p *Planet) Fetch(ctx context.Context, opts FetchOptions) error {
+	if opts.Fixtures != "" {
+		opts.Offline = true
+	} else if opts.Offline && p.cfg.Planet.ResponseCacheDir == "" {
+		return errors.New("working offline replays saved responses: set response_cache_dir in [planet] and fetch once online first")
+	}
+	return p.withRunLock(ctx, opts.Wait, func() error {
+		return p.fetch(ctx, opts)
+	})
+}
+
+// fetch fetches the feeds within fetch_stage_timeout_minutes; the caller
+// holds the run lock
+func (p *Planet) fetch(ctx context.Context, opts FetchOptions) error {
+	return withStageTimeout(ctx, "fetch", time.Duration(p.cfg.Planet.FetchStageTimeoutMinutes)*time.Minute, func(ctx context.Context) error {
+		return p.fetchFeeds(ctx, opts)
+	})
+}
+
+// fetchFeeds fetches the feeds until ctx is done
+func (p *Planet) fetchFeeds(ctx context.Context, opts FetchOptions) error {
+	cfg, repo, logger := p.cfg, p.repo, p.logger
+	fmt.Fprintln(p.out, "Fetching feeds...")
+
+	// Get feeds from database
+	feeds, err := repo.GetFeeds(ctx, true)
+	if err != nil {
+		return fmt.Errorf("get feeds: %w", err)
+	}
+
+	if len(feeds) == 0 {
+		fmt.Fprintln(p.out, "No feeds to fetch. Add feeds with 'rp add-feed <url>'")
+		return nil
+	}
+
+	if !opts.Selection.Empty() {
+		if feeds, err = opts.Selection.Filter(ctx, repo, feeds); err != nil {
+			return err
+		}
+		if len(feeds) == 0 {
+			if opts.Selection.Resume {
+				fmt.Fprintln(p.out, "No feeds left to resume; the last run finished.")
+			} else {
+				fmt.Fprintln(p.out, "No feeds match the selection.")
+			}
+			return nil
+		}
+		// Choosing feeds is an explicit request to contact them now
+		opts.Force = true
+	}
+
+	commandHooks := newHooks(cfg)
+	hookFeeds := make([]hooks.Feed, len(feeds))
+	for i, f := range feeds {
+		hookFeeds[i] = hooks.Feed{URL: f.URL, Title: f.Title, Link: f.Link}
+	}
+	if err := commandHooks.BeforeFetch(ctx, cfg.Planet.Name, hookFeeds); err != nil {
+		return err
+	}
+
+	concurrency := min(max(cfg.Planet.ConcurrentFetch, 1), len(feeds))
+	if cfg.Planet.LowMemory {
+		concurrency = 1
+	}
+	logger.Info("Fetching feeds", "feeds", len(feeds), "concurrency", concurrency, "low_memory", cfg.Planet.LowMemory)
+
+	c := NewCrawler(cfg)
+	feedCrawler, err := newFeedCrawler(cfg, c)
+	if err != nil {
+		return err
+	}
+	n, err := NewNormalizer(cfg)
+	if err != nil {
+		return err
+	}
+	plugins, err := loadPlugins(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer plugins.Close(context.WithoutCancel(ctx))
+
+	// Create rate limiter for per-domain rate limiting
+	rateLimiter := ratelimit.New(cfg.Planet.RequestsPerMinute, cfg.Planet.RateLimitBurst)
+	logger.Debug("Rate limiter configured", "requests_per_minute", cfg.Planet.RequestsPerMinute, "burst", cfg.Planet.RateLimitBurst, "max_bandwidth_kbps", cfg.Planet.MaxBandwidthKbps)
+	wait := rateLimiter.Wait
+
+	if opts.Offline {
+		// Replay the saved feed responses; pages and images are never saved,
+		// so extracting or fetching them fails
+		if opts.Fixtures != "" {
+			logger.Info("Replaying fixtures", "dir", opts.Fixtures)
+			fixtures := crawler.NewFixtures(opts.Fixtures)
+			c, feedCrawler = c.WithResponseCache(fixtures), feedCrawler.WithResponseCache(fixtures)
+		} else {
+			logger.Info("Working offline", "response_cache_dir", cfg.Planet.ResponseCacheDir)
+		}
+		c, feedCrawler = c.Offline(), feedCrawler.Offline()
+		opts.Force = true
+		wait = nil
+	}
+
+	// Shut down gracefully: ctx being cancelled stops new fetches and lets
+	// those in flight finish; closing opts.Abort aborts them too, as does
+	// the fetch stage running out of time
+	stop := make(chan struct{})
+	var stopOnce sync.Once
+	stopFetching := func() { stopOnce.Do(func() { close(stop) }) }
+	runCtx, abort := context.WithCancel(context.WithoutCancel(ctx))
+	defer abort()
+
+	finished := make(chan struct{})
+	defer close(finished)
+	go func() {
+		select {
+		case <-ctx.Done():
+			stopFetching()
+		case <-finished:
+			return
+		}
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			abort()
+			return
+		}
+		select {
+		case <-opts.Abort:
+			abort()
+		case <-finished:
+		}
+	}()
+
+	// Only the fetcher's writer touches the database, so no mutex is needed
+	feedFetcher, err := p.newFetcher(c, feedCrawler, n, nil, plugins, commandHooks)
+	if err != nil {
+		return err
+	}
+	feedFetcher.SetForce(opts.Force)
+	var skipped atomic.Int64
+	var doneMu sync.Mutex
+	done := make(map[int64]bool, len(feeds))
+	started := time.Now()
+	run := metrics.NewRun(started)
+
+	// Fetch, parse, and store feeds in a pipeline
+	feedFetcher.Run(runCtx, feeds, fetcher.RunOptions{
+		FetchWorkers: concurrency,
+		Sequential:   cfg.Planet.LowMemory,
+		FeedTimeout:  FeedTimeout(cfg),
+		WaitTimeout:  time.Duration(cfg.Planet.RateLimitWaitSeconds) * time.Second,
+		Wait:         wait,
+		Stop:         stop,
+		OnStart: func(index int, f repository.Feed) {
+			fmt.Fprintf(p.out, "  [%d/%d] Fetching %s\n", index+1, len(feeds), f.URL)
+		},
+		OnDone: func(index int, f repository.Feed, result fetcher.FetchResult) {
+			run.Record(fetchMetrics(result))
+			doneMu.Lock()
+			done[f.ID] = true
+			doneMu.Unlock()
+			switch {
+			case result.Skipped:
+				fmt.Fprintf(p.out, "  [%d/%d] Skipping %s (%s)\n", index+1, len(feeds), f.URL, result.SkipReason)
+				skipped.Add(1)
+			case result.Error != nil:
+				// Error already logged by fetcher
+			case result.NotModified:
+				fmt.Fprintf(p.out, "    %s: not modified (cached)\n", f.URL)
+			default:
+				fmt.Fprintf(p.out, "    %s: stored %d entries\n", f.URL, result.StoredEntries)
+			}
+		},
+	})
+
+	interrupted := false
+	select {
+	case <-stop:
+		interrupted = true
+	default:
+	}
+	timedOut := errors.Is(ctx.Err(), context.DeadlineExceeded)
+	// Record the outcome even when ctx is done; the watcher above still
+	// reads ctx, so it is not reassigned
+	bg := context.WithoutCancel(ctx)
+	remaining, err := updateFetchQueue(bg, repo, feeds, done, opts.Selection.Empty(), interrupted)
+	if err != nil {
+		logger.Warn("Failed to save the feeds left to fetch", "error", err)
+	}
+
+	run.Finish(time.Now())
+	reg := opts.Metrics
+	if reg == nil {
+		reg = metrics.NewRegistry()
+	}
+	reg.Add(run)
+	if cfg.Metrics.Textfile != "" {
+		if err := reg.WriteTextfile(cfg.Metrics.Textfile); err != nil {
+			logger.Warn("Failed to write metrics", "path", cfg.Metrics.Textfile, "error", err)
+		}
+	}
+
+	if cfg.Notify.Enabled() && !interrupted && !opts.Offline {
+		p.notifyChanges(bg, feeds)
+	}
+	if cfg.Webhook.Enabled() {
+		// first_seen is stored to the second
+		p.postNewEntries(bg, started.Truncate(time.Second))
+	}
+	if cfg.Related.Enabled {
+		p.relateNewEntries(bg, started.Truncate(time.Second))
+	}
+
+	if interrupted {
+		logger.Info("Fetch operation cancelled", "not_fetched", remaining)
+		if remaining > 0 {
+			fmt.Fprintf(p.out, "  Stopped before fetching %d feeds; run with --resume to fetch them\n", remaining)
+		}
+		if timedOut {
+			return context.DeadlineExceeded
+		}
+		return ErrInterrupted
+	}
+	logger.Info("Completed fetching all feeds")
+
+	if n := skipped.Load(); n > 0 {
+		fmt.Fprintf(p.out, "  Skipped %d feeds that are cached, not yet due, or backing off (use --force to fetch them)\n", n)
+	}
+
+	return nil
+}
+
+// FeedTimeout is the limit on fetching one feed, with its retries, and
+// parsing it: feed_timeout_seconds, or by default long enough for every
+// attempt max_retries allows to take the whole HTTP timeout, with the
+// backoffs between them
+func FeedTimeout(cfg *config.Config) time.Duration {
+	if cfg.Planet.FeedTimeoutSeconds > 0 {
+		return time.Duration(cfg.Planet.FeedTimeoutSeconds) * time.Second
+	}
+	attempt := time.Duration(cfg.Planet.HTTPTimeoutSeconds) * time.Second
+	if attempt <= 0 {
+		attempt = crawler.DefaultTimeout
+	}
+	timeout := attempt
+	for retry := 1; retry <= cfg.Planet.MaxRetries; retry++ {
+		// FetchWithRetry's backoff, 1s doubling each retry, plus its jitter
+		timeout += attempt + time.Duration(1<<(retry-1))*1100*time.Millisecond
+	}
+	return timeout
+}
+
+// newFetcher returns a fetcher storing the planet's feeds with the
+// scheduler, filters, article extractor, and entry hooks the config asks
+// for, so that every fetch treats entries alike. mu guards the repository,
+// or is nil if only the fetcher's writer uses it.
+func (p *Planet) newFetcher(c, feedCrawler *crawler.Crawler, n *normalizer.Normalizer, mu sync.Locker, plugins *plugin.Plugins, commandHooks *hooks.Hooks) (*fetcher.Fetcher, error) {
+	cfg := p.cfg
+	feedFetcher := fetcher.New(feedCrawler, n, p.repo, mu, p.logger, cfg.Planet.MaxRetries)
+	if cfg.Planet.AdaptiveScheduling {
+		feedFetcher.SetScheduler(scheduler.New(
+			time.Duration(cfg.Planet.MinFetchIntervalMinutes)*time.Minute,
+			time.Duration(cfg.Planet.MaxFetchIntervalMinutes)*time.Minute,
+		))
+	}
+	if cfg.Planet.FilterStage != "generate" {
+		filters, err := newFilterSet(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("compile filters: %w", err)
+		}
+		feedFetcher.SetFilters(filters)
+	}
+	feedFetcher.SetExtractor(NewExtractor(cfg, c, n))
+	var entryHooks []fetcher.EntryHook
+	if plugins != nil {
+		entryHooks = append(entryHooks, plugins)
+	}
+	if commandHooks.HasEntryHooks() {
+		entryHooks = append(entryHooks, commandHooks)
+	}
+	feedFetcher.SetEntryHooks(n.SanitizeFeedHTML, entryHooks...)
+	feedFetcher.SetDeactivateAfter(cfg.Planet.DeactivateAfterErrors)
+	return feedFetcher, nil
+}
+
+// fetchNewFeed fetches a just-added feed, ignoring any schedule
+func (p *Planet) fetchNewFeed(ctx context.Context, id int64) fetcher.FetchResult {
+	feed, err := p.repo.GetFeedByID(ctx, id)
+	if err != nil {
+		return fetcher.FetchResult{Error: err}
+	}
+
+	n, err := NewNormalizer(p.cfg)
+	if err != nil {
+		return fetcher.FetchResult{Error: err}
+	}
+
+	c := NewCrawler(p.cfg)
+	feedCrawler, err := newFeedCrawler(p.cfg, c)
+	if err != nil {
+		return fetcher.FetchResult{Error: err}
+	}
+	plugins, err := loadPlugins(ctx, p.cfg)
+	if err != nil {
+		return fetcher.FetchResult{Error: err}
+	}
+	defer plugins.Close(context.WithoutCancel(ctx))
+
+	var mu sync.Mutex
+	feedFetcher, err := p.newFetcher(c, feedCrawler, n, &mu, plugins, newHooks(p.cfg))
+	if err != nil {
+		return fetcher.FetchResult{Error: err}
+	}
+	feedFetcher.SetForce(true)
+
+	fetchCtx, cancel := context.WithTimeout(ctx, FeedTimeout(p.cfg))
+	defer cancel()
+	return feedFetcher.FetchFeed(fetchCtx, *feed)
+}
+
+// Selection picks the feeds an update or fetch run fetches. A feed is
+// selected if it matches every criterion given.
+type Selection struct {
+	Patterns   []string // Feed URLs or globs, where * matches any run of characters; a feed must match one
+	Tags       []string // Feed categories (case-insensitive); a feed must have one
+	OnlyErrors bool     // Only feeds whose last fetch failed
+	Resume     bool     // Only feeds an interrupted run did not reach
+
+	Match     []string // Text in the feed's URL or title (case-insensitive); a feed must contain one
+	MinErrors int      // Only feeds with at least this many consecutive fetch errors
+	Inactive  bool     // Only feeds that are deactivated and not fetched
+}
+
+// Empty reports whether s selects every feed
+func (s Selection) Empty() bool {
+	return len(s.Patterns) == 0 && len(s.Tags) == 0 && !s.OnlyErrors && !s.Resume &&
+		len(s.Match) == 0 && s.MinErrors == 0 && !s.Inactive
+}
+
+// Filter returns the selected feeds. It fails if a pattern matches none of
+// feeds, since that is almost always a typo.
+func (s Selection) Filter(ctx context.Context, repo *repository.Repository, feeds []repository.Feed) ([]repository.Feed, error) {
+	if s.Empty() {
+		return feeds, nil
+	}
+
+	var categories map[int64][]string
+	if len(s.Tags) > 0 {
+		var err error
+		if categories, err = repo.GetAllFeedCategories(ctx); err != nil {
+			return nil, fmt.Errorf("get feed categories: %w", err)
+		}
+	}
+	var queued map[int64]bool
+	if s.Resume {
+		ids, err := repo.GetFetchQueue(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("get fetch queue: %w", err)
+		}
+		queued = make(map[int64]bool, len(ids))
+		for _, id := range ids {
+			queued[id] = true
+		}
+	}
+
+	matched := make([]bool, len(s.Patterns))
+	var selected []repository.Feed
+	for _, feed := range feeds {
+		if len(s.Patterns) > 0 {
+			found := false
+			for i, pattern := range s.Patterns {
+				if globMatch(pattern, feed.URL) {
+					matched[i], found = true, true
+				}
+			}
+			if !found {
+				continue
+			}
+		}
+		if len(s.Tags) > 0 && !hasAnyTag(categories[feed.ID], s.Tags) {
+			continue
+		}
+		if s.OnlyErrors && feed.FetchErrorCount == 0 {
+			continue
+		}
+		if s.Resume && !queued[feed.ID] {
+			continue
+		}
+		if len(s.Match) > 0 && !containsAny(feed, s.Match) {
+			continue
+		}
+		if feed.FetchErrorCount < s.MinErrors || (s.Inactive && feed.Active) {
+			continue
+		}
+		selected = append(selected, feed)
+	}
+
+	for i, pattern := range s.Patterns {
+		if !matched[i] {
+			return nil, fmt.Errorf("no feed matches %s", pattern)
+		}
+	}
+	return selected, nil
+}
+
+// containsAny reports whether the feed's URL or title contains one of texts,
+// ignoring case
+func containsAny(feed repository.Feed, texts []string) bool {
+	url, title := strings.ToLower(feed.URL), strings.ToLower(feed.Title)
+	for _, text := range texts {
+		text = strings.ToLower(text)
+		if strings.Contains(url, text) || strings.Contains(title, text) {
+			return true
+		}
+	}
+	return false
+}
+
+// globMatch reports whether s matches pattern, where * matches any run of
+// characters (including /) and ? matches one character
+func globMatch(pattern, s string) bool {
+	if !strings.ContainsAny(pattern, "*?") {
+		return pattern == s
+	}
+	expr := regexp.QuoteMeta(pattern)
+	expr = strings.ReplaceAll(expr, `\*`, ".*")
+	expr = strings.ReplaceAll(expr, `\?`, ".")
+	return regexp.MustCompile("^" + expr + "$").MatchString(s)
+}
+
+// updateFetchQueue records which feeds are still to be fetched after a run
+// over feeds, of which those in done were dealt with, and returns how many
+// of feeds were not. A full run replaces the queue; a run over a selection
+// only takes the feeds it dealt with out of it. An interrupted run queues
+// the feeds it did not reach, for --resume.
+func updateFetchQueue(ctx context.Context, repo *repository.Repository, feeds []repository.Feed, done map[int64]bool, full, interrupted bool) (int, error) {
+	var queue []int64
+	if !full {
+		ids, err := repo.GetFetchQueue(ctx)
+		if err != nil {
+			return 0, fmt.Errorf("get fetch queue: %w", err)
+		}
+		for _, id := range ids {
+			if !done[id] {
+				queue = append(queue, id)
+			}
+		}
+	}
+
+	remaining := 0
+	for _, f := range feeds {
+		if !done[f.ID] {
+			remaining++
+			if interrupted {
+				queue = append(queue, f.ID)
+			}
+		}
+	}
+	return remaining, repo.SetFetchQueue(ctx, queue)
+}
+
+// notifyTimeout limits sending the notifications after a fetch run
+const notifyTimeout = 30 * time.Second
+
+// notifyChanges tells the configured webhook and email recipients about
+// feeds that reached the error threshold or went gone since before was read.
+// Failures are logged; they do not fail the run.
+func (p *Planet) notifyChanges(ctx context.Context, before []repository.Feed) {
+	cfg, logger := p.cfg, p.logger
+	after, err := p.repo.GetFeeds(ctx, false)
+	if err != nil {
+		logger.Warn("Failed to read feeds for notifications", "error", err)
+		return
+	}
+	summary := notify.Changes(before, after, cfg.Notify.ErrorThreshold)
+	if summary.Empty() {
+		return
+	}
+	summary.Planet = cfg.Planet.Name
+
+	var notifiers []notify.Notifier
+	if cfg.Notify.WebhookURL != "" {
+		notifiers = append(notifiers, notify.Webhook{URL: cfg.Notify.WebhookURL})
+	}
+	if cfg.Notify.SMTPAddr != "" {
+		notifiers = append(notifiers, notify.Email{
+			Addr:     cfg.Notify.SMTPAddr,
+			From:     cfg.Notify.EmailFrom,
+			To:       cfg.Notify.EmailTo,
+			Username: cfg.Notify.SMTPUsername,
+			Password: cfg.Notify.SMTPPassword,
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, notifyTimeout)
+	defer cancel()
+	if err := notify.Send(ctx, summary, notifiers...); err != nil {
+		logger.Warn("Failed to send notification", "error", err)
+		return
+	}
+	logger.Info("Sent notification", "failing", len(summary.Failing), "gone", len(summary.Gone))
+}
+
+// fetchMetrics describes a feed's fetch for the metrics
+func fetchMetrics(result fetcher.FetchResult) metrics.Fetch {
+	m := metrics.Fetch{
+		Entries:  result.StoredEntries,
+		Bytes:    result.WireBytes,
+		Duration: result.FetchDuration,
+	}
+	switch {
+	case result.Skipped:
+		m.Outcome = metrics.Skipped
+	case result.Error != nil:
+		m.Outcome = metrics.Failed
+	case result.NotModified:
+		m.Outcome = metrics.NotModified
+	default:
+		m.Outcome = metrics.Stored
+	}
+	return m
+}
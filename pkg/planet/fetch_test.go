@@ -0,0 +1,364 @@
+package planet
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/adewale/rogue_planet/pkg/config"
+	"github.com/adewale/rogue_planet/pkg/fetcher"
+	"github.com/adewale/rogue_planet/pkg/metrics"
+	"github.com/adewale/rogue_planet/pkg/repository"
+)
+
+// fetch opens the planet cfg describes and fetches its feeds
+func fetch(ctx context.Context, cfg *config.Config, opts FetchOptions) error {
+	p, err := Open(cfg, Options{})
+	if err != nil {
+		return err
+	}
+	defer p.Close()
+	return p.Fetch(ctx, opts)
+}
+
+func TestFetchSkipsFreshCache(t *testing.T) {
+	t.Parallel()
+	cfg := newConfig(t)
+	repo, err := OpenRepository(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	id, err := repo.AddFeed(ctx, "https://feed.invalid/atom.xml", "Fresh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.UpdateFeedCacheExpiry(ctx, id, time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	repo.Close()
+
+	if err := fetch(ctx, cfg, FetchOptions{}); err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+
+	repo, err = OpenRepository(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+	log, err := repo.GetFetchLog(ctx, id, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(log) != 0 {
+		t.Errorf("feed with a fresh cache was fetched: %+v", log)
+	}
+}
+
+func TestFetchPreFetchHook(t *testing.T) {
+	t.Parallel()
+	cfg := newConfig(t)
+	repo, err := OpenRepository(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	id, err := repo.AddFeed(ctx, "https://feed.invalid/atom.xml", "Blog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo.Close()
+
+	// A failing pre_fetch hook stops the fetch before any feed is contacted
+	cfg.Hooks.PreFetch = []string{"grep -q feed.invalid/atom.xml && exit 9"}
+	cfg.Hooks.TimeoutSeconds = 60
+	if err := fetch(ctx, cfg, FetchOptions{}); err == nil || !strings.Contains(err.Error(), "pre_fetch hook") {
+		t.Fatalf("Fetch() error = %v, want the pre_fetch hook's failure", err)
+	}
+
+	repo, err = OpenRepository(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+	log, err := repo.GetFetchLog(ctx, id, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(log) != 0 {
+		t.Errorf("feed fetched despite the pre_fetch hook failing: %+v", log)
+	}
+}
+
+func TestFetchWritesMetrics(t *testing.T) {
+	t.Parallel()
+	cfg := newConfig(t)
+	cfg.Metrics.Textfile = filepath.Join(t.TempDir(), "rogue_planet.prom")
+	repo, err := OpenRepository(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	id, err := repo.AddFeed(ctx, "https://feed.invalid/atom.xml", "Fresh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.UpdateFeedCacheExpiry(ctx, id, time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	repo.Close()
+
+	reg := metrics.NewRegistry()
+	for range 2 {
+		if err := fetch(ctx, cfg, FetchOptions{Metrics: reg}); err != nil {
+			t.Fatalf("Fetch() error = %v", err)
+		}
+	}
+
+	data, err := os.ReadFile(cfg.Metrics.Textfile)
+	if err != nil {
+		t.Fatalf("metrics textfile not written: %v", err)
+	}
+	for _, want := range []string{
+		"rogue_planet_runs_total 2\n",
+		"rogue_planet_feeds_skipped_total 2\n",
+		"rogue_planet_last_run_feeds_skipped 1\n",
+		"rogue_planet_last_run_feeds_fetched 0\n",
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("metrics textfile missing %q:\n%s", want, data)
+		}
+	}
+}
+
+func TestFetchNotify(t *testing.T) {
+	t.Parallel()
+	var mu sync.Mutex
+	var payloads []map[string]string
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]string
+		json.NewDecoder(r.Body).Decode(&payload)
+		mu.Lock()
+		payloads = append(payloads, payload)
+		mu.Unlock()
+	}))
+	defer webhook.Close()
+
+	cfg := newConfig(t)
+	cfg.Notify.WebhookURL = webhook.URL
+	cfg.Notify.ErrorThreshold = 2
+	repo, err := OpenRepository(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Private addresses are refused without a retry, so each run adds one error
+	feedURL := "http://127.0.0.1:1/feed.xml"
+	if _, err := repo.AddFeed(context.Background(), feedURL, "Broken"); err != nil {
+		t.Fatal(err)
+	}
+	repo.Close()
+
+	// The feed reaches the threshold on the second run and is reported once
+	for range 3 {
+		if err := fetch(context.Background(), cfg, FetchOptions{Force: true}); err != nil {
+			t.Fatalf("Fetch() error = %v", err)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(payloads) != 1 {
+		t.Fatalf("webhook called %d times, want once", len(payloads))
+	}
+	if text := payloads[0]["text"]; !strings.Contains(text, "1 feed failing") || !strings.Contains(text, "Broken ("+feedURL+"): 2 errors") {
+		t.Errorf("notification text = %q", text)
+	}
+}
+
+func TestGlobMatch(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		pattern, s string
+		want       bool
+	}{
+		{"https://a.example.com/feed", "https://a.example.com/feed", true},
+		{"https://a.example.com/feed", "https://a.example.com/feed.xml", false},
+		{"https://*.example.com/*", "https://blog.example.com/posts/feed.xml", true},
+		{"https://*.example.com/*", "https://example.com/feed", false},
+		{"*medium.com*", "https://medium.com/feed/@someone", true},
+		{"https://a.example.com/feed?.xml", "https://a.example.com/feed2.xml", true},
+		{"https://a.example.com/feed.xml", "https://a.example.com/feedXxml", false},
+	}
+	for _, tt := range tests {
+		if got := globMatch(tt.pattern, tt.s); got != tt.want {
+			t.Errorf("globMatch(%q, %q) = %v, want %v", tt.pattern, tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestSelectionFilter(t *testing.T) {
+	t.Parallel()
+	cfg := newConfig(t)
+	repo, err := OpenRepository(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+
+	ctx := context.Background()
+	add := func(url string, categories []string, failing bool) {
+		id, err := repo.AddFeed(ctx, url, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := repo.SetFeedCategories(ctx, id, categories); err != nil {
+			t.Fatal(err)
+		}
+		if failing {
+			if err := repo.UpdateFeedError(ctx, id, "HTTP 500"); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	add("https://go.example.com/feed", []string{"Go"}, false)
+	add("https://rust.example.com/feed", []string{"Rust"}, true)
+	add("https://news.example.org/feed", nil, true)
+	feeds, err := repo.GetFeeds(ctx, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range feeds {
+		if f.URL == "https://news.example.org/feed" {
+			if err := repo.SetFetchQueue(ctx, []int64{f.ID}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	tests := []struct {
+		name      string
+		selection Selection
+		want      []string
+		wantErr   bool
+	}{
+		{"everything", Selection{}, []string{"https://go.example.com/feed", "https://rust.example.com/feed", "https://news.example.org/feed"}, false},
+		{"one URL", Selection{Patterns: []string{"https://rust.example.com/feed"}}, []string{"https://rust.example.com/feed"}, false},
+		{"glob", Selection{Patterns: []string{"https://*.example.com/*"}}, []string{"https://go.example.com/feed", "https://rust.example.com/feed"}, false},
+		{"tag ignores case", Selection{Tags: []string{"go"}}, []string{"https://go.example.com/feed"}, false},
+		{"only errors", Selection{OnlyErrors: true}, []string{"https://rust.example.com/feed", "https://news.example.org/feed"}, false},
+		{"criteria combine", Selection{Patterns: []string{"*.example.com*"}, OnlyErrors: true}, []string{"https://rust.example.com/feed"}, false},
+		{"nothing failing in tag", Selection{Tags: []string{"Go"}, OnlyErrors: true}, nil, false},
+		{"resume", Selection{Resume: true}, []string{"https://news.example.org/feed"}, false},
+		{"resume in tag", Selection{Tags: []string{"Rust"}, Resume: true}, nil, false},
+		{"unknown URL", Selection{Patterns: []string{"https://missing.example.com/feed"}}, nil, true},
+		{"match ignores case", Selection{Match: []string{"RUST", ".org"}}, []string{"https://rust.example.com/feed", "https://news.example.org/feed"}, false},
+		{"match nothing", Selection{Match: []string{"blogspot"}}, nil, false},
+		{"min errors", Selection{MinErrors: 1, Tags: []string{"rust"}}, []string{"https://rust.example.com/feed"}, false},
+		{"more errors than any", Selection{MinErrors: 2}, nil, false},
+		{"inactive", Selection{Inactive: true}, nil, false},
+	}
+	for _, tt := range tests {
+		got, err := tt.selection.Filter(ctx, repo, feeds)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: Filter() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		var urls []string
+		for _, f := range got {
+			urls = append(urls, f.URL)
+		}
+		if strings.Join(urls, " ") != strings.Join(tt.want, " ") {
+			t.Errorf("%s: Filter() = %v, want %v", tt.name, urls, tt.want)
+		}
+	}
+}
+
+func TestUpdateFetchQueue(t *testing.T) {
+	t.Parallel()
+	cfg := newConfig(t)
+	repo, err := OpenRepository(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+
+	ctx := context.Background()
+	var feeds []repository.Feed
+	for _, url := range []string{"https://a.example.com/feed", "https://b.example.com/feed", "https://c.example.com/feed"} {
+		id, err := repo.AddFeed(ctx, url, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		feeds = append(feeds, repository.Feed{ID: id, URL: url})
+	}
+	a, b, c := feeds[0].ID, feeds[1].ID, feeds[2].ID
+
+	steps := []struct {
+		name        string
+		feeds       []repository.Feed
+		done        []int64
+		full        bool
+		interrupted bool
+		wantLeft    int
+		wantQueue   []int64
+	}{
+		{"interrupted full run", feeds, []int64{a}, true, true, 2, []int64{b, c}},
+		{"selection keeps the rest queued", feeds[:1], []int64{a}, false, false, 0, []int64{b, c}},
+		{"interrupted resume", feeds[1:], nil, false, true, 2, []int64{b, c}},
+		{"resume finishes one", feeds[1:], []int64{b}, false, false, 1, []int64{c}},
+		{"full run clears", feeds, []int64{a, b, c}, true, false, 0, nil},
+	}
+	for _, s := range steps {
+		done := make(map[int64]bool)
+		for _, id := range s.done {
+			done[id] = true
+		}
+		left, err := updateFetchQueue(ctx, repo, s.feeds, done, s.full, s.interrupted)
+		if err != nil {
+			t.Fatalf("%s: updateFetchQueue() error = %v", s.name, err)
+		}
+		queue, err := repo.GetFetchQueue(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if left != s.wantLeft || !slices.Equal(queue, s.wantQueue) {
+			t.Errorf("%s: left %d, queue %v; want %d, %v", s.name, left, queue, s.wantLeft, s.wantQueue)
+		}
+	}
+}
+
+func TestFetchMetrics(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name   string
+		result fetcher.FetchResult
+		want   metrics.Outcome
+	}{
+		{"stored", fetcher.FetchResult{StoredEntries: 2}, metrics.Stored},
+		{"not modified", fetcher.FetchResult{NotModified: true}, metrics.NotModified},
+		{"failed", fetcher.FetchResult{Error: errors.New("boom")}, metrics.Failed},
+		{"skipped", fetcher.FetchResult{Skipped: true}, metrics.Skipped},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := fetchMetrics(tt.result).Outcome; got != tt.want {
+				t.Errorf("fetchMetrics().Outcome = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	m := fetchMetrics(fetcher.FetchResult{StoredEntries: 3, WireBytes: 512, FetchDuration: time.Second})
+	if m.Entries != 3 || m.Bytes != 512 || m.Duration != time.Second {
+		t.Errorf("fetchMetrics() = %+v", m)
+	}
+}
@@ -0,0 +1,895 @@
+package planet
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"html/template"
+	"maps"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/adewale/rogue_planet/pkg/classify"
+	"github.com/adewale/rogue_planet/pkg/config"
+	"github.com/adewale/rogue_planet/pkg/faces"
+	"github.com/adewale/rogue_planet/pkg/favicon"
+	"github.com/adewale/rogue_planet/pkg/filter"
+	"github.com/adewale/rogue_planet/pkg/generator"
+	"github.com/adewale/rogue_planet/pkg/media"
+	"github.com/adewale/rogue_planet/pkg/publish"
+	"github.com/adewale/rogue_planet/pkg/repository"
+	"github.com/adewale/rogue_planet/pkg/translate"
+)
+
+// GenerateOptions adjust a Generate; the zero value generates the whole
+// site and publishes it
+type GenerateOptions struct {
+	Tags      []string // Only include entries with one of these categories (case-insensitive)
+	NoPublish bool     // Skip the [publish] hooks
+}
+
+// Generate generates the planet's site from the stored entries, into a
+// staging directory swapped into the output directory once it is complete,
+// and runs the [publish] hooks, within generate_stage_timeout_minutes
+func (p *Planet) Generate(ctx context.Context, opts GenerateOptions) error {
+	return withStageTimeout(ctx, "generate", time.Duration(p.cfg.Planet.GenerateStageTimeoutMinutes)*time.Minute, func(ctx context.Context) error {
+		return p.generate(ctx, opts)
+	})
+}
+
+// generate generates and publishes the site until ctx is done
+func (p *Planet) generate(ctx context.Context, opts GenerateOptions) error {
+	cfg, repo := p.cfg, p.repo
+	if len(opts.Tags) > 0 {
+		fmt.Fprintf(p.out, "Generating site for tags: %s...\n", strings.Join(opts.Tags, ", "))
+	} else {
+		fmt.Fprintln(p.out, "Generating site...")
+	}
+	commandHooks := newHooks(cfg)
+	if err := commandHooks.BeforeGenerate(ctx, cfg.Planet.Name, cfg.Planet.OutputDir); err != nil {
+		return err
+	}
+
+	// Get recent entries
+	entries, err := repo.GetRecentEntriesWithOptions(ctx, cfg.Planet.Days, cfg.Planet.FilterByFirstSeen, cfg.Planet.SortBy)
+	if err != nil {
+		return fmt.Errorf("get entries: %w", err)
+	}
+
+	// With resurface_updated, entries edited within the window rejoin the
+	// river at the time of the edit
+	var resurfaced map[int64]bool
+	if cfg.Planet.ResurfaceUpdated {
+		updated, err := repo.GetUpdatedEntries(ctx, time.Now().AddDate(0, 0, -cfg.Planet.Days))
+		if err != nil {
+			return fmt.Errorf("get updated entries: %w", err)
+		}
+		entries, resurfaced = resurface(entries, updated, cfg.Planet.SortBy)
+	}
+
+	// Get feeds for metadata
+	feeds, err := repo.GetFeeds(ctx, true)
+	if err != nil {
+		return fmt.Errorf("get feeds: %w", err)
+	}
+
+	feedMap := make(map[int64]*repository.Feed)
+	for i := range feeds {
+		feedMap[feeds[i].ID] = &feeds[i]
+	}
+
+	// Filters normally run before storage; with filter_stage = generate they
+	// hide stored entries instead, so rule changes apply to existing entries
+	var filters *filter.Set
+	if cfg.Planet.FilterStage == "generate" {
+		if filters, err = newFilterSet(cfg); err != nil {
+			return fmt.Errorf("compile filters: %w", err)
+		}
+	}
+
+	// Convert to generator format. icons maps feed links to their cached
+	// favicons once addFavicons has run, and faceImages maps authors to
+	// their faces once publishFaces has. shown is non-nil for the river's
+	// entries: it counts the entries kept from each feed, which stops at the
+	// feed's max_entries_per_feed, and resurfaced entries are marked.
+	icons := make(map[string]string)
+	faceImages := make(map[string]string)
+	authors := cfg.AuthorMap()
+	translator := newTranslator(cfg, repo)
+	classifier, err := newClassifier(cfg, repo)
+	if err != nil {
+		return err
+	}
+	convert := func(entries []repository.Entry, shown map[int64]int) []generator.EntryData {
+		genEntries := make([]generator.EntryData, 0, len(entries))
+		rowIDs := make([]int64, 0, len(entries))
+		var translatable []int
+		// Topics are worked out first so tag filtering sees them. Like a
+		// failed translation, a failed classification leaves the rest of
+		// the run untagged.
+		var topics [][]string
+		if classifier != nil && len(entries) > 0 {
+			var err error
+			if topics, err = classifyEntries(ctx, classifier, entries); err != nil {
+				fmt.Fprintf(p.out, "  Warning: classification failed, entries left without topics: %v\n", err)
+				classifier = nil
+			}
+		}
+		for i, entry := range entries {
+			feed := feedMap[entry.FeedID]
+			if feed == nil {
+				continue
+			}
+			categories, content := entry.Categories, entry.Content
+			var entryTopics []string
+			if topics != nil {
+				entryTopics = topics[i]
+				categories = withTopics(categories, entryTopics)
+				if cfg.Classify.Highlight && len(entryTopics) > 0 {
+					content = classifier.Highlight(content)
+				}
+			}
+			if !hasAnyTag(categories, opts.Tags) {
+				continue
+			}
+			if keep, _ := filters.Match(feed.URL, filter.Item{
+				Title:      entry.Title,
+				Summary:    entry.Summary,
+				Content:    entry.Content,
+				Author:     entry.Author,
+				Categories: categories,
+			}); !keep {
+				continue
+			}
+			if shown != nil {
+				if limit := maxEntriesForFeed(cfg, feed.URL); limit > 0 && shown[feed.ID] >= limit {
+					continue
+				}
+				shown[feed.ID]++
+			}
+
+			// SAFETY: Content was sanitized by normalizer.Parse() before storage.
+			// See pkg/normalizer/normalizer.go:56-69 for HTML sanitization using bluemonday.
+			// Title, Content, and Summary are safe for template.HTML after sanitization:
+			// - XSS vectors removed (script tags, event handlers, javascript: URLs)
+			// - Only http/https schemes allowed in links
+			// - Dangerous tags stripped (object, embed, iframe, base)
+			fc := cfg.FeedSettings[feed.URL]
+			if !fc.NoTranslate {
+				translatable = append(translatable, len(genEntries))
+			}
+			rowIDs = append(rowIDs, entry.ID)
+			genEntries = append(genEntries, generator.EntryData{
+				ID:         entry.EntryID,
+				Title:      template.HTML(entry.Title),
+				Link:       entry.Link,
+				Author:     authors.Canonical(entry.Author),
+				FeedTitle:  feed.Title,
+				FeedLink:   feed.Link,
+				Published:  entry.Published,
+				Updated:    entry.Updated,
+				Content:    template.HTML(content),
+				Summary:    template.HTML(entry.Summary),
+				Categories: categories,
+				Topics:     entryTopics,
+				FeedIcon:   icons[feed.Link],
+				Group:      fc.Group,
+
+				WordCount:             entry.WordCount,
+				ReadingMinutes:        entry.ReadingMinutes,
+				Image:                 entry.Image,
+				Authors:               canonicalAuthors(authors, entry.Authors),
+				ExternalURL:           entry.ExternalURL,
+				Language:              entry.Language,
+				Attachments:           generatorAttachments(entry.Attachments),
+				UpdatedCount:          entry.UpdatedCount,
+				LastSignificantUpdate: entry.LastSignificantUpdate,
+				Resurfaced:            shown != nil && resurfaced[entry.ID],
+
+				FeedCSSClass:    fc.CSSClass,
+				FeedAccentColor: fc.AccentColor,
+				FeedAvatar:      fc.Avatar,
+
+				Face: faceImages[authors.Canonical(entry.Author)],
+			})
+			// Archive pages keep every entry, so links to them last
+			if cfg.Planet.Archives {
+				e := &genEntries[len(genEntries)-1]
+				e.Permalink = generator.ArchivePermalink(*e)
+			}
+		}
+		// A failed translation leaves the rest of the run untranslated
+		// rather than waiting on the translator again for each archive page
+		if translator != nil && len(translatable) > 0 {
+			if err := translateEntries(ctx, translator, cfg.Translate.Fields, genEntries, translatable); err != nil {
+				fmt.Fprintf(p.out, "  Warning: translation failed, entries left untranslated: %v\n", err)
+				translator = nil
+			}
+		}
+		if cfg.Related.Enabled && len(rowIDs) > 0 {
+			if err := addRelatedEntries(ctx, repo, genEntries, rowIDs); err != nil {
+				fmt.Fprintf(p.out, "  Warning: entries shown without related posts: %v\n", err)
+			}
+		}
+		return genEntries
+	}
+	genEntries := convert(entries, make(map[int64]int))
+
+	pinned, err := repo.GetFeaturedEntries(ctx)
+	if err != nil {
+		return fmt.Errorf("get featured entries: %w", err)
+	}
+
+	gen, err := NewGenerator(cfg)
+	if err != nil {
+		return err
+	}
+
+	// Render into a staging directory and swap it into place at the end, so
+	// a failed or interrupted run never leaves a half-written site behind
+	stage, err := generator.NewStage(ctx, cfg.Planet.OutputDir)
+	if err != nil {
+		return fmt.Errorf("stage output: %w", err)
+	}
+	defer stage.Abort()
+
+	// Convert feeds for sidebar
+	genFeeds := make([]generator.FeedData, 0, len(feeds))
+	for _, feed := range feeds {
+		fc := cfg.FeedSettings[feed.URL]
+		genFeeds = append(genFeeds, generator.FeedData{
+			Title:       feed.Title,
+			Link:        feed.Link,
+			URL:         feed.URL,
+			LastUpdated: feed.LastFetched,
+			ErrorCount:  feed.FetchErrorCount,
+			CSSClass:    fc.CSSClass,
+			AccentColor: fc.AccentColor,
+			Avatar:      fc.Avatar,
+		})
+	}
+
+	// Generate HTML
+	data := generator.TemplateData{
+		Title:       cfg.Planet.Name,
+		Link:        cfg.Planet.Link,
+		OwnerName:   cfg.Planet.OwnerName,
+		OwnerEmail:  cfg.Planet.OwnerEmail,
+		Entries:     genEntries,
+		GroupByDate: cfg.Planet.GroupByDate,
+		Feeds:       genFeeds,
+		AtomURL:     generator.AtomFileName,
+		GroupTabs:   cfg.Planet.GroupLayout == "tabs",
+		Featured:    convert(pinned, nil),
+
+		AccentColor:     cfg.Planet.AccentColor,
+		AccentColorDark: cfg.Planet.AccentColorDark,
+	}
+	data.Image = planetImage(cfg, data)
+	data.Topics = entryTopics(cfg, genEntries)
+	for _, g := range cfg.Groups {
+		data.Groups = append(data.Groups, generator.EntryGroup{Name: g.Name, MaxEntries: g.MaxEntries})
+	}
+	if cfg.Planet.GenerateRSS {
+		data.RSSURL = generator.RSSFileName
+	}
+	if cfg.Planet.GenerateJSONFeed {
+		data.JSONFeedURL = generator.JSONFeedFileName
+	}
+
+	if cfg.Planet.Archives {
+		data.ArchiveURL = generator.ArchiveFileName
+	}
+
+	if cfg.Planet.Favicons {
+		p.addFavicons(ctx, stage.Dir(), genFeeds, genEntries)
+		for _, f := range genFeeds {
+			if f.Icon != "" {
+				icons[f.Link] = f.Icon
+			}
+		}
+	}
+	maps.Copy(faceImages, p.publishFaces(stage.Dir()))
+	if len(faceImages) > 0 {
+		for _, entries := range [][]generator.EntryData{genEntries, data.Featured} {
+			for i := range entries {
+				entries[i].Face = faceImages[entries[i].Author]
+			}
+		}
+	}
+	if cfg.Planet.CacheImages {
+		if err := p.cacheEntryImages(ctx, stage.Dir(), genEntries); err != nil {
+			return err
+		}
+	}
+
+	pages, err := gen.GeneratePages(ctx, stage.Dir(), data, cfg.Planet.EntriesPerPage)
+	if err != nil {
+		return fmt.Errorf("generate file: %w", err)
+	}
+
+	feedOpts := generator.FeedOptions{
+		MaxEntries: cfg.Planet.FeedEntries,
+		RSS:        cfg.Planet.GenerateRSS,
+		JSONFeed:   cfg.Planet.GenerateJSONFeed,
+	}
+	if err := gen.WriteFeeds(ctx, stage.Dir(), data, feedOpts); err != nil {
+		return fmt.Errorf("generate feeds: %w", err)
+	}
+
+	sitePages := generator.RiverPages(data, cfg.Planet.EntriesPerPage)
+	if cfg.Planet.Archives {
+		archivePages, err := p.generateArchive(ctx, gen, stage.Dir(), data, convert)
+		if err != nil {
+			return fmt.Errorf("generate archive: %w", err)
+		}
+		sitePages = append(sitePages, archivePages...)
+	} else if err := generator.RemoveArchive(stage.Dir()); err != nil {
+		return err
+	}
+
+	siteOpts := generator.SiteFileOptions{
+		Sitemap:    cfg.Planet.GenerateSitemap,
+		LLMsTxt:    cfg.Planet.GenerateLLMsTxt,
+		Pages:      sitePages,
+		MaxEntries: cfg.Planet.FeedEntries,
+	}
+	if err := gen.WriteSiteFiles(ctx, stage.Dir(), data, siteOpts); err != nil {
+		return fmt.Errorf("generate site files: %w", err)
+	}
+
+	if cfg.Planet.Minify {
+		if err := gen.Minify(ctx, stage.Dir(), sitePages); err != nil {
+			return fmt.Errorf("minify output: %w", err)
+		}
+	}
+
+	publishing := cfg.Publish.Enabled() && !opts.NoPublish
+	var fingerprint string
+	if publishing {
+		if fingerprint, err = siteFingerprint(cfg, data, stage.Dir()); err != nil {
+			return err
+		}
+	}
+
+	if err := stage.Commit(); err != nil {
+		return fmt.Errorf("publish site: %w", err)
+	}
+
+	outputPath := filepath.Join(cfg.Planet.OutputDir, generator.IndexFileName)
+	if pages > 1 {
+		fmt.Fprintf(p.out, "  Generated %s with %d entries across %d pages\n", outputPath, len(genEntries), pages)
+	} else {
+		fmt.Fprintf(p.out, "  Generated %s with %d entries\n", outputPath, len(genEntries))
+	}
+	if err := commandHooks.AfterGenerate(ctx, cfg.Planet.Name, cfg.Planet.OutputDir); err != nil {
+		return err
+	}
+
+	if publishing {
+		return p.publishSite(ctx, data, fingerprint)
+	}
+	return nil
+}
+
+// publishSite runs the [publish] hooks on the output directory, unless the
+// site has not changed since they last succeeded
+func (p *Planet) publishSite(ctx context.Context, data generator.TemplateData, fingerprint string) error {
+	cfg := p.cfg
+	outputDir := cfg.Planet.OutputDir
+	if !cfg.Publish.Always && fingerprint == publish.LastPublished(outputDir) {
+		fmt.Fprintln(p.out, "  Site unchanged since it was last published; not publishing")
+		return nil
+	}
+
+	pub := cfg.Publish
+	var hooks []publish.Hook
+	if pub.Command != "" {
+		hooks = append(hooks, publish.Command(pub.Command))
+	}
+	if pub.Rsync != "" {
+		hooks = append(hooks, publish.Rsync(outputDir, pub.Rsync))
+	}
+	if pub.S3 != "" {
+		hooks = append(hooks, publish.S3(outputDir, pub.S3))
+	}
+	if pub.CloudflarePages != "" {
+		hooks = append(hooks, publish.CloudflarePages(outputDir, pub.CloudflarePages))
+	}
+	if pub.Git != "" {
+		entries := make([]publish.Entry, 0, len(data.Entries))
+		for _, e := range data.Entries {
+			entries = append(entries, publish.Entry{Title: html.UnescapeString(string(e.Title)), Feed: e.FeedTitle, Published: e.Published})
+		}
+		hooks = append(hooks, publish.Git(publish.GitOptions{
+			Remote:  pub.Git,
+			Branch:  pub.GitBranch,
+			Name:    cfg.Planet.OwnerName,
+			Email:   cfg.Planet.OwnerEmail,
+			Title:   cfg.Planet.Name,
+			Entries: entries,
+		}))
+	}
+
+	timeout := time.Duration(pub.TimeoutSeconds) * time.Second
+	results, err := publish.Run(ctx, outputDir, timeout, hooks)
+	for _, r := range results {
+		fmt.Fprintf(p.out, "  Published with %s in %s\n", r.Hook.Name, r.Duration.Round(time.Millisecond))
+	}
+	if err != nil {
+		// The fingerprint is not recorded, so the next run tries again
+		return fmt.Errorf("run publish hooks: %w", err)
+	}
+	return publish.RecordPublished(outputDir, fingerprint)
+}
+
+// siteFingerprint identifies the content of a generated site: its entries
+// and feeds, the theme, and the generated files that do not embed the time
+// of generation (static assets, cached images and favicons, robots.txt, ...)
+func siteFingerprint(cfg *config.Config, data generator.TemplateData, dir string) (string, error) {
+	type entry struct {
+		ID, Link, Author, Feed  string
+		Title, Content, Summary string
+		Group, Image            string
+		ExternalURL, Language   string
+		Published, Updated      time.Time
+		Categories, Authors     []string
+		Attachments             []generator.Attachment
+	}
+	type feed struct {
+		Title, Link, URL, Icon string
+	}
+	entries := make([]entry, 0, len(data.Entries)+len(data.Featured))
+	for _, e := range slices.Concat(data.Featured, data.Entries) {
+		entries = append(entries, entry{e.ID, e.Link, e.Author, e.FeedTitle, string(e.Title), string(e.Content), string(e.Summary), e.Group, e.Image, e.ExternalURL, e.Language, e.Published, e.Updated, e.Categories, e.Authors, e.Attachments})
+	}
+	feeds := make([]feed, 0, len(data.Feeds))
+	for _, f := range data.Feeds {
+		feeds = append(feeds, feed{f.Title, f.Link, f.URL, f.Icon})
+	}
+
+	fp := publish.NewFingerprint()
+	if err := fp.Add(cfg.Planet); err != nil {
+		return "", err
+	}
+	if err := fp.Add(cfg.Groups); err != nil {
+		return "", err
+	}
+	if err := fp.Add(entries); err != nil {
+		return "", err
+	}
+	if err := fp.Add(feeds); err != nil {
+		return "", err
+	}
+	if cfg.Planet.Template != "" {
+		if err := fp.AddFiles(cfg.Planet.Template, nil); err != nil {
+			return "", err
+		}
+	}
+	if err := fp.AddFiles(dir, timestampedFile); err != nil {
+		return "", err
+	}
+	return fp.Sum(), nil
+}
+
+// timestampedFile reports whether a generated file, given by its path
+// relative to the output directory, records when it was generated
+func timestampedFile(rel string) bool {
+	switch rel {
+	case generator.AtomFileName, generator.RSSFileName, generator.SitemapFileName, generator.LLMsFileName, "build-info.json":
+		return true
+	}
+	return strings.HasSuffix(rel, ".html") || (strings.HasPrefix(rel, "feed") && strings.HasSuffix(rel, ".json"))
+}
+
+// generateArchive writes the monthly archive of every stored entry into
+// outputDir, converting each month's entries with convert
+func (p *Planet) generateArchive(ctx context.Context, gen *generator.Generator, outputDir string, data generator.TemplateData, convert func([]repository.Entry, map[int64]int) []generator.EntryData) ([]generator.SitePage, error) {
+	stored, err := p.repo.GetArchiveMonths(ctx)
+	if err != nil {
+		return nil, err
+	}
+	months := make([]generator.ArchiveMonth, 0, len(stored))
+	for _, m := range stored {
+		months = append(months, generator.ArchiveMonth{Year: m.Year, Month: m.Month, Entries: m.Entries})
+	}
+
+	pages, err := gen.GenerateArchive(ctx, outputDir, data, months, func(m generator.ArchiveMonth) ([]generator.EntryData, error) {
+		entries, err := p.repo.GetMonthEntries(ctx, m.Year, m.Month)
+		if err != nil {
+			return nil, err
+		}
+		return convert(entries, nil), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(p.out, "  Archived %d months\n", len(months))
+	return pages, nil
+}
+
+// newGenerator creates a generator for the configured template, or the
+// built-in one
+func newGenerator(cfg *config.Config) (*generator.Generator, error) {
+	if cfg.Planet.Template != "" {
+		gen, err := generator.NewWithTemplate(cfg.Planet.Template)
+		if err != nil {
+			return nil, fmt.Errorf("create generator with template: %w", err)
+		}
+		return gen, nil
+	}
+	gen, err := generator.New()
+	if err != nil {
+		return nil, fmt.Errorf("create generator: %w", err)
+	}
+	return gen, nil
+}
+
+// addFavicons downloads (or reuses) the favicon of each feed's site into the
+// output's favicon cache and points the feeds and their entries at it
+func (p *Planet) addFavicons(ctx context.Context, outputDir string, feeds []generator.FeedData, entries []generator.EntryData) {
+	links := make([]string, 0, len(feeds))
+	for _, f := range feeds {
+		if f.Link != "" {
+			links = append(links, f.Link)
+		}
+	}
+
+	cacheDir := filepath.Join(outputDir, filepath.FromSlash(generator.FaviconsDir))
+	cache := favicon.New(NewCrawler(p.cfg), cacheDir)
+	if p.cfg.Planet.LowMemory {
+		cache.SetWorkers(1)
+	}
+	icons := cache.Icons(ctx, links)
+
+	for i := range feeds {
+		if name, ok := icons[feeds[i].Link]; ok {
+			feeds[i].Icon = path.Join(generator.FaviconsDir, name)
+		}
+	}
+	for i := range entries {
+		if name, ok := icons[entries[i].FeedLink]; ok {
+			entries[i].FeedIcon = path.Join(generator.FaviconsDir, name)
+		}
+	}
+	fmt.Fprintf(p.out, "  Favicons cached for %d of %d sites\n", len(icons), len(links))
+}
+
+// publishFaces scales the face images named in [author] sections into the
+// output and returns their paths there by author. Faces that cannot be
+// published are warned about and left out.
+func (p *Planet) publishFaces(outputDir string) map[string]string {
+	dstDir := filepath.Join(outputDir, filepath.FromSlash(generator.FacesDir))
+	authorFaces := p.cfg.AuthorFaces()
+	if len(authorFaces) == 0 {
+		// Drop the faces an earlier run published
+		if err := os.RemoveAll(dstDir); err != nil {
+			fmt.Fprintf(p.out, "  Warning: remove old author faces: %v\n", err)
+		}
+		return nil
+	}
+
+	names := slices.Sorted(maps.Values(authorFaces))
+	published, err := faces.Publish(p.cfg.Planet.FacesDir, dstDir, names, p.cfg.Planet.FaceSize)
+	if err != nil {
+		for _, line := range strings.Split(err.Error(), "\n") {
+			fmt.Fprintf(p.out, "  Warning: %s\n", line)
+		}
+	}
+
+	paths := make(map[string]string, len(authorFaces))
+	for author, name := range authorFaces {
+		if file, ok := published[name]; ok {
+			paths[author] = path.Join(generator.FacesDir, file)
+		}
+	}
+	fmt.Fprintf(p.out, "  Faces published for %d of %d authors\n", len(paths), len(authorFaces))
+	return paths
+}
+
+// cacheEntryImages downloads the images in entry content into the output's
+// media cache, rewrites the entries to use the local copies, and prunes
+// cached images no entry uses any more
+func (p *Planet) cacheEntryImages(ctx context.Context, outputDir string, entries []generator.EntryData) error {
+	var urls []string
+	for _, e := range entries {
+		urls = append(urls, media.ImageURLs(string(e.Content), e.Link)...)
+		urls = append(urls, media.ImageURLs(string(e.Summary), e.Link)...)
+	}
+
+	cacheDir := filepath.Join(outputDir, generator.MediaDir)
+	cache := media.New(NewCrawler(p.cfg), cacheDir, int64(p.cfg.Planet.MaxImageSizeKB)*1024)
+	if p.cfg.Planet.LowMemory {
+		cache.SetWorkers(1)
+	}
+	results := cache.Fetch(ctx, urls)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	keep := make(map[string]bool)
+	pixels := 0
+	for _, r := range results {
+		if r.Pixel {
+			pixels++
+		} else {
+			keep[r.Name] = true
+		}
+	}
+	for i := range entries {
+		e := &entries[i]
+		// SAFETY: Rewrite only replaces img src attributes (escaped) with
+		// local paths, or drops img tags, in already-sanitized HTML
+		e.Content = template.HTML(media.Rewrite(string(e.Content), e.Link, results, generator.MediaDir))
+		e.Summary = template.HTML(media.Rewrite(string(e.Summary), e.Link, results, generator.MediaDir))
+	}
+
+	pruned, err := cache.Prune(keep)
+	if err != nil {
+		return fmt.Errorf("prune image cache: %w", err)
+	}
+	fmt.Fprintf(p.out, "  Cached %d images (%d tracking pixels removed, %d stale images pruned)\n", len(keep), pixels, pruned)
+	return nil
+}
+
+// hasAnyTag reports whether categories contains one of tags, ignoring case.
+// An empty tag list matches everything.
+func hasAnyTag(categories, tags []string) bool {
+	if len(tags) == 0 {
+		return true
+	}
+	for _, c := range categories {
+		for _, t := range tags {
+			if strings.EqualFold(c, t) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// maxEntriesForFeed returns the most entries the feed may contribute to the
+// generated site: its own max_entries, else the planet's
+// max_entries_per_feed. Zero means no limit.
+func maxEntriesForFeed(cfg *config.Config, feedURL string) int {
+	if n := cfg.FeedSettings[feedURL].MaxEntries; n > 0 {
+		return n
+	}
+	return cfg.Planet.MaxEntriesPerFeed
+}
+
+// resurface merges recently updated entries into the river's entries, each
+// placed by the later of its sort time and its last significant update. It
+// returns the merged entries and the IDs of those moved up by an update.
+func resurface(entries, updated []repository.Entry, sortBy string) ([]repository.Entry, map[int64]bool) {
+	sortTime := func(e repository.Entry) time.Time {
+		if sortBy == "first_seen" {
+			return e.FirstSeen
+		}
+		return e.Published
+	}
+
+	moved := make(map[int64]bool)
+	for _, e := range updated {
+		if e.LastSignificantUpdate.After(sortTime(e)) {
+			moved[e.ID] = true
+		}
+	}
+	merged := make([]repository.Entry, 0, len(entries)+len(moved))
+	for _, e := range entries {
+		if !moved[e.ID] {
+			merged = append(merged, e)
+		}
+	}
+	for _, e := range updated {
+		if moved[e.ID] {
+			merged = append(merged, e)
+		}
+	}
+
+	riverTime := func(e repository.Entry) time.Time {
+		if moved[e.ID] {
+			return e.LastSignificantUpdate
+		}
+		return sortTime(e)
+	}
+	// Stable, so entries that were not moved keep the query's tie-breaking
+	slices.SortStableFunc(merged, func(a, b repository.Entry) int {
+		return riverTime(b).Compare(riverTime(a))
+	})
+	return merged, moved
+}
+
+// newTranslator returns the translator configured by the [translate]
+// section, caching its translations in repo, or nil when translation is off
+func newTranslator(cfg *config.Config, repo *repository.Repository) *translate.Cache {
+	t := cfg.Translate
+	if !t.Enabled() {
+		return nil
+	}
+	var translator translate.Translator = translate.Command{Command: t.Command}
+	if t.URL != "" {
+		translator = translate.HTTP{URL: t.URL}
+	}
+	return &translate.Cache{
+		Translator: translator,
+		Store:      repo,
+		Target:     t.TargetLanguage,
+		Timeout:    time.Duration(t.TimeoutSeconds) * time.Second,
+	}
+}
+
+// newClassifier returns the classifier configured by the [classify] and
+// [topic <name>] sections, caching the topics from its classification
+// service in repo, or nil when entries are not classified
+func newClassifier(cfg *config.Config, repo *repository.Repository) (*classify.Classifier, error) {
+	if !cfg.Classifies() {
+		return nil, nil
+	}
+	topics := make([]classify.Topic, len(cfg.Topics))
+	for i, t := range cfg.Topics {
+		topics[i] = classify.Topic{Name: t.Name, Keywords: t.Keywords}
+	}
+	c, err := classify.New(topics)
+	if err != nil {
+		return nil, fmt.Errorf("compile topics: %w", err)
+	}
+	if cfg.Classify.URL != "" {
+		c.Model = classify.HTTP{URL: cfg.Classify.URL}
+		c.Store = repo
+		c.Timeout = time.Duration(cfg.Classify.TimeoutSeconds) * time.Second
+	}
+	return c, nil
+}
+
+// classifyEntries returns the topics of each entry, from the plain text
+// of its title, summary, and content
+func classifyEntries(ctx context.Context, c *classify.Classifier, entries []repository.Entry) ([][]string, error) {
+	texts := make([]string, len(entries))
+	for i, e := range entries {
+		texts[i] = translate.PlainText(e.Title + "\n" + e.Summary + "\n" + e.Content)
+	}
+	return c.Classify(ctx, texts)
+}
+
+// withTopics returns categories with the topics it lacks added, compared
+// case-insensitively
+func withTopics(categories, topics []string) []string {
+	out := slices.Clip(categories)
+	for _, t := range topics {
+		if !slices.ContainsFunc(out, func(c string) bool { return strings.EqualFold(c, t) }) {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// entryTopics returns the topics the entries are tagged with: configured
+// topics in the order the config names them, then any others in the order
+// they first appear
+func entryTopics(cfg *config.Config, entries []generator.EntryData) []string {
+	seen := make(map[string]bool)
+	var found []string
+	for _, e := range entries {
+		for _, t := range e.Topics {
+			if !seen[t] {
+				seen[t] = true
+				found = append(found, t)
+			}
+		}
+	}
+	var topics []string
+	for _, t := range cfg.Topics {
+		if seen[t.Name] {
+			topics = append(topics, t.Name)
+			delete(seen, t.Name)
+		}
+	}
+	for _, t := range found {
+		if seen[t] {
+			topics = append(topics, t)
+		}
+	}
+	return topics
+}
+
+// addRelatedEntries sets the related entries of each entry, whose row ID
+// is at the same index in rowIDs
+func addRelatedEntries(ctx context.Context, repo *repository.Repository, entries []generator.EntryData, rowIDs []int64) error {
+	related, err := repo.GetRelatedEntries(ctx, rowIDs)
+	if err != nil {
+		return err
+	}
+	for i, id := range rowIDs {
+		for _, r := range related[id] {
+			// SAFETY: titles were sanitized before storage, like the entry's own
+			entries[i].Related = append(entries[i].Related, generator.RelatedEntry{Title: template.HTML(r.Title), Link: r.Link})
+		}
+	}
+	return nil
+}
+
+// translateEntries translates the given fields of the entries at indices
+// in place. A translated field keeps its source text in OriginalTitle or
+// OriginalSummary; fields the translator returns unchanged are left alone.
+func translateEntries(ctx context.Context, translator *translate.Cache, fields []string, entries []generator.EntryData, indices []int) error {
+	type target struct {
+		text     *template.HTML
+		original *template.HTML
+	}
+	var targets []target
+	var texts []string
+	for _, i := range indices {
+		e := &entries[i]
+		for _, field := range fields {
+			switch field {
+			case "title":
+				targets = append(targets, target{&e.Title, &e.OriginalTitle})
+				texts = append(texts, translate.PlainText(string(e.Title)))
+			case "summary":
+				targets = append(targets, target{&e.Summary, &e.OriginalSummary})
+				texts = append(texts, translate.PlainText(string(e.Summary)))
+			}
+		}
+	}
+
+	translated, err := translator.Translate(ctx, texts)
+	if err != nil {
+		return err
+	}
+	for i, t := range targets {
+		if translated[i] == texts[i] {
+			continue
+		}
+		*t.original = *t.text
+		// Translations are plain text, so escaping is all they need
+		*t.text = template.HTML(html.EscapeString(translated[i]))
+	}
+	return nil
+}
+
+// canonicalAuthors returns the names an entry's authors are shown with,
+// without repeats, or nil if there are none
+func canonicalAuthors(authors config.AuthorMap, names []string) []string {
+	var shown []string
+	for _, name := range names {
+		if name = authors.Canonical(name); !slices.Contains(shown, name) {
+			shown = append(shown, name)
+		}
+	}
+	return shown
+}
+
+// generatorAttachments converts stored attachments for templates
+func generatorAttachments(attachments []repository.Attachment) []generator.Attachment {
+	var converted []generator.Attachment
+	for _, a := range attachments {
+		converted = append(converted, generator.Attachment(a))
+	}
+	return converted
+}
+
+// planetImage returns the image for previews of the planet when it is
+// shared: the configured image, made absolute against the planet's link,
+// else the first image among the featured entries and then the river
+func planetImage(cfg *config.Config, data generator.TemplateData) string {
+	if img := cfg.Planet.Image; img != "" {
+		if base, err := url.Parse(cfg.Planet.Link); err == nil && cfg.Planet.Link != "" {
+			if u, err := base.Parse(img); err == nil {
+				return u.String()
+			}
+		}
+		return img
+	}
+	for _, e := range slices.Concat(data.Featured, data.Entries) {
+		if e.Image != "" {
+			return e.Image
+		}
+	}
+	return ""
+}
@@ -0,0 +1,412 @@
+package planet
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adewale/rogue_planet/pkg/config"
+	"github.com/adewale/rogue_planet/pkg/generator"
+	"github.com/adewale/rogue_planet/pkg/publish"
+	"github.com/adewale/rogue_planet/pkg/repository"
+)
+
+// generate opens the planet cfg describes and generates its site
+func generate(ctx context.Context, cfg *config.Config, opts GenerateOptions) error {
+	p, err := Open(cfg, Options{})
+	if err != nil {
+		return err
+	}
+	defer p.Close()
+	return p.Generate(ctx, opts)
+}
+
+func TestGenerateWritesFeeds(t *testing.T) {
+	t.Parallel()
+	cfg := newConfig(t)
+	outputDir := cfg.Planet.OutputDir
+	cfg.Planet.GenerateRSS = true
+	cfg.Planet.GenerateJSONFeed = true
+
+	if err := generate(context.Background(), cfg, GenerateOptions{}); err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+
+	for _, name := range []string{"index.html", "atom.xml", "rss.xml", "feed.json"} {
+		if _, err := os.Stat(filepath.Join(outputDir, name)); err != nil {
+			t.Errorf("%s not generated: %v", name, err)
+		}
+	}
+
+	index, _ := os.ReadFile(filepath.Join(outputDir, "index.html"))
+	if !strings.Contains(string(index), `href="atom.xml"`) {
+		t.Error("index.html should link to atom.xml")
+	}
+	if !strings.Contains(string(index), `type="application/feed+json"`) {
+		t.Error("index.html should link to feed.json")
+	}
+}
+
+func TestGenerateFiltersAtGenerateStage(t *testing.T) {
+	t.Parallel()
+	cfg := newConfig(t)
+	outputDir := cfg.Planet.OutputDir
+	repo, err := OpenRepository(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	id, err := repo.AddFeed(ctx, "https://feed.invalid/atom.xml", "Blog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	for i, title := range []string{"Weekly notes", "Sponsored: buy this"} {
+		if err := repo.UpsertEntry(ctx, &repository.Entry{
+			FeedID: id, EntryID: title, Title: title, Link: fmt.Sprintf("https://feed.invalid/%d", i),
+			Published: now, Updated: now, FirstSeen: now,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	repo.Close()
+
+	cfg.Planet.FilterStage = "generate"
+	cfg.Filters.ExcludeKeywords = []string{"sponsored"}
+	if err := generate(ctx, cfg, GenerateOptions{}); err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+
+	index, err := os.ReadFile(filepath.Join(outputDir, "index.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(index), "Weekly notes") {
+		t.Error("index.html should include the unfiltered entry")
+	}
+	if strings.Contains(string(index), "buy this") {
+		t.Error("index.html should not include the filtered entry")
+	}
+}
+
+func TestGenerateTopics(t *testing.T) {
+	t.Parallel()
+	cfg := newConfig(t)
+	outputDir := cfg.Planet.OutputDir
+	repo, err := OpenRepository(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	id, err := repo.AddFeed(ctx, "https://feed.invalid/atom.xml", "Blog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	for i, title := range []string{"Tuning SQLite", "Weekly notes"} {
+		if err := repo.UpsertEntry(ctx, &repository.Entry{
+			FeedID: id, EntryID: title, Title: title, Link: fmt.Sprintf("https://feed.invalid/%d", i),
+			Content: "<p>About sqlite.</p>", Published: now, Updated: now, FirstSeen: now,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	repo.Close()
+
+	cfg.Topics = []config.TopicConfig{{Name: "Databases", Keywords: []string{"sqlite"}}, {Name: "Unused", Keywords: []string{"cobol"}}}
+	cfg.Classify.Highlight = true
+	if err := generate(ctx, cfg, GenerateOptions{Tags: []string{"databases"}}); err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(outputDir, "index.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	index := string(data)
+	for _, want := range []string{
+		`<a id="topic-databases" href="#topic-databases">Databases</a>`,
+		`data-topics="topic-databases"`,
+		`<mark>sqlite</mark>`,
+		`<li>Databases</li>`,
+	} {
+		if !strings.Contains(index, want) {
+			t.Errorf("index.html missing %q", want)
+		}
+	}
+	if strings.Contains(index, "topic-unused") {
+		t.Error("index.html should only offer topics entries have")
+	}
+}
+
+func TestGenerateFaces(t *testing.T) {
+	t.Parallel()
+	cfg := newConfig(t)
+	outputDir := cfg.Planet.OutputDir
+	repo, err := OpenRepository(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	id, err := repo.AddFeed(ctx, "https://feed.invalid/atom.xml", "Blog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	for i, author := range []string{"jsmith", "Ada Lovelace"} {
+		if err := repo.UpsertEntry(ctx, &repository.Entry{
+			FeedID: id, EntryID: author, Title: "Post by " + author, Link: fmt.Sprintf("https://feed.invalid/%d", i),
+			Author: author, Content: "<p>Hello.</p>", Published: now, Updated: now, FirstSeen: now,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	repo.Close()
+
+	cfg.Planet.FacesDir = t.TempDir()
+	cfg.Planet.FaceSize = 32
+	f, err := os.Create(filepath.Join(cfg.Planet.FacesDir, "john.jpg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := jpeg.Encode(f, image.NewRGBA(image.Rect(0, 0, 100, 60)), nil); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	cfg.Authors = []config.AuthorConfig{
+		{Name: "John Smith", Aliases: []string{"jsmith"}, Face: "john.jpg"},
+		{Name: "Ada Lovelace", Face: "missing.png"},
+	}
+	if err := generate(ctx, cfg, GenerateOptions{}); err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(outputDir, "index.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	index := string(data)
+	if !strings.Contains(index, `<img class="entry-avatar entry-face" src="static/faces/john.png" alt="John Smith"`) {
+		t.Error("index.html should show John Smith's face")
+	}
+	if strings.Count(index, "entry-face") != 1 {
+		t.Error("index.html should show no face for an author whose image is missing")
+	}
+	face, err := os.Open(filepath.Join(outputDir, generator.FacesDir, "john.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer face.Close()
+	if img, err := png.DecodeConfig(face); err != nil || img.Width != 32 || img.Height != 32 {
+		t.Errorf("published face = %+v, %v; want a 32x32 PNG", img, err)
+	}
+
+	// Faces no [author] section names any more are removed
+	cfg.Authors = nil
+	if err := generate(ctx, cfg, GenerateOptions{}); err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, generator.FacesDir)); !os.IsNotExist(err) {
+		t.Errorf("faces directory should be removed, stat error = %v", err)
+	}
+}
+
+func TestGenerateArchives(t *testing.T) {
+	t.Parallel()
+	cfg := newConfig(t)
+	outputDir := cfg.Planet.OutputDir
+	repo, err := OpenRepository(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	id, err := repo.AddFeed(ctx, "https://feed.invalid/atom.xml", "Blog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Both entries are far older than the river's window
+	for _, published := range []time.Time{
+		time.Date(2020, 5, 4, 12, 0, 0, 0, time.UTC),
+		time.Date(2019, 11, 2, 12, 0, 0, 0, time.UTC),
+	} {
+		title := "Post from " + published.Format("January 2006")
+		if err := repo.UpsertEntry(ctx, &repository.Entry{
+			FeedID: id, EntryID: title, Title: title, Link: "https://feed.invalid/" + published.Format("2006-01"),
+			Published: published, Updated: published, FirstSeen: published,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	repo.Close()
+
+	cfg.Planet.Archives = true
+	if err := generate(ctx, cfg, GenerateOptions{}); err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+
+	month, err := os.ReadFile(filepath.Join(outputDir, "2020", "05", "index.html"))
+	if err != nil {
+		t.Fatalf("month page not generated: %v", err)
+	}
+	if !strings.Contains(string(month), "Post from May 2020") || strings.Contains(string(month), "November 2019") {
+		t.Error("2020/05/index.html should list only May 2020's entry")
+	}
+	anchor := generator.EntryAnchor(generator.EntryData{ID: "Post from May 2020"})
+	if !strings.Contains(string(month), `<article id="`+anchor+`"`) || !strings.Contains(string(month), `href="2020/05/#`+anchor+`"`) {
+		t.Errorf("2020/05/index.html should show the entry as %s and link to its permalink", anchor)
+	}
+	index, _ := os.ReadFile(filepath.Join(outputDir, "index.html"))
+	if !strings.Contains(string(index), `href="archive.html"`) {
+		t.Error("index.html should link to archive.html")
+	}
+
+	// Turning archives off removes them
+	cfg.Planet.Archives = false
+	if err := generate(ctx, cfg, GenerateOptions{}); err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	for _, name := range []string{"2020", "archive.html"} {
+		if _, err := os.Stat(filepath.Join(outputDir, name)); !os.IsNotExist(err) {
+			t.Errorf("%s kept after archives were turned off", name)
+		}
+	}
+}
+
+func TestGeneratePublish(t *testing.T) {
+	t.Parallel()
+	cfg := newConfig(t)
+	outputDir := cfg.Planet.OutputDir
+	ctx := context.Background()
+
+	// A failing hook fails the run but keeps the generated site
+	cfg.Publish.Command = "exit 7"
+	if err := generate(ctx, cfg, GenerateOptions{}); err == nil || !strings.Contains(err.Error(), "command: exit status 7") {
+		t.Fatalf("Generate() error = %v, want the hook's exit status", err)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "index.html")); err != nil {
+		t.Errorf("index.html not generated: %v", err)
+	}
+	if publish.LastPublished(outputDir) != "" {
+		t.Error("a failed publish was recorded")
+	}
+
+	cfg.Publish.Command = "exit 0"
+	if err := generate(ctx, cfg, GenerateOptions{}); err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	if publish.LastPublished(outputDir) == "" {
+		t.Error("a successful publish was not recorded")
+	}
+
+	// Nothing changed, so the hook does not run again
+	cfg.Publish.Command = "exit 7"
+	if err := generate(ctx, cfg, GenerateOptions{}); err != nil {
+		t.Errorf("Generate() of an unchanged site error = %v, want the hook skipped", err)
+	}
+	if err := generate(ctx, cfg, GenerateOptions{NoPublish: true}); err != nil {
+		t.Errorf("Generate() with noPublish error = %v", err)
+	}
+	cfg.Publish.Always = true
+	if err := generate(ctx, cfg, GenerateOptions{}); err == nil {
+		t.Error("Generate() with always = true skipped the hook")
+	}
+}
+
+func TestGenerateHooks(t *testing.T) {
+	t.Parallel()
+	cfg := newConfig(t)
+	outputDir := cfg.Planet.OutputDir
+	ctx := context.Background()
+	cfg.Hooks.TimeoutSeconds = 60
+
+	// A failing pre_generate hook stops the generation
+	cfg.Hooks.PreGenerate = []string{"echo not today >&2; exit 1"}
+	if err := generate(ctx, cfg, GenerateOptions{}); err == nil || !strings.Contains(err.Error(), "not today") {
+		t.Fatalf("Generate() error = %v, want the pre_generate hook's failure", err)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "index.html")); err == nil {
+		t.Error("index.html generated despite the pre_generate hook failing")
+	}
+
+	// post_generate hooks get the event once the site is in place, and a
+	// failure stops publishing
+	event := filepath.Join(t.TempDir(), "event.json")
+	cfg.Hooks.PreGenerate = nil
+	cfg.Hooks.PostGenerate = []string{"test -f '" + filepath.Join(outputDir, "index.html") + "' && cat > '" + event + "'", "exit 5"}
+	cfg.Publish.Command = "exit 0"
+	if err := generate(ctx, cfg, GenerateOptions{}); err == nil || !strings.Contains(err.Error(), "post_generate hook") {
+		t.Fatalf("Generate() error = %v, want the post_generate hook's failure", err)
+	}
+	data, err := os.ReadFile(event)
+	if err != nil {
+		t.Fatalf("post_generate hook did not run: %v", err)
+	}
+	if !strings.Contains(string(data), `"event":"post_generate"`) || !strings.Contains(string(data), `"output_dir":`) {
+		t.Errorf("post_generate event = %s", data)
+	}
+	if publish.LastPublished(outputDir) != "" {
+		t.Error("published despite the post_generate hook failing")
+	}
+}
+
+func TestPlanetImage(t *testing.T) {
+	t.Parallel()
+	data := generator.TemplateData{
+		Featured: []generator.EntryData{{Title: "Pinned"}},
+		Entries:  []generator.EntryData{{Title: "Plain"}, {Title: "Pictured", Image: "https://a.example.com/1.png"}, {Image: "https://b.example.com/2.png"}},
+	}
+	tests := []struct {
+		name, link, image, want string
+	}{
+		{"newest entry image", "https://planet.example.com/", "", "https://a.example.com/1.png"},
+		{"configured absolute", "https://planet.example.com/", "https://cdn.example.com/card.png", "https://cdn.example.com/card.png"},
+		{"configured relative", "https://planet.example.com/go/", "static/card.png", "https://planet.example.com/go/static/card.png"},
+		{"relative without link", "", "static/card.png", "static/card.png"},
+	}
+	for _, tt := range tests {
+		cfg := config.Default()
+		cfg.Planet.Link, cfg.Planet.Image = tt.link, tt.image
+		if got := planetImage(cfg, data); got != tt.want {
+			t.Errorf("%s: planetImage() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestResurface(t *testing.T) {
+	t.Parallel()
+	now := time.Now()
+	entry := func(id int64, published, edited time.Time) repository.Entry {
+		return repository.Entry{ID: id, Published: published, FirstSeen: published, LastSignificantUpdate: edited}
+	}
+	recent := entry(1, now.Add(-time.Hour), time.Time{})
+	editedRecent := entry(2, now.Add(-2*time.Hour), now.Add(-90*time.Minute)) // Moves up, but not past entry 1
+	older := entry(3, now.Add(-3*time.Hour), time.Time{})
+	editedOld := entry(4, now.AddDate(0, 0, -30), now.Add(-30*time.Minute)) // Outside the window, edited today
+	editedInWindow := entry(5, now.Add(-4*time.Hour), now.Add(-10*time.Minute))
+
+	entries := []repository.Entry{recent, editedRecent, older, editedInWindow}
+	updated := []repository.Entry{editedInWindow, editedOld, editedRecent}
+	got, moved := resurface(entries, updated, "published")
+
+	var ids []int64
+	for _, e := range got {
+		ids = append(ids, e.ID)
+	}
+	if want := []int64{5, 4, 1, 2, 3}; !slices.Equal(ids, want) {
+		t.Errorf("resurface() order = %v, want %v", ids, want)
+	}
+	if !moved[2] || !moved[4] || !moved[5] || len(moved) != 3 {
+		t.Errorf("resurface() moved = %v, want entries 2, 4, and 5", moved)
+	}
+	if _, moved := resurface(entries, updated, "first_seen"); len(moved) != 3 {
+		t.Errorf("resurface() by first_seen moved %d entries, want 3", len(moved))
+	}
+}
@@ -0,0 +1,90 @@
+package planet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/adewale/rogue_planet/pkg/repository"
+)
+
+// Runs that fetch feeds hold RunLockName in the database, so overlapping
+// runs (a slow cron job and the next one, or a cron job and rp serve) do not
+// fetch the same feeds twice. The lock is renewed while the run lasts, and
+// one left by a process that died expires after runLockTTL.
+const (
+	RunLockName  = "fetch"
+	runLockTTL   = 2 * time.Minute
+	runLockRenew = 30 * time.Second
+)
+
+// runLockPoll is how often a run waiting for the lock tries again
+const runLockPoll = time.Second
+
+// ErrRunInProgress is returned by Update and Fetch when another run holds
+// the run lock
+var ErrRunInProgress = errors.New("another rp run is in progress")
+
+// withRunLock runs fn while holding the run lock. If another process holds
+// it, withRunLock waits up to wait for it to be released, then fails with
+// ErrRunInProgress. Runs of this Planet take turns, since the lock is held
+// in the name of the process.
+func (p *Planet) withRunLock(ctx context.Context, wait time.Duration, fn func() error) error {
+	p.running.Lock()
+	defer p.running.Unlock()
+
+	hostname, _ := os.Hostname()
+	holder := fmt.Sprintf("%s pid %d", hostname, os.Getpid())
+	deadline := time.Now().Add(wait)
+	for {
+		err := p.repo.AcquireLock(ctx, RunLockName, holder, runLockTTL)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, repository.ErrLocked) {
+			return err
+		}
+		if !time.Now().Before(deadline) {
+			if lock, _ := p.repo.GetLock(ctx, RunLockName); lock != nil {
+				return fmt.Errorf("%w (%s, started %s)", ErrRunInProgress, lock.Holder, lock.AcquiredAt.Local().Format(time.DateTime))
+			}
+			return ErrRunInProgress
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(min(runLockPoll, time.Until(deadline))):
+		}
+	}
+
+	// Renew the lock until fn returns. The run goes on if renewing fails;
+	// the worst case is the overlap the lock is there to prevent.
+	done := make(chan struct{})
+	renewed := make(chan struct{})
+	go func() {
+		defer close(renewed)
+		ticker := time.NewTicker(runLockRenew)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := p.repo.RenewLock(context.WithoutCancel(ctx), RunLockName, holder, runLockTTL); err != nil {
+					p.logger.Warn("Failed to renew the run lock", "error", err)
+				}
+			}
+		}
+	}()
+
+	defer func() {
+		close(done)
+		<-renewed
+		if err := p.repo.ReleaseLock(context.WithoutCancel(ctx), RunLockName, holder); err != nil {
+			p.logger.Warn("Failed to release the run lock", "error", err)
+		}
+	}()
+	return fn()
+}
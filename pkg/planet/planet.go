@@ -0,0 +1,269 @@
+// Package planet runs a planet: it fetches the feeds in its database and
+// generates its site, as the rp commands do, for Go programs that embed
+// Rogue Planet instead of running rp.
+//
+// A Planet is opened from a config, loaded from a file with LoadConfig or
+// built in code from config.Default:
+//
+//	cfg, err := planet.LoadConfig("config.ini")
+//	if err != nil {
+//		return err
+//	}
+//	p, err := planet.Open(cfg, planet.Options{Logger: logger})
+//	if err != nil {
+//		return err
+//	}
+//	defer p.Close()
+//
+//	if _, err := p.AddFeed(ctx, "https://blog.example.com/feed", true); err != nil {
+//		return err
+//	}
+//	if err := p.Update(ctx, planet.UpdateOptions{}); err != nil {
+//		return err
+//	}
+//
+// Update and Fetch hold a lock in the database while they fetch, so a
+// program and rp commands sharing the database never fetch at the same
+// time. Cancelling the context of a fetch stops it starting new fetches and
+// lets those in flight finish.
+package planet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/adewale/rogue_planet/pkg/config"
+	"github.com/adewale/rogue_planet/pkg/feedurl"
+	"github.com/adewale/rogue_planet/pkg/logging"
+	"github.com/adewale/rogue_planet/pkg/repository"
+)
+
+// Options configure an opened Planet
+type Options struct {
+	// Logger receives the log of fetches. The config's log_level and
+	// log_format apply to loggers made by pkg/logging. Nil logs nothing.
+	Logger *slog.Logger
+
+	// Output receives the progress messages rp prints, such as each feed
+	// fetched and the pages generated. Nil discards them.
+	Output io.Writer
+}
+
+// Planet is a planet's config and open database. Its methods may be called
+// from several goroutines, though fetches are run one at a time.
+type Planet struct {
+	cfg    *config.Config
+	repo   *repository.Repository
+	logger *slog.Logger
+	out    io.Writer
+
+	running sync.Mutex // Held by the run of this Planet holding the run lock
+}
+
+// Open opens the database of the planet cfg describes. The Planet keeps
+// cfg, which must not be changed while it is open.
+func Open(cfg *config.Config, opts Options) (*Planet, error) {
+	repo, err := OpenRepository(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("open database: %w", err)
+	}
+	logger := opts.Logger
+	if logger == nil {
+		logger = slog.New(slog.DiscardHandler)
+	}
+	out := opts.Output
+	if out == nil {
+		out = io.Discard
+	}
+	return &Planet{
+		cfg:    cfg,
+		repo:   repo,
+		logger: logging.Configure(logger, cfg.Planet.LogLevel, cfg.Planet.LogFormat),
+		out:    out,
+	}, nil
+}
+
+// Close closes the planet's database
+func (p *Planet) Close() error {
+	return p.repo.Close()
+}
+
+// Config returns the planet's config
+func (p *Planet) Config() *config.Config {
+	return p.cfg
+}
+
+// Repository returns the planet's database, for what the Planet's methods
+// don't cover, such as listing feeds or hiding entries
+func (p *Planet) Repository() *repository.Repository {
+	return p.repo
+}
+
+// LoadConfig loads the config file at path, as located by config.Locate,
+// falling back to the defaults if there is no such file
+func LoadConfig(path string) (*config.Config, error) {
+	path = config.Locate(path)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return config.Default(), nil
+	}
+	return config.LoadFromFile(path)
+}
+
+// OpenRepository opens the database cfg selects and applies its [database]
+// settings. Open does this for a Planet; it is for programs that only need
+// the database.
+func OpenRepository(cfg *config.Config) (*repository.Repository, error) {
+	repo, err := OpenDatabase(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	policy, err := repository.EvictionPolicyByName(cfg.Database.EvictionPolicy)
+	if err != nil {
+		repo.Close()
+		return nil, err
+	}
+	repo.SetQuota(repository.Quota{
+		MaxEntriesPerFeed: cfg.Database.QuotaEntriesPerFeed,
+		MaxTotalEntries:   cfg.Database.QuotaTotalEntries,
+		Policy:            policy,
+	})
+	repo.SetFetchLogRetention(cfg.Database.FetchHistory)
+	repo.SetRevisionRetention(repository.RevisionRetention{
+		MaxPerEntry: cfg.Database.EntryRevisions,
+		MaxAge:      time.Duration(cfg.Database.EntryRevisionDays) * 24 * time.Hour,
+	})
+
+	return repo, nil
+}
+
+// OpenDatabase opens the SQLite or PostgreSQL database the config selects,
+// without OpenRepository's settings, for checking the database as it is
+func OpenDatabase(cfg *config.Config) (*repository.Repository, error) {
+	if cfg.Database.Driver == repository.DriverPostgres {
+		return repository.Open(repository.DriverPostgres, cfg.Database.DSN)
+	}
+	return repository.New(cfg.Database.Path)
+}
+
+// UpdateOptions adjust an Update; the zero value fetches the feeds that are
+// due and generates the whole site
+type UpdateOptions struct {
+	Fetch    FetchOptions
+	Generate GenerateOptions
+}
+
+// Update fetches the planet's feeds and generates its site, holding the run
+// lock for both. A fetch that runs past fetch_stage_timeout_minutes still
+// generates the site from the entries it stored before failing, so that a
+// hung feed host cannot keep the site from updating.
+func (p *Planet) Update(ctx context.Context, opts UpdateOptions) error {
+	return p.withRunLock(ctx, opts.Fetch.Wait, func() error {
+		fetchErr := p.fetch(ctx, opts.Fetch)
+		if fetchErr != nil {
+			if !errors.Is(fetchErr, ErrStageTimeout) {
+				return fmt.Errorf("fetch feeds: %w", fetchErr)
+			}
+			fmt.Fprintf(p.out, "  %v; generating the site from the entries fetched so far\n", fetchErr)
+		}
+		if p.cfg.Planet.LowMemory {
+			// Hand back what fetching used before generating takes its own
+			debug.FreeOSMemory()
+		}
+		if err := p.Generate(ctx, opts.Generate); err != nil {
+			return fmt.Errorf("generate site: %w", err)
+		}
+		if fetchErr != nil {
+			return fmt.Errorf("fetch feeds: %w", fetchErr)
+		}
+		return nil
+	})
+}
+
+// ErrStageTimeout is returned by a fetch or generate that ran past its
+// fetch_stage_timeout_minutes or generate_stage_timeout_minutes
+var ErrStageTimeout = errors.New("timed out")
+
+// withStageTimeout runs a stage of a run with ctx limited to timeout, if
+// any, and reports a stage that ran out of time as such rather than by
+// whichever operation it cut short
+func withStageTimeout(ctx context.Context, stage string, timeout time.Duration, run func(ctx context.Context) error) error {
+	if timeout <= 0 {
+		return run(ctx)
+	}
+	stageCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	err := run(stageCtx)
+	if err != nil && ctx.Err() == nil && errors.Is(stageCtx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%s stage %w after %s", stage, ErrStageTimeout, timeout)
+	}
+	return err
+}
+
+// AddedFeed is a feed added by AddFeed
+type AddedFeed struct {
+	Feed    repository.Feed
+	Entries int // Entries stored by its first fetch
+
+	// Feeds the planet already had that look like the same feed under
+	// another URL; the new feed is added anyway
+	Duplicates []repository.Feed
+}
+
+// AddFeed adds the feed at url, in the spelling feedurl.Normalize gives it.
+// With fetch set, the feed is fetched at once, ignoring any schedule, and is
+// only kept if that succeeds, so a mistyped URL never reaches the database;
+// the returned feed then has the title the feed gives itself and the URL it
+// was permanently redirected to, and a feed proxied through a service such
+// as FeedBurner is recognised as a duplicate of its origin.
+func (p *Planet) AddFeed(ctx context.Context, url string, fetch bool) (AddedFeed, error) {
+	url, err := feedurl.Normalize(url)
+	if err != nil {
+		return AddedFeed{}, err
+	}
+	id, err := p.repo.AddFeed(ctx, url, "")
+	if err != nil {
+		return AddedFeed{}, fmt.Errorf("add feed: %w", err)
+	}
+
+	var stored int
+	if fetch {
+		result := p.fetchNewFeed(ctx, id)
+		if result.Error != nil {
+			if removeErr := p.repo.RemoveFeed(ctx, id); removeErr != nil {
+				return AddedFeed{}, fmt.Errorf("feed failed validation (%v) and could not be removed: %w", result.Error, removeErr)
+			}
+			return AddedFeed{}, fmt.Errorf("fetch feed: %w", result.Error)
+		}
+		stored = result.StoredEntries
+	}
+
+	feed, err := p.repo.GetFeedByID(ctx, id)
+	if err != nil {
+		return AddedFeed{}, fmt.Errorf("read feed: %w", err)
+	}
+	feeds, err := p.repo.GetFeeds(ctx, false)
+	if err != nil {
+		return AddedFeed{}, fmt.Errorf("read feeds: %w", err)
+	}
+	return AddedFeed{Feed: *feed, Entries: stored, Duplicates: Duplicates(feeds, *feed)}, nil
+}
+
+// Duplicates returns the feeds, other than feed itself, that look like the
+// same feed under another URL, as feedurl.SameFeed judges
+func Duplicates(feeds []repository.Feed, feed repository.Feed) []repository.Feed {
+	var same []repository.Feed
+	for _, f := range feeds {
+		if f.ID != feed.ID && feedurl.SameFeed(f.URL, f.Link, feed.URL, feed.Link) {
+			same = append(same, f)
+		}
+	}
+	return same
+}
@@ -0,0 +1,303 @@
+package planet
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adewale/rogue_planet/pkg/config"
+	"github.com/adewale/rogue_planet/pkg/repository"
+)
+
+// newConfig returns the config of a planet whose database and output
+// directory are in a temporary directory
+func newConfig(t *testing.T) *config.Config {
+	t.Helper()
+	dir := t.TempDir()
+	cfg := config.Default()
+	cfg.Planet.Name = "Test Planet"
+	cfg.Planet.Link = "https://example.com"
+	cfg.Planet.OutputDir = filepath.Join(dir, "public")
+	cfg.Database.Path = filepath.Join(dir, "planet.db")
+	return cfg
+}
+
+// openPlanet opens the planet cfg describes, closing it when the test ends
+func openPlanet(t *testing.T, cfg *config.Config, out io.Writer) *Planet {
+	t.Helper()
+	p, err := Open(cfg, Options{Output: out})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	t.Cleanup(func() { p.Close() })
+	return p
+}
+
+func TestAddFeed(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	p := openPlanet(t, newConfig(t), nil)
+
+	added, err := p.AddFeed(ctx, "https://blog.example.com/feed", false)
+	if err != nil {
+		t.Fatalf("AddFeed() error = %v", err)
+	}
+	if added.Feed.ID == 0 || added.Feed.URL != "https://blog.example.com/feed" || added.Entries != 0 {
+		t.Errorf("AddFeed() = %+v", added)
+	}
+	if _, err := p.AddFeed(ctx, "https://blog.example.com/feed", false); err == nil {
+		t.Error("AddFeed() of a feed the planet has succeeded")
+	}
+
+	// A feed whose first fetch fails is not kept
+	if _, err := p.AddFeed(ctx, "http://127.0.0.1:1/feed.xml", true); err == nil || !strings.Contains(err.Error(), "fetch feed") {
+		t.Errorf("AddFeed() of an unreachable feed error = %v, want the fetch's", err)
+	}
+	feeds, err := p.Repository().GetFeeds(ctx, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(feeds) != 1 {
+		t.Errorf("planet has %d feeds, want only the first", len(feeds))
+	}
+
+	// Another spelling of the first feed is normalized, added, and flagged
+	respelled, err := p.AddFeed(ctx, "HTTP://Blog.Example.com:80/feed/?utm_source=mastodon#top", false)
+	if err != nil {
+		t.Fatalf("AddFeed() error = %v", err)
+	}
+	if respelled.Feed.URL != "http://blog.example.com/feed/" {
+		t.Errorf("AddFeed() stored %q, want the normalized URL", respelled.Feed.URL)
+	}
+	if len(respelled.Duplicates) != 1 || respelled.Duplicates[0].ID != added.Feed.ID {
+		t.Errorf("AddFeed() duplicates = %+v, want the first feed", respelled.Duplicates)
+	}
+}
+
+func TestAddFeedFiltersFirstFetch(t *testing.T) {
+	t.Parallel()
+	published := time.Now().UTC().Add(-time.Hour).Format(time.RFC1123Z)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		fmt.Fprintf(w, `<rss version="2.0"><channel><title>Blog</title><link>https://blog.example.com/</link>
+<item><title>A post</title><link>https://blog.example.com/1</link><guid>1</guid><pubDate>%[1]s</pubDate></item>
+<item><title>A sponsored post</title><link>https://blog.example.com/2</link><guid>2</guid><pubDate>%[1]s</pubDate></item>
+</channel></rss>`, published)
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	cfg := newConfig(t)
+	cfg.Planet.AllowHosts = []string{"127.0.0.1"}
+	cfg.Filters.ExcludeKeywords = []string{"sponsored"}
+	p := openPlanet(t, cfg, nil)
+
+	// The first fetch stores entries as every later one does
+	added, err := p.AddFeed(ctx, server.URL+"/feed.xml", true)
+	if err != nil {
+		t.Fatalf("AddFeed() error = %v", err)
+	}
+	entries, err := p.Repository().GetRecentEntries(ctx, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if added.Entries != 1 || len(entries) != 1 || entries[0].Title != "A post" {
+		t.Errorf("AddFeed() stored %d entries, %+v; want only the one the filters let through", added.Entries, entries)
+	}
+}
+
+func TestDuplicates(t *testing.T) {
+	t.Parallel()
+
+	feeds := []repository.Feed{
+		{ID: 1, URL: "https://example.com/feed.xml", Link: "https://example.com/"},
+		{ID: 2, URL: "https://example.com/comments.xml", Link: "https://example.com/"},
+		{ID: 3, URL: "https://feeds.feedburner.com/Example", Link: "http://www.example.com"},
+		{ID: 4, URL: "http://www.example.com/feed.xml/"},
+		{ID: 5, URL: "https://example.org/feed.xml", Link: "https://example.org/"},
+	}
+	var ids []int64
+	for _, f := range Duplicates(feeds, feeds[0]) {
+		ids = append(ids, f.ID)
+	}
+	if !slices.Equal(ids, []int64{3, 4}) {
+		t.Errorf("Duplicates() = %v, want feeds 3 and 4", ids)
+	}
+	if got := Duplicates(feeds, feeds[4]); len(got) != 0 {
+		t.Errorf("Duplicates() of a distinct feed = %+v, want none", got)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	cfg := newConfig(t)
+	var out bytes.Buffer
+	p := openPlanet(t, cfg, &out)
+
+	added, err := p.AddFeed(ctx, "https://feed.invalid/atom.xml", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Repository().UpdateFeedCacheExpiry(ctx, added.Feed.ID, time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.Update(ctx, UpdateOptions{}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(cfg.Planet.OutputDir, "index.html")); err != nil {
+		t.Errorf("index.html not generated: %v", err)
+	}
+	for _, want := range []string{"Fetching feeds...", "Skipping https://feed.invalid/atom.xml", "Generating site...", "Generated "} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output = %q, want it to contain %q", out.String(), want)
+		}
+	}
+	if lock, err := p.Repository().GetLock(ctx, RunLockName); err != nil || lock != nil {
+		t.Errorf("run lock after Update() = %+v, %v; want it released", lock, err)
+	}
+}
+
+func TestUpdateLowMemory(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `<rss version="2.0"><channel><title>Feed %[1]s</title><link>https://example.com%[1]s</link>
+<item><title>Post</title><link>https://example.com%[1]s/1</link><guid>%[1]s/1</guid><pubDate>%[2]s</pubDate></item>
+</channel></rss>`, r.URL.Path, time.Now().UTC().Format(time.RFC1123Z))
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	cfg := newConfig(t)
+	cfg.Planet.LowMemory = true
+	cfg.Planet.ConcurrentFetch = 10
+	cfg.Planet.AllowHosts = []string{"127.0.0.1"}
+	var out bytes.Buffer
+	p := openPlanet(t, cfg, &out)
+	for _, path := range []string{"/a", "/b", "/c"} {
+		if _, err := p.AddFeed(ctx, server.URL+path, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := p.Update(ctx, UpdateOptions{}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if n, err := p.Repository().CountEntries(ctx); err != nil || n != 3 {
+		t.Errorf("CountEntries() = %d, %v; want every feed's entry", n, err)
+	}
+	page, err := os.ReadFile(filepath.Join(cfg.Planet.OutputDir, "index.html"))
+	if err != nil || strings.Count(string(page), `<article id="entry-`) != 3 {
+		t.Errorf("index.html = %v, want the 3 entries:\n%s", err, page)
+	}
+}
+
+func TestWithStageTimeout(t *testing.T) {
+	t.Parallel()
+	block := func(ctx context.Context) error {
+		<-ctx.Done()
+		return fmt.Errorf("render index: %w", ctx.Err())
+	}
+
+	err := withStageTimeout(context.Background(), "generate", 10*time.Millisecond, block)
+	if !errors.Is(err, ErrStageTimeout) || err.Error() != "generate stage timed out after 10ms" {
+		t.Errorf("withStageTimeout() of a stage that runs out of time error = %v, want ErrStageTimeout", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := withStageTimeout(ctx, "generate", time.Minute, block); errors.Is(err, ErrStageTimeout) || !errors.Is(err, context.Canceled) {
+		t.Errorf("withStageTimeout() of a cancelled stage error = %v, want context.Canceled", err)
+	}
+
+	if err := withStageTimeout(context.Background(), "generate", 0, func(ctx context.Context) error {
+		if _, ok := ctx.Deadline(); ok {
+			return errors.New("deadline set")
+		}
+		return nil
+	}); err != nil {
+		t.Errorf("withStageTimeout() without a timeout error = %v", err)
+	}
+}
+
+func TestFeedTimeout(t *testing.T) {
+	t.Parallel()
+	cfg := config.Default()
+	// Four 30s attempts and backoffs of 1s, 2s, and 4s with their jitter
+	if got, want := FeedTimeout(cfg), 127700*time.Millisecond; got != want {
+		t.Errorf("FeedTimeout() by default = %v, want %v", got, want)
+	}
+	cfg.Planet.MaxRetries = 0
+	if got, want := FeedTimeout(cfg), 30*time.Second; got != want {
+		t.Errorf("FeedTimeout() without retries = %v, want %v", got, want)
+	}
+	cfg.Planet.FeedTimeoutSeconds = 300
+	if got, want := FeedTimeout(cfg), 5*time.Minute; got != want {
+		t.Errorf("FeedTimeout() with feed_timeout_seconds = %v, want %v", got, want)
+	}
+}
+
+func TestFetchStageTimeout(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// A host that never answers
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Minute):
+		}
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	cfg := newConfig(t)
+	cfg.Planet.AllowHosts = []string{"127.0.0.1"}
+	var out bytes.Buffer
+	p := openPlanet(t, cfg, &out)
+	if _, err := p.AddFeed(ctx, server.URL+"/feed", false); err != nil {
+		t.Fatal(err)
+	}
+
+	// The fetch in flight is abandoned when the stage runs out of time,
+	// well before its own 30 second limit
+	started := time.Now()
+	err := withStageTimeout(ctx, "fetch", 200*time.Millisecond, func(ctx context.Context) error {
+		return p.fetchFeeds(ctx, FetchOptions{})
+	})
+	if !errors.Is(err, ErrStageTimeout) {
+		t.Errorf("fetch error = %v, want ErrStageTimeout", err)
+	}
+	if elapsed := time.Since(started); elapsed > 10*time.Second {
+		t.Errorf("fetch took %s to stop after its stage timed out", elapsed)
+	}
+}
+
+func TestUpdateRunLock(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	p := openPlanet(t, newConfig(t), nil)
+	if err := p.Repository().AcquireLock(ctx, RunLockName, "cron pid 42", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	err := p.Update(ctx, UpdateOptions{Fetch: FetchOptions{Wait: 10 * time.Millisecond}})
+	if !errors.Is(err, ErrRunInProgress) || !strings.Contains(err.Error(), "cron pid 42") {
+		t.Errorf("Update() while locked error = %v, want ErrRunInProgress naming the holder", err)
+	}
+	if err := p.Fetch(ctx, FetchOptions{}); !errors.Is(err, ErrRunInProgress) {
+		t.Errorf("Fetch() while locked error = %v, want ErrRunInProgress", err)
+	}
+	if err := p.Fetch(ctx, FetchOptions{Offline: true}); err == nil || errors.Is(err, ErrRunInProgress) {
+		t.Errorf("Fetch() offline without a response cache error = %v", err)
+	}
+}
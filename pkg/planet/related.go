@@ -0,0 +1,118 @@
+package planet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/adewale/rogue_planet/pkg/related"
+	"github.com/adewale/rogue_planet/pkg/repository"
+	"github.com/adewale/rogue_planet/pkg/translate"
+)
+
+// relateNewEntries works out the related entries of the entries first seen
+// since the start of a fetch run. Failures are logged; they do not fail
+// the run.
+func (p *Planet) relateNewEntries(ctx context.Context, since time.Time) {
+	entries, err := p.repo.GetNewEntries(ctx, since)
+	if err != nil {
+		p.logger.Warn("Failed to read new entries for relating", "error", err)
+		return
+	}
+	if len(entries) == 0 {
+		return
+	}
+	n, err := p.relate(ctx, entries)
+	if err != nil {
+		p.logger.Warn("Failed to relate new entries", "error", err)
+		return
+	}
+	p.logger.Info("Related new entries", "entries", len(entries), "relations", n)
+}
+
+// Relate works out the related entries of every entry published within the
+// [related] window again, replacing those worked out before, and returns
+// how many relations it stored. Fetching relates new entries as they come,
+// so this is only needed after enabling related entries or changing how
+// they are found.
+func (p *Planet) Relate(ctx context.Context) (int, error) {
+	if !p.cfg.Related.Enabled {
+		return 0, errors.New("related entries are off; set enabled = true in the [related] section")
+	}
+	if err := p.repo.ClearRelations(ctx); err != nil {
+		return 0, err
+	}
+	return p.relate(ctx, nil)
+}
+
+// relate relates entries to the entries published within the [related]
+// window, both ways, and returns how many relations it stored. With no
+// entries, it relates every entry in the window.
+func (p *Planet) relate(ctx context.Context, entries []repository.Entry) (int, error) {
+	rc := p.cfg.Related
+	candidates, err := p.repo.GetRecentEntries(ctx, rc.WindowDays)
+	if err != nil {
+		return 0, fmt.Errorf("get entries: %w", err)
+	}
+	var entryDocs, candidateDocs []related.Doc
+	if entries == nil {
+		candidateDocs = p.relatedDocs(ctx, candidates)
+		entryDocs = candidateDocs
+	} else {
+		// Entries may be older than the window, so they get docs of their own
+		docs := p.relatedDocs(ctx, append(slices.Clip(entries), candidates...))
+		entryDocs, candidateDocs = docs[:len(entries)], docs[len(entries):]
+	}
+
+	minScore := float64(rc.MinScore) / 100
+	var relations []repository.Relation
+	for _, doc := range entryDocs {
+		for _, m := range related.Top(doc, candidateDocs, rc.MaxEntries, minScore) {
+			relations = append(relations,
+				repository.Relation{EntryID: doc.ID, RelatedID: m.ID, Score: m.Score},
+				repository.Relation{EntryID: m.ID, RelatedID: doc.ID, Score: m.Score})
+		}
+	}
+	if err := p.repo.SaveRelations(ctx, relations, rc.MaxEntries); err != nil {
+		return 0, err
+	}
+	return len(relations), nil
+}
+
+// relatedDocs returns the entries as compared with each other, with the
+// embeddings of their titles and summaries if an embeddings service is
+// configured. If the service fails, entries are compared by tags and
+// titles instead.
+func (p *Planet) relatedDocs(ctx context.Context, entries []repository.Entry) []related.Doc {
+	rc := p.cfg.Related
+	docs := make([]related.Doc, len(entries))
+	texts := make([]string, len(entries))
+	for i, e := range entries {
+		docs[i] = related.Doc{ID: e.ID, Title: translate.PlainText(e.Title), Tags: e.Categories}
+		summary := e.Summary
+		if summary == "" {
+			summary = e.Content
+		}
+		texts[i] = translate.PlainText(e.Title + "\n" + summary)
+	}
+	if rc.EmbeddingsURL == "" {
+		return docs
+	}
+
+	cache := related.Cache{
+		Embedder: related.HTTP{URL: rc.EmbeddingsURL},
+		Store:    p.repo,
+		Timeout:  time.Duration(rc.TimeoutSeconds) * time.Second,
+	}
+	vectors, err := cache.Embed(ctx, texts)
+	if err != nil {
+		p.logger.Warn("Failed to embed entries, comparing tags and titles instead", "error", err)
+		return docs
+	}
+	for i := range docs {
+		docs[i].Vector = vectors[i]
+	}
+	return docs
+}
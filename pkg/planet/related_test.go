@@ -0,0 +1,64 @@
+package planet
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adewale/rogue_planet/pkg/repository"
+)
+
+func TestRelate(t *testing.T) {
+	t.Parallel()
+	cfg := newConfig(t)
+	p := openPlanet(t, cfg, nil)
+	ctx := context.Background()
+
+	if _, err := p.Relate(ctx); err == nil {
+		t.Error("Relate() with related entries off succeeded")
+	}
+	cfg.Related.Enabled = true
+
+	repo := p.Repository()
+	id, err := repo.AddFeed(ctx, "https://feed.invalid/atom.xml", "Blog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	titles := []string{"Tuning the SQLite query planner", "SQLite query planner internals", "Gardening notes"}
+	for i, title := range titles {
+		if err := repo.UpsertEntry(ctx, &repository.Entry{
+			FeedID: id, EntryID: title, Title: title, Link: fmt.Sprintf("https://feed.invalid/%d", i),
+			Published: now, Updated: now, FirstSeen: now,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	n, err := p.Relate(ctx)
+	if err != nil {
+		t.Fatalf("Relate() error = %v", err)
+	}
+	if n != 4 {
+		t.Errorf("Relate() = %d relations, want the two SQLite posts linked both ways", n)
+	}
+
+	if err := p.Generate(ctx, GenerateOptions{}); err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(cfg.Planet.OutputDir, "index.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	index := string(data)
+	if got := strings.Count(index, `<aside class="entry-related"`); got != 2 {
+		t.Errorf("index.html has %d related posts sections, want 2", got)
+	}
+	if !strings.Contains(index, `<li><a href="https://feed.invalid/1">SQLite query planner internals</a></li>`) {
+		t.Error("index.html should link the related post")
+	}
+}
@@ -0,0 +1,65 @@
+package planet
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/adewale/rogue_planet/pkg/digest"
+	"github.com/adewale/rogue_planet/pkg/translate"
+	"github.com/adewale/rogue_planet/pkg/webhook"
+)
+
+// webhookTimeout limits posting a run's new entries, retries included
+const webhookTimeout = 5 * time.Minute
+
+// postNewEntries posts the entries first seen since the start of a fetch
+// run to the [webhook] URLs, oldest first. Failures are logged; they do not
+// fail the run.
+func (p *Planet) postNewEntries(ctx context.Context, since time.Time) {
+	cfg, logger := p.cfg, p.logger
+	entries, feedMap, err := p.newEntries(ctx, since)
+	if err != nil {
+		logger.Warn("Failed to read new entries for webhooks", "error", err)
+		return
+	}
+	if len(entries) == 0 {
+		return
+	}
+
+	authors := cfg.AuthorMap()
+	posted := make([]webhook.Entry, 0, len(entries))
+	for _, entry := range slices.Backward(entries) {
+		feed := feedMap[entry.FeedID]
+		summary := entry.Summary
+		if summary == "" {
+			summary = entry.Content
+		}
+		posted = append(posted, webhook.Entry{
+			Feed:      webhook.Feed{URL: feed.URL, Title: feed.Title, Link: feed.Link},
+			ID:        entry.EntryID,
+			Title:     translate.PlainText(entry.Title),
+			Link:      entry.Link,
+			Author:    authors.Canonical(entry.Author),
+			Published: entry.Published,
+			Summary:   digest.Excerpt(summary),
+		})
+	}
+
+	sender := webhook.Sender{
+		URLs:       cfg.Webhook.URLs,
+		BatchSize:  cfg.Webhook.BatchSize,
+		MaxRetries: cfg.Webhook.MaxRetries,
+		Client:     &http.Client{Timeout: time.Duration(cfg.Webhook.TimeoutSeconds) * time.Second},
+	}
+	// Entries are only new once, so they are posted even if the run was
+	// interrupted
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), webhookTimeout)
+	defer cancel()
+	if err := sender.Send(ctx, posted); err != nil {
+		logger.Warn("Failed to post new entries to webhook", "error", err)
+		return
+	}
+	logger.Info("Posted new entries to webhooks", "entries", len(posted), "webhooks", len(cfg.Webhook.URLs))
+}
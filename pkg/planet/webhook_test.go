@@ -0,0 +1,69 @@
+package planet
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/adewale/rogue_planet/pkg/repository"
+	"github.com/adewale/rogue_planet/pkg/webhook"
+)
+
+func TestPostNewEntries(t *testing.T) {
+	t.Parallel()
+	var mu sync.Mutex
+	var batches [][]webhook.Entry
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload webhook.Payload
+		json.NewDecoder(r.Body).Decode(&payload)
+		mu.Lock()
+		batches = append(batches, payload.Entries)
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	cfg := newConfig(t)
+	cfg.Webhook.URLs = []string{server.URL}
+	cfg.Webhook.BatchSize = 2
+	p := openPlanet(t, cfg, nil)
+
+	ctx := context.Background()
+	repo := p.Repository()
+	id, err := repo.AddFeed(ctx, "https://feed.invalid/atom.xml", "Blog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now().Truncate(time.Second)
+	for i, title := range []string{"Seen before", "First", "Second", "Third"} {
+		seen := start.Add(time.Duration(i) * time.Minute)
+		if i == 0 {
+			seen = start.Add(-time.Hour)
+		}
+		if err := repo.UpsertEntry(ctx, &repository.Entry{
+			FeedID: id, EntryID: title, Title: title, Link: fmt.Sprintf("https://feed.invalid/%d", i),
+			Content: "<p>All about " + title + "</p>", Published: seen, Updated: seen, FirstSeen: seen,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	p.postNewEntries(ctx, start)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(batches) != 2 || len(batches[0]) != 2 || len(batches[1]) != 1 {
+		t.Fatalf("batches = %+v, want the three new entries in batches of 2", batches)
+	}
+	e := batches[0][0]
+	if e.Title != "First" || e.Feed.Title != "Blog" || e.Feed.URL != "https://feed.invalid/atom.xml" || e.Summary != "All about First" {
+		t.Errorf("first entry = %+v, want the oldest new entry", e)
+	}
+	if batches[1][0].Title != "Third" {
+		t.Errorf("last entry = %+v", batches[1][0])
+	}
+}
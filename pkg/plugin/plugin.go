@@ -0,0 +1,182 @@
+// Package plugin runs WebAssembly entry plugins: WASI programs that change
+// or drop the entries parsed from feeds, like post_entry command hooks but
+// without starting a process per entry and without access to anything but
+// the entry.
+//
+// A plugin is a WASI preview 1 command module, such as a Go program built
+// with GOOS=wasip1 GOARCH=wasm, or a Rust one for wasm32-wasip1. It is run
+// once per entry with the post_entry event of package hooks on stdin, and
+// answers the same way: printing nothing keeps the entry, a JSON object
+// replaces the fields it has, and {"drop": true} drops it. A non-zero exit
+// status is a failure, reported with what the plugin wrote to stderr.
+//
+// Plugins are sandboxed: they see no files, environment variables, network,
+// or real clock, only their arguments and stdin. Each run is limited in
+// memory and time.
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"github.com/tetratelabs/wazero/sys"
+
+	"github.com/adewale/rogue_planet/pkg/hooks"
+)
+
+// maxOutput is how much of a failing plugin's stderr is kept for its error
+const maxOutput = 2048
+
+// pageSize is the size of a WebAssembly memory page
+const pageSize = 64 * 1024
+
+// Options limit each run of a plugin
+type Options struct {
+	MemoryLimitMB int           // Most memory a plugin may use; 0 leaves the WebAssembly limit of 4 GiB
+	Timeout       time.Duration // Time limit for a run on one entry; 0 means no limit
+
+	// CacheDir keeps compiled plugins between runs, so they are only
+	// compiled again when they change; empty compiles them every time
+	CacheDir string
+}
+
+// Plugins are loaded entry plugins, run in turn on each entry. Methods on
+// a nil *Plugins run nothing. They are safe for concurrent use.
+type Plugins struct {
+	runtime wazero.Runtime
+	plugins []plugin
+	timeout time.Duration
+}
+
+type plugin struct {
+	name   string // Base name of the file, argv[0]
+	args   []string
+	module wazero.CompiledModule
+}
+
+// Load compiles the plugins given by specs, each the path of a .wasm file
+// optionally followed by arguments for it, separated by spaces. Call Close
+// to free them.
+func Load(ctx context.Context, specs []string, opts Options) (*Plugins, error) {
+	cfg := wazero.NewRuntimeConfig().WithCloseOnContextDone(true)
+	if opts.MemoryLimitMB > 0 {
+		cfg = cfg.WithMemoryLimitPages(uint32(opts.MemoryLimitMB * 1024 * 1024 / pageSize))
+	}
+	if opts.CacheDir != "" {
+		cache, err := wazero.NewCompilationCacheWithDir(opts.CacheDir)
+		if err != nil {
+			return nil, fmt.Errorf("open plugin cache: %w", err)
+		}
+		cfg = cfg.WithCompilationCache(cache)
+	}
+	r := wazero.NewRuntimeWithConfig(ctx, cfg)
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, r); err != nil {
+		r.Close(ctx)
+		return nil, fmt.Errorf("instantiate WASI: %w", err)
+	}
+
+	p := &Plugins{runtime: r, timeout: opts.Timeout}
+	for _, spec := range specs {
+		fields := strings.Fields(spec)
+		if len(fields) == 0 {
+			continue
+		}
+		path := fields[0]
+		code, err := os.ReadFile(path)
+		if err != nil {
+			r.Close(ctx)
+			return nil, fmt.Errorf("read plugin: %w", err)
+		}
+		module, err := r.CompileModule(ctx, code)
+		if err != nil {
+			r.Close(ctx)
+			return nil, fmt.Errorf("compile plugin %s: %w", path, err)
+		}
+		p.plugins = append(p.plugins, plugin{name: filepath.Base(path), args: fields[1:], module: module})
+	}
+	return p, nil
+}
+
+// Close frees the plugins
+func (p *Plugins) Close(ctx context.Context) error {
+	if p == nil {
+		return nil
+	}
+	return p.runtime.Close(ctx)
+}
+
+// Entry runs the plugins on an entry of feed. It returns the entry as they
+// left it, or keep false if one dropped it. If a plugin fails, Entry
+// returns the entry as the plugins before it left it along with the error.
+func (p *Plugins) Entry(ctx context.Context, feed hooks.Feed, entry hooks.Entry) (_ hooks.Entry, keep bool, _ error) {
+	if p == nil {
+		return entry, true, nil
+	}
+	for _, pl := range p.plugins {
+		input, err := hooks.EntryEvent(feed, entry)
+		if err != nil {
+			return entry, true, err
+		}
+		out, err := p.run(ctx, pl, input)
+		if err != nil {
+			return entry, true, err
+		}
+		changed, keep, err := hooks.ApplyOutput(entry, out)
+		if err != nil {
+			return entry, true, fmt.Errorf("plugin %s: %w", pl.name, err)
+		}
+		if !keep {
+			return entry, false, nil
+		}
+		entry = changed
+	}
+	return entry, true, nil
+}
+
+// run instantiates a plugin with input on its stdin, runs it to completion,
+// and returns its stdout
+func (p *Plugins) run(ctx context.Context, pl plugin, input []byte) ([]byte, error) {
+	if p.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.timeout)
+		defer cancel()
+	}
+
+	var stdout, stderr bytes.Buffer
+	// Unnamed, so a plugin can run on several entries at once
+	cfg := wazero.NewModuleConfig().
+		WithName("").
+		WithArgs(append([]string{pl.name}, pl.args...)...).
+		WithStdin(bytes.NewReader(input)).
+		WithStdout(&stdout).
+		WithStderr(&stderr)
+	mod, err := p.runtime.InstantiateModule(ctx, pl.module, cfg)
+	if mod != nil {
+		mod.Close(ctx)
+	}
+	if err == nil {
+		return stdout.Bytes(), nil
+	}
+
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		err = fmt.Errorf("timed out after %s", p.timeout)
+	} else if exit, ok := err.(*sys.ExitError); ok {
+		err = fmt.Errorf("exit status %d", exit.ExitCode())
+	}
+	output := strings.TrimSpace(stderr.String())
+	if len(output) > maxOutput {
+		output = "..." + output[len(output)-maxOutput:]
+	}
+	if output != "" {
+		return nil, fmt.Errorf("plugin %s: %w\n%s", pl.name, err, output)
+	}
+	return nil, fmt.Errorf("plugin %s: %w", pl.name, err)
+}
@@ -0,0 +1,150 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/adewale/rogue_planet/pkg/hooks"
+)
+
+var (
+	buildOnce sync.Once
+	wasmPath  string
+	buildErr  error
+
+	// cacheDir is shared by the tests so the plugin is compiled once
+	cacheDir string
+)
+
+// testPlugin builds testdata/plugin for wasip1, once for all tests
+func testPlugin(t *testing.T) string {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("builds a WebAssembly plugin")
+	}
+	buildOnce.Do(func() {
+		dir, err := os.MkdirTemp("", "rp-plugin")
+		if err != nil {
+			buildErr = err
+			return
+		}
+		wasmPath, cacheDir = filepath.Join(dir, "plugin.wasm"), filepath.Join(dir, "cache")
+		cmd := exec.Command("go", "build", "-o", wasmPath, "./testdata/plugin")
+		cmd.Env = append(os.Environ(), "GOOS=wasip1", "GOARCH=wasm")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			buildErr = fmt.Errorf("%w\n%s", err, out)
+		}
+	})
+	if buildErr != nil {
+		t.Skipf("cannot build the test plugin: %v", buildErr)
+	}
+	return wasmPath
+}
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+	if wasmPath != "" {
+		os.RemoveAll(filepath.Dir(wasmPath))
+	}
+	os.Exit(code)
+}
+
+func TestEntry(t *testing.T) {
+	t.Parallel()
+	wasm := testPlugin(t)
+	ctx := context.Background()
+	feed := hooks.Feed{URL: "https://example.com/feed", Title: "Example"}
+	entry := hooks.Entry{ID: "1", Title: "Hello", Link: "https://example.com/1", Categories: []string{"go"}}
+
+	tests := []struct {
+		name    string
+		specs   []string
+		want    hooks.Entry
+		keep    bool
+		wantErr string
+	}{
+		{
+			name:  "no output keeps the entry",
+			specs: []string{wasm + " drop sponsored"},
+			want:  entry,
+			keep:  true,
+		},
+		{
+			name:  "output replaces fields except the ID",
+			specs: []string{wasm + " shout", wasm + " shout"},
+			want:  hooks.Entry{ID: "1", Title: "HELLO", Link: "https://example.com/1", Categories: []string{"go", "Example", "Example"}},
+			keep:  true,
+		},
+		{
+			name:  "drop",
+			specs: []string{wasm + " shout", wasm + " drop hello"},
+			want:  entry,
+			keep:  false,
+		},
+		{
+			name:    "sandboxed",
+			specs:   []string{wasm + " read-file"},
+			want:    entry,
+			keep:    true,
+			wantErr: "plugin plugin.wasm: exit status 3\nsandboxed:",
+		},
+		{
+			name:    "timeout",
+			specs:   []string{wasm + " spin"},
+			want:    entry,
+			keep:    true,
+			wantErr: "timed out after 1s",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			p, err := Load(ctx, tt.specs, Options{MemoryLimitMB: 64, Timeout: time.Second, CacheDir: cacheDir})
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			defer p.Close(ctx)
+
+			got, keep, err := p.Entry(ctx, feed, entry)
+			if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("Entry() error = %v, want %q", err, tt.wantErr)
+			}
+			if keep != tt.keep || keep && fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("Entry() = %+v, %v; want %+v, %v", got, keep, tt.want, tt.keep)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	if _, err := Load(ctx, []string{filepath.Join(dir, "missing.wasm")}, Options{}); err == nil {
+		t.Error("Load() of a missing plugin succeeded")
+	}
+	bad := filepath.Join(dir, "bad.wasm")
+	if err := os.WriteFile(bad, []byte("not wasm"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(ctx, []string{bad}, Options{}); err == nil || !strings.Contains(err.Error(), "compile plugin") {
+		t.Errorf("Load() of an invalid plugin error = %v", err)
+	}
+
+	var none *Plugins
+	entry := hooks.Entry{ID: "1", Title: "Hello"}
+	if got, keep, err := none.Entry(ctx, hooks.Feed{}, entry); err != nil || !keep || got.Title != "Hello" {
+		t.Errorf("nil Plugins Entry() = %+v, %v, %v", got, keep, err)
+	}
+	if err := none.Close(ctx); err != nil {
+		t.Errorf("nil Plugins Close() error = %v", err)
+	}
+}
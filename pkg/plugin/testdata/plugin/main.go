@@ -0,0 +1,45 @@
+// Command plugin is an entry plugin for the tests, built for wasip1. Its
+// argument picks what it does with the entry on stdin.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+func main() {
+	var event struct {
+		Feed  struct{ Title string }
+		Entry struct {
+			Title      string
+			Categories []string
+		}
+	}
+	if err := json.NewDecoder(os.Stdin).Decode(&event); err != nil {
+		fmt.Fprintln(os.Stderr, "bad event:", err)
+		os.Exit(2)
+	}
+
+	switch os.Args[1] {
+	case "shout":
+		json.NewEncoder(os.Stdout).Encode(map[string]any{
+			"id":         "changed",
+			"title":      strings.ToUpper(event.Entry.Title),
+			"categories": append(event.Entry.Categories, event.Feed.Title),
+		})
+	case "drop":
+		if strings.Contains(strings.ToLower(event.Entry.Title), os.Args[2]) {
+			fmt.Println(`{"drop": true}`)
+		}
+	case "read-file":
+		if _, err := os.ReadFile("/etc/passwd"); err != nil {
+			fmt.Fprintln(os.Stderr, "sandboxed:", err)
+			os.Exit(3)
+		}
+	case "spin":
+		for {
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package publish
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxMessageEntries is how many new entries a commit message lists
+const maxMessageEntries = 20
+
+// GitOptions configures the Git hook
+type GitOptions struct {
+	Remote string // Repository URL or path to push to
+	Branch string // Branch holding the site, e.g. gh-pages
+	Name   string // Commit author; defaults to "Rogue Planet"
+	Email  string // Commit author's email; defaults to rogue-planet@localhost
+	Title  string // Planet name, used in commit messages
+
+	// The planet's entries. Those published since the branch's last commit
+	// are listed in the commit message.
+	Entries []Entry
+}
+
+// Entry is an entry listed in a commit message
+type Entry struct {
+	Title     string
+	Feed      string
+	Published time.Time
+}
+
+// Git commits the output directory to a branch and pushes it, for GitHub
+// and GitLab Pages. The branch holds nothing but the site: each commit
+// replaces its whole tree. The repository lives in a hidden sibling of the
+// output directory and keeps only the branch's latest commit.
+func Git(opts GitOptions) Hook {
+	return Hook{Name: "git", run: opts.publish}
+}
+
+// publish commits outputDir on top of the branch as it is on the remote
+func (o GitOptions) publish(ctx context.Context, outputDir string, out io.Writer) error {
+	gitDir := siblingPath(outputDir, "git")
+	if _, err := os.Stat(gitDir); os.IsNotExist(err) {
+		if err := runGit(ctx, outputDir, out, "init", "-q", "--bare", gitDir); err != nil {
+			return err
+		}
+	}
+
+	name, email := o.Name, o.Email
+	if name == "" {
+		name = "Rogue Planet"
+	}
+	if email == "" {
+		email = "rogue-planet@localhost"
+	}
+	git := func(args ...string) error {
+		return runGit(ctx, outputDir, out, append([]string{
+			"--git-dir=" + gitDir, "--work-tree=" + outputDir,
+			"-c", "user.name=" + name, "-c", "user.email=" + email,
+		}, args...)...)
+	}
+
+	branch := "refs/heads/" + o.Branch
+	var heads bytes.Buffer
+	if err := runGit(ctx, outputDir, &heads, "--git-dir="+gitDir, "ls-remote", "--heads", o.Remote, branch); err != nil {
+		out.Write(heads.Bytes())
+		return err
+	}
+	exists := strings.TrimSpace(heads.String()) != ""
+
+	// Start from the remote's branch, so the new commit follows it
+	var since time.Time
+	if exists {
+		tracking := "refs/remotes/publish/" + o.Branch
+		if err := git("fetch", "-q", "--depth=1", o.Remote, "+"+branch+":"+tracking); err != nil {
+			return err
+		}
+		if err := git("update-ref", branch, tracking); err != nil {
+			return err
+		}
+		var stamp bytes.Buffer
+		if err := runGit(ctx, outputDir, &stamp, "--git-dir="+gitDir, "log", "-1", "--format=%ct", branch); err != nil {
+			out.Write(stamp.Bytes())
+			return err
+		}
+		if secs, err := strconv.ParseInt(strings.TrimSpace(stamp.String()), 10, 64); err == nil {
+			since = time.Unix(secs, 0)
+		}
+	} else if err := git("update-ref", "-d", branch); err != nil {
+		return err
+	}
+	if err := git("symbolic-ref", "HEAD", branch); err != nil {
+		return err
+	}
+	if exists {
+		if err := git("read-tree", branch); err != nil {
+			return err
+		}
+	} else if err := git("read-tree", "--empty"); err != nil {
+		return err
+	}
+
+	if err := git("add", "-A", "."); err != nil {
+		return err
+	}
+	if exists && git("diff", "--cached", "--quiet") == nil {
+		fmt.Fprintln(out, "nothing to commit")
+		return nil
+	}
+	if err := git("commit", "-q", "-m", commitMessage(o.Title, o.Entries, since)); err != nil {
+		return err
+	}
+	return git("push", "-q", o.Remote, branch+":"+branch)
+}
+
+// runGit runs git from dir, writing its output to out
+func runGit(ctx context.Context, dir string, out io.Writer, args ...string) error {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	cmd.Stdout = out
+	cmd.Stderr = out
+	cmd.WaitDelay = 5 * time.Second
+	// Never stop to ask for credentials; there is nobody to answer
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("git %s: %w", gitCommand(args), err)
+	}
+	return nil
+}
+
+// gitCommand returns the git subcommand in args, skipping global options
+func gitCommand(args []string) string {
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "-c":
+			i++
+		case !strings.HasPrefix(args[i], "-"):
+			return args[i]
+		}
+	}
+	return ""
+}
+
+// commitMessage summarizes the entries published after since
+func commitMessage(title string, entries []Entry, since time.Time) string {
+	var fresh []Entry
+	for _, e := range entries {
+		if e.Published.After(since) {
+			fresh = append(fresh, e)
+		}
+	}
+	if title == "" {
+		title = "Planet"
+	}
+
+	var b strings.Builder
+	switch len(fresh) {
+	case 0:
+		fmt.Fprintf(&b, "Update %s", title)
+		return b.String()
+	case 1:
+		fmt.Fprintf(&b, "Update %s: 1 new entry\n\n", title)
+	default:
+		fmt.Fprintf(&b, "Update %s: %d new entries\n\n", title, len(fresh))
+	}
+	for i, e := range fresh {
+		if i == maxMessageEntries {
+			fmt.Fprintf(&b, "- and %d more\n", len(fresh)-maxMessageEntries)
+			break
+		}
+		line := strings.Join(strings.Fields(e.Title), " ")
+		if line == "" {
+			line = "(untitled)"
+		}
+		if e.Feed != "" {
+			line += " (" + strings.Join(strings.Fields(e.Feed), " ") + ")"
+		}
+		fmt.Fprintf(&b, "- %s\n", line)
+	}
+	return b.String()
+}
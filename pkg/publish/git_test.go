@@ -0,0 +1,127 @@
+package publish
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// gitLog formats the commits of branch in the repository at dir
+func gitLog(t *testing.T, dir, format, branch string) string {
+	t.Helper()
+	out, err := exec.Command("git", "--git-dir="+dir, "log", "--format="+format, branch).CombinedOutput()
+	if err != nil {
+		t.Fatalf("git log: %v\n%s", err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func TestGit(t *testing.T) {
+	t.Parallel()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	remote := filepath.Join(dir, "remote.git")
+	if out, err := exec.Command("git", "init", "-q", "--bare", remote).CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v\n%s", err, out)
+	}
+	out := filepath.Join(dir, "public")
+	if err := os.MkdirAll(filepath.Join(out, "static"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(out, filepath.FromSlash(name)), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("index.html", "first")
+	write("static/style.css", "body{}")
+
+	opts := GitOptions{
+		Remote:  remote,
+		Branch:  "gh-pages",
+		Name:    "Planet Owner",
+		Email:   "owner@example.com",
+		Title:   "Test Planet",
+		Entries: []Entry{{Title: "Hello\nworld", Feed: "Alice", Published: time.Now().Add(-time.Hour)}},
+	}
+	publish := func() {
+		t.Helper()
+		if _, err := Run(context.Background(), out, time.Minute, []Hook{Git(opts)}); err != nil {
+			t.Fatalf("Run(Git) error = %v", err)
+		}
+	}
+
+	publish()
+	if got := gitLog(t, remote, "%an <%ae>|%s|%b", "gh-pages"); got != "Planet Owner <owner@example.com>|Update Test Planet: 1 new entry|- Hello world (Alice)" {
+		t.Errorf("first commit = %q", got)
+	}
+	files, _ := exec.Command("git", "--git-dir="+remote, "ls-tree", "-r", "--name-only", "gh-pages").Output()
+	if strings.Join(strings.Fields(string(files)), " ") != "index.html static/style.css" {
+		t.Errorf("committed files = %q", files)
+	}
+
+	// Nothing changed: no commit
+	publish()
+	if n := len(strings.Split(gitLog(t, remote, "%s", "gh-pages"), "\n")); n != 1 {
+		t.Errorf("unchanged site made %d commits, want 1", n)
+	}
+
+	// The entry was in the last commit, so it is not new any more
+	write("index.html", "second")
+	if err := os.Remove(filepath.Join(out, "static", "style.css")); err != nil {
+		t.Fatal(err)
+	}
+	publish()
+	if got := gitLog(t, remote, "%s", "gh-pages"); got != "Update Test Planet\nUpdate Test Planet: 1 new entry" {
+		t.Errorf("history = %q", got)
+	}
+	files, _ = exec.Command("git", "--git-dir="+remote, "ls-tree", "-r", "--name-only", "gh-pages").Output()
+	if strings.TrimSpace(string(files)) != "index.html" {
+		t.Errorf("deleted file still committed: %q", files)
+	}
+	if _, err := os.Stat(filepath.Join(out, ".git")); !os.IsNotExist(err) {
+		t.Error("the repository was created inside the output directory")
+	}
+}
+
+func TestGitFailure(t *testing.T) {
+	t.Parallel()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	out := filepath.Join(dir, "public")
+	if err := os.MkdirAll(out, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := Run(context.Background(), out, time.Minute, []Hook{Git(GitOptions{Remote: filepath.Join(dir, "missing.git"), Branch: "gh-pages"})})
+	if err == nil || !strings.Contains(err.Error(), "git: git ls-remote: exit status") {
+		t.Errorf("Run(Git) to a missing remote error = %v", err)
+	}
+}
+
+func TestCommitMessage(t *testing.T) {
+	t.Parallel()
+	since := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	var entries []Entry
+	for i := 0; i < maxMessageEntries+3; i++ {
+		entries = append(entries, Entry{Title: "New", Published: since.Add(time.Hour)})
+	}
+	entries = append(entries, Entry{Title: "Old", Published: since.Add(-time.Hour)})
+
+	msg := commitMessage("P", entries, since)
+	if !strings.HasPrefix(msg, "Update P: 23 new entries\n\n- New\n") || !strings.HasSuffix(msg, "- and 3 more\n") || strings.Contains(msg, "Old") {
+		t.Errorf("commitMessage() = %q", msg)
+	}
+	if msg := commitMessage("", nil, since); msg != "Update Planet" {
+		t.Errorf("commitMessage() with no new entries = %q", msg)
+	}
+}
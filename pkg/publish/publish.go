@@ -0,0 +1,245 @@
+// Package publish deploys the generated site once a generation succeeds.
+//
+// Hooks are external programs: an arbitrary shell command, rsync, the aws
+// CLI for S3, wrangler for Cloudflare Pages, or git to commit the site to a
+// Pages branch. Each runs with a time limit
+// and its output is kept, so a failing deploy is reported with what the tool
+// said rather than just an exit status.
+//
+// A Fingerprint of what the site is made of is recorded after every
+// successful publish, next to the output directory, so runs that changed
+// nothing can skip publishing.
+package publish
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// OutputDirEnv is set to the absolute output directory for every hook
+const OutputDirEnv = "RP_OUTPUT_DIR"
+
+// maxOutput is how much of a failing hook's output is kept for its error
+const maxOutput = 2048
+
+// Hook is an external program that publishes the site
+type Hook struct {
+	Name string   // Shown in messages, e.g. "rsync"
+	Args []string // Program and its arguments
+
+	// Hooks that take several steps run this instead of Args
+	run func(ctx context.Context, outputDir string, out io.Writer) error
+}
+
+// Command runs command with the platform's shell
+func Command(command string) Hook {
+	if runtime.GOOS == "windows" {
+		return Hook{Name: "command", Args: []string{"cmd", "/C", command}}
+	}
+	return Hook{Name: "command", Args: []string{"sh", "-c", command}}
+}
+
+// Rsync mirrors outputDir to dest, deleting files that are no longer generated
+func Rsync(outputDir, dest string) Hook {
+	return Hook{Name: "rsync", Args: []string{"rsync", "-rlt", "--delete", filepath.Clean(outputDir) + string(filepath.Separator), dest}}
+}
+
+// S3 mirrors outputDir to an s3://bucket/prefix URL with the aws CLI
+func S3(outputDir, dest string) Hook {
+	return Hook{Name: "s3", Args: []string{"aws", "s3", "sync", "--delete", "--no-progress", outputDir, dest}}
+}
+
+// CloudflarePages deploys outputDir to a Cloudflare Pages project with wrangler
+func CloudflarePages(outputDir, project string) Hook {
+	return Hook{Name: "cloudflare pages", Args: []string{"wrangler", "pages", "deploy", outputDir, "--project-name", project}}
+}
+
+// Result describes one hook that ran successfully
+type Result struct {
+	Hook     Hook
+	Duration time.Duration
+}
+
+// Run runs each hook in turn, each limited to timeout (0 means no limit).
+// A failing hook does not stop the others, since each publishes to its own
+// destination; the errors of all failing hooks are returned together.
+func Run(ctx context.Context, outputDir string, timeout time.Duration, hooks []Hook) ([]Result, error) {
+	abs, err := filepath.Abs(outputDir)
+	if err != nil {
+		return nil, fmt.Errorf("resolve output directory: %w", err)
+	}
+
+	var results []Result
+	var errs []error
+	for _, h := range hooks {
+		if err := ctx.Err(); err != nil {
+			return results, err
+		}
+		start := time.Now()
+		if err := run(ctx, abs, timeout, h); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		results = append(results, Result{Hook: h, Duration: time.Since(start)})
+	}
+	return results, errors.Join(errs...)
+}
+
+// run runs one hook from the current directory
+func run(ctx context.Context, outputDir string, timeout time.Duration, h Hook) error {
+	if len(h.Args) == 0 && h.run == nil {
+		return fmt.Errorf("%s: no command", h.Name)
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	var out tailBuffer
+	var err error
+	if h.run != nil {
+		err = h.run(ctx, outputDir, &out)
+	} else {
+		cmd := exec.CommandContext(ctx, h.Args[0], h.Args[1:]...)
+		cmd.Env = append(os.Environ(), OutputDirEnv+"="+outputDir)
+		cmd.Stdout = &out
+		cmd.Stderr = &out
+		// Don't wait forever on children of a killed hook holding its output open
+		cmd.WaitDelay = 5 * time.Second
+		err = cmd.Run()
+	}
+	if err == nil {
+		return nil
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		err = fmt.Errorf("timed out after %s", timeout)
+	}
+	if output := strings.TrimSpace(out.String()); output != "" {
+		return fmt.Errorf("%s: %w\n%s", h.Name, err, output)
+	}
+	return fmt.Errorf("%s: %w", h.Name, err)
+}
+
+// tailBuffer keeps the last maxOutput bytes written to it
+type tailBuffer struct {
+	buf       bytes.Buffer
+	truncated bool
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.buf.Write(p)
+	if extra := t.buf.Len() - maxOutput; extra > 0 {
+		t.buf.Next(extra)
+		t.truncated = true
+	}
+	return len(p), nil
+}
+
+func (t *tailBuffer) String() string {
+	if t.truncated {
+		return "..." + t.buf.String()
+	}
+	return t.buf.String()
+}
+
+// Fingerprint hashes what a site is made of, to tell whether a generation
+// changed anything worth publishing
+type Fingerprint struct {
+	h hash.Hash
+}
+
+// NewFingerprint returns an empty fingerprint
+func NewFingerprint() *Fingerprint {
+	return &Fingerprint{h: sha256.New()}
+}
+
+// Add adds v, encoded as JSON
+func (f *Fingerprint) Add(v any) error {
+	if err := json.NewEncoder(f.h).Encode(v); err != nil {
+		return fmt.Errorf("fingerprint: %w", err)
+	}
+	return nil
+}
+
+// AddFiles adds the names and contents of the files under root, skipping
+// those for which skip (given the slash-separated path relative to root)
+// returns true. A root that does not exist adds nothing.
+func (f *Fingerprint) AddFiles(root string, skip func(rel string) bool) error {
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == root {
+				return fs.SkipAll
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if skip != nil && skip(rel) {
+			return nil
+		}
+
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		fmt.Fprintf(f.h, "%s\x00", rel)
+		_, err = io.Copy(f.h, file)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("fingerprint %s: %w", root, err)
+	}
+	return nil
+}
+
+// Sum returns the fingerprint as a hex string
+func (f *Fingerprint) Sum() string {
+	return hex.EncodeToString(f.h.Sum(nil))
+}
+
+// LastPublished returns the fingerprint recorded by the last successful
+// publish of outputDir, or "" if there is none
+func LastPublished(outputDir string) string {
+	data, err := os.ReadFile(siblingPath(outputDir, "published"))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// RecordPublished records sum as the fingerprint of the published outputDir
+func RecordPublished(outputDir, sum string) error {
+	if err := os.WriteFile(siblingPath(outputDir, "published"), []byte(sum+"\n"), 0644); err != nil {
+		return fmt.Errorf("record publish: %w", err)
+	}
+	return nil
+}
+
+// siblingPath is a hidden sibling of outputDir, like the generator's staging
+// directories, so it is not itself published
+func siblingPath(outputDir, suffix string) string {
+	parent, name := filepath.Split(filepath.Clean(outputDir))
+	return filepath.Join(parent, "."+name+"."+suffix)
+}
@@ -0,0 +1,164 @@
+package publish
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestMain lets the test binary stand in for the programs hooks run
+func TestMain(m *testing.M) {
+	switch os.Getenv("PUBLISH_TEST_HOOK") {
+	case "":
+		os.Exit(m.Run())
+	case "ok":
+		fmt.Println("uploaded", os.Getenv(OutputDirEnv))
+		os.Exit(0)
+	case "fail":
+		fmt.Println(strings.Repeat("progress\n", 1000) + "error: access denied")
+		os.Exit(3)
+	case "hang":
+		time.Sleep(time.Minute)
+		os.Exit(0)
+	}
+}
+
+// testHook runs the test binary as a hook; PUBLISH_TEST_HOOK picks what it does
+func testHook(t *testing.T, name string) Hook {
+	t.Helper()
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Hook{Name: name, Args: []string{exe, "-test.run=^$"}}
+}
+
+func TestRun(t *testing.T) {
+	t.Setenv("PUBLISH_TEST_HOOK", "ok")
+	dir := t.TempDir()
+
+	results, err := Run(context.Background(), dir, time.Minute, []Hook{testHook(t, "ok")})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if len(results) != 1 || results[0].Hook.Name != "ok" {
+		t.Errorf("Run() results = %+v", results)
+	}
+}
+
+func TestRunFailure(t *testing.T) {
+	t.Setenv("PUBLISH_TEST_HOOK", "fail")
+
+	results, err := Run(context.Background(), t.TempDir(), time.Minute, []Hook{testHook(t, "first"), testHook(t, "second")})
+	if len(results) != 0 || err == nil {
+		t.Fatalf("Run() = %+v, %v; want both hooks to fail", results, err)
+	}
+	msg := err.Error()
+	for _, want := range []string{"first: exit status 3", "second: exit status 3", "error: access denied"} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("error missing %q:\n%s", want, msg)
+		}
+	}
+	if len(msg) > 3*maxOutput {
+		t.Errorf("error keeps %d bytes of output, want at most the last %d per hook", len(msg), maxOutput)
+	}
+}
+
+func TestRunTimeout(t *testing.T) {
+	t.Setenv("PUBLISH_TEST_HOOK", "hang")
+
+	start := time.Now()
+	_, err := Run(context.Background(), t.TempDir(), 100*time.Millisecond, []Hook{testHook(t, "slow")})
+	if err == nil || !strings.Contains(err.Error(), "slow: timed out after 100ms") {
+		t.Errorf("Run() error = %v, want a timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 30*time.Second {
+		t.Errorf("Run() took %s to time out", elapsed)
+	}
+}
+
+func TestHooks(t *testing.T) {
+	t.Parallel()
+	out := filepath.Join("site", "public")
+	tests := []struct {
+		hook Hook
+		want string
+	}{
+		{Rsync(out, "www@example.com:/srv/planet/"), "rsync -rlt --delete " + out + string(filepath.Separator) + " www@example.com:/srv/planet/"},
+		{S3(out, "s3://bucket/planet"), "aws s3 sync --delete --no-progress " + out + " s3://bucket/planet"},
+		{CloudflarePages(out, "planet"), "wrangler pages deploy " + out + " --project-name planet"},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(tt.hook.Args, " "); got != tt.want {
+			t.Errorf("%s hook runs %q, want %q", tt.hook.Name, got, tt.want)
+		}
+	}
+	if c := Command("./deploy.sh"); c.Args[len(c.Args)-1] != "./deploy.sh" {
+		t.Errorf("Command() = %v", c.Args)
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sum := func() string {
+		t.Helper()
+		f := NewFingerprint()
+		if err := f.Add(map[string]string{"title": "Planet"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := f.AddFiles(dir, func(rel string) bool { return strings.HasSuffix(rel, ".html") }); err != nil {
+			t.Fatal(err)
+		}
+		return f.Sum()
+	}
+
+	write("static/style.css", "body{}")
+	write("index.html", "generated at 10:00")
+	first := sum()
+
+	write("index.html", "generated at 10:05")
+	if sum() != first {
+		t.Error("a skipped file changed the fingerprint")
+	}
+	write("static/style.css", "body{color:red}")
+	if sum() == first {
+		t.Error("a changed file kept the fingerprint")
+	}
+
+	if err := NewFingerprint().AddFiles(filepath.Join(dir, "missing"), nil); err != nil {
+		t.Errorf("AddFiles() of a missing directory error = %v", err)
+	}
+}
+
+func TestRecordPublished(t *testing.T) {
+	t.Parallel()
+	out := filepath.Join(t.TempDir(), "public")
+
+	if got := LastPublished(out); got != "" {
+		t.Errorf("LastPublished() before any publish = %q", got)
+	}
+	if err := RecordPublished(out, "abc123"); err != nil {
+		t.Fatalf("RecordPublished() error = %v", err)
+	}
+	if got := LastPublished(out); got != "abc123" {
+		t.Errorf("LastPublished() = %q, want abc123", got)
+	}
+	if _, err := os.Stat(out); !os.IsNotExist(err) {
+		t.Error("RecordPublished() wrote inside the output directory")
+	}
+}
@@ -0,0 +1,283 @@
+// Package related finds the entries related to an entry: those sharing its
+// tags and title words or, with an embeddings service, those whose texts
+// have embeddings closest to its own.
+//
+// The embeddings service is POSTed a Request as JSON and answers with a
+// Response. It can wrap whichever embedding model the operator prefers.
+// Embeddings are cached by a hash of the text, so each text is sent once.
+package related
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxBatch is the most texts sent in one Request
+const maxBatch = 100
+
+// maxResponse limits the size of a Response
+const maxResponse = 64 * 1024 * 1024
+
+// Doc is an entry as compared with others
+type Doc struct {
+	ID     int64
+	Title  string    // Plain text title
+	Tags   []string  // Categories, compared ignoring case
+	Vector []float64 // Embedding of the entry's text, if any
+}
+
+// Match is an entry related to another, with how similar they are
+type Match struct {
+	ID    int64
+	Score float64 // From 0, nothing in common, to 1
+}
+
+// Score returns the similarity of two entries, from 0 to 1. Entries that
+// both have embeddings are compared by the cosine similarity of those;
+// others by the tags and title words they share.
+func Score(a, b Doc) float64 {
+	if len(a.Vector) > 0 && len(a.Vector) == len(b.Vector) {
+		return max(cosine(a.Vector, b.Vector), 0)
+	}
+	title := jaccard(words(a.Title), words(b.Title))
+	if len(a.Tags) == 0 || len(b.Tags) == 0 {
+		return title
+	}
+	return (jaccard(lower(a.Tags), lower(b.Tags)) + title) / 2
+}
+
+// Top returns the at most n candidates most similar to doc, scoring at
+// least minScore, most similar first. Candidates with doc's ID are skipped.
+func Top(doc Doc, candidates []Doc, n int, minScore float64) []Match {
+	var matches []Match
+	for _, c := range candidates {
+		if c.ID == doc.ID {
+			continue
+		}
+		if s := Score(doc, c); s >= minScore && s > 0 {
+			matches = append(matches, Match{ID: c.ID, Score: s})
+		}
+	}
+	slices.SortFunc(matches, func(a, b Match) int {
+		// Newer entries, with higher IDs, break ties
+		return cmp.Or(cmp.Compare(b.Score, a.Score), cmp.Compare(b.ID, a.ID))
+	})
+	if len(matches) > n {
+		matches = matches[:n]
+	}
+	return matches
+}
+
+func cosine(a, b []float64) float64 {
+	var dot, na, nb float64
+	for i := range a {
+		dot += a[i] * b[i]
+		na += a[i] * a[i]
+		nb += b[i] * b[i]
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(na*nb)
+}
+
+// jaccard returns the size of the intersection of two sets over the size
+// of their union
+func jaccard(a, b map[string]bool) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	shared := 0
+	for w := range a {
+		if b[w] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
+
+// stopWords are words too common in titles to relate entries
+var stopWords = map[string]bool{
+	"the": true, "and": true, "for": true, "with": true, "from": true, "that": true,
+	"this": true, "your": true, "you": true, "are": true, "was": true, "how": true,
+	"why": true, "what": true, "when": true, "into": true, "about": true, "not": true,
+	"new": true, "our": true, "its": true, "has": true, "have": true, "can": true,
+}
+
+// words returns the words of a title worth comparing: lower case, at least
+// three letters long, and not stop words
+func words(title string) map[string]bool {
+	set := make(map[string]bool)
+	for _, w := range strings.FieldsFunc(strings.ToLower(title), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	}) {
+		if utf8.RuneCountInString(w) >= 3 && !stopWords[w] {
+			set[w] = true
+		}
+	}
+	return set
+}
+
+func lower(tags []string) map[string]bool {
+	set := make(map[string]bool, len(tags))
+	for _, t := range tags {
+		if t = strings.ToLower(strings.TrimSpace(t)); t != "" {
+			set[t] = true
+		}
+	}
+	return set
+}
+
+// Request asks for the embeddings of Texts
+type Request struct {
+	Texts []string `json:"texts"`
+}
+
+// Response holds the embeddings of a Request's texts, in the same order
+type Response struct {
+	Embeddings [][]float64 `json:"embeddings"`
+}
+
+// Embedder returns the embeddings of plain texts
+type Embedder interface {
+	Embed(ctx context.Context, req Request) ([][]float64, error)
+}
+
+// HTTP POSTs each Request as JSON to a URL
+type HTTP struct {
+	URL    string
+	Client *http.Client // Defaults to a client with a 60 second timeout
+}
+
+// Embed posts the request and reads the embeddings from the reply
+func (h HTTP) Embed(ctx context.Context, req Request) ([][]float64, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("encode embeddings request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("create embeddings request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	client := h.Client
+	if client == nil {
+		client = &http.Client{Timeout: 60 * time.Second}
+	}
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("post embeddings request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+		return nil, fmt.Errorf("post embeddings request: server returned %s", resp.Status)
+	}
+	var r Response
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponse)).Decode(&r); err != nil {
+		return nil, fmt.Errorf("decode embeddings response: %w", err)
+	}
+	if len(r.Embeddings) != len(req.Texts) {
+		return nil, fmt.Errorf("embeddings response has %d texts, want %d", len(r.Embeddings), len(req.Texts))
+	}
+	return r.Embeddings, nil
+}
+
+// Store caches embeddings, keyed by Key of the text
+type Store interface {
+	GetEmbeddings(ctx context.Context, keys []string) (map[string][]float64, error)
+	SaveEmbeddings(ctx context.Context, embeddings map[string][]float64) error
+}
+
+// Key identifies a text in a Store
+func Key(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:])
+}
+
+// Cache returns embeddings, asking the Embedder only for texts the Store
+// does not already hold
+type Cache struct {
+	Embedder Embedder
+	Store    Store
+	Timeout  time.Duration // Time limit for each request to the Embedder (0 = none)
+}
+
+// Embed returns the embedding of each text, nil for empty texts.
+// Embeddings made before an error are kept in the Store, so a later run
+// resumes where this one failed.
+func (c Cache) Embed(ctx context.Context, texts []string) ([][]float64, error) {
+	keys := make([]string, len(texts))
+	for i, text := range texts {
+		keys[i] = Key(text)
+	}
+	cached, err := c.Store.GetEmbeddings(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	// Ask for each missing text once
+	var missing []string
+	seen := make(map[string]bool)
+	for i, text := range texts {
+		if text == "" || seen[keys[i]] {
+			continue
+		}
+		seen[keys[i]] = true
+		if _, ok := cached[keys[i]]; !ok {
+			missing = append(missing, text)
+		}
+	}
+	for start := 0; start < len(missing); start += maxBatch {
+		batch := missing[start:min(start+maxBatch, len(missing))]
+		embeddings, err := c.embed(ctx, Request{Texts: batch})
+		if err != nil {
+			return nil, err
+		}
+		fresh := make(map[string][]float64, len(batch))
+		for i, text := range batch {
+			fresh[Key(text)] = embeddings[i]
+			cached[Key(text)] = embeddings[i]
+		}
+		if err := c.Store.SaveEmbeddings(ctx, fresh); err != nil {
+			return nil, err
+		}
+	}
+
+	out := make([][]float64, len(texts))
+	for i := range texts {
+		out[i] = cached[keys[i]]
+	}
+	return out, nil
+}
+
+// embed makes one request to the Embedder within the time limit
+func (c Cache) embed(ctx context.Context, req Request) ([][]float64, error) {
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+	embeddings, err := c.Embedder.Embed(ctx, req)
+	if c.Timeout > 0 && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return nil, fmt.Errorf("embeddings request timed out after %s", c.Timeout)
+	}
+	return embeddings, err
+}
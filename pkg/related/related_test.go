@@ -0,0 +1,151 @@
+package related
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestScore(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		a, b Doc
+		want float64
+	}{
+		{"shared title words", Doc{Title: "Tuning the SQLite query planner"}, Doc{Title: "Why the query planner chose a scan"}, 1.0 / 3},
+		{"stop words and short words ignored", Doc{Title: "How to do it"}, Doc{Title: "How we do it"}, 0},
+		{"tags and title", Doc{Title: "SQLite tips", Tags: []string{"Databases", "go"}}, Doc{Title: "SQLite tips", Tags: []string{"databases"}}, (0.5 + 1) / 2},
+		{"embeddings", Doc{Title: "a", Vector: []float64{1, 0}}, Doc{Title: "b", Vector: []float64{1, 1}}, 1 / math.Sqrt2},
+		{"opposite embeddings", Doc{Vector: []float64{1, 0}}, Doc{Vector: []float64{-1, 0}}, 0},
+		{"one embedding", Doc{Title: "SQLite", Vector: []float64{1}}, Doc{Title: "SQLite"}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := Score(tt.a, tt.b); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("Score() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTop(t *testing.T) {
+	t.Parallel()
+	doc := Doc{ID: 1, Title: "Go generics in practice"}
+	candidates := []Doc{
+		doc,
+		{ID: 2, Title: "Gardening notes"},
+		{ID: 3, Title: "Go generics"},
+		{ID: 4, Title: "Generics in Rust, in practice"},
+		{ID: 5, Title: "Go generics, in practice"},
+		{ID: 6, Title: "Practice makes perfect"},
+	}
+	got := Top(doc, candidates, 2, 0.3)
+	want := []Match{{ID: 5, Score: 1}, {ID: 4, Score: 2.0 / 3}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Top() = %+v, want %+v", got, want)
+	}
+	if got := Top(doc, candidates, 10, 0.9); len(got) != 1 {
+		t.Errorf("Top() with a high minimum score = %+v, want one match", got)
+	}
+}
+
+// memoryStore is a Store backed by a map
+type memoryStore map[string][]float64
+
+func (m memoryStore) GetEmbeddings(_ context.Context, keys []string) (map[string][]float64, error) {
+	out := make(map[string][]float64)
+	for _, k := range keys {
+		if v, ok := m[k]; ok {
+			out[k] = v
+		}
+	}
+	return out, nil
+}
+
+func (m memoryStore) SaveEmbeddings(_ context.Context, embeddings map[string][]float64) error {
+	for k, v := range embeddings {
+		m[k] = v
+	}
+	return nil
+}
+
+// lengths embeds texts as their length, recording each request
+type lengths struct {
+	requests [][]string
+	err      error
+}
+
+func (l *lengths) Embed(_ context.Context, req Request) ([][]float64, error) {
+	l.requests = append(l.requests, req.Texts)
+	if l.err != nil {
+		return nil, l.err
+	}
+	out := make([][]float64, len(req.Texts))
+	for i, text := range req.Texts {
+		out[i] = []float64{float64(len(text))}
+	}
+	return out, nil
+}
+
+func TestCacheEmbed(t *testing.T) {
+	t.Parallel()
+	e := &lengths{}
+	cache := Cache{Embedder: e, Store: memoryStore{}}
+
+	got, err := cache.Embed(context.Background(), []string{"ab", "", "abc", "ab"})
+	if err != nil {
+		t.Fatalf("Embed() error = %v", err)
+	}
+	if want := [][]float64{{2}, nil, {3}, {2}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Embed() = %v, want %v", got, want)
+	}
+	if _, err := cache.Embed(context.Background(), []string{"abc", "abcd"}); err != nil {
+		t.Fatalf("Embed() error = %v", err)
+	}
+	// Duplicates, empty texts, and cached texts are not sent
+	if want := [][]string{{"ab", "abc"}, {"abcd"}}; !reflect.DeepEqual(e.requests, want) {
+		t.Errorf("requests = %q, want %q", e.requests, want)
+	}
+
+	cache.Embedder = &lengths{err: errors.New("model unavailable")}
+	if _, err := cache.Embed(context.Background(), []string{"new"}); err == nil || !strings.Contains(err.Error(), "model unavailable") {
+		t.Errorf("Embed() error = %v, want the embedder's error", err)
+	}
+}
+
+func TestHTTPEmbed(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req Request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if len(req.Texts) > 1 {
+			http.Error(w, "too many texts", http.StatusRequestEntityTooLarge)
+			return
+		}
+		json.NewEncoder(w).Encode(Response{Embeddings: [][]float64{{0.5, 0.25}}})
+	}))
+	defer server.Close()
+
+	h := HTTP{URL: server.URL}
+	got, err := h.Embed(context.Background(), Request{Texts: []string{"a"}})
+	if err != nil {
+		t.Fatalf("Embed() error = %v", err)
+	}
+	if want := [][]float64{{0.5, 0.25}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Embed() = %v, want %v", got, want)
+	}
+	if _, err := h.Embed(context.Background(), Request{Texts: []string{"a", "b"}}); err == nil || !strings.Contains(err.Error(), "413") {
+		t.Errorf("Embed() error = %v, want the server's status", err)
+	}
+}
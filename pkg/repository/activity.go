@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// FeedActivity is what a feed has published and how its latest fetches went
+type FeedActivity struct {
+	Entries       int64           // Entries stored
+	LastPublished time.Time       // Newest entry's publication time; zero if there are no entries
+	Fetches       []FetchLogEntry // Most recent fetch log records, newest first
+}
+
+// GetFeedActivity returns the activity of every feed with entries or fetch
+// log records, by feed ID, with at most fetches fetch log records each
+func (r *Repository) GetFeedActivity(ctx context.Context, fetches int) (map[int64]*FeedActivity, error) {
+	activity := make(map[int64]*FeedActivity)
+	get := func(feedID int64) *FeedActivity {
+		a, ok := activity[feedID]
+		if !ok {
+			a = &FeedActivity{}
+			activity[feedID] = a
+		}
+		return a
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT feed_id, COUNT(*), MAX(published)
+		FROM entries
+		GROUP BY feed_id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("query entry activity: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var feedID, count int64
+		var published sql.NullString
+		if err := rows.Scan(&feedID, &count, &published); err != nil {
+			return nil, err
+		}
+		a := get(feedID)
+		a.Entries = count
+		if a.LastPublished, err = nullTime(published, "published"); err != nil {
+			return nil, err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// The fetch log keeps only a few records per feed, so reading it all
+	// and keeping the newest of each is cheaper than a query per feed
+	logRows, err := r.db.QueryContext(ctx, `
+		SELECT `+fetchLogColumns+`
+		FROM fetch_log
+		ORDER BY feed_id, id DESC
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("query fetch log: %w", err)
+	}
+	defer logRows.Close()
+	for logRows.Next() {
+		entry, err := scanFetchLogEntry(logRows)
+		if err != nil {
+			return nil, err
+		}
+		if a := get(entry.FeedID); len(a.Fetches) < fetches {
+			a.Fetches = append(a.Fetches, entry)
+		}
+	}
+
+	return activity, logRows.Err()
+}
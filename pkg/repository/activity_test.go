@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestGetFeedActivity(t *testing.T) {
+	t.Parallel()
+	repo, _ := setupTestDB(t)
+	defer repo.Close()
+
+	ctx := context.Background()
+	busy, _ := repo.AddFeed(ctx, "https://example.com/busy", "Busy")
+	failing, _ := repo.AddFeed(ctx, "https://example.com/failing", "Failing")
+	idle, _ := repo.AddFeed(ctx, "https://example.com/idle", "Idle")
+
+	newest := time.Date(2025, 6, 1, 9, 0, 0, 0, time.UTC)
+	for i, id := range []string{"a", "b", "c"} {
+		published := newest.AddDate(0, -i, 0)
+		if err := repo.UpsertEntry(ctx, &Entry{FeedID: busy, EntryID: id, Title: id, Published: published, Updated: published, FirstSeen: published}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	base := time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC)
+	for i := range 4 {
+		if err := repo.RecordFetch(ctx, FetchLogEntry{FeedID: failing, FetchedAt: base.Add(time.Duration(i) * time.Hour), StatusCode: 400 + i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	activity, err := repo.GetFeedActivity(ctx, 3)
+	if err != nil {
+		t.Fatalf("GetFeedActivity() error = %v", err)
+	}
+
+	if a := activity[busy]; a == nil || a.Entries != 3 || !a.LastPublished.Equal(newest) || len(a.Fetches) != 0 {
+		t.Errorf("busy feed activity = %+v, want 3 entries, last published %v, no fetches", a, newest)
+	}
+	a := activity[failing]
+	if a == nil || a.Entries != 0 || !a.LastPublished.IsZero() {
+		t.Fatalf("failing feed activity = %+v, want no entries", a)
+	}
+	if len(a.Fetches) != 3 || a.Fetches[0].StatusCode != 403 || a.Fetches[2].StatusCode != 401 {
+		t.Errorf("failing feed fetches = %+v, want the newest 3, newest first", a.Fetches)
+	}
+	if _, ok := activity[idle]; ok {
+		t.Errorf("idle feed has activity %+v, want none", activity[idle])
+	}
+}
@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// ArchiveMonth counts the entries published in one month
+type ArchiveMonth struct {
+	Year    int
+	Month   time.Month
+	Entries int
+}
+
+// monthKey is the "2006-01" prefix of the published timestamps in a month
+func monthKey(year int, month time.Month) string {
+	return fmt.Sprintf("%04d-%02d", year, int(month))
+}
+
+// GetArchiveMonths returns the months in which entries of active feeds were
+// published, newest first. An entry's month is the one in UTC, in which
+// timestamps are stored.
+func (r *Repository) GetArchiveMonths(ctx context.Context) ([]ArchiveMonth, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT substr(e.published, 1, 7) AS month, COUNT(*)
+		FROM entries e
+		JOIN feeds f ON e.feed_id = f.id
+		WHERE f.active = 1 AND e.published >= '1970' AND `+notHidden+`
+		GROUP BY substr(e.published, 1, 7)
+		ORDER BY month DESC
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("query archive months: %w", err)
+	}
+	defer rows.Close()
+
+	var months []ArchiveMonth
+	for rows.Next() {
+		var key string
+		var m ArchiveMonth
+		if err := rows.Scan(&key, &m.Entries); err != nil {
+			return nil, fmt.Errorf("scan archive month: %w", err)
+		}
+		t, err := time.Parse("2006-01", key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid published month %q: %w", key, err)
+		}
+		m.Year, m.Month = t.Year(), t.Month()
+		months = append(months, m)
+	}
+	return months, rows.Err()
+}
+
+// GetMonthEntries returns the entries of active feeds published in a month,
+// newest first
+func (r *Repository) GetMonthEntries(ctx context.Context, year int, month time.Month) ([]Entry, error) {
+	next := time.Date(year, month+1, 1, 0, 0, 0, 0, time.UTC)
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+r.entryColumns()+`
+		FROM entries e
+		JOIN feeds f ON e.feed_id = f.id
+		WHERE f.active = 1 AND e.published >= ? AND e.published < ? AND `+notHidden+`
+		ORDER BY e.published DESC, `+entryTieBreaker,
+		monthKey(year, month), monthKey(next.Year(), next.Month()))
+	if err != nil {
+		return nil, fmt.Errorf("query month entries: %w", err)
+	}
+	defer rows.Close()
+
+	return scanEntries(rows)
+}
@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestArchiveMonths(t *testing.T) {
+	t.Parallel()
+	repo, _ := setupTestDB(t)
+	defer repo.Close()
+
+	ctx := context.Background()
+	feedID, _ := repo.AddFeed(ctx, "https://example.com/feed", "Test Feed")
+	otherID, _ := repo.AddFeed(ctx, "https://other.example.com/feed", "Other Feed")
+
+	add := func(feed int64, id string, published time.Time) {
+		t.Helper()
+		if err := repo.UpsertEntry(ctx, &Entry{FeedID: feed, EntryID: id, Title: id, Published: published, Updated: published, FirstSeen: published}); err != nil {
+			t.Fatalf("UpsertEntry() error = %v", err)
+		}
+	}
+	add(feedID, "may-1", time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC))
+	add(feedID, "may-31", time.Date(2024, 5, 31, 23, 0, 0, 0, time.UTC))
+	add(feedID, "dec", time.Date(2023, 12, 24, 12, 0, 0, 0, time.UTC))
+	// May in the entry's own time zone, but June in UTC
+	add(feedID, "june-utc", time.Date(2024, 5, 31, 23, 30, 0, 0, time.FixedZone("PDT", -7*3600)))
+	add(feedID, "undated", time.Time{})
+	add(otherID, "inactive", time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC))
+	if err := repo.DeactivateFeed(ctx, otherID); err != nil {
+		t.Fatal(err)
+	}
+
+	months, err := repo.GetArchiveMonths(ctx)
+	if err != nil {
+		t.Fatalf("GetArchiveMonths() error = %v", err)
+	}
+	want := []ArchiveMonth{{2024, time.June, 1}, {2024, time.May, 2}, {2023, time.December, 1}}
+	if len(months) != len(want) || months[0] != want[0] || months[1] != want[1] || months[2] != want[2] {
+		t.Errorf("GetArchiveMonths() = %v, want %v", months, want)
+	}
+
+	entries, err := repo.GetMonthEntries(ctx, 2024, time.May)
+	if err != nil {
+		t.Fatalf("GetMonthEntries() error = %v", err)
+	}
+	var ids []string
+	for _, e := range entries {
+		ids = append(ids, e.EntryID)
+	}
+	if len(ids) != 2 || ids[0] != "may-31" || ids[1] != "may-1" {
+		t.Errorf("GetMonthEntries(2024, May) = %v, want the two May entries, newest first", ids)
+	}
+
+	// December rolls over into the next year
+	if entries, err := repo.GetMonthEntries(ctx, 2023, time.December); err != nil || len(entries) != 1 {
+		t.Errorf("GetMonthEntries(2023, December) = %d entries, %v; want 1", len(entries), err)
+	}
+}
@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// querier is the part of *sql.DB and *sql.Tx the repository uses
+type querier interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// txn is a transaction for a single repository operation. Inside a Batch it
+// is a savepoint in the batch's transaction.
+type txn interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+	Commit() error
+	Rollback() error // A no-op error after Commit, so it can be deferred
+}
+
+// beginTx starts a transaction, or a savepoint when the repository is
+// already running inside a Batch
+func (r *Repository) beginTx(ctx context.Context) (txn, error) {
+	if r.tx == nil {
+		tx, err := r.conn.BeginTx(ctx, nil)
+		if err != nil {
+			return nil, err
+		}
+		return r.dialect.wrapTx(tx), nil
+	}
+	if _, err := r.tx.ExecContext(ctx, "SAVEPOINT op"); err != nil {
+		return nil, err
+	}
+	return r.dialect.wrapTx(&savepoint{Tx: r.tx, ctx: ctx}), nil
+}
+
+// savepoint is a nested transaction inside a Batch
+type savepoint struct {
+	*sql.Tx
+	ctx  context.Context
+	done bool
+}
+
+func (s *savepoint) Commit() error {
+	if s.done {
+		return sql.ErrTxDone
+	}
+	s.done = true
+	_, err := s.Tx.ExecContext(s.ctx, "RELEASE op")
+	return err
+}
+
+func (s *savepoint) Rollback() error {
+	if s.done {
+		return sql.ErrTxDone
+	}
+	s.done = true
+	if _, err := s.Tx.ExecContext(s.ctx, "ROLLBACK TO op"); err != nil {
+		return err
+	}
+	_, err := s.Tx.ExecContext(s.ctx, "RELEASE op")
+	return err
+}
+
+// Batch runs fn with a repository whose reads and writes all belong to one
+// transaction, committed if fn returns nil and rolled back otherwise.
+// Grouping many small writes this way saves the database a disk sync for each.
+// The repository passed to fn must only be used by one goroutine, and not
+// after fn returns. Inside a Batch, Batch simply calls fn.
+func (r *Repository) Batch(ctx context.Context, fn func(FeedRepository) error) error {
+	if r.tx != nil {
+		return fn(r)
+	}
+
+	tx, err := r.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin batch: %w", err)
+	}
+	batch := &Repository{db: r.dialect.wrap(tx, tx), tx: tx, quota: r.quota, history: r.history, revisions: r.revisions, dialect: r.dialect}
+	if err := fn(batch); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit batch: %w", err)
+	}
+	return nil
+}
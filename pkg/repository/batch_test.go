@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestBatch(t *testing.T) {
+	t.Parallel()
+	repo, _ := setupTestDB(t)
+	defer repo.Close()
+
+	ctx := context.Background()
+	feedID, _ := repo.AddFeed(ctx, "https://example.com/feed", "Feed")
+
+	// Committed: entries and categories, whose own transaction becomes a savepoint
+	err := repo.Batch(ctx, func(r FeedRepository) error {
+		for _, id := range []string{"a", "b"} {
+			if err := r.UpsertEntry(ctx, &Entry{FeedID: feedID, EntryID: id, Title: id, Categories: []string{"go"}}); err != nil {
+				return err
+			}
+		}
+		return r.UpdateFeedError(ctx, feedID, "boom")
+	})
+	if err != nil {
+		t.Fatalf("Batch() error = %v", err)
+	}
+	if count, _ := repo.CountEntries(ctx); count != 2 {
+		t.Errorf("CountEntries() = %d after commit, want 2", count)
+	}
+	var categories int
+	if err := repo.db.QueryRow("SELECT COUNT(*) FROM entry_categories").Scan(&categories); err != nil || categories != 2 {
+		t.Errorf("entry_categories rows = %d, %v; want 2", categories, err)
+	}
+
+	// Rolled back: nothing from a failed batch is kept
+	errStop := errors.New("stop")
+	err = repo.Batch(ctx, func(r FeedRepository) error {
+		if err := r.UpsertEntry(ctx, &Entry{FeedID: feedID, EntryID: "c", Title: "c"}); err != nil {
+			return err
+		}
+		if err := r.Batch(ctx, func(FeedRepository) error { return nil }); err != nil {
+			return err
+		}
+		return errStop
+	})
+	if !errors.Is(err, errStop) {
+		t.Fatalf("Batch() error = %v, want errStop", err)
+	}
+	if count, _ := repo.CountEntries(ctx); count != 2 {
+		t.Errorf("CountEntries() = %d after rollback, want 2", count)
+	}
+}
@@ -0,0 +1,132 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// normalizeCategories trims whitespace and surrounding slashes, drops empty
+// names, and removes duplicates while preserving order. Slashes inside a
+// category denote nesting, e.g. "Tech/Go".
+func normalizeCategories(categories []string) []string {
+	seen := make(map[string]bool, len(categories))
+	result := make([]string, 0, len(categories))
+	for _, c := range categories {
+		c = strings.Trim(strings.TrimSpace(c), "/")
+		if c == "" || seen[c] {
+			continue
+		}
+		seen[c] = true
+		result = append(result, c)
+	}
+	return result
+}
+
+// SetFeedCategories replaces the categories assigned to a feed
+func (r *Repository) SetFeedCategories(ctx context.Context, feedID int64, categories []string) error {
+	tx, err := r.beginTx(ctx)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }() // No-op after commit
+
+	if err := setFeedCategories(ctx, tx, feedID, categories); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit feed categories: %w", err)
+	}
+	return nil
+}
+
+// setFeedCategories replaces a feed's categories within tx
+func setFeedCategories(ctx context.Context, tx txn, feedID int64, categories []string) error {
+	if _, err := tx.ExecContext(ctx, "DELETE FROM feed_categories WHERE feed_id = ?", feedID); err != nil {
+		return fmt.Errorf("clear feed categories: %w", err)
+	}
+
+	for _, category := range normalizeCategories(categories) {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO feed_categories (feed_id, category) VALUES (?, ?)
+		`, feedID, category); err != nil {
+			return fmt.Errorf("insert feed category: %w", err)
+		}
+	}
+	return nil
+}
+
+// GetFeedCategories returns the categories assigned to a feed, sorted by name
+func (r *Repository) GetFeedCategories(ctx context.Context, feedID int64) ([]string, error) {
+	categories, err := r.queryCategories(ctx, "WHERE feed_id = ?", feedID)
+	if err != nil {
+		return nil, err
+	}
+	return categories[feedID], nil
+}
+
+// GetAllFeedCategories returns the categories of every feed keyed by feed ID.
+// Feeds without categories are absent from the map.
+func (r *Repository) GetAllFeedCategories(ctx context.Context) (map[int64][]string, error) {
+	return r.queryCategories(ctx, "")
+}
+
+func (r *Repository) queryCategories(ctx context.Context, where string, args ...interface{}) (map[int64][]string, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT feed_id, category
+		FROM feed_categories
+		`+where+`
+		ORDER BY feed_id, category
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query feed categories: %w", err)
+	}
+	defer rows.Close()
+
+	categories := make(map[int64][]string)
+	for rows.Next() {
+		var feedID int64
+		var category string
+		if err := rows.Scan(&feedID, &category); err != nil {
+			return nil, err
+		}
+		categories[feedID] = append(categories[feedID], category)
+	}
+
+	return categories, rows.Err()
+}
+
+// setEntryCategories replaces the stored categories of an upserted entry
+func (r *Repository) setEntryCategories(ctx context.Context, entry *Entry) error {
+	var id int64
+	err := r.db.QueryRowContext(ctx, `
+		SELECT id FROM entries WHERE feed_id = ? AND entry_id = ?
+	`, entry.FeedID, entry.EntryID).Scan(&id)
+	if err != nil {
+		return fmt.Errorf("look up entry: %w", err)
+	}
+
+	tx, err := r.beginTx(ctx)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }() // No-op after commit
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM entry_categories WHERE entry_id = ?", id); err != nil {
+		return fmt.Errorf("clear entry categories: %w", err)
+	}
+
+	for _, category := range normalizeCategories(entry.Categories) {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO entry_categories (entry_id, category) VALUES (?, ?)
+		`, id, category); err != nil {
+			return fmt.Errorf("insert entry category: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit entry categories: %w", err)
+	}
+	return nil
+}
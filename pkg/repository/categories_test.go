@@ -0,0 +1,143 @@
+package repository
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSetFeedCategories(t *testing.T) {
+	t.Parallel()
+	repo, _ := setupTestDB(t)
+	defer repo.Close()
+
+	ctx := context.Background()
+	feedID, _ := repo.AddFeed(ctx, "https://example.com/feed", "Feed")
+
+	err := repo.SetFeedCategories(ctx, feedID, []string{" Tech/Go ", "News", "", "/News/", "Tech/Go"})
+	if err != nil {
+		t.Fatalf("SetFeedCategories() error = %v", err)
+	}
+
+	got, err := repo.GetFeedCategories(ctx, feedID)
+	if err != nil {
+		t.Fatalf("GetFeedCategories() error = %v", err)
+	}
+	if want := []string{"News", "Tech/Go"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("GetFeedCategories() = %v, want %v", got, want)
+	}
+
+	// Setting again replaces rather than merges
+	if err := repo.SetFeedCategories(ctx, feedID, []string{"Community"}); err != nil {
+		t.Fatalf("SetFeedCategories() error = %v", err)
+	}
+	got, _ = repo.GetFeedCategories(ctx, feedID)
+	if want := []string{"Community"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("GetFeedCategories() after replace = %v, want %v", got, want)
+	}
+
+	if err := repo.SetFeedCategories(ctx, feedID, nil); err != nil {
+		t.Fatalf("SetFeedCategories(nil) error = %v", err)
+	}
+	got, _ = repo.GetFeedCategories(ctx, feedID)
+	if len(got) != 0 {
+		t.Errorf("GetFeedCategories() after clear = %v, want none", got)
+	}
+}
+
+func TestGetAllFeedCategories(t *testing.T) {
+	t.Parallel()
+	repo, _ := setupTestDB(t)
+	defer repo.Close()
+
+	ctx := context.Background()
+	feed1, _ := repo.AddFeed(ctx, "https://example.com/1", "One")
+	feed2, _ := repo.AddFeed(ctx, "https://example.com/2", "Two")
+	feed3, _ := repo.AddFeed(ctx, "https://example.com/3", "Three")
+
+	_ = repo.SetFeedCategories(ctx, feed1, []string{"Tech"})
+	_ = repo.SetFeedCategories(ctx, feed2, []string{"News", "Tech"})
+
+	all, err := repo.GetAllFeedCategories(ctx)
+	if err != nil {
+		t.Fatalf("GetAllFeedCategories() error = %v", err)
+	}
+
+	want := map[int64][]string{
+		feed1: {"Tech"},
+		feed2: {"News", "Tech"},
+	}
+	if !reflect.DeepEqual(all, want) {
+		t.Errorf("GetAllFeedCategories() = %v, want %v", all, want)
+	}
+	if _, ok := all[feed3]; ok {
+		t.Error("feed without categories should be absent from the map")
+	}
+
+	// Categories are removed with their feed
+	if err := repo.RemoveFeed(ctx, feed2); err != nil {
+		t.Fatalf("RemoveFeed() error = %v", err)
+	}
+	all, _ = repo.GetAllFeedCategories(ctx)
+	if _, ok := all[feed2]; ok {
+		t.Error("categories should be deleted when the feed is removed")
+	}
+}
+
+func TestEntryCategories(t *testing.T) {
+	t.Parallel()
+	repo, _ := setupTestDB(t)
+	defer repo.Close()
+
+	ctx := context.Background()
+	feedID, _ := repo.AddFeed(ctx, "https://example.com/feed", "Feed")
+	now := time.Now()
+
+	entry := &Entry{
+		FeedID:     feedID,
+		EntryID:    "entry-1",
+		Title:      "Tagged",
+		Published:  now,
+		Updated:    now,
+		FirstSeen:  now,
+		Categories: []string{"Releases", "Go", "Go"},
+	}
+	if err := repo.UpsertEntry(ctx, entry); err != nil {
+		t.Fatalf("UpsertEntry() error = %v", err)
+	}
+	untagged := &Entry{FeedID: feedID, EntryID: "entry-2", Title: "Untagged", Published: now, Updated: now, FirstSeen: now}
+	if err := repo.UpsertEntry(ctx, untagged); err != nil {
+		t.Fatalf("UpsertEntry() error = %v", err)
+	}
+
+	categoriesByID := func() map[string][]string {
+		t.Helper()
+		entries, err := repo.GetRecentEntriesWithOptions(ctx, 7, false, "published")
+		if err != nil {
+			t.Fatalf("GetRecentEntriesWithOptions() error = %v", err)
+		}
+		got := make(map[string][]string)
+		for _, e := range entries {
+			got[e.EntryID] = e.Categories
+		}
+		return got
+	}
+
+	got := categoriesByID()
+	if want := []string{"Go", "Releases"}; !reflect.DeepEqual(got["entry-1"], want) {
+		t.Errorf("entry-1 categories = %v, want %v", got["entry-1"], want)
+	}
+	if len(got["entry-2"]) != 0 {
+		t.Errorf("entry-2 categories = %v, want none", got["entry-2"])
+	}
+
+	// Updating an entry replaces its categories
+	entry.Categories = []string{"Community"}
+	if err := repo.UpsertEntry(ctx, entry); err != nil {
+		t.Fatalf("UpsertEntry() error = %v", err)
+	}
+	if got := categoriesByID(); !reflect.DeepEqual(got["entry-1"], []string{"Community"}) {
+		t.Errorf("entry-1 categories after update = %v, want [Community]", got["entry-1"])
+	}
+}
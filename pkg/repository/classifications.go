@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// GetClassifications returns the cached topics of the texts with the given
+// keys, keyed by key. Texts that have not been classified are missing from
+// the result.
+func (r *Repository) GetClassifications(ctx context.Context, keys []string) (map[string][]string, error) {
+	classifications := make(map[string][]string, len(keys))
+	// Stay well under SQLite's limit on bound parameters
+	const batch = 500
+	for start := 0; start < len(keys); start += batch {
+		ks := keys[start:min(start+batch, len(keys))]
+		args := make([]interface{}, 0, len(ks))
+		for _, k := range ks {
+			args = append(args, k)
+		}
+		placeholders := strings.TrimSuffix(strings.Repeat("?,", len(ks)), ",")
+
+		rows, err := r.db.QueryContext(ctx,
+			`SELECT source_hash, topics FROM classifications WHERE source_hash IN (`+placeholders+`)`, args...)
+		if err != nil {
+			return nil, fmt.Errorf("query classifications: %w", err)
+		}
+		for rows.Next() {
+			var key, topics string
+			if err := rows.Scan(&key, &topics); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("scan classification: %w", err)
+			}
+			var names []string
+			if err := json.Unmarshal([]byte(topics), &names); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("decode classification: %w", err)
+			}
+			classifications[key] = names
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, fmt.Errorf("iterate classifications: %w", err)
+		}
+	}
+	return classifications, nil
+}
+
+// SaveClassifications caches the topics of texts, keyed by the key of the
+// text
+func (r *Repository) SaveClassifications(ctx context.Context, classifications map[string][]string) error {
+	tx, err := r.beginTx(ctx)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }() // No-op after commit
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	for key, names := range classifications {
+		if names == nil {
+			names = []string{}
+		}
+		topics, err := json.Marshal(names)
+		if err != nil {
+			return fmt.Errorf("encode classification: %w", err)
+		}
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO classifications (source_hash, topics, created_at)
+			VALUES (?, ?, ?)
+			ON CONFLICT (source_hash) DO UPDATE SET topics = excluded.topics, created_at = excluded.created_at
+		`, key, string(topics), now)
+		if err != nil {
+			return fmt.Errorf("save classification: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit classifications: %w", err)
+	}
+	return nil
+}
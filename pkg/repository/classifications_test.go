@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestClassifications(t *testing.T) {
+	t.Parallel()
+	repo, _ := setupTestDB(t)
+	defer repo.Close()
+	ctx := context.Background()
+
+	got, err := repo.GetClassifications(ctx, []string{"a", "b"})
+	if err != nil {
+		t.Fatalf("GetClassifications() error = %v", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("GetClassifications() on an empty cache = %v, want none", got)
+	}
+
+	if err := repo.SaveClassifications(ctx, map[string][]string{"a": {"Go", "Databases"}, "b": nil}); err != nil {
+		t.Fatalf("SaveClassifications() error = %v", err)
+	}
+	// Saving again replaces the topics
+	if err := repo.SaveClassifications(ctx, map[string][]string{"a": {"Go"}}); err != nil {
+		t.Fatalf("SaveClassifications() error = %v", err)
+	}
+
+	got, err = repo.GetClassifications(ctx, []string{"a", "b", "c"})
+	if err != nil {
+		t.Fatalf("GetClassifications() error = %v", err)
+	}
+	// A text with no topics is cached as such
+	if want := map[string][]string{"a": {"Go"}, "b": {}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("GetClassifications() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,570 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+
+	_ "github.com/jackc/pgx/v5/stdlib" // Registers the "pgx" database/sql driver
+)
+
+// Database drivers for Open
+const (
+	DriverSQLite   = "sqlite"
+	DriverPostgres = "postgres"
+)
+
+// dialect describes a database the repository can store feeds in. Queries
+// are written for SQLite; the few constructs that differ elsewhere are
+// looked up here, and ? placeholders are rewritten for databases that
+// number them.
+type dialect struct {
+	name        string // DriverSQLite or DriverPostgres
+	sqlDriver   string // database/sql driver name
+	numbered    bool   // Placeholders are $1, $2, ... rather than ?
+	schema      string // Complete schema for a new database
+	revisions   string // The entry_revisions table, which the migration to version 26 adds
+	tablesQuery string // Counts how many of the feeds and entries tables exist
+	joinValues  string // Aggregate joining a column's values with categorySeparator; %s is the column
+}
+
+var sqliteDialect = &dialect{
+	name:        DriverSQLite,
+	sqlDriver:   "sqlite3",
+	schema:      sqliteSchema + sqliteRevisionsSchema,
+	revisions:   sqliteRevisionsSchema,
+	tablesQuery: "SELECT COUNT(*) FROM sqlite_master WHERE type='table' AND name IN ('feeds', 'entries')",
+	joinValues:  "group_concat(%s, char(31))",
+}
+
+var postgresDialect = &dialect{
+	name:        DriverPostgres,
+	sqlDriver:   "pgx",
+	numbered:    true,
+	schema:      postgresSchema + postgresRevisionsSchema,
+	revisions:   postgresRevisionsSchema,
+	tablesQuery: "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = current_schema() AND table_name IN ('feeds', 'entries')",
+	joinValues:  "string_agg(%s, chr(31))",
+}
+
+// dialectByName returns the dialect for a driver name. An empty name
+// selects SQLite.
+func dialectByName(name string) (*dialect, error) {
+	switch name {
+	case "", DriverSQLite:
+		return sqliteDialect, nil
+	case DriverPostgres:
+		return postgresDialect, nil
+	default:
+		return nil, fmt.Errorf("unknown database driver: %s (must be '%s' or '%s')", name, DriverSQLite, DriverPostgres)
+	}
+}
+
+// bind rewrites a query's placeholders for the dialect
+func (d *dialect) bind(query string) string {
+	if !d.numbered {
+		return query
+	}
+	return rebind(query)
+}
+
+// wrap adapts q, a connection pool or transaction, to the dialect. tx is the
+// Batch transaction q belongs to, if any.
+func (d *dialect) wrap(q querier, tx *sql.Tx) querier {
+	if !d.numbered {
+		return q
+	}
+	return numberedQuerier{q: q, tx: tx}
+}
+
+// wrapTx adapts a transaction returned by beginTx to the dialect
+func (d *dialect) wrapTx(t txn) txn {
+	if !d.numbered {
+		return t
+	}
+	return numberedTxn{t}
+}
+
+// rebind rewrites ? placeholders as $1, $2, ... Question marks inside quoted
+// strings are left alone.
+func rebind(query string) string {
+	var b strings.Builder
+	n := 0
+	quoted := false
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case c == '\'':
+			quoted = !quoted
+		case c == '?' && !quoted:
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// numberedQuerier runs queries with rewritten placeholders
+type numberedQuerier struct {
+	q  querier
+	tx *sql.Tx // The Batch transaction, if any
+}
+
+func (n numberedQuerier) Exec(query string, args ...any) (sql.Result, error) {
+	return n.ExecContext(context.Background(), query, args...)
+}
+
+// ExecContext runs a statement. Inside a Batch it runs in a savepoint:
+// PostgreSQL aborts the whole transaction when a statement fails, where
+// SQLite only fails the statement, and the fetcher keeps writing after an
+// error.
+func (n numberedQuerier) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	if n.tx == nil {
+		return n.q.ExecContext(ctx, rebind(query), args...)
+	}
+	if _, err := n.tx.ExecContext(ctx, "SAVEPOINT stmt"); err != nil {
+		return nil, err
+	}
+	result, err := n.tx.ExecContext(ctx, rebind(query), args...)
+	if err != nil {
+		_, _ = n.tx.ExecContext(ctx, "ROLLBACK TO stmt")
+	}
+	if _, relErr := n.tx.ExecContext(ctx, "RELEASE stmt"); relErr != nil && err == nil {
+		return nil, relErr
+	}
+	return result, err
+}
+
+func (n numberedQuerier) Query(query string, args ...any) (*sql.Rows, error) {
+	return n.q.Query(rebind(query), args...)
+}
+
+func (n numberedQuerier) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return n.q.QueryContext(ctx, rebind(query), args...)
+}
+
+func (n numberedQuerier) QueryRow(query string, args ...any) *sql.Row {
+	return n.q.QueryRow(rebind(query), args...)
+}
+
+func (n numberedQuerier) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	return n.q.QueryRowContext(ctx, rebind(query), args...)
+}
+
+// numberedTxn is a txn with rewritten placeholders
+type numberedTxn struct {
+	txn
+}
+
+func (n numberedTxn) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return n.txn.ExecContext(ctx, rebind(query), args...)
+}
+
+func (n numberedTxn) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return n.txn.PrepareContext(ctx, rebind(query))
+}
+
+// sqliteSchema is the complete schema for a new SQLite database
+const sqliteSchema = `
+	CREATE TABLE feeds (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		url TEXT NOT NULL UNIQUE,
+		title TEXT,
+		link TEXT,
+		updated TEXT,
+		last_fetched TEXT,
+		etag TEXT,
+		last_modified TEXT,
+		fetch_error TEXT,
+		fetch_error_count INTEGER DEFAULT 0,
+		next_fetch TEXT,
+		active INTEGER DEFAULT 1,
+		fetch_interval INTEGER DEFAULT 3600,
+		cache_expires TEXT,
+		gone_at TEXT,
+		custom_title INTEGER DEFAULT 0
+	);
+
+	CREATE TABLE entries (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		feed_id INTEGER NOT NULL,
+		entry_id TEXT NOT NULL,
+		title TEXT,
+		link TEXT,
+		author TEXT,
+		published TEXT,
+		updated TEXT,
+		content TEXT,
+		content_type TEXT DEFAULT 'html',
+		summary TEXT,
+		first_seen TEXT,
+		content_hash TEXT NOT NULL DEFAULT '',
+		updated_count INTEGER NOT NULL DEFAULT 0,
+		last_significant_update TEXT,
+		word_count INTEGER NOT NULL DEFAULT 0,
+		reading_minutes INTEGER NOT NULL DEFAULT 0,
+		image TEXT NOT NULL DEFAULT '',
+		authors TEXT NOT NULL DEFAULT '',
+		external_url TEXT NOT NULL DEFAULT '',
+		language TEXT NOT NULL DEFAULT '',
+		attachments TEXT NOT NULL DEFAULT '',
+		FOREIGN KEY (feed_id) REFERENCES feeds(id) ON DELETE CASCADE,
+		UNIQUE(feed_id, entry_id)
+	);
+
+	CREATE INDEX idx_entries_published ON entries(published DESC);
+	CREATE INDEX idx_entries_updated ON entries(updated DESC);
+	CREATE INDEX idx_entries_feed_id ON entries(feed_id);
+	CREATE INDEX idx_entries_first_seen ON entries(first_seen DESC);
+	CREATE INDEX idx_entries_content_hash ON entries(feed_id, content_hash);
+	CREATE INDEX idx_entries_last_significant_update ON entries(last_significant_update DESC);
+	CREATE INDEX idx_feeds_active ON feeds(active);
+	CREATE INDEX idx_feeds_next_fetch ON feeds(next_fetch);
+
+	CREATE TABLE fetch_log (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		feed_id INTEGER NOT NULL,
+		fetched_at TEXT NOT NULL,
+		status_code INTEGER DEFAULT 0,
+		headers TEXT,
+		error TEXT,
+		protocol TEXT,
+		content_encoding TEXT,
+		wire_bytes INTEGER DEFAULT 0,
+		decoded_bytes INTEGER DEFAULT 0,
+		duration_ms INTEGER DEFAULT 0,
+		entries_added INTEGER DEFAULT 0,
+		final_url TEXT,
+		FOREIGN KEY (feed_id) REFERENCES feeds(id) ON DELETE CASCADE
+	);
+
+	CREATE INDEX idx_fetch_log_feed_id ON fetch_log(feed_id, fetched_at DESC);
+
+	CREATE TABLE feed_categories (
+		feed_id INTEGER NOT NULL,
+		category TEXT NOT NULL,
+		FOREIGN KEY (feed_id) REFERENCES feeds(id) ON DELETE CASCADE,
+		PRIMARY KEY (feed_id, category)
+	);
+
+	CREATE TABLE entry_categories (
+		entry_id INTEGER NOT NULL,
+		category TEXT NOT NULL,
+		FOREIGN KEY (entry_id) REFERENCES entries(id) ON DELETE CASCADE,
+		PRIMARY KEY (entry_id, category)
+	);
+
+	CREATE TABLE host_backoff (
+		host TEXT PRIMARY KEY,
+		until TEXT NOT NULL,
+		status_code INTEGER DEFAULT 0,
+		failures INTEGER DEFAULT 0
+	);
+
+	CREATE TABLE fetch_queue (
+		feed_id INTEGER PRIMARY KEY,
+		FOREIGN KEY (feed_id) REFERENCES feeds(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE locks (
+		name TEXT PRIMARY KEY,
+		holder TEXT NOT NULL,
+		acquired_at TEXT NOT NULL,
+		expires_at TEXT NOT NULL
+	);
+
+	CREATE TABLE hidden_entries (
+		feed_id INTEGER NOT NULL,
+		entry_id TEXT NOT NULL,
+		title TEXT,
+		link TEXT,
+		hidden_at TEXT NOT NULL,
+		FOREIGN KEY (feed_id) REFERENCES feeds(id) ON DELETE CASCADE,
+		PRIMARY KEY (feed_id, entry_id)
+	);
+
+	CREATE TABLE pinned_entries (
+		feed_id INTEGER NOT NULL,
+		entry_id TEXT NOT NULL,
+		title TEXT,
+		link TEXT,
+		pinned_at TEXT NOT NULL,
+		FOREIGN KEY (feed_id) REFERENCES feeds(id) ON DELETE CASCADE,
+		PRIMARY KEY (feed_id, entry_id)
+	);
+
+	CREATE TABLE read_entries (
+		feed_id INTEGER NOT NULL,
+		entry_id TEXT NOT NULL,
+		title TEXT,
+		link TEXT,
+		read_at TEXT NOT NULL,
+		FOREIGN KEY (feed_id) REFERENCES feeds(id) ON DELETE CASCADE,
+		PRIMARY KEY (feed_id, entry_id)
+	);
+
+	CREATE TABLE starred_entries (
+		feed_id INTEGER NOT NULL,
+		entry_id TEXT NOT NULL,
+		title TEXT,
+		link TEXT,
+		starred_at TEXT NOT NULL,
+		FOREIGN KEY (feed_id) REFERENCES feeds(id) ON DELETE CASCADE,
+		PRIMARY KEY (feed_id, entry_id)
+	);
+
+	CREATE TABLE translations (
+		source_hash TEXT NOT NULL,
+		language TEXT NOT NULL,
+		text TEXT NOT NULL,
+		created_at TEXT NOT NULL,
+		PRIMARY KEY (source_hash, language)
+	);
+
+	CREATE TABLE classifications (
+		source_hash TEXT PRIMARY KEY,
+		topics TEXT NOT NULL,
+		created_at TEXT NOT NULL
+	);
+
+	CREATE TABLE related_entries (
+		entry_id INTEGER NOT NULL,
+		related_id INTEGER NOT NULL,
+		score REAL NOT NULL,
+		FOREIGN KEY (entry_id) REFERENCES entries(id) ON DELETE CASCADE,
+		FOREIGN KEY (related_id) REFERENCES entries(id) ON DELETE CASCADE,
+		PRIMARY KEY (entry_id, related_id)
+	);
+
+	CREATE INDEX idx_related_entries_related ON related_entries(related_id);
+
+	CREATE TABLE embeddings (
+		source_hash TEXT PRIMARY KEY,
+		vector TEXT NOT NULL,
+		created_at TEXT NOT NULL
+	);
+
+	`
+
+// postgresSchema is sqliteSchema for PostgreSQL. Timestamps stay RFC 3339
+// text, compared byte by byte as SQLite does, so both databases sort them
+// the same way.
+const postgresSchema = `
+	CREATE TABLE feeds (
+		id BIGSERIAL PRIMARY KEY,
+		url TEXT NOT NULL UNIQUE,
+		title TEXT,
+		link TEXT,
+		updated TEXT,
+		last_fetched TEXT,
+		etag TEXT,
+		last_modified TEXT,
+		fetch_error TEXT,
+		fetch_error_count INTEGER DEFAULT 0,
+		next_fetch TEXT COLLATE "C",
+		active INTEGER DEFAULT 1,
+		fetch_interval INTEGER DEFAULT 3600,
+		cache_expires TEXT,
+		gone_at TEXT,
+		custom_title INTEGER DEFAULT 0
+	);
+
+	CREATE TABLE entries (
+		id BIGSERIAL PRIMARY KEY,
+		feed_id BIGINT NOT NULL,
+		entry_id TEXT NOT NULL,
+		title TEXT,
+		link TEXT,
+		author TEXT,
+		published TEXT COLLATE "C",
+		updated TEXT COLLATE "C",
+		content TEXT,
+		content_type TEXT DEFAULT 'html',
+		summary TEXT,
+		first_seen TEXT COLLATE "C",
+		content_hash TEXT NOT NULL DEFAULT '',
+		updated_count INTEGER NOT NULL DEFAULT 0,
+		last_significant_update TEXT COLLATE "C",
+		word_count INTEGER NOT NULL DEFAULT 0,
+		reading_minutes INTEGER NOT NULL DEFAULT 0,
+		image TEXT NOT NULL DEFAULT '',
+		authors TEXT NOT NULL DEFAULT '',
+		external_url TEXT NOT NULL DEFAULT '',
+		language TEXT NOT NULL DEFAULT '',
+		attachments TEXT NOT NULL DEFAULT '',
+		FOREIGN KEY (feed_id) REFERENCES feeds(id) ON DELETE CASCADE,
+		UNIQUE(feed_id, entry_id)
+	);
+
+	CREATE INDEX idx_entries_published ON entries(published DESC);
+	CREATE INDEX idx_entries_updated ON entries(updated DESC);
+	CREATE INDEX idx_entries_feed_id ON entries(feed_id);
+	CREATE INDEX idx_entries_first_seen ON entries(first_seen DESC);
+	CREATE INDEX idx_entries_content_hash ON entries(feed_id, content_hash);
+	CREATE INDEX idx_entries_last_significant_update ON entries(last_significant_update DESC);
+	CREATE INDEX idx_feeds_active ON feeds(active);
+	CREATE INDEX idx_feeds_next_fetch ON feeds(next_fetch);
+
+	CREATE TABLE fetch_log (
+		id BIGSERIAL PRIMARY KEY,
+		feed_id BIGINT NOT NULL,
+		fetched_at TEXT COLLATE "C" NOT NULL,
+		status_code INTEGER DEFAULT 0,
+		headers TEXT,
+		error TEXT,
+		protocol TEXT,
+		content_encoding TEXT,
+		wire_bytes BIGINT DEFAULT 0,
+		decoded_bytes BIGINT DEFAULT 0,
+		duration_ms BIGINT DEFAULT 0,
+		entries_added INTEGER DEFAULT 0,
+		final_url TEXT,
+		FOREIGN KEY (feed_id) REFERENCES feeds(id) ON DELETE CASCADE
+	);
+
+	CREATE INDEX idx_fetch_log_feed_id ON fetch_log(feed_id, fetched_at DESC);
+
+	CREATE TABLE feed_categories (
+		feed_id BIGINT NOT NULL,
+		category TEXT NOT NULL,
+		FOREIGN KEY (feed_id) REFERENCES feeds(id) ON DELETE CASCADE,
+		PRIMARY KEY (feed_id, category)
+	);
+
+	CREATE TABLE entry_categories (
+		entry_id BIGINT NOT NULL,
+		category TEXT NOT NULL,
+		FOREIGN KEY (entry_id) REFERENCES entries(id) ON DELETE CASCADE,
+		PRIMARY KEY (entry_id, category)
+	);
+
+	CREATE TABLE host_backoff (
+		host TEXT PRIMARY KEY,
+		until TEXT NOT NULL,
+		status_code INTEGER DEFAULT 0,
+		failures INTEGER DEFAULT 0
+	);
+
+	CREATE TABLE fetch_queue (
+		feed_id BIGINT PRIMARY KEY,
+		FOREIGN KEY (feed_id) REFERENCES feeds(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE locks (
+		name TEXT PRIMARY KEY,
+		holder TEXT NOT NULL,
+		acquired_at TEXT COLLATE "C" NOT NULL,
+		expires_at TEXT COLLATE "C" NOT NULL
+	);
+
+	CREATE TABLE hidden_entries (
+		feed_id BIGINT NOT NULL,
+		entry_id TEXT NOT NULL,
+		title TEXT,
+		link TEXT,
+		hidden_at TEXT COLLATE "C" NOT NULL,
+		FOREIGN KEY (feed_id) REFERENCES feeds(id) ON DELETE CASCADE,
+		PRIMARY KEY (feed_id, entry_id)
+	);
+
+	CREATE TABLE pinned_entries (
+		feed_id BIGINT NOT NULL,
+		entry_id TEXT NOT NULL,
+		title TEXT,
+		link TEXT,
+		pinned_at TEXT COLLATE "C" NOT NULL,
+		FOREIGN KEY (feed_id) REFERENCES feeds(id) ON DELETE CASCADE,
+		PRIMARY KEY (feed_id, entry_id)
+	);
+
+	CREATE TABLE read_entries (
+		feed_id BIGINT NOT NULL,
+		entry_id TEXT NOT NULL,
+		title TEXT,
+		link TEXT,
+		read_at TEXT COLLATE "C" NOT NULL,
+		FOREIGN KEY (feed_id) REFERENCES feeds(id) ON DELETE CASCADE,
+		PRIMARY KEY (feed_id, entry_id)
+	);
+
+	CREATE TABLE starred_entries (
+		feed_id BIGINT NOT NULL,
+		entry_id TEXT NOT NULL,
+		title TEXT,
+		link TEXT,
+		starred_at TEXT COLLATE "C" NOT NULL,
+		FOREIGN KEY (feed_id) REFERENCES feeds(id) ON DELETE CASCADE,
+		PRIMARY KEY (feed_id, entry_id)
+	);
+
+	CREATE TABLE translations (
+		source_hash TEXT NOT NULL,
+		language TEXT NOT NULL,
+		text TEXT NOT NULL,
+		created_at TEXT COLLATE "C" NOT NULL,
+		PRIMARY KEY (source_hash, language)
+	);
+
+	CREATE TABLE classifications (
+		source_hash TEXT PRIMARY KEY,
+		topics TEXT NOT NULL,
+		created_at TEXT COLLATE "C" NOT NULL
+	);
+
+	CREATE TABLE related_entries (
+		entry_id BIGINT NOT NULL,
+		related_id BIGINT NOT NULL,
+		score DOUBLE PRECISION NOT NULL,
+		FOREIGN KEY (entry_id) REFERENCES entries(id) ON DELETE CASCADE,
+		FOREIGN KEY (related_id) REFERENCES entries(id) ON DELETE CASCADE,
+		PRIMARY KEY (entry_id, related_id)
+	);
+
+	CREATE INDEX idx_related_entries_related ON related_entries(related_id);
+
+	CREATE TABLE embeddings (
+		source_hash TEXT PRIMARY KEY,
+		vector TEXT NOT NULL,
+		created_at TEXT COLLATE "C" NOT NULL
+	);
+
+	`
+
+// sqliteRevisionsSchema is the entry_revisions table of sqliteSchema, kept
+// apart for the migration that adds it
+const sqliteRevisionsSchema = `
+	CREATE TABLE entry_revisions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		entry_id INTEGER NOT NULL,
+		title TEXT,
+		content TEXT,
+		content_hash TEXT NOT NULL DEFAULT '',
+		updated TEXT,
+		replaced_at TEXT NOT NULL,
+		FOREIGN KEY (entry_id) REFERENCES entries(id) ON DELETE CASCADE
+	);
+
+	CREATE INDEX idx_entry_revisions_entry ON entry_revisions(entry_id, id);
+	CREATE INDEX idx_entry_revisions_replaced_at ON entry_revisions(replaced_at);
+	`
+
+// postgresRevisionsSchema is sqliteRevisionsSchema for PostgreSQL
+const postgresRevisionsSchema = `
+	CREATE TABLE entry_revisions (
+		id BIGSERIAL PRIMARY KEY,
+		entry_id BIGINT NOT NULL,
+		title TEXT,
+		content TEXT,
+		content_hash TEXT NOT NULL DEFAULT '',
+		updated TEXT COLLATE "C",
+		replaced_at TEXT COLLATE "C" NOT NULL,
+		FOREIGN KEY (entry_id) REFERENCES entries(id) ON DELETE CASCADE
+	);
+
+	CREATE INDEX idx_entry_revisions_entry ON entry_revisions(entry_id, id);
+	CREATE INDEX idx_entry_revisions_replaced_at ON entry_revisions(replaced_at);
+	`
@@ -0,0 +1,51 @@
+package repository
+
+import "testing"
+
+func TestRebind(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"SELECT 1", "SELECT 1"},
+		{"SELECT * FROM feeds WHERE id = ?", "SELECT * FROM feeds WHERE id = $1"},
+		{"INSERT INTO t (a, b, c) VALUES (?, ?, ?)", "INSERT INTO t (a, b, c) VALUES ($1, $2, $3)"},
+		{"SELECT '?' FROM t WHERE a = ?", "SELECT '?' FROM t WHERE a = $1"},
+		{"SELECT 'it''s?' FROM t WHERE a IN (?,?)", "SELECT 'it''s?' FROM t WHERE a IN ($1,$2)"},
+	}
+	for _, tt := range tests {
+		if got := rebind(tt.query); got != tt.want {
+			t.Errorf("rebind(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestDialectByName(t *testing.T) {
+	t.Parallel()
+	for name, want := range map[string]*dialect{"": sqliteDialect, DriverSQLite: sqliteDialect, DriverPostgres: postgresDialect} {
+		if got, err := dialectByName(name); err != nil || got != want {
+			t.Errorf("dialectByName(%q) = %v, %v; want %s", name, got, err, want.name)
+		}
+	}
+	if _, err := dialectByName("mysql"); err == nil {
+		t.Error("dialectByName(mysql) succeeded")
+	}
+	if _, err := Open("mysql", "planet"); err == nil {
+		t.Error("Open(mysql) succeeded")
+	}
+}
+
+func TestSQLiteDialectUnchanged(t *testing.T) {
+	t.Parallel()
+	repo, _ := setupTestDB(t)
+	defer repo.Close()
+
+	// SQLite runs queries exactly as written
+	if _, ok := repo.db.(numberedQuerier); ok {
+		t.Error("SQLite repository rewrites placeholders")
+	}
+	if got := sqliteDialect.bind("SELECT ?"); got != "SELECT ?" {
+		t.Errorf("bind() = %q, want the query unchanged", got)
+	}
+}
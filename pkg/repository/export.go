@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// EntryFilter selects the entries ExportEntries reads. Its zero value
+// selects every entry.
+type EntryFilter struct {
+	FeedIDs []int64   // Only entries of these feeds; nil for entries of every feed
+	Since   time.Time // Only entries published at or after Since; zero for no limit
+	Until   time.Time // Only entries published before Until; zero for no limit
+}
+
+// ExportEntries calls fn with each stored entry filter selects, oldest
+// first, including entries of inactive feeds but not hidden entries. The
+// entries are read one at a time, so the whole corpus need not fit in
+// memory. It stops at the first error from fn and returns it.
+func (r *Repository) ExportEntries(ctx context.Context, filter EntryFilter, fn func(Entry) error) error {
+	conditions := []string{notHidden}
+	var args []interface{}
+	if filter.FeedIDs != nil {
+		if len(filter.FeedIDs) == 0 {
+			return nil
+		}
+		conditions = append(conditions, "e.feed_id IN ("+strings.TrimSuffix(strings.Repeat("?,", len(filter.FeedIDs)), ",")+")")
+		for _, id := range filter.FeedIDs {
+			args = append(args, id)
+		}
+	}
+	if !filter.Since.IsZero() {
+		conditions = append(conditions, "e.published >= ?")
+		args = append(args, filter.Since.UTC().Format(time.RFC3339))
+	}
+	if !filter.Until.IsZero() {
+		conditions = append(conditions, "e.published < ?")
+		args = append(args, filter.Until.UTC().Format(time.RFC3339))
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+r.entryColumns()+`
+		FROM entries e
+		WHERE `+strings.Join(conditions, " AND ")+`
+		ORDER BY e.published ASC, `+entryTieBreaker, args...)
+	if err != nil {
+		return fmt.Errorf("query entries: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		entry, err := scanEntry(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("iterate entries: %w", err)
+	}
+	return nil
+}
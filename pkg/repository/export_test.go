@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestExportEntries(t *testing.T) {
+	t.Parallel()
+	repo, _ := setupTestDB(t)
+	defer repo.Close()
+	ctx := context.Background()
+	feedA, _ := repo.AddFeed(ctx, "https://a.example.com/feed", "A")
+	feedB, _ := repo.AddFeed(ctx, "https://b.example.com/feed", "B")
+	if err := repo.DeactivateFeed(ctx, feedB); err != nil {
+		t.Fatal(err)
+	}
+
+	day := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	for _, e := range []Entry{
+		{FeedID: feedA, EntryID: "a3", Link: "https://a.example.com/3", Published: day.AddDate(0, 0, 2)},
+		{FeedID: feedA, EntryID: "a1", Link: "https://a.example.com/1", Published: day},
+		{FeedID: feedB, EntryID: "b2", Link: "https://b.example.com/2", Published: day.AddDate(0, 0, 1)},
+		{FeedID: feedA, EntryID: "hidden", Link: "https://a.example.com/private", Published: day},
+	} {
+		e.Updated, e.FirstSeen = e.Published, e.Published
+		if err := repo.UpsertEntry(ctx, &e); err != nil {
+			t.Fatalf("UpsertEntry() error = %v", err)
+		}
+	}
+	if _, err := repo.HideEntries(ctx, "https://a.example.com/private", day); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		filter EntryFilter
+		want   []string
+	}{
+		{"everything, oldest first", EntryFilter{}, []string{"a1", "b2", "a3"}},
+		{"one feed", EntryFilter{FeedIDs: []int64{feedB}}, []string{"b2"}},
+		{"no feeds", EntryFilter{FeedIDs: []int64{}}, nil},
+		{"since", EntryFilter{Since: day.AddDate(0, 0, 1)}, []string{"b2", "a3"}},
+		{"until", EntryFilter{Until: day.AddDate(0, 0, 1)}, []string{"a1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			err := repo.ExportEntries(ctx, tt.filter, func(e Entry) error {
+				got = append(got, e.EntryID)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("ExportEntries() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ExportEntries() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	stop := errors.New("stop")
+	calls := 0
+	err := repo.ExportEntries(ctx, EntryFilter{}, func(Entry) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Errorf("ExportEntries() = %v after %d calls, want fn's error after 1", err, calls)
+	}
+}
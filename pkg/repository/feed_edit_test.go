@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestEditFeed(t *testing.T) {
+	t.Parallel()
+	repo, _ := setupTestDB(t)
+	defer repo.Close()
+
+	ctx := context.Background()
+	id, _ := repo.AddFeed(ctx, "https://old.example.com/feed", "Old")
+	_ = repo.UpdateFeedCache(ctx, id, `"etag"`, "Mon, 01 Jan 2024 00:00:00 GMT", time.Now())
+	_ = repo.UpdateFeedError(ctx, id, "connection refused")
+	_ = repo.SetFeedCategories(ctx, id, []string{"News"})
+	if err := repo.UpsertEntry(ctx, &Entry{FeedID: id, EntryID: "1", Title: "Kept", Published: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+
+	newURL, title := "https://new.example.com/feed", "Custom"
+	if err := repo.EditFeed(ctx, id, FeedEdit{URL: &newURL, Title: &title, Categories: []string{"Go", "Tech"}}); err != nil {
+		t.Fatalf("EditFeed() error = %v", err)
+	}
+
+	feed, err := repo.GetFeedByID(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if feed.URL != newURL || feed.Title != title || !feed.CustomTitle {
+		t.Errorf("feed = %q %q custom %v, want %q %q custom", feed.URL, feed.Title, feed.CustomTitle, newURL, title)
+	}
+	if feed.ETag != "" || feed.LastModified != "" || feed.FetchError != "" || feed.FetchErrorCount != 0 {
+		t.Errorf("cache and errors of the old URL kept: %+v", feed)
+	}
+	if count, _ := repo.GetEntryCountForFeed(ctx, id); count != 1 {
+		t.Errorf("entries after rename = %d, want 1", count)
+	}
+	if got, _ := repo.GetFeedCategories(ctx, id); !reflect.DeepEqual(got, []string{"Go", "Tech"}) {
+		t.Errorf("categories = %v, want [Go Tech]", got)
+	}
+
+	// Fetching keeps the custom title, but updates the rest
+	if err := repo.UpdateFeed(ctx, id, "Feed's Own", "https://new.example.com/", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	feed, _ = repo.GetFeedByID(ctx, id)
+	if feed.Title != title || feed.Link != "https://new.example.com/" {
+		t.Errorf("after fetch: title %q link %q, want %q and the new link", feed.Title, feed.Link, title)
+	}
+
+	// An empty title hands the title back to the feed
+	empty, inactive := "", false
+	if err := repo.EditFeed(ctx, id, FeedEdit{Title: &empty, Active: &inactive}); err != nil {
+		t.Fatalf("EditFeed() error = %v", err)
+	}
+	_ = repo.UpdateFeed(ctx, id, "Feed's Own", "https://new.example.com/", time.Now())
+	feed, _ = repo.GetFeedByID(ctx, id)
+	if feed.Title != "Feed's Own" || feed.CustomTitle || feed.Active {
+		t.Errorf("after reset: title %q custom %v active %v, want the feed's own title, inactive", feed.Title, feed.CustomTitle, feed.Active)
+	}
+	if got, _ := repo.GetFeedCategories(ctx, id); len(got) != 2 {
+		t.Errorf("categories changed by an edit without them: %v", got)
+	}
+
+	if err := repo.EditFeed(ctx, id, FeedEdit{Categories: []string{}}); err != nil {
+		t.Fatalf("EditFeed() error = %v", err)
+	}
+	if got, _ := repo.GetFeedCategories(ctx, id); len(got) != 0 {
+		t.Errorf("categories = %v, want none", got)
+	}
+
+	if err := repo.EditFeed(ctx, id+100, FeedEdit{Title: &title}); !errors.Is(err, ErrFeedNotFound) {
+		t.Errorf("EditFeed() of a missing feed: error = %v, want ErrFeedNotFound", err)
+	}
+
+	other, _ := repo.AddFeed(ctx, "https://other.example.com/feed", "Other")
+	if err := repo.EditFeed(ctx, other, FeedEdit{URL: &newURL}); err == nil {
+		t.Error("EditFeed() to another feed's URL succeeded")
+	}
+}
@@ -0,0 +1,191 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// FetchLogRetention is the default number of fetch log records kept per feed
+const FetchLogRetention = 100
+
+// FetchLogEntry records the outcome of a single fetch attempt
+type FetchLogEntry struct {
+	ID         int64
+	FeedID     int64
+	FetchedAt  time.Time
+	StatusCode int               // 0 if no HTTP response was received
+	Headers    map[string]string // Diagnostic response headers
+	Error      string
+
+	// Transfer details, for fetches that returned a body
+	Proto           string // e.g. "HTTP/2.0"
+	ContentEncoding string // "br", "gzip", "deflate", or "" for none
+	WireBytes       int64  // Body bytes received
+	DecodedBytes    int64  // Body bytes after decompression
+
+	Duration     time.Duration // Time spent on the HTTP fetch, including retries
+	EntriesAdded int           // Entries stored for the first time
+	FinalURL     string        // Where redirects led, if the response came from elsewhere
+}
+
+// SetFetchLogRetention sets the number of fetch log records kept per feed.
+// Zero or less restores the default, FetchLogRetention.
+func (r *Repository) SetFetchLogRetention(n int) {
+	r.history = max(n, 0)
+}
+
+// fetchLogRetention returns the number of fetch log records kept per feed
+func (r *Repository) fetchLogRetention() int {
+	if r.history == 0 {
+		return FetchLogRetention
+	}
+	return r.history
+}
+
+// RecordFetch appends a fetch log record and trims old records for the feed
+func (r *Repository) RecordFetch(ctx context.Context, entry FetchLogEntry) error {
+	var headers sql.NullString
+	if len(entry.Headers) > 0 {
+		data, err := json.Marshal(entry.Headers)
+		if err != nil {
+			return fmt.Errorf("marshal headers: %w", err)
+		}
+		headers = sql.NullString{String: string(data), Valid: true}
+	}
+
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO fetch_log (feed_id, fetched_at, status_code, headers, error,
+			protocol, content_encoding, wire_bytes, decoded_bytes, duration_ms, entries_added, final_url)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, entry.FeedID, entry.FetchedAt.UTC().Format(time.RFC3339), entry.StatusCode, headers, entry.Error,
+		entry.Proto, entry.ContentEncoding, entry.WireBytes, entry.DecodedBytes,
+		entry.Duration.Milliseconds(), entry.EntriesAdded, entry.FinalURL)
+	if err != nil {
+		return fmt.Errorf("insert fetch log: %w", err)
+	}
+
+	// Retention: keep only the most recent records for this feed
+	_, err = r.db.ExecContext(ctx, `
+		DELETE FROM fetch_log
+		WHERE feed_id = ? AND id NOT IN (
+			SELECT id FROM fetch_log WHERE feed_id = ? ORDER BY id DESC LIMIT ?
+		)
+	`, entry.FeedID, entry.FeedID, r.fetchLogRetention())
+	if err != nil {
+		return fmt.Errorf("trim fetch log: %w", err)
+	}
+
+	return nil
+}
+
+// GetFetchLog returns up to limit fetch log records for a feed, newest first
+func (r *Repository) GetFetchLog(ctx context.Context, feedID int64, limit int) ([]FetchLogEntry, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+fetchLogColumns+`
+		FROM fetch_log
+		WHERE feed_id = ?
+		ORDER BY id DESC
+		LIMIT ?
+	`, feedID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("query fetch log: %w", err)
+	}
+	defer rows.Close()
+
+	var log []FetchLogEntry
+	for rows.Next() {
+		entry, err := scanFetchLogEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		log = append(log, entry)
+	}
+
+	return log, rows.Err()
+}
+
+// fetchLogColumns are the fetch_log columns scanFetchLogEntry reads, in order
+const fetchLogColumns = `id, feed_id, fetched_at, status_code, headers, error,
+			protocol, content_encoding, wire_bytes, decoded_bytes, duration_ms, entries_added, final_url`
+
+// scanFetchLogEntry reads a fetch log record selected as fetchLogColumns
+func scanFetchLogEntry(rows *sql.Rows) (FetchLogEntry, error) {
+	var entry FetchLogEntry
+	var fetchedAt string
+	var headers, errMsg, proto, encoding, finalURL sql.NullString
+	var durationMS int64
+
+	if err := rows.Scan(&entry.ID, &entry.FeedID, &fetchedAt, &entry.StatusCode, &headers, &errMsg,
+		&proto, &encoding, &entry.WireBytes, &entry.DecodedBytes, &durationMS, &entry.EntriesAdded, &finalURL); err != nil {
+		return FetchLogEntry{}, err
+	}
+	entry.Duration = time.Duration(durationMS) * time.Millisecond
+
+	var err error
+	entry.FetchedAt, err = time.Parse(time.RFC3339, fetchedAt)
+	if err != nil {
+		return FetchLogEntry{}, fmt.Errorf("invalid fetched_at timestamp %q: %w", fetchedAt, err)
+	}
+	entry.Error = nullString(errMsg)
+	entry.Proto = nullString(proto)
+	entry.ContentEncoding = nullString(encoding)
+	entry.FinalURL = nullString(finalURL)
+
+	if headers.Valid && headers.String != "" {
+		if err := json.Unmarshal([]byte(headers.String), &entry.Headers); err != nil {
+			return FetchLogEntry{}, fmt.Errorf("invalid headers for fetch log %d: %w", entry.ID, err)
+		}
+	}
+
+	return entry, nil
+}
+
+// TransferStats totals the bodies downloaded by the fetches in the fetch log
+type TransferStats struct {
+	Fetches      int            // Fetches that returned a body
+	WireBytes    int64          // Bytes received
+	DecodedBytes int64          // Bytes after decompression
+	ByEncoding   map[string]int // Fetches per content encoding ("" for none)
+	ByProto      map[string]int // Fetches per HTTP protocol version
+}
+
+// GetTransferStats totals the transfer details of fetches since a time.
+// Only each feed's most recent fetches are kept (see SetFetchLogRetention),
+// so older fetches are not counted.
+func (r *Repository) GetTransferStats(ctx context.Context, since time.Time) (TransferStats, error) {
+	stats := TransferStats{
+		ByEncoding: make(map[string]int),
+		ByProto:    make(map[string]int),
+	}
+	// fetched_at keeps its original time zone, so it is compared after parsing
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT fetched_at, COALESCE(protocol, ''), COALESCE(content_encoding, ''),
+			wire_bytes, decoded_bytes
+		FROM fetch_log
+		WHERE decoded_bytes > 0
+	`)
+	if err != nil {
+		return stats, fmt.Errorf("query transfer stats: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var fetchedAt, proto, encoding string
+		var wire, decoded int64
+		if err := rows.Scan(&fetchedAt, &proto, &encoding, &wire, &decoded); err != nil {
+			return stats, err
+		}
+		if t, err := time.Parse(time.RFC3339, fetchedAt); err != nil || t.Before(since) {
+			continue
+		}
+		stats.Fetches++
+		stats.WireBytes += wire
+		stats.DecodedBytes += decoded
+		stats.ByEncoding[encoding]++
+		stats.ByProto[proto]++
+	}
+	return stats, rows.Err()
+}
@@ -0,0 +1,205 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestRecordFetch(t *testing.T) {
+	t.Parallel()
+	repo, _ := setupTestDB(t)
+	defer repo.Close()
+
+	ctx := context.Background()
+	feedID, _ := repo.AddFeed(ctx, "https://example.com/feed", "Feed")
+
+	fetchedAt := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	err := repo.RecordFetch(ctx, FetchLogEntry{
+		FeedID:     feedID,
+		FetchedAt:  fetchedAt,
+		StatusCode: 200,
+		Headers: map[string]string{
+			"Server": "nginx",
+			"Cf-Ray": "8a1b2c3d4e5f-LHR",
+		},
+		Duration:     1250 * time.Millisecond,
+		EntriesAdded: 3,
+	})
+	if err != nil {
+		t.Fatalf("RecordFetch() error = %v", err)
+	}
+
+	err = repo.RecordFetch(ctx, FetchLogEntry{
+		FeedID:    feedID,
+		FetchedAt: fetchedAt.Add(time.Hour),
+		Error:     "connection refused",
+		FinalURL:  "https://example.net/feed",
+	})
+	if err != nil {
+		t.Fatalf("RecordFetch() error = %v", err)
+	}
+
+	log, err := repo.GetFetchLog(ctx, feedID, 10)
+	if err != nil {
+		t.Fatalf("GetFetchLog() error = %v", err)
+	}
+	if len(log) != 2 {
+		t.Fatalf("GetFetchLog() returned %d records, want 2", len(log))
+	}
+
+	// Newest first
+	if log[0].Error != "connection refused" || log[0].StatusCode != 0 {
+		t.Errorf("log[0] = %+v, want error record with no status", log[0])
+	}
+	if log[0].FinalURL != "https://example.net/feed" || log[1].FinalURL != "" {
+		t.Errorf("final URLs = %q, %q; want the redirect target and none", log[0].FinalURL, log[1].FinalURL)
+	}
+	if log[0].Headers != nil {
+		t.Errorf("log[0].Headers = %v, want nil", log[0].Headers)
+	}
+
+	if log[1].StatusCode != 200 {
+		t.Errorf("log[1].StatusCode = %d, want 200", log[1].StatusCode)
+	}
+	if !log[1].FetchedAt.Equal(fetchedAt) {
+		t.Errorf("log[1].FetchedAt = %v, want %v", log[1].FetchedAt, fetchedAt)
+	}
+	if log[1].Headers["Cf-Ray"] != "8a1b2c3d4e5f-LHR" || log[1].Headers["Server"] != "nginx" {
+		t.Errorf("log[1].Headers = %v", log[1].Headers)
+	}
+	if log[1].Duration != 1250*time.Millisecond || log[1].EntriesAdded != 3 {
+		t.Errorf("log[1] duration = %v, entries added = %d, want 1.25s and 3", log[1].Duration, log[1].EntriesAdded)
+	}
+}
+
+func TestRecordFetchRetention(t *testing.T) {
+	t.Parallel()
+	repo, _ := setupTestDB(t)
+	defer repo.Close()
+
+	ctx := context.Background()
+	feed1, _ := repo.AddFeed(ctx, "https://example.com/feed1", "Feed 1")
+	feed2, _ := repo.AddFeed(ctx, "https://example.com/feed2", "Feed 2")
+
+	base := time.Now().Truncate(time.Second)
+	for i := 0; i < FetchLogRetention+5; i++ {
+		err := repo.RecordFetch(ctx, FetchLogEntry{
+			FeedID:    feed1,
+			FetchedAt: base.Add(time.Duration(i) * time.Minute),
+			Error:     fmt.Sprintf("attempt %d", i),
+		})
+		if err != nil {
+			t.Fatalf("RecordFetch() error = %v", err)
+		}
+	}
+	repo.RecordFetch(ctx, FetchLogEntry{FeedID: feed2, FetchedAt: base, StatusCode: 304})
+
+	log, _ := repo.GetFetchLog(ctx, feed1, 100)
+	if len(log) != FetchLogRetention {
+		t.Fatalf("feed1 has %d records, want %d", len(log), FetchLogRetention)
+	}
+	if want := fmt.Sprintf("attempt %d", FetchLogRetention+4); log[0].Error != want {
+		t.Errorf("newest record = %q, want %q", log[0].Error, want)
+	}
+
+	// Trimming one feed must not touch another
+	if log, _ := repo.GetFetchLog(ctx, feed2, 100); len(log) != 1 {
+		t.Errorf("feed2 has %d records, want 1", len(log))
+	}
+}
+
+func TestSetFetchLogRetention(t *testing.T) {
+	t.Parallel()
+	repo, _ := setupTestDB(t)
+	defer repo.Close()
+
+	ctx := context.Background()
+	feedID, _ := repo.AddFeed(ctx, "https://example.com/feed", "Feed")
+	repo.SetFetchLogRetention(3)
+
+	// Records written in a batch are trimmed the same way
+	err := repo.Batch(ctx, func(batch FeedRepository) error {
+		for i := 0; i < 5; i++ {
+			if err := batch.RecordFetch(ctx, FetchLogEntry{FeedID: feedID, FetchedAt: time.Now(), StatusCode: 200}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Batch() error = %v", err)
+	}
+	if log, _ := repo.GetFetchLog(ctx, feedID, 100); len(log) != 3 {
+		t.Errorf("feed has %d records, want 3", len(log))
+	}
+}
+
+func TestFetchLogDeletedWithFeed(t *testing.T) {
+	t.Parallel()
+	repo, _ := setupTestDB(t)
+	defer repo.Close()
+
+	ctx := context.Background()
+	feedID, _ := repo.AddFeed(ctx, "https://example.com/feed", "Feed")
+	repo.RecordFetch(ctx, FetchLogEntry{FeedID: feedID, FetchedAt: time.Now(), StatusCode: 200})
+
+	if err := repo.RemoveFeed(ctx, feedID); err != nil {
+		t.Fatalf("RemoveFeed() error = %v", err)
+	}
+
+	var count int
+	if err := repo.db.QueryRow("SELECT COUNT(*) FROM fetch_log").Scan(&count); err != nil {
+		t.Fatalf("count fetch_log: %v", err)
+	}
+	if count != 0 {
+		t.Errorf("fetch_log has %d records after feed removal, want 0", count)
+	}
+}
+
+func TestGetTransferStats(t *testing.T) {
+	t.Parallel()
+	repo, _ := setupTestDB(t)
+	defer repo.Close()
+
+	ctx := context.Background()
+	feedID, _ := repo.AddFeed(ctx, "https://example.com/feed", "Feed")
+	now := time.Now()
+
+	entries := []FetchLogEntry{
+		{FetchedAt: now.Add(-time.Hour), StatusCode: 200, Proto: "HTTP/2.0", ContentEncoding: "br", WireBytes: 1000, DecodedBytes: 5000},
+		{FetchedAt: now.Add(-2 * time.Hour), StatusCode: 200, Proto: "HTTP/1.1", ContentEncoding: "gzip", WireBytes: 2000, DecodedBytes: 6000},
+		{FetchedAt: now.Add(-3 * time.Hour), StatusCode: 200, Proto: "HTTP/1.1", WireBytes: 500, DecodedBytes: 500},
+		{FetchedAt: now.Add(-30 * time.Minute), StatusCode: 304, Proto: "HTTP/2.0"},           // No body
+		{FetchedAt: now.Add(-48 * time.Hour), StatusCode: 200, WireBytes: 9, DecodedBytes: 9}, // Too old
+	}
+	for _, e := range entries {
+		e.FeedID = feedID
+		if err := repo.RecordFetch(ctx, e); err != nil {
+			t.Fatalf("RecordFetch() error = %v", err)
+		}
+	}
+
+	log, err := repo.GetFetchLog(ctx, feedID, 10)
+	if err != nil {
+		t.Fatalf("GetFetchLog() error = %v", err)
+	}
+	if first := log[len(log)-1]; first.Proto != "HTTP/2.0" || first.ContentEncoding != "br" || first.WireBytes != 1000 || first.DecodedBytes != 5000 {
+		t.Errorf("transfer details not stored: %+v", first)
+	}
+
+	stats, err := repo.GetTransferStats(ctx, now.Add(-24*time.Hour))
+	if err != nil {
+		t.Fatalf("GetTransferStats() error = %v", err)
+	}
+	if stats.Fetches != 3 || stats.WireBytes != 3500 || stats.DecodedBytes != 11500 {
+		t.Errorf("stats = %+v, want 3 fetches, 3500 wire bytes, 11500 decoded", stats)
+	}
+	if stats.ByEncoding["br"] != 1 || stats.ByEncoding["gzip"] != 1 || stats.ByEncoding[""] != 1 {
+		t.Errorf("ByEncoding = %v", stats.ByEncoding)
+	}
+	if stats.ByProto["HTTP/2.0"] != 1 || stats.ByProto["HTTP/1.1"] != 2 {
+		t.Errorf("ByProto = %v", stats.ByProto)
+	}
+}
@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+)
+
+// GetFetchQueue returns the IDs of the feeds an interrupted fetch run did
+// not reach, in ID order
+func (r *Repository) GetFetchQueue(ctx context.Context) ([]int64, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT feed_id FROM fetch_queue ORDER BY feed_id`)
+	if err != nil {
+		return nil, fmt.Errorf("query fetch queue: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("scan fetch queue: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// SetFetchQueue replaces the feeds waiting to be fetched by 'rp update
+// --resume'. An empty list clears the queue.
+func (r *Repository) SetFetchQueue(ctx context.Context, ids []int64) error {
+	tx, err := r.beginTx(ctx)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }() // No-op after commit
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM fetch_queue`); err != nil {
+		return fmt.Errorf("clear fetch queue: %w", err)
+	}
+	for _, id := range ids {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO fetch_queue (feed_id)
+			SELECT id FROM feeds WHERE id = ?
+			ON CONFLICT (feed_id) DO NOTHING
+		`, id)
+		if err != nil {
+			return fmt.Errorf("queue feed %d: %w", id, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit fetch queue: %w", err)
+	}
+	return nil
+}
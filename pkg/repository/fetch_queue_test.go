@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+func TestFetchQueue(t *testing.T) {
+	t.Parallel()
+	repo, _ := setupTestDB(t)
+	defer repo.Close()
+
+	ctx := context.Background()
+	var ids []int64
+	for _, url := range []string{"https://a.example.com/feed", "https://b.example.com/feed", "https://c.example.com/feed"} {
+		id, err := repo.AddFeed(ctx, url, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+
+	if queue, err := repo.GetFetchQueue(ctx); err != nil || len(queue) != 0 {
+		t.Fatalf("GetFetchQueue() on empty table = %v, %v; want none", queue, err)
+	}
+
+	// Duplicates and feeds that no longer exist are ignored
+	if err := repo.SetFetchQueue(ctx, []int64{ids[2], ids[0], ids[2], 9999}); err != nil {
+		t.Fatalf("SetFetchQueue() error = %v", err)
+	}
+	queue, err := repo.GetFetchQueue(ctx)
+	if err != nil {
+		t.Fatalf("GetFetchQueue() error = %v", err)
+	}
+	if want := []int64{ids[0], ids[2]}; !slices.Equal(queue, want) {
+		t.Errorf("GetFetchQueue() = %v, want %v", queue, want)
+	}
+
+	// Removing a feed takes it out of the queue
+	if err := repo.RemoveFeed(ctx, ids[0]); err != nil {
+		t.Fatal(err)
+	}
+	if queue, _ := repo.GetFetchQueue(ctx); !slices.Equal(queue, []int64{ids[2]}) {
+		t.Errorf("GetFetchQueue() after removing a feed = %v, want [%d]", queue, ids[2])
+	}
+
+	if err := repo.SetFetchQueue(ctx, nil); err != nil {
+		t.Fatalf("SetFetchQueue(nil) error = %v", err)
+	}
+	if queue, _ := repo.GetFetchQueue(ctx); len(queue) != 0 {
+		t.Errorf("GetFetchQueue() after clearing = %v, want none", queue)
+	}
+}
@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// HostBackoff records that a host asked us to stop fetching from it for a
+// while, with 429 Too Many Requests or 503 Service Unavailable
+type HostBackoff struct {
+	Host       string    // Host name, with port if not the default
+	Until      time.Time // No feeds on the host are fetched before this time
+	StatusCode int       // Status of the response that caused the backoff
+	Failures   int       // Consecutive rate-limited responses from the host
+}
+
+// GetHostBackoff returns the backoff recorded for a host, expired or not, or
+// nil if there is none
+func (r *Repository) GetHostBackoff(ctx context.Context, host string) (*HostBackoff, error) {
+	var b HostBackoff
+	var until string
+	err := r.db.QueryRowContext(ctx, `
+		SELECT host, until, status_code, failures
+		FROM host_backoff
+		WHERE host = ?
+	`, host).Scan(&b.Host, &until, &b.StatusCode, &b.Failures)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query host backoff: %w", err)
+	}
+
+	b.Until, err = time.Parse(time.RFC3339, until)
+	if err != nil {
+		return nil, fmt.Errorf("invalid until timestamp %q: %w", until, err)
+	}
+	return &b, nil
+}
+
+// SetHostBackoff records or replaces the backoff for a host
+func (r *Repository) SetHostBackoff(ctx context.Context, b HostBackoff) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO host_backoff (host, until, status_code, failures)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(host) DO UPDATE SET
+			until = excluded.until,
+			status_code = excluded.status_code,
+			failures = excluded.failures
+	`, b.Host, b.Until.UTC().Format(time.RFC3339), b.StatusCode, b.Failures)
+	if err != nil {
+		return fmt.Errorf("set host backoff: %w", err)
+	}
+	return nil
+}
+
+// ClearHostBackoff forgets the backoff for a host, once it is answering again
+func (r *Repository) ClearHostBackoff(ctx context.Context, host string) error {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM host_backoff WHERE host = ?`, host); err != nil {
+		return fmt.Errorf("clear host backoff: %w", err)
+	}
+	return nil
+}
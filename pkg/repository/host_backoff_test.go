@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestHostBackoff(t *testing.T) {
+	t.Parallel()
+	repo, _ := setupTestDB(t)
+	defer repo.Close()
+
+	ctx := context.Background()
+
+	b, err := repo.GetHostBackoff(ctx, "example.com")
+	if err != nil || b != nil {
+		t.Fatalf("GetHostBackoff() on empty table = %+v, %v; want nil, nil", b, err)
+	}
+
+	until := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	if err := repo.SetHostBackoff(ctx, HostBackoff{Host: "example.com", Until: until, StatusCode: 429, Failures: 1}); err != nil {
+		t.Fatalf("SetHostBackoff() error = %v", err)
+	}
+	// Replacing keeps a single row per host
+	if err := repo.SetHostBackoff(ctx, HostBackoff{Host: "example.com", Until: until.Add(time.Hour), StatusCode: 503, Failures: 2}); err != nil {
+		t.Fatalf("SetHostBackoff() error = %v", err)
+	}
+
+	b, err = repo.GetHostBackoff(ctx, "example.com")
+	if err != nil {
+		t.Fatalf("GetHostBackoff() error = %v", err)
+	}
+	want := HostBackoff{Host: "example.com", Until: until.Add(time.Hour), StatusCode: 503, Failures: 2}
+	if b == nil || !b.Until.Equal(want.Until) || b.StatusCode != want.StatusCode || b.Failures != want.Failures {
+		t.Errorf("GetHostBackoff() = %+v, want %+v", b, want)
+	}
+
+	if err := repo.ClearHostBackoff(ctx, "example.com"); err != nil {
+		t.Fatalf("ClearHostBackoff() error = %v", err)
+	}
+	if b, _ := repo.GetHostBackoff(ctx, "example.com"); b != nil {
+		t.Errorf("GetHostBackoff() after clear = %+v, want nil", b)
+	}
+}
@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+)
+
+// CheckIntegrity runs SQLite's integrity check and returns the problems it
+// reports, or nil if the database is sound. PostgreSQL keeps its own
+// integrity, so there is nothing to check.
+func (r *Repository) CheckIntegrity(ctx context.Context) ([]string, error) {
+	if r.dialect != sqliteDialect {
+		return nil, nil
+	}
+
+	rows, err := r.db.QueryContext(ctx, "PRAGMA integrity_check")
+	if err != nil {
+		return nil, fmt.Errorf("integrity check: %w", err)
+	}
+	defer rows.Close()
+
+	var problems []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return nil, fmt.Errorf("integrity check: %w", err)
+		}
+		if line != "ok" {
+			problems = append(problems, line)
+		}
+	}
+	return problems, rows.Err()
+}
+
+// Orphans counts rows whose feed or entry no longer exists. They are left
+// behind when rows are deleted with foreign keys off, as older versions did
+// on all but one pooled connection.
+type Orphans struct {
+	Entries         int64 // Entries of removed feeds
+	EntryCategories int64 // Categories of removed entries
+	FeedCategories  int64 // Categories of removed feeds
+	FetchLog        int64 // Fetch log records of removed feeds
+}
+
+// Total is the number of orphaned rows
+func (o Orphans) Total() int64 {
+	return o.Entries + o.EntryCategories + o.FeedCategories + o.FetchLog
+}
+
+// orphanQueries select the orphaned rows of each table, in deletion order
+var orphanQueries = []struct {
+	table string
+	where string
+	count func(*Orphans) *int64
+}{
+	{"entries", "feed_id NOT IN (SELECT id FROM feeds)", func(o *Orphans) *int64 { return &o.Entries }},
+	{"entry_categories", "entry_id NOT IN (SELECT id FROM entries)", func(o *Orphans) *int64 { return &o.EntryCategories }},
+	{"feed_categories", "feed_id NOT IN (SELECT id FROM feeds)", func(o *Orphans) *int64 { return &o.FeedCategories }},
+	{"fetch_log", "feed_id NOT IN (SELECT id FROM feeds)", func(o *Orphans) *int64 { return &o.FetchLog }},
+}
+
+// CountOrphans counts the rows that belong to feeds or entries that no
+// longer exist
+func (r *Repository) CountOrphans(ctx context.Context) (Orphans, error) {
+	var o Orphans
+	for _, q := range orphanQueries {
+		err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+q.table+" WHERE "+q.where).Scan(q.count(&o))
+		if err != nil {
+			return o, fmt.Errorf("count orphaned %s: %w", q.table, err)
+		}
+	}
+	return o, nil
+}
+
+// DeleteOrphans deletes the rows counted by CountOrphans and returns how
+// many were deleted. Categories of orphaned entries go with them.
+func (r *Repository) DeleteOrphans(ctx context.Context) (Orphans, error) {
+	var o Orphans
+	tx, err := r.beginTx(ctx)
+	if err != nil {
+		return o, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }() // No-op after commit
+
+	for _, q := range orphanQueries {
+		result, err := tx.ExecContext(ctx, "DELETE FROM "+q.table+" WHERE "+q.where)
+		if err != nil {
+			return Orphans{}, fmt.Errorf("delete orphaned %s: %w", q.table, err)
+		}
+		if *q.count(&o), err = result.RowsAffected(); err != nil {
+			return Orphans{}, fmt.Errorf("delete orphaned %s: %w", q.table, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return Orphans{}, fmt.Errorf("commit orphan deletion: %w", err)
+	}
+	return o, nil
+}
@@ -33,15 +33,66 @@ type FeedRepository interface {
 	// UpdateFeedCache updates the feed's HTTP cache headers
 	UpdateFeedCache(ctx context.Context, id int64, etag, lastModified string, lastFetched time.Time) error
 
+	// UpdateFeedCacheExpiry records when the feed's HTTP cache lifetime ends (zero clears it)
+	UpdateFeedCacheExpiry(ctx context.Context, id int64, expires time.Time) error
+
+	// UpdateFeedSchedule records the feed's fetch interval and next due time
+	UpdateFeedSchedule(ctx context.Context, id int64, interval time.Duration, nextFetch time.Time) error
+
+	// GetEntryTimes returns publication times of a feed's most recent entries, newest first
+	GetEntryTimes(ctx context.Context, feedID int64, limit int) ([]time.Time, error)
+
 	// UpdateFeedError records a fetch error for a feed
 	UpdateFeedError(ctx context.Context, id int64, errorMsg string) error
 
+	// UpdateFeedNextFetch sets when the feed is next due, keeping its fetch interval
+	UpdateFeedNextFetch(ctx context.Context, id int64, nextFetch time.Time) error
+
+	// DeactivateFeed marks a feed inactive so it is no longer fetched
+	DeactivateFeed(ctx context.Context, id int64) error
+
+	// MarkFeedGone records that a feed is permanently gone and deactivates it
+	MarkFeedGone(ctx context.Context, id int64, at time.Time) error
+
+	// RecordFetch appends a fetch attempt to the feed's fetch log
+	RecordFetch(ctx context.Context, entry FetchLogEntry) error
+
+	// GetHostBackoff returns the backoff recorded for a host, or nil if there is none
+	GetHostBackoff(ctx context.Context, host string) (*HostBackoff, error)
+
+	// SetHostBackoff records that a host asked us to back off
+	SetHostBackoff(ctx context.Context, b HostBackoff) error
+
+	// ClearHostBackoff forgets a host's backoff
+	ClearHostBackoff(ctx context.Context, host string) error
+
+	// GetFetchLog returns up to limit fetch log records for a feed, newest first
+	GetFetchLog(ctx context.Context, feedID int64, limit int) ([]FetchLogEntry, error)
+
 	// RemoveFeed removes a feed and its entries from the database
 	RemoveFeed(ctx context.Context, id int64) error
 
 	// UpsertEntry inserts or updates an entry (deduplicates by feed_id + entry_id)
 	UpsertEntry(ctx context.Context, entry *Entry) error
 
+	// QuotaCutoff tells which new entries of a feed the entry quotas would evict at once
+	QuotaCutoff(ctx context.Context, feedID int64) (QuotaCutoff, error)
+
+	// EnforceQuota evicts entries over the entry quotas, once a feed's entries are stored
+	EnforceQuota(ctx context.Context, feedID int64) (int64, error)
+
+	// GetStoredEntryIDs reports which of a feed's entry IDs are already stored
+	GetStoredEntryIDs(ctx context.Context, feedID int64, entryIDs []string) (map[string]bool, error)
+
+	// GetEntryContents returns the stored content of a feed's entries, keyed by entry ID
+	GetEntryContents(ctx context.Context, feedID int64, entryIDs []string) (map[string]string, error)
+
+	// FindEntryByContent returns the ID of a feed's stored entry with the given link and content hash, or ""
+	FindEntryByContent(ctx context.Context, feedID int64, link, contentHash string) (string, error)
+
+	// RekeyEntry changes a stored entry's ID, for an entry republished under a new ID
+	RekeyEntry(ctx context.Context, feedID int64, oldID, newID string) error
+
 	// GetRecentEntries retrieves entries from the last N days
 	GetRecentEntries(ctx context.Context, days int) ([]Entry, error)
 
@@ -60,6 +111,9 @@ type FeedRepository interface {
 	// PruneOldEntries deletes entries older than N days and returns the count of deleted entries
 	PruneOldEntries(ctx context.Context, days int) (int64, error)
 
+	// Batch runs fn with a repository whose operations share one transaction
+	Batch(ctx context.Context, fn func(FeedRepository) error) error
+
 	// Close closes the database connection
 	Close() error
 }
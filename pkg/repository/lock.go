@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrLocked is returned by AcquireLock when another holder has the lock
+var ErrLocked = errors.New("lock held by another process")
+
+// Lock is a named lease on the database, used to keep separate rp
+// processes from running the same job at once. A lock that is not renewed
+// expires, so one left behind by a crashed process does not block others
+// for long.
+type Lock struct {
+	Name       string
+	Holder     string    // Who holds it, e.g. host name and process ID
+	AcquiredAt time.Time // When the holder took it
+	ExpiresAt  time.Time // When others may take it unless it is renewed
+}
+
+// AcquireLock takes the named lock for holder until ttl from now. It fails
+// with ErrLocked if someone else holds a lock that has not expired; the
+// holder may take its own lock again.
+func (r *Repository) AcquireLock(ctx context.Context, name, holder string, ttl time.Duration) error {
+	now := time.Now().UTC()
+	result, err := r.db.ExecContext(ctx, `
+		INSERT INTO locks (name, holder, acquired_at, expires_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(name) DO UPDATE SET
+			holder = excluded.holder,
+			acquired_at = excluded.acquired_at,
+			expires_at = excluded.expires_at
+		WHERE locks.expires_at <= excluded.acquired_at OR locks.holder = excluded.holder
+	`, name, holder, now.Format(time.RFC3339), now.Add(ttl).Format(time.RFC3339))
+	if err != nil {
+		return fmt.Errorf("acquire lock %s: %w", name, err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("acquire lock %s: %w", name, err)
+	}
+	if n == 0 {
+		return ErrLocked
+	}
+	return nil
+}
+
+// RenewLock extends holder's lock to ttl from now. It fails with ErrLocked
+// if the lock expired and someone else has taken it.
+func (r *Repository) RenewLock(ctx context.Context, name, holder string, ttl time.Duration) error {
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE locks SET expires_at = ? WHERE name = ? AND holder = ?
+	`, time.Now().UTC().Add(ttl).Format(time.RFC3339), name, holder)
+	if err != nil {
+		return fmt.Errorf("renew lock %s: %w", name, err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("renew lock %s: %w", name, err)
+	}
+	if n == 0 {
+		return ErrLocked
+	}
+	return nil
+}
+
+// ReleaseLock gives up holder's lock. Releasing a lock held by someone else
+// does nothing.
+func (r *Repository) ReleaseLock(ctx context.Context, name, holder string) error {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM locks WHERE name = ? AND holder = ?`, name, holder); err != nil {
+		return fmt.Errorf("release lock %s: %w", name, err)
+	}
+	return nil
+}
+
+// GetLock returns the named lock, expired or not, or nil if nobody holds it
+func (r *Repository) GetLock(ctx context.Context, name string) (*Lock, error) {
+	l := Lock{Name: name}
+	var acquired, expires string
+	err := r.db.QueryRowContext(ctx, `
+		SELECT holder, acquired_at, expires_at FROM locks WHERE name = ?
+	`, name).Scan(&l.Holder, &acquired, &expires)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query lock %s: %w", name, err)
+	}
+
+	if l.AcquiredAt, err = time.Parse(time.RFC3339, acquired); err != nil {
+		return nil, fmt.Errorf("invalid acquired_at timestamp %q: %w", acquired, err)
+	}
+	if l.ExpiresAt, err = time.Parse(time.RFC3339, expires); err != nil {
+		return nil, fmt.Errorf("invalid expires_at timestamp %q: %w", expires, err)
+	}
+	return &l, nil
+}
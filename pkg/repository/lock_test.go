@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLock(t *testing.T) {
+	t.Parallel()
+	repo, _ := setupTestDB(t)
+	defer repo.Close()
+
+	ctx := context.Background()
+
+	if l, err := repo.GetLock(ctx, "update"); err != nil || l != nil {
+		t.Fatalf("GetLock() on empty table = %+v, %v; want nil, nil", l, err)
+	}
+
+	if err := repo.AcquireLock(ctx, "update", "a", time.Minute); err != nil {
+		t.Fatalf("AcquireLock() error = %v", err)
+	}
+	if err := repo.AcquireLock(ctx, "update", "b", time.Minute); !errors.Is(err, ErrLocked) {
+		t.Errorf("AcquireLock() of a held lock error = %v, want ErrLocked", err)
+	}
+	if err := repo.AcquireLock(ctx, "update", "a", time.Minute); err != nil {
+		t.Errorf("AcquireLock() by the holder error = %v", err)
+	}
+	if err := repo.AcquireLock(ctx, "other", "b", time.Minute); err != nil {
+		t.Errorf("AcquireLock() of another lock error = %v", err)
+	}
+
+	l, err := repo.GetLock(ctx, "update")
+	if err != nil || l == nil || l.Holder != "a" || !l.ExpiresAt.After(time.Now()) {
+		t.Fatalf("GetLock() = %+v, %v; want an unexpired lock held by a", l, err)
+	}
+
+	if err := repo.RenewLock(ctx, "update", "b", time.Minute); !errors.Is(err, ErrLocked) {
+		t.Errorf("RenewLock() by another process error = %v, want ErrLocked", err)
+	}
+	if err := repo.ReleaseLock(ctx, "update", "b"); err != nil {
+		t.Fatalf("ReleaseLock() error = %v", err)
+	}
+	if l, _ := repo.GetLock(ctx, "update"); l == nil {
+		t.Error("ReleaseLock() by another process released the lock")
+	}
+
+	// An expired lock can be taken over
+	if err := repo.RenewLock(ctx, "update", "a", -time.Second); err != nil {
+		t.Fatalf("RenewLock() error = %v", err)
+	}
+	if err := repo.AcquireLock(ctx, "update", "b", time.Minute); err != nil {
+		t.Errorf("AcquireLock() of an expired lock error = %v", err)
+	}
+	if err := repo.RenewLock(ctx, "update", "a", time.Minute); !errors.Is(err, ErrLocked) {
+		t.Errorf("RenewLock() of a lost lock error = %v, want ErrLocked", err)
+	}
+
+	if err := repo.ReleaseLock(ctx, "update", "b"); err != nil {
+		t.Fatalf("ReleaseLock() error = %v", err)
+	}
+	if l, err := repo.GetLock(ctx, "update"); err != nil || l != nil {
+		t.Errorf("GetLock() after release = %+v, %v; want nil, nil", l, err)
+	}
+}
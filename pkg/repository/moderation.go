@@ -0,0 +1,185 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// MarkedEntry is an entry an operator has hidden from the generated site or
+// pinned to the top of it, or a reader has read or starred. Its title and
+// link are kept from when it was marked, so it stays marked, and listed, if
+// the entry is pruned and fetched again.
+type MarkedEntry struct {
+	FeedID  int64
+	FeedURL string
+	EntryID string
+	Title   string
+	Link    string
+	At      time.Time // When the entry was marked
+}
+
+// Tables of marked entries, with the column recording when each was marked
+const (
+	hiddenEntries  = "hidden_entries"
+	pinnedEntries  = "pinned_entries"
+	readEntries    = "read_entries"
+	starredEntries = "starred_entries"
+)
+
+// markedAt names the time column of a table of marked entries
+var markedAt = map[string]string{
+	hiddenEntries:  "hidden_at",
+	pinnedEntries:  "pinned_at",
+	readEntries:    "read_at",
+	starredEntries: "starred_at",
+}
+
+// notHidden keeps hidden entries out of queries over entries e
+const notHidden = `NOT EXISTS (SELECT 1 FROM hidden_entries h WHERE h.feed_id = e.feed_id AND h.entry_id = e.entry_id)`
+
+// FindEntries returns the stored entries whose link or entry ID is ref,
+// newest first. The same post may be stored once for each feed carrying it.
+func (r *Repository) FindEntries(ctx context.Context, ref string) ([]Entry, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+r.entryColumns()+`
+		FROM entries e
+		WHERE e.link = ? OR e.entry_id = ?
+		ORDER BY e.published DESC, `+entryTieBreaker,
+		ref, ref)
+	if err != nil {
+		return nil, fmt.Errorf("find entries: %w", err)
+	}
+	defer rows.Close()
+
+	return scanEntries(rows)
+}
+
+// HideEntries hides the entries whose link or entry ID is ref from
+// generation and returns them. It returns ErrEntryNotFound if none are stored.
+func (r *Repository) HideEntries(ctx context.Context, ref string, at time.Time) ([]Entry, error) {
+	return r.markFound(ctx, hiddenEntries, ref, at)
+}
+
+// UnhideEntries shows the hidden entries whose link or entry ID is ref again,
+// and returns how many there were
+func (r *Repository) UnhideEntries(ctx context.Context, ref string) (int64, error) {
+	return r.unmarkEntries(ctx, hiddenEntries, ref)
+}
+
+// GetHiddenEntries returns the hidden entries, most recently hidden first
+func (r *Repository) GetHiddenEntries(ctx context.Context) ([]MarkedEntry, error) {
+	return r.markedEntries(ctx, hiddenEntries)
+}
+
+// PinEntry pins the newest stored entry whose link or entry ID is ref to the
+// top of the site, and returns it. Only one copy of a post carried by
+// several feeds is pinned. It returns ErrEntryNotFound if none is stored.
+func (r *Repository) PinEntry(ctx context.Context, ref string, at time.Time) (*Entry, error) {
+	entries, err := r.FindEntries(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, ErrEntryNotFound
+	}
+	if err := r.markEntries(ctx, pinnedEntries, entries[:1], at); err != nil {
+		return nil, err
+	}
+	return &entries[0], nil
+}
+
+// UnpinEntries unpins the entries whose link or entry ID is ref, and returns
+// how many there were
+func (r *Repository) UnpinEntries(ctx context.Context, ref string) (int64, error) {
+	return r.unmarkEntries(ctx, pinnedEntries, ref)
+}
+
+// GetPinnedEntries returns the pinned entries, most recently pinned first,
+// including those no longer stored
+func (r *Repository) GetPinnedEntries(ctx context.Context) ([]MarkedEntry, error) {
+	return r.markedEntries(ctx, pinnedEntries)
+}
+
+// GetFeaturedEntries returns the stored pinned entries of active feeds that
+// are not hidden, most recently pinned first
+func (r *Repository) GetFeaturedEntries(ctx context.Context) ([]Entry, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+r.entryColumns()+`
+		FROM pinned_entries p
+		JOIN entries e ON e.feed_id = p.feed_id AND e.entry_id = p.entry_id
+		JOIN feeds f ON e.feed_id = f.id
+		WHERE f.active = 1 AND `+notHidden+`
+		ORDER BY p.pinned_at DESC, `+entryTieBreaker)
+	if err != nil {
+		return nil, fmt.Errorf("query featured entries: %w", err)
+	}
+	defer rows.Close()
+
+	return scanEntries(rows)
+}
+
+// markEntries records entries in a table of marked entries. Entries marked
+// already keep their original time.
+func (r *Repository) markEntries(ctx context.Context, table string, entries []Entry, at time.Time) error {
+	tx, err := r.beginTx(ctx)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }() // No-op after commit
+
+	for _, e := range entries {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO `+table+` (feed_id, entry_id, title, link, `+markedAt[table]+`)
+			VALUES (?, ?, ?, ?, ?)
+			ON CONFLICT (feed_id, entry_id) DO NOTHING
+		`, e.FeedID, e.EntryID, e.Title, e.Link, at.UTC().Format(time.RFC3339))
+		if err != nil {
+			return fmt.Errorf("mark entry %s in %s: %w", e.EntryID, table, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit %s: %w", table, err)
+	}
+	return nil
+}
+
+// unmarkEntries removes the entries whose link or entry ID is ref from a
+// table of marked entries, and returns how many there were
+func (r *Repository) unmarkEntries(ctx context.Context, table, ref string) (int64, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM `+table+` WHERE link = ? OR entry_id = ?`, ref, ref)
+	if err != nil {
+		return 0, fmt.Errorf("delete from %s: %w", table, err)
+	}
+	return result.RowsAffected()
+}
+
+// markedEntries lists a table of marked entries, most recently marked first
+func (r *Repository) markedEntries(ctx context.Context, table string) ([]MarkedEntry, error) {
+	at := markedAt[table]
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT m.feed_id, f.url, m.entry_id, m.title, m.link, m.`+at+`
+		FROM `+table+` m
+		JOIN feeds f ON m.feed_id = f.id
+		ORDER BY m.`+at+` DESC, m.feed_id ASC, m.entry_id ASC
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("query %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	var marked []MarkedEntry
+	for rows.Next() {
+		var m MarkedEntry
+		var stamp string
+		if err := rows.Scan(&m.FeedID, &m.FeedURL, &m.EntryID, &m.Title, &m.Link, &stamp); err != nil {
+			return nil, fmt.Errorf("scan %s: %w", table, err)
+		}
+		if m.At, err = time.Parse(time.RFC3339, stamp); err != nil {
+			return nil, fmt.Errorf("invalid %s timestamp %q for entry %s: %w", at, stamp, m.EntryID, err)
+		}
+		marked = append(marked, m)
+	}
+	return marked, rows.Err()
+}
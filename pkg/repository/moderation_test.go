@@ -0,0 +1,164 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestHideEntries(t *testing.T) {
+	t.Parallel()
+	repo, _ := setupTestDB(t)
+	defer repo.Close()
+
+	ctx := context.Background()
+	feedID, _ := repo.AddFeed(ctx, "https://example.com/feed", "Test Feed")
+	otherID, _ := repo.AddFeed(ctx, "https://other.example.com/feed", "Other Feed")
+
+	now := time.Now().UTC().Truncate(time.Second)
+	add := func(feed int64, id, link string) {
+		t.Helper()
+		if err := repo.UpsertEntry(ctx, &Entry{FeedID: feed, EntryID: id, Title: id, Link: link, Published: now, Updated: now, FirstSeen: now}); err != nil {
+			t.Fatalf("UpsertEntry() error = %v", err)
+		}
+	}
+	add(feedID, "private", "https://example.com/private")
+	add(otherID, "syndicated", "https://example.com/private")
+	add(feedID, "public", "https://example.com/public")
+
+	visible := func() []string {
+		t.Helper()
+		entries, err := repo.GetRecentEntriesWithOptions(ctx, 7, false, "published")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		for _, e := range entries {
+			ids = append(ids, e.EntryID)
+		}
+		return ids
+	}
+
+	// A link hides every feed's copy of the post
+	hidden, err := repo.HideEntries(ctx, "https://example.com/private", now)
+	if err != nil {
+		t.Fatalf("HideEntries() error = %v", err)
+	}
+	if len(hidden) != 2 {
+		t.Errorf("HideEntries() hid %d entries, want 2", len(hidden))
+	}
+	if ids := visible(); len(ids) != 1 || ids[0] != "public" {
+		t.Errorf("visible entries = %v, want [public]", ids)
+	}
+	if months, _ := repo.GetArchiveMonths(ctx); len(months) != 1 || months[0].Entries != 1 {
+		t.Errorf("GetArchiveMonths() = %+v, want hidden entries left out", months)
+	}
+
+	// Hiding again changes nothing; unknown entries are an error
+	if _, err := repo.HideEntries(ctx, "private", now.Add(time.Hour)); err != nil {
+		t.Errorf("HideEntries() again error = %v", err)
+	}
+	if _, err := repo.HideEntries(ctx, "https://example.com/nope", now); !errors.Is(err, ErrEntryNotFound) {
+		t.Errorf("HideEntries() of an unknown entry error = %v, want ErrEntryNotFound", err)
+	}
+
+	list, err := repo.GetHiddenEntries(ctx)
+	if err != nil {
+		t.Fatalf("GetHiddenEntries() error = %v", err)
+	}
+	if len(list) != 2 || list[0].FeedURL != "https://example.com/feed" || !list[0].At.Equal(now) || list[1].EntryID != "syndicated" {
+		t.Errorf("GetHiddenEntries() = %+v", list)
+	}
+
+	// Hidden entries stay hidden when fetched again
+	add(feedID, "private", "https://example.com/private")
+	if ids := visible(); len(ids) != 1 {
+		t.Errorf("visible entries after refetch = %v", ids)
+	}
+
+	n, err := repo.UnhideEntries(ctx, "syndicated")
+	if err != nil || n != 1 {
+		t.Fatalf("UnhideEntries() = %d, %v; want 1", n, err)
+	}
+	if ids := visible(); len(ids) != 2 {
+		t.Errorf("visible entries after unhiding = %v, want 2", ids)
+	}
+
+	// Removing a feed forgets its hidden entries
+	if err := repo.RemoveFeed(ctx, feedID); err != nil {
+		t.Fatal(err)
+	}
+	if list, _ := repo.GetHiddenEntries(ctx); len(list) != 0 {
+		t.Errorf("GetHiddenEntries() after removing the feed = %+v", list)
+	}
+}
+
+func TestPinEntry(t *testing.T) {
+	t.Parallel()
+	repo, _ := setupTestDB(t)
+	defer repo.Close()
+
+	ctx := context.Background()
+	feedID, _ := repo.AddFeed(ctx, "https://example.com/feed", "Test Feed")
+	otherID, _ := repo.AddFeed(ctx, "https://other.example.com/feed", "Other Feed")
+
+	old := time.Now().UTC().AddDate(-1, 0, 0).Truncate(time.Second)
+	add := func(feed int64, id, link string, published time.Time) {
+		t.Helper()
+		if err := repo.UpsertEntry(ctx, &Entry{FeedID: feed, EntryID: id, Title: id, Link: link, Published: published, Updated: published, FirstSeen: published}); err != nil {
+			t.Fatalf("UpsertEntry() error = %v", err)
+		}
+	}
+	add(feedID, "classic", "https://example.com/classic", old)
+	add(otherID, "classic-copy", "https://example.com/classic", old.Add(-time.Hour))
+	add(feedID, "recent", "https://example.com/recent", time.Now())
+
+	pinned, err := repo.PinEntry(ctx, "https://example.com/classic", old)
+	if err != nil {
+		t.Fatalf("PinEntry() error = %v", err)
+	}
+	if pinned.EntryID != "classic" {
+		t.Errorf("PinEntry() pinned %s, want the newest copy", pinned.EntryID)
+	}
+	if _, err := repo.PinEntry(ctx, "recent", old.Add(time.Hour)); err != nil {
+		t.Fatalf("PinEntry() error = %v", err)
+	}
+	if _, err := repo.PinEntry(ctx, "nope", old); !errors.Is(err, ErrEntryNotFound) {
+		t.Errorf("PinEntry() of an unknown entry error = %v, want ErrEntryNotFound", err)
+	}
+
+	featured := func() []string {
+		t.Helper()
+		entries, err := repo.GetFeaturedEntries(ctx)
+		if err != nil {
+			t.Fatalf("GetFeaturedEntries() error = %v", err)
+		}
+		var ids []string
+		for _, e := range entries {
+			ids = append(ids, e.EntryID)
+		}
+		return ids
+	}
+	if ids := featured(); len(ids) != 2 || ids[0] != "recent" || ids[1] != "classic" {
+		t.Errorf("GetFeaturedEntries() = %v, want most recently pinned first", ids)
+	}
+
+	// Hidden entries are not featured, though they stay pinned
+	if _, err := repo.HideEntries(ctx, "recent", old); err != nil {
+		t.Fatal(err)
+	}
+	if ids := featured(); len(ids) != 1 || ids[0] != "classic" {
+		t.Errorf("GetFeaturedEntries() with a hidden entry = %v", ids)
+	}
+	if list, _ := repo.GetPinnedEntries(ctx); len(list) != 2 {
+		t.Errorf("GetPinnedEntries() = %+v, want both", list)
+	}
+
+	if n, err := repo.UnpinEntries(ctx, "https://example.com/classic"); err != nil || n != 1 {
+		t.Errorf("UnpinEntries() = %d, %v; want 1", n, err)
+	}
+	if ids := featured(); len(ids) != 0 {
+		t.Errorf("GetFeaturedEntries() after unpinning = %v", ids)
+	}
+}
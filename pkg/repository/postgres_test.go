@@ -0,0 +1,117 @@
+//go:build postgres
+// +build postgres
+
+package repository
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+)
+
+// These tests need a PostgreSQL database they may create tables in, and drop
+// them from. Run with:
+// ROGUE_PLANET_POSTGRES_DSN=postgres://... go test -tags=postgres ./pkg/repository
+
+func setupPostgres(t *testing.T) *Repository {
+	t.Helper()
+	dsn := os.Getenv("ROGUE_PLANET_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("ROGUE_PLANET_POSTGRES_DSN not set")
+	}
+
+	dropTables := func(r *Repository) {
+		_, err := r.db.Exec(`DROP TABLE IF EXISTS entry_categories, feed_categories, fetch_log, fetch_queue, host_backoff, locks, entries, feeds, schema_version`)
+		if err != nil {
+			t.Fatalf("drop tables: %v", err)
+		}
+	}
+
+	repo, err := Open(DriverPostgres, dsn)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	dropTables(repo)
+	repo.Close()
+
+	// Open again so the schema is created from scratch
+	repo, err = Open(DriverPostgres, dsn)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	t.Cleanup(func() {
+		dropTables(repo)
+		repo.Close()
+	})
+	return repo
+}
+
+func TestPostgres(t *testing.T) {
+	repo := setupPostgres(t)
+	ctx := context.Background()
+
+	feedID, err := repo.AddFeed(ctx, "https://example.com/feed", "Feed")
+	if err != nil {
+		t.Fatalf("AddFeed() error = %v", err)
+	}
+	if _, err := repo.AddFeed(ctx, "https://example.com/feed", "Again"); err == nil {
+		t.Error("AddFeed() accepted a duplicate URL")
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	for i, id := range []string{"a", "b"} {
+		entry := &Entry{
+			FeedID: feedID, EntryID: id, Title: id, Content: "<p>" + id + "</p>",
+			Published: now.Add(-time.Duration(i) * time.Hour), Updated: now, FirstSeen: now,
+			Categories: []string{"go", "databases"},
+		}
+		if err := repo.UpsertEntry(ctx, entry); err != nil {
+			t.Fatalf("UpsertEntry() error = %v", err)
+		}
+	}
+
+	entries, err := repo.GetRecentEntries(ctx, 7)
+	if err != nil {
+		t.Fatalf("GetRecentEntries() error = %v", err)
+	}
+	if len(entries) != 2 || entries[0].EntryID != "a" {
+		t.Fatalf("GetRecentEntries() = %+v, want a then b", entries)
+	}
+	if got := entries[0].Categories; len(got) != 2 || got[0] != "databases" || got[1] != "go" {
+		t.Errorf("Categories = %v, want [databases go]", got)
+	}
+	if times, err := repo.GetEntryTimes(ctx, feedID, 10); err != nil || len(times) != 2 {
+		t.Errorf("GetEntryTimes() = %v, %v; want 2 times", times, err)
+	}
+
+	if err := repo.SetHostBackoff(ctx, HostBackoff{Host: "example.com", Until: now, StatusCode: 429, Failures: 1}); err != nil {
+		t.Fatalf("SetHostBackoff() error = %v", err)
+	}
+	if err := repo.SetHostBackoff(ctx, HostBackoff{Host: "example.com", Until: now, StatusCode: 503, Failures: 2}); err != nil {
+		t.Fatalf("SetHostBackoff() update error = %v", err)
+	}
+	if b, err := repo.GetHostBackoff(ctx, "example.com"); err != nil || b.Failures != 2 {
+		t.Errorf("GetHostBackoff() = %+v, %v; want 2 failures", b, err)
+	}
+
+	// A failed statement in a batch does not lose the rest of it
+	otherID, _ := repo.AddFeed(ctx, "https://example.com/other", "Other")
+	err = repo.Batch(ctx, func(r FeedRepository) error {
+		if err := r.UpdateFeedURL(ctx, otherID, "https://example.com/feed"); err == nil {
+			t.Error("UpdateFeedURL() to a taken URL succeeded")
+		}
+		return r.UpdateFeedError(ctx, otherID, "boom")
+	})
+	if err != nil {
+		t.Fatalf("Batch() error = %v", err)
+	}
+	if feed, err := repo.GetFeedByID(ctx, otherID); err != nil || feed.FetchError != "boom" {
+		t.Errorf("feed after batch = %+v, %v; want fetch error boom", feed, err)
+	}
+
+	settings, err := repo.Settings(ctx)
+	if err != nil || settings.Driver != DriverPostgres {
+		t.Errorf("Settings() = %+v, %v", settings, err)
+	}
+}
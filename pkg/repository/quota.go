@@ -0,0 +1,313 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+	"unicode/utf8"
+)
+
+// evictable matches the entries quotas may evict, those neither pinned nor
+// starred, of entries aliased e
+const evictable = `NOT EXISTS (SELECT 1 FROM pinned_entries p WHERE p.feed_id = e.feed_id AND p.entry_id = e.entry_id)
+	AND NOT EXISTS (SELECT 1 FROM starred_entries s WHERE s.feed_id = e.feed_id AND s.entry_id = e.entry_id)`
+
+// Eviction policy names accepted by EvictionPolicyByName (and config.ini)
+const (
+	EvictOldestFirst      = "oldest_first"
+	EvictLowestScoreFirst = "lowest_score_first"
+)
+
+// Quota bounds how many entries the repository keeps.
+// Limits are soft: they are enforced by EnforceQuota, which the fetcher calls
+// once a feed's entries are stored, so the database may briefly hold more
+// entries than the limit. Zero means unlimited. Pinned and starred entries
+// are kept regardless and do not count against the limits.
+type Quota struct {
+	MaxEntriesPerFeed int
+	MaxTotalEntries   int
+	Policy            EvictionPolicy // Defaults to OldestFirst if nil
+}
+
+// EvictionCandidate is the lightweight view of an entry used to rank evictions.
+// Content is not loaded; only its length is available to scoring functions.
+type EvictionCandidate struct {
+	ID            int64
+	FeedID        int64
+	Title         string
+	Published     time.Time
+	FirstSeen     time.Time
+	ContentLength int
+}
+
+// EvictionPolicy decides which entries are removed first when a quota is exceeded.
+// Implementations sort candidates in place so that the entries to evict come first.
+type EvictionPolicy interface {
+	Rank(candidates []EvictionCandidate)
+}
+
+// OldestFirst evicts entries with the oldest published date first.
+type OldestFirst struct{}
+
+// Rank sorts candidates by published date ascending, breaking ties by ID.
+func (OldestFirst) Rank(candidates []EvictionCandidate) {
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if !candidates[i].Published.Equal(candidates[j].Published) {
+			return candidates[i].Published.Before(candidates[j].Published)
+		}
+		return candidates[i].ID < candidates[j].ID
+	})
+}
+
+// LowestScoreFirst evicts entries with the lowest score first.
+// Score is pluggable; if nil, DefaultEntryScore is used.
+type LowestScoreFirst struct {
+	Score func(EvictionCandidate) float64
+}
+
+// Rank sorts candidates by score ascending, breaking ties oldest first.
+func (p LowestScoreFirst) Rank(candidates []EvictionCandidate) {
+	score := p.Score
+	if score == nil {
+		score = DefaultEntryScore
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		si, sj := score(candidates[i]), score(candidates[j])
+		if si != sj {
+			return si < sj
+		}
+		if !candidates[i].Published.Equal(candidates[j].Published) {
+			return candidates[i].Published.Before(candidates[j].Published)
+		}
+		return candidates[i].ID < candidates[j].ID
+	})
+}
+
+// DefaultEntryScore scores an entry by age in days plus a small bonus for content.
+// Newer entries score higher; between entries from the same day, the one with
+// more content is kept, so title-only stubs are evicted before full posts.
+func DefaultEntryScore(c EvictionCandidate) float64 {
+	days := float64(c.Published.Unix()) / 86400
+	return days + math.Log10(1+float64(c.ContentLength))/10
+}
+
+// EvictionPolicyByName returns the policy for a config name.
+// An empty name selects oldest_first.
+func EvictionPolicyByName(name string) (EvictionPolicy, error) {
+	switch name {
+	case "", EvictOldestFirst:
+		return OldestFirst{}, nil
+	case EvictLowestScoreFirst:
+		return LowestScoreFirst{}, nil
+	default:
+		return nil, fmt.Errorf("unknown eviction policy: %s (must be '%s' or '%s')", name, EvictOldestFirst, EvictLowestScoreFirst)
+	}
+}
+
+// SetQuota configures the entry quotas EnforceQuota enforces.
+// Passing a zero Quota disables enforcement.
+func (r *Repository) SetQuota(q Quota) {
+	if q.Policy == nil {
+		q.Policy = OldestFirst{}
+	}
+	r.quota = q
+}
+
+// EnforceQuota evicts entries until the configured quotas are satisfied.
+// feedID selects the feed checked against MaxEntriesPerFeed; the total limit
+// is always checked. Call it once after storing a feed's entries rather than
+// after each one. Returns the number of entries evicted.
+func (r *Repository) EnforceQuota(ctx context.Context, feedID int64) (int64, error) {
+	var evicted int64
+
+	if r.quota.MaxEntriesPerFeed > 0 {
+		n, err := r.evictOverLimit(ctx, "WHERE feed_id = ? AND "+evictable, []interface{}{feedID}, r.quota.MaxEntriesPerFeed)
+		if err != nil {
+			return evicted, fmt.Errorf("enforce per-feed quota: %w", err)
+		}
+		evicted += n
+	}
+
+	if r.quota.MaxTotalEntries > 0 {
+		n, err := r.evictOverLimit(ctx, "WHERE "+evictable, nil, r.quota.MaxTotalEntries)
+		if err != nil {
+			return evicted, fmt.Errorf("enforce total quota: %w", err)
+		}
+		evicted += n
+	}
+
+	return evicted, nil
+}
+
+// QuotaCutoff tells which new entries of a feed the quotas would evict as
+// soon as they were stored, so that they can be left out instead of being
+// stored and evicted again on every fetch. The zero value evicts nothing.
+type QuotaCutoff struct {
+	policy EvictionPolicy
+	floors []EvictionCandidate // Per quota that is full, the stored entry a new one must outrank
+}
+
+// Evicts reports whether entry, if it were new, would rank below an entry
+// the next EnforceQuota keeps
+func (c QuotaCutoff) Evicts(entry *Entry) bool {
+	incoming := EvictionCandidate{
+		ID:            math.MaxInt64, // A new entry's ID is higher than any stored
+		FeedID:        entry.FeedID,
+		Title:         entry.Title,
+		Published:     entry.Published,
+		FirstSeen:     entry.FirstSeen,
+		ContentLength: utf8.RuneCountInString(entry.Content),
+	}
+	for _, floor := range c.floors {
+		pair := []EvictionCandidate{incoming, floor}
+		c.policy.Rank(pair)
+		if pair[0].ID == incoming.ID {
+			return true
+		}
+	}
+	return false
+}
+
+// QuotaCutoff returns the cutoff new entries of the feed with ID feedID
+// must pass not to be evicted by the configured quotas. It reflects the
+// entries stored when it is called.
+func (r *Repository) QuotaCutoff(ctx context.Context, feedID int64) (QuotaCutoff, error) {
+	cutoff := QuotaCutoff{policy: r.quota.Policy}
+
+	if r.quota.MaxEntriesPerFeed > 0 {
+		floor, err := r.quotaFloor(ctx, "WHERE feed_id = ? AND "+evictable, []interface{}{feedID}, r.quota.MaxEntriesPerFeed)
+		if err != nil {
+			return QuotaCutoff{}, fmt.Errorf("per-feed quota cutoff: %w", err)
+		}
+		cutoff.floors = append(cutoff.floors, floor...)
+	}
+
+	if r.quota.MaxTotalEntries > 0 {
+		floor, err := r.quotaFloor(ctx, "WHERE "+evictable, nil, r.quota.MaxTotalEntries)
+		if err != nil {
+			return QuotaCutoff{}, fmt.Errorf("total quota cutoff: %w", err)
+		}
+		cutoff.floors = append(cutoff.floors, floor...)
+	}
+
+	return cutoff, nil
+}
+
+// quotaFloor returns the entry matching where that a new entry must outrank
+// to be kept, or nothing if fewer than limit entries match. With one entry
+// added, the count-limit+1 lowest-ranked go, so the new entry must rank
+// above the stored entry ranked count-limit from the bottom.
+func (r *Repository) quotaFloor(ctx context.Context, where string, args []interface{}, limit int) ([]EvictionCandidate, error) {
+	var count int
+	if err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM entries e "+where, args...).Scan(&count); err != nil {
+		return nil, fmt.Errorf("count entries: %w", err)
+	}
+	if count < limit {
+		return nil, nil
+	}
+	return r.rankedCandidates(ctx, where, args, count-limit, 1)
+}
+
+// evictOverLimit deletes the lowest-ranked entries matching where until at most
+// limit remain. where is a fixed SQL fragment chosen by the caller, never user input.
+func (r *Repository) evictOverLimit(ctx context.Context, where string, args []interface{}, limit int) (int64, error) {
+	var count int
+	if err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM entries e "+where, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("count entries: %w", err)
+	}
+
+	excess := count - limit
+	if excess <= 0 {
+		return 0, nil
+	}
+
+	victims, err := r.rankedCandidates(ctx, where, args, 0, excess)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := r.beginTx(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("begin eviction: %w", err)
+	}
+	stmt, err := tx.PrepareContext(ctx, "DELETE FROM entries WHERE id = ?")
+	if err != nil {
+		_ = tx.Rollback() // Rollback on error; ignore rollback errors
+		return 0, fmt.Errorf("prepare eviction: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, c := range victims {
+		if _, err := stmt.ExecContext(ctx, c.ID); err != nil {
+			_ = tx.Rollback() // Rollback on error; ignore rollback errors
+			return 0, fmt.Errorf("evict entry %d: %w", c.ID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("commit eviction: %w", err)
+	}
+
+	return int64(len(victims)), nil
+}
+
+// rankedCandidates returns n of the entries matching where in the order the
+// eviction policy ranks them, skipping the first offset. OldestFirst is
+// ranked by the database, reading only the rows returned; other policies
+// rank every matching entry.
+func (r *Repository) rankedCandidates(ctx context.Context, where string, args []interface{}, offset, n int) ([]EvictionCandidate, error) {
+	query := `
+		SELECT id, feed_id, title, published, first_seen, LENGTH(COALESCE(content, ''))
+		FROM entries e ` + where
+	_, oldestFirst := r.quota.Policy.(OldestFirst)
+	if oldestFirst {
+		query += " ORDER BY published, id LIMIT ? OFFSET ?"
+		args = append(append([]interface{}{}, args...), n, offset)
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query eviction candidates: %w", err)
+	}
+	candidates, err := scanEvictionCandidates(rows)
+	rows.Close()
+	if err != nil || oldestFirst {
+		return candidates, err
+	}
+
+	r.quota.Policy.Rank(candidates)
+	if offset > len(candidates) {
+		offset = len(candidates)
+	}
+	return candidates[offset:min(offset+n, len(candidates))], nil
+}
+
+func scanEvictionCandidates(rows *sql.Rows) ([]EvictionCandidate, error) {
+	var candidates []EvictionCandidate
+
+	for rows.Next() {
+		var c EvictionCandidate
+		var title, published, firstSeen sql.NullString
+
+		if err := rows.Scan(&c.ID, &c.FeedID, &title, &published, &firstSeen, &c.ContentLength); err != nil {
+			return nil, err
+		}
+
+		c.Title = nullString(title)
+
+		var err error
+		if c.Published, err = nullTime(published, "published"); err != nil {
+			return nil, err
+		}
+		if c.FirstSeen, err = nullTime(firstSeen, "first_seen"); err != nil {
+			return nil, err
+		}
+
+		candidates = append(candidates, c)
+	}
+
+	return candidates, rows.Err()
+}
@@ -0,0 +1,357 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func addQuotaTestEntry(t *testing.T, repo *Repository, feedID int64, id string, published time.Time, content string) {
+	t.Helper()
+	err := repo.UpsertEntry(context.Background(), &Entry{
+		FeedID:      feedID,
+		EntryID:     id,
+		Title:       id,
+		Link:        "https://example.com/" + id,
+		Published:   published,
+		Updated:     published,
+		Content:     content,
+		ContentType: "html",
+		FirstSeen:   published,
+	})
+	if err != nil {
+		t.Fatalf("UpsertEntry(%s) error = %v", id, err)
+	}
+}
+
+func enforceQuota(t *testing.T, repo *Repository, feedID int64) int64 {
+	t.Helper()
+	evicted, err := repo.EnforceQuota(context.Background(), feedID)
+	if err != nil {
+		t.Fatalf("EnforceQuota() error = %v", err)
+	}
+	return evicted
+}
+
+func entryIDsForFeed(t *testing.T, repo *Repository, feedID int64) []string {
+	t.Helper()
+	rows, err := repo.db.Query("SELECT entry_id FROM entries WHERE feed_id = ? ORDER BY entry_id", feedID)
+	if err != nil {
+		t.Fatalf("Query error = %v", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			t.Fatalf("Scan error = %v", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func TestQuotaPerFeedOldestFirst(t *testing.T) {
+	t.Parallel()
+	repo, _ := setupTestDB(t)
+	defer repo.Close()
+
+	repo.SetQuota(Quota{MaxEntriesPerFeed: 3})
+
+	ctx := context.Background()
+	feed1, _ := repo.AddFeed(ctx, "https://example.com/feed1", "Feed 1")
+	feed2, _ := repo.AddFeed(ctx, "https://example.com/feed2", "Feed 2")
+
+	base := time.Now().Add(-10 * 24 * time.Hour).Truncate(time.Second)
+	for i := 0; i < 5; i++ {
+		addQuotaTestEntry(t, repo, feed1, fmt.Sprintf("a%d", i), base.Add(time.Duration(i)*time.Hour), "<p>content</p>")
+	}
+	addQuotaTestEntry(t, repo, feed2, "b0", base.Add(-time.Hour), "<p>content</p>")
+
+	// Storing entries leaves enforcement to EnforceQuota
+	if got := entryIDsForFeed(t, repo, feed1); len(got) != 5 {
+		t.Errorf("feed1 entries before EnforceQuota = %v, want 5", got)
+	}
+	if evicted := enforceQuota(t, repo, feed1); evicted != 2 {
+		t.Errorf("EnforceQuota() evicted %d, want 2", evicted)
+	}
+	enforceQuota(t, repo, feed2)
+
+	got := strings.Join(entryIDsForFeed(t, repo, feed1), ",")
+	if got != "a2,a3,a4" {
+		t.Errorf("feed1 entries = %s, want a2,a3,a4", got)
+	}
+
+	// Per-feed quota must not touch other feeds
+	if got := entryIDsForFeed(t, repo, feed2); len(got) != 1 {
+		t.Errorf("feed2 entries = %v, want 1 entry", got)
+	}
+}
+
+func TestQuotaTotalEntries(t *testing.T) {
+	t.Parallel()
+	repo, _ := setupTestDB(t)
+	defer repo.Close()
+
+	repo.SetQuota(Quota{MaxTotalEntries: 4, Policy: OldestFirst{}})
+
+	ctx := context.Background()
+	feed1, _ := repo.AddFeed(ctx, "https://example.com/feed1", "Feed 1")
+	feed2, _ := repo.AddFeed(ctx, "https://example.com/feed2", "Feed 2")
+
+	base := time.Now().Add(-10 * 24 * time.Hour).Truncate(time.Second)
+	addQuotaTestEntry(t, repo, feed1, "old1", base, "x")
+	addQuotaTestEntry(t, repo, feed2, "old2", base.Add(time.Hour), "x")
+	addQuotaTestEntry(t, repo, feed1, "new1", base.Add(2*time.Hour), "x")
+	addQuotaTestEntry(t, repo, feed2, "new2", base.Add(3*time.Hour), "x")
+	addQuotaTestEntry(t, repo, feed1, "new3", base.Add(4*time.Hour), "x")
+	addQuotaTestEntry(t, repo, feed2, "new4", base.Add(5*time.Hour), "x")
+	enforceQuota(t, repo, feed2)
+
+	count, err := repo.CountEntries(ctx)
+	if err != nil {
+		t.Fatalf("CountEntries() error = %v", err)
+	}
+	if count != 4 {
+		t.Errorf("CountEntries() = %d, want 4", count)
+	}
+
+	all := append(entryIDsForFeed(t, repo, feed1), entryIDsForFeed(t, repo, feed2)...)
+	for _, id := range all {
+		if strings.HasPrefix(id, "old") {
+			t.Errorf("entry %s should have been evicted", id)
+		}
+	}
+}
+
+func TestQuotaKeepsPinnedAndStarred(t *testing.T) {
+	t.Parallel()
+	repo, _ := setupTestDB(t)
+	defer repo.Close()
+
+	ctx := context.Background()
+	feed1, _ := repo.AddFeed(ctx, "https://example.com/feed1", "Feed 1")
+	feed2, _ := repo.AddFeed(ctx, "https://example.com/feed2", "Feed 2")
+
+	base := time.Now().Add(-10 * 24 * time.Hour).Truncate(time.Second)
+	for i := 0; i < 5; i++ {
+		addQuotaTestEntry(t, repo, feed1, fmt.Sprintf("a%d", i), base.Add(time.Duration(i)*time.Hour), "x")
+	}
+	if _, err := repo.PinEntry(ctx, "a0", base); err != nil {
+		t.Fatalf("PinEntry() error = %v", err)
+	}
+	if _, err := repo.StarEntries(ctx, "a1", base); err != nil {
+		t.Fatalf("StarEntries() error = %v", err)
+	}
+
+	// The oldest two are protected and do not count, so only a2 goes
+	repo.SetQuota(Quota{MaxEntriesPerFeed: 2})
+	if evicted := enforceQuota(t, repo, feed1); evicted != 1 {
+		t.Errorf("EnforceQuota() evicted %d, want 1", evicted)
+	}
+	if got, want := strings.Join(entryIDsForFeed(t, repo, feed1), ","), "a0,a1,a3,a4"; got != want {
+		t.Errorf("feed1 entries = %s, want %s", got, want)
+	}
+
+	// A new entry older than every unprotected one would be evicted, but
+	// not because the protected entries are older still
+	cutoff, err := repo.QuotaCutoff(ctx, feed1)
+	if err != nil {
+		t.Fatalf("QuotaCutoff() error = %v", err)
+	}
+	if !cutoff.Evicts(&Entry{FeedID: feed1, Published: base.Add(2 * time.Hour)}) {
+		t.Error("Evicts() = false for an entry older than every unprotected one")
+	}
+	if cutoff.Evicts(&Entry{FeedID: feed1, Published: base.Add(5 * time.Hour)}) {
+		t.Error("Evicts() = true for the newest entry")
+	}
+
+	repo.SetQuota(Quota{MaxTotalEntries: 1, Policy: LowestScoreFirst{}})
+	addQuotaTestEntry(t, repo, feed2, "b0", base.Add(6*time.Hour), "x")
+	enforceQuota(t, repo, feed2)
+	all := append(entryIDsForFeed(t, repo, feed1), entryIDsForFeed(t, repo, feed2)...)
+	if got, want := strings.Join(all, ","), "a0,a1,b0"; got != want {
+		t.Errorf("entries under the total quota = %s, want %s", got, want)
+	}
+}
+
+func TestQuotaLowestScoreFirst(t *testing.T) {
+	t.Parallel()
+	repo, _ := setupTestDB(t)
+	defer repo.Close()
+
+	// Custom score: keep the longest content regardless of date
+	repo.SetQuota(Quota{
+		MaxEntriesPerFeed: 2,
+		Policy: LowestScoreFirst{Score: func(c EvictionCandidate) float64 {
+			return float64(c.ContentLength)
+		}},
+	})
+
+	ctx := context.Background()
+	feedID, _ := repo.AddFeed(ctx, "https://example.com/feed", "Feed")
+
+	base := time.Now().Add(-10 * 24 * time.Hour).Truncate(time.Second)
+	addQuotaTestEntry(t, repo, feedID, "long-old", base, strings.Repeat("x", 500))
+	addQuotaTestEntry(t, repo, feedID, "short-new", base.Add(2*time.Hour), "x")
+	addQuotaTestEntry(t, repo, feedID, "medium", base.Add(time.Hour), strings.Repeat("x", 100))
+	enforceQuota(t, repo, feedID)
+
+	got := strings.Join(entryIDsForFeed(t, repo, feedID), ",")
+	if got != "long-old,medium" {
+		t.Errorf("entries = %s, want long-old,medium", got)
+	}
+}
+
+func TestQuotaDisabledByDefault(t *testing.T) {
+	t.Parallel()
+	repo, _ := setupTestDB(t)
+	defer repo.Close()
+
+	ctx := context.Background()
+	feedID, _ := repo.AddFeed(ctx, "https://example.com/feed", "Feed")
+
+	base := time.Now().Truncate(time.Second)
+	for i := 0; i < 10; i++ {
+		addQuotaTestEntry(t, repo, feedID, fmt.Sprintf("e%d", i), base.Add(-time.Duration(i)*time.Hour), "x")
+	}
+	if evicted := enforceQuota(t, repo, feedID); evicted != 0 {
+		t.Errorf("EnforceQuota() without a quota evicted %d", evicted)
+	}
+
+	count, _ := repo.GetEntryCountForFeed(ctx, feedID)
+	if count != 10 {
+		t.Errorf("GetEntryCountForFeed() = %d, want 10", count)
+	}
+}
+
+func TestQuotaCutoff(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	base := time.Now().Add(-10 * 24 * time.Hour).Truncate(time.Second)
+
+	t.Run("oldest_first", func(t *testing.T) {
+		t.Parallel()
+		repo, _ := setupTestDB(t)
+		defer repo.Close()
+		repo.SetQuota(Quota{MaxEntriesPerFeed: 3})
+		feed1, _ := repo.AddFeed(ctx, "https://example.com/feed1", "Feed 1")
+		feed2, _ := repo.AddFeed(ctx, "https://example.com/feed2", "Feed 2")
+
+		entry := func(feedID int64, published time.Time) *Entry {
+			return &Entry{FeedID: feedID, EntryID: "new", Published: published}
+		}
+		for i := 0; i < 2; i++ {
+			addQuotaTestEntry(t, repo, feed1, fmt.Sprintf("a%d", i), base.Add(time.Duration(i)*time.Hour), "x")
+		}
+		cutoff, err := repo.QuotaCutoff(ctx, feed1)
+		if err != nil {
+			t.Fatalf("QuotaCutoff() error = %v", err)
+		}
+		if cutoff.Evicts(entry(feed1, base.Add(-time.Hour))) {
+			t.Error("Evicts() = true with the feed under its quota")
+		}
+
+		addQuotaTestEntry(t, repo, feed1, "a2", base.Add(2*time.Hour), "x")
+		addQuotaTestEntry(t, repo, feed1, "a3", base.Add(3*time.Hour), "x")
+		enforceQuota(t, repo, feed1)
+		cutoff, err = repo.QuotaCutoff(ctx, feed1)
+		if err != nil {
+			t.Fatalf("QuotaCutoff() error = %v", err)
+		}
+		// a1, a2, and a3 are kept; a new entry must be newer than a1
+		if !cutoff.Evicts(entry(feed1, base)) {
+			t.Error("Evicts() = false for an entry older than every kept entry")
+		}
+		if cutoff.Evicts(entry(feed1, base.Add(time.Hour))) {
+			t.Error("Evicts() = true for an entry as old as the oldest kept, which it outranks by ID")
+		}
+		if cutoff.Evicts(entry(feed1, base.Add(4*time.Hour))) {
+			t.Error("Evicts() = true for the newest entry")
+		}
+
+		// Another feed's cutoff is its own
+		cutoff, err = repo.QuotaCutoff(ctx, feed2)
+		if err != nil || cutoff.Evicts(entry(feed2, base.Add(-time.Hour))) {
+			t.Errorf("Evicts() for an empty feed = true, %v; want false", err)
+		}
+	})
+
+	t.Run("lowest_score_first total", func(t *testing.T) {
+		t.Parallel()
+		repo, _ := setupTestDB(t)
+		defer repo.Close()
+		repo.SetQuota(Quota{
+			MaxTotalEntries: 2,
+			Policy: LowestScoreFirst{Score: func(c EvictionCandidate) float64 {
+				return float64(c.ContentLength)
+			}},
+		})
+		feed1, _ := repo.AddFeed(ctx, "https://example.com/feed1", "Feed 1")
+		feed2, _ := repo.AddFeed(ctx, "https://example.com/feed2", "Feed 2")
+		addQuotaTestEntry(t, repo, feed1, "short", base, strings.Repeat("x", 10))
+		addQuotaTestEntry(t, repo, feed2, "long", base, strings.Repeat("x", 100))
+
+		cutoff, err := repo.QuotaCutoff(ctx, feed2)
+		if err != nil {
+			t.Fatalf("QuotaCutoff() error = %v", err)
+		}
+		if !cutoff.Evicts(&Entry{FeedID: feed2, Published: base, Content: "x"}) {
+			t.Error("Evicts() = false for an entry shorter than every stored one")
+		}
+		if cutoff.Evicts(&Entry{FeedID: feed2, Published: base, Content: strings.Repeat("é", 50)}) {
+			t.Error("Evicts() = true for an entry longer than the shortest stored")
+		}
+	})
+
+	t.Run("no quota", func(t *testing.T) {
+		t.Parallel()
+		if (QuotaCutoff{}).Evicts(&Entry{}) {
+			t.Error("the zero QuotaCutoff evicts")
+		}
+	})
+}
+
+func TestDefaultEntryScore(t *testing.T) {
+	t.Parallel()
+	day := time.Date(2025, 1, 10, 12, 0, 0, 0, time.UTC)
+
+	stub := EvictionCandidate{Published: day, ContentLength: 0}
+	full := EvictionCandidate{Published: day, ContentLength: 5000}
+	newer := EvictionCandidate{Published: day.Add(48 * time.Hour), ContentLength: 0}
+
+	if DefaultEntryScore(stub) >= DefaultEntryScore(full) {
+		t.Error("stub should score lower than full post from the same day")
+	}
+	if DefaultEntryScore(full) >= DefaultEntryScore(newer) {
+		t.Error("full post should score lower than an entry two days newer")
+	}
+}
+
+func TestEvictionPolicyByName(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		wantErr bool
+	}{
+		{"", false},
+		{EvictOldestFirst, false},
+		{EvictLowestScoreFirst, false},
+		{"random", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, err := EvictionPolicyByName(tt.name)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("EvictionPolicyByName(%q) error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			}
+			if !tt.wantErr && policy == nil {
+				t.Errorf("EvictionPolicyByName(%q) returned nil policy", tt.name)
+			}
+		})
+	}
+}
@@ -0,0 +1,270 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Read state is for using rp as a personal feed reader. An entry is unread
+// until it is marked read; marks outlive the entry, so one pruned and
+// fetched again stays read.
+
+// unread keeps entries marked read out of queries over entries e
+const unread = `NOT EXISTS (SELECT 1 FROM read_entries rd WHERE rd.feed_id = e.feed_id AND rd.entry_id = e.entry_id)`
+
+// MarkRead marks the entries whose link or entry ID is ref read and returns
+// them. It returns ErrEntryNotFound if none are stored.
+func (r *Repository) MarkRead(ctx context.Context, ref string, at time.Time) ([]Entry, error) {
+	return r.markFound(ctx, readEntries, ref, at)
+}
+
+// MarkAllRead marks every stored entry of a feed read, or of all feeds if
+// feedID is 0, and returns how many were unread
+func (r *Repository) MarkAllRead(ctx context.Context, feedID int64, at time.Time) (int64, error) {
+	result, err := r.db.ExecContext(ctx, `
+		INSERT INTO read_entries (feed_id, entry_id, title, link, read_at)
+		SELECT e.feed_id, e.entry_id, e.title, e.link, ?
+		FROM entries e
+		WHERE (? = 0 OR e.feed_id = ?) AND `+unread+`
+		ON CONFLICT (feed_id, entry_id) DO NOTHING
+	`, at.UTC().Format(time.RFC3339), feedID, feedID)
+	if err != nil {
+		return 0, fmt.Errorf("mark entries read: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// MarkUnread marks the entries whose link or entry ID is ref unread again,
+// and returns how many were read
+func (r *Repository) MarkUnread(ctx context.Context, ref string) (int64, error) {
+	return r.unmarkEntries(ctx, readEntries, ref)
+}
+
+// StarEntries stars the entries whose link or entry ID is ref and returns
+// them. It returns ErrEntryNotFound if none are stored.
+func (r *Repository) StarEntries(ctx context.Context, ref string, at time.Time) ([]Entry, error) {
+	return r.markFound(ctx, starredEntries, ref, at)
+}
+
+// UnstarEntries unstars the entries whose link or entry ID is ref, and
+// returns how many there were
+func (r *Repository) UnstarEntries(ctx context.Context, ref string) (int64, error) {
+	return r.unmarkEntries(ctx, starredEntries, ref)
+}
+
+// GetStarredEntries returns the starred entries, most recently starred
+// first, including those no longer stored
+func (r *Repository) GetStarredEntries(ctx context.Context) ([]MarkedEntry, error) {
+	return r.markedEntries(ctx, starredEntries)
+}
+
+// GetUnreadEntries returns up to limit unread entries of active feeds that
+// are not hidden, newest first
+func (r *Repository) GetUnreadEntries(ctx context.Context, limit int) ([]Entry, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+r.entryColumns()+`
+		FROM entries e
+		JOIN feeds f ON e.feed_id = f.id
+		WHERE `+visible+` AND `+unread+`
+		ORDER BY e.published DESC, `+entryTieBreaker+`
+		LIMIT ?
+	`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("query unread entries: %w", err)
+	}
+	defer rows.Close()
+
+	return scanEntries(rows)
+}
+
+// CountUnreadEntries counts the entries GetUnreadEntries would return with
+// no limit
+func (r *Repository) CountUnreadEntries(ctx context.Context) (int64, error) {
+	var count int64
+	err := r.db.QueryRowContext(ctx, `
+		SELECT COUNT(*)
+		FROM entries e
+		JOIN feeds f ON e.feed_id = f.id
+		WHERE `+visible+` AND `+unread).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("count unread entries: %w", err)
+	}
+	return count, nil
+}
+
+// markFound marks the entries whose link or entry ID is ref in a table of
+// marked entries and returns them, or ErrEntryNotFound if none are stored
+func (r *Repository) markFound(ctx context.Context, table, ref string, at time.Time) ([]Entry, error) {
+	entries, err := r.FindEntries(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, ErrEntryNotFound
+	}
+	if err := r.markEntries(ctx, table, entries, at); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// Reader apps sync entries by their row ID (Entry.ID), which only grows. The
+// entries they see are those GetUnreadEntries draws from, read or not: the
+// entries of active feeds that are not hidden.
+
+// visible keeps entries of inactive feeds f and hidden entries e out of
+// queries over entries
+const visible = `f.active = 1 AND ` + notHidden
+
+// GetEntriesAfterID returns up to limit visible entries with a row ID above
+// sinceID, oldest first
+func (r *Repository) GetEntriesAfterID(ctx context.Context, sinceID int64, limit int) ([]Entry, error) {
+	return r.queryVisibleEntries(ctx, `e.id > ?`, `e.id ASC`, sinceID, limit)
+}
+
+// GetEntriesBeforeID returns up to limit visible entries with a row ID below
+// maxID, or the newest if maxID is 0, newest first
+func (r *Repository) GetEntriesBeforeID(ctx context.Context, maxID int64, limit int) ([]Entry, error) {
+	return r.queryVisibleEntries(ctx, `(? = 0 OR e.id < ?)`, `e.id DESC`, maxID, maxID, limit)
+}
+
+// GetEntriesByRowID returns the visible entries among ids, oldest first
+func (r *Repository) GetEntriesByRowID(ctx context.Context, ids []int64) ([]Entry, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	args := make([]interface{}, 0, len(ids)+1)
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	args = append(args, len(ids))
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",")
+	return r.queryVisibleEntries(ctx, `e.id IN (`+placeholders+`)`, `e.id ASC`, args...)
+}
+
+// CountVisibleEntries counts the entries of active feeds that are not hidden
+func (r *Repository) CountVisibleEntries(ctx context.Context) (int64, error) {
+	var count int64
+	err := r.db.QueryRowContext(ctx, `
+		SELECT COUNT(*)
+		FROM entries e
+		JOIN feeds f ON e.feed_id = f.id
+		WHERE `+visible).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("count visible entries: %w", err)
+	}
+	return count, nil
+}
+
+// GetUnreadEntryIDs returns the row IDs of the unread visible entries
+func (r *Repository) GetUnreadEntryIDs(ctx context.Context) ([]int64, error) {
+	return r.queryEntryIDs(ctx, `
+		SELECT e.id
+		FROM entries e
+		JOIN feeds f ON e.feed_id = f.id
+		WHERE `+visible+` AND `+unread+`
+		ORDER BY e.id`)
+}
+
+// GetStarredEntryIDs returns the row IDs of the stored starred entries
+func (r *Repository) GetStarredEntryIDs(ctx context.Context) ([]int64, error) {
+	return r.queryEntryIDs(ctx, `
+		SELECT e.id
+		FROM starred_entries s
+		JOIN entries e ON e.feed_id = s.feed_id AND e.entry_id = s.entry_id
+		ORDER BY e.id`)
+}
+
+// SetEntryRead marks the entry with row ID id read or unread. It returns
+// ErrEntryNotFound if there is no such entry.
+func (r *Repository) SetEntryRead(ctx context.Context, id int64, read bool, at time.Time) error {
+	return r.setEntryMark(ctx, readEntries, id, read, at)
+}
+
+// SetEntryStarred stars or unstars the entry with row ID id. It returns
+// ErrEntryNotFound if there is no such entry.
+func (r *Repository) SetEntryStarred(ctx context.Context, id int64, starred bool, at time.Time) error {
+	return r.setEntryMark(ctx, starredEntries, id, starred, at)
+}
+
+// MarkReadBefore marks the stored entries of a feed, or of all feeds if
+// feedID is 0, first seen before a time read, and returns how many were
+// unread. Reader apps send the time they last synced, so entries fetched
+// since are left unread.
+func (r *Repository) MarkReadBefore(ctx context.Context, feedID int64, before, at time.Time) (int64, error) {
+	result, err := r.db.ExecContext(ctx, `
+		INSERT INTO read_entries (feed_id, entry_id, title, link, read_at)
+		SELECT e.feed_id, e.entry_id, e.title, e.link, ?
+		FROM entries e
+		WHERE (? = 0 OR e.feed_id = ?) AND e.first_seen < ? AND `+unread+`
+		ON CONFLICT (feed_id, entry_id) DO NOTHING
+	`, at.UTC().Format(time.RFC3339), feedID, feedID, before.UTC().Format(time.RFC3339))
+	if err != nil {
+		return 0, fmt.Errorf("mark entries read: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// queryVisibleEntries returns the visible entries matching where, in order,
+// up to a limit given as the last of args
+func (r *Repository) queryVisibleEntries(ctx context.Context, where, order string, args ...interface{}) ([]Entry, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+r.entryColumns()+`
+		FROM entries e
+		JOIN feeds f ON e.feed_id = f.id
+		WHERE `+visible+` AND `+where+`
+		ORDER BY `+order+`
+		LIMIT ?
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query entries: %w", err)
+	}
+	defer rows.Close()
+
+	return scanEntries(rows)
+}
+
+// queryEntryIDs returns the row IDs a query selects
+func (r *Repository) queryEntryIDs(ctx context.Context, query string) ([]int64, error) {
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("query entry IDs: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("scan entry ID: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// setEntryMark adds the entry with row ID id to a table of marked entries,
+// or removes it
+func (r *Repository) setEntryMark(ctx context.Context, table string, id int64, on bool, at time.Time) error {
+	var e Entry
+	err := r.db.QueryRowContext(ctx, `SELECT feed_id, entry_id, COALESCE(title, ''), COALESCE(link, '') FROM entries WHERE id = ?`, id).
+		Scan(&e.FeedID, &e.EntryID, &e.Title, &e.Link)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrEntryNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("get entry %d: %w", id, err)
+	}
+	if on {
+		return r.markEntries(ctx, table, []Entry{e}, at)
+	}
+	_, err = r.db.ExecContext(ctx, `DELETE FROM `+table+` WHERE feed_id = ? AND entry_id = ?`, e.FeedID, e.EntryID)
+	if err != nil {
+		return fmt.Errorf("delete from %s: %w", table, err)
+	}
+	return nil
+}
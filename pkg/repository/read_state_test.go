@@ -0,0 +1,204 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadState(t *testing.T) {
+	t.Parallel()
+	repo, _ := setupTestDB(t)
+	defer repo.Close()
+
+	ctx := context.Background()
+	feedID, _ := repo.AddFeed(ctx, "https://example.com/feed", "Test Feed")
+	otherID, _ := repo.AddFeed(ctx, "https://other.example.com/feed", "Other Feed")
+
+	now := time.Now().UTC().Truncate(time.Second)
+	add := func(feed int64, id string, published time.Time) {
+		t.Helper()
+		e := &Entry{FeedID: feed, EntryID: id, Title: id, Link: "https://example.com/" + id, Published: published, Updated: published, FirstSeen: now}
+		if err := repo.UpsertEntry(ctx, e); err != nil {
+			t.Fatalf("UpsertEntry() error = %v", err)
+		}
+	}
+	add(feedID, "old", now.Add(-2*time.Hour))
+	add(feedID, "new", now.Add(-time.Hour))
+	add(otherID, "other", now)
+
+	unread := func() []string {
+		t.Helper()
+		entries, err := repo.GetUnreadEntries(ctx, 10)
+		if err != nil {
+			t.Fatalf("GetUnreadEntries() error = %v", err)
+		}
+		count, err := repo.CountUnreadEntries(ctx)
+		if err != nil {
+			t.Fatalf("CountUnreadEntries() error = %v", err)
+		}
+		if count != int64(len(entries)) {
+			t.Errorf("CountUnreadEntries() = %d, want %d", count, len(entries))
+		}
+		var ids []string
+		for _, e := range entries {
+			ids = append(ids, e.EntryID)
+		}
+		return ids
+	}
+
+	if ids := unread(); len(ids) != 3 || ids[0] != "other" || ids[2] != "old" {
+		t.Errorf("unread entries = %v, want all of them, newest first", ids)
+	}
+
+	if _, err := repo.MarkRead(ctx, "https://example.com/new", now); err != nil {
+		t.Fatalf("MarkRead() error = %v", err)
+	}
+	if _, err := repo.MarkRead(ctx, "nope", now); !errors.Is(err, ErrEntryNotFound) {
+		t.Errorf("MarkRead() of an unknown entry error = %v, want ErrEntryNotFound", err)
+	}
+	if ids := unread(); len(ids) != 2 || ids[0] != "other" || ids[1] != "old" {
+		t.Errorf("unread entries after MarkRead = %v", ids)
+	}
+
+	// Read entries stay read when fetched again
+	add(feedID, "new", now.Add(-time.Hour))
+	if ids := unread(); len(ids) != 2 {
+		t.Errorf("unread entries after refetch = %v", ids)
+	}
+
+	if n, err := repo.MarkAllRead(ctx, feedID, now); err != nil || n != 1 {
+		t.Errorf("MarkAllRead(feed) = %d, %v, want 1 (the entry that was unread)", n, err)
+	}
+	if ids := unread(); len(ids) != 1 || ids[0] != "other" {
+		t.Errorf("unread entries after MarkAllRead(feed) = %v", ids)
+	}
+
+	if n, err := repo.MarkUnread(ctx, "old"); err != nil || n != 1 {
+		t.Errorf("MarkUnread() = %d, %v", n, err)
+	}
+	if n, err := repo.MarkAllRead(ctx, 0, now); err != nil || n != 2 {
+		t.Errorf("MarkAllRead(all) = %d, %v, want 2", n, err)
+	}
+	if ids := unread(); len(ids) != 0 {
+		t.Errorf("unread entries after MarkAllRead(all) = %v", ids)
+	}
+
+	// Stars
+	if _, err := repo.StarEntries(ctx, "old", now); err != nil {
+		t.Fatalf("StarEntries() error = %v", err)
+	}
+	starred, err := repo.GetStarredEntries(ctx)
+	if err != nil || len(starred) != 1 || starred[0].EntryID != "old" || !starred[0].At.Equal(now) {
+		t.Errorf("GetStarredEntries() = %+v, %v", starred, err)
+	}
+	if n, err := repo.UnstarEntries(ctx, "https://example.com/old"); err != nil || n != 1 {
+		t.Errorf("UnstarEntries() = %d, %v", n, err)
+	}
+
+	// Marks move with a rekeyed entry and go with a removed feed
+	if _, err := repo.StarEntries(ctx, "old", now); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.RekeyEntry(ctx, feedID, "old", "old-2"); err != nil {
+		t.Fatalf("RekeyEntry() error = %v", err)
+	}
+	if starred, _ := repo.GetStarredEntries(ctx); len(starred) != 1 || starred[0].EntryID != "old-2" {
+		t.Errorf("starred after rekey = %+v", starred)
+	}
+	if err := repo.RemoveFeed(ctx, feedID); err != nil {
+		t.Fatal(err)
+	}
+	if starred, _ := repo.GetStarredEntries(ctx); len(starred) != 0 {
+		t.Errorf("starred after removing the feed = %+v", starred)
+	}
+}
+
+func TestReaderSync(t *testing.T) {
+	t.Parallel()
+	repo, _ := setupTestDB(t)
+	defer repo.Close()
+
+	ctx := context.Background()
+	feedID, _ := repo.AddFeed(ctx, "https://example.com/feed", "Test Feed")
+	otherID, _ := repo.AddFeed(ctx, "https://other.example.com/feed", "Other Feed")
+
+	now := time.Now().Truncate(time.Second)
+	var rowIDs []int64
+	for i, id := range []string{"a", "b", "c", "d"} {
+		feed := feedID
+		if id == "d" {
+			feed = otherID
+		}
+		seen := now.Add(time.Duration(i-3) * time.Hour)
+		if err := repo.UpsertEntry(ctx, &Entry{FeedID: feed, EntryID: id, Title: id, Link: "https://example.com/" + id, Published: seen, Updated: seen, FirstSeen: seen}); err != nil {
+			t.Fatalf("UpsertEntry() error = %v", err)
+		}
+		entries, _ := repo.FindEntries(ctx, id)
+		rowIDs = append(rowIDs, entries[0].ID)
+	}
+	ids := func(entries []Entry, err error) string {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, e := range entries {
+			got = append(got, e.EntryID)
+		}
+		return strings.Join(got, ",")
+	}
+
+	if got := ids(repo.GetEntriesAfterID(ctx, rowIDs[0], 2)); got != "b,c" {
+		t.Errorf("GetEntriesAfterID() = %s, want b,c", got)
+	}
+	if got := ids(repo.GetEntriesBeforeID(ctx, 0, 2)); got != "d,c" {
+		t.Errorf("GetEntriesBeforeID(0) = %s, want d,c", got)
+	}
+	if got := ids(repo.GetEntriesBeforeID(ctx, rowIDs[2], 10)); got != "b,a" {
+		t.Errorf("GetEntriesBeforeID() = %s, want b,a", got)
+	}
+	if got := ids(repo.GetEntriesByRowID(ctx, []int64{rowIDs[3], rowIDs[1], 999})); got != "b,d" {
+		t.Errorf("GetEntriesByRowID() = %s, want b,d", got)
+	}
+
+	// Hidden entries are not synced
+	if _, err := repo.HideEntries(ctx, "c", now); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := repo.CountVisibleEntries(ctx); err != nil || n != 3 {
+		t.Errorf("CountVisibleEntries() = %d, %v, want 3", n, err)
+	}
+
+	if err := repo.SetEntryRead(ctx, rowIDs[0], true, now); err != nil {
+		t.Fatalf("SetEntryRead() error = %v", err)
+	}
+	if err := repo.SetEntryStarred(ctx, rowIDs[1], true, now); err != nil {
+		t.Fatalf("SetEntryStarred() error = %v", err)
+	}
+	if err := repo.SetEntryRead(ctx, 999, true, now); !errors.Is(err, ErrEntryNotFound) {
+		t.Errorf("SetEntryRead() of an unknown entry error = %v, want ErrEntryNotFound", err)
+	}
+	if unread, _ := repo.GetUnreadEntryIDs(ctx); len(unread) != 2 || unread[0] != rowIDs[1] || unread[1] != rowIDs[3] {
+		t.Errorf("GetUnreadEntryIDs() = %v, want b and d", unread)
+	}
+	if starred, _ := repo.GetStarredEntryIDs(ctx); len(starred) != 1 || starred[0] != rowIDs[1] {
+		t.Errorf("GetStarredEntryIDs() = %v, want b", starred)
+	}
+	if err := repo.SetEntryStarred(ctx, rowIDs[1], false, now); err != nil {
+		t.Fatal(err)
+	}
+	if starred, _ := repo.GetStarredEntryIDs(ctx); len(starred) != 0 {
+		t.Errorf("GetStarredEntryIDs() after unstarring = %v", starred)
+	}
+
+	// Entries first seen since the app synced stay unread
+	if n, err := repo.MarkReadBefore(ctx, 0, now.Add(-30*time.Minute), now); err != nil || n != 2 {
+		t.Errorf("MarkReadBefore() = %d, %v, want b and c", n, err)
+	}
+	if unread, _ := repo.GetUnreadEntryIDs(ctx); len(unread) != 1 || unread[0] != rowIDs[3] {
+		t.Errorf("GetUnreadEntryIDs() after MarkReadBefore = %v, want d", unread)
+	}
+}
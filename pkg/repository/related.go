@@ -0,0 +1,190 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Relation links an entry to a related entry
+type Relation struct {
+	EntryID   int64   // Row ID of the entry
+	RelatedID int64   // Row ID of the related entry
+	Score     float64 // Similarity, from 0 to 1
+}
+
+// RelatedEntry is an entry related to another, as shown under it
+type RelatedEntry struct {
+	ID    int64 // Row ID
+	Title string
+	Link  string
+	Score float64
+}
+
+// SaveRelations stores relations, replacing those between the same
+// entries, then keeps only the max highest scoring relations of each entry
+// they touch
+func (r *Repository) SaveRelations(ctx context.Context, relations []Relation, max int) error {
+	tx, err := r.beginTx(ctx)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }() // No-op after commit
+
+	touched := make(map[int64]bool)
+	for _, rel := range relations {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO related_entries (entry_id, related_id, score)
+			VALUES (?, ?, ?)
+			ON CONFLICT (entry_id, related_id) DO UPDATE SET score = excluded.score
+		`, rel.EntryID, rel.RelatedID, rel.Score)
+		if err != nil {
+			return fmt.Errorf("save relation: %w", err)
+		}
+		touched[rel.EntryID] = true
+	}
+	for id := range touched {
+		_, err := tx.ExecContext(ctx, `
+			DELETE FROM related_entries
+			WHERE entry_id = ? AND related_id NOT IN (
+				SELECT related_id FROM related_entries
+				WHERE entry_id = ?
+				ORDER BY score DESC, related_id DESC
+				LIMIT ?
+			)
+		`, id, id, max)
+		if err != nil {
+			return fmt.Errorf("trim relations: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit relations: %w", err)
+	}
+	return nil
+}
+
+// ClearRelations deletes every relation between entries
+func (r *Repository) ClearRelations(ctx context.Context) error {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM related_entries`); err != nil {
+		return fmt.Errorf("clear relations: %w", err)
+	}
+	return nil
+}
+
+// GetRelatedEntries returns the entries related to each of the entries
+// with the given row IDs, most related first, keyed by row ID. Entries of
+// inactive feeds and hidden entries are left out.
+func (r *Repository) GetRelatedEntries(ctx context.Context, ids []int64) (map[int64][]RelatedEntry, error) {
+	related := make(map[int64][]RelatedEntry)
+	// Stay well under SQLite's limit on bound parameters
+	const batch = 500
+	for start := 0; start < len(ids); start += batch {
+		chunk := ids[start:min(start+batch, len(ids))]
+		args := make([]interface{}, 0, len(chunk))
+		for _, id := range chunk {
+			args = append(args, id)
+		}
+		placeholders := strings.TrimSuffix(strings.Repeat("?,", len(chunk)), ",")
+
+		rows, err := r.db.QueryContext(ctx, `
+			SELECT rel.entry_id, e.id, COALESCE(e.title, ''), COALESCE(e.link, ''), rel.score
+			FROM related_entries rel
+			JOIN entries e ON e.id = rel.related_id
+			JOIN feeds f ON e.feed_id = f.id
+			WHERE rel.entry_id IN (`+placeholders+`) AND f.active = 1 AND `+notHidden+`
+			ORDER BY rel.entry_id, rel.score DESC, e.id DESC
+		`, args...)
+		if err != nil {
+			return nil, fmt.Errorf("query related entries: %w", err)
+		}
+		for rows.Next() {
+			var id int64
+			var e RelatedEntry
+			if err := rows.Scan(&id, &e.ID, &e.Title, &e.Link, &e.Score); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("scan related entry: %w", err)
+			}
+			related[id] = append(related[id], e)
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, fmt.Errorf("iterate related entries: %w", err)
+		}
+	}
+	return related, nil
+}
+
+// GetEmbeddings returns the cached embeddings of the texts with the given
+// keys, keyed by key. Texts without one are missing from the result.
+func (r *Repository) GetEmbeddings(ctx context.Context, keys []string) (map[string][]float64, error) {
+	embeddings := make(map[string][]float64, len(keys))
+	const batch = 500
+	for start := 0; start < len(keys); start += batch {
+		ks := keys[start:min(start+batch, len(keys))]
+		args := make([]interface{}, 0, len(ks))
+		for _, k := range ks {
+			args = append(args, k)
+		}
+		placeholders := strings.TrimSuffix(strings.Repeat("?,", len(ks)), ",")
+
+		rows, err := r.db.QueryContext(ctx,
+			`SELECT source_hash, vector FROM embeddings WHERE source_hash IN (`+placeholders+`)`, args...)
+		if err != nil {
+			return nil, fmt.Errorf("query embeddings: %w", err)
+		}
+		for rows.Next() {
+			var key, vector string
+			if err := rows.Scan(&key, &vector); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("scan embedding: %w", err)
+			}
+			var v []float64
+			if err := json.Unmarshal([]byte(vector), &v); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("decode embedding: %w", err)
+			}
+			embeddings[key] = v
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, fmt.Errorf("iterate embeddings: %w", err)
+		}
+	}
+	return embeddings, nil
+}
+
+// SaveEmbeddings caches the embeddings of texts, keyed by the key of the
+// text
+func (r *Repository) SaveEmbeddings(ctx context.Context, embeddings map[string][]float64) error {
+	tx, err := r.beginTx(ctx)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }() // No-op after commit
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	for key, v := range embeddings {
+		vector, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Errorf("encode embedding: %w", err)
+		}
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO embeddings (source_hash, vector, created_at)
+			VALUES (?, ?, ?)
+			ON CONFLICT (source_hash) DO UPDATE SET vector = excluded.vector, created_at = excluded.created_at
+		`, key, string(vector), now)
+		if err != nil {
+			return fmt.Errorf("save embedding: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit embeddings: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRelations(t *testing.T) {
+	t.Parallel()
+	repo, _ := setupTestDB(t)
+	defer repo.Close()
+	ctx := context.Background()
+
+	feedID, _ := repo.AddFeed(ctx, "https://example.com/feed", "Test Feed")
+	now := time.Now().Truncate(time.Second)
+	var rowIDs []int64
+	for _, id := range []string{"a", "b", "c", "d"} {
+		if err := repo.UpsertEntry(ctx, &Entry{FeedID: feedID, EntryID: id, Title: "Post " + id, Link: "https://example.com/" + id, Published: now, Updated: now, FirstSeen: now}); err != nil {
+			t.Fatalf("UpsertEntry() error = %v", err)
+		}
+		entries, _ := repo.FindEntries(ctx, id)
+		rowIDs = append(rowIDs, entries[0].ID)
+	}
+	a, b, c, d := rowIDs[0], rowIDs[1], rowIDs[2], rowIDs[3]
+
+	if err := repo.SaveRelations(ctx, []Relation{
+		{EntryID: a, RelatedID: b, Score: 0.5},
+		{EntryID: a, RelatedID: c, Score: 0.9},
+		{EntryID: b, RelatedID: a, Score: 0.5},
+	}, 2); err != nil {
+		t.Fatalf("SaveRelations() error = %v", err)
+	}
+	// A better match pushes out the worst, past max
+	if err := repo.SaveRelations(ctx, []Relation{{EntryID: a, RelatedID: d, Score: 0.7}}, 2); err != nil {
+		t.Fatalf("SaveRelations() error = %v", err)
+	}
+	if _, err := repo.HideEntries(ctx, "d", now); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := repo.GetRelatedEntries(ctx, []int64{a, b, c})
+	if err != nil {
+		t.Fatalf("GetRelatedEntries() error = %v", err)
+	}
+	want := map[int64][]RelatedEntry{
+		// d is hidden
+		a: {{ID: c, Title: "Post c", Link: "https://example.com/c", Score: 0.9}},
+		b: {{ID: a, Title: "Post a", Link: "https://example.com/a", Score: 0.5}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetRelatedEntries() = %+v, want %+v", got, want)
+	}
+
+	if err := repo.ClearRelations(ctx); err != nil {
+		t.Fatalf("ClearRelations() error = %v", err)
+	}
+	if got, _ := repo.GetRelatedEntries(ctx, []int64{a, b}); len(got) != 0 {
+		t.Errorf("GetRelatedEntries() after ClearRelations() = %+v", got)
+	}
+}
+
+func TestEmbeddings(t *testing.T) {
+	t.Parallel()
+	repo, _ := setupTestDB(t)
+	defer repo.Close()
+	ctx := context.Background()
+
+	if err := repo.SaveEmbeddings(ctx, map[string][]float64{"a": {0.5, -1}, "b": {2}}); err != nil {
+		t.Fatalf("SaveEmbeddings() error = %v", err)
+	}
+	got, err := repo.GetEmbeddings(ctx, []string{"a", "b", "c"})
+	if err != nil {
+		t.Fatalf("GetEmbeddings() error = %v", err)
+	}
+	if want := map[string][]float64{"a": {0.5, -1}, "b": {2}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("GetEmbeddings() = %v, want %v", got, want)
+	}
+}
@@ -1,15 +1,21 @@
-// Package repository provides SQLite database operations for feed storage.
+// Package repository provides SQLite and PostgreSQL database operations for
+// feed storage.
 //
 // The repository handles all database interactions including feed management,
 // entry storage with deduplication, and intelligent querying with fallback logic.
-// It uses WAL mode for better concurrency and prepared statements for security.
+// SQLite databases use WAL mode, so pages can be generated from the database
+// while a fetch is writing to it. All queries use prepared statements for
+// security.
 package repository
 
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	_ "github.com/mattn/go-sqlite3"
@@ -32,9 +38,12 @@ type Feed struct {
 	LastModified    string
 	FetchError      string
 	FetchErrorCount int
-	NextFetch       time.Time // TODO(v1.0): Used for intelligent scheduling (not yet implemented)
+	NextFetch       time.Time // When the feed is next due under adaptive scheduling
 	Active          bool
-	FetchInterval   int // seconds - TODO(v1.0): Used for adaptive polling (not yet implemented)
+	FetchInterval   int       // seconds - interval computed from the feed's posting cadence
+	CacheExpires    time.Time // Freshness lifetime from the server's Cache-Control/Expires headers
+	GoneAt          time.Time // When the feed was found permanently gone (410 Gone or a vanished host); zero if not
+	CustomTitle     bool      // Title was set by hand, so fetches keep it rather than using the feed's own
 }
 
 // Entry represents a feed entry in the database
@@ -51,33 +60,93 @@ type Entry struct {
 	ContentType string
 	Summary     string
 	FirstSeen   time.Time
+	Categories  []string // Categories/tags from the source feed
+	ContentHash string   // Fingerprint of the entry's content, used to recognise republished entries
+
+	// Material changes to the content seen on re-fetch: how many, and when
+	// the last one was first seen (zero if never)
+	UpdatedCount          int
+	LastSignificantUpdate time.Time
+
+	WordCount      int    // Words in the content
+	ReadingMinutes int    // Estimated minutes to read the content
+	Image          string // Representative image URL, for cards and social previews
+
+	Authors     []string     // Names of all the entry's authors, when the feed names them
+	ExternalURL string       // Page elsewhere the entry is about, for link blogs
+	Language    string       // Language of the entry, or else of its feed
+	Attachments []Attachment // Enclosures and JSON Feed attachments
+}
+
+// Attachment is a file that goes with an entry, such as a podcast episode
+// or a video
+type Attachment struct {
+	URL       string `json:"url"`
+	MimeType  string `json:"mime_type,omitempty"`
+	Title     string `json:"title,omitempty"`
+	Size      int64  `json:"size,omitempty"`      // Bytes; 0 if unknown
+	Duration  int    `json:"duration,omitempty"`  // Seconds; 0 if unknown
+	Medium    string `json:"medium,omitempty"`    // "image", "audio", "video", ... or "" if unknown
+	Width     int    `json:"width,omitempty"`     // Pixels; 0 if unknown
+	Height    int    `json:"height,omitempty"`    // Pixels; 0 if unknown
+	Thumbnail string `json:"thumbnail,omitempty"` // URL of an image standing for it, if any
 }
 
 // Repository handles database operations
 type Repository struct {
-	db *sql.DB
+	conn      *sql.DB           // The database; nil for the repository passed to a Batch
+	db        querier           // conn, or the Batch transaction
+	tx        *sql.Tx           // The Batch transaction, if any
+	quota     Quota             // Entry quotas enforced on upsert (zero value disables)
+	history   int               // Fetch log records kept per feed (0 = FetchLogRetention)
+	revisions RevisionRetention // Earlier content kept of changed entries (zero value keeps none)
+	dialect   *dialect          // The kind of database
 }
 
-// New creates a new Repository and initializes the database
+// BusyTimeout is how long a connection waits for another connection's write
+// lock before failing with "database is locked"
+const BusyTimeout = 10 * time.Second
+
+// connectionParams configure every SQLite connection in the pool (PRAGMAs run with
+// db.Exec only reach one of them):
+//   - WAL journal mode, so readers never block the writer or each other.
+//     It is stored in the database file, so existing databases in rollback
+//     journal mode are converted the first time they are opened.
+//   - A busy timeout, so a second writer (say, rp generate while rp fetch is
+//     storing entries) waits for the lock instead of failing.
+//   - synchronous=NORMAL, which is safe in WAL mode and syncs only at
+//     checkpoints.
+//   - Foreign keys, required for CASCADE DELETE.
+var connectionParams = fmt.Sprintf("_journal_mode=WAL&_busy_timeout=%d&_synchronous=NORMAL&_foreign_keys=on", BusyTimeout.Milliseconds())
+
+// New creates a new Repository backed by the SQLite database at dbPath and
+// initializes the database
 func New(dbPath string) (*Repository, error) {
-	db, err := sql.Open("sqlite3", dbPath)
+	return Open(DriverSQLite, dbPath)
+}
+
+// Open creates a new Repository and initializes the database. For
+// DriverSQLite the data source is the database file; for DriverPostgres it
+// is a connection string, either a postgres:// URL or key=value settings.
+func Open(driver, dataSource string) (*Repository, error) {
+	d, err := dialectByName(driver)
 	if err != nil {
-		return nil, fmt.Errorf("open database: %w", err)
+		return nil, err
 	}
-
-	// Enable WAL mode for better concurrency
-	if _, err := db.Exec("PRAGMA journal_mode=WAL"); err != nil {
-		db.Close()
-		return nil, fmt.Errorf("enable WAL: %w", err)
+	if d == sqliteDialect {
+		dataSource += "?" + connectionParams
 	}
 
-	// Enable foreign keys (required for CASCADE DELETE)
-	if _, err := db.Exec("PRAGMA foreign_keys = ON"); err != nil {
+	db, err := sql.Open(d.sqlDriver, dataSource)
+	if err != nil {
+		return nil, fmt.Errorf("open database: %w", err)
+	}
+	if err := db.Ping(); err != nil {
 		db.Close()
-		return nil, fmt.Errorf("enable foreign keys: %w", err)
+		return nil, fmt.Errorf("open database: %w", err)
 	}
 
-	repo := &Repository{db: db}
+	repo := &Repository{conn: db, db: d.wrap(db, nil), dialect: d}
 
 	// Initialize schema
 	if err := repo.initSchema(); err != nil {
@@ -88,12 +157,18 @@ func New(dbPath string) (*Repository, error) {
 	return repo, nil
 }
 
-// Close closes the database connection
+// Close closes the database connection. It does nothing for the repository
+// passed to a Batch.
 func (r *Repository) Close() error {
-	return r.db.Close()
+	if r.conn == nil {
+		return nil
+	}
+	return r.conn.Close()
 }
 
-const currentSchemaVersion = 2
+// currentSchemaVersion is the version of both dialects' schemas. A schema
+// change updates both and adds a migration that works in each.
+const currentSchemaVersion = 27
 
 // initSchema creates the database schema and runs migrations
 func (r *Repository) initSchema() error {
@@ -118,7 +193,7 @@ func (r *Repository) initSchema() error {
 	if version == 0 {
 		// Check if tables exist to determine if this is truly new or legacy
 		var tableCount int
-		err = r.db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type='table' AND name IN ('feeds', 'entries')").Scan(&tableCount)
+		err = r.db.QueryRow(r.dialect.tablesQuery).Scan(&tableCount)
 		if err != nil {
 			return fmt.Errorf("check existing tables: %w", err)
 		}
@@ -155,64 +230,18 @@ func (r *Repository) initSchema() error {
 
 // createInitialSchema creates the complete schema for a new database
 func (r *Repository) createInitialSchema() error {
-	schema := `
-	CREATE TABLE feeds (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		url TEXT NOT NULL UNIQUE,
-		title TEXT,
-		link TEXT,
-		updated TEXT,
-		last_fetched TEXT,
-		etag TEXT,
-		last_modified TEXT,
-		fetch_error TEXT,
-		fetch_error_count INTEGER DEFAULT 0,
-		next_fetch TEXT,
-		active INTEGER DEFAULT 1,
-		fetch_interval INTEGER DEFAULT 3600
-	);
-
-	CREATE TABLE entries (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		feed_id INTEGER NOT NULL,
-		entry_id TEXT NOT NULL,
-		title TEXT,
-		link TEXT,
-		author TEXT,
-		published TEXT,
-		updated TEXT,
-		content TEXT,
-		content_type TEXT DEFAULT 'html',
-		summary TEXT,
-		first_seen TEXT,
-		FOREIGN KEY (feed_id) REFERENCES feeds(id) ON DELETE CASCADE,
-		UNIQUE(feed_id, entry_id)
-	);
-
-	CREATE INDEX idx_entries_published ON entries(published DESC);
-	CREATE INDEX idx_entries_updated ON entries(updated DESC);
-	CREATE INDEX idx_entries_feed_id ON entries(feed_id);
-	CREATE INDEX idx_entries_first_seen ON entries(first_seen DESC);
-	CREATE INDEX idx_feeds_active ON feeds(active);
-	CREATE INDEX idx_feeds_next_fetch ON feeds(next_fetch);
-	`
-
-	_, err := r.db.Exec(schema)
+	_, err := r.db.Exec(r.dialect.schema)
 	return err
 }
 
 // getSchemaVersion returns the current schema version
 func (r *Repository) getSchemaVersion() (int, error) {
-	var version int
-	err := r.db.QueryRow("SELECT MAX(version) FROM schema_version").Scan(&version)
-	if err != nil {
-		// If no rows, version is 0
-		if err.Error() == "sql: Scan error on column index 0, name \"MAX(version)\": converting NULL to int is unsupported" {
-			return 0, nil
-		}
+	// MAX is NULL, so version 0, when no version has been recorded
+	var version sql.NullInt64
+	if err := r.db.QueryRow("SELECT MAX(version) FROM schema_version").Scan(&version); err != nil {
 		return 0, err
 	}
-	return version, nil
+	return int(version.Int64), nil
 }
 
 // setSchemaVersion records that a schema version has been applied
@@ -221,8 +250,13 @@ func (r *Repository) setSchemaVersion(version int) error {
 	return err
 }
 
-// detectLegacyVersion detects the schema version of a pre-migration database
+// detectLegacyVersion detects the schema version of a pre-migration database.
+// Only SQLite databases predate schema versions.
 func (r *Repository) detectLegacyVersion() (int, error) {
+	if r.dialect != sqliteDialect {
+		return 0, errors.New("database has feeds and entries tables but no schema version")
+	}
+
 	// Check if first_seen column exists in entries table
 	var hasFirstSeen bool
 	err := r.db.QueryRow(`
@@ -246,7 +280,32 @@ func (r *Repository) detectLegacyVersion() (int, error) {
 // runMigrations runs all migrations from fromVersion to toVersion
 func (r *Repository) runMigrations(fromVersion, toVersion int) error {
 	migrations := map[int]func() error{
-		2: r.migrateToV2, // Add first_seen column (v0.3.0)
+		2:  r.migrateToV2,  // Add first_seen column (v0.3.0)
+		3:  r.migrateToV3,  // Add fetch_log table
+		4:  r.migrateToV4,  // Add feed_categories table
+		5:  r.migrateToV5,  // Add feeds.cache_expires column
+		6:  r.migrateToV6,  // Add entry_categories table
+		7:  r.migrateToV7,  // Add host_backoff table
+		8:  r.migrateToV8,  // Add feeds.gone_at column
+		9:  r.migrateToV9,  // Add fetch_log transfer columns
+		10: r.migrateToV10, // Add fetch_log duration and entries added
+		11: r.migrateToV11, // Add fetch_queue table
+		12: r.migrateToV12, // Add locks table
+		13: r.migrateToV13, // Add hidden_entries table
+		14: r.migrateToV14, // Add pinned_entries table
+		15: r.migrateToV15, // Add content_hash column to entries
+		16: r.migrateToV16, // Add updated_count and last_significant_update columns to entries
+		17: r.migrateToV17, // Add translations table
+		18: r.migrateToV18, // Add word_count and reading_minutes columns to entries
+		19: r.migrateToV19, // Add image column to entries
+		20: r.migrateToV20, // Add authors, external_url, language, and attachments columns to entries
+		21: r.migrateToV21, // Add read_entries and starred_entries tables
+		22: r.migrateToV22, // Add classifications table
+		23: r.migrateToV23, // Add related_entries and embeddings tables
+		24: r.migrateToV24, // Add feeds.custom_title column
+		25: r.migrateToV25, // Add fetch_log.final_url column
+		26: r.migrateToV26, // Add entry_revisions table
+		27: r.migrateToV27, // Store timestamps in UTC
 	}
 
 	for v := fromVersion + 1; v <= toVersion; v++ {
@@ -257,7 +316,7 @@ func (r *Repository) runMigrations(fromVersion, toVersion int) error {
 		}
 
 		// Run migration in a transaction
-		tx, err := r.db.Begin()
+		tx, err := r.conn.Begin()
 		if err != nil {
 			return fmt.Errorf("begin transaction for v%d: %w", v, err)
 		}
@@ -269,7 +328,7 @@ func (r *Repository) runMigrations(fromVersion, toVersion int) error {
 		}
 
 		// Record version
-		_, err = tx.Exec("INSERT INTO schema_version (version) VALUES (?)", v)
+		_, err = tx.Exec(r.dialect.bind("INSERT INTO schema_version (version) VALUES (?)"), v)
 		if err != nil {
 			_ = tx.Rollback() // Rollback on error; ignore rollback errors
 			return fmt.Errorf("record v%d version: %w", v, err)
@@ -314,27 +373,477 @@ func (r *Repository) migrateToV2() error {
 	return nil
 }
 
+// migrateToV3 adds the fetch_log table for per-fetch diagnostics
+func (r *Repository) migrateToV3() error {
+	_, err := r.db.Exec(`
+		CREATE TABLE IF NOT EXISTS fetch_log (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			feed_id INTEGER NOT NULL,
+			fetched_at TEXT NOT NULL,
+			status_code INTEGER DEFAULT 0,
+			headers TEXT,
+			error TEXT,
+			FOREIGN KEY (feed_id) REFERENCES feeds(id) ON DELETE CASCADE
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("create fetch_log table: %w", err)
+	}
+
+	_, err = r.db.Exec(`CREATE INDEX IF NOT EXISTS idx_fetch_log_feed_id ON fetch_log(feed_id, fetched_at DESC)`)
+	if err != nil {
+		return fmt.Errorf("create fetch_log index: %w", err)
+	}
+
+	return nil
+}
+
+// migrateToV4 adds the feed_categories table for per-feed categories
+func (r *Repository) migrateToV4() error {
+	_, err := r.db.Exec(`
+		CREATE TABLE IF NOT EXISTS feed_categories (
+			feed_id INTEGER NOT NULL,
+			category TEXT NOT NULL,
+			FOREIGN KEY (feed_id) REFERENCES feeds(id) ON DELETE CASCADE,
+			PRIMARY KEY (feed_id, category)
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("create feed_categories table: %w", err)
+	}
+
+	return nil
+}
+
+// migrateToV5 adds the cache_expires column used to skip feeds with fresh HTTP caches
+func (r *Repository) migrateToV5() error {
+	_, err := r.db.Exec(`ALTER TABLE feeds ADD COLUMN cache_expires TEXT`)
+	if err != nil {
+		return fmt.Errorf("add cache_expires column: %w", err)
+	}
+
+	return nil
+}
+
+// migrateToV6 adds the entry_categories table for entry categories/tags
+func (r *Repository) migrateToV6() error {
+	_, err := r.db.Exec(`
+		CREATE TABLE IF NOT EXISTS entry_categories (
+			entry_id INTEGER NOT NULL,
+			category TEXT NOT NULL,
+			FOREIGN KEY (entry_id) REFERENCES entries(id) ON DELETE CASCADE,
+			PRIMARY KEY (entry_id, category)
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("create entry_categories table: %w", err)
+	}
+
+	return nil
+}
+
+// migrateToV7 adds the host_backoff table that carries Retry-After across runs
+func (r *Repository) migrateToV7() error {
+	_, err := r.db.Exec(`
+		CREATE TABLE IF NOT EXISTS host_backoff (
+			host TEXT PRIMARY KEY,
+			until TEXT NOT NULL,
+			status_code INTEGER DEFAULT 0,
+			failures INTEGER DEFAULT 0
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("create host_backoff table: %w", err)
+	}
+
+	return nil
+}
+
+// migrateToV8 adds the gone_at column that marks permanently gone feeds
+func (r *Repository) migrateToV8() error {
+	_, err := r.db.Exec(`ALTER TABLE feeds ADD COLUMN gone_at TEXT`)
+	if err != nil {
+		return fmt.Errorf("add gone_at column: %w", err)
+	}
+
+	return nil
+}
+
+// migrateToV9 adds the protocol, encoding, and byte counts of each fetch to
+// the fetch log
+func (r *Repository) migrateToV9() error {
+	for _, column := range []string{
+		"protocol TEXT",
+		"content_encoding TEXT",
+		"wire_bytes INTEGER DEFAULT 0",
+		"decoded_bytes INTEGER DEFAULT 0",
+	} {
+		if _, err := r.db.Exec(`ALTER TABLE fetch_log ADD COLUMN ` + column); err != nil {
+			return fmt.Errorf("add fetch_log %s column: %w", strings.Fields(column)[0], err)
+		}
+	}
+
+	return nil
+}
+
+// migrateToV10 adds how long each fetch took and how many new entries it
+// stored to the fetch log
+func (r *Repository) migrateToV10() error {
+	for _, column := range []string{
+		"duration_ms INTEGER DEFAULT 0",
+		"entries_added INTEGER DEFAULT 0",
+	} {
+		if _, err := r.db.Exec(`ALTER TABLE fetch_log ADD COLUMN ` + column); err != nil {
+			return fmt.Errorf("add fetch_log %s column: %w", strings.Fields(column)[0], err)
+		}
+	}
+
+	return nil
+}
+
+// migrateToV11 adds the fetch_queue table of feeds an interrupted fetch run
+// did not reach
+func (r *Repository) migrateToV11() error {
+	_, err := r.db.Exec(`
+		CREATE TABLE IF NOT EXISTS fetch_queue (
+			feed_id INTEGER PRIMARY KEY,
+			FOREIGN KEY (feed_id) REFERENCES feeds(id) ON DELETE CASCADE
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("create fetch_queue table: %w", err)
+	}
+
+	return nil
+}
+
+// migrateToV12 adds the locks table that keeps overlapping runs apart
+func (r *Repository) migrateToV12() error {
+	_, err := r.db.Exec(`
+		CREATE TABLE IF NOT EXISTS locks (
+			name TEXT PRIMARY KEY,
+			holder TEXT NOT NULL,
+			acquired_at TEXT NOT NULL,
+			expires_at TEXT NOT NULL
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("create locks table: %w", err)
+	}
+
+	return nil
+}
+
+// migrateToV13 adds the hidden_entries table of entries kept off the site
+func (r *Repository) migrateToV13() error {
+	_, err := r.db.Exec(`
+		CREATE TABLE IF NOT EXISTS hidden_entries (
+			feed_id INTEGER NOT NULL,
+			entry_id TEXT NOT NULL,
+			title TEXT,
+			link TEXT,
+			hidden_at TEXT NOT NULL,
+			FOREIGN KEY (feed_id) REFERENCES feeds(id) ON DELETE CASCADE,
+			PRIMARY KEY (feed_id, entry_id)
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("create hidden_entries table: %w", err)
+	}
+
+	return nil
+}
+
+// migrateToV14 adds the pinned_entries table of entries featured on the site
+func (r *Repository) migrateToV14() error {
+	_, err := r.db.Exec(`
+		CREATE TABLE IF NOT EXISTS pinned_entries (
+			feed_id INTEGER NOT NULL,
+			entry_id TEXT NOT NULL,
+			title TEXT,
+			link TEXT,
+			pinned_at TEXT NOT NULL,
+			FOREIGN KEY (feed_id) REFERENCES feeds(id) ON DELETE CASCADE,
+			PRIMARY KEY (feed_id, entry_id)
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("create pinned_entries table: %w", err)
+	}
+
+	return nil
+}
+
+// migrateToV15 adds the content_hash column that matches entries whose ID
+// changed when they were republished
+func (r *Repository) migrateToV15() error {
+	_, err := r.db.Exec(`ALTER TABLE entries ADD COLUMN content_hash TEXT NOT NULL DEFAULT ''`)
+	if err != nil {
+		return fmt.Errorf("add content_hash column: %w", err)
+	}
+
+	_, err = r.db.Exec(`CREATE INDEX idx_entries_content_hash ON entries(feed_id, content_hash)`)
+	if err != nil {
+		return fmt.Errorf("create content_hash index: %w", err)
+	}
+
+	return nil
+}
+
+// migrateToV16 adds the columns that count material changes to an entry's
+// content and record when the last one was seen
+func (r *Repository) migrateToV16() error {
+	for _, column := range []string{
+		"updated_count INTEGER NOT NULL DEFAULT 0",
+		"last_significant_update TEXT",
+	} {
+		if _, err := r.db.Exec(`ALTER TABLE entries ADD COLUMN ` + column); err != nil {
+			return fmt.Errorf("add entries %s column: %w", strings.Fields(column)[0], err)
+		}
+	}
+
+	_, err := r.db.Exec(`CREATE INDEX idx_entries_last_significant_update ON entries(last_significant_update DESC)`)
+	if err != nil {
+		return fmt.Errorf("create last_significant_update index: %w", err)
+	}
+
+	return nil
+}
+
+// migrateToV17 adds the translations table caching machine translations
+func (r *Repository) migrateToV17() error {
+	_, err := r.db.Exec(`
+		CREATE TABLE IF NOT EXISTS translations (
+			source_hash TEXT NOT NULL,
+			language TEXT NOT NULL,
+			text TEXT NOT NULL,
+			created_at TEXT NOT NULL,
+			PRIMARY KEY (source_hash, language)
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("create translations table: %w", err)
+	}
+
+	return nil
+}
+
+// migrateToV18 adds the word count and estimated reading time of entries.
+// Entries stored before it have zeros until they are fetched again.
+func (r *Repository) migrateToV18() error {
+	for _, column := range []string{
+		"word_count INTEGER NOT NULL DEFAULT 0",
+		"reading_minutes INTEGER NOT NULL DEFAULT 0",
+	} {
+		if _, err := r.db.Exec(`ALTER TABLE entries ADD COLUMN ` + column); err != nil {
+			return fmt.Errorf("add entries %s column: %w", strings.Fields(column)[0], err)
+		}
+	}
+	return nil
+}
+
+// migrateToV19 adds the column holding each entry's representative image
+func (r *Repository) migrateToV19() error {
+	_, err := r.db.Exec(`ALTER TABLE entries ADD COLUMN image TEXT NOT NULL DEFAULT ''`)
+	if err != nil {
+		return fmt.Errorf("add image column: %w", err)
+	}
+	return nil
+}
+
+// migrateToV20 adds the columns holding each entry's authors, external
+// URL, language, and attachments
+func (r *Repository) migrateToV20() error {
+	for _, column := range []string{
+		"authors TEXT NOT NULL DEFAULT ''",
+		"external_url TEXT NOT NULL DEFAULT ''",
+		"language TEXT NOT NULL DEFAULT ''",
+		"attachments TEXT NOT NULL DEFAULT ''",
+	} {
+		if _, err := r.db.Exec(`ALTER TABLE entries ADD COLUMN ` + column); err != nil {
+			return fmt.Errorf("add entries %s column: %w", strings.Fields(column)[0], err)
+		}
+	}
+	return nil
+}
+
+// migrateToV21 adds the read_entries and starred_entries tables of the
+// reader's state
+func (r *Repository) migrateToV21() error {
+	for _, table := range []string{readEntries, starredEntries} {
+		_, err := r.db.Exec(`
+			CREATE TABLE IF NOT EXISTS ` + table + ` (
+				feed_id INTEGER NOT NULL,
+				entry_id TEXT NOT NULL,
+				title TEXT,
+				link TEXT,
+				` + markedAt[table] + ` TEXT NOT NULL,
+				FOREIGN KEY (feed_id) REFERENCES feeds(id) ON DELETE CASCADE,
+				PRIMARY KEY (feed_id, entry_id)
+			)
+		`)
+		if err != nil {
+			return fmt.Errorf("create %s table: %w", table, err)
+		}
+	}
+	return nil
+}
+
+// migrateToV22 adds the classifications table caching the topics a
+// classification service gave entry texts
+func (r *Repository) migrateToV22() error {
+	_, err := r.db.Exec(`
+		CREATE TABLE IF NOT EXISTS classifications (
+			source_hash TEXT PRIMARY KEY,
+			topics TEXT NOT NULL,
+			created_at TEXT NOT NULL
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("create classifications table: %w", err)
+	}
+	return nil
+}
+
+// migrateToV23 adds the related_entries table linking entries to related
+// entries, and the embeddings table caching the embeddings of entry texts
+func (r *Repository) migrateToV23() error {
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS related_entries (
+			entry_id INTEGER NOT NULL,
+			related_id INTEGER NOT NULL,
+			score REAL NOT NULL,
+			FOREIGN KEY (entry_id) REFERENCES entries(id) ON DELETE CASCADE,
+			FOREIGN KEY (related_id) REFERENCES entries(id) ON DELETE CASCADE,
+			PRIMARY KEY (entry_id, related_id)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_related_entries_related ON related_entries(related_id)`,
+		`CREATE TABLE IF NOT EXISTS embeddings (
+			source_hash TEXT PRIMARY KEY,
+			vector TEXT NOT NULL,
+			created_at TEXT NOT NULL
+		)`,
+	}
+	for _, stmt := range statements {
+		if _, err := r.db.Exec(stmt); err != nil {
+			return fmt.Errorf("create related entries tables: %w", err)
+		}
+	}
+	return nil
+}
+
+// migrateToV24 adds the custom_title column that keeps a title set with
+// EditFeed over the feed's own
+func (r *Repository) migrateToV24() error {
+	_, err := r.db.Exec(`ALTER TABLE feeds ADD COLUMN custom_title INTEGER DEFAULT 0`)
+	if err != nil {
+		return fmt.Errorf("add custom_title column: %w", err)
+	}
+
+	return nil
+}
+
+// migrateToV25 adds the URL each fetch ended at after redirects to the fetch
+// log
+func (r *Repository) migrateToV25() error {
+	_, err := r.db.Exec(`ALTER TABLE fetch_log ADD COLUMN final_url TEXT`)
+	if err != nil {
+		return fmt.Errorf("add fetch_log final_url column: %w", err)
+	}
+
+	return nil
+}
+
+// utcColumns are the timestamp columns once written in local or feed time,
+// by table
+var utcColumns = []struct {
+	table   string
+	columns []string
+}{
+	{"entries", []string{"published", "updated", "first_seen", "last_significant_update"}},
+	{"feeds", []string{"updated", "last_fetched", "next_fetch", "cache_expires", "gone_at"}},
+	{"fetch_log", []string{"fetched_at"}},
+}
+
+// migrateToV27 rewrites timestamps stored with a UTC offset in UTC.
+// Timestamps are compared and sorted as text, which only orders them
+// correctly when they share an offset.
+func (r *Repository) migrateToV27() error {
+	for _, t := range utcColumns {
+		for _, column := range t.columns {
+			if err := r.rewriteUTC(t.table, column); err != nil {
+				return fmt.Errorf("rewrite %s.%s in UTC: %w", t.table, column, err)
+			}
+		}
+	}
+	return nil
+}
+
+// rewriteUTC rewrites the RFC 3339 timestamps in a column of table that
+// are not in UTC. Values that don't parse are left alone.
+func (r *Repository) rewriteUTC(table, column string) error {
+	rows, err := r.db.Query(`SELECT id, ` + column + ` FROM ` + table + ` WHERE ` + column + ` IS NOT NULL AND ` + column + ` NOT LIKE '%Z'`)
+	if err != nil {
+		return err
+	}
+	updates := make(map[int64]string)
+	for rows.Next() {
+		var id int64
+		var value string
+		if err := rows.Scan(&id, &value); err != nil {
+			rows.Close()
+			return err
+		}
+		if t, err := time.Parse(time.RFC3339, value); err == nil {
+			updates[id] = t.UTC().Format(time.RFC3339)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for id, value := range updates {
+		if _, err := r.db.Exec(`UPDATE `+table+` SET `+column+` = ? WHERE id = ?`, value, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateToV26 adds the entry_revisions table keeping entries' content from
+// before it changed
+func (r *Repository) migrateToV26() error {
+	if _, err := r.db.Exec(r.dialect.revisions); err != nil {
+		return fmt.Errorf("create entry_revisions table: %w", err)
+	}
+
+	return nil
+}
+
 // AddFeed adds a new feed to the database
 func (r *Repository) AddFeed(ctx context.Context, url, title string) (int64, error) {
-	result, err := r.db.ExecContext(ctx, `
+	var id int64
+	err := r.db.QueryRowContext(ctx, `
 		INSERT INTO feeds (url, title, next_fetch)
 		VALUES (?, ?, ?)
-	`, url, title, time.Now().Format(time.RFC3339))
+		RETURNING id
+	`, url, title, time.Now().UTC().Format(time.RFC3339)).Scan(&id)
 
 	if err != nil {
 		return 0, fmt.Errorf("insert feed: %w", err)
 	}
 
-	return result.LastInsertId()
+	return id, nil
 }
 
-// UpdateFeed updates feed metadata
+// UpdateFeed updates feed metadata. A custom title set with EditFeed is kept.
 func (r *Repository) UpdateFeed(ctx context.Context, id int64, title, link string, updated time.Time) error {
 	_, err := r.db.ExecContext(ctx, `
 		UPDATE feeds
-		SET title = ?, link = ?, updated = ?
+		SET title = CASE WHEN custom_title = 1 THEN title ELSE ? END, link = ?, updated = ?
 		WHERE id = ?
-	`, title, link, updated.Format(time.RFC3339), id)
+	`, title, link, updated.UTC().Format(time.RFC3339), id)
 
 	if err != nil {
 		return fmt.Errorf("update feed: %w", err)
@@ -349,7 +858,7 @@ func (r *Repository) UpdateFeedCache(ctx context.Context, id int64, etag, lastMo
 		UPDATE feeds
 		SET etag = ?, last_modified = ?, last_fetched = ?, fetch_error = NULL, fetch_error_count = 0
 		WHERE id = ?
-	`, etag, lastModified, lastFetched.Format(time.RFC3339), id)
+	`, etag, lastModified, lastFetched.UTC().Format(time.RFC3339), id)
 
 	if err != nil {
 		return fmt.Errorf("update feed cache: %w", err)
@@ -358,13 +867,81 @@ func (r *Repository) UpdateFeedCache(ctx context.Context, id int64, etag, lastMo
 	return nil
 }
 
+// UpdateFeedCacheExpiry records when the feed's HTTP cache lifetime ends.
+// A zero time clears it so the feed is fetched on the next run.
+func (r *Repository) UpdateFeedCacheExpiry(ctx context.Context, id int64, expires time.Time) error {
+	var value sql.NullString
+	if !expires.IsZero() {
+		value = sql.NullString{String: expires.UTC().Format(time.RFC3339), Valid: true}
+	}
+
+	_, err := r.db.ExecContext(ctx, "UPDATE feeds SET cache_expires = ? WHERE id = ?", value, id)
+	if err != nil {
+		return fmt.Errorf("update feed cache expiry: %w", err)
+	}
+
+	return nil
+}
+
+// UpdateFeedSchedule records the feed's computed fetch interval and next due time
+func (r *Repository) UpdateFeedSchedule(ctx context.Context, id int64, interval time.Duration, nextFetch time.Time) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE feeds
+		SET fetch_interval = ?, next_fetch = ?
+		WHERE id = ?
+	`, int(interval/time.Second), nextFetch.UTC().Format(time.RFC3339), id)
+
+	if err != nil {
+		return fmt.Errorf("update feed schedule: %w", err)
+	}
+
+	return nil
+}
+
+// GetEntryTimes returns the publication times of a feed's most recent entries,
+// newest first. Entries without a published date use their first_seen time.
+func (r *Repository) GetEntryTimes(ctx context.Context, feedID int64, limit int) ([]time.Time, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT t FROM (
+			SELECT CASE
+				WHEN published IS NULL OR published = '' OR published LIKE '0001-%' THEN first_seen
+				ELSE published
+			END AS t
+			FROM entries
+			WHERE feed_id = ?
+		) AS times
+		WHERE t IS NOT NULL AND t != ''
+		ORDER BY t DESC
+		LIMIT ?
+	`, feedID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("query entry times: %w", err)
+	}
+	defer rows.Close()
+
+	var times []time.Time
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			return nil, err
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid entry timestamp %q: %w", value, err)
+		}
+		times = append(times, t)
+	}
+
+	return times, rows.Err()
+}
+
 // UpdateFeedError records a fetch error for a feed
 func (r *Repository) UpdateFeedError(ctx context.Context, id int64, errorMsg string) error {
 	_, err := r.db.ExecContext(ctx, `
 		UPDATE feeds
 		SET fetch_error = ?, fetch_error_count = fetch_error_count + 1, last_fetched = ?
 		WHERE id = ?
-	`, errorMsg, time.Now().Format(time.RFC3339), id)
+	`, errorMsg, time.Now().UTC().Format(time.RFC3339), id)
 
 	if err != nil {
 		return fmt.Errorf("update feed error: %w", err)
@@ -373,12 +950,68 @@ func (r *Repository) UpdateFeedError(ctx context.Context, id int64, errorMsg str
 	return nil
 }
 
+// UpdateFeedNextFetch sets when the feed is next due, without changing its
+// fetch interval. Failing feeds use it to back off.
+func (r *Repository) UpdateFeedNextFetch(ctx context.Context, id int64, nextFetch time.Time) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE feeds SET next_fetch = ? WHERE id = ?
+	`, nextFetch.UTC().Format(time.RFC3339), id)
+
+	if err != nil {
+		return fmt.Errorf("update feed next fetch: %w", err)
+	}
+
+	return nil
+}
+
+// DeactivateFeed marks a feed inactive so it is no longer fetched. Its
+// entries stay in the database and its last error is kept for diagnosis.
+func (r *Repository) DeactivateFeed(ctx context.Context, id int64) error {
+	if _, err := r.db.ExecContext(ctx, `UPDATE feeds SET active = 0 WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("deactivate feed: %w", err)
+	}
+	return nil
+}
+
+// MarkFeedGone records that a feed is permanently gone and deactivates it
+func (r *Repository) MarkFeedGone(ctx context.Context, id int64, at time.Time) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE feeds SET active = 0, gone_at = ? WHERE id = ?
+	`, at.UTC().Format(time.RFC3339), id)
+	if err != nil {
+		return fmt.Errorf("mark feed gone: %w", err)
+	}
+	return nil
+}
+
+// ReactivateFeed marks a feed active (and no longer gone), clears its error
+// history, and makes it due on the next fetch
+func (r *Repository) ReactivateFeed(ctx context.Context, id int64) error {
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE feeds
+		SET active = 1, gone_at = NULL, fetch_error = NULL, fetch_error_count = 0, next_fetch = ?
+		WHERE id = ?
+	`, time.Now().UTC().Format(time.RFC3339), id)
+	if err != nil {
+		return fmt.Errorf("reactivate feed: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("reactivate feed: %w", err)
+	}
+	if rows == 0 {
+		return ErrFeedNotFound
+	}
+	return nil
+}
+
 // UpdateFeedURL updates the URL of a feed (typically after a 301 permanent redirect).
 // This also resets the ETag and Last-Modified headers since they're associated with the old URL.
 func (r *Repository) UpdateFeedURL(ctx context.Context, id int64, newURL string) error {
 	_, err := r.db.ExecContext(ctx, `
 		UPDATE feeds
-		SET url = ?, etag = NULL, last_modified = NULL
+		SET url = ?, etag = NULL, last_modified = NULL, cache_expires = NULL
 		WHERE id = ?
 	`, newURL, id)
 
@@ -389,9 +1022,12 @@ func (r *Repository) UpdateFeedURL(ctx context.Context, id int64, newURL string)
 	return nil
 }
 
+// feedColumns lists the feeds columns read by scanFeed, in order
+const feedColumns = "id, url, title, link, updated, last_fetched, etag, last_modified, fetch_error, fetch_error_count, next_fetch, active, fetch_interval, cache_expires, gone_at, custom_title"
+
 // GetFeeds returns all feeds, optionally filtering by active status
 func (r *Repository) GetFeeds(ctx context.Context, activeOnly bool) ([]Feed, error) {
-	query := "SELECT id, url, title, link, updated, last_fetched, etag, last_modified, fetch_error, fetch_error_count, next_fetch, active, fetch_interval FROM feeds"
+	query := "SELECT " + feedColumns + " FROM feeds"
 	if activeOnly {
 		query += " WHERE active = 1"
 	}
@@ -409,7 +1045,7 @@ func (r *Repository) GetFeeds(ctx context.Context, activeOnly bool) ([]Feed, err
 // GetFeedByURL returns a feed by its URL
 func (r *Repository) GetFeedByURL(ctx context.Context, url string) (*Feed, error) {
 	row := r.db.QueryRowContext(ctx, `
-		SELECT id, url, title, link, updated, last_fetched, etag, last_modified, fetch_error, fetch_error_count, next_fetch, active, fetch_interval
+		SELECT `+feedColumns+`
 		FROM feeds
 		WHERE url = ?
 	`, url)
@@ -426,6 +1062,26 @@ func (r *Repository) GetFeedByURL(ctx context.Context, url string) (*Feed, error
 	return feed, nil
 }
 
+// GetFeedByID returns a feed by its ID
+func (r *Repository) GetFeedByID(ctx context.Context, id int64) (*Feed, error) {
+	row := r.db.QueryRowContext(ctx, `
+		SELECT `+feedColumns+`
+		FROM feeds
+		WHERE id = ?
+	`, id)
+
+	feed := &Feed{}
+	err := scanFeed(row, feed)
+	if err == sql.ErrNoRows {
+		return nil, ErrFeedNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query feed: %w", err)
+	}
+
+	return feed, nil
+}
+
 // RemoveFeed removes a feed and all its entries
 func (r *Repository) RemoveFeed(ctx context.Context, id int64) error {
 	_, err := r.db.ExecContext(ctx, "DELETE FROM feeds WHERE id = ?", id)
@@ -439,29 +1095,192 @@ func (r *Repository) RemoveFeed(ctx context.Context, id int64) error {
 // UpsertEntry inserts or updates an entry.
 // On conflict (duplicate feed_id + entry_id), updates content fields but preserves
 // first_seen to maintain the original discovery timestamp for spam prevention.
+// When the content hash changes, the update is counted as significant and
+// entry.FirstSeen (the time of this fetch) is recorded as the entry's
+// last_significant_update. Entries stored without a hash are not counted.
+// With a RevisionRetention set, the content a significant change replaces
+// is kept as a revision.
 func (r *Repository) UpsertEntry(ctx context.Context, entry *Entry) error {
-	_, err := r.db.ExecContext(ctx, `
-		INSERT INTO entries (feed_id, entry_id, title, link, author, published, updated, content, content_type, summary, first_seen)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	attachments, err := encodeAttachments(entry.Attachments)
+	if err != nil {
+		return err
+	}
+	if err := r.recordRevision(ctx, entry); err != nil {
+		return err
+	}
+	_, err = r.db.ExecContext(ctx, `
+		INSERT INTO entries (feed_id, entry_id, title, link, author, published, updated, content, content_type, summary, first_seen, content_hash, word_count, reading_minutes, image,
+		                     authors, external_url, language, attachments)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 		ON CONFLICT(feed_id, entry_id) DO UPDATE SET
 			title = excluded.title,
 			link = excluded.link,
 			author = excluded.author,
+			authors = excluded.authors,
+			external_url = excluded.external_url,
+			language = excluded.language,
+			attachments = excluded.attachments,
 			updated = excluded.updated,
 			content = excluded.content,
-			summary = excluded.summary
+			summary = excluded.summary,
+			content_hash = excluded.content_hash,
+			word_count = excluded.word_count,
+			reading_minutes = excluded.reading_minutes,
+			-- An image found on the entry's page when it was new is kept
+			image = CASE WHEN excluded.image <> '' THEN excluded.image ELSE entries.image END,
+			updated_count = entries.updated_count + CASE WHEN `+significantChange+` THEN 1 ELSE 0 END,
+			last_significant_update = CASE WHEN `+significantChange+` THEN excluded.first_seen ELSE entries.last_significant_update END
 			-- first_seen deliberately NOT updated to preserve original discovery time
 	`, entry.FeedID, entry.EntryID, entry.Title, entry.Link, entry.Author,
-		entry.Published.Format(time.RFC3339), entry.Updated.Format(time.RFC3339),
-		entry.Content, entry.ContentType, entry.Summary, entry.FirstSeen.Format(time.RFC3339))
+		entry.Published.UTC().Format(time.RFC3339), entry.Updated.UTC().Format(time.RFC3339),
+		entry.Content, entry.ContentType, entry.Summary, entry.FirstSeen.UTC().Format(time.RFC3339),
+		entry.ContentHash, entry.WordCount, entry.ReadingMinutes, entry.Image,
+		strings.Join(entry.Authors, authorSeparator), entry.ExternalURL, entry.Language, attachments)
 
 	if err != nil {
 		return fmt.Errorf("upsert entry: %w", err)
 	}
 
+	return r.setEntryCategories(ctx, entry)
+}
+
+// significantChange is true in an upsert whose content hash differs from
+// the stored one. SET expressions see the row as it was before the update.
+const significantChange = `entries.content_hash <> '' AND excluded.content_hash <> '' AND entries.content_hash <> excluded.content_hash`
+
+// GetStoredEntryIDs reports which of a feed's entry IDs are already stored
+func (r *Repository) GetStoredEntryIDs(ctx context.Context, feedID int64, entryIDs []string) (map[string]bool, error) {
+	values, err := r.entryValues(ctx, feedID, entryIDs, "entry_id")
+	if err != nil {
+		return nil, err
+	}
+	stored := make(map[string]bool, len(values))
+	for id := range values {
+		stored[id] = true
+	}
+	return stored, nil
+}
+
+// GetEntryContents returns the stored content of a feed's entries, keyed by
+// entry ID. Entries that are not stored are missing from the result.
+func (r *Repository) GetEntryContents(ctx context.Context, feedID int64, entryIDs []string) (map[string]string, error) {
+	return r.entryValues(ctx, feedID, entryIDs, "content")
+}
+
+// FindEntryByContent returns the ID of a feed's stored entry with the given
+// link and content hash, or "" if there is none. When several match, the
+// most recently stored wins.
+func (r *Repository) FindEntryByContent(ctx context.Context, feedID int64, link, contentHash string) (string, error) {
+	if link == "" || contentHash == "" {
+		return "", nil
+	}
+	var entryID string
+	err := r.db.QueryRowContext(ctx, `
+		SELECT entry_id FROM entries
+		WHERE feed_id = ? AND content_hash = ? AND link = ?
+		ORDER BY id DESC
+		LIMIT 1
+	`, feedID, contentHash, link).Scan(&entryID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("find entry by content: %w", err)
+	}
+	return entryID, nil
+}
+
+// RekeyEntry changes a stored entry's ID, for an entry its feed republished
+// under a new ID. Its hidden, pinned, read, and starred state move with it.
+func (r *Repository) RekeyEntry(ctx context.Context, feedID int64, oldID, newID string) error {
+	tx, err := r.beginTx(ctx)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }() // No-op after commit
+
+	for _, table := range []string{"entries", hiddenEntries, pinnedEntries, readEntries, starredEntries} {
+		_, err := tx.ExecContext(ctx, `UPDATE `+table+` SET entry_id = ? WHERE feed_id = ? AND entry_id = ?`, newID, feedID, oldID)
+		if err != nil {
+			return fmt.Errorf("rekey entry %s in %s: %w", oldID, table, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit entry rekey: %w", err)
+	}
 	return nil
 }
 
+// entryValues returns one column of a feed's stored entries, keyed by entry ID
+func (r *Repository) entryValues(ctx context.Context, feedID int64, entryIDs []string, column string) (map[string]string, error) {
+	values := make(map[string]string, len(entryIDs))
+	// Stay well under SQLite's limit on bound parameters
+	const batch = 500
+	for start := 0; start < len(entryIDs); start += batch {
+		ids := entryIDs[start:min(start+batch, len(entryIDs))]
+		args := make([]interface{}, 0, len(ids)+1)
+		args = append(args, feedID)
+		for _, id := range ids {
+			args = append(args, id)
+		}
+		placeholders := strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",")
+
+		rows, err := r.db.QueryContext(ctx,
+			`SELECT entry_id, `+column+` FROM entries WHERE feed_id = ? AND entry_id IN (`+placeholders+`)`, args...)
+		if err != nil {
+			return nil, fmt.Errorf("query entry %s: %w", column, err)
+		}
+		for rows.Next() {
+			var id, value string
+			if err := rows.Scan(&id, &value); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("scan entry %s: %w", column, err)
+			}
+			values[id] = value
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, fmt.Errorf("iterate entry %s: %w", column, err)
+		}
+	}
+	return values, nil
+}
+
+// entryColumns lists the columns read by scanEntries. Categories are joined
+// into one value separated by categorySeparator.
+func (r *Repository) entryColumns() string {
+	return `e.id, e.feed_id, e.entry_id, e.title, e.link, e.author,
+		       e.published, e.updated, e.content, e.content_type, e.summary, e.first_seen, e.content_hash,
+		       e.updated_count, e.last_significant_update, e.word_count, e.reading_minutes, e.image,
+		       e.authors, e.external_url, e.language, e.attachments,
+		       (SELECT ` + fmt.Sprintf(r.dialect.joinValues, "ec.category") + ` FROM entry_categories ec WHERE ec.entry_id = e.id)`
+}
+
+// categorySeparator joins entry categories in query results (ASCII unit separator)
+const categorySeparator = "\x1f"
+
+// authorSeparator joins an entry's authors in the authors column (ASCII unit separator)
+const authorSeparator = "\x1f"
+
+// encodeAttachments returns attachments as stored in the attachments column
+func encodeAttachments(attachments []Attachment) (string, error) {
+	if len(attachments) == 0 {
+		return "", nil
+	}
+	data, err := json.Marshal(attachments)
+	if err != nil {
+		return "", fmt.Errorf("encode attachments: %w", err)
+	}
+	return string(data), nil
+}
+
+// entryTieBreaker orders entries that share a timestamp. Without it SQLite
+// returns ties in storage order, which can change between runs and produce
+// noisy diffs in published sites.
+const entryTieBreaker = "e.feed_id ASC, e.entry_id ASC"
+
 // GetRecentEntries returns entries from the last N days.
 // If no entries are found in that time window, it falls back to returning
 // the most recent 50 entries to ensure the page always has content.
@@ -470,12 +1289,12 @@ func (r *Repository) GetRecentEntries(ctx context.Context, days int) ([]Entry, e
 
 	// First, try to get entries from the last N days
 	rows, err := r.db.QueryContext(ctx, `
-		SELECT e.id, e.feed_id, e.entry_id, e.title, e.link, e.author, e.published, e.updated, e.content, e.content_type, e.summary, e.first_seen
+		SELECT `+r.entryColumns()+`
 		FROM entries e
 		JOIN feeds f ON e.feed_id = f.id
-		WHERE f.active = 1 AND e.published >= ?
-		ORDER BY e.published DESC
-	`, cutoff.Format(time.RFC3339))
+		WHERE f.active = 1 AND e.published >= ? AND `+notHidden+`
+		ORDER BY e.published DESC, `+entryTieBreaker+`
+	`, cutoff.UTC().Format(time.RFC3339))
 
 	if err != nil {
 		return nil, fmt.Errorf("query entries: %w", err)
@@ -495,11 +1314,11 @@ func (r *Repository) GetRecentEntries(ctx context.Context, days int) ([]Entry, e
 	// Otherwise, fall back to the most recent 50 entries regardless of date
 	// This ensures the page always has content even if feeds are stale
 	rows, err = r.db.QueryContext(ctx, `
-		SELECT e.id, e.feed_id, e.entry_id, e.title, e.link, e.author, e.published, e.updated, e.content, e.content_type, e.summary, e.first_seen
+		SELECT `+r.entryColumns()+`
 		FROM entries e
 		JOIN feeds f ON e.feed_id = f.id
-		WHERE f.active = 1
-		ORDER BY e.published DESC
+		WHERE f.active = 1 AND `+notHidden+`
+		ORDER BY e.published DESC, `+entryTieBreaker+`
 		LIMIT 50
 	`)
 
@@ -538,15 +1357,14 @@ func (r *Repository) GetRecentEntriesWithOptions(ctx context.Context, days int,
 	}
 
 	query := fmt.Sprintf(`
-		SELECT e.id, e.feed_id, e.entry_id, e.title, e.link, e.author,
-		       e.published, e.updated, e.content, e.content_type, e.summary, e.first_seen
+		SELECT %s
 		FROM entries e
 		JOIN feeds f ON e.feed_id = f.id
-		WHERE f.active = 1 AND %s >= ?
-		ORDER BY %s DESC
-	`, filterField, sortField)
+		WHERE f.active = 1 AND %s >= ? AND %s
+		ORDER BY %s DESC, %s
+	`, r.entryColumns(), filterField, notHidden, sortField, entryTieBreaker)
 
-	rows, err := r.db.QueryContext(ctx, query, cutoff.Format(time.RFC3339))
+	rows, err := r.db.QueryContext(ctx, query, cutoff.UTC().Format(time.RFC3339))
 	if err != nil {
 		return nil, fmt.Errorf("query entries: %w", err)
 	}
@@ -564,14 +1382,13 @@ func (r *Repository) GetRecentEntriesWithOptions(ctx context.Context, days int,
 
 	// Fallback to most recent 50 entries (use same sort field)
 	query = fmt.Sprintf(`
-		SELECT e.id, e.feed_id, e.entry_id, e.title, e.link, e.author,
-		       e.published, e.updated, e.content, e.content_type, e.summary, e.first_seen
+		SELECT %s
 		FROM entries e
 		JOIN feeds f ON e.feed_id = f.id
-		WHERE f.active = 1
-		ORDER BY %s DESC
+		WHERE f.active = 1 AND %s
+		ORDER BY %s DESC, %s
 		LIMIT 50
-	`, sortField)
+	`, r.entryColumns(), notHidden, sortField, entryTieBreaker)
 
 	rows, err = r.db.QueryContext(ctx, query)
 	if err != nil {
@@ -582,6 +1399,46 @@ func (r *Repository) GetRecentEntriesWithOptions(ctx context.Context, days int,
 	return scanEntries(rows)
 }
 
+// GetUpdatedEntries returns entries from active feeds whose content last
+// changed materially at or after since, most recently changed first
+func (r *Repository) GetUpdatedEntries(ctx context.Context, since time.Time) ([]Entry, error) {
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM entries e
+		JOIN feeds f ON e.feed_id = f.id
+		WHERE f.active = 1 AND e.last_significant_update >= ? AND %s
+		ORDER BY e.last_significant_update DESC, %s
+	`, r.entryColumns(), notHidden, entryTieBreaker)
+
+	rows, err := r.db.QueryContext(ctx, query, since.UTC().Format(time.RFC3339))
+	if err != nil {
+		return nil, fmt.Errorf("query updated entries: %w", err)
+	}
+	defer rows.Close()
+
+	return scanEntries(rows)
+}
+
+// GetNewEntries returns entries from active feeds first seen at or after
+// since, newest first
+func (r *Repository) GetNewEntries(ctx context.Context, since time.Time) ([]Entry, error) {
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM entries e
+		JOIN feeds f ON e.feed_id = f.id
+		WHERE f.active = 1 AND e.first_seen >= ? AND %s
+		ORDER BY e.published DESC, %s
+	`, r.entryColumns(), notHidden, entryTieBreaker)
+
+	rows, err := r.db.QueryContext(ctx, query, since.UTC().Format(time.RFC3339))
+	if err != nil {
+		return nil, fmt.Errorf("query new entries: %w", err)
+	}
+	defer rows.Close()
+
+	return scanEntries(rows)
+}
+
 // CountEntries returns the total number of entries in the database
 func (r *Repository) CountEntries(ctx context.Context) (int64, error) {
 	var count int64
@@ -601,7 +1458,7 @@ func (r *Repository) CountRecentEntries(ctx context.Context, days int) (int64, e
 		SELECT COUNT(*)
 		FROM entries
 		WHERE published >= ?
-	`, cutoff.Format(time.RFC3339)).Scan(&count)
+	`, cutoff.UTC().Format(time.RFC3339)).Scan(&count)
 
 	if err != nil {
 		return 0, fmt.Errorf("count recent entries: %w", err)
@@ -631,7 +1488,7 @@ func (r *Repository) PruneOldEntries(ctx context.Context, days int) (int64, erro
 	result, err := r.db.ExecContext(ctx, `
 		DELETE FROM entries
 		WHERE published < ?
-	`, cutoff.Format(time.RFC3339))
+	`, cutoff.UTC().Format(time.RFC3339))
 
 	if err != nil {
 		return 0, fmt.Errorf("prune entries: %w", err)
@@ -668,8 +1525,8 @@ func nullBool(ni sql.NullInt64) bool {
 }
 
 func scanFeed(row interface{ Scan(...interface{}) error }, feed *Feed) error {
-	var title, link, updated, lastFetched, etag, lastModified, fetchError, nextFetch sql.NullString
-	var active sql.NullInt64
+	var title, link, updated, lastFetched, etag, lastModified, fetchError, nextFetch, cacheExpires, goneAt sql.NullString
+	var active, customTitle sql.NullInt64
 
 	err := row.Scan(
 		&feed.ID, &feed.URL, &title, &link,
@@ -677,6 +1534,7 @@ func scanFeed(row interface{ Scan(...interface{}) error }, feed *Feed) error {
 		&etag, &lastModified,
 		&fetchError, &feed.FetchErrorCount,
 		&nextFetch, &active, &feed.FetchInterval,
+		&cacheExpires, &goneAt, &customTitle,
 	)
 
 	if err != nil {
@@ -690,6 +1548,7 @@ func scanFeed(row interface{ Scan(...interface{}) error }, feed *Feed) error {
 	feed.LastModified = nullString(lastModified)
 	feed.FetchError = nullString(fetchError)
 	feed.Active = nullBool(active)
+	feed.CustomTitle = nullBool(customTitle)
 
 	// Parse times with error handling
 	if feed.Updated, err = nullTime(updated, "updated"); err != nil {
@@ -701,6 +1560,12 @@ func scanFeed(row interface{ Scan(...interface{}) error }, feed *Feed) error {
 	if feed.NextFetch, err = nullTime(nextFetch, "next_fetch"); err != nil {
 		return err
 	}
+	if feed.CacheExpires, err = nullTime(cacheExpires, "cache_expires"); err != nil {
+		return err
+	}
+	if feed.GoneAt, err = nullTime(goneAt, "gone_at"); err != nil {
+		return err
+	}
 
 	return nil
 }
@@ -723,46 +1588,116 @@ func scanEntries(rows *sql.Rows) ([]Entry, error) {
 	var entries []Entry
 
 	for rows.Next() {
-		var entry Entry
-		var title, link, author, content, contentType, summary sql.NullString
-		var published, updated, firstSeen string
-
-		err := rows.Scan(
-			&entry.ID, &entry.FeedID, &entry.EntryID,
-			&title, &link, &author,
-			&published, &updated,
-			&content, &contentType, &summary,
-			&firstSeen,
-		)
-
+		entry, err := scanEntry(rows)
 		if err != nil {
 			return nil, err
 		}
+		entries = append(entries, entry)
+	}
 
-		// Use helper functions for NULL handling
-		entry.Title = nullString(title)
-		entry.Link = nullString(link)
-		entry.Author = nullString(author)
-		entry.Content = nullString(content)
-		entry.ContentType = nullString(contentType)
-		entry.Summary = nullString(summary)
+	return entries, rows.Err()
+}
 
-		// Parse times (required fields in database)
-		entry.Published, err = time.Parse(time.RFC3339, published)
-		if err != nil {
-			return nil, fmt.Errorf("invalid published timestamp %q for entry %s: %w", published, entry.EntryID, err)
-		}
-		entry.Updated, err = time.Parse(time.RFC3339, updated)
-		if err != nil {
-			return nil, fmt.Errorf("invalid updated timestamp %q for entry %s: %w", updated, entry.EntryID, err)
+// scanEntry reads the entry at the current row, whose columns are those of
+// entryColumns
+func scanEntry(rows *sql.Rows) (Entry, error) {
+	var entry Entry
+	var title, link, author, content, contentType, summary, categories sql.NullString
+	var authors, attachments string
+	var published, updated, firstSeen string
+	var lastUpdate sql.NullString
+
+	err := rows.Scan(
+		&entry.ID, &entry.FeedID, &entry.EntryID,
+		&title, &link, &author,
+		&published, &updated,
+		&content, &contentType, &summary,
+		&firstSeen, &entry.ContentHash,
+		&entry.UpdatedCount, &lastUpdate, &entry.WordCount, &entry.ReadingMinutes, &entry.Image,
+		&authors, &entry.ExternalURL, &entry.Language, &attachments, &categories,
+	)
+
+	if err != nil {
+		return Entry{}, err
+	}
+
+	// Use helper functions for NULL handling
+	entry.Title = nullString(title)
+	entry.Link = nullString(link)
+	entry.Author = nullString(author)
+	entry.Content = nullString(content)
+	entry.ContentType = nullString(contentType)
+	entry.Summary = nullString(summary)
+	if categories.Valid && categories.String != "" {
+		entry.Categories = strings.Split(categories.String, categorySeparator)
+		sort.Strings(entry.Categories)
+	}
+	if authors != "" {
+		entry.Authors = strings.Split(authors, authorSeparator)
+	}
+	if attachments != "" {
+		if err := json.Unmarshal([]byte(attachments), &entry.Attachments); err != nil {
+			return Entry{}, fmt.Errorf("invalid attachments for entry %s: %w", entry.EntryID, err)
 		}
-		entry.FirstSeen, err = time.Parse(time.RFC3339, firstSeen)
+	}
+
+	// Parse times (required fields in database)
+	entry.Published, err = time.Parse(time.RFC3339, published)
+	if err != nil {
+		return Entry{}, fmt.Errorf("invalid published timestamp %q for entry %s: %w", published, entry.EntryID, err)
+	}
+	entry.Updated, err = time.Parse(time.RFC3339, updated)
+	if err != nil {
+		return Entry{}, fmt.Errorf("invalid updated timestamp %q for entry %s: %w", updated, entry.EntryID, err)
+	}
+	entry.FirstSeen, err = time.Parse(time.RFC3339, firstSeen)
+	if err != nil {
+		return Entry{}, fmt.Errorf("invalid first_seen timestamp %q for entry %s: %w", firstSeen, entry.EntryID, err)
+	}
+	if lastUpdate.Valid && lastUpdate.String != "" {
+		entry.LastSignificantUpdate, err = time.Parse(time.RFC3339, lastUpdate.String)
 		if err != nil {
-			return nil, fmt.Errorf("invalid first_seen timestamp %q for entry %s: %w", firstSeen, entry.EntryID, err)
+			return Entry{}, fmt.Errorf("invalid last_significant_update timestamp %q for entry %s: %w", lastUpdate.String, entry.EntryID, err)
 		}
+	}
 
-		entries = append(entries, entry)
+	return entry, nil
+}
+
+// Settings are the settings of a database connection. Apart from Driver
+// they are only reported for SQLite.
+type Settings struct {
+	Driver      string // DriverSQLite or DriverPostgres
+	JournalMode string // "wal" unless the file system cannot support it
+	BusyTimeout time.Duration
+	Synchronous string // "off", "normal", "full", or "extra"
+	ForeignKeys bool
+}
+
+// Settings reports the driver, and for SQLite the journal mode and connection settings in effect
+func (r *Repository) Settings(ctx context.Context) (Settings, error) {
+	s := Settings{Driver: r.dialect.name}
+	if r.dialect != sqliteDialect {
+		return s, nil
+	}
+	var busyMillis, synchronous int
+	if err := r.db.QueryRowContext(ctx, "PRAGMA journal_mode").Scan(&s.JournalMode); err != nil {
+		return s, fmt.Errorf("read journal_mode: %w", err)
+	}
+	if err := r.db.QueryRowContext(ctx, "PRAGMA busy_timeout").Scan(&busyMillis); err != nil {
+		return s, fmt.Errorf("read busy_timeout: %w", err)
+	}
+	if err := r.db.QueryRowContext(ctx, "PRAGMA synchronous").Scan(&synchronous); err != nil {
+		return s, fmt.Errorf("read synchronous: %w", err)
+	}
+	if err := r.db.QueryRowContext(ctx, "PRAGMA foreign_keys").Scan(&s.ForeignKeys); err != nil {
+		return s, fmt.Errorf("read foreign_keys: %w", err)
 	}
 
-	return entries, rows.Err()
+	s.JournalMode = strings.ToLower(s.JournalMode)
+	s.BusyTimeout = time.Duration(busyMillis) * time.Millisecond
+	if synchronous >= 0 && synchronous < 4 {
+		s.Synchronous = []string{"off", "normal", "full", "extra"}[synchronous]
+	}
+	return s, nil
 }
@@ -2,9 +2,12 @@ package repository
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
@@ -135,6 +138,34 @@ func TestUpdateFeedCache(t *testing.T) {
 	}
 }
 
+func TestUpdateFeedCacheExpiry(t *testing.T) {
+	t.Parallel()
+	repo, _ := setupTestDB(t)
+	defer repo.Close()
+
+	ctx := context.Background()
+	id, _ := repo.AddFeed(ctx, "https://example.com/feed", "Test Feed")
+
+	expires := time.Date(2025, 3, 1, 13, 0, 0, 0, time.UTC)
+	if err := repo.UpdateFeedCacheExpiry(ctx, id, expires); err != nil {
+		t.Fatalf("UpdateFeedCacheExpiry() error = %v", err)
+	}
+
+	feed, _ := repo.GetFeedByURL(ctx, "https://example.com/feed")
+	if !feed.CacheExpires.Equal(expires) {
+		t.Errorf("CacheExpires = %v, want %v", feed.CacheExpires, expires)
+	}
+
+	// A new URL invalidates the stored lifetime
+	if err := repo.UpdateFeedURL(ctx, id, "https://example.com/new"); err != nil {
+		t.Fatalf("UpdateFeedURL() error = %v", err)
+	}
+	feed, _ = repo.GetFeedByURL(ctx, "https://example.com/new")
+	if !feed.CacheExpires.IsZero() {
+		t.Errorf("CacheExpires after URL change = %v, want zero", feed.CacheExpires)
+	}
+}
+
 func TestGetFeeds(t *testing.T) {
 	t.Parallel()
 	repo, _ := setupTestDB(t)
@@ -182,6 +213,29 @@ func TestGetFeedByURL(t *testing.T) {
 	}
 }
 
+func TestGetFeedByID(t *testing.T) {
+	t.Parallel()
+	repo, _ := setupTestDB(t)
+	defer repo.Close()
+
+	id, err := repo.AddFeed(context.Background(), "https://example.com/feed", "Test Feed")
+	if err != nil {
+		t.Fatalf("AddFeed() error = %v", err)
+	}
+
+	feed, err := repo.GetFeedByID(context.Background(), id)
+	if err != nil {
+		t.Fatalf("GetFeedByID() error = %v", err)
+	}
+	if feed.URL != "https://example.com/feed" || feed.Title != "Test Feed" {
+		t.Errorf("GetFeedByID() = %q/%q", feed.URL, feed.Title)
+	}
+
+	if _, err := repo.GetFeedByID(context.Background(), id+1); err != ErrFeedNotFound {
+		t.Errorf("Expected ErrFeedNotFound, got %v", err)
+	}
+}
+
 func TestRemoveFeed(t *testing.T) {
 	t.Parallel()
 	repo, _ := setupTestDB(t)
@@ -249,6 +303,136 @@ func TestUpsertEntry(t *testing.T) {
 	}
 }
 
+func TestUpsertEntry_ReadingTime(t *testing.T) {
+	t.Parallel()
+	repo, _ := setupTestDB(t)
+	defer repo.Close()
+	ctx := context.Background()
+	feedID, _ := repo.AddFeed(ctx, "https://example.com/feed", "Test Feed")
+
+	now := time.Now().Truncate(time.Second)
+	entry := &Entry{FeedID: feedID, EntryID: "entry-1", Title: "Post", Link: "https://example.com/1",
+		Published: now, Updated: now, FirstSeen: now, WordCount: 450, ReadingMinutes: 3}
+	for _, words := range []int{450, 900} {
+		entry.WordCount, entry.ReadingMinutes = words, words/150
+		if err := repo.UpsertEntry(ctx, entry); err != nil {
+			t.Fatalf("UpsertEntry() error = %v", err)
+		}
+		entries, err := repo.GetRecentEntries(ctx, 7)
+		if err != nil || len(entries) != 1 {
+			t.Fatalf("GetRecentEntries() = %d entries, %v", len(entries), err)
+		}
+		if got := entries[0]; got.WordCount != words || got.ReadingMinutes != words/150 {
+			t.Errorf("stored WordCount, ReadingMinutes = %d, %d, want %d, %d", got.WordCount, got.ReadingMinutes, words, words/150)
+		}
+	}
+}
+
+func TestUpsertEntry_AuthorsAndAttachments(t *testing.T) {
+	t.Parallel()
+	repo, _ := setupTestDB(t)
+	defer repo.Close()
+	ctx := context.Background()
+	feedID, _ := repo.AddFeed(ctx, "https://example.com/feed", "Test Feed")
+
+	now := time.Now().Truncate(time.Second)
+	entry := &Entry{FeedID: feedID, EntryID: "entry-1", Title: "Episode", Link: "https://example.com/1",
+		Published: now, Updated: now, FirstSeen: now,
+		Authors:     []string{"Ann", "Bob"},
+		ExternalURL: "https://elsewhere.example.org/story",
+		Language:    "fr-CA",
+		Attachments: []Attachment{
+			{URL: "https://example.com/ep1.mp3", MimeType: "audio/mpeg", Title: "Episode 1", Size: 1048576, Duration: 1800, Medium: "audio"},
+			{URL: "https://example.com/clip.mp4", MimeType: "video/mp4", Medium: "video", Width: 1280, Height: 720, Thumbnail: "https://example.com/clip.jpg"},
+		},
+	}
+	if err := repo.UpsertEntry(ctx, entry); err != nil {
+		t.Fatalf("UpsertEntry() error = %v", err)
+	}
+	entries, err := repo.GetRecentEntries(ctx, 7)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("GetRecentEntries() = %d entries, %v", len(entries), err)
+	}
+	got := entries[0]
+	if !reflect.DeepEqual(got.Authors, entry.Authors) || got.ExternalURL != entry.ExternalURL || got.Language != entry.Language {
+		t.Errorf("stored Authors, ExternalURL, Language = %q, %q, %q", got.Authors, got.ExternalURL, got.Language)
+	}
+	if !reflect.DeepEqual(got.Attachments, entry.Attachments) {
+		t.Errorf("stored Attachments = %+v, want %+v", got.Attachments, entry.Attachments)
+	}
+
+	// A later fetch that drops them clears them
+	entry.Authors, entry.ExternalURL, entry.Language, entry.Attachments = nil, "", "", nil
+	if err := repo.UpsertEntry(ctx, entry); err != nil {
+		t.Fatalf("UpsertEntry() error = %v", err)
+	}
+	entries, _ = repo.GetRecentEntries(ctx, 7)
+	if got := entries[0]; got.Authors != nil || got.ExternalURL != "" || got.Language != "" || got.Attachments != nil {
+		t.Errorf("after update, entry = %+v; want no authors, external URL, language, or attachments", got)
+	}
+}
+
+func TestUpsertEntry_SignificantUpdates(t *testing.T) {
+	t.Parallel()
+	repo, _ := setupTestDB(t)
+	defer repo.Close()
+	ctx := context.Background()
+	feedID, _ := repo.AddFeed(ctx, "https://example.com/feed", "Test Feed")
+
+	published := time.Now().Add(-48 * time.Hour).Truncate(time.Second)
+	entry := &Entry{FeedID: feedID, EntryID: "entry-1", Title: "Post", Link: "https://example.com/1",
+		Published: published, Updated: published, FirstSeen: published}
+	get := func() Entry {
+		t.Helper()
+		entries, err := repo.GetRecentEntries(ctx, 7)
+		if err != nil || len(entries) != 1 {
+			t.Fatalf("GetRecentEntries() = %d entries, %v", len(entries), err)
+		}
+		return entries[0]
+	}
+
+	// Entries stored before content hashes, or refetched unchanged, are not updates
+	steps := []struct {
+		hash      string
+		wantCount int
+	}{
+		{"", 0},
+		{"aaa", 0},
+		{"aaa", 0},
+		{"bbb", 1},
+		{"", 1},
+		{"ccc", 1}, // The stored hash was cleared by the previous step
+		{"ddd", 2},
+	}
+	var lastSeen time.Time
+	for i, step := range steps {
+		entry.ContentHash = step.hash
+		entry.FirstSeen = published.Add(time.Duration(i+1) * time.Hour)
+		if err := repo.UpsertEntry(ctx, entry); err != nil {
+			t.Fatalf("UpsertEntry() error = %v", err)
+		}
+		if i > 0 && step.wantCount > steps[i-1].wantCount {
+			lastSeen = entry.FirstSeen
+		}
+		got := get()
+		if got.UpdatedCount != step.wantCount || !got.LastSignificantUpdate.Equal(lastSeen) {
+			t.Errorf("step %d: UpdatedCount = %d, LastSignificantUpdate = %v; want %d, %v",
+				i, got.UpdatedCount, got.LastSignificantUpdate, step.wantCount, lastSeen)
+		}
+	}
+	if !get().FirstSeen.Equal(published.Add(time.Hour)) {
+		t.Error("first_seen should keep the first fetch's time")
+	}
+
+	updated, err := repo.GetUpdatedEntries(ctx, lastSeen)
+	if err != nil || len(updated) != 1 {
+		t.Errorf("GetUpdatedEntries(last update) = %d entries, %v; want 1", len(updated), err)
+	}
+	if updated, _ := repo.GetUpdatedEntries(ctx, lastSeen.Add(time.Second)); len(updated) != 0 {
+		t.Errorf("GetUpdatedEntries(after last update) = %d entries, want 0", len(updated))
+	}
+}
+
 func TestUniqueConstraintHandling(t *testing.T) {
 	t.Parallel()
 	repo, _ := setupTestDB(t)
@@ -889,6 +1073,131 @@ func TestGetRecentEntriesSortByFirstSeen(t *testing.T) {
 	}
 }
 
+func TestGetRecentEntriesStableOrderForTies(t *testing.T) {
+	t.Parallel()
+	repo, _ := setupTestDB(t)
+	defer repo.Close()
+
+	ctx := context.Background()
+	feed1, _ := repo.AddFeed(ctx, "https://example.com/feed1", "Feed 1")
+	feed2, _ := repo.AddFeed(ctx, "https://example.com/feed2", "Feed 2")
+
+	// All entries share one timestamp and are inserted out of order
+	same := time.Now().Add(-time.Hour).Truncate(time.Second)
+	inserts := []struct {
+		feedID  int64
+		entryID string
+	}{
+		{feed2, "b"},
+		{feed1, "c"},
+		{feed2, "a"},
+		{feed1, "a"},
+		{feed1, "b"},
+	}
+	for _, in := range inserts {
+		err := repo.UpsertEntry(ctx, &Entry{
+			FeedID:    in.feedID,
+			EntryID:   in.entryID,
+			Title:     in.entryID,
+			Published: same,
+			FirstSeen: same,
+		})
+		if err != nil {
+			t.Fatalf("UpsertEntry() error = %v", err)
+		}
+	}
+
+	want := []string{"1/a", "1/b", "1/c", "2/a", "2/b"}
+	for _, sortBy := range []string{"published", "first_seen"} {
+		entries, err := repo.GetRecentEntriesWithOptions(ctx, 7, false, sortBy)
+		if err != nil {
+			t.Fatalf("GetRecentEntriesWithOptions(%s) error = %v", sortBy, err)
+		}
+
+		got := make([]string, len(entries))
+		for i, e := range entries {
+			got[i] = fmt.Sprintf("%d/%s", e.FeedID, e.EntryID)
+		}
+		if strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("sort %s: order = %v, want %v", sortBy, got, want)
+		}
+	}
+
+	entries, _ := repo.GetRecentEntries(ctx, 7)
+	if len(entries) != 5 || entries[0].FeedID != feed1 || entries[0].EntryID != "a" {
+		t.Errorf("GetRecentEntries() first entry = %+v, want feed 1 entry a", entries[0])
+	}
+}
+
+func TestGetRecentEntriesAcrossOffsets(t *testing.T) {
+	t.Parallel()
+	repo, _ := setupTestDB(t)
+	defer repo.Close()
+
+	ctx := context.Background()
+	feedID, _ := repo.AddFeed(ctx, "https://example.com/feed", "Feed")
+
+	// In their feeds' zones, the older entry's local time is the later
+	// one, so compared as stored text they would sort the wrong way round
+	base := time.Now().UTC().Add(-2 * time.Hour).Truncate(time.Second)
+	for id, published := range map[string]time.Time{
+		"east": base.In(time.FixedZone("UTC+5", 5*60*60)),
+		"west": base.Add(time.Hour).In(time.FixedZone("UTC-5", -5*60*60)),
+	} {
+		if err := repo.UpsertEntry(ctx, &Entry{FeedID: feedID, EntryID: id, Published: published, Updated: published, FirstSeen: published}); err != nil {
+			t.Fatalf("UpsertEntry() error = %v", err)
+		}
+	}
+
+	entries, err := repo.GetRecentEntries(ctx, 7)
+	if err != nil {
+		t.Fatalf("GetRecentEntries() error = %v", err)
+	}
+	if len(entries) != 2 || entries[0].EntryID != "west" || entries[1].EntryID != "east" {
+		t.Errorf("GetRecentEntries() = %+v, want west then east", entries)
+	}
+	if len(entries) == 2 && !entries[1].Published.Equal(base) {
+		t.Errorf("east Published = %v, want %v", entries[1].Published, base)
+	}
+
+	var published string
+	if err := repo.db.QueryRow(`SELECT published FROM entries WHERE entry_id = 'east'`).Scan(&published); err != nil || published != base.Format(time.RFC3339) {
+		t.Errorf("stored published = %q, %v; want %q", published, err, base.Format(time.RFC3339))
+	}
+}
+
+func TestMigrateToV27(t *testing.T) {
+	t.Parallel()
+	repo, _ := setupTestDB(t)
+	defer repo.Close()
+
+	ctx := context.Background()
+	feedID, _ := repo.AddFeed(ctx, "https://example.com/feed", "Feed")
+	// As written before timestamps were stored in UTC
+	_, err := repo.db.Exec(`INSERT INTO entries (feed_id, entry_id, published, updated, first_seen) VALUES (?, 'a', '2025-03-01T10:00:00+02:00', 'not a date', '2025-03-01T08:00:00Z')`, feedID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.db.Exec(`UPDATE feeds SET last_fetched = '2025-03-01T01:30:00-05:00' WHERE id = ?`, feedID); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repo.migrateToV27(); err != nil {
+		t.Fatalf("migrateToV27() error = %v", err)
+	}
+
+	var published, updated, firstSeen, lastFetched string
+	if err := repo.db.QueryRow(`SELECT published, updated, first_seen FROM entries WHERE entry_id = 'a'`).Scan(&published, &updated, &firstSeen); err != nil {
+		t.Fatal(err)
+	}
+	if published != "2025-03-01T08:00:00Z" || updated != "not a date" || firstSeen != "2025-03-01T08:00:00Z" {
+		t.Errorf("entry times after migration = %q, %q, %q", published, updated, firstSeen)
+	}
+	if err := repo.db.QueryRow(`SELECT last_fetched FROM feeds WHERE id = ?`, feedID).Scan(&lastFetched); err != nil || lastFetched != "2025-03-01T06:30:00Z" {
+		t.Errorf("last_fetched after migration = %q, %v; want 2025-03-01T06:30:00Z", lastFetched, err)
+	}
+}
+
 func TestGetRecentEntriesFilterAndSortByFirstSeen(t *testing.T) {
 	t.Parallel()
 	repo, _ := setupTestDB(t)
@@ -1220,3 +1529,316 @@ func TestGetFeeds_ActiveOnly(t *testing.T) {
 		t.Error("Inactive feed 3 should not be returned by GetFeeds(true)")
 	}
 }
+
+func TestFeedSchedule(t *testing.T) {
+	t.Parallel()
+	repo, _ := setupTestDB(t)
+	defer repo.Close()
+
+	ctx := context.Background()
+	feedID, _ := repo.AddFeed(ctx, "https://example.com/feed", "Feed")
+
+	base := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	entries := []*Entry{
+		{FeedID: feedID, EntryID: "1", Published: base.Add(-2 * time.Hour), FirstSeen: base},
+		{FeedID: feedID, EntryID: "2", Published: base, FirstSeen: base},
+		{FeedID: feedID, EntryID: "3", FirstSeen: base.Add(-time.Hour)}, // Undated: uses first_seen
+	}
+	for _, e := range entries {
+		if err := repo.UpsertEntry(ctx, e); err != nil {
+			t.Fatalf("UpsertEntry() error = %v", err)
+		}
+	}
+
+	times, err := repo.GetEntryTimes(ctx, feedID, 10)
+	if err != nil {
+		t.Fatalf("GetEntryTimes() error = %v", err)
+	}
+	want := []time.Time{base, base.Add(-time.Hour), base.Add(-2 * time.Hour)}
+	if len(times) != len(want) {
+		t.Fatalf("GetEntryTimes() returned %d times, want %d", len(times), len(want))
+	}
+	for i := range want {
+		if !times[i].Equal(want[i]) {
+			t.Errorf("times[%d] = %v, want %v", i, times[i], want[i])
+		}
+	}
+
+	if limited, _ := repo.GetEntryTimes(ctx, feedID, 1); len(limited) != 1 {
+		t.Errorf("GetEntryTimes(limit 1) returned %d times", len(limited))
+	}
+
+	next := base.Add(2 * time.Hour)
+	if err := repo.UpdateFeedSchedule(ctx, feedID, 2*time.Hour, next); err != nil {
+		t.Fatalf("UpdateFeedSchedule() error = %v", err)
+	}
+	feed, _ := repo.GetFeedByURL(ctx, "https://example.com/feed")
+	if feed.FetchInterval != 7200 || !feed.NextFetch.Equal(next) {
+		t.Errorf("schedule = %ds / %v, want 7200s / %v", feed.FetchInterval, feed.NextFetch, next)
+	}
+}
+
+func TestGetEntryContents(t *testing.T) {
+	t.Parallel()
+	repo, _ := setupTestDB(t)
+	defer repo.Close()
+	ctx := context.Background()
+
+	feedID, _ := repo.AddFeed(ctx, "https://example.com/feed", "Test Feed")
+	otherID, _ := repo.AddFeed(ctx, "https://other.example.com/feed", "Other Feed")
+	for _, e := range []*Entry{
+		{FeedID: feedID, EntryID: "a", Content: "<p>A</p>"},
+		{FeedID: feedID, EntryID: "b", Content: "<p>B</p>"},
+		{FeedID: otherID, EntryID: "c", Content: "<p>C</p>"},
+	} {
+		if err := repo.UpsertEntry(ctx, e); err != nil {
+			t.Fatalf("UpsertEntry() error = %v", err)
+		}
+	}
+
+	got, err := repo.GetEntryContents(ctx, feedID, []string{"a", "c", "missing"})
+	if err != nil {
+		t.Fatalf("GetEntryContents() error = %v", err)
+	}
+	if len(got) != 1 || got["a"] != "<p>A</p>" {
+		t.Errorf("GetEntryContents() = %v, want only entry a from this feed", got)
+	}
+
+	got, err = repo.GetEntryContents(ctx, feedID, nil)
+	if err != nil || len(got) != 0 {
+		t.Errorf("GetEntryContents(nil) = %v, %v; want empty", got, err)
+	}
+}
+
+func TestDeactivateAndReactivateFeed(t *testing.T) {
+	t.Parallel()
+	repo, _ := setupTestDB(t)
+	defer repo.Close()
+
+	ctx := context.Background()
+	id, _ := repo.AddFeed(ctx, "https://example.com/feed", "Feed")
+	_ = repo.UpdateFeedError(ctx, id, "timeout")
+	_ = repo.UpdateFeedError(ctx, id, "timeout")
+	next := time.Now().Add(time.Hour).Truncate(time.Second)
+	if err := repo.UpdateFeedNextFetch(ctx, id, next); err != nil {
+		t.Fatalf("UpdateFeedNextFetch() error = %v", err)
+	}
+	if err := repo.DeactivateFeed(ctx, id); err != nil {
+		t.Fatalf("DeactivateFeed() error = %v", err)
+	}
+
+	feed, _ := repo.GetFeedByID(ctx, id)
+	if feed.Active || feed.FetchErrorCount != 2 || !feed.NextFetch.Equal(next) {
+		t.Errorf("After deactivation: active=%v errors=%d next=%v", feed.Active, feed.FetchErrorCount, feed.NextFetch)
+	}
+	if active, _ := repo.GetFeeds(ctx, true); len(active) != 0 {
+		t.Errorf("GetFeeds(active) returned %d feeds, want 0", len(active))
+	}
+
+	if err := repo.ReactivateFeed(ctx, id); err != nil {
+		t.Fatalf("ReactivateFeed() error = %v", err)
+	}
+	feed, _ = repo.GetFeedByID(ctx, id)
+	if !feed.Active || feed.FetchErrorCount != 0 || feed.FetchError != "" || feed.NextFetch.After(time.Now()) {
+		t.Errorf("After reactivation: %+v", feed)
+	}
+
+	if err := repo.ReactivateFeed(ctx, 9999); !errors.Is(err, ErrFeedNotFound) {
+		t.Errorf("ReactivateFeed(unknown) error = %v, want ErrFeedNotFound", err)
+	}
+}
+
+func TestMarkFeedGone(t *testing.T) {
+	t.Parallel()
+	repo, _ := setupTestDB(t)
+	defer repo.Close()
+
+	ctx := context.Background()
+	id, _ := repo.AddFeed(ctx, "https://example.com/feed", "Feed")
+	at := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	if err := repo.MarkFeedGone(ctx, id, at); err != nil {
+		t.Fatalf("MarkFeedGone() error = %v", err)
+	}
+
+	feed, _ := repo.GetFeedByID(ctx, id)
+	if feed.Active || !feed.GoneAt.Equal(at) {
+		t.Errorf("After MarkFeedGone: active=%v goneAt=%v", feed.Active, feed.GoneAt)
+	}
+
+	if err := repo.ReactivateFeed(ctx, id); err != nil {
+		t.Fatalf("ReactivateFeed() error = %v", err)
+	}
+	feed, _ = repo.GetFeedByID(ctx, id)
+	if !feed.Active || !feed.GoneAt.IsZero() {
+		t.Errorf("After ReactivateFeed: active=%v goneAt=%v", feed.Active, feed.GoneAt)
+	}
+}
+
+func TestSettings(t *testing.T) {
+	t.Parallel()
+	dbPath := filepath.Join(t.TempDir(), "old.db")
+
+	// A database left in rollback journal mode is converted when opened
+	old, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := old.Exec("PRAGMA journal_mode=DELETE; CREATE TABLE t (x INTEGER)"); err != nil {
+		t.Fatal(err)
+	}
+	old.Close()
+
+	repo, err := New(dbPath)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer repo.Close()
+
+	settings, err := repo.Settings(context.Background())
+	if err != nil {
+		t.Fatalf("Settings() error = %v", err)
+	}
+	want := Settings{Driver: DriverSQLite, JournalMode: "wal", BusyTimeout: BusyTimeout, Synchronous: "normal", ForeignKeys: true}
+	if settings != want {
+		t.Errorf("Settings() = %+v, want %+v", settings, want)
+	}
+}
+
+func TestConcurrentReadersAndWriters(t *testing.T) {
+	t.Parallel()
+	repo, _ := setupTestDB(t)
+	defer repo.Close()
+
+	ctx := context.Background()
+	if _, err := repo.AddFeed(ctx, "https://example.com/feed", "Feed"); err != nil {
+		t.Fatal(err)
+	}
+
+	// While one transaction holds the write lock, readers carry on and a
+	// second writer waits for it rather than failing with "database is locked"
+	written := make(chan error, 1)
+	err := repo.Batch(ctx, func(r FeedRepository) error {
+		if _, err := r.AddFeed(ctx, "https://example.com/other", "Other"); err != nil {
+			return err
+		}
+
+		feeds, err := repo.GetFeeds(ctx, false)
+		if err != nil {
+			return fmt.Errorf("read during write: %w", err)
+		}
+		if len(feeds) != 1 {
+			return fmt.Errorf("reader saw %d feeds, want the 1 committed before the write", len(feeds))
+		}
+
+		go func() {
+			_, err := repo.AddFeed(ctx, "https://example.com/third", "Third")
+			written <- err
+		}()
+		time.Sleep(100 * time.Millisecond)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Batch() error = %v", err)
+	}
+	if err := <-written; err != nil {
+		t.Fatalf("second writer failed: %v", err)
+	}
+	if feeds, _ := repo.GetFeeds(ctx, false); len(feeds) != 3 {
+		t.Errorf("got %d feeds, want 3", len(feeds))
+	}
+}
+
+func TestCheckIntegrity(t *testing.T) {
+	t.Parallel()
+	repo, _ := setupTestDB(t)
+	defer repo.Close()
+
+	problems, err := repo.CheckIntegrity(context.Background())
+	if err != nil || problems != nil {
+		t.Errorf("CheckIntegrity() = %v, %v; want no problems", problems, err)
+	}
+}
+
+func TestOrphans(t *testing.T) {
+	t.Parallel()
+	repo, _ := setupTestDB(t)
+	defer repo.Close()
+
+	ctx := context.Background()
+	keptID, _ := repo.AddFeed(ctx, "https://example.com/kept", "Kept")
+	goneID, _ := repo.AddFeed(ctx, "https://example.com/gone", "Gone")
+	for _, feedID := range []int64{keptID, goneID} {
+		if err := repo.UpsertEntry(ctx, &Entry{FeedID: feedID, EntryID: "e", Categories: []string{"go", "sql"}}); err != nil {
+			t.Fatalf("UpsertEntry() error = %v", err)
+		}
+		if err := repo.SetFeedCategories(ctx, feedID, []string{"news"}); err != nil {
+			t.Fatalf("SetFeedCategories() error = %v", err)
+		}
+		if err := repo.RecordFetch(ctx, FetchLogEntry{FeedID: feedID, FetchedAt: time.Now(), StatusCode: 200}); err != nil {
+			t.Fatalf("RecordFetch() error = %v", err)
+		}
+	}
+
+	// Remove a feed the way older versions could, without cascading
+	conn, err := repo.conn.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.ExecContext(ctx, "PRAGMA foreign_keys = OFF"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.ExecContext(ctx, "DELETE FROM feeds WHERE id = ?", goneID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.ExecContext(ctx, "PRAGMA foreign_keys = ON"); err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+
+	orphans, err := repo.CountOrphans(ctx)
+	if err != nil {
+		t.Fatalf("CountOrphans() error = %v", err)
+	}
+	want := Orphans{Entries: 1, FeedCategories: 1, FetchLog: 1}
+	if orphans != want {
+		t.Errorf("CountOrphans() = %+v, want %+v", orphans, want)
+	}
+
+	if _, err := repo.DeleteOrphans(ctx); err != nil {
+		t.Fatalf("DeleteOrphans() error = %v", err)
+	}
+	if orphans, _ := repo.CountOrphans(ctx); orphans.Total() != 0 {
+		t.Errorf("CountOrphans() after delete = %+v, want none", orphans)
+	}
+	var categories int
+	if err := repo.db.QueryRow("SELECT COUNT(*) FROM entry_categories").Scan(&categories); err != nil || categories != 2 {
+		t.Errorf("entry_categories rows = %d, %v; want the kept entry's 2", categories, err)
+	}
+	if count, _ := repo.GetEntryCountForFeed(ctx, keptID); count != 1 {
+		t.Errorf("kept feed has %d entries, want 1", count)
+	}
+}
+
+func TestGetNewEntries(t *testing.T) {
+	t.Parallel()
+	repo, _ := setupTestDB(t)
+	defer repo.Close()
+
+	ctx := context.Background()
+	feedID, _ := repo.AddFeed(ctx, "https://example.com/feed", "Test Feed")
+	now := time.Now().Truncate(time.Second)
+	// An old post first seen now is new; a recent post seen yesterday is not
+	for _, e := range []Entry{
+		{FeedID: feedID, EntryID: "backdated", Published: now.AddDate(0, -1, 0), FirstSeen: now},
+		{FeedID: feedID, EntryID: "seen", Published: now.Add(-time.Hour), FirstSeen: now.AddDate(0, 0, -1)},
+	} {
+		if err := repo.UpsertEntry(ctx, &e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err := repo.GetNewEntries(ctx, now.Add(-time.Hour))
+	if err != nil || len(entries) != 1 || entries[0].EntryID != "backdated" {
+		t.Errorf("GetNewEntries() = %v, %v; want the entry first seen now", entries, err)
+	}
+}
@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// RevisionRetention bounds the earlier revisions kept of entries whose
+// content changes, for planets where stealth edits matter. The zero value
+// keeps none.
+type RevisionRetention struct {
+	MaxPerEntry int           // Most revisions kept of each entry, the newest; 0 keeps none
+	MaxAge      time.Duration // Revisions replaced longer ago are deleted; 0 keeps them however old
+}
+
+// Revision is an entry's content as it was before a later fetch changed it
+// significantly (see UpsertEntry)
+type Revision struct {
+	ID          int64
+	Title       string
+	Content     string
+	ContentHash string
+	Updated     time.Time // The entry's updated date in this revision
+	ReplacedAt  time.Time // When a fetch found the content changed
+}
+
+// SetRevisionRetention sets how many earlier revisions are kept of each
+// entry, and for how long
+func (r *Repository) SetRevisionRetention(rr RevisionRetention) {
+	r.revisions = rr
+}
+
+// recordRevision keeps the stored content of entry as a revision if the
+// upsert of entry is about to change it significantly, and trims the
+// entry's revisions to the retention limits
+func (r *Repository) recordRevision(ctx context.Context, entry *Entry) error {
+	if r.revisions.MaxPerEntry <= 0 || entry.ContentHash == "" {
+		return nil
+	}
+
+	// Revision times are UTC so that they compare as text
+	replacedAt := entry.FirstSeen.UTC()
+	result, err := r.db.ExecContext(ctx, `
+		INSERT INTO entry_revisions (entry_id, title, content, content_hash, updated, replaced_at)
+		SELECT id, title, content, content_hash, updated, ?
+		FROM entries
+		WHERE feed_id = ? AND entry_id = ? AND content_hash <> '' AND content_hash <> ?
+	`, replacedAt.Format(time.RFC3339), entry.FeedID, entry.EntryID, entry.ContentHash)
+	if err != nil {
+		return fmt.Errorf("record revision: %w", err)
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return err
+	}
+
+	_, err = r.db.ExecContext(ctx, `
+		DELETE FROM entry_revisions
+		WHERE entry_id = (SELECT id FROM entries WHERE feed_id = ? AND entry_id = ?)
+		  AND id NOT IN (
+			SELECT id FROM entry_revisions
+			WHERE entry_id = (SELECT id FROM entries WHERE feed_id = ? AND entry_id = ?)
+			ORDER BY id DESC LIMIT ?
+		  )
+	`, entry.FeedID, entry.EntryID, entry.FeedID, entry.EntryID, r.revisions.MaxPerEntry)
+	if err != nil {
+		return fmt.Errorf("trim revisions: %w", err)
+	}
+
+	if r.revisions.MaxAge > 0 {
+		cutoff := replacedAt.Add(-r.revisions.MaxAge)
+		if _, err := r.db.ExecContext(ctx, `DELETE FROM entry_revisions WHERE replaced_at < ?`, cutoff.Format(time.RFC3339)); err != nil {
+			return fmt.Errorf("expire revisions: %w", err)
+		}
+	}
+	return nil
+}
+
+// GetRevisions returns the kept revisions of the entry with the given
+// database ID, oldest first
+func (r *Repository) GetRevisions(ctx context.Context, id int64) ([]Revision, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, COALESCE(title, ''), COALESCE(content, ''), content_hash, updated, replaced_at
+		FROM entry_revisions
+		WHERE entry_id = ?
+		ORDER BY id
+	`, id)
+	if err != nil {
+		return nil, fmt.Errorf("query revisions: %w", err)
+	}
+	defer rows.Close()
+
+	var revisions []Revision
+	for rows.Next() {
+		var rev Revision
+		var updated sql.NullString
+		var replacedAt string
+		if err := rows.Scan(&rev.ID, &rev.Title, &rev.Content, &rev.ContentHash, &updated, &replacedAt); err != nil {
+			return nil, fmt.Errorf("scan revision: %w", err)
+		}
+		if rev.ReplacedAt, err = time.Parse(time.RFC3339, replacedAt); err != nil {
+			return nil, fmt.Errorf("invalid replaced_at timestamp %q: %w", replacedAt, err)
+		}
+		if updated.Valid {
+			if rev.Updated, err = time.Parse(time.RFC3339, updated.String); err != nil {
+				return nil, fmt.Errorf("invalid updated timestamp %q: %w", updated.String, err)
+			}
+		}
+		revisions = append(revisions, rev)
+	}
+	return revisions, rows.Err()
+}
@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRevisions(t *testing.T) {
+	t.Parallel()
+	repo, _ := setupTestDB(t)
+	defer repo.Close()
+	ctx := context.Background()
+	feedID, _ := repo.AddFeed(ctx, "https://example.com/feed", "Test Feed")
+
+	published := time.Now().Add(-48 * time.Hour).Truncate(time.Second)
+	entry := &Entry{FeedID: feedID, EntryID: "entry-1", Title: "Post", Link: "https://example.com/1",
+		Published: published, Updated: published, FirstSeen: published}
+	store := func(i int, hash, content string) {
+		t.Helper()
+		entry.ContentHash, entry.Content = hash, content
+		entry.FirstSeen = published.Add(time.Duration(i) * time.Hour)
+		if err := repo.UpsertEntry(ctx, entry); err != nil {
+			t.Fatalf("UpsertEntry() error = %v", err)
+		}
+	}
+	revisions := func() []Revision {
+		t.Helper()
+		entries, err := repo.FindEntries(ctx, entry.Link)
+		if err != nil || len(entries) != 1 {
+			t.Fatalf("FindEntries() = %d entries, %v", len(entries), err)
+		}
+		revs, err := repo.GetRevisions(ctx, entries[0].ID)
+		if err != nil {
+			t.Fatalf("GetRevisions() error = %v", err)
+		}
+		return revs
+	}
+
+	// Without a retention nothing is kept
+	store(0, "aaa", "<p>First</p>")
+	store(1, "bbb", "<p>Second</p>")
+	if revs := revisions(); len(revs) != 0 {
+		t.Errorf("revisions without a retention = %+v, want none", revs)
+	}
+
+	repo.SetRevisionRetention(RevisionRetention{MaxPerEntry: 2})
+	store(2, "bbb", "<p>Second</p>") // Unchanged
+	store(3, "ccc", "<p>Third</p>")
+	store(4, "", "<p>Unhashed</p>") // Not a significant change
+	if revs := revisions(); len(revs) != 1 || revs[0].Content != "<p>Second</p>" || revs[0].ContentHash != "bbb" ||
+		!revs[0].ReplacedAt.Equal(published.Add(3*time.Hour)) || !revs[0].Updated.Equal(published) {
+		t.Errorf("revisions = %+v, want the content the third fetch replaced", revs)
+	}
+
+	// Content stored without a hash is not kept when it is replaced
+	store(5, "ddd", "<p>Fourth</p>")
+	store(6, "eee", "<p>Fifth</p>")
+	if got := revisionHashes(revisions()); got != "bbb ddd" {
+		t.Errorf("revisions = %s, want bbb ddd", got)
+	}
+	store(7, "fff", "<p>Sixth</p>")
+	if got := revisionHashes(revisions()); got != "ddd eee" {
+		t.Errorf("revisions = %s, want the newest 2, oldest first", got)
+	}
+
+	// Revisions replaced longer ago than MaxAge go
+	repo.SetRevisionRetention(RevisionRetention{MaxPerEntry: 10, MaxAge: 90 * time.Minute})
+	store(8, "ggg", "<p>Seventh</p>")
+	if got := revisionHashes(revisions()); got != "eee fff" {
+		t.Errorf("revisions after expiry = %s, want those of the last 90 minutes", got)
+	}
+
+	// Pruning the entry takes its revisions with it
+	if _, err := repo.PruneOldEntries(ctx, 0); err != nil {
+		t.Fatal(err)
+	}
+	var count int
+	if err := repo.db.QueryRow(`SELECT COUNT(*) FROM entry_revisions`).Scan(&count); err != nil || count != 0 {
+		t.Errorf("entry_revisions has %d rows after pruning, %v; want 0", count, err)
+	}
+}
+
+// revisionHashes lists the content hashes of revisions
+func revisionHashes(revs []Revision) string {
+	hashes := make([]string, len(revs))
+	for i, rev := range revs {
+		hashes[i] = rev.ContentHash
+	}
+	return strings.Join(hashes, " ")
+}
@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// GetTranslations returns the cached translations into language of the
+// texts with the given keys, keyed by key. Texts that have not been
+// translated are missing from the result.
+func (r *Repository) GetTranslations(ctx context.Context, language string, keys []string) (map[string]string, error) {
+	translations := make(map[string]string, len(keys))
+	// Stay well under SQLite's limit on bound parameters
+	const batch = 500
+	for start := 0; start < len(keys); start += batch {
+		ks := keys[start:min(start+batch, len(keys))]
+		args := make([]interface{}, 0, len(ks)+1)
+		args = append(args, language)
+		for _, k := range ks {
+			args = append(args, k)
+		}
+		placeholders := strings.TrimSuffix(strings.Repeat("?,", len(ks)), ",")
+
+		rows, err := r.db.QueryContext(ctx,
+			`SELECT source_hash, text FROM translations WHERE language = ? AND source_hash IN (`+placeholders+`)`, args...)
+		if err != nil {
+			return nil, fmt.Errorf("query translations: %w", err)
+		}
+		for rows.Next() {
+			var key, text string
+			if err := rows.Scan(&key, &text); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("scan translation: %w", err)
+			}
+			translations[key] = text
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, fmt.Errorf("iterate translations: %w", err)
+		}
+	}
+	return translations, nil
+}
+
+// SaveTranslations caches translations into language, keyed by the key of
+// their source text
+func (r *Repository) SaveTranslations(ctx context.Context, language string, translations map[string]string) error {
+	tx, err := r.beginTx(ctx)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }() // No-op after commit
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	for key, text := range translations {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO translations (source_hash, language, text, created_at)
+			VALUES (?, ?, ?, ?)
+			ON CONFLICT (source_hash, language) DO UPDATE SET text = excluded.text, created_at = excluded.created_at
+		`, key, language, text, now)
+		if err != nil {
+			return fmt.Errorf("save translation: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit translations: %w", err)
+	}
+	return nil
+}
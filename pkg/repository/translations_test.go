@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestTranslations(t *testing.T) {
+	t.Parallel()
+	repo, _ := setupTestDB(t)
+	defer repo.Close()
+	ctx := context.Background()
+
+	got, err := repo.GetTranslations(ctx, "en", []string{"a", "b"})
+	if err != nil {
+		t.Fatalf("GetTranslations() error = %v", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("GetTranslations() on an empty cache = %v, want none", got)
+	}
+
+	if err := repo.SaveTranslations(ctx, "en", map[string]string{"a": "Hello", "b": "Thanks"}); err != nil {
+		t.Fatalf("SaveTranslations() error = %v", err)
+	}
+	if err := repo.SaveTranslations(ctx, "de", map[string]string{"a": "Hallo"}); err != nil {
+		t.Fatalf("SaveTranslations() error = %v", err)
+	}
+	// Saving again replaces the translation
+	if err := repo.SaveTranslations(ctx, "en", map[string]string{"b": "Thank you"}); err != nil {
+		t.Fatalf("SaveTranslations() error = %v", err)
+	}
+
+	got, err = repo.GetTranslations(ctx, "en", []string{"a", "b", "c"})
+	if err != nil {
+		t.Fatalf("GetTranslations() error = %v", err)
+	}
+	if want := map[string]string{"a": "Hello", "b": "Thank you"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("GetTranslations(en) = %v, want %v", got, want)
+	}
+	got, err = repo.GetTranslations(ctx, "de", []string{"a", "b"})
+	if err != nil {
+		t.Fatalf("GetTranslations() error = %v", err)
+	}
+	if want := map[string]string{"a": "Hallo"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("GetTranslations(de) = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,80 @@
+// Package scheduler computes per-feed fetch intervals from posting history.
+//
+// Feeds that post often are fetched often; dormant feeds back off towards the
+// maximum interval. The interval is derived from the median gap between recent
+// entries, lengthened when a feed has been silent for longer than usual, and
+// clamped to the configured bounds.
+package scheduler
+
+import (
+	"sort"
+	"time"
+)
+
+// HistorySize is the number of recent entry timestamps used to estimate cadence
+const HistorySize = 20
+
+// checksPerGap is how many times a feed is checked per typical posting gap,
+// so new entries appear within about half a gap of being published
+const checksPerGap = 2
+
+// Scheduler computes fetch intervals within [Min, Max]
+type Scheduler struct {
+	Min time.Duration
+	Max time.Duration
+}
+
+// New creates a Scheduler with the given interval bounds
+func New(min, max time.Duration) *Scheduler {
+	return &Scheduler{Min: min, Max: max}
+}
+
+// Interval returns how long to wait before fetching a feed again, given the
+// publication times of its recent entries (in any order). Feeds with fewer
+// than two dated entries have no measurable cadence and use Min.
+func (s *Scheduler) Interval(published []time.Time, now time.Time) time.Duration {
+	times := make([]time.Time, 0, len(published))
+	for _, t := range published {
+		if !t.IsZero() && !t.After(now) {
+			times = append(times, t)
+		}
+	}
+	if len(times) < 2 {
+		return s.Min
+	}
+
+	sort.Slice(times, func(i, j int) bool { return times[i].After(times[j]) })
+	if len(times) > HistorySize {
+		times = times[:HistorySize]
+	}
+
+	gaps := make([]time.Duration, 0, len(times)-1)
+	for i := 1; i < len(times); i++ {
+		gaps = append(gaps, times[i-1].Sub(times[i]))
+	}
+	sort.Slice(gaps, func(i, j int) bool { return gaps[i] < gaps[j] })
+	gap := gaps[len(gaps)/2]
+
+	// A feed that has been quiet for longer than its usual gap is probably
+	// slowing down; stretch the interval so dormant feeds back off
+	if silence := now.Sub(times[0]); silence > gap {
+		gap = silence
+	}
+
+	return s.clamp(gap / checksPerGap)
+}
+
+// Next returns the time a feed becomes due, given its recent publication times
+func (s *Scheduler) Next(published []time.Time, now time.Time) time.Time {
+	return now.Add(s.Interval(published, now))
+}
+
+func (s *Scheduler) clamp(d time.Duration) time.Duration {
+	if d < s.Min {
+		return s.Min
+	}
+	if d > s.Max {
+		return s.Max
+	}
+	return d
+}
@@ -0,0 +1,69 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+// every returns n publication times spaced by gap, the newest at latest
+func every(latest time.Time, gap time.Duration, n int) []time.Time {
+	times := make([]time.Time, n)
+	for i := range times {
+		times[i] = latest.Add(-time.Duration(i) * gap)
+	}
+	return times
+}
+
+func TestInterval(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	s := New(30*time.Minute, 24*time.Hour)
+
+	tests := []struct {
+		name      string
+		published []time.Time
+		want      time.Duration
+	}{
+		{"no history", nil, 30 * time.Minute},
+		{"single entry", []time.Time{now.Add(-time.Hour)}, 30 * time.Minute},
+		{"every four hours", every(now.Add(-time.Hour), 4*time.Hour, 10), 2 * time.Hour},
+		{"hourly clamps to min", every(now, 10*time.Minute, 10), 30 * time.Minute},
+		{"yearly clamps to max", every(now.Add(-24*time.Hour), 365*24*time.Hour, 3), 24 * time.Hour},
+		{"dormant daily feed backs off", every(now.Add(-20*time.Hour), 6*time.Hour, 10), 10 * time.Hour},
+		{"future and zero times ignored", append(every(now.Add(-time.Hour), 4*time.Hour, 5), now.Add(time.Hour), time.Time{}), 2 * time.Hour},
+		{"median resists outliers", append(every(now.Add(-time.Hour), 4*time.Hour, 6), now.Add(-60*24*time.Hour)), 2 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := s.Interval(tt.published, now); got != tt.want {
+				t.Errorf("Interval() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIntervalUsesRecentHistoryOnly(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	s := New(time.Minute, 7*24*time.Hour)
+
+	// Recent hourly posts after a long history of daily posts
+	recent := every(now, time.Hour, HistorySize)
+	older := every(recent[len(recent)-1].Add(-24*time.Hour), 24*time.Hour, 50)
+
+	if got := s.Interval(append(older, recent...), now); got != 30*time.Minute {
+		t.Errorf("Interval() = %v, want 30m from recent cadence", got)
+	}
+}
+
+func TestNext(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	s := New(time.Hour, 24*time.Hour)
+
+	if got, want := s.Next(nil, now), now.Add(time.Hour); !got.Equal(want) {
+		t.Errorf("Next() = %v, want %v", got, want)
+	}
+}
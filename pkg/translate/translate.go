@@ -0,0 +1,248 @@
+// Package translate translates entry titles and summaries into the
+// planet's language at generation time.
+//
+// The translation itself is done elsewhere: by a command, which reads a
+// Request as JSON on standard input and writes a Response to standard
+// output, or by an HTTP service that is POSTed the Request and answers with
+// the Response. Either can wrap whichever machine translation service the
+// operator prefers. Translations are cached by a hash of the source text,
+// so each text is sent once per language.
+package translate
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+// TargetEnv is set to the target language for translation commands
+const TargetEnv = "RP_TARGET_LANGUAGE"
+
+// maxBatch is the most texts sent in one Request
+const maxBatch = 100
+
+// maxResponse limits the size of a Response
+const maxResponse = 16 * 1024 * 1024
+
+// Request asks for Texts to be translated into Target, a language tag
+// such as "en" or "pt-BR"
+type Request struct {
+	Target string   `json:"target"`
+	Texts  []string `json:"texts"`
+}
+
+// Response holds the translations of a Request's texts, in the same order
+type Response struct {
+	Texts []string `json:"texts"`
+}
+
+// Translator translates plain text
+type Translator interface {
+	Translate(ctx context.Context, req Request) ([]string, error)
+}
+
+// Command runs a shell command for each Request
+type Command struct {
+	Command string
+}
+
+// Translate runs the command with the request on its standard input
+func (c Command) Translate(ctx context.Context, req Request) ([]string, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("encode translation request: %w", err)
+	}
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", c.Command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", c.Command)
+	}
+	cmd.Env = append(os.Environ(), TargetEnv+"="+req.Target)
+	cmd.Stdin = bytes.NewReader(body)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	// Don't wait forever on children of a killed command holding its output open
+	cmd.WaitDelay = 5 * time.Second
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("translation command: %w: %s", err, msg)
+		}
+		return nil, fmt.Errorf("translation command: %w", err)
+	}
+	return decode(&stdout, len(req.Texts))
+}
+
+// HTTP POSTs each Request as JSON to a URL
+type HTTP struct {
+	URL    string
+	Client *http.Client // Defaults to a client with a 60 second timeout
+}
+
+// Translate posts the request and reads the translations from the reply
+func (h HTTP) Translate(ctx context.Context, req Request) ([]string, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("encode translation request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("create translation request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	client := h.Client
+	if client == nil {
+		client = &http.Client{Timeout: 60 * time.Second}
+	}
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("post translation request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+		return nil, fmt.Errorf("post translation request: server returned %s", resp.Status)
+	}
+	return decode(resp.Body, len(req.Texts))
+}
+
+// decode reads a Response holding want translations
+func decode(r io.Reader, want int) ([]string, error) {
+	var resp Response
+	if err := json.NewDecoder(io.LimitReader(r, maxResponse)).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("decode translation response: %w", err)
+	}
+	if len(resp.Texts) != want {
+		return nil, fmt.Errorf("translation response has %d texts, want %d", len(resp.Texts), want)
+	}
+	return resp.Texts, nil
+}
+
+// Store caches translations, keyed by Key of the source text
+type Store interface {
+	GetTranslations(ctx context.Context, language string, keys []string) (map[string]string, error)
+	SaveTranslations(ctx context.Context, language string, translations map[string]string) error
+}
+
+// Key identifies a source text in a Store
+func Key(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:])
+}
+
+// Cache translates texts into one language, asking the Translator only for
+// texts the Store does not already hold
+type Cache struct {
+	Translator Translator
+	Store      Store
+	Target     string
+	Timeout    time.Duration // Time limit for each request to the Translator (0 = none)
+}
+
+// Translate returns the translation of each text. Empty texts are returned
+// as they are. Translations made before an error are kept in the Store, so
+// a later run resumes where this one failed.
+func (c Cache) Translate(ctx context.Context, texts []string) ([]string, error) {
+	keys := make([]string, len(texts))
+	for i, text := range texts {
+		keys[i] = Key(text)
+	}
+	cached, err := c.Store.GetTranslations(ctx, c.Target, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	// Ask for each missing text once
+	var missing []string
+	seen := make(map[string]bool)
+	for i, text := range texts {
+		if text == "" || seen[keys[i]] {
+			continue
+		}
+		seen[keys[i]] = true
+		if _, ok := cached[keys[i]]; !ok {
+			missing = append(missing, text)
+		}
+	}
+
+	var errs error
+	for start := 0; start < len(missing); start += maxBatch {
+		batch := missing[start:min(start+maxBatch, len(missing))]
+		translated, err := c.translate(ctx, Request{Target: c.Target, Texts: batch})
+		if err != nil {
+			errs = err
+			break
+		}
+		fresh := make(map[string]string, len(batch))
+		for i, text := range batch {
+			fresh[Key(text)] = translated[i]
+			cached[Key(text)] = translated[i]
+		}
+		if err := c.Store.SaveTranslations(ctx, c.Target, fresh); err != nil {
+			errs = errors.Join(errs, err)
+		}
+	}
+	if errs != nil {
+		return nil, errs
+	}
+
+	out := make([]string, len(texts))
+	for i, text := range texts {
+		if t, ok := cached[keys[i]]; ok {
+			out[i] = t
+		} else {
+			out[i] = text
+		}
+	}
+	return out, nil
+}
+
+// translate makes one request to the Translator within the time limit
+func (c Cache) translate(ctx context.Context, req Request) ([]string, error) {
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+	texts, err := c.Translator.Translate(ctx, req)
+	if c.Timeout > 0 && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return nil, fmt.Errorf("translation timed out after %s", c.Timeout)
+	}
+	return texts, err
+}
+
+// PlainText returns the text of an HTML fragment, with entities decoded and
+// runs of whitespace collapsed, for sending to a Translator
+func PlainText(fragment string) string {
+	z := html.NewTokenizer(strings.NewReader(fragment))
+	var b strings.Builder
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return strings.Join(strings.Fields(b.String()), " ")
+		case html.TextToken:
+			b.Write(z.Text())
+		default:
+			// Tags separate words
+			b.WriteByte(' ')
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package translate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// memoryStore is a Store backed by a map
+type memoryStore map[string]string
+
+func (m memoryStore) GetTranslations(_ context.Context, language string, keys []string) (map[string]string, error) {
+	out := make(map[string]string)
+	for _, k := range keys {
+		if t, ok := m[language+"/"+k]; ok {
+			out[k] = t
+		}
+	}
+	return out, nil
+}
+
+func (m memoryStore) SaveTranslations(_ context.Context, language string, translations map[string]string) error {
+	for k, t := range translations {
+		m[language+"/"+k] = t
+	}
+	return nil
+}
+
+// upper translates by upper-casing, recording each request
+type upper struct {
+	requests [][]string
+	err      error
+}
+
+func (u *upper) Translate(_ context.Context, req Request) ([]string, error) {
+	u.requests = append(u.requests, req.Texts)
+	if u.err != nil {
+		return nil, u.err
+	}
+	out := make([]string, len(req.Texts))
+	for i, text := range req.Texts {
+		out[i] = strings.ToUpper(text)
+	}
+	return out, nil
+}
+
+func TestCacheTranslate(t *testing.T) {
+	t.Parallel()
+	store := memoryStore{}
+	tr := &upper{}
+	cache := Cache{Translator: tr, Store: store, Target: "en"}
+
+	got, err := cache.Translate(context.Background(), []string{"hola", "", "adiós", "hola"})
+	if err != nil {
+		t.Fatalf("Translate() error = %v", err)
+	}
+	if want := []string{"HOLA", "", "ADIÓS", "HOLA"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Translate() = %q, want %q", got, want)
+	}
+	// Duplicates and empty texts are not sent
+	if want := [][]string{{"hola", "adiós"}}; !reflect.DeepEqual(tr.requests, want) {
+		t.Errorf("requests = %q, want %q", tr.requests, want)
+	}
+
+	// Cached translations are not requested again
+	got, err = cache.Translate(context.Background(), []string{"adiós", "gracias"})
+	if err != nil {
+		t.Fatalf("Translate() error = %v", err)
+	}
+	if want := []string{"ADIÓS", "GRACIAS"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Translate() = %q, want %q", got, want)
+	}
+	if want := []string{"gracias"}; !reflect.DeepEqual(tr.requests[1], want) {
+		t.Errorf("second request = %q, want %q", tr.requests[1], want)
+	}
+
+	// The cache is per language
+	cache.Target = "de"
+	if _, err := cache.Translate(context.Background(), []string{"hola"}); err != nil {
+		t.Fatalf("Translate() error = %v", err)
+	}
+	if len(tr.requests) != 3 {
+		t.Errorf("requests = %d, want a request for the new language", len(tr.requests))
+	}
+}
+
+func TestCacheTranslateBatches(t *testing.T) {
+	t.Parallel()
+	tr := &upper{}
+	cache := Cache{Translator: tr, Store: memoryStore{}, Target: "en"}
+
+	texts := make([]string, maxBatch+1)
+	for i := range texts {
+		texts[i] = strings.Repeat("a", i+1)
+	}
+	if _, err := cache.Translate(context.Background(), texts); err != nil {
+		t.Fatalf("Translate() error = %v", err)
+	}
+	if len(tr.requests) != 2 || len(tr.requests[0]) != maxBatch || len(tr.requests[1]) != 1 {
+		t.Errorf("requests of %d texts, want %d split into batches of at most %d", len(texts), len(texts), maxBatch)
+	}
+}
+
+func TestCacheTranslateError(t *testing.T) {
+	t.Parallel()
+	tr := &upper{err: errors.New("quota exceeded")}
+	cache := Cache{Translator: tr, Store: memoryStore{}, Target: "en"}
+
+	if _, err := cache.Translate(context.Background(), []string{"hola"}); err == nil || !strings.Contains(err.Error(), "quota exceeded") {
+		t.Errorf("Translate() error = %v, want the translator's error", err)
+	}
+}
+
+func TestCacheTranslateTimeout(t *testing.T) {
+	t.Parallel()
+	slow := translatorFunc(func(ctx context.Context, _ Request) ([]string, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	cache := Cache{Translator: slow, Store: memoryStore{}, Target: "en", Timeout: 10 * time.Millisecond}
+
+	if _, err := cache.Translate(context.Background(), []string{"hola"}); err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("Translate() error = %v, want a timeout", err)
+	}
+}
+
+type translatorFunc func(context.Context, Request) ([]string, error)
+
+func (f translatorFunc) Translate(ctx context.Context, req Request) ([]string, error) {
+	return f(ctx, req)
+}
+
+func TestHTTPTranslate(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req Request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		resp := Response{}
+		for _, text := range req.Texts {
+			resp.Texts = append(resp.Texts, req.Target+":"+text)
+		}
+		if len(req.Texts) > 1 {
+			// Answer too few texts to a batch
+			resp.Texts = resp.Texts[:1]
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	h := HTTP{URL: server.URL}
+	got, err := h.Translate(context.Background(), Request{Target: "en", Texts: []string{"hola"}})
+	if err != nil {
+		t.Fatalf("Translate() error = %v", err)
+	}
+	if want := []string{"en:hola"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Translate() = %q, want %q", got, want)
+	}
+
+	if _, err := h.Translate(context.Background(), Request{Target: "en", Texts: []string{"a", "b"}}); err == nil {
+		t.Error("Translate() accepted a response with the wrong number of texts")
+	}
+}
+
+func TestHTTPTranslateStatus(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "no", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	if _, err := (HTTP{URL: server.URL}).Translate(context.Background(), Request{Target: "en", Texts: []string{"hola"}}); err == nil || !strings.Contains(err.Error(), "503") {
+		t.Errorf("Translate() error = %v, want the server's status", err)
+	}
+}
+
+func TestPlainText(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		in, want string
+	}{
+		{"", ""},
+		{"Plain title", "Plain title"},
+		{"Fish &amp; chips", "Fish & chips"},
+		{"<p>One</p><p>Two  <em>three</em></p>", "One Two three"},
+		{"  spaced\n\tout  ", "spaced out"},
+	}
+	for _, tt := range tests {
+		if got := PlainText(tt.in); got != tt.want {
+			t.Errorf("PlainText(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+// Package webhook posts the entries a fetch run stores for the first time
+// to webhooks, for downstream automation such as chat channels and search
+// indexers.
+//
+// Entries are posted in batches as JSON. Besides the entries, each payload
+// has a one-line-per-entry "text" (Slack, Mattermost) and "content"
+// (Discord), so chat incoming webhooks accept it as it is. Requests that
+// fail with a network error, 429 Too Many Requests, or a 5xx status are
+// retried with exponential backoff, honouring Retry-After.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Feed is the feed of a posted entry
+type Feed struct {
+	URL   string `json:"url"`
+	Title string `json:"title"`
+	Link  string `json:"link,omitempty"`
+}
+
+// Entry is a posted entry. Its title and summary are plain text.
+type Entry struct {
+	Feed      Feed      `json:"feed"`
+	ID        string    `json:"id"`
+	Title     string    `json:"title"`
+	Link      string    `json:"link"`
+	Author    string    `json:"author,omitempty"`
+	Published time.Time `json:"published"`
+	Summary   string    `json:"summary,omitempty"`
+}
+
+// Payload is the JSON body of a request
+type Payload struct {
+	Text    string  `json:"text"`    // Slack, Mattermost
+	Content string  `json:"content"` // Discord
+	Entries []Entry `json:"entries"`
+}
+
+// discordLimit is the most characters Discord accepts in a message
+const discordLimit = 2000
+
+// DiscordContent returns text shortened, if need be, to fit in a Discord
+// message, ending in "…" where it was cut
+func DiscordContent(text string) string {
+	if r := []rune(text); len(r) > discordLimit {
+		return string(r[:discordLimit-1]) + "…"
+	}
+	return text
+}
+
+// maxRetryAfter caps the wait a server may ask for before a retry
+const maxRetryAfter = time.Minute
+
+// Sender posts entries to webhooks
+type Sender struct {
+	URLs       []string
+	BatchSize  int          // Most entries in one request; 0 sends them all in one
+	MaxRetries int          // Retries of a request that failed temporarily
+	Client     *http.Client // Defaults to a client with a 30 second timeout
+
+	backoff func(retry int) time.Duration // Wait before a retry; defaults to 1s, 2s, 4s, ...
+}
+
+// Send posts entries, in batches, to each webhook. A batch that still fails
+// after its retries is given up on; Send carries on with the other batches
+// and webhooks and returns the first error.
+func (s Sender) Send(ctx context.Context, entries []Entry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	size := s.BatchSize
+	if size <= 0 {
+		size = len(entries)
+	}
+
+	var firstErr error
+	for _, url := range s.URLs {
+		for start := 0; start < len(entries); start += size {
+			batch := entries[start:min(start+size, len(entries))]
+			if err := s.post(ctx, url, batch); err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				if ctx.Err() != nil {
+					return firstErr
+				}
+			}
+		}
+	}
+	return firstErr
+}
+
+// post posts a batch to a webhook, retrying temporary failures
+func (s Sender) post(ctx context.Context, url string, batch []Entry) error {
+	body, err := json.Marshal(newPayload(batch))
+	if err != nil {
+		return fmt.Errorf("encode webhook payload: %w", err)
+	}
+	client := s.Client
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+
+	for retry := 0; ; retry++ {
+		wait, err := s.attempt(ctx, client, url, body)
+		if err == nil {
+			return nil
+		}
+		if wait < 0 || retry >= s.MaxRetries {
+			return fmt.Errorf("post %d entries to webhook: %w", len(batch), err)
+		}
+		if wait == 0 {
+			wait = s.backoffFor(retry)
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("post %d entries to webhook: %w", len(batch), err)
+		case <-time.After(wait):
+		}
+	}
+}
+
+// attempt makes one request. On failure it returns how long the server
+// asked to wait before retrying, 0 to use the backoff, or -1 if the
+// request should not be retried.
+func (s Sender) attempt(ctx context.Context, client *http.Client, url string, body []byte) (time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return -1, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode <= 299:
+		return 0, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return retryAfter(resp.Header.Get("Retry-After")), fmt.Errorf("server returned %s", resp.Status)
+	default:
+		return -1, fmt.Errorf("server returned %s", resp.Status)
+	}
+}
+
+func (s Sender) backoffFor(retry int) time.Duration {
+	if s.backoff != nil {
+		return s.backoff(retry)
+	}
+	return time.Second << retry
+}
+
+// retryAfter parses a Retry-After header given in seconds, capped at
+// maxRetryAfter; anything else is 0
+func retryAfter(header string) time.Duration {
+	seconds, err := strconv.Atoi(header)
+	if err != nil || seconds <= 0 {
+		return 0
+	}
+	return min(time.Duration(seconds)*time.Second, maxRetryAfter)
+}
+
+// newPayload returns the payload posting a batch of entries
+func newPayload(batch []Entry) Payload {
+	var b strings.Builder
+	for _, e := range batch {
+		title := e.Title
+		if title == "" {
+			title = e.Link
+		}
+		fmt.Fprintf(&b, "%s (%s): %s\n", title, e.Feed.Title, e.Link)
+	}
+	text := b.String()
+	return Payload{Text: text, Content: DiscordContent(text), Entries: batch}
+}
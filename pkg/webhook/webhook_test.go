@@ -0,0 +1,152 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func testEntries(n int) []Entry {
+	entries := make([]Entry, n)
+	for i := range entries {
+		entries[i] = Entry{
+			Feed:  Feed{URL: "https://example.com/feed", Title: "Example"},
+			ID:    string(rune('a' + i)),
+			Title: "Post " + string(rune('a'+i)),
+			Link:  "https://example.com/" + string(rune('a'+i)),
+		}
+	}
+	return entries
+}
+
+// recorder is a webhook that answers with the statuses given, then 200, and
+// records the payloads it is sent
+type recorder struct {
+	mu       sync.Mutex
+	statuses []int
+	payloads []Payload
+}
+
+func (rec *recorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var p Payload
+	json.NewDecoder(r.Body).Decode(&p)
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	rec.payloads = append(rec.payloads, p)
+	if len(rec.statuses) > 0 {
+		status := rec.statuses[0]
+		rec.statuses = rec.statuses[1:]
+		w.WriteHeader(status)
+	}
+}
+
+func noBackoff(int) time.Duration { return time.Millisecond }
+
+func TestSendBatches(t *testing.T) {
+	t.Parallel()
+	a, b := &recorder{}, &recorder{}
+	serverA, serverB := httptest.NewServer(a), httptest.NewServer(b)
+	defer serverA.Close()
+	defer serverB.Close()
+
+	s := Sender{URLs: []string{serverA.URL, serverB.URL}, BatchSize: 2}
+	if err := s.Send(context.Background(), testEntries(5)); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	for _, rec := range []*recorder{a, b} {
+		if len(rec.payloads) != 3 || len(rec.payloads[0].Entries) != 2 || len(rec.payloads[2].Entries) != 1 {
+			t.Fatalf("payloads = %+v, want batches of 2, 2, and 1", rec.payloads)
+		}
+	}
+	p := a.payloads[0]
+	if p.Text != "Post a (Example): https://example.com/a\nPost b (Example): https://example.com/b\n" || p.Content != p.Text {
+		t.Errorf("text = %q, content = %q", p.Text, p.Content)
+	}
+
+	if err := s.Send(context.Background(), nil); err != nil || len(a.payloads) != 3 {
+		t.Errorf("Send() of no entries = %v, %d requests", err, len(a.payloads))
+	}
+}
+
+func TestSendRetries(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		statuses []int
+		retries  int
+		wantErr  bool
+		requests int
+	}{
+		{"retried until success", []int{503, 429}, 3, false, 3},
+		{"retries exhausted", []int{500, 500, 500}, 2, true, 3},
+		{"client error not retried", []int{400}, 3, true, 1},
+		{"no retries", []int{502}, 0, true, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			rec := &recorder{statuses: tt.statuses}
+			server := httptest.NewServer(rec)
+			defer server.Close()
+
+			s := Sender{URLs: []string{server.URL}, MaxRetries: tt.retries, backoff: noBackoff}
+			err := s.Send(context.Background(), testEntries(1))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Send() error = %v, want error %v", err, tt.wantErr)
+			}
+			if len(rec.payloads) != tt.requests {
+				t.Errorf("%d requests, want %d", len(rec.payloads), tt.requests)
+			}
+		})
+	}
+}
+
+func TestSendCarriesOn(t *testing.T) {
+	t.Parallel()
+	// The first batch fails; the second is still sent
+	rec := &recorder{statuses: []int{404}}
+	server := httptest.NewServer(rec)
+	defer server.Close()
+
+	s := Sender{URLs: []string{server.URL}, BatchSize: 1, MaxRetries: 2, backoff: noBackoff}
+	err := s.Send(context.Background(), testEntries(2))
+	if err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("Send() error = %v, want the 404", err)
+	}
+	if len(rec.payloads) != 2 {
+		t.Errorf("%d requests, want 2", len(rec.payloads))
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	t.Parallel()
+	for header, want := range map[string]time.Duration{
+		"":      0,
+		"5":     5 * time.Second,
+		"3600":  maxRetryAfter,
+		"-1":    0,
+		"later": 0,
+	} {
+		if got := retryAfter(header); got != want {
+			t.Errorf("retryAfter(%q) = %s, want %s", header, got, want)
+		}
+	}
+}
+
+func TestPayloadDiscordLimit(t *testing.T) {
+	t.Parallel()
+	entries := testEntries(1)
+	entries[0].Title = strings.Repeat("x", 3000)
+	p := newPayload(entries)
+	if n := len([]rune(p.Content)); n != discordLimit {
+		t.Errorf("content is %d characters, want %d", n, discordLimit)
+	}
+	if len(p.Text) < 3000 {
+		t.Error("text should not be shortened")
+	}
+}